@@ -0,0 +1,486 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mattermost/rtcd/service"
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/signaling"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+)
+
+const callMsgChSize = 64
+
+// ErrCallClosed is returned when acting on a call which was left or closed
+// by the service.
+var ErrCallClosed = errors.New("call is closed")
+
+// CloseError is the reason a call got closed by the service.
+type CloseError struct {
+	// Reason is the machine readable reason the session was closed with
+	// (e.g. rtc.CloseReasonKicked or rtc.CloseReasonNetworkTimeout).
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return "session closed: " + e.Reason
+}
+
+type remoteTrack struct {
+	track    *webrtc.TrackRemote
+	receiver *webrtc.RTPReceiver
+}
+
+// Call is a session taking part in a call. It's safe for concurrent use.
+type Call struct {
+	cfg    CallConfig
+	client *Client
+	pc     *webrtc.PeerConnection
+
+	msgCh       chan rtc.Message
+	negotiateCh chan struct{}
+	closeCh     chan struct{}
+	connectedCh chan struct{}
+	closeErr    error
+
+	onTrack        func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver)
+	onMaintenance  func(notice rtc.MaintenanceNotice)
+	pendingTracks  []remoteTrack
+	screenStreamID string
+
+	// pendingCandidates holds the remote candidates received before the
+	// remote description was set. It's only accessed by the message loop.
+	pendingCandidates []webrtc.ICECandidateInit
+
+	mut sync.Mutex
+}
+
+func newCall(client *Client, cfg CallConfig, pc *webrtc.PeerConnection) *Call {
+	c := &Call{
+		cfg:         cfg,
+		client:      client,
+		pc:          pc,
+		msgCh:       make(chan rtc.Message, callMsgChSize),
+		negotiateCh: make(chan struct{}, 1),
+		closeCh:     make(chan struct{}),
+		connectedCh: make(chan struct{}),
+	}
+
+	var connOnce sync.Once
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateConnected:
+			connOnce.Do(func() { close(c.connectedCh) })
+		case webrtc.PeerConnectionStateFailed:
+			c.close(fmt.Errorf("peer connection failed"))
+		}
+	})
+
+	pc.OnICECandidate(func(candidate *webrtc.ICECandidate) {
+		if candidate == nil {
+			return
+		}
+		data, err := json.Marshal(candidate.ToJSON())
+		if err != nil {
+			c.sendError(fmt.Errorf("failed to marshal ICE candidate: %w", err))
+			return
+		}
+		if err := c.send(rtc.ICEMessage, data); err != nil {
+			c.sendError(fmt.Errorf("failed to send ICE candidate: %w", err))
+		}
+	})
+
+	pc.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		c.mut.Lock()
+		cb := c.onTrack
+		if cb == nil {
+			c.pendingTracks = append(c.pendingTracks, remoteTrack{track, receiver})
+		}
+		c.mut.Unlock()
+		if cb != nil {
+			cb(track, receiver)
+		}
+	})
+
+	// Offers are only sent from the message loop so that they don't
+	// interleave with the ones received.
+	pc.OnNegotiationNeeded(func() {
+		select {
+		case c.negotiateCh <- struct{}{}:
+		default:
+		}
+	})
+
+	return c
+}
+
+// start begins the negotiation of the media connection.
+func (c *Call) start() error {
+	go c.msgLoop()
+
+	// A media section is needed to negotiate the initial connection, tracks
+	// get added by the service as they are published.
+	if _, err := c.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{
+		Direction: webrtc.RTPTransceiverDirectionRecvonly,
+	}); err != nil {
+		return fmt.Errorf("failed to add transceiver: %w", err)
+	}
+
+	return nil
+}
+
+// SessionID returns the ID of the session the call was joined with.
+func (c *Call) SessionID() string {
+	return c.cfg.SessionID
+}
+
+// SetJoinToken replaces the join token presented as the session reconnects.
+// Join tokens expiring, a fresh one should be set for the session to be
+// able to reconnect past the expiration of the one it joined with.
+func (c *Call) SetJoinToken(token string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.cfg.JoinToken = token
+}
+
+func (c *Call) joinToken() string {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.cfg.JoinToken
+}
+
+// Connected returns a channel closed once the media connection is
+// established.
+func (c *Call) Connected() <-chan struct{} {
+	return c.connectedCh
+}
+
+// Done returns a channel closed once the call is left or got closed by the
+// service.
+func (c *Call) Done() <-chan struct{} {
+	return c.closeCh
+}
+
+// Err returns the reason the call got closed, once Done is closed. It's nil
+// if the call was left normally.
+func (c *Call) Err() error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.closeErr
+}
+
+// OnTrack registers a callback to be called, in its own goroutine, for every
+// track forwarded to the session. Tracks received before the callback got
+// registered are passed to it right away.
+func (c *Call) OnTrack(cb func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver)) {
+	c.mut.Lock()
+	c.onTrack = cb
+	pending := c.pendingTracks
+	c.pendingTracks = nil
+	c.mut.Unlock()
+
+	if cb == nil {
+		return
+	}
+	for _, t := range pending {
+		go cb(t.track, t.receiver)
+	}
+}
+
+// OnMaintenance registers a callback to be called when the service notifies
+// the participants of an upcoming maintenance window of the node hosting the
+// call, or of its cancellation.
+func (c *Call) OnMaintenance(cb func(notice rtc.MaintenanceNotice)) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.onMaintenance = cb
+}
+
+// PublishTrack sends the given track to the other participants. The first
+// audio track is the voice of the session.
+func (c *Call) PublishTrack(track webrtc.TrackLocal) (*webrtc.RTPSender, error) {
+	if c.isClosed() {
+		return nil, ErrCallClosed
+	}
+
+	sender, err := c.pc.AddTrack(track)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add track: %w", err)
+	}
+
+	// RTCP packets need to be read for the interceptors to process them.
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			if _, _, err := sender.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	return sender, nil
+}
+
+// PublishScreen shares the given video track as the screen of the session.
+// Audio tracks published with the same stream ID are forwarded as the audio
+// of the screen. Only one session can share its screen at a time.
+func (c *Call) PublishScreen(track webrtc.TrackLocal) (*webrtc.RTPSender, error) {
+	if track.Kind() != webrtc.RTPCodecTypeVideo {
+		return nil, fmt.Errorf("invalid screen track: should be video")
+	}
+
+	data, err := json.Marshal(map[string]string{"screenStreamID": track.StreamID()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal screen data: %w", err)
+	}
+	if err := c.send(rtc.ScreenOnMessage, data); err != nil {
+		return nil, fmt.Errorf("failed to send screen message: %w", err)
+	}
+
+	c.mut.Lock()
+	c.screenStreamID = track.StreamID()
+	c.mut.Unlock()
+
+	return c.PublishTrack(track)
+}
+
+// UnpublishTrack stops sending the track of the given sender. Screen sharing
+// stops along with its video track.
+func (c *Call) UnpublishTrack(sender *webrtc.RTPSender) error {
+	if c.isClosed() {
+		return ErrCallClosed
+	}
+
+	track := sender.Track()
+	if err := c.pc.RemoveTrack(sender); err != nil {
+		return fmt.Errorf("failed to remove track: %w", err)
+	}
+
+	c.mut.Lock()
+	screenOff := track != nil && track.Kind() == webrtc.RTPCodecTypeVideo && track.StreamID() == c.screenStreamID
+	if screenOff {
+		c.screenStreamID = ""
+	}
+	c.mut.Unlock()
+
+	if screenOff {
+		if err := c.send(rtc.ScreenOffMessage, nil); err != nil {
+			return fmt.Errorf("failed to send screen message: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Mute stops the voice track of the session from being forwarded.
+func (c *Call) Mute() error {
+	return c.send(rtc.MuteMessage, nil)
+}
+
+// Unmute resumes forwarding the voice track of the session.
+func (c *Call) Unmute() error {
+	return c.send(rtc.UnmuteMessage, nil)
+}
+
+// RequestKeyFrame asks the sender of the given video track for a key frame,
+// e.g. to start decoding it.
+func (c *Call) RequestKeyFrame(track *webrtc.TrackRemote) error {
+	return c.pc.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: uint32(track.SSRC())}})
+}
+
+// Leave leaves the call and closes the media connection, which ends the
+// received tracks.
+func (c *Call) Leave() error {
+	if c.isClosed() {
+		return ErrCallClosed
+	}
+
+	err := c.client.send(service.ClientMessage{Type: service.ClientMessageLeave, Data: signaling.LeaveData{
+		SessionID: c.cfg.SessionID,
+	}.Map()})
+	c.close(nil)
+	if err != nil {
+		return fmt.Errorf("failed to send leave message: %w", err)
+	}
+	return nil
+}
+
+func (c *Call) isClosed() bool {
+	select {
+	case <-c.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// close releases the resources of the call, err is the reason, if abnormal.
+func (c *Call) close(err error) {
+	c.mut.Lock()
+	if c.isClosed() {
+		c.mut.Unlock()
+		return
+	}
+	c.closeErr = err
+	close(c.closeCh)
+	c.mut.Unlock()
+
+	c.client.removeCall(c.cfg.SessionID)
+	if err := c.pc.Close(); err != nil {
+		c.sendError(fmt.Errorf("failed to close peer connection: %w", err))
+	}
+}
+
+func (c *Call) sendError(err error) {
+	c.client.sendError(fmt.Errorf("session %s: %w", c.cfg.SessionID, err))
+}
+
+func (c *Call) send(msgType rtc.MessageType, data []byte) error {
+	return c.client.send(service.ClientMessage{Type: service.ClientMessageRTC, Data: rtc.Message{
+		GroupID:   c.cfg.GroupID,
+		UserID:    c.cfg.UserID,
+		SessionID: c.cfg.SessionID,
+		Type:      msgType,
+		Data:      data,
+	}})
+}
+
+// push queues a signaling message received for the session. Messages are
+// handled in order without blocking the caller.
+func (c *Call) push(msg rtc.Message) {
+	select {
+	case c.msgCh <- msg:
+	default:
+		c.sendError(fmt.Errorf("failed to push message: channel is full"))
+	}
+}
+
+func (c *Call) msgLoop() {
+	for {
+		select {
+		case msg := <-c.msgCh:
+			if err := c.handleMsg(msg); err != nil && !c.isClosed() {
+				c.sendError(fmt.Errorf("failed to handle message: %w", err))
+			}
+		case <-c.negotiateCh:
+			// Negotiation is needed again once back to stable.
+			if c.pc.SignalingState() != webrtc.SignalingStateStable {
+				continue
+			}
+			if err := c.offer(); err != nil && !c.isClosed() {
+				c.sendError(err)
+			}
+		case <-c.closeCh:
+			return
+		}
+	}
+}
+
+func (c *Call) offer() error {
+	offer, err := c.pc.CreateOffer(nil)
+	if err != nil {
+		return fmt.Errorf("failed to create offer: %w", err)
+	}
+	if err := c.pc.SetLocalDescription(offer); err != nil {
+		return fmt.Errorf("failed to set local description: %w", err)
+	}
+	data, err := json.Marshal(c.pc.LocalDescription())
+	if err != nil {
+		return fmt.Errorf("failed to marshal sdp: %w", err)
+	}
+	return c.send(rtc.SDPMessage, data)
+}
+
+func (c *Call) handleMsg(msg rtc.Message) error {
+	// The message kind is inferred from the payload.
+	var data struct {
+		Type       string                    `json:"type"`
+		Candidate  webrtc.ICECandidateInit   `json:"candidate"`
+		Candidates []webrtc.ICECandidateInit `json:"candidates"`
+		SDP        string                    `json:"sdp"`
+	}
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		return fmt.Errorf("failed to unmarshal message: %w", err)
+	}
+
+	switch data.Type {
+	case "candidate":
+		return c.addCandidates(data.Candidate)
+	case "candidates":
+		return c.addCandidates(data.Candidates...)
+	case "offer", "answer":
+		sdp := webrtc.SessionDescription{
+			Type: webrtc.NewSDPType(data.Type),
+			SDP:  data.SDP,
+		}
+
+		// The service ignores our offer when both sides started negotiating
+		// at the same time, so ours is dropped in favor of its own.
+		if sdp.Type == webrtc.SDPTypeOffer && c.pc.SignalingState() == webrtc.SignalingStateHaveLocalOffer {
+			if err := c.pc.SetLocalDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeRollback}); err != nil {
+				return fmt.Errorf("failed to rollback local description: %w", err)
+			}
+		}
+
+		if err := c.pc.SetRemoteDescription(sdp); err != nil {
+			return fmt.Errorf("failed to set remote description: %w", err)
+		}
+		pending := c.pendingCandidates
+		c.pendingCandidates = nil
+		if err := c.addCandidates(pending...); err != nil {
+			return err
+		}
+
+		if sdp.Type != webrtc.SDPTypeOffer {
+			return nil
+		}
+
+		answer, err := c.pc.CreateAnswer(nil)
+		if err != nil {
+			return fmt.Errorf("failed to create answer: %w", err)
+		}
+		if err := c.pc.SetLocalDescription(answer); err != nil {
+			return fmt.Errorf("failed to set local description: %w", err)
+		}
+		js, err := json.Marshal(c.pc.LocalDescription())
+		if err != nil {
+			return fmt.Errorf("failed to marshal sdp: %w", err)
+		}
+		return c.send(rtc.SDPMessage, js)
+	case "maintenance":
+		var notice rtc.MaintenanceNotice
+		if err := json.Unmarshal(msg.Data, &notice); err != nil {
+			return fmt.Errorf("failed to unmarshal maintenance notice: %w", err)
+		}
+		c.mut.Lock()
+		cb := c.onMaintenance
+		c.mut.Unlock()
+		if cb != nil {
+			cb(notice)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unexpected message type: %q", data.Type)
+	}
+}
+
+func (c *Call) addCandidates(candidates ...webrtc.ICECandidateInit) error {
+	if c.pc.RemoteDescription() == nil {
+		c.pendingCandidates = append(c.pendingCandidates, candidates...)
+		return nil
+	}
+	for _, candidate := range candidates {
+		if err := c.pc.AddICECandidate(candidate); err != nil {
+			return fmt.Errorf("failed to add ICE candidate: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/logger"
+	"github.com/mattermost/rtcd/service"
+	"github.com/mattermost/rtcd/service/api"
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/stretchr/testify/require"
+)
+
+const testAuthKey = "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H"
+
+// setupTestService starts a service with a registered client, returning the
+// config to connect as that client.
+func setupTestService(t *testing.T) service.ClientConfig {
+	t.Helper()
+
+	// Finding a free port for the API.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+	apiURL := "http://127.0.0.1:" + strconv.Itoa(port)
+
+	cfg := service.Config{
+		API: service.APIConfig{
+			HTTP: api.Config{
+				ListenAddress: "127.0.0.1:" + strconv.Itoa(port),
+			},
+			Security: service.SecurityConfig{
+				EnableAdmin:    true,
+				AdminSecretKey: "admin_secret_key",
+			},
+		},
+		RTC: rtc.ServerConfig{
+			ICEPortUDP: 30473,
+		},
+		Store: service.StoreConfig{
+			DataSource: t.TempDir(),
+		},
+		Logger: logger.Config{
+			EnableConsole: true,
+			ConsoleLevel:  "ERROR",
+		},
+	}
+	cfg.API.Security.SessionCache.ExpirationMinutes = 1440
+	srvc, err := service.New(cfg)
+	require.NoError(t, err)
+	require.NoError(t, srvc.Start())
+	t.Cleanup(func() {
+		require.NoError(t, srvc.Stop())
+	})
+
+	adminClient, err := service.NewClient(service.ClientConfig{URL: apiURL, AuthKey: cfg.API.Security.AdminSecretKey})
+	require.NoError(t, err)
+	require.NoError(t, adminClient.Register("clientA", testAuthKey))
+
+	return service.ClientConfig{URL: apiURL, ClientID: "clientA", AuthKey: testAuthKey}
+}
+
+func newTestClient(t *testing.T, svcCfg service.ClientConfig) *Client {
+	t.Helper()
+	c, err := New(Config{Service: svcCfg})
+	require.NoError(t, err)
+	c.OnError(func(err error) {
+		t.Logf("client error: %s", err.Error())
+	})
+	require.NoError(t, c.Connect())
+	return c
+}
+
+func writeTestSamples(track *webrtc.TrackLocalStaticSample, data []byte, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = track.WriteSample(media.Sample{Data: data, Duration: 20 * time.Millisecond})
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func waitFor(t *testing.T, ch <-chan struct{}, msg string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "timed out waiting for "+msg)
+	}
+}
+
+func TestCall(t *testing.T) {
+	svcCfg := setupTestService(t)
+
+	pubClient := newTestClient(t, svcCfg)
+	defer pubClient.Close()
+	subClient := newTestClient(t, svcCfg)
+	defer subClient.Close()
+
+	t.Run("invalid config", func(t *testing.T) {
+		_, err := pubClient.JoinCall(CallConfig{UserID: "userA"})
+		require.EqualError(t, err, "invalid call config: invalid CallID value: should not be empty")
+	})
+
+	pub, err := pubClient.JoinCall(CallConfig{CallID: "callA", UserID: "publisher"})
+	require.NoError(t, err)
+	require.NotEmpty(t, pub.SessionID())
+
+	t.Run("duplicate session", func(t *testing.T) {
+		_, err := pubClient.JoinCall(CallConfig{CallID: "callA", UserID: "publisher", SessionID: pub.SessionID()})
+		require.EqualError(t, err, "session \""+pub.SessionID()+"\" already joined")
+	})
+
+	audioTrack, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", random.NewID())
+	require.NoError(t, err)
+	_, err = pub.PublishTrack(audioTrack)
+	require.NoError(t, err)
+	waitFor(t, pub.Connected(), "publisher to connect")
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go writeTestSamples(audioTrack, []byte{0xf8, 0xff, 0xfe}, stopCh)
+
+	sub, err := subClient.JoinCall(CallConfig{CallID: "callA", UserID: "subscriber"})
+	require.NoError(t, err)
+
+	audioCh := make(chan struct{})
+	videoCh := make(chan struct{})
+	var audioPackets, videoPackets int32
+	sub.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		count, doneCh := &audioPackets, audioCh
+		if track.Kind() == webrtc.RTPCodecTypeVideo {
+			count, doneCh = &videoPackets, videoCh
+			require.NoError(t, sub.RequestKeyFrame(track))
+		}
+		for {
+			if _, _, err := track.ReadRTP(); err != nil {
+				return
+			}
+			if atomic.AddInt32(count, 1) == 10 {
+				close(doneCh)
+			}
+		}
+	})
+
+	t.Run("receive track", func(t *testing.T) {
+		waitFor(t, sub.Connected(), "subscriber to connect")
+		waitFor(t, audioCh, "audio packets")
+	})
+
+	t.Run("mute", func(t *testing.T) {
+		mutedCh := make(chan struct{})
+		subClient.Service().OnSessionMuted(func(ev rtc.Event) {
+			if ev.SessionID == pub.SessionID() {
+				close(mutedCh)
+			}
+		})
+		require.NoError(t, pub.Mute())
+		waitFor(t, mutedCh, "session to be muted")
+	})
+
+	t.Run("screen sharing", func(t *testing.T) {
+		_, err := pub.PublishScreen(audioTrack)
+		require.EqualError(t, err, "invalid screen track: should be video")
+
+		videoTrack, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}, "video", random.NewID())
+		require.NoError(t, err)
+		sender, err := pub.PublishScreen(videoTrack)
+		require.NoError(t, err)
+		go writeTestSamples(videoTrack, append([]byte{0x10, 0x02, 0x00, 0x9d, 0x01, 0x2a, 0x10, 0x00, 0x10, 0x00}, make([]byte, 256)...), stopCh)
+
+		waitFor(t, videoCh, "video packets")
+		require.NoError(t, pub.UnpublishTrack(sender))
+	})
+
+	t.Run("leave", func(t *testing.T) {
+		require.NoError(t, pub.Leave())
+		waitFor(t, pub.Done(), "publisher to leave")
+		require.NoError(t, pub.Err())
+		require.ErrorIs(t, pub.Leave(), ErrCallClosed)
+		_, err := pub.PublishTrack(audioTrack)
+		require.ErrorIs(t, err, ErrCallClosed)
+
+		// Closing the client leaves the remaining calls.
+		require.NoError(t, subClient.Close())
+		waitFor(t, sub.Done(), "subscriber to leave")
+	})
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package client lets Go programs (bots, recorders, gateways) take part in the
+// calls hosted by rtcd. It drives the signaling protocol and the WebRTC peer
+// connection of every joined call so that callers only deal with tracks.
+package client
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mattermost/rtcd/service"
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/signaling"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Client is a signaling connection to the rtcd service, over which any
+// number of calls can be joined.
+type Client struct {
+	cfg     Config
+	svc     *service.Client
+	api     *webrtc.API
+	calls   map[string]*Call
+	errorCb func(err error)
+
+	// svcOpts and sEngine are set through options.
+	svcOpts []service.ClientOption
+	sEngine *webrtc.SettingEngine
+
+	mut sync.RWMutex
+}
+
+// New creates a new Client. Connect needs to be called before joining calls.
+func New(cfg Config, opts ...Option) (*Client, error) {
+	c := &Client{
+		cfg:   cfg,
+		calls: map[string]*Call{},
+	}
+
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, fmt.Errorf("failed to apply option: %w", err)
+		}
+	}
+
+	svc, err := service.NewClient(cfg.Service, c.svcOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service client: %w", err)
+	}
+	c.svc = svc
+
+	var m webrtc.MediaEngine
+	if err := m.RegisterDefaultCodecs(); err != nil {
+		return nil, fmt.Errorf("failed to register codecs: %w", err)
+	}
+
+	apiOpts := []func(*webrtc.API){webrtc.WithMediaEngine(&m)}
+	if c.sEngine != nil {
+		apiOpts = append(apiOpts, webrtc.WithSettingEngine(*c.sEngine))
+	}
+	c.api = webrtc.NewAPI(apiOpts...)
+
+	svc.OnRTCMessage(c.handleRTCMessage)
+	svc.OnSessionClose(c.handleSessionClose)
+	svc.OnReconnect(c.handleReconnect)
+	svc.OnError(c.sendError)
+
+	return c, nil
+}
+
+// Service returns the underlying service client, e.g. to subscribe to call
+// events. Its signaling callbacks (OnRTCMessage, OnSessionClose,
+// OnReconnect and OnError) are owned by the Client and must not be replaced.
+func (c *Client) Service() *service.Client {
+	return c.svc
+}
+
+// Connect opens the signaling connection.
+func (c *Client) Connect() error {
+	if err := c.svc.Connect(); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+
+	// Messages not handled through callbacks are of no use to calls.
+	go func() {
+		for range c.svc.ReceiveCh() {
+		}
+	}()
+
+	return nil
+}
+
+// OnError registers a callback to be called on errors which can't be
+// returned to the caller, e.g. failing to handle a signaling message. They
+// are discarded otherwise.
+func (c *Client) OnError(cb func(err error)) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.errorCb = cb
+}
+
+func (c *Client) sendError(err error) {
+	c.mut.RLock()
+	cb := c.errorCb
+	c.mut.RUnlock()
+	if cb != nil {
+		cb(err)
+	}
+}
+
+// JoinCall joins the call described by cfg and starts negotiating the media
+// connection. The returned Call can publish tracks right away, they are sent
+// once connected.
+func (c *Client) JoinCall(cfg CallConfig) (*Call, error) {
+	if err := cfg.IsValid(); err != nil {
+		return nil, fmt.Errorf("invalid call config: %w", err)
+	}
+	if cfg.SessionID == "" {
+		cfg.SessionID = random.NewID()
+	}
+
+	pcCfg := webrtc.Configuration{ICEServers: c.cfg.ICEServers}
+	if cfg.ICEPolicy.RelayOnly {
+		// Only relayed candidates would be accepted anyway.
+		pcCfg.ICETransportPolicy = webrtc.ICETransportPolicyRelay
+	}
+	pc, err := c.api.NewPeerConnection(pcCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create peer connection: %w", err)
+	}
+
+	call := newCall(c, cfg, pc)
+
+	c.mut.Lock()
+	if _, ok := c.calls[cfg.SessionID]; ok {
+		c.mut.Unlock()
+		_ = pc.Close()
+		return nil, fmt.Errorf("session %q already joined", cfg.SessionID)
+	}
+	c.calls[cfg.SessionID] = call
+	c.mut.Unlock()
+
+	data := signaling.JoinData{
+		CallID:         cfg.CallID,
+		UserID:         cfg.UserID,
+		SessionID:      cfg.SessionID,
+		GroupID:        cfg.GroupID,
+		Token:          cfg.JoinToken,
+		Hidden:         cfg.Hidden,
+		AudioOnly:      cfg.AudioOnly,
+		Locale:         cfg.Locale,
+		ICEForceRelay:  cfg.ICEPolicy.ForceRelay,
+		ICERelayOnly:   cfg.ICEPolicy.RelayOnly,
+		ICEDisableHost: cfg.ICEPolicy.DisableHost,
+		ICETURNRegion:  cfg.ICEPolicy.TURNRegion,
+	}
+	if err := c.svc.Send(service.ClientMessage{Type: service.ClientMessageJoin, Data: data.Map()}); err != nil {
+		call.close(nil)
+		return nil, fmt.Errorf("failed to join call: %w", err)
+	}
+
+	if err := call.start(); err != nil {
+		_ = call.Leave()
+		return nil, err
+	}
+
+	return call, nil
+}
+
+func (c *Client) getCall(sessionID string) *Call {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.calls[sessionID]
+}
+
+func (c *Client) removeCall(sessionID string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	delete(c.calls, sessionID)
+}
+
+func (c *Client) send(cm service.ClientMessage) error {
+	return c.svc.Send(cm)
+}
+
+func (c *Client) handleRTCMessage(msg rtc.Message) {
+	call := c.getCall(msg.SessionID)
+	if call == nil {
+		return
+	}
+	call.push(msg)
+}
+
+func (c *Client) handleSessionClose(sessionID, reason string) {
+	call := c.getCall(sessionID)
+	if call == nil {
+		return
+	}
+	var err error
+	if reason != "" && reason != rtc.CloseReasonLeft {
+		err = &CloseError{Reason: reason}
+	}
+	call.close(err)
+}
+
+// handleReconnect re-attaches the sessions to the new connection, along
+// with the last message received so that anything lost gets retransmitted.
+func (c *Client) handleReconnect(_ int) {
+	c.mut.RLock()
+	calls := make([]*Call, 0, len(c.calls))
+	for _, call := range c.calls {
+		calls = append(calls, call)
+	}
+	c.mut.RUnlock()
+
+	for _, call := range calls {
+		sessionID := call.SessionID()
+		lastSeq := c.svc.LastSeq(sessionID)
+		if err := c.send(service.ClientMessage{Type: service.ClientMessageReconnect, Data: signaling.ReconnectData{
+			SessionID: sessionID,
+			LastSeq:   &lastSeq,
+			Token:     call.joinToken(),
+		}.Map()}); err != nil {
+			c.sendError(fmt.Errorf("failed to reconnect session %q: %w", sessionID, err))
+		}
+	}
+}
+
+// Close leaves all the calls and closes the signaling connection.
+func (c *Client) Close() error {
+	c.mut.RLock()
+	calls := make([]*Call, 0, len(c.calls))
+	for _, call := range c.calls {
+		calls = append(calls, call)
+	}
+	c.mut.RUnlock()
+
+	for _, call := range calls {
+		if err := call.Leave(); err != nil {
+			c.sendError(fmt.Errorf("failed to leave call: %w", err))
+		}
+	}
+
+	return c.svc.Close()
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"fmt"
+
+	"github.com/mattermost/rtcd/service"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Config holds the information needed to create a new Client.
+type Config struct {
+	// Service configures the signaling connection to the rtcd service.
+	Service service.ClientConfig
+	// ICEServers lists the STUN/TURN servers the peer connections of the
+	// calls should use.
+	ICEServers []webrtc.ICEServer
+}
+
+// CallConfig holds the information needed to join a call.
+type CallConfig struct {
+	// CallID specifies the id of the call to join.
+	CallID string
+	// UserID specifies the id of the user joining the call.
+	UserID string
+	// SessionID specifies the unique identifier of the session. A random one
+	// is generated if empty.
+	SessionID string
+	// GroupID specifies the group the call belongs to. Defaults to the group
+	// of the client. Other groups need to be authenticated first through the
+	// underlying service client.
+	GroupID string
+	// JoinToken is the token authorizing the session to join the call, as
+	// issued through the join_token API with admin credentials. Only needed
+	// if join tokens are enforced by the service. It's presented again as
+	// the session reconnects, see Call.SetJoinToken.
+	JoinToken string
+	// Hidden joins the call as a receive-only participant, left out of the
+	// call state and participant limit.
+	Hidden bool
+	// AudioOnly makes the call reject any video track. It only applies if the
+	// session starts the call.
+	AudioOnly bool
+	// Locale optionally sets the locale (e.g. "pt-BR") announcements are
+	// played to the session in.
+	Locale string
+	// ICEPolicy optionally restricts the data path of the call. It only
+	// applies if the session starts the call, though RelayOnly also makes
+	// the session gather relayed candidates only.
+	ICEPolicy rtc.ICEPolicy
+}
+
+func (c CallConfig) IsValid() error {
+	if c.CallID == "" {
+		return fmt.Errorf("invalid CallID value: should not be empty")
+	}
+	if c.UserID == "" {
+		return fmt.Errorf("invalid UserID value: should not be empty")
+	}
+	return nil
+}
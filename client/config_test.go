@@ -0,0 +1,26 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallConfigIsValid(t *testing.T) {
+	cfg := CallConfig{
+		CallID: "callID",
+		UserID: "userID",
+	}
+	require.NoError(t, cfg.IsValid())
+
+	invalid := cfg
+	invalid.CallID = ""
+	require.EqualError(t, invalid.IsValid(), "invalid CallID value: should not be empty")
+
+	invalid = cfg
+	invalid.UserID = ""
+	require.EqualError(t, invalid.IsValid(), "invalid UserID value: should not be empty")
+}
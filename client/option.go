@@ -0,0 +1,30 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"github.com/mattermost/rtcd/service"
+
+	"github.com/pion/webrtc/v3"
+)
+
+type Option func(c *Client) error
+
+// WithServiceOptions sets the options the underlying service client is
+// created with, e.g. a custom dialing function.
+func WithServiceOptions(opts ...service.ClientOption) Option {
+	return func(c *Client) error {
+		c.svcOpts = append(c.svcOpts, opts...)
+		return nil
+	}
+}
+
+// WithSettingEngine sets the settings the peer connections of the calls are
+// created with, e.g. to run them over a virtual network.
+func WithSettingEngine(sEngine webrtc.SettingEngine) Option {
+	return func(c *Client) error {
+		c.sEngine = &sEngine
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mattermost/rtcd/rtcbench"
+)
+
+const benchUsage = `usage: rtcd bench [-run regexp] [-macro] [-baseline file] [-save file] [-tolerance percent]
+
+Runs the benchmarks of the media forwarding path, from the UDP sockets to
+whole calls run in process (macro benchmarks, left out unless -macro is set),
+and prints their results. When a baseline, as saved by a previous run, is
+given, the command fails if any result got worse than its baseline by more
+than the tolerance, so that it can gate releases.`
+
+type benchConfig struct {
+	Filter       string
+	Macro        bool
+	BaselinePath string
+	SavePath     string
+	Tolerance    float64
+}
+
+func (c benchConfig) IsValid() error {
+	if c.Tolerance < 0 {
+		return fmt.Errorf("invalid Tolerance value: should not be negative")
+	}
+	return nil
+}
+
+// runBenchCmd executes the bench subcommand with the given arguments,
+// writing the results to stdout.
+func runBenchCmd(args []string, stdout io.Writer) error {
+	var cfg benchConfig
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.StringVar(&cfg.Filter, "run", "", "Regular expression the names of the benchmarks to run should match.")
+	fs.BoolVar(&cfg.Macro, "macro", false, "Also run the macro benchmarks, which start a whole service.")
+	fs.StringVar(&cfg.BaselinePath, "baseline", "", "Path to the results of a previous run to compare against.")
+	fs.StringVar(&cfg.SavePath, "save", "", "Path to save the results to, e.g. to be used as a baseline.")
+	fs.Float64Var(&cfg.Tolerance, "tolerance", 10, "Percentage by which a result can get worse than its baseline.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := cfg.IsValid(); err != nil {
+		return fmt.Errorf("%w\n%s", err, benchUsage)
+	}
+
+	var baseline []rtcbench.Result
+	if cfg.BaselinePath != "" {
+		var err error
+		if baseline, err = rtcbench.LoadResults(cfg.BaselinePath); err != nil {
+			return fmt.Errorf("failed to load baseline: %w", err)
+		}
+	}
+
+	results, err := rtcbench.Run(cfg.Filter, cfg.Macro)
+	if err != nil {
+		return fmt.Errorf("failed to run benchmarks: %w", err)
+	}
+	for _, res := range results {
+		line := fmt.Sprintf("%-20s %10d %14.0f ns/op %8d allocs/op %10d B/op", res.Name, res.N, res.NsPerOp, res.AllocsPerOp, res.BytesPerOp)
+		for _, unit := range res.Units() {
+			line += fmt.Sprintf(" %14.2f %s", res.Extra[unit], unit)
+		}
+		if _, err := fmt.Fprintln(stdout, line); err != nil {
+			return err
+		}
+	}
+
+	if cfg.SavePath != "" {
+		if err := rtcbench.SaveResults(cfg.SavePath, results); err != nil {
+			return fmt.Errorf("failed to save results: %w", err)
+		}
+	}
+
+	if regressions := rtcbench.Compare(baseline, results, cfg.Tolerance); len(regressions) > 0 {
+		lines := make([]string, 0, len(regressions))
+		for _, r := range regressions {
+			lines = append(lines, r.String())
+		}
+		return fmt.Errorf("performance regressions beyond %g%%:\n%s", cfg.Tolerance, strings.Join(lines, "\n"))
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/mattermost/rtcd/rtcbench"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunBenchCmd(t *testing.T) {
+	t.Run("invalid tolerance", func(t *testing.T) {
+		err := runBenchCmd([]string{"-tolerance", "-1"}, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("missing baseline", func(t *testing.T) {
+		err := runBenchCmd([]string{"-baseline", filepath.Join(t.TempDir(), "missing.json")}, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("save and compare", func(t *testing.T) {
+		savePath := filepath.Join(t.TempDir(), "results.json")
+		var buf bytes.Buffer
+		err := runBenchCmd([]string{"-run", "WriteTo", "-save", savePath}, &buf)
+		require.NoError(t, err)
+		require.Contains(t, buf.String(), "MultiConnWriteTo")
+
+		results, err := rtcbench.LoadResults(savePath)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+
+		// A baseline no run can match.
+		results[0].Extra["packets/s"] *= 1000
+		baselinePath := filepath.Join(t.TempDir(), "baseline.json")
+		require.NoError(t, rtcbench.SaveResults(baselinePath, results))
+
+		buf.Reset()
+		err = runBenchCmd([]string{"-run", "WriteTo", "-baseline", baselinePath}, &buf)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "MultiConnWriteTo: packets/s went from")
+	})
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mattermost/rtcd/service/auth"
+)
+
+const bootstrapTokenUsage = `usage: rtcd bootstrap-token -client-id id [-config path] [-expiration duration] [-strict]
+
+Mints a one-time token allowing the given client to register itself through
+the /bootstrap endpoint of the public API, without access to the admin API.
+The token is signed with the admin secret key of the configuration, which
+must match the one of the service. It doesn't require the service to be
+running.`
+
+// runBootstrapTokenCmd executes the bootstrap-token subcommand with the
+// given arguments, writing the token to stdout.
+func runBootstrapTokenCmd(args []string, stdout io.Writer) error {
+	var configPath string
+	var clientID string
+	var expiration time.Duration
+	var strictConfig bool
+	fs := flag.NewFlagSet("bootstrap-token", flag.ContinueOnError)
+	fs.StringVar(&configPath, "config", "config/config.toml", "Path to the configuration file for the rtcd service.")
+	fs.BoolVar(&strictConfig, "strict", false, "Fail on unknown keys in the configuration file or unknown RTCD_ environment variables.")
+	fs.StringVar(&clientID, "client-id", "", "The ID of the client to register.")
+	fs.DurationVar(&expiration, "expiration", 24*time.Hour, "The time after which the token expires if unused.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if clientID == "" {
+		return fmt.Errorf("missing client id\n%s", bootstrapTokenUsage)
+	}
+	if expiration <= 0 {
+		return fmt.Errorf("invalid expiration: should be positive\n%s", bootstrapTokenUsage)
+	}
+
+	cfg, _, err := loadConfig(configPath, strictConfig)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	token, err := auth.NewBootstrapToken(cfg.API.Security.AdminSecretKey, clientID, time.Now().Add(expiration))
+	if err != nil {
+		return fmt.Errorf("failed to mint bootstrap token: %w", err)
+	}
+
+	_, err = fmt.Fprintln(stdout, token)
+	return err
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/auth"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunBootstrapTokenCmd(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+
+	t.Run("missing client id", func(t *testing.T) {
+		err := runBootstrapTokenCmd([]string{"-config", configPath}, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("missing admin secret key", func(t *testing.T) {
+		err := os.WriteFile(configPath, []byte("[api.security]\nadmin_secret_key = \"\"\n"), 0600)
+		require.NoError(t, err)
+		err = runBootstrapTokenCmd([]string{"-config", configPath, "-client-id", "clientA"}, nil)
+		require.EqualError(t, err, "failed to mint bootstrap token: invalid empty admin secret key")
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		err := os.WriteFile(configPath, []byte("[api.security]\nadmin_secret_key = \"secret_key\"\n"), 0600)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		err = runBootstrapTokenCmd([]string{"-config", configPath, "-client-id", "clientA", "-expiration", "1h"}, &buf)
+		require.NoError(t, err)
+
+		claims, err := auth.ParseBootstrapToken("secret_key", strings.TrimSpace(buf.String()), time.Now())
+		require.NoError(t, err)
+		require.Equal(t, "clientA", claims.ClientID)
+		require.InDelta(t, time.Now().Add(time.Hour).Unix(), claims.ExpiresAt, 5)
+	})
+}
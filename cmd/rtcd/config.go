@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/mattermost/rtcd/service"
 
@@ -15,21 +17,177 @@ import (
 	"github.com/kelseyhightower/envconfig"
 )
 
+const (
+	// defaultEnvPrefix is the default prefix of the environment variables
+	// overriding config values.
+	defaultEnvPrefix = "rtcd"
+	// envPrefixVar is the environment variable changing the prefix, e.g. to
+	// run several services configured through the same environment.
+	envPrefixVar = "RTCD_ENV_PREFIX"
+)
+
+// envPrefix returns the prefix of the environment variables overriding
+// config values.
+func envPrefix() string {
+	if prefix := os.Getenv(envPrefixVar); prefix != "" {
+		return prefix
+	}
+	return defaultEnvPrefix
+}
+
+type configSource string
+
+const (
+	configSourceDefault configSource = "default"
+	configSourceProfile configSource = "profile"
+	configSourceFile    configSource = "file"
+	configSourceEnv     configSource = "env"
+)
+
+// configSources maps config fields, identified by their dotted TOML path
+// (e.g. "api.http.listen_address"), to the source that last set them.
+type configSources map[string]configSource
+
 // loadConfig reads the config file and returns a new Config,
 // This method overrides values in the file if there is any environment
-// variables corresponding to a specific setting.
-func loadConfig(path string) (service.Config, error) {
+// variables corresponding to a specific setting. The defaults the file is
+// applied on are tuned by the deployment profile, if any. It also returns the
+// source each config field was set from. In strict mode, unknown keys in the
+// file and unknown environment variables result in an error.
+func loadConfig(path string, strict bool) (service.Config, configSources, error) {
 	var cfg service.Config
 
+	profile, err := configProfile(path)
+	if err != nil {
+		return cfg, nil, err
+	}
+
 	cfg.SetDefaults()
+	defaults := service.FlattenConfig(cfg)
+	if err := cfg.ApplyProfile(profile); err != nil {
+		return cfg, nil, err
+	}
+
+	sources := configSources{}
+	for field, value := range service.FlattenConfig(cfg) {
+		sources[field] = configSourceDefault
+		if value != defaults[field] {
+			sources[field] = configSourceProfile
+		}
+	}
 
 	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
 		log.Printf("config file not found at %s, using defaults", path)
-	} else if _, err := toml.DecodeFile(path, &cfg); err != nil {
-		return cfg, fmt.Errorf("failed to decode config file: %w", err)
+	} else if md, err := toml.DecodeFile(path, &cfg); err != nil {
+		return cfg, nil, fmt.Errorf("failed to decode config file: %w", err)
+	} else {
+		if undecoded := md.Undecoded(); strict && len(undecoded) > 0 {
+			keys := make([]string, 0, len(undecoded))
+			for _, key := range undecoded {
+				keys = append(keys, key.String())
+			}
+			return cfg, nil, fmt.Errorf("unknown config keys: %s", strings.Join(keys, ", "))
+		}
+
+		fileKeys := map[string]bool{}
+		for _, key := range md.Keys() {
+			fileKeys[strings.ToLower(key.String())] = true
+		}
+		for field := range sources {
+			if fileKeys[field] {
+				sources[field] = configSourceFile
+			}
+		}
+	}
+
+	if strict {
+		if err := checkEnvVars(os.Environ()); err != nil {
+			return cfg, nil, err
+		}
+	}
+
+	fileValues := service.FlattenConfig(cfg)
+	if err := envconfig.Process(envPrefix(), &cfg); err != nil {
+		return cfg, nil, err
 	}
-	if err := envconfig.Process("rtcd", &cfg); err != nil {
-		return cfg, err
+	if err := service.ProcessNestedEnv(envPrefix(), &cfg); err != nil {
+		return cfg, nil, err
 	}
-	return cfg, nil
+	for field, value := range service.FlattenConfig(cfg) {
+		if value != fileValues[field] {
+			sources[field] = configSourceEnv
+		}
+	}
+
+	return cfg, sources, nil
+}
+
+// configProfile returns the deployment profile set in either the config file
+// or the environment, the latter taking precedence.
+func configProfile(path string) (string, error) {
+	var cfg struct {
+		Profile string `toml:"profile"`
+	}
+	if _, err := os.Stat(path); err == nil {
+		if _, err := toml.DecodeFile(path, &cfg); err != nil {
+			return "", fmt.Errorf("failed to decode config file: %w", err)
+		}
+	}
+
+	if profile, ok := os.LookupEnv(strings.ToUpper(envPrefix()) + "_PROFILE"); ok {
+		return profile, nil
+	}
+
+	return cfg.Profile, nil
+}
+
+// checkEnvVars returns an error if any of the given environment variables
+// has the config prefix but doesn't match any config field.
+func checkEnvVars(environ []string) error {
+	known := map[string]bool{
+		envPrefixVar: true,
+	}
+	for _, envVar := range service.ConfigEnvVars(envPrefix()) {
+		known[envVar.Key] = true
+		known[envVar.NestedKey] = true
+	}
+
+	var unknown []string
+	for _, kv := range environ {
+		key := strings.SplitN(kv, "=", 2)[0]
+		if strings.HasPrefix(key, strings.ToUpper(envPrefix())+"_") && !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown config env vars: %s", strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// configDiff returns a human readable, redacted, list of the config fields
+// whose effective value differs from the default one, along with the source
+// that set them.
+func configDiff(cfg service.Config, sources configSources) []string {
+	var defaultCfg service.Config
+	defaultCfg.SetDefaults()
+	defaults := service.FlattenConfig(defaultCfg)
+	values := service.FlattenConfig(cfg)
+
+	var diff []string
+	for field, source := range sources {
+		value := values[field]
+		if value == defaults[field] {
+			continue
+		}
+		if service.IsSensitiveConfigField(field) && value != "" {
+			value = "<redacted>"
+		}
+		diff = append(diff, fmt.Sprintf("%s = %q (source: %s)", field, value, source))
+	}
+	sort.Strings(diff)
+
+	return diff
 }
@@ -18,7 +18,7 @@ func TestLoadConfig(t *testing.T) {
 	defaultCfg.SetDefaults()
 
 	t.Run("non existant file", func(t *testing.T) {
-		cfg, err := loadConfig("")
+		cfg, _, err := loadConfig("", false)
 		require.NoError(t, err)
 		require.NotEmpty(t, cfg)
 		require.Equal(t, defaultCfg, cfg)
@@ -31,7 +31,7 @@ func TestLoadConfig(t *testing.T) {
 		defer file.Close()
 		defer os.Remove(file.Name())
 
-		cfg, err := loadConfig(file.Name())
+		cfg, _, err := loadConfig(file.Name(), false)
 		require.NoError(t, err)
 		require.Equal(t, defaultCfg, cfg)
 	})
@@ -46,28 +46,186 @@ func TestLoadConfig(t *testing.T) {
 		_, err = file.Write([]byte(configData))
 		require.NoError(t, err)
 
-		cfg, err := loadConfig(file.Name())
+		cfg, _, err := loadConfig(file.Name(), false)
 		require.NoError(t, err)
 		require.Equal(t, defaultCfg, cfg)
 	})
 
 	t.Run("valid config", func(t *testing.T) {
-		cfg, err := loadConfig("../../config/config.sample.toml")
+		cfg, _, err := loadConfig("../../config/config.sample.toml", false)
 		require.NoError(t, err)
 		require.NotEmpty(t, cfg)
 	})
 
 	t.Run("env override", func(t *testing.T) {
-		cfg, err := loadConfig("../../config/config.sample.toml")
+		cfg, _, err := loadConfig("../../config/config.sample.toml", false)
 		require.NoError(t, err)
 		require.NotEmpty(t, cfg)
 		require.Equal(t, "DEBUG", cfg.Logger.FileLevel)
 
 		os.Setenv("RTCD_LOGGER_FILELEVEL", "ERROR")
 		defer os.Unsetenv("RTCD_LOGGER_FILELEVEL")
-		cfg, err = loadConfig("../../config/config.sample.toml")
+		cfg, _, err = loadConfig("../../config/config.sample.toml", false)
 		require.NoError(t, err)
 		require.NotEmpty(t, cfg)
 		require.Equal(t, "ERROR", cfg.Logger.FileLevel)
 	})
+
+	t.Run("nested env override", func(t *testing.T) {
+		os.Setenv("RTCD_LOGGER_FILELEVEL", "ERROR")
+		defer os.Unsetenv("RTCD_LOGGER_FILELEVEL")
+		os.Setenv("RTCD_LOGGER__FILE_LEVEL", "WARN")
+		defer os.Unsetenv("RTCD_LOGGER__FILE_LEVEL")
+
+		// Nested names take precedence.
+		cfg, sources, err := loadConfig("../../config/config.sample.toml", true)
+		require.NoError(t, err)
+		require.Equal(t, "WARN", cfg.Logger.FileLevel)
+		require.Equal(t, configSourceEnv, sources["logger.file_level"])
+	})
+
+	t.Run("env prefix", func(t *testing.T) {
+		os.Setenv(envPrefixVar, "custom")
+		defer os.Unsetenv(envPrefixVar)
+		os.Setenv("RTCD_LOGGER_FILELEVEL", "ERROR")
+		defer os.Unsetenv("RTCD_LOGGER_FILELEVEL")
+		os.Setenv("CUSTOM_LOGGER__FILE_LEVEL", "WARN")
+		defer os.Unsetenv("CUSTOM_LOGGER__FILE_LEVEL")
+		os.Setenv("CUSTOM_PROFILE", "small")
+		defer os.Unsetenv("CUSTOM_PROFILE")
+
+		cfg, _, err := loadConfig("../../config/config.sample.toml", true)
+		require.NoError(t, err)
+		require.Equal(t, "WARN", cfg.Logger.FileLevel)
+		require.Equal(t, service.ProfileSmall, cfg.Profile)
+	})
+}
+
+func TestLoadConfigSources(t *testing.T) {
+	file, err := os.CreateTemp("", "config.toml")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(`
+[api]
+http.listen_address = ":8080"
+security.admin_secret_key = "secret"
+[rtc]
+ice_port_udp = 8443
+[logger]
+file_level = "INFO"
+`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	os.Setenv("RTCD_LOGGER_FILELEVEL", "ERROR")
+	defer os.Unsetenv("RTCD_LOGGER_FILELEVEL")
+
+	cfg, sources, err := loadConfig(file.Name(), false)
+	require.NoError(t, err)
+
+	require.Equal(t, configSourceFile, sources["api.http.listen_address"])
+	require.Equal(t, configSourceFile, sources["api.security.admin_secret_key"])
+	require.Equal(t, configSourceFile, sources["rtc.ice_port_udp"])
+	require.Equal(t, configSourceEnv, sources["logger.file_level"])
+	require.Equal(t, configSourceDefault, sources["store.data_source"])
+
+	require.Equal(t, []string{
+		`api.http.listen_address = ":8080" (source: file)`,
+		`api.security.admin_secret_key = "<redacted>" (source: file)`,
+		`logger.file_level = "ERROR" (source: env)`,
+	}, configDiff(cfg, sources))
+}
+
+func TestLoadConfigProfile(t *testing.T) {
+	file, err := os.CreateTemp("", "config.toml")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(`
+profile = "large"
+[rtc]
+udp_sockets.min_count = 4
+udp_sockets.max_count = 4
+`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	t.Run("file", func(t *testing.T) {
+		cfg, sources, err := loadConfig(file.Name(), true)
+		require.NoError(t, err)
+		require.NoError(t, cfg.IsValid())
+
+		require.Equal(t, service.ProfileLarge, cfg.Profile)
+		require.True(t, cfg.RTC.UDPSockets.EnableScaling)
+		require.Equal(t, configSourceProfile, sources["rtc.udp_sockets.enable_scaling"])
+		// Explicit settings take precedence over the profile.
+		require.Equal(t, 4, cfg.RTC.UDPSockets.MinCount)
+		require.Equal(t, configSourceFile, sources["rtc.udp_sockets.min_count"])
+		require.Equal(t, configSourceDefault, sources["rtc.ice_port_udp"])
+	})
+
+	t.Run("env", func(t *testing.T) {
+		os.Setenv("RTCD_PROFILE", "small")
+		defer os.Unsetenv("RTCD_PROFILE")
+
+		cfg, sources, err := loadConfig(file.Name(), true)
+		require.NoError(t, err)
+		require.Equal(t, service.ProfileSmall, cfg.Profile)
+		require.Equal(t, configSourceEnv, sources["profile"])
+		require.Equal(t, 1024*1024*4, cfg.RTC.UDPSockets.ReadBufferSize)
+		require.Equal(t, 4, cfg.RTC.UDPSockets.MaxCount)
+		require.Equal(t, 4, cfg.RTC.UDPSockets.MinCount)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		os.Setenv("RTCD_PROFILE", "huge")
+		defer os.Unsetenv("RTCD_PROFILE")
+
+		_, _, err := loadConfig(file.Name(), false)
+		require.EqualError(t, err, `invalid Profile value: "huge" is not one of [broadcast large small]`)
+	})
+}
+
+func TestLoadConfigStrict(t *testing.T) {
+	t.Run("sample config", func(t *testing.T) {
+		_, _, err := loadConfig("../../config/config.sample.toml", true)
+		require.NoError(t, err)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		file, err := os.CreateTemp("", "config.toml")
+		require.NoError(t, err)
+		defer os.Remove(file.Name())
+		_, err = file.WriteString("[rtc]\nice_port_upd = 8443\n")
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		_, _, err = loadConfig(file.Name(), false)
+		require.NoError(t, err)
+
+		_, _, err = loadConfig(file.Name(), true)
+		require.EqualError(t, err, "unknown config keys: rtc.ice_port_upd")
+	})
+
+	t.Run("unknown env var", func(t *testing.T) {
+		os.Setenv("RTCD_RTC_ICEPORTUPD", "8443")
+		defer os.Unsetenv("RTCD_RTC_ICEPORTUPD")
+
+		_, _, err := loadConfig("", false)
+		require.NoError(t, err)
+
+		_, _, err = loadConfig("", true)
+		require.EqualError(t, err, "unknown config env vars: RTCD_RTC_ICEPORTUPD")
+	})
+
+	t.Run("known env var", func(t *testing.T) {
+		os.Setenv("RTCD_RTC_ICEPORTUDP", "8443")
+		defer os.Unsetenv("RTCD_RTC_ICEPORTUDP")
+		os.Setenv("RTCD_RTC__ICE_PORT_UDP", "8443")
+		defer os.Unsetenv("RTCD_RTC__ICE_PORT_UDP")
+		os.Setenv(envPrefixVar, defaultEnvPrefix)
+		defer os.Unsetenv(envPrefixVar)
+
+		_, _, err := loadConfig("", true)
+		require.NoError(t, err)
+	})
 }
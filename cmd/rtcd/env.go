@@ -0,0 +1,47 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/mattermost/rtcd/service"
+)
+
+const envUsage = `usage: rtcd env
+
+Prints the environment variables overriding config values, with the format
+and the default value of each. Variables can be named either after the TOML
+path of the field, levels being separated by double underscores (e.g.
+RTCD_API__HTTP__LISTEN_ADDRESS), or after its Go name (e.g.
+RTCD_API_HTTP_LISTENADDRESS), the former taking precedence. The RTCD prefix
+can be changed by setting ` + envPrefixVar + `.`
+
+// runEnvCmd executes the env subcommand with the given arguments.
+func runEnvCmd(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("env", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), envUsage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments\n%s", envUsage)
+	}
+
+	tabs := tabwriter.NewWriter(stdout, 1, 0, 4, ' ', 0)
+	fmt.Fprintln(tabs, "KEY\tALIAS\tTYPE\tDEFAULT")
+	for _, envVar := range service.ConfigEnvVars(envPrefix()) {
+		alias := envVar.Key
+		if alias == envVar.NestedKey {
+			alias = "-"
+		}
+		fmt.Fprintf(tabs, "%s\t%s\t%s\t%q\n", envVar.NestedKey, alias, envVar.Type, envVar.Default)
+	}
+	return tabs.Flush()
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunEnvCmd(t *testing.T) {
+	t.Run("unexpected arguments", func(t *testing.T) {
+		err := runEnvCmd([]string{"foo"}, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("default prefix", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, runEnvCmd(nil, &buf))
+		lines := strings.Split(buf.String(), "\n")
+		require.Equal(t, []string{"KEY", "ALIAS", "TYPE", "DEFAULT"}, strings.Fields(lines[0]))
+		require.Equal(t, []string{"RTCD_PROFILE", "-", "String", `""`}, strings.Fields(lines[1]))
+		require.Equal(t, []string{"RTCD_API__HTTP__LISTEN_ADDRESS", "RTCD_API_HTTP_LISTENADDRESS", "String", `":8045"`}, strings.Fields(lines[2]))
+	})
+
+	t.Run("custom prefix", func(t *testing.T) {
+		os.Setenv(envPrefixVar, "custom")
+		defer os.Unsetenv(envPrefixVar)
+
+		var buf bytes.Buffer
+		require.NoError(t, runEnvCmd(nil, &buf))
+		require.Contains(t, buf.String(), "CUSTOM_API__HTTP__LISTEN_ADDRESS")
+		require.NotContains(t, buf.String(), "RTCD_")
+	})
+}
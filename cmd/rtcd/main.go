@@ -5,6 +5,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -14,33 +15,129 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "store" {
+		if err := runStoreCmd(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("rtcd: %s", err.Error())
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "env" {
+		if err := runEnvCmd(os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("rtcd: %s", err.Error())
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap-token" {
+		if err := runBootstrapTokenCmd(os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("rtcd: %s", err.Error())
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "recorder" {
+		stopCh := make(chan struct{})
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sig
+			close(stopCh)
+		}()
+		if err := runRecorderCmd(os.Args[2:], stopCh); err != nil {
+			log.Fatalf("rtcd: %s", err.Error())
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		stopCh := make(chan struct{})
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sig
+			close(stopCh)
+		}()
+		if err := runReplayCmd(os.Args[2:], stopCh, log.Printf); err != nil {
+			log.Fatalf("rtcd: %s", err.Error())
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := runBenchCmd(os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("rtcd: %s", err.Error())
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "service" {
+		if err := runServiceCmd(os.Args[2:]); err != nil {
+			log.Fatalf("rtcd: %s", err.Error())
+		}
+		return
+	}
+
 	var configPath string
+	var strictConfig bool
+	var skipPreflight bool
 	flag.StringVar(&configPath, "config", "config/config.toml", "Path to the configuration file for the rtcd service.")
+	flag.BoolVar(&strictConfig, "strict", false, "Fail on unknown keys in the configuration file or unknown RTCD_ environment variables (see rtcd env).")
+	flag.BoolVar(&skipPreflight, "skip-preflight", false, "Skip the checks of the environment (UDP port, open files limit, store path, advertised addresses) run before starting.")
 	flag.Parse()
 
-	cfg, err := loadConfig(configPath)
+	stopCh := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		close(stopCh)
+	}()
+
+	if err := runService(configPath, strictConfig, skipPreflight, stopCh); err != nil {
+		log.Fatalf("rtcd: %s", err.Error())
+	}
+}
+
+// runService runs the rtcd service until stopCh is closed.
+func runService(configPath string, strictConfig, skipPreflight bool, stopCh <-chan struct{}) error {
+	cfg, sources, err := loadConfig(configPath, strictConfig)
 	if err != nil {
-		log.Fatalf("rtcd: failed to load config: %s", err.Error())
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	for _, line := range configDiff(cfg, sources) {
+		log.Printf("rtcd: config: %s", line)
 	}
 
 	if err := cfg.IsValid(); err != nil {
-		log.Fatalf("rtcd: failed to validate config: %s", err.Error())
+		return fmt.Errorf("failed to validate config: %w", err)
+	}
+
+	if !skipPreflight {
+		if err := runPreflightChecks(cfg, log.Printf); err != nil {
+			return err
+		}
 	}
 
 	service, err := service.New(cfg)
 	if err != nil {
-		log.Fatalf("rtcd: failed to create service: %s", err.Error())
+		return fmt.Errorf("failed to create service: %w", err)
 	}
 
 	if err := service.Start(); err != nil {
-		log.Fatalf("rtcd: failed to start service: %s", err.Error())
+		return fmt.Errorf("failed to start service: %w", err)
 	}
 
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-	<-sig
+	select {
+	case <-stopCh:
+	case <-service.StopRequested():
+		log.Printf("rtcd: stopping for scheduled maintenance")
+	}
 
 	if err := service.Stop(); err != nil {
-		log.Fatalf("rtcd: failed to stop service: %s", err.Error())
+		return fmt.Errorf("failed to stop service: %w", err)
 	}
+
+	return nil
 }
@@ -0,0 +1,227 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mattermost/rtcd/service"
+	"github.com/mattermost/rtcd/service/perf"
+)
+
+const (
+	// preflightMinOpenFiles is the open files limit below which a warning is
+	// printed. Each session uses a few descriptors besides the shared UDP
+	// sockets (signaling connection, recordings, etc.).
+	preflightMinOpenFiles = 4096
+	// preflightLookupTimeout bounds each of the DNS lookups.
+	preflightLookupTimeout = 5 * time.Second
+)
+
+// preflightResult describes a failed pre-flight check.
+type preflightResult struct {
+	check       string
+	err         error
+	remediation string
+	// fatal results prevent the service from starting, the other ones are
+	// only reported.
+	fatal bool
+}
+
+func (r preflightResult) String() string {
+	level := "warning"
+	if r.fatal {
+		level = "error"
+	}
+	return fmt.Sprintf("%s: %s: %s\n  -> %s", level, r.check, r.err, r.remediation)
+}
+
+// preflightCheck verifies part of the environment the service is about to
+// run in, returning the problems found.
+type preflightCheck func(cfg service.Config) []preflightResult
+
+var preflightChecks = []preflightCheck{
+	checkUDPPort,
+	checkOpenFilesLimit,
+	checkStorePath,
+	checkAdvertisedAddresses,
+}
+
+// runPreflightChecks runs all the checks, catching the environment issues
+// that would otherwise only surface once the service is running (e.g. when a
+// call starts). It returns an error listing the fatal results, if any. The
+// other results are logged through logf.
+func runPreflightChecks(cfg service.Config, logf func(format string, args ...interface{})) error {
+	var fatal []string
+	for _, check := range preflightChecks {
+		for _, res := range check(cfg) {
+			if res.fatal {
+				fatal = append(fatal, res.String())
+				continue
+			}
+			logf("rtcd: preflight: %s", res)
+		}
+	}
+
+	if len(fatal) > 0 {
+		return fmt.Errorf("pre-flight checks failed (use -skip-preflight to bypass):\n%s", strings.Join(fatal, "\n"))
+	}
+
+	return nil
+}
+
+func checkUDPPort(cfg service.Config) []preflightResult {
+	addr := fmt.Sprintf("%s:%d", cfg.RTC.ICEAddressUDP, cfg.RTC.ICEPortUDP)
+	conn, err := net.ListenPacket("udp4", addr)
+	if err == nil {
+		conn.Close()
+		return nil
+	}
+
+	res := preflightResult{
+		check: "udp port",
+		err:   err,
+		fatal: true,
+	}
+	switch {
+	case errors.Is(err, syscall.EADDRINUSE):
+		res.remediation = fmt.Sprintf("another process (possibly another rtcd instance) is bound to port %d, stop it or change rtc.ice_port_udp", cfg.RTC.ICEPortUDP)
+	case errors.Is(err, syscall.EACCES):
+		res.remediation = "binding the port requires privileges, use a port above 1023 or grant the CAP_NET_BIND_SERVICE capability"
+	case errors.Is(err, syscall.EADDRNOTAVAIL):
+		res.remediation = fmt.Sprintf("%q is not assigned to any local interface, fix rtc.ice_address_udp or leave it empty to listen on all interfaces", cfg.RTC.ICEAddressUDP)
+	default:
+		res.remediation = "check rtc.ice_address_udp and rtc.ice_port_udp"
+	}
+
+	return []preflightResult{res}
+}
+
+func checkOpenFilesLimit(cfg service.Config) []preflightResult {
+	limit, hard, err := perf.GetOpenFilesLimit()
+	if errors.Is(err, perf.ErrOpenFilesLimitUnsupported) {
+		return nil
+	} else if err != nil {
+		return []preflightResult{{
+			check:       "open files limit",
+			err:         err,
+			remediation: fmt.Sprintf("make sure at least %d files can be opened", preflightMinOpenFiles),
+		}}
+	}
+
+	// The service raises the limit at startup, up to the hard one unless
+	// privileged.
+	if target := uint64(cfg.Process.OpenFilesLimit); target > limit {
+		limit = target
+		if limit > hard {
+			limit = hard
+		}
+	}
+
+	if limit >= preflightMinOpenFiles {
+		return nil
+	}
+
+	return []preflightResult{{
+		check:       "open files limit",
+		err:         fmt.Errorf("limit is %d", limit),
+		remediation: fmt.Sprintf("sessions may fail when running out of descriptors, raise process.open_files_limit and the hard limit to at least %d (e.g. ulimit -Hn or LimitNOFILE in the systemd unit)", preflightMinOpenFiles),
+	}}
+}
+
+func checkStorePath(cfg service.Config) []preflightResult {
+	dir := cfg.Store.DataSource
+	err := os.MkdirAll(dir, 0700)
+	if err == nil {
+		var f *os.File
+		f, err = os.CreateTemp(dir, ".rtcd_preflight_")
+		if err == nil {
+			f.Close()
+			err = os.Remove(f.Name())
+		}
+	}
+	if err == nil {
+		return nil
+	}
+
+	return []preflightResult{{
+		check:       "store path",
+		err:         err,
+		remediation: fmt.Sprintf("make %q a directory writable by the user running rtcd or change store.data_source", dir),
+		fatal:       true,
+	}}
+}
+
+func checkAdvertisedAddresses(cfg service.Config) []preflightResult {
+	var results []preflightResult
+
+	if host := cfg.RTC.ICEHostOverride; host != "" {
+		if err := lookupHost(host); err != nil {
+			results = append(results, preflightResult{
+				check:       "ice host override",
+				err:         err,
+				remediation: "clients won't be able to reach the server, set rtc.ice_host_override to the public IP address or a resolvable hostname",
+				fatal:       true,
+			})
+		}
+	}
+
+	// Failures are not fatal as the servers are used by the clients, whose
+	// resolvers may differ.
+	for _, server := range cfg.RTC.ICEServers {
+		for _, u := range server.URLs {
+			host, err := iceServerHost(u)
+			if err == nil {
+				err = lookupHost(host)
+			}
+			if err != nil {
+				results = append(results, preflightResult{
+					check:       "ice server " + u,
+					err:         err,
+					remediation: "fix the URL in rtc.ice_servers, connectivity may fail for the clients relying on it",
+				})
+			}
+		}
+	}
+
+	return results
+}
+
+// iceServerHost returns the host of a STUN/TURN server URL (e.g.
+// "turn:turn.example.com:3478?transport=udp").
+func iceServerHost(u string) (string, error) {
+	_, hostPort, ok := strings.Cut(u, ":")
+	if !ok {
+		return "", fmt.Errorf("invalid URL: missing scheme")
+	}
+	hostPort, _, _ = strings.Cut(hostPort, "?")
+	host, _, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		// The port is optional.
+		host = strings.Trim(hostPort, "[]")
+	}
+	if host == "" {
+		return "", fmt.Errorf("invalid URL: missing host")
+	}
+	return host, nil
+}
+
+func lookupHost(host string) error {
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), preflightLookupTimeout)
+	defer cancel()
+	if _, err := net.DefaultResolver.LookupHost(ctx, host); err != nil {
+		return fmt.Errorf("failed to resolve %q: %w", host, err)
+	}
+	return nil
+}
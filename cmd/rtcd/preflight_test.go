@@ -0,0 +1,117 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mattermost/rtcd/service"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreflightChecks(t *testing.T) {
+	makeConfig := func(t *testing.T) service.Config {
+		t.Helper()
+		var cfg service.Config
+		cfg.SetDefaults()
+		cfg.Store.DataSource = filepath.Join(t.TempDir(), "db")
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		cfg.RTC.ICEAddressUDP = "127.0.0.1"
+		cfg.RTC.ICEPortUDP = conn.LocalAddr().(*net.UDPAddr).Port
+		require.NoError(t, conn.Close())
+		return cfg
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := makeConfig(t)
+		require.Empty(t, checkUDPPort(cfg))
+		require.Empty(t, checkStorePath(cfg))
+		require.Empty(t, checkAdvertisedAddresses(cfg))
+		require.DirExists(t, cfg.Store.DataSource)
+		entries, err := os.ReadDir(cfg.Store.DataSource)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+
+	t.Run("udp port in use", func(t *testing.T) {
+		cfg := makeConfig(t)
+		conn, err := net.ListenPacket("udp4", fmt.Sprintf("127.0.0.1:%d", cfg.RTC.ICEPortUDP))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		results := checkUDPPort(cfg)
+		require.Len(t, results, 1)
+		require.True(t, results[0].fatal)
+		require.Contains(t, results[0].remediation, "another process")
+
+		err = runPreflightChecks(cfg, t.Logf)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "error: udp port:")
+	})
+
+	t.Run("store path not a directory", func(t *testing.T) {
+		cfg := makeConfig(t)
+		err := os.WriteFile(cfg.Store.DataSource, nil, 0600)
+		require.NoError(t, err)
+
+		results := checkStorePath(cfg)
+		require.Len(t, results, 1)
+		require.True(t, results[0].fatal)
+		require.Contains(t, results[0].remediation, "store.data_source")
+	})
+
+	t.Run("advertised addresses", func(t *testing.T) {
+		cfg := makeConfig(t)
+		cfg.RTC.ICEHostOverride = "10.0.0.1"
+		cfg.RTC.ICEServers = rtc.ICEServers{{URLs: []string{"stun:192.0.2.1:3478"}}}
+		require.Empty(t, checkAdvertisedAddresses(cfg))
+
+		// The .invalid TLD is guaranteed to never resolve.
+		cfg.RTC.ICEHostOverride = "rtcd.invalid"
+		cfg.RTC.ICEServers = rtc.ICEServers{{URLs: []string{"turn:turn.invalid:3478?transport=udp"}}}
+		results := checkAdvertisedAddresses(cfg)
+		require.Len(t, results, 2)
+		require.True(t, results[0].fatal)
+		require.Equal(t, "ice host override", results[0].check)
+		require.False(t, results[1].fatal)
+		require.Equal(t, "ice server turn:turn.invalid:3478?transport=udp", results[1].check)
+
+		// Only fatal results fail the checks.
+		cfg.RTC.ICEHostOverride = ""
+		require.NoError(t, runPreflightChecks(cfg, t.Logf))
+	})
+}
+
+func TestICEServerHost(t *testing.T) {
+	tcs := []struct {
+		url  string
+		host string
+		err  string
+	}{
+		{url: "stun:stun.example.com:3478", host: "stun.example.com"},
+		{url: "stun:stun.example.com", host: "stun.example.com"},
+		{url: "turn:10.0.0.1:3478?transport=udp", host: "10.0.0.1"},
+		{url: "turns:[::1]:5349", host: "::1"},
+		{url: "stun.example.com", err: "invalid URL: missing scheme"},
+		{url: "stun::3478", err: "invalid URL: missing host"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.url, func(t *testing.T) {
+			host, err := iceServerHost(tc.url)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.host, host)
+		})
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/client"
+	"github.com/mattermost/rtcd/service"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/pion/webrtc/v3/pkg/media/ivfwriter"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+)
+
+const recorderUsage = `usage: rtcd recorder -url url -client-id id -call-id id [-auth-key key] [-dir path] [-duration duration]
+
+Joins an existing call as a hidden, receive-only participant through the
+public API and records the received tracks locally, one file per track
+(Ogg/Opus for audio, IVF for video). The auth key defaults to the value of
+the RTCD_AUTH_KEY environment variable. Recording stops once the duration
+elapses, the call ends or the process is interrupted.`
+
+var unsafeFileCharsRE = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+type recorderConfig struct {
+	URL      string
+	ClientID string
+	AuthKey  string
+	CallID   string
+	UserID   string
+	Dir      string
+	Duration time.Duration
+}
+
+func (c recorderConfig) IsValid() error {
+	if c.URL == "" {
+		return fmt.Errorf("invalid URL value: should not be empty")
+	}
+	if c.ClientID == "" {
+		return fmt.Errorf("invalid ClientID value: should not be empty")
+	}
+	if c.AuthKey == "" {
+		return fmt.Errorf("invalid AuthKey value: should not be empty")
+	}
+	if c.CallID == "" {
+		return fmt.Errorf("invalid CallID value: should not be empty")
+	}
+	if c.UserID == "" {
+		return fmt.Errorf("invalid UserID value: should not be empty")
+	}
+	if c.Duration < 0 {
+		return fmt.Errorf("invalid Duration value: should not be negative")
+	}
+	return nil
+}
+
+// runRecorderCmd executes the recorder subcommand with the given arguments.
+// It returns once the recording stops, closing stopCh stops it early.
+func runRecorderCmd(args []string, stopCh <-chan struct{}) error {
+	var cfg recorderConfig
+	fs := flag.NewFlagSet("recorder", flag.ContinueOnError)
+	fs.StringVar(&cfg.URL, "url", "", "URL of the rtcd service.")
+	fs.StringVar(&cfg.ClientID, "client-id", "", "ID of the registered client owning the call.")
+	fs.StringVar(&cfg.AuthKey, "auth-key", os.Getenv("RTCD_AUTH_KEY"), "Auth key of the registered client.")
+	fs.StringVar(&cfg.CallID, "call-id", "", "ID of the call to record.")
+	fs.StringVar(&cfg.UserID, "user-id", "rtcd-recorder", "User ID the recorder joins the call as.")
+	fs.StringVar(&cfg.Dir, "dir", ".", "Path to the directory the track files are written to.")
+	fs.DurationVar(&cfg.Duration, "duration", 0, "Duration after which the recording stops. Zero means no limit.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := cfg.IsValid(); err != nil {
+		return fmt.Errorf("%w\n%s", err, recorderUsage)
+	}
+
+	rec, err := newRecorder(cfg)
+	if err != nil {
+		return err
+	}
+
+	files, err := rec.run(stopCh)
+	for _, f := range files {
+		log.Printf("rtcd: recorded %s", f)
+	}
+
+	return err
+}
+
+// recorder joins a call as a hidden participant and writes the tracks it
+// receives to files.
+type recorder struct {
+	cfg    recorderConfig
+	client *client.Client
+	call   *client.Call
+	files  []string
+	wg     sync.WaitGroup
+	mut    sync.Mutex
+}
+
+func newRecorder(cfg recorderConfig) (*recorder, error) {
+	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create dir: %w", err)
+	}
+
+	c, err := client.New(client.Config{
+		Service: service.ClientConfig{
+			URL:      cfg.URL,
+			ClientID: cfg.ClientID,
+			AuthKey:  cfg.AuthKey,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	c.OnError(func(err error) {
+		log.Printf("rtcd: recorder: client error: %s", err.Error())
+	})
+
+	return &recorder{
+		cfg:    cfg,
+		client: c,
+	}, nil
+}
+
+func (r *recorder) recordTrack(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+	ext := ".ogg"
+	if track.Kind() == webrtc.RTPCodecTypeVideo {
+		ext = ".ivf"
+	}
+
+	r.mut.Lock()
+	select {
+	case <-r.call.Done():
+		r.mut.Unlock()
+		return
+	default:
+	}
+	r.wg.Add(1)
+	defer r.wg.Done()
+	name := fmt.Sprintf("%03d_%s%s", len(r.files), unsafeFileCharsRE.ReplaceAllString(track.ID(), "_"), ext)
+	path := filepath.Join(r.cfg.Dir, name)
+	r.files = append(r.files, path)
+	r.mut.Unlock()
+
+	var w media.Writer
+	var err error
+	if track.Kind() == webrtc.RTPCodecTypeVideo {
+		w, err = ivfwriter.New(path)
+		// Video can only be decoded starting from a key frame.
+		if pliErr := r.call.RequestKeyFrame(track); pliErr != nil {
+			log.Printf("rtcd: recorder: failed to request key frame: %s", pliErr.Error())
+		}
+	} else {
+		w, err = oggwriter.New(path, track.Codec().ClockRate, track.Codec().Channels)
+	}
+	if err != nil {
+		log.Printf("rtcd: recorder: failed to create track file: %s", err.Error())
+		return
+	}
+	defer w.Close()
+
+	for {
+		pkt, _, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+		if err := w.WriteRTP(pkt); err != nil {
+			log.Printf("rtcd: recorder: failed to write packet: %s", err.Error())
+			return
+		}
+	}
+}
+
+// run records the call until stopCh is closed, the configured duration
+// elapses or the session gets closed. It returns the paths of the recorded
+// track files.
+func (r *recorder) run(stopCh <-chan struct{}) ([]string, error) {
+	if err := r.client.Connect(); err != nil {
+		return nil, err
+	}
+	defer r.client.Close()
+
+	call, err := r.client.JoinCall(client.CallConfig{
+		CallID: r.cfg.CallID,
+		UserID: r.cfg.UserID,
+		Hidden: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.call = call
+	call.OnTrack(r.recordTrack)
+
+	log.Printf("rtcd: recording call %s as session %s", r.cfg.CallID, call.SessionID())
+
+	var durationCh <-chan time.Time
+	if r.cfg.Duration > 0 {
+		durationCh = time.After(r.cfg.Duration)
+	}
+
+	select {
+	case <-stopCh:
+	case <-durationCh:
+	case <-call.Done():
+	}
+
+	// Leaving closes the peer connection, which ends the tracks and flushes
+	// the files.
+	if err := call.Leave(); err != nil && !errors.Is(err, client.ErrCallClosed) {
+		log.Printf("rtcd: recorder: failed to leave call: %s", err.Error())
+	}
+
+	r.mut.Lock()
+	r.wg.Wait()
+	files := make([]string, len(r.files))
+	copy(files, r.files)
+	r.mut.Unlock()
+
+	return files, call.Err()
+}
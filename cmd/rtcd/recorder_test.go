@@ -0,0 +1,166 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/client"
+	"github.com/mattermost/rtcd/logger"
+	"github.com/mattermost/rtcd/service"
+	"github.com/mattermost/rtcd/service/api"
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderConfigIsValid(t *testing.T) {
+	cfg := recorderConfig{
+		URL:      "http://localhost:8045",
+		ClientID: "clientID",
+		AuthKey:  "authKey",
+		CallID:   "callID",
+		UserID:   "rtcd-recorder",
+	}
+	require.NoError(t, cfg.IsValid())
+
+	invalid := cfg
+	invalid.CallID = ""
+	require.EqualError(t, invalid.IsValid(), "invalid CallID value: should not be empty")
+
+	invalid = cfg
+	invalid.AuthKey = ""
+	require.EqualError(t, invalid.IsValid(), "invalid AuthKey value: should not be empty")
+
+	invalid = cfg
+	invalid.Duration = -time.Second
+	require.EqualError(t, invalid.IsValid(), "invalid Duration value: should not be negative")
+}
+
+func TestRunRecorderCmd(t *testing.T) {
+	t.Run("missing flags", func(t *testing.T) {
+		err := runRecorderCmd([]string{"-url", "http://localhost:8045"}, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "usage: rtcd recorder")
+	})
+
+	// Finding a free port for the API.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+	apiURL := "http://127.0.0.1:" + strconv.Itoa(port)
+
+	cfg := service.Config{
+		API: service.APIConfig{
+			HTTP: api.Config{
+				ListenAddress: "127.0.0.1:" + strconv.Itoa(port),
+			},
+			Security: service.SecurityConfig{
+				EnableAdmin:    true,
+				AdminSecretKey: "admin_secret_key",
+			},
+		},
+		RTC: rtc.ServerConfig{
+			ICEPortUDP: 30463,
+		},
+		Store: service.StoreConfig{
+			DataSource: t.TempDir(),
+		},
+		Logger: logger.Config{
+			EnableConsole: true,
+			ConsoleLevel:  "ERROR",
+		},
+	}
+	cfg.API.Security.SessionCache.ExpirationMinutes = 1440
+	srvc, err := service.New(cfg)
+	require.NoError(t, err)
+	require.NoError(t, srvc.Start())
+	defer func() {
+		require.NoError(t, srvc.Stop())
+	}()
+
+	adminClient, err := service.NewClient(service.ClientConfig{URL: apiURL, AuthKey: cfg.API.Security.AdminSecretKey})
+	require.NoError(t, err)
+	defer adminClient.Close()
+	authKey := "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H"
+	require.NoError(t, adminClient.Register("clientA", authKey))
+
+	// Publisher sending audio in the call to record.
+	pubClient, err := client.New(client.Config{
+		Service: service.ClientConfig{URL: apiURL, ClientID: "clientA", AuthKey: authKey},
+	})
+	require.NoError(t, err)
+	require.NoError(t, pubClient.Connect())
+	defer pubClient.Close()
+	callEndedCh := make(chan struct{})
+	pubClient.Service().OnCallEnded(func(ev rtc.Event) {
+		close(callEndedCh)
+	})
+
+	pub, err := pubClient.JoinCall(client.CallConfig{CallID: "callID", UserID: "publisher"})
+	require.NoError(t, err)
+	track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", random.NewID())
+	require.NoError(t, err)
+	_, err = pub.PublishTrack(track)
+	require.NoError(t, err)
+
+	select {
+	case <-pub.Connected():
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "timed out waiting for publisher to connect")
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = track.WriteSample(media.Sample{Data: []byte{0xf8, 0xff, 0xfe}, Duration: 20 * time.Millisecond})
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	dir := t.TempDir()
+	err = runRecorderCmd([]string{
+		"-url", apiURL,
+		"-client-id", "clientA",
+		"-auth-key", authKey,
+		"-call-id", "callID",
+		"-dir", dir,
+		"-duration", "3s",
+	}, nil)
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.ogg"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	require.Equal(t, "OggS", string(data[:4]))
+	// More than the Ogg headers got written.
+	require.Greater(t, len(data), 512)
+
+	// The recorder should have been left out of the call so the publisher
+	// leaving ends it.
+	require.NoError(t, pub.Leave())
+	select {
+	case <-callEndedCh:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timed out waiting for call to end")
+	}
+}
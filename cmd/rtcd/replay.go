@@ -0,0 +1,229 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/service"
+	"github.com/mattermost/rtcd/service/rtc"
+)
+
+const replayUsage = `usage: rtcd replay -url url -client-id id [-auth-key key] [-speed factor] [-wait duration] trace-file
+
+Feeds the messages sent by clients in a signaling trace, recorded through the
+/admin/signaling_trace endpoint, back into an rtcd service, typically a local
+test instance, so that protocol issues can be reproduced. Messages are sent
+in order, over one connection per recorded connection, their recorded spacing
+divided by the speed factor (zero sends them back to back). The messages
+received in return are logged to be compared with the recorded ones. Group
+IDs are replaced with the client ID and redacted values (e.g. join tokens)
+are sent as is. The auth key defaults to the value of the RTCD_AUTH_KEY
+environment variable.`
+
+// replayHelloTimeout is how long to wait for the service to greet a replay
+// connection before sending anything on it.
+const replayHelloTimeout = 10 * time.Second
+
+type replayConfig struct {
+	URL       string
+	ClientID  string
+	AuthKey   string
+	TracePath string
+	Speed     float64
+	Wait      time.Duration
+}
+
+func (c replayConfig) IsValid() error {
+	if c.URL == "" {
+		return fmt.Errorf("invalid URL value: should not be empty")
+	}
+	if c.ClientID == "" {
+		return fmt.Errorf("invalid ClientID value: should not be empty")
+	}
+	if c.AuthKey == "" {
+		return fmt.Errorf("invalid AuthKey value: should not be empty")
+	}
+	if c.TracePath == "" {
+		return fmt.Errorf("invalid TracePath value: should not be empty")
+	}
+	if c.Speed < 0 {
+		return fmt.Errorf("invalid Speed value: should not be negative")
+	}
+	if c.Wait < 0 {
+		return fmt.Errorf("invalid Wait value: should not be negative")
+	}
+	return nil
+}
+
+// runReplayCmd executes the replay subcommand with the given arguments,
+// logging the messages exchanged through logf. Closing stopCh stops the
+// replay early.
+func runReplayCmd(args []string, stopCh <-chan struct{}, logf func(format string, args ...interface{})) error {
+	var cfg replayConfig
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.StringVar(&cfg.URL, "url", "", "URL of the rtcd service.")
+	fs.StringVar(&cfg.ClientID, "client-id", "", "ID of the registered client the messages are sent as.")
+	fs.StringVar(&cfg.AuthKey, "auth-key", os.Getenv("RTCD_AUTH_KEY"), "Auth key of the registered client.")
+	fs.Float64Var(&cfg.Speed, "speed", 1, "Factor the recorded spacing of the messages is divided by. Zero sends them back to back.")
+	fs.DurationVar(&cfg.Wait, "wait", 5*time.Second, "Time to wait for the replies once all the messages are sent.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg.TracePath = fs.Arg(0)
+
+	if err := cfg.IsValid(); err != nil {
+		return fmt.Errorf("%w\n%s", err, replayUsage)
+	}
+
+	f, err := os.Open(cfg.TracePath)
+	if err != nil {
+		return fmt.Errorf("failed to open trace: %w", err)
+	}
+	entries, err := service.ReadSignalingTrace(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read trace: %w", err)
+	}
+
+	r := &replayer{
+		cfg:     cfg,
+		logf:    logf,
+		clients: map[string]*service.Client{},
+	}
+	defer r.close()
+
+	return r.run(entries, stopCh)
+}
+
+// replayer sends the messages of a signaling trace, over one connection per
+// recorded connection.
+type replayer struct {
+	cfg     replayConfig
+	logf    func(format string, args ...interface{})
+	clients map[string]*service.Client
+	wg      sync.WaitGroup
+}
+
+func (r *replayer) run(entries []service.SignalingTraceEntry, stopCh <-chan struct{}) error {
+	var sent int
+	var lastTimestamp int64
+	for _, entry := range entries {
+		if entry.Direction != service.SignalingTraceDirectionIn {
+			continue
+		}
+
+		if lastTimestamp != 0 && r.cfg.Speed > 0 && entry.Timestamp > lastTimestamp {
+			delay := time.Duration(float64(time.Duration(entry.Timestamp-lastTimestamp)*time.Millisecond) / r.cfg.Speed)
+			select {
+			case <-time.After(delay):
+			case <-stopCh:
+				return nil
+			}
+		}
+		lastTimestamp = entry.Timestamp
+
+		c, err := r.getClient(entry.ConnID)
+		if err != nil {
+			return err
+		}
+		cm := r.clientMessage(entry)
+		if err := c.Send(cm); err != nil {
+			return fmt.Errorf("failed to send %s message: %w", entry.Type, err)
+		}
+		sent++
+		r.logf("rtcd: replay: conn %s: sent %s", entry.ConnID, describeClientMessage(cm))
+	}
+
+	r.logf("rtcd: replay: sent %d messages", sent)
+
+	select {
+	case <-time.After(r.cfg.Wait):
+	case <-stopCh:
+	}
+
+	return nil
+}
+
+// clientMessage returns the message of the given entry, addressed to the
+// client replaying it.
+func (r *replayer) clientMessage(entry service.SignalingTraceEntry) service.ClientMessage {
+	cm := entry.ClientMessage()
+	switch data := cm.Data.(type) {
+	case rtc.Message:
+		data.GroupID = r.cfg.ClientID
+		cm.Data = data
+	case map[string]string:
+		delete(data, "groupID")
+	}
+	return cm
+}
+
+// getClient returns the client replaying the messages of the given recorded
+// connection, connecting it first if needed.
+func (r *replayer) getClient(connID string) (*service.Client, error) {
+	if c := r.clients[connID]; c != nil {
+		return c, nil
+	}
+
+	c, err := service.NewClient(service.ClientConfig{
+		URL:      r.cfg.URL,
+		ClientID: r.cfg.ClientID,
+		AuthKey:  r.cfg.AuthKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	c.OnError(func(err error) {
+		r.logf("rtcd: replay: conn %s: error: %s", connID, err.Error())
+	})
+	if err := c.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	r.clients[connID] = c
+
+	// Messages sent before the protocol is negotiated would be handled as
+	// coming from a legacy client.
+	select {
+	case cm := <-c.ReceiveCh():
+		if cm.Type != service.ClientMessageHello {
+			return nil, fmt.Errorf("unexpected %s message, expected hello", cm.Type)
+		}
+	case <-time.After(replayHelloTimeout):
+		return nil, fmt.Errorf("timed out waiting for hello message")
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for cm := range c.ReceiveCh() {
+			r.logf("rtcd: replay: conn %s: received %s", connID, describeClientMessage(cm))
+		}
+	}()
+
+	return c, nil
+}
+
+func (r *replayer) close() {
+	for _, c := range r.clients {
+		_ = c.Close()
+	}
+	r.wg.Wait()
+}
+
+func describeClientMessage(cm service.ClientMessage) string {
+	switch data := cm.Data.(type) {
+	case rtc.Message:
+		return fmt.Sprintf("%s message (type %d) for session %s: %s", cm.Type, data.Type, data.SessionID, data.Data)
+	case map[string]string:
+		js, _ := json.Marshal(data)
+		return fmt.Sprintf("%s message: %s", cm.Type, js)
+	}
+	return fmt.Sprintf("%s message", cm.Type)
+}
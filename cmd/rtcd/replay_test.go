@@ -0,0 +1,197 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/logger"
+	"github.com/mattermost/rtcd/service"
+	"github.com/mattermost/rtcd/service/api"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayConfigIsValid(t *testing.T) {
+	cfg := replayConfig{
+		URL:       "http://localhost:8045",
+		ClientID:  "clientID",
+		AuthKey:   "authKey",
+		TracePath: "trace.jsonl",
+		Speed:     1,
+	}
+	require.NoError(t, cfg.IsValid())
+
+	invalid := cfg
+	invalid.TracePath = ""
+	require.EqualError(t, invalid.IsValid(), "invalid TracePath value: should not be empty")
+
+	invalid = cfg
+	invalid.ClientID = ""
+	require.EqualError(t, invalid.IsValid(), "invalid ClientID value: should not be empty")
+
+	invalid = cfg
+	invalid.Speed = -1
+	require.EqualError(t, invalid.IsValid(), "invalid Speed value: should not be negative")
+
+	invalid = cfg
+	invalid.Wait = -time.Second
+	require.EqualError(t, invalid.IsValid(), "invalid Wait value: should not be negative")
+}
+
+func TestRunReplayCmd(t *testing.T) {
+	var logs []string
+	var logsMut sync.Mutex
+	logf := func(format string, args ...interface{}) {
+		logsMut.Lock()
+		defer logsMut.Unlock()
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+
+	t.Run("missing flags", func(t *testing.T) {
+		err := runReplayCmd([]string{"-url", "http://localhost:8045"}, nil, logf)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "usage: rtcd replay")
+	})
+
+	t.Run("missing trace", func(t *testing.T) {
+		err := runReplayCmd([]string{
+			"-url", "http://localhost:8045",
+			"-client-id", "clientA",
+			"-auth-key", "authKey",
+			filepath.Join(t.TempDir(), "trace.jsonl"),
+		}, nil, logf)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to open trace")
+	})
+
+	// Finding a free port for the API.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+	apiURL := "http://127.0.0.1:" + strconv.Itoa(port)
+
+	cfg := service.Config{
+		API: service.APIConfig{
+			HTTP: api.Config{
+				ListenAddress: "127.0.0.1:" + strconv.Itoa(port),
+			},
+			Security: service.SecurityConfig{
+				EnableAdmin:    true,
+				AdminSecretKey: "admin_secret_key",
+			},
+		},
+		RTC: rtc.ServerConfig{
+			ICEPortUDP: 30465,
+		},
+		Store: service.StoreConfig{
+			DataSource: t.TempDir(),
+		},
+		Logger: logger.Config{
+			EnableConsole: true,
+			ConsoleLevel:  "ERROR",
+		},
+	}
+	cfg.API.Security.SessionCache.ExpirationMinutes = 1440
+	srvc, err := service.New(cfg)
+	require.NoError(t, err)
+	require.NoError(t, srvc.Start())
+	defer func() {
+		require.NoError(t, srvc.Stop())
+	}()
+
+	adminClient, err := service.NewClient(service.ClientConfig{URL: apiURL, AuthKey: cfg.API.Security.AdminSecretKey})
+	require.NoError(t, err)
+	defer adminClient.Close()
+	authKey := "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H"
+	require.NoError(t, adminClient.Register("clientA", authKey))
+
+	// A trace recorded on another service, with two connections.
+	now := time.Now().UnixMilli()
+	entries := []service.SignalingTraceEntry{
+		{
+			Timestamp: now,
+			Direction: service.SignalingTraceDirectionIn,
+			ConnID:    "connA",
+			Type:      service.ClientMessageJoin,
+			Data: map[string]string{
+				"groupID":   "recordedGroupID",
+				"callID":    "callID",
+				"userID":    "userA",
+				"sessionID": "sessionA",
+				"traceID":   "traceA",
+				"token":     "redacted-redacted-redacted",
+			},
+		},
+		{
+			Timestamp: now + 10,
+			Direction: service.SignalingTraceDirectionIn,
+			ConnID:    "connB",
+			Type:      service.ClientMessageJoin,
+			Data: map[string]string{
+				"callID":    "callID",
+				"userID":    "userB",
+				"sessionID": "sessionB",
+			},
+		},
+		{
+			Timestamp: now + 20,
+			Direction: service.SignalingTraceDirectionIn,
+			ConnID:    "connA",
+			Type:      service.ClientMessageLeave,
+			Data: map[string]string{
+				"sessionID": "sessionA",
+			},
+		},
+		{
+			Timestamp: now + 25,
+			Direction: service.SignalingTraceDirectionOut,
+			ConnID:    "connA",
+			Type:      service.ClientMessageClose,
+			Data: map[string]string{
+				"sessionID": "sessionA",
+				"reason":    rtc.CloseReasonLeft,
+			},
+		},
+	}
+	tracePath := filepath.Join(t.TempDir(), "trace.jsonl")
+	f, err := os.Create(tracePath)
+	require.NoError(t, err)
+	enc := json.NewEncoder(f)
+	for _, entry := range entries {
+		require.NoError(t, enc.Encode(entry))
+	}
+	require.NoError(t, f.Close())
+
+	err = runReplayCmd([]string{
+		"-url", apiURL,
+		"-client-id", "clientA",
+		"-auth-key", authKey,
+		"-speed", "0",
+		"-wait", "1s",
+		tracePath,
+	}, nil, logf)
+	require.NoError(t, err)
+
+	logsMut.Lock()
+	defer logsMut.Unlock()
+	joined := strings.Join(logs, "\n")
+	require.Contains(t, joined, "rtcd: replay: conn connA: sent join message")
+	require.Contains(t, joined, "rtcd: replay: conn connB: sent join message")
+	require.Contains(t, joined, "rtcd: replay: conn connA: sent leave message")
+	require.Contains(t, joined, "rtcd: replay: sent 3 messages")
+	require.Contains(t, joined, `rtcd: replay: conn connA: received close message: {"reason":"left","sessionID":"sessionA","traceID":"traceA"}`)
+	require.NotContains(t, joined, "recordedGroupID")
+}
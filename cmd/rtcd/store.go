@@ -0,0 +1,186 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/mattermost/rtcd/service"
+	"github.com/mattermost/rtcd/service/store"
+)
+
+const storeUsage = `usage: rtcd store <export|import> [-config path] [-file path] [-strict]
+       rtcd store migrate [-config path] [-version n] [-dry-run] [-strict]
+       rtcd store compact [-config path] [-strict]
+
+Exports or imports the client registrations in the store in a portable JSON
+format, the internal state of the service never being exported, or migrates
+its schema to the given version (defaults to the latest one, which the service
+migrates to at startup). A lower version rolls back the migrations above it. Compacting reclaims the space taken by
+overwritten and deleted entries. The service must not be running as the store
+can only be opened by a single process.`
+
+// runStoreCmd executes the store subcommand with the given arguments.
+func runStoreCmd(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing store command\n%s", storeUsage)
+	}
+
+	cmd := args[0]
+	if cmd != "export" && cmd != "import" && cmd != "migrate" && cmd != "compact" {
+		return fmt.Errorf("invalid store command %q\n%s", cmd, storeUsage)
+	}
+
+	var configPath string
+	var filePath string
+	var strictConfig bool
+	var version int
+	var dryRun bool
+	fs := flag.NewFlagSet("store "+cmd, flag.ContinueOnError)
+	fs.StringVar(&configPath, "config", "config/config.toml", "Path to the configuration file for the rtcd service.")
+	fs.BoolVar(&strictConfig, "strict", false, "Fail on unknown keys in the configuration file or unknown RTCD_ environment variables.")
+	if cmd == "migrate" {
+		fs.IntVar(&version, "version", service.LatestStoreSchemaVersion, "Schema version to migrate the store to.")
+		fs.BoolVar(&dryRun, "dry-run", false, "Only print the migrations that would be applied.")
+	} else if cmd != "compact" {
+		fs.StringVar(&filePath, "file", "", "Path to the dump file. Defaults to stdout (export) or stdin (import).")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, _, err := loadConfig(configPath, strictConfig)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if err := cfg.Store.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate store config: %w", err)
+	}
+
+	st, err := service.OpenStore(cfg.Store)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer st.Close()
+
+	switch cmd {
+	case "export":
+		return exportStore(st, filePath, stdout)
+	case "migrate":
+		return migrateStore(st, version, dryRun)
+	case "compact":
+		return compactStore(st)
+	}
+	return importStore(st, filePath, stdin)
+}
+
+func exportStore(st store.Store, filePath string, stdout io.Writer) error {
+	dump, err := store.Export(st)
+	if err != nil {
+		return fmt.Errorf("failed to export store: %w", err)
+	}
+
+	w := stdout
+	if filePath != "" {
+		f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := store.WriteDump(w, dump); err != nil {
+		return err
+	}
+
+	log.Printf("rtcd: exported %d store entries", len(dump.Entries))
+
+	return nil
+}
+
+func importStore(st store.Store, filePath string, stdin io.Reader) error {
+	r := stdin
+	if filePath != "" {
+		f, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	dump, err := store.ReadDump(r)
+	if err != nil {
+		return err
+	}
+
+	n, err := store.Import(st, dump)
+	if err != nil {
+		return fmt.Errorf("failed to import store: %w", err)
+	}
+
+	log.Printf("rtcd: imported %d store entries", n)
+
+	return nil
+}
+
+func migrateStore(st store.Store, version int, dryRun bool) error {
+	if version < 0 {
+		return fmt.Errorf("invalid version %d: should not be negative", version)
+	}
+
+	current, err := store.SchemaVersion(st)
+	if err != nil {
+		return err
+	}
+	log.Printf("rtcd: store schema version is %d", current)
+
+	steps, err := service.MigrateStore(st, version, dryRun)
+	for _, step := range steps {
+		action := "applied"
+		switch {
+		case dryRun && step.Down:
+			action = "would roll back"
+		case dryRun:
+			action = "would apply"
+		case step.Down:
+			action = "rolled back"
+		}
+		log.Printf("rtcd: %s store migration %d: %s", action, step.Version, step.Description)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to migrate store: %w", err)
+	}
+
+	if len(steps) == 0 {
+		log.Printf("rtcd: store schema is already at version %d", version)
+	}
+
+	return nil
+}
+
+func compactStore(st store.Store) error {
+	stats, err := st.Stats()
+	if err != nil {
+		return err
+	}
+
+	if err := st.Compact(); err != nil {
+		return fmt.Errorf("failed to compact store: %w", err)
+	}
+
+	compacted, err := st.Stats()
+	if err != nil {
+		return err
+	}
+	log.Printf("rtcd: compacted store from %d to %d bytes", stats.SizeBytes, compacted.SizeBytes)
+
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mattermost/rtcd/service"
+	"github.com/mattermost/rtcd/service/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunStoreCmd(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	srcDir := filepath.Join(dir, "src")
+	dstDir := filepath.Join(dir, "dst")
+
+	writeConfig := func(t *testing.T, dataSource string) {
+		t.Helper()
+		err := os.WriteFile(configPath, []byte("[store]\ndata_source = \""+dataSource+"\"\n"), 0600)
+		require.NoError(t, err)
+	}
+
+	st, err := store.New(srcDir)
+	require.NoError(t, err)
+	require.NoError(t, st.Set("clientA", "hashA"))
+	require.NoError(t, st.Close())
+
+	t.Run("invalid command", func(t *testing.T) {
+		err := runStoreCmd(nil, nil, nil)
+		require.Error(t, err)
+		err = runStoreCmd([]string{"invalid"}, nil, nil)
+		require.Error(t, err)
+	})
+
+	var buf bytes.Buffer
+	t.Run("export", func(t *testing.T) {
+		writeConfig(t, srcDir)
+		err := runStoreCmd([]string{"export", "-config", configPath}, nil, &buf)
+		require.NoError(t, err)
+		require.Contains(t, buf.String(), `"key": "clientA"`)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		writeConfig(t, dstDir)
+		err := runStoreCmd([]string{"import", "-config", configPath}, &buf, nil)
+		require.NoError(t, err)
+
+		st, err := store.New(dstDir)
+		require.NoError(t, err)
+		defer st.Close()
+		val, err := st.Get("clientA")
+		require.NoError(t, err)
+		require.Equal(t, "hashA", val)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		dumpPath := filepath.Join(dir, "dump.json")
+		writeConfig(t, dstDir)
+		err := runStoreCmd([]string{"export", "-config", configPath, "-file", dumpPath}, nil, nil)
+		require.NoError(t, err)
+
+		writeConfig(t, srcDir)
+		err = runStoreCmd([]string{"import", "-config", configPath, "-file", dumpPath}, nil, nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("migrate", func(t *testing.T) {
+		writeConfig(t, srcDir)
+		err := runStoreCmd([]string{"migrate", "-config", configPath, "-dry-run"}, nil, nil)
+		require.NoError(t, err)
+		checkVersion := func(t *testing.T, expected int) {
+			t.Helper()
+			st, err := store.New(srcDir)
+			require.NoError(t, err)
+			defer st.Close()
+			version, err := store.SchemaVersion(st)
+			require.NoError(t, err)
+			require.Equal(t, expected, version)
+		}
+		checkVersion(t, 0)
+
+		err = runStoreCmd([]string{"migrate", "-config", configPath}, nil, nil)
+		require.NoError(t, err)
+		checkVersion(t, service.LatestStoreSchemaVersion)
+
+		err = runStoreCmd([]string{"migrate", "-config", configPath, "-version", "0"}, nil, nil)
+		require.NoError(t, err)
+		checkVersion(t, 0)
+
+		err = runStoreCmd([]string{"migrate", "-config", configPath, "-version", "-1"}, nil, nil)
+		require.EqualError(t, err, "invalid version -1: should not be negative")
+	})
+	t.Run("compact", func(t *testing.T) {
+		writeConfig(t, srcDir)
+		st, err := store.New(srcDir)
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			require.NoError(t, st.Set("clientB", "hashB"))
+		}
+		require.NoError(t, st.Close())
+
+		err = runStoreCmd([]string{"compact", "-config", configPath}, nil, nil)
+		require.NoError(t, err)
+
+		st, err = store.New(srcDir)
+		require.NoError(t, err)
+		defer st.Close()
+		stats, err := st.Stats()
+		require.NoError(t, err)
+		require.Zero(t, stats.ReclaimableBytes)
+		val, err := st.Get("clientB")
+		require.NoError(t, err)
+		require.Equal(t, "hashB", val)
+	})
+}
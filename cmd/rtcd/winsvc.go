@@ -0,0 +1,64 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+)
+
+const serviceUsage = `usage: rtcd service <install|uninstall|run> [-name name] [-config path] [-strict] [-skip-preflight]
+
+Manages rtcd as a Windows service. install registers the service (started
+automatically at boot) with the given configuration file, uninstall removes
+it and run is invoked by the service control manager.`
+
+const defaultServiceName = "rtcd"
+
+// serviceCmdOpts holds the parsed arguments of the service subcommand.
+type serviceCmdOpts struct {
+	cmd           string
+	name          string
+	configPath    string
+	strictConfig  bool
+	skipPreflight bool
+}
+
+func parseServiceCmd(args []string) (serviceCmdOpts, error) {
+	var opts serviceCmdOpts
+	if len(args) == 0 {
+		return opts, fmt.Errorf("missing service command\n%s", serviceUsage)
+	}
+
+	opts.cmd = args[0]
+	switch opts.cmd {
+	case "install", "uninstall", "run":
+	default:
+		return opts, fmt.Errorf("invalid service command %q\n%s", opts.cmd, serviceUsage)
+	}
+
+	fs := flag.NewFlagSet("service "+opts.cmd, flag.ContinueOnError)
+	fs.StringVar(&opts.name, "name", defaultServiceName, "Name of the Windows service.")
+	fs.StringVar(&opts.configPath, "config", "config/config.toml", "Path to the configuration file for the rtcd service.")
+	fs.BoolVar(&opts.strictConfig, "strict", false, "Fail on unknown keys in the configuration file or unknown RTCD_ environment variables.")
+	fs.BoolVar(&opts.skipPreflight, "skip-preflight", false, "Skip the checks of the environment run before starting.")
+	if err := fs.Parse(args[1:]); err != nil {
+		return opts, err
+	}
+
+	if opts.name == "" {
+		return opts, fmt.Errorf("invalid name: should not be empty")
+	}
+
+	// Services are started from the system directory, so the path must not
+	// depend on the working directory.
+	configPath, err := filepath.Abs(opts.configPath)
+	if err != nil {
+		return opts, fmt.Errorf("failed to get config path: %w", err)
+	}
+	opts.configPath = configPath
+
+	return opts, nil
+}
@@ -0,0 +1,20 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// runServiceCmd executes the service subcommand, which is only available on
+// Windows.
+func runServiceCmd(args []string) error {
+	if _, err := parseServiceCmd(args); err != nil {
+		return err
+	}
+	return fmt.Errorf("service command is not supported on %s, use the init system instead", runtime.GOOS)
+}
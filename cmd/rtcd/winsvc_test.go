@@ -0,0 +1,49 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseServiceCmd(t *testing.T) {
+	t.Run("invalid command", func(t *testing.T) {
+		_, err := parseServiceCmd(nil)
+		require.Error(t, err)
+		_, err = parseServiceCmd([]string{"invalid"})
+		require.Error(t, err)
+	})
+
+	t.Run("empty name", func(t *testing.T) {
+		_, err := parseServiceCmd([]string{"install", "-name", ""})
+		require.Error(t, err)
+		require.Equal(t, "invalid name: should not be empty", err.Error())
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		opts, err := parseServiceCmd([]string{"install"})
+		require.NoError(t, err)
+		require.Equal(t, "install", opts.cmd)
+		require.Equal(t, defaultServiceName, opts.name)
+		require.False(t, opts.strictConfig)
+		require.True(t, filepath.IsAbs(opts.configPath))
+		require.True(t, strings.HasSuffix(opts.configPath, filepath.Join("config", "config.toml")))
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.toml")
+		opts, err := parseServiceCmd([]string{"run", "-name", "rtcd-lab", "-config", configPath, "-strict"})
+		require.NoError(t, err)
+		require.Equal(t, serviceCmdOpts{
+			cmd:          "run",
+			name:         "rtcd-lab",
+			configPath:   configPath,
+			strictConfig: true,
+		}, opts)
+	})
+}
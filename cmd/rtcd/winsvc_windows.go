@@ -0,0 +1,166 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// windowsService runs rtcd under the service control manager.
+type windowsService struct {
+	opts serviceCmdOpts
+	elog *eventlog.Log
+}
+
+// runServiceCmd executes the service subcommand with the given arguments.
+func runServiceCmd(args []string) error {
+	opts, err := parseServiceCmd(args)
+	if err != nil {
+		return err
+	}
+
+	switch opts.cmd {
+	case "install":
+		return installService(opts)
+	case "uninstall":
+		return uninstallService(opts.name)
+	default:
+		return runWindowsService(opts)
+	}
+}
+
+func installService(opts serviceCmdOpts) error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(opts.name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %q already exists", opts.name)
+	}
+
+	args := []string{"service", "run", "-name", opts.name, "-config", opts.configPath}
+	if opts.strictConfig {
+		args = append(args, "-strict")
+	}
+	if opts.skipPreflight {
+		args = append(args, "-skip-preflight")
+	}
+	s, err := m.CreateService(opts.name, exePath, mgr.Config{
+		DisplayName: "rtcd",
+		Description: "Mattermost Calls WebRTC server",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer s.Close()
+
+	if err := eventlog.InstallAsEventCreate(opts.name, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		_ = s.Delete()
+		return fmt.Errorf("failed to install event log source: %w", err)
+	}
+
+	return nil
+}
+
+func uninstallService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("failed to open service %q: %w", name, err)
+	}
+	defer s.Close()
+
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service: %w", err)
+	}
+
+	if err := eventlog.Remove(name); err != nil {
+		return fmt.Errorf("failed to remove event log source: %w", err)
+	}
+
+	return nil
+}
+
+func runWindowsService(opts serviceCmdOpts) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return fmt.Errorf("failed to determine whether running as a service: %w", err)
+	}
+	if !isService {
+		return fmt.Errorf("service run should only be invoked by the service control manager")
+	}
+
+	elog, err := eventlog.Open(opts.name)
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer elog.Close()
+
+	if err := svc.Run(opts.name, &windowsService{opts: opts, elog: elog}); err != nil {
+		_ = elog.Error(1, fmt.Sprintf("rtcd: service failed: %s", err.Error()))
+		return fmt.Errorf("failed to run service: %w", err)
+	}
+
+	return nil
+}
+
+// Execute implements svc.Handler.
+func (ws *windowsService) Execute(_ []string, reqCh <-chan svc.ChangeRequest, statusCh chan<- svc.Status) (bool, uint32) {
+	statusCh <- svc.Status{State: svc.StartPending}
+
+	stopCh := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runService(ws.opts.configPath, ws.opts.strictConfig, ws.opts.skipPreflight, stopCh)
+	}()
+
+	statusCh <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	_ = ws.elog.Info(1, "rtcd: service started")
+
+	for {
+		select {
+		case err := <-errCh:
+			return ws.exit(err)
+		case req := <-reqCh:
+			switch req.Cmd {
+			case svc.Interrogate:
+				statusCh <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				statusCh <- svc.Status{State: svc.StopPending}
+				close(stopCh)
+				return ws.exit(<-errCh)
+			default:
+				_ = ws.elog.Warning(1, fmt.Sprintf("rtcd: unexpected control request %d", req.Cmd))
+			}
+		}
+	}
+}
+
+func (ws *windowsService) exit(err error) (bool, uint32) {
+	if err != nil {
+		_ = ws.elog.Error(1, "rtcd: "+err.Error())
+		return true, 1
+	}
+	_ = ws.elog.Info(1, "rtcd: service stopped")
+	return false, 0
+}
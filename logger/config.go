@@ -4,6 +4,7 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -20,6 +21,52 @@ type Config struct {
 	FileLevel     string `toml:"file_level"`
 	FileLocation  string `toml:"file_location"`
 	EnableColor   bool   `toml:"enable_color"`
+	// EnableAudit controls whether audit entries get written to a dedicated,
+	// append-only file.
+	EnableAudit       bool   `toml:"enable_audit"`
+	AuditFileLocation string `toml:"audit_file_location"`
+	// Tenants routes the records of the given registered clients to
+	// dedicated files, on top of the global ones.
+	Tenants TenantsConfig `toml:"tenants"`
+}
+
+// TenantConfig routes the records attributed to a registered client (i.e.
+// carrying its ID in their clientID or groupID field) to a dedicated file,
+// possibly at a more verbose level than the global targets.
+type TenantConfig struct {
+	ClientID     string `toml:"client_id" json:"client_id"`
+	FileJSON     bool   `toml:"file_json" json:"file_json"`
+	FileLevel    string `toml:"file_level" json:"file_level"`
+	FileLocation string `toml:"file_location" json:"file_location"`
+}
+
+type TenantsConfig []TenantConfig
+
+func isValidLevel(level string) bool {
+	for _, l := range mlog.StdAll {
+		if strings.ToLower(level) == l.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c TenantConfig) IsValid() error {
+	if c.ClientID == "" {
+		return fmt.Errorf("invalid ClientID value: should not be empty")
+	}
+	if !isValidLevel(c.FileLevel) {
+		return fmt.Errorf("invalid FileLevel value %q", c.FileLevel)
+	}
+	if c.FileLocation == "" {
+		return fmt.Errorf("invalid FileLocation value: should not be empty")
+	}
+	return nil
+}
+
+// Decode parses the tenants from a JSON list, as set through the environment.
+func (c *TenantsConfig) Decode(value string) error {
+	return json.Unmarshal([]byte(value), c)
 }
 
 func (c Config) IsValid() error {
@@ -45,5 +92,26 @@ func (c Config) IsValid() error {
 	if c.EnableFile && c.FileLocation == "" {
 		return fmt.Errorf("invalid FileLocation value: should not be empty")
 	}
+
+	if c.EnableAudit && c.AuditFileLocation == "" {
+		return fmt.Errorf("invalid AuditFileLocation value: should not be empty")
+	}
+
+	clientIDs := map[string]bool{}
+	files := map[string]bool{c.FileLocation: c.EnableFile, c.AuditFileLocation: c.EnableAudit}
+	for _, tenant := range c.Tenants {
+		if err := tenant.IsValid(); err != nil {
+			return fmt.Errorf("invalid tenant config: %w", err)
+		}
+		if clientIDs[tenant.ClientID] {
+			return fmt.Errorf("invalid tenant config: duplicate ClientID %q", tenant.ClientID)
+		}
+		if files[tenant.FileLocation] {
+			return fmt.Errorf("invalid tenant config: FileLocation %q is already used", tenant.FileLocation)
+		}
+		clientIDs[tenant.ClientID] = true
+		files[tenant.FileLocation] = true
+	}
+
 	return nil
 }
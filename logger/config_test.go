@@ -62,4 +62,58 @@ func TestIsValid(t *testing.T) {
 		err = cfg.IsValid()
 		require.NoError(t, err)
 	})
+	t.Run("AuditFileLocation", func(t *testing.T) {
+		var cfg Config
+		cfg.EnableConsole = true
+		cfg.ConsoleLevel = "INFO"
+		cfg.EnableAudit = true
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid AuditFileLocation value: should not be empty`, err.Error())
+		cfg.AuditFileLocation = "rtcd_audit.log"
+		err = cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("Tenants", func(t *testing.T) {
+		var cfg Config
+		cfg.EnableFile = true
+		cfg.FileLevel = "INFO"
+		cfg.FileLocation = "rtcd.log"
+		cfg.Tenants = TenantsConfig{{}}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid tenant config: invalid ClientID value: should not be empty", err.Error())
+
+		cfg.Tenants[0].ClientID = "clientA"
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid tenant config: invalid FileLevel value ""`, err.Error())
+
+		cfg.Tenants[0].FileLevel = "DEBUG"
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid tenant config: invalid FileLocation value: should not be empty", err.Error())
+
+		cfg.Tenants[0].FileLocation = "rtcd.log"
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid tenant config: FileLocation "rtcd.log" is already used`, err.Error())
+
+		cfg.Tenants[0].FileLocation = "rtcd_clientA.log"
+		err = cfg.IsValid()
+		require.NoError(t, err)
+
+		cfg.Tenants = append(cfg.Tenants, TenantConfig{ClientID: "clientA", FileLevel: "DEBUG", FileLocation: "rtcd_clientA2.log"})
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid tenant config: duplicate ClientID "clientA"`, err.Error())
+	})
+
+	t.Run("Tenants decode", func(t *testing.T) {
+		var tenants TenantsConfig
+		err := tenants.Decode(`[{"client_id": "clientA", "file_level": "DEBUG", "file_location": "rtcd_clientA.log"}]`)
+		require.NoError(t, err)
+		require.Equal(t, TenantsConfig{{ClientID: "clientA", FileLevel: "DEBUG", FileLocation: "rtcd_clientA.log"}}, tenants)
+	})
 }
@@ -9,8 +9,14 @@ import (
 	"strings"
 
 	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+
+	"github.com/mattermost/logr/v2/targets"
 )
 
+// recentTargetType is the type of the custom target keeping the recent
+// records.
+const recentTargetType = "recent"
+
 func getLevels(level string) []mlog.Level {
 	var levels []mlog.Level
 	for _, l := range mlog.StdAll {
@@ -22,8 +28,9 @@ func getLevels(level string) []mlog.Level {
 	return levels
 }
 
-// New returns a newly created and initialized logger with the given cfg.
-func New(config Config) (*mlog.Logger, error) {
+// New returns a newly created and initialized logger with the given cfg. The
+// records are also written to recent, if not nil.
+func New(config Config, recent *RecentLogs) (*mlog.Logger, error) {
 	if err := config.IsValid(); err != nil {
 		return nil, err
 	}
@@ -33,6 +40,21 @@ func New(config Config) (*mlog.Logger, error) {
 		return nil, err
 	}
 
+	if err := Configure(logger, config, recent); err != nil {
+		return nil, err
+	}
+
+	return logger, nil
+}
+
+// Configure replaces the targets of the given logger with the ones defined
+// in config, plus recent if not nil. It can be used to change logging levels
+// at runtime.
+func Configure(logger *mlog.Logger, config Config, recent *RecentLogs) error {
+	if err := config.IsValid(); err != nil {
+		return err
+	}
+
 	cfg := mlog.LoggerConfiguration{}
 	if config.EnableConsole {
 		var format string
@@ -76,9 +98,78 @@ func New(config Config) (*mlog.Logger, error) {
 			MaxQueueSize:  1000,
 		}
 	}
-	if err := logger.ConfigureTargets(cfg, nil); err != nil {
-		return nil, err
+	if config.EnableAudit {
+		// Audit entries are logged at the dedicated audit level which no other
+		// target accepts. The file is never compressed nor pruned.
+		opts := fmt.Sprintf(`{"filename": "%s", "max_size": 100, "max_age": 0, "max_backups": 0, "compress": false}`, config.AuditFileLocation)
+		cfg["_audit"] = mlog.TargetCfg{
+			Type:          "file",
+			Levels:        []mlog.Level{mlog.LvlAuditAPI},
+			Options:       json.RawMessage(opts),
+			Format:        "json",
+			FormatOptions: json.RawMessage(`{"enable_caller": false}`),
+			MaxQueueSize:  1000,
+		}
 	}
 
-	return logger, nil
+	for _, tenant := range config.Tenants {
+		format := "plain"
+		formatOpts := `{"delim": " ", "min_level_len": 5, "min_msg_len": 45, "enable_color": false, "enable_caller": true}`
+		if tenant.FileJSON {
+			format = "json"
+			formatOpts = `{"enable_caller": true}`
+		}
+		opts, err := json.Marshal(tenantTargetOptions{
+			FileOptions: targets.FileOptions{
+				Filename: tenant.FileLocation,
+				MaxSize:  100,
+				Compress: true,
+			},
+			ClientID: tenant.ClientID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal tenant target options: %w", err)
+		}
+		cfg["_tenant_"+tenant.ClientID] = mlog.TargetCfg{
+			Type:          tenantTargetType,
+			Levels:        getLevels(tenant.FileLevel),
+			Options:       opts,
+			Format:        format,
+			FormatOptions: json.RawMessage(formatOpts),
+			MaxQueueSize:  1000,
+		}
+	}
+
+	var factories *mlog.Factories
+	if recent != nil || len(config.Tenants) > 0 {
+		factories = &mlog.Factories{
+			TargetFactory: func(targetType string, options json.RawMessage) (mlog.Target, error) {
+				switch targetType {
+				case recentTargetType:
+					return recent, nil
+				case tenantTargetType:
+					return newTenantTarget(options)
+				}
+				return nil, fmt.Errorf("unexpected target type %q", targetType)
+			},
+		}
+	}
+
+	if recent != nil {
+		// The records are kept at the file level, or the console one if
+		// logging to file is disabled.
+		level := config.ConsoleLevel
+		if config.EnableFile {
+			level = config.FileLevel
+		}
+		cfg["_recent"] = mlog.TargetCfg{
+			Type:          recentTargetType,
+			Levels:        getLevels(level),
+			Format:        "json",
+			FormatOptions: json.RawMessage(`{"enable_caller": true}`),
+			MaxQueueSize:  1000,
+		}
+	}
+
+	return logger.ConfigureTargets(cfg, factories)
 }
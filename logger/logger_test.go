@@ -4,6 +4,8 @@
 package logger
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -58,7 +60,7 @@ func TestGetLevels(t *testing.T) {
 func TestNewLogger(t *testing.T) {
 	t.Run("empty cfg", func(t *testing.T) {
 		var cfg Config
-		logger, err := New(cfg)
+		logger, err := New(cfg, nil)
 		require.Nil(t, logger)
 		require.Error(t, err)
 	})
@@ -67,7 +69,7 @@ func TestNewLogger(t *testing.T) {
 		var cfg Config
 		cfg.EnableConsole = true
 		cfg.ConsoleLevel = "INVALID"
-		logger, err := New(cfg)
+		logger, err := New(cfg, nil)
 		require.Nil(t, logger)
 		require.Error(t, err)
 		require.Equal(t, `invalid ConsoleLevel value "INVALID"`, err.Error())
@@ -77,8 +79,110 @@ func TestNewLogger(t *testing.T) {
 		var cfg Config
 		cfg.EnableConsole = true
 		cfg.ConsoleLevel = "INFO"
-		logger, err := New(cfg)
+		logger, err := New(cfg, nil)
 		require.NoError(t, err)
 		require.NotNil(t, logger)
 	})
+	t.Run("audit", func(t *testing.T) {
+		auditFile := filepath.Join(t.TempDir(), "audit.log")
+
+		var cfg Config
+		cfg.EnableConsole = true
+		cfg.ConsoleLevel = "DEBUG"
+		cfg.EnableAudit = true
+		cfg.AuditFileLocation = auditFile
+		logger, err := New(cfg, nil)
+		require.NoError(t, err)
+		require.NotNil(t, logger)
+
+		logger.Info("regular entry")
+		logger.Log(mlog.LvlAuditAPI, "audit entry", mlog.String("actor", "admin"))
+		require.NoError(t, logger.Shutdown())
+
+		data, err := os.ReadFile(auditFile)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "audit entry")
+		require.Contains(t, string(data), `"actor":"admin"`)
+		require.NotContains(t, string(data), "regular entry")
+	})
+}
+
+func TestConfigure(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "rtcd.log")
+
+	var cfg Config
+	cfg.EnableFile = true
+	cfg.FileLocation = logFile
+	cfg.FileLevel = "ERROR"
+	logger, err := New(cfg, nil)
+	require.NoError(t, err)
+	require.NotNil(t, logger)
+
+	t.Run("invalid cfg", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.FileLevel = "INVALID"
+		err := Configure(logger, invalidCfg, nil)
+		require.Error(t, err)
+		require.Equal(t, `invalid FileLevel value "INVALID"`, err.Error())
+	})
+
+	t.Run("level change", func(t *testing.T) {
+		logger.Info("filtered entry")
+
+		debugCfg := cfg
+		debugCfg.FileLevel = "DEBUG"
+		err := Configure(logger, debugCfg, nil)
+		require.NoError(t, err)
+
+		logger.Info("visible entry")
+		require.NoError(t, logger.Shutdown())
+
+		data, err := os.ReadFile(logFile)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "visible entry")
+		require.NotContains(t, string(data), "filtered entry")
+	})
+}
+
+func TestTenantRouting(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "rtcd.log")
+	tenantFile := filepath.Join(dir, "rtcd_clientA.log")
+
+	var cfg Config
+	cfg.EnableFile = true
+	cfg.FileLocation = logFile
+	cfg.FileLevel = "INFO"
+	cfg.Tenants = TenantsConfig{
+		{
+			ClientID:     "clientA",
+			FileJSON:     true,
+			FileLevel:    "DEBUG",
+			FileLocation: tenantFile,
+		},
+	}
+	logger, err := New(cfg, nil)
+	require.NoError(t, err)
+	require.NotNil(t, logger)
+
+	logger.Debug("tenant debug entry", mlog.String("clientID", "clientA"))
+	logger.Info("tenant group entry", mlog.String("groupID", "clientA"), mlog.String("sessionID", "sessionA"))
+	logger.Debug("other tenant entry", mlog.String("clientID", "clientB"))
+	logger.Info("global entry")
+	require.NoError(t, logger.Shutdown())
+
+	data, err := os.ReadFile(tenantFile)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "tenant debug entry")
+	require.Contains(t, string(data), "tenant group entry")
+	require.NotContains(t, string(data), "other tenant entry")
+	require.NotContains(t, string(data), "global entry")
+
+	// The global log is left at its own level.
+	data, err = os.ReadFile(logFile)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "tenant group entry")
+	require.Contains(t, string(data), "global entry")
+	require.NotContains(t, string(data), "tenant debug entry")
+	require.NotContains(t, string(data), "other tenant entry")
 }
@@ -0,0 +1,80 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package logger
+
+import (
+	"io"
+	"sync"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// RecentLogs is a log target keeping the most recent records in memory so
+// that they can be included in diagnostic reports.
+type RecentLogs struct {
+	lines [][]byte
+	next  int
+	full  bool
+	mut   sync.Mutex
+}
+
+// NewRecentLogs returns a target keeping the given number of records.
+func NewRecentLogs(size int) *RecentLogs {
+	return &RecentLogs{
+		lines: make([][]byte, size),
+	}
+}
+
+// Init implements mlog.Target.
+func (r *RecentLogs) Init() error {
+	return nil
+}
+
+// Write implements mlog.Target.
+func (r *RecentLogs) Write(p []byte, _ *mlog.LogRec) (int, error) {
+	if len(r.lines) == 0 {
+		return len(p), nil
+	}
+
+	line := make([]byte, len(p))
+	copy(line, p)
+
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+
+	return len(p), nil
+}
+
+// Shutdown implements mlog.Target. The records are kept so that the target
+// can be added again when the logger is reconfigured.
+func (r *RecentLogs) Shutdown() error {
+	return nil
+}
+
+// WriteTo writes the records to w, oldest first.
+func (r *RecentLogs) WriteTo(w io.Writer) (int64, error) {
+	r.mut.Lock()
+	lines := make([][]byte, 0, len(r.lines))
+	if r.full {
+		lines = append(lines, r.lines[r.next:]...)
+	}
+	lines = append(lines, r.lines[:r.next]...)
+	r.mut.Unlock()
+
+	var total int64
+	for _, line := range lines {
+		n, err := w.Write(line)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecentLogs(t *testing.T) {
+	t.Run("ring", func(t *testing.T) {
+		recent := NewRecentLogs(3)
+
+		var buf bytes.Buffer
+		_, err := recent.WriteTo(&buf)
+		require.NoError(t, err)
+		require.Empty(t, buf.String())
+
+		for i := 0; i < 2; i++ {
+			_, err := recent.Write([]byte(fmt.Sprintf("line%d\n", i)), nil)
+			require.NoError(t, err)
+		}
+		_, err = recent.WriteTo(&buf)
+		require.NoError(t, err)
+		require.Equal(t, "line0\nline1\n", buf.String())
+
+		for i := 2; i < 7; i++ {
+			_, err := recent.Write([]byte(fmt.Sprintf("line%d\n", i)), nil)
+			require.NoError(t, err)
+		}
+		buf.Reset()
+		n, err := recent.WriteTo(&buf)
+		require.NoError(t, err)
+		require.Equal(t, "line4\nline5\nline6\n", buf.String())
+		require.Equal(t, int64(buf.Len()), n)
+	})
+
+	t.Run("zero size", func(t *testing.T) {
+		recent := NewRecentLogs(0)
+		n, err := recent.Write([]byte("line"), nil)
+		require.NoError(t, err)
+		require.Equal(t, 4, n)
+	})
+
+	t.Run("logger target", func(t *testing.T) {
+		cfg := Config{
+			EnableConsole: true,
+			ConsoleLevel:  "ERROR",
+			EnableFile:    true,
+			FileLevel:     "INFO",
+			FileLocation:  t.TempDir() + "/rtcd.log",
+		}
+		recent := NewRecentLogs(10)
+		logger, err := New(cfg, recent)
+		require.NoError(t, err)
+
+		logger.Debug("filtered entry")
+		logger.Info("first entry")
+
+		require.NoError(t, logger.Flush())
+
+		// The records are kept across reconfigurations.
+		cfg.FileLevel = "DEBUG"
+		require.NoError(t, Configure(logger, cfg, recent))
+		logger.Debug("second entry")
+		require.NoError(t, logger.Shutdown())
+
+		var buf bytes.Buffer
+		_, err = recent.WriteTo(&buf)
+		require.NoError(t, err)
+		require.NotContains(t, buf.String(), "filtered entry")
+		require.Contains(t, buf.String(), `"msg":"first entry"`)
+		require.Contains(t, buf.String(), `"msg":"second entry"`)
+	})
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+
+	"github.com/mattermost/logr/v2/targets"
+)
+
+// tenantTargetType is the type of the custom targets writing the records of
+// a single tenant.
+const tenantTargetType = "tenant"
+
+// tenantFields are the fields a record is attributed to a tenant by: the
+// client a connection authenticated as, or the group a call belongs to.
+var tenantFields = map[string]bool{
+	"clientID": true,
+	"groupID":  true,
+}
+
+// tenantTargetOptions are the options of a tenant target.
+type tenantTargetOptions struct {
+	targets.FileOptions
+	ClientID string `json:"client_id"`
+}
+
+// tenantTarget is a file target only writing the records attributed to the
+// given client.
+type tenantTarget struct {
+	*targets.File
+	clientID string
+}
+
+func newTenantTarget(options json.RawMessage) (*tenantTarget, error) {
+	var opts tenantTargetOptions
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, fmt.Errorf("failed to parse tenant target options: %w", err)
+	}
+	return &tenantTarget{
+		File:     targets.NewFileTarget(opts.FileOptions),
+		clientID: opts.ClientID,
+	}, nil
+}
+
+// Write implements mlog.Target.
+func (t *tenantTarget) Write(p []byte, rec *mlog.LogRec) (int, error) {
+	if !isTenantRecord(rec, t.clientID) {
+		return len(p), nil
+	}
+	return t.File.Write(p, rec)
+}
+
+// isTenantRecord returns whether the record is attributed to the given client.
+func isTenantRecord(rec *mlog.LogRec, clientID string) bool {
+	for _, field := range rec.Fields() {
+		if tenantFields[field.Key] && field.String == clientID {
+			return true
+		}
+	}
+	return false
+}
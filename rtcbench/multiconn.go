@@ -0,0 +1,119 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtcbench
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/rtc"
+)
+
+const (
+	// packetSize is the size of the packets sent by the benchmarks, close to
+	// the one of video packets.
+	packetSize = 1200
+	// multiConnSockets is the number of sockets served by the benchmarked
+	// connections.
+	multiConnSockets = 2
+)
+
+// newMultiConn returns a connection serving multiConnSockets sockets bound
+// to the loopback interface, along with their addresses.
+func newMultiConn(b *testing.B) (net.PacketConn, []netip.AddrPort) {
+	b.Helper()
+	conns := make([]net.PacketConn, 0, multiConnSockets)
+	addrs := make([]netip.AddrPort, 0, multiConnSockets)
+	for i := 0; i < multiConnSockets; i++ {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			b.Fatalf("failed to listen: %s", err)
+		}
+		conns = append(conns, conn)
+		addrs = append(addrs, conn.LocalAddr().(*net.UDPAddr).AddrPort())
+	}
+
+	mc, err := rtc.NewMultiConn(conns, rtc.UDPWriteModeRoundRobin)
+	if err != nil {
+		b.Fatalf("failed to create conn: %s", err)
+	}
+	b.Cleanup(func() {
+		_ = mc.Close()
+	})
+	return mc, addrs
+}
+
+// benchmarkMultiConnReadFrom measures the packets read through the
+// connection of the server, while a client floods it.
+func benchmarkMultiConnReadFrom(b *testing.B) {
+	mc, addrs := newMultiConn(b)
+
+	sender, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		b.Fatalf("failed to listen: %s", err)
+	}
+	defer sender.Close()
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		pkt := make([]byte, packetSize)
+		for i := 0; ; i++ {
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+			// Errors (e.g. full buffers) are ignored, the reads only need a
+			// steady supply of packets.
+			_, _ = sender.WriteToUDPAddrPort(pkt, addrs[i%len(addrs)])
+		}
+	}()
+	defer func() {
+		close(stopCh)
+		<-doneCh
+	}()
+
+	buf := make([]byte, packetSize)
+	b.SetBytes(packetSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := mc.ReadFrom(buf); err != nil {
+			b.Fatalf("failed to read: %s", err)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "packets/s")
+}
+
+// benchmarkMultiConnWriteTo measures the packets written through the
+// connection of the server.
+func benchmarkMultiConnWriteTo(b *testing.B) {
+	mc, _ := newMultiConn(b)
+
+	receiver, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		b.Fatalf("failed to listen: %s", err)
+	}
+	defer receiver.Close()
+	dst := receiver.LocalAddr()
+
+	pkt := make([]byte, packetSize)
+	b.SetBytes(packetSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		if _, err := mc.WriteTo(pkt, dst); err != nil {
+			b.Fatalf("failed to write: %s", err)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "packets/s")
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package rtcbench benchmarks the media forwarding path of rtcd, from the
+// UDP sockets (micro benchmarks) to whole calls run in process (macro
+// benchmarks), and compares the results against a baseline so that
+// performance regressions are caught before release. Benchmarks can be run
+// through go test -bench or the rtcd bench command.
+package rtcbench
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+// Benchmark is a benchmark of the forwarding path.
+type Benchmark struct {
+	Name string
+	// Macro is set for the benchmarks running a whole service, which take
+	// notably longer.
+	Macro bool
+	Run   func(b *testing.B)
+}
+
+// Benchmarks returns all the benchmarks, sorted by name.
+func Benchmarks() []Benchmark {
+	benchmarks := []Benchmark{
+		{Name: "MultiConnReadFrom", Run: benchmarkMultiConnReadFrom},
+		{Name: "MultiConnWriteTo", Run: benchmarkMultiConnWriteTo},
+		{Name: "ForwardPackets", Macro: true, Run: benchmarkForwardPackets},
+		{Name: "SessionSetup", Macro: true, Run: benchmarkSessionSetup},
+	}
+	sort.Slice(benchmarks, func(i, j int) bool {
+		return benchmarks[i].Name < benchmarks[j].Name
+	})
+	return benchmarks
+}
+
+// Result is the outcome of a benchmark.
+type Result struct {
+	Name        string  `json:"name"`
+	N           int     `json:"n"`
+	NsPerOp     float64 `json:"ns_per_op"`
+	AllocsPerOp int64   `json:"allocs_per_op"`
+	BytesPerOp  int64   `json:"bytes_per_op"`
+	// Extra holds the metrics reported by the benchmark itself, keyed by
+	// unit (e.g. "packets/s").
+	Extra map[string]float64 `json:"extra,omitempty"`
+}
+
+// Units returns the units of the metrics reported by the benchmark itself,
+// sorted.
+func (r Result) Units() []string {
+	units := make([]string, 0, len(r.Extra))
+	for unit := range r.Extra {
+		units = append(units, unit)
+	}
+	sort.Strings(units)
+	return units
+}
+
+// Run runs the benchmarks whose name matches filter, all of them if empty,
+// leaving out the macro ones unless macro is set.
+func Run(filter string, macro bool) ([]Result, error) {
+	re, err := regexp.Compile(filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	// Sets the defaults of the benchmark flags when not run by go test.
+	testing.Init()
+
+	var results []Result
+	for _, bm := range Benchmarks() {
+		if !re.MatchString(bm.Name) || (bm.Macro && !macro) {
+			continue
+		}
+		res := testing.Benchmark(bm.Run)
+		if res.N == 0 {
+			return nil, fmt.Errorf("benchmark %s failed", bm.Name)
+		}
+		results = append(results, Result{
+			Name:        bm.Name,
+			N:           res.N,
+			NsPerOp:     float64(res.T.Nanoseconds()) / float64(res.N),
+			AllocsPerOp: res.AllocsPerOp(),
+			BytesPerOp:  res.AllocedBytesPerOp(),
+			Extra:       res.Extra,
+		})
+	}
+	return results, nil
+}
+
+// Regression is a metric of a benchmark that got worse than its baseline by
+// more than the tolerance.
+type Regression struct {
+	Name     string
+	Metric   string
+	Baseline float64
+	Value    float64
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s: %s went from %g to %g", r.Name, r.Metric, r.Baseline, r.Value)
+}
+
+// Compare returns the regressions of results against baseline, in percent
+// of the baseline values. Time and allocations per operation regress when
+// they grow, the metrics reported by the benchmarks (rates) when they drop.
+// Benchmarks missing from either side are ignored.
+func Compare(baseline, results []Result, tolerancePercent float64) []Regression {
+	base := make(map[string]Result, len(baseline))
+	for _, res := range baseline {
+		base[res.Name] = res
+	}
+
+	var regressions []Regression
+	check := func(name, metric string, baseline, value float64, higherIsBetter bool) {
+		limit := tolerancePercent / 100 * baseline
+		if (higherIsBetter && value < baseline-limit) || (!higherIsBetter && value > baseline+limit) {
+			regressions = append(regressions, Regression{Name: name, Metric: metric, Baseline: baseline, Value: value})
+		}
+	}
+	for _, res := range results {
+		b, ok := base[res.Name]
+		if !ok {
+			continue
+		}
+		check(res.Name, "ns/op", b.NsPerOp, res.NsPerOp, false)
+		check(res.Name, "allocs/op", float64(b.AllocsPerOp), float64(res.AllocsPerOp), false)
+		check(res.Name, "B/op", float64(b.BytesPerOp), float64(res.BytesPerOp), false)
+		for _, unit := range b.Units() {
+			if value, ok := res.Extra[unit]; ok {
+				check(res.Name, unit, b.Extra[unit], value, true)
+			}
+		}
+	}
+	return regressions
+}
+
+// LoadResults reads the results saved by SaveResults.
+func LoadResults(path string) ([]Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results: %w", err)
+	}
+	var results []Result
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal results: %w", err)
+	}
+	return results, nil
+}
+
+// SaveResults writes the results to path, e.g. to be used as a baseline.
+func SaveResults(path string, results []Result) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal results: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+	return nil
+}
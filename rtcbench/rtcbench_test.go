@@ -0,0 +1,83 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtcbench
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func BenchmarkForwardingPath(b *testing.B) {
+	for _, bm := range Benchmarks() {
+		b.Run(bm.Name, bm.Run)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	baseline := []Result{
+		{Name: "A", NsPerOp: 100, AllocsPerOp: 2, BytesPerOp: 64, Extra: map[string]float64{"packets/s": 1000}},
+		{Name: "B", NsPerOp: 100},
+	}
+
+	t.Run("within tolerance", func(t *testing.T) {
+		results := []Result{
+			{Name: "A", NsPerOp: 109, AllocsPerOp: 2, BytesPerOp: 64, Extra: map[string]float64{"packets/s": 910}},
+			{Name: "B", NsPerOp: 50},
+			{Name: "C", NsPerOp: 1000},
+		}
+		require.Empty(t, Compare(baseline, results, 10))
+	})
+
+	t.Run("regressions", func(t *testing.T) {
+		results := []Result{
+			{Name: "A", NsPerOp: 120, AllocsPerOp: 3, BytesPerOp: 64, Extra: map[string]float64{"packets/s": 800}},
+		}
+		regressions := Compare(baseline, results, 10)
+		require.Equal(t, []Regression{
+			{Name: "A", Metric: "ns/op", Baseline: 100, Value: 120},
+			{Name: "A", Metric: "allocs/op", Baseline: 2, Value: 3},
+			{Name: "A", Metric: "packets/s", Baseline: 1000, Value: 800},
+		}, regressions)
+		require.Equal(t, "A: ns/op went from 100 to 120", regressions[0].String())
+	})
+}
+
+func TestResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	results := []Result{
+		{Name: "A", N: 10, NsPerOp: 100, AllocsPerOp: 2, BytesPerOp: 64, Extra: map[string]float64{"packets/s": 1000}},
+	}
+	require.NoError(t, SaveResults(path, results))
+
+	loaded, err := LoadResults(path)
+	require.NoError(t, err)
+	require.Equal(t, results, loaded)
+
+	_, err = LoadResults(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	t.Run("invalid filter", func(t *testing.T) {
+		_, err := Run("(", false)
+		require.Error(t, err)
+	})
+
+	t.Run("micro benchmarks", func(t *testing.T) {
+		results, err := Run("WriteTo", true)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Equal(t, "MultiConnWriteTo", results[0].Name)
+		require.Positive(t, results[0].N)
+		require.Positive(t, results[0].Extra["packets/s"])
+	})
+
+	t.Run("macro benchmarks left out", func(t *testing.T) {
+		results, err := Run("SessionSetup", false)
+		require.NoError(t, err)
+		require.Empty(t, results)
+	})
+}
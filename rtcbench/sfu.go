@@ -0,0 +1,201 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtcbench
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/client"
+	"github.com/mattermost/rtcd/rtctest"
+	"github.com/mattermost/rtcd/service/random"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+)
+
+const (
+	benchClientID = "rtcbench"
+	// forwardSubscribers is the number of sessions the published track gets
+	// forwarded to.
+	forwardSubscribers = 3
+	// forwardWindow is the number of packets allowed in flight, not yet
+	// received by all the subscribers. Sending faster would only measure
+	// the drops of the virtual network.
+	forwardWindow = 64
+	// benchTimeout bounds the waits for the sessions to connect and for the
+	// packets in flight.
+	benchTimeout = 10 * time.Second
+)
+
+// opusSample is a silent Opus frame.
+var opusSample = media.Sample{Data: []byte{0xf8, 0xff, 0xfe}, Duration: 20 * time.Millisecond}
+
+func newServer(b *testing.B) *rtctest.Server {
+	b.Helper()
+	cfg := rtctest.DefaultConfig()
+	// Sessions torn down with the server log errors, which would only get
+	// in the way of the results.
+	cfg.Logger.ConsoleLevel = "FATAL"
+	s, err := rtctest.NewServer(cfg)
+	if err != nil {
+		b.Fatalf("failed to start server: %s", err)
+	}
+	b.Cleanup(func() {
+		_ = s.Close()
+	})
+	return s
+}
+
+func newClient(b *testing.B, s *rtctest.Server) *client.Client {
+	b.Helper()
+	c, err := s.NewClient(benchClientID)
+	if err != nil {
+		b.Fatalf("failed to create client: %s", err)
+	}
+	if err := c.Connect(); err != nil {
+		b.Fatalf("failed to connect client: %s", err)
+	}
+	b.Cleanup(func() {
+		_ = c.Close()
+	})
+	return c
+}
+
+func waitFor(ch <-chan struct{}, what string) error {
+	select {
+	case <-ch:
+		return nil
+	case <-time.After(benchTimeout):
+		return fmt.Errorf("timed out waiting for %s", what)
+	}
+}
+
+// waitPackets waits until cond returns true, or no packet was received for
+// benchTimeout, in which case the packets in flight are considered lost.
+func waitPackets(received *int64, cond func(n int64) bool) {
+	last := atomic.LoadInt64(received)
+	lastAt := time.Now()
+	for {
+		n := atomic.LoadInt64(received)
+		if cond(n) {
+			return
+		}
+		if n != last {
+			last, lastAt = n, time.Now()
+		} else if time.Since(lastAt) > benchTimeout {
+			return
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+}
+
+// benchmarkForwardPackets measures the audio packets forwarded by the SFU
+// from a publisher to forwardSubscribers subscribers, in a call run in
+// process. Each operation is a packet sent by the publisher.
+func benchmarkForwardPackets(b *testing.B) {
+	s := newServer(b)
+	callID := random.NewID()
+
+	pub, err := newClient(b, s).JoinCall(client.CallConfig{CallID: callID, UserID: "publisher"})
+	if err != nil {
+		b.Fatalf("failed to join call: %s", err)
+	}
+	track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", random.NewID())
+	if err != nil {
+		b.Fatalf("failed to create track: %s", err)
+	}
+	if _, err := pub.PublishTrack(track); err != nil {
+		b.Fatalf("failed to publish track: %s", err)
+	}
+	if err := waitFor(pub.Connected(), "publisher to connect"); err != nil {
+		b.Fatal(err)
+	}
+
+	var received int64
+	subscribed := make(chan struct{}, forwardSubscribers)
+	for i := 0; i < forwardSubscribers; i++ {
+		sub, err := newClient(b, s).JoinCall(client.CallConfig{CallID: callID, UserID: fmt.Sprintf("subscriber%d", i)})
+		if err != nil {
+			b.Fatalf("failed to join call: %s", err)
+		}
+		sub.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+			subscribed <- struct{}{}
+			for {
+				if _, _, err := track.ReadRTP(); err != nil {
+					return
+				}
+				atomic.AddInt64(&received, 1)
+			}
+		})
+		if err := waitFor(sub.Connected(), "subscriber to connect"); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	// Tracks are only forwarded once the publisher sends packets.
+	for i := 0; i < forwardSubscribers; i++ {
+		for ok := false; !ok; {
+			if err := track.WriteSample(opusSample); err != nil {
+				b.Fatalf("failed to write sample: %s", err)
+			}
+			select {
+			case <-subscribed:
+				ok = true
+			case <-time.After(opusSample.Duration):
+			}
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	atomic.StoreInt64(&received, 0)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		inFlight := int64(i-forwardWindow) * forwardSubscribers
+		waitPackets(&received, func(n int64) bool { return n >= inFlight })
+		if err := track.WriteSample(opusSample); err != nil {
+			b.Fatalf("failed to write sample: %s", err)
+		}
+	}
+	waitPackets(&received, func(n int64) bool { return n >= int64(b.N)*forwardSubscribers })
+	b.StopTimer()
+
+	n := atomic.LoadInt64(&received)
+	b.ReportMetric(float64(n)/time.Since(start).Seconds(), "packets/s")
+	b.ReportMetric(float64(n)*100/float64(b.N*forwardSubscribers), "delivered_%")
+}
+
+// benchmarkSessionSetup measures the sessions joining a call, through
+// signaling and ICE up to the peer connection being established, in a
+// service run in process. Each operation is a session joining, and leaving,
+// a call of its own.
+func benchmarkSessionSetup(b *testing.B) {
+	s := newServer(b)
+	c := newClient(b, s)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		call, err := c.JoinCall(client.CallConfig{CallID: random.NewID(), UserID: "user"})
+		if err != nil {
+			b.Fatalf("failed to join call: %s", err)
+		}
+		if err := waitFor(call.Connected(), "session to connect"); err != nil {
+			b.Fatal(err)
+		}
+		if err := call.Leave(); err != nil {
+			b.Fatalf("failed to leave call: %s", err)
+		}
+		if err := waitFor(call.Done(), "session to leave"); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "sessions/s")
+}
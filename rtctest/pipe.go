@@ -0,0 +1,69 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtctest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// pipeAddr is the address of a pipeListener.
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return host }
+
+// pipeListener is an in-memory net.Listener. Connections are made through
+// DialContext, each of them being one end of a net.Pipe.
+type pipeListener struct {
+	connCh    chan net.Conn
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{
+		connCh:  make(chan net.Conn),
+		closeCh: make(chan struct{}),
+	}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.connCh:
+		return conn, nil
+	case <-l.closeCh:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closeCh)
+	})
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+// DialContext connects to the listener, whatever the given address.
+func (l *pipeListener) DialContext(ctx context.Context, _, _ string) (net.Conn, error) {
+	serverConn, clientConn := net.Pipe()
+	var err error
+	select {
+	case l.connCh <- serverConn:
+		return clientConn, nil
+	case <-l.closeCh:
+		err = fmt.Errorf("connection refused: listener is closed")
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	serverConn.Close()
+	clientConn.Close()
+	return nil, err
+}
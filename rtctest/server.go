@@ -0,0 +1,283 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package rtctest runs an rtcd service in process for the integration tests
+// of the projects relying on it. Signaling goes through in-memory connections
+// and media through a virtual network, so that tests neither bind ports nor
+// depend on the network of the host.
+package rtctest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/mattermost/rtcd/client"
+	"github.com/mattermost/rtcd/logger"
+	"github.com/mattermost/rtcd/service"
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/pion/ice/v2"
+	"github.com/pion/logging"
+	"github.com/pion/transport/vnet"
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	// host is the host name the service is reachable at. It's never
+	// resolved, all connections go to the in-memory listener.
+	host = "rtctest"
+	// URL is the URL of the service, to be used along with DialContext.
+	URL = "http://" + host
+	// vnetCIDR is the subnet of the virtual network.
+	vnetCIDR = "10.10.0.0/16"
+)
+
+// Server is an rtcd service running in process.
+type Server struct {
+	cfg      service.Config
+	srvc     *service.Service
+	listener *pipeListener
+	router   *vnet.Router
+	admin    *service.Client
+	// dataDir is the directory of the store, if created by NewServer.
+	dataDir string
+
+	// authKeys maps the IDs of the registered clients to their auth keys.
+	authKeys map[string]string
+	mut      sync.Mutex
+}
+
+// DefaultConfig returns a service config suited to tests: the admin API is
+// enabled, with a random secret key, and only errors are logged.
+func DefaultConfig() service.Config {
+	return service.Config{
+		API: service.APIConfig{
+			Security: service.SecurityConfig{
+				EnableAdmin:    true,
+				AdminSecretKey: random.NewID(),
+				SessionCache: auth.SessionCacheConfig{
+					ExpirationMinutes: 1440,
+				},
+			},
+		},
+		RTC: rtc.ServerConfig{
+			ICEPortUDP: 8443,
+		},
+		Logger: logger.Config{
+			EnableConsole: true,
+			ConsoleLevel:  "ERROR",
+		},
+	}
+}
+
+// NewServer starts a service with the given config, as returned by
+// DefaultConfig. The admin API must be enabled. Listen addresses are ignored
+// and the gRPC API is disabled. If no store data source is set, the store is
+// kept in a temporary directory removed on Close.
+func NewServer(cfg service.Config) (*Server, error) {
+	if !cfg.API.Security.EnableAdmin {
+		return nil, fmt.Errorf("invalid config: admin API should be enabled")
+	}
+
+	cfg.API.HTTP.ListenAddress = host
+	cfg.API.Admin.ListenAddress = ""
+	cfg.API.GRPC.ListenAddress = ""
+
+	s := &Server{
+		listener: newPipeListener(),
+		authKeys: map[string]string{},
+	}
+
+	if cfg.Store.DataSource == "" {
+		dir, err := os.MkdirTemp("", "rtctest")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create store directory: %w", err)
+		}
+		s.dataDir = dir
+		cfg.Store.DataSource = dir
+	}
+	s.cfg = cfg
+
+	if err := s.start(); err != nil {
+		_ = s.cleanup()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Server) start() error {
+	var err error
+	s.router, err = vnet.NewRouter(&vnet.RouterConfig{
+		CIDR:          vnetCIDR,
+		LoggerFactory: logging.NewDefaultLoggerFactory(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create vnet router: %w", err)
+	}
+	if err := s.router.Start(); err != nil {
+		return fmt.Errorf("failed to start vnet router: %w", err)
+	}
+
+	n, err := s.NewNet()
+	if err != nil {
+		return err
+	}
+
+	s.srvc, err = service.New(s.cfg, service.WithListener(s.listener), service.WithVNet(n))
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	if err := s.srvc.Start(); err != nil {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+
+	s.admin, err = service.NewClient(service.ClientConfig{
+		URL:     URL,
+		AuthKey: s.cfg.API.Security.AdminSecretKey,
+	}, service.WithDialFunc(s.DialContext))
+	if err != nil {
+		return fmt.Errorf("failed to create admin client: %w", err)
+	}
+
+	return nil
+}
+
+// Close stops the service and releases all resources. Calls should be left
+// first as the service waits for the ongoing sessions to end.
+func (s *Server) Close() error {
+	// Fails if the admin client never connected, closing its idle HTTP
+	// connections is all that's needed then.
+	_ = s.admin.Close()
+	if err := s.srvc.Stop(); err != nil {
+		return fmt.Errorf("failed to stop service: %w", err)
+	}
+	return s.cleanup()
+}
+
+func (s *Server) cleanup() error {
+	// The listener is closed along with the service, unless it failed to
+	// start.
+	s.listener.Close()
+	if s.router != nil {
+		// Fails if the router was never started.
+		_ = s.router.Stop()
+	}
+	if s.dataDir != "" {
+		if err := os.RemoveAll(s.dataDir); err != nil {
+			return fmt.Errorf("failed to remove store directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// Service returns the running service.
+func (s *Server) Service() *service.Service {
+	return s.srvc
+}
+
+// AdminClient returns a client authenticated as admin, e.g. to call the
+// admin API. It's closed along with the server.
+func (s *Server) AdminClient() *service.Client {
+	return s.admin
+}
+
+// DialContext connects to the service, whatever the given address. It's to
+// be set (see service.WithDialFunc) on the clients not created through the
+// server.
+func (s *Server) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return s.listener.DialContext(ctx, network, addr)
+}
+
+// NewNet attaches a new host to the virtual network media is served on. It
+// can be set (see webrtc.SettingEngine.SetVNet) on the peer connections not
+// created through the server.
+func (s *Server) NewNet() (*vnet.Net, error) {
+	n := vnet.NewNet(&vnet.NetConfig{})
+	if err := s.router.AddNet(n); err != nil {
+		return nil, fmt.Errorf("failed to add vnet: %w", err)
+	}
+	return n, nil
+}
+
+// Register registers the given client, if not already, returning its auth
+// key.
+func (s *Server) Register(clientID string) (string, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if authKey, ok := s.authKeys[clientID]; ok {
+		return authKey, nil
+	}
+
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate auth key: %w", err)
+	}
+	if err := s.admin.Register(clientID, authKey); err != nil {
+		return "", fmt.Errorf("failed to register client: %w", err)
+	}
+	s.authKeys[clientID] = authKey
+
+	return authKey, nil
+}
+
+// NewServiceClient returns a signaling client for the given client ID,
+// registering it first if needed. Connect needs to be called before use.
+func (s *Server) NewServiceClient(clientID string) (*service.Client, error) {
+	cfg, err := s.clientConfig(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := service.NewClient(cfg, service.WithDialFunc(s.DialContext))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service client: %w", err)
+	}
+
+	return c, nil
+}
+
+// NewClient returns a client, able to join calls and exchange media, for the
+// given client ID, registering it first if needed. Each client is a host of
+// its own on the virtual network. Connect needs to be called before use.
+func (s *Server) NewClient(clientID string) (*client.Client, error) {
+	cfg, err := s.clientConfig(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := s.NewNet()
+	if err != nil {
+		return nil, err
+	}
+	var sEngine webrtc.SettingEngine
+	sEngine.SetVNet(n)
+	sEngine.SetICEMulticastDNSMode(ice.MulticastDNSModeDisabled)
+
+	c, err := client.New(client.Config{Service: cfg},
+		client.WithServiceOptions(service.WithDialFunc(s.DialContext)),
+		client.WithSettingEngine(sEngine))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	return c, nil
+}
+
+func (s *Server) clientConfig(clientID string) (service.ClientConfig, error) {
+	authKey, err := s.Register(clientID)
+	if err != nil {
+		return service.ClientConfig{}, err
+	}
+	return service.ClientConfig{
+		URL:      URL,
+		ClientID: clientID,
+		AuthKey:  authKey,
+	}, nil
+}
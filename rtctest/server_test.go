@@ -0,0 +1,134 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtctest
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/client"
+	"github.com/mattermost/rtcd/service"
+	"github.com/mattermost/rtcd/service/random"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/stretchr/testify/require"
+)
+
+func waitFor(t *testing.T, ch <-chan struct{}, msg string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "timed out waiting for "+msg)
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	t.Run("admin disabled", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.API.Security.EnableAdmin = false
+		_, err := NewServer(cfg)
+		require.EqualError(t, err, "invalid config: admin API should be enabled")
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.RTC.ICEPortUDP = 0
+		_, err := NewServer(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to create service")
+	})
+
+	t.Run("register", func(t *testing.T) {
+		s, err := NewServer(DefaultConfig())
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, s.Close())
+		}()
+
+		info, err := s.AdminClient().GetVersionInfo()
+		require.NoError(t, err)
+		require.NotEmpty(t, info.GoVersion)
+
+		authKey, err := s.Register("clientA")
+		require.NoError(t, err)
+		require.NotEmpty(t, authKey)
+
+		// Registering again returns the same key.
+		key, err := s.Register("clientA")
+		require.NoError(t, err)
+		require.Equal(t, authKey, key)
+
+		c, err := s.NewServiceClient("clientA")
+		require.NoError(t, err)
+		require.NoError(t, c.Connect())
+		msg := <-c.ReceiveCh()
+		require.Equal(t, service.ClientMessageHello, msg.Type)
+		require.NoError(t, c.Close())
+	})
+}
+
+func TestServerCall(t *testing.T) {
+	s, err := NewServer(DefaultConfig())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, s.Close())
+	}()
+
+	pubClient, err := s.NewClient("clientA")
+	require.NoError(t, err)
+	require.NoError(t, pubClient.Connect())
+	defer pubClient.Close()
+	subClient, err := s.NewClient("clientA")
+	require.NoError(t, err)
+	require.NoError(t, subClient.Connect())
+	defer subClient.Close()
+
+	pub, err := pubClient.JoinCall(client.CallConfig{CallID: "callA", UserID: "publisher"})
+	require.NoError(t, err)
+	track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", random.NewID())
+	require.NoError(t, err)
+	_, err = pub.PublishTrack(track)
+	require.NoError(t, err)
+	waitFor(t, pub.Connected(), "publisher to connect")
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = track.WriteSample(media.Sample{Data: []byte{0xf8, 0xff, 0xfe}, Duration: 20 * time.Millisecond})
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	sub, err := subClient.JoinCall(client.CallConfig{CallID: "callA", UserID: "subscriber"})
+	require.NoError(t, err)
+	audioCh := make(chan struct{})
+	var packets int32
+	sub.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		for {
+			if _, _, err := track.ReadRTP(); err != nil {
+				return
+			}
+			if atomic.AddInt32(&packets, 1) == 10 {
+				close(audioCh)
+			}
+		}
+	})
+	waitFor(t, sub.Connected(), "subscriber to connect")
+	waitFor(t, audioCh, "audio packets")
+
+	require.NoError(t, pub.Leave())
+	waitFor(t, pub.Done(), "publisher to leave")
+	require.NoError(t, sub.Leave())
+	waitFor(t, sub.Done(), "subscriber to leave")
+}
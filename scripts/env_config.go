@@ -4,13 +4,12 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"text/tabwriter"
 
 	"github.com/mattermost/rtcd/service"
-
-	"github.com/kelseyhightower/envconfig"
 )
 
 func main() {
@@ -29,8 +28,15 @@ func main() {
 	if _, err := outFile.Seek(0, 0); err != nil {
 		log.Fatalf("failed to seek file: %s", err.Error())
 	}
-	fmt := "### Config Environment Overrides\n\n```\nKEY	TYPE\n{{range .}}{{usage_key .}}	{{usage_type .}}\n{{end}}```\n"
 	tabs := tabwriter.NewWriter(outFile, 1, 0, 4, ' ', 0)
-	_ = envconfig.Usagef("rtcd", &service.Config{}, tabs, fmt)
+	fmt.Fprintf(tabs, "### Config Environment Overrides\n\n```\nKEY\tALIAS\tTYPE\tDEFAULT\n")
+	for _, envVar := range service.ConfigEnvVars("rtcd") {
+		alias := envVar.Key
+		if alias == envVar.NestedKey {
+			alias = "-"
+		}
+		fmt.Fprintf(tabs, "%s\t%s\t%s\t%q\n", envVar.NestedKey, alias, envVar.Type, envVar.Default)
+	}
 	tabs.Flush()
+	fmt.Fprintf(outFile, "```\n")
 }
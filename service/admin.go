@@ -0,0 +1,1001 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/store"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// adminAuthHandler authenticates the request, making sure it was made by
+// the admin client.
+func (s *Service) adminAuthHandler(w http.ResponseWriter, r *http.Request) (int, error) {
+	if !s.cfg.API.Security.EnableAdmin {
+		return http.StatusForbidden, errors.New("admin access is not enabled")
+	}
+
+	// an empty clientID means admin.
+	clientID, code, err := s.authHandler(w, r)
+	if err != nil {
+		return code, err
+	}
+	if clientID != "" {
+		return http.StatusForbidden, errors.New("admin access is required")
+	}
+
+	return http.StatusOK, nil
+}
+
+func (s *Service) handleUDPSockets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleUDPSockets", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if r.Method == http.MethodPost {
+		if err := decodeReqData(r.Body, data.reqData); err != nil {
+			data.err = err.Error()
+			data.code = http.StatusBadRequest
+			return
+		}
+
+		count, err := strconv.Atoi(data.reqData["count"])
+		if err != nil {
+			data.err = "invalid count value: " + err.Error()
+			data.code = http.StatusBadRequest
+			return
+		}
+
+		if err := s.rtcServer.ScaleUDPSockets(count); err != nil {
+			data.err = err.Error()
+			data.code = http.StatusBadRequest
+			return
+		}
+
+		s.log.Debug("scaled udp sockets", mlog.Int("count", count))
+	}
+
+	stats := s.rtcServer.UDPSocketsStats()
+	data.code = http.StatusOK
+	data.resData["count"] = strconv.Itoa(stats.Count)
+	data.resData["packetRate"] = strconv.FormatFloat(stats.PacketRate, 'f', 2, 64)
+	data.resData["readBufferSize"] = strconv.Itoa(stats.ReadBufferSize)
+	data.resData["writeBufferSize"] = strconv.Itoa(stats.WriteBufferSize)
+	data.resData["temporaryReadErrors"] = strconv.FormatUint(stats.TemporaryReadErrors, 10)
+	data.resData["rebinds"] = strconv.FormatUint(stats.Rebinds, 10)
+	data.resData["truncatedPackets"] = strconv.FormatUint(stats.TruncatedPackets, 10)
+	var writeErrors uint64
+	for _, conn := range stats.Conns {
+		writeErrors += conn.Errors
+	}
+	data.resData["writeErrors"] = strconv.FormatUint(writeErrors, 10)
+}
+
+// handleCapacity reports the estimated load of the node and the capacity
+// left.
+func (s *Service) handleCapacity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleCapacity", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	js, err := json.Marshal(s.rtcServer.GetCapacity())
+	if err != nil {
+		data.err = "failed to marshal capacity: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	data.code = http.StatusOK
+	data.resData["capacity"] = string(js)
+}
+
+func (s *Service) handleStoreExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	// The response is only written by httpAudit in case of failure.
+	rw := w
+	defer func() {
+		s.httpAudit("handleStoreExport", data, rw, r)
+	}()
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	dump, err := store.Export(s.store)
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	rw = nil
+	data.code = http.StatusOK
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(data.code)
+	if err := store.WriteDump(w, dump); err != nil {
+		s.log.Error("failed to write store dump", mlog.Err(err))
+	}
+}
+
+func (s *Service) handleStoreImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleStoreImport", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	dump, err := store.ReadDump(r.Body)
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	n, err := store.Import(s.store, dump)
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+	} else {
+		data.code = http.StatusOK
+	}
+	data.resData["count"] = strconv.Itoa(n)
+
+	s.log.Info("imported store entries", mlog.Int("count", n))
+}
+
+func (s *Service) handleRuntimeParams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleRuntimeParams", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if r.Method == http.MethodPost {
+		if s.checkIdempotencyKey("handleRuntimeParams", data, w, r) {
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+			data.err = err.Error()
+			data.code = http.StatusBadRequest
+			return
+		}
+
+		params := s.getRuntimeParams()
+		if err := params.update(data.reqData); err != nil {
+			data.err = err.Error()
+			data.code = http.StatusBadRequest
+			return
+		}
+
+		if err := s.setRuntimeParams(params, true); err != nil {
+			data.err = err.Error()
+			data.code = http.StatusBadRequest
+			return
+		}
+
+		s.log.Info("updated runtime params", mlog.Any("params", params))
+	}
+
+	data.code = http.StatusOK
+	for k, v := range s.getRuntimeParams().toMap() {
+		data.resData[k] = v
+	}
+}
+
+// handleFeatures returns (GET) or overrides (POST) the state of the features
+// gated behind flags, for the client given through the clientID query
+// parameter, or the deployment if missing.
+func (s *Service) handleFeatures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleFeatures", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	clientID := r.URL.Query().Get("clientID")
+
+	if r.Method == http.MethodPost {
+		if s.checkIdempotencyKey("handleFeatures", data, w, r) {
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+			data.err = err.Error()
+			data.code = http.StatusBadRequest
+			return
+		}
+
+		if err := s.updateFeatureFlags(clientID, data.reqData); err != nil {
+			data.err = err.Error()
+			data.code = http.StatusBadRequest
+			return
+		}
+
+		s.log.Info("updated feature flags", mlog.String("clientID", clientID), mlog.Any("flags", data.reqData))
+	}
+
+	data.code = http.StatusOK
+	for name, enabled := range s.getFeatures(clientID) {
+		data.resData[name] = strconv.FormatBool(enabled)
+	}
+}
+
+// handleCalls dispatches the requests for the resources of a call, found
+// under callEventsPathPrefix.
+func (s *Service) handleCalls(w http.ResponseWriter, r *http.Request) {
+	_, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, callEventsPathPrefix), "/")
+	switch name {
+	case "topology":
+		s.handleCallTopology(w, r)
+	case "observers":
+		s.handleCallObservers(w, r)
+	default:
+		s.handleCallEvents(w, r)
+	}
+}
+
+// handleCallTopology returns how the tracks of a call are routed between its
+// sessions, to debug a participant not receiving a given track.
+func (s *Service) handleCallTopology(w http.ResponseWriter, r *http.Request) {
+	callID, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, callEventsPathPrefix), "/")
+	if r.Method != http.MethodGet || callID == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleCallTopology", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	groupID := r.URL.Query().Get("groupID")
+	data.reqData["groupID"] = groupID
+	data.reqData["callID"] = callID
+	if groupID == "" {
+		data.err = "missing groupID"
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	topology, err := s.rtcServer.GetCallTopology(groupID, callID)
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusNotFound
+		return
+	}
+
+	js, err := json.Marshal(topology)
+	if err != nil {
+		data.err = "failed to marshal call topology: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	data.code = http.StatusOK
+	data.resData["callID"] = callID
+	data.resData["topology"] = string(js)
+}
+
+// handleCallObservers issues a token allowing a session to join a call as
+// an observer, receiving its media while staying out of the participants and
+// not being able to publish. The session ID is generated and returned along
+// with the token.
+func (s *Service) handleCallObservers(w http.ResponseWriter, r *http.Request) {
+	callID, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, callEventsPathPrefix), "/")
+	if r.Method != http.MethodPost || callID == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleCallObservers", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+	data.reqData["callID"] = callID
+
+	groupID := data.reqData["groupID"]
+	userID := data.reqData["userID"]
+	data.auditFields = append(data.auditFields,
+		mlog.String("groupID", groupID),
+		mlog.String("callID", callID),
+		mlog.String("userID", userID))
+	if groupID == "" {
+		data.err = "missing groupID"
+		data.code = http.StatusBadRequest
+		return
+	}
+	// The user is the one on whose behalf the call is observed, recorded
+	// in the audit log as the session joins.
+	if userID == "" {
+		data.err = "missing userID"
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	if _, err := s.rtcServer.GetCallState(groupID, callID); err != nil {
+		data.err = err.Error()
+		data.code = http.StatusNotFound
+		return
+	}
+
+	sessionID := random.NewID()
+	expiresAt := time.Now().Add(observerTokenExpiration)
+	token, err := s.auth.NewObserverToken(groupID, callID, sessionID, expiresAt)
+	if err != nil {
+		data.err = "failed to issue observer token: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+	data.auditFields = append(data.auditFields, mlog.String("sessionID", sessionID))
+
+	data.code = http.StatusCreated
+	data.resData["sessionID"] = sessionID
+	data.resData["token"] = token
+	data.resData["expiresAt"] = strconv.FormatInt(expiresAt.UnixMilli(), 10)
+}
+
+// handleCapture starts (POST) or stops (DELETE) a packet capture on the
+// requested call.
+func (s *Service) handleCapture(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleCapture", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if s.checkIdempotencyKey("handleCapture", data, w, r) {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	groupID := data.reqData["groupID"]
+	callID := data.reqData["callID"]
+
+	if r.Method == http.MethodDelete {
+		info, err := s.rtcServer.StopCapture(groupID, callID)
+		if err != nil {
+			data.err = err.Error()
+			data.code = http.StatusBadRequest
+			return
+		}
+		data.code = http.StatusOK
+		data.resData["path"] = info.Path
+		data.resData["size"] = strconv.FormatInt(info.Size, 10)
+		return
+	}
+
+	var duration time.Duration
+	if val := data.reqData["durationSeconds"]; val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil || seconds < 0 {
+			data.err = "invalid durationSeconds value"
+			data.code = http.StatusBadRequest
+			return
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+
+	path, err := s.rtcServer.StartCapture(groupID, callID, duration)
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	s.log.Info("started packet capture", mlog.String("groupID", groupID), mlog.String("callID", callID), mlog.String("path", path))
+
+	data.code = http.StatusOK
+	data.resData["path"] = path
+}
+
+// handleRecording starts (POST) or stops (DELETE) the recording of a single
+// participant.
+func (s *Service) handleRecording(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleRecording", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if s.checkIdempotencyKey("handleRecording", data, w, r) {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	groupID := data.reqData["groupID"]
+	sessionID := data.reqData["sessionID"]
+
+	var info rtc.RecordingInfo
+	var err error
+	if r.Method == http.MethodDelete {
+		info, err = s.rtcServer.StopSessionRecording(groupID, sessionID)
+	} else {
+		var duration time.Duration
+		if val := data.reqData["durationSeconds"]; val != "" {
+			seconds, err := strconv.Atoi(val)
+			if err != nil || seconds < 0 {
+				data.err = "invalid durationSeconds value"
+				data.code = http.StatusBadRequest
+				return
+			}
+			duration = time.Duration(seconds) * time.Second
+		}
+		info, err = s.rtcServer.StartSessionRecording(groupID, sessionID, data.reqData["target"], duration)
+	}
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	js, err := json.Marshal(info)
+	if err != nil {
+		data.err = "failed to marshal recording info: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	data.code = http.StatusOK
+	data.resData["id"] = info.ID
+	data.resData["dir"] = info.Dir
+	data.resData["recording"] = string(js)
+}
+
+// handleHLSStream starts (POST) or stops (DELETE) the LL-HLS broadcast of a
+// call. Streams are started from the session to broadcast and stopped by
+// call.
+func (s *Service) handleHLSStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleHLSStream", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if s.checkIdempotencyKey("handleHLSStream", data, w, r) {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	groupID := data.reqData["groupID"]
+
+	var info rtc.HLSStreamInfo
+	var err error
+	if r.Method == http.MethodDelete {
+		info, err = s.rtcServer.StopHLS(groupID, data.reqData["callID"])
+	} else {
+		info, err = s.rtcServer.StartHLS(groupID, data.reqData["sessionID"])
+	}
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	js, err := json.Marshal(info)
+	if err != nil {
+		data.err = "failed to marshal stream info: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	data.code = http.StatusOK
+	data.resData["id"] = info.ID
+	data.resData["path"] = hlsPathPrefix + info.ID + "/index.m3u8"
+	data.resData["stream"] = string(js)
+}
+
+// handleAnnouncement plays an announcement to the sessions of a call or, if
+// a sessionID is given, to that session only.
+func (s *Service) handleAnnouncement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleAnnouncement", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if s.checkIdempotencyKey("handleAnnouncement", data, w, r) {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	info, err := s.rtcServer.PlayAnnouncement(data.reqData["groupID"], data.reqData["callID"],
+		data.reqData["sessionID"], data.reqData["name"])
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	js, err := json.Marshal(info)
+	if err != nil {
+		data.err = "failed to marshal announcement info: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	data.code = http.StatusOK
+	data.resData["id"] = info.ID
+	data.resData["sessions"] = strconv.Itoa(len(info.Sessions))
+	data.resData["announcement"] = string(js)
+}
+
+// handleKeyExport exports the SRTP keys of the sessions of a call to the
+// configured recorder. Exports are flagged as sensitive in the audit log.
+func (s *Service) handleKeyExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleKeyExport", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if !s.cfg.RTC.KeyExport.Enable {
+		data.err = "key export is not enabled"
+		data.code = http.StatusForbidden
+		return
+	}
+
+	if s.checkIdempotencyKey("handleKeyExport", data, w, r) {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	// Attempts are flagged too, successful or not.
+	data.auditFields = []mlog.Field{
+		mlog.Bool("sensitive", true),
+		mlog.String("groupID", data.reqData["groupID"]),
+		mlog.String("callID", data.reqData["callID"]),
+		mlog.String("reference", data.reqData["reference"]),
+	}
+
+	info, err := s.rtcServer.ExportSRTPKeys(data.reqData["groupID"], data.reqData["callID"],
+		data.reqData["reference"])
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+	data.auditFields = append(data.auditFields,
+		mlog.String("exportID", info.ID),
+		mlog.Int("sessions", len(info.SessionIDs)))
+
+	js, err := json.Marshal(info)
+	if err != nil {
+		data.err = "failed to marshal export info: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	data.code = http.StatusOK
+	data.resData["id"] = info.ID
+	data.resData["sessions"] = strconv.Itoa(len(info.SessionIDs))
+	data.resData["export"] = string(js)
+}
+
+// handleTestCall runs a synthetic test call on the node and reports whether
+// media flowed end-to-end.
+func (s *Service) handleTestCall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleTestCall", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if s.checkIdempotencyKey("handleTestCall", data, w, r) {
+		return
+	}
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+			data.err = err.Error()
+			data.code = http.StatusBadRequest
+			return
+		}
+	}
+
+	timeout := testCallDefaultTimeout
+	if val := data.reqData["timeoutSeconds"]; val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > testCallMaxTimeout {
+			data.err = "invalid timeoutSeconds value"
+			data.code = http.StatusBadRequest
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	res, err := s.runTestCall(timeout)
+	for k, v := range res.toMap() {
+		data.resData[k] = v
+	}
+	if err != nil {
+		s.log.Warn("test call failed", mlog.Err(err))
+		data.err = err.Error()
+		data.code = http.StatusServiceUnavailable
+		return
+	}
+
+	data.code = http.StatusOK
+}
+
+// handleBots lists (GET), spawns (POST) and stops (DELETE) the bots running
+// on the node.
+func (s *Service) handleBots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleBots", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if !s.cfg.Bots.Enable {
+		data.err = "bots are not enabled"
+		data.code = http.StatusForbidden
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		js, err := json.Marshal(s.getBots())
+		if err != nil {
+			data.err = "failed to marshal bots: " + err.Error()
+			data.code = http.StatusInternalServerError
+			return
+		}
+		data.code = http.StatusOK
+		data.resData["bots"] = string(js)
+		return
+	}
+
+	if s.checkIdempotencyKey("handleBots", data, w, r) {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	var info BotInfo
+	var err error
+	code := http.StatusOK
+	if r.Method == http.MethodDelete {
+		info, err = s.stopBot(data.reqData["id"])
+	} else {
+		cfg := BotConfig{
+			Type:          BotType(data.reqData["type"]),
+			GroupID:       data.reqData["groupID"],
+			CallID:        data.reqData["callID"],
+			Announcement:  data.reqData["announcement"],
+			TargetGroupID: data.reqData["targetGroupID"],
+			TargetCallID:  data.reqData["targetCallID"],
+		}
+		if val := data.reqData["durationSeconds"]; val != "" {
+			seconds, convErr := strconv.Atoi(val)
+			if convErr != nil || seconds < 0 {
+				data.err = "invalid durationSeconds value"
+				data.code = http.StatusBadRequest
+				return
+			}
+			cfg.Duration = time.Duration(seconds) * time.Second
+		}
+		info, err = s.startBot(cfg)
+		code = http.StatusCreated
+	}
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		data.errCode = errorCode(err)
+		return
+	}
+
+	js, err := json.Marshal(info)
+	if err != nil {
+		data.err = "failed to marshal bot info: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	data.code = code
+	data.resData["id"] = info.ID
+	data.resData["bot"] = string(js)
+}
+
+// handleMirrors lists (GET), starts (POST) and stops (DELETE) the mirroring
+// of calls to other rtcd instances.
+func (s *Service) handleMirrors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleMirrors", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if !s.cfg.Mirroring.Enable {
+		data.err = "mirroring is not enabled"
+		data.code = http.StatusForbidden
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		js, err := json.Marshal(s.getMirrors())
+		if err != nil {
+			data.err = "failed to marshal mirrors: " + err.Error()
+			data.code = http.StatusInternalServerError
+			return
+		}
+		data.code = http.StatusOK
+		data.resData["mirrors"] = string(js)
+		return
+	}
+
+	if s.checkIdempotencyKey("handleMirrors", data, w, r) {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	var info MirrorInfo
+	var err error
+	code := http.StatusOK
+	if r.Method == http.MethodDelete {
+		info, err = s.stopMirror(data.reqData["id"])
+	} else {
+		info, err = s.startMirror(MirrorConfig{
+			GroupID:      data.reqData["groupID"],
+			CallID:       data.reqData["callID"],
+			URL:          data.reqData["url"],
+			ClientID:     data.reqData["targetClientID"],
+			AuthKey:      data.reqData["targetAuthKey"],
+			TargetCallID: data.reqData["targetCallID"],
+		})
+		code = http.StatusCreated
+	}
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		data.errCode = errorCode(err)
+		return
+	}
+
+	js, err := json.Marshal(info)
+	if err != nil {
+		data.err = "failed to marshal mirror info: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	data.code = code
+	data.resData["id"] = info.ID
+	data.resData["mirror"] = string(js)
+}
@@ -0,0 +1,312 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/gorilla/websocket"
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const (
+	// adminEventsChSize is the number of events buffered for a consumer.
+	// Consumers lagging behind further get disconnected.
+	adminEventsChSize = 256
+	// adminEventsMaxConsumers is the maximum number of consumers streaming
+	// the events at once.
+	adminEventsMaxConsumers = 32
+	// adminEventsMaxReadBytes is the maximum size of the messages read from
+	// the consumers, which are only expected to send control ones.
+	adminEventsMaxReadBytes = 1024
+	adminEventsPingInterval = 10 * time.Second
+	adminEventsWriteTimeout = 10 * time.Second
+)
+
+// Types of the server events streamed to the admin consumers, besides the
+// call and session ones.
+const (
+	// ErrorEvent is sent when a message from a client or a session fails to
+	// be handled.
+	ErrorEvent rtc.EventType = "error"
+	// DrainStartedEvent and DrainFinishedEvent are sent when the sessions
+	// start being drained on shutdown, and once they're all closed.
+	DrainStartedEvent  rtc.EventType = "drain_started"
+	DrainFinishedEvent rtc.EventType = "drain_finished"
+)
+
+// AdminEvent is a live server event, as streamed to the admin consumers.
+type AdminEvent struct {
+	rtc.Event
+	// ClientID is set for ErrorEvent to the client whose message failed.
+	ClientID string `json:"client_id,omitempty"`
+	// Error is set for ErrorEvent to the error message.
+	Error string `json:"error,omitempty"`
+	// ForcedSessions is set for DrainFinishedEvent to the number of sessions
+	// which had to be force-closed.
+	ForcedSessions int `json:"forced_sessions,omitempty"`
+}
+
+func newAdminEvent(evType rtc.EventType) AdminEvent {
+	return AdminEvent{
+		Event: rtc.Event{
+			Type:      evType,
+			Timestamp: time.Now().UnixMilli(),
+		},
+	}
+}
+
+// adminEventsFilter selects the events streamed to a consumer. Empty fields
+// match any event.
+type adminEventsFilter struct {
+	types   map[rtc.EventType]bool
+	groupID string
+	callID  string
+}
+
+func parseAdminEventsFilter(r *http.Request) adminEventsFilter {
+	q := r.URL.Query()
+	filter := adminEventsFilter{
+		groupID: q.Get("groupID"),
+		callID:  q.Get("callID"),
+	}
+	if types := q.Get("types"); types != "" {
+		filter.types = map[rtc.EventType]bool{}
+		for _, t := range strings.Split(types, ",") {
+			filter.types[rtc.EventType(strings.TrimSpace(t))] = true
+		}
+	}
+	return filter
+}
+
+func (f adminEventsFilter) match(ev AdminEvent) bool {
+	if f.types != nil && !f.types[ev.Type] {
+		return false
+	}
+	if f.groupID != "" && ev.GroupID != f.groupID && ev.ClientID != f.groupID {
+		return false
+	}
+	if f.callID != "" && ev.CallID != f.callID {
+		return false
+	}
+	return true
+}
+
+type adminEventsConsumer struct {
+	filter adminEventsFilter
+	ch     chan []byte
+	// lagging is set once an event had to be dropped, the consumer being
+	// disconnected then.
+	lagging bool
+}
+
+// adminEventsHub dispatches the server events to the admin consumers.
+type adminEventsHub struct {
+	consumers map[*adminEventsConsumer]bool
+	closed    bool
+	mut       sync.Mutex
+}
+
+var errTooManyAdminEventsConsumers = errors.New("too many event consumers")
+
+func newAdminEventsHub() *adminEventsHub {
+	return &adminEventsHub{
+		consumers: map[*adminEventsConsumer]bool{},
+	}
+}
+
+func (h *adminEventsHub) subscribe(filter adminEventsFilter) (*adminEventsConsumer, error) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	if h.closed {
+		return nil, errors.New("hub is closed")
+	}
+	if len(h.consumers) >= adminEventsMaxConsumers {
+		return nil, errTooManyAdminEventsConsumers
+	}
+
+	c := &adminEventsConsumer{
+		filter: filter,
+		ch:     make(chan []byte, adminEventsChSize),
+	}
+	h.consumers[c] = true
+	return c, nil
+}
+
+func (h *adminEventsHub) unsubscribe(c *adminEventsConsumer) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	if h.consumers[c] {
+		delete(h.consumers, c)
+		close(c.ch)
+	}
+}
+
+// publish sends the event, as a JSON line, to the consumers it matches the
+// filter of. It never blocks: consumers whose buffer is full get
+// disconnected.
+func (h *adminEventsHub) publish(ev AdminEvent) error {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	if len(h.consumers) == 0 {
+		return nil
+	}
+
+	js, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	js = append(js, '\n')
+
+	for c := range h.consumers {
+		if !c.filter.match(ev) {
+			continue
+		}
+		select {
+		case c.ch <- js:
+		default:
+			c.lagging = true
+			delete(h.consumers, c)
+			close(c.ch)
+		}
+	}
+
+	return nil
+}
+
+// close disconnects all the consumers.
+func (h *adminEventsHub) close() {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	h.closed = true
+	for c := range h.consumers {
+		delete(h.consumers, c)
+		close(c.ch)
+	}
+}
+
+// publishAdminEvent streams the event to the admin consumers.
+func (s *Service) publishAdminEvent(ev AdminEvent) {
+	if err := s.adminEvents.publish(ev); err != nil {
+		s.log.Error("failed to publish admin event", mlog.Err(err), mlog.String("type", string(ev.Type)))
+	}
+}
+
+// handleAdminEvents streams the live server events over a WebSocket
+// connection, one JSON line per message, until the consumer disconnects.
+func (s *Service) handleAdminEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	// The response is only written by httpAudit in case of failure.
+	rw := w
+	defer func() {
+		s.httpAudit("handleAdminEvents", data, rw, r)
+	}()
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if !websocket.IsWebSocketUpgrade(r) {
+		data.err = "a WebSocket upgrade is required"
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	consumer, err := s.adminEvents.subscribe(parseAdminEventsFilter(r))
+	if err != nil {
+		data.err = "failed to subscribe: " + err.Error()
+		data.code = http.StatusServiceUnavailable
+		return
+	}
+	defer s.adminEvents.unsubscribe(consumer)
+
+	rw = nil
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already replied with an error.
+		data.err = "failed to upgrade connection: " + err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+	defer conn.Close()
+	data.code = http.StatusSwitchingProtocols
+
+	// Reading is needed to process the control messages, the ones sent by
+	// the consumer being ignored.
+	readDoneCh := make(chan struct{})
+	go func() {
+		defer close(readDoneCh)
+		conn.SetReadLimit(adminEventsMaxReadBytes)
+		_ = conn.SetReadDeadline(time.Now().Add(2 * adminEventsPingInterval))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * adminEventsPingInterval))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	pingTicker := time.NewTicker(adminEventsPingInterval)
+	defer pingTicker.Stop()
+
+	for {
+		select {
+		case js, ok := <-consumer.ch:
+			if !ok {
+				s.closeAdminEventsConn(conn, consumer)
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(adminEventsWriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, js); err != nil {
+				s.log.Debug("failed to write admin event", mlog.Err(err))
+				return
+			}
+		case <-pingTicker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(adminEventsWriteTimeout)); err != nil {
+				s.log.Debug("failed to ping admin events consumer", mlog.Err(err))
+				return
+			}
+		case <-readDoneCh:
+			return
+		}
+	}
+}
+
+// closeAdminEventsConn closes the connection of a consumer the hub
+// disconnected, telling it why.
+func (s *Service) closeAdminEventsConn(conn *websocket.Conn, consumer *adminEventsConsumer) {
+	s.adminEvents.mut.Lock()
+	lagging := consumer.lagging
+	s.adminEvents.mut.Unlock()
+
+	code, text := websocket.CloseGoingAway, "shutting down"
+	if lagging {
+		s.log.Warn("disconnecting lagging admin events consumer")
+		code, text = websocket.CloseTryAgainLater, "consumer is lagging behind"
+	}
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(adminEventsWriteTimeout))
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminEventsFilter(t *testing.T) {
+	ev := AdminEvent{Event: rtc.Event{Type: rtc.SessionJoinedEvent, GroupID: "groupA", CallID: "callA"}}
+
+	t.Run("empty", func(t *testing.T) {
+		filter := parseAdminEventsFilter(httptest.NewRequest(http.MethodGet, "/admin/events", nil))
+		require.True(t, filter.match(ev))
+	})
+
+	t.Run("types", func(t *testing.T) {
+		filter := parseAdminEventsFilter(httptest.NewRequest(http.MethodGet, "/admin/events?types=call_started,%20session_joined", nil))
+		require.True(t, filter.match(ev))
+		require.False(t, filter.match(newAdminEvent(DrainStartedEvent)))
+	})
+
+	t.Run("group and call", func(t *testing.T) {
+		filter := parseAdminEventsFilter(httptest.NewRequest(http.MethodGet, "/admin/events?groupID=groupA&callID=callA", nil))
+		require.True(t, filter.match(ev))
+
+		filter = parseAdminEventsFilter(httptest.NewRequest(http.MethodGet, "/admin/events?groupID=groupB", nil))
+		require.False(t, filter.match(ev))
+
+		errEv := newAdminEvent(ErrorEvent)
+		errEv.ClientID = "groupB"
+		require.True(t, filter.match(errEv))
+
+		filter = parseAdminEventsFilter(httptest.NewRequest(http.MethodGet, "/admin/events?callID=callB", nil))
+		require.False(t, filter.match(ev))
+	})
+}
+
+func TestAdminEventsHub(t *testing.T) {
+	hub := newAdminEventsHub()
+
+	consumer, err := hub.subscribe(adminEventsFilter{types: map[rtc.EventType]bool{ErrorEvent: true}})
+	require.NoError(t, err)
+
+	// Events not matching the filter aren't counted.
+	for i := 0; i < 2*adminEventsChSize; i++ {
+		require.NoError(t, hub.publish(newAdminEvent(DrainStartedEvent)))
+	}
+	require.Empty(t, consumer.ch)
+
+	for i := 0; i < adminEventsChSize; i++ {
+		require.NoError(t, hub.publish(newAdminEvent(ErrorEvent)))
+	}
+	require.Len(t, consumer.ch, adminEventsChSize)
+	js := <-consumer.ch
+	require.True(t, strings.HasSuffix(string(js), "}\n"))
+	var ev AdminEvent
+	require.NoError(t, json.Unmarshal(js, &ev))
+	require.Equal(t, ErrorEvent, ev.Type)
+
+	// The lagging consumer gets disconnected.
+	require.NoError(t, hub.publish(newAdminEvent(ErrorEvent)))
+	require.NoError(t, hub.publish(newAdminEvent(ErrorEvent)))
+	for range consumer.ch {
+	}
+	require.True(t, consumer.lagging)
+	hub.unsubscribe(consumer)
+
+	consumers := make([]*adminEventsConsumer, 0, adminEventsMaxConsumers)
+	for i := 0; i < adminEventsMaxConsumers; i++ {
+		c, err := hub.subscribe(adminEventsFilter{})
+		require.NoError(t, err)
+		consumers = append(consumers, c)
+	}
+	_, err = hub.subscribe(adminEventsFilter{})
+	require.ErrorIs(t, err, errTooManyAdminEventsConsumers)
+
+	hub.close()
+	for _, c := range consumers {
+		_, ok := <-c.ch
+		require.False(t, ok)
+	}
+	_, err = hub.subscribe(adminEventsFilter{})
+	require.Error(t, err)
+}
+
+func TestAdminEventsHandler(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	registerClient(t, th, "clientA", "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H")
+
+	wsURL := "ws" + strings.TrimPrefix(th.apiURL, "http") + "/admin/events"
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/events", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("clientA", "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("no upgrade", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/events", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		req, err := http.NewRequest("GET", wsURL+"?types=session_joined,error&groupID=clientA", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		conn, resp, err := websocket.DefaultDialer.Dial(req.URL.String(), req.Header)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		defer conn.Close()
+
+		th.srvc.publishAdminEvent(AdminEvent{Event: rtc.Event{Type: rtc.CallStartedEvent, GroupID: "clientA"}})
+		th.srvc.publishAdminEvent(AdminEvent{Event: rtc.Event{Type: rtc.SessionJoinedEvent, GroupID: "clientB"}})
+		th.srvc.publishAdminEvent(AdminEvent{Event: rtc.Event{Type: rtc.SessionJoinedEvent, GroupID: "clientA", SessionID: "sessionA"}})
+		errEv := newAdminEvent(ErrorEvent)
+		errEv.ClientID = "clientA"
+		errEv.Error = "failed"
+		th.srvc.publishAdminEvent(errEv)
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var ev AdminEvent
+		require.NoError(t, json.Unmarshal(data, &ev))
+		require.Equal(t, rtc.SessionJoinedEvent, ev.Type)
+		require.Equal(t, "sessionA", ev.SessionID)
+
+		_, data, err = conn.ReadMessage()
+		require.NoError(t, err)
+		require.Equal(t, `{"type":"error","timestamp":`, string(data[:28]))
+		ev = AdminEvent{}
+		require.NoError(t, json.Unmarshal(data, &ev))
+		require.Equal(t, "clientA", ev.ClientID)
+		require.Equal(t, "failed", ev.Error)
+	})
+}
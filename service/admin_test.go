@@ -0,0 +1,801 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/store"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminListener(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.API.Admin.ListenAddress = "127.0.0.1:0"
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	adminURL := "http://" + th.srvc.adminServer.Addr()
+
+	doRequest := func(t *testing.T, url string) int {
+		t.Helper()
+		req, err := http.NewRequest("GET", url, nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	t.Run("admin endpoints", func(t *testing.T) {
+		require.Equal(t, http.StatusNotFound, doRequest(t, th.apiURL+"/admin/rtc/sockets"))
+		require.Equal(t, http.StatusOK, doRequest(t, adminURL+"/admin/rtc/sockets"))
+		require.Equal(t, http.StatusNotFound, doRequest(t, th.apiURL+"/debug/pprof/heap"))
+		require.Equal(t, http.StatusOK, doRequest(t, adminURL+"/debug/pprof/heap"))
+	})
+
+	t.Run("public endpoints", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, doRequest(t, th.apiURL+"/version"))
+		require.Equal(t, http.StatusNotFound, doRequest(t, adminURL+"/version"))
+	})
+}
+
+func TestMetricsListener(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.API.Metrics.ListenAddress = "127.0.0.1:0"
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	metricsURL := "http://" + th.srvc.metricsServer.Addr()
+
+	doRequest := func(t *testing.T, url string) int {
+		t.Helper()
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusNotFound, doRequest(t, th.apiURL+"/metrics"))
+	require.Equal(t, http.StatusOK, doRequest(t, metricsURL+"/metrics"))
+	require.Equal(t, http.StatusNotFound, doRequest(t, metricsURL+"/version"))
+}
+
+func TestUDPSocketsHandler(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.RTC.UDPSockets.MaxCount = 2
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	t.Run("invalid method", func(t *testing.T) {
+		req, err := http.NewRequest("DELETE", th.apiURL+"/admin/rtc/sockets", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		registerClient(t, th, "clientA", "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H")
+
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/rtc/sockets", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("clientA", "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/rtc/sockets", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		require.NotEmpty(t, response["count"])
+		require.Equal(t, "0.00", response["packetRate"])
+	})
+
+	t.Run("set invalid count", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte(`{"count": "10"}`))
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/rtc/sockets", buf)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte(`{"count": "1"}`))
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/rtc/sockets", buf)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		require.Equal(t, "1", response["count"])
+	})
+
+	t.Run("set number", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte(`{"count": 1}`))
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/rtc/sockets", buf)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		require.Equal(t, "1", response["count"])
+	})
+
+	t.Run("set invalid type", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte(`{"count": [1]}`))
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/rtc/sockets", buf)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestCapacityHandler(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.RTC.Capacity.MaxPacketRate = 10000
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	t.Run("invalid method", func(t *testing.T) {
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/rtc/capacity", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/rtc/capacity", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/rtc/capacity", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		var capacity rtc.Capacity
+		require.NoError(t, json.Unmarshal([]byte(response["capacity"]), &capacity))
+		require.Equal(t, 10000, capacity.MaxPacketRate)
+		require.Equal(t, 1.0, capacity.Headroom)
+		require.False(t, capacity.Busy)
+	})
+}
+
+func TestStoreExportImportHandlers(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	registerClient(t, th, "clientA", "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H")
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/store/export", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("clientA", "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	var dump store.Dump
+	t.Run("export", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/store/export", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		dump, err = store.ReadDump(resp.Body)
+		require.NoError(t, err)
+		// The client's auth key hash, any signing key being internal.
+		require.Len(t, dump.Entries, 1)
+		require.Equal(t, "clientA", dump.Entries[0].Key)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		err := th.adminClient.Unregister("clientA")
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, store.WriteDump(&buf, dump))
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/store/import", &buf)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		require.Equal(t, "1", response["count"])
+
+		// The imported credentials should be usable.
+		err = th.srvc.auth.Authenticate("clientA", "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H")
+		require.NoError(t, err)
+	})
+
+	t.Run("import invalid", func(t *testing.T) {
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/store/import", bytes.NewBufferString(`{"version": 2}`))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestRuntimeParamsHandler(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	doRequest := func(t *testing.T, method, body string) (int, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest(method, th.apiURL+"/admin/rtc/params", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp.StatusCode, response
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/rtc/params", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("get defaults", func(t *testing.T) {
+		code, response := doRequest(t, "GET", "")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "0", response["maxScreenBitrateKbps"])
+		require.Equal(t, "0", response["nackBufferSize"])
+		require.Equal(t, "0", response["pliThrottleMs"])
+		require.Empty(t, response["logLevel"])
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		code, response := doRequest(t, "POST", `{"nackBufferSize": "100"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "invalid NACKBufferSize value: should be a power of two not greater than 32768", response["error"])
+
+		code, response = doRequest(t, "POST", `{"logLevel": "LOUD"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Contains(t, response["error"], "invalid LogLevel value")
+
+		code, response = doRequest(t, "POST", `{"unknown": "1"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, `unknown parameter "unknown"`, response["error"])
+	})
+
+	t.Run("set", func(t *testing.T) {
+		code, response := doRequest(t, "POST", `{"maxScreenBitrateKbps": "2500", "logLevel": "WARN"}`)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "2500", response["maxScreenBitrateKbps"])
+		require.Equal(t, "WARN", response["logLevel"])
+
+		code, response = doRequest(t, "POST", `{"pliThrottleMs": "500"}`)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "2500", response["maxScreenBitrateKbps"])
+		require.Equal(t, "500", response["pliThrottleMs"])
+		require.Equal(t, "WARN", response["logLevel"])
+
+		require.Equal(t, 2500, th.srvc.rtcServer.GetRuntimeParams().MaxScreenBitrateKbps)
+		require.Equal(t, 500, th.srvc.rtcServer.GetRuntimeParams().PLIThrottleMs)
+	})
+
+	t.Run("persisted across restarts", func(t *testing.T) {
+		err := th.srvc.Stop()
+		require.NoError(t, err)
+
+		th.srvc, err = New(*cfg)
+		require.NoError(t, err)
+		err = th.srvc.Start()
+		require.NoError(t, err)
+
+		params := th.srvc.getRuntimeParams()
+		require.Equal(t, 2500, params.RTC.MaxScreenBitrateKbps)
+		require.Equal(t, 500, params.RTC.PLIThrottleMs)
+		require.Equal(t, "WARN", params.LogLevel)
+		require.Equal(t, params.RTC, th.srvc.rtcServer.GetRuntimeParams())
+	})
+}
+
+func TestCaptureHandler(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.RTC.Capture = rtc.CaptureConfig{
+		Dir:                t.TempDir(),
+		MaxSizeMB:          1,
+		MaxDurationSeconds: 60,
+	}
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	doRequest := func(t *testing.T, method, body string) (int, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest(method, th.apiURL+"/admin/rtc/capture", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp.StatusCode, response
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/rtc/capture", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("invalid duration", func(t *testing.T) {
+		code, response := doRequest(t, "POST", `{"groupID": "groupID", "callID": "callID", "durationSeconds": "-1"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "invalid durationSeconds value", response["error"])
+	})
+
+	t.Run("call not found", func(t *testing.T) {
+		code, response := doRequest(t, "POST", `{"groupID": "groupID", "callID": "callID"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "group not found: groupID", response["error"])
+
+		code, response = doRequest(t, "DELETE", `{"groupID": "groupID", "callID": "callID"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "group not found: groupID", response["error"])
+	})
+}
+
+func TestRecordingHandler(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.RTC.Recording = rtc.RecordingConfig{
+		Dir:                t.TempDir(),
+		MaxDurationSeconds: 60,
+	}
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	doRequest := func(t *testing.T, method, body string) (int, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest(method, th.apiURL+"/admin/rtc/recording", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp.StatusCode, response
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/rtc/recording", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("invalid duration", func(t *testing.T) {
+		code, response := doRequest(t, "POST", `{"groupID": "groupID", "sessionID": "sessionID", "durationSeconds": "-1"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "invalid durationSeconds value", response["error"])
+	})
+
+	t.Run("invalid target", func(t *testing.T) {
+		code, response := doRequest(t, "POST", `{"groupID": "groupID", "sessionID": "sessionID", "target": "../tmp"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, `invalid recording target: "../tmp"`, response["error"])
+	})
+
+	t.Run("session not found", func(t *testing.T) {
+		code, response := doRequest(t, "POST", `{"groupID": "groupID", "sessionID": "sessionID"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "session not found: sessionID", response["error"])
+
+		code, response = doRequest(t, "DELETE", `{"groupID": "groupID", "sessionID": "sessionID"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "session not found: sessionID", response["error"])
+	})
+}
+
+func TestHLSStreamHandler(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.RTC.HLS = rtc.HLSConfig{
+		Enable:            true,
+		PartDurationMs:    500,
+		SegmentDurationMs: 2000,
+		PlaylistSegments:  6,
+	}
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	doRequest := func(t *testing.T, method, body string) (int, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest(method, th.apiURL+"/admin/rtc/hls", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp.StatusCode, response
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/rtc/hls", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("session not found", func(t *testing.T) {
+		code, response := doRequest(t, "POST", `{"groupID": "groupID", "sessionID": "sessionID"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "session not found: sessionID", response["error"])
+	})
+
+	t.Run("group not found", func(t *testing.T) {
+		code, response := doRequest(t, "DELETE", `{"groupID": "groupID", "callID": "callID"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "group not found: groupID", response["error"])
+	})
+}
+
+func TestAnnouncementHandler(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.RTC.Announcements = rtc.AnnouncementsConfig{
+		Enable:        true,
+		Dir:           t.TempDir(),
+		DefaultLocale: "en",
+		MaxQueueSize:  8,
+	}
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	doRequest := func(t *testing.T, body string) (int, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/rtc/announcements", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp.StatusCode, response
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/rtc/announcements", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		code, response := doRequest(t, `{"groupID": "groupID", "callID": "callID", "name": "../busy.ogg"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, `invalid announcement name: "../busy.ogg"`, response["error"])
+	})
+
+	t.Run("group not found", func(t *testing.T) {
+		code, response := doRequest(t, `{"groupID": "groupID", "callID": "callID", "name": "busy.ogg"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "group not found: groupID", response["error"])
+	})
+
+	t.Run("played", func(t *testing.T) {
+		require.NoError(t, os.MkdirAll(filepath.Join(cfg.RTC.Announcements.Dir, "fr"), 0700))
+		writeTestAnnouncement(t, filepath.Join(cfg.RTC.Announcements.Dir, "fr", "busy.ogg"), time.Second)
+
+		sessionCfg := rtc.SessionConfig{
+			GroupID:   "groupID",
+			CallID:    "callID",
+			UserID:    "userID",
+			SessionID: random.NewID(),
+			Locale:    "fr-CA",
+		}
+		p, err := newLocalPeer(sessionCfg, th.srvc.rtcServer, th.srvc.log)
+		require.NoError(t, err)
+		th.srvc.mut.Lock()
+		th.srvc.localPeers[sessionCfg.SessionID] = p
+		th.srvc.mut.Unlock()
+		defer func() {
+			_ = th.srvc.rtcServer.CloseSession(sessionCfg.SessionID)
+			_ = p.close()
+		}()
+
+		receivedCh := make(chan string, 1)
+		p.pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+			if _, _, err := track.ReadRTP(); err == nil {
+				receivedCh <- track.ID()
+			}
+		})
+		_, err = p.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{
+			Direction: webrtc.RTPTransceiverDirectionRecvonly,
+		})
+		require.NoError(t, err)
+		require.NoError(t, th.srvc.rtcServer.InitSession(sessionCfg, nil))
+		require.NoError(t, p.offer())
+		select {
+		case <-p.connCh:
+		case <-time.After(10 * time.Second):
+			require.Fail(t, "timed out waiting for peer to connect")
+		}
+
+		code, response := doRequest(t, `{"groupID": "groupID", "callID": "callID", "name": "busy.ogg"}`)
+		require.Equal(t, http.StatusOK, code, response["error"])
+		require.Equal(t, "1", response["sessions"])
+		var info rtc.AnnouncementInfo
+		require.NoError(t, json.Unmarshal([]byte(response["announcement"]), &info))
+		require.Equal(t, map[string]string{sessionCfg.SessionID: "fr"}, info.Sessions)
+
+		select {
+		case trackID := <-receivedCh:
+			require.True(t, strings.HasPrefix(trackID, "announcement_"+sessionCfg.SessionID))
+		case <-time.After(10 * time.Second):
+			require.Fail(t, "timed out waiting for announcement")
+		}
+	})
+}
+
+func TestKeyExportHandler(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.RTC.KeyExport = rtc.KeyExportConfig{
+		Enable:         true,
+		RecorderURL:    "https://localhost:1/keys",
+		TimeoutSeconds: 1,
+	}
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	doRequest := func(t *testing.T, body string) (int, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/rtc/key_export", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp.StatusCode, response
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/rtc/key_export", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		th.srvc.cfg.RTC.KeyExport.Enable = false
+		defer func() {
+			th.srvc.cfg.RTC.KeyExport.Enable = true
+		}()
+		code, response := doRequest(t, `{"groupID": "groupID", "callID": "callID", "reference": "case-42"}`)
+		require.Equal(t, http.StatusForbidden, code)
+		require.Equal(t, "key export is not enabled", response["error"])
+	})
+
+	t.Run("missing reference", func(t *testing.T) {
+		code, response := doRequest(t, `{"groupID": "groupID", "callID": "callID"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "invalid reference: should not be empty", response["error"])
+	})
+
+	t.Run("group not found", func(t *testing.T) {
+		code, response := doRequest(t, `{"groupID": "groupID", "callID": "callID", "reference": "case-42"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "group not found: groupID", response["error"])
+	})
+}
+
+func TestCallTopologyHandler(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	doRequest := func(t *testing.T, path string) (int, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest("GET", th.apiURL+path, nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp.StatusCode, response
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		resp, err := http.Get(th.apiURL + "/admin/calls/callID/topology?groupID=groupID")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("missing groupID", func(t *testing.T) {
+		code, _ := doRequest(t, "/admin/calls/callID/topology")
+		require.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("unknown call", func(t *testing.T) {
+		code, response := doRequest(t, "/admin/calls/callID/topology?groupID=groupID")
+		require.Equal(t, http.StatusNotFound, code)
+		require.Equal(t, "group not found: groupID", response["error"])
+	})
+
+	t.Run("success", func(t *testing.T) {
+		cfg := rtc.SessionConfig{
+			GroupID:   "groupID",
+			CallID:    "callID",
+			UserID:    "userID",
+			SessionID: "sessionID",
+		}
+		require.NoError(t, th.srvc.rtcServer.InitSession(cfg, nil))
+		defer func() {
+			require.NoError(t, th.srvc.rtcServer.CloseSession("sessionID"))
+		}()
+
+		code, response := doRequest(t, "/admin/calls/callID/topology?groupID=groupID")
+		require.Equal(t, http.StatusOK, code, response["error"])
+		require.Equal(t, "callID", response["callID"])
+		var topology rtc.CallTopology
+		require.NoError(t, json.Unmarshal([]byte(response["topology"]), &topology))
+		require.Equal(t, rtc.CallTopology{
+			GroupID:     "groupID",
+			CallID:      "callID",
+			Publishers:  []rtc.TopologyPublisher{},
+			Subscribers: []rtc.TopologySubscriber{{SessionID: "sessionID", UserID: "userID", Tracks: []string{}}},
+			Edges:       []rtc.TopologyEdge{},
+		}, topology)
+	})
+}
+
+func TestCallObserversHandler(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	doRequest := func(t *testing.T, body string) (int, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/calls/callID/observers", strings.NewReader(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp.StatusCode, response
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		resp, err := http.Post(th.apiURL+"/admin/calls/callID/observers", "application/json",
+			strings.NewReader(`{"groupID": "groupID", "userID": "userID"}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("missing groupID", func(t *testing.T) {
+		code, response := doRequest(t, `{"userID": "userID"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "missing groupID", response["error"])
+	})
+
+	t.Run("missing userID", func(t *testing.T) {
+		code, response := doRequest(t, `{"groupID": "groupID"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "missing userID", response["error"])
+	})
+
+	t.Run("unknown call", func(t *testing.T) {
+		code, response := doRequest(t, `{"groupID": "groupID", "userID": "userID"}`)
+		require.Equal(t, http.StatusNotFound, code)
+		require.Equal(t, "group not found: groupID", response["error"])
+	})
+
+	t.Run("success", func(t *testing.T) {
+		cfg := rtc.SessionConfig{
+			GroupID:   "groupID",
+			CallID:    "callID",
+			UserID:    "userID",
+			SessionID: "sessionID",
+		}
+		require.NoError(t, th.srvc.rtcServer.InitSession(cfg, nil))
+		defer func() {
+			require.NoError(t, th.srvc.rtcServer.CloseSession("sessionID"))
+		}()
+
+		code, response := doRequest(t, `{"groupID": "groupID", "userID": "supportUserID"}`)
+		require.Equal(t, http.StatusCreated, code, response["error"])
+		require.NotEmpty(t, response["sessionID"])
+		require.NotEmpty(t, response["expiresAt"])
+
+		require.NoError(t, th.srvc.auth.ValidateObserverToken(response["token"], "groupID", "callID", response["sessionID"]))
+		require.Error(t, th.srvc.auth.ValidateJoinToken(response["token"], "groupID", "callID", response["sessionID"]))
+	})
+}
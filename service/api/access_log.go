@@ -0,0 +1,115 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// RequestIDHeader is the header carrying the ID of a request. If the client
+// doesn't provide one it's generated and set on both the request and the
+// response.
+const RequestIDHeader = "X-Request-Id"
+
+type ctxKey int
+
+const (
+	accessLogCtxKey ctxKey = iota
+	versionCtxKey
+	connCtxKey
+)
+
+// accessLogInfo holds the request details only known to the handlers.
+type accessLogInfo struct {
+	clientID string
+	mut      sync.Mutex
+}
+
+// SetClientID records the authenticated client of the request so that it's
+// included in the access log. It's a no-op if access logging is disabled.
+func SetClientID(r *http.Request, clientID string) {
+	info, ok := r.Context().Value(accessLogCtxKey).(*accessLogInfo)
+	if !ok {
+		return
+	}
+	info.mut.Lock()
+	defer info.mut.Unlock()
+	info.clientID = clientID
+}
+
+// statusRecorder captures the status code and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(data []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(data)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack is needed by the WebSocket upgrade.
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	rec.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+func (s *Server) accessLogHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		reqID := r.Header.Get(RequestIDHeader)
+		if reqID == "" {
+			reqID = random.NewID()
+			r.Header.Set(RequestIDHeader, reqID)
+		}
+		w.Header().Set(RequestIDHeader, reqID)
+
+		info := &accessLogInfo{}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), accessLogCtxKey, info)))
+
+		info.mut.Lock()
+		clientID := info.clientID
+		info.mut.Unlock()
+
+		s.log.Info("api: request",
+			mlog.String("method", r.Method),
+			mlog.String("path", r.URL.Path),
+			mlog.Int("status", rec.status),
+			mlog.Int("bytes", rec.bytes),
+			mlog.Int64("latencyMs", time.Since(start).Milliseconds()),
+			mlog.String("clientID", clientID),
+			mlog.String("requestID", reqID),
+			mlog.String("remoteAddr", r.RemoteAddr),
+		)
+	})
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLog(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		err := log.Shutdown()
+		require.NoError(t, err)
+	}()
+	var buf bytes.Buffer
+	err = mlog.AddWriterTarget(log, &buf, true, mlog.LvlInfo)
+	require.NoError(t, err)
+
+	s, err := NewServer(Config{ListenAddress: ":0", EnableAccessLog: true}, log)
+	require.NoError(t, err)
+	s.RegisterHandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		require.NotEmpty(t, r.Header.Get(RequestIDHeader))
+		SetClientID(r, "clientA")
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("data"))
+	})
+
+	t.Run("generated request id", func(t *testing.T) {
+		buf.Reset()
+		w := httptest.NewRecorder()
+		s.srv.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+		require.Equal(t, http.StatusTeapot, w.Code)
+		reqID := w.Header().Get(RequestIDHeader)
+		require.Len(t, reqID, 26)
+
+		require.NoError(t, log.Flush())
+		line := buf.String()
+		require.Contains(t, line, `"msg":"api: request"`)
+		require.Contains(t, line, `"method":"GET"`)
+		require.Contains(t, line, `"path":"/test"`)
+		require.Contains(t, line, `"status":418`)
+		require.Contains(t, line, `"bytes":4`)
+		require.Contains(t, line, `"clientID":"clientA"`)
+		require.Contains(t, line, `"requestID":"`+reqID+`"`)
+		require.Contains(t, line, `"latencyMs":`)
+	})
+
+	t.Run("propagated request id", func(t *testing.T) {
+		buf.Reset()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/test", nil)
+		req.Header.Set(RequestIDHeader, "customID")
+		s.srv.Handler.ServeHTTP(w, req)
+		require.Equal(t, "customID", w.Header().Get(RequestIDHeader))
+
+		require.NoError(t, log.Flush())
+		require.Contains(t, buf.String(), `"requestID":"customID"`)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		buf.Reset()
+		s, err := NewServer(Config{ListenAddress: ":0"}, log)
+		require.NoError(t, err)
+		s.RegisterHandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+			SetClientID(r, "clientA")
+		})
+		w := httptest.NewRecorder()
+		s.srv.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+		require.Empty(t, w.Header().Get(RequestIDHeader))
+
+		require.NoError(t, log.Flush())
+		require.NotContains(t, buf.String(), "api: request")
+	})
+}
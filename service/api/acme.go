@@ -0,0 +1,67 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newACMEManager returns the manager obtaining the certificates of the
+// configured domains on the first handshake requiring them, and renewing
+// them in the background ahead of their expiration.
+func newACMEManager(cfg ACMEConfig) *autocert.Manager {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cfg.CacheDir),
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Email:      cfg.Email,
+	}
+	if cfg.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.DirectoryURL}
+	}
+	return m
+}
+
+// startACME makes the server get its certificates through ACME, answering
+// the TLS-ALPN-01 challenges on its listener and, if configured, the HTTP-01
+// ones on a separate plaintext listener.
+func (s *Server) startACME() error {
+	m := newACMEManager(s.cfg.TLS.ACME)
+	s.srv.TLSConfig.GetCertificate = m.GetCertificate
+	s.srv.TLSConfig.NextProtos = []string{"h2", "http/1.1", acme.ALPNProto}
+
+	if s.cfg.TLS.ACME.HTTPChallengeAddress == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", s.cfg.TLS.ACME.HTTPChallengeAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen for http challenges: %w", err)
+	}
+	s.acmeListener = listener
+	s.acmeSrv = &http.Server{
+		// Requests other than challenges are redirected to HTTPS.
+		Handler:           m.HTTPHandler(nil),
+		ReadHeaderTimeout: defaultReadHeaderTimeout,
+		ReadTimeout:       defaultReadTimeout,
+		WriteTimeout:      defaultWriteTimeout,
+		IdleTimeout:       defaultIdleTimeout,
+	}
+
+	s.log.Info("api: answering acme http challenges on " + listener.Addr().String())
+
+	go func() {
+		if err := s.acmeSrv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.log.Error("error serving acme http challenges", mlog.Err(err))
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACME(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, log.Shutdown())
+	}()
+
+	cfg := Config{
+		ListenAddress: "localhost:0",
+		TLS: TLSConfig{
+			Enable: true,
+			ACME: ACMEConfig{
+				Enable:               true,
+				Domains:              []string{"rtcd.example.com"},
+				CacheDir:             t.TempDir(),
+				AcceptTOS:            true,
+				HTTPChallengeAddress: "localhost:0",
+			},
+		},
+	}
+	s, err := NewServer(cfg, log)
+	require.NoError(t, err)
+	require.NoError(t, s.Start())
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	t.Run("tls-alpn-01", func(t *testing.T) {
+		require.Contains(t, s.srv.TLSConfig.NextProtos, "acme-tls/1")
+
+		// Certificates are only requested for the configured domains.
+		conn, err := tls.Dial("tcp", s.Addr(), &tls.Config{ServerName: "other.example.com"})
+		if err == nil {
+			conn.Close()
+		}
+		require.Error(t, err)
+	})
+
+	t.Run("http-01", func(t *testing.T) {
+		require.NotNil(t, s.acmeListener)
+		client := &http.Client{
+			CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+
+		// Other requests get redirected to HTTPS.
+		req, err := http.NewRequest(http.MethodGet, "http://"+s.acmeListener.Addr().String()+"/version", nil)
+		require.NoError(t, err)
+		req.Host = "rtcd.example.com"
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+		require.Equal(t, "https://rtcd.example.com/version", resp.Header.Get("Location"))
+
+		// Unknown challenges aren't found.
+		req.URL.Path = "/.well-known/acme-challenge/token"
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// certLoader serves the TLS key pair loaded from the configured files,
+// reloading it whenever any of them gets modified so that certificates can
+// be rotated without restarting the server.
+type certLoader struct {
+	certFile string
+	keyFile  string
+
+	cert    *tls.Certificate
+	modTime time.Time
+	mut     sync.Mutex
+}
+
+func newCertLoader(certFile, keyFile string) (*certLoader, error) {
+	l := &certLoader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if _, err := l.getCertificate(nil); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *certLoader) filesModTime() (time.Time, error) {
+	var modTime time.Time
+	for _, name := range []string{l.certFile, l.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime, nil
+}
+
+func (l *certLoader) getCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	modTime, err := l.filesModTime()
+	if err != nil {
+		// Keep serving the last valid certificate if the files are
+		// temporarily unavailable.
+		if l.cert != nil {
+			return l.cert, nil
+		}
+		return nil, fmt.Errorf("failed to stat certificate files: %w", err)
+	}
+
+	if l.cert != nil && !modTime.After(l.modTime) {
+		return l.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		if l.cert != nil {
+			return l.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load key pair: %w", err)
+	}
+
+	l.cert = &cert
+	l.modTime = modTime
+
+	return l.cert, nil
+}
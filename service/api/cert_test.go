@@ -0,0 +1,67 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertLoader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	t.Run("missing files", func(t *testing.T) {
+		l, err := newCertLoader(certFile, keyFile)
+		require.Error(t, err)
+		require.Nil(t, l)
+	})
+
+	certData, err := os.ReadFile("../../testfiles/tls_test_cert.pem")
+	require.NoError(t, err)
+	keyData, err := os.ReadFile("../../testfiles/tls_test_key.pem")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, certData, 0600))
+	require.NoError(t, os.WriteFile(keyFile, keyData, 0600))
+
+	l, err := newCertLoader(certFile, keyFile)
+	require.NoError(t, err)
+	require.NotNil(t, l)
+
+	cert, err := l.getCertificate(nil)
+	require.NoError(t, err)
+	require.NotNil(t, cert)
+
+	t.Run("cached", func(t *testing.T) {
+		cached, err := l.getCertificate(nil)
+		require.NoError(t, err)
+		require.True(t, cert == cached)
+	})
+
+	t.Run("reload on change", func(t *testing.T) {
+		modTime := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+
+		reloaded, err := l.getCertificate(nil)
+		require.NoError(t, err)
+		require.False(t, cert == reloaded)
+		cert = reloaded
+	})
+
+	t.Run("invalid files", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("invalid"), 0600))
+		modTime := time.Now().Add(2 * time.Minute)
+		require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+
+		// The last valid certificate is kept.
+		current, err := l.getCertificate(nil)
+		require.NoError(t, err)
+		require.True(t, cert == current)
+	})
+}
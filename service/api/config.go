@@ -7,13 +7,71 @@ import (
 	"fmt"
 )
 
+// ACMEConfig configures the automatic management of the TLS certificate
+// through the ACME protocol (e.g. Let's Encrypt).
+type ACMEConfig struct {
+	Enable bool
+	// Domains are the host names certificates are requested for. Requests
+	// for any other name are rejected.
+	Domains []string `toml:"domains"`
+	// Email is the contact address of the ACME account, notified about
+	// certificates about to expire.
+	Email string `toml:"email"`
+	// CacheDir is the directory the account key and certificates are stored
+	// in, so that they survive restarts.
+	CacheDir string `toml:"cache_dir"`
+	// DirectoryURL is the directory endpoint of the ACME server. If empty,
+	// Let's Encrypt is used.
+	DirectoryURL string `toml:"directory_url"`
+	// AcceptTOS must be set to agree to the terms of service of the ACME
+	// server.
+	AcceptTOS bool `toml:"accept_tos"`
+	// HTTPChallengeAddress is the address a plaintext HTTP server answering
+	// the HTTP-01 challenges listens on (e.g. ":80"). If empty, only the
+	// TLS-ALPN-01 challenges, answered on the TLS listener, are used.
+	HTTPChallengeAddress string `toml:"http_challenge_address"`
+}
+
+func (c ACMEConfig) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+	if len(c.Domains) == 0 {
+		return fmt.Errorf("invalid Domains value: should not be empty")
+	}
+	for _, domain := range c.Domains {
+		if domain == "" {
+			return fmt.Errorf("invalid Domains value: should not contain empty names")
+		}
+	}
+	if c.CacheDir == "" {
+		return fmt.Errorf("invalid CacheDir value: should not be empty")
+	}
+	if !c.AcceptTOS {
+		return fmt.Errorf("invalid AcceptTOS value: the terms of service should be accepted")
+	}
+	return nil
+}
+
 type TLSConfig struct {
 	Enable   bool
 	CertFile string `toml:"cert_file"`
 	CertKey  string `toml:"cert_key"`
+	// ACME configures the automatic management of the certificate, in place
+	// of CertFile and CertKey.
+	ACME ACMEConfig `toml:"acme"`
 }
 
 func (c TLSConfig) IsValid() error {
+	if c.ACME.Enable {
+		if !c.Enable {
+			return fmt.Errorf("invalid ACME config: TLS should be enabled")
+		}
+		if err := c.ACME.IsValid(); err != nil {
+			return fmt.Errorf("invalid ACME config: %w", err)
+		}
+		return nil
+	}
 	if c.Enable {
 		if c.CertFile == "" {
 			return fmt.Errorf("invalid CertFile value: should not be empty")
@@ -28,6 +86,42 @@ func (c TLSConfig) IsValid() error {
 type Config struct {
 	ListenAddress string `toml:"listen_address"`
 	TLS           TLSConfig
+	// EnableAccessLog controls whether a log line should be written for
+	// every request served.
+	EnableAccessLog bool `toml:"enable_access_log"`
+	// ValidateRequests controls whether the requests not matching the
+	// OpenAPI specification of the API should be rejected.
+	ValidateRequests bool `toml:"validate_requests"`
+	// ValidateResponses controls whether the responses not matching the
+	// OpenAPI specification of the API should be logged.
+	ValidateResponses bool `toml:"validate_responses"`
+	// ReadTimeoutSeconds is the time allowed to read a request, body
+	// included. Zero uses the default of 30 seconds.
+	ReadTimeoutSeconds int `toml:"read_timeout_seconds"`
+	// ReadHeaderTimeoutSeconds is the time allowed to read the headers of a
+	// request. Zero uses the default of 10 seconds.
+	ReadHeaderTimeoutSeconds int `toml:"read_header_timeout_seconds"`
+	// WriteTimeoutSeconds is the time allowed to write a response, once the
+	// headers of the request are read. Zero uses the default of 60 seconds.
+	WriteTimeoutSeconds int `toml:"write_timeout_seconds"`
+	// IdleTimeoutSeconds is the time keep-alive connections are kept open
+	// waiting for the next request. Zero uses the default of 30 seconds.
+	IdleTimeoutSeconds int `toml:"idle_timeout_seconds"`
+	// MaxHeaderSizeKB is the maximum size of the headers of a request. Zero
+	// uses the default of 64KB.
+	MaxHeaderSizeKB int `toml:"max_header_size_kb"`
+	// MaxBodySizeKB is the maximum size of the body of a request, unless the
+	// route allows for larger ones. Zero uses the default of 1MB.
+	MaxBodySizeKB int `toml:"max_body_size_kb"`
+	// LongRequestTimeoutSeconds is the time allowed to read and serve a
+	// request on the routes holding them open for long (e.g. blocking
+	// playlist reloads, store dumps), in place of the read and write
+	// timeouts. Zero uses the default of 300 seconds.
+	LongRequestTimeoutSeconds int `toml:"long_request_timeout_seconds"`
+	// UpgradeTimeoutSeconds is the time allowed to complete the handshake of
+	// WebSocket connections, once the headers of the request are read. Zero
+	// uses the default of 10 seconds.
+	UpgradeTimeoutSeconds int `toml:"upgrade_timeout_seconds"`
 }
 
 func (c Config) IsValid() error {
@@ -37,5 +131,29 @@ func (c Config) IsValid() error {
 	if err := c.TLS.IsValid(); err != nil {
 		return fmt.Errorf("invalid TLS config: %w", err)
 	}
+	if c.ReadTimeoutSeconds < 0 {
+		return fmt.Errorf("invalid ReadTimeoutSeconds value: should not be negative")
+	}
+	if c.ReadHeaderTimeoutSeconds < 0 {
+		return fmt.Errorf("invalid ReadHeaderTimeoutSeconds value: should not be negative")
+	}
+	if c.WriteTimeoutSeconds < 0 {
+		return fmt.Errorf("invalid WriteTimeoutSeconds value: should not be negative")
+	}
+	if c.IdleTimeoutSeconds < 0 {
+		return fmt.Errorf("invalid IdleTimeoutSeconds value: should not be negative")
+	}
+	if c.MaxHeaderSizeKB < 0 {
+		return fmt.Errorf("invalid MaxHeaderSizeKB value: should not be negative")
+	}
+	if c.MaxBodySizeKB < 0 {
+		return fmt.Errorf("invalid MaxBodySizeKB value: should not be negative")
+	}
+	if c.LongRequestTimeoutSeconds < 0 {
+		return fmt.Errorf("invalid LongRequestTimeoutSeconds value: should not be negative")
+	}
+	if c.UpgradeTimeoutSeconds < 0 {
+		return fmt.Errorf("invalid UpgradeTimeoutSeconds value: should not be negative")
+	}
 	return nil
 }
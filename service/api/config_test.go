@@ -36,6 +36,57 @@ func TestIsValid(t *testing.T) {
 		require.Equal(t, "invalid TLS config: invalid CertKey value: should not be empty", err.Error())
 	})
 
+	t.Run("acme without tls", func(t *testing.T) {
+		var cfg Config
+		cfg.ListenAddress = ":8080"
+		cfg.TLS.ACME.Enable = true
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TLS config: invalid ACME config: TLS should be enabled", err.Error())
+	})
+
+	t.Run("acme missing tos", func(t *testing.T) {
+		var cfg Config
+		cfg.ListenAddress = ":8080"
+		cfg.TLS.Enable = true
+		cfg.TLS.ACME.Enable = true
+		cfg.TLS.ACME.Domains = []string{"rtcd.example.com"}
+		cfg.TLS.ACME.CacheDir = "/var/lib/rtcd/acme"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TLS config: invalid ACME config: invalid AcceptTOS value: the terms of service should be accepted", err.Error())
+	})
+
+	t.Run("valid with acme", func(t *testing.T) {
+		var cfg Config
+		cfg.ListenAddress = ":8080"
+		cfg.TLS.Enable = true
+		cfg.TLS.ACME.Enable = true
+		cfg.TLS.ACME.Domains = []string{"rtcd.example.com"}
+		cfg.TLS.ACME.CacheDir = "/var/lib/rtcd/acme"
+		cfg.TLS.ACME.AcceptTOS = true
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("negative timeout", func(t *testing.T) {
+		var cfg Config
+		cfg.ListenAddress = ":8080"
+		cfg.WriteTimeoutSeconds = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid WriteTimeoutSeconds value: should not be negative", err.Error())
+	})
+
+	t.Run("negative body size", func(t *testing.T) {
+		var cfg Config
+		cfg.ListenAddress = ":8080"
+		cfg.MaxBodySizeKB = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxBodySizeKB value: should not be negative", err.Error())
+	})
+
 	t.Run("valid no tls", func(t *testing.T) {
 		var cfg Config
 		cfg.ListenAddress = ":8080"
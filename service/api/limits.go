@@ -0,0 +1,117 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultReadTimeout        = 30 * time.Second
+	defaultReadHeaderTimeout  = 10 * time.Second
+	defaultWriteTimeout       = 60 * time.Second
+	defaultIdleTimeout        = 30 * time.Second
+	defaultMaxHeaderSize      = 64 * 1024
+	defaultMaxBodySize        = 1024 * 1024
+	defaultLongRequestTimeout = 300 * time.Second
+	defaultUpgradeTimeout     = 10 * time.Second
+)
+
+// secondsOr returns the given number of seconds as a duration, or def if
+// zero.
+func secondsOr(seconds int, def time.Duration) time.Duration {
+	if seconds == 0 {
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// kbOr returns the given number of kilobytes in bytes, or def if zero.
+func kbOr(kb int, def int) int {
+	if kb == 0 {
+		return def
+	}
+	return kb * 1024
+}
+
+// routeLimits holds the limits applied to the requests of a route.
+type routeLimits struct {
+	// timeout is the time allowed to read and serve a request.
+	timeout time.Duration
+	// upgrade is set for the routes upgrading connections to WebSocket,
+	// which outlive the handler.
+	upgrade bool
+	// maxBodySize is the maximum size of request bodies, zero meaning
+	// unlimited.
+	maxBodySize int64
+}
+
+// RouteOption customizes the limits applied to the requests of a route.
+type RouteOption func(s *Server, l *routeLimits)
+
+// WithLongRequests marks a route as holding requests open for long, such as
+// long polling or streaming ones, which are then given the
+// LongRequestTimeoutSeconds of the server to be read and served.
+func WithLongRequests() RouteOption {
+	return func(s *Server, l *routeLimits) {
+		l.timeout = secondsOr(s.cfg.LongRequestTimeoutSeconds, defaultLongRequestTimeout)
+	}
+}
+
+// WithUpgrade marks a route as upgrading connections to WebSocket, the
+// handshake of which is given the UpgradeTimeoutSeconds of the server. The
+// server timeouts don't apply to upgraded connections.
+func WithUpgrade() RouteOption {
+	return func(s *Server, l *routeLimits) {
+		l.timeout = secondsOr(s.cfg.UpgradeTimeoutSeconds, defaultUpgradeTimeout)
+		l.upgrade = true
+	}
+}
+
+// WithMaxBodySize sets the maximum size of the request bodies of a route, in
+// place of the MaxBodySizeKB of the server. Zero means unlimited.
+func WithMaxBodySize(size int64) RouteOption {
+	return func(_ *Server, l *routeLimits) {
+		l.maxBodySize = size
+	}
+}
+
+// limitsHandler applies the limits of a route to its requests. Request
+// contexts expire once their response can't be written anymore.
+func (s *Server) limitsHandler(next http.Handler, opts []RouteOption) http.Handler {
+	limits := routeLimits{
+		timeout:     s.srv.WriteTimeout,
+		maxBodySize: int64(kbOr(s.cfg.MaxBodySizeKB, defaultMaxBodySize)),
+	}
+	for _, opt := range opts {
+		opt(s, &limits)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limits.maxBodySize > 0 && r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(w, r.Body, limits.maxBodySize)
+		}
+
+		if limits.timeout != s.srv.WriteTimeout {
+			// Deadlines can only be moved on HTTP/1 connections, which
+			// serve a single request at a time.
+			if conn, ok := r.Context().Value(connCtxKey).(net.Conn); ok && r.ProtoMajor == 1 {
+				deadline := time.Now().Add(limits.timeout)
+				_ = conn.SetReadDeadline(deadline)
+				_ = conn.SetWriteDeadline(deadline)
+			}
+		}
+
+		if !limits.upgrade {
+			ctx, cancel := context.WithTimeout(r.Context(), limits.timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
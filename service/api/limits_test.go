@@ -0,0 +1,92 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteLimits(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, log.Shutdown())
+	}()
+
+	cfg := Config{
+		ListenAddress:             "localhost:0",
+		WriteTimeoutSeconds:       1,
+		MaxBodySizeKB:             1,
+		LongRequestTimeoutSeconds: 3,
+	}
+	s, err := NewServer(cfg, log)
+	require.NoError(t, err)
+
+	readBody := func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+	}
+	s.RegisterHandleFunc("/body", readBody)
+	s.RegisterHandleFunc("/body/unlimited", readBody, WithMaxBodySize(0))
+
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok := r.Context().Deadline()
+		if !ok {
+			http.Error(w, "no deadline", http.StatusInternalServerError)
+			return
+		}
+		time.Sleep(1500 * time.Millisecond)
+		_, _ = w.Write([]byte(time.Until(deadline).String()))
+	}
+	s.RegisterHandleFunc("/slow", slow)
+	s.RegisterHandleFunc("/slow/long", slow, WithLongRequests())
+
+	require.NoError(t, s.Start())
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	baseURL := "http://" + s.Addr()
+
+	t.Run("body size", func(t *testing.T) {
+		body := strings.Repeat("a", 2048)
+
+		resp, err := http.Post(baseURL+"/body", "text/plain", strings.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+		resp, err = http.Post(baseURL+"/body/unlimited", "text/plain", strings.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("timeouts", func(t *testing.T) {
+		// The response can't be written past the write timeout.
+		resp, err := http.Get(baseURL + "/slow")
+		if err == nil {
+			defer resp.Body.Close()
+			_, err = io.ReadAll(resp.Body)
+		}
+		require.Error(t, err)
+
+		resp, err = http.Get(baseURL + "/slow/long")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		left, err := time.ParseDuration(string(data))
+		require.NoError(t, err)
+		require.Greater(t, left, time.Second)
+	})
+}
@@ -9,10 +9,22 @@ import (
 
 type HandleFunc func(http.ResponseWriter, *http.Request)
 
-func (s *Server) RegisterHandleFunc(path string, hf HandleFunc) {
-	s.mux.HandleFunc(path, hf)
+// RegisterHandleFunc registers the handler function for the given pattern,
+// the options customizing the limits applied to its requests.
+func (s *Server) RegisterHandleFunc(path string, hf HandleFunc, opts ...RouteOption) {
+	s.mux.Handle(path, s.limitsHandler(http.HandlerFunc(hf), opts))
+	s.routes = append(s.routes, path)
 }
 
-func (s *Server) RegisterHandler(path string, handler http.Handler) {
-	s.mux.Handle(path, handler)
+// RegisterHandler registers the handler for the given pattern, the options
+// customizing the limits applied to its requests.
+func (s *Server) RegisterHandler(path string, handler http.Handler, opts ...RouteOption) {
+	s.mux.Handle(path, s.limitsHandler(handler, opts))
+	s.routes = append(s.routes, path)
+}
+
+// Routes returns the patterns registered on the server, in registration
+// order.
+func (s *Server) Routes() []string {
+	return append([]string(nil), s.routes...)
 }
@@ -0,0 +1,491 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const (
+	jsonMediaType = "application/json"
+	// maxValidatedBodySize is the size past which request and response
+	// bodies are not validated.
+	maxValidatedBodySize = 1 << 20
+)
+
+// Spec is an OpenAPI 3 specification of the HTTP API. Only the subset needed
+// to validate JSON bodies is modeled: path templates, request bodies and
+// responses, and schemas made of types, properties, required properties,
+// items, enums and local references.
+type Spec struct {
+	OpenAPI    string               `json:"openapi"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components struct {
+		Schemas   map[string]*Schema   `json:"schemas"`
+		Responses map[string]*Response `json:"responses"`
+	} `json:"components"`
+
+	raw    []byte
+	routes []specRoute
+}
+
+// PathItem holds the operations of a path, keyed by lower case method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref         string                `json:"$ref"`
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Enum                 []string           `json:"enum"`
+}
+
+type specRoute struct {
+	path     string
+	segments []string
+	item     *PathItem
+}
+
+// ParseSpec parses a JSON encoded OpenAPI 3 specification.
+func ParseSpec(data []byte) (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal spec: %w", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q", spec.OpenAPI)
+	}
+
+	for path, item := range spec.Paths {
+		if !strings.HasPrefix(path, "/") || item == nil {
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+		for method, op := range *item {
+			if op == nil {
+				return nil, fmt.Errorf("invalid operation %s %s", method, path)
+			}
+			if err := spec.resolveResponses(op); err != nil {
+				return nil, fmt.Errorf("invalid operation %s %s: %w", method, path, err)
+			}
+			if err := spec.checkRefs(op); err != nil {
+				return nil, fmt.Errorf("invalid operation %s %s: %w", method, path, err)
+			}
+		}
+		spec.routes = append(spec.routes, specRoute{path: path, segments: strings.Split(path, "/"), item: item})
+	}
+	// Static segments take precedence over templated ones.
+	sort.Slice(spec.routes, func(i, j int) bool {
+		return strings.Count(spec.routes[i].path, "{") < strings.Count(spec.routes[j].path, "{")
+	})
+
+	spec.raw = data
+
+	return &spec, nil
+}
+
+// Raw returns the specification as it was parsed.
+func (s *Spec) Raw() []byte {
+	return s.raw
+}
+
+// HasPath returns whether the given pattern, as registered on the server,
+// is documented. Patterns ending with a slash match the paths under them.
+func (s *Spec) HasPath(pattern string) bool {
+	for _, route := range s.routes {
+		if route.path == pattern || (strings.HasSuffix(pattern, "/") && strings.HasPrefix(route.path, pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveResponses replaces the references to the shared responses of the
+// components with the responses themselves.
+func (s *Spec) resolveResponses(op *Operation) error {
+	for status, res := range op.Responses {
+		if res == nil {
+			return fmt.Errorf("invalid response %q", status)
+		}
+		if res.Ref == "" {
+			continue
+		}
+		name := strings.TrimPrefix(res.Ref, "#/components/responses/")
+		resolved := s.Components.Responses[name]
+		if name == res.Ref || resolved == nil || resolved.Ref != "" {
+			return fmt.Errorf("unresolved reference %q", res.Ref)
+		}
+		op.Responses[status] = resolved
+	}
+	return nil
+}
+
+func (s *Spec) checkRefs(op *Operation) error {
+	var schemas []*Schema
+	if op.RequestBody != nil {
+		for _, mt := range op.RequestBody.Content {
+			schemas = append(schemas, mt.Schema)
+		}
+	}
+	for _, res := range op.Responses {
+		for _, mt := range res.Content {
+			schemas = append(schemas, mt.Schema)
+		}
+	}
+	for len(schemas) > 0 {
+		schema := schemas[len(schemas)-1]
+		schemas = schemas[:len(schemas)-1]
+		if schema == nil {
+			continue
+		}
+		if schema.Ref != "" {
+			if _, err := s.resolve(schema); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, prop := range schema.Properties {
+			schemas = append(schemas, prop)
+		}
+		schemas = append(schemas, schema.Items)
+	}
+	return nil
+}
+
+func (s *Spec) resolve(schema *Schema) (*Schema, error) {
+	if schema.Ref == "" {
+		return schema, nil
+	}
+	name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+	resolved := s.Components.Schemas[name]
+	if name == schema.Ref || resolved == nil {
+		return nil, fmt.Errorf("unresolved reference %q", schema.Ref)
+	}
+	if resolved.Ref != "" {
+		return nil, fmt.Errorf("nested reference %q", resolved.Ref)
+	}
+	return resolved, nil
+}
+
+// operation returns the operation documented for the given method and path.
+func (s *Spec) operation(method, path string) *Operation {
+	segments := strings.Split(path, "/")
+	for _, route := range s.routes {
+		if !matchSegments(route.segments, segments) {
+			continue
+		}
+		if method == http.MethodHead {
+			method = http.MethodGet
+		}
+		return (*route.item)[strings.ToLower(method)]
+	}
+	return nil
+}
+
+func matchSegments(template, segments []string) bool {
+	if len(template) != len(segments) {
+		return false
+	}
+	for i, seg := range template {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if seg != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks that value, as decoded by encoding/json, matches schema.
+func (s *Spec) validate(schema *Schema, value interface{}, name string) error {
+	schema, err := s.resolve(schema)
+	if err != nil {
+		return err
+	}
+
+	switch schema.Type {
+	case "":
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: should be an object", name)
+		}
+		for _, key := range schema.Required {
+			if _, ok := obj[key]; !ok {
+				return fmt.Errorf("%s.%s: is required", name, key)
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			prop, ok := schema.Properties[key]
+			if !ok {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					return fmt.Errorf("%s.%s: is not allowed", name, key)
+				}
+				continue
+			}
+			if err := s.validate(prop, obj[key], name+"."+key); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: should be an array", name)
+		}
+		if schema.Items == nil {
+			break
+		}
+		for i, item := range arr {
+			if err := s.validate(schema.Items, item, name+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: should be a string", name)
+		}
+		if len(schema.Enum) > 0 && !containsString(schema.Enum, str) {
+			return fmt.Errorf("%s: should be one of %s", name, strings.Join(schema.Enum, ", "))
+		}
+	case "integer":
+		num, ok := value.(float64)
+		if !ok || num != math.Trunc(num) {
+			return fmt.Errorf("%s: should be an integer", name)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: should be a number", name)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: should be a boolean", name)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported schema type %q", name, schema.Type)
+	}
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRequest checks that the body of r matches the request body of op.
+// The body is restored so that it can be read again by the handler.
+func (s *Spec) validateRequest(op *Operation, r *http.Request) error {
+	if op.RequestBody == nil || r.Body == nil {
+		return nil
+	}
+	mt := op.RequestBody.Content[jsonMediaType]
+	if mt == nil || mt.Schema == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBodySize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if len(body) > maxValidatedBodySize {
+		return nil
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			return errors.New("body: is required")
+		}
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("body: %w", err)
+	}
+
+	return s.validate(mt.Schema, value, "body")
+}
+
+// validateResponse checks that a response of the given status and body
+// matches the responses of op.
+func (s *Spec) validateResponse(op *Operation, status int, contentType string, body []byte) error {
+	res := op.Responses[strconv.Itoa(status)]
+	if res == nil {
+		res = op.Responses["default"]
+	}
+	if res == nil {
+		return fmt.Errorf("undocumented status %d", status)
+	}
+
+	mt := res.Content[jsonMediaType]
+	if mt == nil || mt.Schema == nil || !strings.HasPrefix(contentType, jsonMediaType) {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("body: %w", err)
+	}
+
+	return s.validate(mt.Schema, value, "body")
+}
+
+// SetSpec sets the specification the requests and responses are validated
+// against, as enabled by the config. Must be called before Start.
+func (s *Server) SetSpec(spec *Spec) {
+	s.spec = spec
+}
+
+// specRecorder keeps a copy of the response for it to be validated.
+type specRecorder struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (rec *specRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *specRecorder) Write(data []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if !rec.truncated {
+		if rec.body.Len()+len(data) > maxValidatedBodySize {
+			rec.truncated = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(data)
+		}
+	}
+	return rec.ResponseWriter.Write(data)
+}
+
+func (rec *specRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	// The connection is no longer an HTTP response.
+	rec.truncated = true
+	return h.Hijack()
+}
+
+func (rec *specRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// specHandler validates the requests and responses of the documented
+// operations against the specification. Invalid requests are rejected while
+// invalid responses, which are the sign of a drift between the
+// specification and the handlers, are logged.
+func (s *Server) specHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.spec == nil || (!s.cfg.ValidateRequests && !s.cfg.ValidateResponses) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		op := s.spec.operation(r.Method, r.URL.Path)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if s.cfg.ValidateRequests {
+			if err := s.spec.validateRequest(op, r); err != nil {
+				writeJSONError(w, http.StatusBadRequest, errorCodeBadRequest, "request validation failed: "+err.Error())
+				return
+			}
+		}
+
+		if !s.cfg.ValidateResponses {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &specRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.truncated || rec.status == 0 {
+			return
+		}
+		if err := s.spec.validateResponse(op, rec.status, w.Header().Get("Content-Type"), rec.body.Bytes()); err != nil {
+			s.log.Warn("api: response does not match the specification", mlog.String("method", r.Method),
+				mlog.String("path", r.URL.Path), mlog.Int("status", rec.status), mlog.Err(err))
+		}
+	})
+}
+
+// errorCodeBadRequest is the error code of malformed requests, as defined
+// by the service package.
+const errorCodeBadRequest = "BAD_REQUEST"
+
+// writeJSONError answers a request with an error, the same way the handlers
+// do.
+func writeJSONError(w http.ResponseWriter, code int, errCode, msg string) {
+	w.Header().Set("Content-Type", jsonMediaType)
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"error":     msg,
+		"errorCode": errCode,
+		"code":      strconv.Itoa(code),
+	})
+}
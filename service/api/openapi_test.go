@@ -0,0 +1,261 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/items": {
+      "post": {
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["name"],
+                "additionalProperties": false,
+                "properties": {
+                  "name": {"type": "string"},
+                  "kind": {"type": "string", "enum": ["a", "b"]}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created.",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Item"}}}
+          },
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/items/{id}": {
+      "get": {
+        "responses": {
+          "200": {
+            "description": "The item.",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Item"}}}
+          }
+        }
+      }
+    },
+    "/items/all": {
+      "get": {
+        "responses": {
+          "200": {
+            "description": "The items.",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Item"}}}}
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "responses": {
+      "Error": {
+        "description": "The request failed.",
+        "content": {"application/json": {"schema": {"type": "object", "required": ["error"]}}}
+      }
+    },
+    "schemas": {
+      "Item": {
+        "type": "object",
+        "required": ["id", "size"],
+        "properties": {
+          "id": {"type": "string"},
+          "size": {"type": "integer"},
+          "ratio": {"type": "number"},
+          "enabled": {"type": "boolean"}
+        }
+      }
+    }
+  }
+}`
+
+func TestParseSpec(t *testing.T) {
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParseSpec([]byte("{"))
+		require.Error(t, err)
+
+		_, err = ParseSpec([]byte(`{"openapi": "2.0"}`))
+		require.EqualError(t, err, `unsupported OpenAPI version "2.0"`)
+
+		_, err = ParseSpec([]byte(`{"openapi": "3.0.3", "paths": {"items": {}}}`))
+		require.EqualError(t, err, `invalid path "items"`)
+
+		_, err = ParseSpec([]byte(`{"openapi": "3.0.3", "paths": {"/items": {"get": {"responses": {"200": {"description": "",
+			"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Missing"}}}}}}}}}`))
+		require.EqualError(t, err, `invalid operation get /items: unresolved reference "#/components/schemas/Missing"`)
+
+		_, err = ParseSpec([]byte(`{"openapi": "3.0.3", "paths": {"/items": {"get": {"responses": {"default":
+			{"$ref": "#/components/responses/Missing"}}}}}}`))
+		require.EqualError(t, err, `invalid operation get /items: unresolved reference "#/components/responses/Missing"`)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		spec, err := ParseSpec([]byte(testSpec))
+		require.NoError(t, err)
+		require.Equal(t, testSpec, string(spec.Raw()))
+
+		require.True(t, spec.HasPath("/items"))
+		require.True(t, spec.HasPath("/items/"))
+		require.False(t, spec.HasPath("/other"))
+
+		require.NotNil(t, spec.operation(http.MethodPost, "/items"))
+		require.Nil(t, spec.operation(http.MethodGet, "/items"))
+		require.NotNil(t, spec.operation(http.MethodHead, "/items/id"))
+		require.Nil(t, spec.operation(http.MethodGet, "/items/"))
+		require.Nil(t, spec.operation(http.MethodGet, "/items/id/other"))
+
+		// Static paths take precedence over templated ones.
+		require.Equal(t, "array", spec.operation(http.MethodGet, "/items/all").Responses["200"].Content[jsonMediaType].Schema.Type)
+	})
+}
+
+func TestSpecValidate(t *testing.T) {
+	spec, err := ParseSpec([]byte(testSpec))
+	require.NoError(t, err)
+	item := &Schema{Ref: "#/components/schemas/Item"}
+
+	for _, tc := range []struct {
+		name   string
+		schema *Schema
+		value  string
+		err    string
+	}{
+		{name: "valid", schema: item, value: `{"id": "a", "size": 1, "ratio": 0.5, "enabled": true, "extra": null}`},
+		{name: "not an object", schema: item, value: `[]`, err: "body: should be an object"},
+		{name: "missing required", schema: item, value: `{"id": "a"}`, err: "body.size: is required"},
+		{name: "wrong string", schema: item, value: `{"id": 1, "size": 1}`, err: "body.id: should be a string"},
+		{name: "wrong integer", schema: item, value: `{"id": "a", "size": 1.5}`, err: "body.size: should be an integer"},
+		{name: "wrong number", schema: item, value: `{"id": "a", "size": 1, "ratio": "1"}`, err: "body.ratio: should be a number"},
+		{name: "wrong boolean", schema: item, value: `{"id": "a", "size": 1, "enabled": 1}`, err: "body.enabled: should be a boolean"},
+		{name: "valid array", schema: &Schema{Type: "array", Items: item}, value: `[{"id": "a", "size": 1}]`},
+		{name: "invalid array item", schema: &Schema{Type: "array", Items: item}, value: `[{"id": "a", "size": 1}, {}]`,
+			err: "body[1].id: is required"},
+		{name: "enum", schema: &Schema{Type: "string", Enum: []string{"a", "b"}}, value: `"c"`, err: "body: should be one of a, b"},
+		{name: "unsupported type", schema: &Schema{Type: "file"}, value: `""`, err: `body: unsupported schema type "file"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var value interface{}
+			require.NoError(t, json.Unmarshal([]byte(tc.value), &value))
+			err := spec.validate(tc.schema, value, "body")
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestSpecHandler(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		err := log.Shutdown()
+		require.NoError(t, err)
+	}()
+	var buf bytes.Buffer
+	err = mlog.AddWriterTarget(log, &buf, true, mlog.LvlWarn)
+	require.NoError(t, err)
+
+	spec, err := ParseSpec([]byte(testSpec))
+	require.NoError(t, err)
+
+	newServer := func(t *testing.T, cfg Config, res string) *Server {
+		t.Helper()
+		cfg.ListenAddress = ":0"
+		s, err := NewServer(cfg, log)
+		require.NoError(t, err)
+		s.SetSpec(spec)
+		s.RegisterHandleFunc("/items", func(w http.ResponseWriter, r *http.Request) {
+			var data map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+				writeJSONError(w, http.StatusBadRequest, errorCodeBadRequest, err.Error())
+				return
+			}
+			w.Header().Set("Content-Type", jsonMediaType)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(res))
+		})
+		return s
+	}
+
+	do := func(s *Server, body string) *httptest.ResponseRecorder {
+		buf.Reset()
+		w := httptest.NewRecorder()
+		s.srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(body)))
+		require.NoError(t, log.Flush())
+		return w
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		s := newServer(t, Config{}, `{}`)
+		w := do(s, `{"unknown": true}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.Empty(t, buf.String())
+	})
+
+	t.Run("requests", func(t *testing.T) {
+		s := newServer(t, Config{ValidateRequests: true}, `{"id": "a", "size": 1}`)
+
+		w := do(s, `{"name": "a", "kind": "b"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.JSONEq(t, `{"id": "a", "size": 1}`, w.Body.String())
+
+		w = do(s, `{"name": "a", "unknown": true}`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.JSONEq(t, `{"error": "request validation failed: body.unknown: is not allowed", "errorCode": "BAD_REQUEST", "code": "400"}`, w.Body.String())
+
+		w = do(s, `{"name": "a", "kind": "c"}`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = do(s, ``)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.JSONEq(t, `{"error": "request validation failed: body: is required", "errorCode": "BAD_REQUEST", "code": "400"}`, w.Body.String())
+
+		// Versioned paths are validated against the unversioned route.
+		buf.Reset()
+		w = httptest.NewRecorder()
+		s.srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, VersionPath(CurrentVersion, "/items"), strings.NewReader(`{}`)))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("responses", func(t *testing.T) {
+		s := newServer(t, Config{ValidateResponses: true}, `{"id": "a"}`)
+
+		// Responses are sent as is, but the drift gets logged.
+		w := do(s, `{"name": "a"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.JSONEq(t, `{"id": "a"}`, w.Body.String())
+		require.Contains(t, buf.String(), "api: response does not match the specification")
+		require.Contains(t, buf.String(), "body.size: is required")
+
+		// Errors are validated against the default response.
+		w = do(s, `invalid`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Empty(t, buf.String())
+
+		s = newServer(t, Config{ValidateResponses: true}, `{"id": "a", "size": 1}`)
+		w = do(s, `{"name": "a"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.Empty(t, buf.String())
+	})
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"net"
+
+	"github.com/mattermost/rtcd/service/fips"
+)
+
+type ServerOption func(s *Server) error
+
+// WithFIPSMode restricts the TLS configuration of the server to the
+// FIPS-approved cipher suites and curves.
+func WithFIPSMode() ServerOption {
+	return func(s *Server) error {
+		fips.RestrictTLSConfig(s.srv.TLSConfig)
+		return nil
+	}
+}
+
+// WithListener makes the server accept connections from the given listener
+// rather than listening on the configured address, e.g. to serve over an
+// in-memory transport in tests.
+func WithListener(l net.Listener) ServerOption {
+	return func(s *Server) error {
+		s.listener = l
+		return nil
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/mattermost/rtcd/service/crash"
+)
+
+// SetCrashReporter sets the reporter of the panics raised by the handlers,
+// which are then answered with an internal error. Without a reporter panics
+// are left to the HTTP server. Must be called before Start.
+func (s *Server) SetCrashReporter(reporter *crash.Reporter) {
+	s.crash = reporter
+}
+
+func (s *Server) recoverHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.crash != nil {
+			defer s.recoverRequest(w, r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) recoverRequest(w http.ResponseWriter, r *http.Request) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	// Used by handlers to abort the response, not a failure.
+	if v == http.ErrAbortHandler {
+		panic(v)
+	}
+
+	s.crash.Report("api "+r.URL.Path, v, debug.Stack())
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
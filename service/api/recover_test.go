@@ -0,0 +1,67 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattermost/rtcd/service/crash"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverHandler(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		err := log.Shutdown()
+		require.NoError(t, err)
+	}()
+	var buf bytes.Buffer
+	err = mlog.AddWriterTarget(log, &buf, true, mlog.LvlInfo, mlog.LvlError)
+	require.NoError(t, err)
+
+	s, err := NewServer(Config{ListenAddress: ":0", EnableAccessLog: true}, log)
+	require.NoError(t, err)
+	s.RegisterHandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("handler panic")
+	})
+	s.RegisterHandleFunc("/abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	t.Run("no reporter", func(t *testing.T) {
+		require.PanicsWithValue(t, "handler panic", func() {
+			s.srv.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+		})
+	})
+
+	reporter, err := crash.NewReporter(crash.Config{}, log, nil, nil)
+	require.NoError(t, err)
+	s.SetCrashReporter(reporter)
+
+	t.Run("recovered", func(t *testing.T) {
+		buf.Reset()
+		w := httptest.NewRecorder()
+		require.NotPanics(t, func() {
+			s.srv.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+		})
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+
+		require.NoError(t, log.Flush())
+		require.Contains(t, buf.String(), `"component":"api /panic"`)
+		require.Contains(t, buf.String(), `"panic":"handler panic"`)
+		require.Contains(t, buf.String(), `"status":500`)
+	})
+
+	t.Run("aborted", func(t *testing.T) {
+		require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			s.srv.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+		})
+	})
+}
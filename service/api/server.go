@@ -11,6 +11,8 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/mattermost/rtcd/service/crash"
+
 	"github.com/mattermost/mattermost-server/v6/shared/mlog"
 )
 
@@ -20,19 +22,32 @@ type Server struct {
 	srv      *http.Server
 	mux      *http.ServeMux
 	log      mlog.LoggerIFace
+	crash    *crash.Reporter
+	spec     *Spec
+	routes   []string
+	// acmeSrv answers the ACME HTTP-01 challenges, if enabled.
+	acmeSrv      *http.Server
+	acmeListener net.Listener
 }
 
-func NewServer(cfg Config, log mlog.LoggerIFace) (*Server, error) {
+func NewServer(cfg Config, log mlog.LoggerIFace, opts ...ServerOption) (*Server, error) {
 	if err := cfg.IsValid(); err != nil {
 		return nil, err
 	}
 	mux := http.NewServeMux()
 	s := &Server{
 		srv: &http.Server{
-			Addr:         cfg.ListenAddress,
-			ReadTimeout:  30 * time.Second,
-			WriteTimeout: 60 * time.Second,
-			IdleTimeout:  30 * time.Second,
+			Addr:              cfg.ListenAddress,
+			ReadTimeout:       secondsOr(cfg.ReadTimeoutSeconds, defaultReadTimeout),
+			ReadHeaderTimeout: secondsOr(cfg.ReadHeaderTimeoutSeconds, defaultReadHeaderTimeout),
+			WriteTimeout:      secondsOr(cfg.WriteTimeoutSeconds, defaultWriteTimeout),
+			IdleTimeout:       secondsOr(cfg.IdleTimeoutSeconds, defaultIdleTimeout),
+			MaxHeaderBytes:    kbOr(cfg.MaxHeaderSizeKB, defaultMaxHeaderSize),
+			// The connections are made available to the handlers so that
+			// the deadlines of long requests can be moved.
+			ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+				return context.WithValue(ctx, connCtxKey, c)
+			},
 			TLSConfig: &tls.Config{
 				MinVersion:               tls.VersionTLS12,
 				PreferServerCipherSuites: true,
@@ -40,29 +55,57 @@ func NewServer(cfg Config, log mlog.LoggerIFace) (*Server, error) {
 					tls.CurveP256,
 				},
 			},
-			Handler: mux,
 		},
 		log: log,
 		cfg: cfg,
 		mux: mux,
 	}
+	s.srv.Handler = s.recoverHandler(s.versionHandler(s.specHandler(mux)))
+	if cfg.EnableAccessLog {
+		s.srv.Handler = s.accessLogHandler(s.srv.Handler)
+	}
+
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, fmt.Errorf("failed to apply option: %w", err)
+		}
+	}
+
 	return s, nil
 }
 
 func (s *Server) Start() error {
-	var err error
-	s.listener, err = net.Listen("tcp", s.cfg.ListenAddress)
-	if err != nil {
-		return fmt.Errorf("failed to listen: %w", err)
+	if s.listener == nil {
+		var err error
+		s.listener, err = net.Listen("tcp", s.cfg.ListenAddress)
+		if err != nil {
+			return fmt.Errorf("failed to listen: %w", err)
+		}
 	}
 
 	s.log.Info("api: server is listening on " + s.listener.Addr().String())
 
+	acmeEnabled := s.cfg.TLS.Enable && s.cfg.TLS.ACME.Enable
+	tlsEnabled := acmeEnabled || (s.cfg.TLS.Enable && s.cfg.TLS.CertFile != "" && s.cfg.TLS.CertKey != "")
+	if acmeEnabled {
+		if err := s.startACME(); err != nil {
+			s.listener.Close()
+			return fmt.Errorf("failed to start acme: %w", err)
+		}
+	} else if tlsEnabled {
+		loader, err := newCertLoader(s.cfg.TLS.CertFile, s.cfg.TLS.CertKey)
+		if err != nil {
+			s.listener.Close()
+			return fmt.Errorf("failed to load certificate: %w", err)
+		}
+		s.srv.TLSConfig.GetCertificate = loader.getCertificate
+	}
+
 	go func() {
 		var err error
-		if s.cfg.TLS.Enable && s.cfg.TLS.CertFile != "" && s.cfg.TLS.CertKey != "" {
+		if tlsEnabled {
 			s.log.Debug("api: serving with tls")
-			err = s.srv.ServeTLS(s.listener, s.cfg.TLS.CertFile, s.cfg.TLS.CertKey)
+			err = s.srv.ServeTLS(s.listener, "", "")
 		} else {
 			s.log.Debug("api: serving plaintext")
 			err = s.srv.Serve(s.listener)
@@ -81,6 +124,11 @@ func (s *Server) Stop() error {
 	if err := s.srv.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown server: %w", err)
 	}
+	if s.acmeSrv != nil {
+		if err := s.acmeSrv.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to shutdown acme server: %w", err)
+		}
+	}
 	s.log.Info("api: server was shutdown")
 	return nil
 }
@@ -67,6 +67,33 @@ func TestStartServer(t *testing.T) {
 		require.Error(t, err)
 	})
 
+	t.Run("listener", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		cfg := Config{
+			// Not listened on.
+			ListenAddress: "127.0.0.1:1",
+		}
+		s, err := NewServer(cfg, log, WithListener(listener))
+		require.NoError(t, err)
+		require.NotNil(t, s)
+
+		err = s.Start()
+		require.NoError(t, err)
+		require.Equal(t, listener.Addr().String(), s.Addr())
+
+		client := &http.Client{}
+		_, err = client.Get("http://" + listener.Addr().String())
+		require.NoError(t, err)
+
+		err = s.Stop()
+		require.NoError(t, err)
+
+		_, err = client.Get("http://" + listener.Addr().String())
+		require.Error(t, err)
+	})
+
 	t.Run("tls", func(t *testing.T) {
 		cfg := Config{
 			ListenAddress: ":0",
@@ -99,4 +126,47 @@ func TestStartServer(t *testing.T) {
 		_, err = client.Get("https://localhost:" + port)
 		require.Error(t, err)
 	})
+	t.Run("tls fips mode", func(t *testing.T) {
+		cfg := Config{
+			ListenAddress: ":0",
+			TLS: TLSConfig{
+				Enable:   true,
+				CertFile: "../../testfiles/tls_test_cert.pem",
+				CertKey:  "../../testfiles/tls_test_key.pem",
+			},
+		}
+		s, err := NewServer(cfg, log, WithFIPSMode())
+		require.NoError(t, err)
+		require.NotNil(t, s)
+
+		err = s.Start()
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, s.Stop())
+		}()
+
+		_, port, err := net.SplitHostPort(s.listener.Addr().String())
+		require.NoError(t, err)
+
+		tr := &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+		client := &http.Client{Transport: tr}
+		resp, err := client.Get("https://localhost:" + port)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, uint16(tls.VersionTLS12), resp.TLS.Version)
+		require.Equal(t, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, resp.TLS.CipherSuite)
+
+		tr = &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+				MaxVersion:         tls.VersionTLS12,
+				CipherSuites:       []uint16{tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256},
+			},
+		}
+		client = &http.Client{Transport: tr}
+		_, err = client.Get("https://localhost:" + port)
+		require.Error(t, err)
+	})
 }
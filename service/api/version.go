@@ -0,0 +1,105 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// VersionHeader is the header through which clients request the highest
+	// version of the API they support. The server answers with the version
+	// the request was served with.
+	VersionHeader = "X-Api-Version"
+
+	// MinVersion is the oldest version of the API still served.
+	MinVersion = 1
+	// CurrentVersion is the latest version of the API.
+	CurrentVersion = 1
+)
+
+// RequestVersion returns the version of the API the given request is served
+// with.
+func RequestVersion(r *http.Request) int {
+	if v, ok := r.Context().Value(versionCtxKey).(int); ok {
+		return v
+	}
+	return MinVersion
+}
+
+// VersionPath returns the path of the given route under the given version.
+func VersionPath(version int, path string) string {
+	return fmt.Sprintf("/v%d%s", version, path)
+}
+
+// parseVersionPath splits a path of the form /v<version>/<route> into its
+// version and route.
+func parseVersionPath(path string) (int, string, bool) {
+	if !strings.HasPrefix(path, "/v") {
+		return 0, "", false
+	}
+	i := strings.IndexByte(path[1:], '/')
+	if i < 0 {
+		return 0, "", false
+	}
+	version, err := strconv.Atoi(path[2 : i+1])
+	if err != nil || version <= 0 {
+		return 0, "", false
+	}
+	return version, path[i+1:], true
+}
+
+// negotiateVersion returns the version to serve a request with, given the
+// highest one the client supports. Clients not sending any are served the
+// oldest version so that they keep working as the API evolves.
+func negotiateVersion(header string) (int, error) {
+	if header == "" {
+		return MinVersion, nil
+	}
+	requested, err := strconv.Atoi(header)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s header: %w", VersionHeader, err)
+	}
+	if requested < MinVersion {
+		return 0, fmt.Errorf("unsupported API version %d: should be at least %d", requested, MinVersion)
+	}
+	if requested > CurrentVersion {
+		return CurrentVersion, nil
+	}
+	return requested, nil
+}
+
+// versionHandler routes the requests made under a version prefix (e.g.
+// /v1/register) to the handler of the route, and negotiates the version of
+// the requests made to the unversioned paths through VersionHeader. The
+// version is made available to the handlers through RequestVersion.
+func (s *Server) versionHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, route, ok := parseVersionPath(r.URL.Path)
+		if ok {
+			if version < MinVersion || version > CurrentVersion {
+				http.NotFound(w, r)
+				return
+			}
+			r = r.Clone(r.Context())
+			r.URL.Path = route
+			r.URL.RawPath = ""
+		} else {
+			var err error
+			version, err = negotiateVersion(r.Header.Get(VersionHeader))
+			if err != nil {
+				w.Header().Set(VersionHeader, strconv.Itoa(CurrentVersion))
+				writeJSONError(w, http.StatusBadRequest, errorCodeBadRequest, err.Error())
+				return
+			}
+		}
+
+		w.Header().Set(VersionHeader, strconv.Itoa(version))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), versionCtxKey, version)))
+	})
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersionPath(t *testing.T) {
+	for _, tc := range []struct {
+		path    string
+		version int
+		route   string
+		ok      bool
+	}{
+		{path: "/v1/register", version: 1, route: "/register", ok: true},
+		{path: "/v2/admin/rtc/params", version: 2, route: "/admin/rtc/params", ok: true},
+		{path: "/v1/", version: 1, route: "/", ok: true},
+		{path: "/v1"},
+		{path: "/version"},
+		{path: "/vault/path"},
+		{path: "/v0/register"},
+		{path: "/register"},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			version, route, ok := parseVersionPath(tc.path)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.version, version)
+			require.Equal(t, tc.route, route)
+		})
+	}
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	version, err := negotiateVersion("")
+	require.NoError(t, err)
+	require.Equal(t, MinVersion, version)
+
+	version, err = negotiateVersion(strconv.Itoa(CurrentVersion))
+	require.NoError(t, err)
+	require.Equal(t, CurrentVersion, version)
+
+	// Clients newer than the server get the latest version it supports.
+	version, err = negotiateVersion(strconv.Itoa(CurrentVersion + 1))
+	require.NoError(t, err)
+	require.Equal(t, CurrentVersion, version)
+
+	_, err = negotiateVersion(strconv.Itoa(MinVersion - 1))
+	require.Error(t, err)
+
+	_, err = negotiateVersion("invalid")
+	require.Error(t, err)
+}
+
+func TestVersionHandler(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		err := log.Shutdown()
+		require.NoError(t, err)
+	}()
+
+	s, err := NewServer(Config{ListenAddress: ":0"}, log)
+	require.NoError(t, err)
+	s.RegisterHandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"path":    r.URL.Path,
+			"version": strconv.Itoa(RequestVersion(r)),
+		})
+	})
+	require.NoError(t, s.Start())
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	do := func(t *testing.T, path, version string) (*http.Response, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest("GET", "http://"+s.Addr()+path, nil)
+		require.NoError(t, err)
+		if version != "" {
+			req.Header.Set(VersionHeader, version)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		respData := map[string]string{}
+		_ = json.NewDecoder(resp.Body).Decode(&respData)
+		return resp, respData
+	}
+
+	t.Run("unversioned path", func(t *testing.T) {
+		resp, data := do(t, "/test", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, strconv.Itoa(MinVersion), resp.Header.Get(VersionHeader))
+		require.Equal(t, "/test", data["path"])
+		require.Equal(t, strconv.Itoa(MinVersion), data["version"])
+	})
+
+	t.Run("negotiated", func(t *testing.T) {
+		resp, data := do(t, "/test", strconv.Itoa(CurrentVersion+1))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, strconv.Itoa(CurrentVersion), resp.Header.Get(VersionHeader))
+		require.Equal(t, strconv.Itoa(CurrentVersion), data["version"])
+
+		resp, data = do(t, "/test", "invalid")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		require.NotEmpty(t, data["error"])
+		require.Equal(t, errorCodeBadRequest, data["errorCode"])
+	})
+
+	t.Run("versioned path", func(t *testing.T) {
+		resp, data := do(t, VersionPath(CurrentVersion, "/test"), "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, strconv.Itoa(CurrentVersion), resp.Header.Get(VersionHeader))
+		require.Equal(t, "/test", data["path"])
+		require.Equal(t, strconv.Itoa(CurrentVersion), data["version"])
+	})
+
+	t.Run("unsupported version path", func(t *testing.T) {
+		resp, _ := do(t, VersionPath(CurrentVersion+1, "/test"), "")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
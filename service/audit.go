@@ -6,32 +6,120 @@ package service
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+
+	"github.com/mattermost/rtcd/service/api"
+	"github.com/mattermost/rtcd/service/random"
 
 	"github.com/mattermost/mattermost-server/v6/shared/mlog"
 )
 
+const requestIDHeader = api.RequestIDHeader
+
+// decodeReqData decodes a JSON object from r into reqData, accepting
+// numbers and booleans as values alongside strings.
+func decodeReqData(r io.Reader, reqData map[string]string) error {
+	var values map[string]interface{}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&values); err != nil {
+		return err
+	}
+
+	for key, value := range values {
+		switch v := value.(type) {
+		case string:
+			reqData[key] = v
+		case json.Number:
+			reqData[key] = v.String()
+		case bool:
+			reqData[key] = strconv.FormatBool(v)
+		default:
+			return fmt.Errorf("invalid %s value: should be a string, a number or a boolean", key)
+		}
+	}
+
+	return nil
+}
+
+// auditedHandlers lists the API handlers whose requests are recorded in the
+// audit log.
+var auditedHandlers = map[string]bool{
+	"registerClient":       true,
+	"bootstrapClient":      true,
+	"unregisterClient":     true,
+	"loginClient":          true,
+	"handleUDPSockets":     true,
+	"handleStoreExport":    true,
+	"handleStoreImport":    true,
+	"handleRuntimeParams":  true,
+	"handleFeatures":       true,
+	"handleCapture":        true,
+	"handleSignalingTrace": true,
+	"handleRecording":      true,
+	"handleHLSStream":      true,
+	"handleAnnouncement":   true,
+	"handleKeyExport":      true,
+	"handleMaintenance":    true,
+	"handleBots":           true,
+	"handleMirrors":        true,
+	"handleCallObservers":  true,
+	"handleTestCall":       true,
+}
+
 type httpData struct {
-	err     string
-	code    int
+	err  string
+	code int
+	// errCode is the code of the error returned to the caller. If unset, it
+	// is derived from the HTTP status code.
+	errCode ErrorCode
 	reqData map[string]string
 	resData map[string]string
+	// actor is the identity of the authenticated caller, if any.
+	actor string
+	// idempotencyKey is the store key under which the result of the request
+	// is persisted, if it was made with an idempotency key.
+	idempotencyKey   string
+	idempotentResult idempotentResult
+	// auditFields are extra fields the handler records in the audit log.
+	auditFields []mlog.Field
 }
 
 func (s *Service) httpAudit(handler string, data *httpData, w http.ResponseWriter, r *http.Request) {
+	if data.idempotencyKey != "" {
+		s.storeIdempotentResult(data)
+	}
+	reqID := requestID(r.Header.Get(requestIDHeader))
 	fields := append(reqAuditFields(r), mlog.Int("code", data.code))
 	status := "fail"
 	if data.err == "" {
 		status = "success"
 	} else {
 		data.resData["error"] = data.err
+		if data.errCode == "" {
+			data.errCode = errorCodeForStatus(data.code)
+		}
+		data.resData["errorCode"] = string(data.errCode)
 		fields = append(fields, mlog.Err(fmt.Errorf("%s", data.err)))
 	}
 	if clientID := data.reqData["clientID"]; clientID != "" {
 		fields = append(fields, mlog.String("clientID", clientID))
+		api.SetClientID(r, clientID)
 	}
 	s.log.Debug(handler, append(fields, mlog.String("status", status))...)
+	if auditedHandlers[handler] {
+		auditFields := append([]mlog.Field{
+			mlog.Int("code", data.code),
+			mlog.String("remoteAddr", r.RemoteAddr),
+			mlog.String("clientID", data.reqData["clientID"]),
+			mlog.String("error", data.err),
+		}, data.auditFields...)
+		s.auditLog(handler, data.actor, reqID, status, auditFields...)
+	}
 	if w != nil {
+		w.Header().Set(requestIDHeader, reqID)
 		data.resData["code"] = fmt.Sprintf("%d", data.code)
 		w.Header().Add("Content-Type", "application/json")
 		w.WriteHeader(data.code)
@@ -53,3 +141,34 @@ func reqAuditFields(req *http.Request) []mlog.Field {
 	}
 	return fields
 }
+
+// auditLog writes an entry to the audit log. Entries are only persisted if
+// the audit log is enabled in the logger config.
+func (s *Service) auditLog(action, actor, reqID, status string, fields ...mlog.Field) {
+	if actor == "" {
+		actor = "anonymous"
+	}
+	s.log.Log(mlog.LvlAuditAPI, action, append([]mlog.Field{
+		mlog.String("actor", actor),
+		mlog.String("requestID", reqID),
+		mlog.String("status", status),
+	}, fields...)...)
+}
+
+// requestID returns the given caller provided request ID or a newly
+// generated one if empty.
+func requestID(id string) string {
+	if id == "" {
+		return random.NewID()
+	}
+	return id
+}
+
+// actorID returns the identity to record in the audit log for an
+// authenticated clientID, an empty one meaning admin.
+func actorID(clientID string) string {
+	if clientID == "" {
+		return "admin"
+	}
+	return clientID
+}
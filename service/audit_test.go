@@ -0,0 +1,94 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/random"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.Logger.EnableAudit = true
+	cfg.Logger.AuditFileLocation = filepath.Join(t.TempDir(), "audit.log")
+	th := SetupTestHelper(t, cfg)
+
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = th.adminClient.Register("clientA", authKey)
+	require.NoError(t, err)
+
+	c, err := NewClient(ClientConfig{
+		URL:      th.apiURL,
+		ClientID: "clientA",
+		AuthKey:  "invalid",
+	})
+	require.NoError(t, err)
+	err = c.Unregister("clientA")
+	require.Error(t, err)
+
+	req, err := http.NewRequest("GET", th.apiURL+"/admin/rtc/sockets", nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("", cfg.API.Security.AdminSecretKey)
+	req.Header.Set(requestIDHeader, "reqID")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "reqID", resp.Header.Get(requestIDHeader))
+
+	req, err = http.NewRequest("POST", th.apiURL+"/admin/rtc/test_call", nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Stopping the service flushes the logger.
+	th.Teardown()
+
+	f, err := os.Open(cfg.Logger.AuditFileLocation)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []map[string]any
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, entries, 4)
+
+	require.Equal(t, "registerClient", entries[0]["msg"])
+	require.Equal(t, "admin", entries[0]["actor"])
+	require.Equal(t, "clientA", entries[0]["clientID"])
+	require.Equal(t, "success", entries[0]["status"])
+	require.NotEmpty(t, entries[0]["requestID"])
+
+	require.Equal(t, "unregisterClient", entries[1]["msg"])
+	require.Equal(t, "anonymous", entries[1]["actor"])
+	require.Equal(t, "fail", entries[1]["status"])
+	require.True(t, strings.HasPrefix(entries[1]["error"].(string), "authentication failed"))
+
+	require.Equal(t, "handleUDPSockets", entries[2]["msg"])
+	require.Equal(t, "admin", entries[2]["actor"])
+	require.Equal(t, "reqID", entries[2]["requestID"])
+	require.Equal(t, "success", entries[2]["status"])
+
+	require.Equal(t, "handleTestCall", entries[3]["msg"])
+	require.Equal(t, "anonymous", entries[3]["actor"])
+	require.Equal(t, "fail", entries[3]["status"])
+}
@@ -7,13 +7,22 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/rtc"
 
 	"github.com/mattermost/mattermost-server/v6/shared/mlog"
 )
 
-const bearerPrefix = "Bearer "
+const (
+	bearerPrefix     = "Bearer "
+	signedAuthPrefix = auth.SignedAuthScheme + " "
+)
 
 func (s *Service) authHandler(w http.ResponseWriter, r *http.Request) (clientID string, code int, err error) {
 	defer func() {
@@ -31,10 +40,68 @@ func (s *Service) authHandler(w http.ResponseWriter, r *http.Request) (clientID
 		s.httpAudit("authHandler", data, nil, r)
 	}()
 
-	if strings.HasPrefix(r.Header.Get("Authorization"), bearerPrefix) {
-		return s.bearerAuthHandler(w, r)
+	claimedID := claimedClientID(r)
+	if retryAfter, err := s.checkAuthLockout(claimedID, r.RemoteAddr); err != nil {
+		if w != nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		}
+		return "", http.StatusTooManyRequests, err
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(authHeader, bearerPrefix):
+		clientID, code, err = s.bearerAuthHandler(w, r)
+	case strings.HasPrefix(authHeader, signedAuthPrefix):
+		clientID, code, err = s.signedAuthHandler(w, r)
+	default:
+		clientID, code, err = s.basicAuthHandler(w, r)
+	}
+
+	if err != nil && code == http.StatusUnauthorized {
+		s.recordAuthFailure(claimedID, r.RemoteAddr, requestID(r.Header.Get(requestIDHeader)))
+	} else if err == nil {
+		s.resetAuthFailures(clientID, r.RemoteAddr)
+	}
+
+	return clientID, code, err
+}
+
+// claimedClientID returns the client the credentials of r claim to belong
+// to, if any, for the failed attempts to be tracked against it.
+func claimedClientID(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if strings.HasPrefix(authHeader, signedAuthPrefix) {
+		if creds, err := auth.ParseSignedCredentials(strings.TrimPrefix(authHeader, signedAuthPrefix)); err == nil {
+			return creds.ClientID
+		}
+		return ""
+	}
+	clientID, _, _ := r.BasicAuth()
+	return clientID
+}
+
+// wsAuthHandler authenticates the signaling connections. Unlike the other
+// requests, bare auth keys can be rejected in favor of signed credentials.
+func (s *Service) wsAuthHandler(w http.ResponseWriter, r *http.Request) (string, int, error) {
+	if err := s.checkSignedAuthRequired(r); err != nil {
+		return "", http.StatusUnauthorized, err
 	}
-	return s.basicAuthHandler(w, r)
+	return s.authHandler(w, r)
+}
+
+// checkSignedAuthRequired returns an error if signed credentials are
+// required and r carries the bare auth key of a client. The admin secret key
+// is still accepted.
+func (s *Service) checkSignedAuthRequired(r *http.Request) error {
+	if !s.cfg.API.Security.SignedAuth.Require {
+		return nil
+	}
+	_, authKey, ok := r.BasicAuth()
+	if !ok || (s.cfg.API.Security.EnableAdmin && authKey == s.getAdminSecretKey()) {
+		return nil
+	}
+	return errors.New("authentication failed: signed credentials are required")
 }
 
 func (s *Service) basicAuthHandler(w http.ResponseWriter, r *http.Request) (string, int, error) {
@@ -43,7 +110,7 @@ func (s *Service) basicAuthHandler(w http.ResponseWriter, r *http.Request) (stri
 		return "", http.StatusUnauthorized, errors.New("authentication failed: invalid auth header")
 	}
 
-	if s.cfg.API.Security.EnableAdmin && authKey == s.cfg.API.Security.AdminSecretKey {
+	if s.cfg.API.Security.EnableAdmin && authKey == s.getAdminSecretKey() {
 		return "", http.StatusOK, nil
 	}
 
@@ -77,6 +144,83 @@ func (s *Service) bearerAuthHandler(w http.ResponseWriter, r *http.Request) (str
 	return session.ClientID, http.StatusOK, nil
 }
 
+func (s *Service) signedAuthHandler(w http.ResponseWriter, r *http.Request) (string, int, error) {
+	creds, err := auth.ParseSignedCredentials(strings.TrimPrefix(r.Header.Get("Authorization"), signedAuthPrefix))
+	if err != nil {
+		return "", http.StatusUnauthorized, fmt.Errorf("authentication failed: %w", err)
+	}
+
+	if err := s.authenticateSigned(creds); err != nil {
+		s.log.Error("authentication failed", mlog.Err(err), mlog.String("clientID", creds.ClientID))
+		return "", http.StatusUnauthorized, errors.New("authentication failed")
+	}
+
+	return creds.ClientID, http.StatusOK, nil
+}
+
+func (s *Service) authenticateSigned(creds auth.SignedCredentials) error {
+	if !s.cfg.API.Security.SignedAuth.Enable {
+		return errors.New("signed credentials are disabled")
+	}
+	maxSkew := time.Duration(s.cfg.API.Security.SignedAuth.MaxClockSkewSeconds) * time.Second
+	return s.auth.AuthenticateSigned(creds, maxSkew)
+}
+
+// providerAuthHandler validates the credentials of a client registering
+// under clientID with the registration auth provider. Admin credentials are
+// accepted as well.
+func (s *Service) providerAuthHandler(w http.ResponseWriter, r *http.Request, clientID string) (string, int, error) {
+	username, password, isBasic := r.BasicAuth()
+	if isBasic && s.cfg.API.Security.EnableAdmin && password == s.getAdminSecretKey() {
+		return "", http.StatusOK, nil
+	}
+
+	if clientID == "" {
+		return "", http.StatusBadRequest, errors.New("registration failed: invalid empty client id")
+	}
+
+	if retryAfter, err := s.checkAuthLockout(clientID, r.RemoteAddr); err != nil {
+		if w != nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		}
+		return "", http.StatusTooManyRequests, err
+	}
+
+	var creds auth.Credentials
+	if isBasic {
+		creds.Username = username
+		creds.Password = password
+	} else if token, ok := parseBearerAuth(r.Header.Get("Authorization")); ok {
+		creds.Token = token
+	}
+
+	err := s.authProvider.Validate(r.Context(), clientID, creds)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		s.log.Error("authentication failed", mlog.Err(err), mlog.String("clientID", clientID))
+		s.recordAuthFailure(clientID, r.RemoteAddr, requestID(r.Header.Get(requestIDHeader)))
+		return "", http.StatusUnauthorized, errors.New("authentication failed")
+	} else if err != nil {
+		s.log.Error("failed to validate credentials", mlog.Err(err), mlog.String("clientID", clientID))
+		return "", http.StatusServiceUnavailable, errors.New("authentication failed: provider unavailable")
+	}
+	s.resetAuthFailures(clientID, r.RemoteAddr)
+
+	return clientID, http.StatusOK, nil
+}
+
+// registrationAuthHandler authenticates a request registering clientID when
+// self registration isn't allowed: with the registration auth provider if
+// one is configured, else with admin or registered client credentials.
+func (s *Service) registrationAuthHandler(w http.ResponseWriter, r *http.Request, clientID string) (string, int, error) {
+	if s.authProvider != nil {
+		return s.providerAuthHandler(w, r, clientID)
+	}
+	if err := s.checkSignedAuthRequired(r); err != nil {
+		return "", http.StatusUnauthorized, err
+	}
+	return s.authHandler(w, r)
+}
+
 func parseBearerAuth(auth string) (token string, ok bool) {
 	if len(auth) < len(bearerPrefix) || !strings.EqualFold(auth[:len(bearerPrefix)], bearerPrefix) {
 		return
@@ -96,33 +240,94 @@ func (s *Service) registerClient(w http.ResponseWriter, r *http.Request) {
 	}
 	defer s.httpAudit("registerClient", data, w, r)
 
+	if s.checkIdempotencyKey("registerClient", data, w, r) {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	clientID := data.reqData["clientID"]
+	authKey := data.reqData["authKey"]
+
+	// With a provider, the credentials are validated against the ID the
+	// client registers under, hence once the body is decoded.
 	if !s.cfg.API.Security.AllowSelfRegistration {
-		_, code, err := s.authHandler(w, r)
+		actor, code, err := s.registrationAuthHandler(w, r, clientID)
 		if err != nil {
 			data.err = err.Error()
 			data.code = code
 			return
 		}
+		data.actor = actorID(actor)
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+	err := s.auth.Register(clientID, authKey)
+	if err != nil {
 		data.err = err.Error()
 		data.code = http.StatusBadRequest
 		return
 	}
 
-	clientID := data.reqData["clientID"]
-	authKey := data.reqData["authKey"]
-	err := s.auth.Register(clientID, authKey)
+	s.log.Debug("registered new client", mlog.String("clientID", clientID))
+	data.code = http.StatusCreated
+	data.resData["clientID"] = clientID
+}
+
+// bootstrapClient registers the client a bootstrap token was minted for and
+// returns its newly generated auth key. Unlike registerClient it doesn't
+// require admin credentials, the token being signed with the admin secret
+// key instead.
+func (s *Service) bootstrapClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("bootstrapClient", data, w, r)
+
+	if !s.cfg.API.Security.AllowBootstrapTokens {
+		data.err = "bootstrap tokens not enabled"
+		data.code = http.StatusForbidden
+		return
+	}
+
+	if retryAfter, err := s.checkAuthLockout("", r.RemoteAddr); err != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		data.err = err.Error()
+		data.code = http.StatusTooManyRequests
+		return
+	}
+
+	// The token is kept out of reqData so that it doesn't get logged.
+	var reqData map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	clientID, authKey, err := s.auth.RegisterWithBootstrapToken(s.getAdminSecretKey(), reqData["token"])
 	if err != nil {
+		s.recordAuthFailure("", r.RemoteAddr, requestID(r.Header.Get(requestIDHeader)))
 		data.err = err.Error()
 		data.code = http.StatusBadRequest
 		return
 	}
+	data.actor = actorID(clientID)
+	data.reqData["clientID"] = clientID
 
-	s.log.Debug("registered new client", mlog.String("clientID", clientID))
+	s.log.Debug("registered new client through bootstrap token", mlog.String("clientID", clientID))
 	data.code = http.StatusCreated
 	data.resData["clientID"] = clientID
+	data.resData["authKey"] = authKey
 }
 
 func (s *Service) unregisterClient(w http.ResponseWriter, r *http.Request) {
@@ -153,6 +358,11 @@ func (s *Service) unregisterClient(w http.ResponseWriter, r *http.Request) {
 		data.code = code
 		return
 	}
+	data.actor = actorID(authedClientID)
+
+	if s.checkIdempotencyKey("unregisterClient", data, w, r) {
+		return
+	}
 
 	if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
 		data.err = err.Error()
@@ -206,14 +416,133 @@ func (s *Service) loginClient(w http.ResponseWriter, r *http.Request) {
 
 	clientID := data.reqData["clientID"]
 	authKey := data.reqData["authKey"]
+	data.actor = clientID
+
+	// The bearer tokens being accepted by the signaling connection, they
+	// can't be exchanged for a bare auth key when signed credentials are
+	// required.
+	if s.cfg.API.Security.SignedAuth.Require {
+		data.err = "login failed: signed credentials are required"
+		data.code = http.StatusUnauthorized
+		return
+	}
+
+	if retryAfter, err := s.checkAuthLockout(clientID, r.RemoteAddr); err != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		data.err = err.Error()
+		data.code = http.StatusTooManyRequests
+		return
+	}
 	bearerToken, err := s.auth.Login(clientID, authKey)
 	if err != nil {
+		s.recordAuthFailure(clientID, r.RemoteAddr, requestID(r.Header.Get(requestIDHeader)))
 		data.err = err.Error()
 		data.code = http.StatusBadRequest
 		return
 	}
+	s.resetAuthFailures(clientID, r.RemoteAddr)
 
 	s.log.Debug("logged in client", mlog.String("clientID", clientID))
 	data.code = http.StatusOK
 	data.resData["bearerToken"] = bearerToken
 }
+
+// getJoinToken issues a token allowing the given session to join the given
+// call of a group. Tokens are issued with admin credentials only, for the
+// sessions joining on a client's connection to be authorized by another
+// principal than the client itself.
+func (s *Service) getJoinToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("getJoinToken", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	groupID := data.reqData["groupID"]
+	if groupID == "" {
+		data.err = "missing groupID"
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	expiresAt := time.Now().Add(time.Duration(s.cfg.API.Security.JoinTokens.ExpirationMinutes) * time.Minute)
+	token, err := s.auth.NewJoinToken(groupID, data.reqData["callID"], data.reqData["sessionID"], expiresAt)
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	data.code = http.StatusOK
+	data.resData["token"] = token
+	data.resData["expiresAt"] = fmt.Sprintf("%d", expiresAt.UnixMilli())
+}
+
+func (s *Service) getTURNCredentials(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("getTURNCredentials", data, w, r)
+
+	clientID, code, err := s.authHandler(w, r)
+	if err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+
+	// Credentials are tied to the requesting client so that the TURN
+	// traffic can be attributed.
+	if clientID == "" {
+		data.err = "client id should not be empty"
+		data.code = http.StatusForbidden
+		return
+	}
+	data.reqData["clientID"] = clientID
+
+	servers, expiresAt, err := s.rtcServer.GenICEServers(clientID)
+	if errors.Is(err, rtc.ErrTURNCredentialsDisabled) {
+		data.err = err.Error()
+		data.code = http.StatusNotFound
+		return
+	} else if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	js, err := json.Marshal(servers)
+	if err != nil {
+		data.err = "failed to marshal ICE servers: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	data.code = http.StatusOK
+	data.resData["iceServers"] = string(js)
+	data.resData["expiresAt"] = fmt.Sprintf("%d", expiresAt.UnixMilli())
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/rtcd/service/store"
+)
+
+const (
+	bootstrapTokenContext = "rtcd bootstrap token"
+	// usedBootstrapTokenKeyPrefix is the prefix of the store keys marking
+	// the bootstrap tokens already used, until they expire.
+	usedBootstrapTokenKeyPrefix = "rtcd:bootstrap_token:"
+)
+
+// BootstrapTokenClaims holds the content of a bootstrap token. A token
+// allows registering the given client once, until it expires.
+type BootstrapTokenClaims struct {
+	ClientID  string `json:"client_id"`
+	ExpiresAt int64  `json:"exp"`
+	// Nonce identifies the token so that it can only be used once.
+	Nonce string `json:"nonce"`
+}
+
+// NewBootstrapToken mints a token allowing clientID to register itself
+// once, until expiresAt. It's signed with a key derived from the admin secret
+// key so it can be minted offline, without access to the service.
+func NewBootstrapToken(adminSecretKey, clientID string, expiresAt time.Time) (string, error) {
+	if adminSecretKey == "" {
+		return "", errors.New("invalid empty admin secret key")
+	}
+	if clientID == "" {
+		return "", errors.New("invalid empty client id")
+	}
+
+	nonce, err := newRandomString(nonceLen)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	payload, err := json.Marshal(BootstrapTokenClaims{
+		ClientID:  clientID,
+		ExpiresAt: expiresAt.Unix(),
+		Nonce:     nonce,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal claims: %w", err)
+	}
+
+	encPayload := base64.RawURLEncoding.EncodeToString(payload)
+	return encPayload + "." + signBootstrapToken(adminSecretKey, encPayload), nil
+}
+
+// ParseBootstrapToken verifies that token was signed with the given admin
+// secret key and hasn't expired, and returns its claims.
+func ParseBootstrapToken(adminSecretKey, token string, now time.Time) (BootstrapTokenClaims, error) {
+	if adminSecretKey == "" {
+		return BootstrapTokenClaims{}, errors.New("invalid bootstrap token: no admin secret key")
+	}
+
+	encPayload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return BootstrapTokenClaims{}, errors.New("invalid bootstrap token: malformed")
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(signBootstrapToken(adminSecretKey, encPayload))) {
+		return BootstrapTokenClaims{}, errors.New("invalid bootstrap token: bad signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return BootstrapTokenClaims{}, fmt.Errorf("invalid bootstrap token: %w", err)
+	}
+
+	var claims BootstrapTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return BootstrapTokenClaims{}, fmt.Errorf("invalid bootstrap token: %w", err)
+	}
+
+	if claims.ClientID == "" || claims.Nonce == "" {
+		return BootstrapTokenClaims{}, errors.New("invalid bootstrap token: missing fields")
+	}
+	if len(claims.Nonce) > maxNonceLen {
+		return BootstrapTokenClaims{}, errors.New("invalid bootstrap token: nonce is too long")
+	}
+
+	if now.Unix() > claims.ExpiresAt {
+		return BootstrapTokenClaims{}, errors.New("invalid bootstrap token: expired")
+	}
+
+	return claims, nil
+}
+
+// RegisterWithBootstrapToken registers the client the given token was minted
+// for, with a newly generated auth key which is returned along with its ID.
+// Each token can only be used once.
+func (s *Service) RegisterWithBootstrapToken(adminSecretKey, token string) (string, string, error) {
+	now := time.Now()
+	claims, err := ParseBootstrapToken(adminSecretKey, token, now)
+	if err != nil {
+		return "", "", fmt.Errorf("registration failed: %w", err)
+	}
+
+	s.removeUsedBootstrapTokens(now)
+
+	usedKey := usedBootstrapTokenKeyPrefix + claims.Nonce
+	if err := s.store.Put(usedKey, strconv.FormatInt(claims.ExpiresAt, 10)); errors.Is(err, store.ErrConflict) {
+		return "", "", errors.New("registration failed: bootstrap token was already used")
+	} else if err != nil {
+		return "", "", fmt.Errorf("registration failed: %w", err)
+	}
+
+	authKey, err := newRandomString(MinKeyLen)
+	if err != nil {
+		_ = s.store.Delete(usedKey)
+		return "", "", fmt.Errorf("registration failed: %w", err)
+	}
+
+	if err := s.Register(claims.ClientID, authKey); err != nil {
+		// The token can be used again once the cause is addressed (e.g.
+		// the client got unregistered).
+		_ = s.store.Delete(usedKey)
+		return "", "", err
+	}
+
+	return claims.ClientID, authKey, nil
+}
+
+// removeUsedBootstrapTokens deletes the marks of the used bootstrap tokens
+// that have expired since, as they can't be used anymore regardless.
+func (s *Service) removeUsedBootstrapTokens(now time.Time) {
+	keys, err := s.store.Keys()
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, usedBootstrapTokenKeyPrefix) {
+			continue
+		}
+		val, err := s.store.Get(key)
+		if err != nil {
+			continue
+		}
+		if expiresAt, err := strconv.ParseInt(val, 10, 64); err == nil && now.Unix() <= expiresAt {
+			continue
+		}
+		_ = s.store.Delete(key)
+	}
+}
+
+func signBootstrapToken(adminSecretKey, encPayload string) string {
+	key := hmac.New(sha256.New, []byte(adminSecretKey))
+	key.Write([]byte(bootstrapTokenContext))
+	h := hmac.New(sha256.New, key.Sum(nil))
+	h.Write([]byte(encPayload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
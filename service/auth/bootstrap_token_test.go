@@ -0,0 +1,130 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBootstrapToken(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+
+	t.Run("invalid args", func(t *testing.T) {
+		token, err := NewBootstrapToken("", "clientID", expiresAt)
+		require.EqualError(t, err, "invalid empty admin secret key")
+		require.Empty(t, token)
+
+		token, err = NewBootstrapToken("secret", "", expiresAt)
+		require.EqualError(t, err, "invalid empty client id")
+		require.Empty(t, token)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		token, err := NewBootstrapToken("secret", "clientID", expiresAt)
+		require.NoError(t, err)
+
+		claims, err := ParseBootstrapToken("secret", token, now)
+		require.NoError(t, err)
+		require.Equal(t, "clientID", claims.ClientID)
+		require.Equal(t, expiresAt.Unix(), claims.ExpiresAt)
+		require.NotEmpty(t, claims.Nonce)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		token, err := NewBootstrapToken("secret", "clientID", expiresAt)
+		require.NoError(t, err)
+
+		_, err = ParseBootstrapToken("other", token, now)
+		require.EqualError(t, err, "invalid bootstrap token: bad signature")
+		_, err = ParseBootstrapToken("", token, now)
+		require.EqualError(t, err, "invalid bootstrap token: no admin secret key")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		token, err := NewBootstrapToken("secret", "clientID", expiresAt)
+		require.NoError(t, err)
+
+		_, err = ParseBootstrapToken("secret", token, expiresAt.Add(time.Second))
+		require.EqualError(t, err, "invalid bootstrap token: expired")
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		_, err := ParseBootstrapToken("secret", "", now)
+		require.EqualError(t, err, "invalid bootstrap token: malformed")
+
+		token, err := NewBootstrapToken("secret", "clientID", expiresAt)
+		require.NoError(t, err)
+		other, err := NewBootstrapToken("secret", "clientB", expiresAt)
+		require.NoError(t, err)
+		payload, _, _ := strings.Cut(other, ".")
+		_, sig, _ := strings.Cut(token, ".")
+		_, err = ParseBootstrapToken("secret", payload+"."+sig, now)
+		require.EqualError(t, err, "invalid bootstrap token: bad signature")
+	})
+}
+
+func TestRegisterWithBootstrapToken(t *testing.T) {
+	dbStore, teardown := newTestDBStore(t)
+	defer teardown()
+	sessionCache := newTestSessionCache(t)
+
+	authSrvc, err := NewService(dbStore, sessionCache)
+	require.NoError(t, err)
+
+	t.Run("invalid token", func(t *testing.T) {
+		token, err := NewBootstrapToken("other", "clientA", time.Now().Add(time.Hour))
+		require.NoError(t, err)
+
+		_, _, err = authSrvc.RegisterWithBootstrapToken("secret", token)
+		require.EqualError(t, err, "registration failed: invalid bootstrap token: bad signature")
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		token, err := NewBootstrapToken("secret", "clientA", time.Now().Add(time.Hour))
+		require.NoError(t, err)
+
+		clientID, authKey, err := authSrvc.RegisterWithBootstrapToken("secret", token)
+		require.NoError(t, err)
+		require.Equal(t, "clientA", clientID)
+		require.Len(t, authKey, MinKeyLen)
+		require.NoError(t, authSrvc.Authenticate(clientID, authKey))
+
+		// Tokens can only be used once.
+		require.NoError(t, authSrvc.Unregister(clientID))
+		_, _, err = authSrvc.RegisterWithBootstrapToken("secret", token)
+		require.EqualError(t, err, "registration failed: bootstrap token was already used")
+	})
+
+	t.Run("already registered", func(t *testing.T) {
+		require.NoError(t, authSrvc.Register("clientB", "Ey4-H_BJA00_TVByPi8DozE12ekN3S7L"))
+
+		token, err := NewBootstrapToken("secret", "clientB", time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		_, _, err = authSrvc.RegisterWithBootstrapToken("secret", token)
+		require.EqualError(t, err, "registration failed: already registered")
+
+		// The token is still usable once the client got unregistered.
+		require.NoError(t, authSrvc.Unregister("clientB"))
+		clientID, _, err := authSrvc.RegisterWithBootstrapToken("secret", token)
+		require.NoError(t, err)
+		require.Equal(t, "clientB", clientID)
+	})
+
+	t.Run("expired marks removal", func(t *testing.T) {
+		require.NoError(t, dbStore.Set(usedBootstrapTokenKeyPrefix+"expired", "1"))
+		require.NoError(t, dbStore.Set(usedBootstrapTokenKeyPrefix+"valid", "9999999999"))
+
+		authSrvc.removeUsedBootstrapTokens(time.Now())
+
+		_, err := dbStore.Get(usedBootstrapTokenKeyPrefix + "expired")
+		require.Error(t, err)
+		_, err = dbStore.Get(usedBootstrapTokenKeyPrefix + "valid")
+		require.NoError(t, err)
+	})
+}
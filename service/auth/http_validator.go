@@ -0,0 +1,104 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type HTTPValidatorConfig struct {
+	// The URL the credentials of the registering clients are posted to. A
+	// 2xx response accepts the client, a 401 or 403 rejects it.
+	URL string `toml:"url"`
+	// The optional token sent along with the requests, as a bearer token,
+	// authenticating rtcd to the validator.
+	BearerToken string `toml:"bearer_token"`
+}
+
+func (c HTTPValidatorConfig) IsValid() error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL value: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("invalid URL value: should use the http or https scheme")
+	}
+	if u.Host == "" {
+		return errors.New("invalid URL value: should contain a host")
+	}
+	return nil
+}
+
+// httpValidatorRequest is the payload posted to the validator.
+type httpValidatorRequest struct {
+	ClientID string `json:"clientID"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// httpValidator delegates the validation of clients to an external HTTP
+// endpoint.
+type httpValidator struct {
+	cfg    HTTPValidatorConfig
+	client *http.Client
+}
+
+func newHTTPValidator(cfg HTTPValidatorConfig, timeout time.Duration) *httpValidator {
+	return &httpValidator{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (v *httpValidator) Validate(ctx context.Context, clientID string, creds Credentials) error {
+	if creds == (Credentials{}) {
+		return fmt.Errorf("%w: credentials are required", ErrInvalidCredentials)
+	}
+
+	data, err := json.Marshal(httpValidatorRequest{
+		ClientID: clientID,
+		Username: creds.Username,
+		Password: creds.Password,
+		Token:    creds.Token,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.cfg.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if v.cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+v.cfg.BearerToken)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return nil
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: rejected by validator", ErrInvalidCredentials)
+	default:
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+}
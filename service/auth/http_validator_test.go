@@ -0,0 +1,70 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPValidator(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer rtcd-token" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var req httpValidatorRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch {
+		case req.ClientID == "unavailable":
+			w.WriteHeader(http.StatusBadGateway)
+		case req.ClientID == "alice" && req.Username == "alice" && req.Password == "secret",
+			req.ClientID == "bob" && req.Token == "bob-token":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ts.Close()
+
+	v := newHTTPValidator(HTTPValidatorConfig{
+		URL:         ts.URL,
+		BearerToken: "rtcd-token",
+	}, 5*time.Second)
+
+	t.Run("valid", func(t *testing.T) {
+		require.NoError(t, v.Validate(context.Background(), "alice", Credentials{Username: "alice", Password: "secret"}))
+		require.NoError(t, v.Validate(context.Background(), "bob", Credentials{Token: "bob-token"}))
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		err := v.Validate(context.Background(), "alice", Credentials{Username: "alice", Password: "wrong"})
+		require.True(t, errors.Is(err, ErrInvalidCredentials))
+
+		err = v.Validate(context.Background(), "bob", Credentials{Token: "alice-token"})
+		require.True(t, errors.Is(err, ErrInvalidCredentials))
+	})
+
+	t.Run("no credentials", func(t *testing.T) {
+		err := v.Validate(context.Background(), "alice", Credentials{})
+		require.True(t, errors.Is(err, ErrInvalidCredentials))
+	})
+
+	t.Run("validator error", func(t *testing.T) {
+		err := v.Validate(context.Background(), "unavailable", Credentials{Token: "token"})
+		require.Error(t, err)
+		require.False(t, errors.Is(err, ErrInvalidCredentials))
+		require.Equal(t, "unexpected status code 502", err.Error())
+	})
+}
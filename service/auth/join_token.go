@@ -0,0 +1,138 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type JoinTokenConfig struct {
+	// Whether or not sessions are required to present a join token
+	// when joining a call, and again when reconnecting. Tokens are issued
+	// with admin credentials.
+	Enable bool `toml:"enable"`
+	// The expiration, in minutes, of newly issued join tokens.
+	ExpirationMinutes int `toml:"expiration_minutes"`
+}
+
+func (c JoinTokenConfig) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.ExpirationMinutes <= 0 {
+		return errors.New("invalid ExpirationMinutes value: should be a positive number")
+	}
+	return nil
+}
+
+// JoinTokenClaims holds the scope of a join token. A token is only valid
+// for the client, call and session it was issued for.
+type JoinTokenClaims struct {
+	ClientID  string `json:"client_id"`
+	CallID    string `json:"call_id"`
+	SessionID string `json:"session_id"`
+	ExpiresAt int64  `json:"exp"`
+	// Observer is set for the tokens issued by admins for the session to
+	// join as an observer. They can't be used to join as a participant.
+	Observer bool `json:"observer,omitempty"`
+}
+
+// NewJoinToken issues a signed token allowing the given session to join
+// the given call on behalf of clientID.
+func (s *Service) NewJoinToken(clientID, callID, sessionID string, expiresAt time.Time) (string, error) {
+	return s.newJoinToken(clientID, callID, sessionID, expiresAt, false)
+}
+
+// NewObserverToken issues a signed token allowing the given session to
+// join the given call of clientID as an observer.
+func (s *Service) NewObserverToken(clientID, callID, sessionID string, expiresAt time.Time) (string, error) {
+	return s.newJoinToken(clientID, callID, sessionID, expiresAt, true)
+}
+
+func (s *Service) newJoinToken(clientID, callID, sessionID string, expiresAt time.Time, observer bool) (string, error) {
+	if clientID == "" {
+		return "", errors.New("invalid empty client id")
+	}
+	if callID == "" {
+		return "", errors.New("invalid empty call id")
+	}
+	if sessionID == "" {
+		return "", errors.New("invalid empty session id")
+	}
+
+	payload, err := json.Marshal(JoinTokenClaims{
+		ClientID:  clientID,
+		CallID:    callID,
+		SessionID: sessionID,
+		ExpiresAt: expiresAt.Unix(),
+		Observer:  observer,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal claims: %w", err)
+	}
+
+	encPayload := base64.RawURLEncoding.EncodeToString(payload)
+	return encPayload + "." + s.signJoinToken(encPayload), nil
+}
+
+// ValidateJoinToken verifies that token was issued by this service for the
+// given client, call and session and that it hasn't expired.
+func (s *Service) ValidateJoinToken(token, clientID, callID, sessionID string) error {
+	return s.validateJoinToken(token, clientID, callID, sessionID, false)
+}
+
+// ValidateObserverToken verifies that token was issued by this service for
+// the given session to observe the given call of clientID, and that it
+// hasn't expired.
+func (s *Service) ValidateObserverToken(token, clientID, callID, sessionID string) error {
+	return s.validateJoinToken(token, clientID, callID, sessionID, true)
+}
+
+func (s *Service) validateJoinToken(token, clientID, callID, sessionID string, observer bool) error {
+	encPayload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return errors.New("invalid join token: malformed")
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(s.signJoinToken(encPayload))) {
+		return errors.New("invalid join token: bad signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return fmt.Errorf("invalid join token: %w", err)
+	}
+
+	var claims JoinTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return fmt.Errorf("invalid join token: %w", err)
+	}
+
+	if claims.ClientID != clientID || claims.CallID != callID || claims.SessionID != sessionID {
+		return errors.New("invalid join token: scope mismatch")
+	}
+
+	if claims.Observer != observer {
+		return errors.New("invalid join token: role mismatch")
+	}
+
+	if time.Now().Unix() > claims.ExpiresAt {
+		return errors.New("invalid join token: expired")
+	}
+
+	return nil
+}
+
+func (s *Service) signJoinToken(encPayload string) string {
+	h := hmac.New(sha256.New, s.joinTokenKey)
+	h.Write([]byte(encPayload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
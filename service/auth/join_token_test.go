@@ -0,0 +1,114 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinTokenConfigIsValid(t *testing.T) {
+	var cfg JoinTokenConfig
+	require.NoError(t, cfg.IsValid())
+
+	cfg.Enable = true
+	err := cfg.IsValid()
+	require.Error(t, err)
+	require.Equal(t, "invalid ExpirationMinutes value: should be a positive number", err.Error())
+
+	cfg.ExpirationMinutes = 5
+	require.NoError(t, cfg.IsValid())
+}
+
+func TestJoinToken(t *testing.T) {
+	dbStore, teardown := newTestDBStore(t)
+	defer teardown()
+	sessionCache := newTestSessionCache(t)
+
+	authSrvc, err := NewService(dbStore, sessionCache)
+	require.NoError(t, err)
+
+	expiresAt := time.Now().Add(time.Minute)
+
+	t.Run("invalid args", func(t *testing.T) {
+		token, err := authSrvc.NewJoinToken("", "callID", "sessionID", expiresAt)
+		require.EqualError(t, err, "invalid empty client id")
+		require.Empty(t, token)
+
+		token, err = authSrvc.NewJoinToken("clientID", "", "sessionID", expiresAt)
+		require.EqualError(t, err, "invalid empty call id")
+		require.Empty(t, token)
+
+		token, err = authSrvc.NewJoinToken("clientID", "callID", "", expiresAt)
+		require.EqualError(t, err, "invalid empty session id")
+		require.Empty(t, token)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		token, err := authSrvc.NewJoinToken("clientID", "callID", "sessionID", expiresAt)
+		require.NoError(t, err)
+		require.NotEmpty(t, token)
+
+		err = authSrvc.ValidateJoinToken(token, "clientID", "callID", "sessionID")
+		require.NoError(t, err)
+	})
+
+	t.Run("scope mismatch", func(t *testing.T) {
+		token, err := authSrvc.NewJoinToken("clientID", "callID", "sessionID", expiresAt)
+		require.NoError(t, err)
+
+		err = authSrvc.ValidateJoinToken(token, "clientB", "callID", "sessionID")
+		require.EqualError(t, err, "invalid join token: scope mismatch")
+		err = authSrvc.ValidateJoinToken(token, "clientID", "callB", "sessionID")
+		require.EqualError(t, err, "invalid join token: scope mismatch")
+		err = authSrvc.ValidateJoinToken(token, "clientID", "callID", "sessionB")
+		require.EqualError(t, err, "invalid join token: scope mismatch")
+	})
+
+	t.Run("observer", func(t *testing.T) {
+		token, err := authSrvc.NewObserverToken("clientID", "callID", "sessionID", expiresAt)
+		require.NoError(t, err)
+
+		err = authSrvc.ValidateObserverToken(token, "clientID", "callID", "sessionID")
+		require.NoError(t, err)
+		err = authSrvc.ValidateObserverToken(token, "clientID", "callB", "sessionID")
+		require.EqualError(t, err, "invalid join token: scope mismatch")
+
+		// Observer tokens don't allow joining as a participant, and the other
+		// way around.
+		err = authSrvc.ValidateJoinToken(token, "clientID", "callID", "sessionID")
+		require.EqualError(t, err, "invalid join token: role mismatch")
+
+		token, err = authSrvc.NewJoinToken("clientID", "callID", "sessionID", expiresAt)
+		require.NoError(t, err)
+		err = authSrvc.ValidateObserverToken(token, "clientID", "callID", "sessionID")
+		require.EqualError(t, err, "invalid join token: role mismatch")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		token, err := authSrvc.NewJoinToken("clientID", "callID", "sessionID", time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+
+		err = authSrvc.ValidateJoinToken(token, "clientID", "callID", "sessionID")
+		require.EqualError(t, err, "invalid join token: expired")
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		err := authSrvc.ValidateJoinToken("", "clientID", "callID", "sessionID")
+		require.EqualError(t, err, "invalid join token: malformed")
+
+		token, err := authSrvc.NewJoinToken("clientID", "callID", "sessionID", expiresAt)
+		require.NoError(t, err)
+
+		err = authSrvc.ValidateJoinToken("x"+token, "clientID", "callID", "sessionID")
+		require.EqualError(t, err, "invalid join token: bad signature")
+
+		otherSrvc, err := NewService(dbStore, sessionCache)
+		require.NoError(t, err)
+		err = otherSrvc.ValidateJoinToken(token, "clientID", "callID", "sessionID")
+		require.EqualError(t, err, "invalid join token: bad signature")
+	})
+}
@@ -0,0 +1,312 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ldapUsernamePlaceholder = "{username}"
+	// ldapMaxMessageLen bounds the size of the responses read from the
+	// server.
+	ldapMaxMessageLen = 64 * 1024
+
+	ldapResultSuccess            = 0
+	ldapResultInvalidCredentials = 49
+
+	berTagInteger     = 0x02
+	berTagOctetString = 0x04
+	berTagEnumerated  = 0x0a
+	berTagSequence    = 0x30
+	// Application tags of the LDAP operations, constructed unless noted.
+	ldapTagBindRequest   = 0x60
+	ldapTagBindResponse  = 0x61
+	ldapTagUnbindRequest = 0x42 // primitive
+	// ldapTagSimpleAuth is the context tag of the simple authentication
+	// choice of bind requests.
+	ldapTagSimpleAuth = 0x80
+)
+
+type LDAPConfig struct {
+	// The URL of the LDAP server, using either the ldap or the ldaps (TLS)
+	// scheme.
+	URL string `toml:"url"`
+	// The template of the DN clients bind with, {username} being replaced
+	// with their escaped username (e.g. "uid={username},ou=people,dc=example,dc=com").
+	BindDNTemplate string `toml:"bind_dn_template"`
+}
+
+func (c LDAPConfig) IsValid() error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL value: %w", err)
+	}
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return errors.New("invalid URL value: should use the ldap or ldaps scheme")
+	}
+	if u.Hostname() == "" {
+		return errors.New("invalid URL value: should contain a host")
+	}
+	if !strings.Contains(c.BindDNTemplate, ldapUsernamePlaceholder) {
+		return fmt.Errorf("invalid BindDNTemplate value: should contain %s", ldapUsernamePlaceholder)
+	}
+	return nil
+}
+
+// ldapProvider validates clients by binding to the directory as the user
+// named after the client ID.
+type ldapProvider struct {
+	cfg     LDAPConfig
+	timeout time.Duration
+}
+
+func newLDAPProvider(cfg LDAPConfig, timeout time.Duration) *ldapProvider {
+	return &ldapProvider{
+		cfg:     cfg,
+		timeout: timeout,
+	}
+}
+
+func (p *ldapProvider) Validate(ctx context.Context, clientID string, creds Credentials) error {
+	if creds.Username == "" || creds.Password == "" {
+		// An empty password would make for an unauthenticated bind, which
+		// servers usually accept.
+		return fmt.Errorf("%w: username and password are required", ErrInvalidCredentials)
+	}
+	if creds.Username != clientID {
+		return fmt.Errorf("%w: username should match the client ID", ErrInvalidCredentials)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to LDAP server: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return fmt.Errorf("failed to set deadline: %w", err)
+		}
+	}
+
+	dn := strings.ReplaceAll(p.cfg.BindDNTemplate, ldapUsernamePlaceholder, escapeLDAPDN(creds.Username))
+	code, msg, err := ldapBind(conn, dn, creds.Password)
+	if err != nil {
+		return fmt.Errorf("LDAP bind failed: %w", err)
+	}
+	// Unbinding is a courtesy, the connection being closed either way.
+	_, _ = conn.Write(berEncode(berTagSequence, append(berInt(2), berEncode(ldapTagUnbindRequest, nil)...)))
+
+	switch code {
+	case ldapResultSuccess:
+		return nil
+	case ldapResultInvalidCredentials:
+		return fmt.Errorf("%w: LDAP bind rejected", ErrInvalidCredentials)
+	default:
+		return fmt.Errorf("LDAP bind failed with result code %d: %s", code, msg)
+	}
+}
+
+func (p *ldapProvider) dial(ctx context.Context) (net.Conn, error) {
+	u, err := url.Parse(p.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "389"
+		if u.Scheme == "ldaps" {
+			port = "636"
+		}
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ldaps" {
+		return conn, nil
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName: u.Hostname(),
+		MinVersion: tls.VersionTLS12,
+	})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// ldapBind sends a simple bind request (message ID 1) and returns the
+// result code and diagnostic message of the response.
+func ldapBind(rw io.ReadWriter, dn, password string) (int, string, error) {
+	var req []byte
+	req = append(req, berInt(3)...) // version
+	req = append(req, berEncode(berTagOctetString, []byte(dn))...)
+	req = append(req, berEncode(ldapTagSimpleAuth, []byte(password))...)
+	msg := append(berInt(1), berEncode(ldapTagBindRequest, req)...)
+	if _, err := rw.Write(berEncode(berTagSequence, msg)); err != nil {
+		return 0, "", err
+	}
+
+	tag, content, err := berRead(rw)
+	if err != nil {
+		return 0, "", err
+	}
+	if tag != berTagSequence {
+		return 0, "", fmt.Errorf("unexpected tag 0x%x", tag)
+	}
+	r := &berReader{buf: content}
+	if id := r.int(berTagInteger); r.err == nil && id != 1 {
+		return 0, "", fmt.Errorf("unexpected message ID %d", id)
+	}
+	res := &berReader{buf: r.next(ldapTagBindResponse)}
+	code := res.int(berTagEnumerated)
+	res.next(berTagOctetString) // matched DN
+	diag := res.next(berTagOctetString)
+	if r.err != nil {
+		return 0, "", r.err
+	}
+	if res.err != nil {
+		return 0, "", res.err
+	}
+	return code, string(diag), nil
+}
+
+// escapeLDAPDN escapes the special characters of an attribute value of a
+// DN (RFC 4514).
+func escapeLDAPDN(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == 0:
+			b.WriteString(`\00`)
+		case strings.IndexByte(`"+,;<>\=`, c) >= 0,
+			(c == ' ' || c == '#') && i == 0,
+			c == ' ' && i == len(s)-1:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// berEncode returns the BER encoding of an element with the given tag and
+// content, using the definite length form.
+func berEncode(tag byte, content []byte) []byte {
+	out := []byte{tag}
+	n := len(content)
+	switch {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	case n <= 0xffff:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	default:
+		out = append(out, 0x84, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(out, content...)
+}
+
+// berInt returns the BER encoding of a small non-negative integer.
+func berInt(v int) []byte {
+	var content []byte
+	for {
+		content = append([]byte{byte(v)}, content...)
+		v >>= 8
+		if v == 0 && content[0] < 0x80 {
+			break
+		}
+	}
+	return berEncode(berTagInteger, content)
+}
+
+// berRead reads a single BER element.
+func berRead(r io.Reader) (byte, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := int(hdr[1])
+	if n >= 0x80 {
+		size := n & 0x7f
+		if size == 0 || size > 4 {
+			return 0, nil, errors.New("unsupported length")
+		}
+		var buf [4]byte
+		if _, err := io.ReadFull(r, buf[:size]); err != nil {
+			return 0, nil, err
+		}
+		n = 0
+		for _, b := range buf[:size] {
+			n = n<<8 | int(b)
+		}
+	}
+	if n > ldapMaxMessageLen {
+		return 0, nil, errors.New("message too long")
+	}
+	content := make([]byte, n)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return 0, nil, err
+	}
+	return hdr[0], content, nil
+}
+
+// berReader decodes the elements of a constructed BER element. Errors are
+// sticky.
+type berReader struct {
+	buf []byte
+	err error
+}
+
+// next returns the content of the next element, which should have the
+// given tag.
+func (r *berReader) next(tag byte) []byte {
+	if r.err != nil {
+		return nil
+	}
+	rd := bytes.NewReader(r.buf)
+	t, content, err := berRead(rd)
+	if err != nil {
+		r.err = fmt.Errorf("failed to decode element: %w", err)
+		return nil
+	}
+	if t != tag {
+		r.err = fmt.Errorf("unexpected tag 0x%x", t)
+		return nil
+	}
+	r.buf = r.buf[len(r.buf)-rd.Len():]
+	return content
+}
+
+func (r *berReader) int(tag byte) int {
+	content := r.next(tag)
+	if r.err == nil && (len(content) == 0 || len(content) > 4) {
+		r.err = errors.New("invalid integer")
+	}
+	v := 0
+	for _, b := range content {
+		v = v<<8 | int(b)
+	}
+	return v
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// serveLDAPBinds answers the bind requests received on ln, accepting those
+// matching users (DN to password).
+func serveLDAPBinds(ln net.Listener, users map[string]string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			tag, content, err := berRead(conn)
+			if err != nil || tag != berTagSequence {
+				return
+			}
+			r := &berReader{buf: content}
+			id := r.int(berTagInteger)
+			req := &berReader{buf: r.next(ldapTagBindRequest)}
+			req.int(berTagInteger) // version
+			dn := string(req.next(berTagOctetString))
+			password := string(req.next(ldapTagSimpleAuth))
+			if r.err != nil || req.err != nil {
+				return
+			}
+
+			code := ldapResultInvalidCredentials
+			if pass, ok := users[dn]; ok && pass == password {
+				code = ldapResultSuccess
+			} else if dn == "uid=unavailable,dc=example,dc=com" {
+				code = 52 // unavailable
+			}
+			var res []byte
+			res = append(res, berEncode(berTagEnumerated, []byte{byte(code)})...)
+			res = append(res, berEncode(berTagOctetString, nil)...)
+			res = append(res, berEncode(berTagOctetString, []byte("diagnostic"))...)
+			msg := append(berInt(id), berEncode(ldapTagBindResponse, res)...)
+			_, _ = conn.Write(berEncode(berTagSequence, msg))
+			// Wait for the unbind request.
+			_, _, _ = berRead(conn)
+		}()
+	}
+}
+
+func TestLDAPProvider(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go serveLDAPBinds(ln, map[string]string{
+		"uid=alice,dc=example,dc=com":       "secret",
+		`uid=b\,ob,dc=example,dc=com`:       "secret",
+		"uid=unavailable,dc=example,dc=com": "",
+	})
+
+	p := newLDAPProvider(LDAPConfig{
+		URL:            "ldap://" + ln.Addr().String(),
+		BindDNTemplate: "uid={username},dc=example,dc=com",
+	}, 5*time.Second)
+
+	t.Run("valid", func(t *testing.T) {
+		err := p.Validate(context.Background(), "alice", Credentials{Username: "alice", Password: "secret"})
+		require.NoError(t, err)
+	})
+
+	t.Run("escaped username", func(t *testing.T) {
+		err := p.Validate(context.Background(), "b,ob", Credentials{Username: "b,ob", Password: "secret"})
+		require.NoError(t, err)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		err := p.Validate(context.Background(), "alice", Credentials{Username: "alice", Password: "wrong"})
+		require.True(t, errors.Is(err, ErrInvalidCredentials))
+	})
+
+	t.Run("empty password", func(t *testing.T) {
+		err := p.Validate(context.Background(), "alice", Credentials{Username: "alice"})
+		require.True(t, errors.Is(err, ErrInvalidCredentials))
+	})
+
+	t.Run("mismatching client ID", func(t *testing.T) {
+		err := p.Validate(context.Background(), "bob", Credentials{Username: "alice", Password: "secret"})
+		require.True(t, errors.Is(err, ErrInvalidCredentials))
+	})
+
+	t.Run("bearer token", func(t *testing.T) {
+		err := p.Validate(context.Background(), "alice", Credentials{Token: "token"})
+		require.True(t, errors.Is(err, ErrInvalidCredentials))
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		err := p.Validate(context.Background(), "unavailable", Credentials{Username: "unavailable", Password: "secret"})
+		require.Error(t, err)
+		require.False(t, errors.Is(err, ErrInvalidCredentials))
+		require.Equal(t, "LDAP bind failed with result code 52: diagnostic", err.Error())
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		p := newLDAPProvider(LDAPConfig{
+			URL:            "ldap://localhost:1",
+			BindDNTemplate: "uid={username},dc=example,dc=com",
+		}, 5*time.Second)
+		err := p.Validate(context.Background(), "alice", Credentials{Username: "alice", Password: "secret"})
+		require.Error(t, err)
+		require.False(t, errors.Is(err, ErrInvalidCredentials))
+	})
+}
+
+func TestEscapeLDAPDN(t *testing.T) {
+	require.Equal(t, "alice", escapeLDAPDN("alice"))
+	require.Equal(t, `a\,b\+c\=d`, escapeLDAPDN("a,b+c=d"))
+	require.Equal(t, `\#a b\ `, escapeLDAPDN("#a b "))
+	require.Equal(t, `\ a\00`, escapeLDAPDN(" a\x00"))
+}
+
+func TestBER(t *testing.T) {
+	t.Run("integers", func(t *testing.T) {
+		for _, v := range []int{0, 1, 127, 128, 255, 256, 65535, 1 << 20} {
+			r := &berReader{buf: berInt(v)}
+			require.Equal(t, v, r.int(berTagInteger))
+			require.NoError(t, r.err)
+			require.Empty(t, r.buf)
+		}
+	})
+
+	t.Run("long lengths", func(t *testing.T) {
+		for _, n := range []int{0, 127, 128, 255, 256, 40000} {
+			content := make([]byte, n)
+			r := &berReader{buf: berEncode(berTagOctetString, content)}
+			require.Len(t, r.next(berTagOctetString), n)
+			require.NoError(t, r.err)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		buf := berEncode(berTagOctetString, []byte("data"))
+		r := &berReader{buf: buf[:len(buf)-1]}
+		require.Nil(t, r.next(berTagOctetString))
+		require.Error(t, r.err)
+	})
+
+	t.Run("unexpected tag", func(t *testing.T) {
+		r := &berReader{buf: berInt(1)}
+		require.Nil(t, r.next(berTagOctetString))
+		require.EqualError(t, r.err, "unexpected tag 0x2")
+	})
+}
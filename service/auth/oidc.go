@@ -0,0 +1,126 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// oidcMaxResponseLen bounds the size of the introspection responses.
+const oidcMaxResponseLen = 64 * 1024
+
+type OIDCConfig struct {
+	// The token introspection endpoint (RFC 7662) of the identity provider.
+	IntrospectionURL string `toml:"introspection_url"`
+	// The credentials rtcd authenticates to the introspection endpoint
+	// with.
+	ClientID     string `toml:"client_id"`
+	ClientSecret string `toml:"client_secret"`
+	// The claim of the introspection response that should match the ID of
+	// the registering client (e.g. "sub", "username" or "client_id").
+	ClientIDClaim string `toml:"client_id_claim"`
+	// The optional scope the tokens should have been granted.
+	RequiredScope string `toml:"required_scope"`
+}
+
+func (c OIDCConfig) IsValid() error {
+	u, err := url.Parse(c.IntrospectionURL)
+	if err != nil {
+		return fmt.Errorf("invalid IntrospectionURL value: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("invalid IntrospectionURL value: should use the http or https scheme")
+	}
+	if u.Host == "" {
+		return errors.New("invalid IntrospectionURL value: should contain a host")
+	}
+	if c.ClientID == "" {
+		return errors.New("invalid ClientID value: should not be empty")
+	}
+	if c.ClientIDClaim == "" {
+		return errors.New("invalid ClientIDClaim value: should not be empty")
+	}
+	if strings.ContainsAny(c.RequiredScope, " \t\r\n") {
+		return errors.New("invalid RequiredScope value: should be a single scope")
+	}
+	return nil
+}
+
+// oidcProvider validates the bearer tokens of the registering clients
+// through the introspection endpoint of the identity provider.
+type oidcProvider struct {
+	cfg    OIDCConfig
+	client *http.Client
+}
+
+func newOIDCProvider(cfg OIDCConfig, timeout time.Duration) *oidcProvider {
+	return &oidcProvider{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (p *oidcProvider) Validate(ctx context.Context, clientID string, creds Credentials) error {
+	if creds.Token == "" {
+		return fmt.Errorf("%w: bearer token is required", ErrInvalidCredentials)
+	}
+
+	form := url.Values{
+		"token":           {creds.Token},
+		"token_type_hint": {"access_token"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.IntrospectionURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("introspection request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("introspection failed with status code %d", resp.StatusCode)
+	}
+
+	var claims map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseLen)).Decode(&claims); err != nil {
+		return fmt.Errorf("failed to decode introspection response: %w", err)
+	}
+
+	if active, _ := claims["active"].(bool); !active {
+		return fmt.Errorf("%w: token is not active", ErrInvalidCredentials)
+	}
+	if id, _ := claims[p.cfg.ClientIDClaim].(string); id != clientID {
+		return fmt.Errorf("%w: %s claim should match the client ID", ErrInvalidCredentials, p.cfg.ClientIDClaim)
+	}
+	if p.cfg.RequiredScope != "" {
+		scope, _ := claims["scope"].(string)
+		found := false
+		for _, s := range strings.Fields(scope) {
+			if s == p.cfg.RequiredScope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: token is missing the %s scope", ErrInvalidCredentials, p.cfg.RequiredScope)
+		}
+	}
+
+	return nil
+}
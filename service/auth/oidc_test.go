@@ -0,0 +1,88 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDCProvider(t *testing.T) {
+	tokens := map[string]map[string]interface{}{
+		"alice-token":   {"active": true, "sub": "alice", "scope": "openid rtcd"},
+		"noscope-token": {"active": true, "sub": "alice", "scope": "openid"},
+		"expired-token": {"active": false},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, ok := r.BasicAuth(); !ok || id != "rtcd" || secret != "rtcd-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("token_type_hint") != "access_token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		claims, ok := tokens[r.PostForm.Get("token")]
+		if !ok {
+			claims = map[string]interface{}{"active": false}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(claims)
+	}))
+	defer ts.Close()
+
+	cfg := OIDCConfig{
+		IntrospectionURL: ts.URL,
+		ClientID:         "rtcd",
+		ClientSecret:     "rtcd-secret",
+		ClientIDClaim:    "sub",
+		RequiredScope:    "rtcd",
+	}
+	p := newOIDCProvider(cfg, 5*time.Second)
+
+	t.Run("valid", func(t *testing.T) {
+		require.NoError(t, p.Validate(context.Background(), "alice", Credentials{Token: "alice-token"}))
+	})
+
+	t.Run("mismatching client ID", func(t *testing.T) {
+		err := p.Validate(context.Background(), "bob", Credentials{Token: "alice-token"})
+		require.True(t, errors.Is(err, ErrInvalidCredentials))
+		require.Equal(t, "invalid credentials: sub claim should match the client ID", err.Error())
+	})
+
+	t.Run("missing scope", func(t *testing.T) {
+		err := p.Validate(context.Background(), "alice", Credentials{Token: "noscope-token"})
+		require.True(t, errors.Is(err, ErrInvalidCredentials))
+		require.Equal(t, "invalid credentials: token is missing the rtcd scope", err.Error())
+	})
+
+	t.Run("inactive token", func(t *testing.T) {
+		err := p.Validate(context.Background(), "alice", Credentials{Token: "expired-token"})
+		require.True(t, errors.Is(err, ErrInvalidCredentials))
+		err = p.Validate(context.Background(), "alice", Credentials{Token: "unknown-token"})
+		require.True(t, errors.Is(err, ErrInvalidCredentials))
+	})
+
+	t.Run("basic credentials", func(t *testing.T) {
+		err := p.Validate(context.Background(), "alice", Credentials{Username: "alice", Password: "secret"})
+		require.True(t, errors.Is(err, ErrInvalidCredentials))
+	})
+
+	t.Run("introspection error", func(t *testing.T) {
+		cfg := cfg
+		cfg.ClientSecret = "wrong"
+		p := newOIDCProvider(cfg, 5*time.Second)
+		err := p.Validate(context.Background(), "alice", Credentials{Token: "alice-token"})
+		require.Error(t, err)
+		require.False(t, errors.Is(err, ErrInvalidCredentials))
+		require.Equal(t, "introspection failed with status code 401", err.Error())
+	})
+}
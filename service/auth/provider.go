@@ -0,0 +1,117 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// ProviderStatic leaves registration to the credentials stored by rtcd:
+	// registering clients need admin credentials, or those of a registered
+	// client, unless self registration is allowed.
+	ProviderStatic = "static"
+	// ProviderLDAP validates registering clients by binding to an LDAP
+	// directory with their username and password.
+	ProviderLDAP = "ldap"
+	// ProviderHTTP validates registering clients by forwarding their
+	// credentials to an external HTTP endpoint.
+	ProviderHTTP = "http"
+	// ProviderOIDC validates the access tokens of registering clients
+	// through OAuth 2.0 token introspection (RFC 7662).
+	ProviderOIDC = "oidc"
+)
+
+// ErrInvalidCredentials is returned by providers rejecting the credentials
+// of a client, as opposed to failing to validate them.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Credentials are the credentials presented by a registering client, taken
+// from the Authorization header of the request.
+type Credentials struct {
+	// Username and Password are set for the basic auth scheme.
+	Username string
+	Password string
+	// Token is set for the bearer auth scheme.
+	Token string
+}
+
+// Provider validates registering clients against an external identity
+// provider.
+type Provider interface {
+	// Validate returns an error if the credentials don't allow registering
+	// a client under the given ID. The error wraps ErrInvalidCredentials if
+	// the credentials were rejected.
+	Validate(ctx context.Context, clientID string, creds Credentials) error
+}
+
+type RegistrationAuthConfig struct {
+	// The provider validating the registering clients ("static", "ldap",
+	// "http" or "oidc"). Defaults to static if empty.
+	Provider string `toml:"provider"`
+	// The timeout, in seconds, of the requests to the provider.
+	TimeoutSeconds int `toml:"timeout_seconds"`
+	// Configuration of the ldap provider.
+	LDAP LDAPConfig `toml:"ldap"`
+	// Configuration of the http provider.
+	HTTP HTTPValidatorConfig `toml:"http"`
+	// Configuration of the oidc provider.
+	OIDC OIDCConfig `toml:"oidc"`
+}
+
+func (c RegistrationAuthConfig) IsValid() error {
+	switch c.Provider {
+	case "", ProviderStatic:
+		return nil
+	case ProviderLDAP, ProviderHTTP, ProviderOIDC:
+	default:
+		return fmt.Errorf("invalid Provider value: should be one of %q, %q, %q or %q",
+			ProviderStatic, ProviderLDAP, ProviderHTTP, ProviderOIDC)
+	}
+
+	if c.TimeoutSeconds <= 0 {
+		return errors.New("invalid TimeoutSeconds value: should be a positive number")
+	}
+
+	switch c.Provider {
+	case ProviderLDAP:
+		if err := c.LDAP.IsValid(); err != nil {
+			return fmt.Errorf("invalid LDAP config: %w", err)
+		}
+	case ProviderHTTP:
+		if err := c.HTTP.IsValid(); err != nil {
+			return fmt.Errorf("invalid HTTP config: %w", err)
+		}
+	case ProviderOIDC:
+		if err := c.OIDC.IsValid(); err != nil {
+			return fmt.Errorf("invalid OIDC config: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// NewProvider returns the provider selected by cfg. It returns a nil
+// provider for the static one, registration being left to the stored
+// credentials.
+func NewProvider(cfg RegistrationAuthConfig) (Provider, error) {
+	if err := cfg.IsValid(); err != nil {
+		return nil, fmt.Errorf("failed to validate config: %w", err)
+	}
+
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	switch cfg.Provider {
+	case ProviderLDAP:
+		return newLDAPProvider(cfg.LDAP, timeout), nil
+	case ProviderHTTP:
+		return newHTTPValidator(cfg.HTTP, timeout), nil
+	case ProviderOIDC:
+		return newOIDCProvider(cfg.OIDC, timeout), nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistrationAuthConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg RegistrationAuthConfig
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("static", func(t *testing.T) {
+		cfg := RegistrationAuthConfig{Provider: ProviderStatic}
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid Provider", func(t *testing.T) {
+		cfg := RegistrationAuthConfig{Provider: "saml"}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid Provider value: should be one of "static", "ldap", "http" or "oidc"`, err.Error())
+	})
+
+	t.Run("invalid TimeoutSeconds", func(t *testing.T) {
+		cfg := RegistrationAuthConfig{
+			Provider: ProviderHTTP,
+			HTTP:     HTTPValidatorConfig{URL: "http://localhost/validate"},
+		}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TimeoutSeconds value: should be a positive number", err.Error())
+	})
+
+	t.Run("invalid LDAP", func(t *testing.T) {
+		cfg := RegistrationAuthConfig{
+			Provider:       ProviderLDAP,
+			TimeoutSeconds: 10,
+			LDAP: LDAPConfig{
+				URL:            "http://localhost",
+				BindDNTemplate: "uid={username},dc=example,dc=com",
+			},
+		}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid LDAP config: invalid URL value: should use the ldap or ldaps scheme", err.Error())
+
+		cfg.LDAP.URL = "ldaps://"
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid LDAP config: invalid URL value: should contain a host", err.Error())
+
+		cfg.LDAP.URL = "ldaps://localhost"
+		cfg.LDAP.BindDNTemplate = "uid=user,dc=example,dc=com"
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid LDAP config: invalid BindDNTemplate value: should contain {username}", err.Error())
+	})
+
+	t.Run("invalid HTTP", func(t *testing.T) {
+		cfg := RegistrationAuthConfig{
+			Provider:       ProviderHTTP,
+			TimeoutSeconds: 10,
+			HTTP:           HTTPValidatorConfig{URL: "ftp://localhost"},
+		}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid HTTP config: invalid URL value: should use the http or https scheme", err.Error())
+	})
+
+	t.Run("invalid OIDC", func(t *testing.T) {
+		cfg := RegistrationAuthConfig{
+			Provider:       ProviderOIDC,
+			TimeoutSeconds: 10,
+			OIDC: OIDCConfig{
+				IntrospectionURL: "https://idp.example.com/introspect",
+				ClientIDClaim:    "sub",
+			},
+		}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid OIDC config: invalid ClientID value: should not be empty", err.Error())
+
+		cfg.OIDC.ClientID = "rtcd"
+		cfg.OIDC.ClientIDClaim = ""
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid OIDC config: invalid ClientIDClaim value: should not be empty", err.Error())
+
+		cfg.OIDC.ClientIDClaim = "sub"
+		cfg.OIDC.RequiredScope = "rtcd calls"
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid OIDC config: invalid RequiredScope value: should be a single scope", err.Error())
+	})
+}
+
+func TestNewProvider(t *testing.T) {
+	t.Run("invalid config", func(t *testing.T) {
+		p, err := NewProvider(RegistrationAuthConfig{Provider: ProviderLDAP})
+		require.Error(t, err)
+		require.Nil(t, p)
+	})
+
+	t.Run("static", func(t *testing.T) {
+		p, err := NewProvider(RegistrationAuthConfig{Provider: ProviderStatic})
+		require.NoError(t, err)
+		require.Nil(t, p)
+	})
+
+	t.Run("ldap", func(t *testing.T) {
+		p, err := NewProvider(RegistrationAuthConfig{
+			Provider:       ProviderLDAP,
+			TimeoutSeconds: 10,
+			LDAP: LDAPConfig{
+				URL:            "ldap://localhost",
+				BindDNTemplate: "uid={username},dc=example,dc=com",
+			},
+		})
+		require.NoError(t, err)
+		require.IsType(t, &ldapProvider{}, p)
+	})
+
+	t.Run("http", func(t *testing.T) {
+		p, err := NewProvider(RegistrationAuthConfig{
+			Provider:       ProviderHTTP,
+			TimeoutSeconds: 10,
+			HTTP:           HTTPValidatorConfig{URL: "https://auth.example.com/validate"},
+		})
+		require.NoError(t, err)
+		require.IsType(t, &httpValidator{}, p)
+	})
+
+	t.Run("oidc", func(t *testing.T) {
+		p, err := NewProvider(RegistrationAuthConfig{
+			Provider:       ProviderOIDC,
+			TimeoutSeconds: 10,
+			OIDC: OIDCConfig{
+				IntrospectionURL: "https://idp.example.com/introspect",
+				ClientID:         "rtcd",
+				ClientIDClaim:    "sub",
+			},
+		})
+		require.NoError(t, err)
+		require.IsType(t, &oidcProvider{}, p)
+	})
+}
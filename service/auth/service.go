@@ -4,6 +4,7 @@
 package auth
 
 import (
+	"crypto/rand"
 	"errors"
 	"fmt"
 
@@ -15,19 +16,49 @@ const MinKeyLen = 32
 type Service struct {
 	sessionCache *SessionCache
 	store        store.Store
+	// joinTokenKey is the key used to sign join tokens. It's generated on
+	// startup so tokens don't survive a restart.
+	joinTokenKey []byte
+	// signedAuth is whether signing keys are kept for the clients to
+	// authenticate with signed credentials.
+	signedAuth bool
+	// nonces holds the nonces of the signed credentials used so far.
+	nonces *nonceCache
 }
 
-func NewService(store store.Store, sessionCache *SessionCache) (*Service, error) {
+// ServiceOption configures a Service.
+type ServiceOption func(s *Service)
+
+// WithSignedAuth enables the authentication with signed credentials. The
+// signing keys of the clients are kept in the store, which should be
+// encrypted.
+func WithSignedAuth() ServiceOption {
+	return func(s *Service) {
+		s.signedAuth = true
+	}
+}
+
+func NewService(store store.Store, sessionCache *SessionCache, opts ...ServiceOption) (*Service, error) {
 	if store == nil {
 		return nil, errors.New("invalid store")
 	}
 	if sessionCache == nil {
 		return nil, errors.New("invalid session cache")
 	}
-	return &Service{
+	joinTokenKey := make([]byte, 32)
+	if _, err := rand.Read(joinTokenKey); err != nil {
+		return nil, fmt.Errorf("failed to generate join token key: %w", err)
+	}
+	s := &Service{
 		sessionCache: sessionCache,
 		store:        store,
-	}, nil
+		joinTokenKey: joinTokenKey,
+		nonces:       newNonceCache(store),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
 }
 
 func (s *Service) Authenticate(id, authToken string) error {
@@ -38,6 +69,20 @@ func (s *Service) Authenticate(id, authToken string) error {
 	if err := compareKeyHash(hash, authToken); err != nil {
 		return errors.New("authentication failed")
 	}
+
+	// Clients registered before signed credentials were enabled get their
+	// signing key on first use of the auth key.
+	if !s.signedAuth {
+		return nil
+	}
+	if _, err := s.getSigningKey(id); errors.Is(err, store.ErrNotFound) {
+		if err := s.setSigningKey(id, authToken); err != nil {
+			return fmt.Errorf("failed to store signing key: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get signing key: %w", err)
+	}
+
 	return nil
 }
 
@@ -46,6 +91,12 @@ func (s *Service) Register(id, key string) error {
 		return errors.New("registration failed: key not long enough")
 	}
 
+	// The registrations share the keyspace of the store with its internal
+	// state.
+	if !store.IsRegistrationKey(id) {
+		return errors.New("registration failed: invalid client id")
+	}
+
 	if _, err := s.store.Get(id); err == nil {
 		return errors.New("registration failed: already registered")
 	} else if err != nil && !errors.Is(err, store.ErrNotFound) {
@@ -63,6 +114,11 @@ func (s *Service) Register(id, key string) error {
 		return fmt.Errorf("registration failed: %w", err)
 	}
 
+	if err := s.setSigningKey(id, key); err != nil {
+		_ = s.store.Delete(id)
+		return fmt.Errorf("registration failed: %w", err)
+	}
+
 	return nil
 }
 
@@ -75,6 +131,9 @@ func (s *Service) Unregister(id string) error {
 	if err != nil {
 		return fmt.Errorf("unregister failed: %w", err)
 	}
+	if err := s.store.Delete(signingKeyStoreKey(id)); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("unregister failed: %w", err)
+	}
 
 	// Invalidate token when unregistering
 	s.sessionCache.Delete(id)
@@ -80,6 +80,9 @@ func TestRegister(t *testing.T) {
 	require.Error(t, err)
 	require.EqualError(t, err, "registration failed: already registered")
 
+	err = s.Register(store.InternalKeyPrefix+"schema_version", authKey)
+	require.EqualError(t, err, "registration failed: invalid client id")
+
 	err = s.Unregister("instanceA")
 	require.NoError(t, err)
 
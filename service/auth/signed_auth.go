@@ -0,0 +1,341 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/service/store"
+)
+
+const (
+	// SignedAuthScheme is the Authorization header scheme of the signed
+	// credentials.
+	SignedAuthScheme = "RTCD-HMAC-SHA256"
+
+	signingKeyStoreKeyPrefix = "rtcd:signing_key:"
+	nonceStoreKeyPrefix      = "rtcd:nonce:"
+	signingKeyContext        = "rtcd signing key"
+	signedAuthContext        = "rtcd signed auth"
+	// nonceLen is the length of the nonces generated by
+	// NewSignedCredentials.
+	nonceLen = 22
+	// maxNonceLen bounds the size of the nonces kept by the replay cache.
+	maxNonceLen = 64
+	// noncePruneInterval is the minimum interval between two removals of
+	// the expired nonces.
+	noncePruneInterval = time.Minute
+)
+
+type SignedAuthConfig struct {
+	// Whether or not clients can authenticate with signed credentials. Their
+	// signing keys being secrets, it requires the store to be encrypted.
+	Enable bool `toml:"enable"`
+	// Whether or not clients are required to authenticate the signaling
+	// connection (WebSocket and gRPC) and the group_auth messages with
+	// signed credentials instead of their bare auth key. Logins, exchanging
+	// the auth key for a bearer token, are rejected.
+	Require bool `toml:"require"`
+	// The maximum difference, in seconds, between the timestamp of signed
+	// credentials and the server clock. Credentials are rejected once used
+	// or past this window.
+	MaxClockSkewSeconds int `toml:"max_clock_skew_seconds"`
+}
+
+func (c SignedAuthConfig) IsValid() error {
+	if c.MaxClockSkewSeconds < 0 {
+		return errors.New("invalid MaxClockSkewSeconds value: should not be negative")
+	}
+	if c.Require && !c.Enable {
+		return errors.New("invalid Require value: signed credentials should be enabled")
+	}
+	if c.Enable && c.MaxClockSkewSeconds == 0 {
+		return errors.New("invalid MaxClockSkewSeconds value: should be a positive number when signed credentials are enabled")
+	}
+	return nil
+}
+
+// SignedCredentials prove the knowledge of the auth key of a client without
+// sending it. They can only be used once, within the allowed clock skew.
+type SignedCredentials struct {
+	ClientID string
+	// Timestamp is the time, in seconds since the Unix epoch, the
+	// credentials were signed at.
+	Timestamp int64
+	// Nonce is a random string making the credentials unique.
+	Nonce     string
+	Signature string
+}
+
+// NewSignedCredentials returns credentials for clientID, signed at now with
+// a key derived from authKey.
+func NewSignedCredentials(clientID, authKey string, now time.Time) (SignedCredentials, error) {
+	if clientID == "" {
+		return SignedCredentials{}, errors.New("invalid empty client id")
+	}
+	if strings.Contains(clientID, ":") {
+		return SignedCredentials{}, errors.New("invalid client id: should not contain colons")
+	}
+
+	nonce, err := newRandomString(nonceLen)
+	if err != nil {
+		return SignedCredentials{}, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	creds := SignedCredentials{
+		ClientID:  clientID,
+		Timestamp: now.Unix(),
+		Nonce:     nonce,
+	}
+	creds.Signature = creds.sign(deriveSigningKey(authKey))
+
+	return creds, nil
+}
+
+// ParseSignedCredentials parses credentials encoded by
+// SignedCredentials.String.
+func ParseSignedCredentials(s string) (SignedCredentials, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 4 {
+		return SignedCredentials{}, errors.New("invalid signed credentials: malformed")
+	}
+
+	ts, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return SignedCredentials{}, fmt.Errorf("invalid signed credentials: bad timestamp: %w", err)
+	}
+
+	creds := SignedCredentials{
+		ClientID:  parts[0],
+		Timestamp: ts,
+		Nonce:     parts[2],
+		Signature: parts[3],
+	}
+	if creds.ClientID == "" || creds.Nonce == "" || creds.Signature == "" {
+		return SignedCredentials{}, errors.New("invalid signed credentials: missing fields")
+	}
+	if len(creds.Nonce) > maxNonceLen {
+		return SignedCredentials{}, errors.New("invalid signed credentials: nonce is too long")
+	}
+
+	return creds, nil
+}
+
+// String encodes the credentials as
+// <clientID>:<timestamp>:<nonce>:<signature>.
+func (c SignedCredentials) String() string {
+	return fmt.Sprintf("%s:%d:%s:%s", c.ClientID, c.Timestamp, c.Nonce, c.Signature)
+}
+
+func (c SignedCredentials) sign(key []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(signedAuthContext + "\x00" + c.ClientID + "\x00" + strconv.FormatInt(c.Timestamp, 10) + "\x00" + c.Nonce))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// deriveSigningKey returns the key signing the credentials of the client
+// owning authKey. It's kept in the store, as opposed to the auth key, since
+// signatures can't be verified against a bcrypt hash. Since it's enough to
+// sign credentials, signed auth is only enabled along with the encryption of
+// the store.
+func deriveSigningKey(authKey string) []byte {
+	h := hmac.New(sha256.New, []byte(authKey))
+	h.Write([]byte(signingKeyContext))
+	return h.Sum(nil)
+}
+
+// signingKeyStoreKey returns the store key of the signing key of a client.
+// IDs are hashed as keys are limited to 64 bytes by the store.
+func signingKeyStoreKey(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return signingKeyStoreKeyPrefix + base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (s *Service) getSigningKey(id string) ([]byte, error) {
+	val, err := s.store.Get(signingKeyStoreKey(id))
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(val)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signing key: %w", err)
+	}
+	return key, nil
+}
+
+// setSigningKey stores the signing key of a client, if signed credentials
+// are enabled.
+func (s *Service) setSigningKey(id, authKey string) error {
+	if !s.signedAuth {
+		return nil
+	}
+	return s.store.Set(signingKeyStoreKey(id), base64.StdEncoding.EncodeToString(deriveSigningKey(authKey)))
+}
+
+// AuthenticateSigned verifies that creds were signed by the client they
+// belong to, no more than maxSkew away from the current time, and that they
+// weren't used already. Clients registered before signed credentials were
+// supported need to authenticate with their auth key once first.
+func (s *Service) AuthenticateSigned(creds SignedCredentials, maxSkew time.Duration) error {
+	if !s.signedAuth {
+		return errors.New("authentication failed: signed credentials are disabled")
+	}
+
+	now := time.Now()
+	ts := time.Unix(creds.Timestamp, 0)
+	if ts.Before(now.Add(-maxSkew)) || ts.After(now.Add(maxSkew)) {
+		return errors.New("authentication failed: timestamp is outside the allowed window")
+	}
+
+	if _, err := s.store.Get(creds.ClientID); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	key, err := s.getSigningKey(creds.ClientID)
+	if errors.Is(err, store.ErrNotFound) {
+		return errors.New("authentication failed: no signing key, authenticate with the auth key first")
+	} else if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	if !hmac.Equal([]byte(creds.Signature), []byte(creds.sign(key))) {
+		return errors.New("authentication failed: bad signature")
+	}
+
+	// Nonces are only kept for as long as the timestamp is valid.
+	if ok, err := s.nonces.add(creds.ClientID+"\x00"+creds.Nonce, ts.Add(maxSkew), now); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	} else if !ok {
+		return errors.New("authentication failed: credentials were already used")
+	}
+
+	return nil
+}
+
+// nonceCache keeps the nonces of the signed credentials used so far, until
+// they expire. They are persisted so that credentials can't be replayed
+// across restarts.
+type nonceCache struct {
+	store     store.Store
+	lastPrune time.Time
+	mut       sync.Mutex
+}
+
+func newNonceCache(store store.Store) *nonceCache {
+	return &nonceCache{
+		store: store,
+	}
+}
+
+// nonceStoreKey returns the store key of a nonce. Nonces are hashed as keys
+// are limited to 64 bytes by the store.
+func nonceStoreKey(nonce string) string {
+	sum := sha256.Sum256([]byte(nonce))
+	return nonceStoreKeyPrefix + base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// add stores nonce until expiresAt. It returns false if the nonce was
+// already stored.
+func (c *nonceCache) add(nonce string, expiresAt, now time.Time) (bool, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if now.Sub(c.lastPrune) >= noncePruneInterval {
+		if err := c.prune(now); err != nil {
+			return false, fmt.Errorf("failed to prune nonces: %w", err)
+		}
+		c.lastPrune = now
+	}
+
+	key := nonceStoreKey(nonce)
+	val := strconv.FormatInt(expiresAt.Unix(), 10)
+	for {
+		err := c.store.Put(key, val)
+		if err == nil {
+			return true, nil
+		} else if !errors.Is(err, store.ErrConflict) {
+			return false, fmt.Errorf("failed to store nonce: %w", err)
+		}
+
+		exp, err := c.getExpiration(key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		if now.Before(exp) {
+			return false, nil
+		}
+		if err := c.store.Delete(key); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return false, fmt.Errorf("failed to delete nonce: %w", err)
+		}
+	}
+}
+
+func (c *nonceCache) getExpiration(key string) (time.Time, error) {
+	val, err := c.store.Get(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	exp, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse nonce expiration: %w", err)
+	}
+	return time.Unix(exp, 0), nil
+}
+
+// prune deletes the expired nonces from the store.
+func (c *nonceCache) prune(now time.Time) error {
+	keys, err := c.store.Keys()
+	if err != nil {
+		return fmt.Errorf("failed to get keys: %w", err)
+	}
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, nonceStoreKeyPrefix) {
+			continue
+		}
+		exp, err := c.getExpiration(key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if now.Before(exp) {
+			continue
+		}
+		if err := c.store.Delete(key); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("failed to delete nonce: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteSigningKeys removes the signing keys of all the clients from st.
+// They get derived again from the auth keys on the next authentication.
+func DeleteSigningKeys(st store.Store) error {
+	keys, err := st.Keys()
+	if err != nil {
+		return fmt.Errorf("failed to get keys: %w", err)
+	}
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, signingKeyStoreKeyPrefix) {
+			continue
+		}
+		if err := st.Delete(key); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("failed to delete %q: %w", key, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignedAuthConfigIsValid(t *testing.T) {
+	require.NoError(t, SignedAuthConfig{}.IsValid())
+	require.NoError(t, SignedAuthConfig{Enable: true, Require: true, MaxClockSkewSeconds: 300}.IsValid())
+	require.EqualError(t, SignedAuthConfig{MaxClockSkewSeconds: -1}.IsValid(),
+		"invalid MaxClockSkewSeconds value: should not be negative")
+	require.EqualError(t, SignedAuthConfig{Require: true, MaxClockSkewSeconds: 300}.IsValid(),
+		"invalid Require value: signed credentials should be enabled")
+	require.EqualError(t, SignedAuthConfig{Enable: true}.IsValid(),
+		"invalid MaxClockSkewSeconds value: should be a positive number when signed credentials are enabled")
+}
+
+func TestSignedCredentials(t *testing.T) {
+	t.Run("invalid client id", func(t *testing.T) {
+		_, err := NewSignedCredentials("", "authKey", time.Now())
+		require.EqualError(t, err, "invalid empty client id")
+		_, err = NewSignedCredentials("client:A", "authKey", time.Now())
+		require.EqualError(t, err, "invalid client id: should not contain colons")
+	})
+
+	t.Run("encoding", func(t *testing.T) {
+		creds, err := NewSignedCredentials("clientA", "authKey", time.Unix(1000, 0))
+		require.NoError(t, err)
+		require.Equal(t, int64(1000), creds.Timestamp)
+		require.Len(t, creds.Nonce, nonceLen)
+
+		parsed, err := ParseSignedCredentials(creds.String())
+		require.NoError(t, err)
+		require.Equal(t, creds, parsed)
+
+		other, err := NewSignedCredentials("clientA", "authKey", time.Unix(1000, 0))
+		require.NoError(t, err)
+		require.NotEqual(t, creds.Nonce, other.Nonce)
+		require.NotEqual(t, creds.Signature, other.Signature)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, s := range []string{
+			"",
+			"clientA:1000:nonce",
+			"clientA:abc:nonce:sig",
+			":1000:nonce:sig",
+			"clientA:1000::sig",
+			"clientA:1000:" + string(make([]byte, maxNonceLen+1)) + ":sig",
+		} {
+			_, err := ParseSignedCredentials(s)
+			require.Error(t, err, s)
+		}
+	})
+}
+
+func TestAuthenticateSigned(t *testing.T) {
+	dbStore, teardown := newTestDBStore(t)
+	defer teardown()
+	sessionCache := newTestSessionCache(t)
+
+	s, err := NewService(dbStore, sessionCache, WithSignedAuth())
+	require.NoError(t, err)
+
+	authKey, err := newRandomString(MinKeyLen)
+	require.NoError(t, err)
+	require.NoError(t, s.Register("clientA", authKey))
+
+	maxSkew := 5 * time.Minute
+
+	t.Run("disabled", func(t *testing.T) {
+		disabled, err := NewService(dbStore, sessionCache)
+		require.NoError(t, err)
+		creds, err := NewSignedCredentials("clientA", authKey, time.Now())
+		require.NoError(t, err)
+		require.EqualError(t, disabled.AuthenticateSigned(creds, maxSkew), "authentication failed: signed credentials are disabled")
+
+		// No signing key is kept when disabled.
+		authKeyB, err := newRandomString(MinKeyLen)
+		require.NoError(t, err)
+		require.NoError(t, disabled.Register("clientB", authKeyB))
+		require.NoError(t, disabled.Authenticate("clientB", authKeyB))
+		_, err = dbStore.Get(signingKeyStoreKey("clientB"))
+		require.ErrorIs(t, err, store.ErrNotFound)
+		require.NoError(t, disabled.Unregister("clientB"))
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		creds, err := NewSignedCredentials("clientA", authKey, time.Now())
+		require.NoError(t, err)
+		require.NoError(t, s.AuthenticateSigned(creds, maxSkew))
+	})
+
+	t.Run("replayed", func(t *testing.T) {
+		creds, err := NewSignedCredentials("clientA", authKey, time.Now())
+		require.NoError(t, err)
+		require.NoError(t, s.AuthenticateSigned(creds, maxSkew))
+		require.EqualError(t, s.AuthenticateSigned(creds, maxSkew), "authentication failed: credentials were already used")
+
+		// Used nonces survive a restart.
+		restarted, err := NewService(dbStore, sessionCache, WithSignedAuth())
+		require.NoError(t, err)
+		require.EqualError(t, restarted.AuthenticateSigned(creds, maxSkew), "authentication failed: credentials were already used")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		creds, err := NewSignedCredentials("clientA", authKey, time.Now().Add(-2*maxSkew))
+		require.NoError(t, err)
+		require.EqualError(t, s.AuthenticateSigned(creds, maxSkew), "authentication failed: timestamp is outside the allowed window")
+
+		creds, err = NewSignedCredentials("clientA", authKey, time.Now().Add(2*maxSkew))
+		require.NoError(t, err)
+		require.EqualError(t, s.AuthenticateSigned(creds, maxSkew), "authentication failed: timestamp is outside the allowed window")
+	})
+
+	t.Run("bad signature", func(t *testing.T) {
+		creds, err := NewSignedCredentials("clientA", authKey+" ", time.Now())
+		require.NoError(t, err)
+		require.EqualError(t, s.AuthenticateSigned(creds, maxSkew), "authentication failed: bad signature")
+
+		creds, err = NewSignedCredentials("clientA", authKey, time.Now())
+		require.NoError(t, err)
+		creds.Timestamp++
+		require.EqualError(t, s.AuthenticateSigned(creds, maxSkew), "authentication failed: bad signature")
+	})
+
+	t.Run("unregistered", func(t *testing.T) {
+		creds, err := NewSignedCredentials("clientB", authKey, time.Now())
+		require.NoError(t, err)
+		require.EqualError(t, s.AuthenticateSigned(creds, maxSkew), "authentication failed: error: not found")
+	})
+
+	t.Run("missing signing key", func(t *testing.T) {
+		// Simulating a client registered before signed credentials were
+		// supported.
+		require.NoError(t, dbStore.Delete(signingKeyStoreKey("clientA")))
+		creds, err := NewSignedCredentials("clientA", authKey, time.Now())
+		require.NoError(t, err)
+		require.EqualError(t, s.AuthenticateSigned(creds, maxSkew), "authentication failed: no signing key, authenticate with the auth key first")
+
+		require.NoError(t, s.Authenticate("clientA", authKey))
+		require.NoError(t, s.AuthenticateSigned(creds, maxSkew))
+	})
+
+	t.Run("unregister", func(t *testing.T) {
+		require.NoError(t, s.Unregister("clientA"))
+		_, err := dbStore.Get(signingKeyStoreKey("clientA"))
+		require.Error(t, err)
+	})
+}
+
+func TestNonceCache(t *testing.T) {
+	dbStore, teardown := newTestDBStore(t)
+	defer teardown()
+
+	c := newNonceCache(dbStore)
+	now := time.Now()
+
+	add := func(nonce string, expiresAt, now time.Time) bool {
+		t.Helper()
+		ok, err := c.add(nonce, expiresAt, now)
+		require.NoError(t, err)
+		return ok
+	}
+
+	require.True(t, add("a", now.Add(time.Minute), now))
+	require.False(t, add("a", now.Add(time.Minute), now))
+	require.True(t, add("b", now.Add(time.Minute), now))
+
+	// Expired nonces can be added again and are eventually pruned.
+	later := now.Add(2 * noncePruneInterval)
+	require.True(t, add("a", later.Add(time.Minute), later))
+	keys, err := dbStore.Keys()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{nonceStoreKey("a")}, keys)
+}
+
+func TestDeleteSigningKeys(t *testing.T) {
+	dbStore, teardown := newTestDBStore(t)
+	defer teardown()
+	sessionCache := newTestSessionCache(t)
+
+	s, err := NewService(dbStore, sessionCache, WithSignedAuth())
+	require.NoError(t, err)
+
+	authKey, err := newRandomString(MinKeyLen)
+	require.NoError(t, err)
+	require.NoError(t, s.Register("clientA", authKey))
+
+	require.NoError(t, DeleteSigningKeys(dbStore))
+	keys, err := dbStore.Keys()
+	require.NoError(t, err)
+	require.Equal(t, []string{"clientA"}, keys)
+
+	// The signing key is derived again on authentication.
+	require.NoError(t, s.Authenticate("clientA", authKey))
+	_, err = dbStore.Get(signingKeyStoreKey("clientA"))
+	require.NoError(t, err)
+}
@@ -10,9 +10,12 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
+	"github.com/mattermost/rtcd/service/auth"
 	"github.com/mattermost/rtcd/service/ws"
 
 	"github.com/stretchr/testify/require"
@@ -54,6 +57,94 @@ func TestRegisterClient(t *testing.T) {
 	})
 }
 
+func TestRegisterClientWithProvider(t *testing.T) {
+	validator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch {
+		case req["clientID"] == "unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case req["username"] == req["clientID"] && req["password"] == "secret",
+			req["token"] == req["clientID"]+"-token":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer validator.Close()
+
+	cfg := MakeDefaultCfg(t)
+	cfg.API.Security.RegistrationAuth.Provider = auth.ProviderHTTP
+	cfg.API.Security.RegistrationAuth.TimeoutSeconds = 5
+	cfg.API.Security.RegistrationAuth.HTTP.URL = validator.URL
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	register := func(t *testing.T, clientID string, setAuth func(req *http.Request)) *http.Response {
+		t.Helper()
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"clientID": %q, "authKey": "Ey4-H_BJA00_TVByPi8DozE12ekN3S7L"}`, clientID))
+		req, err := http.NewRequest("POST", th.apiURL+"/register", buf)
+		require.NoError(t, err)
+		setAuth(req)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("basic credentials", func(t *testing.T) {
+		resp := register(t, "clientA", func(req *http.Request) {
+			req.SetBasicAuth("clientA", "secret")
+		})
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	})
+
+	t.Run("bearer token", func(t *testing.T) {
+		resp := register(t, "clientB", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer clientB-token")
+		})
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	})
+
+	t.Run("rejected credentials", func(t *testing.T) {
+		resp := register(t, "clientC", func(req *http.Request) {
+			req.SetBasicAuth("clientC", "wrong")
+		})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp = register(t, "clientC", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer clientA-token")
+		})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("registered client credentials", func(t *testing.T) {
+		// Credentials of registered clients don't allow registering others,
+		// the provider being in charge.
+		resp := register(t, "clientD", func(req *http.Request) {
+			req.SetBasicAuth("clientA", "Ey4-H_BJA00_TVByPi8DozE12ekN3S7L")
+		})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("provider unavailable", func(t *testing.T) {
+		resp := register(t, "unavailable", func(req *http.Request) {
+			req.SetBasicAuth("unavailable", "secret")
+		})
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	})
+
+	t.Run("admin credentials", func(t *testing.T) {
+		resp := register(t, "clientE", func(req *http.Request) {
+			req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		})
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	})
+}
+
 func TestUnregisterClient(t *testing.T) {
 	t.Run("invalid method", func(t *testing.T) {
 		th := SetupTestHelper(t, nil)
@@ -295,6 +386,95 @@ func TestWSAuthHandler(t *testing.T) {
 	})
 }
 
+func TestWSSignedAuth(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.Store.EncryptionKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+	cfg.API.Security.SignedAuth.Enable = true
+	cfg.API.Security.SignedAuth.MaxClockSkewSeconds = 300
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	_, port, err := net.SplitHostPort(th.srvc.apiServer.Addr())
+	require.NoError(t, err)
+	wsURL := url.URL{Scheme: "ws", Host: "localhost:" + port, Path: "/ws"}
+
+	clientID := "clientA"
+	authKey := "Ey4-H_BJA00_TVByPi8DozE12ekN3S7L"
+	registerClient(t, th, clientID, authKey)
+
+	connect := func(creds auth.SignedCredentials) (*ws.Client, error) {
+		return ws.NewClient(ws.ClientConfig{
+			URL:        wsURL.String(),
+			AuthScheme: auth.SignedAuthScheme,
+			AuthToken:  creds.String(),
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		creds, err := auth.NewSignedCredentials(clientID, authKey, time.Now())
+		require.NoError(t, err)
+		wsClient, err := connect(creds)
+		require.NoError(t, err)
+		require.NotNil(t, wsClient)
+		require.NoError(t, wsClient.Close())
+
+		// Credentials can't be replayed.
+		wsClient, err = connect(creds)
+		require.Error(t, err)
+		require.Nil(t, wsClient)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		creds, err := auth.NewSignedCredentials(clientID, authKey, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		wsClient, err := connect(creds)
+		require.Error(t, err)
+		require.Nil(t, wsClient)
+	})
+
+	t.Run("bad key", func(t *testing.T) {
+		creds, err := auth.NewSignedCredentials(clientID, authKey+"x", time.Now())
+		require.NoError(t, err)
+		wsClient, err := connect(creds)
+		require.Error(t, err)
+		require.Nil(t, wsClient)
+	})
+
+	t.Run("required", func(t *testing.T) {
+		th.srvc.cfg.API.Security.SignedAuth.Require = true
+		defer func() {
+			th.srvc.cfg.API.Security.SignedAuth.Require = false
+		}()
+
+		wsClient, err := ws.NewClient(ws.ClientConfig{
+			URL:       wsURL.String(),
+			AuthToken: base64.StdEncoding.EncodeToString([]byte(clientID + ":" + authKey)),
+		})
+		require.Error(t, err)
+		require.Nil(t, wsClient)
+
+		// Bearer tokens can't be obtained with the bare auth key either.
+		buf := bytes.NewBuffer([]byte(fmt.Sprintf(`{"clientID": "%s", "authKey": "%s"}`, clientID, authKey)))
+		resp, err := http.Post(th.apiURL+"/login", "application/json", buf)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		c, err := NewClient(ClientConfig{
+			URL:        th.apiURL,
+			ClientID:   clientID,
+			AuthKey:    authKey,
+			SignedAuth: true,
+		})
+		require.NoError(t, err)
+		require.NoError(t, c.Connect())
+		defer c.Close()
+		msg, ok := <-c.ReceiveCh()
+		require.True(t, ok)
+		require.Equal(t, ClientMessageHello, msg.Type)
+	})
+}
+
 func registerClient(t *testing.T, th *TestHelper, clientID string, authKey string) {
 	bufStr := fmt.Sprintf(`{"clientID": "%s", "authKey": "%s"}`, clientID, authKey)
 	buf := bytes.NewBuffer([]byte(bufStr))
@@ -311,3 +491,17 @@ func registerClient(t *testing.T, th *TestHelper, clientID string, authKey strin
 	require.NoError(t, err)
 	require.NotEmpty(t, response["clientID"])
 }
+
+func TestRegisterClientEncryptedStore(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.Store.EncryptionKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	authKey := "Ey4-H_BJA00_TVByPi8DozE12ekN3S7L"
+	err := th.adminClient.Register("clientA", authKey)
+	require.NoError(t, err)
+
+	err = th.srvc.auth.Authenticate("clientA", authKey)
+	require.NoError(t, err)
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package autoscaling
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/mattermost/rtcd/service/broker"
+)
+
+const (
+	BackendNATS  = broker.NATS
+	BackendRedis = broker.Redis
+)
+
+type Config struct {
+	// Backend is the pub/sub system the signals are published to ("nats" or
+	// "redis"). Publishing is disabled if empty.
+	Backend string `toml:"backend"`
+	// Address is the TCP address (host:port) of the NATS or Redis server.
+	Address string `toml:"address"`
+	// Subject is the NATS subject, or Redis channel, the signals are
+	// published to.
+	Subject string `toml:"subject"`
+	// Username is the optional username to authenticate with.
+	Username string `toml:"username"`
+	// Password is the optional password to authenticate with. With NATS, it's
+	// sent as a token if no username is set.
+	Password string `toml:"password"`
+	// IntervalSeconds is the interval at which the signals are published.
+	// They are also published as soon as calls start or end, or sessions
+	// join or leave.
+	IntervalSeconds int `toml:"interval_seconds"`
+	// TimeoutSeconds is the timeout applied to connecting and publishing.
+	TimeoutSeconds int `toml:"timeout_seconds"`
+}
+
+func (c Config) IsEnabled() bool {
+	return c.Backend != ""
+}
+
+func (c Config) IsValid() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+
+	if c.Backend != BackendNATS && c.Backend != BackendRedis {
+		return fmt.Errorf("invalid Backend value: should be either %q or %q", BackendNATS, BackendRedis)
+	}
+
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return fmt.Errorf("invalid Address value: %w", err)
+	}
+
+	if c.Subject == "" {
+		return fmt.Errorf("invalid Subject value: should not be empty")
+	}
+	if strings.ContainsAny(c.Subject, " \t\r\n") {
+		return fmt.Errorf("invalid Subject value: should not contain whitespace")
+	}
+
+	if c.IntervalSeconds <= 0 {
+		return fmt.Errorf("invalid IntervalSeconds value: should be a positive number")
+	}
+
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("invalid TimeoutSeconds value: should be a positive number")
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package autoscaling
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg Config
+		require.False(t, cfg.IsEnabled())
+		require.NoError(t, cfg.IsValid())
+	})
+
+	validCfg := Config{
+		Backend:         BackendNATS,
+		Address:         "localhost:4222",
+		Subject:         "rtcd.autoscaling",
+		IntervalSeconds: 10,
+		TimeoutSeconds:  5,
+	}
+
+	t.Run("invalid Backend", func(t *testing.T) {
+		cfg := validCfg
+		cfg.Backend = "kafka"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid Backend value: should be either "nats" or "redis"`, err.Error())
+	})
+
+	t.Run("invalid Address", func(t *testing.T) {
+		cfg := validCfg
+		cfg.Address = "localhost"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Address value: address localhost: missing port in address", err.Error())
+	})
+
+	t.Run("invalid Subject", func(t *testing.T) {
+		cfg := validCfg
+		cfg.Subject = ""
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Subject value: should not be empty", err.Error())
+
+		cfg.Subject = "rtcd autoscaling"
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Subject value: should not contain whitespace", err.Error())
+	})
+
+	t.Run("invalid IntervalSeconds", func(t *testing.T) {
+		cfg := validCfg
+		cfg.IntervalSeconds = 0
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid IntervalSeconds value: should be a positive number", err.Error())
+	})
+
+	t.Run("invalid TimeoutSeconds", func(t *testing.T) {
+		cfg := validCfg
+		cfg.TimeoutSeconds = 0
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TimeoutSeconds value: should be a positive number", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		require.True(t, validCfg.IsEnabled())
+		require.NoError(t, validCfg.IsValid())
+
+		cfg := validCfg
+		cfg.Backend = BackendRedis
+		require.NoError(t, cfg.IsValid())
+	})
+}
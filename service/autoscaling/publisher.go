@@ -0,0 +1,168 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package autoscaling publishes the load signals of the node to a pub/sub
+// system (NATS or Redis), so that orchestration can scale the fleet ahead of
+// the load rather than reacting to the CPU usage alone.
+package autoscaling
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/service/broker"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// minNotifyInterval bounds the rate at which notifications trigger
+// publishing, coalescing bursts (e.g. many sessions joining at once).
+const minNotifyInterval = time.Second
+
+// Signals are the load signals of the node.
+type Signals struct {
+	// Node identifies the node, e.g. by its hostname.
+	Node string `json:"node"`
+	// Timestamp is the time the signals were taken at, in Unix milliseconds.
+	Timestamp int64 `json:"timestamp"`
+	Calls     int   `json:"calls"`
+	Sessions  int   `json:"sessions"`
+	// PacketRate is the number of UDP packets received and sent per second.
+	PacketRate    float64 `json:"packet_rate"`
+	BandwidthMbps float64 `json:"bandwidth_mbps"`
+	// CapacityPercent is the percentage of the capacity of the node in use.
+	CapacityPercent float64 `json:"capacity_percent"`
+	Busy            bool    `json:"busy"`
+	// Draining is set once the node stopped accepting new sessions.
+	Draining bool `json:"draining"`
+}
+
+// Publisher periodically publishes the signals returned by getSignals, and
+// as soon as notified of a change. Connections are established lazily and
+// re-established after failures, signals being published on a best effort
+// basis.
+type Publisher struct {
+	cfg        Config
+	log        mlog.LoggerIFace
+	getSignals func() Signals
+	conn       broker.Conn
+	notifyCh   chan struct{}
+	closeCh    chan struct{}
+	wg         sync.WaitGroup
+}
+
+func NewPublisher(cfg Config, log mlog.LoggerIFace, getSignals func() Signals) (*Publisher, error) {
+	if err := cfg.IsValid(); err != nil {
+		return nil, fmt.Errorf("failed to validate config: %w", err)
+	}
+	if log == nil {
+		return nil, fmt.Errorf("log should not be nil")
+	}
+	if getSignals == nil {
+		return nil, fmt.Errorf("getSignals should not be nil")
+	}
+
+	p := &Publisher{
+		cfg:        cfg,
+		log:        log,
+		getSignals: getSignals,
+		notifyCh:   make(chan struct{}, 1),
+		closeCh:    make(chan struct{}),
+	}
+
+	p.wg.Add(1)
+	go p.worker()
+
+	return p, nil
+}
+
+// Notify requests the signals to be published, e.g. because a call started.
+// It does not block.
+func (p *Publisher) Notify() {
+	select {
+	case p.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// Close publishes the signals one last time, so that a draining node is
+// reported, and closes the connection.
+func (p *Publisher) Close() {
+	close(p.closeCh)
+	p.wg.Wait()
+
+	if err := p.publish(); err != nil {
+		p.log.Error("failed to publish autoscaling signals", mlog.Err(err))
+	}
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+func (p *Publisher) worker() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(p.cfg.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	var lastPublish time.Time
+	// pendingCh fires once a notification held back by minNotifyInterval
+	// can be served.
+	var pendingCh <-chan time.Time
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.notifyCh:
+			if pendingCh != nil {
+				continue
+			}
+			if wait := minNotifyInterval - time.Since(lastPublish); wait > 0 {
+				pendingCh = time.After(wait)
+				continue
+			}
+		case <-pendingCh:
+			pendingCh = nil
+		case <-p.closeCh:
+			return
+		}
+
+		lastPublish = time.Now()
+		if err := p.publish(); err != nil {
+			p.log.Error("failed to publish autoscaling signals", mlog.Err(err))
+		}
+	}
+}
+
+// publish sends the current signals. Only called by the worker, or once it
+// returned.
+func (p *Publisher) publish() error {
+	signals := p.getSignals()
+	signals.Timestamp = time.Now().UnixMilli()
+	data, err := json.Marshal(signals)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signals: %w", err)
+	}
+
+	if p.conn == nil {
+		p.conn, err = broker.Dial(p.cfg.Backend, broker.Options{
+			Address:  p.cfg.Address,
+			Username: p.cfg.Username,
+			Password: p.cfg.Password,
+			Timeout:  time.Duration(p.cfg.TimeoutSeconds) * time.Second,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to connect to %s server: %w", p.cfg.Backend, err)
+		}
+	}
+
+	if err := p.conn.Publish(p.cfg.Subject, "", data); err != nil {
+		p.conn.Close()
+		p.conn = nil
+		return err
+	}
+
+	return nil
+}
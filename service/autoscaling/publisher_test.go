@@ -0,0 +1,134 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package autoscaling
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/broker/brokertest"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		err := log.Shutdown()
+		require.NoError(t, err)
+	}()
+
+	var calls int32
+	getSignals := func() Signals {
+		return Signals{Node: "node", Calls: int(atomic.LoadInt32(&calls))}
+	}
+
+	receive := func(t *testing.T, msgCh <-chan brokertest.Message) Signals {
+		t.Helper()
+		select {
+		case msg := <-msgCh:
+			require.Equal(t, "rtcd.autoscaling", msg.Topic)
+			var signals Signals
+			require.NoError(t, json.Unmarshal([]byte(msg.Data), &signals))
+			require.Positive(t, signals.Timestamp)
+			return signals
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for signals")
+			return Signals{}
+		}
+	}
+
+	t.Run("invalid config", func(t *testing.T) {
+		p, err := NewPublisher(Config{Backend: BackendNATS}, log, getSignals)
+		require.Error(t, err)
+		require.Nil(t, p)
+	})
+
+	t.Run("nats", func(t *testing.T) {
+		s, err := brokertest.NewNATSServer("", "")
+		require.NoError(t, err)
+		defer s.Close()
+		p, err := NewPublisher(Config{
+			Backend:         BackendNATS,
+			Address:         s.Addr(),
+			Subject:         "rtcd.autoscaling",
+			IntervalSeconds: 3600,
+			TimeoutSeconds:  5,
+		}, log, getSignals)
+		require.NoError(t, err)
+
+		// Notifications publish right away.
+		atomic.StoreInt32(&calls, 1)
+		p.Notify()
+		signals := receive(t, s.Messages())
+		require.Equal(t, "node", signals.Node)
+		require.Equal(t, 1, signals.Calls)
+
+		// Bursts are coalesced.
+		atomic.StoreInt32(&calls, 2)
+		for i := 0; i < 10; i++ {
+			p.Notify()
+		}
+		require.Equal(t, 2, receive(t, s.Messages()).Calls)
+		select {
+		case <-s.Messages():
+			require.FailNow(t, "unexpected signals")
+		case <-time.After(2 * minNotifyInterval):
+		}
+
+		// Closing publishes the signals one last time.
+		atomic.StoreInt32(&calls, 0)
+		p.Close()
+		require.Zero(t, receive(t, s.Messages()).Calls)
+	})
+
+	t.Run("redis", func(t *testing.T) {
+		s, err := brokertest.NewRedisServer("")
+		require.NoError(t, err)
+		defer s.Close()
+		p, err := NewPublisher(Config{
+			Backend:         BackendRedis,
+			Address:         s.Addr(),
+			Subject:         "rtcd.autoscaling",
+			IntervalSeconds: 1,
+			TimeoutSeconds:  5,
+		}, log, getSignals)
+		require.NoError(t, err)
+		defer p.Close()
+
+		// Signals are published periodically.
+		receive(t, s.Messages())
+		receive(t, s.Messages())
+	})
+
+	t.Run("reconnect", func(t *testing.T) {
+		s, err := brokertest.NewNATSServer("", "")
+		require.NoError(t, err)
+		defer s.Close()
+		p, err := NewPublisher(Config{
+			Backend:         BackendNATS,
+			Address:         s.Addr(),
+			Subject:         "rtcd.autoscaling",
+			IntervalSeconds: 3600,
+			TimeoutSeconds:  5,
+		}, log, getSignals)
+		require.NoError(t, err)
+		defer p.Close()
+
+		p.Notify()
+		receive(t, s.Messages())
+
+		// A failed publish drops the connection, the next one dials again.
+		s.DropConns()
+		p.Notify()
+		time.Sleep(2 * minNotifyInterval)
+		p.Notify()
+		receive(t, s.Messages())
+		require.Equal(t, 2, s.Connects())
+	})
+}
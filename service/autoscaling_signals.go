@@ -0,0 +1,42 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"os"
+
+	"github.com/mattermost/rtcd/service/autoscaling"
+	"github.com/mattermost/rtcd/service/rtc"
+)
+
+// getAutoscalingSignals returns the load signals of the node published to
+// the autoscaling target.
+func (s *Service) getAutoscalingSignals() autoscaling.Signals {
+	// Errors leave the node unnamed, the signals remain useful in aggregate.
+	hostname, _ := os.Hostname()
+	capacity := s.rtcServer.GetCapacity()
+	signals := autoscaling.Signals{
+		Node:            hostname,
+		PacketRate:      capacity.PacketRate,
+		BandwidthMbps:   capacity.BandwidthMbps,
+		CapacityPercent: (1 - capacity.Headroom) * 100,
+		Busy:            capacity.Busy,
+		Draining:        s.rtcServer.IsDraining(),
+	}
+	for _, st := range s.rtcServer.GetCallsStats() {
+		signals.Calls++
+		signals.Sessions += st.Sessions
+	}
+	return signals
+}
+
+// isAutoscalingEvent returns whether the event changes the load signals
+// enough to publish them right away.
+func isAutoscalingEvent(ev rtc.Event) bool {
+	switch ev.Type {
+	case rtc.CallStartedEvent, rtc.CallEndedEvent, rtc.SessionJoinedEvent, rtc.SessionLeftEvent:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAutoscalingSignals(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	signals := th.srvc.getAutoscalingSignals()
+	require.NotEmpty(t, signals.Node)
+	require.Zero(t, signals.Calls)
+	require.Zero(t, signals.Sessions)
+	require.Zero(t, signals.CapacityPercent)
+	require.False(t, signals.Draining)
+
+	cfg := rtc.SessionConfig{
+		GroupID:   "groupID",
+		CallID:    "callID",
+		UserID:    "userID",
+		SessionID: "sessionID",
+	}
+	require.NoError(t, th.srvc.rtcServer.InitSession(cfg, nil))
+	defer func() {
+		require.NoError(t, th.srvc.rtcServer.CloseSession(cfg.SessionID))
+	}()
+
+	signals = th.srvc.getAutoscalingSignals()
+	require.Equal(t, 1, signals.Calls)
+	require.Equal(t, 1, signals.Sessions)
+}
+
+func TestIsAutoscalingEvent(t *testing.T) {
+	require.True(t, isAutoscalingEvent(rtc.Event{Type: rtc.CallStartedEvent}))
+	require.True(t, isAutoscalingEvent(rtc.Event{Type: rtc.SessionLeftEvent}))
+	require.False(t, isAutoscalingEvent(rtc.Event{Type: rtc.SessionMutedEvent}))
+}
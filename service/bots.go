@@ -0,0 +1,635 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/pion/webrtc/v3/pkg/media/oggreader"
+)
+
+// BotType is the kind of automation a bot performs in a call.
+type BotType string
+
+const (
+	// BotTypeAnnouncement joins the call as a participant, plays an audio
+	// file and leaves.
+	BotTypeAnnouncement BotType = "announcement"
+	// BotTypeObserver joins the call as a hidden participant and collects
+	// the statistics of the media it receives.
+	BotTypeObserver BotType = "observer"
+	// BotTypeBridge joins a call as a hidden participant and forwards the
+	// audio it receives to another call, where it takes part as a regular
+	// participant.
+	BotTypeBridge BotType = "bridge"
+)
+
+const (
+	botUserIDPrefix = "rtcd-bot-"
+	// The reasons reported for a bot to stop.
+	botStopReasonFinished   = "finished"
+	botStopReasonStopped    = "stopped"
+	botStopReasonExpired    = "expired"
+	botStopReasonCallEnded  = "call ended"
+	botStopReasonClosed     = "session closed"
+	botStopReasonShutdown   = "shutdown"
+	botStopReasonConnFailed = "connection failed"
+	botConnectTimeout       = 30 * time.Second
+	// botReceiveMTU is the size of the buffer observer bots read packets
+	// into.
+	botReceiveMTU = 1460
+	// opusClockRate is the rate of the Ogg/Opus granule positions,
+	// regardless of the input sample rate.
+	opusClockRate = 48000
+)
+
+// callCheckInterval is the interval at which the bots and mirrors check
+// whether the calls they are in have ended.
+var callCheckInterval = 5 * time.Second
+
+// BotConfig holds the settings of a bot to spawn.
+type BotConfig struct {
+	Type    BotType
+	GroupID string
+	CallID  string
+	// Announcement is the name of the file, in the configured announcements
+	// directory, played by an announcement bot.
+	Announcement string
+	// TargetGroupID and TargetCallID identify the call a bridge bot forwards
+	// the media to.
+	TargetGroupID string
+	TargetCallID  string
+	// Duration is the time after which the bot gets stopped. Zero means the
+	// configured maximum, if any.
+	Duration time.Duration
+}
+
+func (c BotConfig) IsValid() error {
+	switch c.Type {
+	case BotTypeAnnouncement:
+		if c.Announcement == "" {
+			return fmt.Errorf("invalid Announcement value: should not be empty")
+		}
+		if c.Announcement != filepath.Base(c.Announcement) {
+			return fmt.Errorf("invalid Announcement value: should be a file name")
+		}
+	case BotTypeObserver:
+	case BotTypeBridge:
+		if c.TargetCallID == "" {
+			return fmt.Errorf("invalid TargetCallID value: should not be empty")
+		}
+		if c.TargetGroupID == c.GroupID && c.TargetCallID == c.CallID {
+			return fmt.Errorf("invalid TargetCallID value: should not be the bridged call")
+		}
+	default:
+		return fmt.Errorf("invalid Type value: %q", c.Type)
+	}
+	if c.CallID == "" {
+		return fmt.Errorf("invalid CallID value: should not be empty")
+	}
+	if c.Duration < 0 {
+		return fmt.Errorf("invalid Duration value: should not be negative")
+	}
+	return nil
+}
+
+// MediaStats holds the media counters of a bot or a mirror: the tracks and
+// packets it received (observer, mirror), forwarded (bridge) or sent
+// (announcement).
+type MediaStats struct {
+	Tracks  int64 `json:"tracks"`
+	Packets int64 `json:"packets"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// mediaCounters accumulates MediaStats from concurrent tracks.
+type mediaCounters struct {
+	tracks  int64
+	packets int64
+	bytes   int64
+}
+
+func (c *mediaCounters) addTrack() {
+	atomic.AddInt64(&c.tracks, 1)
+}
+
+func (c *mediaCounters) addMedia(packets, bytes int) {
+	atomic.AddInt64(&c.packets, int64(packets))
+	atomic.AddInt64(&c.bytes, int64(bytes))
+}
+
+func (c *mediaCounters) getStats() MediaStats {
+	return MediaStats{
+		Tracks:  atomic.LoadInt64(&c.tracks),
+		Packets: atomic.LoadInt64(&c.packets),
+		Bytes:   atomic.LoadInt64(&c.bytes),
+	}
+}
+
+// BotInfo describes a running or stopped bot.
+type BotInfo struct {
+	ID            string   `json:"id"`
+	Type          BotType  `json:"type"`
+	GroupID       string   `json:"group_id"`
+	CallID        string   `json:"call_id"`
+	Announcement  string   `json:"announcement,omitempty"`
+	TargetGroupID string   `json:"target_group_id,omitempty"`
+	TargetCallID  string   `json:"target_call_id,omitempty"`
+	SessionIDs    []string `json:"session_ids"`
+	StartedAt     int64    `json:"started_at"`
+	StoppedAt     int64    `json:"stopped_at,omitempty"`
+	// Reason is why the bot was stopped.
+	Reason string     `json:"reason,omitempty"`
+	Stats  MediaStats `json:"stats"`
+}
+
+// bot is an in-process automation taking part in one or two calls through
+// local peers.
+type bot struct {
+	cfg   BotConfig
+	info  BotInfo
+	peers []*localPeer
+	// calls lists the group and call IDs of the calls the bot takes part
+	// in.
+	calls [][2]string
+
+	mediaCounters
+
+	mut      sync.Mutex
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	reason   string
+}
+
+// requestStop asks the bot to stop for the given reason. Only the first
+// reason is kept. It doesn't block.
+func (b *bot) requestStop(reason string) {
+	b.stopOnce.Do(func() {
+		b.mut.Lock()
+		b.reason = reason
+		b.mut.Unlock()
+		close(b.stopCh)
+	})
+}
+
+func (b *bot) getInfo() BotInfo {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	info := b.info
+	info.SessionIDs = append([]string(nil), b.info.SessionIDs...)
+	info.Stats = b.getStats()
+	return info
+}
+
+// startBot spawns a bot with the given config, returning its info once its
+// sessions are initialized.
+func (s *Service) startBot(cfg BotConfig) (BotInfo, error) {
+	if cfg.Type == BotTypeBridge && cfg.TargetGroupID == "" {
+		cfg.TargetGroupID = cfg.GroupID
+	}
+	if err := cfg.IsValid(); err != nil {
+		return BotInfo{}, err
+	}
+
+	if maxDuration := time.Duration(s.cfg.Bots.MaxDurationMinutes) * time.Minute; maxDuration > 0 {
+		if cfg.Duration == 0 || cfg.Duration > maxDuration {
+			cfg.Duration = maxDuration
+		}
+	}
+
+	var announcement string
+	if cfg.Type == BotTypeAnnouncement {
+		if s.cfg.Bots.AnnouncementsDir == "" {
+			return BotInfo{}, fmt.Errorf("announcements are not configured")
+		}
+		announcement = filepath.Join(s.cfg.Bots.AnnouncementsDir, cfg.Announcement)
+		if _, err := os.Stat(announcement); err != nil {
+			return BotInfo{}, fmt.Errorf("failed to find announcement: %w", err)
+		}
+	}
+
+	b := &bot{
+		cfg: cfg,
+		info: BotInfo{
+			ID:            random.NewID(),
+			Type:          cfg.Type,
+			GroupID:       cfg.GroupID,
+			CallID:        cfg.CallID,
+			Announcement:  cfg.Announcement,
+			TargetGroupID: cfg.TargetGroupID,
+			TargetCallID:  cfg.TargetCallID,
+			StartedAt:     time.Now().UnixMilli(),
+		},
+		calls:  [][2]string{{cfg.GroupID, cfg.CallID}},
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+	if cfg.Type == BotTypeBridge {
+		b.calls = append(b.calls, [2]string{cfg.TargetGroupID, cfg.TargetCallID})
+	}
+
+	// Bots only join ongoing calls.
+	for _, c := range b.calls {
+		if _, err := s.rtcServer.GetCallState(c[0], c[1]); err != nil {
+			return BotInfo{}, err
+		}
+	}
+
+	s.mut.Lock()
+	if len(s.bots) >= s.cfg.Bots.MaxCount {
+		s.mut.Unlock()
+		return BotInfo{}, fmt.Errorf("too many bots: the limit is %d", s.cfg.Bots.MaxCount)
+	}
+	s.bots[b.info.ID] = b
+	s.mut.Unlock()
+
+	if err := s.initBot(b, announcement); err != nil {
+		s.teardownBot(b)
+		s.mut.Lock()
+		delete(s.bots, b.info.ID)
+		s.mut.Unlock()
+		close(b.doneCh)
+		return BotInfo{}, err
+	}
+
+	go s.superviseBot(b)
+
+	s.log.Info("bot started", mlog.String("botID", b.info.ID), mlog.String("type", string(cfg.Type)),
+		mlog.String("groupID", cfg.GroupID), mlog.String("callID", cfg.CallID))
+
+	return b.getInfo(), nil
+}
+
+// addBotPeer creates a local peer for the bot in the given call.
+func (s *Service) addBotPeer(b *bot, groupID, callID string, hidden bool) (*localPeer, error) {
+	cfg := rtc.SessionConfig{
+		GroupID:   groupID,
+		CallID:    callID,
+		UserID:    botUserIDPrefix + b.info.ID,
+		SessionID: random.NewID(),
+		Hidden:    hidden,
+	}
+	p, err := newLocalPeer(cfg, s.rtcServer, s.log)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mut.Lock()
+	b.peers = append(b.peers, p)
+	b.info.SessionIDs = append(b.info.SessionIDs, cfg.SessionID)
+	b.mut.Unlock()
+
+	s.mut.Lock()
+	s.localPeers[cfg.SessionID] = p
+	s.mut.Unlock()
+
+	return p, nil
+}
+
+// initBot creates the peers of the bot and joins them to their calls.
+func (s *Service) initBot(b *bot, announcement string) error {
+	var err error
+	switch b.cfg.Type {
+	case BotTypeAnnouncement:
+		err = s.initAnnouncementBot(b, announcement)
+	case BotTypeObserver:
+		err = s.initObserverBot(b)
+	case BotTypeBridge:
+		err = s.initBridgeBot(b)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, p := range b.peers {
+		if err := s.rtcServer.InitSession(p.cfg, func(_ string) error {
+			b.requestStop(botStopReasonClosed)
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to initialize rtc session: %w", err)
+		}
+		p.renegotiate()
+	}
+
+	return nil
+}
+
+func (s *Service) initAnnouncementBot(b *bot, path string) error {
+	p, err := s.addBotPeer(b, b.cfg.GroupID, b.cfg.CallID, false)
+	if err != nil {
+		return err
+	}
+
+	track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", random.NewID())
+	if err != nil {
+		return fmt.Errorf("failed to create audio track: %w", err)
+	}
+	if _, err := p.pc.AddTrack(track); err != nil {
+		return fmt.Errorf("failed to add track: %w", err)
+	}
+	b.addTrack()
+
+	go func() {
+		select {
+		case <-p.connCh:
+		case <-b.stopCh:
+			return
+		case <-time.After(botConnectTimeout):
+			b.requestStop(botStopReasonConnFailed)
+			return
+		}
+		if err := playAnnouncement(b, track, path); err != nil {
+			s.log.Error("failed to play announcement", mlog.Err(err), mlog.String("botID", b.info.ID))
+		}
+		b.requestStop(botStopReasonFinished)
+	}()
+
+	return nil
+}
+
+// playAnnouncement writes the pages of the Ogg/Opus file at path to the
+// track, paced according to their granule positions.
+func playAnnouncement(b *bot, track *webrtc.TrackLocalStaticSample, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open announcement: %w", err)
+	}
+	defer f.Close()
+
+	reader, _, err := oggreader.NewWith(f)
+	if err != nil {
+		return fmt.Errorf("failed to read announcement: %w", err)
+	}
+
+	start := time.Now()
+	var elapsed time.Duration
+	var lastGranule uint64
+	for {
+		page, pageHeader, err := reader.ParseNextPage()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to parse page: %w", err)
+		}
+
+		// The comment header page carries no audio.
+		if pageHeader.GranulePosition <= lastGranule {
+			continue
+		}
+		duration := time.Duration((pageHeader.GranulePosition-lastGranule)*uint64(time.Second)) / opusClockRate
+		lastGranule = pageHeader.GranulePosition
+
+		if err := track.WriteSample(media.Sample{Data: page, Duration: duration}); err != nil {
+			return fmt.Errorf("failed to write sample: %w", err)
+		}
+		b.addMedia(1, len(page))
+
+		elapsed += duration
+		select {
+		case <-time.After(time.Until(start.Add(elapsed))):
+		case <-b.stopCh:
+			return nil
+		}
+	}
+}
+
+func (s *Service) initObserverBot(b *bot) error {
+	p, err := s.addBotPeer(b, b.cfg.GroupID, b.cfg.CallID, true)
+	if err != nil {
+		return err
+	}
+
+	p.pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		b.addTrack()
+		buf := make([]byte, botReceiveMTU)
+		for {
+			n, _, err := track.Read(buf)
+			if err != nil {
+				return
+			}
+			b.addMedia(1, n)
+		}
+	})
+
+	// The observer only receives but still needs a media section to
+	// negotiate the initial connection.
+	if _, err := p.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{
+		Direction: webrtc.RTPTransceiverDirectionRecvonly,
+	}); err != nil {
+		return fmt.Errorf("failed to add transceiver: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) initBridgeBot(b *bot) error {
+	src, err := s.addBotPeer(b, b.cfg.GroupID, b.cfg.CallID, true)
+	if err != nil {
+		return err
+	}
+	dst, err := s.addBotPeer(b, b.cfg.TargetGroupID, b.cfg.TargetCallID, false)
+	if err != nil {
+		return err
+	}
+
+	src.pc.OnTrack(func(remoteTrack *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		if remoteTrack.Kind() != webrtc.RTPCodecTypeAudio {
+			return
+		}
+
+		track, err := webrtc.NewTrackLocalStaticRTP(remoteTrack.Codec().RTPCodecCapability, "audio", random.NewID())
+		if err != nil {
+			s.log.Error("failed to create bridged track", mlog.Err(err), mlog.String("botID", b.info.ID))
+			return
+		}
+		sender, err := dst.pc.AddTrack(track)
+		if err != nil {
+			s.log.Error("failed to add bridged track", mlog.Err(err), mlog.String("botID", b.info.ID))
+			return
+		}
+		dst.renegotiate()
+		b.addTrack()
+
+		defer func() {
+			if err := dst.pc.RemoveTrack(sender); err == nil {
+				dst.renegotiate()
+			}
+		}()
+
+		for {
+			pkt, _, err := remoteTrack.ReadRTP()
+			if err != nil {
+				return
+			}
+			if err := track.WriteRTP(pkt); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+				return
+			}
+			b.addMedia(1, len(pkt.Payload))
+		}
+	})
+
+	for _, p := range []*localPeer{src, dst} {
+		if _, err := p.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{
+			Direction: webrtc.RTPTransceiverDirectionRecvonly,
+		}); err != nil {
+			return fmt.Errorf("failed to add transceiver: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// superviseBot stops the bot once requested, once its duration elapses or
+// once the calls it takes part in have no other participants left.
+func (s *Service) superviseBot(b *bot) {
+	var durationCh <-chan time.Time
+	if b.cfg.Duration > 0 {
+		timer := time.NewTimer(b.cfg.Duration)
+		defer timer.Stop()
+		durationCh = timer.C
+	}
+	ticker := time.NewTicker(callCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stopCh:
+		case <-durationCh:
+			b.requestStop(botStopReasonExpired)
+		case <-ticker.C:
+			if !s.botCallsActive(b) {
+				b.requestStop(botStopReasonCallEnded)
+			}
+			continue
+		}
+		break
+	}
+
+	s.teardownBot(b)
+
+	s.mut.Lock()
+	delete(s.bots, b.info.ID)
+	s.mut.Unlock()
+
+	info := b.getInfo()
+	s.log.Info("bot stopped", mlog.String("botID", info.ID), mlog.String("reason", info.Reason),
+		mlog.Int64("packets", info.Stats.Packets))
+
+	close(b.doneCh)
+}
+
+// botCallsActive returns whether all the calls the bot takes part in still
+// have participants other than the bot.
+func (s *Service) botCallsActive(b *bot) bool {
+	for _, c := range b.calls {
+		if !s.callHasParticipants(c[0], c[1], botUserIDPrefix+b.info.ID) {
+			return false
+		}
+	}
+	return true
+}
+
+// callHasParticipants returns whether the given call has visible sessions
+// of users other than userID.
+func (s *Service) callHasParticipants(groupID, callID, userID string) bool {
+	state, err := s.rtcServer.GetCallState(groupID, callID)
+	if err != nil {
+		return false
+	}
+	for _, ss := range state.Sessions {
+		if ss.UserID != userID {
+			return true
+		}
+	}
+	return false
+}
+
+// teardownBot closes the sessions and peers of the bot.
+func (s *Service) teardownBot(b *bot) {
+	b.requestStop(botStopReasonStopped)
+
+	b.mut.Lock()
+	peers := b.peers
+	b.info.StoppedAt = time.Now().UnixMilli()
+	b.info.Reason = b.reason
+	b.mut.Unlock()
+
+	for _, p := range peers {
+		if err := s.rtcServer.CloseSession(p.cfg.SessionID); err != nil {
+			s.log.Debug("failed to close bot session", mlog.Err(err), mlog.String("sessionID", p.cfg.SessionID))
+		}
+		if err := p.close(); err != nil {
+			s.log.Error("failed to close bot peer", mlog.Err(err), mlog.String("sessionID", p.cfg.SessionID))
+		}
+		s.mut.Lock()
+		delete(s.localPeers, p.cfg.SessionID)
+		s.mut.Unlock()
+	}
+}
+
+// stopBot stops the bot with the given ID, returning its final info.
+func (s *Service) stopBot(id string) (BotInfo, error) {
+	s.mut.RLock()
+	b := s.bots[id]
+	s.mut.RUnlock()
+	if b == nil {
+		return BotInfo{}, fmt.Errorf("bot not found: %s", id)
+	}
+
+	b.requestStop(botStopReasonStopped)
+	<-b.doneCh
+
+	return b.getInfo(), nil
+}
+
+// getBots returns the info of the running bots.
+func (s *Service) getBots() []BotInfo {
+	s.mut.RLock()
+	bots := make([]*bot, 0, len(s.bots))
+	for _, b := range s.bots {
+		bots = append(bots, b)
+	}
+	s.mut.RUnlock()
+
+	infos := make([]BotInfo, 0, len(bots))
+	for _, b := range bots {
+		infos = append(infos, b.getInfo())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartedAt < infos[j].StartedAt
+	})
+	return infos
+}
+
+// stopBots stops all the running bots and waits for them to be done.
+func (s *Service) stopBots(reason string) {
+	s.mut.RLock()
+	bots := make([]*bot, 0, len(s.bots))
+	for _, b := range s.bots {
+		bots = append(bots, b)
+	}
+	s.mut.RUnlock()
+
+	for _, b := range bots {
+		b.requestStop(reason)
+	}
+	for _, b := range bots {
+		<-b.doneCh
+	}
+}
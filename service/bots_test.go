@@ -0,0 +1,288 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+	"github.com/stretchr/testify/require"
+)
+
+// startTestPublisher joins a local peer sending silence to the given call,
+// returning it once connected.
+func startTestPublisher(t *testing.T, th *TestHelper, groupID, callID string) *localPeer {
+	t.Helper()
+
+	cfg := rtc.SessionConfig{
+		GroupID:   groupID,
+		CallID:    callID,
+		UserID:    "publisher",
+		SessionID: random.NewID(),
+	}
+	p, err := newLocalPeer(cfg, th.srvc.rtcServer, th.srvc.log)
+	require.NoError(t, err)
+	th.srvc.mut.Lock()
+	th.srvc.localPeers[cfg.SessionID] = p
+	th.srvc.mut.Unlock()
+
+	track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", random.NewID())
+	require.NoError(t, err)
+	_, err = p.pc.AddTrack(track)
+	require.NoError(t, err)
+
+	require.NoError(t, th.srvc.rtcServer.InitSession(cfg, nil))
+	require.NoError(t, p.offer())
+
+	select {
+	case <-p.connCh:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "timed out waiting for publisher to connect")
+	}
+
+	stopCh := make(chan struct{})
+	go writeTestMedia(track, opusSilenceFrame, testCallAudioFrameMs, stopCh)
+
+	t.Cleanup(func() {
+		close(stopCh)
+		_ = th.srvc.rtcServer.CloseSession(cfg.SessionID)
+		_ = p.close()
+		th.srvc.mut.Lock()
+		delete(th.srvc.localPeers, cfg.SessionID)
+		th.srvc.mut.Unlock()
+	})
+
+	return p
+}
+
+// writeTestAnnouncement writes an Ogg/Opus file holding the given duration
+// of silence.
+func writeTestAnnouncement(t *testing.T, path string, duration time.Duration) {
+	t.Helper()
+
+	w, err := oggwriter.New(path, opusClockRate, 2)
+	require.NoError(t, err)
+	frames := int(duration / (testCallAudioFrameMs * time.Millisecond))
+	for i := 0; i < frames; i++ {
+		require.NoError(t, w.WriteRTP(&rtp.Packet{
+			Header: rtp.Header{
+				SequenceNumber: uint16(i),
+				Timestamp:      uint32(i * opusClockRate * testCallAudioFrameMs / 1000),
+			},
+			Payload: opusSilenceFrame,
+		}))
+	}
+	require.NoError(t, w.Close())
+}
+
+func getTestBot(t *testing.T, th *TestHelper, id string) *bot {
+	t.Helper()
+	th.srvc.mut.RLock()
+	defer th.srvc.mut.RUnlock()
+	b := th.srvc.bots[id]
+	require.NotNil(t, b)
+	return b
+}
+
+func waitForBot(t *testing.T, b *bot) BotInfo {
+	t.Helper()
+	select {
+	case <-b.doneCh:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "timed out waiting for bot to stop")
+	}
+	return b.getInfo()
+}
+
+func TestBotsHandler(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		th := SetupTestHelper(t, nil)
+		defer th.Teardown()
+
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/bots", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	cfg := MakeDefaultCfg(t)
+	cfg.Bots.Enable = true
+	cfg.Bots.MaxCount = 1
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	doRequest := func(t *testing.T, method, body string) (int, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest(method, th.apiURL+"/admin/bots", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp.StatusCode, response
+	}
+
+	t.Run("invalid type", func(t *testing.T) {
+		code, response := doRequest(t, "POST", `{"type": "unknown", "callID": "callA"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, `invalid Type value: "unknown"`, response["error"])
+		require.Equal(t, string(ErrorCodeBadRequest), response["errorCode"])
+	})
+
+	t.Run("call not found", func(t *testing.T) {
+		code, response := doRequest(t, "POST", `{"type": "observer", "groupID": "groupA", "callID": "callA"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "group not found: groupA", response["error"])
+	})
+
+	t.Run("bot not found", func(t *testing.T) {
+		code, response := doRequest(t, "DELETE", `{"id": "unknown"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "bot not found: unknown", response["error"])
+	})
+
+	startTestPublisher(t, th, "groupA", "callA")
+
+	t.Run("observer", func(t *testing.T) {
+		code, response := doRequest(t, "POST", `{"type": "observer", "groupID": "groupA", "callID": "callA"}`)
+		require.Equal(t, http.StatusCreated, code, response["error"])
+		id := response["id"]
+		require.NotEmpty(t, id)
+
+		// The observer is hidden from the call state.
+		state, err := th.srvc.rtcServer.GetCallState("groupA", "callA")
+		require.NoError(t, err)
+		require.Len(t, state.Sessions, 1)
+
+		t.Run("limit", func(t *testing.T) {
+			code, response := doRequest(t, "POST", `{"type": "observer", "groupID": "groupA", "callID": "callA"}`)
+			require.Equal(t, http.StatusBadRequest, code)
+			require.Equal(t, "too many bots: the limit is 1", response["error"])
+		})
+
+		require.Eventually(t, func() bool {
+			code, response := doRequest(t, "GET", "")
+			require.Equal(t, http.StatusOK, code)
+			var bots []BotInfo
+			require.NoError(t, json.Unmarshal([]byte(response["bots"]), &bots))
+			require.Len(t, bots, 1)
+			require.Equal(t, id, bots[0].ID)
+			return bots[0].Stats.Packets > 0
+		}, 10*time.Second, 100*time.Millisecond)
+
+		code, response = doRequest(t, "DELETE", `{"id": "`+id+`"}`)
+		require.Equal(t, http.StatusOK, code, response["error"])
+		var info BotInfo
+		require.NoError(t, json.Unmarshal([]byte(response["bot"]), &info))
+		require.Equal(t, botStopReasonStopped, info.Reason)
+		require.NotZero(t, info.StoppedAt)
+		require.Equal(t, int64(1), info.Stats.Tracks)
+
+		th.srvc.mut.RLock()
+		require.Empty(t, th.srvc.bots)
+		require.Len(t, th.srvc.localPeers, 1)
+		th.srvc.mut.RUnlock()
+	})
+}
+
+func TestBots(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.Bots.Enable = true
+	cfg.Bots.MaxCount = 10
+	cfg.Bots.AnnouncementsDir = t.TempDir()
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	startTestPublisher(t, th, "groupA", "callA")
+
+	t.Run("announcement", func(t *testing.T) {
+		_, err := th.srvc.startBot(BotConfig{Type: BotTypeAnnouncement, GroupID: "groupA", CallID: "callA", Announcement: "../announcement.ogg"})
+		require.EqualError(t, err, "invalid Announcement value: should be a file name")
+
+		writeTestAnnouncement(t, filepath.Join(cfg.Bots.AnnouncementsDir, "announcement.ogg"), time.Second)
+
+		info, err := th.srvc.startBot(BotConfig{Type: BotTypeAnnouncement, GroupID: "groupA", CallID: "callA", Announcement: "announcement.ogg"})
+		require.NoError(t, err)
+
+		// The announcement bot takes part in the call.
+		state, err := th.srvc.rtcServer.GetCallState("groupA", "callA")
+		require.NoError(t, err)
+		require.Len(t, state.Sessions, 2)
+
+		info = waitForBot(t, getTestBot(t, th, info.ID))
+		require.Equal(t, botStopReasonFinished, info.Reason)
+		require.Greater(t, info.Stats.Packets, int64(0))
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		info, err := th.srvc.startBot(BotConfig{Type: BotTypeObserver, GroupID: "groupA", CallID: "callA", Duration: 500 * time.Millisecond})
+		require.NoError(t, err)
+		info = waitForBot(t, getTestBot(t, th, info.ID))
+		require.Equal(t, botStopReasonExpired, info.Reason)
+	})
+
+	t.Run("bridge", func(t *testing.T) {
+		_, err := th.srvc.startBot(BotConfig{Type: BotTypeBridge, GroupID: "groupA", CallID: "callA", TargetCallID: "callA"})
+		require.EqualError(t, err, "invalid TargetCallID value: should not be the bridged call")
+
+		startTestPublisher(t, th, "groupA", "callB")
+		info, err := th.srvc.startBot(BotConfig{Type: BotTypeBridge, GroupID: "groupA", CallID: "callA", TargetCallID: "callB"})
+		require.NoError(t, err)
+		require.Len(t, info.SessionIDs, 2)
+
+		b := getTestBot(t, th, info.ID)
+		require.Eventually(t, func() bool {
+			return b.getInfo().Stats.Packets > 0
+		}, 10*time.Second, 100*time.Millisecond)
+
+		// The bridge takes part in the target call only.
+		state, err := th.srvc.rtcServer.GetCallState("groupA", "callB")
+		require.NoError(t, err)
+		require.Len(t, state.Sessions, 2)
+
+		info, err = th.srvc.stopBot(info.ID)
+		require.NoError(t, err)
+		require.Equal(t, botStopReasonStopped, info.Reason)
+	})
+
+	t.Run("call ended", func(t *testing.T) {
+		defer func(interval time.Duration) { callCheckInterval = interval }(callCheckInterval)
+		callCheckInterval = 100 * time.Millisecond
+
+		p := startTestPublisher(t, th, "groupA", "callC")
+		info, err := th.srvc.startBot(BotConfig{Type: BotTypeObserver, GroupID: "groupA", CallID: "callC"})
+		require.NoError(t, err)
+		b := getTestBot(t, th, info.ID)
+
+		require.NoError(t, th.srvc.rtcServer.CloseSession(p.cfg.SessionID))
+		info = waitForBot(t, b)
+		require.Equal(t, botStopReasonCallEnded, info.Reason)
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		info, err := th.srvc.startBot(BotConfig{Type: BotTypeObserver, GroupID: "groupA", CallID: "callA"})
+		require.NoError(t, err)
+		b := getTestBot(t, th, info.ID)
+
+		th.srvc.stopBots(botStopReasonShutdown)
+		require.Equal(t, botStopReasonShutdown, waitForBot(t, b).Reason)
+		require.Empty(t, th.srvc.getBots())
+	})
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package broker implements the publishing side of the message brokers rtcd
+// sends signals and events to (NATS, Redis and Kafka), only covering what's
+// needed to publish messages.
+package broker
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	NATS  = "nats"
+	Redis = "redis"
+	Kafka = "kafka"
+)
+
+// Conn is a connection to a message broker. It's not safe for concurrent
+// use.
+type Conn interface {
+	// Publish sends data to the given topic (NATS subject, Redis channel or
+	// Kafka topic). The key is only used by Kafka, to select the partition:
+	// messages with the same key keep their order.
+	Publish(topic, key string, data []byte) error
+	Close() error
+}
+
+// Options are the options to connect to a broker.
+type Options struct {
+	// Address is the TCP address (host:port) of the broker. With Kafka, it's
+	// the address of the bootstrap broker.
+	Address string
+	// Username is the optional username to authenticate with.
+	Username string
+	// Password is the optional password to authenticate with. With NATS,
+	// it's sent as a token if no username is set.
+	Password string
+	// Timeout applies to connecting and to each request.
+	Timeout time.Duration
+}
+
+// Dial connects to a broker of the given type (NATS, Redis or Kafka).
+func Dial(typ string, opts Options) (Conn, error) {
+	// Errors are checked before converting to the interface so that a nil
+	// connection is returned as such.
+	switch typ {
+	case NATS:
+		c, err := DialNATS(opts)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	case Redis:
+		c, err := DialRedis(opts)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	case Kafka:
+		c, err := DialKafka(opts)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unsupported broker %q", typ)
+	}
+}
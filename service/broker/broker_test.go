@@ -0,0 +1,46 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/broker/brokertest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDial(t *testing.T) {
+	t.Run("unsupported broker", func(t *testing.T) {
+		c, err := Dial("amqp", Options{})
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Equal(t, `unsupported broker "amqp"`, err.Error())
+	})
+
+	t.Run("connection failure", func(t *testing.T) {
+		s, err := brokertest.NewNATSServer("", "")
+		require.NoError(t, err)
+		addr := s.Addr()
+		require.NoError(t, s.Close())
+
+		// A failed dial returns a nil interface, not a nil pointer.
+		c, err := Dial(NATS, Options{Address: addr, Timeout: time.Second})
+		require.Error(t, err)
+		require.True(t, c == nil)
+	})
+
+	t.Run("kafka", func(t *testing.T) {
+		s, err := brokertest.NewKafkaServer("", "", 1)
+		require.NoError(t, err)
+		defer s.Close()
+
+		c, err := Dial(Kafka, Options{Address: s.Addr(), Timeout: time.Second})
+		require.NoError(t, err)
+		defer c.Close()
+		require.NoError(t, c.Publish("topic", "key", []byte("data")))
+		require.Equal(t, brokertest.Message{Topic: "topic", Key: "key", Data: "data"}, <-s.Messages())
+	})
+}
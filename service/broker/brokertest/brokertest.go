@@ -0,0 +1,110 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package brokertest provides fake message brokers serving just enough of
+// their protocols to test publishers.
+package brokertest
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Message is a message published to a fake broker.
+type Message struct {
+	Topic string
+	// Key is only set by Kafka.
+	Key  string
+	Data string
+}
+
+// server is the part common to the fake brokers: accepting connections and
+// collecting the published messages.
+type server struct {
+	ln       net.Listener
+	msgCh    chan Message
+	connects int32
+	conns    map[net.Conn]bool
+	mut      sync.Mutex
+	wg       sync.WaitGroup
+}
+
+func newServer(serve func(conn net.Conn)) (*server, error) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &server{
+		ln:    ln,
+		msgCh: make(chan Message, 100),
+		conns: map[net.Conn]bool{},
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&s.connects, 1)
+			s.mut.Lock()
+			s.conns[conn] = true
+			s.mut.Unlock()
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer func() {
+					s.mut.Lock()
+					delete(s.conns, conn)
+					s.mut.Unlock()
+					conn.Close()
+				}()
+				serve(conn)
+			}()
+		}
+	}()
+	return s, nil
+}
+
+// publish records a published message, dropping it if the channel is full
+// so that serving never blocks.
+func (s *server) publish(msg Message) {
+	select {
+	case s.msgCh <- msg:
+	default:
+	}
+}
+
+// Addr returns the address the server listens on.
+func (s *server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Messages returns the channel of the published messages.
+func (s *server) Messages() <-chan Message {
+	return s.msgCh
+}
+
+// Connects returns the number of connections accepted so far.
+func (s *server) Connects() int {
+	return int(atomic.LoadInt32(&s.connects))
+}
+
+// DropConns closes the connections of the clients.
+func (s *server) DropConns() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Close stops the server, closing the connections of the clients.
+func (s *server) Close() error {
+	err := s.ln.Close()
+	s.DropConns()
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,333 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package brokertest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	kafkaErrTopicAuthorizationFailed int16 = 29
+	kafkaErrSASLAuthenticationFailed int16 = 58
+	kafkaErrUnsupportedSASLMechanism int16 = 33
+)
+
+var errShortRequest = errors.New("short request")
+
+// KafkaServer is a fake single node Kafka cluster, where every topic exists
+// with the given number of partitions. Producing to a topic starting with
+// "denied" fails with an authorization error.
+type KafkaServer struct {
+	*server
+	username   string
+	password   string
+	partitions int
+}
+
+// NewKafkaServer starts a fake Kafka broker. If username is set, clients
+// must authenticate with SASL/PLAIN.
+func NewKafkaServer(username, password string, partitions int) (*KafkaServer, error) {
+	s := &KafkaServer{
+		username:   username,
+		password:   password,
+		partitions: partitions,
+	}
+	var err error
+	if s.server, err = newServer(s.serve); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *KafkaServer) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	authenticated := s.username == ""
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+		buf := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return
+		}
+
+		req := &kafkaReader{buf: buf}
+		apiKey := req.int16()
+		req.int16() // version
+		correlationID := req.int32()
+		req.string() // client ID
+		if req.err != nil {
+			return
+		}
+
+		var res kafkaWriter
+		res.int32(correlationID)
+		switch apiKey {
+		case 17: // SASL handshake
+			code := int16(0)
+			if mechanism := req.string(); mechanism != "PLAIN" {
+				code = kafkaErrUnsupportedSASLMechanism
+			}
+			res.int16(code)
+			res.int32(1)
+			res.string("PLAIN")
+		case 36: // SASL authenticate
+			parts := strings.Split(string(req.bytes()), "\x00")
+			if len(parts) == 3 && parts[1] == s.username && parts[2] == s.password {
+				authenticated = true
+				res.int16(0)
+				res.int16(-1)
+			} else {
+				res.int16(kafkaErrSASLAuthenticationFailed)
+				res.string("Authentication failed: Invalid username or password")
+			}
+			res.int32(0)
+		case 3: // metadata
+			if !authenticated {
+				return
+			}
+			s.writeMetadata(req, &res)
+		case 0: // produce
+			if !authenticated {
+				return
+			}
+			s.produce(req, &res)
+		default:
+			return
+		}
+		if req.err != nil {
+			return
+		}
+
+		var out kafkaWriter
+		out.int32(int32(len(res.buf)))
+		out.buf = append(out.buf, res.buf...)
+		if _, err := conn.Write(out.buf); err != nil {
+			return
+		}
+	}
+}
+
+// writeMetadata answers a metadata request (v1), the server being the
+// leader of all the partitions.
+func (s *KafkaServer) writeMetadata(req *kafkaReader, res *kafkaWriter) {
+	var topics []string
+	for i, n := 0, req.int32(); i < int(n) && req.err == nil; i++ {
+		topics = append(topics, req.string())
+	}
+
+	host, portStr, _ := net.SplitHostPort(s.Addr())
+	port, _ := strconv.Atoi(portStr)
+	res.int32(1)
+	res.int32(0) // node ID
+	res.string(host)
+	res.int32(int32(port))
+	res.int16(-1) // rack
+	res.int32(0)  // controller ID
+
+	res.int32(int32(len(topics)))
+	for _, topic := range topics {
+		res.int16(0)
+		res.string(topic)
+		res.int8(0)
+		res.int32(int32(s.partitions))
+		for i := 0; i < s.partitions; i++ {
+			res.int16(0)
+			res.int32(int32(i))
+			res.int32(0) // leader
+			res.int32(1) // replicas
+			res.int32(0)
+			res.int32(1) // in-sync replicas
+			res.int32(0)
+		}
+	}
+}
+
+// produce answers a produce request (v3), recording the messages.
+func (s *KafkaServer) produce(req *kafkaReader, res *kafkaWriter) {
+	req.nullableString() // transactional ID
+	req.int16()          // acks
+	req.int32()          // timeout
+
+	n := req.int32()
+	res.int32(n)
+	for i := 0; i < int(n) && req.err == nil; i++ {
+		topic := req.string()
+		res.string(topic)
+		m := req.int32()
+		res.int32(m)
+		for j := 0; j < int(m) && req.err == nil; j++ {
+			partition := req.int32()
+			records := req.bytes()
+			code := int16(0)
+			if strings.HasPrefix(topic, "denied") {
+				code = kafkaErrTopicAuthorizationFailed
+			} else if msgs, err := decodeRecordBatch(topic, records); err != nil {
+				req.err = err
+			} else {
+				for _, msg := range msgs {
+					s.publish(msg)
+				}
+			}
+			res.int32(partition)
+			res.int16(code)
+			res.int64(0)  // base offset
+			res.int64(-1) // log append time
+		}
+	}
+	res.int32(0) // throttle time
+}
+
+// decodeRecordBatch decodes a record batch (v2), checking its CRC.
+func decodeRecordBatch(topic string, buf []byte) ([]Message, error) {
+	r := &kafkaReader{buf: buf}
+	r.int64() // base offset
+	if length := r.int32(); r.err == nil && int(length) != len(r.buf) {
+		return nil, errors.New("invalid batch length")
+	}
+	r.int32() // partition leader epoch
+	if magic := r.int8(); r.err == nil && magic != 2 {
+		return nil, errors.New("unsupported magic")
+	}
+	crc := uint32(r.int32())
+	if r.err == nil && crc != crc32.Checksum(r.buf, crc32.MakeTable(crc32.Castagnoli)) {
+		return nil, errors.New("invalid CRC")
+	}
+	r.int16() // attributes
+	r.int32() // last offset delta
+	r.int64() // first timestamp
+	r.int64() // max timestamp
+	r.int64() // producer ID
+	r.int16() // producer epoch
+	r.int32() // base sequence
+
+	var msgs []Message
+	for i, n := 0, r.int32(); i < int(n) && r.err == nil; i++ {
+		rec := &kafkaReader{buf: r.next(int(r.varint()))}
+		rec.int8()   // attributes
+		rec.varint() // timestamp delta
+		rec.varint() // offset delta
+		var msg Message
+		msg.Topic = topic
+		if n := rec.varint(); n >= 0 {
+			msg.Key = string(rec.next(int(n)))
+		}
+		msg.Data = string(rec.next(int(rec.varint())))
+		if rec.err != nil {
+			return nil, rec.err
+		}
+		msgs = append(msgs, msg)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return msgs, nil
+}
+
+type kafkaWriter struct {
+	buf []byte
+}
+
+func (w *kafkaWriter) int8(v int8) {
+	w.buf = append(w.buf, byte(v))
+}
+
+func (w *kafkaWriter) int16(v int16) {
+	w.buf = append(w.buf, byte(v>>8), byte(v))
+}
+
+func (w *kafkaWriter) int32(v int32) {
+	w.buf = append(w.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (w *kafkaWriter) int64(v int64) {
+	w.int32(int32(v >> 32))
+	w.int32(int32(v))
+}
+
+func (w *kafkaWriter) string(s string) {
+	w.int16(int16(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+type kafkaReader struct {
+	buf []byte
+	err error
+}
+
+func (r *kafkaReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.buf) < n {
+		r.err = errShortRequest
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *kafkaReader) int8() int8 {
+	if b := r.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (r *kafkaReader) int16() int16 {
+	if b := r.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int32() int32 {
+	if b := r.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int64() int64 {
+	if b := r.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.err = errShortRequest
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *kafkaReader) string() string {
+	return string(r.next(int(r.int16())))
+}
+
+func (r *kafkaReader) nullableString() {
+	if n := r.int16(); n > 0 {
+		r.next(int(n))
+	}
+}
+
+func (r *kafkaReader) bytes() []byte {
+	return r.next(int(r.int32()))
+}
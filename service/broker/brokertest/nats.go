@@ -0,0 +1,86 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package brokertest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// NATSServer is a fake NATS server. Publishing to a subject starting with
+// "denied" fails with a permissions error.
+type NATSServer struct {
+	*server
+	username string
+	password string
+}
+
+// NewNATSServer starts a fake NATS server. If username is set, clients
+// must authenticate with username and password, otherwise with password
+// as a token, if set.
+func NewNATSServer(username, password string) (*NATSServer, error) {
+	s := &NATSServer{
+		username: username,
+		password: password,
+	}
+	var err error
+	if s.server, err = newServer(s.serve); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *NATSServer) authorized(opts map[string]string) bool {
+	if s.username != "" {
+		return opts["user"] == s.username && opts["pass"] == s.password
+	}
+	return opts["auth_token"] == s.password
+}
+
+func (s *NATSServer) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "INFO {\"server_id\":\"fake\"}\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "CONNECT":
+			opts := map[string]string{}
+			_ = json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "CONNECT ")), &opts)
+			if !s.authorized(opts) {
+				fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+				return
+			}
+		case "PING":
+			// Servers ping clients too.
+			fmt.Fprint(conn, "PING\r\nPONG\r\n")
+		case "PUB":
+			if len(fields) < 3 {
+				fmt.Fprint(conn, "-ERR 'Unknown Protocol Operation'\r\n")
+				return
+			}
+			n, _ := strconv.Atoi(fields[len(fields)-1])
+			data := make([]byte, n+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			if strings.HasPrefix(fields[1], "denied") {
+				fmt.Fprintf(conn, "-ERR 'Permissions Violation for Publish to \"%s\"'\r\n", fields[1])
+				continue
+			}
+			s.publish(Message{Topic: fields[1], Data: string(data[:n])})
+		}
+	}
+}
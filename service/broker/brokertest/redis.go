@@ -0,0 +1,85 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package brokertest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// RedisServer is a fake Redis server.
+type RedisServer struct {
+	*server
+	password string
+}
+
+// NewRedisServer starts a fake Redis server. If password is set, clients
+// must authenticate with it.
+func NewRedisServer(password string) (*RedisServer, error) {
+	s := &RedisServer{password: password}
+	var err error
+	if s.server, err = newServer(s.serve); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func readRedisCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid command")
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid argument")
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args = append(args, string(arg[:size]))
+	}
+	return args, nil
+}
+
+func (s *RedisServer) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	for {
+		args, err := readRedisCommand(r)
+		if err != nil {
+			return
+		}
+		switch {
+		case args[0] == "AUTH":
+			if args[len(args)-1] != s.password {
+				fmt.Fprint(conn, "-WRONGPASS invalid username-password pair\r\n")
+				continue
+			}
+			authenticated = true
+			fmt.Fprint(conn, "+OK\r\n")
+		case !authenticated:
+			fmt.Fprint(conn, "-NOAUTH Authentication required.\r\n")
+		case args[0] == "PUBLISH" && len(args) == 3:
+			s.publish(Message{Topic: args[1], Data: args[2]})
+			fmt.Fprint(conn, ":1\r\n")
+		default:
+			fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", args[0])
+		}
+	}
+}
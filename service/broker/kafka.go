@@ -0,0 +1,538 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package broker
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	kafkaAPIProduce          int16 = 0
+	kafkaAPIMetadata         int16 = 3
+	kafkaAPISASLHandshake    int16 = 17
+	kafkaAPISASLAuthenticate int16 = 36
+
+	kafkaClientID = "rtcd"
+	// kafkaMaxResponseSize bounds the size of the responses read, guarding
+	// against a garbled size prefix.
+	kafkaMaxResponseSize = 16 << 20
+)
+
+// The Kafka error codes after which the metadata of the topic should be
+// fetched again.
+const (
+	kafkaErrUnknownTopicOrPartition int16 = 3
+	kafkaErrLeaderNotAvailable      int16 = 5
+	kafkaErrNotLeaderOrFollower     int16 = 6
+)
+
+var (
+	errKafkaShortResponse = errors.New("short response")
+	kafkaCRCTable         = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// KafkaError is an error code returned by a Kafka broker.
+type KafkaError int16
+
+func (e KafkaError) Error() string {
+	return "kafka error code " + strconv.Itoa(int(e))
+}
+
+// KafkaConn publishes messages to Kafka topics, one produce request per
+// message, acknowledged by the partition leader. The partition is chosen by
+// hashing the key (FNV-1a), or in turn if the key is empty. Connections to
+// the partition leaders, found through the bootstrap broker, are made as
+// needed.
+type KafkaConn struct {
+	opts Options
+	// bootstrap is the connection metadata is fetched from.
+	bootstrap *kafkaBrokerConn
+	// conns holds the connections to the brokers, keyed by node ID.
+	conns map[int32]*kafkaBrokerConn
+	// brokers holds the addresses of the brokers, keyed by node ID.
+	brokers map[int32]string
+	// leaders holds the node ID of the leader of each partition, keyed by
+	// topic.
+	leaders map[string][]int32
+	next    uint32
+}
+
+// DialKafka connects to the bootstrap broker, authenticating with SASL/PLAIN
+// if a username is set.
+func DialKafka(opts Options) (*KafkaConn, error) {
+	bootstrap, err := dialKafkaBroker(opts.Address, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &KafkaConn{
+		opts:      opts,
+		bootstrap: bootstrap,
+		conns:     map[int32]*kafkaBrokerConn{},
+		brokers:   map[int32]string{},
+		leaders:   map[string][]int32{},
+	}, nil
+}
+
+// Publish sends data to the given topic, on the partition selected by key.
+func (c *KafkaConn) Publish(topic, key string, data []byte) error {
+	leaders, ok := c.leaders[topic]
+	if !ok {
+		var err error
+		if leaders, err = c.fetchMetadata(topic); err != nil {
+			return fmt.Errorf("failed to fetch metadata: %w", err)
+		}
+	}
+
+	var partition int
+	if key != "" {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(key))
+		partition = int(h.Sum32() % uint32(len(leaders)))
+	} else {
+		partition = int(c.next % uint32(len(leaders)))
+		c.next++
+	}
+
+	leader := leaders[partition]
+	conn, err := c.brokerConn(leader)
+	if err != nil {
+		delete(c.leaders, topic)
+		return err
+	}
+
+	var w kafkaWriter
+	w.nullableString(nil) // transactional ID
+	w.int16(1)            // acks from the leader only
+	w.int32(int32(c.opts.Timeout / time.Millisecond))
+	w.int32(1)
+	w.string(topic)
+	w.int32(1)
+	w.int32(int32(partition))
+	w.bytes(kafkaRecordBatch(key, data, time.Now()))
+
+	r, err := conn.roundTrip(kafkaAPIProduce, 3, w.buf)
+	if err != nil {
+		conn.Close()
+		delete(c.conns, leader)
+		delete(c.leaders, topic)
+		return fmt.Errorf("failed to produce: %w", err)
+	}
+
+	errCode := int16(0)
+	for i, n := 0, r.int32(); i < int(n) && r.err == nil; i++ {
+		r.string()
+		for j, m := 0, r.int32(); j < int(m) && r.err == nil; j++ {
+			r.int32() // partition
+			if code := r.int16(); code != 0 {
+				errCode = code
+			}
+			r.int64() // base offset
+			r.int64() // log append time
+		}
+	}
+	if r.err != nil {
+		return fmt.Errorf("failed to parse produce response: %w", r.err)
+	}
+	if errCode != 0 {
+		switch errCode {
+		case kafkaErrUnknownTopicOrPartition, kafkaErrLeaderNotAvailable, kafkaErrNotLeaderOrFollower:
+			delete(c.leaders, topic)
+		}
+		return fmt.Errorf("failed to produce: %w", KafkaError(errCode))
+	}
+
+	return nil
+}
+
+// fetchMetadata looks up the partition leaders of the topic, and the
+// addresses of the brokers.
+func (c *KafkaConn) fetchMetadata(topic string) ([]int32, error) {
+	var w kafkaWriter
+	w.int32(1)
+	w.string(topic)
+
+	r, err := c.metadataRoundTrip(w.buf)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, n := 0, r.int32(); i < int(n) && r.err == nil; i++ {
+		id := r.int32()
+		host := r.string()
+		port := r.int32()
+		r.nullableString() // rack
+		c.brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	r.int32() // controller ID
+
+	var leaders []int32
+	for i, n := 0, r.int32(); i < int(n) && r.err == nil; i++ {
+		errCode := r.int16()
+		name := r.string()
+		r.int8() // is internal
+		var partitions []int32
+		for j, m := 0, r.int32(); j < int(m) && r.err == nil; j++ {
+			r.int16() // partition error code
+			index := r.int32()
+			leader := r.int32()
+			r.skipInt32Array() // replicas
+			r.skipInt32Array() // in-sync replicas
+			if index < 0 || index >= 1<<16 {
+				return nil, fmt.Errorf("invalid partition index %d", index)
+			}
+			for int(index) >= len(partitions) {
+				partitions = append(partitions, -1)
+			}
+			partitions[index] = leader
+		}
+		if r.err != nil {
+			break
+		}
+		if name != topic {
+			continue
+		}
+		if errCode != 0 {
+			return nil, fmt.Errorf("topic %q: %w", topic, KafkaError(errCode))
+		}
+		leaders = partitions
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to parse metadata response: %w", r.err)
+	}
+	if len(leaders) == 0 {
+		return nil, fmt.Errorf("topic %q: no partitions", topic)
+	}
+
+	c.leaders[topic] = leaders
+	return leaders, nil
+}
+
+// metadataRoundTrip sends a metadata request through the bootstrap
+// connection. An idle connection may have been closed by the broker, in
+// which case it's dialed again once.
+func (c *KafkaConn) metadataRoundTrip(body []byte) (*kafkaReader, error) {
+	reused := c.bootstrap != nil
+	if !reused {
+		var err error
+		if c.bootstrap, err = dialKafkaBroker(c.opts.Address, c.opts); err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := c.bootstrap.roundTrip(kafkaAPIMetadata, 1, body)
+	if err == nil {
+		return r, nil
+	}
+	c.bootstrap.Close()
+	c.bootstrap = nil
+	if !reused {
+		return nil, err
+	}
+	return c.metadataRoundTrip(body)
+}
+
+// brokerConn returns the connection to the broker with the given node ID,
+// connecting if needed.
+func (c *KafkaConn) brokerConn(id int32) (*kafkaBrokerConn, error) {
+	if conn := c.conns[id]; conn != nil {
+		return conn, nil
+	}
+	addr, ok := c.brokers[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown broker %d", id)
+	}
+	conn, err := dialKafkaBroker(addr, c.opts)
+	if err != nil {
+		return nil, err
+	}
+	c.conns[id] = conn
+	return conn, nil
+}
+
+func (c *KafkaConn) Close() error {
+	var err error
+	if c.bootstrap != nil {
+		err = c.bootstrap.Close()
+		c.bootstrap = nil
+	}
+	for id, conn := range c.conns {
+		if closeErr := conn.Close(); err == nil {
+			err = closeErr
+		}
+		delete(c.conns, id)
+	}
+	return err
+}
+
+// kafkaRecordBatch encodes a record batch (v2) holding a single record.
+func kafkaRecordBatch(key string, value []byte, now time.Time) []byte {
+	var rec kafkaWriter
+	rec.int8(0)   // attributes
+	rec.varint(0) // timestamp delta
+	rec.varint(0) // offset delta
+	if key == "" {
+		rec.varint(-1) // null key
+	} else {
+		rec.varint(int64(len(key)))
+		rec.buf = append(rec.buf, key...)
+	}
+	rec.varint(int64(len(value)))
+	rec.buf = append(rec.buf, value...)
+	rec.varint(0) // headers
+
+	ts := now.UnixMilli()
+	// The part of the batch covered by the CRC.
+	var body kafkaWriter
+	body.int16(0) // attributes
+	body.int32(0) // last offset delta
+	body.int64(ts)
+	body.int64(ts)
+	body.int64(-1) // producer ID
+	body.int16(-1) // producer epoch
+	body.int32(-1) // base sequence
+	body.int32(1)
+	body.varint(int64(len(rec.buf)))
+	body.buf = append(body.buf, rec.buf...)
+
+	var batch kafkaWriter
+	batch.int64(0) // base offset
+	// Partition leader epoch, magic and CRC, followed by the body.
+	batch.int32(int32(4 + 1 + 4 + len(body.buf)))
+	batch.int32(-1) // partition leader epoch
+	batch.int8(2)   // magic
+	batch.int32(int32(crc32.Checksum(body.buf, kafkaCRCTable)))
+	batch.buf = append(batch.buf, body.buf...)
+	return batch.buf
+}
+
+// kafkaBrokerConn is a connection to a single Kafka broker.
+type kafkaBrokerConn struct {
+	conn          net.Conn
+	r             *bufio.Reader
+	timeout       time.Duration
+	correlationID int32
+}
+
+func dialKafkaBroker(addr string, opts Options) (*kafkaBrokerConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, opts.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial: %w", err)
+	}
+	c := &kafkaBrokerConn{
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		timeout: opts.Timeout,
+	}
+
+	if opts.Username != "" {
+		if err := c.authenticate(opts.Username, opts.Password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+// authenticate performs the SASL/PLAIN authentication.
+func (c *kafkaBrokerConn) authenticate(username, password string) error {
+	var w kafkaWriter
+	w.string("PLAIN")
+	r, err := c.roundTrip(kafkaAPISASLHandshake, 1, w.buf)
+	if err != nil {
+		return err
+	}
+	if code := r.int16(); r.err == nil && code != 0 {
+		return fmt.Errorf("handshake failed: %w", KafkaError(code))
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	w = kafkaWriter{}
+	w.bytes([]byte("\x00" + username + "\x00" + password))
+	r, err = c.roundTrip(kafkaAPISASLAuthenticate, 0, w.buf)
+	if err != nil {
+		return err
+	}
+	code := r.int16()
+	msg := r.nullableString()
+	if r.err != nil {
+		return r.err
+	}
+	if code != 0 {
+		if msg != nil {
+			return fmt.Errorf("%w: %s", KafkaError(code), *msg)
+		}
+		return KafkaError(code)
+	}
+
+	return nil
+}
+
+// roundTrip sends a request and returns a reader positioned on the body of
+// the response.
+func (c *kafkaBrokerConn) roundTrip(apiKey, apiVersion int16, body []byte) (*kafkaReader, error) {
+	_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	c.correlationID++
+	var w kafkaWriter
+	w.int32(0) // size, set below
+	w.int16(apiKey)
+	w.int16(apiVersion)
+	w.int32(c.correlationID)
+	w.string(kafkaClientID)
+	w.buf = append(w.buf, body...)
+	binary.BigEndian.PutUint32(w.buf, uint32(len(w.buf)-4))
+	if _, err := c.conn.Write(w.buf); err != nil {
+		return nil, fmt.Errorf("failed to write request: %w", err)
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(c.r, size[:]); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n < 4 || n > kafkaMaxResponseSize {
+		return nil, fmt.Errorf("invalid response size %d", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(c.r, buf); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	r := &kafkaReader{buf: buf}
+	if id := r.int32(); id != c.correlationID {
+		return nil, fmt.Errorf("unexpected correlation ID %d", id)
+	}
+	return r, nil
+}
+
+func (c *kafkaBrokerConn) Close() error {
+	return c.conn.Close()
+}
+
+// kafkaWriter encodes the primitive types of the Kafka protocol.
+type kafkaWriter struct {
+	buf []byte
+}
+
+func (w *kafkaWriter) int8(v int8) {
+	w.buf = append(w.buf, byte(v))
+}
+
+func (w *kafkaWriter) int16(v int16) {
+	w.buf = append(w.buf, byte(v>>8), byte(v))
+}
+
+func (w *kafkaWriter) int32(v int32) {
+	w.buf = append(w.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (w *kafkaWriter) int64(v int64) {
+	w.int32(int32(v >> 32))
+	w.int32(int32(v))
+}
+
+// varint encodes v as a zig-zag varint.
+func (w *kafkaWriter) varint(v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v)
+	w.buf = append(w.buf, tmp[:n]...)
+}
+
+func (w *kafkaWriter) string(s string) {
+	w.int16(int16(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *kafkaWriter) nullableString(s *string) {
+	if s == nil {
+		w.int16(-1)
+		return
+	}
+	w.string(*s)
+}
+
+func (w *kafkaWriter) bytes(b []byte) {
+	w.int32(int32(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// kafkaReader decodes the primitive types of the Kafka protocol. Reading
+// past the end sets err and returns zero values.
+type kafkaReader struct {
+	buf []byte
+	err error
+}
+
+func (r *kafkaReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.buf) < n {
+		r.err = errKafkaShortResponse
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *kafkaReader) int8() int8 {
+	if b := r.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (r *kafkaReader) int16() int16 {
+	if b := r.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int32() int32 {
+	if b := r.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int64() int64 {
+	if b := r.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) string() string {
+	return string(r.next(int(r.int16())))
+}
+
+func (r *kafkaReader) nullableString() *string {
+	n := r.int16()
+	if n < 0 {
+		return nil
+	}
+	s := string(r.next(int(n)))
+	return &s
+}
+
+func (r *kafkaReader) skipInt32Array() {
+	n := r.int32()
+	if n > 0 {
+		r.next(int(n) * 4)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/broker/brokertest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKafkaConn(t *testing.T) {
+	s, err := brokertest.NewKafkaServer("user", "pass", 4)
+	require.NoError(t, err)
+	defer s.Close()
+
+	opts := Options{
+		Address:  s.Addr(),
+		Username: "user",
+		Password: "pass",
+		Timeout:  5 * time.Second,
+	}
+
+	t.Run("publish", func(t *testing.T) {
+		c, err := DialKafka(opts)
+		require.NoError(t, err)
+		defer c.Close()
+
+		err = c.Publish("rtcd.events", "callID", []byte(`{"type":"call_started"}`))
+		require.NoError(t, err)
+		require.Equal(t, brokertest.Message{Topic: "rtcd.events", Key: "callID", Data: `{"type":"call_started"}`}, <-s.Messages())
+		require.Len(t, c.leaders["rtcd.events"], 4)
+
+		// Messages without a key go to the partitions in turn.
+		for i := 0; i < 4; i++ {
+			err = c.Publish("rtcd.events", "", []byte("{}"))
+			require.NoError(t, err)
+			require.Equal(t, brokertest.Message{Topic: "rtcd.events", Data: "{}"}, <-s.Messages())
+		}
+		require.Equal(t, uint32(4), c.next)
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		c, err := DialKafka(opts)
+		require.NoError(t, err)
+		defer c.Close()
+
+		err = c.Publish("denied", "", []byte("{}"))
+		require.Error(t, err)
+		require.Equal(t, "failed to produce: kafka error code 29", err.Error())
+		var kafkaErr KafkaError
+		require.ErrorAs(t, err, &kafkaErr)
+	})
+
+	t.Run("reconnect", func(t *testing.T) {
+		c, err := DialKafka(opts)
+		require.NoError(t, err)
+		defer c.Close()
+
+		require.NoError(t, c.Publish("rtcd.events", "", []byte("{}")))
+		<-s.Messages()
+
+		s.DropConns()
+		require.Error(t, c.Publish("rtcd.events", "", []byte("{}")))
+		require.Empty(t, c.leaders)
+		require.NoError(t, c.Publish("rtcd.events", "", []byte("{}")))
+		<-s.Messages()
+	})
+
+	t.Run("authentication failure", func(t *testing.T) {
+		opts := opts
+		opts.Password = "wrong"
+		c, err := DialKafka(opts)
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Equal(t, "failed to authenticate: kafka error code 58: Authentication failed: Invalid username or password", err.Error())
+	})
+}
+
+func TestKafkaRecordBatch(t *testing.T) {
+	batch := kafkaRecordBatch("key", []byte("value"), time.UnixMilli(1000))
+	r := &kafkaReader{buf: batch}
+	require.Zero(t, r.int64())
+	require.Equal(t, int32(len(batch)-12), r.int32())
+	require.Equal(t, int32(-1), r.int32())
+	require.Equal(t, int8(2), r.int8())
+	require.NoError(t, r.err)
+}
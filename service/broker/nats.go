@@ -0,0 +1,127 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package broker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// NATSConn is a connection to a NATS server. Each publish is followed by a
+// PING so that errors reported by the server (e.g. permissions) are caught.
+type NATSConn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+}
+
+type natsConnectOptions struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+func DialNATS(opts Options) (*NATSConn, error) {
+	conn, err := net.DialTimeout("tcp", opts.Address, opts.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial: %w", err)
+	}
+	c := &NATSConn{
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		timeout: opts.Timeout,
+	}
+
+	if err := c.handshake(opts); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *NATSConn) handshake(opts Options) error {
+	_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	line, err := c.readLine()
+	if err != nil {
+		return fmt.Errorf("failed to read server info: %w", err)
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected server greeting %q", line)
+	}
+
+	connectOpts := natsConnectOptions{Name: "rtcd", Lang: "go"}
+	if opts.Username != "" {
+		connectOpts.User = opts.Username
+		connectOpts.Pass = opts.Password
+	} else {
+		connectOpts.AuthToken = opts.Password
+	}
+	data, err := json.Marshal(connectOpts)
+	if err != nil {
+		return fmt.Errorf("failed to marshal options: %w", err)
+	}
+	if _, err := fmt.Fprintf(c.conn, "CONNECT %s\r\nPING\r\n", data); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+
+	return c.waitPong()
+}
+
+// Publish sends data to the given subject, the key being ignored.
+func (c *NATSConn) Publish(subject, _ string, data []byte) error {
+	_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	msg := make([]byte, 0, len(subject)+len(data)+32)
+	msg = append(msg, fmt.Sprintf("PUB %s %d\r\n", subject, len(data))...)
+	msg = append(msg, data...)
+	msg = append(msg, "\r\nPING\r\n"...)
+	if _, err := c.conn.Write(msg); err != nil {
+		return fmt.Errorf("failed to publish: %w", err)
+	}
+
+	return c.waitPong()
+}
+
+// waitPong reads the server messages until the PONG answering our PING,
+// replying to the PINGs of the server along the way.
+func (c *NATSConn) waitPong() error {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return fmt.Errorf("failed to read reply: %w", err)
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := c.conn.Write([]byte("PONG\r\n")); err != nil {
+				return fmt.Errorf("failed to reply to ping: %w", err)
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+func (c *NATSConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *NATSConn) Close() error {
+	return c.conn.Close()
+}
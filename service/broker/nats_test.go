@@ -0,0 +1,67 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/broker/brokertest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNATSConn(t *testing.T) {
+	s, err := brokertest.NewNATSServer("", "token")
+	require.NoError(t, err)
+	defer s.Close()
+
+	opts := Options{
+		Address:  s.Addr(),
+		Password: "token",
+		Timeout:  5 * time.Second,
+	}
+
+	t.Run("publish", func(t *testing.T) {
+		c, err := DialNATS(opts)
+		require.NoError(t, err)
+		defer c.Close()
+
+		err = c.Publish("rtcd.events", "key", []byte(`{"calls":1}`))
+		require.NoError(t, err)
+		require.Equal(t, brokertest.Message{Topic: "rtcd.events", Data: `{"calls":1}`}, <-s.Messages())
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		c, err := DialNATS(opts)
+		require.NoError(t, err)
+		defer c.Close()
+
+		err = c.Publish("denied.subject", "", []byte("{}"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "server error: 'Permissions Violation")
+	})
+
+	t.Run("authentication failure", func(t *testing.T) {
+		opts := opts
+		opts.Password = "wrong"
+		c, err := DialNATS(opts)
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Equal(t, "server error: 'Authorization Violation'", err.Error())
+	})
+
+	t.Run("username and password", func(t *testing.T) {
+		s, err := brokertest.NewNATSServer("user", "pass")
+		require.NoError(t, err)
+		defer s.Close()
+
+		c, err := DialNATS(Options{Address: s.Addr(), Username: "user", Password: "pass", Timeout: 5 * time.Second})
+		require.NoError(t, err)
+		require.NoError(t, c.Close())
+
+		_, err = DialNATS(Options{Address: s.Addr(), Password: "pass", Timeout: 5 * time.Second})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package broker
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedisConn is a connection to a Redis server.
+type RedisConn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+}
+
+func DialRedis(opts Options) (*RedisConn, error) {
+	conn, err := net.DialTimeout("tcp", opts.Address, opts.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial: %w", err)
+	}
+	c := &RedisConn{
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		timeout: opts.Timeout,
+	}
+
+	if opts.Password != "" {
+		args := []string{"AUTH", opts.Password}
+		if opts.Username != "" {
+			args = []string{"AUTH", opts.Username, opts.Password}
+		}
+		if _, err := c.do(args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+// Publish sends data to the given channel, the key being ignored.
+func (c *RedisConn) Publish(channel, _ string, data []byte) error {
+	if _, err := c.do("PUBLISH", channel, string(data)); err != nil {
+		return fmt.Errorf("failed to publish: %w", err)
+	}
+	return nil
+}
+
+// do sends a command and returns its reply, only simple strings and
+// integers being expected.
+func (c *RedisConn) do(args ...string) (string, error) {
+	_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	var sb strings.Builder
+	sb.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		sb.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	if _, err := c.conn.Write([]byte(sb.String())); err != nil {
+		return "", fmt.Errorf("failed to write command: %w", err)
+	}
+
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read reply: %w", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", fmt.Errorf("server error: %s", line[1:])
+	default:
+		return "", fmt.Errorf("unexpected reply %q", line)
+	}
+}
+
+func (c *RedisConn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/broker/brokertest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisConn(t *testing.T) {
+	s, err := brokertest.NewRedisServer("pass")
+	require.NoError(t, err)
+	defer s.Close()
+
+	opts := Options{
+		Address:  s.Addr(),
+		Password: "pass",
+		Timeout:  5 * time.Second,
+	}
+
+	t.Run("publish", func(t *testing.T) {
+		c, err := DialRedis(opts)
+		require.NoError(t, err)
+		defer c.Close()
+
+		err = c.Publish("rtcd.autoscaling", "key", []byte("{\"calls\":1}\r\n"))
+		require.NoError(t, err)
+		require.Equal(t, brokertest.Message{Topic: "rtcd.autoscaling", Data: "{\"calls\":1}\r\n"}, <-s.Messages())
+	})
+
+	t.Run("authentication failure", func(t *testing.T) {
+		opts := opts
+		opts.Password = "wrong"
+		c, err := DialRedis(opts)
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Equal(t, "failed to authenticate: server error: WRONGPASS invalid username-password pair", err.Error())
+	})
+
+	t.Run("not authenticated", func(t *testing.T) {
+		opts := opts
+		opts.Password = ""
+		c, err := DialRedis(opts)
+		require.NoError(t, err)
+		defer c.Close()
+
+		err = c.Publish("rtcd.autoscaling", "", []byte("{}"))
+		require.Error(t, err)
+		require.Equal(t, "failed to publish: server error: NOAUTH Authentication required.", err.Error())
+	})
+}
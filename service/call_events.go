@@ -0,0 +1,267 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/store"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const (
+	// callEventsStoreKeyPrefix is the prefix of the store keys under which
+	// the events of each call are persisted, one per key:
+	// <prefix><call hash>:<sequence number>.
+	callEventsStoreKeyPrefix = "rtcd:call_events:"
+	// callEventsCleanupInterval is the interval at which the expired call
+	// events are removed from the store.
+	callEventsCleanupInterval = time.Hour
+	callEventsPathPrefix      = "/admin/calls/"
+)
+
+// callEventsRange is the range of the sequence numbers of the events of a
+// call persisted in the store.
+type callEventsRange struct {
+	first uint64
+	next  uint64
+}
+
+// callEventsKeyPrefix returns the prefix of the store keys of the events of
+// the given call.
+func callEventsKeyPrefix(callID string) string {
+	// Keys are limited to 64 bytes by the store.
+	sum := sha256.Sum256([]byte(callID))
+	return callEventsStoreKeyPrefix + base64.RawURLEncoding.EncodeToString(sum[:18]) + ":"
+}
+
+func callEventKey(callID string, seq uint64) string {
+	// Zero padded so that keys sort in sequence order.
+	return callEventsKeyPrefix(callID) + fmt.Sprintf("%010d", seq)
+}
+
+// parseCallEventKey returns the call prefix and sequence number of the given
+// call event key.
+func parseCallEventKey(key string) (string, uint64, bool) {
+	if !strings.HasPrefix(key, callEventsStoreKeyPrefix) {
+		return "", 0, false
+	}
+	i := strings.LastIndexByte(key, ':')
+	seq, err := strconv.ParseUint(key[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return key[:i+1], seq, true
+}
+
+// loadCallEvents indexes the call events persisted in the store.
+func (s *Service) loadCallEvents() error {
+	keys, err := s.store.Keys()
+	if err != nil {
+		return fmt.Errorf("failed to get keys: %w", err)
+	}
+
+	s.callEventsMut.Lock()
+	defer s.callEventsMut.Unlock()
+
+	for _, key := range keys {
+		prefix, seq, ok := parseCallEventKey(key)
+		if !ok {
+			continue
+		}
+		r := s.callEvents[prefix]
+		if r == nil {
+			s.callEvents[prefix] = &callEventsRange{first: seq, next: seq + 1}
+			continue
+		}
+		if seq < r.first {
+			r.first = seq
+		}
+		if seq >= r.next {
+			r.next = seq + 1
+		}
+	}
+
+	return nil
+}
+
+// recordCallEvent appends the given event to the timeline of its call,
+// dropping the oldest events past the configured maximum.
+func (s *Service) recordCallEvent(ev rtc.Event) error {
+	if s.cfg.Store.CallEventsMax == 0 || ev.CallID == "" {
+		return nil
+	}
+
+	js, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	s.callEventsMut.Lock()
+	defer s.callEventsMut.Unlock()
+
+	prefix := callEventsKeyPrefix(ev.CallID)
+	r := s.callEvents[prefix]
+	if r == nil {
+		r = &callEventsRange{}
+		s.callEvents[prefix] = r
+	}
+
+	if err := s.store.Set(callEventKey(ev.CallID, r.next), string(js)); err != nil {
+		return fmt.Errorf("failed to store event: %w", err)
+	}
+	r.next++
+
+	for r.next-r.first > uint64(s.cfg.Store.CallEventsMax) {
+		if err := s.store.Delete(callEventKey(ev.CallID, r.first)); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("failed to delete event: %w", err)
+		}
+		r.first++
+	}
+
+	return nil
+}
+
+// getCallEvents returns the persisted events of the given call, oldest
+// first.
+func (s *Service) getCallEvents(callID string) ([]rtc.Event, error) {
+	s.callEventsMut.Lock()
+	defer s.callEventsMut.Unlock()
+
+	events := []rtc.Event{}
+	r := s.callEvents[callEventsKeyPrefix(callID)]
+	if r == nil {
+		return events, nil
+	}
+
+	for seq := r.first; seq < r.next; seq++ {
+		data, err := s.store.Get(callEventKey(callID, seq))
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get event: %w", err)
+		}
+		var ev rtc.Event
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+		events = append(events, ev)
+	}
+
+	return events, nil
+}
+
+// removeExpiredCallEvents deletes the call events older than the configured
+// TTL from the store.
+func (s *Service) removeExpiredCallEvents(now time.Time) error {
+	keys, err := s.store.Keys()
+	if err != nil {
+		return fmt.Errorf("failed to get keys: %w", err)
+	}
+
+	s.callEventsMut.Lock()
+	defer s.callEventsMut.Unlock()
+
+	expiredAt := now.Add(-time.Duration(s.cfg.Store.CallEventsTTLHours) * time.Hour).UnixMilli()
+	for _, key := range keys {
+		prefix, seq, ok := parseCallEventKey(key)
+		if !ok {
+			continue
+		}
+
+		data, err := s.store.Get(key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		} else if err == nil {
+			var ev rtc.Event
+			if err := json.Unmarshal([]byte(data), &ev); err == nil && ev.Timestamp > expiredAt {
+				continue
+			}
+		}
+
+		if err := s.store.Delete(key); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("failed to delete key: %w", err)
+		}
+
+		// Events are appended in chronological order so the expired ones are
+		// the oldest of their call.
+		if r := s.callEvents[prefix]; r != nil && seq >= r.first {
+			r.first = seq + 1
+			if r.first >= r.next {
+				delete(s.callEvents, prefix)
+			}
+		}
+	}
+
+	return nil
+}
+
+// runCallEventsCleanup periodically removes the expired call events.
+func (s *Service) runCallEventsCleanup(interval time.Duration) {
+	defer close(s.callEventsDoneCh)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := s.removeExpiredCallEvents(now); err != nil {
+				s.log.Error("failed to remove expired call events", mlog.Err(err))
+			}
+		case <-s.callEventsStopCh:
+			return
+		}
+	}
+}
+
+func (s *Service) handleCallEvents(w http.ResponseWriter, r *http.Request) {
+	callID, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, callEventsPathPrefix), "/")
+	if r.Method != http.MethodGet || !ok || callID == "" || name != "events" {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleCallEvents", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+	data.reqData["callID"] = callID
+
+	events, err := s.getCallEvents(callID)
+	if err != nil {
+		data.err = "failed to get call events: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	js, err := json.Marshal(events)
+	if err != nil {
+		data.err = "failed to marshal call events: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	data.code = http.StatusOK
+	data.resData["callID"] = callID
+	data.resData["events"] = string(js)
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallEventKey(t *testing.T) {
+	key := callEventKey("callID", 42)
+	require.LessOrEqual(t, len(key), 64)
+
+	prefix, seq, ok := parseCallEventKey(key)
+	require.True(t, ok)
+	require.Equal(t, callEventsKeyPrefix("callID"), prefix)
+	require.Equal(t, uint64(42), seq)
+
+	_, _, ok = parseCallEventKey("rtcd:bandwidth_usage:clientA")
+	require.False(t, ok)
+}
+
+func TestCallEvents(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.Store.CallEventsMax = 3
+	cfg.Store.CallEventsTTLHours = 1
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	now := time.Now()
+	newEvent := func(evType rtc.EventType, callID string, ts time.Time) rtc.Event {
+		return rtc.Event{
+			Type:      evType,
+			Timestamp: ts.UnixMilli(),
+			GroupID:   "groupID",
+			CallID:    callID,
+			SessionID: "sessionID",
+		}
+	}
+
+	getEvents := func(t *testing.T, callID string) (int, []rtc.Event) {
+		t.Helper()
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/calls/"+callID+"/events", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return resp.StatusCode, nil
+		}
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		var events []rtc.Event
+		err = json.Unmarshal([]byte(response["events"]), &events)
+		require.NoError(t, err)
+		return resp.StatusCode, events
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		resp, err := http.Get(th.apiURL + "/admin/calls/callA/events")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		code, _ := getEvents(t, "callA/other")
+		require.Equal(t, http.StatusNotFound, code)
+		code, _ = getEvents(t, "")
+		require.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("unknown call", func(t *testing.T) {
+		code, events := getEvents(t, "unknown")
+		require.Equal(t, http.StatusOK, code)
+		require.Empty(t, events)
+	})
+
+	t.Run("bounded timeline", func(t *testing.T) {
+		evTypes := []rtc.EventType{
+			rtc.SessionJoinedEvent,
+			rtc.SessionUnmutedEvent,
+			rtc.StreamQualityChangedEvent,
+			rtc.ICERestartedEvent,
+			rtc.SessionLeftEvent,
+		}
+		for i, evType := range evTypes {
+			require.NoError(t, th.srvc.recordCallEvent(newEvent(evType, "callA", now.Add(time.Duration(i)*time.Second))))
+		}
+		require.NoError(t, th.srvc.recordCallEvent(newEvent(rtc.SessionJoinedEvent, "callB", now)))
+		// Events not bound to a call are ignored.
+		require.NoError(t, th.srvc.recordCallEvent(newEvent(rtc.SessionJoinedEvent, "", now)))
+
+		code, events := getEvents(t, "callA")
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, events, 3)
+		for i, ev := range events {
+			require.Equal(t, evTypes[i+2], ev.Type)
+			require.Equal(t, "callA", ev.CallID)
+		}
+
+		code, events = getEvents(t, "callB")
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, events, 1)
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		th.srvc.callEvents = map[string]*callEventsRange{}
+		require.NoError(t, th.srvc.loadCallEvents())
+
+		_, events := getEvents(t, "callA")
+		require.Len(t, events, 3)
+		require.Equal(t, rtc.StreamQualityChangedEvent, events[0].Type)
+
+		require.NoError(t, th.srvc.recordCallEvent(newEvent(rtc.CallEndedEvent, "callA", now.Add(5*time.Second))))
+		_, events = getEvents(t, "callA")
+		require.Len(t, events, 3)
+		require.Equal(t, rtc.ICERestartedEvent, events[0].Type)
+		require.Equal(t, rtc.CallEndedEvent, events[2].Type)
+	})
+
+	t.Run("expiration", func(t *testing.T) {
+		ttl := time.Duration(th.srvc.cfg.Store.CallEventsTTLHours) * time.Hour
+
+		// Only the oldest event of callA is expired.
+		require.NoError(t, th.srvc.removeExpiredCallEvents(now.Add(ttl+3500*time.Millisecond)))
+		_, events := getEvents(t, "callA")
+		require.Len(t, events, 2)
+		require.Equal(t, rtc.SessionLeftEvent, events[0].Type)
+		_, events = getEvents(t, "callB")
+		require.Empty(t, events)
+
+		require.NoError(t, th.srvc.removeExpiredCallEvents(now.Add(ttl+time.Minute)))
+		_, events = getEvents(t, "callA")
+		require.Empty(t, events)
+		require.Empty(t, th.srvc.callEvents)
+	})
+}
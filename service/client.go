@@ -9,11 +9,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/mattermost/rtcd/service/api"
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/signaling"
 	"github.com/mattermost/rtcd/service/ws"
 )
 
@@ -26,6 +30,15 @@ type Client struct {
 	cfg    *ClientConfig
 	connID string
 
+	protocolVersion int
+	capabilities    map[string]bool
+	// lastSeqs tracks the sequence number of the last signaling message
+	// received for each session.
+	lastSeqs map[string]uint64
+	// groups holds the auth keys of the additional groups multiplexed over
+	// the connection.
+	groups map[string]string
+
 	httpClient  *http.Client
 	wsClient    *ws.Client
 	receiveCh   chan ClientMessage
@@ -34,6 +47,9 @@ type Client struct {
 	dialFn      DialContextFn
 	closed      bool
 
+	handlers    clientHandlers
+	handlersMut sync.RWMutex
+
 	mut sync.RWMutex
 	wg  sync.WaitGroup
 }
@@ -54,19 +70,15 @@ func NewClient(cfg ClientConfig, opts ...ClientOption) (*Client, error) {
 		}
 	}
 
-	dialFn := (&net.Dialer{
-		Timeout:   5 * time.Second,
-		KeepAlive: 30 * time.Second,
-		DualStack: true,
-	}).DialContext
-
-	if c.dialFn != nil {
-		dialFn = c.dialFn
+	// Both the HTTP and WebSocket connections race the addresses the service
+	// host resolves to.
+	if c.dialFn == nil {
+		c.dialFn = newHappyEyeballsDialer(cfg.DialAttemptTimeout, cfg.DialAttemptDelay).DialContext
 	}
 
 	transport := &http.Transport{
 		Proxy:                 http.ProxyFromEnvironment,
-		DialContext:           dialFn,
+		DialContext:           c.dialFn,
 		MaxConnsPerHost:       100,
 		MaxIdleConns:          100,
 		MaxIdleConnsPerHost:   100,
@@ -76,11 +88,23 @@ func NewClient(cfg ClientConfig, opts ...ClientOption) (*Client, error) {
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 
-	c.httpClient = &http.Client{Transport: transport}
+	c.httpClient = &http.Client{Transport: &apiVersionTransport{transport}}
 
 	return &c, nil
 }
 
+// apiVersionTransport requests the latest version of the API the client
+// supports on every request. Servers predating API versioning ignore it.
+type apiVersionTransport struct {
+	http.RoundTripper
+}
+
+func (t *apiVersionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(api.VersionHeader, strconv.Itoa(api.CurrentVersion))
+	return t.RoundTripper.RoundTrip(req)
+}
+
 func (c *Client) Register(clientID string, authKey string) error {
 	if c.httpClient == nil {
 		return fmt.Errorf("http client is not initialized")
@@ -96,7 +120,7 @@ func (c *Client) Register(clientID string, authKey string) error {
 		return fmt.Errorf("failed to encode body: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", c.cfg.httpURL+"/register", &buf)
+	req, err := http.NewRequest("POST", c.cfg.getHTTPURL("register"), &buf)
 	if err != nil {
 		return fmt.Errorf("failed to build request: %w", err)
 	}
@@ -114,15 +138,52 @@ func (c *Client) Register(clientID string, authKey string) error {
 	}
 
 	if resp.StatusCode != http.StatusCreated {
-		if errMsg := respData["error"]; errMsg != "" {
-			return fmt.Errorf("request failed: %s", errMsg)
-		}
-		return fmt.Errorf("request failed with status %s", resp.Status)
+		return responseError(resp, respData)
 	}
 
 	return nil
 }
 
+// Bootstrap registers the client a bootstrap token was minted for, without
+// requiring admin credentials. It returns the ID and the newly generated
+// auth key of the client.
+func (c *Client) Bootstrap(token string) (string, string, error) {
+	if c.httpClient == nil {
+		return "", "", fmt.Errorf("http client is not initialized")
+	}
+
+	reqData := map[string]string{
+		"token": token,
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(reqData); err != nil {
+		return "", "", fmt.Errorf("failed to encode body: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", c.cfg.getHTTPURL("bootstrap"), &buf)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to build request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("http request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respData := map[string]string{}
+	if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
+		return "", "", fmt.Errorf("decoding http response failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", "", responseError(resp, respData)
+	}
+
+	return respData["clientID"], respData["authKey"], nil
+}
+
 func (c *Client) Unregister(clientID string) error {
 	if c.httpClient == nil {
 		return fmt.Errorf("http client is not initialized")
@@ -137,7 +198,7 @@ func (c *Client) Unregister(clientID string) error {
 		return fmt.Errorf("failed to encode body: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", c.cfg.httpURL+"/unregister", &buf)
+	req, err := http.NewRequest("POST", c.cfg.getHTTPURL("unregister"), &buf)
 	if err != nil {
 		return fmt.Errorf("failed to build request: %w", err)
 	}
@@ -155,15 +216,94 @@ func (c *Client) Unregister(clientID string) error {
 			return fmt.Errorf("decoding http response failed: %w", err)
 		}
 
-		if errMsg := respData["error"]; errMsg != "" {
-			return fmt.Errorf("request failed: %s", errMsg)
-		}
-		return fmt.Errorf("request failed with status %s", resp.Status)
+		return responseError(resp, respData)
 	}
 
 	return nil
 }
 
+// GetJoinToken requests a token allowing the given session to join the
+// given call of groupID. It requires admin credentials.
+func (c *Client) GetJoinToken(groupID, callID, sessionID string) (string, error) {
+	if c.httpClient == nil {
+		return "", fmt.Errorf("http client is not initialized")
+	}
+
+	reqData := map[string]string{
+		"groupID":   groupID,
+		"callID":    callID,
+		"sessionID": sessionID,
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(reqData); err != nil {
+		return "", fmt.Errorf("failed to encode body: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", c.cfg.getHTTPURL("join_token"), &buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+	req.SetBasicAuth(c.cfg.ClientID, c.cfg.AuthKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("http request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respData := map[string]string{}
+	if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
+		return "", fmt.Errorf("decoding http response failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", responseError(resp, respData)
+	}
+
+	return respData["token"], nil
+}
+
+// GetTURNCredentials requests the ICE servers to use, TURN ones coming with
+// short-lived credentials, and the time the credentials expire at.
+func (c *Client) GetTURNCredentials() (rtc.ICEServers, time.Time, error) {
+	if c.httpClient == nil {
+		return nil, time.Time{}, fmt.Errorf("http client is not initialized")
+	}
+
+	req, err := http.NewRequest("GET", c.cfg.getHTTPURL("turn_credentials"), nil)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.SetBasicAuth(c.cfg.ClientID, c.cfg.AuthKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("http request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respData := map[string]string{}
+	if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
+		return nil, time.Time{}, fmt.Errorf("decoding http response failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, responseError(resp, respData)
+	}
+
+	var servers rtc.ICEServers
+	if err := json.Unmarshal([]byte(respData["iceServers"]), &servers); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to decode ICE servers: %w", err)
+	}
+	expiresAt, err := strconv.ParseInt(respData["expiresAt"], 10, 64)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse expiration: %w", err)
+	}
+
+	return servers, time.UnixMilli(expiresAt), nil
+}
+
 func (c *Client) Connect() error {
 	c.mut.Lock()
 	defer c.mut.Unlock()
@@ -176,16 +316,34 @@ func (c *Client) Connect() error {
 		return fmt.Errorf("ws client is already initialized")
 	}
 
-	wsClient, err := ws.NewClient(ws.ClientConfig{
+	wsCfg := ws.ClientConfig{
 		URL:       c.cfg.wsURL,
 		AuthToken: base64.StdEncoding.EncodeToString([]byte(c.cfg.ClientID + ":" + c.cfg.AuthKey)),
-	}, ws.WithDialFunc(ws.DialContextFn(c.dialFn)))
+	}
+	// Signed credentials are single use so they are generated on every
+	// (re)connect.
+	if c.cfg.SignedAuth {
+		creds, err := auth.NewSignedCredentials(c.cfg.ClientID, c.cfg.AuthKey, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to sign credentials: %w", err)
+		}
+		wsCfg.AuthScheme = auth.SignedAuthScheme
+		wsCfg.AuthToken = creds.String()
+	}
+
+	wsClient, err := ws.NewClient(wsCfg, ws.WithDialFunc(ws.DialContextFn(c.dialFn)))
 	if err != nil {
 		return fmt.Errorf("failed to create ws client: %w", err)
 	}
 
 	c.wsClient = wsClient
 
+	for groupID, authKey := range c.groups {
+		if err := c.sendGroupAuth(wsClient, groupID, authKey); err != nil {
+			c.sendError(fmt.Errorf("failed to authenticate group: %w", err))
+		}
+	}
+
 	c.wg.Add(2)
 
 	go func() {
@@ -231,6 +389,55 @@ func (c *Client) Send(msg ClientMessage) error {
 	return c.wsClient.Send(ws.BinaryMessage, data)
 }
 
+// AddGroup authenticates an additional group over the client's connection
+// so that messages carrying its ID in the groupID field are routed to it.
+// Groups are authenticated again on reconnect. Authentication failures are
+// reported as errors.
+func (c *Client) AddGroup(groupID, authKey string) error {
+	if groupID == "" {
+		return fmt.Errorf("invalid empty group id")
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.closed {
+		return fmt.Errorf("ws client is closed")
+	}
+
+	if c.groups == nil {
+		c.groups = map[string]string{}
+	}
+	c.groups[groupID] = authKey
+
+	if c.wsClient == nil {
+		return nil
+	}
+
+	return c.sendGroupAuth(c.wsClient, groupID, authKey)
+}
+
+func (c *Client) sendGroupAuth(wsClient *ws.Client, groupID, authKey string) error {
+	msgData := signaling.GroupAuthData{
+		GroupID: groupID,
+	}
+	if c.cfg.SignedAuth {
+		creds, err := auth.NewSignedCredentials(groupID, authKey, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to sign credentials: %w", err)
+		}
+		msgData.Credentials = creds.String()
+	} else {
+		msgData.AuthKey = authKey
+	}
+
+	data, err := NewPackedClientMessage(ClientMessageGroupAuth, msgData.Map())
+	if err != nil {
+		return err
+	}
+	return wsClient.Send(ws.BinaryMessage, data)
+}
+
 func (c *Client) ReceiveCh() <-chan ClientMessage {
 	return c.receiveCh
 }
@@ -267,6 +474,11 @@ func (c *Client) Close() error {
 }
 
 func (c *Client) sendError(err error) {
+	if cb := c.getHandlers().err; cb != nil {
+		cb(err)
+		return
+	}
+
 	c.mut.RLock()
 	defer c.mut.RUnlock()
 	if c.closed {
@@ -294,12 +506,57 @@ func (c *Client) msgReader(wsClient *ws.Client) {
 		}
 
 		if cm.Type == ClientMessageHello {
-			data, ok := cm.Data.(map[string]string)
-			if ok && data["connID"] != "" {
+			data, _ := cm.Data.(map[string]string)
+			hello, err := signaling.ParseHelloData(data)
+			if err != nil {
+				c.sendError(fmt.Errorf("failed to parse hello message: %w", err))
+			}
+			if hello.ConnID != "" {
 				c.mut.Lock()
-				c.connID = data["connID"]
+				c.connID = hello.ConnID
 				c.mut.Unlock()
 			}
+			if hello.ProtocolVersion != "" {
+				if err := c.negotiateProtocol(wsClient, hello); err != nil {
+					c.sendError(fmt.Errorf("failed to negotiate protocol: %w", err))
+				}
+			}
+		}
+
+		if cm.Type == ClientMessageGroupAuth {
+			data, _ := cm.Data.(map[string]string)
+			if groupAuth, err := signaling.ParseGroupAuthData(data); err != nil {
+				c.sendError(fmt.Errorf("failed to parse group auth message: %w", err))
+			} else if groupAuth.Error != "" {
+				c.sendError(fmt.Errorf("failed to authenticate group %q: %w", groupAuth.GroupID, &Error{
+					Code:    ErrorCode(groupAuth.ErrorCode),
+					Message: groupAuth.Error,
+				}))
+			}
+			continue
+		}
+
+		if cm.Type == ClientMessageError {
+			data, _ := cm.Data.(map[string]string)
+			if errData, err := signaling.ParseErrorData(data); err != nil {
+				c.sendError(fmt.Errorf("failed to parse error message: %w", err))
+			} else {
+				c.sendError(fmt.Errorf("failed to handle %s message: %w", errData.MsgType, &Error{
+					Code:    ErrorCode(errData.ErrorCode),
+					Message: errData.Error,
+				}))
+			}
+			continue
+		}
+
+		if rtcMsg, ok := cm.Data.(rtc.Message); ok && cm.Type == ClientMessageRTC && rtcMsg.Seq > 0 {
+			if err := c.ackMessage(wsClient, rtcMsg); err != nil {
+				c.sendError(fmt.Errorf("failed to ack message: %w", err))
+			}
+		}
+
+		if c.dispatch(cm) {
+			continue
 		}
 
 		select {
@@ -310,6 +567,86 @@ func (c *Client) msgReader(wsClient *ws.Client) {
 	}
 }
 
+// negotiateProtocol handles the protocol information advertised by the
+// server and replies with the client's own.
+func (c *Client) negotiateProtocol(wsClient *ws.Client, hello signaling.HelloData) error {
+	serverVersion, err := parseProtocolVersion(hello.ProtocolVersion)
+	if err != nil {
+		return err
+	}
+
+	clientCaps := c.cfg.Capabilities
+	if clientCaps == nil {
+		clientCaps = serverCapabilities
+	}
+
+	version, caps := negotiateProtocol(ProtocolVersion, clientCaps, serverVersion, parseCapabilities(hello.Capabilities))
+	info := newProtocolInfo(version, caps)
+
+	c.mut.Lock()
+	c.protocolVersion = info.version
+	c.capabilities = info.capabilities
+	c.mut.Unlock()
+
+	reply, err := NewPackedClientMessage(ClientMessageHello, newHelloData(ProtocolVersion, clientCaps).Map())
+	if err != nil {
+		return err
+	}
+
+	return wsClient.Send(ws.BinaryMessage, reply)
+}
+
+func (c *Client) ackMessage(wsClient *ws.Client, msg rtc.Message) error {
+	c.mut.Lock()
+	if c.lastSeqs == nil {
+		c.lastSeqs = map[string]uint64{}
+	}
+	if msg.Seq > c.lastSeqs[msg.SessionID] {
+		c.lastSeqs[msg.SessionID] = msg.Seq
+	}
+	replay := c.capabilities[CapabilityReplay]
+	c.mut.Unlock()
+
+	if !replay {
+		return nil
+	}
+
+	data, err := NewPackedClientMessage(ClientMessageAck, signaling.AckData{
+		SessionID: msg.SessionID,
+		Seq:       msg.Seq,
+	}.Map())
+	if err != nil {
+		return err
+	}
+
+	return wsClient.Send(ws.BinaryMessage, data)
+}
+
+// LastSeq returns the sequence number of the last signaling message received
+// for the given session. It should be sent along with the reconnect message
+// (as "lastSeq") to have any message lost in the meantime retransmitted.
+func (c *Client) LastSeq(sessionID string) uint64 {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.lastSeqs[sessionID]
+}
+
+// ProtocolVersion returns the signaling protocol version negotiated with the
+// server. Zero is returned if the negotiation hasn't happened yet.
+func (c *Client) ProtocolVersion() int {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.protocolVersion
+}
+
+// HasCapability returns whether the given capability is supported by both
+// the client and the server.
+func (c *Client) HasCapability(capability string) bool {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.capabilities[capability]
+}
+
 func (c *Client) reconnectHandler() {
 	var attempt int
 	var waitTime time.Duration
@@ -334,6 +671,9 @@ func (c *Client) reconnectHandler() {
 
 		err := c.Connect()
 		if err == nil {
+			if cb := c.getHandlers().reconnected; cb != nil {
+				cb(attempt)
+			}
 			break
 		}
 
@@ -346,7 +686,7 @@ func (c *Client) GetVersionInfo() (VersionInfo, error) {
 		return VersionInfo{}, fmt.Errorf("http client is not initialized")
 	}
 
-	req, err := http.NewRequest("GET", c.cfg.httpURL+"/version", nil)
+	req, err := http.NewRequest("GET", c.cfg.getHTTPURL("version"), nil)
 	if err != nil {
 		return VersionInfo{}, fmt.Errorf("failed to build request: %w", err)
 	}
@@ -0,0 +1,314 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/signaling"
+)
+
+// clientHandlers holds the callbacks registered through the typed event
+// API of the client.
+type clientHandlers struct {
+	events      map[rtc.EventType]func(ev rtc.Event)
+	rtcMsg      func(msg rtc.Message)
+	sessionEnd  func(sessionID, reason string)
+	err         func(err error)
+	reconnected func(attempt int)
+	shutdown    func(timeout time.Duration)
+	maintenance func(notice rtc.MaintenanceNotice)
+}
+
+// OnEvent registers a callback to be called whenever an event of the given
+// type is received. Events are only delivered by servers supporting the
+// events capability.
+func (c *Client) OnEvent(evType rtc.EventType, cb func(ev rtc.Event)) {
+	c.handlersMut.Lock()
+	defer c.handlersMut.Unlock()
+	if c.handlers.events == nil {
+		c.handlers.events = map[rtc.EventType]func(ev rtc.Event){}
+	}
+	c.handlers.events[evType] = cb
+}
+
+// OnCallStarted registers a callback to be called when a call starts.
+func (c *Client) OnCallStarted(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.CallStartedEvent, cb)
+}
+
+// OnCallEnded registers a callback to be called when a call ends.
+func (c *Client) OnCallEnded(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.CallEndedEvent, cb)
+}
+
+// OnSessionJoined registers a callback to be called when a session joins a
+// call.
+func (c *Client) OnSessionJoined(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.SessionJoinedEvent, cb)
+}
+
+// OnSessionLeft registers a callback to be called when a session leaves a
+// call.
+func (c *Client) OnSessionLeft(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.SessionLeftEvent, cb)
+}
+
+// OnStreamQualityChanged registers a callback to be called when the quality
+// level of a stream forwarded to a session changes. The event carries the
+// quality of the stream.
+func (c *Client) OnStreamQualityChanged(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.StreamQualityChangedEvent, cb)
+}
+
+// OnRecordingStarted registers a callback to be called when the recording
+// of a single participant starts. The event carries the recording info.
+func (c *Client) OnRecordingStarted(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.RecordingStartedEvent, cb)
+}
+
+// OnRecordingStopped registers a callback to be called when the recording
+// of a single participant stops. The event carries the final recording info.
+func (c *Client) OnRecordingStopped(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.RecordingStoppedEvent, cb)
+}
+
+// OnHLSStarted registers a callback to be called when the LL-HLS broadcast
+// of a call starts. The event carries the stream info.
+func (c *Client) OnHLSStarted(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.HLSStartedEvent, cb)
+}
+
+// OnHLSStopped registers a callback to be called when the LL-HLS broadcast
+// of a call stops.
+func (c *Client) OnHLSStopped(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.HLSStoppedEvent, cb)
+}
+
+// OnSessionMigrated registers a callback to be called when a session gets
+// re-anchored to the new network address of its client. The event carries
+// the previous and new addresses.
+func (c *Client) OnSessionMigrated(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.SessionMigratedEvent, cb)
+}
+
+// OnSessionMuted registers a callback to be called when the voice track of
+// a session stops being forwarded.
+func (c *Client) OnSessionMuted(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.SessionMutedEvent, cb)
+}
+
+// OnSessionUnmuted registers a callback to be called when the voice track of
+// a session starts being forwarded again.
+func (c *Client) OnSessionUnmuted(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.SessionUnmutedEvent, cb)
+}
+
+// OnICERestarted registers a callback to be called when the server restarts
+// ICE for a session.
+func (c *Client) OnICERestarted(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.ICERestartedEvent, cb)
+}
+
+// OnTrackStalled registers a callback to be called when no packet was
+// received on a track published by a session for longer than the configured
+// timeout. The event carries the track ID.
+func (c *Client) OnTrackStalled(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.TrackStalledEvent, cb)
+}
+
+// OnTrackResumed registers a callback to be called when packets flow again
+// on a stalled track. The event carries the track ID.
+func (c *Client) OnTrackResumed(cb func(ev rtc.Event)) {
+	c.OnEvent(rtc.TrackResumedEvent, cb)
+}
+
+// OnRTCMessage registers a callback to be called with the signaling
+// messages meant for the client's sessions.
+func (c *Client) OnRTCMessage(cb func(msg rtc.Message)) {
+	c.handlersMut.Lock()
+	defer c.handlersMut.Unlock()
+	c.handlers.rtcMsg = cb
+}
+
+// OnSessionClose registers a callback to be called when the server closes
+// one of the client's sessions, with the reason it was closed with (e.g.
+// rtc.CloseReasonLeft). The reason is empty with servers predating close
+// reasons.
+func (c *Client) OnSessionClose(cb func(sessionID, reason string)) {
+	c.handlersMut.Lock()
+	defer c.handlersMut.Unlock()
+	c.handlers.sessionEnd = cb
+}
+
+// OnError registers a callback to be called on errors. Errors are no longer
+// delivered through ErrorCh once set.
+func (c *Client) OnError(cb func(err error)) {
+	c.handlersMut.Lock()
+	defer c.handlersMut.Unlock()
+	c.handlers.err = cb
+}
+
+// OnReconnect registers a callback to be called once the client has
+// successfully re-connected, along with the number of attempts it took.
+func (c *Client) OnReconnect(cb func(attempt int)) {
+	c.handlersMut.Lock()
+	defer c.handlersMut.Unlock()
+	c.handlers.reconnected = cb
+}
+
+// OnShutdown registers a callback to be called when the server notifies it
+// is shutting down, along with the time left before the client's sessions
+// get force-closed. Notifications are only delivered by servers supporting
+// the shutdown capability.
+func (c *Client) OnShutdown(cb func(timeout time.Duration)) {
+	c.handlersMut.Lock()
+	defer c.handlersMut.Unlock()
+	c.handlers.shutdown = cb
+}
+
+// OnMaintenance registers a callback to be called when the server notifies
+// an upcoming maintenance window, or its cancellation. Notifications are only
+// delivered by servers supporting the maintenance capability.
+func (c *Client) OnMaintenance(cb func(notice rtc.MaintenanceNotice)) {
+	c.handlersMut.Lock()
+	defer c.handlersMut.Unlock()
+	c.handlers.maintenance = cb
+}
+
+func (c *Client) getHandlers() clientHandlers {
+	c.handlersMut.RLock()
+	defer c.handlersMut.RUnlock()
+	return c.handlers
+}
+
+// dispatch calls the handler registered for the given message, if any. It
+// returns whether the message was handled.
+func (c *Client) dispatch(cm ClientMessage) bool {
+	h := c.getHandlers()
+
+	switch cm.Type {
+	case ClientMessageEvent:
+		data, ok := cm.Data.(map[string]string)
+		if !ok {
+			return false
+		}
+		cb := h.events[rtc.EventType(data["type"])]
+		if cb == nil {
+			return false
+		}
+		ev, err := parseEvent(data)
+		if err != nil {
+			c.sendError(err)
+			return true
+		}
+		cb(ev)
+		return true
+	case ClientMessageRTC:
+		msg, ok := cm.Data.(rtc.Message)
+		if !ok || h.rtcMsg == nil {
+			return false
+		}
+		h.rtcMsg(msg)
+		return true
+	case ClientMessageClose:
+		data, ok := cm.Data.(map[string]string)
+		if !ok || h.sessionEnd == nil {
+			return false
+		}
+		closeData, err := signaling.ParseCloseData(data)
+		if err != nil {
+			c.sendError(fmt.Errorf("failed to parse close message: %w", err))
+			return true
+		}
+		h.sessionEnd(closeData.SessionID, closeData.Reason)
+		return true
+	case ClientMessageShutdown:
+		data, ok := cm.Data.(map[string]string)
+		if !ok || h.shutdown == nil {
+			return false
+		}
+		shutdown, err := signaling.ParseShutdownData(data)
+		if err != nil {
+			c.sendError(fmt.Errorf("failed to parse shutdown message: %w", err))
+			return true
+		}
+		h.shutdown(time.Duration(shutdown.TimeoutSeconds) * time.Second)
+		return true
+	case ClientMessageMaintenance:
+		data, ok := cm.Data.(map[string]string)
+		if !ok || h.maintenance == nil {
+			return false
+		}
+		maintenance, err := signaling.ParseMaintenanceData(data)
+		if err != nil {
+			c.sendError(fmt.Errorf("failed to parse maintenance message: %w", err))
+			return true
+		}
+		h.maintenance(rtc.MaintenanceNotice{
+			ID:        maintenance.ID,
+			Action:    maintenance.Action,
+			StartAt:   maintenance.StartAt,
+			Cancelled: maintenance.Cancelled,
+		})
+		return true
+	}
+
+	return false
+}
+
+func parseEvent(m map[string]string) (rtc.Event, error) {
+	data, err := signaling.ParseEventData(m)
+	if err != nil {
+		return rtc.Event{}, fmt.Errorf("failed to parse event: %w", err)
+	}
+
+	ev := rtc.Event{
+		Type:      rtc.EventType(data.Type),
+		Timestamp: data.Timestamp,
+		GroupID:   data.GroupID,
+		CallID:    data.CallID,
+		UserID:    data.UserID,
+		SessionID: data.SessionID,
+		TraceID:   data.TraceID,
+		TrackID:   data.TrackID,
+	}
+
+	if js := data.Quality; js != "" {
+		var quality rtc.StreamQuality
+		if err := json.Unmarshal([]byte(js), &quality); err != nil {
+			return rtc.Event{}, fmt.Errorf("failed to parse event quality: %w", err)
+		}
+		ev.Quality = &quality
+	}
+
+	if js := data.Recording; js != "" {
+		var recording rtc.RecordingInfo
+		if err := json.Unmarshal([]byte(js), &recording); err != nil {
+			return rtc.Event{}, fmt.Errorf("failed to parse event recording: %w", err)
+		}
+		ev.Recording = &recording
+	}
+
+	if js := data.HLS; js != "" {
+		var info rtc.HLSStreamInfo
+		if err := json.Unmarshal([]byte(js), &info); err != nil {
+			return rtc.Event{}, fmt.Errorf("failed to parse event hls: %w", err)
+		}
+		ev.HLS = &info
+	}
+
+	if js := data.Migration; js != "" {
+		var migration rtc.SessionMigration
+		if err := json.Unmarshal([]byte(js), &migration); err != nil {
+			return rtc.Event{}, fmt.Errorf("failed to parse event migration: %w", err)
+		}
+		ev.Migration = &migration
+	}
+
+	return ev, nil
+}
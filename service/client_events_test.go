@@ -0,0 +1,237 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientEvents(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	clientID := "clientA"
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = th.adminClient.Register(clientID, authKey)
+	require.NoError(t, err)
+
+	c, err := NewClient(ClientConfig{
+		URL:      th.apiURL,
+		ClientID: clientID,
+		AuthKey:  authKey,
+	})
+	require.NoError(t, err)
+
+	callStartedCh := make(chan rtc.Event, 1)
+	sessionJoinedCh := make(chan rtc.Event, 1)
+	sessionCloseCh := make(chan [2]string, 1)
+	c.OnCallStarted(func(ev rtc.Event) {
+		callStartedCh <- ev
+	})
+	c.OnSessionJoined(func(ev rtc.Event) {
+		sessionJoinedCh <- ev
+	})
+	c.OnSessionClose(func(sessionID, reason string) {
+		sessionCloseCh <- [2]string{sessionID, reason}
+	})
+
+	err = c.Connect()
+	require.NoError(t, err)
+	defer c.Close()
+
+	// Waiting for the protocol negotiation to happen.
+	msg := <-c.ReceiveCh()
+	require.Equal(t, ClientMessageHello, msg.Type)
+	require.Eventually(t, func() bool {
+		th.srvc.mut.RLock()
+		defer th.srvc.mut.RUnlock()
+		return len(th.srvc.connProtocols) > 0
+	}, time.Second, 10*time.Millisecond)
+	require.True(t, c.HasCapability(CapabilityEvents))
+
+	err = c.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+		"callID":    "callID",
+		"userID":    "userID",
+		"sessionID": "sessionID",
+	}))
+	require.NoError(t, err)
+
+	select {
+	case ev := <-callStartedCh:
+		require.Equal(t, rtc.CallStartedEvent, ev.Type)
+		require.Equal(t, clientID, ev.GroupID)
+		require.Equal(t, "callID", ev.CallID)
+		require.NotZero(t, ev.Timestamp)
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "timed out waiting for call started event")
+	}
+
+	select {
+	case ev := <-sessionJoinedCh:
+		require.Equal(t, rtc.SessionJoinedEvent, ev.Type)
+		require.Equal(t, "sessionID", ev.SessionID)
+		require.Equal(t, "userID", ev.UserID)
+		// A trace ID is generated as none was passed on join.
+		require.Len(t, ev.TraceID, 26)
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "timed out waiting for session joined event")
+	}
+
+	err = c.Send(*NewClientMessage(ClientMessageLeave, map[string]string{
+		"sessionID": "sessionID",
+		"reason":    rtc.CloseReasonKicked,
+	}))
+	require.NoError(t, err)
+
+	select {
+	case closed := <-sessionCloseCh:
+		require.Equal(t, [2]string{"sessionID", rtc.CloseReasonKicked}, closed)
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "timed out waiting for session close")
+	}
+}
+
+func TestClientDispatch(t *testing.T) {
+	c, err := NewClient(ClientConfig{URL: "http://localhost"})
+	require.NoError(t, err)
+
+	t.Run("no handlers", func(t *testing.T) {
+		require.False(t, c.dispatch(ClientMessage{Type: ClientMessageRTC, Data: rtc.Message{}}))
+		require.False(t, c.dispatch(ClientMessage{Type: ClientMessageEvent, Data: map[string]string{
+			"type": string(rtc.CallEndedEvent),
+		}}))
+	})
+
+	t.Run("rtc message", func(t *testing.T) {
+		var received rtc.Message
+		c.OnRTCMessage(func(msg rtc.Message) {
+			received = msg
+		})
+		require.True(t, c.dispatch(ClientMessage{Type: ClientMessageRTC, Data: rtc.Message{SessionID: "sessionID"}}))
+		require.Equal(t, "sessionID", received.SessionID)
+	})
+
+	t.Run("invalid event", func(t *testing.T) {
+		var handlerErr error
+		c.OnError(func(err error) {
+			handlerErr = err
+		})
+		c.OnCallEnded(func(_ rtc.Event) {
+			require.Fail(t, "should not be called")
+		})
+		require.True(t, c.dispatch(ClientMessage{Type: ClientMessageEvent, Data: map[string]string{
+			"type":      string(rtc.CallEndedEvent),
+			"timestamp": "invalid",
+		}}))
+		require.Error(t, handlerErr)
+	})
+
+	t.Run("stream quality", func(t *testing.T) {
+		var received rtc.Event
+		c.OnStreamQualityChanged(func(ev rtc.Event) {
+			received = ev
+		})
+		require.True(t, c.dispatch(ClientMessage{Type: ClientMessageEvent, Data: map[string]string{
+			"type":      string(rtc.StreamQualityChangedEvent),
+			"timestamp": "1000",
+			"sessionID": "sessionID",
+			"quality":   `{"track_id":"trackID","mos":2.5,"level":"poor"}`,
+		}}))
+		require.Equal(t, "sessionID", received.SessionID)
+		require.NotNil(t, received.Quality)
+		require.Equal(t, rtc.StreamQuality{TrackID: "trackID", MOS: 2.5, Level: rtc.QualityLevelPoor}, *received.Quality)
+	})
+
+	t.Run("recording", func(t *testing.T) {
+		var received rtc.Event
+		c.OnRecordingStopped(func(ev rtc.Event) {
+			received = ev
+		})
+		require.True(t, c.dispatch(ClientMessage{Type: ClientMessageEvent, Data: map[string]string{
+			"type":      string(rtc.RecordingStoppedEvent),
+			"timestamp": "1000",
+			"sessionID": "sessionID",
+			"recording": `{"id":"recordingID","dir":"/tmp/recordingID","files":["voice.ogg"],"reason":"stopped"}`,
+		}}))
+		require.Equal(t, "sessionID", received.SessionID)
+		require.NotNil(t, received.Recording)
+		require.Equal(t, rtc.RecordingInfo{
+			ID:     "recordingID",
+			Dir:    "/tmp/recordingID",
+			Files:  []string{"voice.ogg"},
+			Reason: "stopped",
+		}, *received.Recording)
+	})
+
+	t.Run("session migration", func(t *testing.T) {
+		var received rtc.Event
+		c.OnSessionMigrated(func(ev rtc.Event) {
+			received = ev
+		})
+		require.True(t, c.dispatch(ClientMessage{Type: ClientMessageEvent, Data: map[string]string{
+			"type":      string(rtc.SessionMigratedEvent),
+			"timestamp": "1000",
+			"sessionID": "sessionID",
+			"migration": `{"previous_address":"192.168.1.10:50000","address":"203.0.113.10:40000"}`,
+		}}))
+		require.Equal(t, "sessionID", received.SessionID)
+		require.NotNil(t, received.Migration)
+		require.Equal(t, rtc.SessionMigration{
+			PreviousAddress: "192.168.1.10:50000",
+			Address:         "203.0.113.10:40000",
+		}, *received.Migration)
+	})
+
+	t.Run("track stalled", func(t *testing.T) {
+		var received rtc.Event
+		c.OnTrackStalled(func(ev rtc.Event) {
+			received = ev
+		})
+		require.True(t, c.dispatch(ClientMessage{Type: ClientMessageEvent, Data: map[string]string{
+			"type":      string(rtc.TrackStalledEvent),
+			"timestamp": "1000",
+			"sessionID": "sessionID",
+			"trackID":   "screen_sessionID_trackID",
+		}}))
+		require.Equal(t, rtc.TrackStalledEvent, received.Type)
+		require.Equal(t, "sessionID", received.SessionID)
+		require.Equal(t, "screen_sessionID_trackID", received.TrackID)
+	})
+	t.Run("shutdown", func(t *testing.T) {
+		var received time.Duration
+		c.OnShutdown(func(timeout time.Duration) {
+			received = timeout
+		})
+		require.True(t, c.dispatch(ClientMessage{Type: ClientMessageShutdown, Data: map[string]string{
+			"timeoutSeconds": "30",
+		}}))
+		require.Equal(t, 30*time.Second, received)
+	})
+	t.Run("maintenance", func(t *testing.T) {
+		var received rtc.MaintenanceNotice
+		c.OnMaintenance(func(notice rtc.MaintenanceNotice) {
+			received = notice
+		})
+		require.True(t, c.dispatch(ClientMessage{Type: ClientMessageMaintenance, Data: map[string]string{
+			"id":        "maintenanceID",
+			"action":    rtc.MaintenanceActionDrain,
+			"startAt":   "1700000000000",
+			"cancelled": "true",
+		}}))
+		require.Equal(t, rtc.MaintenanceNotice{
+			ID:        "maintenanceID",
+			Action:    rtc.MaintenanceActionDrain,
+			StartAt:   1700000000000,
+			Cancelled: true,
+		}, received)
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 
 	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/signaling"
 
 	"github.com/vmihailenco/msgpack/v5"
 )
@@ -16,13 +17,53 @@ type ClientMessage struct {
 	Data interface{} `msgpack:"data,omitempty"`
 }
 
+// The types of the client messages, defined along with the data they carry
+// in the signaling package.
 const (
-	ClientMessageJoin      = "join"
-	ClientMessageLeave     = "leave"
-	ClientMessageRTC       = "rtc"
-	ClientMessageHello     = "hello"
-	ClientMessageReconnect = "reconnect"
-	ClientMessageClose     = "close"
+	ClientMessageJoin      = signaling.TypeJoin
+	ClientMessageLeave     = signaling.TypeLeave
+	ClientMessageRTC       = signaling.TypeRTC
+	ClientMessageHello     = signaling.TypeHello
+	ClientMessageReconnect = signaling.TypeReconnect
+	ClientMessageClose     = signaling.TypeClose
+	ClientMessageAck       = signaling.TypeAck
+	ClientMessageResync    = signaling.TypeResync
+	ClientMessageCallState = signaling.TypeCallState
+	ClientMessageEvent     = signaling.TypeEvent
+	ClientMessageGroupAuth = signaling.TypeGroupAuth
+
+	ClientMessageTranscriptionStart = signaling.TypeTranscriptionStart
+	ClientMessageTranscriptionStop  = signaling.TypeTranscriptionStop
+
+	ClientMessageRecordingStart = signaling.TypeRecordingStart
+	ClientMessageRecordingStop  = signaling.TypeRecordingStop
+
+	ClientMessageHLSStart = signaling.TypeHLSStart
+	ClientMessageHLSStop  = signaling.TypeHLSStop
+
+	// ClientMessageShutdown notifies the clients supporting the shutdown
+	// capability that the server is shutting down.
+	ClientMessageShutdown = signaling.TypeShutdown
+
+	// ClientMessageError reports the failure to handle a client message to
+	// the clients supporting the errors capability.
+	ClientMessageError = signaling.TypeError
+
+	// ClientMessageMaintenance notifies the clients supporting the
+	// maintenance capability of an upcoming maintenance window, or of its
+	// cancellation.
+	ClientMessageMaintenance = signaling.TypeMaintenance
+
+	// ClientMessageP2PPeer notifies the sessions of a call negotiated
+	// peer-to-peer of their peer joining or leaving.
+	ClientMessageP2PPeer = signaling.TypeP2PPeer
+	// ClientMessageP2PSignal carries the signaling data exchanged between
+	// the sessions of a call negotiated peer-to-peer.
+	ClientMessageP2PSignal = signaling.TypeP2PSignal
+	// ClientMessageP2PUpgrade notifies the sessions of a call negotiated
+	// peer-to-peer that it's now routed through the server, for them to
+	// negotiate with it as if they had just joined.
+	ClientMessageP2PUpgrade = signaling.TypeP2PUpgrade
 )
 
 var _ msgpack.CustomEncoder = (*ClientMessage)(nil)
@@ -40,19 +81,19 @@ func (cm *ClientMessage) DecodeMsgpack(dec *msgpack.Decoder) error {
 	}
 	cm.Type = msgType
 
-	switch cm.Type {
-	case ClientMessageJoin, ClientMessageLeave, ClientMessageHello, ClientMessageReconnect, ClientMessageClose:
-		data, err := dec.DecodeTypedMap()
-		if err != nil {
-			return fmt.Errorf("failed to decode msg.Data: %w", err)
-		}
-		cm.Data = data
-	case ClientMessageRTC:
+	switch {
+	case cm.Type == ClientMessageRTC:
 		var rtcMsg rtc.Message
 		if err = dec.Decode(&rtcMsg); err != nil {
 			return fmt.Errorf("failed to decode rtc.Message: %w", err)
 		}
 		cm.Data = rtcMsg
+	case signaling.HasMapData(cm.Type):
+		data, err := dec.DecodeTypedMap()
+		if err != nil {
+			return fmt.Errorf("failed to decode msg.Data: %w", err)
+		}
+		cm.Data = data
 	default:
 		data, err := dec.DecodeInterface()
 		if err != nil {
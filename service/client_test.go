@@ -5,17 +5,26 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
 	"runtime"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/mattermost/rtcd/service/api"
 	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/fips"
 	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
 	"github.com/mattermost/rtcd/service/ws"
 
 	"github.com/stretchr/testify/require"
@@ -49,7 +58,7 @@ func TestNewClient(t *testing.T) {
 		require.NoError(t, err)
 		require.NotNil(t, c)
 		require.NotEmpty(t, c)
-		require.Equal(t, apiURL, c.cfg.httpURL)
+		require.Equal(t, apiURL, c.cfg.httpURL.String())
 		require.Equal(t, "ws://localhost/ws", c.cfg.wsURL)
 	})
 
@@ -59,7 +68,7 @@ func TestNewClient(t *testing.T) {
 		require.NoError(t, err)
 		require.NotNil(t, c)
 		require.NotEmpty(t, c)
-		require.Equal(t, apiURL, c.cfg.httpURL)
+		require.Equal(t, apiURL, c.cfg.httpURL.String())
 		require.Equal(t, "wss://localhost/ws", c.cfg.wsURL)
 	})
 
@@ -75,7 +84,7 @@ func TestNewClient(t *testing.T) {
 		require.NoError(t, err)
 		require.NotNil(t, c)
 		require.NotEmpty(t, c)
-		require.Equal(t, apiURL, c.cfg.httpURL)
+		require.Equal(t, apiURL, c.cfg.httpURL.String())
 		require.Equal(t, "ws://localhost/ws", c.cfg.wsURL)
 
 		_ = c.Register("", "")
@@ -137,6 +146,7 @@ func TestClientRegister(t *testing.T) {
 		err = c.Register("", "")
 		require.Error(t, err)
 		require.Equal(t, "request failed: authentication failed: unauthorized", err.Error())
+		require.Equal(t, ErrorCodeAuthFailed, ErrorCodeOf(err))
 	})
 
 	t.Run("self registering", func(t *testing.T) {
@@ -159,6 +169,50 @@ func TestClientRegister(t *testing.T) {
 	})
 }
 
+func TestClientBootstrap(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	c, err := NewClient(ClientConfig{
+		URL: th.apiURL,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	defer c.Close()
+
+	token, err := auth.NewBootstrapToken(th.srvc.cfg.API.Security.AdminSecretKey, "clientA", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	t.Run("not enabled", func(t *testing.T) {
+		_, _, err := c.Bootstrap(token)
+		require.EqualError(t, err, "request failed: bootstrap tokens not enabled")
+	})
+
+	th.srvc.cfg.API.Security.AllowBootstrapTokens = true
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, _, err := c.Bootstrap("")
+		require.EqualError(t, err, "request failed: registration failed: invalid bootstrap token: malformed")
+
+		other, err := auth.NewBootstrapToken("other_key", "clientA", time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		_, _, err = c.Bootstrap(other)
+		require.EqualError(t, err, "request failed: registration failed: invalid bootstrap token: bad signature")
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		clientID, authKey, err := c.Bootstrap(token)
+		require.NoError(t, err)
+		require.Equal(t, "clientA", clientID)
+		require.NoError(t, th.srvc.auth.Authenticate(clientID, authKey))
+	})
+
+	t.Run("already used", func(t *testing.T) {
+		_, _, err := c.Bootstrap(token)
+		require.EqualError(t, err, "request failed: registration failed: bootstrap token was already used")
+	})
+}
+
 func TestClientUnregister(t *testing.T) {
 	th := SetupTestHelper(t, nil)
 	defer th.Teardown()
@@ -393,6 +447,409 @@ func TestClientReceive(t *testing.T) {
 	wg.Wait()
 }
 
+func TestClientProtocolNegotiation(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	clientID := "clientA"
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = th.adminClient.Register(clientID, authKey)
+	require.NoError(t, err)
+
+	c, err := NewClient(ClientConfig{
+		URL:          th.apiURL,
+		ClientID:     clientID,
+		AuthKey:      authKey,
+		Capabilities: []string{CapabilityCodecOpus, CapabilityCaptions, CapabilityE2EE},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	require.Zero(t, c.ProtocolVersion())
+
+	err = c.Connect()
+	require.NoError(t, err)
+	defer c.Close()
+
+	msg, ok := <-c.ReceiveCh()
+	require.True(t, ok)
+	require.Equal(t, ClientMessageHello, msg.Type)
+	msgData, ok := msg.Data.(map[string]string)
+	require.True(t, ok)
+	require.Equal(t, strconv.Itoa(ProtocolVersion), msgData["protocolVersion"])
+	require.Equal(t, formatCapabilities(serverCapabilities), msgData["capabilities"])
+
+	require.Equal(t, ProtocolVersion, c.ProtocolVersion())
+	require.True(t, c.HasCapability(CapabilityCodecOpus))
+	require.True(t, c.HasCapability(CapabilityCaptions))
+	require.False(t, c.HasCapability(CapabilityCodecVP8))
+	require.False(t, c.HasCapability(CapabilityE2EE))
+
+	require.Eventually(t, func() bool {
+		info := th.srvc.getConnProtocol(msgData["connID"])
+		return info.version == ProtocolVersion && info.hasCapability(CapabilityCaptions)
+	}, 5*time.Second, 50*time.Millisecond)
+
+	info := th.srvc.getConnProtocol("unknownConnID")
+	require.Equal(t, 1, info.version)
+	require.False(t, info.hasCapability(CapabilityCaptions))
+}
+
+func TestClientErrors(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	clientID := "clientA"
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = th.adminClient.Register(clientID, authKey)
+	require.NoError(t, err)
+
+	connect := func(t *testing.T, capabilities []string) *Client {
+		t.Helper()
+		c, err := NewClient(ClientConfig{
+			URL:          th.apiURL,
+			ClientID:     clientID,
+			AuthKey:      authKey,
+			Capabilities: capabilities,
+		})
+		require.NoError(t, err)
+		err = c.Connect()
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			c.Close()
+		})
+
+		msg, ok := <-c.ReceiveCh()
+		require.True(t, ok)
+		require.Equal(t, ClientMessageHello, msg.Type)
+		connID := msg.Data.(map[string]string)["connID"]
+		require.Eventually(t, func() bool {
+			return th.srvc.getConnProtocol(connID).version == ProtocolVersion
+		}, 5*time.Second, 50*time.Millisecond)
+
+		return c
+	}
+
+	waitForError := func(t *testing.T, c *Client) error {
+		t.Helper()
+		select {
+		case err := <-c.ErrorCh():
+			return err
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "timed out waiting for error")
+		}
+		return nil
+	}
+
+	t.Run("bad message", func(t *testing.T) {
+		c := connect(t, nil)
+
+		err := c.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+			"userID":    "userA",
+			"sessionID": "sessionA",
+		}))
+		require.NoError(t, err)
+
+		err = waitForError(t, c)
+		require.EqualError(t, err, "failed to handle join message: missing callID in client message")
+		require.Equal(t, ErrorCodeBadRequest, ErrorCodeOf(err))
+
+		err = c.Send(*NewClientMessage(ClientMessageLeave, map[string]string{
+			"sessionID": "sessionA",
+			"reason":    rtc.CloseReasonIdle,
+		}))
+		require.NoError(t, err)
+
+		err = waitForError(t, c)
+		require.EqualError(t, err, `failed to handle leave message: invalid reason "idle" in client message`)
+		require.Equal(t, ErrorCodeBadRequest, ErrorCodeOf(err))
+	})
+
+	t.Run("codec unsupported", func(t *testing.T) {
+		c := connect(t, []string{CapabilityCodecVP8, CapabilityErrors})
+
+		err := c.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+			"callID":    "callA",
+			"userID":    "userA",
+			"sessionID": "sessionA",
+			"traceID":   "traceA",
+		}))
+		require.NoError(t, err)
+
+		msg, ok := <-c.ReceiveCh()
+		require.True(t, ok)
+		require.Equal(t, ClientMessageClose, msg.Type)
+		require.Equal(t, map[string]string{
+			"sessionID": "sessionA",
+			"traceID":   "traceA",
+			"reason":    closeReasonCodecUnsupported,
+			"errorCode": string(ErrorCodeCodecUnsupported),
+		}, msg.Data)
+
+		err = waitForError(t, c)
+		require.Equal(t, ErrorCodeCodecUnsupported, ErrorCodeOf(err))
+
+		_, err = th.srvc.rtcServer.GetCallState(clientID, "callA")
+		require.Error(t, err)
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		c := connect(t, []string{CapabilityCodecOpus})
+
+		err := c.Send(*NewClientMessage(ClientMessageLeave, map[string]string{}))
+		require.NoError(t, err)
+
+		select {
+		case err := <-c.ErrorCh():
+			require.Fail(t, "unexpected error", err.Error())
+		case <-time.After(500 * time.Millisecond):
+		}
+	})
+}
+
+func TestClientReplay(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	clientID := "clientA"
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = th.adminClient.Register(clientID, authKey)
+	require.NoError(t, err)
+
+	c, err := NewClient(ClientConfig{
+		URL:      th.apiURL,
+		ClientID: clientID,
+		AuthKey:  authKey,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	err = c.Connect()
+	require.NoError(t, err)
+	defer c.Close()
+
+	msg, ok := <-c.ReceiveCh()
+	require.True(t, ok)
+	require.Equal(t, ClientMessageHello, msg.Type)
+	connID := msg.Data.(map[string]string)["connID"]
+	require.Eventually(t, func() bool {
+		return th.srvc.getConnProtocol(connID).hasCapability(CapabilityReplay)
+	}, 5*time.Second, 50*time.Millisecond)
+	require.True(t, c.HasCapability(CapabilityReplay))
+
+	sessionID := "sessionID"
+	buf := newReplayBuffer()
+	th.srvc.mut.Lock()
+	th.srvc.connMap[sessionID] = connID
+	th.srvc.sessionScopes[sessionID] = sessionScope{groupID: clientID, callID: "callID"}
+	th.srvc.replayBuffers[sessionID] = buf
+	th.srvc.mut.Unlock()
+
+	rtcMsg := rtc.Message{
+		GroupID:   clientID,
+		SessionID: sessionID,
+		Type:      rtc.ICEMessage,
+		Data:      []byte("candidate"),
+	}
+
+	t.Run("ack", func(t *testing.T) {
+		for i := 1; i <= 3; i++ {
+			err := th.srvc.handleRTCMsg(rtcMsg)
+			require.NoError(t, err)
+
+			msg, ok := <-c.ReceiveCh()
+			require.True(t, ok)
+			require.Equal(t, ClientMessageRTC, msg.Type)
+			require.Equal(t, uint64(i), msg.Data.(rtc.Message).Seq)
+		}
+
+		require.Equal(t, uint64(3), c.LastSeq(sessionID))
+		require.Eventually(t, func() bool {
+			return len(buf.since(0)) == 0
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		// Simulating messages sent while the client was disconnected.
+		th.srvc.mut.Lock()
+		th.srvc.connMap[sessionID] = "lostConnID"
+		th.srvc.mut.Unlock()
+		for i := 0; i < 2; i++ {
+			err := th.srvc.handleRTCMsg(rtcMsg)
+			require.NoError(t, err)
+		}
+
+		err := c.Send(*NewClientMessage(ClientMessageReconnect, map[string]string{
+			"sessionID": sessionID,
+			"lastSeq":   strconv.FormatUint(c.LastSeq(sessionID), 10),
+		}))
+		require.NoError(t, err)
+
+		for i := 4; i <= 5; i++ {
+			msg, ok := <-c.ReceiveCh()
+			require.True(t, ok)
+			require.Equal(t, ClientMessageRTC, msg.Type)
+			require.Equal(t, uint64(i), msg.Data.(rtc.Message).Seq)
+		}
+		require.Equal(t, uint64(5), c.LastSeq(sessionID))
+	})
+
+	t.Run("ack from another connection", func(t *testing.T) {
+		require.Eventually(t, func() bool {
+			return len(buf.since(0)) == 0
+		}, 5*time.Second, 50*time.Millisecond)
+
+		th.srvc.mut.Lock()
+		th.srvc.connMap[sessionID] = "otherConnID"
+		th.srvc.mut.Unlock()
+		err := th.srvc.handleRTCMsg(rtcMsg)
+		require.NoError(t, err)
+
+		err = c.Send(*NewClientMessage(ClientMessageAck, map[string]string{
+			"sessionID": sessionID,
+			"seq":       "6",
+		}))
+		require.NoError(t, err)
+		select {
+		case err := <-c.ErrorCh():
+			require.Equal(t, ErrorCodeForbidden, ErrorCodeOf(err))
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "timed out waiting for error")
+		}
+		require.Len(t, buf.since(0), 1)
+	})
+
+	t.Run("reconnect from another client", func(t *testing.T) {
+		th.srvc.mut.Lock()
+		th.srvc.connMap[sessionID] = connID
+		th.srvc.mut.Unlock()
+
+		for _, id := range []string{sessionID, "unknownSessionID"} {
+			data, err := NewPackedClientMessage(ClientMessageReconnect, map[string]string{
+				"sessionID": id,
+				"lastSeq":   "0",
+			})
+			require.NoError(t, err)
+			err = th.srvc.handleClientMsg(ws.Message{
+				ConnID:   "otherConnID",
+				ClientID: "clientB",
+				Type:     ws.BinaryMessage,
+				Data:     data,
+			})
+			require.Error(t, err)
+		}
+		_, err := th.srvc.checkSessionGroup("otherConnID", "clientB", sessionID)
+		require.Equal(t, ErrorCodeForbidden, errorCode(err))
+		_, err = th.srvc.checkSessionGroup("otherConnID", "clientB", "unknownSessionID")
+		require.Equal(t, ErrorCodeNotFound, errorCode(err))
+
+		// Neither rebound nor replayed.
+		th.srvc.mut.RLock()
+		require.Equal(t, connID, th.srvc.connMap[sessionID])
+		_, ok := th.srvc.connMap["unknownSessionID"]
+		require.False(t, ok)
+		th.srvc.mut.RUnlock()
+		require.NotEmpty(t, buf.since(0))
+
+		// Unless the group of the session got authenticated on the
+		// connection.
+		th.srvc.mut.Lock()
+		th.srvc.connGroups["otherConnID"] = map[string]bool{clientID: true}
+		th.srvc.mut.Unlock()
+		defer func() {
+			th.srvc.mut.Lock()
+			delete(th.srvc.connGroups, "otherConnID")
+			th.srvc.mut.Unlock()
+		}()
+		scope, err := th.srvc.checkSessionGroup("otherConnID", "clientB", sessionID)
+		require.NoError(t, err)
+		require.Equal(t, clientID, scope.groupID)
+	})
+
+	t.Run("reconnect without replay", func(t *testing.T) {
+		data, err := NewPackedClientMessage(ClientMessageReconnect, map[string]string{
+			"sessionID": sessionID,
+		})
+		require.NoError(t, err)
+		err = th.srvc.handleClientMsg(ws.Message{
+			ConnID:   "legacyConnID",
+			ClientID: clientID,
+			Type:     ws.BinaryMessage,
+			Data:     data,
+		})
+		require.NoError(t, err)
+
+		th.srvc.mut.RLock()
+		require.Nil(t, th.srvc.replayBuffers[sessionID])
+		th.srvc.mut.RUnlock()
+	})
+}
+
+func TestClientResync(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	clientID := "clientA"
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = th.adminClient.Register(clientID, authKey)
+	require.NoError(t, err)
+
+	c, err := NewClient(ClientConfig{
+		URL:      th.apiURL,
+		ClientID: clientID,
+		AuthKey:  authKey,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	err = c.Connect()
+	require.NoError(t, err)
+	defer c.Close()
+
+	msg, ok := <-c.ReceiveCh()
+	require.True(t, ok)
+	require.Equal(t, ClientMessageHello, msg.Type)
+
+	err = c.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+		"callID":    "callID",
+		"userID":    "userID",
+		"sessionID": "sessionID",
+	}))
+	require.NoError(t, err)
+
+	err = c.Send(*NewClientMessage(ClientMessageResync, map[string]string{
+		"callID": "callID",
+	}))
+	require.NoError(t, err)
+
+	for msg := range c.ReceiveCh() {
+		if msg.Type != ClientMessageCallState {
+			continue
+		}
+		data, ok := msg.Data.(map[string]string)
+		require.True(t, ok)
+		require.Equal(t, "callID", data["callID"])
+
+		var state rtc.CallState
+		err := json.Unmarshal([]byte(data["state"]), &state)
+		require.NoError(t, err)
+		require.Equal(t, clientID, state.GroupID)
+		require.Equal(t, "callID", state.CallID)
+		require.Len(t, state.Sessions, 1)
+		require.Equal(t, "sessionID", state.Sessions[0].SessionID)
+		require.Equal(t, "userID", state.Sessions[0].UserID)
+		break
+	}
+
+	err = c.Send(*NewClientMessage(ClientMessageLeave, map[string]string{
+		"sessionID": "sessionID",
+	}))
+	require.NoError(t, err)
+}
+
 func TestClientReconnect(t *testing.T) {
 	th := SetupTestHelper(t, nil)
 	defer th.Teardown()
@@ -628,10 +1085,342 @@ func TestClientGetVersionInfo(t *testing.T) {
 		require.NoError(t, err)
 		require.NotEmpty(t, info)
 		require.Equal(t, VersionInfo{
-			BuildHash:    buildHash,
-			BuildDate:    buildDate,
-			BuildVersion: buildVersion,
-			GoVersion:    runtime.Version(),
+			BuildHash:         buildHash,
+			BuildDate:         buildDate,
+			BuildVersion:      buildVersion,
+			GoVersion:         runtime.Version(),
+			CryptoModule:      fips.Module(),
+			AESImplementation: rtc.AESImplementation(),
+			MinAPIVersion:     api.MinVersion,
+			APIVersion:        api.CurrentVersion,
 		}, info)
 	})
+
+	t.Run("versioned path", func(t *testing.T) {
+		resp, err := http.Get(th.apiURL + api.VersionPath(api.CurrentVersion, "/version"))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, strconv.Itoa(api.CurrentVersion), resp.Header.Get(api.VersionHeader))
+	})
+}
+
+func TestClientJoinToken(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.API.Security.JoinTokens = auth.JoinTokenConfig{
+		Enable:            true,
+		ExpirationMinutes: 5,
+	}
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	clientID := "clientA"
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = th.adminClient.Register(clientID, authKey)
+	require.NoError(t, err)
+
+	c, err := NewClient(ClientConfig{
+		URL:      th.apiURL,
+		ClientID: clientID,
+		AuthKey:  authKey,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	t.Run("client credentials", func(t *testing.T) {
+		// Clients can't authorize their own sessions.
+		token, err := c.GetJoinToken(clientID, "callID", "sessionID")
+		require.EqualError(t, err, "request failed: admin access is required")
+		require.Empty(t, token)
+	})
+
+	t.Run("missing group id", func(t *testing.T) {
+		token, err := th.adminClient.GetJoinToken("", "callID", "sessionID")
+		require.EqualError(t, err, "request failed: missing groupID")
+		require.Empty(t, token)
+	})
+
+	t.Run("missing call id", func(t *testing.T) {
+		token, err := th.adminClient.GetJoinToken(clientID, "", "sessionID")
+		require.EqualError(t, err, "request failed: invalid empty call id")
+		require.Empty(t, token)
+	})
+
+	t.Run("join", func(t *testing.T) {
+		err := c.Connect()
+		require.NoError(t, err)
+		defer c.Close()
+
+		msg, ok := <-c.ReceiveCh()
+		require.True(t, ok)
+		require.Equal(t, ClientMessageHello, msg.Type)
+
+		otherToken, err := th.adminClient.GetJoinToken(clientID, "callB", "sessionID")
+		require.NoError(t, err)
+
+		// Joining without a token or with a token issued for a different
+		// call should be rejected.
+		for _, token := range []string{"", otherToken} {
+			err = c.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+				"callID":    "callID",
+				"userID":    "userID",
+				"sessionID": "sessionID",
+				"token":     token,
+			}))
+			require.NoError(t, err)
+		}
+
+		token, err := th.adminClient.GetJoinToken(clientID, "callID", "sessionID")
+		require.NoError(t, err)
+		require.NotEmpty(t, token)
+
+		err = c.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+			"callID":    "callID",
+			"userID":    "userID",
+			"sessionID": "sessionID",
+			"token":     token,
+		}))
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			_, err := th.srvc.rtcServer.GetCallState(clientID, "callID")
+			return err == nil
+		}, 2*time.Second, 10*time.Millisecond)
+
+		state, err := th.srvc.rtcServer.GetCallState(clientID, "callID")
+		require.NoError(t, err)
+		require.Len(t, state.Sessions, 1)
+
+		// The session needs to present its token again to be taken over by
+		// another connection.
+		th.srvc.mut.RLock()
+		connID := th.srvc.connMap["sessionID"]
+		th.srvc.mut.RUnlock()
+		for _, token := range []string{"", otherToken} {
+			data, err := NewPackedClientMessage(ClientMessageReconnect, map[string]string{
+				"sessionID": "sessionID",
+				"token":     token,
+			})
+			require.NoError(t, err)
+			err = th.srvc.handleClientMsg(ws.Message{
+				ConnID:   "otherConnID",
+				ClientID: clientID,
+				Type:     ws.BinaryMessage,
+				Data:     data,
+			})
+			require.Error(t, err)
+			require.Equal(t, ErrorCodeAuthFailed, errorCode(err))
+		}
+		th.srvc.mut.RLock()
+		require.Equal(t, connID, th.srvc.connMap["sessionID"])
+		th.srvc.mut.RUnlock()
+
+		data, err := NewPackedClientMessage(ClientMessageReconnect, map[string]string{
+			"sessionID": "sessionID",
+			"token":     token,
+		})
+		require.NoError(t, err)
+		err = th.srvc.handleClientMsg(ws.Message{
+			ConnID:   connID,
+			ClientID: clientID,
+			Type:     ws.BinaryMessage,
+			Data:     data,
+		})
+		require.NoError(t, err)
+
+		err = c.Send(*NewClientMessage(ClientMessageLeave, map[string]string{
+			"sessionID": "sessionID",
+		}))
+		require.NoError(t, err)
+	})
+}
+
+func TestClientObserver(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	clientID := "clientA"
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = th.adminClient.Register(clientID, authKey)
+	require.NoError(t, err)
+
+	c, err := NewClient(ClientConfig{
+		URL:      th.apiURL,
+		ClientID: clientID,
+		AuthKey:  authKey,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	err = c.Connect()
+	require.NoError(t, err)
+	defer c.Close()
+
+	msg, ok := <-c.ReceiveCh()
+	require.True(t, ok)
+	require.Equal(t, ClientMessageHello, msg.Type)
+
+	err = c.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+		"callID":    "callID",
+		"userID":    "userID",
+		"sessionID": "sessionID",
+	}))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, err := th.srvc.rtcServer.GetCallState(clientID, "callID")
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	observerSessionID := random.NewID()
+	token, err := th.srvc.auth.NewObserverToken(clientID, "callID", observerSessionID, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	joinToken, err := th.srvc.auth.NewJoinToken(clientID, "callID", observerSessionID, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	// Joining as an observer without a token or with a regular join token
+	// should be rejected.
+	for _, token := range []string{"", joinToken} {
+		err = c.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+			"callID":    "callID",
+			"userID":    "supportUserID",
+			"sessionID": observerSessionID,
+			"token":     token,
+			"observer":  "true",
+		}))
+		require.NoError(t, err)
+	}
+
+	err = c.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+		"callID":    "callID",
+		"userID":    "supportUserID",
+		"sessionID": observerSessionID,
+		"token":     token,
+		"observer":  "true",
+	}))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		topology, err := th.srvc.rtcServer.GetCallTopology(clientID, "callID")
+		return err == nil && len(topology.Subscribers) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	topology, err := th.srvc.rtcServer.GetCallTopology(clientID, "callID")
+	require.NoError(t, err)
+	for _, sub := range topology.Subscribers {
+		require.Equal(t, sub.SessionID == observerSessionID, sub.Observer)
+	}
+
+	// The observer stays out of the participants.
+	state, err := th.srvc.rtcServer.GetCallState(clientID, "callID")
+	require.NoError(t, err)
+	require.Len(t, state.Sessions, 1)
+	require.Equal(t, "sessionID", state.Sessions[0].SessionID)
+
+	for _, sessionID := range []string{observerSessionID, "sessionID"} {
+		err = c.Send(*NewClientMessage(ClientMessageLeave, map[string]string{
+			"sessionID": sessionID,
+		}))
+		require.NoError(t, err)
+	}
+}
+
+func TestClientTURNCredentials(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.RTC.ICEServers = rtc.ICEServers{
+		{URLs: []string{"turn:turn.example.com:3478"}},
+	}
+	cfg.RTC.TURNConfig.CredentialsExpirationMinutes = 60
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	clientID := "clientA"
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = th.adminClient.Register(clientID, authKey)
+	require.NoError(t, err)
+
+	c, err := NewClient(ClientConfig{
+		URL:      th.apiURL,
+		ClientID: clientID,
+		AuthKey:  authKey,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	t.Run("disabled", func(t *testing.T) {
+		servers, _, err := c.GetTURNCredentials()
+		require.EqualError(t, err, "request failed: TURN credentials are not enabled")
+		require.Empty(t, servers)
+	})
+
+	th.srvc.rtcServer.SetTURNStaticAuthSecret("secret")
+
+	t.Run("admin client", func(t *testing.T) {
+		servers, _, err := th.adminClient.GetTURNCredentials()
+		require.EqualError(t, err, "request failed: client id should not be empty")
+		require.Empty(t, servers)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		other, err := NewClient(ClientConfig{
+			URL:      th.apiURL,
+			ClientID: clientID,
+			AuthKey:  "invalid",
+		})
+		require.NoError(t, err)
+		_, _, err = other.GetTURNCredentials()
+		require.Error(t, err)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		servers, expiresAt, err := c.GetTURNCredentials()
+		require.NoError(t, err)
+		require.True(t, expiresAt.After(time.Now()))
+		require.Len(t, servers, 1)
+		require.Equal(t, []string{"turn:turn.example.com:3478"}, servers[0].URLs)
+		require.Equal(t, fmt.Sprintf("%d:%s", expiresAt.Unix(), clientID), servers[0].Username)
+		require.NotEmpty(t, servers[0].Credential)
+	})
+}
+
+func TestClientPathPrefix(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	target, err := url.Parse(th.apiURL)
+	require.NoError(t, err)
+	proxy := httptest.NewServer(http.StripPrefix("/rtcd", httputil.NewSingleHostReverseProxy(target)))
+	defer proxy.Close()
+
+	adminClient, err := NewClient(ClientConfig{
+		URL:     proxy.URL + "/rtcd/",
+		AuthKey: th.srvc.cfg.API.Security.AdminSecretKey,
+	})
+	require.NoError(t, err)
+	defer adminClient.Close()
+
+	clientID := "clientA"
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = adminClient.Register(clientID, authKey)
+	require.NoError(t, err)
+
+	c, err := NewClient(ClientConfig{
+		URL:      proxy.URL + "/rtcd",
+		ClientID: clientID,
+		AuthKey:  authKey,
+	})
+	require.NoError(t, err)
+
+	err = c.Connect()
+	require.NoError(t, err)
+
+	_, err = c.GetVersionInfo()
+	require.NoError(t, err)
+
+	err = c.Close()
+	require.NoError(t, err)
 }
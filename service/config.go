@@ -7,11 +7,22 @@ import (
 	"fmt"
 	"github.com/mattermost/rtcd/service/auth"
 	"net/url"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/mattermost/rtcd/logger"
 	"github.com/mattermost/rtcd/service/api"
+	"github.com/mattermost/rtcd/service/autoscaling"
+	"github.com/mattermost/rtcd/service/crash"
+	"github.com/mattermost/rtcd/service/eventbus"
+	"github.com/mattermost/rtcd/service/fips"
+	"github.com/mattermost/rtcd/service/perf"
+	"github.com/mattermost/rtcd/service/rpc"
 	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/store"
+	"github.com/mattermost/rtcd/service/vault"
+	"github.com/mattermost/rtcd/service/webhook"
 )
 
 type SecurityConfig struct {
@@ -20,12 +31,48 @@ type SecurityConfig struct {
 	// The secret key used to authenticate admin requests.
 	AdminSecretKey string `toml:"admin_secret_key"`
 	// Whether or not to allow clients to self-register.
-	AllowSelfRegistration bool                    `toml:"allow_self_registration"`
-	SessionCache          auth.SessionCacheConfig `toml:"session_cache"`
+	AllowSelfRegistration bool `toml:"allow_self_registration"`
+	// Whether or not to allow clients to register themselves by presenting
+	// a one-time bootstrap token, minted offline with the admin secret key.
+	AllowBootstrapTokens bool                    `toml:"allow_bootstrap_tokens"`
+	SessionCache         auth.SessionCacheConfig `toml:"session_cache"`
+	// Configuration of the per-call tokens required for sessions to join.
+	JoinTokens auth.JoinTokenConfig `toml:"join_tokens"`
+	// Configuration of the replay-protected credentials clients can
+	// authenticate the signaling connection with.
+	SignedAuth auth.SignedAuthConfig `toml:"signed_auth"`
+	// Configuration of the lockout of the clients and addresses failing to
+	// authenticate.
+	AuthLockout AuthLockoutConfig `toml:"auth_lockout"`
+	// Configuration of the provider validating the registering clients.
+	RegistrationAuth auth.RegistrationAuthConfig `toml:"registration_auth"`
 }
 
 func (c SecurityConfig) IsValid() error {
-	if !c.EnableAdmin {
+	if err := c.JoinTokens.IsValid(); err != nil {
+		return fmt.Errorf("invalid JoinTokens config: %w", err)
+	}
+	// Join tokens are issued with admin credentials only.
+	if c.JoinTokens.Enable && !c.EnableAdmin {
+		return fmt.Errorf("invalid JoinTokens config: admin access should be enabled to issue the tokens")
+	}
+
+	if err := c.SignedAuth.IsValid(); err != nil {
+		return fmt.Errorf("invalid SignedAuth config: %w", err)
+	}
+
+	if err := c.AuthLockout.IsValid(); err != nil {
+		return fmt.Errorf("invalid AuthLockout config: %w", err)
+	}
+
+	if err := c.RegistrationAuth.IsValid(); err != nil {
+		return fmt.Errorf("invalid RegistrationAuth config: %w", err)
+	}
+	if c.AllowSelfRegistration && c.RegistrationAuth.Provider != "" && c.RegistrationAuth.Provider != auth.ProviderStatic {
+		return fmt.Errorf("invalid RegistrationAuth config: provider %q can't be used along with self registration", c.RegistrationAuth.Provider)
+	}
+
+	if !c.EnableAdmin && !c.AllowBootstrapTokens {
 		return nil
 	}
 
@@ -36,16 +83,293 @@ func (c SecurityConfig) IsValid() error {
 	return nil
 }
 
+// AuthLockoutConfig holds the settings throttling the failed authentication
+// attempts, tracked per client ID and source IP address, and per source IP
+// address.
+type AuthLockoutConfig struct {
+	// The number of consecutive failed attempts after which further
+	// attempts are rejected. Zero disables the lockout.
+	MaxFailedAttempts int `toml:"max_failed_attempts"`
+	// The duration, in seconds, of the first lockout. It doubles with every
+	// further failed attempt.
+	BaseDurationSeconds int `toml:"base_duration_seconds"`
+	// The maximum duration, in seconds, of a lockout. Failed attempts are
+	// forgotten after this long without failures.
+	MaxDurationSeconds int `toml:"max_duration_seconds"`
+}
+
+func (c AuthLockoutConfig) IsValid() error {
+	if c.MaxFailedAttempts < 0 {
+		return fmt.Errorf("invalid MaxFailedAttempts value: should not be negative")
+	}
+	if c.MaxFailedAttempts == 0 {
+		return nil
+	}
+	if c.BaseDurationSeconds <= 0 {
+		return fmt.Errorf("invalid BaseDurationSeconds value: should be a positive number")
+	}
+	if c.MaxDurationSeconds < c.BaseDurationSeconds {
+		return fmt.Errorf("invalid MaxDurationSeconds value: should not be lower than BaseDurationSeconds")
+	}
+	return nil
+}
+
+// OutboundConfig holds the settings of the outbound-only mode, in which the
+// service dials the signaling connection instead of accepting it.
+type OutboundConfig struct {
+	// Whether or not the service should establish the signaling connection
+	// to URL.
+	Enable bool `toml:"enable"`
+	// The WebSocket URL to connect to. Should start with either `ws://` or
+	// `wss://`.
+	URL string `toml:"url"`
+	// The ID of the client the connection acts on behalf of.
+	ClientID string `toml:"client_id"`
+	// The key sent, along with ClientID, to authenticate against the
+	// remote end.
+	AuthKey string `toml:"auth_key"`
+	// The base interval, in seconds, between reconnection attempts.
+	ReconnectIntervalSeconds int `toml:"reconnect_interval_seconds"`
+}
+
+func (c OutboundConfig) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+
+	if c.URL == "" {
+		return fmt.Errorf("invalid URL value: should not be empty")
+	}
+
+	if !strings.HasPrefix(c.URL, "ws://") && !strings.HasPrefix(c.URL, "wss://") {
+		return fmt.Errorf(`invalid URL value: should start with "ws://" or "wss://"`)
+	}
+
+	if c.ClientID == "" {
+		return fmt.Errorf("invalid ClientID value: should not be empty")
+	}
+
+	if c.ReconnectIntervalSeconds <= 0 {
+		return fmt.Errorf("invalid ReconnectIntervalSeconds value: should be a positive number")
+	}
+
+	return nil
+}
+
+// ChaosConfig holds the settings of the faults injected into the signaling
+// connections to test the reconnection logic of clients. Never meant to be
+// enabled in production.
+type ChaosConfig struct {
+	// Whether or not faults should be injected.
+	Enable bool `toml:"enable"`
+	// The percentage of the WebSocket messages, sent or received, after which
+	// the connection gets dropped as a network failure would.
+	WSDisconnectPercent int `toml:"ws_disconnect_percent"`
+}
+
+func (c ChaosConfig) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.WSDisconnectPercent < 0 || c.WSDisconnectPercent > 100 {
+		return fmt.Errorf("invalid WSDisconnectPercent value: should be in the range [0, 100]")
+	}
+	return nil
+}
+
+// SignalingTraceConfig holds the settings of the signaling traces, recording
+// the signaling messages of calls for debugging.
+type SignalingTraceConfig struct {
+	// The path to the directory traces are written to. Traces are disabled
+	// if empty.
+	Dir string `toml:"dir"`
+	// The size, in megabytes, after which a trace gets stopped.
+	MaxSizeMB int `toml:"max_size_mb"`
+	// The maximum duration, in seconds, of a trace.
+	MaxDurationSeconds int `toml:"max_duration_seconds"`
+}
+
+func (c SignalingTraceConfig) IsValid() error {
+	if c.Dir == "" {
+		return nil
+	}
+	if c.MaxSizeMB <= 0 {
+		return fmt.Errorf("invalid MaxSizeMB value: should be a positive number")
+	}
+	if c.MaxDurationSeconds <= 0 {
+		return fmt.Errorf("invalid MaxDurationSeconds value: should be a positive number")
+	}
+	return nil
+}
+
 type APIConfig struct {
-	HTTP     api.Config     `toml:"http"`
+	HTTP api.Config `toml:"http"`
+	// Admin configures a separate HTTP server for the admin and debug
+	// endpoints. If its ListenAddress is empty they are served by the public
+	// HTTP server.
+	Admin    api.Config     `toml:"admin"`
+	GRPC     rpc.Config     `toml:"grpc"`
 	Security SecurityConfig `toml:"security"`
+	Outbound OutboundConfig `toml:"outbound"`
+	Chaos    ChaosConfig    `toml:"chaos"`
+	// SignalingTrace configures the recording of the signaling messages of
+	// calls, triggered through the admin API.
+	SignalingTrace SignalingTraceConfig `toml:"signaling_trace"`
+	// Metrics configures a separate HTTP server for the metrics endpoint. If
+	// its ListenAddress is empty it's served by the public HTTP server.
+	Metrics api.Config `toml:"metrics"`
+	// The time, in seconds, the sessions of a lost signaling connection are
+	// kept for their client to resume them by reconnecting. Past it they
+	// get closed, ending the calls left without participants. Zero keeps
+	// them until they time out on their own.
+	StaleSessionTimeoutSeconds int `toml:"stale_session_timeout_seconds"`
+}
+
+// ProcessConfig holds the settings applied to the process itself.
+type ProcessConfig struct {
+	// The number of open file descriptors (RLIMIT_NOFILE) the limit is raised
+	// to at startup. Raising the hard limit requires privileges, otherwise
+	// the limit is raised up to it. Zero leaves the limit untouched.
+	OpenFilesLimit int `toml:"open_files_limit"`
+	// The percentage of the open files limit above which a warning is
+	// logged. Zero disables the warning.
+	OpenFilesWarnPercent int `toml:"open_files_warn_percent"`
+	// Crash configures the diagnostic bundles written when recovering from
+	// panics.
+	Crash crash.Config `toml:"crash"`
+	// The time, in seconds, given to the ongoing sessions to end once
+	// clients are notified of a shutdown. The sessions still ongoing past it
+	// get force-closed.
+	ShutdownTimeoutSeconds int `toml:"shutdown_timeout_seconds"`
+}
+
+func (c ProcessConfig) IsValid() error {
+	if c.OpenFilesLimit < 0 {
+		return fmt.Errorf("invalid OpenFilesLimit value: should not be negative")
+	}
+	if c.OpenFilesWarnPercent < 0 || c.OpenFilesWarnPercent > 100 {
+		return fmt.Errorf("invalid OpenFilesWarnPercent value: should be in the range [0, 100]")
+	}
+	if c.ShutdownTimeoutSeconds < 0 {
+		return fmt.Errorf("invalid ShutdownTimeoutSeconds value: should not be negative")
+	}
+	if err := c.Crash.IsValid(); err != nil {
+		return fmt.Errorf("invalid Crash config: %w", err)
+	}
+	return nil
+}
+
+// BotsConfig holds the settings of the in-process bots spawned in calls
+// through the admin API.
+type BotsConfig struct {
+	// A boolean controlling whether bots can be spawned.
+	Enable bool `toml:"enable"`
+	// The maximum number of bots running at the same time on this node.
+	MaxCount int `toml:"max_count"`
+	// The time, in minutes, after which a bot gets stopped regardless of the
+	// requested duration. Zero means no limit.
+	MaxDurationMinutes int `toml:"max_duration_minutes"`
+	// The path to the directory holding the Ogg/Opus files announcement
+	// bots can play.
+	AnnouncementsDir string `toml:"announcements_dir"`
+}
+
+func (c BotsConfig) IsValid() error {
+	if c.MaxCount < 0 {
+		return fmt.Errorf("invalid MaxCount value: should not be negative")
+	}
+	if c.Enable && c.MaxCount == 0 {
+		return fmt.Errorf("invalid MaxCount value: should be positive")
+	}
+	if c.MaxDurationMinutes < 0 {
+		return fmt.Errorf("invalid MaxDurationMinutes value: should not be negative")
+	}
+	return nil
+}
+
+// MirroringConfig holds the settings of the mirroring of calls to other rtcd
+// instances.
+type MirroringConfig struct {
+	// A boolean controlling whether calls can be mirrored.
+	Enable bool `toml:"enable"`
+	// The maximum number of mirrors running at the same time on this node.
+	MaxCount int `toml:"max_count"`
+	// The time, in seconds, waited before re-establishing a failed link to
+	// the target instance. It doubles on every consecutive failure, up to a
+	// minute.
+	RetryIntervalSeconds int `toml:"retry_interval_seconds"`
+}
+
+func (c MirroringConfig) IsValid() error {
+	if c.MaxCount < 0 {
+		return fmt.Errorf("invalid MaxCount value: should not be negative")
+	}
+	if c.Enable && c.MaxCount == 0 {
+		return fmt.Errorf("invalid MaxCount value: should be positive")
+	}
+	if c.Enable && c.RetryIntervalSeconds <= 0 {
+		return fmt.Errorf("invalid RetryIntervalSeconds value: should be positive")
+	}
+	return nil
+}
+
+// P2PConfig holds the settings of the 1:1 calls negotiated peer-to-peer.
+type P2PConfig struct {
+	// A boolean controlling whether 1:1 calls can be negotiated
+	// peer-to-peer, with the service only relaying the signaling, until a
+	// third participant joins.
+	Enable bool `toml:"enable"`
+}
+
+// FeaturesConfig holds the state of the features gated behind flags for all
+// the clients, in place of their own settings. Flags can be further
+// overridden, for the deployment or per client, through the admin API.
+type FeaturesConfig struct {
+	// The features enabled for all the clients.
+	Enabled []string `toml:"enabled"`
+	// The features disabled for all the clients.
+	Disabled []string `toml:"disabled"`
+}
+
+func (c FeaturesConfig) IsValid() error {
+	enabled := map[string]bool{}
+	for _, name := range c.Enabled {
+		if !isFeature(name) {
+			return fmt.Errorf("invalid Enabled value: unknown feature %q", name)
+		}
+		enabled[name] = true
+	}
+	for _, name := range c.Disabled {
+		if !isFeature(name) {
+			return fmt.Errorf("invalid Disabled value: unknown feature %q", name)
+		}
+		if enabled[name] {
+			return fmt.Errorf("invalid Disabled value: feature %q is also enabled", name)
+		}
+	}
+	return nil
 }
 
 type Config struct {
-	API    APIConfig
-	RTC    rtc.ServerConfig
-	Store  StoreConfig
-	Logger logger.Config
+	// The deployment profile ("small", "large" or "broadcast") tuning the
+	// defaults of the load dependent settings. Explicit settings take
+	// precedence over the profile.
+	Profile     string `toml:"profile"`
+	API         APIConfig
+	RTC         rtc.ServerConfig
+	Store       StoreConfig
+	Logger      logger.Config
+	Webhooks    webhook.Config
+	Autoscaling autoscaling.Config
+	EventBus    eventbus.Config
+	Vault       vault.Config
+	Metrics     perf.Config
+	Process     ProcessConfig
+	FIPS        fips.Config
+	Bots        BotsConfig
+	Mirroring   MirroringConfig
+	Features    FeaturesConfig
+	P2P         P2PConfig
 }
 
 func (c APIConfig) IsValid() error {
@@ -57,10 +381,46 @@ func (c APIConfig) IsValid() error {
 		return fmt.Errorf("failed to validate http config: %w", err)
 	}
 
+	if c.Admin.ListenAddress != "" {
+		if err := c.Admin.IsValid(); err != nil {
+			return fmt.Errorf("failed to validate admin config: %w", err)
+		}
+	}
+
+	if c.Metrics.ListenAddress != "" {
+		if err := c.Metrics.IsValid(); err != nil {
+			return fmt.Errorf("failed to validate metrics config: %w", err)
+		}
+	}
+
+	if err := c.GRPC.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate grpc config: %w", err)
+	}
+
+	if err := c.Outbound.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate outbound config: %w", err)
+	}
+
+	if err := c.Chaos.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate chaos config: %w", err)
+	}
+
+	if err := c.SignalingTrace.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate signaling trace config: %w", err)
+	}
+
+	if c.StaleSessionTimeoutSeconds < 0 {
+		return fmt.Errorf("invalid StaleSessionTimeoutSeconds value: should not be negative")
+	}
+
 	return nil
 }
 
 func (c Config) IsValid() error {
+	if _, ok := profiles[c.Profile]; c.Profile != "" && !ok {
+		return fmt.Errorf("invalid Profile value: %q is not one of %v", c.Profile, Profiles())
+	}
+
 	if err := c.API.IsValid(); err != nil {
 		return err
 	}
@@ -77,15 +437,133 @@ func (c Config) IsValid() error {
 		return err
 	}
 
+	if err := c.Webhooks.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate webhooks config: %w", err)
+	}
+
+	if err := c.Autoscaling.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate autoscaling config: %w", err)
+	}
+
+	if err := c.EventBus.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate event bus config: %w", err)
+	}
+
+	if err := c.Vault.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate vault config: %w", err)
+	}
+
+	if err := c.Metrics.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate metrics config: %w", err)
+	}
+
+	if err := c.Process.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate process config: %w", err)
+	}
+
+	if err := c.FIPS.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate fips config: %w", err)
+	}
+
+	if err := c.Bots.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate bots config: %w", err)
+	}
+
+	if err := c.Mirroring.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate mirroring config: %w", err)
+	}
+
+	if err := c.Features.IsValid(); err != nil {
+		return fmt.Errorf("failed to validate features config: %w", err)
+	}
+	for _, name := range c.Features.Enabled {
+		if err := checkFeatureRequirements(c, name); err != nil {
+			return fmt.Errorf("failed to validate features config: %w", err)
+		}
+	}
+
+	if c.API.Security.SignedAuth.Enable && c.Store.EncryptionKey == "" {
+		return fmt.Errorf("invalid SignedAuth config: the store should be encrypted, the signing keys being secrets")
+	}
+
+	if c.P2P.Enable && c.RTC.RelayOnly {
+		return fmt.Errorf("failed to validate p2p config: peer-to-peer calls can't be enabled in relay only mode")
+	}
+
+	if c.FIPS.Enable {
+		for _, profile := range c.RTC.SRTPProtectionProfiles {
+			if profile != rtc.SRTPProfileAEADAES128GCM {
+				return fmt.Errorf("invalid SRTPProtectionProfiles value: only %q is allowed in FIPS mode", rtc.SRTPProfileAEADAES128GCM)
+			}
+		}
+	}
+
 	return nil
 }
 
 func (c *Config) SetDefaults() {
 	c.API.HTTP.ListenAddress = ":8045"
+	c.API.GRPC.ListenAddress = ":8046"
 	c.API.Security.SessionCache.ExpirationMinutes = 1440
+	c.API.Security.JoinTokens.ExpirationMinutes = 5
+	c.API.Security.SignedAuth.MaxClockSkewSeconds = 300
+	c.API.Security.AuthLockout.MaxFailedAttempts = 10
+	c.API.Security.AuthLockout.BaseDurationSeconds = 30
+	c.API.Security.AuthLockout.MaxDurationSeconds = 3600
+	c.API.Security.RegistrationAuth.Provider = auth.ProviderStatic
+	c.API.Security.RegistrationAuth.TimeoutSeconds = 10
+	c.API.Security.RegistrationAuth.OIDC.ClientIDClaim = "sub"
+	c.API.Outbound.ReconnectIntervalSeconds = 2
+	c.API.SignalingTrace.MaxSizeMB = 10
+	c.API.SignalingTrace.MaxDurationSeconds = 3600
+	c.API.StaleSessionTimeoutSeconds = 60
 	c.RTC.ICEPortUDP = 8443
 	c.RTC.TURNConfig.CredentialsExpirationMinutes = 1440
+	c.RTC.UDPSockets.MinCount = 1
+	c.RTC.UDPSockets.PacketRatePerSocket = 50000
+	c.RTC.UDPSockets.ReadBufferSize = 1024 * 1024 * 16
+	c.RTC.UDPSockets.WriteBufferSize = 1024 * 1024 * 16
+	c.RTC.UDPSockets.WriteMode = rtc.UDPWriteModeRoundRobin
+	c.RTC.UDPSockets.StallTimeoutSeconds = 30
+	c.RTC.UDPSockets.SendQueueSize = 512
+	c.RTC.IdleCallTimeoutMinutes = 10
+	c.RTC.TrackInactivityTimeoutMs = 5000
+	c.RTC.ReceiverReportAggregation = rtc.ReceiverReportAggregationNone
+	c.RTC.RTX.PayloadType = 97
+	c.RTC.Capture.MaxSizeMB = 100
+	c.RTC.Capture.MaxDurationSeconds = 300
+	c.RTC.Recording.MaxDurationSeconds = 14400
+	c.RTC.Recording.PostProcessTimeoutSeconds = 300
+	c.RTC.HLS.PartDurationMs = 500
+	c.RTC.HLS.SegmentDurationMs = 2000
+	c.RTC.HLS.PlaylistSegments = 6
+	c.RTC.Announcements.DefaultLocale = "en"
+	c.RTC.Announcements.MaxQueueSize = 8
+	c.RTC.KeyExport.TimeoutSeconds = 10
+	c.RTC.PublicIPDiscovery.RecheckIntervalSeconds = 300
+	c.RTC.PublicIPDiscovery.TimeoutSeconds = 5
+	c.RTC.ConnectivityCheck.IntervalSeconds = 60
+	c.RTC.ConnectivityCheck.TimeoutSeconds = 5
+	c.RTC.DTLSCertificate.Persist = true
+	c.RTC.DTLSCertificate.RotationDays = 30
+	c.RTC.ICETimeouts.DisconnectedTimeoutMs = 5000
+	c.RTC.ICETimeouts.FailedTimeoutMs = 25000
+	c.RTC.ICETimeouts.KeepaliveIntervalMs = 2000
+	c.RTC.MDNSCandidates.Mode = rtc.MDNSCandidatesModeIgnore
+	c.RTC.MDNSCandidates.ResolveTimeoutMs = 1000
+	c.RTC.ICECandidates.DropLinkLocal = true
+	c.RTC.EnableSessionMigration = true
+	c.RTC.KeyFrameCache.Enable = true
+	c.RTC.KeyFrameCache.MaxSizeKB = 512
+	c.RTC.EgressShaping.RateKbps = 50000
+	c.RTC.EgressShaping.BurstKB = 1024
+	c.RTC.Capacity.MaxCPUPercent = 80
 	c.Store.DataSource = "/tmp/rtcd_db"
+	c.Store.UsagePersistIntervalSeconds = 60
+	c.Store.IdempotencyKeyTTLMinutes = 60
+	c.Store.CallEventsMax = 1000
+	c.Store.CallEventsTTLHours = 168
+	c.Store.CompactionIntervalMinutes = 60
 	c.Logger.EnableConsole = true
 	c.Logger.ConsoleJSON = false
 	c.Logger.ConsoleLevel = "INFO"
@@ -94,27 +572,163 @@ func (c *Config) SetDefaults() {
 	c.Logger.FileLocation = "rtcd.log"
 	c.Logger.FileLevel = "DEBUG"
 	c.Logger.EnableColor = false
+	c.Logger.EnableAudit = false
+	c.Logger.AuditFileLocation = "rtcd_audit.log"
+	c.Webhooks.MaxRetries = 3
+	c.Webhooks.TimeoutSeconds = 10
+	c.Autoscaling.Subject = "rtcd.autoscaling"
+	c.Autoscaling.IntervalSeconds = 10
+	c.Autoscaling.TimeoutSeconds = 5
+	c.EventBus.Topic = "rtcd.events"
+	c.EventBus.Types = []string{
+		string(rtc.CallStartedEvent),
+		string(rtc.CallEndedEvent),
+		string(rtc.SessionJoinedEvent),
+		string(rtc.SessionLeftEvent),
+	}
+	c.EventBus.TimeoutSeconds = 5
+	c.Vault.AuthMethod = vault.AuthMethodToken
+	c.Vault.KubernetesMountPath = "kubernetes"
+	c.Vault.KubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	c.Vault.SecretPath = "secret/data/rtcd"
+	c.Vault.RefreshIntervalMinutes = 60
+	c.Metrics.CallMetricsMaxCalls = 50
+	c.Metrics.Watchdog.IntervalSeconds = 30
+	c.Metrics.StatsD.Address = "localhost:8125"
+	c.Metrics.StatsD.FlushIntervalMs = 1000
+	c.Process.OpenFilesLimit = 65536
+	c.Process.OpenFilesWarnPercent = 80
+	c.Process.Crash.DumpDir = "rtcd_crash"
+	c.Process.Crash.MaxDumps = 10
+	c.Process.Crash.LogLines = 1000
+	c.Process.ShutdownTimeoutSeconds = 30
+	c.Bots.MaxCount = 10
+	c.Bots.MaxDurationMinutes = 60
+	c.Mirroring.MaxCount = 10
+	c.Mirroring.RetryIntervalSeconds = 2
 }
 
 type StoreConfig struct {
 	DataSource string `toml:"data_source"`
+	// A base64 encoded 32 bytes key used to encrypt the values persisted in
+	// the store. The existing values get encrypted the first time it's set.
+	// Encryption is disabled if empty.
+	EncryptionKey string `toml:"encryption_key"`
+	// The interval, in seconds, at which the bandwidth usage totals of each
+	// client are persisted. Zero disables persistence.
+	UsagePersistIntervalSeconds int `toml:"usage_persist_interval_seconds"`
+	// The time, in minutes, the results of the requests made with an
+	// Idempotency-Key header are kept for. Zero disables idempotency keys.
+	IdempotencyKeyTTLMinutes int `toml:"idempotency_key_ttl_minutes"`
+	// The maximum number of events (joins, leaves, mutes, ICE restarts,
+	// quality changes, etc.) persisted per call, the oldest ones being
+	// dropped past it. Zero disables the call event timelines.
+	CallEventsMax int `toml:"call_events_max"`
+	// The time, in hours, the persisted call events are kept for.
+	CallEventsTTLHours int `toml:"call_events_ttl_hours"`
+	// The interval, in minutes, at which the store is compacted, reclaiming
+	// the space taken by overwritten and deleted entries. Zero disables
+	// periodic compaction.
+	CompactionIntervalMinutes int `toml:"compaction_interval_minutes"`
+	// The size, in megabytes, past which writes to the store are rejected,
+	// after compacting it if that can make room. Zero means unlimited.
+	MaxSizeMB int `toml:"max_size_mb"`
 }
 
 func (c StoreConfig) IsValid() error {
 	if c.DataSource == "" {
 		return fmt.Errorf("invalid DataSource value: should not be empty")
 	}
+	if c.EncryptionKey != "" {
+		if _, err := store.ParseMasterKey(c.EncryptionKey); err != nil {
+			return fmt.Errorf("invalid EncryptionKey value: %w", err)
+		}
+	}
+	if c.UsagePersistIntervalSeconds < 0 {
+		return fmt.Errorf("invalid UsagePersistIntervalSeconds value: should not be negative")
+	}
+	if c.IdempotencyKeyTTLMinutes < 0 {
+		return fmt.Errorf("invalid IdempotencyKeyTTLMinutes value: should not be negative")
+	}
+	if c.CallEventsMax < 0 {
+		return fmt.Errorf("invalid CallEventsMax value: should not be negative")
+	}
+	if c.CallEventsMax > 0 && c.CallEventsTTLHours <= 0 {
+		return fmt.Errorf("invalid CallEventsTTLHours value: should be positive")
+	}
+	if c.CompactionIntervalMinutes < 0 {
+		return fmt.Errorf("invalid CompactionIntervalMinutes value: should not be negative")
+	}
+	if c.MaxSizeMB < 0 {
+		return fmt.Errorf("invalid MaxSizeMB value: should not be negative")
+	}
 	return nil
 }
 
+// OpenStore opens the store configured by cfg, enabling encryption at rest
+// if an encryption key is set.
+func OpenStore(cfg StoreConfig) (store.Store, error) {
+	st, err := store.New(cfg.DataSource, store.WithMaxSize(int64(cfg.MaxSizeMB)*1024*1024))
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.EncryptionKey == "" {
+		return st, nil
+	}
+
+	key, err := store.ParseMasterKey(cfg.EncryptionKey)
+	if err == nil {
+		var encSt store.Store
+		if encSt, err = store.NewEncrypted(st, key); err == nil {
+			return encSt, nil
+		}
+	}
+
+	if closeErr := st.Close(); closeErr != nil {
+		return nil, fmt.Errorf("failed to close store: %w", closeErr)
+	}
+
+	return nil, fmt.Errorf("failed to enable encryption: %w", err)
+}
+
 type ClientConfig struct {
-	httpURL string
+	// httpURL is the base URL of the API endpoints.
+	httpURL url.URL
 	wsURL   string
 
 	ClientID          string
 	AuthKey           string
 	URL               string
 	ReconnectInterval time.Duration
+	// Capabilities lists the signaling features supported by the client.
+	// Defaults to all the features supported by the server if nil.
+	Capabilities []string
+	// SignedAuth makes the client authenticate the signaling connection and
+	// its groups with replay-protected signed credentials instead of the
+	// bare auth keys.
+	SignedAuth bool
+	// DialAttemptTimeout bounds each attempt at connecting to one of the
+	// addresses the service host resolves to. Defaults to 3 seconds.
+	DialAttemptTimeout time.Duration
+	// DialAttemptDelay is how long to wait for an attempt to succeed before
+	// racing it against one to the next address. Defaults to 250ms.
+	DialAttemptDelay time.Duration
+}
+
+// joinURLPath returns u with elem appended to both its path and, if set, its
+// escaped form so that escaped separators (e.g. %2F) are kept as is.
+func joinURLPath(u url.URL, elem string) string {
+	u.Path = path.Join("/", u.Path, elem)
+	if u.RawPath != "" {
+		u.RawPath = path.Join("/", u.RawPath, elem)
+	}
+	return u.String()
+}
+
+// getHTTPURL returns the URL of the given API endpoint (e.g. "register").
+func (c ClientConfig) getHTTPURL(endpoint string) string {
+	return joinURLPath(c.httpURL, endpoint)
 }
 
 func (c *ClientConfig) Parse() error {
@@ -131,24 +745,35 @@ func (c *ClientConfig) Parse() error {
 		return fmt.Errorf("invalid url host: should not be empty")
 	}
 
+	// Preserving any path prefix (e.g. when running behind a reverse proxy),
+	// as escaped, and query (e.g. a token expected by the proxy).
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = strings.TrimSuffix(u.RawPath, "/")
+	u.Fragment = ""
+
 	switch u.Scheme {
 	case "http":
-		c.httpURL = c.URL
+		c.httpURL = *u
 		u.Scheme = "ws"
-		u.Path = "/ws"
-		c.wsURL = u.String()
 	case "https":
-		c.httpURL = c.URL
+		c.httpURL = *u
 		u.Scheme = "wss"
-		u.Path = "/ws"
-		c.wsURL = u.String()
 	default:
 		return fmt.Errorf("invalid url scheme: %q is not valid", u.Scheme)
 	}
+	c.wsURL = joinURLPath(*u, "ws")
 
 	if c.ReconnectInterval <= 0 {
 		c.ReconnectInterval = defaultReconnectInterval
 	}
 
+	if c.DialAttemptTimeout <= 0 {
+		c.DialAttemptTimeout = defaultDialAttemptTimeout
+	}
+
+	if c.DialAttemptDelay <= 0 {
+		c.DialAttemptDelay = defaultDialAttemptDelay
+	}
+
 	return nil
 }
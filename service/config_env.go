@@ -0,0 +1,125 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+// ConfigEnvVar describes an environment variable overriding a config field.
+type ConfigEnvVar struct {
+	// Key is the name of the variable derived from the Go names of the
+	// field and its parents (e.g. RTCD_API_HTTP_LISTENADDRESS).
+	Key string
+	// NestedKey is the name of the variable derived from the TOML path of
+	// the field, levels being separated by double underscores (e.g.
+	// RTCD_API__HTTP__LISTEN_ADDRESS).
+	NestedKey string
+	// Path is the dotted TOML path of the field.
+	Path string
+	// Type describes the format of the value.
+	Type string
+	// Default is the default value of the field.
+	Default string
+
+	index []int
+	typ   reflect.Type
+}
+
+// ConfigEnvVars returns the environment variables overriding the config
+// fields with the given prefix, in the order of the fields.
+func ConfigEnvVars(prefix string) []ConfigEnvVar {
+	var cfg Config
+	cfg.SetDefaults()
+	defaults := FlattenConfig(cfg)
+
+	prefix = strings.ToUpper(prefix)
+	var vars []ConfigEnvVar
+	collectConfigEnvVars(reflect.TypeOf(cfg), nil, prefix, prefix, "", &vars)
+	for i := range vars {
+		vars[i].Default = defaults[vars[i].Path]
+	}
+	return vars
+}
+
+func collectConfigEnvVars(t reflect.Type, index []int, key, nestedKey, path string, vars *[]ConfigEnvVar) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldIndex := append(append([]int(nil), index...), i)
+		name := tomlFieldName(field)
+		fieldKey := key + "_" + strings.ToUpper(field.Name)
+		fieldNestedKey := nestedKey + "_" + strings.ToUpper(name)
+		fieldPath := name
+		if path != "" {
+			fieldNestedKey = nestedKey + "__" + strings.ToUpper(name)
+			fieldPath = path + "." + name
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			collectConfigEnvVars(field.Type, fieldIndex, fieldKey, fieldNestedKey, fieldPath, vars)
+			continue
+		}
+
+		*vars = append(*vars, ConfigEnvVar{
+			Key:       fieldKey,
+			NestedKey: fieldNestedKey,
+			Path:      fieldPath,
+			Type:      envTypeDescription(field.Type),
+			index:     fieldIndex,
+			typ:       field.Type,
+		})
+	}
+}
+
+// newEnvSpec returns a pointer to a struct holding a single value of the
+// given type, read by envconfig from the given variable.
+func newEnvSpec(t reflect.Type, key string) reflect.Value {
+	return reflect.New(reflect.StructOf([]reflect.StructField{{
+		Name: "Value",
+		Type: t,
+		Tag:  reflect.StructTag(fmt.Sprintf("envconfig:%q", key)),
+	}}))
+}
+
+// envTypeDescription returns the description envconfig gives of the
+// format of the values of the given type.
+func envTypeDescription(t reflect.Type) string {
+	var buf bytes.Buffer
+	if err := envconfig.Usagef("", newEnvSpec(t, "VALUE").Interface(), &buf, "{{range .}}{{usage_type .}}{{end}}"); err != nil {
+		return t.String()
+	}
+	return buf.String()
+}
+
+// ProcessNestedEnv overrides the fields of cfg with the set environment
+// variables named after their TOML path (e.g.
+// RTCD_API__HTTP__LISTEN_ADDRESS), values being parsed as envconfig does.
+func ProcessNestedEnv(prefix string, cfg *Config) error {
+	v := reflect.ValueOf(cfg).Elem()
+	for _, envVar := range ConfigEnvVars(prefix) {
+		if envVar.NestedKey == envVar.Key {
+			// Already processed by envconfig.
+			continue
+		}
+		if _, ok := os.LookupEnv(envVar.NestedKey); !ok {
+			continue
+		}
+		spec := newEnvSpec(envVar.typ, envVar.NestedKey)
+		if err := envconfig.Process("", spec.Interface()); err != nil {
+			return err
+		}
+		v.FieldByIndex(envVar.index).Set(spec.Elem().Field(0))
+	}
+	return nil
+}
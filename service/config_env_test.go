@@ -0,0 +1,64 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigEnvVars(t *testing.T) {
+	vars := ConfigEnvVars("rtcd")
+
+	// The keys match the ones envconfig processes.
+	var buf bytes.Buffer
+	err := envconfig.Usagef("rtcd", &Config{}, &buf, "{{range .}}{{usage_key .}}\t{{usage_type .}}\n{{end}}")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, vars, len(lines))
+	for i, line := range lines {
+		key, typ, _ := strings.Cut(line, "\t")
+		require.Equal(t, key, vars[i].Key)
+		require.Equal(t, typ, vars[i].Type)
+	}
+
+	byPath := map[string]ConfigEnvVar{}
+	for _, envVar := range vars {
+		byPath[envVar.Path] = envVar
+	}
+	require.Equal(t, "RTCD_PROFILE", byPath["profile"].NestedKey)
+	require.Equal(t, "RTCD_API__HTTP__LISTEN_ADDRESS", byPath["api.http.listen_address"].NestedKey)
+	require.Equal(t, ":8045", byPath["api.http.listen_address"].Default)
+	require.Equal(t, "True or False", byPath["api.http.tls.enable"].Type)
+
+	envVar := ConfigEnvVars("custom")[1]
+	require.Equal(t, "CUSTOM_API_HTTP_LISTENADDRESS", envVar.Key)
+	require.Equal(t, "CUSTOM_API__HTTP__LISTEN_ADDRESS", envVar.NestedKey)
+}
+
+func TestProcessNestedEnv(t *testing.T) {
+	var cfg Config
+	cfg.SetDefaults()
+
+	os.Setenv("RTCD_API__HTTP__LISTEN_ADDRESS", ":8080")
+	defer os.Unsetenv("RTCD_API__HTTP__LISTEN_ADDRESS")
+	os.Setenv("RTCD_API__HTTP__TLS__ACME__DOMAINS", "a.example.com,b.example.com")
+	defer os.Unsetenv("RTCD_API__HTTP__TLS__ACME__DOMAINS")
+	os.Setenv("RTCD_RTC__ICE_PORT_UDP", "9443")
+	defer os.Unsetenv("RTCD_RTC__ICE_PORT_UDP")
+
+	require.NoError(t, ProcessNestedEnv("rtcd", &cfg))
+	require.Equal(t, ":8080", cfg.API.HTTP.ListenAddress)
+	require.Equal(t, []string{"a.example.com", "b.example.com"}, cfg.API.HTTP.TLS.ACME.Domains)
+	require.Equal(t, 9443, cfg.RTC.ICEPortUDP)
+	require.Equal(t, ":8046", cfg.API.GRPC.ListenAddress)
+
+	os.Setenv("RTCD_RTC__ICE_PORT_UDP", "invalid")
+	require.Error(t, ProcessNestedEnv("rtcd", &cfg))
+}
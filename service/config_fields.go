@@ -0,0 +1,75 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// sensitiveConfigFields lists the (partial) field names whose values should
+// never be logged.
+var sensitiveConfigFields = []string{"secret", "token", "password", "credential", "key", "ice_servers"}
+
+// FlattenConfig returns the string representation of all the leaf fields
+// in cfg, keyed by their dotted TOML path.
+func FlattenConfig(cfg Config) map[string]string {
+	fields := map[string]string{}
+	flattenValue(reflect.ValueOf(cfg), "", fields)
+	return fields
+}
+
+func flattenValue(v reflect.Value, prefix string, fields map[string]string) {
+	if v.Kind() != reflect.Struct {
+		fields[prefix] = fmt.Sprintf("%v", v.Interface())
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := tomlFieldName(field)
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		flattenValue(v.Field(i), name, fields)
+	}
+}
+
+// tomlFieldName returns the name of the given field in the TOML config.
+func tomlFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("toml"), ",")[0]
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// IsSensitiveConfigField returns whether the value of the given config
+// field, identified by its dotted TOML path, should never be logged.
+func IsSensitiveConfigField(field string) bool {
+	name := field[strings.LastIndex(field, ".")+1:]
+	for _, s := range sensitiveConfigFields {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactedConfig returns FlattenConfig(cfg), with the values of the
+// sensitive fields redacted.
+func RedactedConfig(cfg Config) map[string]string {
+	fields := FlattenConfig(cfg)
+	for field, value := range fields {
+		if IsSensitiveConfigField(field) && value != "" {
+			fields[field] = "<redacted>"
+		}
+	}
+	return fields
+}
@@ -0,0 +1,23 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactedConfig(t *testing.T) {
+	var cfg Config
+	cfg.SetDefaults()
+	cfg.API.Security.AdminSecretKey = "secret"
+	cfg.Store.EncryptionKey = ""
+
+	fields := RedactedConfig(cfg)
+	require.Equal(t, "<redacted>", fields["api.security.admin_secret_key"])
+	require.Equal(t, "", fields["store.encryption_key"])
+	require.Equal(t, ":8045", fields["api.http.listen_address"])
+	require.Equal(t, "rtcd_crash", fields["process.crash.dump_dir"])
+}
@@ -6,6 +6,9 @@ package service
 import (
 	"testing"
 
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/rtc"
+
 	"github.com/stretchr/testify/require"
 )
 
@@ -24,6 +27,56 @@ func TestSecurityConfigIsValid(t *testing.T) {
 		require.Equal(t, "invalid AdminSecretKey value: should not be empty", err.Error())
 	})
 
+	t.Run("bootstrap tokens without key", func(t *testing.T) {
+		var cfg SecurityConfig
+		cfg.AllowBootstrapTokens = true
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid AdminSecretKey value: should not be empty", err.Error())
+
+		cfg.AdminSecretKey = "secret_key"
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid join tokens", func(t *testing.T) {
+		var cfg SecurityConfig
+		cfg.JoinTokens.Enable = true
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid JoinTokens config: invalid ExpirationMinutes value: should be a positive number", err.Error())
+
+		cfg.JoinTokens.ExpirationMinutes = 5
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid JoinTokens config: admin access should be enabled to issue the tokens", err.Error())
+
+		cfg.EnableAdmin = true
+		cfg.AdminSecretKey = "secret_key"
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid registration auth", func(t *testing.T) {
+		var cfg SecurityConfig
+		cfg.RegistrationAuth.Provider = "saml"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid RegistrationAuth config: invalid Provider value: should be one of "static", "ldap", "http" or "oidc"`, err.Error())
+	})
+
+	t.Run("registration auth with self registration", func(t *testing.T) {
+		var cfg SecurityConfig
+		cfg.AllowSelfRegistration = true
+		cfg.RegistrationAuth.Provider = auth.ProviderHTTP
+		cfg.RegistrationAuth.TimeoutSeconds = 10
+		cfg.RegistrationAuth.HTTP.URL = "http://localhost/validate"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid RegistrationAuth config: provider "http" can't be used along with self registration`, err.Error())
+
+		cfg.AllowSelfRegistration = false
+		require.NoError(t, cfg.IsValid())
+	})
+
 	t.Run("valid", func(t *testing.T) {
 		var cfg SecurityConfig
 		cfg.EnableAdmin = true
@@ -33,6 +86,215 @@ func TestSecurityConfigIsValid(t *testing.T) {
 	})
 }
 
+func TestAPIConfigIsValid(t *testing.T) {
+	t.Run("no admin listener", func(t *testing.T) {
+		var cfg APIConfig
+		cfg.HTTP.ListenAddress = ":8045"
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid admin listener", func(t *testing.T) {
+		var cfg APIConfig
+		cfg.HTTP.ListenAddress = ":8045"
+		cfg.Admin.ListenAddress = "127.0.0.1:8047"
+		cfg.Admin.TLS.Enable = true
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "failed to validate admin config: invalid TLS config: invalid CertFile value: should not be empty", err.Error())
+	})
+
+	t.Run("valid admin listener", func(t *testing.T) {
+		var cfg APIConfig
+		cfg.HTTP.ListenAddress = ":8045"
+		cfg.Admin.ListenAddress = "127.0.0.1:8047"
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid metrics listener", func(t *testing.T) {
+		var cfg APIConfig
+		cfg.HTTP.ListenAddress = ":8045"
+		cfg.Metrics.ListenAddress = "127.0.0.1:9090"
+		cfg.Metrics.TLS.Enable = true
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "failed to validate metrics config: invalid TLS config: invalid CertFile value: should not be empty", err.Error())
+	})
+
+	t.Run("valid metrics listener", func(t *testing.T) {
+		var cfg APIConfig
+		cfg.HTTP.ListenAddress = ":8045"
+		cfg.Metrics.ListenAddress = "127.0.0.1:9090"
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid stale session timeout", func(t *testing.T) {
+		var cfg APIConfig
+		cfg.HTTP.ListenAddress = ":8045"
+		cfg.StaleSessionTimeoutSeconds = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid StaleSessionTimeoutSeconds value: should not be negative", err.Error())
+	})
+}
+
+func TestP2PConfigIsValid(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.P2P.Enable = true
+	require.NoError(t, cfg.IsValid())
+
+	cfg.RTC.RelayOnly = true
+	cfg.RTC.ICEServers = rtc.ICEServers{
+		rtc.ICEServerConfig{URLs: []string{"turn:localhost:3478"}, Username: "username", Credential: "password"},
+	}
+	require.EqualError(t, cfg.IsValid(), "failed to validate p2p config: peer-to-peer calls can't be enabled in relay only mode")
+}
+
+func TestSignedAuthConfigRequiresEncryption(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.API.Security.SignedAuth.Enable = true
+	cfg.API.Security.SignedAuth.MaxClockSkewSeconds = 300
+	require.EqualError(t, cfg.IsValid(), "invalid SignedAuth config: the store should be encrypted, the signing keys being secrets")
+
+	cfg.Store.EncryptionKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+	require.NoError(t, cfg.IsValid())
+}
+
+func TestFeaturesConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg FeaturesConfig
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("unknown feature", func(t *testing.T) {
+		cfg := FeaturesConfig{Enabled: []string{"av1"}}
+		require.EqualError(t, cfg.IsValid(), `invalid Enabled value: unknown feature "av1"`)
+	})
+
+	t.Run("enabled and disabled", func(t *testing.T) {
+		cfg := FeaturesConfig{
+			Enabled:  []string{rtc.FeatureSessionMigration},
+			Disabled: []string{rtc.FeatureSessionMigration},
+		}
+		require.EqualError(t, cfg.IsValid(), `invalid Disabled value: feature "session_migration" is also enabled`)
+	})
+
+	t.Run("requirements", func(t *testing.T) {
+		cfg := MakeDefaultCfg(t)
+		cfg.Features.Enabled = []string{rtc.FeatureEgressShaping}
+		require.EqualError(t, cfg.IsValid(), "failed to validate features config: egress_shaping requires a valid EgressShaping config: invalid RateKbps value: should be a positive number")
+		cfg.RTC.EgressShaping.RateKbps = 1000
+		cfg.RTC.EgressShaping.BurstKB = 100
+		require.NoError(t, cfg.IsValid())
+	})
+}
+
+func TestOutboundConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg OutboundConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("empty URL", func(t *testing.T) {
+		var cfg OutboundConfig
+		cfg.Enable = true
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid URL value: should not be empty", err.Error())
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		var cfg OutboundConfig
+		cfg.Enable = true
+		cfg.URL = "http://localhost:8065"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid URL value: should start with "ws://" or "wss://"`, err.Error())
+	})
+
+	t.Run("empty client id", func(t *testing.T) {
+		var cfg OutboundConfig
+		cfg.Enable = true
+		cfg.URL = "ws://localhost:8065"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid ClientID value: should not be empty", err.Error())
+	})
+
+	t.Run("invalid reconnect interval", func(t *testing.T) {
+		var cfg OutboundConfig
+		cfg.Enable = true
+		cfg.URL = "ws://localhost:8065"
+		cfg.ClientID = "clientA"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid ReconnectIntervalSeconds value: should be a positive number", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg OutboundConfig
+		cfg.Enable = true
+		cfg.URL = "wss://localhost:8065/plugins/calls/rtcd"
+		cfg.ClientID = "clientA"
+		cfg.ReconnectIntervalSeconds = 2
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestChaosConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg ChaosConfig
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid WSDisconnectPercent", func(t *testing.T) {
+		cfg := ChaosConfig{Enable: true, WSDisconnectPercent: -1}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid WSDisconnectPercent value: should be in the range [0, 100]", err.Error())
+
+		cfg.WSDisconnectPercent = 101
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid WSDisconnectPercent value: should be in the range [0, 100]", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := ChaosConfig{Enable: true, WSDisconnectPercent: 5}
+		require.NoError(t, cfg.IsValid())
+	})
+}
+
+func TestSignalingTraceConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg SignalingTraceConfig
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid MaxSizeMB", func(t *testing.T) {
+		cfg := SignalingTraceConfig{Dir: "/tmp/traces", MaxDurationSeconds: 60}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxSizeMB value: should be a positive number", err.Error())
+	})
+
+	t.Run("invalid MaxDurationSeconds", func(t *testing.T) {
+		cfg := SignalingTraceConfig{Dir: "/tmp/traces", MaxSizeMB: 10}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxDurationSeconds value: should be a positive number", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := SignalingTraceConfig{Dir: "/tmp/traces", MaxSizeMB: 10, MaxDurationSeconds: 60}
+		require.NoError(t, cfg.IsValid())
+	})
+}
+
 func TestStoreConfigIsValid(t *testing.T) {
 	t.Run("empty struct", func(t *testing.T) {
 		var cfg StoreConfig
@@ -41,6 +303,63 @@ func TestStoreConfigIsValid(t *testing.T) {
 		require.Equal(t, "invalid DataSource value: should not be empty", err.Error())
 	})
 
+	t.Run("invalid encryption key", func(t *testing.T) {
+		var cfg StoreConfig
+		cfg.DataSource = "/tmp/rtcd_db"
+		cfg.EncryptionKey = "c2hvcnQ="
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid EncryptionKey value: key should be 32 bytes long", err.Error())
+
+		cfg.EncryptionKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+		err = cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid UsagePersistIntervalSeconds", func(t *testing.T) {
+		var cfg StoreConfig
+		cfg.DataSource = "/tmp/rtcd_db"
+		cfg.UsagePersistIntervalSeconds = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid UsagePersistIntervalSeconds value: should not be negative", err.Error())
+	})
+
+	t.Run("invalid call events settings", func(t *testing.T) {
+		var cfg StoreConfig
+		cfg.DataSource = "/tmp/rtcd_db"
+		cfg.CallEventsMax = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid CallEventsMax value: should not be negative", err.Error())
+
+		cfg.CallEventsMax = 100
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid CallEventsTTLHours value: should be positive", err.Error())
+
+		cfg.CallEventsTTLHours = 24
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid compaction settings", func(t *testing.T) {
+		var cfg StoreConfig
+		cfg.DataSource = "/tmp/rtcd_db"
+		cfg.CompactionIntervalMinutes = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid CompactionIntervalMinutes value: should not be negative", err.Error())
+
+		cfg.CompactionIntervalMinutes = 60
+		cfg.MaxSizeMB = -1
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxSizeMB value: should not be negative", err.Error())
+
+		cfg.MaxSizeMB = 1024
+		require.NoError(t, cfg.IsValid())
+	})
+
 	t.Run("valid", func(t *testing.T) {
 		var cfg StoreConfig
 		cfg.DataSource = "/tmp/rtcd_db"
@@ -49,6 +368,123 @@ func TestStoreConfigIsValid(t *testing.T) {
 	})
 }
 
+func TestProcessConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg ProcessConfig
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid OpenFilesLimit", func(t *testing.T) {
+		cfg := ProcessConfig{OpenFilesLimit: -1}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid OpenFilesLimit value: should not be negative", err.Error())
+	})
+
+	t.Run("invalid OpenFilesWarnPercent", func(t *testing.T) {
+		cfg := ProcessConfig{OpenFilesWarnPercent: 101}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid OpenFilesWarnPercent value: should be in the range [0, 100]", err.Error())
+	})
+
+	t.Run("invalid ShutdownTimeoutSeconds", func(t *testing.T) {
+		cfg := ProcessConfig{ShutdownTimeoutSeconds: -1}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid ShutdownTimeoutSeconds value: should not be negative", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := ProcessConfig{OpenFilesLimit: 65536, OpenFilesWarnPercent: 80}
+		require.NoError(t, cfg.IsValid())
+	})
+}
+
+func TestBotsConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg BotsConfig
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid MaxCount", func(t *testing.T) {
+		cfg := BotsConfig{MaxCount: -1}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxCount value: should not be negative", err.Error())
+
+		cfg = BotsConfig{Enable: true}
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxCount value: should be positive", err.Error())
+	})
+
+	t.Run("invalid MaxDurationMinutes", func(t *testing.T) {
+		cfg := BotsConfig{MaxDurationMinutes: -1}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxDurationMinutes value: should not be negative", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := BotsConfig{Enable: true, MaxCount: 10, MaxDurationMinutes: 60}
+		require.NoError(t, cfg.IsValid())
+	})
+}
+
+func TestMirroringConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg MirroringConfig
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid MaxCount", func(t *testing.T) {
+		cfg := MirroringConfig{MaxCount: -1}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxCount value: should not be negative", err.Error())
+
+		cfg = MirroringConfig{Enable: true, RetryIntervalSeconds: 2}
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxCount value: should be positive", err.Error())
+	})
+
+	t.Run("invalid RetryIntervalSeconds", func(t *testing.T) {
+		cfg := MirroringConfig{Enable: true, MaxCount: 10}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid RetryIntervalSeconds value: should be positive", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := MirroringConfig{Enable: true, MaxCount: 10, RetryIntervalSeconds: 2}
+		require.NoError(t, cfg.IsValid())
+	})
+}
+
+func TestConfigFIPSMode(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.FIPS.Enable = true
+	require.NoError(t, cfg.IsValid())
+
+	cfg.RTC.SRTPProtectionProfiles = []string{"AEAD_AES_128_GCM"}
+	require.NoError(t, cfg.IsValid())
+
+	cfg.RTC.SRTPProtectionProfiles = []string{"AEAD_AES_128_GCM", "AES_128_CM_SHA1_80"}
+	err := cfg.IsValid()
+	require.Error(t, err)
+	require.Equal(t, `invalid SRTPProtectionProfiles value: only "AEAD_AES_128_GCM" is allowed in FIPS mode`, err.Error())
+
+	cfg.FIPS.Enable = false
+	require.NoError(t, cfg.IsValid())
+
+	cfg.FIPS.RequireValidatedModule = true
+	err = cfg.IsValid()
+	require.Error(t, err)
+	require.Equal(t, "failed to validate fips config: invalid RequireValidatedModule value: FIPS mode should be enabled", err.Error())
+}
+
 func TestClientConfigParse(t *testing.T) {
 	t.Run("empty struct", func(t *testing.T) {
 		var cfg ClientConfig
@@ -88,4 +524,39 @@ func TestClientConfigParse(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, "wss://rtcd.example.com/ws", cfg.wsURL)
 	})
+
+	t.Run("path prefix", func(t *testing.T) {
+		var cfg ClientConfig
+		cfg.URL = "https://example.com/rtcd"
+		err := cfg.Parse()
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/rtcd", cfg.httpURL.String())
+		require.Equal(t, "wss://example.com/rtcd/ws", cfg.wsURL)
+
+		cfg.URL = "http://example.com:8045/path/to/rtcd/"
+		err = cfg.Parse()
+		require.NoError(t, err)
+		require.Equal(t, "http://example.com:8045/path/to/rtcd", cfg.httpURL.String())
+		require.Equal(t, "ws://example.com:8045/path/to/rtcd/ws", cfg.wsURL)
+	})
+
+	t.Run("escaped path prefix", func(t *testing.T) {
+		var cfg ClientConfig
+		cfg.URL = "https://example.com/a%2Fb/"
+		err := cfg.Parse()
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/a%2Fb", cfg.httpURL.String())
+		require.Equal(t, "https://example.com/a%2Fb/register", cfg.getHTTPURL("register"))
+		require.Equal(t, "wss://example.com/a%2Fb/ws", cfg.wsURL)
+	})
+
+	t.Run("query", func(t *testing.T) {
+		var cfg ClientConfig
+		cfg.URL = "https://example.com/rtcd?token=abc%2B1#fragment"
+		err := cfg.Parse()
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/rtcd?token=abc%2B1", cfg.httpURL.String())
+		require.Equal(t, "https://example.com/rtcd/version?token=abc%2B1", cfg.getHTTPURL("version"))
+		require.Equal(t, "wss://example.com/rtcd/ws?token=abc%2B1", cfg.wsURL)
+	})
 }
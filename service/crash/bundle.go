@@ -0,0 +1,98 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package crash
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+)
+
+const bundlePrefix = "crash-"
+
+// writeBundle writes a diagnostic bundle, made of the following files, to a
+// new directory in DumpDir, returning its path:
+//   - panic.txt: the panic value and the stack of the panicking goroutine.
+//   - goroutines.txt: the stacks of all the goroutines.
+//   - logs.txt: the recent log records.
+//   - config.txt: the config.
+func (r *Reporter) writeBundle(now time.Time, component string, v interface{}, stack []byte) (string, error) {
+	// Names are sortable by time.
+	dir := filepath.Join(r.cfg.DumpDir, bundlePrefix+now.UTC().Format("20060102T150405.000"))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "component: %s\ntime: %s\npanic: %v\n\n", component, now.UTC().Format(time.RFC3339Nano), v)
+	buf.Write(stack)
+	if err := os.WriteFile(filepath.Join(dir, "panic.txt"), buf.Bytes(), 0600); err != nil {
+		return "", fmt.Errorf("failed to write panic: %w", err)
+	}
+
+	buf.Reset()
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 2); err != nil {
+		return "", fmt.Errorf("failed to get goroutines: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "goroutines.txt"), buf.Bytes(), 0600); err != nil {
+		return "", fmt.Errorf("failed to write goroutines: %w", err)
+	}
+
+	if r.logs != nil {
+		buf.Reset()
+		if _, err := r.logs.WriteTo(&buf); err != nil {
+			return "", fmt.Errorf("failed to get logs: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "logs.txt"), buf.Bytes(), 0600); err != nil {
+			return "", fmt.Errorf("failed to write logs: %w", err)
+		}
+	}
+
+	lines := make([]string, 0, len(r.config))
+	for field, value := range r.config {
+		lines = append(lines, fmt.Sprintf("%s = %q\n", field, value))
+	}
+	sort.Strings(lines)
+	if err := os.WriteFile(filepath.Join(dir, "config.txt"), []byte(strings.Join(lines, "")), 0600); err != nil {
+		return "", fmt.Errorf("failed to write config: %w", err)
+	}
+
+	return dir, nil
+}
+
+// pruneBundles removes the oldest bundles exceeding MaxDumps.
+func (r *Reporter) pruneBundles() error {
+	if r.cfg.MaxDumps == 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(r.cfg.DumpDir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var bundles []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), bundlePrefix) {
+			bundles = append(bundles, entry.Name())
+		}
+	}
+	if len(bundles) <= r.cfg.MaxDumps {
+		return nil
+	}
+
+	// Entries are sorted by name, thus by time.
+	for _, name := range bundles[:len(bundles)-r.cfg.MaxDumps] {
+		if err := os.RemoveAll(filepath.Join(r.cfg.DumpDir, name)); err != nil {
+			return fmt.Errorf("failed to remove bundle: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package crash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBundle(t *testing.T) {
+	dir := t.TempDir()
+	r, _ := newTestReporter(t, Config{DumpDir: dir})
+
+	now := time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC)
+	path, err := r.writeBundle(now, "test", "test panic", []byte("stack trace"))
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "crash-20221101T100000.000"), path)
+
+	data, err := os.ReadFile(filepath.Join(path, "panic.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "component: test\ntime: 2022-11-01T10:00:00Z\npanic: test panic\n\nstack trace", string(data))
+
+	data, err = os.ReadFile(filepath.Join(path, "goroutines.txt"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "TestWriteBundle")
+
+	data, err = os.ReadFile(filepath.Join(path, "logs.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "recent entry\n", string(data))
+
+	data, err = os.ReadFile(filepath.Join(path, "config.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "api.security.admin_secret_key = \"<redacted>\"\nstore.data_source = \"/tmp/rtcd_db\"\n", string(data))
+}
+
+func TestPruneBundles(t *testing.T) {
+	dir := t.TempDir()
+	r, _ := newTestReporter(t, Config{DumpDir: dir, MaxDumps: 2})
+
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		_, err := r.writeBundle(now.Add(time.Duration(i)*time.Second), "test", "test panic", nil)
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other"), nil, 0600))
+
+	require.NoError(t, r.pruneBundles())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, bundlePrefix+now.Add(2*time.Second).UTC().Format("20060102T150405.000"), entries[0].Name())
+	require.Equal(t, bundlePrefix+now.Add(3*time.Second).UTC().Format("20060102T150405.000"), entries[1].Name())
+	require.Equal(t, "other", entries[2].Name())
+
+	t.Run("no limit", func(t *testing.T) {
+		r.cfg.MaxDumps = 0
+		_, err := r.writeBundle(now.Add(time.Minute), "test", "test panic", nil)
+		require.NoError(t, err)
+		require.NoError(t, r.pruneBundles())
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 4)
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package crash
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/logger"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// bundleCooldown is the minimum interval between diagnostic bundles, so that
+// a panic repeating in a loop doesn't fill the disk.
+const bundleCooldown = time.Minute
+
+type Config struct {
+	// DumpDir is the directory where a diagnostic bundle is written when a
+	// panic is recovered. Bundles are disabled if empty.
+	DumpDir string `toml:"dump_dir"`
+	// MaxDumps is the number of bundles kept in DumpDir, the oldest ones
+	// being removed. Zero means no limit.
+	MaxDumps int `toml:"max_dumps"`
+	// LogLines is the number of recent log records included in the bundles.
+	LogLines int `toml:"log_lines"`
+}
+
+func (c Config) IsValid() error {
+	if c.MaxDumps < 0 {
+		return fmt.Errorf("invalid MaxDumps value: should not be negative")
+	}
+	if c.LogLines < 0 {
+		return fmt.Errorf("invalid LogLines value: should not be negative")
+	}
+	return nil
+}
+
+// Reporter handles the panics recovered by the service, logging them and
+// writing diagnostic bundles. A nil Reporter doesn't recover panics.
+type Reporter struct {
+	cfg    Config
+	log    mlog.LoggerIFace
+	logs   *logger.RecentLogs
+	config map[string]string
+
+	lastBundleAt time.Time
+	mut          sync.Mutex
+}
+
+// NewReporter returns a new Reporter. The recent log records, if not nil,
+// and the (redacted) config are included in the bundles.
+func NewReporter(cfg Config, log mlog.LoggerIFace, logs *logger.RecentLogs, config map[string]string) (*Reporter, error) {
+	if err := cfg.IsValid(); err != nil {
+		return nil, err
+	}
+	if log == nil {
+		return nil, fmt.Errorf("log should not be nil")
+	}
+
+	return &Reporter{
+		cfg:    cfg,
+		log:    log,
+		logs:   logs,
+		config: config,
+	}, nil
+}
+
+// Recover recovers from a panic of the calling goroutine, reporting it and
+// then calling onPanic, if not nil, to clean up the affected component. It
+// must be directly deferred.
+func (r *Reporter) Recover(component string, onPanic func()) {
+	if r == nil {
+		return
+	}
+	v := recover()
+	if v == nil {
+		return
+	}
+
+	r.Report(component, v, debug.Stack())
+
+	if onPanic != nil {
+		onPanic()
+	}
+}
+
+// Report logs a recovered panic, along with its stack, and writes a
+// diagnostic bundle.
+func (r *Reporter) Report(component string, v interface{}, stack []byte) {
+	r.log.Error("recovered from panic",
+		mlog.String("component", component),
+		mlog.String("panic", fmt.Sprint(v)),
+		mlog.String("stack", string(stack)),
+	)
+
+	if r.cfg.DumpDir == "" {
+		return
+	}
+
+	now := time.Now()
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if now.Sub(r.lastBundleAt) < bundleCooldown {
+		r.log.Warn("skipping diagnostic bundle, one was recently written", mlog.String("component", component))
+		return
+	}
+	r.lastBundleAt = now
+
+	// Making sure the panic entry is part of the recent logs.
+	if f, ok := r.log.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+
+	path, err := r.writeBundle(now, component, v, stack)
+	if err != nil {
+		r.log.Error("failed to write diagnostic bundle", mlog.Err(err))
+		return
+	}
+	r.log.Info("diagnostic bundle written", mlog.String("path", path))
+
+	if err := r.pruneBundles(); err != nil {
+		r.log.Error("failed to prune diagnostic bundles", mlog.Err(err))
+	}
+}
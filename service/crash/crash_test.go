@@ -0,0 +1,123 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package crash
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/mattermost/rtcd/logger"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestReporter(t *testing.T, cfg Config) (*Reporter, *bytes.Buffer) {
+	t.Helper()
+
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, log.Shutdown())
+	})
+	var buf bytes.Buffer
+	err = mlog.AddWriterTarget(log, &buf, true, mlog.LvlInfo, mlog.LvlWarn, mlog.LvlError)
+	require.NoError(t, err)
+
+	logs := logger.NewRecentLogs(10)
+	_, err = logs.Write([]byte("recent entry\n"), nil)
+	require.NoError(t, err)
+
+	r, err := NewReporter(cfg, log, logs, map[string]string{
+		"store.data_source":             "/tmp/rtcd_db",
+		"api.security.admin_secret_key": "<redacted>",
+	})
+	require.NoError(t, err)
+
+	return r, &buf
+}
+
+func TestConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg Config
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid MaxDumps", func(t *testing.T) {
+		cfg := Config{MaxDumps: -1}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxDumps value: should not be negative", err.Error())
+	})
+
+	t.Run("invalid LogLines", func(t *testing.T) {
+		cfg := Config{LogLines: -1}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid LogLines value: should not be negative", err.Error())
+	})
+}
+
+func TestRecover(t *testing.T) {
+	t.Run("nil reporter", func(t *testing.T) {
+		var r *Reporter
+		require.PanicsWithValue(t, "test", func() {
+			defer r.Recover("test", nil)
+			panic("test")
+		})
+	})
+
+	t.Run("no panic", func(t *testing.T) {
+		r, _ := newTestReporter(t, Config{})
+		var called bool
+		func() {
+			defer r.Recover("test", func() { called = true })
+		}()
+		require.False(t, called)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		dir := t.TempDir()
+		r, buf := newTestReporter(t, Config{DumpDir: dir})
+		var called bool
+		require.NotPanics(t, func() {
+			defer r.Recover("test", func() { called = true })
+			panic("test panic")
+		})
+		require.True(t, called)
+
+		require.NoError(t, r.log.(*mlog.Logger).Flush())
+		require.Contains(t, buf.String(), `"msg":"recovered from panic"`)
+		require.Contains(t, buf.String(), `"component":"test"`)
+		require.Contains(t, buf.String(), `"panic":"test panic"`)
+		require.Contains(t, buf.String(), `"msg":"diagnostic bundle written"`)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		// Bundles are rate limited.
+		require.NotPanics(t, func() {
+			defer r.Recover("test", nil)
+			panic("test panic")
+		})
+		entries, err = os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.NoError(t, r.log.(*mlog.Logger).Flush())
+		require.Contains(t, buf.String(), `"msg":"skipping diagnostic bundle, one was recently written"`)
+	})
+
+	t.Run("bundles disabled", func(t *testing.T) {
+		r, buf := newTestReporter(t, Config{})
+		require.NotPanics(t, func() {
+			defer r.Recover("test", nil)
+			panic("test panic")
+		})
+		require.NoError(t, r.log.(*mlog.Logger).Flush())
+		require.Contains(t, buf.String(), `"msg":"recovered from panic"`)
+		require.NotContains(t, buf.String(), "diagnostic bundle")
+	})
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/rtcd/service/perf"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// diagnosticsFile is a file included in a diagnostic bundle.
+type diagnosticsFile struct {
+	name string
+	data []byte
+}
+
+// diagnosticsCall summarizes the state of a call for a diagnostic bundle.
+type diagnosticsCall struct {
+	GroupID        string `json:"group_id"`
+	CallID         string `json:"call_id"`
+	Sessions       int    `json:"sessions"`
+	Tracks         int    `json:"tracks"`
+	StalledTracks  int    `json:"stalled_tracks"`
+	ForwardedBytes uint64 `json:"forwarded_bytes"`
+	NACKs          uint64 `json:"nacks"`
+	PLIs           uint64 `json:"plis"`
+	// State is nil if the call ended while building the bundle.
+	State *rtc.CallState `json:"state,omitempty"`
+}
+
+// getDiagnostics returns the files making up a diagnostic bundle:
+//   - config.txt: the config, with the secrets redacted.
+//   - logs.txt: the recent log records, if kept (see process.crash.log_lines).
+//   - goroutines.txt: the stacks of all the goroutines.
+//   - metrics.txt: the current value of the metrics, if using Prometheus.
+//   - calls.json: the state of the ongoing calls.
+func (s *Service) getDiagnostics() ([]diagnosticsFile, error) {
+	var files []diagnosticsFile
+
+	config := RedactedConfig(s.cfg)
+	lines := make([]string, 0, len(config))
+	for field, value := range config {
+		lines = append(lines, fmt.Sprintf("%s = %q\n", field, value))
+	}
+	sort.Strings(lines)
+	files = append(files, diagnosticsFile{name: "config.txt", data: []byte(strings.Join(lines, ""))})
+
+	if s.recentLogs != nil {
+		if err := s.log.Flush(); err != nil {
+			s.log.Warn("failed to flush logs", mlog.Err(err))
+		}
+		var buf bytes.Buffer
+		if _, err := s.recentLogs.WriteTo(&buf); err != nil {
+			return nil, fmt.Errorf("failed to get logs: %w", err)
+		}
+		files = append(files, diagnosticsFile{name: "logs.txt", data: buf.Bytes()})
+	}
+
+	var goroutines bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&goroutines, 2); err != nil {
+		return nil, fmt.Errorf("failed to get goroutines: %w", err)
+	}
+	files = append(files, diagnosticsFile{name: "goroutines.txt", data: goroutines.Bytes()})
+
+	var metrics bytes.Buffer
+	if err := s.metrics.WriteSnapshot(&metrics); err == nil {
+		files = append(files, diagnosticsFile{name: "metrics.txt", data: metrics.Bytes()})
+	} else if !errors.Is(err, perf.ErrSnapshotUnsupported) {
+		return nil, fmt.Errorf("failed to get metrics: %w", err)
+	}
+
+	calls := []diagnosticsCall{}
+	for _, cs := range s.rtcServer.GetCallsStats() {
+		call := diagnosticsCall{
+			GroupID:        cs.GroupID,
+			CallID:         cs.CallID,
+			Sessions:       cs.Sessions,
+			Tracks:         cs.Tracks,
+			StalledTracks:  cs.StalledTracks,
+			ForwardedBytes: cs.ForwardedBytes,
+			NACKs:          cs.NACKs,
+			PLIs:           cs.PLIs,
+		}
+		if state, err := s.rtcServer.GetCallState(cs.GroupID, cs.CallID); err == nil {
+			call.State = &state
+		}
+		calls = append(calls, call)
+	}
+	js, err := json.MarshalIndent(calls, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal calls: %w", err)
+	}
+	files = append(files, diagnosticsFile{name: "calls.json", data: js})
+
+	return files, nil
+}
+
+// writeDiagnostics writes the files as a gzipped tarball to w.
+func writeDiagnostics(w io.Writer, files []diagnosticsFile, now time.Time) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+
+	for _, file := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    file.name,
+			Mode:    0600,
+			Size:    int64(len(file.data)),
+			ModTime: now,
+		}); err != nil {
+			return fmt.Errorf("failed to write header: %w", err)
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	// The response is only written by httpAudit in case of failure.
+	rw := w
+	defer func() {
+		s.httpAudit("handleDiagnostics", data, rw, r)
+	}()
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	// The files are gathered upfront so that failures can still be reported.
+	files, err := s.getDiagnostics()
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	now := time.Now()
+	rw = nil
+	data.code = http.StatusOK
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="rtcd-diagnostics-%s.tar.gz"`, now.UTC().Format("20060102T150405")))
+	w.WriteHeader(data.code)
+	if err := writeDiagnostics(w, files, now); err != nil {
+		s.log.Error("failed to write diagnostics", mlog.Err(err))
+	}
+}
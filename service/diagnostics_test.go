@@ -0,0 +1,77 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnosticsHandler(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.Process.Crash.LogLines = 100
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	registerClient(t, th, "clientA", "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H")
+
+	t.Run("invalid method", func(t *testing.T) {
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/diagnostics", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/diagnostics", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("clientA", "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/diagnostics", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/gzip", resp.Header.Get("Content-Type"))
+
+		zr, err := gzip.NewReader(resp.Body)
+		require.NoError(t, err)
+		tr := tar.NewReader(zr)
+		files := map[string]string{}
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			data, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			files[hdr.Name] = string(data)
+		}
+
+		require.Len(t, files, 5)
+		require.Contains(t, files["config.txt"], `api.security.admin_secret_key = "<redacted>"`)
+		require.NotContains(t, files["config.txt"], `= "`+th.srvc.cfg.API.Security.AdminSecretKey+`"`)
+		require.Contains(t, files, "logs.txt")
+		require.Contains(t, files["goroutines.txt"], "goroutine ")
+		require.Contains(t, files["metrics.txt"], "rtcd_process_open_files_limit")
+		require.Equal(t, "[]", files["calls.json"])
+	})
+}
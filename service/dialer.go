@@ -0,0 +1,190 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDialAttemptTimeout = 3 * time.Second
+	defaultDialAttemptDelay   = 250 * time.Millisecond
+)
+
+// happyEyeballsDialer connects to the first reachable address a host resolves
+// to. Following RFC 8305, attempts alternate between address families and are
+// started in a staggered fashion, each bounded by its own timeout, so that a
+// dead address (e.g. a stale AAAA record) doesn't hold the connection up.
+type happyEyeballsDialer struct {
+	attemptTimeout time.Duration
+	attemptDelay   time.Duration
+
+	lookupFn func(ctx context.Context, host string) ([]net.IPAddr, error)
+	dialFn   DialContextFn
+}
+
+func newHappyEyeballsDialer(attemptTimeout, attemptDelay time.Duration) *happyEyeballsDialer {
+	dialer := &net.Dialer{
+		KeepAlive: 30 * time.Second,
+	}
+	return &happyEyeballsDialer{
+		attemptTimeout: attemptTimeout,
+		attemptDelay:   attemptDelay,
+		lookupFn:       net.DefaultResolver.LookupIPAddr,
+		dialFn:         dialer.DialContext,
+	}
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+func (d *happyEyeballsDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address: %w", err)
+	}
+
+	var ipAddrs []net.IPAddr
+	if ip := net.ParseIP(host); ip != nil {
+		ipAddrs = []net.IPAddr{{IP: ip}}
+	} else {
+		ipAddrs, err = d.lookupFn(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve host: %w", err)
+		}
+	}
+
+	addrs := interleaveAddrs(filterAddrs(network, ipAddrs))
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no %s address found for %q", network, host)
+	}
+	for i := range addrs {
+		addrs[i] = net.JoinHostPort(addrs[i], port)
+	}
+
+	return d.race(ctx, network, addrs)
+}
+
+// race dials the given addresses, starting a new attempt every attemptDelay
+// or as soon as the previous one failed, and returns the first connection
+// established.
+func (d *happyEyeballsDialer) race(ctx context.Context, network string, addrs []string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered so that attempts still in flight once done never block.
+	resCh := make(chan dialResult, len(addrs))
+	var pending int
+	dial := func(addr string) {
+		pending++
+		go func() {
+			attemptCtx, cancel := context.WithTimeout(ctx, d.attemptTimeout)
+			defer cancel()
+			conn, err := d.dialFn(attemptCtx, network, addr)
+			resCh <- dialResult{conn: conn, err: err}
+		}()
+	}
+	// closePending closes the connections of the attempts still in flight,
+	// should they succeed anyway.
+	closePending := func(n int) {
+		for i := 0; i < n; i++ {
+			if res := <-resCh; res.conn != nil {
+				res.conn.Close()
+			}
+		}
+	}
+
+	dial(addrs[0])
+	next := 1
+	var errs []string
+	for pending > 0 {
+		var delay *time.Timer
+		var delayCh <-chan time.Time
+		if next < len(addrs) {
+			delay = time.NewTimer(d.attemptDelay)
+			delayCh = delay.C
+		}
+
+		var res dialResult
+		var done bool
+		select {
+		case res = <-resCh:
+			pending--
+			done = true
+		case <-delayCh:
+		case <-ctx.Done():
+			go closePending(pending)
+			return nil, ctx.Err()
+		}
+
+		if delay != nil {
+			delay.Stop()
+		}
+
+		if done && res.err == nil {
+			cancel()
+			go closePending(pending)
+			return res.conn, nil
+		}
+		if done {
+			errs = append(errs, res.err.Error())
+		}
+
+		if next < len(addrs) {
+			dial(addrs[next])
+			next++
+		}
+	}
+
+	return nil, fmt.Errorf("failed to connect: %s", strings.Join(errs, "; "))
+}
+
+// filterAddrs returns the addresses usable with the given network.
+func filterAddrs(network string, ipAddrs []net.IPAddr) []net.IPAddr {
+	var addrs []net.IPAddr
+	for _, addr := range ipAddrs {
+		isIPv4 := addr.IP.To4() != nil
+		if (strings.HasSuffix(network, "4") && !isIPv4) || (strings.HasSuffix(network, "6") && isIPv4) {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// interleaveAddrs alternates between address families, starting with the
+// family of the preferred (first) address, and returns the addresses as
+// strings.
+func interleaveAddrs(ipAddrs []net.IPAddr) []string {
+	if len(ipAddrs) == 0 {
+		return nil
+	}
+
+	var primary, fallback []string
+	primaryIPv4 := ipAddrs[0].IP.To4() != nil
+	for _, addr := range ipAddrs {
+		if (addr.IP.To4() != nil) == primaryIPv4 {
+			primary = append(primary, addr.String())
+		} else {
+			fallback = append(fallback, addr.String())
+		}
+	}
+
+	addrs := make([]string, 0, len(ipAddrs))
+	for i := 0; i < len(primary) || i < len(fallback); i++ {
+		if i < len(primary) {
+			addrs = append(addrs, primary[i])
+		}
+		if i < len(fallback) {
+			addrs = append(addrs, fallback[i])
+		}
+	}
+	return addrs
+}
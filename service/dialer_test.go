@@ -0,0 +1,151 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterleaveAddrs(t *testing.T) {
+	parse := func(ips ...string) []net.IPAddr {
+		addrs := make([]net.IPAddr, 0, len(ips))
+		for _, ip := range ips {
+			addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+		}
+		return addrs
+	}
+
+	require.Empty(t, interleaveAddrs(nil))
+	require.Equal(t, []string{"::1", "127.0.0.1", "::2", "127.0.0.2", "::3"},
+		interleaveAddrs(parse("::1", "::2", "::3", "127.0.0.1", "127.0.0.2")))
+	require.Equal(t, []string{"127.0.0.1", "::1", "127.0.0.2"},
+		interleaveAddrs(parse("127.0.0.1", "127.0.0.2", "::1")))
+	require.Equal(t, []string{"127.0.0.1"}, interleaveAddrs(filterAddrs("tcp4", parse("::1", "127.0.0.1"))))
+	require.Equal(t, []string{"::1"}, interleaveAddrs(filterAddrs("tcp6", parse("::1", "127.0.0.1"))))
+}
+
+func TestHappyEyeballsDialer(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	require.NoError(t, err)
+
+	// newDialer returns a dialer for a host resolving to a dead IPv6 address,
+	// whose connection attempts hang, followed by the listener's address.
+	newDialer := func(attemptTimeout, attemptDelay time.Duration) (*happyEyeballsDialer, func() []string) {
+		d := newHappyEyeballsDialer(attemptTimeout, attemptDelay)
+		d.lookupFn = func(_ context.Context, host string) ([]net.IPAddr, error) {
+			if host != "rtcd.example.com" {
+				return nil, errors.New("no such host")
+			}
+			return []net.IPAddr{{IP: net.ParseIP("2001:db8::1")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+		}
+		var dialed []string
+		var mut sync.Mutex
+		dialFn := d.dialFn
+		d.dialFn = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			mut.Lock()
+			dialed = append(dialed, addr)
+			mut.Unlock()
+			if addr == net.JoinHostPort("2001:db8::1", port) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return dialFn(ctx, network, addr)
+		}
+		return d, func() []string {
+			mut.Lock()
+			defer mut.Unlock()
+			return append([]string(nil), dialed...)
+		}
+	}
+
+	t.Run("dead address", func(t *testing.T) {
+		d, dialed := newDialer(time.Minute, 50*time.Millisecond)
+
+		start := time.Now()
+		conn, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("rtcd.example.com", port))
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Less(t, time.Since(start), 5*time.Second)
+		require.Equal(t, ln.Addr().String(), conn.RemoteAddr().String())
+		require.Equal(t, []string{net.JoinHostPort("2001:db8::1", port), ln.Addr().String()}, dialed())
+	})
+
+	t.Run("network", func(t *testing.T) {
+		d, dialed := newDialer(time.Minute, time.Minute)
+
+		conn, err := d.DialContext(context.Background(), "tcp4", net.JoinHostPort("rtcd.example.com", port))
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Equal(t, []string{ln.Addr().String()}, dialed())
+
+		_, err = d.DialContext(context.Background(), "tcp4", net.JoinHostPort("::1", port))
+		require.EqualError(t, err, `no tcp4 address found for "::1"`)
+	})
+
+	t.Run("attempt timeout", func(t *testing.T) {
+		d, _ := newDialer(100*time.Millisecond, time.Minute)
+
+		start := time.Now()
+		_, err := d.DialContext(context.Background(), "tcp6", net.JoinHostPort("rtcd.example.com", port))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to connect: "+context.DeadlineExceeded.Error())
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("failed attempt", func(t *testing.T) {
+		d, _ := newDialer(time.Minute, time.Minute)
+		d.lookupFn = func(_ context.Context, _ string) ([]net.IPAddr, error) {
+			return []net.IPAddr{{IP: net.ParseIP("127.0.0.2")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+		}
+		dialFn := d.dialFn
+		d.dialFn = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if addr == net.JoinHostPort("127.0.0.2", port) {
+				return nil, errors.New("connection refused")
+			}
+			return dialFn(ctx, network, addr)
+		}
+
+		// The next address is tried right away rather than after the delay.
+		start := time.Now()
+		conn, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("rtcd.example.com", port))
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		d, _ := newDialer(time.Minute, time.Minute)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err := d.DialContext(ctx, "tcp6", net.JoinHostPort("rtcd.example.com", port))
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("resolve error", func(t *testing.T) {
+		d, _ := newDialer(time.Minute, time.Minute)
+
+		_, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("unknown.example.com", port))
+		require.EqualError(t, err, "failed to resolve host: no such host")
+	})
+}
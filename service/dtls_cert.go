@@ -0,0 +1,203 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/store"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const (
+	dtlsCertStoreKey = "rtcd:dtls_certificate"
+	// dtlsCertCheckInterval is the interval at which the persisted
+	// certificate is checked for rotation.
+	dtlsCertCheckInterval = time.Hour
+	// dtlsCertValidityMargin extends the validity of certificates past their
+	// rotation so that they don't expire before being replaced.
+	dtlsCertValidityMargin = 7 * 24 * time.Hour
+)
+
+// dtlsCertificate is the DTLS certificate as persisted in the store.
+type dtlsCertificate struct {
+	// PEM holds the certificate and its private key.
+	PEM string `json:"pem"`
+	// CreatedAt is the time, in seconds since the Unix epoch, the
+	// certificate was generated at.
+	CreatedAt int64 `json:"created_at"`
+}
+
+func (s *Service) getDTLSCertificate() (dtlsCertificate, *webrtc.Certificate, error) {
+	var entry dtlsCertificate
+	val, err := s.store.Get(dtlsCertStoreKey)
+	if err != nil {
+		return entry, nil, err
+	}
+	if err := json.Unmarshal([]byte(val), &entry); err != nil {
+		return entry, nil, fmt.Errorf("failed to unmarshal certificate: %w", err)
+	}
+	cert, err := webrtc.CertificateFromPEM(entry.PEM)
+	if err != nil {
+		return entry, nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return entry, cert, nil
+}
+
+// loadDTLSCertificate returns the persisted DTLS certificate, replacing it
+// with a new one first if it's missing, invalid or due for rotation.
+func (s *Service) loadDTLSCertificate(now time.Time) (dtlsCertificate, *webrtc.Certificate, error) {
+	rotation := time.Duration(s.cfg.RTC.DTLSCertificate.RotationDays) * 24 * time.Hour
+
+	entry, cert, err := s.getDTLSCertificate()
+	if err == nil {
+		if now.Before(time.Unix(entry.CreatedAt, 0).Add(rotation)) && now.Before(cert.Expires()) {
+			return entry, cert, nil
+		}
+		s.log.Info("rotating DTLS certificate", mlog.Int64("createdAt", entry.CreatedAt))
+	} else if !errors.Is(err, store.ErrNotFound) {
+		s.log.Error("failed to get DTLS certificate, generating a new one", mlog.Err(err))
+	}
+
+	cert, err = rtc.NewDTLSCertificate(now, rotation+dtlsCertValidityMargin)
+	if err != nil {
+		return entry, nil, fmt.Errorf("failed to generate certificate: %w", err)
+	}
+	pem, err := cert.PEM()
+	if err != nil {
+		return entry, nil, fmt.Errorf("failed to encode certificate: %w", err)
+	}
+	entry = dtlsCertificate{
+		PEM:       pem,
+		CreatedAt: now.Unix(),
+	}
+
+	js, err := json.Marshal(entry)
+	if err != nil {
+		return entry, nil, fmt.Errorf("failed to marshal certificate: %w", err)
+	}
+	if err := s.store.Set(dtlsCertStoreKey, string(js)); err != nil {
+		return entry, nil, fmt.Errorf("failed to store certificate: %w", err)
+	}
+
+	return entry, cert, nil
+}
+
+// updateDTLSCertificate makes the rtc server use the persisted DTLS
+// certificate, if it changed since the last call. Sessions already
+// established keep the certificate they were initialized with.
+func (s *Service) updateDTLSCertificate(now time.Time) error {
+	entry, cert, err := s.loadDTLSCertificate(now)
+	if err != nil {
+		return err
+	}
+	if entry.PEM == s.dtlsCertPEM {
+		return nil
+	}
+
+	fingerprint, err := dtlsFingerprint(cert)
+	if err != nil {
+		return err
+	}
+
+	s.rtcServer.SetDTLSCertificate(cert)
+	s.dtlsCertPEM = entry.PEM
+	s.log.Info("using DTLS certificate",
+		mlog.String("fingerprint", fingerprint),
+		mlog.Int64("createdAt", entry.CreatedAt),
+		mlog.String("expiresAt", cert.Expires().UTC().Format(time.RFC3339)),
+	)
+
+	return nil
+}
+
+// runDTLSCertificateRotation periodically rotates the persisted DTLS
+// certificate.
+func (s *Service) runDTLSCertificateRotation(interval time.Duration) {
+	defer close(s.dtlsCertDoneCh)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.updateDTLSCertificate(time.Now()); err != nil {
+				s.log.Error("failed to update DTLS certificate", mlog.Err(err))
+			}
+		case <-s.dtlsCertStopCh:
+			return
+		}
+	}
+}
+
+// dtlsFingerprint returns the fingerprint of cert, as advertised in the
+// a=fingerprint SDP attribute.
+func dtlsFingerprint(cert *webrtc.Certificate) (string, error) {
+	fingerprints, err := cert.GetFingerprints()
+	if err != nil {
+		return "", fmt.Errorf("failed to get fingerprints: %w", err)
+	}
+	if len(fingerprints) == 0 {
+		return "", errors.New("no fingerprint")
+	}
+	return fingerprints[0].Algorithm + " " + fingerprints[0].Value, nil
+}
+
+func (s *Service) handleDTLSCertificate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleDTLSCertificate", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if !s.cfg.RTC.DTLSCertificate.Persist {
+		data.err = "DTLS certificate is not persisted"
+		data.code = http.StatusNotFound
+		return
+	}
+
+	entry, cert, err := s.getDTLSCertificate()
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	fingerprint, err := dtlsFingerprint(cert)
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	rotation := time.Duration(s.cfg.RTC.DTLSCertificate.RotationDays) * 24 * time.Hour
+	createdAt := time.Unix(entry.CreatedAt, 0).UTC()
+
+	data.code = http.StatusOK
+	data.resData["fingerprint"] = fingerprint
+	data.resData["createdAt"] = createdAt.Format(time.RFC3339)
+	data.resData["rotatesAt"] = createdAt.Add(rotation).Format(time.RFC3339)
+	data.resData["expiresAt"] = cert.Expires().UTC().Format(time.RFC3339)
+}
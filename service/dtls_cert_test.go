@@ -0,0 +1,112 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDTLSCertificate(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.RTC.DTLSCertificate.Persist = true
+	cfg.RTC.DTLSCertificate.RotationDays = 30
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	entry, cert, err := th.srvc.getDTLSCertificate()
+	require.NoError(t, err)
+	require.Equal(t, entry.PEM, th.srvc.dtlsCertPEM)
+	require.Equal(t, cert.Expires().Unix(), th.srvc.rtcServer.GetDTLSCertificate().Expires().Unix())
+	fingerprint, err := dtlsFingerprint(cert)
+	require.NoError(t, err)
+
+	t.Run("stable", func(t *testing.T) {
+		require.NoError(t, th.srvc.updateDTLSCertificate(time.Now()))
+		reloaded, _, err := th.srvc.getDTLSCertificate()
+		require.NoError(t, err)
+		require.Equal(t, entry, reloaded)
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/rtc/dtls_certificate", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var res map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		require.Equal(t, fingerprint, res["fingerprint"])
+		createdAt := time.Unix(entry.CreatedAt, 0).UTC()
+		require.Equal(t, createdAt.Format(time.RFC3339), res["createdAt"])
+		require.Equal(t, createdAt.Add(30*24*time.Hour).Format(time.RFC3339), res["rotatesAt"])
+	})
+
+	t.Run("rotation", func(t *testing.T) {
+		now := time.Now().Add(31 * 24 * time.Hour)
+		require.NoError(t, th.srvc.updateDTLSCertificate(now))
+
+		rotated, rotatedCert, err := th.srvc.getDTLSCertificate()
+		require.NoError(t, err)
+		require.Equal(t, now.Unix(), rotated.CreatedAt)
+		require.Equal(t, rotated.PEM, th.srvc.dtlsCertPEM)
+		rotatedFingerprint, err := dtlsFingerprint(rotatedCert)
+		require.NoError(t, err)
+		require.NotEqual(t, fingerprint, rotatedFingerprint)
+	})
+
+	t.Run("not exported", func(t *testing.T) {
+		registerClient(t, th, "clientA", "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H")
+		require.NoError(t, th.srvc.store.Set("rtcd:bootstrap_token:nonce", "0"))
+
+		keys, err := th.srvc.store.Keys()
+		require.NoError(t, err)
+		require.Contains(t, keys, dtlsCertStoreKey)
+
+		dump, err := store.Export(th.srvc.store)
+		require.NoError(t, err)
+		require.Len(t, dump.Entries, 1)
+		require.Equal(t, "clientA", dump.Entries[0].Key)
+		for _, entry := range dump.Entries {
+			require.NotEqual(t, dtlsCertStoreKey, entry.Key)
+			require.False(t, strings.HasPrefix(entry.Key, "rtcd:signing_key:"))
+			require.False(t, strings.HasPrefix(entry.Key, "rtcd:bootstrap_token:"))
+			require.NotContains(t, entry.Value, "PRIVATE KEY")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		require.NoError(t, th.srvc.store.Set(dtlsCertStoreKey, `{"pem":"invalid"}`))
+		require.NoError(t, th.srvc.updateDTLSCertificate(time.Now()))
+		_, _, err := th.srvc.getDTLSCertificate()
+		require.NoError(t, err)
+	})
+}
+
+func TestDTLSCertificateNotPersisted(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.RTC.DTLSCertificate.Persist = false
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	require.Nil(t, th.srvc.rtcServer.GetDTLSCertificate())
+
+	req, err := http.NewRequest("GET", th.apiURL+"/admin/rtc/dtls_certificate", nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
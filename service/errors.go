@@ -0,0 +1,156 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mattermost/rtcd/service/rtc"
+)
+
+// ErrorCode is a stable, machine readable identifier of the reason a request
+// failed. Unlike error messages, codes are part of the API contract and can
+// be relied upon by clients to give specific feedback.
+type ErrorCode string
+
+const (
+	ErrorCodeBadRequest       ErrorCode = "BAD_REQUEST"
+	ErrorCodeAuthFailed       ErrorCode = "AUTH_FAILED"
+	ErrorCodeForbidden        ErrorCode = "FORBIDDEN"
+	ErrorCodeNotFound         ErrorCode = "NOT_FOUND"
+	ErrorCodeConflict         ErrorCode = "CONFLICT"
+	ErrorCodeTooLarge         ErrorCode = "TOO_LARGE"
+	ErrorCodeRateLimited      ErrorCode = "RATE_LIMITED"
+	ErrorCodeCallFull         ErrorCode = "CALL_FULL"
+	ErrorCodeCodecUnsupported ErrorCode = "CODEC_UNSUPPORTED"
+	ErrorCodeDraining         ErrorCode = "DRAINING"
+	ErrorCodeBusy             ErrorCode = "BUSY"
+	ErrorCodeUnavailable      ErrorCode = "UNAVAILABLE"
+	ErrorCodeInternal         ErrorCode = "INTERNAL"
+)
+
+// closeReasonCodecUnsupported is used when rejecting a session whose client
+// doesn't support the audio codec in use.
+const closeReasonCodecUnsupported = "codec_unsupported"
+
+// Error is an error reported by the server, in response to either an API
+// request or a signaling message.
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorCodeOf returns the code of the server error wrapped by err, if any.
+func ErrorCodeOf(err error) ErrorCode {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ""
+}
+
+// codedError attaches an error code to an error without altering its
+// message.
+type codedError struct {
+	code ErrorCode
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+func withErrorCode(code ErrorCode, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+// errorCode returns the code best describing err, if it's a known error.
+func errorCode(err error) ErrorCode {
+	var ce *codedError
+	switch {
+	case errors.As(err, &ce):
+		return ce.code
+	case errors.Is(err, rtc.ErrMaxParticipantsReached):
+		return ErrorCodeCallFull
+	case errors.Is(err, rtc.ErrServerDraining):
+		return ErrorCodeDraining
+	case errors.Is(err, rtc.ErrServerBusy):
+		return ErrorCodeBusy
+	case errors.Is(err, rtc.ErrICEPolicyUnsatisfiable):
+		return ErrorCodeBadRequest
+	default:
+		return ""
+	}
+}
+
+// errorCodeForStatus returns the code matching a failed HTTP response.
+func errorCodeForStatus(httpCode int) ErrorCode {
+	switch httpCode {
+	case http.StatusBadRequest:
+		return ErrorCodeBadRequest
+	case http.StatusUnauthorized:
+		return ErrorCodeAuthFailed
+	case http.StatusForbidden:
+		return ErrorCodeForbidden
+	case http.StatusNotFound:
+		return ErrorCodeNotFound
+	case http.StatusConflict, http.StatusUnprocessableEntity:
+		return ErrorCodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return ErrorCodeTooLarge
+	case http.StatusTooManyRequests:
+		return ErrorCodeRateLimited
+	case http.StatusServiceUnavailable:
+		return ErrorCodeUnavailable
+	default:
+		return ErrorCodeInternal
+	}
+}
+
+// errorCodeForCloseReason returns the code matching the reason a session was
+// closed with, if it was closed because of an error.
+func errorCodeForCloseReason(reason string) ErrorCode {
+	switch reason {
+	case rtc.CloseReasonMaxParticipants:
+		return ErrorCodeCallFull
+	case rtc.CloseReasonShutdown:
+		return ErrorCodeDraining
+	case rtc.CloseReasonBusy:
+		return ErrorCodeBusy
+	case rtc.CloseReasonInternalError:
+		return ErrorCodeInternal
+	case closeReasonCodecUnsupported:
+		return ErrorCodeCodecUnsupported
+	default:
+		return ""
+	}
+}
+
+// responseError returns the error reported by a failed API response.
+func responseError(resp *http.Response, respData map[string]string) error {
+	code := ErrorCode(respData["errorCode"])
+	if code == "" {
+		// Servers predating error codes.
+		code = errorCodeForStatus(resp.StatusCode)
+	}
+	if errMsg := respData["error"]; errMsg != "" {
+		return fmt.Errorf("request failed: %w", &Error{Code: code, Message: errMsg})
+	}
+	return &Error{Code: code, Message: fmt.Sprintf("request failed with status %s", resp.Status)}
+}
+
+// newBadMessageError returns an error about a malformed client message.
+func newBadMessageError(format string, args ...interface{}) error {
+	return withErrorCode(ErrorCodeBadRequest, fmt.Errorf(format, args...))
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCode(t *testing.T) {
+	t.Run("coded", func(t *testing.T) {
+		err := fmt.Errorf("failed: %w", withErrorCode(ErrorCodeBadRequest, errors.New("missing callID")))
+		require.EqualError(t, err, "failed: missing callID")
+		require.Equal(t, ErrorCodeBadRequest, errorCode(err))
+	})
+
+	t.Run("rtc errors", func(t *testing.T) {
+		require.Equal(t, ErrorCodeCallFull, errorCode(fmt.Errorf("failed: %w", rtc.ErrMaxParticipantsReached)))
+		require.Equal(t, ErrorCodeDraining, errorCode(fmt.Errorf("failed: %w", rtc.ErrServerDraining)))
+		require.Equal(t, ErrorCodeBusy, errorCode(fmt.Errorf("failed: %w", rtc.ErrServerBusy)))
+		require.Equal(t, ErrorCodeBadRequest, errorCode(fmt.Errorf("failed: %w", rtc.ErrICEPolicyUnsatisfiable)))
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		require.Empty(t, errorCode(errors.New("failed")))
+	})
+}
+
+func TestErrorCodeForStatus(t *testing.T) {
+	require.Equal(t, ErrorCodeBadRequest, errorCodeForStatus(http.StatusBadRequest))
+	require.Equal(t, ErrorCodeAuthFailed, errorCodeForStatus(http.StatusUnauthorized))
+	require.Equal(t, ErrorCodeConflict, errorCodeForStatus(http.StatusUnprocessableEntity))
+	require.Equal(t, ErrorCodeRateLimited, errorCodeForStatus(http.StatusTooManyRequests))
+	require.Equal(t, ErrorCodeInternal, errorCodeForStatus(http.StatusBadGateway))
+}
+
+func TestResponseError(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusBadRequest,
+		Status:     "400 Bad Request",
+	}
+
+	t.Run("error code", func(t *testing.T) {
+		err := responseError(resp, map[string]string{"error": "call is full", "errorCode": string(ErrorCodeCallFull)})
+		require.EqualError(t, err, "request failed: call is full")
+		require.Equal(t, ErrorCodeCallFull, ErrorCodeOf(err))
+	})
+
+	t.Run("legacy server", func(t *testing.T) {
+		err := responseError(resp, map[string]string{"error": "invalid request"})
+		require.EqualError(t, err, "request failed: invalid request")
+		require.Equal(t, ErrorCodeBadRequest, ErrorCodeOf(err))
+
+		err = responseError(resp, map[string]string{})
+		require.EqualError(t, err, "request failed with status 400 Bad Request")
+		require.Equal(t, ErrorCodeBadRequest, ErrorCodeOf(err))
+	})
+
+	t.Run("not a server error", func(t *testing.T) {
+		require.Empty(t, ErrorCodeOf(errors.New("failed")))
+	})
+}
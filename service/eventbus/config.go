@@ -0,0 +1,78 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package eventbus
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/mattermost/rtcd/service/broker"
+)
+
+const (
+	BackendNATS  = broker.NATS
+	BackendKafka = broker.Kafka
+)
+
+type Config struct {
+	// Backend is the message broker events are published to ("nats" or
+	// "kafka"). Publishing is disabled if empty.
+	Backend string `toml:"backend"`
+	// Address is the TCP address (host:port) of the NATS server, or of the
+	// Kafka bootstrap broker.
+	Address string `toml:"address"`
+	// Topic is the Kafka topic events are published to. With NATS, it's the
+	// prefix of the subjects, the event type being appended to it (e.g.
+	// rtcd.events.call_started).
+	Topic string `toml:"topic"`
+	// Username is the optional username to authenticate with (SASL/PLAIN
+	// with Kafka).
+	Username string `toml:"username"`
+	// Password is the optional password to authenticate with. With NATS, it's
+	// sent as a token if no username is set.
+	Password string `toml:"password"`
+	// Types is the list of the event types published. All events are
+	// published if empty.
+	Types []string `toml:"types"`
+	// TimeoutSeconds is the timeout applied to connecting and publishing.
+	TimeoutSeconds int `toml:"timeout_seconds"`
+}
+
+func (c Config) IsEnabled() bool {
+	return c.Backend != ""
+}
+
+func (c Config) IsValid() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+
+	if c.Backend != BackendNATS && c.Backend != BackendKafka {
+		return fmt.Errorf("invalid Backend value: should be either %q or %q", BackendNATS, BackendKafka)
+	}
+
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return fmt.Errorf("invalid Address value: %w", err)
+	}
+
+	if c.Topic == "" {
+		return fmt.Errorf("invalid Topic value: should not be empty")
+	}
+	if strings.ContainsAny(c.Topic, " \t\r\n") {
+		return fmt.Errorf("invalid Topic value: should not contain whitespace")
+	}
+
+	for _, typ := range c.Types {
+		if typ == "" || strings.ContainsAny(typ, " \t\r\n.") {
+			return fmt.Errorf("invalid Types value: %q is not a valid event type", typ)
+		}
+	}
+
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("invalid TimeoutSeconds value: should be a positive number")
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package eventbus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg Config
+		require.False(t, cfg.IsEnabled())
+		require.NoError(t, cfg.IsValid())
+	})
+
+	validCfg := Config{
+		Backend:        BackendKafka,
+		Address:        "localhost:9092",
+		Topic:          "rtcd.events",
+		Types:          []string{"call_started", "call_ended"},
+		TimeoutSeconds: 5,
+	}
+
+	t.Run("invalid Backend", func(t *testing.T) {
+		cfg := validCfg
+		cfg.Backend = "redis"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid Backend value: should be either "nats" or "kafka"`, err.Error())
+	})
+
+	t.Run("invalid Address", func(t *testing.T) {
+		cfg := validCfg
+		cfg.Address = "localhost"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Address value: address localhost: missing port in address", err.Error())
+	})
+
+	t.Run("invalid Topic", func(t *testing.T) {
+		cfg := validCfg
+		cfg.Topic = ""
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Topic value: should not be empty", err.Error())
+
+		cfg.Topic = "rtcd events"
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Topic value: should not contain whitespace", err.Error())
+	})
+
+	t.Run("invalid Types", func(t *testing.T) {
+		cfg := validCfg
+		cfg.Types = []string{"call_started", ""}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid Types value: "" is not a valid event type`, err.Error())
+
+		cfg.Types = []string{"call.started"}
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid Types value: "call.started" is not a valid event type`, err.Error())
+	})
+
+	t.Run("invalid TimeoutSeconds", func(t *testing.T) {
+		cfg := validCfg
+		cfg.TimeoutSeconds = 0
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TimeoutSeconds value: should be a positive number", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		require.True(t, validCfg.IsEnabled())
+		require.NoError(t, validCfg.IsValid())
+
+		cfg := validCfg
+		cfg.Backend = BackendNATS
+		cfg.Types = nil
+		require.NoError(t, cfg.IsValid())
+	})
+}
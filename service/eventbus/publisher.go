@@ -0,0 +1,170 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package eventbus publishes the call and session lifecycle events to a
+// message broker (NATS or Kafka), for consumers that can't keep up with
+// webhook deliveries.
+package eventbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/service/broker"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const queueSize = 4096
+
+type message struct {
+	topic string
+	key   string
+	data  []byte
+}
+
+// Publisher publishes events asynchronously, in the order they are sent.
+// Connections are established lazily and re-established after failures.
+// Events failing to publish are dropped.
+type Publisher struct {
+	cfg     Config
+	log     mlog.LoggerIFace
+	types   map[rtc.EventType]bool
+	conn    broker.Conn
+	queueCh chan message
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewPublisher(cfg Config, log mlog.LoggerIFace) (*Publisher, error) {
+	if err := cfg.IsValid(); err != nil {
+		return nil, fmt.Errorf("failed to validate config: %w", err)
+	}
+	if log == nil {
+		return nil, fmt.Errorf("log should not be nil")
+	}
+
+	p := &Publisher{
+		cfg:     cfg,
+		log:     log,
+		queueCh: make(chan message, queueSize),
+		closeCh: make(chan struct{}),
+	}
+	if len(cfg.Types) > 0 {
+		p.types = make(map[rtc.EventType]bool, len(cfg.Types))
+		for _, typ := range cfg.Types {
+			p.types[rtc.EventType(typ)] = true
+		}
+	}
+
+	p.wg.Add(1)
+	go p.worker()
+
+	return p, nil
+}
+
+// Send queues an event for publishing, unless its type is filtered out. It
+// does not block and returns an error if the queue is full.
+//
+// With NATS, the event is published to the subject made of the configured
+// topic and of the event type. With Kafka, it's keyed by call ID so that the
+// events of a call keep their order.
+func (p *Publisher) Send(ev rtc.Event) error {
+	if p.types != nil && !p.types[ev.Type] {
+		return nil
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	msg := message{topic: p.cfg.Topic, data: data}
+	if p.cfg.Backend == BackendNATS {
+		msg.topic += "." + string(ev.Type)
+	} else {
+		msg.key = ev.CallID
+	}
+
+	select {
+	case p.queueCh <- msg:
+	default:
+		return fmt.Errorf("queue is full")
+	}
+
+	return nil
+}
+
+// Close stops the publisher, flushing the queued events first unless
+// publishing fails, and closes the connection.
+func (p *Publisher) Close() {
+	close(p.closeCh)
+	p.wg.Wait()
+
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+func (p *Publisher) worker() {
+	defer p.wg.Done()
+	for {
+		select {
+		case msg := <-p.queueCh:
+			if err := p.publish(msg); err != nil {
+				p.log.Error("failed to publish event", mlog.Err(err), mlog.String("topic", msg.topic))
+			}
+		case <-p.closeCh:
+			for {
+				select {
+				case msg := <-p.queueCh:
+					if err := p.publish(msg); err != nil {
+						p.log.Error("failed to flush events", mlog.Err(err), mlog.Int("dropped", len(p.queueCh)+1))
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// publish sends a message, retrying once on a new connection if an
+// established one failed, as the broker may have closed it since the last
+// event. Only called by the worker.
+func (p *Publisher) publish(msg message) error {
+	reused := p.conn != nil
+	err := p.tryPublish(msg)
+	if err != nil && reused {
+		err = p.tryPublish(msg)
+	}
+	return err
+}
+
+func (p *Publisher) tryPublish(msg message) error {
+	if p.conn == nil {
+		var err error
+		p.conn, err = broker.Dial(p.cfg.Backend, broker.Options{
+			Address:  p.cfg.Address,
+			Username: p.cfg.Username,
+			Password: p.cfg.Password,
+			Timeout:  time.Duration(p.cfg.TimeoutSeconds) * time.Second,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to connect to %s server: %w", p.cfg.Backend, err)
+		}
+	}
+
+	if err := p.conn.Publish(msg.topic, msg.key, msg.data); err != nil {
+		p.conn.Close()
+		p.conn = nil
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package eventbus
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/broker/brokertest"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		err := log.Shutdown()
+		require.NoError(t, err)
+	}()
+
+	receive := func(t *testing.T, msgCh <-chan brokertest.Message) (brokertest.Message, rtc.Event) {
+		t.Helper()
+		select {
+		case msg := <-msgCh:
+			var ev rtc.Event
+			require.NoError(t, json.Unmarshal([]byte(msg.Data), &ev))
+			return msg, ev
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for event")
+			return brokertest.Message{}, rtc.Event{}
+		}
+	}
+
+	t.Run("invalid config", func(t *testing.T) {
+		p, err := NewPublisher(Config{Backend: BackendKafka}, log)
+		require.Error(t, err)
+		require.Nil(t, p)
+	})
+
+	t.Run("nats", func(t *testing.T) {
+		s, err := brokertest.NewNATSServer("", "")
+		require.NoError(t, err)
+		defer s.Close()
+		p, err := NewPublisher(Config{
+			Backend:        BackendNATS,
+			Address:        s.Addr(),
+			Topic:          "rtcd.events",
+			Types:          []string{"call_started", "session_joined"},
+			TimeoutSeconds: 5,
+		}, log)
+		require.NoError(t, err)
+		defer p.Close()
+
+		require.NoError(t, p.Send(rtc.Event{Type: rtc.CallStartedEvent, CallID: "callA"}))
+		// Filtered out.
+		require.NoError(t, p.Send(rtc.Event{Type: rtc.StreamQualityChangedEvent, CallID: "callA"}))
+		require.NoError(t, p.Send(rtc.Event{Type: rtc.SessionJoinedEvent, CallID: "callA", SessionID: "sessionA"}))
+
+		msg, ev := receive(t, s.Messages())
+		require.Equal(t, "rtcd.events.call_started", msg.Topic)
+		require.Equal(t, rtc.CallStartedEvent, ev.Type)
+		require.Equal(t, "callA", ev.CallID)
+
+		msg, ev = receive(t, s.Messages())
+		require.Equal(t, "rtcd.events.session_joined", msg.Topic)
+		require.Equal(t, "sessionA", ev.SessionID)
+	})
+
+	t.Run("kafka", func(t *testing.T) {
+		s, err := brokertest.NewKafkaServer("user", "pass", 4)
+		require.NoError(t, err)
+		defer s.Close()
+		p, err := NewPublisher(Config{
+			Backend:        BackendKafka,
+			Address:        s.Addr(),
+			Topic:          "rtcd.events",
+			Username:       "user",
+			Password:       "pass",
+			TimeoutSeconds: 5,
+		}, log)
+		require.NoError(t, err)
+		defer p.Close()
+
+		require.NoError(t, p.Send(rtc.Event{Type: rtc.CallStartedEvent, CallID: "callA"}))
+		require.NoError(t, p.Send(rtc.Event{Type: rtc.StreamQualityChangedEvent, CallID: "callA"}))
+
+		for _, typ := range []rtc.EventType{rtc.CallStartedEvent, rtc.StreamQualityChangedEvent} {
+			msg, ev := receive(t, s.Messages())
+			require.Equal(t, "rtcd.events", msg.Topic)
+			require.Equal(t, "callA", msg.Key)
+			require.Equal(t, typ, ev.Type)
+		}
+	})
+
+	t.Run("reconnect", func(t *testing.T) {
+		s, err := brokertest.NewNATSServer("", "")
+		require.NoError(t, err)
+		defer s.Close()
+		p, err := NewPublisher(Config{
+			Backend:        BackendNATS,
+			Address:        s.Addr(),
+			Topic:          "rtcd.events",
+			TimeoutSeconds: 5,
+		}, log)
+		require.NoError(t, err)
+		defer p.Close()
+
+		require.NoError(t, p.Send(rtc.Event{Type: rtc.CallStartedEvent, CallID: "callA"}))
+		receive(t, s.Messages())
+
+		// Events sent after the connection dropped are published on a new one.
+		s.DropConns()
+		require.NoError(t, p.Send(rtc.Event{Type: rtc.CallEndedEvent, CallID: "callA"}))
+		_, ev := receive(t, s.Messages())
+		require.Equal(t, rtc.CallEndedEvent, ev.Type)
+		require.Equal(t, 2, s.Connects())
+	})
+
+	t.Run("close flushes", func(t *testing.T) {
+		s, err := brokertest.NewNATSServer("", "")
+		require.NoError(t, err)
+		defer s.Close()
+		p, err := NewPublisher(Config{
+			Backend:        BackendNATS,
+			Address:        s.Addr(),
+			Topic:          "rtcd.events",
+			TimeoutSeconds: 5,
+		}, log)
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			require.NoError(t, p.Send(rtc.Event{Type: rtc.SessionJoinedEvent, CallID: "callA"}))
+		}
+		p.Close()
+		for i := 0; i < 10; i++ {
+			receive(t, s.Messages())
+		}
+	})
+}
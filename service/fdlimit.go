@@ -0,0 +1,92 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mattermost/rtcd/service/perf"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// openFilesCheckInterval is how often the number of open file descriptors is
+// compared against the limit.
+const openFilesCheckInterval = 10 * time.Second
+
+// setupOpenFilesLimit raises the open files limit to the configured one and
+// exports the resulting limit. It returns zero if the limit is unknown.
+func (s *Service) setupOpenFilesLimit() uint64 {
+	var limit uint64
+	var err error
+	target := s.cfg.Process.OpenFilesLimit
+	if target > 0 {
+		limit, err = perf.RaiseOpenFilesLimit(uint64(target))
+	} else {
+		limit, _, err = perf.GetOpenFilesLimit()
+	}
+	if errors.Is(err, perf.ErrOpenFilesLimitUnsupported) {
+		return 0
+	} else if err != nil {
+		s.log.Error("failed to set open files limit", mlog.Err(err), mlog.Int("target", target))
+	}
+	if limit == 0 {
+		return 0
+	}
+
+	if limit < uint64(target) {
+		s.log.Warn("open files limit is below the configured one, raising the hard limit requires privileges",
+			mlog.Uint64("limit", limit), mlog.Int("target", target))
+	} else {
+		s.log.Info("open files limit", mlog.Uint64("limit", limit))
+	}
+	s.metrics.SetOpenFilesLimit(limit)
+
+	return limit
+}
+
+// runOpenFilesCheck periodically checks whether the number of open file
+// descriptors is nearing limit.
+func (s *Service) runOpenFilesCheck(limit uint64, interval time.Duration) {
+	defer close(s.openFilesDoneCh)
+
+	threshold := limit * uint64(s.cfg.Process.OpenFilesWarnPercent) / 100
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var exceeded bool
+	for {
+		select {
+		case <-ticker.C:
+			exceeded = s.checkOpenFiles(limit, threshold, exceeded)
+		case <-s.openFilesStopCh:
+			return
+		}
+	}
+}
+
+// checkOpenFiles logs a warning when the number of open file descriptors
+// crosses threshold, and when it goes back below it. It returns whether the
+// threshold is exceeded.
+func (s *Service) checkOpenFiles(limit, threshold uint64, exceeded bool) bool {
+	fds, err := perf.CountOpenFDs()
+	if err != nil {
+		return exceeded
+	}
+
+	if uint64(fds) >= threshold {
+		if !exceeded {
+			s.log.Warn("open files are nearing the limit, new sessions may fail",
+				mlog.Int("fds", fds), mlog.Uint64("limit", limit))
+		}
+		return true
+	}
+
+	if exceeded {
+		s.log.Info("open files are back below the warning threshold",
+			mlog.Int("fds", fds), mlog.Uint64("limit", limit))
+	}
+	return false
+}
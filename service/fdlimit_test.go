@@ -0,0 +1,69 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/mattermost/rtcd/service/perf"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenFilesLimit(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		err := log.Shutdown()
+		require.NoError(t, err)
+	}()
+	var buf bytes.Buffer
+	err = mlog.AddWriterTarget(log, &buf, true, mlog.LvlInfo, mlog.LvlWarn)
+	require.NoError(t, err)
+
+	s := &Service{
+		log:     log,
+		metrics: perf.NewMetrics("rtcd", prometheus.NewRegistry()),
+	}
+
+	t.Run("setup", func(t *testing.T) {
+		soft, _, err := perf.GetOpenFilesLimit()
+		if err != nil {
+			t.Skip(err.Error())
+		}
+
+		// The limit is never lowered.
+		s.cfg.Process.OpenFilesLimit = 64
+		require.Equal(t, soft, s.setupOpenFilesLimit())
+
+		s.cfg.Process.OpenFilesLimit = 0
+		require.Equal(t, soft, s.setupOpenFilesLimit())
+	})
+
+	t.Run("check", func(t *testing.T) {
+		if _, err := perf.CountOpenFDs(); err != nil {
+			t.Skip(err.Error())
+		}
+
+		require.NoError(t, log.Flush())
+		buf.Reset()
+		exceeded := s.checkOpenFiles(1024, 1, false)
+		require.True(t, exceeded)
+		exceeded = s.checkOpenFiles(1024, 1, exceeded)
+		require.True(t, exceeded)
+		exceeded = s.checkOpenFiles(1024, math.MaxUint64, exceeded)
+		require.False(t, exceeded)
+		exceeded = s.checkOpenFiles(1024, math.MaxUint64, exceeded)
+		require.False(t, exceeded)
+
+		require.NoError(t, log.Flush())
+		require.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("open files are nearing the limit")))
+		require.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("open files are back below the warning threshold")))
+		require.Contains(t, buf.String(), `"limit":1024`)
+	})
+}
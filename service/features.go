@@ -0,0 +1,238 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/store"
+)
+
+// featureFlagsStoreKey is the store key under which the feature flags
+// overridden through the admin API are persisted.
+const featureFlagsStoreKey = "rtcd:feature_flags"
+
+func isFeature(name string) bool {
+	for _, feature := range rtc.Features() {
+		if feature == name {
+			return true
+		}
+	}
+	return false
+}
+
+// featureDefault returns the state of the given feature as set by its own
+// config setting.
+func featureDefault(cfg Config, name string) bool {
+	switch name {
+	case rtc.FeatureSessionMigration:
+		return cfg.RTC.EnableSessionMigration
+	case rtc.FeatureEgressShaping:
+		return cfg.RTC.EgressShaping.Enable
+	}
+	return false
+}
+
+// checkFeatureRequirements returns an error if the given feature can't be
+// enabled with the given config.
+func checkFeatureRequirements(cfg Config, name string) error {
+	if name == rtc.FeatureEgressShaping {
+		shaping := cfg.RTC.EgressShaping
+		shaping.Enable = true
+		if err := shaping.IsValid(); err != nil {
+			return fmt.Errorf("%s requires a valid EgressShaping config: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// featureOverrides holds the feature flags overridden through the admin API.
+type featureOverrides struct {
+	// Deployment maps features to their state for all the clients.
+	Deployment map[string]bool `json:"deployment,omitempty"`
+	// Clients maps client IDs to the state of the features overridden for
+	// them.
+	Clients map[string]map[string]bool `json:"clients,omitempty"`
+}
+
+// featureFlags resolves the state of the features gated behind flags. The
+// overrides for a client take precedence over the ones for the deployment,
+// which take precedence over the features config, the settings of the
+// features applying last.
+type featureFlags struct {
+	cfg       FeaturesConfig
+	overrides featureOverrides
+	mut       sync.RWMutex
+	// updateMut serializes the updates of the overrides.
+	updateMut sync.Mutex
+}
+
+func newFeatureFlags(cfg FeaturesConfig) *featureFlags {
+	return &featureFlags{
+		cfg: cfg,
+	}
+}
+
+// FeatureEnabled implements rtc.FeatureFlags.
+func (f *featureFlags) FeatureEnabled(name, clientID string) (bool, bool) {
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+
+	if enabled, ok := f.overrides.Clients[clientID][name]; ok && clientID != "" {
+		return enabled, true
+	}
+	if enabled, ok := f.overrides.Deployment[name]; ok {
+		return enabled, true
+	}
+	for _, feature := range f.cfg.Enabled {
+		if feature == name {
+			return true, true
+		}
+	}
+	for _, feature := range f.cfg.Disabled {
+		if feature == name {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// getOverrides returns a copy of the overrides.
+func (f *featureFlags) getOverrides() featureOverrides {
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+
+	overrides := featureOverrides{
+		Deployment: make(map[string]bool, len(f.overrides.Deployment)),
+		Clients:    make(map[string]map[string]bool, len(f.overrides.Clients)),
+	}
+	for name, enabled := range f.overrides.Deployment {
+		overrides.Deployment[name] = enabled
+	}
+	for clientID, flags := range f.overrides.Clients {
+		overrides.Clients[clientID] = make(map[string]bool, len(flags))
+		for name, enabled := range flags {
+			overrides.Clients[clientID][name] = enabled
+		}
+	}
+	return overrides
+}
+
+func (f *featureFlags) setOverrides(overrides featureOverrides) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.overrides = overrides
+}
+
+// update applies the flags in data, mapping features to "true" or "false",
+// or to an empty string to remove the override, for the given client or the
+// deployment if empty.
+func (o *featureOverrides) update(clientID string, data map[string]string) error {
+	flags := o.Deployment
+	if clientID != "" {
+		flags = o.Clients[clientID]
+	}
+	if flags == nil {
+		flags = map[string]bool{}
+	}
+
+	for name, value := range data {
+		if !isFeature(name) {
+			return fmt.Errorf("unknown feature %q", name)
+		}
+		if value == "" {
+			delete(flags, name)
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value: %w", name, err)
+		}
+		flags[name] = enabled
+	}
+
+	if clientID == "" {
+		o.Deployment = flags
+	} else if len(flags) == 0 {
+		delete(o.Clients, clientID)
+	} else {
+		if o.Clients == nil {
+			o.Clients = map[string]map[string]bool{}
+		}
+		o.Clients[clientID] = flags
+	}
+
+	return nil
+}
+
+// getFeatures returns the state of all the features for the given client,
+// or the deployment if empty.
+func (s *Service) getFeatures(clientID string) map[string]bool {
+	features := map[string]bool{}
+	for _, name := range rtc.Features() {
+		enabled, ok := s.features.FeatureEnabled(name, clientID)
+		if !ok {
+			enabled = featureDefault(s.cfg, name)
+		}
+		features[name] = enabled
+	}
+	return features
+}
+
+// updateFeatureFlags applies the flags in data, as documented by
+// featureOverrides.update, for the given client or the deployment if empty,
+// and persists them. Flags are consulted as sessions join and calls start.
+func (s *Service) updateFeatureFlags(clientID string, data map[string]string) error {
+	s.features.updateMut.Lock()
+	defer s.features.updateMut.Unlock()
+
+	overrides := s.features.getOverrides()
+	if err := overrides.update(clientID, data); err != nil {
+		return err
+	}
+
+	for name, value := range data {
+		if enabled, _ := strconv.ParseBool(value); enabled {
+			if err := checkFeatureRequirements(s.cfg, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	js, err := json.Marshal(overrides)
+	if err != nil {
+		return fmt.Errorf("failed to marshal feature flags: %w", err)
+	}
+	if err := s.store.Set(featureFlagsStoreKey, string(js)); err != nil {
+		return fmt.Errorf("failed to store feature flags: %w", err)
+	}
+
+	s.features.setOverrides(overrides)
+
+	return nil
+}
+
+// loadFeatureFlags applies the feature flags overrides persisted in the
+// store, if any.
+func (s *Service) loadFeatureFlags() error {
+	data, err := s.store.Get(featureFlagsStoreKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get feature flags: %w", err)
+	}
+
+	var overrides featureOverrides
+	if err := json.Unmarshal([]byte(data), &overrides); err != nil {
+		return fmt.Errorf("failed to unmarshal feature flags: %w", err)
+	}
+	s.features.setOverrides(overrides)
+
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	f := newFeatureFlags(FeaturesConfig{
+		Enabled:  []string{rtc.FeatureSessionMigration},
+		Disabled: []string{rtc.FeatureEgressShaping},
+	})
+
+	t.Run("config", func(t *testing.T) {
+		enabled, ok := f.FeatureEnabled(rtc.FeatureSessionMigration, "clientA")
+		require.True(t, ok)
+		require.True(t, enabled)
+		enabled, ok = f.FeatureEnabled(rtc.FeatureEgressShaping, "clientA")
+		require.True(t, ok)
+		require.False(t, enabled)
+		_, ok = newFeatureFlags(FeaturesConfig{}).FeatureEnabled(rtc.FeatureEgressShaping, "clientA")
+		require.False(t, ok)
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		overrides := f.getOverrides()
+		require.NoError(t, overrides.update("", map[string]string{rtc.FeatureEgressShaping: "true"}))
+		require.NoError(t, overrides.update("clientA", map[string]string{rtc.FeatureEgressShaping: "false", rtc.FeatureSessionMigration: "false"}))
+		f.setOverrides(overrides)
+
+		// Client overrides take precedence over the deployment ones.
+		enabled, _ := f.FeatureEnabled(rtc.FeatureEgressShaping, "clientA")
+		require.False(t, enabled)
+		enabled, _ = f.FeatureEnabled(rtc.FeatureEgressShaping, "clientB")
+		require.True(t, enabled)
+		enabled, _ = f.FeatureEnabled(rtc.FeatureSessionMigration, "clientA")
+		require.False(t, enabled)
+		enabled, _ = f.FeatureEnabled(rtc.FeatureSessionMigration, "clientB")
+		require.True(t, enabled)
+
+		// Removing the overrides of a client forgets about it.
+		overrides = f.getOverrides()
+		require.NoError(t, overrides.update("clientA", map[string]string{rtc.FeatureEgressShaping: "", rtc.FeatureSessionMigration: ""}))
+		require.Empty(t, overrides.Clients)
+		require.Equal(t, map[string]bool{rtc.FeatureEgressShaping: true}, overrides.Deployment)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var overrides featureOverrides
+		require.EqualError(t, overrides.update("", map[string]string{"av1": "true"}), `unknown feature "av1"`)
+		require.Error(t, overrides.update("", map[string]string{rtc.FeatureEgressShaping: "maybe"}))
+	})
+}
+
+func TestFeaturesHandler(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	doRequest := func(t *testing.T, method, query, body string) (int, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest(method, th.apiURL+"/admin/features"+query, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp.StatusCode, response
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/features", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("get defaults", func(t *testing.T) {
+		code, response := doRequest(t, "GET", "", "")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "false", response[rtc.FeatureSessionMigration])
+		require.Equal(t, "false", response[rtc.FeatureEgressShaping])
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		code, response := doRequest(t, "POST", "", `{"av1": "true"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, `unknown feature "av1"`, response["error"])
+
+		// The egress shaping settings aren't set.
+		code, response = doRequest(t, "POST", "", `{"egress_shaping": "true"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "egress_shaping requires a valid EgressShaping config: invalid RateKbps value: should be a positive number", response["error"])
+	})
+
+	t.Run("set", func(t *testing.T) {
+		code, response := doRequest(t, "POST", "", `{"session_migration": "true"}`)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "true", response[rtc.FeatureSessionMigration])
+
+		code, response = doRequest(t, "POST", "?clientID=clientA", `{"session_migration": "false"}`)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "false", response[rtc.FeatureSessionMigration])
+
+		code, response = doRequest(t, "GET", "?clientID=clientB", "")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "true", response[rtc.FeatureSessionMigration])
+	})
+
+	t.Run("persisted across restarts", func(t *testing.T) {
+		err := th.srvc.Stop()
+		require.NoError(t, err)
+
+		th.srvc, err = New(*cfg)
+		require.NoError(t, err)
+		err = th.srvc.Start()
+		require.NoError(t, err)
+
+		require.Equal(t, map[string]bool{rtc.FeatureSessionMigration: true, rtc.FeatureEgressShaping: false}, th.srvc.getFeatures(""))
+		require.Equal(t, map[string]bool{rtc.FeatureSessionMigration: false, rtc.FeatureEgressShaping: false}, th.srvc.getFeatures("clientA"))
+	})
+}
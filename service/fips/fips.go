@@ -0,0 +1,83 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package fips implements the FIPS mode, restricting the cryptographic
+// algorithms negotiated by the transports to FIPS-approved ones.
+package fips
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// Names of the cryptographic modules the process can be backed by.
+const (
+	// ModuleGo is the regular Go cryptographic library.
+	ModuleGo = "go"
+	// ModuleBoringCrypto is the BoringCrypto module of the binaries built
+	// with GOEXPERIMENT=boringcrypto.
+	ModuleBoringCrypto = "boringcrypto"
+	// ModuleFIPS140 is the Go Cryptographic Module, when running with
+	// GODEBUG=fips140=on (or only).
+	ModuleFIPS140 = "fips140"
+)
+
+type Config struct {
+	// Enable controls whether TLS, DTLS and SRTP should be restricted to
+	// FIPS-approved algorithms.
+	Enable bool `toml:"enable"`
+	// RequireValidatedModule controls whether the service should refuse to
+	// start unless backed by a FIPS validated cryptographic module, either
+	// BoringCrypto or the Go Cryptographic Module.
+	RequireValidatedModule bool `toml:"require_validated_module"`
+}
+
+func (c Config) IsValid() error {
+	if c.RequireValidatedModule && !c.Enable {
+		return fmt.Errorf("invalid RequireValidatedModule value: FIPS mode should be enabled")
+	}
+	return nil
+}
+
+// CheckModule returns an error if a validated module is required but the
+// process isn't backed by one.
+func (c Config) CheckModule() error {
+	if c.RequireValidatedModule && Module() == ModuleGo {
+		return fmt.Errorf("no FIPS validated cryptographic module: build with GOEXPERIMENT=boringcrypto or run with GODEBUG=fips140=on")
+	}
+	return nil
+}
+
+// Module returns the cryptographic module the process is backed by.
+func Module() string {
+	if boringCryptoEnabled() {
+		return ModuleBoringCrypto
+	}
+	if fips140Enabled() {
+		return ModuleFIPS140
+	}
+	return ModuleGo
+}
+
+// TLSCipherSuites are the cipher suites allowed in FIPS mode.
+var TLSCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// TLSCurves are the key exchange curves allowed in FIPS mode.
+var TLSCurves = []tls.CurveID{
+	tls.CurveP256,
+	tls.CurveP384,
+}
+
+// RestrictTLSConfig restricts cfg to the FIPS-approved cipher suites and
+// curves. TLS 1.3 is disabled as its cipher suites can't be configured.
+func RestrictTLSConfig(cfg *tls.Config) {
+	cfg.MinVersion = tls.VersionTLS12
+	cfg.MaxVersion = tls.VersionTLS12
+	cfg.CipherSuites = TLSCipherSuites
+	cfg.CurvePreferences = TLSCurves
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package fips
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigIsValid(t *testing.T) {
+	require.NoError(t, Config{}.IsValid())
+	require.NoError(t, Config{Enable: true}.IsValid())
+	require.NoError(t, Config{Enable: true, RequireValidatedModule: true}.IsValid())
+	require.EqualError(t, Config{RequireValidatedModule: true}.IsValid(),
+		"invalid RequireValidatedModule value: FIPS mode should be enabled")
+}
+
+func TestCheckModule(t *testing.T) {
+	require.NoError(t, Config{Enable: true}.CheckModule())
+
+	err := Config{Enable: true, RequireValidatedModule: true}.CheckModule()
+	if Module() == ModuleGo {
+		require.Error(t, err)
+	} else {
+		require.NoError(t, err)
+	}
+}
+
+func TestRestrictTLSConfig(t *testing.T) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS10,
+	}
+	RestrictTLSConfig(cfg)
+	require.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	require.Equal(t, uint16(tls.VersionTLS12), cfg.MaxVersion)
+	require.Equal(t, TLSCipherSuites, cfg.CipherSuites)
+	require.Equal(t, TLSCurves, cfg.CurvePreferences)
+
+	for _, id := range cfg.CipherSuites {
+		for _, suite := range tls.InsecureCipherSuites() {
+			require.NotEqual(t, suite.ID, id)
+		}
+	}
+}
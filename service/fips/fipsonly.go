@@ -0,0 +1,10 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build boringcrypto && fipsonly
+
+package fips
+
+// Restricting all the TLS configurations of the process, including the ones
+// of the outgoing connections, to FIPS-approved settings.
+import _ "crypto/tls/fipsonly"
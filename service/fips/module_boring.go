@@ -0,0 +1,12 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build boringcrypto
+
+package fips
+
+import "crypto/boring"
+
+func boringCryptoEnabled() bool {
+	return boring.Enabled()
+}
@@ -0,0 +1,12 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build go1.24
+
+package fips
+
+import "crypto/fips140"
+
+func fips140Enabled() bool {
+	return fips140.Enabled()
+}
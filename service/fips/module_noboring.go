@@ -0,0 +1,10 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build !boringcrypto
+
+package fips
+
+func boringCryptoEnabled() bool {
+	return false
+}
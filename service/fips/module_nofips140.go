@@ -0,0 +1,10 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build !go1.24
+
+package fips
+
+func fips140Enabled() bool {
+	return false
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"fmt"
+
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/signaling"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// A connection always acts on behalf of the client it authenticated as.
+// Additional groups can be multiplexed over the same connection by
+// authenticating them through a group_auth message, after which messages
+// carrying a groupID field are routed to the given group.
+
+// handleGroupAuth authenticates the group in data and, on success, allows
+// the connection to act on its behalf. The outcome is reported back to the
+// client through a group_auth reply.
+func (s *Service) handleGroupAuth(connID, clientID string, data map[string]string) error {
+	groupAuth, err := signaling.ParseGroupAuthData(data)
+	if err != nil {
+		return newBadMessageError("%s in client message", err)
+	}
+	groupID := groupAuth.GroupID
+
+	replyData := signaling.GroupAuthData{
+		GroupID: groupID,
+	}
+
+	var authErr error
+	authErrCode := ErrorCodeRateLimited
+	// Failures are tracked per authenticating client so that a client can't
+	// lock a group out from the others.
+	lockoutSubjects := groupAuthSubjects(groupID, clientID)
+	if _, authErr = s.checkLockout(lockoutSubjects); authErr == nil {
+		authErrCode = ErrorCodeAuthFailed
+		authErr = s.authenticateGroup(groupAuth)
+		if authErr != nil {
+			s.recordFailure(lockoutSubjects, "")
+		} else {
+			s.resetFailures(lockoutSubjects)
+		}
+	}
+	if authErr != nil {
+		replyData.Error = "authentication failed"
+		replyData.ErrorCode = string(authErrCode)
+	} else {
+		s.mut.Lock()
+		if s.connGroups[connID] == nil {
+			s.connGroups[connID] = map[string]bool{}
+		}
+		s.connGroups[connID][groupID] = true
+		s.mut.Unlock()
+		s.log.Debug("group authenticated", mlog.String("connID", connID), mlog.String("groupID", groupID))
+	}
+
+	reply, err := NewPackedClientMessage(ClientMessageGroupAuth, replyData.Map())
+	if err != nil {
+		return fmt.Errorf("failed to pack group auth message: %w", err)
+	}
+	if err := s.sendClientMessage(connID, clientID, reply); err != nil {
+		return err
+	}
+
+	if authErr != nil {
+		return fmt.Errorf("failed to authenticate group %q: %w", groupID, authErr)
+	}
+
+	return nil
+}
+
+// authenticateGroup verifies the credentials of a group_auth message, either
+// signed (credentials field) or the bare auth key (authKey field).
+func (s *Service) authenticateGroup(data signaling.GroupAuthData) error {
+	if data.Credentials == "" {
+		if s.cfg.API.Security.SignedAuth.Require {
+			return fmt.Errorf("signed credentials are required")
+		}
+		return s.auth.Authenticate(data.GroupID, data.AuthKey)
+	}
+
+	creds, err := auth.ParseSignedCredentials(data.Credentials)
+	if err != nil {
+		return err
+	}
+	if creds.ClientID != data.GroupID {
+		return fmt.Errorf("credentials do not match the group")
+	}
+
+	return s.authenticateSigned(creds)
+}
+
+// resolveGroupID returns the group a client message carrying the given
+// groupID should be routed to. Messages not specifying a group belong to the
+// client owning the connection.
+func (s *Service) resolveGroupID(connID, clientID, groupID string) (string, error) {
+	if groupID == "" || groupID == clientID {
+		return clientID, nil
+	}
+
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	if !s.connGroups[connID][groupID] {
+		return "", withErrorCode(ErrorCodeForbidden, fmt.Errorf("group %q is not authenticated on this connection", groupID))
+	}
+
+	return groupID, nil
+}
+
+// hasConnGroup returns whether the given connection can act on behalf of
+// groupID. It should be called with s.mut held.
+func (s *Service) hasConnGroup(connID, groupID string, info protocolInfo) bool {
+	return info.clientID == groupID || s.connGroups[connID][groupID]
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveGroupID(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	th.srvc.connGroups["connA"] = map[string]bool{"groupB": true}
+
+	t.Run("default", func(t *testing.T) {
+		groupID, err := th.srvc.resolveGroupID("connA", "clientA", "")
+		require.NoError(t, err)
+		require.Equal(t, "clientA", groupID)
+	})
+
+	t.Run("own client", func(t *testing.T) {
+		groupID, err := th.srvc.resolveGroupID("connB", "clientA", "clientA")
+		require.NoError(t, err)
+		require.Equal(t, "clientA", groupID)
+	})
+
+	t.Run("authenticated group", func(t *testing.T) {
+		groupID, err := th.srvc.resolveGroupID("connA", "clientA", "groupB")
+		require.NoError(t, err)
+		require.Equal(t, "groupB", groupID)
+	})
+
+	t.Run("unauthenticated group", func(t *testing.T) {
+		groupID, err := th.srvc.resolveGroupID("connB", "clientA", "groupB")
+		require.EqualError(t, err, `group "groupB" is not authenticated on this connection`)
+		require.Equal(t, ErrorCodeForbidden, errorCode(err))
+		require.Empty(t, groupID)
+	})
+}
+
+func TestClientGroups(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.Store.EncryptionKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+	cfg.API.Security.SignedAuth.Enable = true
+	cfg.API.Security.SignedAuth.MaxClockSkewSeconds = 300
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	authKeyA, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = th.adminClient.Register("clientA", authKeyA)
+	require.NoError(t, err)
+
+	authKeyB, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = th.adminClient.Register("clientB", authKeyB)
+	require.NoError(t, err)
+
+	c, err := NewClient(ClientConfig{
+		URL:      th.apiURL,
+		ClientID: "clientA",
+		AuthKey:  authKeyA,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	err = c.AddGroup("", authKeyB)
+	require.EqualError(t, err, "invalid empty group id")
+
+	// Groups added before connecting are authenticated on connect.
+	err = c.AddGroup("clientB", authKeyB)
+	require.NoError(t, err)
+
+	err = c.Connect()
+	require.NoError(t, err)
+	defer c.Close()
+
+	msg, ok := <-c.ReceiveCh()
+	require.True(t, ok)
+	require.Equal(t, ClientMessageHello, msg.Type)
+
+	t.Run("routing", func(t *testing.T) {
+		err := c.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+			"groupID":   "clientB",
+			"callID":    "callID",
+			"userID":    "userID",
+			"sessionID": "sessionID",
+		}))
+		require.NoError(t, err)
+
+		err = c.Send(*NewClientMessage(ClientMessageResync, map[string]string{
+			"groupID": "clientB",
+			"callID":  "callID",
+		}))
+		require.NoError(t, err)
+
+		for msg := range c.ReceiveCh() {
+			if msg.Type != ClientMessageCallState {
+				continue
+			}
+			data, ok := msg.Data.(map[string]string)
+			require.True(t, ok)
+			require.Equal(t, "clientB", data["groupID"])
+
+			var state rtc.CallState
+			err := json.Unmarshal([]byte(data["state"]), &state)
+			require.NoError(t, err)
+			require.Equal(t, "clientB", state.GroupID)
+			require.Len(t, state.Sessions, 1)
+			require.Equal(t, "sessionID", state.Sessions[0].SessionID)
+			break
+		}
+
+		_, err = th.srvc.rtcServer.GetCallState("clientA", "callID")
+		require.Error(t, err)
+
+		err = c.Send(*NewClientMessage(ClientMessageLeave, map[string]string{
+			"sessionID": "sessionID",
+		}))
+		require.NoError(t, err)
+	})
+
+	t.Run("auth failure", func(t *testing.T) {
+		err := c.AddGroup("clientC", authKeyB)
+		require.NoError(t, err)
+
+		select {
+		case err := <-c.ErrorCh():
+			require.EqualError(t, err, `failed to authenticate group "clientC": authentication failed`)
+			require.Equal(t, ErrorCodeAuthFailed, ErrorCodeOf(err))
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "timed out waiting for error")
+		}
+	})
+
+	t.Run("signed credentials", func(t *testing.T) {
+		c, err := NewClient(ClientConfig{
+			URL:        th.apiURL,
+			ClientID:   "clientA",
+			AuthKey:    authKeyA,
+			SignedAuth: true,
+		})
+		require.NoError(t, err)
+		err = c.Connect()
+		require.NoError(t, err)
+		defer c.Close()
+
+		msg, ok := <-c.ReceiveCh()
+		require.True(t, ok)
+		require.Equal(t, ClientMessageHello, msg.Type)
+		c.mut.RLock()
+		connID := c.connID
+		c.mut.RUnlock()
+
+		err = c.AddGroup("clientB", authKeyB)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			th.srvc.mut.RLock()
+			defer th.srvc.mut.RUnlock()
+			return th.srvc.connGroups[connID]["clientB"]
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+}
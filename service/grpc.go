@@ -0,0 +1,362 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rpc"
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/ws"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const rpcSendChSize = 256
+
+// grpcServer implements the gRPC API on top of the same primitives used by
+// the HTTP and WebSocket handlers.
+type grpcServer struct {
+	rpc.UnimplementedRTCDServer
+	s *Service
+}
+
+// grpcRequest builds an http.Request carrying the authorization metadata of
+// the incoming call so that the existing auth handlers can be reused.
+func grpcRequest(ctx context.Context, method string) *http.Request {
+	r := &http.Request{
+		Method: "GRPC",
+		URL:    &url.URL{Path: method},
+		Header: http.Header{},
+	}
+	// The context is kept for the calls made on behalf of the request,
+	// such as the registration auth provider ones.
+	r = r.WithContext(ctx)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			r.Header.Set("Authorization", values[0])
+		}
+		if values := md.Get(requestIDHeader); len(values) > 0 {
+			r.Header.Set(requestIDHeader, values[0])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r
+}
+
+func grpcCode(httpCode int) codes.Code {
+	switch httpCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// audit records the outcome of an auth related call in the audit log.
+func (g *grpcServer) audit(ctx context.Context, method, actor, clientID string, err error) {
+	r := grpcRequest(ctx, method)
+	result := "success"
+	var errMsg string
+	if err != nil {
+		result = "fail"
+		errMsg = err.Error()
+	}
+	g.s.auditLog(method, actor, requestID(r.Header.Get(requestIDHeader)), result,
+		mlog.String("remoteAddr", r.RemoteAddr),
+		mlog.String("clientID", clientID),
+		mlog.String("error", errMsg),
+	)
+}
+
+// authenticate authenticates the calls. As they can all serve the signaling,
+// bare auth keys are rejected when signed credentials are required.
+func (g *grpcServer) authenticate(ctx context.Context, method string) (string, error) {
+	r := grpcRequest(ctx, method)
+	if err := g.s.checkSignedAuthRequired(r); err != nil {
+		return "", status.Error(codes.Unauthenticated, err.Error())
+	}
+	clientID, code, err := g.s.authHandler(nil, r)
+	if err != nil {
+		return "", status.Error(grpcCode(code), err.Error())
+	}
+	return clientID, nil
+}
+
+func (g *grpcServer) Register(ctx context.Context, req *rpc.RegisterRequest) (_ *rpc.RegisterResponse, err error) {
+	var actor string
+	defer func() {
+		g.audit(ctx, "Register", actor, req.GetClientId(), err)
+	}()
+
+	if !g.s.cfg.API.Security.AllowSelfRegistration {
+		authedClientID, code, err := g.s.registrationAuthHandler(nil, grpcRequest(ctx, "Register"), req.GetClientId())
+		if err != nil {
+			return nil, status.Error(grpcCode(code), err.Error())
+		}
+		actor = actorID(authedClientID)
+	}
+
+	if err := g.s.auth.Register(req.GetClientId(), req.GetAuthKey()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	g.s.log.Debug("registered new client", mlog.String("clientID", req.GetClientId()))
+
+	return &rpc.RegisterResponse{ClientId: req.GetClientId()}, nil
+}
+
+func (g *grpcServer) Unregister(ctx context.Context, req *rpc.UnregisterRequest) (_ *rpc.UnregisterResponse, err error) {
+	var actor string
+	defer func() {
+		g.audit(ctx, "Unregister", actor, req.GetClientId(), err)
+	}()
+
+	if !g.s.cfg.API.Security.EnableAdmin && !g.s.cfg.API.Security.AllowSelfRegistration {
+		return nil, status.Error(codes.PermissionDenied, "unregister not enabled")
+	}
+
+	authedClientID, err := g.authenticate(ctx, "Unregister")
+	if err != nil {
+		return nil, err
+	}
+	actor = actorID(authedClientID)
+
+	clientID := req.GetClientId()
+	if clientID == "" {
+		return nil, status.Error(codes.InvalidArgument, "client id should not be empty")
+	}
+
+	// an authedClientID == "" means admin.
+	if authedClientID != "" && authedClientID != clientID {
+		return nil, status.Error(codes.PermissionDenied, "client id not valid")
+	}
+
+	if err := g.s.auth.Unregister(clientID); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	g.s.log.Debug("unregistered client", mlog.String("clientID", clientID))
+
+	return &rpc.UnregisterResponse{}, nil
+}
+
+func (g *grpcServer) Login(ctx context.Context, req *rpc.LoginRequest) (_ *rpc.LoginResponse, err error) {
+	defer func() {
+		g.audit(ctx, "Login", req.GetClientId(), req.GetClientId(), err)
+	}()
+
+	if g.s.cfg.API.Security.SignedAuth.Require {
+		return nil, status.Error(codes.Unauthenticated, "login failed: signed credentials are required")
+	}
+
+	r := grpcRequest(ctx, "Login")
+	if _, err := g.s.checkAuthLockout(req.GetClientId(), r.RemoteAddr); err != nil {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	bearerToken, err := g.s.auth.Login(req.GetClientId(), req.GetAuthKey())
+	if err != nil {
+		g.s.recordAuthFailure(req.GetClientId(), r.RemoteAddr, requestID(r.Header.Get(requestIDHeader)))
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	g.s.resetAuthFailures(req.GetClientId(), r.RemoteAddr)
+
+	g.s.log.Debug("logged in client", mlog.String("clientID", req.GetClientId()))
+
+	return &rpc.LoginResponse{BearerToken: bearerToken}, nil
+}
+
+// Signal serves a signaling stream. Messages are handled exactly like the ones
+// received through the WebSocket connection.
+func (g *grpcServer) Signal(stream rpc.RTCD_SignalServer) error {
+	clientID, err := g.authenticate(stream.Context(), "Signal")
+	if err != nil {
+		return err
+	}
+
+	connID := random.NewID()
+	sendCh := make(chan *rpc.ClientMessage, rpcSendChSize)
+
+	g.s.rpcMut.Lock()
+	g.s.rpcConns[connID] = sendCh
+	g.s.rpcMut.Unlock()
+	defer func() {
+		g.s.rpcMut.Lock()
+		delete(g.s.rpcConns, connID)
+		g.s.rpcMut.Unlock()
+		// As for the WebSocket connections, the sessions get closed unless
+		// resumed on another connection in time.
+		g.s.handleConnClose(connID, clientID)
+	}()
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	sendErrCh := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case msg := <-sendCh:
+				if err := stream.Send(msg); err != nil {
+					sendErrCh <- err
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Receiving in the background lets a failing send side end the stream
+	// while the client isn't sending anything.
+	type recvResult struct {
+		msg *rpc.ClientMessage
+		err error
+	}
+	recvCh := make(chan recvResult)
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			select {
+			case recvCh <- recvResult{msg: msg, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := g.s.handleConnOpen(connID, clientID); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	for {
+		var res recvResult
+		select {
+		case res = <-recvCh:
+		case err := <-sendErrCh:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if res.err == io.EOF {
+			return nil
+		} else if res.err != nil {
+			return res.err
+		}
+
+		data, err := clientMessageFromProto(res.msg).Pack()
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		if err := g.s.handleClientMsg(ws.Message{
+			ConnID:   connID,
+			ClientID: clientID,
+			Type:     ws.BinaryMessage,
+			Data:     data,
+		}); err != nil {
+			g.s.log.Error("failed to handle message",
+				mlog.Err(err),
+				mlog.String("connID", connID),
+				mlog.String("clientID", clientID))
+		}
+	}
+}
+
+// sendMessage routes a message to either a gRPC stream, the outbound
+// connection or a WebSocket connection depending on where connID originated
+// from.
+func (s *Service) sendMessage(msg ws.Message) error {
+	if wsClient := s.getOutboundConn(msg.ConnID); wsClient != nil {
+		return wsClient.Send(msg.Type, msg.Data)
+	}
+
+	s.rpcMut.RLock()
+	sendCh, ok := s.rpcConns[msg.ConnID]
+	s.rpcMut.RUnlock()
+	if !ok {
+		return s.wsServer.Send(msg)
+	}
+
+	var cm ClientMessage
+	if err := cm.Unpack(msg.Data); err != nil {
+		return fmt.Errorf("failed to unpack message: %w", err)
+	}
+
+	pm, err := clientMessageToProto(cm)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case sendCh <- pm:
+	default:
+		return fmt.Errorf("failed to send message: channel is full")
+	}
+
+	return nil
+}
+
+func clientMessageToProto(cm ClientMessage) (*rpc.ClientMessage, error) {
+	pm := &rpc.ClientMessage{Type: cm.Type}
+	switch data := cm.Data.(type) {
+	case nil:
+	case map[string]string:
+		pm.Data = data
+	case rtc.Message:
+		pm.Rtc = &rpc.RTCMessage{
+			GroupId:   data.GroupID,
+			UserId:    data.UserID,
+			SessionId: data.SessionID,
+			Type:      int32(data.Type),
+			Data:      data.Data,
+			Seq:       data.Seq,
+		}
+	default:
+		return nil, fmt.Errorf("unexpected data type: %T", cm.Data)
+	}
+	return pm, nil
+}
+
+func clientMessageFromProto(pm *rpc.ClientMessage) *ClientMessage {
+	if rtcMsg := pm.GetRtc(); rtcMsg != nil {
+		return NewClientMessage(pm.GetType(), rtc.Message{
+			GroupID:   rtcMsg.GetGroupId(),
+			UserID:    rtcMsg.GetUserId(),
+			SessionID: rtcMsg.GetSessionId(),
+			Type:      rtc.MessageType(rtcMsg.GetType()),
+			Data:      rtcMsg.GetData(),
+			Seq:       rtcMsg.GetSeq(),
+		})
+	}
+
+	data := pm.GetData()
+	if data == nil {
+		data = map[string]string{}
+	}
+	return NewClientMessage(pm.GetType(), data)
+}
@@ -0,0 +1,323 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/rpc"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func setupGRPCClient(t *testing.T, th *TestHelper) rpc.RTCDClient {
+	t.Helper()
+
+	_, port, err := net.SplitHostPort(th.srvc.rpcServer.Addr().String())
+	require.NoError(t, err)
+
+	conn, err := grpc.Dial("localhost:"+port, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, conn.Close())
+	})
+
+	return rpc.NewRTCDClient(conn)
+}
+
+func basicAuthCtx(ctx context.Context, clientID, authKey string) context.Context {
+	token := base64.StdEncoding.EncodeToString([]byte(clientID + ":" + authKey))
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Basic "+token)
+}
+
+// newTestCtx returns a context for the RPCs of a test, cancelled once it's
+// done, so that a slow test doesn't eat into the time of the following ones.
+func newTestCtx(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestGRPCServer(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.API.GRPC = rpc.Config{
+		Enable:        true,
+		ListenAddress: ":0",
+	}
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	client := setupGRPCClient(t, th)
+
+	clientID := "clientA"
+	authKey := "Ey4-H_BJA00_TVByPi8DozE12ekN3S7L"
+
+	t.Run("register unauthorized", func(t *testing.T) {
+		ctx := newTestCtx(t)
+		_, err := client.Register(ctx, &rpc.RegisterRequest{ClientId: clientID, AuthKey: authKey})
+		require.Error(t, err)
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("register", func(t *testing.T) {
+		ctx := newTestCtx(t)
+		adminCtx := basicAuthCtx(ctx, "", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := client.Register(adminCtx, &rpc.RegisterRequest{ClientId: clientID, AuthKey: authKey})
+		require.NoError(t, err)
+		require.Equal(t, clientID, resp.GetClientId())
+	})
+
+	t.Run("login", func(t *testing.T) {
+		ctx := newTestCtx(t)
+		resp, err := client.Login(ctx, &rpc.LoginRequest{ClientId: clientID, AuthKey: "invalid"})
+		require.Error(t, err)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		resp, err = client.Login(ctx, &rpc.LoginRequest{ClientId: clientID, AuthKey: authKey})
+		require.NoError(t, err)
+		require.NotEmpty(t, resp.GetBearerToken())
+	})
+
+	t.Run("signal", func(t *testing.T) {
+		ctx := newTestCtx(t)
+		stream, err := client.Signal(basicAuthCtx(ctx, clientID, authKey))
+		require.NoError(t, err)
+
+		msg, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, ClientMessageHello, msg.GetType())
+		require.Equal(t, clientID, msg.GetData()["clientID"])
+		require.NotEmpty(t, msg.GetData()["connID"])
+
+		err = stream.Send(&rpc.ClientMessage{
+			Type: ClientMessageJoin,
+			Data: map[string]string{
+				"callID":    "callID",
+				"userID":    "userID",
+				"sessionID": "sessionID",
+			},
+		})
+		require.NoError(t, err)
+
+		err = stream.Send(&rpc.ClientMessage{
+			Type: ClientMessageLeave,
+			Data: map[string]string{
+				"sessionID": "sessionID",
+			},
+		})
+		require.NoError(t, err)
+
+		msg, err = stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, ClientMessageClose, msg.GetType())
+		require.Equal(t, "sessionID", msg.GetData()["sessionID"])
+
+		require.NoError(t, stream.CloseSend())
+	})
+
+	t.Run("unregister", func(t *testing.T) {
+		ctx := newTestCtx(t)
+		_, err := client.Unregister(basicAuthCtx(ctx, clientID, authKey), &rpc.UnregisterRequest{ClientId: "clientB"})
+		require.Error(t, err)
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		_, err = client.Unregister(basicAuthCtx(ctx, clientID, authKey), &rpc.UnregisterRequest{ClientId: clientID})
+		require.NoError(t, err)
+	})
+}
+
+func TestGRPCRegisterWithProvider(t *testing.T) {
+	validator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req["username"] == req["clientID"] && req["password"] == "secret" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer validator.Close()
+
+	cfg := MakeDefaultCfg(t)
+	cfg.API.GRPC = rpc.Config{
+		Enable:        true,
+		ListenAddress: ":0",
+	}
+	cfg.API.Security.RegistrationAuth.Provider = auth.ProviderHTTP
+	cfg.API.Security.RegistrationAuth.TimeoutSeconds = 5
+	cfg.API.Security.RegistrationAuth.HTTP.URL = validator.URL
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	client := setupGRPCClient(t, th)
+
+	authKey := "Ey4-H_BJA00_TVByPi8DozE12ekN3S7L"
+
+	t.Run("provider credentials", func(t *testing.T) {
+		ctx := newTestCtx(t)
+		resp, err := client.Register(basicAuthCtx(ctx, "clientA", "secret"), &rpc.RegisterRequest{ClientId: "clientA", AuthKey: authKey})
+		require.NoError(t, err)
+		require.Equal(t, "clientA", resp.GetClientId())
+	})
+
+	t.Run("rejected credentials", func(t *testing.T) {
+		ctx := newTestCtx(t)
+		_, err := client.Register(basicAuthCtx(ctx, "clientB", "wrong"), &rpc.RegisterRequest{ClientId: "clientB", AuthKey: authKey})
+		require.Error(t, err)
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("registered client credentials", func(t *testing.T) {
+		ctx := newTestCtx(t)
+		// The provider being in charge, the credentials of registered
+		// clients don't allow registering others.
+		_, err := client.Register(basicAuthCtx(ctx, "clientA", authKey), &rpc.RegisterRequest{ClientId: "clientC", AuthKey: authKey})
+		require.Error(t, err)
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("admin credentials", func(t *testing.T) {
+		ctx := newTestCtx(t)
+		adminCtx := basicAuthCtx(ctx, "", th.srvc.cfg.API.Security.AdminSecretKey)
+		_, err := client.Register(adminCtx, &rpc.RegisterRequest{ClientId: "clientD", AuthKey: authKey})
+		require.NoError(t, err)
+	})
+}
+
+func TestGRPCSignedAuthRequired(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.API.GRPC = rpc.Config{
+		Enable:        true,
+		ListenAddress: ":0",
+	}
+	cfg.Store.EncryptionKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+	cfg.API.Security.SignedAuth = auth.SignedAuthConfig{
+		Enable:              true,
+		Require:             true,
+		MaxClockSkewSeconds: 300,
+	}
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	client := setupGRPCClient(t, th)
+
+	clientID := "clientA"
+	authKey := "Ey4-H_BJA00_TVByPi8DozE12ekN3S7L"
+
+	// The admin secret key is still accepted.
+	adminCtx := basicAuthCtx(newTestCtx(t), "", th.srvc.cfg.API.Security.AdminSecretKey)
+	_, err := client.Register(adminCtx, &rpc.RegisterRequest{ClientId: clientID, AuthKey: authKey})
+	require.NoError(t, err)
+
+	t.Run("login", func(t *testing.T) {
+		ctx := newTestCtx(t)
+		_, err := client.Login(ctx, &rpc.LoginRequest{ClientId: clientID, AuthKey: authKey})
+		require.Error(t, err)
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("signal with auth key", func(t *testing.T) {
+		ctx := newTestCtx(t)
+		stream, err := client.Signal(basicAuthCtx(ctx, clientID, authKey))
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.Error(t, err)
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("unregister with auth key", func(t *testing.T) {
+		ctx := newTestCtx(t)
+		_, err := client.Unregister(basicAuthCtx(ctx, clientID, authKey), &rpc.UnregisterRequest{ClientId: clientID})
+		require.Error(t, err)
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("signal with signed credentials", func(t *testing.T) {
+		ctx := newTestCtx(t)
+		// The signing key is derived on registration.
+		creds, err := auth.NewSignedCredentials(clientID, authKey, time.Now())
+		require.NoError(t, err)
+		signedCtx := metadata.AppendToOutgoingContext(ctx, "authorization", auth.SignedAuthScheme+" "+creds.String())
+		stream, err := client.Signal(signedCtx)
+		require.NoError(t, err)
+		msg, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, ClientMessageHello, msg.GetType())
+		require.Equal(t, clientID, msg.GetData()["clientID"])
+		require.NoError(t, stream.CloseSend())
+	})
+}
+
+func TestGRPCSignalStaleSessions(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.API.GRPC = rpc.Config{
+		Enable:        true,
+		ListenAddress: ":0",
+	}
+	cfg.API.StaleSessionTimeoutSeconds = 1
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	client := setupGRPCClient(t, th)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	clientID := "clientA"
+	authKey := "Ey4-H_BJA00_TVByPi8DozE12ekN3S7L"
+	registerClient(t, th, clientID, authKey)
+
+	streamCtx, cancelStream := context.WithCancel(basicAuthCtx(ctx, clientID, authKey))
+	stream, err := client.Signal(streamCtx)
+	require.NoError(t, err)
+	msg, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, ClientMessageHello, msg.GetType())
+	connID := msg.GetData()["connID"]
+
+	err = stream.Send(&rpc.ClientMessage{
+		Type: ClientMessageJoin,
+		Data: map[string]string{
+			"callID":    "callID",
+			"userID":    "userID",
+			"sessionID": "sessionID",
+		},
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		th.srvc.mut.RLock()
+		defer th.srvc.mut.RUnlock()
+		return th.srvc.connMap["sessionID"] == connID
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// The session gets closed once the stream is gone for longer than the
+	// stale session timeout.
+	cancelStream()
+	require.Eventually(t, func() bool {
+		th.srvc.rpcMut.RLock()
+		defer th.srvc.rpcMut.RUnlock()
+		return th.srvc.rpcConns[connID] == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		th.srvc.mut.RLock()
+		defer th.srvc.mut.RUnlock()
+		_, ok := th.srvc.connMap["sessionID"]
+		return !ok && len(th.srvc.lostConns) == 0
+	}, 4*time.Second, 50*time.Millisecond)
+}
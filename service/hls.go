@@ -0,0 +1,83 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/rtcd/service/rtc"
+)
+
+const hlsPathPrefix = "/hls/"
+
+// handleHLS serves the files of the LL-HLS streams under
+// /hls/<streamID>/<name>. Streams are meant for passive viewers and don't
+// require authentication, their IDs are random.
+func (s *Service) handleHLS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.NotFound(w, r)
+		return
+	}
+
+	streamID, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, hlsPathPrefix), "/")
+	if !ok || streamID == "" || name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Blocking playlist reload parameters.
+	msn, part := -1, -1
+	query := r.URL.Query()
+	if val := query.Get("_HLS_msn"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid _HLS_msn value", http.StatusBadRequest)
+			return
+		}
+		msn = n
+	}
+	if val := query.Get("_HLS_part"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 || msn < 0 {
+			http.Error(w, "invalid _HLS_part value", http.StatusBadRequest)
+			return
+		}
+		part = n
+	}
+
+	data, err := s.rtcServer.GetHLSFile(r.Context(), streamID, name, msn, part)
+	switch {
+	case errors.Is(err, rtc.ErrHLSNotFound):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, rtc.ErrHLSBadRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, rtc.ErrHLSUnavailable):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if path.Ext(name) == ".m3u8" {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		// Media files never change once available.
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(data)
+	}
+}
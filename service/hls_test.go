@@ -0,0 +1,63 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHLSHandler(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		th := SetupTestHelper(t, nil)
+		defer th.Teardown()
+
+		resp, err := http.Get(th.apiURL + "/hls/streamID/index.m3u8")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	cfg := MakeDefaultCfg(t)
+	cfg.RTC.HLS = rtc.HLSConfig{
+		Enable:            true,
+		PartDurationMs:    500,
+		SegmentDurationMs: 2000,
+		PlaylistSegments:  6,
+	}
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	tcs := []struct {
+		name string
+		path string
+		code int
+	}{
+		{name: "missing name", path: "/hls/streamID", code: http.StatusNotFound},
+		{name: "nested name", path: "/hls/streamID/a/index.m3u8", code: http.StatusNotFound},
+		{name: "stream not found", path: "/hls/streamID/index.m3u8", code: http.StatusNotFound},
+		{name: "invalid msn", path: "/hls/streamID/audio.m3u8?_HLS_msn=-1", code: http.StatusBadRequest},
+		{name: "part without msn", path: "/hls/streamID/audio.m3u8?_HLS_part=1", code: http.StatusBadRequest},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := http.Get(th.apiURL + tc.path)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tc.code, resp.StatusCode)
+		})
+	}
+
+	t.Run("method not allowed", func(t *testing.T) {
+		resp, err := http.Post(th.apiURL+"/hls/streamID/index.m3u8", "", nil)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattermost/rtcd/service/store"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	idempotencyStoreKeyPrefix = "rtcd:idempotency:"
+	// idempotencyMaxBodySize bounds the requests that can be made idempotent
+	// as their body needs to be buffered to be fingerprinted.
+	idempotencyMaxBodySize = 1024 * 1024
+)
+
+// idempotentResult is the result of a request made with an idempotency key,
+// as persisted in the store. A zero code means the request is still being
+// handled.
+type idempotentResult struct {
+	Fingerprint string            `json:"fingerprint"`
+	Code        int               `json:"code"`
+	Err         string            `json:"err,omitempty"`
+	ErrCode     ErrorCode         `json:"err_code,omitempty"`
+	ResData     map[string]string `json:"res_data,omitempty"`
+	ExpiresAt   int64             `json:"expires_at"`
+}
+
+func (res idempotentResult) isExpired() bool {
+	return time.Now().Unix() >= res.ExpiresAt
+}
+
+func getIdempotentResult(st store.Store, key string) (idempotentResult, error) {
+	var res idempotentResult
+	val, err := st.Get(key)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal([]byte(val), &res); err != nil {
+		return res, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+	return res, nil
+}
+
+// checkIdempotencyKey handles the Idempotency-Key header of a mutating
+// request and should be called once the request is authenticated. If the key
+// was already used, the previous result is set on data and true is returned,
+// meaning the request should not be processed again. Otherwise the key is
+// reserved and the result is persisted by httpAudit. Keys are scoped to the
+// handler and the credentials of the caller.
+func (s *Service) checkIdempotencyKey(handler string, data *httpData, w http.ResponseWriter, r *http.Request) bool {
+	idemKey := r.Header.Get(idempotencyKeyHeader)
+	if idemKey == "" || s.cfg.Store.IdempotencyKeyTTLMinutes == 0 {
+		return false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, idempotencyMaxBodySize+1))
+	if err != nil {
+		data.err = "failed to read body: " + err.Error()
+		data.code = http.StatusBadRequest
+		return true
+	}
+	if len(body) > idempotencyMaxBodySize {
+		data.err = "request body is too large"
+		data.code = http.StatusRequestEntityTooLarge
+		return true
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	scope := sha256.Sum256([]byte(handler + "\x00" + r.Method + "\x00" + r.Header.Get("Authorization") + "\x00" + idemKey))
+	// Keys are limited to 64 bytes by the store.
+	key := idempotencyStoreKeyPrefix + base64.RawURLEncoding.EncodeToString(scope[:])
+	fingerprint := sha256.Sum256(body)
+
+	res := idempotentResult{
+		Fingerprint: hex.EncodeToString(fingerprint[:]),
+		ExpiresAt:   time.Now().Add(time.Duration(s.cfg.Store.IdempotencyKeyTTLMinutes) * time.Minute).Unix(),
+	}
+	js, err := json.Marshal(res)
+	if err != nil {
+		data.err = "failed to marshal result: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return true
+	}
+
+	for {
+		err := s.store.Put(key, string(js))
+		if err == nil {
+			data.idempotencyKey = key
+			data.idempotentResult = res
+			return false
+		}
+		if !errors.Is(err, store.ErrConflict) {
+			data.err = "failed to store idempotency key: " + err.Error()
+			data.code = http.StatusInternalServerError
+			return true
+		}
+
+		prev, err := getIdempotentResult(s.store, key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		} else if err != nil {
+			data.err = "failed to get idempotency key: " + err.Error()
+			data.code = http.StatusInternalServerError
+			return true
+		}
+
+		if prev.isExpired() {
+			if err := s.store.Delete(key); err != nil && !errors.Is(err, store.ErrNotFound) {
+				data.err = "failed to delete idempotency key: " + err.Error()
+				data.code = http.StatusInternalServerError
+				return true
+			}
+			continue
+		}
+
+		if prev.Fingerprint != res.Fingerprint {
+			data.err = "idempotency key was already used for a different request"
+			data.code = http.StatusUnprocessableEntity
+			return true
+		}
+
+		if prev.Code == 0 {
+			data.err = "a request with the same idempotency key is in progress"
+			data.code = http.StatusConflict
+			return true
+		}
+
+		data.code = prev.Code
+		data.err = prev.Err
+		data.errCode = prev.ErrCode
+		for k, v := range prev.ResData {
+			data.resData[k] = v
+		}
+		w.Header().Set(idempotentReplayedHeader, "true")
+		return true
+	}
+}
+
+// storeIdempotentResult persists the result of a request made with an
+// idempotency key. Server errors release the key so that the request can be
+// retried.
+func (s *Service) storeIdempotentResult(data *httpData) {
+	if data.code >= http.StatusInternalServerError {
+		if err := s.store.Delete(data.idempotencyKey); err != nil {
+			s.log.Error("failed to delete idempotency key", mlog.Err(err))
+		}
+		return
+	}
+
+	res := data.idempotentResult
+	res.Code = data.code
+	res.Err = data.err
+	res.ErrCode = data.errCode
+	res.ResData = make(map[string]string, len(data.resData))
+	for k, v := range data.resData {
+		res.ResData[k] = v
+	}
+	js, err := json.Marshal(res)
+	if err != nil {
+		s.log.Error("failed to marshal idempotent result", mlog.Err(err))
+		return
+	}
+	if err := s.store.Set(data.idempotencyKey, string(js)); err != nil {
+		s.log.Error("failed to store idempotent result", mlog.Err(err))
+	}
+}
+
+// removeExpiredIdempotencyKeys deletes the expired idempotency keys from the
+// store. If includePending is true, the keys of the requests that haven't
+// completed are deleted as well, which is only safe when no request is being
+// handled.
+func (s *Service) removeExpiredIdempotencyKeys(includePending bool) error {
+	keys, err := s.store.Keys()
+	if err != nil {
+		return fmt.Errorf("failed to get keys: %w", err)
+	}
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, idempotencyStoreKeyPrefix) {
+			continue
+		}
+		res, err := getIdempotentResult(s.store, key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		} else if err == nil && !res.isExpired() && (res.Code != 0 || !includePending) {
+			continue
+		}
+		if err := s.store.Delete(key); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("failed to delete key: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// runIdempotencyCleanup periodically removes the expired idempotency keys.
+func (s *Service) runIdempotencyCleanup(interval time.Duration) {
+	defer close(s.idempotencyDoneCh)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.removeExpiredIdempotencyKeys(false); err != nil {
+				s.log.Error("failed to remove expired idempotency keys", mlog.Err(err))
+			}
+		case <-s.idempotencyStopCh:
+			return
+		}
+	}
+}
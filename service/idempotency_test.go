@@ -0,0 +1,113 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.Store.IdempotencyKeyTTLMinutes = 1
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	register := func(t *testing.T, idemKey, body string) (*http.Response, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest("POST", th.apiURL+"/register", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		if idemKey != "" {
+			req.Header.Set(idempotencyKeyHeader, idemKey)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp, response
+	}
+
+	body := `{"clientID": "clientA", "authKey": "Ey4-H_BJA00_TVByPi8DozE12ekN3S7L"}`
+
+	t.Run("replayed result", func(t *testing.T) {
+		resp, response := register(t, "keyA", body)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		require.Empty(t, resp.Header.Get(idempotentReplayedHeader))
+		require.Equal(t, "clientA", response["clientID"])
+
+		resp, response = register(t, "keyA", body)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		require.Equal(t, "true", resp.Header.Get(idempotentReplayedHeader))
+		require.Equal(t, "clientA", response["clientID"])
+
+		resp, _ = register(t, "", body)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("different request", func(t *testing.T) {
+		resp, response := register(t, "keyA", `{"clientID": "clientB", "authKey": "Ey4-H_BJA00_TVByPi8DozE12ekN3S7L"}`)
+		require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		require.Equal(t, "idempotency key was already used for a different request", response["error"])
+	})
+
+	t.Run("error result", func(t *testing.T) {
+		resp, _ := register(t, "keyB", body)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		err := th.srvc.auth.Unregister("clientA")
+		require.NoError(t, err)
+
+		resp, _ = register(t, "keyB", body)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		require.Equal(t, "true", resp.Header.Get(idempotentReplayedHeader))
+	})
+
+	t.Run("expired keys", func(t *testing.T) {
+		expired, err := json.Marshal(idempotentResult{Code: http.StatusOK, ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+		require.NoError(t, err)
+		err = th.srvc.store.Set(idempotencyStoreKeyPrefix+"expired", string(expired))
+		require.NoError(t, err)
+		pending, err := json.Marshal(idempotentResult{ExpiresAt: time.Now().Add(time.Minute).Unix()})
+		require.NoError(t, err)
+		err = th.srvc.store.Set(idempotencyStoreKeyPrefix+"pending", string(pending))
+		require.NoError(t, err)
+
+		err = th.srvc.removeExpiredIdempotencyKeys(false)
+		require.NoError(t, err)
+		_, err = th.srvc.store.Get(idempotencyStoreKeyPrefix + "expired")
+		require.Error(t, err)
+		_, err = th.srvc.store.Get(idempotencyStoreKeyPrefix + "pending")
+		require.NoError(t, err)
+
+		err = th.srvc.removeExpiredIdempotencyKeys(true)
+		require.NoError(t, err)
+		_, err = th.srvc.store.Get(idempotencyStoreKeyPrefix + "pending")
+		require.Error(t, err)
+	})
+}
+
+func TestIdempotencyKeyDisabled(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	for _, code := range []int{http.StatusCreated, http.StatusBadRequest} {
+		req, err := http.NewRequest("POST", th.apiURL+"/register",
+			bytes.NewBufferString(`{"clientID": "clientA", "authKey": "Ey4-H_BJA00_TVByPi8DozE12ekN3S7L"}`))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		req.Header.Set(idempotencyKeyHeader, "keyA")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, code, resp.StatusCode)
+	}
+}
@@ -0,0 +1,450 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// componentStopTimeout is the time a component is given to stop unless it
+// sets its own timeout.
+const componentStopTimeout = 10 * time.Second
+
+// errStopTimedOut is returned, wrapped, for the components that didn't
+// stop in time.
+var errStopTimedOut = errors.New("stop timed out")
+
+// component is a part of the service whose start and stop are ordered by
+// the lifecycle manager.
+type component struct {
+	name string
+	// start starts the component. Components without one are running as
+	// soon as they are added, having been started on creation.
+	start func() error
+	// stop stops the component, if there is anything to stop.
+	stop func() error
+	// timeout bounds the time stop is given to return, componentStopTimeout
+	// if zero.
+	timeout time.Duration
+
+	running bool
+}
+
+// lifecycle starts the components of the service in the order they were
+// added, that of their dependencies, and stops them in the reverse order.
+type lifecycle struct {
+	components []*component
+	mut        sync.Mutex
+}
+
+// add appends a component, started after and stopped before the ones
+// already added.
+func (l *lifecycle) add(c component) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	c.running = c.start == nil
+	l.components = append(l.components, &c)
+}
+
+// start starts the components not running yet, in order. It returns on
+// the first failure, the components started so far being left for stop.
+func (l *lifecycle) start() error {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	for _, c := range l.components {
+		if c.running {
+			continue
+		}
+		if err := c.start(); err != nil {
+			return fmt.Errorf("failed to start %s: %w", c.name, err)
+		}
+		c.running = true
+	}
+
+	return nil
+}
+
+// stop stops the running components in the reverse order, each within
+// its timeout. A component failing or timing out doesn't prevent the
+// following ones from being stopped: the errors are aggregated into the
+// returned one. Components that timed out are given up on, their stop
+// being left to return in the background.
+func (l *lifecycle) stop() error {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	var errs stopErrors
+	for i := len(l.components) - 1; i >= 0; i-- {
+		c := l.components[i]
+		if !c.running {
+			continue
+		}
+		c.running = false
+		if c.stop == nil {
+			continue
+		}
+		if err := c.stopWithTimeout(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (c *component) stopWithTimeout() error {
+	timeout := c.timeout
+	if timeout <= 0 {
+		timeout = componentStopTimeout
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.stop()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("failed to stop %s: %w", c.name, err)
+		}
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("failed to stop %s after %s: %w", c.name, timeout, errStopTimedOut)
+	}
+}
+
+// stopErrors aggregates the errors of the components that failed to stop.
+type stopErrors []error
+
+func (e stopErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e stopErrors) Unwrap() []error {
+	return e
+}
+
+// Is reports whether any of the errors matches target. Along with As, it
+// makes errors.Is and errors.As follow the aggregated errors with the Go
+// versions not unwrapping to a slice.
+func (e stopErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors matching target.
+func (e stopErrors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// addComponents adds the components of the service to its lifecycle, in
+// the order of their dependencies: the logger and the store first, the
+// background tasks using them, the servers and last the client sessions,
+// drained before anything else is stopped.
+func (s *Service) addComponents(openFilesLimit uint64) {
+	s.lifecycle.add(component{
+		name: "logger",
+		stop: s.log.Shutdown,
+	})
+
+	s.lifecycle.add(component{
+		name: "store",
+		stop: s.store.Close,
+	})
+
+	if s.cfg.Store.UsagePersistIntervalSeconds > 0 {
+		s.lifecycle.add(component{
+			name: "usage persistence",
+			start: func() error {
+				go s.runUsagePersistence(time.Duration(s.cfg.Store.UsagePersistIntervalSeconds) * time.Second)
+				return nil
+			},
+			stop: func() error {
+				close(s.usageStopCh)
+				<-s.usageDoneCh
+				return s.persistBandwidthUsage()
+			},
+		})
+	}
+
+	for _, task := range []struct {
+		name    string
+		enabled bool
+		run     func()
+		stopCh  chan struct{}
+		doneCh  chan struct{}
+	}{
+		{
+			name:    "dtls certificate rotation",
+			enabled: s.cfg.RTC.DTLSCertificate.Persist,
+			run:     func() { s.runDTLSCertificateRotation(dtlsCertCheckInterval) },
+			stopCh:  s.dtlsCertStopCh,
+			doneCh:  s.dtlsCertDoneCh,
+		},
+		{
+			name:    "auth lockout cleanup",
+			enabled: s.cfg.API.Security.AuthLockout.MaxFailedAttempts > 0,
+			run:     func() { s.runAuthLockoutCleanup(authLockoutCleanupInterval) },
+			stopCh:  s.authLockoutStopCh,
+			doneCh:  s.authLockoutDoneCh,
+		},
+		{
+			name:    "open files check",
+			enabled: openFilesLimit > 0 && s.cfg.Process.OpenFilesWarnPercent > 0,
+			run:     func() { s.runOpenFilesCheck(openFilesLimit, openFilesCheckInterval) },
+			stopCh:  s.openFilesStopCh,
+			doneCh:  s.openFilesDoneCh,
+		},
+		{
+			name:    "store compaction",
+			enabled: true,
+			run:     func() { s.runStoreCompaction(time.Duration(s.cfg.Store.CompactionIntervalMinutes) * time.Minute) },
+			stopCh:  s.storeStopCh,
+			doneCh:  s.storeDoneCh,
+		},
+		{
+			name:    "call events cleanup",
+			enabled: s.cfg.Store.CallEventsMax > 0,
+			run:     func() { s.runCallEventsCleanup(callEventsCleanupInterval) },
+			stopCh:  s.callEventsStopCh,
+			doneCh:  s.callEventsDoneCh,
+		},
+		{
+			name:    "idempotency cleanup",
+			enabled: s.cfg.Store.IdempotencyKeyTTLMinutes > 0,
+			run:     func() { s.runIdempotencyCleanup(time.Duration(s.cfg.Store.IdempotencyKeyTTLMinutes) * time.Minute) },
+			stopCh:  s.idempotencyStopCh,
+			doneCh:  s.idempotencyDoneCh,
+		},
+	} {
+		if !task.enabled {
+			continue
+		}
+		task := task
+		s.lifecycle.add(component{
+			name: task.name,
+			start: func() error {
+				go task.run()
+				return nil
+			},
+			stop: func() error {
+				close(task.stopCh)
+				<-task.doneCh
+				return nil
+			},
+		})
+	}
+
+	if s.eventBus != nil {
+		s.lifecycle.add(component{
+			name: "event bus",
+			stop: func() error {
+				s.eventBus.Close()
+				return nil
+			},
+		})
+	}
+
+	if s.webhooks != nil {
+		s.lifecycle.add(component{
+			name: "webhooks",
+			stop: func() error {
+				s.webhooks.Close()
+				return nil
+			},
+		})
+	}
+
+	if s.statsd != nil {
+		s.lifecycle.add(component{
+			name: "statsd backend",
+			stop: s.statsd.Close,
+		})
+	}
+
+	// The message handlers, started with the servers below, return once
+	// those close their channels on stop. The rtc ones are waited for after
+	// the rtc server stopped, the ws one before, so that nothing sends to the
+	// rtc server once it closed its channels.
+	s.lifecycle.add(component{
+		name: "rtc message handlers",
+		stop: func() error {
+			s.rtcHandlersWg.Wait()
+			return nil
+		},
+	})
+
+	s.lifecycle.add(component{
+		name:  "rtc server",
+		start: s.rtcServer.Start,
+		stop:  s.rtcServer.Stop,
+	})
+
+	s.lifecycle.add(component{
+		name: "ws message handlers",
+		stop: func() error {
+			s.wsHandlersWg.Wait()
+			return nil
+		},
+	})
+
+	s.lifecycle.add(component{
+		name: "ws server",
+		stop: func() error {
+			s.wsServer.Close()
+			s.stopSignalingTraces()
+			return nil
+		},
+	})
+
+	s.lifecycle.add(component{
+		name:  "api server",
+		start: s.apiServer.Start,
+		stop:  s.apiServer.Stop,
+	})
+
+	if s.adminServer != nil {
+		s.lifecycle.add(component{
+			name:  "admin api server",
+			start: s.adminServer.Start,
+			stop:  s.adminServer.Stop,
+		})
+	}
+
+	if s.metricsServer != nil {
+		s.lifecycle.add(component{
+			name:  "metrics api server",
+			start: s.metricsServer.Start,
+			stop:  s.metricsServer.Stop,
+		})
+	}
+
+	if s.rpcServer != nil {
+		s.lifecycle.add(component{
+			name:  "rpc server",
+			start: s.rpcServer.Start,
+			stop:  s.rpcServer.Stop,
+		})
+	}
+
+	if s.watchdog != nil {
+		s.lifecycle.add(component{
+			name: "watchdog",
+			start: func() error {
+				s.watchdog.Start()
+				return nil
+			},
+			stop: func() error {
+				s.watchdog.Stop()
+				return nil
+			},
+		})
+	}
+
+	if s.cfg.API.Outbound.Enable {
+		s.lifecycle.add(component{
+			name: "outbound connection",
+			start: func() error {
+				go s.runOutbound()
+				return nil
+			},
+			stop: func() error {
+				close(s.outboundStopCh)
+				<-s.outboundDoneCh
+				return nil
+			},
+		})
+	}
+
+	// The message handlers are only started with all the servers, whose
+	// channels they read until closed, so that waiting for them can't
+	// block on a server that failed to start.
+	s.lifecycle.add(component{
+		name: "message handlers start",
+		start: func() error {
+			run := func(wg *sync.WaitGroup, handle func()) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					handle()
+				}()
+			}
+			run(&s.wsHandlersWg, s.handleWSMessages)
+			run(&s.rtcHandlersWg, s.handleRTCMessages)
+			run(&s.rtcHandlersWg, s.handleRTCEvents)
+			return nil
+		},
+	})
+
+	if s.vault != nil && s.cfg.Vault.RefreshIntervalMinutes > 0 {
+		s.lifecycle.add(component{
+			name: "secrets refresh",
+			start: func() error {
+				go s.refreshSecrets(time.Duration(s.cfg.Vault.RefreshIntervalMinutes) * time.Minute)
+				return nil
+			},
+			stop: func() error {
+				close(s.vaultStopCh)
+				<-s.vaultDoneCh
+				return nil
+			},
+		})
+	}
+
+	if s.autoscaling != nil {
+		// Closing publishes the signals one last time, reporting the node
+		// as draining.
+		s.lifecycle.add(component{
+			name: "autoscaling",
+			stop: func() error {
+				s.autoscaling.Close()
+				return nil
+			},
+		})
+	}
+
+	s.lifecycle.add(component{
+		name: "admin events",
+		stop: func() error {
+			s.adminEvents.close()
+			return nil
+		},
+	})
+
+	s.lifecycle.add(component{
+		name: "sessions",
+		stop: func() error {
+			s.stopMaintenance()
+			s.stopStaleSessionsCleanups()
+			s.drain()
+			return nil
+		},
+		// Draining gives the sessions the whole shutdown timeout to end.
+		timeout: time.Duration(s.cfg.Process.ShutdownTimeoutSeconds)*time.Second + componentStopTimeout,
+	})
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycle(t *testing.T) {
+	var calls []string
+	newComponent := func(name string, startErr, stopErr error) component {
+		return component{
+			name: name,
+			start: func() error {
+				calls = append(calls, "start "+name)
+				return startErr
+			},
+			stop: func() error {
+				calls = append(calls, "stop "+name)
+				return stopErr
+			},
+		}
+	}
+
+	t.Run("order", func(t *testing.T) {
+		calls = nil
+		var l lifecycle
+		l.add(newComponent("store", nil, nil))
+		l.add(component{
+			name: "logger",
+			stop: func() error {
+				calls = append(calls, "stop logger")
+				return nil
+			},
+		})
+		l.add(newComponent("api", nil, nil))
+		l.add(newComponent("rtc", nil, nil))
+
+		require.NoError(t, l.start())
+		require.NoError(t, l.stop())
+		require.Equal(t, []string{
+			"start store", "start api", "start rtc",
+			"stop rtc", "stop api", "stop logger", "stop store",
+		}, calls)
+
+		// Stopped components aren't stopped again.
+		calls = nil
+		require.NoError(t, l.stop())
+		require.Empty(t, calls)
+	})
+
+	t.Run("not started", func(t *testing.T) {
+		calls = nil
+		var l lifecycle
+		l.add(newComponent("api", nil, nil))
+		l.add(component{
+			name: "store",
+			stop: func() error {
+				calls = append(calls, "stop store")
+				return nil
+			},
+		})
+
+		require.NoError(t, l.stop())
+		require.Equal(t, []string{"stop store"}, calls)
+	})
+
+	t.Run("start failure", func(t *testing.T) {
+		calls = nil
+		var l lifecycle
+		l.add(newComponent("store", nil, nil))
+		l.add(newComponent("api", errors.New("address in use"), nil))
+		l.add(newComponent("rtc", nil, nil))
+
+		err := l.start()
+		require.EqualError(t, err, "failed to start api: address in use")
+
+		require.NoError(t, l.stop())
+		require.Equal(t, []string{"start store", "start api", "stop store"}, calls)
+	})
+
+	t.Run("stop failures", func(t *testing.T) {
+		calls = nil
+		errAPI := errors.New("api error")
+		errStore := errors.New("store error")
+		var l lifecycle
+		l.add(newComponent("store", nil, errStore))
+		l.add(newComponent("api", nil, errAPI))
+		l.add(newComponent("rtc", nil, nil))
+
+		require.NoError(t, l.start())
+		err := l.stop()
+		require.EqualError(t, err, "failed to stop api: api error; failed to stop store: store error")
+		require.ErrorIs(t, err, errAPI)
+		require.ErrorIs(t, err, errStore)
+		require.Equal(t, []string{
+			"start store", "start api", "start rtc",
+			"stop rtc", "stop api", "stop store",
+		}, calls)
+	})
+
+	t.Run("stop failure as", func(t *testing.T) {
+		calls = nil
+		errStore := &os.PathError{Op: "close", Path: "store.db", Err: os.ErrClosed}
+		var l lifecycle
+		l.add(newComponent("store", nil, errStore))
+		l.add(newComponent("api", nil, errors.New("api error")))
+
+		require.NoError(t, l.start())
+		err := l.stop()
+		require.Error(t, err)
+		var pathErr *os.PathError
+		require.True(t, errors.As(err, &pathErr))
+		require.Equal(t, errStore, pathErr)
+		require.True(t, errors.Is(err, os.ErrClosed))
+	})
+
+	t.Run("stop timeout", func(t *testing.T) {
+		blockCh := make(chan struct{})
+		defer close(blockCh)
+
+		var stopped bool
+		var l lifecycle
+		l.add(component{
+			name: "store",
+			stop: func() error {
+				stopped = true
+				return nil
+			},
+		})
+		l.add(component{
+			name: "rtc",
+			stop: func() error {
+				<-blockCh
+				return nil
+			},
+			timeout: 10 * time.Millisecond,
+		})
+
+		err := l.stop()
+		require.EqualError(t, err, "failed to stop rtc after 10ms: stop timed out")
+		require.ErrorIs(t, err, errStopTimedOut)
+		require.True(t, stopped)
+	})
+}
+
+func TestServiceStopNotStarted(t *testing.T) {
+	// The outbound connection is only waited for once started.
+	cfg := MakeDefaultCfg(t)
+	defer os.RemoveAll(cfg.Store.DataSource)
+	cfg.API.Outbound = OutboundConfig{
+		Enable:                   true,
+		URL:                      "ws://localhost:1",
+		ClientID:                 "clientA",
+		AuthKey:                  "authKey",
+		ReconnectIntervalSeconds: 1,
+	}
+
+	srvc, err := New(*cfg)
+	require.NoError(t, err)
+
+	doneCh := make(chan error, 1)
+	go func() {
+		doneCh <- srvc.Stop()
+	}()
+
+	select {
+	case err := <-doneCh:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "timed out stopping service")
+	}
+
+	// Stopping again is a no-op.
+	require.NoError(t, srvc.Stop())
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/ice/v2"
+	"github.com/pion/transport/vnet"
+	"github.com/pion/webrtc/v3"
+)
+
+const localPeerMsgChSize = 64
+
+// localPeer is an in-process WebRTC client taking part in a call, e.g. a
+// test call, a bot or a mirror. It signals with the rtc server, local or
+// remote, exactly like a remote client would.
+type localPeer struct {
+	cfg     rtc.SessionConfig
+	pc      *webrtc.PeerConnection
+	sendFn  func(msg rtc.Message) error
+	log     mlog.LoggerIFace
+	msgCh   chan rtc.Message
+	closeCh chan struct{}
+	connCh  chan struct{}
+	// failCh is closed once the connection fails.
+	failCh chan struct{}
+	// readerDoneCh is closed once the message reader has returned.
+	readerDoneCh chan struct{}
+	// negotiateCh requests a new negotiation, e.g. after adding a track
+	// once connected.
+	negotiateCh chan struct{}
+
+	// pendingCandidates holds the remote candidates received before the
+	// remote description was set.
+	pendingCandidates []webrtc.ICECandidateInit
+	// pendingNegotiation is set when a negotiation was requested while
+	// another one was in progress.
+	pendingNegotiation bool
+}
+
+// newLocalPeer creates a peer signaling with the local rtc server.
+func newLocalPeer(cfg rtc.SessionConfig, rtcServer *rtc.Server, log mlog.LoggerIFace) (*localPeer, error) {
+	return newPeer(cfg, rtcServer.Send, rtcServer.VNet(), log)
+}
+
+// newRemotePeer creates a peer signaling with the rtc server of the rtcd
+// instance c is connected to.
+func newRemotePeer(cfg rtc.SessionConfig, c *Client, log mlog.LoggerIFace) (*localPeer, error) {
+	return newPeer(cfg, func(msg rtc.Message) error {
+		return c.Send(ClientMessage{Type: ClientMessageRTC, Data: msg})
+	}, nil, log)
+}
+
+// newPeer creates a peer sending its signaling messages through sendFn. Its
+// media goes through the given virtual network, if any.
+func newPeer(cfg rtc.SessionConfig, sendFn func(msg rtc.Message) error, n *vnet.Net, log mlog.LoggerIFace) (*localPeer, error) {
+	var m webrtc.MediaEngine
+	if err := m.RegisterDefaultCodecs(); err != nil {
+		return nil, fmt.Errorf("failed to register codecs: %w", err)
+	}
+
+	var sEngine webrtc.SettingEngine
+	sEngine.SetICEMulticastDNSMode(ice.MulticastDNSModeDisabled)
+	if n != nil {
+		sEngine.SetVNet(n)
+	}
+
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(&m), webrtc.WithSettingEngine(sEngine))
+	pc, err := api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create peer connection: %w", err)
+	}
+
+	p := &localPeer{
+		cfg:     cfg,
+		pc:      pc,
+		sendFn:  sendFn,
+		log:     log,
+		msgCh:   make(chan rtc.Message, localPeerMsgChSize),
+		closeCh: make(chan struct{}),
+		connCh:  make(chan struct{}),
+		failCh:  make(chan struct{}),
+
+		readerDoneCh: make(chan struct{}),
+		negotiateCh:  make(chan struct{}, 1),
+	}
+
+	var connOnce, failOnce sync.Once
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateConnected:
+			connOnce.Do(func() { close(p.connCh) })
+		case webrtc.PeerConnectionStateFailed:
+			failOnce.Do(func() { close(p.failCh) })
+		}
+	})
+
+	pc.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c == nil {
+			return
+		}
+		data, err := json.Marshal(c.ToJSON())
+		if err != nil {
+			p.log.Error("failed to marshal ICE candidate", mlog.Err(err), mlog.String("sessionID", p.cfg.SessionID))
+			return
+		}
+		if err := p.send(rtc.ICEMessage, data); err != nil {
+			p.log.Error("failed to send ICE candidate", mlog.Err(err), mlog.String("sessionID", p.cfg.SessionID))
+		}
+	})
+
+	go p.msgReader()
+
+	return p, nil
+}
+
+func (p *localPeer) send(msgType rtc.MessageType, data []byte) error {
+	return p.sendFn(rtc.Message{
+		GroupID:   p.cfg.GroupID,
+		UserID:    p.cfg.UserID,
+		SessionID: p.cfg.SessionID,
+		Type:      msgType,
+		Data:      data,
+	})
+}
+
+// offer starts the negotiation from the peer's side.
+func (p *localPeer) offer() error {
+	offer, err := p.pc.CreateOffer(nil)
+	if err != nil {
+		return fmt.Errorf("failed to create offer: %w", err)
+	}
+	if err := p.pc.SetLocalDescription(offer); err != nil {
+		return fmt.Errorf("failed to set local description: %w", err)
+	}
+	data, err := json.Marshal(p.pc.LocalDescription())
+	if err != nil {
+		return fmt.Errorf("failed to marshal sdp: %w", err)
+	}
+	return p.send(rtc.SDPMessage, data)
+}
+
+// push queues a message sent by the rtc server to the peer. Messages are
+// handled in order without blocking the caller.
+func (p *localPeer) push(msg rtc.Message) error {
+	select {
+	case p.msgCh <- msg:
+	default:
+		return fmt.Errorf("failed to push local peer message: channel is full")
+	}
+	return nil
+}
+
+// renegotiate has the peer send a new offer once the ongoing negotiation,
+// if any, completes.
+func (p *localPeer) renegotiate() {
+	select {
+	case p.negotiateCh <- struct{}{}:
+	default:
+	}
+}
+
+func (p *localPeer) msgReader() {
+	defer close(p.readerDoneCh)
+	for {
+		select {
+		case <-p.negotiateCh:
+			if p.pc.SignalingState() != webrtc.SignalingStateStable {
+				p.pendingNegotiation = true
+				continue
+			}
+			if err := p.offer(); err != nil {
+				p.log.Error("failed to renegotiate", mlog.Err(err), mlog.String("sessionID", p.cfg.SessionID))
+			}
+		case msg := <-p.msgCh:
+			if err := p.handleMsg(msg); err != nil {
+				p.log.Error("failed to handle local peer message", mlog.Err(err), mlog.String("sessionID", p.cfg.SessionID))
+			}
+		case <-p.closeCh:
+			return
+		}
+	}
+}
+
+func (p *localPeer) handleMsg(msg rtc.Message) error {
+	// Like remote clients, the message kind is inferred from the payload.
+	var data struct {
+		Type       string                    `json:"type"`
+		Candidate  webrtc.ICECandidateInit   `json:"candidate"`
+		Candidates []webrtc.ICECandidateInit `json:"candidates"`
+		SDP        string                    `json:"sdp"`
+	}
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		return fmt.Errorf("failed to unmarshal message: %w", err)
+	}
+
+	switch data.Type {
+	case "candidate":
+		if p.pc.RemoteDescription() == nil {
+			p.pendingCandidates = append(p.pendingCandidates, data.Candidate)
+			return nil
+		}
+		return p.pc.AddICECandidate(data.Candidate)
+	case "candidates":
+		if p.pc.RemoteDescription() == nil {
+			p.pendingCandidates = append(p.pendingCandidates, data.Candidates...)
+			return nil
+		}
+		for _, c := range data.Candidates {
+			if err := p.pc.AddICECandidate(c); err != nil {
+				return fmt.Errorf("failed to add ICE candidate: %w", err)
+			}
+		}
+		return nil
+	case "offer", "answer":
+		sdp := webrtc.SessionDescription{
+			Type: webrtc.NewSDPType(data.Type),
+			SDP:  data.SDP,
+		}
+		// On glare the server ignores our offer so we roll it back and
+		// offer again once the server's offer is answered.
+		if sdp.Type == webrtc.SDPTypeOffer && p.pc.SignalingState() == webrtc.SignalingStateHaveLocalOffer {
+			if err := p.pc.SetLocalDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeRollback}); err != nil {
+				return fmt.Errorf("failed to rollback local offer: %w", err)
+			}
+			p.pendingNegotiation = true
+		}
+		if err := p.pc.SetRemoteDescription(sdp); err != nil {
+			return fmt.Errorf("failed to set remote description: %w", err)
+		}
+		for _, c := range p.pendingCandidates {
+			if err := p.pc.AddICECandidate(c); err != nil {
+				return fmt.Errorf("failed to add ICE candidate: %w", err)
+			}
+		}
+		p.pendingCandidates = nil
+
+		if sdp.Type != webrtc.SDPTypeOffer {
+			return p.flushNegotiation()
+		}
+
+		answer, err := p.pc.CreateAnswer(nil)
+		if err != nil {
+			return fmt.Errorf("failed to create answer: %w", err)
+		}
+		if err := p.pc.SetLocalDescription(answer); err != nil {
+			return fmt.Errorf("failed to set local description: %w", err)
+		}
+		js, err := json.Marshal(p.pc.LocalDescription())
+		if err != nil {
+			return fmt.Errorf("failed to marshal sdp: %w", err)
+		}
+		if err := p.send(rtc.SDPMessage, js); err != nil {
+			return err
+		}
+		return p.flushNegotiation()
+	default:
+		return fmt.Errorf("unexpected message type: %q", data.Type)
+	}
+}
+
+// flushNegotiation sends the offer for a negotiation requested while the
+// previous one was in progress.
+func (p *localPeer) flushNegotiation() error {
+	if !p.pendingNegotiation {
+		return nil
+	}
+	p.pendingNegotiation = false
+	return p.offer()
+}
+
+func (p *localPeer) close() error {
+	close(p.closeCh)
+	// The peer connection can't be used while closing.
+	<-p.readerDoneCh
+	return p.pc.Close()
+}
+
+func (s *Service) getLocalPeer(sessionID string) *localPeer {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.localPeers[sessionID]
+}
@@ -0,0 +1,292 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/mattermost/rtcd/service/store"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const (
+	authLockoutStoreKeyPrefix = "rtcd:auth_lockout:"
+	// authLockoutCleanupInterval is the interval at which the expired
+	// lockout entries are removed from the store.
+	authLockoutCleanupInterval = time.Minute
+)
+
+var errAuthLockedOut = errors.New("authentication failed: too many failed attempts, try again later")
+
+// authSubject is something failed authentication attempts are tracked for:
+// a client ID, along with the source of the attempts, or a source IP
+// address.
+type authSubject struct {
+	kind  string
+	value string
+	// source identifies where the attempts on a client come from, so that
+	// failing ones can't lock the client out from its other sources.
+	source string
+}
+
+func (sub authSubject) storeKey() string {
+	// Keys are limited to 64 bytes by the store.
+	sum := sha256.Sum256([]byte(sub.kind + "\x00" + sub.value + "\x00" + sub.source))
+	return authLockoutStoreKeyPrefix + base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// authSubjects returns the subjects of an authentication attempt: the
+// claimed client from the source IP address, and the address itself. Either
+// value can be empty.
+func authSubjects(clientID, remoteAddr string) []authSubject {
+	var subjects []authSubject
+	ip := remoteIP(remoteAddr)
+	if clientID != "" {
+		sub := authSubject{kind: "client", value: clientID}
+		if ip != "" {
+			sub.source = "ip:" + ip
+		}
+		subjects = append(subjects, sub)
+	}
+	if ip != "" {
+		subjects = append(subjects, authSubject{kind: "ip", value: ip})
+	}
+	return subjects
+}
+
+// groupAuthSubjects returns the subjects of the authentication of a group
+// over the signaling connection of the given client.
+func groupAuthSubjects(groupID, clientID string) []authSubject {
+	return []authSubject{{kind: "client", value: groupID, source: "client:" + clientID}}
+}
+
+func remoteIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return strings.Trim(remoteAddr, "[]")
+	}
+	return host
+}
+
+// authLockout is the state of the failed authentication attempts of a
+// subject, as persisted in the store.
+type authLockout struct {
+	// Failures is the number of consecutive failed attempts.
+	Failures int `json:"failures"`
+	// LockedUntil is the time, in seconds since the Unix epoch, attempts are
+	// rejected until.
+	LockedUntil int64 `json:"locked_until,omitempty"`
+	// UpdatedAt is the time, in seconds since the Unix epoch, of the last
+	// failed attempt.
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// isExpired returns whether the entry can be discarded: it's not locked
+// and the last failure is older than the longest lockout.
+func (l authLockout) isExpired(now time.Time, maxDuration time.Duration) bool {
+	return now.Unix() >= l.LockedUntil && now.Sub(time.Unix(l.UpdatedAt, 0)) >= maxDuration
+}
+
+func (s *Service) getAuthLockout(key string) (authLockout, error) {
+	var l authLockout
+	val, err := s.store.Get(key)
+	if err != nil {
+		return l, err
+	}
+	if err := json.Unmarshal([]byte(val), &l); err != nil {
+		return l, fmt.Errorf("failed to unmarshal lockout: %w", err)
+	}
+	return l, nil
+}
+
+// checkAuthLockout returns errAuthLockedOut, along with the time left, if
+// any of the subjects of an authentication attempt is locked out.
+func (s *Service) checkAuthLockout(clientID, remoteAddr string) (time.Duration, error) {
+	return s.checkLockout(authSubjects(clientID, remoteAddr))
+}
+
+func (s *Service) checkLockout(subjects []authSubject) (time.Duration, error) {
+	if s.cfg.API.Security.AuthLockout.MaxFailedAttempts == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, sub := range subjects {
+		l, err := s.getAuthLockout(sub.storeKey())
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		} else if err != nil {
+			s.log.Error("failed to get auth lockout", mlog.Err(err))
+			continue
+		}
+		if d := time.Unix(l.LockedUntil, 0).Sub(now); d > retryAfter {
+			retryAfter = d
+		}
+	}
+
+	if retryAfter > 0 {
+		s.metrics.IncAuthFailures("locked")
+		return retryAfter, errAuthLockedOut
+	}
+
+	return 0, nil
+}
+
+// recordAuthFailure tracks a failed authentication attempt, locking out
+// its subjects once they reach the maximum number of failed attempts. The
+// lockout doubles with every further failure. reqID identifies the attempt
+// in the audit log, a new one is generated if empty.
+func (s *Service) recordAuthFailure(clientID, remoteAddr, reqID string) {
+	s.recordFailure(authSubjects(clientID, remoteAddr), reqID)
+}
+
+func (s *Service) recordFailure(subjects []authSubject, reqID string) {
+	s.metrics.IncAuthFailures("invalid")
+
+	cfg := s.cfg.API.Security.AuthLockout
+	if cfg.MaxFailedAttempts == 0 {
+		return
+	}
+
+	baseDuration := time.Duration(cfg.BaseDurationSeconds) * time.Second
+	maxDuration := time.Duration(cfg.MaxDurationSeconds) * time.Second
+	now := time.Now()
+
+	s.authLockoutMut.Lock()
+	defer s.authLockoutMut.Unlock()
+
+	for _, sub := range subjects {
+		key := sub.storeKey()
+		l, err := s.getAuthLockout(key)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			s.log.Error("failed to get auth lockout", mlog.Err(err))
+			continue
+		}
+		if l.isExpired(now, maxDuration) {
+			l = authLockout{}
+		}
+
+		l.Failures++
+		l.UpdatedAt = now.Unix()
+		if excess := l.Failures - cfg.MaxFailedAttempts; excess >= 0 {
+			duration := maxDuration
+			// Guarding against overflows.
+			if excess < 32 && baseDuration<<excess < maxDuration {
+				duration = baseDuration << excess
+			}
+			l.LockedUntil = now.Add(duration).Unix()
+
+			s.metrics.IncAuthLockouts(sub.kind)
+			s.log.Warn("locking out after too many failed authentication attempts",
+				mlog.String("type", sub.kind),
+				mlog.String("value", sub.value),
+				mlog.String("source", sub.source),
+				mlog.Int("failures", l.Failures),
+				mlog.Int64("durationSeconds", int64(duration.Seconds())),
+			)
+			s.auditLog("authLockout", "", requestID(reqID), "success",
+				mlog.String("type", sub.kind),
+				mlog.String("value", sub.value),
+				mlog.String("source", sub.source),
+				mlog.Int("failures", l.Failures),
+				mlog.Int64("lockedUntil", l.LockedUntil),
+			)
+		}
+
+		js, err := json.Marshal(l)
+		if err != nil {
+			s.log.Error("failed to marshal auth lockout", mlog.Err(err))
+			continue
+		}
+		if err := s.store.Set(key, string(js)); err != nil {
+			s.log.Error("failed to store auth lockout", mlog.Err(err))
+		}
+	}
+}
+
+// resetAuthFailures clears the failed attempts of the subjects of a
+// successful authentication attempt.
+func (s *Service) resetAuthFailures(clientID, remoteAddr string) {
+	s.resetFailures(authSubjects(clientID, remoteAddr))
+}
+
+func (s *Service) resetFailures(subjects []authSubject) {
+	if s.cfg.API.Security.AuthLockout.MaxFailedAttempts == 0 {
+		return
+	}
+
+	s.authLockoutMut.Lock()
+	defer s.authLockoutMut.Unlock()
+
+	for _, sub := range subjects {
+		// Checking first as deletions are synced to disk.
+		key := sub.storeKey()
+		if _, err := s.store.Get(key); errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err := s.store.Delete(key); err != nil && !errors.Is(err, store.ErrNotFound) {
+			s.log.Error("failed to delete auth lockout", mlog.Err(err))
+		}
+	}
+}
+
+// removeExpiredAuthLockouts deletes the expired lockout entries from the
+// store.
+func (s *Service) removeExpiredAuthLockouts() error {
+	keys, err := s.store.Keys()
+	if err != nil {
+		return fmt.Errorf("failed to get keys: %w", err)
+	}
+
+	maxDuration := time.Duration(s.cfg.API.Security.AuthLockout.MaxDurationSeconds) * time.Second
+	now := time.Now()
+
+	s.authLockoutMut.Lock()
+	defer s.authLockoutMut.Unlock()
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, authLockoutStoreKeyPrefix) {
+			continue
+		}
+		l, err := s.getAuthLockout(key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		} else if err == nil && !l.isExpired(now, maxDuration) {
+			continue
+		}
+		if err := s.store.Delete(key); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("failed to delete key: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// runAuthLockoutCleanup periodically removes the expired lockout entries.
+func (s *Service) runAuthLockoutCleanup(interval time.Duration) {
+	defer close(s.authLockoutDoneCh)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.removeExpiredAuthLockouts(); err != nil {
+				s.log.Error("failed to remove expired auth lockouts", mlog.Err(err))
+			}
+		case <-s.authLockoutStopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthLockout(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.API.Security.AuthLockout = AuthLockoutConfig{
+		MaxFailedAttempts:   3,
+		BaseDurationSeconds: 10,
+		MaxDurationSeconds:  30,
+	}
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	authKey := "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H"
+	registerClient(t, th, "clientA", authKey)
+
+	doRequest := func(t *testing.T, key string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest("POST", th.apiURL+"/join_token", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("clientA", key)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	clientKey := authSubject{kind: "client", value: "clientA", source: "ip:127.0.0.1"}.storeKey()
+	ipKey := authSubject{kind: "ip", value: "127.0.0.1"}.storeKey()
+
+	getLockout := func(t *testing.T, key string) authLockout {
+		t.Helper()
+		l, err := th.srvc.getAuthLockout(key)
+		require.NoError(t, err)
+		return l
+	}
+
+	unlock := func(t *testing.T) {
+		t.Helper()
+		for _, key := range []string{clientKey, ipKey} {
+			l := getLockout(t, key)
+			l.LockedUntil = time.Now().Add(-time.Second).Unix()
+			js, err := json.Marshal(l)
+			require.NoError(t, err)
+			require.NoError(t, th.srvc.store.Set(key, string(js)))
+		}
+	}
+
+	t.Run("lockout", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			require.Equal(t, http.StatusUnauthorized, doRequest(t, "invalid").StatusCode)
+		}
+
+		resp := doRequest(t, authKey)
+		require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		require.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+		l := getLockout(t, clientKey)
+		require.Equal(t, 3, l.Failures)
+		require.Equal(t, int64(10), l.LockedUntil-l.UpdatedAt)
+		require.Equal(t, l, getLockout(t, ipKey))
+	})
+
+	t.Run("exponential", func(t *testing.T) {
+		unlock(t)
+		require.Equal(t, http.StatusUnauthorized, doRequest(t, "invalid").StatusCode)
+		l := getLockout(t, clientKey)
+		require.Equal(t, 4, l.Failures)
+		require.Equal(t, int64(20), l.LockedUntil-l.UpdatedAt)
+
+		// Capped at the max duration.
+		unlock(t)
+		require.Equal(t, http.StatusUnauthorized, doRequest(t, "invalid").StatusCode)
+		l = getLockout(t, clientKey)
+		require.Equal(t, 5, l.Failures)
+		require.Equal(t, int64(30), l.LockedUntil-l.UpdatedAt)
+	})
+
+	t.Run("reset on success", func(t *testing.T) {
+		unlock(t)
+		// Authenticated, join tokens being issued to admins only.
+		require.Equal(t, http.StatusForbidden, doRequest(t, authKey).StatusCode)
+
+		_, err := th.srvc.store.Get(clientKey)
+		require.ErrorIs(t, err, store.ErrNotFound)
+		_, err = th.srvc.store.Get(ipKey)
+		require.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, doRequest(t, "invalid").StatusCode)
+		require.NoError(t, th.srvc.removeExpiredAuthLockouts())
+		getLockout(t, clientKey)
+
+		l := getLockout(t, ipKey)
+		l.UpdatedAt = time.Now().Add(-time.Minute).Unix()
+		js, err := json.Marshal(l)
+		require.NoError(t, err)
+		require.NoError(t, th.srvc.store.Set(ipKey, string(js)))
+
+		require.NoError(t, th.srvc.removeExpiredAuthLockouts())
+		getLockout(t, clientKey)
+		_, err = th.srvc.store.Get(ipKey)
+		require.ErrorIs(t, err, store.ErrNotFound)
+	})
+}
+
+func TestAuthLockoutPerSource(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.API.Security.AuthLockout = AuthLockoutConfig{
+		MaxFailedAttempts:   3,
+		BaseDurationSeconds: 10,
+		MaxDurationSeconds:  30,
+	}
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	authKey := "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H"
+	registerClient(t, th, "clientA", authKey)
+
+	authenticate := func(t *testing.T, remoteAddr, key string) int {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/join_token", nil)
+		r.RemoteAddr = remoteAddr
+		r.SetBasicAuth("clientA", key)
+		_, code, _ := th.srvc.authHandler(httptest.NewRecorder(), r)
+		return code
+	}
+
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusUnauthorized, authenticate(t, "10.0.0.1:1234", "invalid"))
+	}
+	require.Equal(t, http.StatusTooManyRequests, authenticate(t, "10.0.0.1:1234", authKey))
+
+	// The client isn't locked out from another address.
+	require.Equal(t, http.StatusOK, authenticate(t, "10.0.0.2:1234", authKey))
+
+	t.Run("group auth", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			th.srvc.recordFailure(groupAuthSubjects("clientA", "clientB"), "")
+		}
+		_, err := th.srvc.checkLockout(groupAuthSubjects("clientA", "clientB"))
+		require.ErrorIs(t, err, errAuthLockedOut)
+
+		// Neither from another client nor from the client's own connections.
+		_, err = th.srvc.checkLockout(groupAuthSubjects("clientA", "clientC"))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, authenticate(t, "10.0.0.3:1234", authKey))
+	})
+}
+
+func TestAuthLockoutConfigIsValid(t *testing.T) {
+	require.NoError(t, AuthLockoutConfig{}.IsValid())
+	require.NoError(t, AuthLockoutConfig{MaxFailedAttempts: 5, BaseDurationSeconds: 10, MaxDurationSeconds: 10}.IsValid())
+	require.EqualError(t, AuthLockoutConfig{MaxFailedAttempts: -1}.IsValid(),
+		"invalid MaxFailedAttempts value: should not be negative")
+	require.EqualError(t, AuthLockoutConfig{MaxFailedAttempts: 5}.IsValid(),
+		"invalid BaseDurationSeconds value: should be a positive number")
+	require.EqualError(t, AuthLockoutConfig{MaxFailedAttempts: 5, BaseDurationSeconds: 10, MaxDurationSeconds: 5}.IsValid(),
+		"invalid MaxDurationSeconds value: should not be lower than BaseDurationSeconds")
+}
@@ -0,0 +1,366 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/signaling"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// maintenanceDefaultNotifyMinutes is how long before a maintenance window
+// clients and participants get notified of it, unless requested otherwise.
+const maintenanceDefaultNotifyMinutes = 10
+
+// MaintenanceInfo describes the scheduled maintenance window of the node.
+type MaintenanceInfo struct {
+	ID     string `json:"id"`
+	Action string `json:"action"`
+	// StartAt and NotifyAt are the times, in unix milliseconds, the window
+	// starts at and clients get notified at.
+	StartAt  int64 `json:"start_at"`
+	NotifyAt int64 `json:"notify_at"`
+	Notified bool  `json:"notified"`
+}
+
+// maintenanceWindow is a scheduled maintenance of the node, along with the
+// timers driving it.
+type maintenanceWindow struct {
+	info        MaintenanceInfo
+	notifyTimer *time.Timer
+	startTimer  *time.Timer
+}
+
+func (w *maintenanceWindow) notice() rtc.MaintenanceNotice {
+	return rtc.MaintenanceNotice{
+		ID:      w.info.ID,
+		Action:  w.info.Action,
+		StartAt: w.info.StartAt,
+	}
+}
+
+// StopRequested returns a channel closed once a scheduled maintenance
+// requires the service to be stopped.
+func (s *Service) StopRequested() <-chan struct{} {
+	return s.maintenanceStopCh
+}
+
+func (s *Service) getMaintenance() (MaintenanceInfo, bool) {
+	s.maintenanceMut.Lock()
+	defer s.maintenanceMut.Unlock()
+	if s.maintenance == nil {
+		return MaintenanceInfo{}, false
+	}
+	return s.maintenance.info, true
+}
+
+// scheduleMaintenance schedules the given action at startAt, notifying
+// clients and participants notifyBefore ahead. It replaces the window
+// previously scheduled, if any.
+func (s *Service) scheduleMaintenance(action string, startAt time.Time, notifyBefore time.Duration) (MaintenanceInfo, error) {
+	if action != rtc.MaintenanceActionDrain && action != rtc.MaintenanceActionShutdown {
+		return MaintenanceInfo{}, fmt.Errorf("invalid action value: should be %s or %s",
+			rtc.MaintenanceActionDrain, rtc.MaintenanceActionShutdown)
+	}
+	now := time.Now()
+	if !startAt.After(now) {
+		return MaintenanceInfo{}, errors.New("invalid startAt value: should be in the future")
+	}
+	if notifyBefore < 0 {
+		return MaintenanceInfo{}, errors.New("invalid notifyMinutes value: should not be negative")
+	}
+
+	notifyAt := startAt.Add(-notifyBefore)
+	if notifyAt.Before(now) {
+		notifyAt = now
+	}
+	w := &maintenanceWindow{
+		info: MaintenanceInfo{
+			ID:       random.NewID(),
+			Action:   action,
+			StartAt:  startAt.UnixMilli(),
+			NotifyAt: notifyAt.UnixMilli(),
+		},
+	}
+
+	info := w.info
+
+	s.maintenanceMut.Lock()
+	prev := s.maintenance
+	s.maintenance = w
+	prevNotified := false
+	if prev != nil {
+		prev.notifyTimer.Stop()
+		prev.startTimer.Stop()
+		prevNotified = prev.info.Notified
+	}
+	w.notifyTimer = time.AfterFunc(notifyAt.Sub(now), func() {
+		s.notifyMaintenance(w)
+	})
+	w.startTimer = time.AfterFunc(startAt.Sub(now), func() {
+		s.startMaintenance(w)
+	})
+	s.maintenanceMut.Unlock()
+
+	// Participants told about the previous window would otherwise still
+	// expect it until notified of this one.
+	if prevNotified {
+		notice := prev.notice()
+		notice.Cancelled = true
+		s.sendMaintenanceNotice(notice)
+	}
+
+	s.log.Info("maintenance scheduled",
+		mlog.String("maintenanceID", info.ID),
+		mlog.String("action", action),
+		mlog.String("startAt", startAt.UTC().Format(time.RFC3339)),
+		mlog.String("notifyAt", notifyAt.UTC().Format(time.RFC3339)))
+
+	return info, nil
+}
+
+// cancelMaintenance cancels the scheduled maintenance window, notifying the
+// clients and participants already told about it. It returns false if none
+// was scheduled.
+func (s *Service) cancelMaintenance() (MaintenanceInfo, bool) {
+	s.maintenanceMut.Lock()
+	w := s.maintenance
+	s.maintenance = nil
+	if w != nil {
+		w.notifyTimer.Stop()
+		w.startTimer.Stop()
+	}
+	s.maintenanceMut.Unlock()
+
+	if w == nil {
+		return MaintenanceInfo{}, false
+	}
+
+	if w.info.Notified {
+		notice := w.notice()
+		notice.Cancelled = true
+		s.sendMaintenanceNotice(notice)
+	}
+
+	s.log.Info("maintenance cancelled", mlog.String("maintenanceID", w.info.ID))
+
+	return w.info, true
+}
+
+// stopMaintenance stops the timers of the scheduled maintenance window, if
+// any, without notifying anyone.
+func (s *Service) stopMaintenance() {
+	s.maintenanceMut.Lock()
+	defer s.maintenanceMut.Unlock()
+	if s.maintenance != nil {
+		s.maintenance.notifyTimer.Stop()
+		s.maintenance.startTimer.Stop()
+		s.maintenance = nil
+	}
+}
+
+func (s *Service) notifyMaintenance(w *maintenanceWindow) {
+	s.maintenanceMut.Lock()
+	if s.maintenance != w {
+		// Cancelled or replaced in the meantime.
+		s.maintenanceMut.Unlock()
+		return
+	}
+	w.info.Notified = true
+	s.maintenanceMut.Unlock()
+
+	notified := s.sendMaintenanceNotice(w.notice())
+	s.log.Info("maintenance notified", mlog.String("maintenanceID", w.info.ID),
+		mlog.Int("notifiedConns", notified))
+}
+
+func (s *Service) startMaintenance(w *maintenanceWindow) {
+	s.maintenanceMut.Lock()
+	if s.maintenance != w {
+		s.maintenanceMut.Unlock()
+		return
+	}
+	s.maintenance = nil
+	s.maintenanceMut.Unlock()
+
+	s.log.Info("maintenance starting", mlog.String("maintenanceID", w.info.ID),
+		mlog.String("action", w.info.Action))
+
+	switch w.info.Action {
+	case rtc.MaintenanceActionDrain:
+		s.drain()
+	case rtc.MaintenanceActionShutdown:
+		s.maintenanceStopOnce.Do(func() {
+			close(s.maintenanceStopCh)
+		})
+	}
+}
+
+func newMaintenanceMessage(notice rtc.MaintenanceNotice) ([]byte, error) {
+	return NewPackedClientMessage(ClientMessageMaintenance, signaling.MaintenanceData{
+		ID:        notice.ID,
+		Action:    notice.Action,
+		StartAt:   notice.StartAt,
+		Cancelled: notice.Cancelled,
+	}.Map())
+}
+
+// sendMaintenanceNotice notifies the connected clients supporting the
+// maintenance capability, and the participants of the calls, of the given
+// notice. It returns the number of connections notified.
+func (s *Service) sendMaintenanceNotice(notice rtc.MaintenanceNotice) int {
+	s.rtcServer.SetMaintenanceNotice(notice)
+
+	type conn struct {
+		connID   string
+		clientID string
+	}
+	var conns []conn
+	s.mut.RLock()
+	for connID, info := range s.connProtocols {
+		if info.hasCapability(CapabilityMaintenance) {
+			conns = append(conns, conn{connID: connID, clientID: info.clientID})
+		}
+	}
+	s.mut.RUnlock()
+
+	if len(conns) == 0 {
+		return 0
+	}
+
+	data, err := newMaintenanceMessage(notice)
+	if err != nil {
+		s.log.Error("failed to pack maintenance message", mlog.Err(err))
+		return 0
+	}
+
+	var notified int
+	for _, c := range conns {
+		if err := s.sendClientMessage(c.connID, c.clientID, data); err != nil {
+			s.log.Error("failed to send maintenance message", mlog.Err(err), mlog.String("connID", c.connID))
+			continue
+		}
+		notified++
+	}
+
+	return notified
+}
+
+// sendMaintenanceNoticeToConn notifies a newly negotiated connection of the
+// upcoming maintenance window, if clients were already notified of it.
+func (s *Service) sendMaintenanceNoticeToConn(connID, clientID string) {
+	s.maintenanceMut.Lock()
+	w := s.maintenance
+	notified := w != nil && w.info.Notified
+	s.maintenanceMut.Unlock()
+	if !notified || !s.getConnProtocol(connID).hasCapability(CapabilityMaintenance) {
+		return
+	}
+
+	data, err := newMaintenanceMessage(w.notice())
+	if err != nil {
+		s.log.Error("failed to pack maintenance message", mlog.Err(err))
+		return
+	}
+	if err := s.sendClientMessage(connID, clientID, data); err != nil {
+		s.log.Error("failed to send maintenance message", mlog.Err(err), mlog.String("connID", connID))
+	}
+}
+
+// handleMaintenance returns (GET), schedules (POST) or cancels (DELETE) the
+// maintenance window of the node.
+func (s *Service) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleMaintenance", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	var info MaintenanceInfo
+	switch r.Method {
+	case http.MethodGet:
+		var ok bool
+		if info, ok = s.getMaintenance(); !ok {
+			data.err = "no maintenance scheduled"
+			data.code = http.StatusNotFound
+			return
+		}
+	case http.MethodDelete:
+		var ok bool
+		if info, ok = s.cancelMaintenance(); !ok {
+			data.err = "no maintenance scheduled"
+			data.code = http.StatusNotFound
+			return
+		}
+	case http.MethodPost:
+		if s.checkIdempotencyKey("handleMaintenance", data, w, r) {
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+			data.err = err.Error()
+			data.code = http.StatusBadRequest
+			return
+		}
+
+		startAt, err := time.Parse(time.RFC3339, data.reqData["startAt"])
+		if err != nil {
+			data.err = "invalid startAt value: should be an RFC 3339 time"
+			data.code = http.StatusBadRequest
+			return
+		}
+
+		notifyMinutes := maintenanceDefaultNotifyMinutes
+		if val := data.reqData["notifyMinutes"]; val != "" {
+			if notifyMinutes, err = strconv.Atoi(val); err != nil {
+				data.err = "invalid notifyMinutes value"
+				data.code = http.StatusBadRequest
+				return
+			}
+		}
+
+		action := data.reqData["action"]
+		if action == "" {
+			action = rtc.MaintenanceActionShutdown
+		}
+
+		info, err = s.scheduleMaintenance(action, startAt, time.Duration(notifyMinutes)*time.Minute)
+		if err != nil {
+			data.err = err.Error()
+			data.code = http.StatusBadRequest
+			return
+		}
+	}
+
+	js, err := json.Marshal(info)
+	if err != nil {
+		data.err = "failed to marshal maintenance info: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	data.code = http.StatusOK
+	data.resData["maintenance"] = string(js)
+}
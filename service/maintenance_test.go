@@ -0,0 +1,261 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceHandler(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	doRequest := func(t *testing.T, method, body string) (int, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest(method, th.apiURL+"/admin/maintenance", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp.StatusCode, response
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/maintenance", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("not scheduled", func(t *testing.T) {
+		code, response := doRequest(t, "GET", "")
+		require.Equal(t, http.StatusNotFound, code)
+		require.Equal(t, "no maintenance scheduled", response["error"])
+
+		code, response = doRequest(t, "DELETE", "")
+		require.Equal(t, http.StatusNotFound, code)
+		require.Equal(t, "no maintenance scheduled", response["error"])
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		startAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		for _, tc := range []struct {
+			body string
+			err  string
+		}{
+			{`{"startAt": "tomorrow"}`, "invalid startAt value: should be an RFC 3339 time"},
+			{`{"startAt": "2020-01-01T00:00:00Z"}`, "invalid startAt value: should be in the future"},
+			{`{"startAt": "` + startAt + `", "notifyMinutes": "ten"}`, "invalid notifyMinutes value"},
+			{`{"startAt": "` + startAt + `", "notifyMinutes": "-1"}`, "invalid notifyMinutes value: should not be negative"},
+			{`{"startAt": "` + startAt + `", "action": "reboot"}`, "invalid action value: should be drain or shutdown"},
+		} {
+			code, response := doRequest(t, "POST", tc.body)
+			require.Equal(t, http.StatusBadRequest, code)
+			require.Equal(t, tc.err, response["error"])
+		}
+	})
+
+	t.Run("scheduled", func(t *testing.T) {
+		startAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		code, response := doRequest(t, "POST", `{"startAt": "`+startAt.UTC().Format(time.RFC3339)+`", "notifyMinutes": "15"}`)
+		require.Equal(t, http.StatusOK, code)
+		var info MaintenanceInfo
+		require.NoError(t, json.Unmarshal([]byte(response["maintenance"]), &info))
+		require.NotEmpty(t, info.ID)
+		require.Equal(t, rtc.MaintenanceActionShutdown, info.Action)
+		require.Equal(t, startAt.UnixMilli(), info.StartAt)
+		require.Equal(t, startAt.Add(-15*time.Minute).UnixMilli(), info.NotifyAt)
+		require.False(t, info.Notified)
+
+		code, response = doRequest(t, "GET", "")
+		require.Equal(t, http.StatusOK, code)
+		var scheduled MaintenanceInfo
+		require.NoError(t, json.Unmarshal([]byte(response["maintenance"]), &scheduled))
+		require.Equal(t, info, scheduled)
+
+		// Scheduling again replaces the window.
+		code, response = doRequest(t, "POST", `{"startAt": "`+startAt.UTC().Format(time.RFC3339)+`", "action": "drain"}`)
+		require.Equal(t, http.StatusOK, code)
+		require.NoError(t, json.Unmarshal([]byte(response["maintenance"]), &scheduled))
+		require.NotEqual(t, info.ID, scheduled.ID)
+		require.Equal(t, rtc.MaintenanceActionDrain, scheduled.Action)
+		require.Equal(t, startAt.Add(-maintenanceDefaultNotifyMinutes*time.Minute).UnixMilli(), scheduled.NotifyAt)
+
+		code, _ = doRequest(t, "DELETE", "")
+		require.Equal(t, http.StatusOK, code)
+		code, _ = doRequest(t, "GET", "")
+		require.Equal(t, http.StatusNotFound, code)
+	})
+}
+
+func TestMaintenanceNotice(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	clientID := "clientA"
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = th.adminClient.Register(clientID, authKey)
+	require.NoError(t, err)
+
+	newClient := func(t *testing.T) (*Client, chan rtc.MaintenanceNotice) {
+		t.Helper()
+		c, err := NewClient(ClientConfig{
+			URL:      th.apiURL,
+			ClientID: clientID,
+			AuthKey:  authKey,
+		})
+		require.NoError(t, err)
+		noticeCh := make(chan rtc.MaintenanceNotice, 4)
+		c.OnMaintenance(func(notice rtc.MaintenanceNotice) {
+			noticeCh <- notice
+		})
+		require.NoError(t, c.Connect())
+		msg := <-c.ReceiveCh()
+		require.Equal(t, ClientMessageHello, msg.Type)
+		return c, noticeCh
+	}
+
+	waitNotice := func(t *testing.T, noticeCh chan rtc.MaintenanceNotice) rtc.MaintenanceNotice {
+		t.Helper()
+		select {
+		case notice := <-noticeCh:
+			return notice
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "timed out waiting for maintenance notice")
+		}
+		return rtc.MaintenanceNotice{}
+	}
+
+	c, noticeCh := newClient(t)
+	defer c.Close()
+	require.Eventually(t, func() bool {
+		th.srvc.mut.RLock()
+		defer th.srvc.mut.RUnlock()
+		return len(th.srvc.connProtocols) > 0
+	}, time.Second, 10*time.Millisecond)
+	require.True(t, c.HasCapability(CapabilityMaintenance))
+
+	rtcNoticeCh := make(chan rtc.MaintenanceNotice, 4)
+	c.OnRTCMessage(func(msg rtc.Message) {
+		if msg.Type != rtc.MaintenanceMessage {
+			return
+		}
+		var notice rtc.MaintenanceNotice
+		if err := json.Unmarshal(msg.Data, &notice); err == nil {
+			rtcNoticeCh <- notice
+		}
+	})
+	err = c.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+		"callID":    "callID",
+		"userID":    "userID",
+		"sessionID": "sessionID",
+	}))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		th.srvc.mut.RLock()
+		defer th.srvc.mut.RUnlock()
+		return th.srvc.connMap["sessionID"] != ""
+	}, time.Second, 10*time.Millisecond)
+
+	// Not notified yet.
+	startAt := time.Now().Add(time.Hour)
+	_, err = th.srvc.scheduleMaintenance(rtc.MaintenanceActionShutdown, startAt, 30*time.Minute)
+	require.NoError(t, err)
+	select {
+	case <-noticeCh:
+		require.Fail(t, "unexpected maintenance notice")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Notified right away.
+	info, err := th.srvc.scheduleMaintenance(rtc.MaintenanceActionShutdown, startAt, 2*time.Hour)
+	require.NoError(t, err)
+	notice := waitNotice(t, noticeCh)
+	require.Equal(t, rtc.MaintenanceNotice{
+		ID:      info.ID,
+		Action:  rtc.MaintenanceActionShutdown,
+		StartAt: startAt.UnixMilli(),
+	}, notice)
+	require.Equal(t, notice, waitNotice(t, rtcNoticeCh))
+	info, ok := th.srvc.getMaintenance()
+	require.True(t, ok)
+	require.True(t, info.Notified)
+
+	t.Run("late connection", func(t *testing.T) {
+		c2, noticeCh2 := newClient(t)
+		defer c2.Close()
+		require.Equal(t, notice, waitNotice(t, noticeCh2))
+	})
+
+	t.Run("late session", func(t *testing.T) {
+		err = c.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+			"callID":    "callID",
+			"userID":    "userB",
+			"sessionID": "sessionB",
+		}))
+		require.NoError(t, err)
+		require.Equal(t, notice, waitNotice(t, rtcNoticeCh))
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		_, ok := th.srvc.cancelMaintenance()
+		require.True(t, ok)
+		cancelled := notice
+		cancelled.Cancelled = true
+		require.Equal(t, cancelled, waitNotice(t, noticeCh))
+		// One per session.
+		require.Equal(t, cancelled, waitNotice(t, rtcNoticeCh))
+		require.Equal(t, cancelled, waitNotice(t, rtcNoticeCh))
+	})
+}
+
+func TestMaintenanceStart(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	t.Run("shutdown", func(t *testing.T) {
+		_, err := th.srvc.scheduleMaintenance(rtc.MaintenanceActionShutdown, time.Now().Add(100*time.Millisecond), 0)
+		require.NoError(t, err)
+		select {
+		case <-th.srvc.StopRequested():
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "timed out waiting for stop request")
+		}
+		_, ok := th.srvc.getMaintenance()
+		require.False(t, ok)
+		require.False(t, th.srvc.rtcServer.IsDraining())
+	})
+
+	t.Run("drain", func(t *testing.T) {
+		_, err := th.srvc.scheduleMaintenance(rtc.MaintenanceActionDrain, time.Now().Add(100*time.Millisecond), 0)
+		require.NoError(t, err)
+		require.Eventually(t, th.srvc.rtcServer.IsDraining, 2*time.Second, 10*time.Millisecond)
+
+		resp, err := http.Get(th.apiURL + "/readyz")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		var res readyzResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		require.False(t, res.Ready)
+		require.True(t, res.Draining)
+	})
+}
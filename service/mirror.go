@@ -0,0 +1,696 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	mirrorUserIDPrefix = "rtcd-mirror-"
+	// The reasons reported for a mirror to stop.
+	mirrorStopReasonStopped   = "stopped"
+	mirrorStopReasonCallEnded = "call ended"
+	mirrorStopReasonClosed    = "session closed"
+	mirrorStopReasonShutdown  = "shutdown"
+	// mirrorMaxRetryInterval caps the time waited before re-establishing a
+	// failed link.
+	mirrorMaxRetryInterval = time.Minute
+	mirrorConnectTimeout   = 30 * time.Second
+)
+
+var errMirrorLinkClosed = errors.New("mirror link closed")
+
+// MirrorConfig holds the settings of a call mirror.
+type MirrorConfig struct {
+	GroupID string
+	CallID  string
+	// URL, ClientID and AuthKey are the address of the target rtcd instance
+	// and the credentials of a client registered on it.
+	URL      string
+	ClientID string
+	AuthKey  string
+	// TargetCallID is the ID of the call on the target instance. It
+	// defaults to CallID.
+	TargetCallID string
+}
+
+func (c MirrorConfig) IsValid() error {
+	if c.CallID == "" {
+		return fmt.Errorf("invalid CallID value: should not be empty")
+	}
+	if c.URL == "" {
+		return fmt.Errorf("invalid URL value: should not be empty")
+	}
+	if c.ClientID == "" {
+		return fmt.Errorf("invalid ClientID value: should not be empty")
+	}
+	if c.AuthKey == "" {
+		return fmt.Errorf("invalid AuthKey value: should not be empty")
+	}
+	return nil
+}
+
+// MirrorInfo describes a running or stopped mirror.
+type MirrorInfo struct {
+	ID      string `json:"id"`
+	GroupID string `json:"group_id"`
+	CallID  string `json:"call_id"`
+	// SessionID is the ID of the hidden session receiving the tracks of the
+	// mirrored call.
+	SessionID      string `json:"session_id"`
+	URL            string `json:"url"`
+	TargetClientID string `json:"target_client_id"`
+	TargetCallID   string `json:"target_call_id"`
+	// TargetSessionID is the ID of the session publishing the tracks on the
+	// target instance, if linked.
+	TargetSessionID string `json:"target_session_id,omitempty"`
+	Connected       bool   `json:"connected"`
+	// Attempts is the number of links opened to the target instance.
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+	StartedAt int64  `json:"started_at"`
+	StoppedAt int64  `json:"stopped_at,omitempty"`
+	// Reason is why the mirror was stopped.
+	Reason string     `json:"reason,omitempty"`
+	Stats  MediaStats `json:"stats"`
+}
+
+// mirrorTrack is a track of the mirrored call along with the track it is
+// forwarded to.
+type mirrorTrack struct {
+	remote *webrtc.TrackRemote
+	local  *webrtc.TrackLocalStaticRTP
+}
+
+func (t *mirrorTrack) isVideo() bool {
+	return t.remote.Kind() == webrtc.RTPCodecTypeVideo
+}
+
+// mirrorLink is a signaling connection and session on the target instance.
+// A failed link is replaced by a new one, the mirrored call is unaffected.
+type mirrorLink struct {
+	svc     *Client
+	peer    *localPeer
+	senders map[*mirrorTrack]*webrtc.RTPSender
+
+	failOnce sync.Once
+	failCh   chan struct{}
+	failErr  error
+	closed   bool
+}
+
+// fail marks the link as failed for the given reason. It doesn't block.
+func (l *mirrorLink) fail(err error) {
+	l.failOnce.Do(func() {
+		l.failErr = err
+		close(l.failCh)
+	})
+}
+
+// mirror forwards the tracks of a call to a session on another rtcd
+// instance.
+type mirror struct {
+	cfg    MirrorConfig
+	info   MirrorInfo
+	src    *localPeer
+	tracks map[*mirrorTrack]bool
+	link   *mirrorLink
+
+	mediaCounters
+
+	mut      sync.Mutex
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	reason   string
+}
+
+// requestStop asks the mirror to stop for the given reason. Only the first
+// reason is kept. It doesn't block.
+func (m *mirror) requestStop(reason string) {
+	m.stopOnce.Do(func() {
+		m.mut.Lock()
+		m.reason = reason
+		m.mut.Unlock()
+		close(m.stopCh)
+	})
+}
+
+func (m *mirror) getInfo() MirrorInfo {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	info := m.info
+	info.Stats = m.getStats()
+	return info
+}
+
+// startMirror starts mirroring the call described by cfg, returning the
+// mirror info once the session receiving the tracks is initialized. The
+// link to the target instance is established in the background.
+func (s *Service) startMirror(cfg MirrorConfig) (MirrorInfo, error) {
+	if err := cfg.IsValid(); err != nil {
+		return MirrorInfo{}, err
+	}
+	if cfg.TargetCallID == "" {
+		cfg.TargetCallID = cfg.CallID
+	}
+	// Validating the URL early rather than failing every link.
+	if err := (&ClientConfig{URL: cfg.URL}).Parse(); err != nil {
+		return MirrorInfo{}, fmt.Errorf("invalid URL value: %w", err)
+	}
+
+	if _, err := s.rtcServer.GetCallState(cfg.GroupID, cfg.CallID); err != nil {
+		return MirrorInfo{}, err
+	}
+
+	m := &mirror{
+		cfg: cfg,
+		info: MirrorInfo{
+			ID:             random.NewID(),
+			GroupID:        cfg.GroupID,
+			CallID:         cfg.CallID,
+			URL:            cfg.URL,
+			TargetClientID: cfg.ClientID,
+			TargetCallID:   cfg.TargetCallID,
+			StartedAt:      time.Now().UnixMilli(),
+		},
+		tracks: map[*mirrorTrack]bool{},
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+
+	s.mut.Lock()
+	if len(s.mirrors) >= s.cfg.Mirroring.MaxCount {
+		s.mut.Unlock()
+		return MirrorInfo{}, fmt.Errorf("too many mirrors: the limit is %d", s.cfg.Mirroring.MaxCount)
+	}
+	s.mirrors[m.info.ID] = m
+	s.mut.Unlock()
+
+	if err := s.initMirror(m); err != nil {
+		s.closeMirrorSource(m)
+		s.mut.Lock()
+		delete(s.mirrors, m.info.ID)
+		s.mut.Unlock()
+		close(m.doneCh)
+		return MirrorInfo{}, err
+	}
+
+	go s.runMirror(m)
+
+	s.log.Info("mirror started", mlog.String("mirrorID", m.info.ID), mlog.String("groupID", cfg.GroupID),
+		mlog.String("callID", cfg.CallID), mlog.String("url", cfg.URL))
+
+	return m.getInfo(), nil
+}
+
+// initMirror joins the mirrored call with a hidden session receiving its
+// tracks.
+func (s *Service) initMirror(m *mirror) error {
+	cfg := rtc.SessionConfig{
+		GroupID:   m.cfg.GroupID,
+		CallID:    m.cfg.CallID,
+		UserID:    mirrorUserIDPrefix + m.info.ID,
+		SessionID: random.NewID(),
+		Hidden:    true,
+	}
+	p, err := newLocalPeer(cfg, s.rtcServer, s.log)
+	if err != nil {
+		return err
+	}
+
+	m.mut.Lock()
+	m.src = p
+	m.info.SessionID = cfg.SessionID
+	m.mut.Unlock()
+
+	s.mut.Lock()
+	s.localPeers[cfg.SessionID] = p
+	s.mut.Unlock()
+
+	p.pc.OnTrack(func(remoteTrack *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		s.mirrorTrack(m, remoteTrack)
+	})
+
+	// The session only receives but still needs a media section to
+	// negotiate the initial connection.
+	if _, err := p.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{
+		Direction: webrtc.RTPTransceiverDirectionRecvonly,
+	}); err != nil {
+		return fmt.Errorf("failed to add transceiver: %w", err)
+	}
+
+	if err := s.rtcServer.InitSession(cfg, func(_ string) error {
+		m.requestStop(mirrorStopReasonClosed)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to initialize rtc session: %w", err)
+	}
+	p.renegotiate()
+
+	return nil
+}
+
+// mirrorTrack forwards a track of the mirrored call to the current and
+// future links until it ends.
+func (s *Service) mirrorTrack(m *mirror, remoteTrack *webrtc.TrackRemote) {
+	local, err := webrtc.NewTrackLocalStaticRTP(remoteTrack.Codec().RTPCodecCapability, remoteTrack.ID(), remoteTrack.StreamID())
+	if err != nil {
+		s.log.Error("failed to create mirrored track", mlog.Err(err), mlog.String("mirrorID", m.info.ID))
+		return
+	}
+	t := &mirrorTrack{remote: remoteTrack, local: local}
+	m.addTrack()
+
+	m.mut.Lock()
+	m.tracks[t] = true
+	if m.link != nil {
+		s.addMirrorLinkTrack(m, m.link, t)
+		m.link.peer.renegotiate()
+	}
+	m.mut.Unlock()
+
+	defer func() {
+		m.mut.Lock()
+		defer m.mut.Unlock()
+		delete(m.tracks, t)
+		if m.link != nil {
+			s.removeMirrorLinkTrack(m, m.link, t)
+			m.link.peer.renegotiate()
+		}
+	}()
+
+	for {
+		pkt, _, err := remoteTrack.ReadRTP()
+		if err != nil {
+			return
+		}
+		if err := local.WriteRTP(pkt); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			s.log.Error("failed to write mirrored packet", mlog.Err(err), mlog.String("mirrorID", m.info.ID))
+			return
+		}
+		m.addMedia(1, len(pkt.Payload))
+	}
+}
+
+// addMirrorLinkTrack publishes the track on the link. Video tracks are
+// published as screen sharing, the only kind of video rtcd forwards. m.mut
+// must be held.
+func (s *Service) addMirrorLinkTrack(m *mirror, l *mirrorLink, t *mirrorTrack) {
+	if t.isVideo() {
+		data, err := json.Marshal(map[string]string{"screenStreamID": t.local.StreamID()})
+		if err == nil {
+			err = l.peer.send(rtc.ScreenOnMessage, data)
+		}
+		if err != nil {
+			s.log.Error("failed to send screen message", mlog.Err(err), mlog.String("mirrorID", m.info.ID))
+		}
+	}
+
+	sender, err := l.peer.pc.AddTrack(t.local)
+	if err != nil {
+		s.log.Error("failed to add mirrored track", mlog.Err(err), mlog.String("mirrorID", m.info.ID))
+		return
+	}
+	l.senders[t] = sender
+
+	if !t.isVideo() {
+		return
+	}
+
+	// Video can only be decoded starting from a key frame, so the key frame
+	// requests of the target's subscribers are relayed to the mirrored call.
+	requestKeyFrame := func() {
+		if err := m.src.pc.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: uint32(t.remote.SSRC())}}); err != nil {
+			s.log.Debug("failed to request key frame", mlog.Err(err), mlog.String("mirrorID", m.info.ID))
+		}
+	}
+	requestKeyFrame()
+	go func() {
+		for {
+			pkts, _, err := sender.ReadRTCP()
+			if err != nil {
+				return
+			}
+			for _, pkt := range pkts {
+				if _, ok := pkt.(*rtcp.PictureLossIndication); ok {
+					requestKeyFrame()
+				}
+			}
+		}
+	}()
+}
+
+// removeMirrorLinkTrack unpublishes the track from the link. m.mut must be
+// held.
+func (s *Service) removeMirrorLinkTrack(m *mirror, l *mirrorLink, t *mirrorTrack) {
+	sender := l.senders[t]
+	if sender == nil {
+		return
+	}
+	delete(l.senders, t)
+	if err := l.peer.pc.RemoveTrack(sender); err != nil {
+		s.log.Debug("failed to remove mirrored track", mlog.Err(err), mlog.String("mirrorID", m.info.ID))
+	}
+	if t.isVideo() {
+		if err := l.peer.send(rtc.ScreenOffMessage, nil); err != nil {
+			s.log.Debug("failed to send screen message", mlog.Err(err), mlog.String("mirrorID", m.info.ID))
+		}
+	}
+}
+
+// runMirror links the mirror to the target instance until it gets stopped,
+// which happens once requested or once the mirrored call has no
+// participants left.
+func (s *Service) runMirror(m *mirror) {
+	linksDoneCh := make(chan struct{})
+	go func() {
+		defer close(linksDoneCh)
+		s.runMirrorLinks(m)
+	}()
+
+	ticker := time.NewTicker(callCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopCh:
+		case <-ticker.C:
+			if !s.callHasParticipants(m.cfg.GroupID, m.cfg.CallID, mirrorUserIDPrefix+m.info.ID) {
+				m.requestStop(mirrorStopReasonCallEnded)
+			}
+			continue
+		}
+		break
+	}
+
+	<-linksDoneCh
+	s.closeMirrorSource(m)
+
+	s.mut.Lock()
+	delete(s.mirrors, m.info.ID)
+	s.mut.Unlock()
+
+	m.mut.Lock()
+	m.info.StoppedAt = time.Now().UnixMilli()
+	m.info.Reason = m.reason
+	m.mut.Unlock()
+
+	info := m.getInfo()
+	s.log.Info("mirror stopped", mlog.String("mirrorID", info.ID), mlog.String("reason", info.Reason),
+		mlog.Int("attempts", info.Attempts), mlog.Int64("packets", info.Stats.Packets))
+
+	close(m.doneCh)
+}
+
+// runMirrorLinks keeps a link to the target instance open, replacing it
+// with an exponential backoff whenever it fails, until the mirror gets
+// stopped.
+func (s *Service) runMirrorLinks(m *mirror) {
+	retryInterval := time.Duration(s.cfg.Mirroring.RetryIntervalSeconds) * time.Second
+	wait := retryInterval
+	for {
+		var connected bool
+		l, err := s.openMirrorLink(m)
+		if err == nil {
+			connected, err = s.waitMirrorLink(m, l)
+			s.closeMirrorLink(m, l)
+		}
+
+		select {
+		case <-m.stopCh:
+			return
+		default:
+		}
+
+		if connected {
+			wait = retryInterval
+		}
+		m.mut.Lock()
+		m.info.LastError = err.Error()
+		m.mut.Unlock()
+		s.log.Warn("mirror link failed", mlog.Err(err), mlog.String("mirrorID", m.info.ID),
+			mlog.String("url", m.cfg.URL), mlog.Duration("retryIn", wait))
+
+		select {
+		case <-time.After(wait):
+		case <-m.stopCh:
+			return
+		}
+
+		if wait *= 2; wait > mirrorMaxRetryInterval {
+			wait = mirrorMaxRetryInterval
+		}
+	}
+}
+
+// openMirrorLink connects to the target instance, joins the target call
+// and publishes the tracks mirrored so far.
+func (s *Service) openMirrorLink(m *mirror) (*mirrorLink, error) {
+	m.mut.Lock()
+	m.info.Attempts++
+	m.mut.Unlock()
+
+	l := &mirrorLink{
+		senders: map[*mirrorTrack]*webrtc.RTPSender{},
+		failCh:  make(chan struct{}),
+	}
+
+	svc, err := NewClient(ClientConfig{
+		URL:      m.cfg.URL,
+		ClientID: m.cfg.ClientID,
+		AuthKey:  m.cfg.AuthKey,
+	}, WithClientReconnectCb(func(_ *Client, _ int) error {
+		// Closing a link while it's reconnecting doesn't stop the client.
+		m.mut.Lock()
+		defer m.mut.Unlock()
+		if l.closed {
+			return errMirrorLinkClosed
+		}
+		return nil
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	l.svc = svc
+
+	cfg := rtc.SessionConfig{
+		CallID:    m.cfg.TargetCallID,
+		UserID:    mirrorUserIDPrefix + m.info.ID,
+		SessionID: random.NewID(),
+	}
+	peer, err := newRemotePeer(cfg, svc, s.log)
+	if err != nil {
+		return nil, err
+	}
+	l.peer = peer
+
+	svc.OnRTCMessage(func(msg rtc.Message) {
+		if msg.SessionID != cfg.SessionID {
+			return
+		}
+		if err := peer.push(msg); err != nil {
+			s.log.Error("failed to push mirror message", mlog.Err(err), mlog.String("mirrorID", m.info.ID))
+		}
+	})
+	svc.OnSessionClose(func(sessionID, reason string) {
+		if sessionID == cfg.SessionID {
+			l.fail(fmt.Errorf("session closed by target: %q", reason))
+		}
+	})
+	svc.OnReconnect(func(_ int) {
+		// Re-attaching the session to the new connection, along with the
+		// last message received so that anything lost gets retransmitted.
+		if err := svc.Send(ClientMessage{Type: ClientMessageReconnect, Data: map[string]string{
+			"sessionID": cfg.SessionID,
+			"lastSeq":   strconv.FormatUint(svc.LastSeq(cfg.SessionID), 10),
+		}}); err != nil {
+			l.fail(fmt.Errorf("failed to reconnect session: %w", err))
+		}
+	})
+	svc.OnError(func(err error) {
+		s.log.Debug("mirror link error", mlog.Err(err), mlog.String("mirrorID", m.info.ID))
+	})
+
+	if err := svc.Connect(); err != nil {
+		_ = peer.close()
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	// Messages not handled through callbacks are of no use to the link.
+	go func() {
+		for range svc.ReceiveCh() {
+		}
+	}()
+
+	if err := svc.Send(ClientMessage{Type: ClientMessageJoin, Data: map[string]string{
+		"callID":    cfg.CallID,
+		"userID":    cfg.UserID,
+		"sessionID": cfg.SessionID,
+	}}); err != nil {
+		s.closeMirrorLink(m, l)
+		return nil, fmt.Errorf("failed to join call: %w", err)
+	}
+
+	// A media section is needed to negotiate the initial connection in case
+	// no track is mirrored yet.
+	if _, err := peer.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{
+		Direction: webrtc.RTPTransceiverDirectionRecvonly,
+	}); err != nil {
+		s.closeMirrorLink(m, l)
+		return nil, fmt.Errorf("failed to add transceiver: %w", err)
+	}
+
+	m.mut.Lock()
+	m.link = l
+	m.info.TargetSessionID = cfg.SessionID
+	for t := range m.tracks {
+		s.addMirrorLinkTrack(m, l, t)
+	}
+	m.mut.Unlock()
+	peer.renegotiate()
+
+	return l, nil
+}
+
+// waitMirrorLink waits for the link to fail or for the mirror to be
+// stopped, returning whether the link got connected and why it failed.
+func (s *Service) waitMirrorLink(m *mirror, l *mirrorLink) (bool, error) {
+	select {
+	case <-l.peer.connCh:
+		m.mut.Lock()
+		m.info.Connected = true
+		m.mut.Unlock()
+		s.log.Debug("mirror link connected", mlog.String("mirrorID", m.info.ID),
+			mlog.String("sessionID", l.peer.cfg.SessionID))
+	case <-l.failCh:
+		return false, l.failErr
+	case <-time.After(mirrorConnectTimeout):
+		return false, fmt.Errorf("timed out waiting for connection")
+	case <-m.stopCh:
+		return false, nil
+	}
+
+	select {
+	case <-l.peer.failCh:
+		return true, fmt.Errorf("connection failed")
+	case <-l.failCh:
+		return true, l.failErr
+	case <-m.stopCh:
+		return true, nil
+	}
+}
+
+// closeMirrorLink leaves the target call and closes the link.
+func (s *Service) closeMirrorLink(m *mirror, l *mirrorLink) {
+	m.mut.Lock()
+	l.closed = true
+	if m.link == l {
+		m.link = nil
+		m.info.TargetSessionID = ""
+		m.info.Connected = false
+	}
+	m.mut.Unlock()
+
+	if err := l.svc.Send(ClientMessage{Type: ClientMessageLeave, Data: map[string]string{
+		"sessionID": l.peer.cfg.SessionID,
+	}}); err != nil {
+		s.log.Debug("failed to leave target call", mlog.Err(err), mlog.String("mirrorID", m.info.ID))
+	}
+	if err := l.peer.close(); err != nil {
+		s.log.Error("failed to close mirror peer", mlog.Err(err), mlog.String("mirrorID", m.info.ID))
+	}
+	if err := l.svc.Close(); err != nil {
+		s.log.Debug("failed to close mirror client", mlog.Err(err), mlog.String("mirrorID", m.info.ID))
+	}
+}
+
+// closeMirrorSource closes the session receiving the tracks of the
+// mirrored call.
+func (s *Service) closeMirrorSource(m *mirror) {
+	m.requestStop(mirrorStopReasonStopped)
+
+	m.mut.Lock()
+	p := m.src
+	m.mut.Unlock()
+	if p == nil {
+		return
+	}
+
+	if err := s.rtcServer.CloseSession(p.cfg.SessionID); err != nil {
+		s.log.Debug("failed to close mirror session", mlog.Err(err), mlog.String("sessionID", p.cfg.SessionID))
+	}
+	if err := p.close(); err != nil {
+		s.log.Error("failed to close mirror peer", mlog.Err(err), mlog.String("sessionID", p.cfg.SessionID))
+	}
+	s.mut.Lock()
+	delete(s.localPeers, p.cfg.SessionID)
+	s.mut.Unlock()
+}
+
+// stopMirror stops the mirror with the given ID, returning its final info.
+// The mirrored call is unaffected.
+func (s *Service) stopMirror(id string) (MirrorInfo, error) {
+	s.mut.RLock()
+	m := s.mirrors[id]
+	s.mut.RUnlock()
+	if m == nil {
+		return MirrorInfo{}, fmt.Errorf("mirror not found: %s", id)
+	}
+
+	m.requestStop(mirrorStopReasonStopped)
+	<-m.doneCh
+
+	return m.getInfo(), nil
+}
+
+// getMirrors returns the info of the running mirrors.
+func (s *Service) getMirrors() []MirrorInfo {
+	s.mut.RLock()
+	mirrors := make([]*mirror, 0, len(s.mirrors))
+	for _, m := range s.mirrors {
+		mirrors = append(mirrors, m)
+	}
+	s.mut.RUnlock()
+
+	infos := make([]MirrorInfo, 0, len(mirrors))
+	for _, m := range mirrors {
+		infos = append(infos, m.getInfo())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartedAt < infos[j].StartedAt
+	})
+	return infos
+}
+
+// stopMirrors stops all the running mirrors and waits for them to be done.
+func (s *Service) stopMirrors(reason string) {
+	s.mut.RLock()
+	mirrors := make([]*mirror, 0, len(s.mirrors))
+	for _, m := range s.mirrors {
+		mirrors = append(mirrors, m)
+	}
+	s.mut.RUnlock()
+
+	for _, m := range mirrors {
+		m.requestStop(reason)
+	}
+	for _, m := range mirrors {
+		<-m.doneCh
+	}
+}
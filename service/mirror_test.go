@@ -0,0 +1,275 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// startTestSubscriber joins a local peer to the given call, returning a
+// function reporting the number of packets it received.
+func startTestSubscriber(t *testing.T, th *TestHelper, groupID, callID string) func() int64 {
+	t.Helper()
+
+	cfg := rtc.SessionConfig{
+		GroupID:   groupID,
+		CallID:    callID,
+		UserID:    "subscriber",
+		SessionID: random.NewID(),
+	}
+	p, err := newLocalPeer(cfg, th.srvc.rtcServer, th.srvc.log)
+	require.NoError(t, err)
+	th.srvc.mut.Lock()
+	th.srvc.localPeers[cfg.SessionID] = p
+	th.srvc.mut.Unlock()
+
+	var packets int64
+	p.pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		for {
+			if _, _, err := track.ReadRTP(); err != nil {
+				return
+			}
+			atomic.AddInt64(&packets, 1)
+		}
+	})
+	_, err = p.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{
+		Direction: webrtc.RTPTransceiverDirectionRecvonly,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, th.srvc.rtcServer.InitSession(cfg, nil))
+	p.renegotiate()
+
+	t.Cleanup(func() {
+		_ = th.srvc.rtcServer.CloseSession(cfg.SessionID)
+		_ = p.close()
+		th.srvc.mut.Lock()
+		delete(th.srvc.localPeers, cfg.SessionID)
+		th.srvc.mut.Unlock()
+	})
+
+	return func() int64 {
+		return atomic.LoadInt64(&packets)
+	}
+}
+
+func getTestMirror(t *testing.T, th *TestHelper, id string) *mirror {
+	t.Helper()
+	th.srvc.mut.RLock()
+	defer th.srvc.mut.RUnlock()
+	m := th.srvc.mirrors[id]
+	require.NotNil(t, m)
+	return m
+}
+
+func TestMirrorsHandler(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		th := SetupTestHelper(t, nil)
+		defer th.Teardown()
+
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/mirrors", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	cfg := MakeDefaultCfg(t)
+	cfg.Mirroring.Enable = true
+	cfg.Mirroring.MaxCount = 1
+	cfg.Mirroring.RetryIntervalSeconds = 1
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	require.NoError(t, th.adminClient.Register("clientB", authKey))
+
+	doRequest := func(t *testing.T, method, body string) (int, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest(method, th.apiURL+"/admin/mirrors", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp.StatusCode, response
+	}
+
+	t.Run("invalid config", func(t *testing.T) {
+		code, response := doRequest(t, "POST", `{"groupID": "groupA", "callID": "callA"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "invalid URL value: should not be empty", response["error"])
+
+		code, response = doRequest(t, "POST", `{"groupID": "groupA", "callID": "callA", "url": "ftp://localhost", "targetClientID": "clientB", "targetAuthKey": "key"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, `invalid URL value: invalid url scheme: "ftp" is not valid`, response["error"])
+	})
+
+	t.Run("call not found", func(t *testing.T) {
+		code, response := doRequest(t, "POST", `{"groupID": "groupA", "callID": "callA", "url": "`+th.apiURL+`", "targetClientID": "clientB", "targetAuthKey": "key"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "group not found: groupA", response["error"])
+	})
+
+	startTestPublisher(t, th, "groupA", "callA")
+
+	t.Run("mirror", func(t *testing.T) {
+		code, response := doRequest(t, "POST", `{"groupID": "groupA", "callID": "callA", "url": "`+th.apiURL+`", "targetClientID": "clientB", "targetAuthKey": "`+authKey+`", "targetCallID": "callM"}`)
+		require.Equal(t, http.StatusCreated, code, response["error"])
+		id := response["id"]
+		require.NotEmpty(t, id)
+
+		t.Run("limit", func(t *testing.T) {
+			code, response := doRequest(t, "POST", `{"groupID": "groupA", "callID": "callA", "url": "`+th.apiURL+`", "targetClientID": "clientB", "targetAuthKey": "`+authKey+`"}`)
+			require.Equal(t, http.StatusBadRequest, code)
+			require.Equal(t, "too many mirrors: the limit is 1", response["error"])
+		})
+
+		var info MirrorInfo
+		require.Eventually(t, func() bool {
+			code, response := doRequest(t, "GET", "")
+			require.Equal(t, http.StatusOK, code)
+			var mirrors []MirrorInfo
+			require.NoError(t, json.Unmarshal([]byte(response["mirrors"]), &mirrors))
+			require.Len(t, mirrors, 1)
+			info = mirrors[0]
+			return info.Connected
+		}, 10*time.Second, 100*time.Millisecond)
+		require.Equal(t, id, info.ID)
+		require.Equal(t, 1, info.Attempts)
+
+		// The mirror takes part in the target call as a participant.
+		state, err := th.srvc.rtcServer.GetCallState("clientB", "callM")
+		require.NoError(t, err)
+		require.Len(t, state.Sessions, 1)
+		require.Equal(t, info.TargetSessionID, state.Sessions[0].SessionID)
+
+		packets := startTestSubscriber(t, th, "clientB", "callM")
+		require.Eventually(t, func() bool {
+			return packets() > 0
+		}, 10*time.Second, 100*time.Millisecond)
+
+		code, response = doRequest(t, "DELETE", `{"id": "`+id+`"}`)
+		require.Equal(t, http.StatusOK, code, response["error"])
+		require.NoError(t, json.Unmarshal([]byte(response["mirror"]), &info))
+		require.Equal(t, mirrorStopReasonStopped, info.Reason)
+		require.Equal(t, int64(1), info.Stats.Tracks)
+		require.False(t, info.Connected)
+
+		// The mirror leaves the target call, the mirrored call is unaffected.
+		require.Eventually(t, func() bool {
+			state, err := th.srvc.rtcServer.GetCallState("clientB", "callM")
+			return err == nil && len(state.Sessions) == 1 && state.Sessions[0].UserID == "subscriber"
+		}, 10*time.Second, 100*time.Millisecond)
+		state, err = th.srvc.rtcServer.GetCallState("groupA", "callA")
+		require.NoError(t, err)
+		require.Len(t, state.Sessions, 1)
+	})
+}
+
+func TestMirrorRetry(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.Mirroring.Enable = true
+	cfg.Mirroring.MaxCount = 10
+	cfg.Mirroring.RetryIntervalSeconds = 1
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	require.NoError(t, th.adminClient.Register("clientB", authKey))
+
+	startTestPublisher(t, th, "groupA", "callA")
+
+	t.Run("target unreachable", func(t *testing.T) {
+		// Finding a port nothing listens on.
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		url := "http://" + l.Addr().String()
+		require.NoError(t, l.Close())
+
+		info, err := th.srvc.startMirror(MirrorConfig{GroupID: "groupA", CallID: "callA", URL: url, ClientID: "clientB", AuthKey: authKey})
+		require.NoError(t, err)
+		m := getTestMirror(t, th, info.ID)
+
+		require.Eventually(t, func() bool {
+			return m.getInfo().Attempts >= 2
+		}, 10*time.Second, 100*time.Millisecond)
+		info = m.getInfo()
+		require.False(t, info.Connected)
+		require.Contains(t, info.LastError, "failed to connect")
+
+		info, err = th.srvc.stopMirror(info.ID)
+		require.NoError(t, err)
+		require.Equal(t, mirrorStopReasonStopped, info.Reason)
+	})
+
+	t.Run("target session closed", func(t *testing.T) {
+		info, err := th.srvc.startMirror(MirrorConfig{GroupID: "groupA", CallID: "callA", URL: th.apiURL, ClientID: "clientB", AuthKey: authKey, TargetCallID: "callM"})
+		require.NoError(t, err)
+		m := getTestMirror(t, th, info.ID)
+
+		require.Eventually(t, func() bool {
+			return m.getInfo().Connected
+		}, 10*time.Second, 100*time.Millisecond)
+		sessionID := m.getInfo().TargetSessionID
+
+		// The link is replaced by a new one.
+		require.NoError(t, th.srvc.rtcServer.CloseSession(sessionID))
+		require.Eventually(t, func() bool {
+			info := m.getInfo()
+			return info.Connected && info.TargetSessionID != sessionID
+		}, 10*time.Second, 100*time.Millisecond)
+		info = m.getInfo()
+		require.Equal(t, 2, info.Attempts)
+		require.NotEmpty(t, info.LastError)
+
+		info, err = th.srvc.stopMirror(info.ID)
+		require.NoError(t, err)
+		require.Equal(t, mirrorStopReasonStopped, info.Reason)
+	})
+
+	t.Run("call ended", func(t *testing.T) {
+		defer func(interval time.Duration) { callCheckInterval = interval }(callCheckInterval)
+		callCheckInterval = 100 * time.Millisecond
+
+		p := startTestPublisher(t, th, "groupA", "callC")
+		info, err := th.srvc.startMirror(MirrorConfig{GroupID: "groupA", CallID: "callC", URL: th.apiURL, ClientID: "clientB", AuthKey: authKey})
+		require.NoError(t, err)
+		m := getTestMirror(t, th, info.ID)
+
+		require.NoError(t, th.srvc.rtcServer.CloseSession(p.cfg.SessionID))
+		select {
+		case <-m.doneCh:
+		case <-time.After(10 * time.Second):
+			require.Fail(t, "timed out waiting for mirror to stop")
+		}
+		require.Equal(t, mirrorStopReasonCallEnded, m.getInfo().Reason)
+
+		// The target call is left along with the mirrored one.
+		require.Eventually(t, func() bool {
+			_, err := th.srvc.rtcServer.GetCallState("clientB", "callC")
+			return err != nil
+		}, 10*time.Second, 100*time.Millisecond)
+	})
+}
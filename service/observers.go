@@ -0,0 +1,27 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"time"
+
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// observerTokenExpiration is the time left to the observer session to join
+// once its token is issued.
+const observerTokenExpiration = 5 * time.Minute
+
+// auditObserver records an observer session joining or leaving a call in
+// the audit log, the group the call belongs to being the actor.
+func (s *Service) auditObserver(action, status string, cfg rtc.SessionConfig, fields ...mlog.Field) {
+	s.auditLog(action, cfg.GroupID, requestID(""), status, append([]mlog.Field{
+		mlog.String("callID", cfg.CallID),
+		mlog.String("userID", cfg.UserID),
+		mlog.String("sessionID", cfg.SessionID),
+		mlog.String("traceID", cfg.TraceID),
+	}, fields...)...)
+}
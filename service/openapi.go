@@ -0,0 +1,56 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/mattermost/rtcd/service/signaling"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// openAPISpec is the OpenAPI specification of the HTTP API. It must be kept
+// in sync with the handlers registered in New.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+const (
+	specPath = "/api/spec"
+	// signalingSchemaPath serves the JSON Schema of the signaling messages,
+	// which the HTTP API spec doesn't cover.
+	signalingSchemaPath = "/api/signaling"
+)
+
+func (s *Service) getSpec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(s.spec.Raw()); err != nil {
+		s.log.Error("failed to write spec", mlog.Err(err))
+	}
+}
+
+func (s *Service) getSignalingSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(signaling.Schema); err != nil {
+		s.log.Error("failed to write signaling schema", mlog.Err(err))
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/rtcd/service/api"
+	"github.com/mattermost/rtcd/service/signaling"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.API.HTTP.ValidateRequests = true
+	cfg.API.HTTP.ValidateResponses = true
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	var buf bytes.Buffer
+	err := mlog.AddWriterTarget(th.srvc.log, &buf, true, mlog.LvlWarn)
+	require.NoError(t, err)
+
+	doRequest := func(t *testing.T, method, path, body string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, th.apiURL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+
+	t.Run("served", func(t *testing.T) {
+		code, data := doRequest(t, http.MethodGet, "/api/spec", "")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, string(openAPISpec), data)
+
+		_, err := api.ParseSpec([]byte(data))
+		require.NoError(t, err)
+
+		code, _ = doRequest(t, http.MethodPost, "/api/spec", "")
+		require.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("signaling schema served", func(t *testing.T) {
+		code, data := doRequest(t, http.MethodGet, "/api/signaling", "")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, string(signaling.Schema), data)
+
+		code, _ = doRequest(t, http.MethodPost, "/api/signaling", "")
+		require.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("routes documented", func(t *testing.T) {
+		for _, route := range th.srvc.apiServer.Routes() {
+			if strings.HasPrefix(route, "/debug/pprof/") || route == "/metrics" {
+				continue
+			}
+			require.True(t, th.srvc.spec.HasPath(route), "route %s is not documented", route)
+		}
+		require.True(t, th.srvc.spec.HasPath(hlsPathPrefix))
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		code, data := doRequest(t, http.MethodPost, "/admin/rtc/params", `{"unknown": "1"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Contains(t, data, "request validation failed: body.unknown: is not allowed")
+	})
+
+	t.Run("no drift", func(t *testing.T) {
+		buf.Reset()
+
+		err := th.adminClient.Register("clientA", "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H")
+		require.NoError(t, err)
+		err = th.adminClient.Register("clientA", "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H")
+		require.Error(t, err)
+		_, err = th.adminClient.GetVersionInfo()
+		require.NoError(t, err)
+
+		for _, tc := range []struct {
+			method string
+			path   string
+			body   string
+		}{
+			{method: http.MethodGet, path: "/readyz"},
+			{method: http.MethodGet, path: "/api/signaling"},
+			{method: http.MethodPost, path: "/login", body: `{"clientID": "clientA", "authKey": "Ey4-H_BJA00_TVByPi8DozE12ekN3S7H"}`},
+			{method: http.MethodPost, path: "/login", body: `{"clientID": "clientA", "authKey": "invalid"}`},
+			{method: http.MethodPost, path: "/bootstrap", body: `{"token": "invalid"}`},
+			{method: http.MethodGet, path: "/admin/rtc/sockets"},
+			{method: http.MethodGet, path: "/admin/rtc/params"},
+			{method: http.MethodPost, path: "/admin/rtc/params", body: `{"pliThrottleMs": "500"}`},
+			{method: http.MethodGet, path: "/admin/store/export"},
+			{method: http.MethodGet, path: "/admin/rtc/dtls_certificate"},
+			{method: http.MethodGet, path: "/admin/usage"},
+			{method: http.MethodGet, path: "/admin/calls/callID/events"},
+			{method: http.MethodDelete, path: "/admin/rtc/capture", body: `{"groupID": "groupID", "callID": "callID"}`},
+			{method: http.MethodPost, path: "/unregister", body: `{"clientID": "clientA"}`},
+			{method: http.MethodPost, path: "/unregister", body: `{"clientID": "clientA"}`},
+		} {
+			doRequest(t, tc.method, tc.path, tc.body)
+		}
+
+		require.NoError(t, th.srvc.log.Flush())
+		require.Empty(t, buf.String())
+	})
+}
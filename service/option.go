@@ -5,9 +5,15 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"net"
+
+	"github.com/mattermost/rtcd/service/perf"
+
+	"github.com/pion/transport/vnet"
 )
 
+type ServiceOption func(s *Service) error
 type ClientOption func(c *Client) error
 type ClientReconnectCb func(c *Client, attempt int) error
 type DialContextFn func(ctx context.Context, network, addr string) (net.Conn, error)
@@ -29,3 +35,37 @@ func WithDialFunc(dialFn DialContextFn) ClientOption {
 		return nil
 	}
 }
+
+// WithMetricsBackend lets the caller record the service metrics through its
+// own backend instead of the default Prometheus registry. The /metrics
+// endpoint isn't exposed in that case.
+func WithMetricsBackend(backend perf.Backend) ServiceOption {
+	return func(s *Service) error {
+		m, err := perf.NewMetricsWithBackend("rtcd", backend)
+		if err != nil {
+			return fmt.Errorf("failed to create metrics: %w", err)
+		}
+		s.metrics = m
+		return nil
+	}
+}
+
+// WithListener lets the caller serve the HTTP API on its own listener, e.g.
+// an in-memory one in tests, instead of listening on the configured address.
+// It doesn't apply to the admin API when served on its own address.
+func WithListener(l net.Listener) ServiceOption {
+	return func(s *Service) error {
+		s.listener = l
+		return nil
+	}
+}
+
+// WithVNet lets the caller serve media, including the one of in-process
+// peers, over a virtual network instead of the UDP sockets of the host (see
+// rtc.WithVNet).
+func WithVNet(n *vnet.Net) ServiceOption {
+	return func(s *Service) error {
+		s.vnet = n
+		return nil
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/mattermost/rtcd/service/perf"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testMetricsBackend struct {
+	names []string
+	mut   sync.Mutex
+}
+
+type nopMetric struct{}
+
+func (nopMetric) Add(float64, ...string)     {}
+func (nopMetric) Set(float64, ...string)     {}
+func (nopMetric) Observe(float64, ...string) {}
+
+func (b *testMetricsBackend) add(opts perf.MetricOpts) nopMetric {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.names = append(b.names, opts.Subsystem+"_"+opts.Name)
+	return nopMetric{}
+}
+
+func (b *testMetricsBackend) NewCounter(opts perf.MetricOpts) (perf.Counter, error) {
+	return b.add(opts), nil
+}
+
+func (b *testMetricsBackend) NewGauge(opts perf.MetricOpts) (perf.Gauge, error) {
+	return b.add(opts), nil
+}
+
+func (b *testMetricsBackend) NewHistogram(opts perf.MetricOpts) (perf.Histogram, error) {
+	return b.add(opts), nil
+}
+
+func TestWithMetricsBackend(t *testing.T) {
+	t.Run("nil backend", func(t *testing.T) {
+		_, err := New(*MakeDefaultCfg(t), WithMetricsBackend(nil))
+		require.EqualError(t, err, "failed to create metrics: backend should not be nil")
+	})
+
+	backend := &testMetricsBackend{}
+	srvc, err := New(*MakeDefaultCfg(t), WithMetricsBackend(backend))
+	require.NoError(t, err)
+	require.NoError(t, srvc.Start())
+	defer func() {
+		require.NoError(t, srvc.Stop())
+	}()
+	require.Contains(t, backend.names, "ws_connections_total")
+	require.Contains(t, backend.names, "rtc_sessions_total")
+
+	// The Prometheus endpoint isn't exposed.
+	_, port, err := net.SplitHostPort(srvc.apiServer.Addr())
+	require.NoError(t, err)
+	resp, err := http.Get("http://localhost:" + port + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
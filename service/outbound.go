@@ -0,0 +1,124 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/ws"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// runOutbound keeps the outbound signaling connection established until the
+// service is stopped.
+func (s *Service) runOutbound() {
+	defer close(s.outboundDoneCh)
+
+	interval := time.Duration(s.cfg.API.Outbound.ReconnectIntervalSeconds) * time.Second
+	var waitTime time.Duration
+	for {
+		connected, err := s.serveOutbound()
+		if err != nil {
+			s.log.Error("outbound connection failed", mlog.Err(err), mlog.String("url", s.cfg.API.Outbound.URL))
+		}
+
+		if connected {
+			waitTime = 0
+		}
+		if waitTime < maxReconnectInterval {
+			waitTime += interval
+		}
+
+		select {
+		case <-s.outboundStopCh:
+			return
+		case <-time.After(waitTime):
+		}
+	}
+}
+
+// serveOutbound dials the configured URL and handles the messages received
+// on the connection until it's closed. Messages are handled exactly like the
+// ones received through inbound connections.
+func (s *Service) serveOutbound() (bool, error) {
+	cfg := s.cfg.API.Outbound
+	connID := random.NewID()
+
+	wsClient, err := ws.NewClient(ws.ClientConfig{
+		URL:       cfg.URL,
+		ConnID:    connID,
+		AuthToken: base64.StdEncoding.EncodeToString([]byte(cfg.ClientID + ":" + cfg.AuthKey)),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	s.outboundMut.Lock()
+	s.outboundConn = wsClient
+	s.outboundConnID = connID
+	s.outboundMut.Unlock()
+
+	closeCh := make(chan struct{})
+	defer func() {
+		close(closeCh)
+		s.outboundMut.Lock()
+		s.outboundConn = nil
+		s.outboundConnID = ""
+		s.outboundMut.Unlock()
+		// The connection may have already been closed by the remote end or
+		// on shutdown.
+		_ = wsClient.Close()
+		s.handleConnClose(connID, cfg.ClientID)
+	}()
+
+	// Closing the connection on shutdown unblocks the receiving loop below.
+	go func() {
+		select {
+		case <-s.outboundStopCh:
+			_ = wsClient.Close()
+		case <-closeCh:
+		}
+	}()
+
+	go func() {
+		for err := range wsClient.ErrorCh() {
+			s.log.Debug("outbound connection error", mlog.Err(err), mlog.String("connID", connID))
+		}
+	}()
+
+	if err := s.handleConnOpen(connID, cfg.ClientID); err != nil {
+		return true, err
+	}
+
+	for msg := range wsClient.ReceiveCh() {
+		if msg.Type != ws.BinaryMessage {
+			s.log.Warn("unexpected ws message", mlog.String("connID", connID), mlog.String("clientID", cfg.ClientID))
+			continue
+		}
+
+		msg.ConnID = connID
+		msg.ClientID = cfg.ClientID
+		if err := s.handleClientMsg(msg); err != nil {
+			s.log.Error("failed to handle message",
+				mlog.Err(err),
+				mlog.String("connID", connID),
+				mlog.String("clientID", cfg.ClientID))
+		}
+	}
+
+	return true, nil
+}
+
+func (s *Service) getOutboundConn(connID string) *ws.Client {
+	s.outboundMut.RLock()
+	defer s.outboundMut.RUnlock()
+	if connID == "" || connID != s.outboundConnID {
+		return nil
+	}
+	return s.outboundConn
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/ws"
+
+	"github.com/gorilla/websocket"
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRemoteServer(t *testing.T, clientID, authKey string) (*ws.Server, string, func()) {
+	t.Helper()
+
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+
+	authCb := func(w http.ResponseWriter, r *http.Request) (string, int, error) {
+		id, key, ok := r.BasicAuth()
+		if !ok || id != clientID || key != authKey {
+			return "", http.StatusUnauthorized, fmt.Errorf("authentication failed")
+		}
+		return id, http.StatusOK, nil
+	}
+
+	s, err := ws.NewServer(ws.ServerConfig{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		PingInterval:    time.Second,
+	}, log, ws.WithAuthCb(authCb))
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go func() {
+		_ = http.Serve(listener, s)
+	}()
+
+	return s, "ws://" + listener.Addr().String(), func() {
+		s.Close()
+		listener.Close()
+		err := log.Shutdown()
+		require.NoError(t, err)
+	}
+}
+
+func receiveRemoteMsg(t *testing.T, s *ws.Server, msgType ws.MessageType) ws.Message {
+	t.Helper()
+	for {
+		select {
+		case msg := <-s.ReceiveCh():
+			if msg.Type == msgType {
+				return msg
+			}
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for message")
+		}
+	}
+}
+
+func TestOutbound(t *testing.T) {
+	remote, remoteURL, closeRemote := setupRemoteServer(t, "clientA", "authKey")
+	defer closeRemote()
+
+	cfg := MakeDefaultCfg(t)
+	cfg.API.Outbound = OutboundConfig{
+		Enable:                   true,
+		URL:                      remoteURL,
+		ClientID:                 "clientA",
+		AuthKey:                  "authKey",
+		ReconnectIntervalSeconds: 1,
+	}
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	sendRemoteMsg := func(connID string, cm *ClientMessage) {
+		t.Helper()
+		data, err := cm.Pack()
+		require.NoError(t, err)
+		err = remote.Send(ws.Message{
+			ConnID: connID,
+			Type:   ws.BinaryMessage,
+			Data:   data,
+		})
+		require.NoError(t, err)
+	}
+
+	receiveClientMsg := func(msgType string) ClientMessage {
+		t.Helper()
+		for {
+			msg := receiveRemoteMsg(t, remote, ws.BinaryMessage)
+			var cm ClientMessage
+			err := cm.Unpack(msg.Data)
+			require.NoError(t, err)
+			if cm.Type == msgType {
+				return cm
+			}
+		}
+	}
+
+	openMsg := receiveRemoteMsg(t, remote, ws.OpenMessage)
+	require.Equal(t, "clientA", openMsg.ClientID)
+
+	t.Run("hello", func(t *testing.T) {
+		cm := receiveClientMsg(ClientMessageHello)
+		data, ok := cm.Data.(map[string]string)
+		require.True(t, ok)
+		require.Equal(t, "clientA", data["clientID"])
+		require.NotEmpty(t, data["connID"])
+	})
+
+	t.Run("signaling", func(t *testing.T) {
+		sendRemoteMsg(openMsg.ConnID, NewClientMessage(ClientMessageJoin, map[string]string{
+			"callID":    "callID",
+			"userID":    "userID",
+			"sessionID": "sessionID",
+		}))
+		sendRemoteMsg(openMsg.ConnID, NewClientMessage(ClientMessageResync, map[string]string{
+			"callID": "callID",
+		}))
+
+		cm := receiveClientMsg(ClientMessageCallState)
+		data, ok := cm.Data.(map[string]string)
+		require.True(t, ok)
+
+		var state rtc.CallState
+		err := json.Unmarshal([]byte(data["state"]), &state)
+		require.NoError(t, err)
+		require.Equal(t, "clientA", state.GroupID)
+		require.Len(t, state.Sessions, 1)
+		require.Equal(t, "sessionID", state.Sessions[0].SessionID)
+
+		sendRemoteMsg(openMsg.ConnID, NewClientMessage(ClientMessageLeave, map[string]string{
+			"sessionID": "sessionID",
+		}))
+		receiveClientMsg(ClientMessageClose)
+	})
+
+	t.Run("reconnect", func(t *testing.T) {
+		err := remote.Send(ws.Message{
+			ConnID: openMsg.ConnID,
+			Type:   ws.CloseMessage,
+			Data:   websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		})
+		require.NoError(t, err)
+
+		reopenMsg := receiveRemoteMsg(t, remote, ws.OpenMessage)
+		require.Equal(t, "clientA", reopenMsg.ClientID)
+		require.NotEqual(t, openMsg.ConnID, reopenMsg.ConnID)
+		receiveClientMsg(ClientMessageHello)
+	})
+}
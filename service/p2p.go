@@ -0,0 +1,256 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"fmt"
+
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/signaling"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const (
+	p2pPeerJoined = "joined"
+	p2pPeerLeft   = "left"
+
+	p2pRoleOfferer  = "offerer"
+	p2pRoleAnswerer = "answerer"
+)
+
+// p2pSession is a session of a call negotiated peer-to-peer.
+type p2pSession struct {
+	cfg rtc.SessionConfig
+	// clientID is the ID of the client the session joined through.
+	clientID string
+	// closeCb notifies the client of the session being closed. It's handed
+	// over to the rtc server if the call gets upgraded.
+	closeCb func(reason string) error
+}
+
+// p2pCall is a 1:1 call negotiated peer-to-peer, with the service only
+// relaying the signaling between its sessions. It's upgraded to be routed
+// through the rtc server as soon as a third session joins.
+type p2pCall struct {
+	key      string
+	sessions []*p2pSession
+}
+
+func p2pCallKey(groupID, callID string) string {
+	return groupID + "/" + callID
+}
+
+// getPeer returns the other session of the call, if any.
+func (c *p2pCall) getPeer(sessionID string) *p2pSession {
+	for _, us := range c.sessions {
+		if us.cfg.SessionID != sessionID {
+			return us
+		}
+	}
+	return nil
+}
+
+// isRTCCall returns whether the call is already routed through the rtc
+// server.
+func (s *Service) isRTCCall(groupID, callID string) bool {
+	_, err := s.rtcServer.GetCallState(groupID, callID)
+	return err == nil
+}
+
+// joinP2PCall adds the session to the peer-to-peer call it's joining, if
+// requested and possible, and returns whether it did. An existing
+// peer-to-peer call that can't take the session is upgraded instead, for the
+// session to join it through the rtc server.
+func (s *Service) joinP2PCall(connID, clientID string, cfg rtc.SessionConfig, requested bool, closeCb func(reason string) error) (bool, error) {
+	if !s.cfg.P2P.Enable {
+		return false, nil
+	}
+
+	// Sessions restricting the data path of the call need the server.
+	eligible := requested && !cfg.Hidden && cfg.ICEPolicy == (rtc.ICEPolicy{}) &&
+		s.getConnProtocol(connID).hasCapability(CapabilityP2P)
+
+	s.p2pMut.Lock()
+	defer s.p2pMut.Unlock()
+
+	key := p2pCallKey(cfg.GroupID, cfg.CallID)
+	call := s.p2pCalls[key]
+	if call == nil {
+		if !eligible || s.isRTCCall(cfg.GroupID, cfg.CallID) {
+			return false, nil
+		}
+		call = &p2pCall{key: key}
+		s.p2pCalls[key] = call
+	} else if !eligible || len(call.sessions) == 2 {
+		s.upgradeP2PCall(call)
+		return false, nil
+	}
+
+	if _, ok := s.p2pSessions[cfg.SessionID]; ok {
+		return false, newBadMessageError("session %q already joined", cfg.SessionID)
+	}
+
+	us := &p2pSession{
+		cfg:      cfg,
+		clientID: clientID,
+		closeCb:  closeCb,
+	}
+	call.sessions = append(call.sessions, us)
+	s.p2pSessions[cfg.SessionID] = call
+
+	s.mut.Lock()
+	s.connMap[cfg.SessionID] = connID
+	s.sessionScopes[cfg.SessionID] = newSessionScope(cfg)
+	s.mut.Unlock()
+
+	s.log.Debug("session joined p2p call", mlog.String("groupID", cfg.GroupID), mlog.String("callID", cfg.CallID),
+		mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
+
+	if peer := call.getPeer(cfg.SessionID); peer != nil {
+		// The session joining last makes the offer, the other one waiting
+		// for it.
+		s.sendP2PPeer(us, peer, p2pPeerJoined, p2pRoleOfferer)
+		s.sendP2PPeer(peer, us, p2pPeerJoined, p2pRoleAnswerer)
+	}
+
+	return true, nil
+}
+
+// upgradeP2PCall hands the sessions of the call over to the rtc server, in
+// the order they joined. Clients tear down their peer connection on the
+// upgrade message and negotiate with the server as if they had just joined.
+// s.p2pMut must be held.
+func (s *Service) upgradeP2PCall(call *p2pCall) {
+	delete(s.p2pCalls, call.key)
+	for _, us := range call.sessions {
+		delete(s.p2pSessions, us.cfg.SessionID)
+	}
+
+	for _, us := range call.sessions {
+		s.log.Debug("upgrading p2p session", mlog.String("groupID", us.cfg.GroupID), mlog.String("callID", us.cfg.CallID),
+			mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+
+		if err := s.sendP2PMessage(us, ClientMessageP2PUpgrade, signaling.P2PUpgradeData{
+			SessionID: us.cfg.SessionID,
+		}.Map()); err != nil {
+			s.log.Error("failed to send p2p upgrade message", mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID))
+		}
+
+		s.mut.RLock()
+		connID := s.connMap[us.cfg.SessionID]
+		s.mut.RUnlock()
+
+		if err := s.initRTCSession(connID, us.cfg, us.closeCb); err != nil {
+			s.log.Error("failed to upgrade p2p session", mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+			// The client was already notified of the known failures.
+			if errorCode(err) == "" {
+				if cbErr := us.closeCb(rtc.CloseReasonInternalError); cbErr != nil {
+					s.log.Error("failed to close session", mlog.Err(cbErr), mlog.String("sessionID", us.cfg.SessionID))
+				}
+			}
+		}
+	}
+}
+
+// leaveP2PCall removes the session from the peer-to-peer call it's in, if
+// any, and returns whether it did.
+func (s *Service) leaveP2PCall(sessionID, reason string) bool {
+	s.p2pMut.Lock()
+	defer s.p2pMut.Unlock()
+
+	call := s.p2pSessions[sessionID]
+	if call == nil {
+		return false
+	}
+	delete(s.p2pSessions, sessionID)
+
+	var us *p2pSession
+	for i, ss := range call.sessions {
+		if ss.cfg.SessionID == sessionID {
+			us = ss
+			call.sessions = append(call.sessions[:i], call.sessions[i+1:]...)
+			break
+		}
+	}
+
+	if peer := call.getPeer(sessionID); peer != nil {
+		s.sendP2PPeer(peer, us, p2pPeerLeft, "")
+	} else {
+		delete(s.p2pCalls, call.key)
+	}
+
+	if err := us.closeCb(reason); err != nil {
+		s.log.Error("failed to close session", mlog.Err(err), mlog.String("sessionID", sessionID))
+	}
+
+	return true
+}
+
+// handleP2PSignal relays the signaling data sent by a session of a
+// peer-to-peer call to its peer.
+func (s *Service) handleP2PSignal(connID string, data map[string]string) error {
+	signal, err := signaling.ParseP2PSignalData(data)
+	if err != nil {
+		return newBadMessageError("%s in client message", err)
+	}
+	sessionID := signal.SessionID
+
+	s.mut.RLock()
+	ownerConnID := s.connMap[sessionID]
+	s.mut.RUnlock()
+	if ownerConnID != connID {
+		return withErrorCode(ErrorCodeForbidden, fmt.Errorf("session %q doesn't belong to the connection", sessionID))
+	}
+
+	s.p2pMut.Lock()
+	defer s.p2pMut.Unlock()
+
+	call := s.p2pSessions[sessionID]
+	if call == nil {
+		return withErrorCode(ErrorCodeNotFound, fmt.Errorf("session %q isn't in a p2p call", sessionID))
+	}
+	peer := call.getPeer(sessionID)
+	if peer == nil {
+		return withErrorCode(ErrorCodeConflict, fmt.Errorf("session %q has no peer", sessionID))
+	}
+
+	return s.sendP2PMessage(peer, ClientMessageP2PSignal, signaling.P2PSignalData{
+		SessionID:     peer.cfg.SessionID,
+		PeerSessionID: sessionID,
+		Data:          signal.Data,
+	}.Map())
+}
+
+// sendP2PPeer notifies the session of its peer joining or leaving.
+func (s *Service) sendP2PPeer(us, peer *p2pSession, state, role string) {
+	data := signaling.P2PPeerData{
+		SessionID:     us.cfg.SessionID,
+		PeerSessionID: peer.cfg.SessionID,
+		PeerUserID:    peer.cfg.UserID,
+		State:         state,
+		Role:          role,
+	}
+	if err := s.sendP2PMessage(us, ClientMessageP2PPeer, data.Map()); err != nil {
+		s.log.Error("failed to send p2p peer message", mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID))
+	}
+}
+
+// sendP2PMessage sends a message to the connection the session is currently
+// reachable through.
+func (s *Service) sendP2PMessage(us *p2pSession, msgType string, data map[string]string) error {
+	s.mut.RLock()
+	connID := s.connMap[us.cfg.SessionID]
+	s.mut.RUnlock()
+	if connID == "" {
+		return fmt.Errorf("unexpected empty connID")
+	}
+
+	packed, err := NewPackedClientMessage(msgType, data)
+	if err != nil {
+		return fmt.Errorf("failed to pack %s message: %w", msgType, err)
+	}
+
+	return s.sendClientMessage(connID, us.clientID, packed)
+}
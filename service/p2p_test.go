@@ -0,0 +1,185 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestP2PCall(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.P2P.Enable = true
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	clientID := "clientA"
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = th.adminClient.Register(clientID, authKey)
+	require.NoError(t, err)
+
+	c, err := NewClient(ClientConfig{
+		URL:          th.apiURL,
+		ClientID:     clientID,
+		AuthKey:      authKey,
+		Capabilities: []string{CapabilityCodecOpus, CapabilityP2P, CapabilityErrors},
+	})
+	require.NoError(t, err)
+	err = c.Connect()
+	require.NoError(t, err)
+	defer c.Close()
+
+	msg, ok := <-c.ReceiveCh()
+	require.True(t, ok)
+	require.Equal(t, ClientMessageHello, msg.Type)
+	connID := msg.Data.(map[string]string)["connID"]
+	require.Eventually(t, func() bool {
+		return th.srvc.getConnProtocol(connID).hasCapability(CapabilityP2P)
+	}, 5*time.Second, 50*time.Millisecond)
+
+	join := func(t *testing.T, callID, sessionID string, p2p bool) {
+		t.Helper()
+		data := map[string]string{
+			"callID":    callID,
+			"userID":    "user" + sessionID,
+			"sessionID": sessionID,
+			"traceID":   "trace" + sessionID,
+		}
+		if p2p {
+			data["p2p"] = "true"
+		}
+		require.NoError(t, c.Send(*NewClientMessage(ClientMessageJoin, data)))
+	}
+
+	receive := func(t *testing.T, msgType string) map[string]string {
+		t.Helper()
+		select {
+		case msg, ok := <-c.ReceiveCh():
+			require.True(t, ok)
+			require.Equal(t, msgType, msg.Type)
+			return msg.Data.(map[string]string)
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "timed out waiting for message", msgType)
+		}
+		return nil
+	}
+
+	isP2PSession := func(sessionID string) bool {
+		th.srvc.p2pMut.Lock()
+		defer th.srvc.p2pMut.Unlock()
+		return th.srvc.p2pSessions[sessionID] != nil
+	}
+
+	t.Run("negotiated peer-to-peer", func(t *testing.T) {
+		join(t, "callA", "sessionA", true)
+		require.Eventually(t, func() bool {
+			return isP2PSession("sessionA")
+		}, time.Second, 10*time.Millisecond)
+		require.False(t, th.srvc.isRTCCall(clientID, "callA"))
+
+		join(t, "callA", "sessionB", true)
+		require.Equal(t, map[string]string{
+			"sessionID":     "sessionB",
+			"peerSessionID": "sessionA",
+			"peerUserID":    "usersessionA",
+			"state":         p2pPeerJoined,
+			"role":          p2pRoleOfferer,
+		}, receive(t, ClientMessageP2PPeer))
+		require.Equal(t, map[string]string{
+			"sessionID":     "sessionA",
+			"peerSessionID": "sessionB",
+			"peerUserID":    "usersessionB",
+			"state":         p2pPeerJoined,
+			"role":          p2pRoleAnswerer,
+		}, receive(t, ClientMessageP2PPeer))
+		require.False(t, th.srvc.isRTCCall(clientID, "callA"))
+	})
+
+	t.Run("signaling relayed", func(t *testing.T) {
+		err := c.Send(*NewClientMessage(ClientMessageP2PSignal, map[string]string{
+			"sessionID": "sessionB",
+			"data":      `{"type":"offer"}`,
+		}))
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			"sessionID":     "sessionA",
+			"peerSessionID": "sessionB",
+			"data":          `{"type":"offer"}`,
+		}, receive(t, ClientMessageP2PSignal))
+	})
+
+	t.Run("signaling outside of a p2p call", func(t *testing.T) {
+		err := c.Send(*NewClientMessage(ClientMessageP2PSignal, map[string]string{
+			"sessionID": "sessionX",
+		}))
+		require.NoError(t, err)
+		select {
+		case err := <-c.ErrorCh():
+			require.Equal(t, ErrorCodeForbidden, ErrorCodeOf(err))
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "timed out waiting for error")
+		}
+	})
+
+	t.Run("upgraded on third session", func(t *testing.T) {
+		join(t, "callA", "sessionC", true)
+		require.Equal(t, map[string]string{"sessionID": "sessionA"}, receive(t, ClientMessageP2PUpgrade))
+		require.Equal(t, map[string]string{"sessionID": "sessionB"}, receive(t, ClientMessageP2PUpgrade))
+
+		require.Eventually(t, func() bool {
+			state, err := th.srvc.rtcServer.GetCallState(clientID, "callA")
+			return err == nil && len(state.Sessions) == 3
+		}, 2*time.Second, 10*time.Millisecond)
+		require.False(t, isP2PSession("sessionA"))
+		require.False(t, isP2PSession("sessionB"))
+		require.False(t, isP2PSession("sessionC"))
+
+		// Sessions joining later go through the server.
+		join(t, "callA", "sessionD", true)
+		require.Eventually(t, func() bool {
+			state, err := th.srvc.rtcServer.GetCallState(clientID, "callA")
+			return err == nil && len(state.Sessions) == 4
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("left", func(t *testing.T) {
+		join(t, "callB", "sessionE", true)
+		join(t, "callB", "sessionF", true)
+		receive(t, ClientMessageP2PPeer)
+		receive(t, ClientMessageP2PPeer)
+
+		err := c.Send(*NewClientMessage(ClientMessageLeave, map[string]string{
+			"sessionID": "sessionF",
+		}))
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			"sessionID":     "sessionE",
+			"peerSessionID": "sessionF",
+			"peerUserID":    "usersessionF",
+			"state":         p2pPeerLeft,
+		}, receive(t, ClientMessageP2PPeer))
+		require.Equal(t, map[string]string{
+			"sessionID": "sessionF",
+			"traceID":   "tracesessionF",
+			"reason":    rtc.CloseReasonLeft,
+		}, receive(t, ClientMessageClose))
+		require.False(t, isP2PSession("sessionF"))
+		require.True(t, isP2PSession("sessionE"))
+	})
+
+	t.Run("not requested", func(t *testing.T) {
+		join(t, "callC", "sessionG", false)
+		require.Eventually(t, func() bool {
+			return th.srvc.isRTCCall(clientID, "callC")
+		}, 2*time.Second, 10*time.Millisecond)
+		require.False(t, isP2PSession("sessionG"))
+	})
+}
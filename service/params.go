@@ -0,0 +1,129 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/mattermost/rtcd/logger"
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/store"
+)
+
+// runtimeParamsStoreKey is the store key under which the runtime parameters
+// are persisted.
+const runtimeParamsStoreKey = "rtcd:runtime_params"
+
+// runtimeParams holds the parameters that can be changed without restarting
+// the service.
+type runtimeParams struct {
+	RTC rtc.RuntimeParams `json:"rtc"`
+	// LogLevel overrides the level of all the logging targets. The configured
+	// levels are used if empty.
+	LogLevel string `json:"log_level"`
+}
+
+func (p runtimeParams) toMap() map[string]string {
+	return map[string]string{
+		"maxScreenBitrateKbps": strconv.Itoa(p.RTC.MaxScreenBitrateKbps),
+		"nackBufferSize":       strconv.Itoa(p.RTC.NACKBufferSize),
+		"pliThrottleMs":        strconv.Itoa(p.RTC.PLIThrottleMs),
+		"logLevel":             p.LogLevel,
+	}
+}
+
+// update sets the parameters present in data, leaving the others untouched.
+func (p *runtimeParams) update(data map[string]string) error {
+	intParams := map[string]*int{
+		"maxScreenBitrateKbps": &p.RTC.MaxScreenBitrateKbps,
+		"nackBufferSize":       &p.RTC.NACKBufferSize,
+		"pliThrottleMs":        &p.RTC.PLIThrottleMs,
+	}
+	for key, value := range data {
+		if key == "logLevel" {
+			p.LogLevel = value
+			continue
+		}
+		ptr, ok := intParams[key]
+		if !ok {
+			return fmt.Errorf("unknown parameter %q", key)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value: %w", key, err)
+		}
+		*ptr = n
+	}
+	return nil
+}
+
+func (s *Service) getRuntimeParams() runtimeParams {
+	s.paramsMut.RLock()
+	defer s.paramsMut.RUnlock()
+	return s.params
+}
+
+// setRuntimeParams applies the given parameters and persists them if
+// requested.
+func (s *Service) setRuntimeParams(params runtimeParams, persist bool) error {
+	s.paramsMut.Lock()
+	defer s.paramsMut.Unlock()
+
+	logCfg := s.cfg.Logger
+	if params.LogLevel != "" {
+		logCfg.ConsoleLevel = params.LogLevel
+		logCfg.FileLevel = params.LogLevel
+	}
+	if err := logCfg.IsValid(); err != nil {
+		return fmt.Errorf("invalid LogLevel value: %w", err)
+	}
+	if err := params.RTC.IsValid(); err != nil {
+		return err
+	}
+
+	if persist {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to marshal params: %w", err)
+		}
+		if err := s.store.Set(runtimeParamsStoreKey, string(data)); err != nil {
+			return fmt.Errorf("failed to store params: %w", err)
+		}
+	}
+
+	if err := s.rtcServer.SetRuntimeParams(params.RTC); err != nil {
+		return err
+	}
+
+	if params.LogLevel != s.params.LogLevel {
+		if err := logger.Configure(s.log, logCfg, s.recentLogs); err != nil {
+			return fmt.Errorf("failed to configure logger: %w", err)
+		}
+	}
+
+	s.params = params
+
+	return nil
+}
+
+// loadRuntimeParams applies the runtime parameters persisted in the store,
+// if any.
+func (s *Service) loadRuntimeParams() error {
+	data, err := s.store.Get(runtimeParamsStoreKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get params: %w", err)
+	}
+
+	var params runtimeParams
+	if err := json.Unmarshal([]byte(data), &params); err != nil {
+		return fmt.Errorf("failed to unmarshal params: %w", err)
+	}
+
+	return s.setRuntimeParams(params, false)
+}
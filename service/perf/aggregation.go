@@ -0,0 +1,131 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"sync"
+)
+
+// AggregatedCallID is the callID label value of the metrics of the calls
+// aggregated per registered client.
+const AggregatedCallID = "aggregated"
+
+type callLabel struct {
+	value string
+	// refs is the number of sessions of the call.
+	refs int
+}
+
+// callLabels assigns the callID label values of the metrics partitioned by
+// call. Once the number of calls labeled individually reaches the threshold,
+// new calls get aggregated per group under AggregatedCallID. A call keeps
+// its label for its whole lifetime so that the gauges tracking it get
+// decremented consistently.
+type callLabels struct {
+	threshold int
+	// calls is keyed by group and call ID.
+	calls map[string]*callLabel
+	// labeled is the number of calls labeled individually.
+	labeled int
+	mut     sync.Mutex
+}
+
+func (l *callLabels) setThreshold(threshold int) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.threshold = threshold
+}
+
+// acquire returns the label value to use for a new session of the given
+// call.
+func (l *callLabels) acquire(groupID, callID string) string {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	key := groupID + "/" + callID
+	if label, ok := l.calls[key]; ok {
+		label.refs++
+		return label.value
+	}
+
+	label := &callLabel{value: callID, refs: 1}
+	if l.threshold > 0 && l.labeled >= l.threshold {
+		label.value = AggregatedCallID
+	} else {
+		l.labeled++
+	}
+	if l.calls == nil {
+		l.calls = map[string]*callLabel{}
+	}
+	l.calls[key] = label
+
+	return label.value
+}
+
+// release returns the label value used for a session of the given call that
+// ended.
+func (l *callLabels) release(groupID, callID string) string {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	key := groupID + "/" + callID
+	label, ok := l.calls[key]
+	if !ok {
+		return callID
+	}
+
+	label.refs--
+	if label.refs <= 0 {
+		delete(l.calls, key)
+		if label.value != AggregatedCallID {
+			l.labeled--
+		}
+	}
+
+	return label.value
+}
+
+// SetCallsAggregationThreshold sets the number of calls past which the
+// metrics partitioned by call get aggregated per registered client. Zero
+// disables aggregation.
+func (m *Metrics) SetCallsAggregationThreshold(threshold int) {
+	m.callLabels.setThreshold(threshold)
+}
+
+// aggregateCallStats sums up the stats of the calls of each group. The
+// counters of the calls that ended, as found in ended, are added so that
+// they keep increasing.
+func aggregateCallStats(stats []CallStats, ended map[string]CallStats) []CallStats {
+	groups := make(map[string]*CallStats)
+	var order []string
+	add := func(s CallStats) {
+		g, ok := groups[s.GroupID]
+		if !ok {
+			g = &CallStats{GroupID: s.GroupID, CallID: AggregatedCallID}
+			groups[s.GroupID] = g
+			order = append(order, s.GroupID)
+		}
+		g.Sessions += s.Sessions
+		g.Tracks += s.Tracks
+		g.StalledTracks += s.StalledTracks
+		g.ForwardedBytes += s.ForwardedBytes
+		g.NACKs += s.NACKs
+		g.PLIs += s.PLIs
+	}
+
+	for _, s := range stats {
+		add(s)
+	}
+	for _, groupID := range order {
+		if e, ok := ended[groupID]; ok {
+			add(e)
+		}
+	}
+
+	aggregated := make([]CallStats, 0, len(order))
+	for _, groupID := range order {
+		aggregated = append(aggregated, *groups[groupID])
+	}
+	return aggregated
+}
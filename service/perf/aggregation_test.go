@@ -0,0 +1,89 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallLabels(t *testing.T) {
+	t.Run("no threshold", func(t *testing.T) {
+		var l callLabels
+		for _, callID := range []string{"callA", "callB", "callC"} {
+			require.Equal(t, callID, l.acquire("groupA", callID))
+		}
+	})
+
+	t.Run("threshold", func(t *testing.T) {
+		l := callLabels{threshold: 2}
+		require.Equal(t, "callA", l.acquire("groupA", "callA"))
+		require.Equal(t, "callB", l.acquire("groupA", "callB"))
+		require.Equal(t, AggregatedCallID, l.acquire("groupA", "callC"))
+		require.Equal(t, AggregatedCallID, l.acquire("groupB", "callD"))
+
+		// Calls keep their label until their last session is gone.
+		require.Equal(t, "callA", l.acquire("groupA", "callA"))
+		require.Equal(t, "callA", l.release("groupA", "callA"))
+		require.Equal(t, AggregatedCallID, l.acquire("groupA", "callE"))
+		require.Equal(t, "callA", l.release("groupA", "callA"))
+
+		// A slot was freed.
+		require.Equal(t, "callF", l.acquire("groupA", "callF"))
+		require.Equal(t, AggregatedCallID, l.release("groupA", "callC"))
+		require.Equal(t, AggregatedCallID, l.acquire("groupA", "callG"))
+	})
+}
+
+func TestRTCSessionsAggregation(t *testing.T) {
+	m := NewMetrics("rtcd", prometheus.NewRegistry())
+	m.SetCallsAggregationThreshold(1)
+
+	m.IncRTCSessions("groupA", "callA")
+	m.IncRTCSessions("groupA", "callB")
+	m.IncRTCSessions("groupA", "callC")
+	m.IncRTCSessions("groupA", "callC")
+
+	expected := `
+# HELP rtcd_rtc_sessions_total Total number of active RTC sessions
+# TYPE rtcd_rtc_sessions_total gauge
+rtcd_rtc_sessions_total{callID="aggregated",groupID="groupA"} 3
+rtcd_rtc_sessions_total{callID="callA",groupID="groupA"} 1
+`
+	err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "rtcd_rtc_sessions_total")
+	require.NoError(t, err)
+
+	m.DecRTCSessions("groupA", "callC")
+	m.DecRTCSessions("groupA", "callA")
+
+	expected = `
+# HELP rtcd_rtc_sessions_total Total number of active RTC sessions
+# TYPE rtcd_rtc_sessions_total gauge
+rtcd_rtc_sessions_total{callID="aggregated",groupID="groupA"} 2
+rtcd_rtc_sessions_total{callID="callA",groupID="groupA"} 0
+`
+	err = testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "rtcd_rtc_sessions_total")
+	require.NoError(t, err)
+}
+
+func TestAggregateCallStats(t *testing.T) {
+	stats := []CallStats{
+		{GroupID: "groupA", CallID: "callA", Sessions: 2, Tracks: 1, ForwardedBytes: 100, NACKs: 1, PLIs: 1},
+		{GroupID: "groupB", CallID: "callB", Sessions: 1, ForwardedBytes: 10},
+		{GroupID: "groupA", CallID: "callC", Sessions: 3, Tracks: 2, ForwardedBytes: 200, PLIs: 2},
+	}
+	ended := map[string]CallStats{
+		"groupA": {GroupID: "groupA", ForwardedBytes: 1000, NACKs: 5},
+		"groupC": {GroupID: "groupC", ForwardedBytes: 1000},
+	}
+
+	require.Equal(t, []CallStats{
+		{GroupID: "groupA", CallID: AggregatedCallID, Sessions: 5, Tracks: 3, ForwardedBytes: 1300, NACKs: 6, PLIs: 3},
+		{GroupID: "groupB", CallID: AggregatedCallID, Sessions: 1, ForwardedBytes: 10},
+	}, aggregateCallStats(stats, ended))
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricOpts describes a metric created through a Backend.
+type MetricOpts struct {
+	Namespace string
+	Subsystem string
+	Name      string
+	Help      string
+	// Labels are the names of the labels the metric is partitioned by.
+	// Their values are passed in the same order when recording.
+	Labels []string
+	// Buckets are the upper bounds of the buckets of a histogram.
+	Buckets []float64
+}
+
+type Counter interface {
+	Add(value float64, labelValues ...string)
+}
+
+type Gauge interface {
+	Set(value float64, labelValues ...string)
+	Add(value float64, labelValues ...string)
+}
+
+type Histogram interface {
+	Observe(value float64, labelValues ...string)
+}
+
+// ExemplarHistogram is implemented by the histograms able to attach an
+// exemplar (e.g. the trace ID of a session) to an observation.
+type ExemplarHistogram interface {
+	ObserveWithExemplar(value float64, exemplar map[string]string, labelValues ...string)
+}
+
+// observeWithExemplar records the observation along with the exemplar if the
+// histogram supports it, or without otherwise.
+func observeWithExemplar(h Histogram, value float64, exemplar map[string]string, labelValues ...string) {
+	if eh, ok := h.(ExemplarHistogram); ok && len(exemplar) > 0 {
+		eh.ObserveWithExemplar(value, exemplar, labelValues...)
+		return
+	}
+	h.Observe(value, labelValues...)
+}
+
+// Backend creates the metrics recorded by the service and the rtc server,
+// letting embedders wire their own metrics system. The default one is
+// backed by a Prometheus registry (see NewPrometheusBackend).
+type Backend interface {
+	NewCounter(opts MetricOpts) (Counter, error)
+	NewGauge(opts MetricOpts) (Gauge, error)
+	NewHistogram(opts MetricOpts) (Histogram, error)
+}
+
+// registryProvider is implemented by the backends exposing a Prometheus
+// registry.
+type registryProvider interface {
+	Registry() *prometheus.Registry
+}
+
+type multiBackend []Backend
+
+type multiCounter []Counter
+
+type multiGauge []Gauge
+
+type multiHistogram []Histogram
+
+// NewMultiBackend returns a backend recording the metrics to all the given
+// ones.
+func NewMultiBackend(backends ...Backend) Backend {
+	return multiBackend(backends)
+}
+
+// Registry returns the registry of the first Prometheus backend, if any.
+func (mb multiBackend) Registry() *prometheus.Registry {
+	for _, b := range mb {
+		if rp, ok := b.(registryProvider); ok {
+			return rp.Registry()
+		}
+	}
+	return nil
+}
+
+func (mb multiBackend) NewCounter(opts MetricOpts) (Counter, error) {
+	var mc multiCounter
+	for _, b := range mb {
+		c, err := b.NewCounter(opts)
+		if err != nil {
+			return nil, err
+		}
+		mc = append(mc, c)
+	}
+	return mc, nil
+}
+
+func (mb multiBackend) NewGauge(opts MetricOpts) (Gauge, error) {
+	var mg multiGauge
+	for _, b := range mb {
+		g, err := b.NewGauge(opts)
+		if err != nil {
+			return nil, err
+		}
+		mg = append(mg, g)
+	}
+	return mg, nil
+}
+
+func (mb multiBackend) NewHistogram(opts MetricOpts) (Histogram, error) {
+	var mh multiHistogram
+	for _, b := range mb {
+		h, err := b.NewHistogram(opts)
+		if err != nil {
+			return nil, err
+		}
+		mh = append(mh, h)
+	}
+	return mh, nil
+}
+
+func (mc multiCounter) Add(value float64, labelValues ...string) {
+	for _, c := range mc {
+		c.Add(value, labelValues...)
+	}
+}
+
+func (mg multiGauge) Set(value float64, labelValues ...string) {
+	for _, g := range mg {
+		g.Set(value, labelValues...)
+	}
+}
+
+func (mg multiGauge) Add(value float64, labelValues ...string) {
+	for _, g := range mg {
+		g.Add(value, labelValues...)
+	}
+}
+
+func (mh multiHistogram) Observe(value float64, labelValues ...string) {
+	for _, h := range mh {
+		h.Observe(value, labelValues...)
+	}
+}
+
+func (mh multiHistogram) ObserveWithExemplar(value float64, exemplar map[string]string, labelValues ...string) {
+	for _, h := range mh {
+		observeWithExemplar(h, value, exemplar, labelValues...)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsSubSystemCall = "call"
+
+// CallStats holds the per-call values exported by the calls collector.
+type CallStats struct {
+	GroupID        string
+	CallID         string
+	Sessions       int
+	Tracks         int
+	StalledTracks  int
+	ForwardedBytes uint64
+	NACKs          uint64
+	PLIs           uint64
+}
+
+type callSample struct {
+	stats     CallStats
+	sampledAt time.Time
+}
+
+// callsCollector exports per-call metrics, computed on each scrape out of
+// the stats returned by getStats.
+type callsCollector struct {
+	cfg      Config
+	getStats func() []CallStats
+
+	sessions       *prometheus.Desc
+	tracks         *prometheus.Desc
+	stalledTracks  *prometheus.Desc
+	forwardedBytes *prometheus.Desc
+	forwardedKbps  *prometheus.Desc
+	nacks          *prometheus.Desc
+	plis           *prometheus.Desc
+
+	// samples holds the stats seen on the previous scrape, used to compute
+	// rates.
+	samples map[string]callSample
+	// ended holds the counters of the calls that ended, summed up per
+	// group, so that the aggregated counters keep increasing.
+	ended map[string]CallStats
+	mut   sync.Mutex
+}
+
+// RegisterCallsCollector registers a collector exporting the per-call
+// metrics returned by getStats. It's a no-op if not using the Prometheus
+// backend.
+func (m *Metrics) RegisterCallsCollector(namespace string, cfg Config, getStats func() []CallStats) error {
+	if m.registry == nil {
+		return nil
+	}
+
+	newDesc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, metricsSubSystemCall, name), help,
+			[]string{"groupID", "callID"}, nil)
+	}
+
+	return m.registry.Register(&callsCollector{
+		cfg:            cfg,
+		getStats:       getStats,
+		sessions:       newDesc("sessions", "Number of sessions in the call"),
+		tracks:         newDesc("tracks", "Number of media tracks forwarded in the call"),
+		stalledTracks:  newDesc("stalled_tracks", "Number of published tracks not receiving packets in the call"),
+		forwardedBytes: newDesc("forwarded_bytes_total", "Total number of RTP payload bytes forwarded to subscribers"),
+		forwardedKbps:  newDesc("forwarded_kbps", "Rate of forwarded RTP payload since the previous scrape"),
+		nacks:          newDesc("nacks_total", "Total number of NACK requests received from subscribers"),
+		plis:           newDesc("plis_total", "Total number of PLI requests received from subscribers"),
+		samples:        map[string]callSample{},
+		ended:          map[string]CallStats{},
+	})
+}
+
+func (c *callsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.sessions
+	ch <- c.tracks
+	ch <- c.stalledTracks
+	ch <- c.forwardedBytes
+	ch <- c.forwardedKbps
+	ch <- c.nacks
+	ch <- c.plis
+}
+
+func (c *callsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	now := time.Now()
+	stats := c.getStats()
+	rates := make(map[string]float64, len(stats))
+	samples := make(map[string]callSample, len(stats))
+	for _, s := range stats {
+		key := s.GroupID + "/" + s.CallID
+		if prev, ok := c.samples[key]; ok && s.ForwardedBytes >= prev.stats.ForwardedBytes {
+			if elapsed := now.Sub(prev.sampledAt).Seconds(); elapsed > 0 {
+				rates[key] = float64(s.ForwardedBytes-prev.stats.ForwardedBytes) * 8 / 1000 / elapsed
+			}
+		}
+		samples[key] = callSample{stats: s, sampledAt: now}
+	}
+	for key, prev := range c.samples {
+		if _, ok := samples[key]; ok {
+			continue
+		}
+		e := c.ended[prev.stats.GroupID]
+		e.GroupID = prev.stats.GroupID
+		e.ForwardedBytes += prev.stats.ForwardedBytes
+		e.NACKs += prev.stats.NACKs
+		e.PLIs += prev.stats.PLIs
+		c.ended[prev.stats.GroupID] = e
+	}
+	// Replacing the samples so that ended calls are forgotten.
+	c.samples = samples
+
+	if c.cfg.AggregateCallsThreshold > 0 && len(stats) > c.cfg.AggregateCallsThreshold {
+		groupRates := make(map[string]float64)
+		for _, s := range stats {
+			groupRates[s.GroupID+"/"+AggregatedCallID] += rates[s.GroupID+"/"+s.CallID]
+		}
+		rates = groupRates
+		stats = aggregateCallStats(stats, c.ended)
+	} else if c.cfg.CallMetricsMaxCalls > 0 && len(stats) > c.cfg.CallMetricsMaxCalls {
+		sort.Slice(stats, func(i, j int) bool {
+			return rates[stats[i].GroupID+"/"+stats[i].CallID] > rates[stats[j].GroupID+"/"+stats[j].CallID]
+		})
+		stats = stats[:c.cfg.CallMetricsMaxCalls]
+	}
+
+	for _, s := range stats {
+		callID := s.CallID
+		if c.cfg.CallMetricsHashIDs && callID != AggregatedCallID {
+			callID = hashID(callID)
+		}
+		ch <- prometheus.MustNewConstMetric(c.sessions, prometheus.GaugeValue, float64(s.Sessions), s.GroupID, callID)
+		ch <- prometheus.MustNewConstMetric(c.tracks, prometheus.GaugeValue, float64(s.Tracks), s.GroupID, callID)
+		ch <- prometheus.MustNewConstMetric(c.stalledTracks, prometheus.GaugeValue, float64(s.StalledTracks), s.GroupID, callID)
+		ch <- prometheus.MustNewConstMetric(c.forwardedBytes, prometheus.CounterValue, float64(s.ForwardedBytes), s.GroupID, callID)
+		ch <- prometheus.MustNewConstMetric(c.forwardedKbps, prometheus.GaugeValue, rates[s.GroupID+"/"+s.CallID], s.GroupID, callID)
+		ch <- prometheus.MustNewConstMetric(c.nacks, prometheus.CounterValue, float64(s.NACKs), s.GroupID, callID)
+		ch <- prometheus.MustNewConstMetric(c.plis, prometheus.CounterValue, float64(s.PLIs), s.GroupID, callID)
+	}
+}
+
+// hashID returns a short, stable, hash of the given id.
+func hashID(id string) string {
+	h := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(h[:8])
+}
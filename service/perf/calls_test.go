@@ -0,0 +1,158 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallsCollector(t *testing.T) {
+	stats := []CallStats{
+		{GroupID: "groupA", CallID: "callA", Sessions: 2, Tracks: 2, ForwardedBytes: 1000, NACKs: 1, PLIs: 2},
+		{GroupID: "groupA", CallID: "callB", Sessions: 3, Tracks: 4, ForwardedBytes: 5000},
+	}
+	getStats := func() []CallStats {
+		out := make([]CallStats, len(stats))
+		copy(out, stats)
+		return out
+	}
+
+	t.Run("all calls", func(t *testing.T) {
+		m := NewMetrics("rtcd", prometheus.NewRegistry())
+		err := m.RegisterCallsCollector("rtcd", Config{EnableCallMetrics: true}, getStats)
+		require.NoError(t, err)
+
+		expected := `
+# HELP rtcd_call_sessions Number of sessions in the call
+# TYPE rtcd_call_sessions gauge
+rtcd_call_sessions{callID="callA",groupID="groupA"} 2
+rtcd_call_sessions{callID="callB",groupID="groupA"} 3
+# HELP rtcd_call_nacks_total Total number of NACK requests received from subscribers
+# TYPE rtcd_call_nacks_total counter
+rtcd_call_nacks_total{callID="callA",groupID="groupA"} 1
+rtcd_call_nacks_total{callID="callB",groupID="groupA"} 0
+`
+		err = testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "rtcd_call_sessions", "rtcd_call_nacks_total")
+		require.NoError(t, err)
+	})
+
+	t.Run("max calls", func(t *testing.T) {
+		m := NewMetrics("rtcd", prometheus.NewRegistry())
+		err := m.RegisterCallsCollector("rtcd", Config{EnableCallMetrics: true, CallMetricsMaxCalls: 1}, getStats)
+		require.NoError(t, err)
+
+		// The first scrape only samples the forwarded bytes.
+		_, err = m.registry.Gather()
+		require.NoError(t, err)
+
+		stats[0].ForwardedBytes += 100
+		stats[1].ForwardedBytes += 1000
+
+		expected := `
+# HELP rtcd_call_tracks Number of media tracks forwarded in the call
+# TYPE rtcd_call_tracks gauge
+rtcd_call_tracks{callID="callB",groupID="groupA"} 4
+`
+		err = testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "rtcd_call_tracks")
+		require.NoError(t, err)
+	})
+
+	t.Run("hashed ids", func(t *testing.T) {
+		m := NewMetrics("rtcd", prometheus.NewRegistry())
+		err := m.RegisterCallsCollector("rtcd", Config{EnableCallMetrics: true, CallMetricsHashIDs: true}, getStats)
+		require.NoError(t, err)
+
+		families, err := m.registry.Gather()
+		require.NoError(t, err)
+		for _, f := range families {
+			for _, metric := range f.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "callID" {
+						require.Len(t, label.GetValue(), 16)
+						require.NotContains(t, label.GetValue(), "call")
+					}
+				}
+			}
+		}
+		require.Equal(t, hashID("callA"), hashID("callA"))
+		require.NotEqual(t, hashID("callA"), hashID("callB"))
+	})
+
+	t.Run("aggregation", func(t *testing.T) {
+		stats := []CallStats{
+			{GroupID: "groupA", CallID: "callA", Sessions: 2, NACKs: 1},
+			{GroupID: "groupA", CallID: "callB", Sessions: 3, NACKs: 2},
+			{GroupID: "groupB", CallID: "callC", Sessions: 1, NACKs: 4},
+		}
+		m := NewMetrics("rtcd", prometheus.NewRegistry())
+		err := m.RegisterCallsCollector("rtcd", Config{EnableCallMetrics: true, AggregateCallsThreshold: 2}, func() []CallStats {
+			out := make([]CallStats, len(stats))
+			copy(out, stats)
+			return out
+		})
+		require.NoError(t, err)
+
+		expected := `
+# HELP rtcd_call_sessions Number of sessions in the call
+# TYPE rtcd_call_sessions gauge
+rtcd_call_sessions{callID="aggregated",groupID="groupA"} 5
+rtcd_call_sessions{callID="aggregated",groupID="groupB"} 1
+# HELP rtcd_call_nacks_total Total number of NACK requests received from subscribers
+# TYPE rtcd_call_nacks_total counter
+rtcd_call_nacks_total{callID="aggregated",groupID="groupA"} 3
+rtcd_call_nacks_total{callID="aggregated",groupID="groupB"} 4
+`
+		err = testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "rtcd_call_sessions", "rtcd_call_nacks_total")
+		require.NoError(t, err)
+
+		// The counters of ended calls keep being accounted for.
+		stats = append(stats[:1], CallStats{GroupID: "groupA", CallID: "callD", Sessions: 1, NACKs: 1}, stats[2])
+		expected = `
+# HELP rtcd_call_nacks_total Total number of NACK requests received from subscribers
+# TYPE rtcd_call_nacks_total counter
+rtcd_call_nacks_total{callID="aggregated",groupID="groupA"} 4
+rtcd_call_nacks_total{callID="aggregated",groupID="groupB"} 4
+`
+		err = testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "rtcd_call_nacks_total")
+		require.NoError(t, err)
+
+		// Below the threshold calls are exported individually.
+		stats = stats[:2]
+		expected = `
+# HELP rtcd_call_sessions Number of sessions in the call
+# TYPE rtcd_call_sessions gauge
+rtcd_call_sessions{callID="callA",groupID="groupA"} 2
+rtcd_call_sessions{callID="callD",groupID="groupA"} 1
+`
+		err = testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "rtcd_call_sessions")
+		require.NoError(t, err)
+	})
+}
+
+func TestConfigIsValid(t *testing.T) {
+	var cfg Config
+	require.NoError(t, cfg.IsValid())
+
+	cfg.CallMetricsMaxCalls = -1
+	err := cfg.IsValid()
+	require.Error(t, err)
+	require.Equal(t, "invalid CallMetricsMaxCalls value: should not be negative", err.Error())
+
+	cfg.CallMetricsMaxCalls = 0
+	cfg.AggregateCallsThreshold = -1
+	err = cfg.IsValid()
+	require.Error(t, err)
+	require.Equal(t, "invalid AggregateCallsThreshold value: should not be negative", err.Error())
+
+	cfg.AggregateCallsThreshold = 0
+	cfg.StatsD = StatsDConfig{Enable: true, Address: "localhost:8125"}
+	err = cfg.IsValid()
+	require.Error(t, err)
+	require.Equal(t, "invalid StatsD config: invalid FlushIntervalMs value: should be a positive number", err.Error())
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"fmt"
+)
+
+type Config struct {
+	// EnableCallMetrics controls whether per-call media pipeline metrics
+	// should be exported.
+	EnableCallMetrics bool `toml:"enable_call_metrics"`
+	// CallMetricsMaxCalls limits the number of calls metrics are exported
+	// for, picking the ones forwarding the most traffic. Zero means no limit.
+	CallMetricsMaxCalls int `toml:"call_metrics_max_calls"`
+	// CallMetricsHashIDs controls whether call IDs should be hashed before
+	// being used as label values.
+	CallMetricsHashIDs bool `toml:"call_metrics_hash_ids"`
+	// AggregateCallsThreshold is the number of ongoing calls past which the
+	// metrics partitioned by call are aggregated per registered client
+	// instead, with AggregatedCallID as callID label. Zero disables
+	// aggregation.
+	AggregateCallsThreshold int `toml:"aggregate_calls_threshold"`
+	// Watchdog configures the monitoring of the service's own resource usage.
+	Watchdog WatchdogConfig `toml:"watchdog"`
+	// StatsD configures the optional export of metrics to a StatsD server,
+	// alongside Prometheus.
+	StatsD StatsDConfig `toml:"statsd"`
+}
+
+func (c Config) IsValid() error {
+	if c.CallMetricsMaxCalls < 0 {
+		return fmt.Errorf("invalid CallMetricsMaxCalls value: should not be negative")
+	}
+	if c.AggregateCallsThreshold < 0 {
+		return fmt.Errorf("invalid AggregateCallsThreshold value: should not be negative")
+	}
+	if err := c.Watchdog.IsValid(); err != nil {
+		return fmt.Errorf("invalid Watchdog config: %w", err)
+	}
+	if err := c.StatsD.IsValid(); err != nil {
+		return fmt.Errorf("invalid StatsD config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"errors"
+)
+
+// ErrOpenFilesLimitUnsupported is returned on the platforms having no
+// per-process limit on open file descriptors.
+var ErrOpenFilesLimitUnsupported = errors.New("open files limit is not supported on this platform")
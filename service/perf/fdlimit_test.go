@@ -0,0 +1,44 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build !windows
+
+package perf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestRaiseOpenFilesLimit(t *testing.T) {
+	soft, hard, err := GetOpenFilesLimit()
+	require.NoError(t, err)
+	require.NotZero(t, soft)
+	require.GreaterOrEqual(t, hard, soft)
+
+	t.Run("already above target", func(t *testing.T) {
+		limit, err := RaiseOpenFilesLimit(soft - 1)
+		require.NoError(t, err)
+		require.Equal(t, soft, limit)
+	})
+
+	t.Run("up to the hard limit", func(t *testing.T) {
+		defer func() {
+			rlimit := newRlimit(soft, hard)
+			require.NoError(t, unix.Setrlimit(unix.RLIMIT_NOFILE, &rlimit))
+		}()
+
+		// The Go runtime raises the soft limit to the hard one at startup.
+		rlimit := newRlimit(64, hard)
+		require.NoError(t, unix.Setrlimit(unix.RLIMIT_NOFILE, &rlimit))
+
+		limit, err := RaiseOpenFilesLimit(128)
+		require.NoError(t, err)
+		require.Equal(t, uint64(128), limit)
+		newSoft, _, err := GetOpenFilesLimit()
+		require.NoError(t, err)
+		require.Equal(t, uint64(128), newSoft)
+	})
+}
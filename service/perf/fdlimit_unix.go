@@ -0,0 +1,51 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build !windows
+
+package perf
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// GetOpenFilesLimit returns the soft and hard limits of open file
+// descriptors (RLIMIT_NOFILE).
+func GetOpenFilesLimit() (uint64, uint64, error) {
+	var rlimit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rlimit); err != nil {
+		return 0, 0, fmt.Errorf("failed to get rlimit: %w", err)
+	}
+	return uint64(rlimit.Cur), uint64(rlimit.Max), nil
+}
+
+// RaiseOpenFilesLimit raises the soft limit of open file descriptors to
+// target. The hard limit is raised as well if needed, which requires
+// privileges, falling back to raising the soft limit up to the hard one. The
+// limit is never lowered. It returns the resulting soft limit.
+func RaiseOpenFilesLimit(target uint64) (uint64, error) {
+	soft, hard, err := GetOpenFilesLimit()
+	if err != nil {
+		return 0, err
+	}
+	if soft >= target {
+		return soft, nil
+	}
+
+	if target > hard {
+		rlimit := newRlimit(target, target)
+		if err := unix.Setrlimit(unix.RLIMIT_NOFILE, &rlimit); err == nil {
+			return target, nil
+		}
+		target = hard
+	}
+
+	rlimit := newRlimit(target, hard)
+	if err := unix.Setrlimit(unix.RLIMIT_NOFILE, &rlimit); err != nil {
+		return soft, fmt.Errorf("failed to set rlimit: %w", err)
+	}
+
+	return target, nil
+}
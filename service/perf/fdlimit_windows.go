@@ -0,0 +1,16 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+// GetOpenFilesLimit is not supported as Windows has no per-process limit on
+// open handles.
+func GetOpenFilesLimit() (uint64, uint64, error) {
+	return 0, 0, ErrOpenFilesLimitUnsupported
+}
+
+// RaiseOpenFilesLimit is not supported as Windows has no per-process limit
+// on open handles.
+func RaiseOpenFilesLimit(_ uint64) (uint64, error) {
+	return 0, ErrOpenFilesLimitUnsupported
+}
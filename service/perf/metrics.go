@@ -4,160 +4,417 @@
 package perf
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
 )
 
+// ErrSnapshotUnsupported is returned when taking a snapshot of metrics not
+// using the Prometheus backend.
+var ErrSnapshotUnsupported = errors.New("metrics snapshots require the prometheus backend")
+
 const (
-	metricsSubSystemRTC = "rtc"
-	metricsSubSystemWS  = "ws"
+	metricsSubSystemRTC   = "rtc"
+	metricsSubSystemWS    = "ws"
+	metricsSubSystemAuth  = "auth"
+	metricsSubSystemStore = "store"
+	// metricsSubSystemProcess doesn't clash with the metrics of the process
+	// collector as their names differ.
+	metricsSubSystemProcess = "process"
 )
 
 type Metrics struct {
+	// registry is only set when using the Prometheus backend.
 	registry *prometheus.Registry
+	backend  Backend
+
+	// RTPPacketCounters and RTPPacketBytesCounters are only created for the
+	// backends other than Prometheus, which has the RTP collector instead.
+	RTPPacketCounters        Counter
+	RTPPacketBytesCounters   Counter
+	RTXPacketCounters        Counter
+	SSRCCollisionCounters    Counter
+	EgressShapedPackets      Counter
+	SendQueueDrops           Counter
+	ConnectivityChecks       Gauge
+	JoinPhaseHistograms      Histogram
+	OneWayDelayHistograms    Histogram
+	UDPSocketBufferSizes     Gauge
+	PublicIPChanges          Counter
+	UDPConnWriteCounters     Counter
+	UDPConnWriteLatencies    Gauge
+	UDPConnRebindCounters    Counter
+	UDPSourceIPCacheSize     Gauge
+	UDPTruncatedPackets      Counter
+	CapacityHeadroom         Gauge
+	SRTPBenchmarkPacketTimes Gauge
+	RTCSessions              Gauge
+	RTCConnStateCounters     Counter
+	RTCSessionCloses         Counter
+	RTCErrors                Counter
+
+	WSConnections     Gauge
+	WSMessageCounters Counter
+	// WSStaleSessionCloses counts the sessions closed because their
+	// signaling connection was lost and not resumed in time.
+	WSStaleSessionCloses Counter
 
-	RTPPacketCounters      *prometheus.CounterVec
-	RTPPacketBytesCounters *prometheus.CounterVec
-	RTCSessions            *prometheus.GaugeVec
-	RTCConnStateCounters   *prometheus.CounterVec
-	RTCErrors              *prometheus.CounterVec
+	AuthFailureCounters Counter
+	AuthLockoutCounters Counter
 
-	WSConnections     *prometheus.GaugeVec
-	WSMessageCounters *prometheus.CounterVec
+	StoreSize               Gauge
+	StoreReclaimableSize    Gauge
+	StoreCompactionDuration Histogram
+
+	OpenFilesLimit Gauge
+
+	// callLabels assigns the callID label values of RTCSessions.
+	callLabels callLabels
 }
 
+// NewMetrics creates the metrics using the Prometheus backend. A new
+// registry, including the process and Go runtime collectors, is created if
+// none is given.
 func NewMetrics(namespace string, registry *prometheus.Registry) *Metrics {
-	var m Metrics
-
-	if registry != nil {
-		m.registry = registry
-	} else {
-		m.registry = prometheus.NewRegistry()
-		m.registry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{
-			Namespace: namespace,
-		}))
-		m.registry.MustRegister(collectors.NewGoCollector())
-	}
-
-	m.RTPPacketCounters = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: namespace,
-			Subsystem: metricsSubSystemRTC,
-			Name:      "rtp_packets_total",
-			Help:      "Total number of sent/received RTP packets",
-		},
-		[]string{"direction", "type"},
-	)
-	m.registry.MustRegister(m.RTPPacketCounters)
-
-	m.RTPPacketBytesCounters = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: namespace,
-			Subsystem: metricsSubSystemRTC,
-			Name:      "rtp_bytes_total",
-			Help:      "Total number of sent/received RTP packet bytes",
-		},
-		[]string{"direction", "type"},
-	)
-	m.registry.MustRegister(m.RTPPacketBytesCounters)
-
-	m.RTCSessions = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: namespace,
-			Subsystem: metricsSubSystemRTC,
-			Name:      "sessions_total",
-			Help:      "Total number of active RTC sessions",
-		},
-		[]string{"groupID", "callID"},
-	)
-	m.registry.MustRegister(m.RTCSessions)
-
-	m.RTCConnStateCounters = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: namespace,
-			Subsystem: metricsSubSystemRTC,
-			Name:      "conn_states_total",
-			Help:      "Total number of RTC connection state changes",
-		},
-		[]string{"type"},
-	)
-	m.registry.MustRegister(m.RTCConnStateCounters)
-
-	m.RTCErrors = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: namespace,
-			Subsystem: metricsSubSystemRTC,
-			Name:      "errors_total",
-			Help:      "Total number of RTC related errors",
-		},
-		[]string{"groupID", "type"},
-	)
-	m.registry.MustRegister(m.RTCErrors)
-
-	m.WSConnections = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: namespace,
-			Subsystem: metricsSubSystemWS,
-			Name:      "connections_total",
-			Help:      "Total number of active WebSocket sessions",
-		},
-		[]string{"clientID"},
-	)
-	m.registry.MustRegister(m.WSConnections)
-
-	m.WSMessageCounters = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: namespace,
-			Subsystem: metricsSubSystemWS,
-			Name:      "messages_total",
-			Help:      "Total number of sent/received WebSocket messages",
-		},
-		[]string{"clientID", "type", "direction"},
-	)
-	m.registry.MustRegister(m.WSMessageCounters)
-
-	return &m
+	if registry == nil {
+		registry = NewRegistry(namespace)
+	}
+
+	m, err := NewMetricsWithBackend(namespace, NewPrometheusBackend(registry))
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// NewRegistry returns a Prometheus registry including the process and Go
+// runtime collectors.
+func NewRegistry(namespace string) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{
+		Namespace: namespace,
+	}))
+	registry.MustRegister(collectors.NewGoCollector())
+	return registry
+}
+
+// NewMetricsWithBackend creates the metrics using the given backend. The
+// collectors computing values on scrape (calls, usage, RTP) and the HTTP
+// handler are only available with the Prometheus backend.
+func NewMetricsWithBackend(namespace string, backend Backend) (*Metrics, error) {
+	if backend == nil {
+		return nil, fmt.Errorf("backend should not be nil")
+	}
+
+	m := Metrics{backend: backend}
+	if rp, ok := backend.(registryProvider); ok {
+		m.registry = rp.Registry()
+	}
+
+	var err error
+	newCounter := func(subsystem, name, help string, labels ...string) Counter {
+		if err != nil {
+			return nil
+		}
+		var c Counter
+		c, err = backend.NewCounter(MetricOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help, Labels: labels})
+		return c
+	}
+	newGauge := func(subsystem, name, help string, labels ...string) Gauge {
+		if err != nil {
+			return nil
+		}
+		var g Gauge
+		g, err = backend.NewGauge(MetricOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help, Labels: labels})
+		return g
+	}
+
+	if m.registry == nil {
+		m.RTPPacketCounters = newCounter(metricsSubSystemRTC, "rtp_packets_total",
+			"Total number of sent/received RTP packets", "direction", "type")
+		m.RTPPacketBytesCounters = newCounter(metricsSubSystemRTC, "rtp_bytes_total",
+			"Total number of sent/received RTP packet bytes", "direction", "type")
+	}
+	m.RTXPacketCounters = newCounter(metricsSubSystemRTC, "rtx_packets_total",
+		"Total number of received RTX packets by outcome (repaired/dropped)", "type", "result")
+	m.SSRCCollisionCounters = newCounter(metricsSubSystemRTC, "ssrc_collisions_total",
+		"Total number of SSRC collisions between the streams of a session by direction (sent/received) and outcome (reassigned/unresolved)", "direction", "result")
+	m.EgressShapedPackets = newCounter(metricsSubSystemRTC, "egress_shaped_packets_total",
+		"Total number of packets dropped by the per-call egress shaping", "type")
+	m.SendQueueDrops = newCounter(metricsSubSystemRTC, "send_queue_dropped_packets_total",
+		"Total number of packets dropped because the send queue of their call was full by lane (priority/video)", "lane")
+	m.ConnectivityChecks = newGauge(metricsSubSystemRTC, "connectivity_check_ok",
+		"Outcome of the last connectivity check run against a STUN/TURN server (1 for success)", "type", "url")
+	m.UDPSocketBufferSizes = newGauge(metricsSubSystemRTC, "udp_socket_buffer_bytes",
+		"Effective size of the UDP socket buffers, as reported by the kernel", "direction")
+	m.UDPConnWriteCounters = newCounter(metricsSubSystemRTC, "udp_conn_writes_total",
+		"Total number of writes to each UDP socket by result (ok/error)", "conn", "result")
+	m.UDPConnWriteLatencies = newGauge(metricsSubSystemRTC, "udp_conn_write_latency_seconds",
+		"Moving average of the duration of the writes to each UDP socket", "conn")
+	m.UDPConnRebindCounters = newCounter(metricsSubSystemRTC, "udp_conn_rebinds_total",
+		"Total number of UDP sockets rebound by reason (stalled/stopped)", "reason")
+	m.UDPSourceIPCacheSize = newGauge(metricsSubSystemRTC, "udp_source_ip_cache_entries",
+		"Number of remote addresses the local IP to send from is cached for")
+	m.UDPTruncatedPackets = newCounter(metricsSubSystemRTC, "udp_truncated_packets_total",
+		"Total number of received packets likely truncated because larger than the receive MTU")
+	m.CapacityHeadroom = newGauge(metricsSubSystemRTC, "capacity_headroom",
+		"Estimated fraction of the capacity left on the node, for the most loaded of the limited resources")
+	m.SRTPBenchmarkPacketTimes = newGauge(metricsSubSystemRTC, "srtp_startup_benchmark_packet_seconds",
+		"Time taken to encrypt a video packet with each SRTP protection profile in the benchmark run at startup, not live traffic", "profile")
+	m.PublicIPChanges = newCounter(metricsSubSystemRTC, "public_ip_changes_total",
+		"Total number of changes of the public IP address discovered through STUN")
+	m.RTCSessions = newGauge(metricsSubSystemRTC, "sessions_total",
+		"Total number of active RTC sessions", "groupID", "callID")
+	m.RTCConnStateCounters = newCounter(metricsSubSystemRTC, "conn_states_total",
+		"Total number of RTC connection state changes", "type")
+	m.RTCSessionCloses = newCounter(metricsSubSystemRTC, "session_closes_total",
+		"Total number of closed RTC sessions by reason", "reason")
+	m.RTCErrors = newCounter(metricsSubSystemRTC, "errors_total",
+		"Total number of RTC related errors", "groupID", "type")
+	m.WSConnections = newGauge(metricsSubSystemWS, "connections_total",
+		"Total number of active WebSocket sessions", "clientID")
+	m.WSMessageCounters = newCounter(metricsSubSystemWS, "messages_total",
+		"Total number of sent/received WebSocket messages", "clientID", "type", "direction")
+	m.WSStaleSessionCloses = newCounter(metricsSubSystemWS, "stale_session_closes_total",
+		"Total number of sessions closed because their WebSocket connection was lost and not resumed in time", "clientID")
+	m.AuthFailureCounters = newCounter(metricsSubSystemAuth, "failures_total",
+		"Total number of failed authentication attempts by reason (invalid/locked)", "reason")
+	m.AuthLockoutCounters = newCounter(metricsSubSystemAuth, "lockouts_total",
+		"Total number of lockouts following failed authentication attempts", "type")
+	m.StoreSize = newGauge(metricsSubSystemStore, "size_bytes",
+		"Size of the files of the data store")
+	m.StoreReclaimableSize = newGauge(metricsSubSystemStore, "reclaimable_bytes",
+		"Size of the overwritten and deleted entries of the data store compaction would reclaim")
+	m.OpenFilesLimit = newGauge(metricsSubSystemProcess, "open_files_limit",
+		"Effective limit on the number of open file descriptors (RLIMIT_NOFILE)")
+	if err != nil {
+		return nil, err
+	}
+
+	m.JoinPhaseHistograms, err = backend.NewHistogram(MetricOpts{
+		Namespace: namespace,
+		Subsystem: metricsSubSystemRTC,
+		Name:      "session_join_phase_seconds",
+		Help:      "Time it took sessions to reach each setup phase since they were initialized",
+		Labels:    []string{"phase"},
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.StoreCompactionDuration, err = backend.NewHistogram(MetricOpts{
+		Namespace: namespace,
+		Subsystem: metricsSubSystemStore,
+		Name:      "compaction_duration_seconds",
+		Help:      "Time it took to compact the data store",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.OneWayDelayHistograms, err = backend.NewHistogram(MetricOpts{
+		Namespace: namespace,
+		Subsystem: metricsSubSystemRTC,
+		Name:      "one_way_delay_seconds",
+		Help:      "Estimated one-way delay of the forwarded packets on the publisher (uplink) and subscriber (downlink) legs",
+		Labels:    []string{"leg"},
+		Buckets:   []float64{0.01, 0.025, 0.05, 0.1, 0.15, 0.2, 0.3, 0.5, 1, 2},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
 }
 
 func (m *Metrics) IncRTCSessions(groupID string, callID string) {
-	m.RTCSessions.With(prometheus.Labels{"groupID": groupID, "callID": callID}).Inc()
+	m.RTCSessions.Add(1, groupID, m.callLabels.acquire(groupID, callID))
 }
 
 func (m *Metrics) DecRTCSessions(groupID string, callID string) {
-	m.RTCSessions.With(prometheus.Labels{"groupID": groupID, "callID": callID}).Dec()
+	m.RTCSessions.Add(-1, groupID, m.callLabels.release(groupID, callID))
 }
 
 func (m *Metrics) IncRTCConnState(state string) {
-	m.RTCConnStateCounters.With(prometheus.Labels{"type": state}).Inc()
+	m.RTCConnStateCounters.Add(1, state)
+}
+
+func (m *Metrics) IncRTCSessionCloses(reason string) {
+	m.RTCSessionCloses.Add(1, reason)
 }
 
 func (m *Metrics) IncRTCErrors(groupID string, errType string) {
-	m.RTCErrors.With(prometheus.Labels{"type": errType, "groupID": groupID}).Inc()
+	m.RTCErrors.Add(1, groupID, errType)
 }
 
-func (m *Metrics) IncRTPPackets(direction, trackType string) {
-	m.RTPPacketCounters.With(prometheus.Labels{"direction": direction, "type": trackType}).Inc()
+// AddRTPPackets is a no-op with the Prometheus backend, the RTP collector
+// exporting the counters on scrape.
+func (m *Metrics) AddRTPPackets(direction, trackType string, value int) {
+	if m.RTPPacketCounters != nil {
+		m.RTPPacketCounters.Add(float64(value), direction, trackType)
+	}
 }
 
+// AddRTPPacketBytes is a no-op with the Prometheus backend, the RTP collector
+// exporting the counters on scrape.
 func (m *Metrics) AddRTPPacketBytes(direction, trackType string, value int) {
-	m.RTPPacketBytesCounters.With(prometheus.Labels{"direction": direction, "type": trackType}).Add(float64(value))
+	if m.RTPPacketBytesCounters != nil {
+		m.RTPPacketBytesCounters.Add(float64(value), direction, trackType)
+	}
+}
+
+func (m *Metrics) IncRTXPackets(trackType, result string) {
+	m.RTXPacketCounters.Add(1, trackType, result)
+}
+
+func (m *Metrics) IncSSRCCollisions(direction, result string) {
+	m.SSRCCollisionCounters.Add(1, direction, result)
+}
+
+func (m *Metrics) IncEgressShapedPackets(trackType string) {
+	m.EgressShapedPackets.Add(1, trackType)
+}
+
+func (m *Metrics) IncSendQueueDrops(lane string) {
+	m.SendQueueDrops.Add(1, lane)
+}
+
+func (m *Metrics) SetConnectivityCheck(checkType, url string, ok bool) {
+	var val float64
+	if ok {
+		val = 1
+	}
+	m.ConnectivityChecks.Set(val, checkType, url)
+}
+
+// ObserveJoinPhase observes the time taken by a session to reach the given
+// setup phase. The trace ID of the session is attached as an exemplar, if
+// the backend supports it, so that slow joins can be looked up in the logs.
+func (m *Metrics) ObserveJoinPhase(phase string, seconds float64, traceID string) {
+	var exemplar map[string]string
+	if traceID != "" {
+		exemplar = map[string]string{"trace_id": traceID}
+	}
+	observeWithExemplar(m.JoinPhaseHistograms, seconds, exemplar, phase)
+}
+
+// ObserveOneWayDelay observes the estimated one-way delay of the packets
+// forwarded on the given leg.
+func (m *Metrics) ObserveOneWayDelay(leg string, seconds float64) {
+	m.OneWayDelayHistograms.Observe(seconds, leg)
+}
+
+func (m *Metrics) SetUDPSocketBufferSize(direction string, size int) {
+	m.UDPSocketBufferSizes.Set(float64(size), direction)
+}
+
+func (m *Metrics) AddUDPConnWrites(conn string, writes, errors uint64) {
+	m.UDPConnWriteCounters.Add(float64(writes-errors), conn, "ok")
+	m.UDPConnWriteCounters.Add(float64(errors), conn, "error")
+}
+
+func (m *Metrics) SetUDPConnWriteLatency(conn string, seconds float64) {
+	m.UDPConnWriteLatencies.Set(seconds, conn)
+}
+
+func (m *Metrics) IncUDPConnRebinds(reason string) {
+	m.UDPConnRebindCounters.Add(1, reason)
+}
+
+func (m *Metrics) SetUDPSourceIPCacheSize(size int) {
+	m.UDPSourceIPCacheSize.Set(float64(size))
+}
+
+func (m *Metrics) AddUDPTruncatedPackets(count uint64) {
+	m.UDPTruncatedPackets.Add(float64(count))
+}
+
+func (m *Metrics) SetCapacityHeadroom(headroom float64) {
+	m.CapacityHeadroom.Set(headroom)
+}
+
+func (m *Metrics) SetSRTPBenchmarkPacketTime(profile string, seconds float64) {
+	m.SRTPBenchmarkPacketTimes.Set(seconds, profile)
+}
+
+func (m *Metrics) IncPublicIPChanges() {
+	m.PublicIPChanges.Add(1)
 }
 
 func (m *Metrics) IncWSConnections(clientID string) {
-	m.WSConnections.With(prometheus.Labels{"clientID": clientID}).Inc()
+	m.WSConnections.Add(1, clientID)
 }
 
 func (m *Metrics) DecWSConnections(clientID string) {
-	m.WSConnections.With(prometheus.Labels{"clientID": clientID}).Dec()
+	m.WSConnections.Add(-1, clientID)
 }
 
 func (m *Metrics) IncWSMessages(clientID, msgType, direction string) {
-	m.WSMessageCounters.With(prometheus.Labels{"clientID": clientID, "type": msgType, "direction": direction}).Inc()
+	m.WSMessageCounters.Add(1, clientID, msgType, direction)
 }
 
+func (m *Metrics) IncWSStaleSessionCloses(clientID string) {
+	m.WSStaleSessionCloses.Add(1, clientID)
+}
+
+func (m *Metrics) IncAuthFailures(reason string) {
+	m.AuthFailureCounters.Add(1, reason)
+}
+
+func (m *Metrics) IncAuthLockouts(lockoutType string) {
+	m.AuthLockoutCounters.Add(1, lockoutType)
+}
+
+func (m *Metrics) SetStoreSize(size, reclaimable int64) {
+	m.StoreSize.Set(float64(size))
+	m.StoreReclaimableSize.Set(float64(reclaimable))
+}
+
+func (m *Metrics) ObserveStoreCompaction(seconds float64) {
+	m.StoreCompactionDuration.Observe(seconds)
+}
+
+func (m *Metrics) SetOpenFilesLimit(limit uint64) {
+	m.OpenFilesLimit.Set(float64(limit))
+}
+
+// Handler returns the HTTP handler exposing the metrics. It's nil if not
+// using the Prometheus backend.
 func (m *Metrics) Handler() http.Handler {
-	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	if m.registry == nil {
+		return nil
+	}
+	// Exemplars are only exposed in the OpenMetrics format, served to the
+	// scrapers asking for it.
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// WriteSnapshot writes the current value of the metrics to w, in the
+// Prometheus text format.
+func (m *Metrics) WriteSnapshot(w io.Writer) error {
+	if m.registry == nil {
+		return ErrSnapshotUnsupported
+	}
+
+	families, err := m.registry.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	enc := expfmt.NewEncoder(w, expfmt.FmtText)
+	for _, mf := range families {
+		if err := enc.Encode(mf); err != nil {
+			return fmt.Errorf("failed to encode metrics: %w", err)
+		}
+	}
+
+	return nil
 }
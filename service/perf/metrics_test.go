@@ -0,0 +1,156 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// testBackend records the metric values keyed by name and label values.
+type testBackend struct {
+	values map[string]float64
+	mut    sync.Mutex
+}
+
+type testMetric struct {
+	b    *testBackend
+	name string
+}
+
+func (b *testBackend) newMetric(opts MetricOpts) testMetric {
+	return testMetric{b: b, name: opts.Subsystem + "_" + opts.Name}
+}
+
+func (b *testBackend) NewCounter(opts MetricOpts) (Counter, error) {
+	return b.newMetric(opts), nil
+}
+
+func (b *testBackend) NewGauge(opts MetricOpts) (Gauge, error) {
+	return b.newMetric(opts), nil
+}
+
+func (b *testBackend) NewHistogram(opts MetricOpts) (Histogram, error) {
+	if len(opts.Buckets) == 0 {
+		return nil, fmt.Errorf("missing buckets")
+	}
+	return b.newMetric(opts), nil
+}
+
+func (m testMetric) key(labelValues []string) string {
+	return m.name + "{" + strings.Join(labelValues, ",") + "}"
+}
+
+func (m testMetric) Add(value float64, labelValues ...string) {
+	m.b.mut.Lock()
+	defer m.b.mut.Unlock()
+	m.b.values[m.key(labelValues)] += value
+}
+
+func (m testMetric) Set(value float64, labelValues ...string) {
+	m.b.mut.Lock()
+	defer m.b.mut.Unlock()
+	m.b.values[m.key(labelValues)] = value
+}
+
+func (m testMetric) Observe(value float64, labelValues ...string) {
+	m.Set(value, labelValues...)
+}
+
+func TestNewMetricsWithBackend(t *testing.T) {
+	t.Run("nil backend", func(t *testing.T) {
+		m, err := NewMetricsWithBackend("rtcd", nil)
+		require.EqualError(t, err, "backend should not be nil")
+		require.Nil(t, m)
+	})
+
+	t.Run("custom backend", func(t *testing.T) {
+		b := &testBackend{values: map[string]float64{}}
+		m, err := NewMetricsWithBackend("rtcd", b)
+		require.NoError(t, err)
+		require.Nil(t, m.Handler())
+		require.ErrorIs(t, m.WriteSnapshot(&strings.Builder{}), ErrSnapshotUnsupported)
+		require.NoError(t, m.RegisterCallsCollector("rtcd", Config{}, func() []CallStats { return nil }))
+		require.NoError(t, m.RegisterUsageCollector("rtcd", func() []ClientUsage { return nil }))
+
+		m.IncRTCSessions("groupID", "callID")
+		m.IncRTCSessions("groupID", "callID")
+		m.DecRTCSessions("groupID", "callID")
+		m.IncRTCErrors("groupID", "rtp")
+		m.AddRTPPackets("in", "voice", 2)
+		m.AddRTPPacketBytes("in", "voice", 100)
+		m.IncEgressShapedPackets("screen")
+		m.IncSendQueueDrops("video")
+		m.ObserveJoinPhase("ice_connected", 0.5, "traceID")
+		m.ObserveOneWayDelay("uplink", 0.05)
+		m.IncWSMessages("clientID", "join", "in")
+		m.IncWSStaleSessionCloses("clientID")
+		m.SetOpenFilesLimit(4096)
+		m.SetStoreSize(2048, 1024)
+		m.ObserveStoreCompaction(0.5)
+		m.IncAuthFailures("invalid")
+		m.IncAuthLockouts("ip")
+
+		require.Equal(t, map[string]float64{
+			"rtc_sessions_total{groupID,callID}":            1,
+			"rtc_errors_total{groupID,rtp}":                 1,
+			"rtc_rtp_packets_total{in,voice}":               2,
+			"rtc_rtp_bytes_total{in,voice}":                 100,
+			"rtc_egress_shaped_packets_total{screen}":       1,
+			"rtc_send_queue_dropped_packets_total{video}":   1,
+			"rtc_session_join_phase_seconds{ice_connected}": 0.5,
+			"rtc_one_way_delay_seconds{uplink}":             0.05,
+			"ws_messages_total{clientID,join,in}":           1,
+			"ws_stale_session_closes_total{clientID}":       1,
+			"process_open_files_limit{}":                    4096,
+			"store_size_bytes{}":                            2048,
+			"store_reclaimable_bytes{}":                     1024,
+			"store_compaction_duration_seconds{}":           0.5,
+			"auth_failures_total{invalid}":                  1,
+			"auth_lockouts_total{ip}":                       1,
+		}, b.values)
+
+		w, err := m.NewWatchdog("rtcd", WatchdogConfig{}, nil)
+		require.NoError(t, err)
+		w.AddChannels("rtc", func() map[string]int {
+			return map[string]int{"send": 4}
+		})
+		w.check(time.Now())
+		require.Equal(t, float64(4), b.values["watchdog_channel_depth{rtc_send}"])
+	})
+
+	t.Run("prometheus backend", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		m := NewMetrics("rtcd", registry)
+		require.NotNil(t, m.Handler())
+
+		m.IncRTCErrors("groupID", "rtp")
+		require.Equal(t, float64(1), testutil.ToFloat64(m.RTCErrors.(promCounter).WithLabelValues("groupID", "rtp")))
+
+		var snapshot strings.Builder
+		require.NoError(t, m.WriteSnapshot(&snapshot))
+		require.Contains(t, snapshot.String(), `rtcd_rtc_errors_total{groupID="groupID",type="rtp"} 1`)
+
+		// Exemplars are exposed in the OpenMetrics format.
+		m.ObserveJoinPhase("ice_connected", 0.5, "traceID")
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Accept", "application/openmetrics-text")
+		rec := httptest.NewRecorder()
+		m.Handler().ServeHTTP(rec, req)
+		require.Contains(t, rec.Body.String(), `# {trace_id="traceID"} 0.5`)
+
+		// Registering the same metrics twice fails.
+		_, err := NewMetricsWithBackend("rtcd", NewPrometheusBackend(registry))
+		require.Error(t, err)
+	})
+}
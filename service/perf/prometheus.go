@@ -0,0 +1,97 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusBackend is the default Backend, registering the metrics to a
+// Prometheus registry.
+type PrometheusBackend struct {
+	registry *prometheus.Registry
+}
+
+type promCounter struct {
+	*prometheus.CounterVec
+}
+
+type promGauge struct {
+	*prometheus.GaugeVec
+}
+
+type promHistogram struct {
+	*prometheus.HistogramVec
+}
+
+func NewPrometheusBackend(registry *prometheus.Registry) *PrometheusBackend {
+	return &PrometheusBackend{registry: registry}
+}
+
+// Registry returns the registry the metrics are registered to.
+func (b *PrometheusBackend) Registry() *prometheus.Registry {
+	return b.registry
+}
+
+func (b *PrometheusBackend) register(c prometheus.Collector) error {
+	if err := b.registry.Register(c); err != nil {
+		return fmt.Errorf("failed to register metric: %w", err)
+	}
+	return nil
+}
+
+func (b *PrometheusBackend) NewCounter(opts MetricOpts) (Counter, error) {
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      opts.Name,
+		Help:      opts.Help,
+	}, opts.Labels)
+	return promCounter{c}, b.register(c)
+}
+
+func (b *PrometheusBackend) NewGauge(opts MetricOpts) (Gauge, error) {
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      opts.Name,
+		Help:      opts.Help,
+	}, opts.Labels)
+	return promGauge{g}, b.register(g)
+}
+
+func (b *PrometheusBackend) NewHistogram(opts MetricOpts) (Histogram, error) {
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      opts.Name,
+		Help:      opts.Help,
+		Buckets:   opts.Buckets,
+	}, opts.Labels)
+	return promHistogram{h}, b.register(h)
+}
+
+func (c promCounter) Add(value float64, labelValues ...string) {
+	c.WithLabelValues(labelValues...).Add(value)
+}
+
+func (g promGauge) Set(value float64, labelValues ...string) {
+	g.WithLabelValues(labelValues...).Set(value)
+}
+
+func (g promGauge) Add(value float64, labelValues ...string) {
+	g.WithLabelValues(labelValues...).Add(value)
+}
+
+func (h promHistogram) Observe(value float64, labelValues ...string) {
+	h.WithLabelValues(labelValues...).Observe(value)
+}
+
+func (h promHistogram) ObserveWithExemplar(value float64, exemplar map[string]string, labelValues ...string) {
+	// Histograms created by NewHistogramVec always implement
+	// ExemplarObserver.
+	h.WithLabelValues(labelValues...).(prometheus.ExemplarObserver).ObserveWithExemplar(value, exemplar)
+}
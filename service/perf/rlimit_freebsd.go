@@ -0,0 +1,13 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// newRlimit is needed as the limits are signed on FreeBSD.
+func newRlimit(soft, hard uint64) unix.Rlimit {
+	return unix.Rlimit{Cur: int64(soft), Max: int64(hard)}
+}
@@ -0,0 +1,14 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build !windows && !freebsd
+
+package perf
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+func newRlimit(soft, hard uint64) unix.Rlimit {
+	return unix.Rlimit{Cur: soft, Max: hard}
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RTPStats holds the values exported by the RTP collector for a direction
+// (in/out) and a type of track.
+type RTPStats struct {
+	Direction string
+	TrackType string
+	Packets   uint64
+	Bytes     uint64
+}
+
+// rtpCollector exports the RTP traffic counters, computed on each scrape out
+// of the stats returned by getStats. Counting every packet through a
+// counter vector would look up its labels, under lock, on the media path.
+type rtpCollector struct {
+	getStats func() []RTPStats
+	packets  *prometheus.Desc
+	bytes    *prometheus.Desc
+}
+
+// RegisterRTPCollector registers a collector exporting the RTP traffic
+// counters returned by getStats. It's a no-op if not using the Prometheus
+// backend.
+func (m *Metrics) RegisterRTPCollector(namespace string, getStats func() []RTPStats) error {
+	if m.registry == nil {
+		return nil
+	}
+
+	newDesc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, metricsSubSystemRTC, name), help,
+			[]string{"direction", "type"}, nil)
+	}
+
+	return m.registry.Register(&rtpCollector{
+		getStats: getStats,
+		packets:  newDesc("rtp_packets_total", "Total number of sent/received RTP packets"),
+		bytes:    newDesc("rtp_bytes_total", "Total number of sent/received RTP packet bytes"),
+	})
+}
+
+func (c *rtpCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.packets
+	ch <- c.bytes
+}
+
+func (c *rtpCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.getStats() {
+		ch <- prometheus.MustNewConstMetric(c.packets, prometheus.CounterValue, float64(s.Packets), s.Direction, s.TrackType)
+		ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.CounterValue, float64(s.Bytes), s.Direction, s.TrackType)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRTPCollector(t *testing.T) {
+	m := NewMetrics("rtcd", prometheus.NewRegistry())
+	err := m.RegisterRTPCollector("rtcd", func() []RTPStats {
+		return []RTPStats{
+			{Direction: "in", TrackType: "voice", Packets: 10, Bytes: 1000},
+			{Direction: "out", TrackType: "voice", Packets: 20, Bytes: 2000},
+		}
+	})
+	require.NoError(t, err)
+
+	expected := `
+# HELP rtcd_rtc_rtp_bytes_total Total number of sent/received RTP packet bytes
+# TYPE rtcd_rtc_rtp_bytes_total counter
+rtcd_rtc_rtp_bytes_total{direction="in",type="voice"} 1000
+rtcd_rtc_rtp_bytes_total{direction="out",type="voice"} 2000
+# HELP rtcd_rtc_rtp_packets_total Total number of sent/received RTP packets
+# TYPE rtcd_rtc_rtp_packets_total counter
+rtcd_rtc_rtp_packets_total{direction="in",type="voice"} 10
+rtcd_rtc_rtp_packets_total{direction="out",type="voice"} 20
+`
+	err = testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "rtcd_rtc_rtp_packets_total", "rtcd_rtc_rtp_bytes_total")
+	require.NoError(t, err)
+
+	t.Run("pushed counters ignored", func(t *testing.T) {
+		m.AddRTPPackets("in", "voice", 5)
+		m.AddRTPPacketBytes("in", "voice", 500)
+		err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "rtcd_rtc_rtp_packets_total", "rtcd_rtc_rtp_bytes_total")
+		require.NoError(t, err)
+	})
+
+	t.Run("custom backend", func(t *testing.T) {
+		m, err := NewMetricsWithBackend("rtcd", &testBackend{values: map[string]float64{}})
+		require.NoError(t, err)
+		require.NoError(t, m.RegisterRTPCollector("rtcd", func() []RTPStats { return nil }))
+	})
+}
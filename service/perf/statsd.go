@@ -0,0 +1,248 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statsDMaxPacketSize keeps the packets within the MTU of most networks.
+const statsDMaxPacketSize = 1432
+
+type StatsDConfig struct {
+	// Enable controls whether metrics should also be sent to a StatsD server.
+	Enable bool `toml:"enable"`
+	// Address is the UDP address (host:port) of the StatsD server or agent.
+	Address string `toml:"address"`
+	// Prefix is an optional string prepended to all metric names.
+	Prefix string `toml:"prefix"`
+	// DogStatsD controls whether labels should be sent as DogStatsD tags. If
+	// false, label values are appended to the metric names.
+	DogStatsD bool `toml:"dogstatsd"`
+	// FlushIntervalMs is the maximum time metrics are buffered for before
+	// being sent.
+	FlushIntervalMs int `toml:"flush_interval_ms"`
+}
+
+func (c StatsDConfig) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return fmt.Errorf("invalid Address value: %w", err)
+	}
+
+	if c.FlushIntervalMs <= 0 {
+		return fmt.Errorf("invalid FlushIntervalMs value: should be a positive number")
+	}
+
+	return nil
+}
+
+// StatsDBackend is a Backend sending the metrics to a StatsD server over
+// UDP. Histograms are sent as DogStatsD histograms or, for plain StatsD, as
+// timers in milliseconds.
+type StatsDBackend struct {
+	cfg  StatsDConfig
+	conn net.Conn
+	buf  []byte
+
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+	mut      sync.Mutex
+}
+
+type statsDMetric struct {
+	b      *StatsDBackend
+	name   string
+	labels []string
+}
+
+// statsDGauge keeps the gauge values, keyed by label values, as not all
+// servers support relative updates.
+type statsDGauge struct {
+	statsDMetric
+	values map[string]float64
+	mut    sync.Mutex
+}
+
+func NewStatsDBackend(cfg StatsDConfig) (*StatsDBackend, error) {
+	if err := cfg.IsValid(); err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("udp", cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial statsd server: %w", err)
+	}
+
+	b := &StatsDBackend{
+		cfg:    cfg,
+		conn:   conn,
+		buf:    make([]byte, 0, statsDMaxPacketSize),
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+	go b.run()
+
+	return b, nil
+}
+
+func (b *StatsDBackend) run() {
+	defer close(b.doneCh)
+
+	ticker := time.NewTicker(time.Duration(b.cfg.FlushIntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.mut.Lock()
+			b.flushLocked()
+			b.mut.Unlock()
+		case <-b.stopCh:
+			return
+		}
+	}
+}
+
+// Close flushes the buffered metrics and closes the connection.
+func (b *StatsDBackend) Close() error {
+	var err error
+	b.stopOnce.Do(func() {
+		close(b.stopCh)
+		<-b.doneCh
+		b.mut.Lock()
+		defer b.mut.Unlock()
+		b.flushLocked()
+		err = b.conn.Close()
+	})
+	return err
+}
+
+func (b *StatsDBackend) flushLocked() {
+	if len(b.buf) == 0 {
+		return
+	}
+	// Errors are ignored, metrics are sent on a best effort basis.
+	_, _ = b.conn.Write(b.buf)
+	b.buf = b.buf[:0]
+}
+
+func (b *StatsDBackend) send(line string) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	if len(b.buf) > 0 && len(b.buf)+1+len(line) > statsDMaxPacketSize {
+		b.flushLocked()
+	}
+	if len(b.buf) > 0 {
+		b.buf = append(b.buf, '\n')
+	}
+	b.buf = append(b.buf, line...)
+}
+
+func (b *StatsDBackend) newMetric(opts MetricOpts) statsDMetric {
+	var parts []string
+	for _, p := range []string{b.cfg.Prefix, opts.Namespace, opts.Subsystem, opts.Name} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return statsDMetric{
+		b:      b,
+		name:   strings.Join(parts, "."),
+		labels: opts.Labels,
+	}
+}
+
+func (b *StatsDBackend) NewCounter(opts MetricOpts) (Counter, error) {
+	return b.newMetric(opts), nil
+}
+
+func (b *StatsDBackend) NewGauge(opts MetricOpts) (Gauge, error) {
+	return &statsDGauge{
+		statsDMetric: b.newMetric(opts),
+		values:       map[string]float64{},
+	}, nil
+}
+
+func (b *StatsDBackend) NewHistogram(opts MetricOpts) (Histogram, error) {
+	return b.newMetric(opts), nil
+}
+
+// sanitizeStatsD replaces the characters having a meaning in the StatsD
+// line protocol.
+func sanitizeStatsD(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '#', ',', '\n', ' ':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func (m statsDMetric) line(value float64, typ string, labelValues []string) string {
+	var sb strings.Builder
+	sb.WriteString(m.name)
+	if !m.b.cfg.DogStatsD {
+		for _, v := range labelValues {
+			sb.WriteByte('.')
+			sb.WriteString(strings.ReplaceAll(sanitizeStatsD(v), ".", "_"))
+		}
+	}
+	sb.WriteByte(':')
+	sb.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	sb.WriteByte('|')
+	sb.WriteString(typ)
+	if m.b.cfg.DogStatsD && len(labelValues) > 0 {
+		sb.WriteString("|#")
+		for i, v := range labelValues {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			if i < len(m.labels) {
+				sb.WriteString(m.labels[i])
+				sb.WriteByte(':')
+			}
+			sb.WriteString(sanitizeStatsD(v))
+		}
+	}
+	return sb.String()
+}
+
+func (m statsDMetric) Add(value float64, labelValues ...string) {
+	m.b.send(m.line(value, "c", labelValues))
+}
+
+func (m statsDMetric) Observe(value float64, labelValues ...string) {
+	if m.b.cfg.DogStatsD {
+		m.b.send(m.line(value, "h", labelValues))
+		return
+	}
+	m.b.send(m.line(value*1000, "ms", labelValues))
+}
+
+func (g *statsDGauge) Set(value float64, labelValues ...string) {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	g.values[strings.Join(labelValues, "\x00")] = value
+	g.b.send(g.line(value, "g", labelValues))
+}
+
+func (g *statsDGauge) Add(value float64, labelValues ...string) {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	key := strings.Join(labelValues, "\x00")
+	value += g.values[key]
+	g.values[key] = value
+	g.b.send(g.line(value, "g", labelValues))
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsDConfigIsValid(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		var cfg StatsDConfig
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		cfg := StatsDConfig{Enable: true, Address: "localhost", FlushIntervalMs: 1000}
+		require.EqualError(t, cfg.IsValid(), "invalid Address value: address localhost: missing port in address")
+	})
+
+	t.Run("invalid flush interval", func(t *testing.T) {
+		cfg := StatsDConfig{Enable: true, Address: "localhost:8125"}
+		require.EqualError(t, cfg.IsValid(), "invalid FlushIntervalMs value: should be a positive number")
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := StatsDConfig{Enable: true, Address: "localhost:8125", FlushIntervalMs: 1000}
+		require.NoError(t, cfg.IsValid())
+	})
+}
+
+func TestStatsDBackend(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	readLines := func(t *testing.T) []string {
+		t.Helper()
+		buf := make([]byte, 2*statsDMaxPacketSize)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		require.LessOrEqual(t, n, statsDMaxPacketSize)
+		return strings.Split(string(buf[:n]), "\n")
+	}
+
+	newBackend := func(t *testing.T, dogStatsD bool) *StatsDBackend {
+		t.Helper()
+		b, err := NewStatsDBackend(StatsDConfig{
+			Enable:          true,
+			Address:         conn.LocalAddr().String(),
+			Prefix:          "test",
+			DogStatsD:       dogStatsD,
+			FlushIntervalMs: 10000,
+		})
+		require.NoError(t, err)
+		return b
+	}
+
+	t.Run("statsd", func(t *testing.T) {
+		b := newBackend(t, false)
+		m, err := NewMetricsWithBackend("rtcd", b)
+		require.NoError(t, err)
+
+		m.IncRTCErrors("group.ID", "rtp")
+		m.IncWSConnections("clientA")
+		m.IncWSConnections("clientA")
+		m.DecWSConnections("clientA")
+		m.ObserveJoinPhase("ice_connected", 0.25, "")
+		require.NoError(t, b.Close())
+
+		require.Equal(t, []string{
+			"test.rtcd.rtc.errors_total.group_ID.rtp:1|c",
+			"test.rtcd.ws.connections_total.clientA:1|g",
+			"test.rtcd.ws.connections_total.clientA:2|g",
+			"test.rtcd.ws.connections_total.clientA:1|g",
+			"test.rtcd.rtc.session_join_phase_seconds.ice_connected:250|ms",
+		}, readLines(t))
+	})
+
+	t.Run("dogstatsd", func(t *testing.T) {
+		b := newBackend(t, true)
+		m, err := NewMetricsWithBackend("rtcd", b)
+		require.NoError(t, err)
+
+		m.IncRTCErrors("groupID", "rtp")
+		m.SetConnectivityCheck("stun", "stun:host:3478", true)
+		m.ObserveJoinPhase("ice_connected", 0.25, "")
+		require.NoError(t, b.Close())
+
+		require.Equal(t, []string{
+			"test.rtcd.rtc.errors_total:1|c|#groupID:groupID,type:rtp",
+			"test.rtcd.rtc.connectivity_check_ok:1|g|#type:stun,url:stun_host_3478",
+			"test.rtcd.rtc.session_join_phase_seconds:0.25|h|#phase:ice_connected",
+		}, readLines(t))
+	})
+
+	t.Run("packet size", func(t *testing.T) {
+		b := newBackend(t, true)
+		c, err := b.NewCounter(MetricOpts{Name: strings.Repeat("a", 100)})
+		require.NoError(t, err)
+
+		// The buffer gets flushed once full.
+		for i := 0; i < 20; i++ {
+			c.Add(1)
+		}
+		perPacket := statsDMaxPacketSize / (len("test.") + 100 + len(":1|c") + 1)
+		require.Len(t, readLines(t), perPacket)
+		require.NoError(t, b.Close())
+		require.Len(t, readLines(t), 20-perPacket)
+	})
+
+	t.Run("flush interval", func(t *testing.T) {
+		b, err := NewStatsDBackend(StatsDConfig{
+			Enable:          true,
+			Address:         conn.LocalAddr().String(),
+			FlushIntervalMs: 10,
+		})
+		require.NoError(t, err)
+		defer b.Close()
+		c, err := b.NewCounter(MetricOpts{Namespace: "rtcd", Name: "counter"})
+		require.NoError(t, err)
+		c.Add(2)
+		require.Equal(t, []string{"rtcd.counter:2|c"}, readLines(t))
+	})
+}
+
+func TestMultiBackend(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	testBackend := &testBackend{values: map[string]float64{}}
+	m, err := NewMetricsWithBackend("rtcd", NewMultiBackend(testBackend, NewPrometheusBackend(registry)))
+	require.NoError(t, err)
+	require.NotNil(t, m.Handler())
+
+	m.IncRTCConnState("connected")
+	require.Equal(t, float64(1), testBackend.values["rtc_conn_states_total{connected}"])
+	count, err := testutil.GatherAndCount(registry, "rtcd_rtc_conn_states_total")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
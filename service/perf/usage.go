@@ -0,0 +1,51 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsSubSystemClient = "client"
+
+// ClientUsage holds the per-client values exported by the usage collector.
+type ClientUsage struct {
+	ClientID     string
+	IngressBytes uint64
+	EgressBytes  uint64
+}
+
+// usageCollector exports the cumulative media traffic of each client,
+// computed on each scrape out of the usage returned by getUsage.
+type usageCollector struct {
+	getUsage func() []ClientUsage
+	bytes    *prometheus.Desc
+}
+
+// RegisterUsageCollector registers a collector exporting the per-client
+// bandwidth usage returned by getUsage. It's a no-op if not using the
+// Prometheus backend.
+func (m *Metrics) RegisterUsageCollector(namespace string, getUsage func() []ClientUsage) error {
+	if m.registry == nil {
+		return nil
+	}
+
+	return m.registry.Register(&usageCollector{
+		getUsage: getUsage,
+		bytes: prometheus.NewDesc(prometheus.BuildFQName(namespace, metricsSubSystemClient, "rtp_bytes_total"),
+			"Total number of RTP payload bytes received from (in) and forwarded to (out) the client's sessions",
+			[]string{"clientID", "direction"}, nil),
+	})
+}
+
+func (c *usageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.bytes
+}
+
+func (c *usageCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, u := range c.getUsage() {
+		ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.CounterValue, float64(u.IngressBytes), u.ClientID, "in")
+		ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.CounterValue, float64(u.EgressBytes), u.ClientID, "out")
+	}
+}
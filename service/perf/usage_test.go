@@ -0,0 +1,35 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageCollector(t *testing.T) {
+	m := NewMetrics("rtcd", prometheus.NewRegistry())
+	err := m.RegisterUsageCollector("rtcd", func() []ClientUsage {
+		return []ClientUsage{
+			{ClientID: "clientA", IngressBytes: 1000, EgressBytes: 4000},
+			{ClientID: "clientB"},
+		}
+	})
+	require.NoError(t, err)
+
+	expected := `
+# HELP rtcd_client_rtp_bytes_total Total number of RTP payload bytes received from (in) and forwarded to (out) the client's sessions
+# TYPE rtcd_client_rtp_bytes_total counter
+rtcd_client_rtp_bytes_total{clientID="clientA",direction="in"} 1000
+rtcd_client_rtp_bytes_total{clientID="clientA",direction="out"} 4000
+rtcd_client_rtp_bytes_total{clientID="clientB",direction="in"} 0
+rtcd_client_rtp_bytes_total{clientID="clientB",direction="out"} 0
+`
+	err = testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "rtcd_client_rtp_bytes_total")
+	require.NoError(t, err)
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const (
+	metricsSubSystemWatchdog = "watchdog"
+	// heapProfileCooldown is the minimum interval between heap profile dumps.
+	heapProfileCooldown = 10 * time.Minute
+)
+
+type WatchdogConfig struct {
+	// Enable controls whether the service should monitor its own resource
+	// usage.
+	Enable bool `toml:"enable"`
+	// IntervalSeconds specifies how often resource usage is sampled.
+	IntervalSeconds int `toml:"interval_seconds"`
+	// MaxGoroutines is the number of goroutines above which a warning gets
+	// logged. Zero disables the check.
+	MaxGoroutines int `toml:"max_goroutines"`
+	// MaxOpenFDs is the number of open file descriptors above which a
+	// warning gets logged. Zero disables the check.
+	MaxOpenFDs int `toml:"max_open_fds"`
+	// MaxHeapMB is the size of the heap, in megabytes, above which a warning
+	// gets logged. Zero disables the check.
+	MaxHeapMB int `toml:"max_heap_mb"`
+	// MaxChannelDepth is the number of messages queued in any of the internal
+	// channels above which a warning gets logged. Zero disables the check.
+	MaxChannelDepth int `toml:"max_channel_depth"`
+	// HeapProfileDir is an optional directory where a heap profile is written
+	// when MaxHeapMB is exceeded.
+	HeapProfileDir string `toml:"heap_profile_dir"`
+}
+
+func (c WatchdogConfig) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+
+	if c.IntervalSeconds <= 0 {
+		return fmt.Errorf("invalid IntervalSeconds value: should be a positive number")
+	}
+
+	if c.MaxGoroutines < 0 || c.MaxOpenFDs < 0 || c.MaxHeapMB < 0 || c.MaxChannelDepth < 0 {
+		return fmt.Errorf("invalid thresholds: should not be negative")
+	}
+
+	return nil
+}
+
+// Watchdog periodically samples the resource usage of the process, exporting
+// it as metrics and logging a warning when a threshold is exceeded.
+type Watchdog struct {
+	cfg      WatchdogConfig
+	log      mlog.LoggerIFace
+	channels map[string]func() map[string]int
+
+	goroutines   Gauge
+	openFDs      Gauge
+	heapBytes    Gauge
+	channelDepth Gauge
+
+	lastProfileAt time.Time
+	started       bool
+	stopOnce      sync.Once
+	stopCh        chan struct{}
+	doneCh        chan struct{}
+}
+
+func (m *Metrics) NewWatchdog(namespace string, cfg WatchdogConfig, log mlog.LoggerIFace) (*Watchdog, error) {
+	if err := cfg.IsValid(); err != nil {
+		return nil, err
+	}
+
+	w := &Watchdog{
+		cfg:      cfg,
+		log:      log,
+		channels: map[string]func() map[string]int{},
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+
+	for _, g := range []struct {
+		gauge  *Gauge
+		name   string
+		help   string
+		labels []string
+	}{
+		{&w.goroutines, "goroutines", "Number of goroutines at the last sample", nil},
+		{&w.openFDs, "open_fds", "Number of open file descriptors at the last sample", nil},
+		{&w.heapBytes, "heap_bytes", "Size of the allocated heap at the last sample", nil},
+		{&w.channelDepth, "channel_depth", "Number of messages queued in internal channels at the last sample", []string{"channel"}},
+	} {
+		gauge, err := m.backend.NewGauge(MetricOpts{
+			Namespace: namespace,
+			Subsystem: metricsSubSystemWatchdog,
+			Name:      g.name,
+			Help:      g.help,
+			Labels:    g.labels,
+		})
+		if err != nil {
+			return nil, err
+		}
+		*g.gauge = gauge
+	}
+
+	return w, nil
+}
+
+// AddChannels registers a function returning the depths of a set of
+// channels, keyed by name. Names get prefixed by the given prefix.
+// Must be called before Start.
+func (w *Watchdog) AddChannels(prefix string, getDepths func() map[string]int) {
+	w.channels[prefix] = getDepths
+}
+
+// Start starts the watchdog. Must be called at most once.
+func (w *Watchdog) Start() {
+	w.started = true
+	go w.run()
+}
+
+// Stop stops the watchdog, waiting for it to be done if it was started.
+func (w *Watchdog) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+		if w.started {
+			<-w.doneCh
+		}
+	})
+}
+
+func (w *Watchdog) run() {
+	defer close(w.doneCh)
+
+	ticker := time.NewTicker(time.Duration(w.cfg.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			w.check(now)
+		case <-w.stopCh:
+			return
+		}
+	}
+}
+
+func (w *Watchdog) check(now time.Time) {
+	goroutines := runtime.NumGoroutine()
+	w.goroutines.Set(float64(goroutines))
+	if w.cfg.MaxGoroutines > 0 && goroutines > w.cfg.MaxGoroutines {
+		w.log.Warn("watchdog: goroutines threshold exceeded",
+			mlog.Int("goroutines", goroutines), mlog.Int("threshold", w.cfg.MaxGoroutines))
+	}
+
+	if fds, err := CountOpenFDs(); err == nil {
+		w.openFDs.Set(float64(fds))
+		if w.cfg.MaxOpenFDs > 0 && fds > w.cfg.MaxOpenFDs {
+			w.log.Warn("watchdog: open FDs threshold exceeded",
+				mlog.Int("fds", fds), mlog.Int("threshold", w.cfg.MaxOpenFDs))
+		}
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	w.heapBytes.Set(float64(ms.HeapAlloc))
+	if w.cfg.MaxHeapMB > 0 && ms.HeapAlloc > uint64(w.cfg.MaxHeapMB)*1024*1024 {
+		w.log.Warn("watchdog: heap threshold exceeded",
+			mlog.Uint64("heapBytes", ms.HeapAlloc), mlog.Int("thresholdMB", w.cfg.MaxHeapMB))
+		if w.cfg.HeapProfileDir != "" && now.Sub(w.lastProfileAt) > heapProfileCooldown {
+			w.lastProfileAt = now
+			if path, err := writeHeapProfile(w.cfg.HeapProfileDir, now); err != nil {
+				w.log.Error("watchdog: failed to write heap profile", mlog.Err(err))
+			} else {
+				w.log.Info("watchdog: heap profile written", mlog.String("path", path))
+			}
+		}
+	}
+
+	for prefix, getDepths := range w.channels {
+		for name, depth := range getDepths() {
+			name = prefix + "_" + name
+			w.channelDepth.Set(float64(depth), name)
+			if w.cfg.MaxChannelDepth > 0 && depth > w.cfg.MaxChannelDepth {
+				w.log.Warn("watchdog: channel depth threshold exceeded",
+					mlog.String("channel", name), mlog.Int("depth", depth), mlog.Int("threshold", w.cfg.MaxChannelDepth))
+			}
+		}
+	}
+}
+
+// CountOpenFDs returns the number of file descriptors opened by the process.
+// Only supported on systems exposing /proc.
+func CountOpenFDs() (int, error) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+func writeHeapProfile(dir string, now time.Time) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("heap-%s.pprof", now.UTC().Format("20060102T150405")))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer f.Close()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return "", fmt.Errorf("failed to write profile: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package perf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+func TestWatchdogConfigIsValid(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		var cfg WatchdogConfig
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid interval", func(t *testing.T) {
+		cfg := WatchdogConfig{Enable: true}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid IntervalSeconds value: should be a positive number", err.Error())
+	})
+
+	t.Run("negative threshold", func(t *testing.T) {
+		cfg := WatchdogConfig{Enable: true, IntervalSeconds: 10, MaxHeapMB: -1}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid thresholds: should not be negative", err.Error())
+	})
+}
+
+func TestWatchdog(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer log.Shutdown()
+
+	profileDir := t.TempDir()
+	m := NewMetrics("rtcd", prometheus.NewRegistry())
+	w, err := m.NewWatchdog("rtcd", WatchdogConfig{
+		Enable:          true,
+		IntervalSeconds: 1,
+		MaxGoroutines:   1,
+		MaxHeapMB:       0,
+		MaxChannelDepth: 1,
+		HeapProfileDir:  profileDir,
+	}, log)
+	require.NoError(t, err)
+
+	w.AddChannels("rtc", func() map[string]int {
+		return map[string]int{"send": 4}
+	})
+
+	t.Run("check", func(t *testing.T) {
+		w.check(time.Now())
+		require.Positive(t, testutil.ToFloat64(w.goroutines.(promGauge)))
+		require.Positive(t, testutil.ToFloat64(w.heapBytes.(promGauge)))
+		require.Equal(t, float64(4), testutil.ToFloat64(w.channelDepth.(promGauge).WithLabelValues("rtc_send")))
+
+		// No profile should be written as the heap check is disabled.
+		entries, err := os.ReadDir(profileDir)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+
+	t.Run("heap profile", func(t *testing.T) {
+		path, err := writeHeapProfile(profileDir, time.Now())
+		require.NoError(t, err)
+		require.Equal(t, profileDir, filepath.Dir(path))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Positive(t, info.Size())
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		w.Start()
+		w.Stop()
+		// Stopping twice should be a no-op.
+		w.Stop()
+	})
+
+	t.Run("stop without start", func(t *testing.T) {
+		w, err := NewMetrics("rtcd", prometheus.NewRegistry()).NewWatchdog("rtcd", WatchdogConfig{}, log)
+		require.NoError(t, err)
+		w.Stop()
+	})
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+
+	"github.com/mattermost/rtcd/service/rtc"
+)
+
+// Deployment profiles pre-tune the settings that depend on the expected load
+// so that operators get sane scaling defaults without WebRTC expertise.
+const (
+	// ProfileSmall fits a handful of calls on a small instance.
+	ProfileSmall = "small"
+	// ProfileLarge fits many concurrent calls on a dedicated, multi-core
+	// instance.
+	ProfileLarge = "large"
+	// ProfileBroadcast fits calls with few presenters and a large audience,
+	// where egress traffic dominates.
+	ProfileBroadcast = "broadcast"
+)
+
+// profileMinUDPSockets returns the given minimum number of UDP sockets,
+// capped to the number of available CPUs, their default maximum.
+func profileMinUDPSockets(count int) int {
+	if n := runtime.NumCPU(); count > n {
+		return n
+	}
+	return count
+}
+
+// profiles maps each deployment profile to the function tuning a default
+// config for it.
+var profiles = map[string]func(c *Config){
+	ProfileSmall: func(c *Config) {
+		c.RTC.UDPSockets.EnableScaling = false
+		c.RTC.UDPSockets.MaxCount = 1
+		c.RTC.UDPSockets.ReadBufferSize = 1024 * 1024 * 4
+		c.RTC.UDPSockets.WriteBufferSize = 1024 * 1024 * 4
+		c.RTC.IdleCallTimeoutMinutes = 5
+		c.Store.CallEventsMax = 200
+		c.Process.OpenFilesLimit = 8192
+	},
+	ProfileLarge: func(c *Config) {
+		c.RTC.UDPSockets.EnableScaling = true
+		c.RTC.UDPSockets.MinCount = profileMinUDPSockets(2)
+		c.RTC.UDPSockets.ReadBufferSize = 1024 * 1024 * 32
+		c.RTC.UDPSockets.WriteBufferSize = 1024 * 1024 * 32
+		c.RTC.ReceiverReportAggregation = rtc.ReceiverReportAggregationMedian
+		c.RTC.ICECandidates.BatchIntervalMs = 50
+		c.Metrics.AggregateCallsThreshold = 200
+		c.Process.OpenFilesLimit = 262144
+	},
+	ProfileBroadcast: func(c *Config) {
+		c.RTC.UDPSockets.EnableScaling = true
+		c.RTC.UDPSockets.MinCount = profileMinUDPSockets(2)
+		c.RTC.UDPSockets.ReadBufferSize = 1024 * 1024 * 16
+		c.RTC.UDPSockets.WriteBufferSize = 1024 * 1024 * 64
+		c.RTC.UDPSockets.WriteMode = rtc.UDPWriteModePinned
+		c.RTC.ReceiverReportAggregation = rtc.ReceiverReportAggregationWorst
+		c.RTC.RTX.Enable = true
+		c.RTC.JitterBuffer.VideoReorderWindowMs = 100
+		c.RTC.IdleCallTimeoutMinutes = 30
+		c.Process.OpenFilesLimit = 262144
+	},
+}
+
+// Profiles returns the names of the available deployment profiles.
+func Profiles() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile tunes the settings of the given deployment profile. It's meant
+// to be applied on top of the defaults (see SetDefaults) and before any
+// explicit setting, so that the latter take precedence. An empty profile
+// leaves the config untouched.
+func (c *Config) ApplyProfile(profile string) error {
+	if profile == "" {
+		return nil
+	}
+	apply, ok := profiles[profile]
+	if !ok {
+		return fmt.Errorf("invalid Profile value: %q is not one of %v", profile, Profiles())
+	}
+	apply(c)
+	c.Profile = profile
+	return nil
+}
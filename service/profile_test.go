@@ -0,0 +1,41 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyProfile(t *testing.T) {
+	var defaultCfg Config
+	defaultCfg.SetDefaults()
+
+	t.Run("none", func(t *testing.T) {
+		cfg := defaultCfg
+		require.NoError(t, cfg.ApplyProfile(""))
+		require.Equal(t, defaultCfg, cfg)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		cfg := defaultCfg
+		err := cfg.ApplyProfile("huge")
+		require.EqualError(t, err, `invalid Profile value: "huge" is not one of [broadcast large small]`)
+		require.Equal(t, defaultCfg, cfg)
+
+		cfg.Profile = "huge"
+		require.EqualError(t, cfg.IsValid(), `invalid Profile value: "huge" is not one of [broadcast large small]`)
+	})
+
+	for _, profile := range Profiles() {
+		t.Run(profile, func(t *testing.T) {
+			cfg := defaultCfg
+			require.NoError(t, cfg.ApplyProfile(profile))
+			require.Equal(t, profile, cfg.Profile)
+			require.NotEqual(t, defaultCfg.RTC, cfg.RTC)
+			require.NoError(t, cfg.IsValid())
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/rtcd/service/signaling"
+)
+
+// ProtocolVersion is the version of the signaling protocol spoken by this
+// server. Version 1 is the implicit version of clients that don't take part
+// in the capability exchange.
+const ProtocolVersion = 2
+
+const (
+	CapabilityCodecOpus = "codec_opus"
+	CapabilityCodecVP8  = "codec_vp8"
+	CapabilitySimulcast = "simulcast"
+	CapabilityE2EE      = "e2ee"
+	CapabilityDCRelay   = "dc_relay"
+	CapabilityCaptions  = "captions"
+	CapabilityReplay    = "replay"
+	CapabilityResync    = "resync"
+	CapabilityEvents    = "events"
+	CapabilityShutdown  = "shutdown"
+	CapabilityErrors    = "errors"
+	// CapabilityMaintenance is for clients to be notified of the upcoming
+	// maintenance windows, both on the connection and in the calls.
+	CapabilityMaintenance = "maintenance"
+	// CapabilityP2P is for clients able to negotiate 1:1 calls peer-to-peer,
+	// with the server only relaying the signaling.
+	CapabilityP2P = "p2p"
+)
+
+// serverCapabilities lists the features this server supports.
+var serverCapabilities = []string{
+	CapabilityCodecOpus,
+	CapabilityCodecVP8,
+	CapabilityCaptions,
+	CapabilityReplay,
+	CapabilityResync,
+	CapabilityEvents,
+	CapabilityShutdown,
+	CapabilityErrors,
+	CapabilityMaintenance,
+	CapabilityP2P,
+}
+
+// legacyCapabilities is what is assumed for clients speaking version 1 of
+// the protocol.
+var legacyCapabilities = []string{
+	CapabilityCodecOpus,
+	CapabilityCodecVP8,
+}
+
+// protocolInfo holds the result of the negotiation with a connected client.
+type protocolInfo struct {
+	version      int
+	capabilities map[string]bool
+	// clientID is the ID of the client owning the connection.
+	clientID string
+}
+
+func (p protocolInfo) hasCapability(capability string) bool {
+	return p.capabilities[capability]
+}
+
+func newProtocolInfo(version int, capabilities []string) protocolInfo {
+	info := protocolInfo{
+		version:      version,
+		capabilities: make(map[string]bool, len(capabilities)),
+	}
+	for _, c := range capabilities {
+		info.capabilities[c] = true
+	}
+	return info
+}
+
+func legacyProtocolInfo() protocolInfo {
+	return newProtocolInfo(1, legacyCapabilities)
+}
+
+func formatCapabilities(capabilities []string) string {
+	return strings.Join(capabilities, ",")
+}
+
+func parseCapabilities(s string) []string {
+	var capabilities []string
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			capabilities = append(capabilities, c)
+		}
+	}
+	return capabilities
+}
+
+func parseProtocolVersion(s string) (int, error) {
+	version, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse protocol version: %w", err)
+	}
+	if version < 1 {
+		return 0, fmt.Errorf("invalid protocol version %d", version)
+	}
+	return version, nil
+}
+
+// negotiateProtocol returns the protocol version and the set of capabilities
+// supported by both peers.
+func negotiateProtocol(localVersion int, localCaps []string, remoteVersion int, remoteCaps []string) (int, []string) {
+	version := localVersion
+	if remoteVersion < version {
+		version = remoteVersion
+	}
+
+	remote := make(map[string]bool, len(remoteCaps))
+	for _, c := range remoteCaps {
+		remote[c] = true
+	}
+
+	var caps []string
+	for _, c := range localCaps {
+		if remote[c] {
+			caps = append(caps, c)
+		}
+	}
+	sort.Strings(caps)
+
+	return version, caps
+}
+
+// newHelloData returns the data sent along with the hello message,
+// advertising the protocol version and capabilities of the sender.
+func newHelloData(version int, capabilities []string) signaling.HelloData {
+	return signaling.HelloData{
+		ProtocolVersion: strconv.Itoa(version),
+		Capabilities:    formatCapabilities(capabilities),
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProtocolVersion(t *testing.T) {
+	_, err := parseProtocolVersion("")
+	require.Error(t, err)
+
+	_, err = parseProtocolVersion("0")
+	require.Error(t, err)
+	require.Equal(t, "invalid protocol version 0", err.Error())
+
+	version, err := parseProtocolVersion("2")
+	require.NoError(t, err)
+	require.Equal(t, 2, version)
+}
+
+func TestParseCapabilities(t *testing.T) {
+	require.Empty(t, parseCapabilities(""))
+	require.Equal(t, []string{"a", "b"}, parseCapabilities(" a, ,b,"))
+	require.Equal(t, "a,b", formatCapabilities(parseCapabilities("a,b")))
+}
+
+func TestNegotiateProtocol(t *testing.T) {
+	t.Run("older remote", func(t *testing.T) {
+		version, caps := negotiateProtocol(3, []string{"b", "a", "c"}, 2, []string{"c", "a", "d"})
+		require.Equal(t, 2, version)
+		require.Equal(t, []string{"a", "c"}, caps)
+	})
+
+	t.Run("newer remote", func(t *testing.T) {
+		version, caps := negotiateProtocol(2, []string{"a"}, 5, nil)
+		require.Equal(t, 2, version)
+		require.Empty(t, caps)
+	})
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+type readyzResponse struct {
+	Ready  bool                    `json:"ready"`
+	Checks []rtc.ConnectivityCheck `json:"checks,omitempty"`
+	// Draining is set once the node stopped accepting new sessions.
+	Draining bool `json:"draining,omitempty"`
+}
+
+// handleReadyz reports whether the node is ready to serve media. If
+// connectivity checks are enabled, the node is ready only once they all
+// succeeded. A draining node is never ready.
+func (s *Service) handleReadyz(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.NotFound(w, req)
+		return
+	}
+
+	res := readyzResponse{Ready: true}
+	if s.cfg.RTC.ConnectivityCheck.Enable {
+		res.Checks = s.rtcServer.GetConnectivityChecks()
+		// No round of checks has completed yet.
+		if res.Checks == nil {
+			res.Ready = false
+		}
+		for _, c := range res.Checks {
+			if !c.OK {
+				res.Ready = false
+			}
+		}
+	}
+
+	if s.rtcServer.IsDraining() {
+		res.Ready = false
+		res.Draining = true
+	}
+
+	code := http.StatusOK
+	if !res.Ready {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		s.log.Error("failed to encode data", mlog.Err(err))
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadyz(t *testing.T) {
+	getReadyz := func(t *testing.T, apiURL string) (int, readyzResponse) {
+		t.Helper()
+		resp, err := http.Get(apiURL + "/readyz")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var res readyzResponse
+		err = json.NewDecoder(resp.Body).Decode(&res)
+		require.NoError(t, err)
+		return resp.StatusCode, res
+	}
+
+	t.Run("checks disabled", func(t *testing.T) {
+		th := SetupTestHelper(t, nil)
+		defer th.Teardown()
+
+		code, res := getReadyz(t, th.apiURL)
+		require.Equal(t, http.StatusOK, code)
+		require.True(t, res.Ready)
+		require.Empty(t, res.Checks)
+	})
+
+	t.Run("checks failing", func(t *testing.T) {
+		cfg := MakeDefaultCfg(t)
+		cfg.RTC.ICEHostOverride = "127.0.0.1"
+		// Nothing is expected to be listening on this port.
+		cfg.RTC.ICEServers = []rtc.ICEServerConfig{{URLs: []string{"stun:127.0.0.1:1"}}}
+		cfg.RTC.ConnectivityCheck = rtc.ConnectivityCheckConfig{
+			Enable:          true,
+			IntervalSeconds: 60,
+			TimeoutSeconds:  1,
+		}
+		th := SetupTestHelper(t, cfg)
+		defer th.Teardown()
+
+		require.Eventually(t, func() bool {
+			return th.srvc.rtcServer.GetConnectivityChecks() != nil
+		}, 5*time.Second, 50*time.Millisecond)
+
+		code, res := getReadyz(t, th.apiURL)
+		require.Equal(t, http.StatusServiceUnavailable, code)
+		require.False(t, res.Ready)
+		require.Len(t, res.Checks, 1)
+		require.Equal(t, rtc.ConnectivityCheckSTUN, res.Checks[0].Type)
+		require.False(t, res.Checks[0].OK)
+	})
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"sync"
+
+	"github.com/mattermost/rtcd/service/rtc"
+)
+
+const replayBufferSize = 256
+
+// replayBuffer keeps the signaling messages sent to a session that haven't
+// been acknowledged yet so that they can be retransmitted after a reconnect.
+type replayBuffer struct {
+	seq  uint64
+	msgs []rtc.Message
+	mut  sync.Mutex
+}
+
+func newReplayBuffer() *replayBuffer {
+	return &replayBuffer{
+		msgs: make([]rtc.Message, 0, replayBufferSize),
+	}
+}
+
+// push assigns the next sequence number to msg and stores it. The oldest
+// message is evicted if the buffer is full.
+func (b *replayBuffer) push(msg rtc.Message) rtc.Message {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	b.seq++
+	msg.Seq = b.seq
+
+	if len(b.msgs) == replayBufferSize {
+		copy(b.msgs, b.msgs[1:])
+		b.msgs = b.msgs[:len(b.msgs)-1]
+	}
+	b.msgs = append(b.msgs, msg)
+
+	return msg
+}
+
+// ack drops all the messages with a sequence number lower or equal to seq.
+func (b *replayBuffer) ack(seq uint64) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	var i int
+	for i < len(b.msgs) && b.msgs[i].Seq <= seq {
+		i++
+	}
+	b.msgs = append(b.msgs[:0], b.msgs[i:]...)
+}
+
+// since returns a copy of the buffered messages with a sequence number
+// greater than seq.
+func (b *replayBuffer) since(seq uint64) []rtc.Message {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	var msgs []rtc.Message
+	for _, msg := range b.msgs {
+		if msg.Seq > seq {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayBuffer(t *testing.T) {
+	t.Run("push", func(t *testing.T) {
+		b := newReplayBuffer()
+		for i := 1; i <= 3; i++ {
+			msg := b.push(rtc.Message{SessionID: "sessionID"})
+			require.Equal(t, uint64(i), msg.Seq)
+		}
+		require.Len(t, b.since(0), 3)
+		require.Len(t, b.since(2), 1)
+		require.Empty(t, b.since(3))
+	})
+
+	t.Run("ack", func(t *testing.T) {
+		b := newReplayBuffer()
+		for i := 0; i < 5; i++ {
+			b.push(rtc.Message{SessionID: "sessionID"})
+		}
+		b.ack(3)
+		msgs := b.since(0)
+		require.Len(t, msgs, 2)
+		require.Equal(t, uint64(4), msgs[0].Seq)
+		require.Equal(t, uint64(5), msgs[1].Seq)
+
+		b.ack(10)
+		require.Empty(t, b.since(0))
+		require.Equal(t, uint64(6), b.push(rtc.Message{}).Seq)
+	})
+
+	t.Run("full", func(t *testing.T) {
+		b := newReplayBuffer()
+		for i := 0; i < replayBufferSize+10; i++ {
+			b.push(rtc.Message{SessionID: "sessionID"})
+		}
+		msgs := b.since(0)
+		require.Len(t, msgs, replayBufferSize)
+		require.Equal(t, uint64(11), msgs[0].Seq)
+	})
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rpc
+
+import (
+	"fmt"
+
+	"github.com/mattermost/rtcd/service/api"
+)
+
+type Config struct {
+	// Enable controls whether the gRPC API should be served.
+	Enable bool `toml:"enable"`
+	// ListenAddress is the address and port the gRPC server listens on.
+	ListenAddress string `toml:"listen_address"`
+	TLS           api.TLSConfig
+}
+
+func (c Config) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.ListenAddress == "" {
+		return fmt.Errorf("invalid ListenAddress value: should not be empty")
+	}
+	if c.TLS.ACME.Enable {
+		return fmt.Errorf("invalid TLS config: ACME is not supported")
+	}
+	if err := c.TLS.IsValid(); err != nil {
+		return fmt.Errorf("invalid TLS config: %w", err)
+	}
+	return nil
+}
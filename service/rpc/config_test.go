@@ -0,0 +1,56 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rpc
+
+import (
+	"testing"
+
+	"github.com/mattermost/rtcd/service/api"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigIsValid(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		var cfg Config
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid ListenAddress", func(t *testing.T) {
+		cfg := Config{Enable: true}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid ListenAddress value: should not be empty", err.Error())
+	})
+
+	t.Run("invalid TLS", func(t *testing.T) {
+		cfg := Config{
+			Enable:        true,
+			ListenAddress: ":8046",
+			TLS:           api.TLSConfig{Enable: true},
+		}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TLS config: invalid CertFile value: should not be empty", err.Error())
+	})
+
+	t.Run("ACME", func(t *testing.T) {
+		cfg := Config{
+			Enable:        true,
+			ListenAddress: ":8046",
+			TLS:           api.TLSConfig{Enable: true, ACME: api.ACMEConfig{Enable: true}},
+		}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TLS config: ACME is not supported", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := Config{
+			Enable:        true,
+			ListenAddress: ":8046",
+		}
+		require.NoError(t, cfg.IsValid())
+	})
+}
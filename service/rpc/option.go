@@ -0,0 +1,32 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rpc
+
+import (
+	"fmt"
+	"time"
+)
+
+type ServerOption func(s *Server) error
+
+// WithFIPSMode restricts the TLS configuration of the server to the
+// FIPS-approved cipher suites and curves.
+func WithFIPSMode() ServerOption {
+	return func(s *Server) error {
+		s.fipsMode = true
+		return nil
+	}
+}
+
+// WithStopTimeout sets the time given to the ongoing calls to end when the
+// server is stopped, before they get cancelled.
+func WithStopTimeout(timeout time.Duration) ServerOption {
+	return func(s *Server) error {
+		if timeout <= 0 {
+			return fmt.Errorf("invalid stop timeout %s: should be positive", timeout)
+		}
+		s.stopTimeout = timeout
+		return nil
+	}
+}
@@ -0,0 +1,246 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: rtcd.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// RTCDClient is the client API for RTCD service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RTCDClient interface {
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	Unregister(ctx context.Context, in *UnregisterRequest, opts ...grpc.CallOption) (*UnregisterResponse, error)
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+	Signal(ctx context.Context, opts ...grpc.CallOption) (RTCD_SignalClient, error)
+}
+
+type rTCDClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRTCDClient(cc grpc.ClientConnInterface) RTCDClient {
+	return &rTCDClient{cc}
+}
+
+func (c *rTCDClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, "/rtcd.RTCD/Register", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rTCDClient) Unregister(ctx context.Context, in *UnregisterRequest, opts ...grpc.CallOption) (*UnregisterResponse, error) {
+	out := new(UnregisterResponse)
+	err := c.cc.Invoke(ctx, "/rtcd.RTCD/Unregister", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rTCDClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error) {
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, "/rtcd.RTCD/Login", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rTCDClient) Signal(ctx context.Context, opts ...grpc.CallOption) (RTCD_SignalClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RTCD_ServiceDesc.Streams[0], "/rtcd.RTCD/Signal", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rTCDSignalClient{stream}
+	return x, nil
+}
+
+type RTCD_SignalClient interface {
+	Send(*ClientMessage) error
+	Recv() (*ClientMessage, error)
+	grpc.ClientStream
+}
+
+type rTCDSignalClient struct {
+	grpc.ClientStream
+}
+
+func (x *rTCDSignalClient) Send(m *ClientMessage) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *rTCDSignalClient) Recv() (*ClientMessage, error) {
+	m := new(ClientMessage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RTCDServer is the server API for RTCD service.
+// All implementations must embed UnimplementedRTCDServer
+// for forward compatibility
+type RTCDServer interface {
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	Unregister(context.Context, *UnregisterRequest) (*UnregisterResponse, error)
+	Login(context.Context, *LoginRequest) (*LoginResponse, error)
+	Signal(RTCD_SignalServer) error
+	mustEmbedUnimplementedRTCDServer()
+}
+
+// UnimplementedRTCDServer must be embedded to have forward compatible implementations.
+type UnimplementedRTCDServer struct {
+}
+
+func (UnimplementedRTCDServer) Register(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedRTCDServer) Unregister(context.Context, *UnregisterRequest) (*UnregisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unregister not implemented")
+}
+func (UnimplementedRTCDServer) Login(context.Context, *LoginRequest) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedRTCDServer) Signal(RTCD_SignalServer) error {
+	return status.Errorf(codes.Unimplemented, "method Signal not implemented")
+}
+func (UnimplementedRTCDServer) mustEmbedUnimplementedRTCDServer() {}
+
+// UnsafeRTCDServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RTCDServer will
+// result in compilation errors.
+type UnsafeRTCDServer interface {
+	mustEmbedUnimplementedRTCDServer()
+}
+
+func RegisterRTCDServer(s grpc.ServiceRegistrar, srv RTCDServer) {
+	s.RegisterService(&RTCD_ServiceDesc, srv)
+}
+
+func _RTCD_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RTCDServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rtcd.RTCD/Register",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RTCDServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RTCD_Unregister_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnregisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RTCDServer).Unregister(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rtcd.RTCD/Unregister",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RTCDServer).Unregister(ctx, req.(*UnregisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RTCD_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RTCDServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rtcd.RTCD/Login",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RTCDServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RTCD_Signal_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RTCDServer).Signal(&rTCDSignalServer{stream})
+}
+
+type RTCD_SignalServer interface {
+	Send(*ClientMessage) error
+	Recv() (*ClientMessage, error)
+	grpc.ServerStream
+}
+
+type rTCDSignalServer struct {
+	grpc.ServerStream
+}
+
+func (x *rTCDSignalServer) Send(m *ClientMessage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *rTCDSignalServer) Recv() (*ClientMessage, error) {
+	m := new(ClientMessage)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RTCD_ServiceDesc is the grpc.ServiceDesc for RTCD service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RTCD_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rtcd.RTCD",
+	HandlerType: (*RTCDServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _RTCD_Register_Handler,
+		},
+		{
+			MethodName: "Unregister",
+			Handler:    _RTCD_Unregister_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _RTCD_Login_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Signal",
+			Handler:       _RTCD_Signal_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "rtcd.proto",
+}
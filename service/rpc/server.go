@@ -0,0 +1,141 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative rtcd.proto
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/mattermost/rtcd/service/fips"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// defaultStopTimeout is the time given to the ongoing calls to end on Stop
+// before they get cancelled.
+const defaultStopTimeout = 5 * time.Second
+
+type Server struct {
+	cfg      Config
+	log      mlog.LoggerIFace
+	listener net.Listener
+	srv      *grpc.Server
+	// fipsMode restricts the TLS configuration to FIPS-approved settings.
+	fipsMode bool
+	// stopTimeout bounds the graceful stop of the server.
+	stopTimeout time.Duration
+}
+
+func NewServer(cfg Config, log mlog.LoggerIFace, impl RTCDServer, opts ...ServerOption) (*Server, error) {
+	if err := cfg.IsValid(); err != nil {
+		return nil, err
+	}
+	if log == nil {
+		return nil, fmt.Errorf("log should not be nil")
+	}
+	if impl == nil {
+		return nil, fmt.Errorf("impl should not be nil")
+	}
+
+	s := &Server{
+		cfg:         cfg,
+		log:         log,
+		stopTimeout: defaultStopTimeout,
+	}
+
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, fmt.Errorf("failed to apply option: %w", err)
+		}
+	}
+
+	var srvOpts []grpc.ServerOption
+	if cfg.TLS.Enable {
+		creds, err := s.newTLSCredentials()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS credentials: %w", err)
+		}
+		srvOpts = append(srvOpts, grpc.Creds(creds))
+	}
+
+	s.srv = grpc.NewServer(srvOpts...)
+	RegisterRTCDServer(s.srv, impl)
+
+	return s, nil
+}
+
+func (s *Server) newTLSCredentials() (credentials.TransportCredentials, error) {
+	if !s.fipsMode {
+		return credentials.NewServerTLSFromFile(s.cfg.TLS.CertFile, s.cfg.TLS.CertKey)
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.cfg.TLS.CertFile, s.cfg.TLS.CertKey)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+	fips.RestrictTLSConfig(tlsConfig)
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+func (s *Server) Start() error {
+	var err error
+	s.listener, err = net.Listen("tcp", s.cfg.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	s.log.Info("rpc: server is listening on " + s.listener.Addr().String())
+
+	go func() {
+		if err := s.srv.Serve(s.listener); err != nil && err != grpc.ErrServerStopped {
+			s.log.Critical("error starting gRPC server", mlog.Err(err))
+		}
+	}()
+
+	return nil
+}
+
+// Addr returns the address the server is listening on. It is only valid
+// after a successful call to Start.
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Stop stops the server, waiting for the ongoing calls to end. The
+// signaling streams lasting as long as their clients, the calls still
+// running after the stop timeout get cancelled.
+func (s *Server) Stop() error {
+	doneCh := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(doneCh)
+	}()
+
+	timer := time.NewTimer(s.stopTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-doneCh:
+	case <-timer.C:
+		s.log.Warn("rpc: cancelling the calls still running", mlog.Duration("timeout", s.stopTimeout))
+		// Closing the connections makes GracefulStop return as well.
+		s.srv.Stop()
+		<-doneCh
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// streamingServer holds the signaling streams open until they get
+// cancelled.
+type streamingServer struct {
+	UnimplementedRTCDServer
+	openCh chan struct{}
+}
+
+func (s *streamingServer) Signal(stream RTCD_SignalServer) error {
+	close(s.openCh)
+	<-stream.Context().Done()
+	return stream.Context().Err()
+}
+
+func TestServerStop(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, log.Shutdown())
+	}()
+
+	t.Run("invalid stop timeout", func(t *testing.T) {
+		_, err := NewServer(Config{Enable: true, ListenAddress: ":0"}, log, &streamingServer{}, WithStopTimeout(0))
+		require.EqualError(t, err, "failed to apply option: invalid stop timeout 0s: should be positive")
+	})
+
+	t.Run("open stream", func(t *testing.T) {
+		impl := &streamingServer{openCh: make(chan struct{})}
+		s, err := NewServer(Config{Enable: true, ListenAddress: ":0"}, log, impl, WithStopTimeout(100*time.Millisecond))
+		require.NoError(t, err)
+		require.NoError(t, s.Start())
+
+		conn, err := grpc.Dial(s.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		stream, err := NewRTCDClient(conn).Signal(context.Background())
+		require.NoError(t, err)
+		select {
+		case <-impl.openCh:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for stream")
+		}
+
+		start := time.Now()
+		require.NoError(t, s.Stop())
+		require.Less(t, time.Since(start), 5*time.Second)
+
+		_, err = stream.Recv()
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,372 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media/oggreader"
+)
+
+// localeRE matches the locales announcements can be translated to, e.g.
+// "fr" or "pt-BR".
+var localeRE = regexp.MustCompile(`^[a-zA-Z]{2,8}([_-][a-zA-Z0-9]{1,8}){0,3}$`)
+
+const (
+	// announcementSetupTimeout bounds the time waited for a session to
+	// negotiate its announcement track before the first announcement is
+	// played anyway.
+	announcementSetupTimeout  = 5 * time.Second
+	announcementSetupInterval = 50 * time.Millisecond
+	// opusClockRate is the rate of both the Ogg/Opus granule positions and
+	// the RTP timestamps of Opus streams.
+	opusClockRate = 48000
+)
+
+// AnnouncementInfo describes an announcement queued to the sessions of a
+// call.
+type AnnouncementInfo struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	GroupID string `json:"group_id"`
+	CallID  string `json:"call_id"`
+	// Sessions maps the IDs of the sessions the announcement is queued to,
+	// to the locale it's played in. The locale is empty for the
+	// untranslated file.
+	Sessions map[string]string `json:"sessions"`
+}
+
+// announcement is a file queued to be played to a session.
+type announcement struct {
+	id   string
+	path string
+}
+
+// announcer plays the announcements queued to a session on a dedicated,
+// server owned, audio track. Announcements are played one after the other
+// so that they never overlap, the client mixing the track with the voice
+// tracks of the other participants: mixing them on the server would take
+// decoding and encoding again the Opus streams it otherwise only forwards.
+// Adding the track renegotiates the session, which the client must accept.
+// It stops once closed or once the session is.
+type announcer struct {
+	us      *session
+	track   *webrtc.TrackLocalStaticRTP
+	log     mlog.LoggerIFace
+	queueCh chan announcement
+	closeCh chan struct{}
+	doneCh  chan struct{}
+
+	seq uint16
+	ts  uint32
+	// lastSentAt is the time the last packet was sent at, so that the
+	// timestamps of the next announcement account for the silence.
+	lastSentAt time.Time
+}
+
+func newAnnouncer(us *session, queueSize int, log mlog.LoggerIFace) (*announcer, error) {
+	track, err := webrtc.NewTrackLocalStaticRTP(rtpAudioCodec, genTrackID("announcement", us.cfg.SessionID), random.NewID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create announcement track: %w", err)
+	}
+
+	return &announcer{
+		us:      us,
+		track:   track,
+		log:     log,
+		queueCh: make(chan announcement, queueSize),
+		closeCh: make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}, nil
+}
+
+// enqueue queues the announcement, returning false if the queue is full.
+func (a *announcer) enqueue(an announcement) bool {
+	select {
+	case a.queueCh <- an:
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *announcer) start() {
+	defer close(a.doneCh)
+
+	if !a.waitNegotiated() {
+		a.log.Warn("timed out waiting for the announcement track to be negotiated",
+			mlog.String("groupID", a.us.cfg.GroupID), mlog.String("sessionID", a.us.cfg.SessionID),
+			mlog.String("traceID", a.us.cfg.TraceID))
+	}
+
+	for {
+		select {
+		case an := <-a.queueCh:
+			if err := a.play(an); err != nil {
+				a.log.Error("failed to play announcement", mlog.Err(err),
+					mlog.String("groupID", a.us.cfg.GroupID), mlog.String("sessionID", a.us.cfg.SessionID),
+					mlog.String("traceID", a.us.cfg.TraceID), mlog.String("announcementID", an.id))
+			}
+		case <-a.closeCh:
+			return
+		case <-a.us.closeCh:
+			return
+		}
+	}
+}
+
+// waitNegotiated waits for the announcement track to be added to the peer
+// connection and for the resulting negotiation to complete, since packets
+// written before are dropped.
+func (a *announcer) waitNegotiated() bool {
+	timeout := time.After(announcementSetupTimeout)
+	ticker := time.NewTicker(announcementSetupInterval)
+	defer ticker.Stop()
+	for {
+		a.us.mut.RLock()
+		_, added := a.us.senders[a.track.ID()]
+		makingOffer := a.us.makingOffer
+		a.us.mut.RUnlock()
+		if added && !makingOffer && a.us.rtcConn.SignalingState() == webrtc.SignalingStateStable {
+			return true
+		}
+
+		select {
+		case <-ticker.C:
+		case <-timeout:
+			return false
+		case <-a.closeCh:
+			return false
+		case <-a.us.closeCh:
+			return false
+		}
+	}
+}
+
+// play sends the pages of the Ogg/Opus file of the announcement as RTP
+// packets, paced according to their granule positions.
+func (a *announcer) play(an announcement) error {
+	f, err := os.Open(an.path)
+	if err != nil {
+		return fmt.Errorf("failed to open announcement: %w", err)
+	}
+	defer f.Close()
+
+	reader, _, err := oggreader.NewWith(f)
+	if err != nil {
+		return fmt.Errorf("failed to read announcement: %w", err)
+	}
+
+	if !a.lastSentAt.IsZero() {
+		a.ts += uint32(time.Since(a.lastSentAt) * opusClockRate / time.Second)
+	}
+
+	start := time.Now()
+	var elapsed time.Duration
+	var lastGranule uint64
+	marker := true
+	for {
+		page, pageHeader, err := reader.ParseNextPage()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to parse page: %w", err)
+		}
+
+		// The header pages carry no audio.
+		if pageHeader.GranulePosition <= lastGranule {
+			continue
+		}
+		samples := pageHeader.GranulePosition - lastGranule
+		lastGranule = pageHeader.GranulePosition
+
+		pkt := &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Marker:         marker,
+				SequenceNumber: a.seq,
+				Timestamp:      a.ts,
+			},
+			Payload: page,
+		}
+		if err := a.track.WriteRTP(pkt); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			return fmt.Errorf("failed to write packet: %w", err)
+		}
+		marker = false
+		a.seq++
+		a.ts += uint32(samples)
+		a.lastSentAt = time.Now()
+
+		elapsed += time.Duration(samples*uint64(time.Second)) / opusClockRate
+		select {
+		case <-time.After(time.Until(start.Add(elapsed))):
+		case <-a.closeCh:
+			return nil
+		case <-a.us.closeCh:
+			return nil
+		}
+	}
+}
+
+func (a *announcer) close() {
+	close(a.closeCh)
+	<-a.doneCh
+}
+
+// resolveAnnouncement returns the path of the translation of the named
+// announcement best matching locale, along with the locale it's in. It falls
+// back from the regional locale (e.g. "pt-BR") to its language ("pt"), to
+// the default locale and finally to the untranslated file at the root of the
+// directory.
+func resolveAnnouncement(cfg AnnouncementsConfig, name, locale string) (string, string, error) {
+	var locales []string
+	if locale != "" {
+		locales = append(locales, locale)
+		if i := strings.IndexAny(locale, "-_"); i > 0 {
+			locales = append(locales, locale[:i])
+		}
+	}
+	if cfg.DefaultLocale != "" {
+		locales = append(locales, cfg.DefaultLocale)
+	}
+	locales = append(locales, "")
+
+	for _, l := range locales {
+		path := filepath.Join(cfg.Dir, l, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, l, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("announcement not found: %s", name)
+}
+
+func (s *session) getAnnouncer() *announcer {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.announcer
+}
+
+// getAnnouncer returns the announcer of the session, creating it and adding
+// its track to the session on first use.
+func (s *Server) getAnnouncer(us *session) (*announcer, error) {
+	us.mut.Lock()
+	defer us.mut.Unlock()
+	if us.announcer != nil {
+		return us.announcer, nil
+	}
+
+	a, err := newAnnouncer(us, s.cfg.Announcements.MaxQueueSize, s.log)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case us.tracksCh <- a.track:
+	default:
+		return nil, fmt.Errorf("failed to add announcement track: channel is full")
+	}
+	us.announcer = a
+	go a.start()
+
+	return a, nil
+}
+
+// PlayAnnouncement plays the named announcement to the sessions of a call or,
+// if sessionID is set, to that session only. Each session gets the
+// translation matching its locale. Announcements are queued and played one
+// at a time, sessions whose queue is full are skipped.
+func (s *Server) PlayAnnouncement(groupID, callID, sessionID, name string) (AnnouncementInfo, error) {
+	if !s.cfg.Announcements.Enable {
+		return AnnouncementInfo{}, fmt.Errorf("announcements are not enabled")
+	}
+
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return AnnouncementInfo{}, fmt.Errorf("invalid announcement name: %q", name)
+	}
+
+	var sessions []*session
+	if sessionID != "" {
+		call, us, err := s.getCallSession(groupID, sessionID)
+		if err != nil {
+			return AnnouncementInfo{}, err
+		}
+		if callID != "" && callID != call.id {
+			return AnnouncementInfo{}, fmt.Errorf("session not found: %s", sessionID)
+		}
+		callID = call.id
+		sessions = append(sessions, us)
+	} else {
+		group := s.getGroup(groupID)
+		if group == nil {
+			return AnnouncementInfo{}, fmt.Errorf("group not found: %s", groupID)
+		}
+		call := group.getCall(callID)
+		if call == nil {
+			return AnnouncementInfo{}, fmt.Errorf("call not found: %s", callID)
+		}
+		call.iterSessions(func(us *session) {
+			if !us.cfg.Hidden {
+				sessions = append(sessions, us)
+			}
+		})
+	}
+
+	info := AnnouncementInfo{
+		ID:       random.NewID(),
+		Name:     name,
+		GroupID:  groupID,
+		CallID:   callID,
+		Sessions: map[string]string{},
+	}
+
+	// All the translations are resolved first so that a missing file doesn't
+	// leave the announcement played to part of the call.
+	paths := make([]string, len(sessions))
+	locales := make([]string, len(sessions))
+	for i, us := range sessions {
+		var err error
+		paths[i], locales[i], err = resolveAnnouncement(s.cfg.Announcements, name, us.cfg.Locale)
+		if err != nil {
+			return AnnouncementInfo{}, err
+		}
+	}
+
+	for i, us := range sessions {
+		a, err := s.getAnnouncer(us)
+		if err != nil {
+			s.log.Error("failed to get announcer", mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+			continue
+		}
+		if !a.enqueue(announcement{id: info.ID, path: paths[i]}) {
+			s.log.Warn("announcement queue is full", mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+			continue
+		}
+		info.Sessions[us.cfg.SessionID] = locales[i]
+	}
+
+	if len(sessions) > 0 && len(info.Sessions) == 0 {
+		return AnnouncementInfo{}, fmt.Errorf("failed to queue announcement")
+	}
+
+	s.log.Info("announcement queued",
+		mlog.String("groupID", groupID),
+		mlog.String("callID", callID),
+		mlog.String("announcementID", info.ID),
+		mlog.String("name", name),
+		mlog.Int("sessions", len(info.Sessions)))
+
+	return info, nil
+}
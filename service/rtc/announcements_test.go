@@ -0,0 +1,136 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveAnnouncement(t *testing.T) {
+	cfg := AnnouncementsConfig{
+		Enable:        true,
+		Dir:           t.TempDir(),
+		DefaultLocale: "en",
+		MaxQueueSize:  1,
+	}
+	for _, path := range []string{"busy.ogg", "en/busy.ogg", "pt/busy.ogg", "pt-BR/busy.ogg", "fr/busy.ogg/x", "de/other.ogg"} {
+		path = filepath.Join(cfg.Dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, nil, 0600))
+	}
+
+	for _, tc := range []struct {
+		locale   string
+		expected string
+	}{
+		{"pt-BR", "pt-BR"},
+		{"pt_PT", "pt"},
+		{"pt", "pt"},
+		{"de", "en"},
+		// Directories are skipped.
+		{"fr", "en"},
+		{"", "en"},
+	} {
+		path, locale, err := resolveAnnouncement(cfg, "busy.ogg", tc.locale)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, locale)
+		require.Equal(t, filepath.Join(cfg.Dir, tc.expected, "busy.ogg"), path)
+	}
+
+	// Without a default locale the untranslated file is played.
+	cfg.DefaultLocale = ""
+	path, locale, err := resolveAnnouncement(cfg, "busy.ogg", "de")
+	require.NoError(t, err)
+	require.Empty(t, locale)
+	require.Equal(t, filepath.Join(cfg.Dir, "busy.ogg"), path)
+
+	_, _, err = resolveAnnouncement(cfg, "other.ogg", "fr")
+	require.EqualError(t, err, "announcement not found: other.ogg")
+}
+
+func TestPlayAnnouncement(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	_, err := server.PlayAnnouncement("groupID", "callID", "", "busy.ogg")
+	require.EqualError(t, err, "announcements are not enabled")
+
+	server.cfg.Announcements = AnnouncementsConfig{
+		Enable:        true,
+		Dir:           t.TempDir(),
+		DefaultLocale: "en",
+		MaxQueueSize:  1,
+	}
+	for _, path := range []string{"en/busy.ogg", "fr/busy.ogg", "fr/recording.ogg"} {
+		path = filepath.Join(server.cfg.Announcements.Dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, nil, 0600))
+	}
+
+	for _, name := range []string{"", "..", "../busy.ogg", "fr/busy.ogg"} {
+		_, err := server.PlayAnnouncement("groupID", "callID", "", name)
+		require.EqualError(t, err, "invalid announcement name: \""+name+"\"")
+	}
+
+	_, err = server.PlayAnnouncement("groupID", "callID", "", "busy.ogg")
+	require.EqualError(t, err, "group not found: groupID")
+
+	for _, cfg := range []SessionConfig{
+		{SessionID: "sessionA", Locale: "fr-CA"},
+		{SessionID: "sessionB"},
+		{SessionID: "sessionC", Locale: "fr", Hidden: true},
+	} {
+		cfg.GroupID = "groupID"
+		cfg.CallID = "callID"
+		cfg.UserID = cfg.SessionID
+		peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		_, err = server.addSession(cfg, peerConn, nil)
+		require.NoError(t, err)
+	}
+
+	_, err = server.PlayAnnouncement("groupID", "otherCallID", "", "busy.ogg")
+	require.EqualError(t, err, "call not found: otherCallID")
+
+	_, err = server.PlayAnnouncement("groupID", "otherCallID", "sessionA", "busy.ogg")
+	require.EqualError(t, err, "session not found: sessionA")
+
+	// sessionB has no translation of it.
+	_, err = server.PlayAnnouncement("groupID", "callID", "", "recording.ogg")
+	require.EqualError(t, err, "announcement not found: recording.ogg")
+
+	t.Run("call", func(t *testing.T) {
+		info, err := server.PlayAnnouncement("groupID", "callID", "", "busy.ogg")
+		require.NoError(t, err)
+		require.NotEmpty(t, info.ID)
+		require.Equal(t, "busy.ogg", info.Name)
+		require.Equal(t, "callID", info.CallID)
+		// Hidden sessions are left out.
+		require.Equal(t, map[string]string{"sessionA": "fr", "sessionB": "en"}, info.Sessions)
+	})
+
+	t.Run("session", func(t *testing.T) {
+		info, err := server.PlayAnnouncement("groupID", "", "sessionC", "recording.ogg")
+		require.NoError(t, err)
+		require.Equal(t, "callID", info.CallID)
+		require.Equal(t, map[string]string{"sessionC": "fr"}, info.Sessions)
+	})
+
+	t.Run("queue full", func(t *testing.T) {
+		// The announcements already queued are waiting for the tracks to be
+		// negotiated.
+		_, err := server.PlayAnnouncement("groupID", "callID", "", "busy.ogg")
+		require.EqualError(t, err, "failed to queue announcement")
+	})
+
+	// Closing the sessions stops their announcers.
+	for _, sessionID := range []string{"sessionA", "sessionB", "sessionC"} {
+		require.NoError(t, server.CloseSession(sessionID))
+	}
+}
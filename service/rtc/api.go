@@ -0,0 +1,66 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"context"
+	"time"
+)
+
+// The interfaces below are the stable API of the SFU, for programs embedding
+// it without the HTTP/WS service layer. Server implements all of them.
+
+// SignalSender exchanges the signaling messages (SDP, ICE candidates,
+// mute state, etc.) with the clients. Messages received from the clients
+// are passed to Send, messages for the clients are read from ReceiveCh. The
+// transport is up to the embedder.
+type SignalSender interface {
+	Send(msg Message) error
+	ReceiveCh() <-chan Message
+}
+
+// SessionManager handles the lifecycle of the RTC sessions. Calls and
+// groups are implicitly created by their first session and removed along
+// with their last one.
+type SessionManager interface {
+	InitSession(cfg SessionConfig, closeCb func(reason string) error) error
+	CloseSession(sessionID string) error
+	CloseSessionWithReason(sessionID, reason string) error
+	GetCallState(groupID, callID string) (CallState, error)
+	GetCallsStats() []CallStats
+	// EventsCh returns the channel of the session and call events. It's
+	// closed when the server stops.
+	EventsCh() <-chan Event
+}
+
+// TrackRouter controls where the tracks received by the SFU are routed to,
+// besides the other participants of the call.
+type TrackRouter interface {
+	StartTranscription(groupID, callID string, errCb func(err error)) error
+	StopTranscription(groupID, callID string) error
+	StartSessionRecording(groupID, sessionID, target string, duration time.Duration) (RecordingInfo, error)
+	StopSessionRecording(groupID, sessionID string) (RecordingInfo, error)
+	StartHLS(groupID, sessionID string) (HLSStreamInfo, error)
+	StopHLS(groupID, callID string) (HLSStreamInfo, error)
+	GetHLSFile(ctx context.Context, streamID, name string, msn, part int) ([]byte, error)
+	PlayAnnouncement(groupID, callID, sessionID, name string) (AnnouncementInfo, error)
+}
+
+// SFU is the complete embeddable server. The logger and metrics are
+// injected through NewServer.
+type SFU interface {
+	SignalSender
+	SessionManager
+	TrackRouter
+	AddSDPHook(hook SDPHook)
+	GetRuntimeParams() RuntimeParams
+	SetRuntimeParams(params RuntimeParams) error
+	// Start binds the UDP sockets and starts processing messages.
+	Start() error
+	// Stop waits for the ongoing sessions to end and releases all
+	// resources. The server can't be started again.
+	Stop() error
+}
+
+var _ SFU = (*Server)(nil)
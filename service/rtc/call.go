@@ -4,15 +4,58 @@
 package rtc
 
 import (
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/pion/rtcp"
 	"github.com/pion/webrtc/v3"
 )
 
 type call struct {
+	// stats is accessed atomically, keeping it first guarantees 64-bit
+	// alignment.
+	stats callStats
+
 	id            string
 	sessions      map[string]*session
 	screenSession *session
+	transcriber   *transcriber
+	// transcriberStarting is set while the transcriber is connecting to the
+	// transcription service. It's cleared if the transcription is stopped
+	// or the call ends meanwhile.
+	transcriberStarting bool
+	capture             *capture
+	hlsStream           *hlsStream
+	createdAt           time.Time
+	// started is set once the first session got added.
+	started bool
+	// audioOnly is set when the call is created and never changes.
+	audioOnly bool
+	// icePolicy is set when the call is created and never changes.
+	icePolicy ICEPolicy
+	// iface is the network interface the call was placed on, empty if
+	// unrestricted. It's set when the call is created and never changes.
+	iface string
+	// trackReports holds the subscriber reports of forwarded tracks, keyed
+	// by local track ID.
+	trackReports map[string]*trackReports
+	// uplinkLoss holds the loss on the publisher leg of forwarded audio
+	// tracks, keyed by local track ID.
+	uplinkLoss map[string]*uplinkLoss
+	// uplinkDelay holds the delay on the publisher leg of forwarded tracks,
+	// keyed by local track ID.
+	uplinkDelay map[string]*uplinkDelay
+	// frameThrottlers holds the framerate limited forwarders of video
+	// tracks, keyed by local track ID and subscriber session ID.
+	frameThrottlers map[string]map[string]*frameThrottler
+	// keyFrames holds the last key frame of forwarded video tracks, keyed by
+	// local track ID.
+	keyFrames map[string]*keyFrameCache
+	// egress limits the media forwarded to the subscribers. It's nil if
+	// egress shaping is disabled.
+	egress *tokenBucket
 
 	mut sync.RWMutex
 }
@@ -23,11 +66,24 @@ func (c *call) getSession(sessionID string) *session {
 	return c.sessions[sessionID]
 }
 
-func (c *call) addSession(cfg SessionConfig, rtcConn *webrtc.PeerConnection, closeCb func() error) (*session, bool) {
+// addSession adds a session to the call, returning whether the call started
+// with it.
+func (c *call) addSession(cfg SessionConfig, rtcConn *webrtc.PeerConnection, closeCb func(reason string) error, maxParticipants int) (*session, bool, error) {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 	if s := c.sessions[cfg.SessionID]; s != nil {
-		return s, false
+		return nil, false, fmt.Errorf("user session already exists")
+	}
+	if maxParticipants > 0 && !cfg.Hidden {
+		var participants int
+		for _, ss := range c.sessions {
+			if !ss.cfg.Hidden {
+				participants++
+			}
+		}
+		if participants >= maxParticipants {
+			return nil, false, ErrMaxParticipantsReached
+		}
 	}
 
 	s := &session{
@@ -39,10 +95,14 @@ func (c *call) addSession(cfg SessionConfig, rtcConn *webrtc.PeerConnection, clo
 		closeCh:       make(chan struct{}),
 		closeCb:       closeCb,
 		tracksCh:      make(chan *webrtc.TrackLocalStaticRTP, tracksChSize),
+
+		connStateChangedAt: time.Now(),
 	}
 
 	c.sessions[cfg.SessionID] = s
-	return s, true
+	started := !c.started
+	c.started = true
+	return s, started, nil
 }
 
 func (c *call) getScreenSession() *session {
@@ -61,6 +121,31 @@ func (c *call) setScreenSession(s *session) bool {
 	return false
 }
 
+// requestKeyFrame forwards a PLI (Picture Loss Indication) request to the
+// screen sharing session, unless throttled.
+func (c *call) requestKeyFrame(params RuntimeParams) error {
+	screenSession := c.getScreenSession()
+	if screenSession == nil {
+		return errors.New("screenSession should not be nil")
+	}
+
+	screenTrack := screenSession.getRemoteScreenTrack()
+	if screenTrack == nil {
+		return errors.New("screenTrack should not be nil")
+	}
+
+	throttle := time.Duration(params.PLIThrottleMs) * time.Millisecond
+	if !screenSession.shouldForwardPLI(time.Now(), throttle) {
+		return nil
+	}
+
+	if err := screenSession.rtcConn.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: uint32(screenTrack.SSRC())}}); err != nil {
+		return fmt.Errorf("failed to write RTCP packet: %w", err)
+	}
+
+	return nil
+}
+
 func (c *call) iterSessions(cb func(s *session)) {
 	c.mut.RLock()
 	for _, session := range c.sessions {
@@ -70,3 +155,251 @@ func (c *call) iterSessions(cb func(s *session)) {
 	}
 	c.mut.RUnlock()
 }
+
+// countSubscribers returns the number of sessions the tracks of the given
+// user get forwarded to.
+func (c *call) countSubscribers(userID string) int {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	var n int
+	for _, s := range c.sessions {
+		if s.cfg.UserID != userID {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *call) getTranscriber() *transcriber {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.transcriber
+}
+
+// reserveTranscriber marks the transcriber of the call as starting,
+// returning false if the transcription is already started or starting.
+func (c *call) reserveTranscriber() bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.transcriber != nil || c.transcriberStarting {
+		return false
+	}
+	c.transcriberStarting = true
+	return true
+}
+
+// setTranscriber sets the transcriber reserved through reserveTranscriber,
+// returning false if the reservation got canceled meanwhile. A nil t only
+// clears the reservation.
+func (c *call) setTranscriber(t *transcriber) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if !c.transcriberStarting {
+		return false
+	}
+	c.transcriberStarting = false
+	c.transcriber = t
+	return true
+}
+
+// clearTranscriber clears and returns the transcriber of the call, also
+// canceling its start if ongoing, which is reported by the second value.
+func (c *call) clearTranscriber() (*transcriber, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	t, starting := c.transcriber, c.transcriberStarting
+	c.transcriber = nil
+	c.transcriberStarting = false
+	return t, starting
+}
+
+// removeTranscriber clears the transcriber of the call if it's still t,
+// returning whether it was.
+func (c *call) removeTranscriber(t *transcriber) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.transcriber != t {
+		return false
+	}
+	c.transcriber = nil
+	return true
+}
+
+func (c *call) getCapture() *capture {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.capture
+}
+
+func (c *call) setCapture(cpt *capture) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.capture == nil {
+		c.capture = cpt
+		return true
+	}
+	return false
+}
+
+// clearCapture removes the given capture from the call. It returns false if
+// it was already removed.
+func (c *call) clearCapture(cpt *capture) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.capture != cpt {
+		return false
+	}
+	c.capture = nil
+	return true
+}
+
+func (c *call) getHLSStream() *hlsStream {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.hlsStream
+}
+
+func (c *call) setHLSStream(hs *hlsStream) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.hlsStream == nil {
+		c.hlsStream = hs
+		return true
+	}
+	return false
+}
+
+// clearHLSStream removes the given stream from the call. It returns false if
+// it was already removed.
+func (c *call) clearHLSStream(hs *hlsStream) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.hlsStream != hs {
+		return false
+	}
+	c.hlsStream = nil
+	return true
+}
+
+func (c *call) getSessions() []*session {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	sessions := make([]*session, 0, len(c.sessions))
+	for _, s := range c.sessions {
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+func (c *call) getTrackReports(trackID string) *trackReports {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.trackReports[trackID]
+}
+
+func (c *call) addTrackReports(trackID string, tr *trackReports) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.trackReports == nil {
+		c.trackReports = map[string]*trackReports{}
+	}
+	c.trackReports[trackID] = tr
+}
+
+func (c *call) removeTrackReports(trackID string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	delete(c.trackReports, trackID)
+}
+
+func (c *call) getUplinkLoss(trackID string) *uplinkLoss {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.uplinkLoss[trackID]
+}
+
+func (c *call) addUplinkLoss(trackID string, l *uplinkLoss) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.uplinkLoss == nil {
+		c.uplinkLoss = map[string]*uplinkLoss{}
+	}
+	c.uplinkLoss[trackID] = l
+}
+
+func (c *call) removeUplinkLoss(trackID string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	delete(c.uplinkLoss, trackID)
+}
+
+func (c *call) getUplinkDelay(trackID string) *uplinkDelay {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.uplinkDelay[trackID]
+}
+
+func (c *call) addUplinkDelay(trackID string, d *uplinkDelay) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.uplinkDelay == nil {
+		c.uplinkDelay = map[string]*uplinkDelay{}
+	}
+	c.uplinkDelay[trackID] = d
+}
+
+func (c *call) removeUplinkDelay(trackID string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	delete(c.uplinkDelay, trackID)
+}
+
+func (c *call) getKeyFrameCache(trackID string) *keyFrameCache {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.keyFrames[trackID]
+}
+
+func (c *call) addKeyFrameCache(trackID string, kf *keyFrameCache) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.keyFrames == nil {
+		c.keyFrames = map[string]*keyFrameCache{}
+	}
+	c.keyFrames[trackID] = kf
+}
+
+func (c *call) removeKeyFrameCache(trackID string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	delete(c.keyFrames, trackID)
+}
+
+func (c *call) getFrameThrottlers(trackID string) []*frameThrottler {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	if len(c.frameThrottlers[trackID]) == 0 {
+		return nil
+	}
+	throttlers := make([]*frameThrottler, 0, len(c.frameThrottlers[trackID]))
+	for _, t := range c.frameThrottlers[trackID] {
+		throttlers = append(throttlers, t)
+	}
+	return throttlers
+}
+
+func (c *call) setFrameThrottler(trackID, sessionID string, t *frameThrottler) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if t == nil {
+		delete(c.frameThrottlers[trackID], sessionID)
+		return
+	}
+	if c.frameThrottlers == nil {
+		c.frameThrottlers = map[string]map[string]*frameThrottler{}
+	}
+	if c.frameThrottlers[trackID] == nil {
+		c.frameThrottlers[trackID] = map[string]*frameThrottler{}
+	}
+	c.frameThrottlers[trackID][sessionID] = t
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"sync/atomic"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// callStats holds the media pipeline counters of a call.
+type callStats struct {
+	forwardedBytes uint64
+	nacks          uint64
+	plis           uint64
+}
+
+func (s *callStats) addForwardedBytes(n int) {
+	atomic.AddUint64(&s.forwardedBytes, uint64(n))
+}
+
+func (s *callStats) incNACKs() {
+	atomic.AddUint64(&s.nacks, 1)
+}
+
+func (s *callStats) incPLIs() {
+	atomic.AddUint64(&s.plis, 1)
+}
+
+// CallStats is a snapshot of the media pipeline state of a call.
+type CallStats struct {
+	GroupID string
+	CallID  string
+	// Sessions is the number of sessions in the call.
+	Sessions int
+	// Tracks is the number of media tracks being forwarded.
+	Tracks int
+	// StalledTracks is the number of published tracks no packet was received
+	// on for longer than the inactivity timeout.
+	StalledTracks int
+	// ForwardedBytes is the total number of RTP payload bytes sent to
+	// subscribers.
+	ForwardedBytes uint64
+	// NACKs is the total number of NACK requests received from subscribers.
+	NACKs uint64
+	// PLIs is the total number of PLI requests received from subscribers.
+	PLIs uint64
+}
+
+func (c *call) getStats(groupID string) CallStats {
+	stats := CallStats{
+		GroupID:        groupID,
+		CallID:         c.id,
+		ForwardedBytes: atomic.LoadUint64(&c.stats.forwardedBytes),
+		NACKs:          atomic.LoadUint64(&c.stats.nacks),
+		PLIs:           atomic.LoadUint64(&c.stats.plis),
+	}
+
+	for _, s := range c.getSessions() {
+		stats.Sessions++
+		s.mut.RLock()
+		for _, track := range []*webrtc.TrackLocalStaticRTP{s.outVoiceTrack, s.outScreenTrack, s.outScreenAudioTrack} {
+			if track != nil {
+				stats.Tracks++
+			}
+		}
+		stats.StalledTracks += len(s.getStalledTracks())
+		s.mut.RUnlock()
+	}
+
+	return stats
+}
+
+// GetCallsStats returns a snapshot of the stats of all the ongoing calls.
+func (s *Server) GetCallsStats() []CallStats {
+	var stats []CallStats
+	s.iterCalls(func(g *group, c *call) {
+		stats = append(stats, c.getStats(g.id))
+	})
+	return stats
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCallsStats(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	require.Empty(t, server.GetCallsStats())
+
+	cfg := SessionConfig{
+		GroupID:   "groupA",
+		CallID:    "callA",
+		UserID:    "userA",
+		SessionID: "sessionA",
+	}
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	us, err := server.addSession(cfg, peerConn, nil)
+	require.NoError(t, err)
+
+	cfg.UserID = "userB"
+	cfg.SessionID = "sessionB"
+	_, err = server.addSession(cfg, peerConn, nil)
+	require.NoError(t, err)
+
+	us.outVoiceTrack, err = webrtc.NewTrackLocalStaticRTP(rtpAudioCodec, "voice", "streamID")
+	require.NoError(t, err)
+
+	c := server.getGroup("groupA").getCall("callA")
+	c.stats.addForwardedBytes(1000)
+	c.stats.addForwardedBytes(500)
+	c.stats.incNACKs()
+	c.stats.incPLIs()
+	c.stats.incPLIs()
+
+	require.Equal(t, []CallStats{
+		{
+			GroupID:        "groupA",
+			CallID:         "callA",
+			Sessions:       2,
+			Tracks:         1,
+			ForwardedBytes: 1500,
+			NACKs:          1,
+			PLIs:           2,
+		},
+	}, server.GetCallsStats())
+
+	require.NoError(t, server.CloseSession("sessionA"))
+	require.NoError(t, server.CloseSession("sessionB"))
+}
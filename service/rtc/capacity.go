@@ -0,0 +1,227 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"math"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const capacitySampleInterval = 5 * time.Second
+
+// Capacity is an estimate of the load of the node and of the capacity left,
+// as measured during the last sampling interval.
+type Capacity struct {
+	// CPUPercent is the CPU usage of the process, in percent of all the
+	// CPUs.
+	CPUPercent float64 `json:"cpu_percent"`
+	// PacketRate is the number of UDP packets received and sent per second.
+	PacketRate float64 `json:"packet_rate"`
+	// BandwidthMbps is the media bandwidth, received and sent, in megabits
+	// per second.
+	BandwidthMbps float64 `json:"bandwidth_mbps"`
+	// MaxCPUPercent, MaxPacketRate and MaxBandwidthMbps are the configured
+	// limits. Zero means no limit.
+	MaxCPUPercent    int `json:"max_cpu_percent"`
+	MaxPacketRate    int `json:"max_packet_rate"`
+	MaxBandwidthMbps int `json:"max_bandwidth_mbps"`
+	// Headroom is the fraction of the capacity left, between 0 and 1, for
+	// the most loaded of the limited resources. The CPU usage is compared
+	// against all the CPUs if not limited.
+	Headroom float64 `json:"headroom"`
+	// Busy is set when any of the limits is exceeded, or all the
+	// interfaces exceed their budget.
+	Busy bool `json:"busy"`
+	// Interfaces holds the load of the network interfaces with a bandwidth
+	// budget.
+	Interfaces []InterfaceCapacity `json:"interfaces,omitempty"`
+	// UpdatedAt is the time of the last sample, in milliseconds since the
+	// epoch. It's zero until the first sample is taken.
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// InterfaceCapacity is the load of a network interface against its
+// bandwidth budget.
+type InterfaceCapacity struct {
+	Interface        string  `json:"interface"`
+	BandwidthMbps    float64 `json:"bandwidth_mbps"`
+	MaxBandwidthMbps int     `json:"max_bandwidth_mbps"`
+	// Headroom is the fraction of the budget left, between 0 and 1.
+	Headroom float64 `json:"headroom"`
+	// Busy is set when the budget is exceeded.
+	Busy bool `json:"busy"`
+}
+
+// capacitySample holds the cumulative counters the capacity is computed out
+// of.
+type capacitySample struct {
+	at      time.Time
+	cpuTime time.Duration
+	packets uint64
+	bytes   uint64
+	// ifaceBytes holds the bytes received and sent through each interface
+	// with a budget, keyed by name.
+	ifaceBytes map[string]uint64
+}
+
+// newCapacity returns the capacity of an idle node.
+func newCapacity(cfg CapacityConfig) Capacity {
+	c := Capacity{
+		MaxCPUPercent:    cfg.MaxCPUPercent,
+		MaxPacketRate:    cfg.MaxPacketRate,
+		MaxBandwidthMbps: cfg.MaxBandwidthMbps,
+		Headroom:         1,
+	}
+	for _, b := range cfg.InterfaceBudgets {
+		c.Interfaces = append(c.Interfaces, InterfaceCapacity{
+			Interface:        b.Interface,
+			MaxBandwidthMbps: b.MaxBandwidthMbps,
+			Headroom:         1,
+		})
+	}
+	return c
+}
+
+// computeCapacity returns the capacity estimated out of two consecutive
+// samples, numCPU being the number of CPUs usable by the process.
+func computeCapacity(prev, cur capacitySample, cfg CapacityConfig, numCPU int) Capacity {
+	c := newCapacity(cfg)
+	c.UpdatedAt = cur.at.UnixMilli()
+
+	elapsed := cur.at.Sub(prev.at)
+	if elapsed <= 0 {
+		return c
+	}
+	if cur.cpuTime >= prev.cpuTime {
+		c.CPUPercent = float64(cur.cpuTime-prev.cpuTime) / float64(elapsed) / float64(numCPU) * 100
+	}
+	if cur.packets >= prev.packets {
+		c.PacketRate = float64(cur.packets-prev.packets) / elapsed.Seconds()
+	}
+	if cur.bytes >= prev.bytes {
+		c.BandwidthMbps = float64(cur.bytes-prev.bytes) * 8 / 1e6 / elapsed.Seconds()
+	}
+
+	check := func(used float64, limit int) {
+		if limit <= 0 {
+			return
+		}
+		c.Headroom = math.Min(c.Headroom, 1-used/float64(limit))
+		if used > float64(limit) {
+			c.Busy = true
+		}
+	}
+	check(c.PacketRate, cfg.MaxPacketRate)
+	check(c.BandwidthMbps, cfg.MaxBandwidthMbps)
+	if cfg.MaxCPUPercent > 0 {
+		check(c.CPUPercent, cfg.MaxCPUPercent)
+	} else {
+		c.Headroom = math.Min(c.Headroom, 1-c.CPUPercent/100)
+	}
+
+	// New calls can be placed as long as any interface has headroom.
+	if len(c.Interfaces) > 0 {
+		allBusy := true
+		var ifaceHeadroom float64
+		for i := range c.Interfaces {
+			iface := &c.Interfaces[i]
+			if cur, prev := cur.ifaceBytes[iface.Interface], prev.ifaceBytes[iface.Interface]; cur >= prev {
+				iface.BandwidthMbps = float64(cur-prev) * 8 / 1e6 / elapsed.Seconds()
+			}
+			iface.Headroom = math.Max(0, 1-iface.BandwidthMbps/float64(iface.MaxBandwidthMbps))
+			iface.Busy = iface.BandwidthMbps > float64(iface.MaxBandwidthMbps)
+			allBusy = allBusy && iface.Busy
+			ifaceHeadroom = math.Max(ifaceHeadroom, iface.Headroom)
+		}
+		c.Busy = c.Busy || allBusy
+		c.Headroom = math.Min(c.Headroom, ifaceHeadroom)
+	}
+	c.Headroom = math.Max(0, c.Headroom)
+
+	return c
+}
+
+// takeCapacitySample returns the current values of the counters the capacity
+// is computed out of.
+func (s *Server) takeCapacitySample(now time.Time) capacitySample {
+	sample := capacitySample{at: now}
+
+	cpuTime, err := getProcessCPUTime()
+	if err != nil {
+		s.log.Debug("rtc: failed to get cpu time", mlog.Err(err))
+	}
+	sample.cpuTime = cpuTime
+
+	if s.udpConn != nil {
+		sample.packets = s.udpConn.readCount()
+		for _, st := range s.udpConn.connWriteStats() {
+			sample.packets += st.Writes
+		}
+	}
+
+	s.usageMut.RLock()
+	for _, u := range s.usage {
+		sample.bytes += atomic.LoadUint64(&u.ingressBytes) + atomic.LoadUint64(&u.egressBytes)
+	}
+	s.usageMut.RUnlock()
+
+	if len(s.cfg.Capacity.InterfaceBudgets) > 0 && s.udpConn != nil {
+		sample.ifaceBytes = s.getInterfaceBytes(s.udpConn.srcIPs.getBytes())
+	}
+
+	return sample
+}
+
+// capacityMonitor periodically estimates the capacity left on the node.
+func (s *Server) capacityMonitor(stopCh <-chan struct{}, doneCh chan<- struct{}) {
+	defer close(doneCh)
+
+	ticker := time.NewTicker(capacitySampleInterval)
+	defer ticker.Stop()
+
+	prev := s.takeCapacitySample(time.Now())
+	for {
+		select {
+		case now := <-ticker.C:
+			cur := s.takeCapacitySample(now)
+			capacity := computeCapacity(prev, cur, s.cfg.Capacity, runtime.NumCPU())
+			prev = cur
+
+			s.mut.Lock()
+			wasBusy := s.capacity.Busy
+			s.capacity = capacity
+			s.mut.Unlock()
+
+			s.metrics.SetCapacityHeadroom(capacity.Headroom)
+			if capacity.Busy != wasBusy {
+				s.log.Info("rtc: node busy state changed", mlog.Bool("busy", capacity.Busy),
+					mlog.Float64("cpuPercent", capacity.CPUPercent),
+					mlog.Float64("packetRate", capacity.PacketRate),
+					mlog.Float64("bandwidthMbps", capacity.BandwidthMbps))
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// GetCapacity returns the last estimate of the capacity left on the node.
+func (s *Server) GetCapacity() Capacity {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.capacity
+}
+
+// isOverCapacity returns whether a session starting a new call should be
+// rejected because the node is busy.
+func (s *Server) isOverCapacity(cfg SessionConfig) bool {
+	if !s.cfg.Capacity.RejectNewCalls || s.getSessionCall(cfg) != nil {
+		return false
+	}
+	return s.GetCapacity().Busy
+}
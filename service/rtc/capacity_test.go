@@ -0,0 +1,139 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeCapacity(t *testing.T) {
+	now := time.Now()
+	prev := capacitySample{at: now}
+	cur := capacitySample{
+		at: now.Add(10 * time.Second),
+		// 50% of two CPUs.
+		cpuTime: 10 * time.Second,
+		packets: 50000,
+		bytes:   125000000,
+	}
+
+	t.Run("no elapsed time", func(t *testing.T) {
+		c := computeCapacity(prev, prev, CapacityConfig{}, 2)
+		require.Equal(t, 1.0, c.Headroom)
+		require.Zero(t, c.CPUPercent)
+	})
+
+	t.Run("no limits", func(t *testing.T) {
+		c := computeCapacity(prev, cur, CapacityConfig{}, 2)
+		require.Equal(t, 50.0, c.CPUPercent)
+		require.Equal(t, 5000.0, c.PacketRate)
+		require.Equal(t, 100.0, c.BandwidthMbps)
+		require.Equal(t, 0.5, c.Headroom)
+		require.False(t, c.Busy)
+		require.Equal(t, cur.at.UnixMilli(), c.UpdatedAt)
+	})
+
+	t.Run("within limits", func(t *testing.T) {
+		c := computeCapacity(prev, cur, CapacityConfig{MaxCPUPercent: 100, MaxPacketRate: 10000, MaxBandwidthMbps: 400}, 2)
+		require.Equal(t, 0.5, c.Headroom)
+		require.False(t, c.Busy)
+		require.Equal(t, 10000, c.MaxPacketRate)
+	})
+
+	t.Run("over limit", func(t *testing.T) {
+		c := computeCapacity(prev, cur, CapacityConfig{MaxCPUPercent: 80, MaxBandwidthMbps: 50}, 2)
+		require.Zero(t, c.Headroom)
+		require.True(t, c.Busy)
+
+		c = computeCapacity(prev, cur, CapacityConfig{MaxCPUPercent: 40}, 2)
+		require.True(t, c.Busy)
+	})
+
+	t.Run("interface budgets", func(t *testing.T) {
+		cfg := CapacityConfig{InterfaceBudgets: InterfaceBudgets{
+			{Interface: "eth0", MaxBandwidthMbps: 200},
+			{Interface: "eth1", MaxBandwidthMbps: 50},
+		}}
+		prev := capacitySample{at: now, ifaceBytes: map[string]uint64{}}
+		cur := cur
+		// 50Mbps on eth0, 80Mbps on eth1.
+		cur.ifaceBytes = map[string]uint64{"eth0": 62500000, "eth1": 100000000}
+
+		c := computeCapacity(prev, cur, cfg, 2)
+		require.Equal(t, []InterfaceCapacity{
+			{Interface: "eth0", BandwidthMbps: 50, MaxBandwidthMbps: 200, Headroom: 0.75},
+			{Interface: "eth1", BandwidthMbps: 80, MaxBandwidthMbps: 50, Headroom: 0, Busy: true},
+		}, c.Interfaces)
+		require.False(t, c.Busy)
+		require.Equal(t, 0.5, c.Headroom)
+
+		cfg.InterfaceBudgets[0].MaxBandwidthMbps = 40
+		c = computeCapacity(prev, cur, cfg, 2)
+		require.True(t, c.Busy)
+		require.Zero(t, c.Headroom)
+	})
+
+	t.Run("counters reset", func(t *testing.T) {
+		c := computeCapacity(cur, capacitySample{at: cur.at.Add(time.Second)}, CapacityConfig{}, 2)
+		require.Zero(t, c.PacketRate)
+		require.Zero(t, c.BandwidthMbps)
+		require.Equal(t, 1.0, c.Headroom)
+	})
+}
+
+func TestGetProcessCPUTime(t *testing.T) {
+	cpuTime, err := getProcessCPUTime()
+	require.NoError(t, err)
+	require.Positive(t, cpuTime)
+}
+
+func TestAdmission(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	server.cfg.Capacity = CapacityConfig{MaxCPUPercent: 80, RejectNewCalls: true}
+	server.mut.Lock()
+	server.capacity.Busy = true
+	server.mut.Unlock()
+
+	cfg := SessionConfig{
+		GroupID:   "groupID",
+		CallID:    "callID",
+		UserID:    "userA",
+		SessionID: "sessionA",
+	}
+
+	t.Run("new call", func(t *testing.T) {
+		require.True(t, server.isOverCapacity(cfg))
+		err := server.InitSession(cfg, nil)
+		require.ErrorIs(t, err, ErrServerBusy)
+	})
+
+	t.Run("ongoing call", func(t *testing.T) {
+		peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		_, err = server.addSession(cfg, peerConn, nil)
+		require.NoError(t, err)
+		defer func() {
+			err := server.CloseSession(cfg.SessionID)
+			require.NoError(t, err)
+		}()
+
+		joinCfg := cfg
+		joinCfg.UserID = "userB"
+		joinCfg.SessionID = "sessionB"
+		require.False(t, server.isOverCapacity(joinCfg))
+	})
+
+	t.Run("not busy", func(t *testing.T) {
+		server.mut.Lock()
+		server.capacity.Busy = false
+		server.mut.Unlock()
+		require.False(t, server.isOverCapacity(cfg))
+	})
+}
@@ -0,0 +1,412 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+const (
+	pcapngBlockSHB       = 0x0A0D0D0A
+	pcapngBlockIDB       = 0x00000001
+	pcapngBlockEPB       = 0x00000006
+	pcapngByteOrderMagic = 0x1A2B3C4D
+
+	pcapngOptEnd     = 0
+	pcapngOptComment = 1
+	pcapngOptFlags   = 2
+
+	pcapngFlagInbound  = 1
+	pcapngFlagOutbound = 2
+
+	// linkTypeUpperPDU lets packets be handed directly to the dissector
+	// named in their exported PDU header, since captured packets carry no
+	// lower layers.
+	linkTypeUpperPDU = 252
+	// exportedPDUTagDissector is the exported PDU tag holding the name of
+	// the dissector.
+	exportedPDUTagDissector = 12
+
+	captureProtoRTP  = "rtp"
+	captureProtoRTCP = "rtcp"
+)
+
+// CaptureInfo describes a packet capture.
+type CaptureInfo struct {
+	// Path is the path of the capture file.
+	Path string
+	// Size is the number of bytes written to the file.
+	Size int64
+}
+
+// capture writes the RTP/RTCP packets of a call to a pcapng file. Packets
+// are written after SRTP decryption.
+type capture struct {
+	file           *os.File
+	w              *bufio.Writer
+	path           string
+	size           int64
+	maxSize        int64
+	includePayload bool
+	timer          *time.Timer
+	closed         bool
+	// onDone is called when the capture reaches its size or time bound.
+	onDone func(c *capture, reason string)
+	mut    sync.Mutex
+}
+
+func newCapture(path string, maxSize int64, duration time.Duration, includePayload bool, onDone func(c *capture, reason string)) (*capture, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capture file: %w", err)
+	}
+
+	c := &capture{
+		file:           file,
+		w:              bufio.NewWriter(file),
+		path:           path,
+		maxSize:        maxSize,
+		includePayload: includePayload,
+		onDone:         onDone,
+	}
+
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:], pcapngByteOrderMagic)
+	binary.LittleEndian.PutUint16(shb[4:], 1)
+	binary.LittleEndian.PutUint16(shb[6:], 0)
+	// Section length is not specified.
+	binary.LittleEndian.PutUint64(shb[8:], 0xFFFFFFFFFFFFFFFF)
+
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb[0:], linkTypeUpperPDU)
+
+	err = c.writeBlock(pcapngBlockSHB, shb)
+	if err == nil {
+		err = c.writeBlock(pcapngBlockIDB, idb)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write capture header: %w", err)
+	}
+
+	c.mut.Lock()
+	c.timer = time.AfterFunc(duration, func() {
+		c.onDone(c, "duration elapsed")
+	})
+	c.mut.Unlock()
+
+	return c, nil
+}
+
+func (c *capture) writeBlock(blockType uint32, body []byte) error {
+	padded := pad4(len(body))
+	blockLen := uint32(12 + padded)
+
+	var hdr [8]byte
+	binary.LittleEndian.PutUint32(hdr[0:], blockType)
+	binary.LittleEndian.PutUint32(hdr[4:], blockLen)
+	if _, err := c.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	if _, err := c.w.Write(body); err != nil {
+		return err
+	}
+	if _, err := c.w.Write(make([]byte, padded-len(body))); err != nil {
+		return err
+	}
+	if _, err := c.w.Write(hdr[4:]); err != nil {
+		return err
+	}
+
+	c.size += int64(blockLen)
+
+	return nil
+}
+
+// writePacket writes the given packet, truncated to snapLen bytes if
+// positive.
+func (c *capture) writePacket(proto string, data []byte, snapLen int, outbound bool, sessionID string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	// Exported PDU header, in network byte order.
+	name := pad4(len(proto))
+	pduHdr := make([]byte, 4+name+4)
+	binary.BigEndian.PutUint16(pduHdr[0:], exportedPDUTagDissector)
+	binary.BigEndian.PutUint16(pduHdr[2:], uint16(name))
+	copy(pduHdr[4:], proto)
+
+	capData := data
+	if snapLen > 0 && snapLen < len(data) {
+		capData = data[:snapLen]
+	}
+	capLen := len(pduHdr) + len(capData)
+
+	flags := uint32(pcapngFlagInbound)
+	if outbound {
+		flags = pcapngFlagOutbound
+	}
+	comment := "session=" + sessionID
+
+	body := make([]byte, 20, 20+pad4(capLen)+8+4+pad4(len(comment))+4)
+	ts := uint64(time.Now().UnixMicro())
+	binary.LittleEndian.PutUint32(body[4:], uint32(ts>>32))
+	binary.LittleEndian.PutUint32(body[8:], uint32(ts))
+	binary.LittleEndian.PutUint32(body[12:], uint32(capLen))
+	binary.LittleEndian.PutUint32(body[16:], uint32(len(pduHdr)+len(data)))
+	body = append(body, pduHdr...)
+	body = append(body, capData...)
+	body = append(body, make([]byte, pad4(capLen)-capLen)...)
+	flagsValue := make([]byte, 4)
+	binary.LittleEndian.PutUint32(flagsValue, flags)
+	body = appendOption(body, pcapngOptFlags, flagsValue)
+	body = appendOption(body, pcapngOptComment, []byte(comment))
+	body = appendOption(body, pcapngOptEnd, nil)
+
+	if err := c.writeBlock(pcapngBlockEPB, body); err != nil {
+		c.closeLocked()
+		go c.onDone(c, "write failed: "+err.Error())
+		return
+	}
+
+	if c.size >= c.maxSize {
+		c.closeLocked()
+		go c.onDone(c, "size limit reached")
+	}
+}
+
+// writeRTP writes an RTP packet, leaving out its payload if not included.
+func (c *capture) writeRTP(data []byte, outbound bool, sessionID string) {
+	var snapLen int
+	if !c.includePayload {
+		var hdr rtp.Header
+		n, err := hdr.Unmarshal(data)
+		if err != nil {
+			return
+		}
+		snapLen = n
+	}
+	c.writePacket(captureProtoRTP, data, snapLen, outbound, sessionID)
+}
+
+func (c *capture) writeRTCP(data []byte, outbound bool, sessionID string) {
+	c.writePacket(captureProtoRTCP, data, 0, outbound, sessionID)
+}
+
+func (c *capture) closeLocked() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.timer.Stop()
+	_ = c.w.Flush()
+	_ = c.file.Close()
+}
+
+// close stops the capture and returns its info. It's safe to call it
+// multiple times.
+func (c *capture) close() CaptureInfo {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.closeLocked()
+	return CaptureInfo{
+		Path: c.path,
+		Size: c.size,
+	}
+}
+
+func appendOption(b []byte, code uint16, value []byte) []byte {
+	var hdr [4]byte
+	binary.LittleEndian.PutUint16(hdr[0:], code)
+	binary.LittleEndian.PutUint16(hdr[2:], uint16(len(value)))
+	b = append(b, hdr[:]...)
+	b = append(b, value...)
+	return append(b, make([]byte, pad4(len(value))-len(value))...)
+}
+
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// captureInterceptor hands the packets sent and received by a session to
+// the capture running on its call, if any.
+type captureInterceptor struct {
+	interceptor.NoOp
+
+	sessionID string
+	call      *call
+	mut       sync.RWMutex
+}
+
+// NewInterceptor implements interceptor.Factory. The same instance is
+// returned since a new one is created for each peer connection.
+func (i *captureInterceptor) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return i, nil
+}
+
+func (i *captureInterceptor) setCall(c *call) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	i.call = c
+}
+
+func (i *captureInterceptor) getCapture() *capture {
+	i.mut.RLock()
+	c := i.call
+	i.mut.RUnlock()
+	if c == nil {
+		return nil
+	}
+	return c.getCapture()
+}
+
+func (i *captureInterceptor) BindRTCPReader(reader interceptor.RTCPReader) interceptor.RTCPReader {
+	return interceptor.RTCPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attr, err := reader.Read(b, a)
+		if err == nil {
+			if cpt := i.getCapture(); cpt != nil {
+				cpt.writeRTCP(b[:n], false, i.sessionID)
+			}
+		}
+		return n, attr, err
+	})
+}
+
+func (i *captureInterceptor) BindRTCPWriter(writer interceptor.RTCPWriter) interceptor.RTCPWriter {
+	return interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, a interceptor.Attributes) (int, error) {
+		if cpt := i.getCapture(); cpt != nil {
+			if data, err := rtcp.Marshal(pkts); err == nil {
+				cpt.writeRTCP(data, true, i.sessionID)
+			}
+		}
+		return writer.Write(pkts, a)
+	})
+}
+
+func (i *captureInterceptor) BindLocalStream(_ *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, a interceptor.Attributes) (int, error) {
+		if cpt := i.getCapture(); cpt != nil {
+			if data, err := header.Marshal(); err == nil {
+				cpt.writeRTP(append(data, payload...), true, i.sessionID)
+			}
+		}
+		return writer.Write(header, payload, a)
+	})
+}
+
+func (i *captureInterceptor) BindRemoteStream(_ *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	return interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attr, err := reader.Read(b, a)
+		if err == nil {
+			if cpt := i.getCapture(); cpt != nil {
+				cpt.writeRTP(b[:n], false, i.sessionID)
+			}
+		}
+		return n, attr, err
+	})
+}
+
+// StartCapture starts writing the packets sent and received by the sessions
+// of the given call to a pcapng file. The capture is stopped once duration
+// (capped to the configured maximum) elapses, the size limit is reached or
+// the call ends. It returns the path of the capture file.
+func (s *Server) StartCapture(groupID, callID string, duration time.Duration) (string, error) {
+	if s.cfg.Capture.Dir == "" {
+		return "", fmt.Errorf("capture is not enabled")
+	}
+
+	group := s.getGroup(groupID)
+	if group == nil {
+		return "", fmt.Errorf("group not found: %s", groupID)
+	}
+	call := group.getCall(callID)
+	if call == nil {
+		return "", fmt.Errorf("call not found: %s", callID)
+	}
+
+	if call.getCapture() != nil {
+		return "", fmt.Errorf("capture already started")
+	}
+
+	maxDuration := time.Duration(s.cfg.Capture.MaxDurationSeconds) * time.Second
+	if duration <= 0 || duration > maxDuration {
+		duration = maxDuration
+	}
+
+	// IDs are client provided, a random name keeps them out of the path.
+	path := filepath.Join(s.cfg.Capture.Dir,
+		fmt.Sprintf("rtcd_capture_%s_%s.pcapng", time.Now().UTC().Format("20060102T150405Z"), random.NewID()))
+	maxSize := int64(s.cfg.Capture.MaxSizeMB) * 1024 * 1024
+	cpt, err := newCapture(path, maxSize, duration, s.cfg.Capture.IncludePayload, func(cpt *capture, reason string) {
+		s.stopCapture(call, cpt, reason)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !call.setCapture(cpt) {
+		cpt.close()
+		os.Remove(path)
+		return "", fmt.Errorf("capture already started")
+	}
+
+	s.log.Info("capture started",
+		mlog.String("groupID", groupID),
+		mlog.String("callID", callID),
+		mlog.String("path", path),
+		mlog.Int("durationSeconds", int(duration.Seconds())))
+
+	return path, nil
+}
+
+// StopCapture stops the capture running on the given call.
+func (s *Server) StopCapture(groupID, callID string) (CaptureInfo, error) {
+	group := s.getGroup(groupID)
+	if group == nil {
+		return CaptureInfo{}, fmt.Errorf("group not found: %s", groupID)
+	}
+	call := group.getCall(callID)
+	if call == nil {
+		return CaptureInfo{}, fmt.Errorf("call not found: %s", callID)
+	}
+
+	cpt := call.getCapture()
+	if cpt == nil {
+		return CaptureInfo{}, fmt.Errorf("capture not started")
+	}
+
+	return s.stopCapture(call, cpt, "stopped"), nil
+}
+
+func (s *Server) stopCapture(c *call, cpt *capture, reason string) CaptureInfo {
+	if !c.clearCapture(cpt) {
+		// Already stopped.
+		return cpt.close()
+	}
+	info := cpt.close()
+	s.log.Info("capture stopped",
+		mlog.String("callID", c.id),
+		mlog.String("path", info.Path),
+		mlog.Int64("size", info.Size),
+		mlog.String("reason", reason))
+	return info
+}
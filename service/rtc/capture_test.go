@@ -0,0 +1,253 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+type pcapngBlock struct {
+	blockType uint32
+	body      []byte
+}
+
+func readPcapngBlocks(t *testing.T, path string) []pcapngBlock {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var blocks []pcapngBlock
+	for len(data) > 0 {
+		require.GreaterOrEqual(t, len(data), 12)
+		blockLen := binary.LittleEndian.Uint32(data[4:])
+		require.Zero(t, blockLen%4)
+		require.GreaterOrEqual(t, len(data), int(blockLen))
+		require.Equal(t, blockLen, binary.LittleEndian.Uint32(data[blockLen-4:]))
+		blocks = append(blocks, pcapngBlock{
+			blockType: binary.LittleEndian.Uint32(data),
+			body:      data[8 : blockLen-4],
+		})
+		data = data[blockLen:]
+	}
+
+	return blocks
+}
+
+func marshalRTP(t *testing.T, pkt rtp.Packet) []byte {
+	t.Helper()
+	data, err := pkt.Marshal()
+	require.NoError(t, err)
+	return data
+}
+
+func TestCapture(t *testing.T) {
+	dir := t.TempDir()
+
+	rtpPkt := marshalRTP(t, rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 45, SSRC: 1000},
+		Payload: []byte{0x01, 0x02, 0x03, 0x04, 0x05},
+	})
+	rtcpPkt, err := rtcp.Marshal([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: 1000}})
+	require.NoError(t, err)
+
+	t.Run("format", func(t *testing.T) {
+		path := filepath.Join(dir, "format.pcapng")
+		c, err := newCapture(path, 1024*1024, time.Minute, false, func(_ *capture, _ string) {})
+		require.NoError(t, err)
+
+		c.writeRTP(rtpPkt, false, "sessionA")
+		c.writeRTCP(rtcpPkt, true, "sessionB")
+
+		info := c.close()
+		require.Equal(t, path, info.Path)
+		// Closing again is a no-op.
+		require.Equal(t, info, c.close())
+		c.writeRTP(rtpPkt, false, "sessionA")
+
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, fi.Size(), info.Size)
+
+		blocks := readPcapngBlocks(t, path)
+		require.Len(t, blocks, 4)
+
+		require.Equal(t, uint32(pcapngBlockSHB), blocks[0].blockType)
+		require.Equal(t, uint32(pcapngByteOrderMagic), binary.LittleEndian.Uint32(blocks[0].body))
+
+		require.Equal(t, uint32(pcapngBlockIDB), blocks[1].blockType)
+		require.Equal(t, uint16(linkTypeUpperPDU), binary.LittleEndian.Uint16(blocks[1].body))
+
+		// PDU header: dissector tag with the padded name, followed by the
+		// end tag.
+		pduHdrLen := 4 + 4 + 4
+
+		checkPacket := func(block pcapngBlock, proto string, data []byte, capLen int, flags uint32, comment string) {
+			t.Helper()
+			require.Equal(t, uint32(pcapngBlockEPB), block.blockType)
+			body := block.body
+			require.Equal(t, uint32(pduHdrLen+capLen), binary.LittleEndian.Uint32(body[12:]))
+			require.Equal(t, uint32(pduHdrLen+len(data)), binary.LittleEndian.Uint32(body[16:]))
+
+			pkt := body[20:]
+			require.Equal(t, uint16(exportedPDUTagDissector), binary.BigEndian.Uint16(pkt))
+			require.Equal(t, uint16(4), binary.BigEndian.Uint16(pkt[2:]))
+			require.Equal(t, proto, string(pkt[4:4+len(proto)]))
+			require.Equal(t, []byte{0, 0, 0, 0}, pkt[8:12])
+			require.Equal(t, data[:capLen], pkt[pduHdrLen:pduHdrLen+capLen])
+
+			opts := body[20+pad4(pduHdrLen+capLen):]
+			require.Equal(t, uint16(pcapngOptFlags), binary.LittleEndian.Uint16(opts))
+			require.Equal(t, flags, binary.LittleEndian.Uint32(opts[4:]))
+			opts = opts[8:]
+			require.Equal(t, uint16(pcapngOptComment), binary.LittleEndian.Uint16(opts))
+			require.Equal(t, comment, string(opts[4:4+len(comment)]))
+		}
+
+		// Payloads are left out.
+		checkPacket(blocks[2], "rtp", rtpPkt, 12, pcapngFlagInbound, "session=sessionA")
+		checkPacket(blocks[3], "rtcp", rtcpPkt, len(rtcpPkt), pcapngFlagOutbound, "session=sessionB")
+	})
+
+	t.Run("payload", func(t *testing.T) {
+		path := filepath.Join(dir, "payload.pcapng")
+		c, err := newCapture(path, 1024*1024, time.Minute, true, func(_ *capture, _ string) {})
+		require.NoError(t, err)
+
+		c.writeRTP(rtpPkt, false, "sessionA")
+		c.close()
+
+		blocks := readPcapngBlocks(t, path)
+		require.Len(t, blocks, 3)
+		require.Equal(t, uint32(12+len(rtpPkt)), binary.LittleEndian.Uint32(blocks[2].body[12:]))
+	})
+
+	t.Run("size limit", func(t *testing.T) {
+		doneCh := make(chan string, 1)
+		c, err := newCapture(filepath.Join(dir, "size.pcapng"), 256, time.Minute, true, func(cpt *capture, reason string) {
+			cpt.close()
+			doneCh <- reason
+		})
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			c.writeRTP(rtpPkt, false, "sessionA")
+		}
+
+		select {
+		case reason := <-doneCh:
+			require.Equal(t, "size limit reached", reason)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for capture to stop")
+		}
+		require.Less(t, c.close().Size, int64(256+128))
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		doneCh := make(chan string, 1)
+		_, err := newCapture(filepath.Join(dir, "duration.pcapng"), 1024*1024, 10*time.Millisecond, true, func(cpt *capture, reason string) {
+			cpt.close()
+			doneCh <- reason
+		})
+		require.NoError(t, err)
+
+		select {
+		case reason := <-doneCh:
+			require.Equal(t, "duration elapsed", reason)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for capture to stop")
+		}
+	})
+}
+
+func TestStartCapture(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	t.Run("not enabled", func(t *testing.T) {
+		path, err := server.StartCapture("groupID", "callID", 0)
+		require.EqualError(t, err, "capture is not enabled")
+		require.Empty(t, path)
+	})
+
+	dir := t.TempDir()
+	server.cfg.Capture = CaptureConfig{
+		Dir:                dir,
+		MaxSizeMB:          1,
+		MaxDurationSeconds: 60,
+	}
+
+	t.Run("group not found", func(t *testing.T) {
+		_, err := server.StartCapture("groupID", "callID", 0)
+		require.EqualError(t, err, "group not found: groupID")
+
+		_, err = server.StopCapture("groupID", "callID")
+		require.EqualError(t, err, "group not found: groupID")
+	})
+
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	us, err := server.addSession(SessionConfig{
+		GroupID:   "groupID",
+		CallID:    "callID",
+		UserID:    "userID",
+		SessionID: "sessionID",
+	}, peerConn, nil)
+	require.NoError(t, err)
+	call := server.getGroup("groupID").getCall("callID")
+
+	ci := &captureInterceptor{sessionID: us.cfg.SessionID}
+	ci.setCall(call)
+	rtpPkt := marshalRTP(t, rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 45, SSRC: 1000},
+		Payload: []byte{0x01, 0x02},
+	})
+	reader := ci.BindRemoteStream(&interceptor.StreamInfo{SSRC: 1000}, interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		return copy(b, rtpPkt), a, nil
+	}))
+
+	t.Run("start and stop", func(t *testing.T) {
+		path, err := server.StartCapture("groupID", "callID", time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, dir, filepath.Dir(path))
+		require.Equal(t, ".pcapng", filepath.Ext(path))
+
+		_, err = server.StartCapture("groupID", "callID", 0)
+		require.EqualError(t, err, "capture already started")
+
+		_, _, err = reader.Read(make([]byte, 1500), nil)
+		require.NoError(t, err)
+
+		info, err := server.StopCapture("groupID", "callID")
+		require.NoError(t, err)
+		require.Equal(t, path, info.Path)
+		require.Len(t, readPcapngBlocks(t, path), 3)
+
+		_, err = server.StopCapture("groupID", "callID")
+		require.EqualError(t, err, "capture not started")
+	})
+
+	t.Run("call end", func(t *testing.T) {
+		path, err := server.StartCapture("groupID", "callID", 0)
+		require.NoError(t, err)
+		cpt := call.getCapture()
+		require.NotNil(t, cpt)
+
+		err = server.CloseSession(us.cfg.SessionID)
+		require.NoError(t, err)
+		require.Nil(t, call.getCapture())
+		require.True(t, cpt.closed)
+		require.Len(t, readPcapngBlocks(t, path), 2)
+	})
+}
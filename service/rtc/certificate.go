@@ -0,0 +1,65 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+const dtlsCertificateCommonName = "rtcd"
+
+// NewDTLSCertificate generates a self-signed ECDSA (P-256) certificate,
+// valid from now for the given duration, to be used by the DTLS transports.
+func NewDTLSCertificate(now time.Time, validity time.Duration) (*webrtc.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	cert, err := webrtc.NewCertificate(key, x509.Certificate{
+		Issuer:       pkix.Name{CommonName: dtlsCertificateCommonName},
+		Subject:      pkix.Name{CommonName: dtlsCertificateCommonName},
+		SerialNumber: serialNumber,
+		// Tolerating clock differences with the peers.
+		NotBefore: now.Add(-24 * time.Hour),
+		NotAfter:  now.Add(validity),
+		Version:   2,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	return cert, nil
+}
+
+// SetDTLSCertificate sets the certificate used by the DTLS transports of the
+// sessions initialized from now on, so that they share the same fingerprint.
+// A new certificate is generated for each session if nil.
+func (s *Server) SetDTLSCertificate(cert *webrtc.Certificate) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.dtlsCert = cert
+}
+
+// GetDTLSCertificate returns the certificate set through SetDTLSCertificate,
+// if any.
+func (s *Server) GetDTLSCertificate() *webrtc.Certificate {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.dtlsCert
+}
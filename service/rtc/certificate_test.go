@@ -0,0 +1,43 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDTLSCertificate(t *testing.T) {
+	now := time.Now()
+	cert, err := NewDTLSCertificate(now, 24*time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(24*time.Hour).Unix(), cert.Expires().Unix())
+
+	fingerprints, err := cert.GetFingerprints()
+	require.NoError(t, err)
+	require.NotEmpty(t, fingerprints)
+
+	t.Run("pem", func(t *testing.T) {
+		pem, err := cert.PEM()
+		require.NoError(t, err)
+
+		parsed, err := webrtc.CertificateFromPEM(pem)
+		require.NoError(t, err)
+		parsedFingerprints, err := parsed.GetFingerprints()
+		require.NoError(t, err)
+		require.Equal(t, fingerprints, parsedFingerprints)
+	})
+
+	t.Run("unique", func(t *testing.T) {
+		other, err := NewDTLSCertificate(now, 24*time.Hour)
+		require.NoError(t, err)
+		otherFingerprints, err := other.GetFingerprints()
+		require.NoError(t, err)
+		require.NotEqual(t, fingerprints, otherFingerprints)
+	})
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"math/rand"
+	"net"
+	"time"
+)
+
+// chaosReorderDelay is how long the packets picked for reordering are held
+// back, on top of the configured latency.
+const chaosReorderDelay = 20 * time.Millisecond
+
+// chaosConn wraps the conn media is served on, injecting the faults set in
+// ChaosConfig as a lossy network would: packets get dropped in either
+// direction, and the ones sent get delayed and reordered.
+type chaosConn struct {
+	net.PacketConn
+	cfg ChaosConfig
+	// randFn returns a pseudo-random number in [0, 1).
+	randFn func() float64
+}
+
+func newChaosConn(conn net.PacketConn, cfg ChaosConfig) *chaosConn {
+	return &chaosConn{
+		PacketConn: conn,
+		cfg:        cfg,
+		randFn:     rand.Float64,
+	}
+}
+
+// hit returns whether a fault occurring percent percent of the time should
+// be injected.
+func (c *chaosConn) hit(percent int) bool {
+	return percent > 0 && c.randFn()*100 < float64(percent)
+}
+
+func (c *chaosConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(p)
+		if err != nil || !c.hit(c.cfg.PacketLossPercent) {
+			return n, addr, err
+		}
+	}
+}
+
+func (c *chaosConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if c.hit(c.cfg.PacketLossPercent) {
+		return len(p), nil
+	}
+
+	delay := c.delay()
+	if delay == 0 {
+		return c.PacketConn.WriteTo(p, addr)
+	}
+
+	// The caller can reuse p as soon as we return.
+	data := make([]byte, len(p))
+	copy(data, p)
+	time.AfterFunc(delay, func() {
+		_, _ = c.PacketConn.WriteTo(data, addr)
+	})
+
+	return len(p), nil
+}
+
+// delay returns how long the next packet sent should be held back for.
+func (c *chaosConn) delay() time.Duration {
+	delay := time.Duration(c.cfg.LatencyMs) * time.Millisecond
+	if c.cfg.JitterMs > 0 {
+		delay += time.Duration(c.randFn() * float64(time.Duration(c.cfg.JitterMs)*time.Millisecond))
+	}
+	if c.hit(c.cfg.ReorderPercent) {
+		delay += chaosReorderDelay
+	}
+	return delay
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaosConn(t *testing.T) {
+	newConns := func(t *testing.T, cfg ChaosConfig, randFn func() float64) (*chaosConn, net.PacketConn) {
+		t.Helper()
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		peer, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { peer.Close() })
+		c := newChaosConn(conn, cfg)
+		c.randFn = randFn
+		return c, peer
+	}
+
+	readPacket := func(t *testing.T, conn net.PacketConn) string {
+		t.Helper()
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		buf := make([]byte, receiveMTU)
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	t.Run("passthrough", func(t *testing.T) {
+		c, peer := newConns(t, ChaosConfig{Enable: true}, func() float64 { return 0 })
+		n, err := c.WriteTo([]byte("a"), peer.LocalAddr())
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		require.Equal(t, "a", readPacket(t, peer))
+
+		_, err = peer.WriteTo([]byte("b"), c.LocalAddr())
+		require.NoError(t, err)
+		require.Equal(t, "b", readPacket(t, c))
+	})
+
+	t.Run("packet loss", func(t *testing.T) {
+		var drop bool
+		c, peer := newConns(t, ChaosConfig{Enable: true, PacketLossPercent: 50}, func() float64 {
+			drop = !drop
+			if drop {
+				return 0.1
+			}
+			return 0.9
+		})
+
+		for _, data := range []string{"a", "b", "c", "d"} {
+			n, err := c.WriteTo([]byte(data), peer.LocalAddr())
+			require.NoError(t, err)
+			require.Equal(t, 1, n)
+		}
+		require.Equal(t, "b", readPacket(t, peer))
+		require.Equal(t, "d", readPacket(t, peer))
+
+		for _, data := range []string{"a", "b", "c", "d"} {
+			_, err := peer.WriteTo([]byte(data), c.LocalAddr())
+			require.NoError(t, err)
+		}
+		require.Equal(t, "b", readPacket(t, c))
+		require.Equal(t, "d", readPacket(t, c))
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		c, peer := newConns(t, ChaosConfig{Enable: true, LatencyMs: 50, JitterMs: 100}, func() float64 { return 0.5 })
+		require.Equal(t, 100*time.Millisecond, c.delay())
+
+		start := time.Now()
+		data := []byte("a")
+		_, err := c.WriteTo(data, peer.LocalAddr())
+		require.NoError(t, err)
+		// The packet is copied.
+		data[0] = 'b'
+		require.Equal(t, "a", readPacket(t, peer))
+		require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("reorder", func(t *testing.T) {
+		var reorder bool
+		c, peer := newConns(t, ChaosConfig{Enable: true, ReorderPercent: 50}, func() float64 {
+			reorder = !reorder
+			if reorder {
+				return 0.1
+			}
+			return 0.9
+		})
+
+		for _, data := range []string{"a", "b"} {
+			_, err := c.WriteTo([]byte(data), peer.LocalAddr())
+			require.NoError(t, err)
+		}
+		require.Equal(t, "b", readPacket(t, peer))
+		require.Equal(t, "a", readPacket(t, peer))
+	})
+}
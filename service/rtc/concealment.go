@@ -0,0 +1,122 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+// Sources of the concealment stats of a stream.
+const (
+	// ConcealmentSourceXR is set when the stats are derived from RTCP XR
+	// VoIP metrics, which account for the packets discarded by the jitter
+	// buffer of the peer.
+	ConcealmentSourceXR = "xr"
+	// ConcealmentSourceRR is set when the stats are derived from the loss
+	// reported in reception reports.
+	ConcealmentSourceRR = "rr"
+)
+
+// uplinkLossInterval is the interval the loss of the publisher leg of a
+// stream is computed over.
+const uplinkLossInterval = time.Second
+
+// uplinkLoss computes the fraction of the packets of a stream lost between
+// its publisher and the server, the same way receivers do for their
+// reception reports (RFC 3550 A.3).
+type uplinkLoss struct {
+	started bool
+	// cycles counts the wrap-arounds of the sequence numbers, shifted by 16
+	// bits.
+	cycles uint32
+	maxSeq uint16
+	// baseSeq is the extended sequence number the current interval starts
+	// at.
+	baseSeq       uint32
+	received      uint32
+	intervalStart time.Time
+	fractionLost  float64
+	mut           sync.Mutex
+}
+
+// onPacket accounts for a packet received from the publisher.
+func (l *uplinkLoss) onPacket(seq uint16, now time.Time) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	if !l.started {
+		l.started = true
+		l.maxSeq = seq
+		l.baseSeq = uint32(seq)
+		l.received = 1
+		l.intervalStart = now
+		return
+	}
+
+	if seqBefore(l.maxSeq, seq) {
+		if seq < l.maxSeq {
+			l.cycles += 1 << 16
+		}
+		l.maxSeq = seq
+	}
+	l.received++
+
+	if now.Sub(l.intervalStart) < uplinkLossInterval {
+		return
+	}
+
+	extSeq := l.cycles | uint32(l.maxSeq)
+	expected := extSeq - l.baseSeq + 1
+	var lost uint32
+	// Duplicates and retransmissions can make up for lost packets.
+	if expected > l.received {
+		lost = expected - l.received
+	}
+	l.fractionLost = float64(lost) / float64(expected)
+
+	l.baseSeq = extSeq + 1
+	l.received = 0
+	l.intervalStart = now
+}
+
+// get returns the fraction of packets lost over the last complete interval.
+func (l *uplinkLoss) get() float64 {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	return l.fractionLost
+}
+
+// concealmentFromReceptionReport sets the concealment stats of q, a stream
+// quality derived from a reception report: every lost packet had to be
+// concealed. Stats derived from a recent XR block, if any, are kept as
+// they also account for the late packets.
+func concealmentFromReceptionReport(q, prev StreamQuality, now time.Time) StreamQuality {
+	if !prev.xrReceivedAt.IsZero() && now.Sub(prev.xrReceivedAt) < receiverReportTimeout {
+		q.ConcealmentRate = prev.ConcealmentRate
+		q.DiscardRate = prev.DiscardRate
+		q.ConcealmentSource = ConcealmentSourceXR
+		q.xrReceivedAt = prev.xrReceivedAt
+		return q
+	}
+
+	q.ConcealmentRate = q.FractionLost
+	q.DiscardRate = 0
+	q.ConcealmentSource = ConcealmentSourceRR
+	return q
+}
+
+// concealmentFromVoIPMetrics sets the concealment stats of q out of an XR
+// VoIP metrics block: packets are concealed if either lost or discarded for
+// arriving too late to be played out.
+func concealmentFromVoIPMetrics(q StreamQuality, block *rtcp.VoIPMetricsReportBlock, now time.Time) StreamQuality {
+	q.DiscardRate = float64(block.DiscardRate) / 256
+	q.ConcealmentRate = math.Min(1, float64(block.LossRate)/256+q.DiscardRate)
+	q.ConcealmentSource = ConcealmentSourceXR
+	q.xrReceivedAt = now
+	return q
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUplinkLoss(t *testing.T) {
+	now := time.Now()
+
+	t.Run("no loss", func(t *testing.T) {
+		var l uplinkLoss
+		for i := 0; i <= 50; i++ {
+			l.onPacket(uint16(i), now.Add(time.Duration(i)*20*time.Millisecond))
+		}
+		require.Zero(t, l.get())
+	})
+
+	t.Run("loss", func(t *testing.T) {
+		var l uplinkLoss
+		for i := 0; i <= 50; i++ {
+			// One packet out of five is lost.
+			if i%5 == 4 {
+				continue
+			}
+			l.onPacket(uint16(i), now.Add(time.Duration(i)*20*time.Millisecond))
+		}
+		require.InDelta(t, 0.2, l.get(), 0.01)
+
+		// Only the last interval is considered.
+		for i := 51; i <= 101; i++ {
+			l.onPacket(uint16(i), now.Add(time.Duration(i)*20*time.Millisecond))
+		}
+		require.Zero(t, l.get())
+	})
+
+	t.Run("wrap-around", func(t *testing.T) {
+		var l uplinkLoss
+		for i := 0; i <= 50; i++ {
+			if i == 30 {
+				continue
+			}
+			l.onPacket(uint16(65520+i), now.Add(time.Duration(i)*20*time.Millisecond))
+		}
+		require.InDelta(t, 1.0/51, l.get(), 0.001)
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		var l uplinkLoss
+		for i := 0; i <= 50; i++ {
+			l.onPacket(uint16(i), now.Add(time.Duration(i)*20*time.Millisecond))
+			l.onPacket(uint16(i), now.Add(time.Duration(i)*20*time.Millisecond))
+		}
+		require.Zero(t, l.get())
+	})
+}
+
+func TestConcealment(t *testing.T) {
+	now := time.Now()
+
+	t.Run("reception report", func(t *testing.T) {
+		q := newStreamQuality("trackID", 0.1, 0, 0, now)
+		q = concealmentFromReceptionReport(q, StreamQuality{}, now)
+		require.Equal(t, ConcealmentSourceRR, q.ConcealmentSource)
+		require.Equal(t, 0.1, q.ConcealmentRate)
+		require.Zero(t, q.DiscardRate)
+	})
+
+	t.Run("voip metrics", func(t *testing.T) {
+		q := newStreamQuality("trackID", 0.1, 0, 0, now)
+		q = concealmentFromVoIPMetrics(q, &rtcp.VoIPMetricsReportBlock{
+			LossRate:    26,
+			DiscardRate: 13,
+		}, now)
+		require.Equal(t, ConcealmentSourceXR, q.ConcealmentSource)
+		require.InDelta(t, 0.15, q.ConcealmentRate, 0.01)
+		require.InDelta(t, 0.05, q.DiscardRate, 0.01)
+
+		q = concealmentFromVoIPMetrics(q, &rtcp.VoIPMetricsReportBlock{
+			LossRate:    200,
+			DiscardRate: 200,
+		}, now)
+		require.Equal(t, 1.0, q.ConcealmentRate)
+	})
+
+	t.Run("voip metrics take precedence", func(t *testing.T) {
+		xr := concealmentFromVoIPMetrics(newStreamQuality("trackID", 0.1, 0, 0, now), &rtcp.VoIPMetricsReportBlock{
+			LossRate:    26,
+			DiscardRate: 13,
+		}, now)
+
+		q := concealmentFromReceptionReport(newStreamQuality("trackID", 0.02, 0, 0, now), xr, now.Add(time.Second))
+		require.Equal(t, ConcealmentSourceXR, q.ConcealmentSource)
+		require.Equal(t, xr.ConcealmentRate, q.ConcealmentRate)
+		require.Equal(t, xr.DiscardRate, q.DiscardRate)
+		require.Equal(t, 0.02, q.FractionLost)
+
+		// Until the XR stats get stale.
+		q = concealmentFromReceptionReport(newStreamQuality("trackID", 0.02, 0, 0, now), q, now.Add(receiverReportTimeout+time.Second))
+		require.Equal(t, ConcealmentSourceRR, q.ConcealmentSource)
+		require.Equal(t, 0.02, q.ConcealmentRate)
+		require.Zero(t, q.DiscardRate)
+	})
+}
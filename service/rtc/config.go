@@ -7,7 +7,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"net/url"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/pion/ice/v2"
 )
 
 type ServerConfig struct {
@@ -21,6 +27,924 @@ type ServerConfig struct {
 	// A list of ICE server (STUN/TURN) configurations to use.
 	ICEServers ICEServers `toml:"ice_servers"`
 	TURNConfig TURNConfig `toml:"turn"`
+	// PublicIPDiscovery configures how the public IP address advertised to
+	// clients is discovered when ICEHostOverride is not set.
+	PublicIPDiscovery PublicIPDiscoveryConfig `toml:"public_ip_discovery"`
+	// UDPSockets controls how many UDP sockets are used to serve media.
+	UDPSockets UDPSocketsConfig `toml:"udp_sockets"`
+	// LocalUDPPorts restricts the local ports of the UDP sockets opened for
+	// outbound traffic, so that egress firewall rules can be kept tight.
+	LocalUDPPorts LocalUDPPortsConfig `toml:"local_udp_ports"`
+	// Transcription configures the optional external transcription service.
+	Transcription TranscriptionConfig `toml:"transcription"`
+	// IdleCallTimeoutMinutes specifies after how many minutes a call in which
+	// no session has an established connection gets ended. Zero disables it.
+	IdleCallTimeoutMinutes int `toml:"idle_call_timeout_minutes"`
+	// MaxCallDurationMinutes specifies after how many minutes a call gets
+	// ended. Zero means no limit.
+	MaxCallDurationMinutes int `toml:"max_call_duration_minutes"`
+	// MaxCallParticipants specifies the maximum number of sessions allowed
+	// in a single call. Zero means no limit.
+	MaxCallParticipants int `toml:"max_call_participants"`
+	// TrackInactivityTimeoutMs specifies after how many milliseconds without
+	// receiving packets a published track is reported as stalled. Zero
+	// disables it.
+	TrackInactivityTimeoutMs int `toml:"track_inactivity_timeout_ms"`
+	// ReceiverReportAggregation controls how the receiver reports sent by
+	// subscribers are aggregated and fed back to publishers so that their
+	// encoders can adapt, in place of the reports on the received streams.
+	// Can be "none", "worst" or "median".
+	ReceiverReportAggregation string `toml:"receiver_report_aggregation"`
+	// RTX configures the negotiation of retransmission streams.
+	RTX RTXConfig `toml:"rtx"`
+	// Capture configures the packet captures of calls.
+	Capture CaptureConfig `toml:"capture"`
+	// Recording configures the recordings of single participants.
+	Recording RecordingConfig `toml:"recording"`
+	// HLS configures the LL-HLS broadcasts of calls.
+	HLS HLSConfig `toml:"hls"`
+	// Announcements configures the audio announcements played into calls.
+	Announcements AnnouncementsConfig `toml:"announcements"`
+	// KeyExport configures the export of the SRTP keys of calls to an
+	// authorized recorder.
+	KeyExport KeyExportConfig `toml:"key_export"`
+	// ConnectivityCheck configures the periodic checks of the configured
+	// STUN/TURN servers.
+	ConnectivityCheck ConnectivityCheckConfig `toml:"connectivity_check"`
+	// SRTPProtectionProfiles lists, in order of preference, the SRTP
+	// protection profiles offered during the DTLS handshake. Can contain
+	// "AEAD_AES_128_GCM" and "AES_128_CM_SHA1_80". Defaults to both, in
+	// this order, if empty.
+	SRTPProtectionProfiles []string `toml:"srtp_protection_profiles"`
+	// SRTPProfilesByBenchmark orders the default SRTP protection profiles
+	// from the fastest to the slowest in the benchmark run at startup. It
+	// doesn't apply if SRTPProtectionProfiles is set.
+	SRTPProfilesByBenchmark bool `toml:"srtp_profiles_by_benchmark"`
+	// DTLSCertificate configures the certificate used by the DTLS
+	// transports.
+	DTLSCertificate DTLSCertificateConfig `toml:"dtls_certificate"`
+	// ICETimeouts configures the timers of the ICE agents.
+	ICETimeouts ICETimeoutsConfig `toml:"ice_timeouts"`
+	// MDNSCandidates configures the handling of the client candidates whose
+	// address is an mDNS (.local) host name.
+	MDNSCandidates MDNSCandidatesConfig `toml:"mdns_candidates"`
+	// ICECandidates configures the batching and filtering of the local
+	// candidates sent to clients.
+	ICECandidates ICECandidatesConfig `toml:"ice_candidates"`
+	// EnableSessionMigration controls whether sessions should be re-anchored,
+	// through an ICE restart, to the new network address of clients sending
+	// from one after losing connectivity, instead of failing.
+	EnableSessionMigration bool `toml:"enable_session_migration"`
+	// RelayOnly applies the relay only ICE policy to all the calls: sessions
+	// connect through the TURN servers and the candidates sent by clients
+	// are dropped unless relayed, protecting the IP addresses of the
+	// participants.
+	RelayOnly bool `toml:"relay_only"`
+	// RTPHeaderExtensions lists the RTP header extensions to negotiate. Can
+	// contain "audio-level", "transport-cc", "mid", "rid", "abs-send-time",
+	// "abs-capture-time" and "video-orientation". None are negotiated if
+	// empty.
+	RTPHeaderExtensions []string `toml:"rtp_header_extensions"`
+	// JitterBuffer configures the buffering of the packets received from
+	// publishers before they get forwarded.
+	JitterBuffer JitterBufferConfig `toml:"jitter_buffer"`
+	// KeyFrameCache configures the caching of the last key frame of video
+	// tracks, sent to new subscribers.
+	KeyFrameCache KeyFrameCacheConfig `toml:"keyframe_cache"`
+	// EgressShaping configures the limiting of the media bandwidth sent to
+	// the subscribers of each call.
+	EgressShaping EgressShapingConfig `toml:"egress_shaping"`
+	// Capacity configures the estimation of the capacity left on the node
+	// and the admission of new calls.
+	Capacity CapacityConfig `toml:"capacity"`
+	// Chaos configures the faults injected into the media traffic to test
+	// the resilience of clients. Never meant to be enabled in production.
+	Chaos ChaosConfig `toml:"chaos"`
+}
+
+// ChaosConfig holds the settings of the fault injection mode, in which the
+// packets sent and received on the UDP sockets get dropped, delayed and
+// reordered on purpose.
+type ChaosConfig struct {
+	// Enable controls whether faults should be injected.
+	Enable bool `toml:"enable"`
+	// PacketLossPercent specifies the percentage of packets dropped, in
+	// either direction.
+	PacketLossPercent int `toml:"packet_loss_percent"`
+	// LatencyMs specifies the delay, in milliseconds, added to the packets
+	// sent.
+	LatencyMs int `toml:"latency_ms"`
+	// JitterMs specifies the upper bound, in milliseconds, of the random
+	// delay added on top of LatencyMs.
+	JitterMs int `toml:"jitter_ms"`
+	// ReorderPercent specifies the percentage of packets sent held back long
+	// enough for the following ones to overtake them.
+	ReorderPercent int `toml:"reorder_percent"`
+}
+
+func (c ChaosConfig) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.PacketLossPercent < 0 || c.PacketLossPercent > 100 {
+		return fmt.Errorf("invalid PacketLossPercent value: should be in the range [0, 100]")
+	}
+	if c.LatencyMs < 0 {
+		return fmt.Errorf("invalid LatencyMs value: should not be negative")
+	}
+	if c.JitterMs < 0 {
+		return fmt.Errorf("invalid JitterMs value: should not be negative")
+	}
+	if c.ReorderPercent < 0 || c.ReorderPercent > 100 {
+		return fmt.Errorf("invalid ReorderPercent value: should be in the range [0, 100]")
+	}
+	return nil
+}
+
+type JitterBufferConfig struct {
+	// AudioDelayMs specifies by how many milliseconds the forwarding of audio
+	// packets is delayed so that they can be paced according to their
+	// timestamps. Audio is forwarded as received if zero.
+	AudioDelayMs int `toml:"audio_delay_ms"`
+	// VideoReorderWindowMs specifies for how many milliseconds video packets
+	// are held when some are missing, so that those received out of order
+	// get forwarded in order. Video is forwarded as received if zero.
+	VideoReorderWindowMs int `toml:"video_reorder_window_ms"`
+}
+
+func (c JitterBufferConfig) IsValid() error {
+	if c.AudioDelayMs < 0 || c.AudioDelayMs > jitterBufferMaxDelay {
+		return fmt.Errorf("invalid AudioDelayMs value: %d is not in allowed range [0, %d]", c.AudioDelayMs, jitterBufferMaxDelay)
+	}
+
+	if c.VideoReorderWindowMs < 0 || c.VideoReorderWindowMs > jitterBufferMaxDelay {
+		return fmt.Errorf("invalid VideoReorderWindowMs value: %d is not in allowed range [0, %d]", c.VideoReorderWindowMs, jitterBufferMaxDelay)
+	}
+
+	return nil
+}
+
+// KeyFrameCacheConfig holds the settings of the key frame cache: the last key
+// frame of each video track is kept so that new subscribers can be sent one
+// right away, instead of waiting for the publisher to send the next one.
+type KeyFrameCacheConfig struct {
+	// Enable controls whether key frames should be cached.
+	Enable bool `toml:"enable"`
+	// MaxSizeKB specifies the size, in kilobytes, above which key frames
+	// aren't cached.
+	MaxSizeKB int `toml:"max_size_kb"`
+}
+
+func (c KeyFrameCacheConfig) IsValid() error {
+	if c.Enable && c.MaxSizeKB <= 0 {
+		return fmt.Errorf("invalid MaxSizeKB value: should be a positive number")
+	}
+	return nil
+}
+
+// EgressShapingConfig holds the settings of the per-call egress shaping: the
+// media forwarded to the subscribers of a call is limited by a token bucket
+// so that a single large call can't take all the bandwidth of the node.
+type EgressShapingConfig struct {
+	// Enable controls whether the egress of calls should be shaped.
+	Enable bool `toml:"enable"`
+	// RateKbps specifies the sustained bandwidth, in kilobits per second,
+	// each call is allowed to send.
+	RateKbps int `toml:"rate_kbps"`
+	// BurstKB specifies the size, in kilobytes, of the bursts each call is
+	// allowed to send above the rate.
+	BurstKB int `toml:"burst_kb"`
+}
+
+func (c EgressShapingConfig) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.RateKbps <= 0 {
+		return fmt.Errorf("invalid RateKbps value: should be a positive number")
+	}
+	if c.BurstKB <= 0 {
+		return fmt.Errorf("invalid BurstKB value: should be a positive number")
+	}
+	return nil
+}
+
+type CapacityConfig struct {
+	// MaxCPUPercent specifies the CPU usage of the process, in percent of
+	// all the CPUs, above which the node is considered busy. Zero means no
+	// limit.
+	MaxCPUPercent int `toml:"max_cpu_percent"`
+	// MaxPacketRate specifies the number of UDP packets received and sent
+	// per second above which the node is considered busy. Zero means no
+	// limit.
+	MaxPacketRate int `toml:"max_packet_rate"`
+	// MaxBandwidthMbps specifies the media bandwidth, received and sent, in
+	// megabits per second, above which the node is considered busy. Zero
+	// means no limit.
+	MaxBandwidthMbps int `toml:"max_bandwidth_mbps"`
+	// RejectNewCalls controls whether sessions starting a new call should
+	// be rejected while the node is busy. Sessions joining ongoing calls are
+	// always accepted.
+	RejectNewCalls bool `toml:"reject_new_calls"`
+	// InterfaceBudgets optionally assigns a media bandwidth budget to the
+	// network interfaces of a multi-homed host. New calls are then placed on
+	// the interface with the most headroom, the node being considered busy
+	// once all of them exceed their budget.
+	InterfaceBudgets InterfaceBudgets `toml:"interface_budgets"`
+}
+
+// InterfaceBudgetConfig holds the media bandwidth budget of a network
+// interface.
+type InterfaceBudgetConfig struct {
+	// Interface is the name of the network interface (e.g. "eth1").
+	Interface string `toml:"interface" json:"interface"`
+	// MaxBandwidthMbps is the media bandwidth, received and sent through
+	// the IP addresses of the interface, in megabits per second, above which
+	// no new call is placed on it.
+	MaxBandwidthMbps int `toml:"max_bandwidth_mbps" json:"max_bandwidth_mbps"`
+}
+
+type InterfaceBudgets []InterfaceBudgetConfig
+
+func (c InterfaceBudgetConfig) IsValid() error {
+	if c.Interface == "" {
+		return fmt.Errorf("invalid Interface value: should not be empty")
+	}
+	if c.MaxBandwidthMbps <= 0 {
+		return fmt.Errorf("invalid MaxBandwidthMbps value: should be a positive number")
+	}
+	return nil
+}
+
+func (b InterfaceBudgets) IsValid() error {
+	seen := make(map[string]bool, len(b))
+	for _, c := range b {
+		if err := c.IsValid(); err != nil {
+			return err
+		}
+		if seen[c.Interface] {
+			return fmt.Errorf("invalid Interface value: %q is listed more than once", c.Interface)
+		}
+		seen[c.Interface] = true
+	}
+	return nil
+}
+
+// Decode parses the budgets from a JSON array, as set through the
+// environment, e.g. [{"interface": "eth1", "max_bandwidth_mbps": 1000}].
+func (b *InterfaceBudgets) Decode(value string) error {
+	return json.Unmarshal([]byte(value), b)
+}
+
+func (c CapacityConfig) IsValid() error {
+	if c.MaxCPUPercent < 0 || c.MaxCPUPercent > 100 {
+		return fmt.Errorf("invalid MaxCPUPercent value: should be in the range [0, 100]")
+	}
+	if c.MaxPacketRate < 0 {
+		return fmt.Errorf("invalid MaxPacketRate value: should not be negative")
+	}
+	if c.MaxBandwidthMbps < 0 {
+		return fmt.Errorf("invalid MaxBandwidthMbps value: should not be negative")
+	}
+	if c.RejectNewCalls && c.MaxCPUPercent == 0 && c.MaxPacketRate == 0 && c.MaxBandwidthMbps == 0 && len(c.InterfaceBudgets) == 0 {
+		return fmt.Errorf("invalid RejectNewCalls value: at least one limit should be set")
+	}
+	if err := c.InterfaceBudgets.IsValid(); err != nil {
+		return fmt.Errorf("invalid InterfaceBudgets value: %w", err)
+	}
+	return nil
+}
+
+type ICECandidatesConfig struct {
+	// BatchIntervalMs specifies for how many milliseconds the gathered
+	// candidates are held so that they're sent in a single signaling message.
+	// Each candidate is sent as soon as gathered if zero.
+	BatchIntervalMs int `toml:"batch_interval_ms"`
+	// DropLinkLocal controls whether candidates with a link-local address
+	// (169.254.0.0/16, fe80::/10) should not be sent.
+	DropLinkLocal bool `toml:"drop_link_local"`
+	// DropPrivateWhenPublic controls whether candidates with a private
+	// address should not be sent once a candidate with a public address is
+	// gathered.
+	DropPrivateWhenPublic bool `toml:"drop_private_when_public"`
+	// AllowedNetworks optionally lists, in CIDR notation, the networks the
+	// address of a candidate should belong to for it to be sent.
+	AllowedNetworks []string `toml:"allowed_networks"`
+}
+
+func (c ICECandidatesConfig) IsValid() error {
+	if c.BatchIntervalMs < 0 {
+		return fmt.Errorf("invalid BatchIntervalMs value: should not be negative")
+	}
+
+	for _, cidr := range c.AllowedNetworks {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid AllowedNetworks value: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type MDNSCandidatesConfig struct {
+	// Mode controls how the client candidates whose address is an mDNS
+	// (.local) host name are handled. Can be "ignore", dropping them, or
+	// "resolve", resolving them through multicast DNS queries. Defaults to
+	// "ignore" if empty.
+	Mode string `toml:"mode"`
+	// ResolveTimeoutMs specifies how long, in milliseconds, resolving the
+	// candidates of a single signaling message can take. The candidates not
+	// resolved in time are dropped.
+	ResolveTimeoutMs int `toml:"resolve_timeout_ms"`
+}
+
+func (c MDNSCandidatesConfig) IsValid() error {
+	switch c.Mode {
+	case "", MDNSCandidatesModeIgnore:
+	case MDNSCandidatesModeResolve:
+		if c.ResolveTimeoutMs <= 0 {
+			return fmt.Errorf("invalid ResolveTimeoutMs value: should be a positive number")
+		}
+	default:
+		return fmt.Errorf("invalid Mode value: should be one of %q or %q",
+			MDNSCandidatesModeIgnore, MDNSCandidatesModeResolve)
+	}
+
+	return nil
+}
+
+type ICETimeoutsConfig struct {
+	// DisconnectedTimeoutMs specifies after how many milliseconds without
+	// receiving any packet a connection is considered disconnected.
+	// Defaults to 5000 if zero.
+	DisconnectedTimeoutMs int `toml:"disconnected_timeout_ms"`
+	// FailedTimeoutMs specifies after how many milliseconds a disconnected
+	// connection is considered failed, ending the session. Defaults to 25000
+	// if zero.
+	FailedTimeoutMs int `toml:"failed_timeout_ms"`
+	// KeepaliveIntervalMs specifies how often, in milliseconds, the selected
+	// candidate pair is checked to keep the connection alive. Defaults to
+	// 2000 if zero.
+	KeepaliveIntervalMs int `toml:"keepalive_interval_ms"`
+}
+
+// Defaults of the WebRTC stack.
+const (
+	defaultICEDisconnectedTimeout = 5 * time.Second
+	defaultICEFailedTimeout       = 25 * time.Second
+	defaultICEKeepaliveInterval   = 2 * time.Second
+)
+
+// getTimeouts returns the disconnected and failed timeouts and the
+// keepalive interval, falling back to the defaults for the unset ones.
+func (c ICETimeoutsConfig) getTimeouts() (disconnected, failed, keepalive time.Duration) {
+	disconnected, failed, keepalive = defaultICEDisconnectedTimeout, defaultICEFailedTimeout, defaultICEKeepaliveInterval
+	if c.DisconnectedTimeoutMs > 0 {
+		disconnected = time.Duration(c.DisconnectedTimeoutMs) * time.Millisecond
+	}
+	if c.FailedTimeoutMs > 0 {
+		failed = time.Duration(c.FailedTimeoutMs) * time.Millisecond
+	}
+	if c.KeepaliveIntervalMs > 0 {
+		keepalive = time.Duration(c.KeepaliveIntervalMs) * time.Millisecond
+	}
+	return disconnected, failed, keepalive
+}
+
+func (c ICETimeoutsConfig) IsValid() error {
+	if c.DisconnectedTimeoutMs < 0 {
+		return fmt.Errorf("invalid DisconnectedTimeoutMs value: should not be negative")
+	}
+
+	if c.FailedTimeoutMs < 0 {
+		return fmt.Errorf("invalid FailedTimeoutMs value: should not be negative")
+	}
+
+	if c.KeepaliveIntervalMs < 0 {
+		return fmt.Errorf("invalid KeepaliveIntervalMs value: should not be negative")
+	}
+
+	if c.KeepaliveIntervalMs > 0 && c.DisconnectedTimeoutMs > 0 && c.KeepaliveIntervalMs >= c.DisconnectedTimeoutMs {
+		return fmt.Errorf("invalid KeepaliveIntervalMs value: should be less than DisconnectedTimeoutMs")
+	}
+
+	return nil
+}
+
+type DTLSCertificateConfig struct {
+	// Persist controls whether a single DTLS certificate should be kept in
+	// the store and used by all sessions, so that its fingerprint is stable
+	// across restarts. A new certificate is generated for every session
+	// otherwise.
+	Persist bool `toml:"persist"`
+	// RotationDays specifies after how many days the persisted certificate
+	// gets replaced with a new one.
+	RotationDays int `toml:"rotation_days"`
+}
+
+func (c DTLSCertificateConfig) IsValid() error {
+	if !c.Persist {
+		return nil
+	}
+
+	if c.RotationDays <= 0 {
+		return fmt.Errorf("invalid RotationDays value: should be a positive number")
+	}
+
+	return nil
+}
+
+type PublicIPDiscoveryConfig struct {
+	// STUNServers lists the URLs of the STUN servers (e.g.
+	// "stun:stun.example.com:3478") queried, in order, for the public IP
+	// address. The STUN servers in ICEServers are used if empty.
+	STUNServers []string `toml:"stun_servers"`
+	// RecheckIntervalSeconds specifies how often the public IP address is
+	// discovered again, so that changes get advertised to new sessions. Zero
+	// disables re-checks.
+	RecheckIntervalSeconds int `toml:"recheck_interval_seconds"`
+	// TimeoutSeconds specifies how long to wait for a STUN server to answer
+	// before trying the next one. Defaults to 5 seconds if zero.
+	TimeoutSeconds int `toml:"timeout_seconds"`
+}
+
+func (c PublicIPDiscoveryConfig) IsValid() error {
+	for _, u := range c.STUNServers {
+		if !strings.HasPrefix(u, "stun:") && !strings.HasPrefix(u, "stuns:") {
+			return fmt.Errorf("invalid STUNServers value: %q is not a STUN URL", u)
+		}
+	}
+
+	if c.RecheckIntervalSeconds < 0 {
+		return fmt.Errorf("invalid RecheckIntervalSeconds value: should not be negative")
+	}
+
+	if c.TimeoutSeconds < 0 {
+		return fmt.Errorf("invalid TimeoutSeconds value: should not be negative")
+	}
+
+	return nil
+}
+
+func (c PublicIPDiscoveryConfig) getTimeout() time.Duration {
+	if c.TimeoutSeconds == 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// getDiscoverySTUNServers returns the STUN servers to discover the public IP
+// address with.
+func (c ServerConfig) getDiscoverySTUNServers() []string {
+	if len(c.PublicIPDiscovery.STUNServers) > 0 {
+		return c.PublicIPDiscovery.STUNServers
+	}
+	return c.ICEServers.getSTUNs()
+}
+
+type ConnectivityCheckConfig struct {
+	// Enable controls whether the configured STUN/TURN servers should be
+	// periodically checked for reachability. TURN servers are also used to
+	// verify that the advertised host candidate is reachable from outside.
+	Enable bool `toml:"enable"`
+	// IntervalSeconds specifies how often the checks are run.
+	IntervalSeconds int `toml:"interval_seconds"`
+	// TimeoutSeconds specifies how long a single check can take before
+	// being considered failed.
+	TimeoutSeconds int `toml:"timeout_seconds"`
+}
+
+func (c ConnectivityCheckConfig) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+
+	if c.IntervalSeconds <= 0 {
+		return fmt.Errorf("invalid IntervalSeconds value: should be a positive number")
+	}
+
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("invalid TimeoutSeconds value: should be a positive number")
+	}
+
+	if c.TimeoutSeconds >= c.IntervalSeconds {
+		return fmt.Errorf("invalid TimeoutSeconds value: should be less than IntervalSeconds")
+	}
+
+	return nil
+}
+
+type CaptureConfig struct {
+	// Dir specifies the directory capture files are written to. Captures
+	// are disabled if left empty.
+	Dir string `toml:"dir"`
+	// MaxSizeMB specifies the size, in megabytes, after which a capture
+	// gets stopped.
+	MaxSizeMB int `toml:"max_size_mb"`
+	// MaxDurationSeconds specifies the duration after which a capture gets
+	// stopped.
+	MaxDurationSeconds int `toml:"max_duration_seconds"`
+	// IncludePayload controls whether media payloads should be written.
+	// Only RTP headers are captured otherwise.
+	IncludePayload bool `toml:"include_payload"`
+}
+
+func (c CaptureConfig) IsValid() error {
+	if c.Dir == "" {
+		return nil
+	}
+
+	if c.MaxSizeMB <= 0 {
+		return fmt.Errorf("invalid MaxSizeMB value: should be a positive number")
+	}
+
+	if c.MaxDurationSeconds <= 0 {
+		return fmt.Errorf("invalid MaxDurationSeconds value: should be a positive number")
+	}
+
+	return nil
+}
+
+type RecordingConfig struct {
+	// Dir specifies the base directory recordings are written to. Each
+	// recording can target a subdirectory of it. Recordings are disabled if
+	// left empty.
+	Dir string `toml:"dir"`
+	// MaxDurationSeconds specifies the duration after which a recording gets
+	// stopped.
+	MaxDurationSeconds int `toml:"max_duration_seconds"`
+	// PostProcessCommand optionally specifies the path of an executable run
+	// once a recording completes. It's passed the path of the recording
+	// manifest as its only argument.
+	PostProcessCommand string `toml:"post_process_command"`
+	// PostProcessURL optionally specifies an HTTP endpoint the manifest of
+	// a completed recording is posted to.
+	PostProcessURL string `toml:"post_process_url"`
+	// PostProcessTimeoutSeconds specifies how long post-processing hooks
+	// can take before being canceled.
+	PostProcessTimeoutSeconds int `toml:"post_process_timeout_seconds"`
+}
+
+func (c RecordingConfig) IsValid() error {
+	if c.Dir == "" {
+		return nil
+	}
+
+	if c.MaxDurationSeconds <= 0 {
+		return fmt.Errorf("invalid MaxDurationSeconds value: should be a positive number")
+	}
+
+	if c.PostProcessURL != "" {
+		u, err := url.Parse(c.PostProcessURL)
+		if err != nil {
+			return fmt.Errorf("invalid PostProcessURL value: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid PostProcessURL value: should use the http or https scheme")
+		}
+		if u.Host == "" {
+			return fmt.Errorf("invalid PostProcessURL value: should contain a host")
+		}
+	}
+
+	if (c.PostProcessCommand != "" || c.PostProcessURL != "") && c.PostProcessTimeoutSeconds <= 0 {
+		return fmt.Errorf("invalid PostProcessTimeoutSeconds value: should be a positive number")
+	}
+
+	return nil
+}
+
+type HLSConfig struct {
+	// Enable controls whether calls can be broadcast as LL-HLS streams
+	// served by the API server.
+	Enable bool `toml:"enable"`
+	// Dir optionally specifies a directory streams are also written to so
+	// that they can be served by an external HTTP server.
+	Dir string `toml:"dir"`
+	// PartDurationMs specifies the target duration, in milliseconds, of
+	// partial segments.
+	PartDurationMs int `toml:"part_duration_ms"`
+	// SegmentDurationMs specifies the target duration, in milliseconds, of
+	// segments.
+	SegmentDurationMs int `toml:"segment_duration_ms"`
+	// PlaylistSegments specifies how many segments are kept in playlists.
+	PlaylistSegments int `toml:"playlist_segments"`
+}
+
+func (c HLSConfig) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+
+	if c.PartDurationMs < 100 {
+		return fmt.Errorf("invalid PartDurationMs value: should be at least 100")
+	}
+
+	if c.SegmentDurationMs < c.PartDurationMs {
+		return fmt.Errorf("invalid SegmentDurationMs value: should not be less than PartDurationMs")
+	}
+
+	if c.PlaylistSegments < 3 {
+		return fmt.Errorf("invalid PlaylistSegments value: should be at least 3")
+	}
+
+	return nil
+}
+
+type AnnouncementsConfig struct {
+	// Enable controls whether announcements can be played into calls.
+	Enable bool `toml:"enable"`
+	// Dir specifies the directory holding the Ogg/Opus announcement files.
+	// Translations go in per-locale subdirectories (e.g. "fr/busy.ogg").
+	Dir string `toml:"dir"`
+	// DefaultLocale specifies the locale played to the sessions that didn't
+	// set one, or whose locale has no translation of an announcement.
+	DefaultLocale string `toml:"default_locale"`
+	// MaxQueueSize specifies the maximum number of announcements waiting to
+	// be played to a single session.
+	MaxQueueSize int `toml:"max_queue_size"`
+}
+
+func (c AnnouncementsConfig) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+
+	if c.Dir == "" {
+		return fmt.Errorf("invalid Dir value: should not be empty")
+	}
+
+	if c.DefaultLocale != "" && !localeRE.MatchString(c.DefaultLocale) {
+		return fmt.Errorf("invalid DefaultLocale value: %q is not a valid locale", c.DefaultLocale)
+	}
+
+	if c.MaxQueueSize <= 0 {
+		return fmt.Errorf("invalid MaxQueueSize value: should be a positive number")
+	}
+
+	return nil
+}
+
+type KeyExportConfig struct {
+	// Enable controls whether the SRTP keys of calls can be exported, for
+	// deployments subject to lawful recording requirements. It weakens the
+	// end-to-server encryption of the media and is disabled by default.
+	Enable bool `toml:"enable"`
+	// RecorderURL specifies the HTTPS endpoint of the authorized recorder
+	// the keys are posted to.
+	RecorderURL string `toml:"recorder_url"`
+	// RecorderAuthToken optionally specifies the bearer token rtcd
+	// authenticates to the recorder with.
+	RecorderAuthToken string `toml:"recorder_auth_token"`
+	// TimeoutSeconds specifies how long posting the keys to the recorder
+	// can take.
+	TimeoutSeconds int `toml:"timeout_seconds"`
+}
+
+func (c KeyExportConfig) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+
+	u, err := url.Parse(c.RecorderURL)
+	if err != nil {
+		return fmt.Errorf("invalid RecorderURL value: %w", err)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("invalid RecorderURL value: should use the https scheme")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid RecorderURL value: should contain a host")
+	}
+
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("invalid TimeoutSeconds value: should be a positive number")
+	}
+
+	return nil
+}
+
+type RTXConfig struct {
+	// Enable controls whether video retransmissions (RFC 4588) should be
+	// negotiated on a dedicated stream.
+	Enable bool `toml:"enable"`
+	// PayloadType specifies the payload type of the RTX stream. It's
+	// associated (apt) to the VP8 payload type.
+	PayloadType int `toml:"payload_type"`
+}
+
+func (c RTXConfig) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+
+	if c.PayloadType < 96 || c.PayloadType > 127 {
+		return fmt.Errorf("invalid PayloadType value: %d is not in the dynamic range [96, 127]", c.PayloadType)
+	}
+
+	if c.PayloadType == rtpVideoCodecVP8PayloadType || c.PayloadType == rtpAudioCodecPayloadType {
+		return fmt.Errorf("invalid PayloadType value: %d is already in use", c.PayloadType)
+	}
+
+	return nil
+}
+
+type TranscriptionConfig struct {
+	// URL specifies the WebSocket endpoint of the external transcription
+	// service. Transcription is disabled if left empty.
+	URL string `toml:"url"`
+	// AuthToken specifies the token used to authenticate against the
+	// transcription service.
+	AuthToken string `toml:"auth_token"`
+}
+
+func (c TranscriptionConfig) IsValid() error {
+	if c.URL == "" {
+		return nil
+	}
+
+	if !strings.HasPrefix(c.URL, "ws://") && !strings.HasPrefix(c.URL, "wss://") {
+		return fmt.Errorf(`invalid URL value: should start with "ws://" or "wss://"`)
+	}
+
+	return nil
+}
+
+// LocalUDPPortsConfig holds the range of local ports the UDP sockets opened
+// for outbound traffic (connectivity checks, public IP discovery, server
+// reflexive and relay candidates) are bound to. Ports are picked by the OS
+// if both values are zero.
+type LocalUDPPortsConfig struct {
+	// Min is the lowest port of the range.
+	Min int `toml:"min"`
+	// Max is the highest port of the range.
+	Max int `toml:"max"`
+}
+
+func (c LocalUDPPortsConfig) IsValid() error {
+	if !c.isEnabled() {
+		return nil
+	}
+	if c.Min < 1 || c.Min > 65535 {
+		return fmt.Errorf("invalid Min value: should be in range [1, 65535]")
+	}
+	if c.Max < c.Min || c.Max > 65535 {
+		return fmt.Errorf("invalid Max value: should be in range [Min, 65535]")
+	}
+	return nil
+}
+
+func (c LocalUDPPortsConfig) isEnabled() bool {
+	return c.Min != 0 || c.Max != 0
+}
+
+func (c LocalUDPPortsConfig) contains(port int) bool {
+	return c.isEnabled() && port >= c.Min && port <= c.Max
+}
+
+type UDPSocketsConfig struct {
+	// EnableScaling controls whether the number of UDP sockets should be
+	// adjusted at runtime based on the observed packet rate. If disabled, one
+	// socket per available CPU is created at startup.
+	EnableScaling bool `toml:"enable_scaling"`
+	// MinCount specifies the minimum number of UDP sockets to keep open.
+	MinCount int `toml:"min_count"`
+	// MaxCount specifies the maximum number of UDP sockets that can be open.
+	// Defaults to the number of available CPUs if zero.
+	MaxCount int `toml:"max_count"`
+	// PacketRatePerSocket specifies the number of received packets per second
+	// a single socket is expected to handle before a new one is added.
+	PacketRatePerSocket int `toml:"packet_rate_per_socket"`
+	// ReadBufferSize specifies the size, in bytes, of the receive buffer
+	// (SO_RCVBUF) of each socket. The kernel default is kept if zero.
+	ReadBufferSize int `toml:"read_buffer_size"`
+	// WriteBufferSize specifies the size, in bytes, of the send buffer
+	// (SO_SNDBUF) of each socket. The kernel default is kept if zero.
+	WriteBufferSize int `toml:"write_buffer_size"`
+	// WriteMode controls how writes are distributed among the sockets. Can be
+	// "round_robin", "pinned", always using the same socket for a given
+	// destination, or "adaptive", steering writes away from the sockets whose
+	// writes are slow or failing.
+	WriteMode string `toml:"write_mode"`
+	// ReadShards specifies the number of goroutines demultiplexing the
+	// received packets, each handling the packets of a shard of the sessions
+	// so that the packets of a session are always processed by the same
+	// goroutine. Disabled if zero or one.
+	ReadShards int `toml:"read_shards"`
+	// NUMANode specifies the NUMA node the socket readers should be pinned
+	// to, so that received packets are handled by the CPUs close to the
+	// NIC. Can be a node number or "auto" to use the node owning the NIC
+	// ICEAddressUDP is bound to. Disabled if empty. Only supported on Linux.
+	NUMANode string `toml:"numa_node"`
+	// StallTimeoutSeconds specifies after how many seconds without receiving
+	// packets, while the other sockets do, a socket that received packets
+	// before is considered stalled and rebound. Sockets whose reader stopped
+	// on an error are rebound as well. Zero disables it. Only supported
+	// where sockets can share an address.
+	StallTimeoutSeconds int `toml:"stall_timeout_seconds"`
+	// ReceiveMTU specifies the size, in bytes, of the buffers received
+	// packets are read into. Larger packets get truncated. Defaults to 8192,
+	// which is also the maximum, and to the WebRTC stack default for the
+	// reads of the tracks, if zero.
+	ReceiveMTU int `toml:"receive_mtu"`
+	// SendQueueSize specifies the number of packets queued per call on the
+	// send path. Writes are then scheduled fairly between calls (deficit
+	// round robin) so that a call sending bursts doesn't delay the packets
+	// of the others while the sockets can't keep up, packets sent while the
+	// queue of their call is full being dropped. Packets are written right
+	// away if zero.
+	SendQueueSize int `toml:"send_queue_size"`
+}
+
+func (c UDPSocketsConfig) IsValid() error {
+	if c.MinCount < 0 {
+		return fmt.Errorf("invalid MinCount value: should not be negative")
+	}
+
+	if c.MaxCount < 0 {
+		return fmt.Errorf("invalid MaxCount value: should not be negative")
+	}
+
+	// MaxCount defaults to the number of available CPUs.
+	maxCount := c.MaxCount
+	if maxCount == 0 {
+		maxCount = runtime.NumCPU()
+	}
+	if c.MinCount > maxCount {
+		return fmt.Errorf("invalid MinCount value: should not be greater than MaxCount (%d)", maxCount)
+	}
+
+	if c.EnableScaling && c.PacketRatePerSocket <= 0 {
+		return fmt.Errorf("invalid PacketRatePerSocket value: should be a positive number")
+	}
+
+	if c.ReadBufferSize < 0 {
+		return fmt.Errorf("invalid ReadBufferSize value: should not be negative")
+	}
+
+	if c.WriteBufferSize < 0 {
+		return fmt.Errorf("invalid WriteBufferSize value: should not be negative")
+	}
+
+	if c.ReadShards < 0 || c.ReadShards > maxUDPReadShards {
+		return fmt.Errorf("invalid ReadShards value: should be in the range [0, %d]", maxUDPReadShards)
+	}
+
+	switch c.WriteMode {
+	case "", UDPWriteModeRoundRobin, UDPWriteModePinned, UDPWriteModeAdaptive:
+	default:
+		return fmt.Errorf("invalid WriteMode value: should be one of %q, %q or %q",
+			UDPWriteModeRoundRobin, UDPWriteModePinned, UDPWriteModeAdaptive)
+	}
+
+	if c.ReceiveMTU != 0 && (c.ReceiveMTU < minReceiveMTU || c.ReceiveMTU > receiveMTU) {
+		return fmt.Errorf("invalid ReceiveMTU value: should be zero or in the range [%d, %d]", minReceiveMTU, receiveMTU)
+	}
+
+	if c.StallTimeoutSeconds < 0 {
+		return fmt.Errorf("invalid StallTimeoutSeconds value: should not be negative")
+	}
+
+	if c.SendQueueSize < 0 {
+		return fmt.Errorf("invalid SendQueueSize value: should not be negative")
+	}
+
+	if c.NUMANode != "" && c.NUMANode != NUMANodeAuto {
+		if node, err := strconv.Atoi(c.NUMANode); err != nil || node < 0 {
+			return fmt.Errorf("invalid NUMANode value: should be a non-negative number or %q", NUMANodeAuto)
+		}
+	}
+
+	return nil
+}
+
+// getReceiveMTU returns the size of the read buffers.
+func (c UDPSocketsConfig) getReceiveMTU() int {
+	if c.ReceiveMTU <= 0 {
+		return receiveMTU
+	}
+	return c.ReceiveMTU
+}
+
+// getMinCount returns the minimum number of sockets, never less than one.
+func (c UDPSocketsConfig) getMinCount() int {
+	if c.MinCount <= 0 || !reusePortSupported {
+		return 1
+	}
+	return c.MinCount
+}
+
+// getMaxCount returns the maximum number of sockets, defaulting to the number
+// of available CPUs. It's always one on platforms where sockets can't share
+// an address.
+func (c UDPSocketsConfig) getMaxCount() int {
+	if !reusePortSupported {
+		return 1
+	}
+	if c.MaxCount <= 0 {
+		return runtime.NumCPU()
+	}
+	return c.MaxCount
 }
 
 func (c ServerConfig) IsValid() error {
@@ -40,9 +964,145 @@ func (c ServerConfig) IsValid() error {
 		return fmt.Errorf("invalid TURNConfig: %w", err)
 	}
 
+	if c.RelayOnly && !c.hasUsableTURNServer() {
+		return fmt.Errorf("invalid RelayOnly value: no usable TURN server is configured")
+	}
+
+	if err := c.UDPSockets.IsValid(); err != nil {
+		return fmt.Errorf("invalid UDPSockets config: %w", err)
+	}
+
+	if err := c.LocalUDPPorts.IsValid(); err != nil {
+		return fmt.Errorf("invalid LocalUDPPorts config: %w", err)
+	}
+
+	if c.LocalUDPPorts.contains(c.ICEPortUDP) {
+		return fmt.Errorf("invalid LocalUDPPorts config: should not include ICEPortUDP")
+	}
+
+	// Calls are placed on an interface by advertising the IP addresses of
+	// its own.
+	if len(c.Capacity.InterfaceBudgets) > 0 && c.ICEAddressUDP != "" {
+		return fmt.Errorf("invalid Capacity config: InterfaceBudgets requires ICEAddressUDP to be unset")
+	}
+	if len(c.Capacity.InterfaceBudgets) > 0 && c.ICEHostOverride != "" {
+		return fmt.Errorf("invalid Capacity config: InterfaceBudgets requires ICEHostOverride to be unset")
+	}
+
+	if c.UDPSockets.NUMANode == NUMANodeAuto && c.ICEAddressUDP == "" {
+		return fmt.Errorf("invalid UDPSockets config: invalid NUMANode value: %q requires ICEAddressUDP to be set", NUMANodeAuto)
+	}
+
+	if err := c.Transcription.IsValid(); err != nil {
+		return fmt.Errorf("invalid Transcription config: %w", err)
+	}
+
+	if c.IdleCallTimeoutMinutes < 0 {
+		return fmt.Errorf("invalid IdleCallTimeoutMinutes value: should not be negative")
+	}
+
+	if c.MaxCallDurationMinutes < 0 {
+		return fmt.Errorf("invalid MaxCallDurationMinutes value: should not be negative")
+	}
+
+	if c.MaxCallParticipants < 0 {
+		return fmt.Errorf("invalid MaxCallParticipants value: should not be negative")
+	}
+
+	if c.TrackInactivityTimeoutMs < 0 {
+		return fmt.Errorf("invalid TrackInactivityTimeoutMs value: should not be negative")
+	}
+
+	if err := c.RTX.IsValid(); err != nil {
+		return fmt.Errorf("invalid RTX config: %w", err)
+	}
+
+	if err := c.Capture.IsValid(); err != nil {
+		return fmt.Errorf("invalid Capture config: %w", err)
+	}
+
+	if err := c.Recording.IsValid(); err != nil {
+		return fmt.Errorf("invalid Recording config: %w", err)
+	}
+
+	if err := c.HLS.IsValid(); err != nil {
+		return fmt.Errorf("invalid HLS config: %w", err)
+	}
+
+	if err := c.Announcements.IsValid(); err != nil {
+		return fmt.Errorf("invalid Announcements config: %w", err)
+	}
+
+	if err := c.KeyExport.IsValid(); err != nil {
+		return fmt.Errorf("invalid KeyExport config: %w", err)
+	}
+
+	if err := c.PublicIPDiscovery.IsValid(); err != nil {
+		return fmt.Errorf("invalid PublicIPDiscovery config: %w", err)
+	}
+
+	if err := c.ConnectivityCheck.IsValid(); err != nil {
+		return fmt.Errorf("invalid ConnectivityCheck config: %w", err)
+	}
+
+	if _, err := parseSRTPProtectionProfiles(c.SRTPProtectionProfiles); err != nil {
+		return fmt.Errorf("invalid SRTPProtectionProfiles value: %w", err)
+	}
+
+	if _, err := parseRTPHeaderExtensions(c.RTPHeaderExtensions); err != nil {
+		return fmt.Errorf("invalid RTPHeaderExtensions value: %w", err)
+	}
+
+	if err := c.JitterBuffer.IsValid(); err != nil {
+		return fmt.Errorf("invalid JitterBuffer config: %w", err)
+	}
+
+	if err := c.KeyFrameCache.IsValid(); err != nil {
+		return fmt.Errorf("invalid KeyFrameCache config: %w", err)
+	}
+
+	if err := c.EgressShaping.IsValid(); err != nil {
+		return fmt.Errorf("invalid EgressShaping config: %w", err)
+	}
+
+	if err := c.Capacity.IsValid(); err != nil {
+		return fmt.Errorf("invalid Capacity config: %w", err)
+	}
+
+	if err := c.DTLSCertificate.IsValid(); err != nil {
+		return fmt.Errorf("invalid DTLSCertificate config: %w", err)
+	}
+
+	if err := c.ICETimeouts.IsValid(); err != nil {
+		return fmt.Errorf("invalid ICETimeouts config: %w", err)
+	}
+
+	if err := c.MDNSCandidates.IsValid(); err != nil {
+		return fmt.Errorf("invalid MDNSCandidates config: %w", err)
+	}
+
+	if err := c.ICECandidates.IsValid(); err != nil {
+		return fmt.Errorf("invalid ICECandidates config: %w", err)
+	}
+
+	if err := c.Chaos.IsValid(); err != nil {
+		return fmt.Errorf("invalid Chaos config: %w", err)
+	}
+
+	switch c.ReceiverReportAggregation {
+	case "", ReceiverReportAggregationNone, ReceiverReportAggregationWorst, ReceiverReportAggregationMedian:
+	default:
+		return fmt.Errorf("invalid ReceiverReportAggregation value: should be one of %q, %q or %q",
+			ReceiverReportAggregationNone, ReceiverReportAggregationWorst, ReceiverReportAggregationMedian)
+	}
+
 	return nil
 }
 
+func (c ServerConfig) isReceiverReportAggregationEnabled() bool {
+	return c.ReceiverReportAggregation != "" && c.ReceiverReportAggregation != ReceiverReportAggregationNone
+}
+
 type SessionConfig struct {
 	// GroupID specifies the id of the group the session should belong to.
 	GroupID string
@@ -52,6 +1112,27 @@ type SessionConfig struct {
 	UserID string
 	// SessionID specifies the unique identifier for the session.
 	SessionID string
+	// Hidden marks a receive-only session (e.g. a recorder) which is left
+	// out of the call state, session events and participant limit. Tracks
+	// sent by hidden sessions are ignored.
+	Hidden bool
+	// Observer marks a hidden session joined on behalf of an admin to watch
+	// the call (e.g. for live QA), as opposed to one joined by a client.
+	Observer bool
+	// AudioOnly makes the call reject any video track (e.g. screen sharing).
+	// It only applies to the session starting the call, the following ones
+	// inherit the setting of the call.
+	AudioOnly bool
+	// Locale optionally specifies the locale (e.g. "pt-BR") announcements
+	// are played to the session in.
+	Locale string
+	// TraceID identifies the session across services (e.g. in the logs of
+	// the plugin and rtcd). A new one is generated when the session is
+	// initialized if not set.
+	TraceID string
+	// ICEPolicy restricts the data path of the call. Like AudioOnly, it only
+	// applies to the session starting the call.
+	ICEPolicy ICEPolicy
 }
 
 func (c SessionConfig) IsValid() error {
@@ -71,6 +1152,22 @@ func (c SessionConfig) IsValid() error {
 		return fmt.Errorf("invalid SessionID value: should not be empty")
 	}
 
+	if c.Locale != "" && !localeRE.MatchString(c.Locale) {
+		return fmt.Errorf("invalid Locale value: %q is not a valid locale", c.Locale)
+	}
+
+	if c.TraceID != "" && !traceIDRE.MatchString(c.TraceID) {
+		return fmt.Errorf("invalid TraceID value: should be at most %d alphanumeric, '-' or '_' characters", maxTraceIDLength)
+	}
+
+	if c.Observer && !c.Hidden {
+		return fmt.Errorf("invalid Observer value: observer sessions should be hidden")
+	}
+
+	if err := c.ICEPolicy.IsValid(); err != nil {
+		return fmt.Errorf("invalid ICEPolicy: %w", err)
+	}
+
 	return nil
 }
 
@@ -78,6 +1175,9 @@ type ICEServerConfig struct {
 	URLs       []string `toml:"urls" json:"urls"`
 	Username   string   `toml:"username,omitempty" json:"username,omitempty"`
 	Credential string   `toml:"credential,omitempty" json:"credential,omitempty"`
+	// Region optionally tags a TURN server so that calls can be restricted
+	// to the servers of a given region through their ICE policy.
+	Region string `toml:"region,omitempty" json:"region,omitempty"`
 }
 
 type ICEServers []ICEServerConfig
@@ -94,6 +1194,19 @@ func (c ICEServerConfig) IsValid() error {
 		if !c.IsSTUN() && !c.IsTURN() {
 			return fmt.Errorf("URL is not a valid STUN/TURN server")
 		}
+
+		// Also checks the transport, e.g. "turns:host:443?transport=tcp".
+		if _, err := ice.ParseURL(u); err != nil {
+			return fmt.Errorf("invalid URL %q: %w", u, err)
+		}
+	}
+	if c.Region != "" {
+		if !c.IsTURN() {
+			return fmt.Errorf("invalid Region: only TURN servers have a region")
+		}
+		if !regionRE.MatchString(c.Region) {
+			return fmt.Errorf("invalid Region: should be at most 64 alphanumeric, '-' or '_' characters")
+		}
 	}
 	return nil
 }
@@ -125,6 +1238,17 @@ func (s ICEServers) IsValid() error {
 	return nil
 }
 
+// hasUsableTURNServer returns whether a TURN server can be used by the
+// sessions, either with static credentials or with generated ones.
+func (c ServerConfig) hasUsableTURNServer() bool {
+	for _, iceCfg := range c.ICEServers {
+		if iceCfg.IsTURN() && (c.TURNConfig.StaticAuthSecret != "" || iceCfg.Username != "" || iceCfg.Credential != "") {
+			return true
+		}
+	}
+	return false
+}
+
 func (s ICEServers) getSTUN() string {
 	for _, cfg := range s {
 		if cfg.IsSTUN() {
@@ -134,6 +1258,19 @@ func (s ICEServers) getSTUN() string {
 	return ""
 }
 
+// getSTUNs returns the URLs of all the STUN servers, in order.
+func (s ICEServers) getSTUNs() []string {
+	var urls []string
+	for _, cfg := range s {
+		for _, u := range cfg.URLs {
+			if strings.HasPrefix(u, "stun:") || strings.HasPrefix(u, "stuns:") {
+				urls = append(urls, u)
+			}
+		}
+	}
+	return urls
+}
+
 func (s *ICEServers) Decode(value string) error {
 	fmt.Println(value)
 
@@ -174,6 +1311,7 @@ func (s *ICEServers) UnmarshalTOML(data interface{}) error {
 			}
 			server.Username, _ = m["username"].(string)
 			server.Credential, _ = m["credential"].(string)
+			server.Region, _ = m["region"].(string)
 		default:
 			return fmt.Errorf("unknown type %T", t)
 		}
@@ -4,7 +4,11 @@
 package rtc
 
 import (
+	"fmt"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -41,25 +45,970 @@ func TestServerConfigIsValid(t *testing.T) {
 		require.Equal(t, "invalid ICEPortUDP value: 65000 is not in allowed range [80, 49151]", err.Error())
 	})
 
+	t.Run("relay only", func(t *testing.T) {
+		var cfg ServerConfig
+		cfg.ICEPortUDP = 8443
+		cfg.RelayOnly = true
+		cfg.ICEServers = ICEServers{
+			ICEServerConfig{URLs: []string{"stun:localhost:3478"}},
+			ICEServerConfig{URLs: []string{"turn:localhost:3478"}},
+		}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid RelayOnly value: no usable TURN server is configured", err.Error())
+
+		cfg.ICEServers[1].Username = "username"
+		cfg.ICEServers[1].Credential = "password"
+		require.NoError(t, cfg.IsValid())
+
+		cfg.ICEServers[1].Username = ""
+		cfg.ICEServers[1].Credential = ""
+		cfg.TURNConfig.StaticAuthSecret = "secret"
+		cfg.TURNConfig.CredentialsExpirationMinutes = 1440
+		require.NoError(t, cfg.IsValid())
+	})
+
 	t.Run("invalid TURNCredentialsExpirationMinutes", func(t *testing.T) {
 		var cfg ServerConfig
 		cfg.ICEPortUDP = 8443
 		cfg.TURNConfig.StaticAuthSecret = "secret"
 		err := cfg.IsValid()
 		require.Error(t, err)
-		require.Equal(t, "invalid TURNConfig: invalid CredentialsExpirationMinutes value: should be a positive number", err.Error())
+		require.Equal(t, "invalid TURNConfig: invalid CredentialsExpirationMinutes value: should be a positive number", err.Error())
+
+		cfg.TURNConfig.CredentialsExpirationMinutes = 20000
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TURNConfig: invalid CredentialsExpirationMinutes value: should be less than 1 week", err.Error())
+	})
+
+	t.Run("NUMANode auto without ICEAddressUDP", func(t *testing.T) {
+		var cfg ServerConfig
+		cfg.ICEPortUDP = 8443
+		cfg.UDPSockets.NUMANode = NUMANodeAuto
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid UDPSockets config: invalid NUMANode value: "auto" requires ICEAddressUDP to be set`, err.Error())
+	})
+
+	t.Run("LocalUDPPorts including ICEPortUDP", func(t *testing.T) {
+		var cfg ServerConfig
+		cfg.ICEPortUDP = 8443
+		cfg.LocalUDPPorts = LocalUDPPortsConfig{Min: 8000, Max: 8999}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid LocalUDPPorts config: should not include ICEPortUDP", err.Error())
+
+		cfg.LocalUDPPorts = LocalUDPPortsConfig{Min: 50000, Max: 50999}
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("InterfaceBudgets with fixed ICE address", func(t *testing.T) {
+		var cfg ServerConfig
+		cfg.ICEPortUDP = 8443
+		cfg.Capacity.InterfaceBudgets = InterfaceBudgets{{Interface: "eth0", MaxBandwidthMbps: 1000}}
+		require.NoError(t, cfg.IsValid())
+
+		cfg.ICEAddressUDP = "127.0.0.1"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Capacity config: InterfaceBudgets requires ICEAddressUDP to be unset", err.Error())
+
+		cfg.ICEAddressUDP = ""
+		cfg.ICEHostOverride = "example.com"
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Capacity config: InterfaceBudgets requires ICEHostOverride to be unset", err.Error())
+	})
+
+	t.Run("invalid IdleCallTimeoutMinutes", func(t *testing.T) {
+		var cfg ServerConfig
+		cfg.ICEPortUDP = 8443
+		cfg.IdleCallTimeoutMinutes = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid IdleCallTimeoutMinutes value: should not be negative", err.Error())
+	})
+
+	t.Run("invalid call limits", func(t *testing.T) {
+		var cfg ServerConfig
+		cfg.ICEPortUDP = 8443
+		cfg.MaxCallDurationMinutes = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxCallDurationMinutes value: should not be negative", err.Error())
+
+		cfg.MaxCallDurationMinutes = 0
+		cfg.MaxCallParticipants = -1
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxCallParticipants value: should not be negative", err.Error())
+
+		cfg.MaxCallParticipants = 0
+		cfg.TrackInactivityTimeoutMs = -1
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TrackInactivityTimeoutMs value: should not be negative", err.Error())
+
+		cfg.TrackInactivityTimeoutMs = 0
+		cfg.ReceiverReportAggregation = "best"
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid ReceiverReportAggregation value: should be one of "none", "worst" or "median"`, err.Error())
+
+		cfg.ReceiverReportAggregation = ""
+		cfg.SRTPProtectionProfiles = []string{"AES_256_CM"}
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid SRTPProtectionProfiles value: unknown profile "AES_256_CM": should be one of "AEAD_AES_128_GCM" or "AES_128_CM_SHA1_80"`, err.Error())
+
+		cfg.SRTPProtectionProfiles = nil
+		cfg.RTPHeaderExtensions = []string{"color-space"}
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid RTPHeaderExtensions value: unknown extension "color-space"`, err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg ServerConfig
+		cfg.ICEAddressUDP = "127.0.0.1"
+		cfg.ICEPortUDP = 8443
+		cfg.TURNConfig.CredentialsExpirationMinutes = 1440
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestUDPSocketsConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg UDPSocketsConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+		require.Equal(t, 1, cfg.getMinCount())
+		require.Equal(t, runtime.NumCPU(), cfg.getMaxCount())
+	})
+
+	t.Run("invalid MinCount", func(t *testing.T) {
+		var cfg UDPSocketsConfig
+		cfg.MinCount = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MinCount value: should not be negative", err.Error())
+
+		cfg.MinCount = 4
+		cfg.MaxCount = 2
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MinCount value: should not be greater than MaxCount (2)", err.Error())
+
+		// MaxCount defaults to the number of available CPUs.
+		cfg.MinCount = runtime.NumCPU() + 1
+		cfg.MaxCount = 0
+		err = cfg.IsValid()
+		require.EqualError(t, err, fmt.Sprintf("invalid MinCount value: should not be greater than MaxCount (%d)", runtime.NumCPU()))
+	})
+
+	t.Run("invalid MaxCount", func(t *testing.T) {
+		var cfg UDPSocketsConfig
+		cfg.MaxCount = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxCount value: should not be negative", err.Error())
+	})
+
+	t.Run("invalid PacketRatePerSocket", func(t *testing.T) {
+		var cfg UDPSocketsConfig
+		cfg.EnableScaling = true
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid PacketRatePerSocket value: should be a positive number", err.Error())
+	})
+
+	t.Run("invalid buffer sizes", func(t *testing.T) {
+		var cfg UDPSocketsConfig
+		cfg.ReadBufferSize = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid ReadBufferSize value: should not be negative", err.Error())
+
+		cfg.ReadBufferSize = 1024
+		cfg.WriteBufferSize = -1
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid WriteBufferSize value: should not be negative", err.Error())
+	})
+
+	t.Run("invalid ReadShards", func(t *testing.T) {
+		var cfg UDPSocketsConfig
+		cfg.ReadShards = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid ReadShards value: should be in the range [0, 64]", err.Error())
+
+		cfg.ReadShards = 65
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid ReadShards value: should be in the range [0, 64]", err.Error())
+	})
+
+	t.Run("invalid WriteMode", func(t *testing.T) {
+		var cfg UDPSocketsConfig
+		cfg.WriteMode = "random"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid WriteMode value: should be one of "round_robin", "pinned" or "adaptive"`, err.Error())
+	})
+
+	t.Run("invalid ReceiveMTU", func(t *testing.T) {
+		var cfg UDPSocketsConfig
+		cfg.ReceiveMTU = 1000
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid ReceiveMTU value: should be zero or in the range [1280, 8192]", err.Error())
+		cfg.ReceiveMTU = 9000
+		err = cfg.IsValid()
+		require.Error(t, err)
+	})
+
+	t.Run("invalid StallTimeoutSeconds", func(t *testing.T) {
+		var cfg UDPSocketsConfig
+		cfg.StallTimeoutSeconds = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid StallTimeoutSeconds value: should not be negative", err.Error())
+	})
+
+	t.Run("invalid SendQueueSize", func(t *testing.T) {
+		var cfg UDPSocketsConfig
+		cfg.SendQueueSize = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid SendQueueSize value: should not be negative", err.Error())
+	})
+
+	t.Run("invalid NUMANode", func(t *testing.T) {
+		var cfg UDPSocketsConfig
+		cfg.NUMANode = "-1"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid NUMANode value: should be a non-negative number or "auto"`, err.Error())
+		cfg.NUMANode = "first"
+		err = cfg.IsValid()
+		require.Error(t, err)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg UDPSocketsConfig
+		cfg.EnableScaling = true
+		cfg.MinCount = 2
+		cfg.MaxCount = 4
+		cfg.PacketRatePerSocket = 1000
+		cfg.ReadBufferSize = 1024 * 1024
+		cfg.WriteBufferSize = 1024 * 1024
+		cfg.WriteMode = UDPWriteModePinned
+		err := cfg.IsValid()
+		require.NoError(t, err)
+		cfg.WriteMode = UDPWriteModeAdaptive
+		err = cfg.IsValid()
+		require.NoError(t, err)
+		cfg.NUMANode = "1"
+		err = cfg.IsValid()
+		require.NoError(t, err)
+		cfg.NUMANode = NUMANodeAuto
+		err = cfg.IsValid()
+		require.NoError(t, err)
+		require.Equal(t, receiveMTU, cfg.getReceiveMTU())
+		cfg.ReceiveMTU = 1500
+		err = cfg.IsValid()
+		require.NoError(t, err)
+		require.Equal(t, 1500, cfg.getReceiveMTU())
+		require.Equal(t, 2, cfg.getMinCount())
+		require.Equal(t, 4, cfg.getMaxCount())
+	})
+}
+
+func TestLocalUDPPortsConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg LocalUDPPortsConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid Min", func(t *testing.T) {
+		cfg := LocalUDPPortsConfig{Max: 50999}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Min value: should be in range [1, 65535]", err.Error())
+	})
+
+	t.Run("invalid Max", func(t *testing.T) {
+		cfg := LocalUDPPortsConfig{Min: 50000, Max: 49999}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Max value: should be in range [Min, 65535]", err.Error())
+
+		cfg.Max = 65536
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Max value: should be in range [Min, 65535]", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := LocalUDPPortsConfig{Min: 50000, Max: 50000}
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestRTXConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg RTXConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("out of range", func(t *testing.T) {
+		var cfg RTXConfig
+		cfg.Enable = true
+		cfg.PayloadType = 128
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid PayloadType value: 128 is not in the dynamic range [96, 127]", err.Error())
+	})
+
+	t.Run("in use", func(t *testing.T) {
+		var cfg RTXConfig
+		cfg.Enable = true
+		cfg.PayloadType = 96
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid PayloadType value: 96 is already in use", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg RTXConfig
+		cfg.Enable = true
+		cfg.PayloadType = 97
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestCaptureConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg CaptureConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid MaxSizeMB", func(t *testing.T) {
+		var cfg CaptureConfig
+		cfg.Dir = "/tmp"
+		cfg.MaxDurationSeconds = 60
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxSizeMB value: should be a positive number", err.Error())
+	})
+
+	t.Run("invalid MaxDurationSeconds", func(t *testing.T) {
+		var cfg CaptureConfig
+		cfg.Dir = "/tmp"
+		cfg.MaxSizeMB = 100
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxDurationSeconds value: should be a positive number", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg CaptureConfig
+		cfg.Dir = "/tmp"
+		cfg.MaxSizeMB = 100
+		cfg.MaxDurationSeconds = 60
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestRecordingConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg RecordingConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid MaxDurationSeconds", func(t *testing.T) {
+		var cfg RecordingConfig
+		cfg.Dir = "/tmp"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxDurationSeconds value: should be a positive number", err.Error())
+	})
+
+	t.Run("invalid PostProcessURL", func(t *testing.T) {
+		var cfg RecordingConfig
+		cfg.Dir = "/tmp"
+		cfg.MaxDurationSeconds = 3600
+		cfg.PostProcessTimeoutSeconds = 60
+		cfg.PostProcessURL = "ftp://localhost"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid PostProcessURL value: should use the http or https scheme", err.Error())
+
+		cfg.PostProcessURL = "http://"
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid PostProcessURL value: should contain a host", err.Error())
+	})
+
+	t.Run("invalid PostProcessTimeoutSeconds", func(t *testing.T) {
+		var cfg RecordingConfig
+		cfg.Dir = "/tmp"
+		cfg.MaxDurationSeconds = 3600
+		cfg.PostProcessCommand = "/usr/bin/true"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid PostProcessTimeoutSeconds value: should be a positive number", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg RecordingConfig
+		cfg.Dir = "/tmp"
+		cfg.MaxDurationSeconds = 3600
+		err := cfg.IsValid()
+		require.NoError(t, err)
+
+		cfg.PostProcessCommand = "/usr/bin/true"
+		cfg.PostProcessURL = "https://localhost/recordings"
+		cfg.PostProcessTimeoutSeconds = 60
+		err = cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestHLSConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg HLSConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid PartDurationMs", func(t *testing.T) {
+		var cfg HLSConfig
+		cfg.Enable = true
+		cfg.PartDurationMs = 50
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid PartDurationMs value: should be at least 100", err.Error())
+	})
+
+	t.Run("invalid SegmentDurationMs", func(t *testing.T) {
+		var cfg HLSConfig
+		cfg.Enable = true
+		cfg.PartDurationMs = 500
+		cfg.SegmentDurationMs = 400
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid SegmentDurationMs value: should not be less than PartDurationMs", err.Error())
+	})
+
+	t.Run("invalid PlaylistSegments", func(t *testing.T) {
+		var cfg HLSConfig
+		cfg.Enable = true
+		cfg.PartDurationMs = 500
+		cfg.SegmentDurationMs = 2000
+		cfg.PlaylistSegments = 2
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid PlaylistSegments value: should be at least 3", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg HLSConfig
+		cfg.Enable = true
+		cfg.PartDurationMs = 500
+		cfg.SegmentDurationMs = 2000
+		cfg.PlaylistSegments = 6
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestAnnouncementsConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg AnnouncementsConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid Dir", func(t *testing.T) {
+		var cfg AnnouncementsConfig
+		cfg.Enable = true
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Dir value: should not be empty", err.Error())
+	})
+
+	t.Run("invalid DefaultLocale", func(t *testing.T) {
+		var cfg AnnouncementsConfig
+		cfg.Enable = true
+		cfg.Dir = "/tmp"
+		cfg.DefaultLocale = "en/../.."
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid DefaultLocale value: "en/../.." is not a valid locale`, err.Error())
+	})
+
+	t.Run("invalid MaxQueueSize", func(t *testing.T) {
+		var cfg AnnouncementsConfig
+		cfg.Enable = true
+		cfg.Dir = "/tmp"
+		cfg.DefaultLocale = "en"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxQueueSize value: should be a positive number", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg AnnouncementsConfig
+		cfg.Enable = true
+		cfg.Dir = "/tmp"
+		cfg.MaxQueueSize = 8
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestKeyExportConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg KeyExportConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid RecorderURL", func(t *testing.T) {
+		var cfg KeyExportConfig
+		cfg.Enable = true
+		cfg.TimeoutSeconds = 10
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid RecorderURL value: should use the https scheme", err.Error())
+
+		cfg.RecorderURL = "http://recorder.example.com/keys"
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid RecorderURL value: should use the https scheme", err.Error())
+
+		cfg.RecorderURL = "https:///keys"
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid RecorderURL value: should contain a host", err.Error())
+	})
+
+	t.Run("invalid TimeoutSeconds", func(t *testing.T) {
+		var cfg KeyExportConfig
+		cfg.Enable = true
+		cfg.RecorderURL = "https://recorder.example.com/keys"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TimeoutSeconds value: should be a positive number", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg KeyExportConfig
+		cfg.Enable = true
+		cfg.RecorderURL = "https://recorder.example.com/keys"
+		cfg.TimeoutSeconds = 10
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestConnectivityCheckConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg ConnectivityCheckConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid IntervalSeconds", func(t *testing.T) {
+		var cfg ConnectivityCheckConfig
+		cfg.Enable = true
+		cfg.TimeoutSeconds = 5
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid IntervalSeconds value: should be a positive number", err.Error())
+	})
+
+	t.Run("invalid TimeoutSeconds", func(t *testing.T) {
+		var cfg ConnectivityCheckConfig
+		cfg.Enable = true
+		cfg.IntervalSeconds = 60
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TimeoutSeconds value: should be a positive number", err.Error())
+
+		cfg.TimeoutSeconds = 60
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TimeoutSeconds value: should be less than IntervalSeconds", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg ConnectivityCheckConfig
+		cfg.Enable = true
+		cfg.IntervalSeconds = 60
+		cfg.TimeoutSeconds = 5
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestPublicIPDiscoveryConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg PublicIPDiscoveryConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid STUNServers", func(t *testing.T) {
+		var cfg PublicIPDiscoveryConfig
+		cfg.STUNServers = []string{"stun:localhost:3478", "turn:localhost:3478"}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid STUNServers value: "turn:localhost:3478" is not a STUN URL`, err.Error())
+	})
+
+	t.Run("invalid RecheckIntervalSeconds", func(t *testing.T) {
+		var cfg PublicIPDiscoveryConfig
+		cfg.RecheckIntervalSeconds = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid RecheckIntervalSeconds value: should not be negative", err.Error())
+	})
+
+	t.Run("invalid TimeoutSeconds", func(t *testing.T) {
+		var cfg PublicIPDiscoveryConfig
+		cfg.TimeoutSeconds = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TimeoutSeconds value: should not be negative", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg PublicIPDiscoveryConfig
+		cfg.STUNServers = []string{"stun:localhost:3478", "stuns:localhost:5349"}
+		cfg.RecheckIntervalSeconds = 300
+		cfg.TimeoutSeconds = 5
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestDTLSCertificateConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg DTLSCertificateConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid RotationDays", func(t *testing.T) {
+		var cfg DTLSCertificateConfig
+		cfg.Persist = true
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid RotationDays value: should be a positive number", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg DTLSCertificateConfig
+		cfg.Persist = true
+		cfg.RotationDays = 30
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestICETimeoutsConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg ICETimeoutsConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+
+		disconnected, failed, keepalive := cfg.getTimeouts()
+		require.Equal(t, 5*time.Second, disconnected)
+		require.Equal(t, 25*time.Second, failed)
+		require.Equal(t, 2*time.Second, keepalive)
+	})
+
+	t.Run("negative values", func(t *testing.T) {
+		cfg := ICETimeoutsConfig{DisconnectedTimeoutMs: -1}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid DisconnectedTimeoutMs value: should not be negative", err.Error())
+
+		cfg = ICETimeoutsConfig{FailedTimeoutMs: -1}
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid FailedTimeoutMs value: should not be negative", err.Error())
+
+		cfg = ICETimeoutsConfig{KeepaliveIntervalMs: -1}
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid KeepaliveIntervalMs value: should not be negative", err.Error())
+	})
+
+	t.Run("invalid KeepaliveIntervalMs", func(t *testing.T) {
+		cfg := ICETimeoutsConfig{DisconnectedTimeoutMs: 2000, KeepaliveIntervalMs: 2000}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid KeepaliveIntervalMs value: should be less than DisconnectedTimeoutMs", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := ICETimeoutsConfig{DisconnectedTimeoutMs: 10000, FailedTimeoutMs: 60000}
+		err := cfg.IsValid()
+		require.NoError(t, err)
+
+		disconnected, failed, keepalive := cfg.getTimeouts()
+		require.Equal(t, 10*time.Second, disconnected)
+		require.Equal(t, time.Minute, failed)
+		require.Equal(t, 2*time.Second, keepalive)
+	})
+}
+
+func TestJitterBufferConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg JitterBufferConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid AudioDelayMs", func(t *testing.T) {
+		cfg := JitterBufferConfig{AudioDelayMs: 500}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid AudioDelayMs value: 500 is not in allowed range [0, 200]", err.Error())
+	})
+
+	t.Run("invalid VideoReorderWindowMs", func(t *testing.T) {
+		cfg := JitterBufferConfig{VideoReorderWindowMs: -1}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid VideoReorderWindowMs value: -1 is not in allowed range [0, 200]", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := JitterBufferConfig{AudioDelayMs: 40, VideoReorderWindowMs: 20}
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestKeyFrameCacheConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg KeyFrameCacheConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid MaxSizeKB", func(t *testing.T) {
+		cfg := KeyFrameCacheConfig{Enable: true}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxSizeKB value: should be a positive number", err.Error())
+	})
 
-		cfg.TURNConfig.CredentialsExpirationMinutes = 20000
+	t.Run("valid", func(t *testing.T) {
+		cfg := KeyFrameCacheConfig{Enable: true, MaxSizeKB: 512}
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestEgressShapingConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg EgressShapingConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid RateKbps", func(t *testing.T) {
+		cfg := EgressShapingConfig{Enable: true, BurstKB: 1024}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid RateKbps value: should be a positive number", err.Error())
+	})
+
+	t.Run("invalid BurstKB", func(t *testing.T) {
+		cfg := EgressShapingConfig{Enable: true, RateKbps: 50000}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid BurstKB value: should be a positive number", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := EgressShapingConfig{Enable: true, RateKbps: 50000, BurstKB: 1024}
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestCapacityConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg CapacityConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid MaxCPUPercent", func(t *testing.T) {
+		cfg := CapacityConfig{MaxCPUPercent: 101}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxCPUPercent value: should be in the range [0, 100]", err.Error())
+	})
+
+	t.Run("invalid MaxPacketRate", func(t *testing.T) {
+		cfg := CapacityConfig{MaxPacketRate: -1}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxPacketRate value: should not be negative", err.Error())
+	})
+
+	t.Run("invalid MaxBandwidthMbps", func(t *testing.T) {
+		cfg := CapacityConfig{MaxBandwidthMbps: -1}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxBandwidthMbps value: should not be negative", err.Error())
+	})
+
+	t.Run("no limits", func(t *testing.T) {
+		cfg := CapacityConfig{RejectNewCalls: true}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid RejectNewCalls value: at least one limit should be set", err.Error())
+	})
+
+	t.Run("invalid InterfaceBudgets", func(t *testing.T) {
+		cfg := CapacityConfig{InterfaceBudgets: InterfaceBudgets{{MaxBandwidthMbps: 1000}}}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid InterfaceBudgets value: invalid Interface value: should not be empty", err.Error())
+
+		cfg.InterfaceBudgets = InterfaceBudgets{{Interface: "eth0"}}
 		err = cfg.IsValid()
 		require.Error(t, err)
-		require.Equal(t, "invalid TURNConfig: invalid CredentialsExpirationMinutes value: should be less than 1 week", err.Error())
+		require.Equal(t, "invalid InterfaceBudgets value: invalid MaxBandwidthMbps value: should be a positive number", err.Error())
+
+		cfg.InterfaceBudgets = InterfaceBudgets{{Interface: "eth0", MaxBandwidthMbps: 1000}, {Interface: "eth0", MaxBandwidthMbps: 100}}
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid InterfaceBudgets value: invalid Interface value: "eth0" is listed more than once`, err.Error())
 	})
 
 	t.Run("valid", func(t *testing.T) {
-		var cfg ServerConfig
-		cfg.ICEAddressUDP = "127.0.0.1"
-		cfg.ICEPortUDP = 8443
-		cfg.TURNConfig.CredentialsExpirationMinutes = 1440
+		cfg := CapacityConfig{MaxCPUPercent: 80, MaxBandwidthMbps: 1000, RejectNewCalls: true}
+		err := cfg.IsValid()
+		require.NoError(t, err)
+
+		cfg = CapacityConfig{
+			InterfaceBudgets: InterfaceBudgets{{Interface: "eth0", MaxBandwidthMbps: 1000}, {Interface: "eth1", MaxBandwidthMbps: 100}},
+			RejectNewCalls:   true,
+		}
+		err = cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestInterfaceBudgetsDecode(t *testing.T) {
+	var b InterfaceBudgets
+	err := b.Decode(`[{"interface": "eth0", "max_bandwidth_mbps": 1000}]`)
+	require.NoError(t, err)
+	require.Equal(t, InterfaceBudgets{{Interface: "eth0", MaxBandwidthMbps: 1000}}, b)
+
+	err = b.Decode("eth0")
+	require.Error(t, err)
+}
+
+func TestICECandidatesConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg ICECandidatesConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid BatchIntervalMs", func(t *testing.T) {
+		cfg := ICECandidatesConfig{BatchIntervalMs: -1}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid BatchIntervalMs value: should not be negative", err.Error())
+	})
+
+	t.Run("invalid AllowedNetworks", func(t *testing.T) {
+		cfg := ICECandidatesConfig{AllowedNetworks: []string{"10.0.0.1"}}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid AllowedNetworks value: invalid CIDR address: 10.0.0.1", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := ICECandidatesConfig{
+			BatchIntervalMs:       50,
+			DropLinkLocal:         true,
+			DropPrivateWhenPublic: true,
+			AllowedNetworks:       []string{"10.0.0.0/8", "2001:db8::/32"},
+		}
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestMDNSCandidatesConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg MDNSCandidatesConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid Mode", func(t *testing.T) {
+		cfg := MDNSCandidatesConfig{Mode: "query"}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid Mode value: should be one of "ignore" or "resolve"`, err.Error())
+	})
+
+	t.Run("invalid ResolveTimeoutMs", func(t *testing.T) {
+		cfg := MDNSCandidatesConfig{Mode: MDNSCandidatesModeResolve}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid ResolveTimeoutMs value: should be a positive number", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := MDNSCandidatesConfig{Mode: MDNSCandidatesModeResolve, ResolveTimeoutMs: 1000}
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestTranscriptionConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg TranscriptionConfig
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		var cfg TranscriptionConfig
+		cfg.URL = "http://localhost:8080"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid URL value: should start with "ws://" or "wss://"`, err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg TranscriptionConfig
+		cfg.URL = "wss://localhost:8080/transcribe"
+		cfg.AuthToken = "authToken"
 		err := cfg.IsValid()
 		require.NoError(t, err)
 	})
@@ -106,6 +1055,49 @@ func TestSessionConfigIsValid(t *testing.T) {
 		require.Equal(t, "invalid SessionID value: should not be empty", err.Error())
 	})
 
+	t.Run("invalid Locale", func(t *testing.T) {
+		var cfg SessionConfig
+		cfg.GroupID = "groupID"
+		cfg.CallID = "callID"
+		cfg.UserID = "userID"
+		cfg.SessionID = "sessionID"
+		cfg.Locale = "../fr"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid Locale value: "../fr" is not a valid locale`, err.Error())
+	})
+
+	t.Run("invalid TraceID", func(t *testing.T) {
+		var cfg SessionConfig
+		cfg.GroupID = "groupID"
+		cfg.CallID = "callID"
+		cfg.UserID = "userID"
+		cfg.SessionID = "sessionID"
+		cfg.TraceID = "trace id"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TraceID value: should be at most 64 alphanumeric, '-' or '_' characters", err.Error())
+
+		cfg.TraceID = strings.Repeat("a", 65)
+		err = cfg.IsValid()
+		require.Error(t, err)
+	})
+
+	t.Run("invalid Observer", func(t *testing.T) {
+		var cfg SessionConfig
+		cfg.GroupID = "groupID"
+		cfg.CallID = "callID"
+		cfg.UserID = "userID"
+		cfg.SessionID = "sessionID"
+		cfg.Observer = true
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Observer value: observer sessions should be hidden", err.Error())
+
+		cfg.Hidden = true
+		require.NoError(t, cfg.IsValid())
+	})
+
 	t.Run("valid", func(t *testing.T) {
 		var cfg SessionConfig
 		cfg.GroupID = "groupID"
@@ -114,6 +1106,14 @@ func TestSessionConfigIsValid(t *testing.T) {
 		cfg.SessionID = "sessionID"
 		err := cfg.IsValid()
 		require.NoError(t, err)
+
+		cfg.Locale = "pt-BR"
+		err = cfg.IsValid()
+		require.NoError(t, err)
+
+		cfg.TraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		err = cfg.IsValid()
+		require.NoError(t, err)
 	})
 }
 
@@ -164,6 +1164,77 @@ func TestGetSTUN(t *testing.T) {
 	})
 }
 
+func TestGetSTUNs(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var servers ICEServers
+		urls := servers.getSTUNs()
+		require.Empty(t, urls)
+	})
+
+	t.Run("no STUN", func(t *testing.T) {
+		servers := ICEServers{
+			ICEServerConfig{
+				URLs: []string{"turn:localhost"},
+			},
+			ICEServerConfig{
+				URLs: []string{"turn:localhost"},
+			},
+		}
+		urls := servers.getSTUNs()
+		require.Empty(t, urls)
+	})
+
+	t.Run("single STUN", func(t *testing.T) {
+		servers := ICEServers{
+			ICEServerConfig{
+				URLs: []string{"turn:localhost"},
+			},
+			ICEServerConfig{
+				URLs: []string{"stun:localhost"},
+			},
+		}
+		urls := servers.getSTUNs()
+		require.Equal(t, []string{"stun:localhost"}, urls)
+	})
+
+	t.Run("mixed with TURN", func(t *testing.T) {
+		servers := ICEServers{
+			ICEServerConfig{
+				URLs: []string{"turn:localhost", "stun:localhost"},
+			},
+		}
+		urls := servers.getSTUNs()
+		require.Equal(t, []string{"stun:localhost"}, urls)
+	})
+
+	t.Run("multiple STUN", func(t *testing.T) {
+		servers := ICEServers{
+			ICEServerConfig{
+				URLs: []string{"stun:stun0.localhost"},
+			},
+			ICEServerConfig{
+				URLs: []string{"stuns:stun1.localhost", "stun:stun2.localhost"},
+			},
+		}
+		urls := servers.getSTUNs()
+		require.Equal(t, []string{"stun:stun0.localhost", "stuns:stun1.localhost", "stun:stun2.localhost"}, urls)
+	})
+}
+
+func TestGetDiscoverySTUNServers(t *testing.T) {
+	cfg := ServerConfig{
+		ICEServers: ICEServers{
+			ICEServerConfig{
+				URLs: []string{"stun:stun0.localhost"},
+			},
+		},
+	}
+	require.Equal(t, []string{"stun:stun0.localhost"}, cfg.getDiscoverySTUNServers())
+
+	cfg.PublicIPDiscovery.STUNServers = []string{"stun:stun1.localhost"}
+	require.Equal(t, []string{"stun:stun1.localhost"}, cfg.getDiscoverySTUNServers())
+}
+
 func TestICEServerConfigIsValid(t *testing.T) {
 	t.Run("empty struct", func(t *testing.T) {
 		var cfg ICEServerConfig
@@ -232,4 +1303,95 @@ func TestICEServerConfigIsValid(t *testing.T) {
 		err := cfg.IsValid()
 		require.NoError(t, err)
 	})
+
+	t.Run("valid, over TCP and TLS", func(t *testing.T) {
+		cfg := ICEServerConfig{
+			URLs: []string{
+				"turn:localhost:443?transport=tcp",
+				"turns:localhost:443?transport=tcp",
+			},
+		}
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid transport", func(t *testing.T) {
+		cfg := ICEServerConfig{
+			URLs: []string{
+				"turn:localhost:443?transport=sctp",
+			},
+		}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid URL "turn:localhost:443?transport=sctp": invalid transport protocol type`, err.Error())
+	})
+
+	t.Run("region on STUN server", func(t *testing.T) {
+		cfg := ICEServerConfig{
+			URLs:   []string{"stun:localhost:3478"},
+			Region: "eu",
+		}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Region: only TURN servers have a region", err.Error())
+	})
+
+	t.Run("invalid region", func(t *testing.T) {
+		cfg := ICEServerConfig{
+			URLs:   []string{"turn:localhost:3478"},
+			Region: "eu west",
+		}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid Region: should be at most 64 alphanumeric, '-' or '_' characters", err.Error())
+	})
+
+	t.Run("valid, with region", func(t *testing.T) {
+		cfg := ICEServerConfig{
+			URLs:   []string{"turn:localhost:3478"},
+			Region: "eu-west",
+		}
+		err := cfg.IsValid()
+		require.NoError(t, err)
+	})
+}
+
+func TestChaosConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg ChaosConfig
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cfg := ChaosConfig{PacketLossPercent: 200}
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, tc := range []struct {
+			cfg ChaosConfig
+			err string
+		}{
+			{ChaosConfig{Enable: true, PacketLossPercent: -1}, "invalid PacketLossPercent value: should be in the range [0, 100]"},
+			{ChaosConfig{Enable: true, PacketLossPercent: 101}, "invalid PacketLossPercent value: should be in the range [0, 100]"},
+			{ChaosConfig{Enable: true, LatencyMs: -1}, "invalid LatencyMs value: should not be negative"},
+			{ChaosConfig{Enable: true, JitterMs: -1}, "invalid JitterMs value: should not be negative"},
+			{ChaosConfig{Enable: true, ReorderPercent: 101}, "invalid ReorderPercent value: should be in the range [0, 100]"},
+		} {
+			err := tc.cfg.IsValid()
+			require.Error(t, err)
+			require.Equal(t, tc.err, err.Error())
+		}
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := ChaosConfig{
+			Enable:            true,
+			PacketLossPercent: 5,
+			LatencyMs:         100,
+			JitterMs:          30,
+			ReorderPercent:    1,
+		}
+		require.NoError(t, cfg.IsValid())
+	})
 }
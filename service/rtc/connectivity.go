@@ -0,0 +1,378 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/ice/v2"
+	"github.com/pion/stun"
+	"github.com/pion/turn/v2"
+)
+
+// Types of connectivity checks.
+const (
+	ConnectivityCheckSTUN    = "stun"
+	ConnectivityCheckTURN    = "turn"
+	ConnectivityCheckReflect = "reflect"
+)
+
+const connectivityProbeUsernamePrefix = "rtcd-probe-"
+
+// ConnectivityCheck is the outcome of the last check run against a
+// STUN/TURN server.
+type ConnectivityCheck struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	OK   bool   `json:"ok"`
+	// Error is the reason the check failed, if any.
+	Error string `json:"error,omitempty"`
+	// RTTMs is the time it took to complete the check, in milliseconds.
+	RTTMs     int64 `json:"rtt_ms"`
+	CheckedAt int64 `json:"checked_at"`
+}
+
+// probeConn wraps the conn media is served on, answering the reachability
+// probes relayed to the advertised address. Any other packet is passed
+// through.
+type probeConn struct {
+	net.PacketConn
+	username []byte
+}
+
+func newProbeConn(conn net.PacketConn) *probeConn {
+	return &probeConn{
+		PacketConn: conn,
+		username:   []byte(connectivityProbeUsernamePrefix + random.NewID()),
+	}
+}
+
+func (c *probeConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(p)
+		if err != nil || !c.handleProbe(p[:n], addr) {
+			return n, addr, err
+		}
+	}
+}
+
+// handleProbe answers the given packet if it's a probe, returning whether it
+// was one.
+func (c *probeConn) handleProbe(data []byte, addr net.Addr) bool {
+	// Checking for the username first avoids decoding every ICE
+	// connectivity check.
+	if !stun.IsMessage(data) || !bytes.Contains(data, c.username) {
+		return false
+	}
+
+	msg := &stun.Message{Raw: data}
+	if err := msg.Decode(); err != nil || msg.Type != stun.BindingRequest {
+		return false
+	}
+	var username stun.Username
+	if err := username.GetFrom(msg); err != nil || !bytes.Equal(username, c.username) {
+		return false
+	}
+
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return true
+	}
+	resp, err := stun.Build(stun.NewTransactionIDSetter(msg.TransactionID), stun.BindingSuccess,
+		&stun.XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port})
+	if err != nil {
+		return true
+	}
+	_, _ = c.PacketConn.WriteTo(resp.Raw, addr)
+
+	return true
+}
+
+// GetConnectivityChecks returns the outcome of the last round of
+// connectivity checks. It returns nil if no round has completed yet.
+func (s *Server) GetConnectivityChecks() []ConnectivityCheck {
+	s.connectivityMut.RLock()
+	defer s.connectivityMut.RUnlock()
+	if s.connectivityChecks == nil {
+		return nil
+	}
+	checks := make([]ConnectivityCheck, len(s.connectivityChecks))
+	copy(checks, s.connectivityChecks)
+	return checks
+}
+
+func (s *Server) connectivityChecker(stopCh <-chan struct{}, doneCh chan<- struct{}) {
+	defer close(doneCh)
+
+	s.runConnectivityChecks()
+
+	ticker := time.NewTicker(time.Duration(s.cfg.ConnectivityCheck.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.runConnectivityChecks()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func (s *Server) runConnectivityChecks() {
+	timeout := time.Duration(s.cfg.ConnectivityCheck.TimeoutSeconds) * time.Second
+
+	// The advertised address can only be checked from outside if it's known.
+	var advertisedAddr *net.UDPAddr
+	if iceHost := s.getICEHost(); iceHost != "" {
+		ip, err := resolveHost(iceHost, timeout)
+		if err != nil {
+			s.log.Error("connectivity check: failed to resolve advertised host", mlog.Err(err))
+		} else {
+			advertisedAddr = &net.UDPAddr{IP: net.ParseIP(ip), Port: s.cfg.ICEPortUDP}
+		}
+	}
+
+	checks := []ConnectivityCheck{}
+	for _, iceCfg := range s.cfg.ICEServers {
+		for _, u := range iceCfg.URLs {
+			iceURL, err := ice.ParseURL(u)
+			if err != nil {
+				s.log.Error("connectivity check: failed to parse URL", mlog.Err(err), mlog.String("url", u))
+				continue
+			}
+			addr := fmt.Sprintf("%s:%d", iceURL.Host, iceURL.Port)
+
+			switch {
+			case iceURL.Scheme == ice.SchemeTypeSTUN && iceURL.Proto == ice.ProtoTypeUDP:
+				checks = append(checks, runConnectivityCheck(ConnectivityCheckSTUN, u, func() error {
+					return checkSTUN(s.cfg.LocalUDPPorts, addr, timeout)
+				}))
+			// TURN over DTLS isn't supported.
+			case iceURL.Scheme == ice.SchemeTypeTURN || (iceURL.Scheme == ice.SchemeTypeTURNS && iceURL.Proto == ice.ProtoTypeTCP):
+				username, password, err := s.getTURNCheckCredentials(iceCfg)
+				if err != nil {
+					s.log.Error("connectivity check: failed to get TURN credentials", mlog.Err(err), mlog.String("url", u))
+					continue
+				}
+				if username == "" {
+					continue
+				}
+				checks = append(checks, s.checkTURN(u, iceURL, username, password, advertisedAddr, timeout)...)
+			}
+		}
+	}
+
+	s.connectivityMut.Lock()
+	prevChecks := s.connectivityChecks
+	s.connectivityChecks = checks
+	s.connectivityMut.Unlock()
+
+	prevOK := make(map[string]bool, len(prevChecks))
+	for _, c := range prevChecks {
+		prevOK[c.Type+c.URL] = c.OK
+	}
+	for _, c := range checks {
+		s.metrics.SetConnectivityCheck(c.Type, c.URL, c.OK)
+		if ok, found := prevOK[c.Type+c.URL]; found && ok == c.OK {
+			continue
+		}
+		if c.OK {
+			s.log.Info("connectivity check succeeded", mlog.String("type", c.Type), mlog.String("url", c.URL))
+		} else {
+			s.log.Warn("connectivity check failed", mlog.String("type", c.Type), mlog.String("url", c.URL), mlog.String("error", c.Error))
+		}
+	}
+}
+
+// getTURNCheckCredentials returns the credentials to allocate on the given
+// TURN server, generating short-lived ones if needed. Empty credentials are
+// returned if none are available.
+func (s *Server) getTURNCheckCredentials(cfg ICEServerConfig) (string, string, error) {
+	if cfg.Username != "" || cfg.Credential != "" {
+		return cfg.Username, cfg.Credential, nil
+	}
+
+	s.mut.RLock()
+	secret := s.cfg.TURNConfig.StaticAuthSecret
+	s.mut.RUnlock()
+
+	if secret == "" {
+		return "", "", nil
+	}
+
+	ts := time.Now().Add(time.Duration(s.cfg.TURNConfig.CredentialsExpirationMinutes) * time.Minute).Unix()
+	return genTURNCredentials("rtcd-connectivity-check", secret, ts)
+}
+
+func runConnectivityCheck(checkType, u string, check func() error) ConnectivityCheck {
+	start := time.Now()
+	err := check()
+	c := ConnectivityCheck{
+		Type:      checkType,
+		URL:       u,
+		OK:        err == nil,
+		RTTMs:     time.Since(start).Milliseconds(),
+		CheckedAt: start.UnixMilli(),
+	}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	return c
+}
+
+func checkSTUN(localPorts LocalUDPPortsConfig, addr string, timeout time.Duration) error {
+	serverAddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return fmt.Errorf("failed to resolve stun host: %w", err)
+	}
+
+	conn, err := localPorts.listenUDP(0)
+	if err != nil {
+		return fmt.Errorf("failed to listen on udp: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := getXORMappedAddr(conn, serverAddr, timeout); err != nil {
+		return fmt.Errorf("failed to get mapped address: %w", err)
+	}
+
+	return nil
+}
+
+// checkTURN allocates a relay on the given TURN server. If the advertised
+// address is known, the relay is also used as reflector: a probe is sent
+// through it to the advertised address, verifying that it can be reached from
+// outside.
+func (s *Server) checkTURN(u string, iceURL *ice.URL, username, password string, advertisedAddr *net.UDPAddr, timeout time.Duration) []ConnectivityCheck {
+	addr := fmt.Sprintf("%s:%d", iceURL.Host, iceURL.Port)
+
+	var conn net.PacketConn
+	var client *turn.Client
+	var relayConn net.PacketConn
+	turnCheck := runConnectivityCheck(ConnectivityCheckTURN, u, func() error {
+		var err error
+		conn, err = s.dialTURN(iceURL, addr, timeout)
+		if err != nil {
+			return err
+		}
+
+		client, err = turn.NewClient(&turn.ClientConfig{
+			STUNServerAddr: addr,
+			TURNServerAddr: addr,
+			Username:       username,
+			Password:       password,
+			RTO:            timeout / 4,
+			Conn:           conn,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create turn client: %w", err)
+		}
+
+		if err := client.Listen(); err != nil {
+			return fmt.Errorf("failed to listen: %w", err)
+		}
+
+		relayConn, err = client.Allocate()
+		if err != nil {
+			return fmt.Errorf("failed to allocate: %w", err)
+		}
+
+		return nil
+	})
+	if conn != nil {
+		defer conn.Close()
+	}
+	if client != nil {
+		defer client.Close()
+	}
+	if !turnCheck.OK {
+		return []ConnectivityCheck{turnCheck}
+	}
+	// Closing the relay conn releases the allocation.
+	defer relayConn.Close()
+
+	if advertisedAddr == nil {
+		return []ConnectivityCheck{turnCheck}
+	}
+
+	reflectCheck := runConnectivityCheck(ConnectivityCheckReflect, u, func() error {
+		return s.sendProbe(relayConn, advertisedAddr, timeout)
+	})
+
+	return []ConnectivityCheck{turnCheck, reflectCheck}
+}
+
+// dialTURN returns the conn to exchange messages with the TURN server at
+// addr through, over the transport of the given URL. TCP and TLS streams are
+// framed as expected by the TURN client.
+func (s *Server) dialTURN(iceURL *ice.URL, addr string, timeout time.Duration) (net.PacketConn, error) {
+	if iceURL.Proto == ice.ProtoTypeUDP {
+		conn, err := s.cfg.LocalUDPPorts.listenUDP(0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on udp: %w", err)
+		}
+		return conn, nil
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	if iceURL.Scheme == ice.SchemeTypeTURNS {
+		conn, err := tls.DialWithDialer(dialer, "tcp4", addr, &tls.Config{
+			ServerName: iceURL.Host,
+			RootCAs:    s.turnRootCAs,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to dial tls: %w", err)
+		}
+		return turn.NewSTUNConn(conn), nil
+	}
+
+	conn, err := dialer.Dial("tcp4", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial tcp: %w", err)
+	}
+	return turn.NewSTUNConn(conn), nil
+}
+
+// sendProbe sends a probe to the given address through conn, waiting for
+// the response.
+func (s *Server) sendProbe(conn net.PacketConn, addr *net.UDPAddr, timeout time.Duration) error {
+	if s.probeConn == nil {
+		return fmt.Errorf("probe conn is not set")
+	}
+
+	req, err := stun.Build(stun.TransactionID, stun.BindingRequest, stun.Username(s.probeConn.username))
+	if err != nil {
+		return fmt.Errorf("failed to build probe: %w", err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return fmt.Errorf("failed to set deadline: %w", err)
+	}
+	if _, err := conn.WriteTo(req.Raw, addr); err != nil {
+		return fmt.Errorf("failed to send probe: %w", err)
+	}
+
+	buf := make([]byte, receiveMTU)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return fmt.Errorf("failed to receive probe response from %s: %w", addr, err)
+		}
+		resp := &stun.Message{Raw: buf[:n]}
+		if err := resp.Decode(); err != nil {
+			continue
+		}
+		if resp.Type == stun.BindingSuccess && resp.TransactionID == req.TransactionID {
+			return nil
+		}
+	}
+}
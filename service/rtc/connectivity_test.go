@@ -0,0 +1,239 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/dtls/v2/pkg/crypto/selfsign"
+	"github.com/pion/stun"
+	"github.com/pion/turn/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTURNServer(t *testing.T, username, password string) (string, func()) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	key := turn.GenerateAuthKey(username, "rtcd", password)
+	s, err := turn.NewServer(turn.ServerConfig{
+		Realm: "rtcd",
+		AuthHandler: func(u, _ string, _ net.Addr) ([]byte, bool) {
+			return key, u == username
+		},
+		PacketConnConfigs: []turn.PacketConnConfig{
+			{
+				PacketConn: conn,
+				RelayAddressGenerator: &turn.RelayAddressGeneratorStatic{
+					RelayAddress: net.ParseIP("127.0.0.1"),
+					Address:      "127.0.0.1",
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	return conn.LocalAddr().String(), func() {
+		err := s.Close()
+		require.NoError(t, err)
+	}
+}
+
+// setupTURNStreamServer starts a TURN server over TCP or, if tlsConfig is
+// set, over TLS.
+func setupTURNStreamServer(t *testing.T, username, password string, tlsConfig *tls.Config) (string, func()) {
+	t.Helper()
+
+	var l net.Listener
+	var err error
+	if tlsConfig != nil {
+		l, err = tls.Listen("tcp4", "127.0.0.1:0", tlsConfig)
+	} else {
+		l, err = net.Listen("tcp4", "127.0.0.1:0")
+	}
+	require.NoError(t, err)
+
+	key := turn.GenerateAuthKey(username, "rtcd", password)
+	s, err := turn.NewServer(turn.ServerConfig{
+		Realm: "rtcd",
+		AuthHandler: func(u, _ string, _ net.Addr) ([]byte, bool) {
+			return key, u == username
+		},
+		ListenerConfigs: []turn.ListenerConfig{
+			{
+				Listener: l,
+				RelayAddressGenerator: &turn.RelayAddressGeneratorStatic{
+					RelayAddress: net.ParseIP("127.0.0.1"),
+					Address:      "127.0.0.1",
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	return l.Addr().String(), func() {
+		err := s.Close()
+		require.NoError(t, err)
+	}
+}
+
+func TestProbeConn(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	pc := newProbeConn(conn)
+	defer pc.Close()
+
+	client, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer client.Close()
+
+	readCh := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, receiveMTU)
+		for {
+			n, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				close(readCh)
+				return
+			}
+			readCh <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+
+	t.Run("probe", func(t *testing.T) {
+		req, err := stun.Build(stun.TransactionID, stun.BindingRequest, stun.Username(pc.username))
+		require.NoError(t, err)
+		_, err = client.WriteTo(req.Raw, pc.LocalAddr())
+		require.NoError(t, err)
+
+		err = client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		require.NoError(t, err)
+		buf := make([]byte, receiveMTU)
+		n, _, err := client.ReadFrom(buf)
+		require.NoError(t, err)
+
+		resp := &stun.Message{Raw: buf[:n]}
+		err = resp.Decode()
+		require.NoError(t, err)
+		require.Equal(t, stun.BindingSuccess, resp.Type)
+		require.Equal(t, req.TransactionID, resp.TransactionID)
+
+		var addr stun.XORMappedAddress
+		err = addr.GetFrom(resp)
+		require.NoError(t, err)
+		require.Equal(t, client.LocalAddr().(*net.UDPAddr).Port, addr.Port)
+
+		// Probes are not passed through.
+		select {
+		case <-readCh:
+			require.Fail(t, "unexpected packet")
+		default:
+		}
+	})
+
+	t.Run("passthrough", func(t *testing.T) {
+		req, err := stun.Build(stun.TransactionID, stun.BindingRequest, stun.NewUsername("ufragA:ufragB"))
+		require.NoError(t, err)
+
+		for _, data := range [][]byte{req.Raw, []byte("media")} {
+			_, err = client.WriteTo(data, pc.LocalAddr())
+			require.NoError(t, err)
+
+			select {
+			case received := <-readCh:
+				require.Equal(t, data, received)
+			case <-time.After(5 * time.Second):
+				require.Fail(t, "timed out waiting for packet")
+			}
+		}
+	})
+}
+
+func TestConnectivityChecks(t *testing.T) {
+	turnAddr, closeTURN := setupTURNServer(t, "username", "password")
+	defer closeTURN()
+
+	tcpAddr, closeTCP := setupTURNStreamServer(t, "username", "password", nil)
+	defer closeTCP()
+
+	cert, err := selfsign.GenerateSelfSignedWithDNS("localhost", "localhost")
+	require.NoError(t, err)
+	tlsAddr, closeTLS := setupTURNStreamServer(t, "username", "password", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	})
+	defer closeTLS()
+	_, tlsPort, err := net.SplitHostPort(tlsAddr)
+	require.NoError(t, err)
+
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	require.Nil(t, server.GetConnectivityChecks())
+
+	rootCert, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	server.turnRootCAs = x509.NewCertPool()
+	server.turnRootCAs.AddCert(rootCert)
+
+	server.cfg.ICEHostOverride = "127.0.0.1"
+	server.cfg.ICEServers = ICEServers{
+		{URLs: []string{"stun:" + turnAddr}},
+		{URLs: []string{"turn:" + turnAddr}, Username: "username", Credential: "password"},
+		{URLs: []string{"turn:" + turnAddr}, Username: "username", Credential: "wrong"},
+		{URLs: []string{"turn:" + tcpAddr + "?transport=tcp"}, Username: "username", Credential: "password"},
+		{URLs: []string{"turns:localhost:" + tlsPort + "?transport=tcp"}, Username: "username", Credential: "password"},
+		// Not supported, should be skipped.
+		{URLs: []string{"turns:" + turnAddr + "?transport=udp"}, Username: "username", Credential: "password"},
+	}
+	server.cfg.ConnectivityCheck = ConnectivityCheckConfig{
+		Enable:          true,
+		IntervalSeconds: 60,
+		TimeoutSeconds:  2,
+	}
+
+	err = server.Start()
+	require.NoError(t, err)
+
+	var checks []ConnectivityCheck
+	require.Eventually(t, func() bool {
+		checks = server.GetConnectivityChecks()
+		return checks != nil
+	}, 10*time.Second, 50*time.Millisecond)
+
+	require.Len(t, checks, 8)
+
+	require.Equal(t, ConnectivityCheckSTUN, checks[0].Type)
+	require.Equal(t, "stun:"+turnAddr, checks[0].URL)
+	require.True(t, checks[0].OK, checks[0].Error)
+
+	require.Equal(t, ConnectivityCheckTURN, checks[1].Type)
+	require.True(t, checks[1].OK, checks[1].Error)
+
+	// The probe gets relayed to the advertised address.
+	require.Equal(t, ConnectivityCheckReflect, checks[2].Type)
+	require.True(t, checks[2].OK, checks[2].Error)
+
+	require.Equal(t, ConnectivityCheckTURN, checks[3].Type)
+	require.False(t, checks[3].OK)
+	require.Contains(t, checks[3].Error, "failed to allocate")
+
+	// The relays allocated over TCP and TLS work as reflectors as well.
+	for i, u := range []string{"turn:" + tcpAddr + "?transport=tcp", "turns:localhost:" + tlsPort + "?transport=tcp"} {
+		require.Equal(t, ConnectivityCheckTURN, checks[4+2*i].Type)
+		require.Equal(t, u, checks[4+2*i].URL)
+		require.True(t, checks[4+2*i].OK, checks[4+2*i].Error)
+		require.Equal(t, ConnectivityCheckReflect, checks[5+2*i].Type)
+		require.True(t, checks[5+2*i].OK, checks[5+2*i].Error)
+	}
+
+	for _, c := range checks {
+		require.NotZero(t, c.CheckedAt)
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+// Legs of a forwarded stream, as labeled in the one-way delay metrics.
+const (
+	// DelayLegUplink is the leg between the publisher and the server.
+	DelayLegUplink = "uplink"
+	// DelayLegDownlink is the leg between the server and a subscriber.
+	DelayLegDownlink = "downlink"
+)
+
+const (
+	// uplinkDelayInterval is the interval the queuing delay of the
+	// publisher leg of a stream is averaged over.
+	uplinkDelayInterval = time.Second
+	// uplinkDelayBaseIntervals is the number of intervals the lowest transit
+	// time, taken as free of queuing, is kept over.
+	uplinkDelayBaseIntervals = 10
+	// absSendTimeBits is the number of bits of the NTP timestamps the
+	// abs-send-time extension carries (6.18 fixed point seconds), once
+	// shifted to the 32.32 format.
+	absSendTimeBits = 38
+)
+
+// uplinkDelay estimates the delay of the packets of a stream between its
+// publisher and the server out of the send times carried by their
+// abs-send-time or abs-capture-time extension. The clocks of the publisher
+// and the server aren't synchronized so the transit times are off by an
+// unknown offset: only their variation, the queuing delay, can be measured,
+// against the lowest transit time seen over the last intervals.
+type uplinkDelay struct {
+	// sendTimeID and captureTimeID are the IDs of the extensions on the
+	// publisher leg, zero if not negotiated. The send time is preferred as
+	// it's set on every packet.
+	sendTimeID    uint8
+	captureTimeID uint8
+
+	started bool
+	// firstTransit is the transit time of the first packet, in NTP units.
+	// The following ones are measured relative to it.
+	firstTransit  uint64
+	intervalStart time.Time
+	// sum and count accumulate the relative transit times, in milliseconds,
+	// of the current interval, and minTransit holds its lowest one.
+	sum        float64
+	count      int
+	minTransit float64
+	// baseTransits holds the lowest relative transit times of the previous
+	// intervals.
+	baseTransits []float64
+	queuingMs    float64
+	// delayMs is the last estimate of the one-way delay, set by the server
+	// once it's completed with the base delay.
+	delayMs float64
+	mut     sync.Mutex
+}
+
+// newUplinkDelay returns an estimator for the stream received on a leg
+// with the given negotiated extensions. It returns nil if neither
+// extension was negotiated.
+func newUplinkDelay(params []webrtc.RTPHeaderExtensionParameter) *uplinkDelay {
+	var d uplinkDelay
+	for _, param := range params {
+		switch param.URI {
+		case rtpHeaderExtensionURI(RTPHeaderExtensionAbsSendTime):
+			d.sendTimeID = uint8(param.ID)
+		case rtpHeaderExtensionURI(RTPHeaderExtensionAbsCaptureTime):
+			d.captureTimeID = uint8(param.ID)
+		}
+	}
+	if d.sendTimeID == 0 && d.captureTimeID == 0 {
+		return nil
+	}
+	return &d
+}
+
+// ntpTime returns the 64-bit NTP timestamp of t.
+func ntpTime(t time.Time) uint64 {
+	secs := uint64(t.Unix()+ntpEpochOffset) << 32
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return secs | frac
+}
+
+// getTransit returns the transit time of a packet received at the given
+// time, in NTP units and off by the clock offset of the publisher, along
+// with the number of bits it's significant over. It returns false if the
+// packet doesn't carry its send time.
+func (d *uplinkDelay) getTransit(h *rtp.Header, now time.Time) (uint64, uint, bool) {
+	if d.sendTimeID != 0 {
+		var ext rtp.AbsSendTimeExtension
+		if payload := h.GetExtension(d.sendTimeID); payload != nil && ext.Unmarshal(payload) == nil {
+			return (ntpTime(now) - ext.Timestamp<<14) & (1<<absSendTimeBits - 1), absSendTimeBits, true
+		}
+		return 0, 0, false
+	}
+
+	// The capture time is optionally followed by the estimated offset of
+	// the capture clock, which isn't needed.
+	payload := h.GetExtension(d.captureTimeID)
+	if len(payload) < 8 {
+		return 0, 0, false
+	}
+	return ntpTime(now) - binary.BigEndian.Uint64(payload), 64, true
+}
+
+// onPacket accounts for a packet received from the publisher, returning
+// whether a new estimate of the queuing delay is available.
+func (d *uplinkDelay) onPacket(h *rtp.Header, now time.Time) bool {
+	transit, bits, ok := d.getTransit(h, now)
+	if !ok {
+		return false
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if !d.started {
+		d.started = true
+		d.firstTransit = transit
+		d.intervalStart = now
+	}
+
+	// Sign extends the difference, transit times wrapping around.
+	shift := 64 - bits
+	relative := float64(int64((transit-d.firstTransit)<<shift)>>shift) * 1000 / (1 << 32)
+
+	if d.count == 0 || relative < d.minTransit {
+		d.minTransit = relative
+	}
+	d.sum += relative
+	d.count++
+
+	if now.Sub(d.intervalStart) < uplinkDelayInterval {
+		return false
+	}
+
+	base := d.minTransit
+	for _, t := range d.baseTransits {
+		base = math.Min(base, t)
+	}
+	d.queuingMs = math.Max(0, d.sum/float64(d.count)-base)
+
+	d.baseTransits = append(d.baseTransits, d.minTransit)
+	if len(d.baseTransits) > uplinkDelayBaseIntervals-1 {
+		d.baseTransits = d.baseTransits[1:]
+	}
+	d.sum = 0
+	d.count = 0
+	d.intervalStart = now
+
+	return true
+}
+
+// getQueuing returns the queuing delay, in milliseconds, averaged over the
+// last complete interval.
+func (d *uplinkDelay) getQueuing() float64 {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return d.queuingMs
+}
+
+func (d *uplinkDelay) setDelay(ms float64) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	d.delayMs = ms
+}
+
+// getDelay returns the last estimate of the one-way delay, in milliseconds.
+func (d *uplinkDelay) getDelay() float64 {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return d.delayMs
+}
+
+// estimateUplinkDelay estimates the one-way delay between a publisher and
+// the server out of the queuing delay measured on its packets, the
+// propagation delay being taken as half the lowest round trip time of its
+// connection. Only the queuing delay is known until the publisher reports
+// on the streams it receives.
+func estimateUplinkDelay(minRTTMs, queuingMs float64) float64 {
+	return minRTTMs/2 + queuingMs
+}
+
+// estimateDownlinkDelay estimates the one-way delay between the server and
+// a subscriber out of the round trip time of its connection. The delay
+// above the lowest round trip time is queuing, attributed to the downlink
+// but for the queuing measured on the packets the subscriber publishes
+// itself, if any. It returns zero if the round trip time is unknown.
+func estimateDownlinkDelay(rttMs, minRTTMs, uplinkQueuingMs float64) float64 {
+	if rttMs <= 0 {
+		return 0
+	}
+	if minRTTMs <= 0 || minRTTMs > rttMs {
+		minRTTMs = rttMs
+	}
+	return minRTTMs/2 + math.Max(0, rttMs-minRTTMs-uplinkQueuingMs)
+}
+
+// getMinRTT returns the lowest round trip time, in milliseconds, reported
+// on the connection of the session, zero if none was.
+func (s *session) getMinRTT() float64 {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.minRTTMs
+}
+
+func (s *session) setUplinkQueuing(ms float64) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.uplinkQueuingMs = ms
+}
+
+func (s *session) getUplinkQueuing() float64 {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.uplinkQueuingMs
+}
+
+// onUplinkDelay completes the queuing delay newly measured on a stream
+// published by the given session into an estimate of its one-way delay.
+func (s *Server) onUplinkDelay(us *session, d *uplinkDelay) {
+	queuing := d.getQueuing()
+	us.setUplinkQueuing(queuing)
+	delay := estimateUplinkDelay(us.getMinRTT(), queuing)
+	d.setDelay(delay)
+	s.metrics.ObserveOneWayDelay(DelayLegUplink, delay/1000)
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUplinkDelay(t *testing.T) {
+	require.Nil(t, newUplinkDelay(nil))
+	require.Nil(t, newUplinkDelay([]webrtc.RTPHeaderExtensionParameter{
+		{URI: rtpHeaderExtensionURI(RTPHeaderExtensionAudioLevel), ID: 1},
+	}))
+
+	d := newUplinkDelay([]webrtc.RTPHeaderExtensionParameter{
+		{URI: rtpHeaderExtensionURI(RTPHeaderExtensionAudioLevel), ID: 1},
+		{URI: rtpHeaderExtensionURI(RTPHeaderExtensionAbsSendTime), ID: 3},
+		{URI: rtpHeaderExtensionURI(RTPHeaderExtensionAbsCaptureTime), ID: 5},
+	})
+	require.NotNil(t, d)
+	require.Equal(t, uint8(3), d.sendTimeID)
+	require.Equal(t, uint8(5), d.captureTimeID)
+}
+
+func TestUplinkDelay(t *testing.T) {
+	start := time.Now()
+
+	sendTimeHeader := func(sendTime time.Time) *rtp.Header {
+		var h rtp.Header
+		payload, err := rtp.NewAbsSendTimeExtension(sendTime).Marshal()
+		require.NoError(t, err)
+		require.NoError(t, h.SetExtension(3, payload))
+		return &h
+	}
+
+	captureTimeHeader := func(captureTime time.Time) *rtp.Header {
+		var h rtp.Header
+		payload := make([]byte, 8)
+		binary.BigEndian.PutUint64(payload, ntpTime(captureTime))
+		require.NoError(t, h.SetExtension(5, payload))
+		return &h
+	}
+
+	// send feeds the packets from..to, sent every 20ms by a publisher whose
+	// clock is off by offset and received after delay, returning whether
+	// any completed an estimate.
+	send := func(d *uplinkDelay, from, to int, offset, delay time.Duration, header func(time.Time) *rtp.Header) bool {
+		var ok bool
+		for i := from; i <= to; i++ {
+			sentAt := start.Add(time.Duration(i) * 20 * time.Millisecond)
+			if d.onPacket(header(sentAt.Add(offset)), sentAt.Add(delay)) {
+				ok = true
+			}
+		}
+		return ok
+	}
+
+	t.Run("send time", func(t *testing.T) {
+		d := &uplinkDelay{sendTimeID: 3}
+		require.True(t, send(d, 0, 50, -37*time.Second, 40*time.Millisecond, sendTimeHeader))
+		require.InDelta(t, 0, d.getQueuing(), 0.1)
+
+		require.True(t, send(d, 51, 100, -37*time.Second, 140*time.Millisecond, sendTimeHeader))
+		require.InDelta(t, 100, d.getQueuing(), 0.1)
+	})
+
+	t.Run("capture time", func(t *testing.T) {
+		d := &uplinkDelay{captureTimeID: 5}
+		require.True(t, send(d, 0, 50, 1000*time.Second, 40*time.Millisecond, captureTimeHeader))
+		require.InDelta(t, 0, d.getQueuing(), 0.1)
+
+		require.True(t, send(d, 51, 100, 1000*time.Second, 90*time.Millisecond, captureTimeHeader))
+		require.InDelta(t, 50, d.getQueuing(), 0.1)
+	})
+
+	t.Run("wrap-around", func(t *testing.T) {
+		// The transit times wrap around the 64 seconds covered by the send
+		// times.
+		d := &uplinkDelay{sendTimeID: 3}
+		require.True(t, send(d, 0, 50, 90*time.Millisecond, 40*time.Millisecond, sendTimeHeader))
+		require.True(t, send(d, 51, 100, 90*time.Millisecond, 140*time.Millisecond, sendTimeHeader))
+		require.InDelta(t, 100, d.getQueuing(), 0.1)
+	})
+
+	t.Run("base expiring", func(t *testing.T) {
+		d := &uplinkDelay{sendTimeID: 3}
+		require.True(t, send(d, 0, 50, 0, 40*time.Millisecond, sendTimeHeader))
+
+		// The lowest transit time is kept over uplinkDelayBaseIntervals
+		// intervals, after which a longer route is taken as the new base.
+		for i := 1; i < uplinkDelayBaseIntervals; i++ {
+			require.True(t, send(d, i*50+1, (i+1)*50, 0, 100*time.Millisecond, sendTimeHeader))
+			require.InDelta(t, 60, d.getQueuing(), 0.1)
+		}
+		require.True(t, send(d, 501, 550, 0, 100*time.Millisecond, sendTimeHeader))
+		require.InDelta(t, 0, d.getQueuing(), 0.1)
+	})
+
+	t.Run("missing extension", func(t *testing.T) {
+		d := &uplinkDelay{sendTimeID: 3}
+		require.False(t, send(d, 0, 100, 0, 40*time.Millisecond, captureTimeHeader))
+		require.Zero(t, d.getQueuing())
+	})
+}
+
+func TestEstimateOneWayDelay(t *testing.T) {
+	t.Run("uplink", func(t *testing.T) {
+		require.Equal(t, 30.0, estimateUplinkDelay(0, 30))
+		require.Equal(t, 50.0, estimateUplinkDelay(40, 30))
+	})
+
+	t.Run("downlink", func(t *testing.T) {
+		tcs := []struct {
+			name            string
+			rttMs           float64
+			minRTTMs        float64
+			uplinkQueuingMs float64
+			delayMs         float64
+		}{
+			{name: "unknown rtt", minRTTMs: 40},
+			{name: "first report", rttMs: 100, delayMs: 50},
+			{name: "new lowest rtt", rttMs: 30, minRTTMs: 40, delayMs: 15},
+			{name: "queuing", rttMs: 100, minRTTMs: 40, delayMs: 80},
+			{name: "queuing on uplink", rttMs: 100, minRTTMs: 40, uplinkQueuingMs: 30, delayMs: 50},
+			{name: "queuing on uplink only", rttMs: 100, minRTTMs: 40, uplinkQueuingMs: 90, delayMs: 20},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.name, func(t *testing.T) {
+				require.Equal(t, tc.delayMs, estimateDownlinkDelay(tc.rttMs, tc.minRTTMs, tc.uplinkQueuingMs))
+			})
+		}
+	})
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+type EventType string
+
+const (
+	CallStartedEvent          EventType = "call_started"
+	CallEndedEvent            EventType = "call_ended"
+	SessionJoinedEvent        EventType = "session_joined"
+	SessionLeftEvent          EventType = "session_left"
+	TranscriptionStartedEvent EventType = "transcription_started"
+	TranscriptionStoppedEvent EventType = "transcription_stopped"
+	// StreamQualityChangedEvent is sent when the quality level of a stream
+	// forwarded to a session changes.
+	StreamQualityChangedEvent EventType = "stream_quality_changed"
+	// RecordingStartedEvent and RecordingStoppedEvent are sent when the
+	// recording of a single participant starts or stops.
+	RecordingStartedEvent EventType = "recording_started"
+	RecordingStoppedEvent EventType = "recording_stopped"
+	// HLSStartedEvent and HLSStoppedEvent are sent when the LL-HLS broadcast
+	// of a call starts or stops.
+	HLSStartedEvent EventType = "hls_started"
+	HLSStoppedEvent EventType = "hls_stopped"
+	// SessionMigratedEvent is sent when a session got re-anchored to the new
+	// network address of its client.
+	SessionMigratedEvent EventType = "session_migrated"
+	// SessionMutedEvent and SessionUnmutedEvent are sent when the voice
+	// track of a session stops or starts being forwarded.
+	SessionMutedEvent   EventType = "session_muted"
+	SessionUnmutedEvent EventType = "session_unmuted"
+	// ICERestartedEvent is sent when the server restarts ICE for a session.
+	ICERestartedEvent EventType = "ice_restarted"
+	// TrackStalledEvent is sent when no packet was received on a track
+	// published by a session for longer than the configured timeout, and
+	// TrackResumedEvent when packets flow again.
+	TrackStalledEvent EventType = "track_stalled"
+	TrackResumedEvent EventType = "track_resumed"
+)
+
+// Event describes a change in the lifecycle of a call or session. Events are
+// meant for external consumption (e.g. webhooks) and delivered on a best
+// effort basis.
+type Event struct {
+	Type      EventType `json:"type"`
+	Timestamp int64     `json:"timestamp"`
+	GroupID   string    `json:"group_id"`
+	CallID    string    `json:"call_id"`
+	UserID    string    `json:"user_id,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	// TraceID is set for the session events to the trace ID of the session.
+	TraceID string `json:"trace_id,omitempty"`
+	// Quality is set for StreamQualityChangedEvent.
+	Quality *StreamQuality `json:"quality,omitempty"`
+	// Recording is set for RecordingStartedEvent and RecordingStoppedEvent.
+	Recording *RecordingInfo `json:"recording,omitempty"`
+	// HLS is set for HLSStartedEvent and HLSStoppedEvent.
+	HLS *HLSStreamInfo `json:"hls,omitempty"`
+	// Migration is set for SessionMigratedEvent.
+	Migration *SessionMigration `json:"migration,omitempty"`
+	// Reason is set for SessionLeftEvent to the reason the session was
+	// closed with (e.g. CloseReasonKicked).
+	Reason string `json:"reason,omitempty"`
+	// TrackID is set for TrackStalledEvent and TrackResumedEvent to the ID
+	// of the track, as forwarded to subscribers.
+	TrackID string `json:"track_id,omitempty"`
+}
+
+func newEvent(evType EventType, cfg SessionConfig) Event {
+	return Event{
+		Type:      evType,
+		Timestamp: time.Now().UnixMilli(),
+		GroupID:   cfg.GroupID,
+		CallID:    cfg.CallID,
+		UserID:    cfg.UserID,
+		SessionID: cfg.SessionID,
+		TraceID:   cfg.TraceID,
+	}
+}
+
+func newCallEvent(evType EventType, groupID, callID string) Event {
+	return newEvent(evType, SessionConfig{
+		GroupID: groupID,
+		CallID:  callID,
+	})
+}
+
+// EventsCh returns a channel on which call and session events are delivered.
+// The channel is closed when the server stops.
+func (s *Server) EventsCh() <-chan Event {
+	return s.eventsCh
+}
+
+func (s *Server) sendEvent(ev Event) {
+	s.eventsMut.RLock()
+	defer s.eventsMut.RUnlock()
+
+	if s.eventsClosed {
+		return
+	}
+
+	select {
+	case s.eventsCh <- ev:
+	default:
+		s.log.Error("failed to send event: channel is full", mlog.String("type", string(ev.Type)))
+	}
+}
+
+func (s *Server) closeEvents() {
+	s.eventsMut.Lock()
+	defer s.eventsMut.Unlock()
+	if !s.eventsClosed {
+		s.eventsClosed = true
+		close(s.eventsCh)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	cfg := SessionConfig{
+		GroupID:   "groupID",
+		CallID:    "callID",
+		UserID:    "userID",
+		SessionID: "sessionID",
+		TraceID:   "traceID",
+	}
+
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+
+	_, err = server.addSession(cfg, peerConn, nil)
+	require.NoError(t, err)
+
+	// Failed joins don't emit any event.
+	_, err = server.addSession(cfg, peerConn, nil)
+	require.EqualError(t, err, "user session already exists")
+
+	err = server.CloseSession(cfg.SessionID)
+	require.NoError(t, err)
+
+	leftEv := newEvent(SessionLeftEvent, cfg)
+	leftEv.Reason = CloseReasonLeft
+	expected := []Event{
+		newCallEvent(CallStartedEvent, cfg.GroupID, cfg.CallID),
+		newEvent(SessionJoinedEvent, cfg),
+		leftEv,
+		newCallEvent(CallEndedEvent, cfg.GroupID, cfg.CallID),
+	}
+
+	for _, exp := range expected {
+		ev := <-server.EventsCh()
+		require.NotZero(t, ev.Timestamp)
+		ev.Timestamp = 0
+		exp.Timestamp = 0
+		require.Equal(t, exp, ev)
+	}
+}
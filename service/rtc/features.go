@@ -0,0 +1,52 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+// The features gated behind flags, which can be enabled or disabled per
+// group (registered client) in place of their config setting.
+const (
+	// FeatureSessionMigration gates the re-anchoring of the sessions whose
+	// client changes network address (EnableSessionMigration).
+	FeatureSessionMigration = "session_migration"
+	// FeatureEgressShaping gates the shaping of the media forwarded on calls
+	// (EgressShaping.Enable).
+	FeatureEgressShaping = "egress_shaping"
+)
+
+// Features returns the names of the features gated behind flags.
+func Features() []string {
+	return []string{
+		FeatureSessionMigration,
+		FeatureEgressShaping,
+	}
+}
+
+// FeatureFlags tells whether the features gated behind flags are enabled for
+// a group. Flags are consulted as sessions join and calls start.
+type FeatureFlags interface {
+	// FeatureEnabled returns whether the given feature is enabled for the
+	// given group, ok being false if the config setting applies.
+	FeatureEnabled(name, groupID string) (enabled, ok bool)
+}
+
+// WithFeatureFlags makes the server consult the given flags in place of the
+// config settings of the features gated behind them.
+func WithFeatureFlags(flags FeatureFlags) ServerOption {
+	return func(s *Server) error {
+		s.featureFlags = flags
+		return nil
+	}
+}
+
+// isFeatureEnabled returns whether the given feature is enabled for the
+// given group, def being the config setting.
+func (s *Server) isFeatureEnabled(name, groupID string, def bool) bool {
+	if s.featureFlags == nil {
+		return def
+	}
+	if enabled, ok := s.featureFlags.FeatureEnabled(name, groupID); ok {
+		return enabled
+	}
+	return def
+}
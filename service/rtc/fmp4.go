@@ -0,0 +1,301 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	mp4CodecOpus = "opus"
+	mp4CodecVP8  = "vp8"
+
+	// opusPreSkip is the number of samples the decoder should discard, as
+	// recommended by RFC 7845.
+	opusPreSkip = 312
+
+	mp4SampleFlagsSync    = 0x02000000
+	mp4SampleFlagsNonSync = 0x01010000
+)
+
+// mp4Track describes a track of a fragmented MP4 (CMAF) stream.
+type mp4Track struct {
+	id        uint32
+	codec     string
+	timescale uint32
+	// width and height are only set for video tracks.
+	width  uint16
+	height uint16
+}
+
+// mp4Sample is a single audio or video frame.
+type mp4Sample struct {
+	data     []byte
+	duration uint32
+	key      bool
+}
+
+// mp4Fields accumulates the big-endian encoded fields of a box.
+type mp4Fields []byte
+
+func (f mp4Fields) u8(v uint8) mp4Fields {
+	return append(f, v)
+}
+
+func (f mp4Fields) u16(v uint16) mp4Fields {
+	return append(f, byte(v>>8), byte(v))
+}
+
+func (f mp4Fields) u32(v uint32) mp4Fields {
+	return append(f, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (f mp4Fields) u64(v uint64) mp4Fields {
+	return f.u32(uint32(v >> 32)).u32(uint32(v))
+}
+
+func (f mp4Fields) zeros(n int) mp4Fields {
+	return append(f, make([]byte, n)...)
+}
+
+func (f mp4Fields) str(s string) mp4Fields {
+	return append(f, s...)
+}
+
+func (f mp4Fields) matrix() mp4Fields {
+	return f.u32(0x00010000).u32(0).u32(0).
+		u32(0).u32(0x00010000).u32(0).
+		u32(0).u32(0).u32(0x40000000)
+}
+
+func mp4Box(typ string, children ...[]byte) []byte {
+	size := 8
+	for _, c := range children {
+		size += len(c)
+	}
+	b := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(b, uint32(size))
+	copy(b[4:], typ)
+	for _, c := range children {
+		b = append(b, c...)
+	}
+	return b
+}
+
+func mp4FullBox(typ string, version uint8, flags uint32, children ...[]byte) []byte {
+	hdr := []byte{version, byte(flags >> 16), byte(flags >> 8), byte(flags)}
+	return mp4Box(typ, append([][]byte{hdr}, children...)...)
+}
+
+func (t mp4Track) isVideo() bool {
+	return t.codec == mp4CodecVP8
+}
+
+// codecString returns the value identifying the codec of the track in the
+// CODECS attribute of HLS playlists.
+func (t mp4Track) codecString() string {
+	if t.isVideo() {
+		return "vp08.00.10.08"
+	}
+	return "opus"
+}
+
+func (t mp4Track) sampleEntry() []byte {
+	if t.isVideo() {
+		vpcC := mp4FullBox("vpcC", 1, 0, mp4Fields{}.
+			u8(0).  // profile
+			u8(10). // level
+			// 8 bits depth, 4:2:0 chroma subsampling, limited range.
+			u8(8<<4|1<<1).
+			u8(1).u8(1).u8(1). // BT.709 colour primaries, transfer and matrix.
+			u16(0))
+		return mp4Box("vp08", mp4Fields{}.
+			zeros(6).u16(1). // data reference index
+			zeros(16).
+			u16(t.width).u16(t.height).
+			u32(0x00480000).u32(0x00480000). // 72 dpi
+			u32(0).
+			u16(1). // frame count
+			zeros(32).
+			u16(0x0018).u16(0xffff), vpcC)
+	}
+
+	dOps := mp4Box("dOps", mp4Fields{}.
+		u8(0). // version
+		u8(uint8(rtpAudioCodec.Channels)).
+		u16(opusPreSkip).
+		u32(rtpAudioCodec.ClockRate).
+		u16(0). // output gain
+		u8(0))  // channel mapping family
+	return mp4Box("Opus", mp4Fields{}.
+		zeros(6).u16(1). // data reference index
+		zeros(8).
+		u16(rtpAudioCodec.Channels).
+		u16(16). // sample size
+		zeros(4).
+		u32(rtpAudioCodec.ClockRate<<16), dOps)
+}
+
+// mp4InitSegment returns the initialization segment of the given track.
+func mp4InitSegment(t mp4Track) []byte {
+	ftyp := mp4Box("ftyp", mp4Fields{}.str("iso6").u32(0).str("iso6cmfcmp41"))
+
+	mvhd := mp4FullBox("mvhd", 0, 0, mp4Fields{}.
+		u32(0).u32(0). // creation and modification time
+		u32(1000).     // timescale
+		u32(0).        // duration
+		u32(0x00010000).u16(0x0100).zeros(10).
+		matrix().
+		zeros(24).
+		u32(t.id+1)) // next track ID
+
+	var volume uint16
+	handler, handlerName := "soun", "SoundHandler"
+	mediaHeader := mp4FullBox("smhd", 0, 0, mp4Fields{}.u32(0))
+	if t.isVideo() {
+		handler, handlerName = "vide", "VideoHandler"
+		mediaHeader = mp4FullBox("vmhd", 0, 1, mp4Fields{}.zeros(8))
+	} else {
+		volume = 0x0100
+	}
+
+	// Tracks are enabled and part of the presentation.
+	tkhd := mp4FullBox("tkhd", 0, 3, mp4Fields{}.
+		u32(0).u32(0).
+		u32(t.id).
+		u32(0).
+		u32(0). // duration
+		zeros(8).
+		u16(0).u16(0). // layer and alternate group
+		u16(volume).u16(0).
+		matrix().
+		u32(uint32(t.width)<<16).u32(uint32(t.height)<<16))
+
+	mdhd := mp4FullBox("mdhd", 0, 0, mp4Fields{}.
+		u32(0).u32(0).
+		u32(t.timescale).
+		u32(0).
+		u16(0x55c4). // "und" language
+		u16(0))
+	hdlr := mp4FullBox("hdlr", 0, 0, mp4Fields{}.
+		u32(0).str(handler).zeros(12).str(handlerName).u8(0))
+
+	dinf := mp4Box("dinf", mp4FullBox("dref", 0, 0, mp4Fields{}.u32(1), mp4FullBox("url ", 0, 1)))
+	stbl := mp4Box("stbl",
+		mp4FullBox("stsd", 0, 0, mp4Fields{}.u32(1), t.sampleEntry()),
+		mp4FullBox("stts", 0, 0, mp4Fields{}.u32(0)),
+		mp4FullBox("stsc", 0, 0, mp4Fields{}.u32(0)),
+		mp4FullBox("stsz", 0, 0, mp4Fields{}.u32(0).u32(0)),
+		mp4FullBox("stco", 0, 0, mp4Fields{}.u32(0)))
+	minf := mp4Box("minf", mediaHeader, dinf, stbl)
+
+	trak := mp4Box("trak", tkhd, mp4Box("mdia", mdhd, hdlr, minf))
+	mvex := mp4Box("mvex", mp4FullBox("trex", 0, 0, mp4Fields{}.
+		u32(t.id).
+		u32(1). // sample description index
+		u32(0).u32(0).u32(0)))
+
+	return append(ftyp, mp4Box("moov", mvhd, trak, mvex)...)
+}
+
+// mp4Fragment returns a movie fragment (moof and mdat boxes) holding the
+// given samples, the first of which starts at decodeTime.
+func mp4Fragment(trackID, seq uint32, decodeTime uint64, samples []mp4Sample) []byte {
+	var dataSize int
+	for _, s := range samples {
+		dataSize += len(s.data)
+	}
+
+	// moof, mfhd, traf, tfhd, tfdt and trun sizes, the latter having 12
+	// bytes per sample.
+	moofSize := 8 + 16 + 8 + 16 + 20 + 20 + 12*len(samples)
+
+	trunFields := mp4Fields{}.
+		u32(uint32(len(samples))).
+		u32(uint32(moofSize + 8)) // data offset, past the mdat header
+	for _, s := range samples {
+		flags := uint32(mp4SampleFlagsNonSync)
+		if s.key {
+			flags = mp4SampleFlagsSync
+		}
+		trunFields = trunFields.u32(s.duration).u32(uint32(len(s.data))).u32(flags)
+	}
+
+	moof := mp4Box("moof",
+		mp4FullBox("mfhd", 0, 0, mp4Fields{}.u32(seq)),
+		mp4Box("traf",
+			// default-base-is-moof
+			mp4FullBox("tfhd", 0, 0x020000, mp4Fields{}.u32(trackID)),
+			mp4FullBox("tfdt", 1, 0, mp4Fields{}.u64(decodeTime)),
+			// data-offset, sample-duration, sample-size and sample-flags
+			// present.
+			mp4FullBox("trun", 0, 0x000701, trunFields)))
+
+	mdat := make([]byte, 8, 8+dataSize)
+	binary.BigEndian.PutUint32(mdat, uint32(8+dataSize))
+	copy(mdat[4:], "mdat")
+	for _, s := range samples {
+		mdat = append(mdat, s.data...)
+	}
+
+	return append(moof, mdat...)
+}
+
+// opusPacketDuration returns the duration of an Opus packet, in samples at
+// 48kHz, as described by its TOC byte (RFC 6716, section 3.1).
+func opusPacketDuration(payload []byte) (uint32, error) {
+	if len(payload) == 0 {
+		return 0, fmt.Errorf("empty packet")
+	}
+
+	config := payload[0] >> 3
+	var frameSize uint32
+	switch {
+	case config < 12:
+		// SILK: 10, 20, 40 or 60ms.
+		frameSize = []uint32{480, 960, 1920, 2880}[config%4]
+	case config < 16:
+		// Hybrid: 10 or 20ms.
+		frameSize = []uint32{480, 960}[config%2]
+	default:
+		// CELT: 2.5, 5, 10 or 20ms.
+		frameSize = []uint32{120, 240, 480, 960}[config%4]
+	}
+
+	var frames uint32
+	switch payload[0] & 0x3 {
+	case 0:
+		frames = 1
+	case 1, 2:
+		frames = 2
+	default:
+		if len(payload) < 2 {
+			return 0, fmt.Errorf("missing frame count")
+		}
+		frames = uint32(payload[1] & 0x3f)
+	}
+
+	duration := frameSize * frames
+	// Packets are limited to 120ms.
+	if duration == 0 || duration > 5760 {
+		return 0, fmt.Errorf("invalid packet duration %d", duration)
+	}
+
+	return duration, nil
+}
+
+// vp8KeyFrameSize returns the dimensions of a VP8 key frame (RFC 6386,
+// section 9.1).
+func vp8KeyFrameSize(frame []byte) (uint16, uint16, bool) {
+	if len(frame) < 10 || frame[0]&0x1 != 0 {
+		return 0, 0, false
+	}
+	if frame[3] != 0x9d || frame[4] != 0x01 || frame[5] != 0x2a {
+		return 0, 0, false
+	}
+	width := binary.LittleEndian.Uint16(frame[6:]) & 0x3fff
+	height := binary.LittleEndian.Uint16(frame[8:]) & 0x3fff
+	return width, height, true
+}
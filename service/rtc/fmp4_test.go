@@ -0,0 +1,145 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// findMP4Box returns the payload of the first box matching the given path,
+// descending into container boxes.
+func findMP4Box(t *testing.T, data []byte, path ...string) []byte {
+	t.Helper()
+	for len(data) > 0 {
+		require.GreaterOrEqual(t, len(data), 8)
+		size := int(binary.BigEndian.Uint32(data))
+		require.GreaterOrEqual(t, size, 8)
+		require.LessOrEqual(t, size, len(data))
+		if string(data[4:8]) == path[0] {
+			if len(path) == 1 {
+				return data[8:size]
+			}
+			return findMP4Box(t, data[8:size], path[1:]...)
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+func TestMP4InitSegment(t *testing.T) {
+	t.Run("opus", func(t *testing.T) {
+		data := mp4InitSegment(mp4Track{id: 1, codec: mp4CodecOpus, timescale: 48000})
+		require.Equal(t, "iso6", string(findMP4Box(t, data, "ftyp")[:4]))
+
+		mdhd := findMP4Box(t, data, "moov", "trak", "mdia", "mdhd")
+		require.NotNil(t, mdhd)
+		require.Equal(t, uint32(48000), binary.BigEndian.Uint32(mdhd[12:]))
+
+		hdlr := findMP4Box(t, data, "moov", "trak", "mdia", "hdlr")
+		require.Equal(t, "soun", string(hdlr[8:12]))
+
+		stsd := findMP4Box(t, data, "moov", "trak", "mdia", "minf", "stbl", "stsd")
+		require.NotNil(t, stsd)
+		// Skipping version, flags and entry count.
+		dOps := findMP4Box(t, stsd[8+8+28:], "dOps")
+		require.Equal(t, []byte{0, 2, 0x01, 0x38, 0, 0, 0xbb, 0x80, 0, 0, 0}, dOps)
+
+		trex := findMP4Box(t, data, "moov", "mvex", "trex")
+		require.Equal(t, uint32(1), binary.BigEndian.Uint32(trex[4:]))
+	})
+
+	t.Run("vp8", func(t *testing.T) {
+		data := mp4InitSegment(mp4Track{id: 2, codec: mp4CodecVP8, timescale: 90000, width: 1280, height: 720})
+
+		tkhd := findMP4Box(t, data, "moov", "trak", "tkhd")
+		require.Equal(t, uint32(2), binary.BigEndian.Uint32(tkhd[12:]))
+		require.Equal(t, uint32(1280<<16), binary.BigEndian.Uint32(tkhd[len(tkhd)-8:]))
+		require.Equal(t, uint32(720<<16), binary.BigEndian.Uint32(tkhd[len(tkhd)-4:]))
+
+		hdlr := findMP4Box(t, data, "moov", "trak", "mdia", "hdlr")
+		require.Equal(t, "vide", string(hdlr[8:12]))
+		require.NotNil(t, findMP4Box(t, data, "moov", "trak", "mdia", "minf", "vmhd"))
+
+		stsd := findMP4Box(t, data, "moov", "trak", "mdia", "minf", "stbl", "stsd")
+		vp08 := findMP4Box(t, stsd[8:], "vp08")
+		require.NotNil(t, vp08)
+		require.Equal(t, uint16(1280), binary.BigEndian.Uint16(vp08[24:]))
+		require.Equal(t, uint16(720), binary.BigEndian.Uint16(vp08[26:]))
+		require.NotNil(t, findMP4Box(t, vp08[78:], "vpcC"))
+	})
+}
+
+func TestMP4Fragment(t *testing.T) {
+	samples := []mp4Sample{
+		{data: []byte{1, 2, 3}, duration: 3000, key: true},
+		{data: []byte{4, 5}, duration: 3000},
+	}
+	data := mp4Fragment(2, 7, 90000, samples)
+
+	mfhd := findMP4Box(t, data, "moof", "mfhd")
+	require.Equal(t, uint32(7), binary.BigEndian.Uint32(mfhd[4:]))
+
+	tfdt := findMP4Box(t, data, "moof", "traf", "tfdt")
+	require.Equal(t, uint64(90000), binary.BigEndian.Uint64(tfdt[4:]))
+
+	trun := findMP4Box(t, data, "moof", "traf", "trun")
+	require.Equal(t, uint32(2), binary.BigEndian.Uint32(trun[4:]))
+	offset := binary.BigEndian.Uint32(trun[8:])
+	require.Equal(t, []byte{1, 2, 3, 4, 5}, data[offset:])
+	require.Equal(t, []byte{1, 2, 3, 4, 5}, findMP4Box(t, data, "mdat"))
+
+	// First sample: duration, size and flags.
+	require.Equal(t, uint32(3000), binary.BigEndian.Uint32(trun[12:]))
+	require.Equal(t, uint32(3), binary.BigEndian.Uint32(trun[16:]))
+	require.Equal(t, uint32(mp4SampleFlagsSync), binary.BigEndian.Uint32(trun[20:]))
+	require.Equal(t, uint32(mp4SampleFlagsNonSync), binary.BigEndian.Uint32(trun[32:]))
+}
+
+func TestOpusPacketDuration(t *testing.T) {
+	tcs := []struct {
+		name     string
+		payload  []byte
+		duration uint32
+		err      string
+	}{
+		{name: "empty", err: "empty packet"},
+		{name: "celt 20ms", payload: []byte{0xf8, 0xff, 0xfe}, duration: 960},
+		{name: "silk 10ms two frames", payload: []byte{0x01}, duration: 960},
+		{name: "hybrid 20ms", payload: []byte{0x68}, duration: 960},
+		{name: "silk 20ms three frames", payload: []byte{0x0b, 0x03}, duration: 2880},
+		{name: "missing frame count", payload: []byte{0x0b}, err: "missing frame count"},
+		{name: "too long", payload: []byte{0x1b, 0x03}, err: "invalid packet duration 8640"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			duration, err := opusPacketDuration(tc.payload)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.duration, duration)
+		})
+	}
+}
+
+func TestVP8KeyFrameSize(t *testing.T) {
+	frame := []byte{0x50, 0x42, 0x00, 0x9d, 0x01, 0x2a, 0x00, 0x05, 0xd0, 0x02}
+	width, height, ok := vp8KeyFrameSize(frame)
+	require.True(t, ok)
+	require.Equal(t, uint16(1280), width)
+	require.Equal(t, uint16(720), height)
+
+	// Inter frame.
+	frame[0] |= 0x1
+	_, _, ok = vp8KeyFrameSize(frame)
+	require.False(t, ok)
+
+	_, _, ok = vp8KeyFrameSize([]byte{0x50})
+	require.False(t, ok)
+}
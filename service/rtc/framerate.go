@@ -0,0 +1,183 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"sync"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+)
+
+// frameHistorySize is the number of frames whose outcome is kept for the
+// packets received out of order.
+const frameHistorySize = 16
+
+// frameThrottler forwards a video track to a single subscriber, dropping
+// discardable frames (non-reference or upper temporal layers) in order to
+// keep the framerate under a given limit. Once set up for a subscriber it's
+// kept for as long as the track is forwarded to it, the sequence numbers
+// having been rewritten, and a zero limit lets every frame through.
+type frameThrottler struct {
+	track *webrtc.TrackLocalStaticRTP
+
+	// maxFramerate is the framerate the track is limited to, zero meaning
+	// no limit.
+	maxFramerate int
+	// minInterval is the minimum interval between forwarded frames, in RTP
+	// timestamp units.
+	minInterval uint32
+	started     bool
+	// frameTS is the timestamp of the frame currently being processed.
+	frameTS   uint32
+	dropFrame bool
+	// lastTS is the timestamp of the last forwarded frame.
+	lastTS uint32
+	// lastSeq is the highest sequence number received.
+	lastSeq uint16
+	// droppedTID is the lowest temporal layer dropped since the last frame
+	// of a lower layer, -1 if none. Frames depending on it need dropping too.
+	droppedTID int
+	// seqOffset is the number of dropped packets, used to rewrite sequence
+	// numbers so that the subscriber doesn't see gaps.
+	seqOffset uint16
+	// frames holds the outcome of the last frames, as a ring buffer of the
+	// frameCount frames processed.
+	frames     [frameHistorySize]throttledFrame
+	frameCount int
+
+	mut sync.Mutex
+}
+
+// throttledFrame is the outcome of a frame: whether it got dropped and, if
+// not, the offset its sequence numbers are rewritten with.
+type throttledFrame struct {
+	ts        uint32
+	dropped   bool
+	seqOffset uint16
+}
+
+func newFrameThrottler(track *webrtc.TrackLocalStaticRTP, maxFramerate int) *frameThrottler {
+	t := &frameThrottler{
+		track:      track,
+		droppedTID: -1,
+	}
+	t.setMaxFramerate(maxFramerate)
+	return t
+}
+
+func (t *frameThrottler) setMaxFramerate(maxFramerate int) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.maxFramerate = maxFramerate
+	t.minInterval = 0
+	if maxFramerate > 0 {
+		t.minInterval = t.track.Codec().ClockRate / uint32(maxFramerate)
+	}
+}
+
+func (t *frameThrottler) getMaxFramerate() int {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.maxFramerate
+}
+
+// rewriteSeq returns the sequence number the given packet is forwarded with,
+// and false if it should be dropped. It expects packets in the order they
+// are received from the publisher.
+func (t *frameThrottler) rewriteSeq(pkt *rtp.Packet) (uint16, bool) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	// Packets of a previous frame received late get the outcome of their
+	// frame, leaving the state of the following ones untouched. They can't
+	// be accounted for in the offset anymore if their frame got dropped, so
+	// they show up as lost to the subscriber.
+	if t.started && pkt.Timestamp != t.frameTS && seqBefore(pkt.SequenceNumber, t.lastSeq) {
+		f, ok := t.getFrame(pkt.Timestamp)
+		if !ok || f.dropped {
+			return 0, false
+		}
+		return pkt.SequenceNumber - f.seqOffset, true
+	}
+
+	if !t.started || pkt.Timestamp != t.frameTS {
+		t.dropFrame = t.shouldDropFrame(pkt)
+		if !t.dropFrame {
+			t.lastTS = pkt.Timestamp
+		}
+		t.frameTS = pkt.Timestamp
+		t.frames[t.frameCount%frameHistorySize] = throttledFrame{
+			ts:        pkt.Timestamp,
+			dropped:   t.dropFrame,
+			seqOffset: t.seqOffset,
+		}
+		t.frameCount++
+	}
+	if !t.started || seqBefore(t.lastSeq, pkt.SequenceNumber) {
+		t.lastSeq = pkt.SequenceNumber
+	}
+	t.started = true
+
+	if t.dropFrame {
+		t.seqOffset++
+		return 0, false
+	}
+
+	return pkt.SequenceNumber - t.seqOffset, true
+}
+
+// getFrame returns the outcome of the frame with the given timestamp, if
+// still known.
+func (t *frameThrottler) getFrame(ts uint32) (throttledFrame, bool) {
+	for i := 0; i < t.frameCount && i < frameHistorySize; i++ {
+		if t.frames[i].ts == ts {
+			return t.frames[i], true
+		}
+	}
+	return throttledFrame{}, false
+}
+
+func (t *frameThrottler) shouldDropFrame(pkt *rtp.Packet) bool {
+	var vp8 codecs.VP8Packet
+	if _, err := vp8.Unmarshal(pkt.Payload); err != nil {
+		return false
+	}
+
+	var tid int
+	if vp8.T == 1 {
+		tid = int(vp8.TID)
+	}
+
+	if t.droppedTID >= 0 {
+		if tid >= t.droppedTID {
+			return true
+		}
+		t.droppedTID = -1
+	}
+
+	if !t.started || pkt.Timestamp-t.lastTS >= t.minInterval {
+		return false
+	}
+
+	if tid > 0 {
+		t.droppedTID = tid
+		return true
+	}
+
+	return vp8.N == 1
+}
+
+func (t *frameThrottler) writeRTP(pkt *rtp.Packet) error {
+	seq, ok := t.rewriteSeq(pkt)
+	if !ok {
+		return nil
+	}
+
+	out := *pkt
+	out.SequenceNumber = seq
+
+	return t.track.WriteRTP(&out)
+}
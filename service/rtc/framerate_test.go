@@ -0,0 +1,168 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func newVP8Packet(seq uint16, ts uint32, tid uint8, nonRef bool) *rtp.Packet {
+	// X and S bits set, T bit set in the extension.
+	desc := []byte{0x90, 0x20, tid << 6}
+	if nonRef {
+		desc[0] |= 0x20
+	}
+	return &rtp.Packet{
+		Header: rtp.Header{
+			SequenceNumber: seq,
+			Timestamp:      ts,
+		},
+		Payload: append(desc, 0x00, 0x01, 0x02),
+	}
+}
+
+func shouldForward(throttler *frameThrottler, pkt *rtp.Packet) bool {
+	_, ok := throttler.rewriteSeq(pkt)
+	return ok
+}
+
+func TestFrameThrottler(t *testing.T) {
+	track, err := webrtc.NewTrackLocalStaticRTP(rtpVideoCodecVP8, "screen_test", "streamID")
+	require.NoError(t, err)
+
+	t.Run("non-droppable frames", func(t *testing.T) {
+		throttler := newFrameThrottler(track, 10)
+		for i := 0; i < 5; i++ {
+			require.True(t, shouldForward(throttler, newVP8Packet(uint16(i), uint32(i*3000), 0, false)))
+		}
+		require.Zero(t, throttler.seqOffset)
+	})
+
+	t.Run("non-reference frames", func(t *testing.T) {
+		// 30fps stream throttled to 10fps.
+		throttler := newFrameThrottler(track, 10)
+		var forwarded int
+		for i := 0; i < 30; i++ {
+			if shouldForward(throttler, newVP8Packet(uint16(i), uint32(i*3000), 0, i > 0)) {
+				forwarded++
+			}
+		}
+		require.Equal(t, 10, forwarded)
+		require.Equal(t, uint16(20), throttler.seqOffset)
+	})
+
+	t.Run("multiple packets per frame", func(t *testing.T) {
+		throttler := newFrameThrottler(track, 10)
+		require.True(t, shouldForward(throttler, newVP8Packet(0, 0, 0, false)))
+		require.True(t, shouldForward(throttler, newVP8Packet(1, 0, 0, false)))
+		require.False(t, shouldForward(throttler, newVP8Packet(2, 3000, 0, true)))
+		require.False(t, shouldForward(throttler, newVP8Packet(3, 3000, 0, true)))
+		require.True(t, shouldForward(throttler, newVP8Packet(4, 9000, 0, true)))
+		require.Equal(t, uint16(2), throttler.seqOffset)
+	})
+
+	t.Run("temporal layers", func(t *testing.T) {
+		throttler := newFrameThrottler(track, 10)
+		// L0 L2 L1 L2 pattern at 30fps.
+		tids := []uint8{0, 2, 1, 2, 0, 2, 1, 2}
+		var forwarded []uint8
+		for i, tid := range tids {
+			if shouldForward(throttler, newVP8Packet(uint16(i), uint32(i*3000), tid, false)) {
+				forwarded = append(forwarded, tid)
+			}
+		}
+		// The L2 frame following a dropped L1 frame depends on it and
+		// should be dropped as well.
+		require.Equal(t, []uint8{0, 0}, forwarded)
+	})
+
+	t.Run("sequence rewriting", func(t *testing.T) {
+		out, err := webrtc.NewTrackLocalStaticRTP(rtpVideoCodecVP8, "screen_test", "streamID")
+		require.NoError(t, err)
+		throttler := newFrameThrottler(out, 10)
+		require.NoError(t, throttler.writeRTP(newVP8Packet(10, 0, 0, false)))
+		pkt := newVP8Packet(11, 3000, 0, true)
+		require.NoError(t, throttler.writeRTP(pkt))
+		require.NoError(t, throttler.writeRTP(newVP8Packet(12, 9000, 0, false)))
+		require.Equal(t, uint16(1), throttler.seqOffset)
+		// The original packet should not be modified.
+		require.Equal(t, uint16(11), pkt.SequenceNumber)
+	})
+
+	t.Run("throttling on and off", func(t *testing.T) {
+		// 30fps stream of non-reference frames, throttled to 10fps, then
+		// not throttled anymore and throttled again.
+		throttler := newFrameThrottler(track, 10)
+		var seqs []uint16
+		write := func(from, to int) {
+			for i := from; i < to; i++ {
+				if seq, ok := throttler.rewriteSeq(newVP8Packet(uint16(i), uint32(i*3000), 0, i > 0)); ok {
+					seqs = append(seqs, seq)
+				}
+			}
+		}
+
+		write(0, 6)
+		require.Equal(t, []uint16{0, 1}, seqs)
+
+		throttler.setMaxFramerate(0)
+		write(6, 9)
+		// The offset is kept so that the subscriber sees no jump.
+		require.Equal(t, []uint16{0, 1, 2, 3, 4}, seqs)
+
+		throttler.setMaxFramerate(10)
+		write(9, 15)
+		require.Equal(t, []uint16{0, 1, 2, 3, 4, 5, 6}, seqs)
+		require.Equal(t, 10, throttler.getMaxFramerate())
+	})
+
+	t.Run("out of order packets", func(t *testing.T) {
+		throttler := newFrameThrottler(track, 10)
+		rewrite := func(seq uint16, ts uint32, nonRef bool) (uint16, bool) {
+			return throttler.rewriteSeq(newVP8Packet(seq, ts, 0, nonRef))
+		}
+
+		seq, ok := rewrite(0, 0, false)
+		require.True(t, ok)
+		require.Equal(t, uint16(0), seq)
+		// Frame 3000 gets dropped, its second packet arriving late.
+		_, ok = rewrite(1, 3000, true)
+		require.False(t, ok)
+		// Frame 9000 is forwarded, its first packet arriving late.
+		seq, ok = rewrite(4, 9000, true)
+		require.True(t, ok)
+		require.Equal(t, uint16(3), seq)
+
+		// The late packet of the forwarded frame gets the offset of its
+		// frame.
+		seq, ok = rewrite(3, 9000, true)
+		require.True(t, ok)
+		require.Equal(t, uint16(2), seq)
+
+		// The late packet of the dropped frame stays dropped, without
+		// shifting the following packets nor the frame timestamps.
+		_, ok = rewrite(2, 3000, true)
+		require.False(t, ok)
+		seq, ok = rewrite(5, 9000, true)
+		require.True(t, ok)
+		require.Equal(t, uint16(4), seq)
+		require.Equal(t, uint32(9000), throttler.lastTS)
+		require.Equal(t, uint32(9000), throttler.frameTS)
+
+		// Late packets of forwarded frames are rewritten with the offset
+		// of their frame, even once later frames got dropped.
+		_, ok = rewrite(7, 12000, true)
+		require.False(t, ok)
+		seq, ok = rewrite(8, 18000, true)
+		require.True(t, ok)
+		require.Equal(t, uint16(6), seq)
+		seq, ok = rewrite(6, 9000, true)
+		require.True(t, ok)
+		require.Equal(t, uint16(5), seq)
+	})
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+)
+
+// Names of the RTP header extensions that can be negotiated.
+const (
+	RTPHeaderExtensionAudioLevel       = "audio-level"
+	RTPHeaderExtensionTransportCC      = "transport-cc"
+	RTPHeaderExtensionMID              = "mid"
+	RTPHeaderExtensionRID              = "rid"
+	RTPHeaderExtensionAbsSendTime      = "abs-send-time"
+	RTPHeaderExtensionVideoOrientation = "video-orientation"
+	RTPHeaderExtensionAbsCaptureTime   = "abs-capture-time"
+)
+
+const (
+	rtpHeaderExtensionProfileOneByte = 0xBEDE
+	rtpHeaderExtensionProfileTwoByte = 0x1000
+)
+
+type rtpHeaderExtension struct {
+	name  string
+	uri   string
+	audio bool
+	video bool
+	// forward tells whether the extension carries end-to-end information, in
+	// which case it's forwarded to subscribers. Hop-by-hop extensions, only
+	// meaningful on the leg they were received on, are dropped.
+	forward bool
+}
+
+// rtpHeaderExtensions is the registry of the supported RTP header
+// extensions. Forwarded packets carry the extensions with their index in
+// the registry (plus one) as ID between the receiving and the sending legs,
+// each of which negotiates its own IDs.
+var rtpHeaderExtensions = []rtpHeaderExtension{
+	{name: RTPHeaderExtensionAudioLevel, uri: sdp.AudioLevelURI, audio: true, forward: true},
+	{name: RTPHeaderExtensionTransportCC, uri: sdp.TransportCCURI, audio: true, video: true},
+	{name: RTPHeaderExtensionMID, uri: sdp.SDESMidURI, audio: true, video: true},
+	{name: RTPHeaderExtensionRID, uri: sdp.SDESRTPStreamIDURI, video: true},
+	{name: RTPHeaderExtensionAbsSendTime, uri: sdp.ABSSendTimeURI, audio: true, video: true, forward: true},
+	{name: RTPHeaderExtensionVideoOrientation, uri: "urn:3gpp:video-orientation", video: true, forward: true},
+	{name: RTPHeaderExtensionAbsCaptureTime, uri: "http://www.webrtc.org/experiments/rtp-hdrext/abs-capture-time", audio: true, video: true, forward: true},
+}
+
+// parseRTPHeaderExtensions returns the registered RTP header extensions
+// matching the given names.
+func parseRTPHeaderExtensions(names []string) ([]rtpHeaderExtension, error) {
+	exts := make([]rtpHeaderExtension, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate extension %q", name)
+		}
+		seen[name] = true
+
+		var found bool
+		for _, ext := range rtpHeaderExtensions {
+			if ext.name == name {
+				exts = append(exts, ext)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown extension %q", name)
+		}
+	}
+	return exts, nil
+}
+
+// rtpHeaderExtensionURI returns the URI of the registered extension with
+// the given name.
+func rtpHeaderExtensionURI(name string) string {
+	for _, ext := range rtpHeaderExtensions {
+		if ext.name == name {
+			return ext.uri
+		}
+	}
+	return ""
+}
+
+func hasRTPHeaderExtension(exts []rtpHeaderExtension, name string) bool {
+	for _, ext := range exts {
+		if ext.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// registerRTPHeaderExtensions makes m negotiate the given extensions.
+func registerRTPHeaderExtensions(m *webrtc.MediaEngine, exts []rtpHeaderExtension) error {
+	for _, ext := range exts {
+		capability := webrtc.RTPHeaderExtensionCapability{URI: ext.uri}
+		if ext.audio {
+			if err := m.RegisterHeaderExtension(capability, webrtc.RTPCodecTypeAudio); err != nil {
+				return fmt.Errorf("failed to register %s extension: %w", ext.name, err)
+			}
+		}
+		if ext.video {
+			if err := m.RegisterHeaderExtension(capability, webrtc.RTPCodecTypeVideo); err != nil {
+				return fmt.Errorf("failed to register %s extension: %w", ext.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// rtpHeaderExtensionsMap maps the IDs of the header extensions of a leg to
+// the IDs they should be rewritten to. Extensions not in the map are
+// dropped.
+type rtpHeaderExtensionsMap map[uint8]uint8
+
+// newInboundRTPHeaderExtensionsMap maps the extension IDs negotiated on a
+// receiving leg to the registry IDs, for the extensions to forward.
+func newInboundRTPHeaderExtensionsMap(params []webrtc.RTPHeaderExtensionParameter) rtpHeaderExtensionsMap {
+	m := rtpHeaderExtensionsMap{}
+	for _, param := range params {
+		for i, ext := range rtpHeaderExtensions {
+			if ext.uri == param.URI && ext.forward {
+				m[uint8(param.ID)] = uint8(i + 1)
+			}
+		}
+	}
+	return m
+}
+
+// newOutboundRTPHeaderExtensionsMap maps the registry IDs to the extension
+// IDs negotiated on a sending leg, for the extensions to forward.
+func newOutboundRTPHeaderExtensionsMap(negotiated []interceptor.RTPHeaderExtension) rtpHeaderExtensionsMap {
+	m := rtpHeaderExtensionsMap{}
+	for _, neg := range negotiated {
+		for i, ext := range rtpHeaderExtensions {
+			if ext.uri == neg.URI && ext.forward {
+				m[uint8(i+1)] = uint8(neg.ID)
+			}
+		}
+	}
+	return m
+}
+
+// rewrite returns a copy of h with the IDs of its header extensions
+// rewritten, h being left untouched.
+func (m rtpHeaderExtensionsMap) rewrite(h rtp.Header) rtp.Header {
+	if !h.Extension {
+		return h
+	}
+
+	out := h
+	out.Extension = false
+	out.ExtensionProfile = 0
+	out.Extensions = nil
+
+	// Extensions with a profile other than RFC 8285 ones can't be mapped.
+	if h.ExtensionProfile != rtpHeaderExtensionProfileOneByte && h.ExtensionProfile != rtpHeaderExtensionProfileTwoByte {
+		return out
+	}
+
+	profile := uint16(rtpHeaderExtensionProfileOneByte)
+	ids := h.GetExtensionIDs()
+	for _, id := range ids {
+		if to, ok := m[id]; ok && (to > 14 || len(h.GetExtension(id)) > 16) {
+			profile = rtpHeaderExtensionProfileTwoByte
+		}
+	}
+
+	for _, id := range ids {
+		to, ok := m[id]
+		if !ok {
+			continue
+		}
+		if !out.Extension {
+			out.Extension = true
+			out.ExtensionProfile = profile
+		}
+		// Can't fail, the profile fits all the extensions.
+		_ = out.SetExtension(to, h.GetExtension(id))
+	}
+
+	return out
+}
+
+// headerExtensionsInterceptor rewrites the header extensions of the
+// forwarded packets from the registry IDs to the IDs negotiated with each
+// subscriber.
+type headerExtensionsInterceptor struct {
+	interceptor.NoOp
+}
+
+// NewInterceptor implements interceptor.Factory. The interceptor is
+// stateless so the same instance is shared.
+func (i *headerExtensionsInterceptor) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return i, nil
+}
+
+func (i *headerExtensionsInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	m := newOutboundRTPHeaderExtensionsMap(info.RTPHeaderExtensions)
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		// The header is shared by all the subscribers of the track so it
+		// can't be modified in place.
+		h := m.rewrite(*header)
+		return writer.Write(&h, payload, attributes)
+	})
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRTPHeaderExtensions(t *testing.T) {
+	tcs := []struct {
+		name  string
+		names []string
+		uris  []string
+		err   string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:  "valid",
+			names: []string{RTPHeaderExtensionAudioLevel, RTPHeaderExtensionMID},
+			uris:  []string{sdp.AudioLevelURI, sdp.SDESMidURI},
+		},
+		{
+			name:  "duplicate",
+			names: []string{RTPHeaderExtensionMID, RTPHeaderExtensionMID},
+			err:   `duplicate extension "mid"`,
+		},
+		{
+			name:  "unknown",
+			names: []string{"color-space"},
+			err:   `unknown extension "color-space"`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			exts, err := parseRTPHeaderExtensions(tc.names)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			var uris []string
+			for _, ext := range exts {
+				uris = append(uris, ext.uri)
+			}
+			require.Equal(t, tc.uris, uris)
+		})
+	}
+}
+
+func TestRegisterRTPHeaderExtensions(t *testing.T) {
+	exts, err := parseRTPHeaderExtensions([]string{RTPHeaderExtensionAudioLevel, RTPHeaderExtensionVideoOrientation})
+	require.NoError(t, err)
+	m, err := initMediaEngine(RTXConfig{}, false, exts)
+	require.NoError(t, err)
+	pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(m)).NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer pc.Close()
+
+	_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio)
+	require.NoError(t, err)
+	_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo)
+	require.NoError(t, err)
+	offer, err := pc.CreateOffer(nil)
+	require.NoError(t, err)
+	require.Contains(t, offer.SDP, sdp.AudioLevelURI)
+	require.Contains(t, offer.SDP, "urn:3gpp:video-orientation")
+	require.NotContains(t, offer.SDP, sdp.TransportCCURI)
+}
+
+func TestRTPHeaderExtensionsMap(t *testing.T) {
+	// Registry IDs: audio-level is 1, transport-cc 2, abs-send-time 5.
+	inbound := newInboundRTPHeaderExtensionsMap([]webrtc.RTPHeaderExtensionParameter{
+		{URI: sdp.AudioLevelURI, ID: 10},
+		{URI: sdp.TransportCCURI, ID: 3},
+		{URI: sdp.ABSSendTimeURI, ID: 2},
+	})
+	require.Equal(t, rtpHeaderExtensionsMap{10: 1, 2: 5}, inbound)
+
+	outbound := newOutboundRTPHeaderExtensionsMap([]interceptor.RTPHeaderExtension{
+		{URI: sdp.AudioLevelURI, ID: 4},
+		{URI: sdp.TransportCCURI, ID: 5},
+	})
+	require.Equal(t, rtpHeaderExtensionsMap{1: 4}, outbound)
+
+	var h rtp.Header
+	require.NoError(t, h.SetExtension(10, []byte{0x80}))
+	require.NoError(t, h.SetExtension(3, []byte{0x00, 0x01}))
+	require.NoError(t, h.SetExtension(2, []byte{0x01, 0x02, 0x03}))
+
+	t.Run("inbound", func(t *testing.T) {
+		out := inbound.rewrite(h)
+		require.True(t, out.Extension)
+		require.Equal(t, uint16(rtpHeaderExtensionProfileOneByte), out.ExtensionProfile)
+		require.Equal(t, []uint8{1, 5}, out.GetExtensionIDs())
+		require.Equal(t, []byte{0x80}, out.GetExtension(1))
+		require.Equal(t, []byte{0x01, 0x02, 0x03}, out.GetExtension(5))
+
+		// The original header is left untouched.
+		require.Equal(t, []uint8{10, 3, 2}, h.GetExtensionIDs())
+	})
+
+	t.Run("outbound", func(t *testing.T) {
+		out := outbound.rewrite(inbound.rewrite(h))
+		require.Equal(t, []uint8{4}, out.GetExtensionIDs())
+		require.Equal(t, []byte{0x80}, out.GetExtension(4))
+	})
+
+	t.Run("none left", func(t *testing.T) {
+		out := rtpHeaderExtensionsMap{}.rewrite(h)
+		require.False(t, out.Extension)
+		require.Empty(t, out.GetExtensionIDs())
+
+		buf, err := out.Marshal()
+		require.NoError(t, err)
+		require.Len(t, buf, 12)
+	})
+
+	t.Run("two-byte profile", func(t *testing.T) {
+		out := rtpHeaderExtensionsMap{10: 20}.rewrite(h)
+		require.Equal(t, uint16(rtpHeaderExtensionProfileTwoByte), out.ExtensionProfile)
+		require.Equal(t, []byte{0x80}, out.GetExtension(20))
+
+		buf, err := out.Marshal()
+		require.NoError(t, err)
+		var parsed rtp.Header
+		_, err = parsed.Unmarshal(buf)
+		require.NoError(t, err)
+		require.Equal(t, []byte{0x80}, parsed.GetExtension(20))
+	})
+
+	t.Run("no extensions", func(t *testing.T) {
+		out := inbound.rewrite(rtp.Header{SSRC: 1})
+		require.Equal(t, rtp.Header{SSRC: 1}, out)
+	})
+}
+
+func TestHeaderExtensionsInterceptor(t *testing.T) {
+	i := &headerExtensionsInterceptor{}
+	var written *rtp.Header
+	writer := i.BindLocalStream(&interceptor.StreamInfo{
+		RTPHeaderExtensions: []interceptor.RTPHeaderExtension{{URI: sdp.AudioLevelURI, ID: 7}},
+	}, interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		written = header
+		return len(payload), nil
+	}))
+
+	var h rtp.Header
+	require.NoError(t, h.SetExtension(1, []byte{0x80}))
+	_, err := writer.Write(&h, []byte{0x01}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []uint8{7}, written.GetExtensionIDs())
+	require.Equal(t, []uint8{1}, h.GetExtensionIDs())
+}
@@ -0,0 +1,974 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+)
+
+const (
+	hlsAudioTrackID = 1
+	hlsVideoTrackID = 2
+
+	hlsAudioBandwidth = 64000
+	hlsVideoBandwidth = 2500000
+
+	hlsMasterPlaylistName = "index.m3u8"
+	hlsTickInterval       = 100 * time.Millisecond
+	// hlsPartialSegments is the number of most recent segments, including
+	// the one being produced, whose parts are listed in playlists.
+	hlsPartialSegments = 3
+	// hlsMaxFrameSize bounds the size of the video frames being reassembled.
+	hlsMaxFrameSize = 4 * 1024 * 1024
+	// hlsKeyFrameRequestInterval is the minimum interval between key frame
+	// requests sent on behalf of a stream.
+	hlsKeyFrameRequestInterval = time.Second
+	// hlsMaxVideoFrameGap is the largest RTP timestamp gap between video
+	// frames that is trusted as a frame duration.
+	hlsMaxVideoFrameGap = 5 * 90000
+)
+
+var (
+	ErrHLSNotFound    = errors.New("not found")
+	ErrHLSBadRequest  = errors.New("bad request")
+	ErrHLSUnavailable = errors.New("unavailable")
+)
+
+// opusSilenceFrame is a 20ms Opus frame of silence, used to fill the audio
+// track while the source is muted.
+var opusSilenceFrame = []byte{0xf8, 0xff, 0xfe}
+
+// HLSStreamInfo describes the LL-HLS broadcast of a call.
+type HLSStreamInfo struct {
+	ID        string `json:"id"`
+	GroupID   string `json:"group_id"`
+	CallID    string `json:"call_id"`
+	SessionID string `json:"session_id"`
+	// Tracks lists the types of the broadcast tracks ("voice" and
+	// "screen").
+	Tracks    []string `json:"tracks"`
+	StartedAt int64    `json:"started_at"`
+	StoppedAt int64    `json:"stopped_at,omitempty"`
+	// Reason is why the stream was stopped.
+	Reason string `json:"reason,omitempty"`
+}
+
+type hlsPart struct {
+	data        []byte
+	duration    uint32
+	independent bool
+}
+
+type hlsSegment struct {
+	msn      int
+	parts    []hlsPart
+	duration uint32
+	complete bool
+	// keyFrameRequested is set once a key frame got requested to end the
+	// segment.
+	keyFrameRequested bool
+}
+
+// hlsTrack splits the samples of a track into parts and segments, keeping
+// the most recent ones around to be served.
+type hlsTrack struct {
+	name  string
+	track mp4Track
+	// init is the initialization segment. It's nil until the video
+	// dimensions are known.
+	init []byte
+
+	partTarget     uint32
+	segmentTarget  uint32
+	maxSegDuration uint32
+	windowSize     int
+
+	started  bool
+	segments []*hlsSegment
+	nextMSN  int
+	fragSeq  uint32
+	// samples holds the samples of the part being produced, starting at
+	// partStart.
+	samples         []mp4Sample
+	samplesDuration uint32
+	partStart       uint64
+	partStartedAt   time.Time
+	wantKeyFrame    bool
+}
+
+func newHLSTrack(cfg HLSConfig, name string, track mp4Track) *hlsTrack {
+	t := &hlsTrack{
+		name:          name,
+		track:         track,
+		partTarget:    uint32(uint64(cfg.PartDurationMs) * uint64(track.timescale) / 1000),
+		segmentTarget: uint32(uint64(cfg.SegmentDurationMs) * uint64(track.timescale) / 1000),
+		windowSize:    cfg.PlaylistSegments,
+	}
+	t.maxSegDuration = t.segmentTarget
+	if track.isVideo() {
+		// Video segments can only be cut on key frames, they are let grow
+		// up to twice the target waiting for one.
+		t.maxSegDuration = 2 * t.segmentTarget
+	} else {
+		t.init = mp4InitSegment(track)
+	}
+	return t
+}
+
+func toTimescale(d time.Duration, timescale uint32) uint64 {
+	return uint64(d/time.Millisecond) * uint64(timescale) / 1000
+}
+
+func (t *hlsTrack) seconds(d uint32) float64 {
+	return float64(d) / float64(t.track.timescale)
+}
+
+func (t *hlsTrack) start(decodeTime uint64) {
+	t.started = true
+	t.partStart = decodeTime
+}
+
+// end returns the decode time at which the next sample starts.
+func (t *hlsTrack) end() uint64 {
+	return t.partStart + uint64(t.samplesDuration)
+}
+
+func (t *hlsTrack) current() *hlsSegment {
+	if len(t.segments) == 0 {
+		return nil
+	}
+	return t.segments[len(t.segments)-1]
+}
+
+func (t *hlsTrack) startSegment() {
+	t.segments = append(t.segments, &hlsSegment{msn: t.nextMSN})
+	t.nextMSN++
+	// The window holds windowSize complete segments plus the one being
+	// produced.
+	if len(t.segments) > t.windowSize+1 {
+		t.segments = t.segments[len(t.segments)-t.windowSize-1:]
+	}
+}
+
+func (t *hlsTrack) flushPart() bool {
+	if len(t.samples) == 0 {
+		return false
+	}
+	cur := t.current()
+	t.fragSeq++
+	cur.parts = append(cur.parts, hlsPart{
+		data:        mp4Fragment(t.track.id, t.fragSeq, t.partStart, t.samples),
+		duration:    t.samplesDuration,
+		independent: t.samples[0].key,
+	})
+	cur.duration += t.samplesDuration
+	t.partStart += uint64(t.samplesDuration)
+	t.samples = nil
+	t.samplesDuration = 0
+	return true
+}
+
+// addSample appends a sample to the track. It returns true if a part got
+// completed.
+func (t *hlsTrack) addSample(smp mp4Sample, now time.Time) bool {
+	var updated bool
+	cur := t.current()
+	if cur == nil {
+		if t.track.isVideo() && !smp.key {
+			return false
+		}
+		t.startSegment()
+		cur = t.current()
+	}
+
+	segDuration := cur.duration + t.samplesDuration
+	if segDuration > 0 && segDuration+smp.duration > t.segmentTarget {
+		if !t.track.isVideo() || smp.key || segDuration+smp.duration > t.maxSegDuration {
+			t.flushPart()
+			cur.complete = true
+			t.startSegment()
+			updated = true
+		} else if !cur.keyFrameRequested {
+			cur.keyFrameRequested = true
+			t.wantKeyFrame = true
+		}
+	}
+	if len(t.samples) > 0 && t.samplesDuration+smp.duration > t.partTarget {
+		updated = t.flushPart()
+	}
+
+	if len(t.samples) == 0 {
+		t.partStartedAt = now
+	}
+	t.samples = append(t.samples, smp)
+	t.samplesDuration += smp.duration
+
+	return updated
+}
+
+func (t *hlsTrack) partName(msn, part int) string {
+	return fmt.Sprintf("%s_%d.%d.m4s", t.name, msn, part)
+}
+
+func (t *hlsTrack) segmentName(msn int) string {
+	return fmt.Sprintf("%s_%d.m4s", t.name, msn)
+}
+
+func (t *hlsTrack) initName() string {
+	return t.name + "_init.mp4"
+}
+
+func (t *hlsTrack) playlistName() string {
+	return t.name + ".m3u8"
+}
+
+// playlist returns the media playlist of the track. It should only be
+// called once a segment got started.
+func (t *hlsTrack) playlist(ended bool) []byte {
+	partTarget := t.seconds(t.partTarget)
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:6\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", (t.maxSegDuration+t.track.timescale-1)/t.track.timescale)
+	fmt.Fprintf(&b, "#EXT-X-PART-INF:PART-TARGET=%.3f\n", partTarget)
+	fmt.Fprintf(&b, "#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=%.3f\n", 3*partTarget)
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", t.segments[0].msn)
+	fmt.Fprintf(&b, "#EXT-X-MAP:URI=\"%s\"\n", t.initName())
+
+	for i, seg := range t.segments {
+		if i >= len(t.segments)-hlsPartialSegments {
+			for j, part := range seg.parts {
+				fmt.Fprintf(&b, "#EXT-X-PART:DURATION=%.3f,URI=\"%s\"", t.seconds(part.duration), t.partName(seg.msn, j))
+				if part.independent {
+					b.WriteString(",INDEPENDENT=YES")
+				}
+				b.WriteString("\n")
+			}
+		}
+		if seg.complete {
+			fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", t.seconds(seg.duration), t.segmentName(seg.msn))
+		}
+	}
+
+	if ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	} else if cur := t.current(); !cur.complete {
+		fmt.Fprintf(&b, "#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"%s\"\n", t.partName(cur.msn, len(cur.parts)))
+	}
+
+	return []byte(b.String())
+}
+
+// reached returns whether the playlist contains the given part, or the given
+// segment as a whole if part is negative.
+func (t *hlsTrack) reached(msn, part int) bool {
+	cur := t.current()
+	if cur == nil {
+		return false
+	}
+	if msn < cur.msn {
+		return true
+	}
+	if msn > cur.msn {
+		return false
+	}
+	if part < 0 {
+		return cur.complete
+	}
+	return part < len(cur.parts)
+}
+
+func (t *hlsTrack) getSegment(msn int) *hlsSegment {
+	for _, seg := range t.segments {
+		if seg.msn == msn {
+			return seg
+		}
+	}
+	return nil
+}
+
+// hlsStream packages the tracks of a session into LL-HLS renditions: an
+// Opus audio one from its voice track and, if it was sharing its screen
+// when the stream started, a VP8 video one.
+type hlsStream struct {
+	cfg       HLSConfig
+	log       mlog.LoggerIFace
+	info      HLSStreamInfo
+	sessionID string
+	dir       string
+	audio     *hlsTrack
+	// video is nil if the screen isn't broadcast.
+	video     *hlsTrack
+	startedAt time.Time
+
+	// frame holds the video frame being reassembled.
+	frame        []byte
+	frameTS      uint32
+	frameOK      bool
+	lastSeq      uint16
+	hasLastSeq   bool
+	waitKeyFrame bool
+	// held is the last complete video frame, kept until the next one comes
+	// in to know its duration.
+	held              *mp4Sample
+	heldTS            uint32
+	heldAt            time.Time
+	lastFrameDuration uint32
+	lastKeyFrameReqAt time.Time
+	requestKeyFrame   func()
+
+	// updateCh is closed, and replaced, whenever new content is available.
+	updateCh chan struct{}
+	dirty    bool
+	closed   bool
+	// written holds the names of the files written to dir.
+	written map[string]bool
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	mut     sync.Mutex
+}
+
+func newHLSStream(cfg HLSConfig, log mlog.LoggerIFace, sessionCfg SessionConfig, withScreen bool, requestKeyFrame func()) (*hlsStream, error) {
+	hs := &hlsStream{
+		cfg: cfg,
+		log: log,
+		info: HLSStreamInfo{
+			ID:        random.NewID(),
+			GroupID:   sessionCfg.GroupID,
+			CallID:    sessionCfg.CallID,
+			SessionID: sessionCfg.SessionID,
+			Tracks:    []string{"voice"},
+			StartedAt: time.Now().UnixMilli(),
+		},
+		sessionID: sessionCfg.SessionID,
+		audio: newHLSTrack(cfg, "audio", mp4Track{
+			id:        hlsAudioTrackID,
+			codec:     mp4CodecOpus,
+			timescale: rtpAudioCodec.ClockRate,
+		}),
+		startedAt:         time.Now(),
+		lastFrameDuration: rtpVideoCodecVP8.ClockRate / 30,
+		requestKeyFrame:   requestKeyFrame,
+		updateCh:          make(chan struct{}),
+		written:           map[string]bool{},
+		stopCh:            make(chan struct{}),
+		doneCh:            make(chan struct{}),
+	}
+
+	if withScreen {
+		hs.info.Tracks = append(hs.info.Tracks, "screen")
+		hs.video = newHLSTrack(cfg, "video", mp4Track{
+			id:        hlsVideoTrackID,
+			codec:     mp4CodecVP8,
+			timescale: rtpVideoCodecVP8.ClockRate,
+		})
+		hs.waitKeyFrame = true
+	}
+
+	if cfg.Dir != "" {
+		hs.dir = filepath.Join(cfg.Dir, hs.info.ID)
+		if err := os.MkdirAll(hs.dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create stream dir: %w", err)
+		}
+	}
+
+	go hs.run()
+
+	return hs, nil
+}
+
+func (hs *hlsStream) notifyLocked() {
+	close(hs.updateCh)
+	hs.updateCh = make(chan struct{})
+	hs.dirty = true
+}
+
+func (hs *hlsStream) startTrackLocked(t *hlsTrack, now time.Time) {
+	if !t.started {
+		// Tracks share the stream start as time origin so that they stay in
+		// sync.
+		t.start(toTimescale(now.Sub(hs.startedAt), t.track.timescale))
+	}
+}
+
+// writeVoice adds an RTP packet of the source voice track.
+func (hs *hlsStream) writeVoice(pkt *rtp.Packet) {
+	duration, err := opusPacketDuration(pkt.Payload)
+	if err != nil {
+		return
+	}
+	data := make([]byte, len(pkt.Payload))
+	copy(data, pkt.Payload)
+
+	now := time.Now()
+	hs.mut.Lock()
+	defer hs.mut.Unlock()
+	if hs.closed {
+		return
+	}
+
+	hs.startTrackLocked(hs.audio, now)
+	if hs.audio.addSample(mp4Sample{data: data, duration: duration, key: true}, now) {
+		hs.notifyLocked()
+	}
+}
+
+// writeScreen adds an RTP packet of the source screen track. Frames are
+// reassembled and dropped until the next key frame on packet loss.
+func (hs *hlsStream) writeScreen(pkt *rtp.Packet) {
+	if hs.video == nil {
+		return
+	}
+
+	var vp8 codecs.VP8Packet
+	payload, err := vp8.Unmarshal(pkt.Payload)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	hs.mut.Lock()
+	defer hs.mut.Unlock()
+	if hs.closed {
+		return
+	}
+
+	if hs.hasLastSeq && pkt.SequenceNumber != hs.lastSeq+1 {
+		hs.frameOK = false
+		hs.waitKeyFrame = true
+	}
+	hs.lastSeq = pkt.SequenceNumber
+	hs.hasLastSeq = true
+
+	if vp8.S == 1 && vp8.PID == 0 {
+		hs.frame = hs.frame[:0]
+		hs.frameTS = pkt.Timestamp
+		hs.frameOK = true
+	}
+	if !hs.frameOK || pkt.Timestamp != hs.frameTS || len(hs.frame)+len(payload) > hlsMaxFrameSize {
+		hs.frameOK = false
+		return
+	}
+	hs.frame = append(hs.frame, payload...)
+	if !pkt.Marker {
+		return
+	}
+	hs.frameOK = false
+
+	frame := make([]byte, len(hs.frame))
+	copy(frame, hs.frame)
+	hs.addFrameLocked(frame, pkt.Timestamp, now)
+}
+
+func (hs *hlsStream) requestKeyFrameLocked(now time.Time) {
+	if now.Sub(hs.lastKeyFrameReqAt) < hlsKeyFrameRequestInterval {
+		return
+	}
+	hs.lastKeyFrameReqAt = now
+	go hs.requestKeyFrame()
+}
+
+func (hs *hlsStream) addFrameLocked(frame []byte, ts uint32, now time.Time) {
+	width, height, key := vp8KeyFrameSize(frame)
+	if key {
+		hs.waitKeyFrame = false
+		if hs.video.init == nil {
+			hs.video.track.width = width
+			hs.video.track.height = height
+			hs.video.init = mp4InitSegment(hs.video.track)
+		}
+	} else if hs.waitKeyFrame {
+		hs.requestKeyFrameLocked(now)
+		return
+	}
+
+	if hs.held != nil {
+		duration := ts - hs.heldTS
+		if duration == 0 {
+			duration = hs.lastFrameDuration
+		} else if duration > hlsMaxVideoFrameGap {
+			duration = uint32(toTimescale(now.Sub(hs.heldAt), hs.video.track.timescale))
+		}
+		hs.lastFrameDuration = duration
+		hs.held.duration = duration
+		hs.addVideoSampleLocked(*hs.held, now)
+	} else {
+		hs.startTrackLocked(hs.video, now)
+	}
+
+	hs.held = &mp4Sample{data: frame, key: key}
+	hs.heldTS = ts
+	hs.heldAt = now
+}
+
+func (hs *hlsStream) addVideoSampleLocked(smp mp4Sample, now time.Time) {
+	if hs.video.addSample(smp, now) {
+		hs.notifyLocked()
+	}
+	if hs.video.wantKeyFrame {
+		hs.video.wantKeyFrame = false
+		hs.requestKeyFrameLocked(now)
+	}
+}
+
+// tick keeps the tracks advancing when no media is received: the audio
+// track is filled with silence and the last video frame is extended.
+func (hs *hlsStream) tick(now time.Time) {
+	hs.mut.Lock()
+	defer hs.mut.Unlock()
+	if hs.closed {
+		return
+	}
+
+	var updated bool
+
+	hs.startTrackLocked(hs.audio, now)
+	wallTime := toTimescale(now.Sub(hs.startedAt), hs.audio.track.timescale)
+	if hs.audio.end()+uint64(hs.audio.partTarget) < wallTime {
+		const silenceDuration = 960
+		for hs.audio.end()+silenceDuration <= wallTime {
+			if hs.audio.addSample(mp4Sample{data: opusSilenceFrame, duration: silenceDuration, key: true}, now) {
+				updated = true
+			}
+		}
+	}
+
+	partTarget := time.Duration(hs.cfg.PartDurationMs) * time.Millisecond
+	if hs.held != nil && now.Sub(hs.heldAt) > partTarget {
+		hs.held.duration = uint32(toTimescale(now.Sub(hs.heldAt), hs.video.track.timescale))
+		hs.addVideoSampleLocked(*hs.held, now)
+		hs.held = nil
+	}
+
+	for _, t := range []*hlsTrack{hs.audio, hs.video} {
+		if t != nil && len(t.samples) > 0 && now.Sub(t.partStartedAt) >= 2*partTarget && t.flushPart() {
+			updated = true
+		}
+	}
+
+	if updated {
+		hs.notifyLocked()
+	}
+}
+
+func (hs *hlsStream) run() {
+	defer close(hs.doneCh)
+
+	ticker := time.NewTicker(hlsTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			hs.tick(now)
+			hs.syncDir()
+		case <-hs.stopCh:
+			return
+		}
+	}
+}
+
+func (hs *hlsStream) tracks() []*hlsTrack {
+	if hs.video != nil {
+		return []*hlsTrack{hs.audio, hs.video}
+	}
+	return []*hlsTrack{hs.audio}
+}
+
+func (hs *hlsStream) masterPlaylist() []byte {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:6\n")
+	if hs.video == nil {
+		fmt.Fprintf(&b, "#EXT-X-INDEPENDENT-SEGMENTS\n#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s\"\n%s\n",
+			hlsAudioBandwidth, hs.audio.track.codecString(), hs.audio.playlistName())
+		return []byte(b.String())
+	}
+	fmt.Fprintf(&b, "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio\",NAME=\"voice\",DEFAULT=YES,AUTOSELECT=YES,URI=\"%s\"\n",
+		hs.audio.playlistName())
+	fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s,%s\",AUDIO=\"audio\"\n%s\n",
+		hlsVideoBandwidth+hlsAudioBandwidth, hs.video.track.codecString(), hs.audio.track.codecString(), hs.video.playlistName())
+	return []byte(b.String())
+}
+
+// parseHLSMediaName splits the name of a media file into its track name,
+// segment number and part number (-1 for whole segments).
+func parseHLSMediaName(name string) (string, int, int, bool) {
+	base := strings.TrimSuffix(name, ".m4s")
+	if base == name {
+		return "", 0, 0, false
+	}
+	i := strings.LastIndexByte(base, '_')
+	if i < 0 {
+		return "", 0, 0, false
+	}
+	trackName, nums := base[:i], base[i+1:]
+
+	part := -1
+	if j := strings.IndexByte(nums, '.'); j >= 0 {
+		n, err := strconv.Atoi(nums[j+1:])
+		if err != nil || n < 0 {
+			return "", 0, 0, false
+		}
+		part = n
+		nums = nums[:j]
+	}
+	msn, err := strconv.Atoi(nums)
+	if err != nil || msn < 0 {
+		return "", 0, 0, false
+	}
+
+	return trackName, msn, part, true
+}
+
+// lookupLocked returns the content of the given file. It returns true if
+// the content is expected to become available later.
+func (hs *hlsStream) lookupLocked(name string, msn, part int) ([]byte, bool, error) {
+	if name == hlsMasterPlaylistName {
+		return hs.masterPlaylist(), false, nil
+	}
+
+	for _, t := range hs.tracks() {
+		switch name {
+		case t.playlistName():
+			if cur := t.current(); msn > hs.maxMSN(cur) {
+				return nil, false, ErrHLSBadRequest
+			}
+			if t.current() == nil || (msn >= 0 && !t.reached(msn, part)) {
+				return nil, !hs.closed, ErrHLSNotFound
+			}
+			return t.playlist(hs.closed), false, nil
+		case t.initName():
+			if t.init == nil {
+				return nil, !hs.closed, ErrHLSNotFound
+			}
+			return t.init, false, nil
+		}
+	}
+
+	trackName, segMSN, partIdx, ok := parseHLSMediaName(name)
+	if !ok {
+		return nil, false, ErrHLSNotFound
+	}
+	for _, t := range hs.tracks() {
+		if t.name != trackName {
+			continue
+		}
+		seg := t.getSegment(segMSN)
+		if seg == nil {
+			return nil, false, ErrHLSNotFound
+		}
+		if partIdx < 0 {
+			if !seg.complete {
+				return nil, false, ErrHLSNotFound
+			}
+			var data []byte
+			for _, p := range seg.parts {
+				data = append(data, p.data...)
+			}
+			return data, false, nil
+		}
+		if partIdx < len(seg.parts) {
+			return seg.parts[partIdx].data, false, nil
+		}
+		// The part advertised by the preload hint.
+		if seg == t.current() && !seg.complete && partIdx == len(seg.parts) {
+			return nil, !hs.closed, ErrHLSNotFound
+		}
+		return nil, false, ErrHLSNotFound
+	}
+
+	return nil, false, ErrHLSNotFound
+}
+
+// maxMSN returns the largest segment number that can be waited for, two
+// segments past the one being produced.
+func (hs *hlsStream) maxMSN(cur *hlsSegment) int {
+	if cur == nil {
+		return 2
+	}
+	return cur.msn + 2
+}
+
+// getFile returns the content of the given stream file. Requests for
+// playlists, if msn is not negative, and for the part advertised by the
+// preload hint block until the content is available.
+func (hs *hlsStream) getFile(ctx context.Context, name string, msn, part int) ([]byte, error) {
+	timeout := 3 * time.Duration(hs.cfg.SegmentDurationMs) * time.Millisecond
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		hs.mut.Lock()
+		data, wait, err := hs.lookupLocked(name, msn, part)
+		updateCh := hs.updateCh
+		hs.mut.Unlock()
+
+		if !wait {
+			return data, err
+		}
+
+		select {
+		case <-updateCh:
+		case <-ctx.Done():
+			return nil, ErrHLSUnavailable
+		}
+	}
+}
+
+// syncDir writes the stream files to the stream directory, if any, and
+// removes those of the segments that left the playlists.
+func (hs *hlsStream) syncDir() {
+	if hs.dir == "" {
+		return
+	}
+
+	hs.mut.Lock()
+	if !hs.dirty {
+		hs.mut.Unlock()
+		return
+	}
+	hs.dirty = false
+
+	playlists := map[string][]byte{
+		hlsMasterPlaylistName: hs.masterPlaylist(),
+	}
+	media := map[string][]byte{}
+	for _, t := range hs.tracks() {
+		if t.current() == nil || t.init == nil {
+			continue
+		}
+		playlists[t.playlistName()] = t.playlist(hs.closed)
+		media[t.initName()] = t.init
+		for _, seg := range t.segments {
+			for i, p := range seg.parts {
+				media[t.partName(seg.msn, i)] = p.data
+			}
+			if seg.complete {
+				var data []byte
+				for _, p := range seg.parts {
+					data = append(data, p.data...)
+				}
+				media[t.segmentName(seg.msn)] = data
+			}
+		}
+	}
+	hs.mut.Unlock()
+
+	// Media files are written once, before the playlists referencing them.
+	for name, data := range media {
+		if hs.written[name] {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(hs.dir, name), data, 0600); err != nil {
+			hs.log.Error("failed to write stream file", mlog.Err(err), mlog.String("streamID", hs.info.ID), mlog.String("name", name))
+			continue
+		}
+		hs.written[name] = true
+	}
+
+	for name, data := range playlists {
+		tmpPath := filepath.Join(hs.dir, "."+name)
+		err := os.WriteFile(tmpPath, data, 0600)
+		if err == nil {
+			err = os.Rename(tmpPath, filepath.Join(hs.dir, name))
+		}
+		if err != nil {
+			hs.log.Error("failed to write stream playlist", mlog.Err(err), mlog.String("streamID", hs.info.ID), mlog.String("name", name))
+		}
+	}
+
+	for name := range hs.written {
+		if _, ok := media[name]; ok {
+			continue
+		}
+		if err := os.Remove(filepath.Join(hs.dir, name)); err != nil && !os.IsNotExist(err) {
+			hs.log.Error("failed to remove stream file", mlog.Err(err), mlog.String("streamID", hs.info.ID), mlog.String("name", name))
+		}
+		delete(hs.written, name)
+	}
+}
+
+// close stops the stream, completing the segments being produced, and
+// returns its info. It's safe to call it multiple times, only the first
+// reason is kept.
+func (hs *hlsStream) close(reason string) HLSStreamInfo {
+	now := time.Now()
+	hs.mut.Lock()
+	if hs.closed {
+		info := hs.getInfoLocked()
+		hs.mut.Unlock()
+		return info
+	}
+	if hs.held != nil {
+		hs.held.duration = hs.lastFrameDuration
+		hs.addVideoSampleLocked(*hs.held, now)
+		hs.held = nil
+	}
+	for _, t := range hs.tracks() {
+		t.flushPart()
+		if cur := t.current(); cur != nil && len(cur.parts) > 0 {
+			cur.complete = true
+		}
+	}
+	hs.closed = true
+	hs.info.StoppedAt = now.UnixMilli()
+	hs.info.Reason = reason
+	hs.notifyLocked()
+	info := hs.getInfoLocked()
+	hs.mut.Unlock()
+
+	close(hs.stopCh)
+	<-hs.doneCh
+	hs.syncDir()
+
+	return info
+}
+
+func (hs *hlsStream) getInfo() HLSStreamInfo {
+	hs.mut.Lock()
+	defer hs.mut.Unlock()
+	return hs.getInfoLocked()
+}
+
+func (hs *hlsStream) getInfoLocked() HLSStreamInfo {
+	info := hs.info
+	info.Tracks = make([]string, len(hs.info.Tracks))
+	copy(info.Tracks, hs.info.Tracks)
+	return info
+}
+
+// StartHLS starts broadcasting the call of the given session as an LL-HLS
+// stream. The voice of the session is broadcast, along with its screen if
+// it's sharing it. The stream is stopped once the session ends or stops
+// sharing its screen.
+func (s *Server) StartHLS(groupID, sessionID string) (HLSStreamInfo, error) {
+	if !s.cfg.HLS.Enable {
+		return HLSStreamInfo{}, fmt.Errorf("hls is not enabled")
+	}
+
+	call, us, err := s.getCallSession(groupID, sessionID)
+	if err != nil {
+		return HLSStreamInfo{}, err
+	}
+
+	if call.getHLSStream() != nil {
+		return HLSStreamInfo{}, fmt.Errorf("stream already started")
+	}
+
+	withScreen := call.getScreenSession() == us
+	hs, err := newHLSStream(s.cfg.HLS, s.log, us.cfg, withScreen, func() {
+		if err := call.requestKeyFrame(s.GetRuntimeParams()); err != nil {
+			s.log.Debug("failed to request key frame", mlog.Err(err), mlog.String("sessionID", sessionID))
+		}
+	})
+	if err != nil {
+		return HLSStreamInfo{}, err
+	}
+
+	if !call.setHLSStream(hs) {
+		hs.close("")
+		return HLSStreamInfo{}, fmt.Errorf("stream already started")
+	}
+
+	s.hlsMut.Lock()
+	s.hlsStreams[hs.info.ID] = hs
+	s.hlsMut.Unlock()
+
+	if withScreen {
+		hs.mut.Lock()
+		hs.requestKeyFrameLocked(time.Now())
+		hs.mut.Unlock()
+	}
+
+	info := hs.getInfo()
+
+	s.log.Info("hls stream started",
+		mlog.String("groupID", groupID),
+		mlog.String("callID", us.cfg.CallID),
+		mlog.String("sessionID", sessionID),
+		mlog.String("streamID", info.ID),
+		mlog.Bool("screen", withScreen))
+
+	ev := newEvent(HLSStartedEvent, us.cfg)
+	ev.HLS = &info
+	s.sendEvent(ev)
+
+	return info, nil
+}
+
+// StopHLS stops the stream broadcasting the given call.
+func (s *Server) StopHLS(groupID, callID string) (HLSStreamInfo, error) {
+	group := s.getGroup(groupID)
+	if group == nil {
+		return HLSStreamInfo{}, fmt.Errorf("group not found: %s", groupID)
+	}
+	call := group.getCall(callID)
+	if call == nil {
+		return HLSStreamInfo{}, fmt.Errorf("call not found: %s", callID)
+	}
+
+	hs := call.getHLSStream()
+	if hs == nil {
+		return HLSStreamInfo{}, fmt.Errorf("stream not started")
+	}
+
+	return s.stopHLS(call, hs, "stopped"), nil
+}
+
+func (s *Server) stopHLS(c *call, hs *hlsStream, reason string) HLSStreamInfo {
+	if !c.clearHLSStream(hs) {
+		// Already stopped.
+		return hs.close(reason)
+	}
+
+	s.hlsMut.Lock()
+	delete(s.hlsStreams, hs.info.ID)
+	s.hlsMut.Unlock()
+
+	info := hs.close(reason)
+	s.log.Info("hls stream stopped",
+		mlog.String("callID", c.id),
+		mlog.String("streamID", info.ID),
+		mlog.String("reason", info.Reason))
+
+	ev := newEvent(HLSStoppedEvent, SessionConfig{
+		GroupID:   info.GroupID,
+		CallID:    info.CallID,
+		SessionID: info.SessionID,
+	})
+	ev.HLS = &info
+	s.sendEvent(ev)
+
+	return info
+}
+
+// GetHLSFile returns the content of a file of the given stream: the master
+// playlist (index.m3u8), a media playlist, an initialization segment, a
+// segment or a part. Media playlist requests block until the playlist
+// contains the part (or the whole segment if part is negative) of the
+// segment msn, if msn is not negative, as per the blocking playlist reload
+// of LL-HLS. It returns ErrHLSUnavailable if the wait times out.
+func (s *Server) GetHLSFile(ctx context.Context, streamID, name string, msn, part int) ([]byte, error) {
+	s.hlsMut.RLock()
+	hs := s.hlsStreams[streamID]
+	s.hlsMut.RUnlock()
+	if hs == nil {
+		return nil, ErrHLSNotFound
+	}
+	return hs.getFile(ctx, name, msn, part)
+}
@@ -0,0 +1,381 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func testHLSConfig() HLSConfig {
+	return HLSConfig{
+		Enable:            true,
+		PartDurationMs:    500,
+		SegmentDurationMs: 2000,
+		PlaylistSegments:  3,
+	}
+}
+
+func TestHLSTrack(t *testing.T) {
+	now := time.Now()
+
+	t.Run("audio", func(t *testing.T) {
+		track := newHLSTrack(testHLSConfig(), "audio", mp4Track{id: 1, codec: mp4CodecOpus, timescale: 48000})
+		track.start(0)
+		require.NotNil(t, track.init)
+
+		// 5 seconds of 20ms frames.
+		for i := 0; i < 250; i++ {
+			track.addSample(mp4Sample{data: opusSilenceFrame, duration: 960, key: true}, now)
+		}
+
+		require.Len(t, track.segments, 3)
+		for _, seg := range track.segments[:2] {
+			require.True(t, seg.complete)
+			require.Equal(t, uint32(96000), seg.duration)
+			require.Len(t, seg.parts, 4)
+		}
+		cur := track.current()
+		require.False(t, cur.complete)
+		require.Len(t, cur.parts, 1)
+		require.Equal(t, uint64(2*96000+24000), track.partStart)
+		require.Equal(t, uint64(250*960), track.end())
+
+		require.True(t, track.reached(1, -1))
+		require.False(t, track.reached(2, -1))
+		require.True(t, track.reached(2, 0))
+		require.False(t, track.reached(2, 1))
+
+		playlist := string(track.playlist(false))
+		require.Equal(t, `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:2
+#EXT-X-PART-INF:PART-TARGET=0.500
+#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=1.500
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-MAP:URI="audio_init.mp4"
+#EXT-X-PART:DURATION=0.500,URI="audio_0.0.m4s",INDEPENDENT=YES
+#EXT-X-PART:DURATION=0.500,URI="audio_0.1.m4s",INDEPENDENT=YES
+#EXT-X-PART:DURATION=0.500,URI="audio_0.2.m4s",INDEPENDENT=YES
+#EXT-X-PART:DURATION=0.500,URI="audio_0.3.m4s",INDEPENDENT=YES
+#EXTINF:2.000,
+audio_0.m4s
+#EXT-X-PART:DURATION=0.500,URI="audio_1.0.m4s",INDEPENDENT=YES
+#EXT-X-PART:DURATION=0.500,URI="audio_1.1.m4s",INDEPENDENT=YES
+#EXT-X-PART:DURATION=0.500,URI="audio_1.2.m4s",INDEPENDENT=YES
+#EXT-X-PART:DURATION=0.500,URI="audio_1.3.m4s",INDEPENDENT=YES
+#EXTINF:2.000,
+audio_1.m4s
+#EXT-X-PART:DURATION=0.500,URI="audio_2.0.m4s",INDEPENDENT=YES
+#EXT-X-PRELOAD-HINT:TYPE=PART,URI="audio_2.1.m4s"
+`, playlist)
+
+		// 10 more seconds, older segments leave the window.
+		for i := 0; i < 500; i++ {
+			track.addSample(mp4Sample{data: opusSilenceFrame, duration: 960, key: true}, now)
+		}
+		require.Len(t, track.segments, 4)
+		require.Equal(t, 4, track.segments[0].msn)
+		playlist = string(track.playlist(true))
+		require.Contains(t, playlist, "#EXT-X-MEDIA-SEQUENCE:4\n")
+		require.NotContains(t, playlist, "audio_4.0.m4s")
+		require.Contains(t, playlist, "audio_5.0.m4s")
+		require.True(t, strings.HasSuffix(playlist, "#EXT-X-ENDLIST\n"))
+	})
+
+	t.Run("video", func(t *testing.T) {
+		track := newHLSTrack(testHLSConfig(), "video", mp4Track{id: 2, codec: mp4CodecVP8, timescale: 90000})
+		track.start(0)
+
+		// Segments start on key frames.
+		require.False(t, track.addSample(mp4Sample{data: []byte{1}, duration: 3000}, now))
+		require.Nil(t, track.current())
+
+		track.addSample(mp4Sample{data: []byte{0}, duration: 3000, key: true}, now)
+		// 3 seconds without key frames.
+		for i := 0; i < 89; i++ {
+			track.addSample(mp4Sample{data: []byte{1}, duration: 3000}, now)
+		}
+		require.Len(t, track.segments, 1)
+		require.True(t, track.wantKeyFrame)
+		require.True(t, track.current().keyFrameRequested)
+
+		track.addSample(mp4Sample{data: []byte{0}, duration: 3000, key: true}, now)
+		require.Len(t, track.segments, 2)
+		require.True(t, track.segments[0].complete)
+		require.Equal(t, uint32(270000), track.segments[0].duration)
+		require.True(t, track.segments[0].parts[0].independent)
+		require.False(t, track.segments[0].parts[1].independent)
+
+		// Segments get cut past twice the target regardless.
+		for i := 0; i < 121; i++ {
+			track.addSample(mp4Sample{data: []byte{1}, duration: 3000}, now)
+		}
+		require.Len(t, track.segments, 3)
+		require.Equal(t, uint32(360000), track.segments[1].duration)
+		require.Contains(t, string(track.playlist(false)), "#EXT-X-TARGETDURATION:4\n")
+	})
+}
+
+func TestParseHLSMediaName(t *testing.T) {
+	tcs := []struct {
+		name  string
+		track string
+		msn   int
+		part  int
+		ok    bool
+	}{
+		{name: "audio_12.m4s", track: "audio", msn: 12, part: -1, ok: true},
+		{name: "video_3.4.m4s", track: "video", msn: 3, part: 4, ok: true},
+		{name: "audio_12.mp4"},
+		{name: "audio.m4s"},
+		{name: "audio_-1.m4s"},
+		{name: "audio_1.x.m4s"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			track, msn, part, ok := parseHLSMediaName(tc.name)
+			require.Equal(t, tc.ok, ok)
+			if tc.ok {
+				require.Equal(t, tc.track, track)
+				require.Equal(t, tc.msn, msn)
+				require.Equal(t, tc.part, part)
+			}
+		})
+	}
+}
+
+func TestHLSStream(t *testing.T) {
+	cfg := testHLSConfig()
+	cfg.PartDurationMs = 100
+	cfg.SegmentDurationMs = 200
+	cfg.Dir = t.TempDir()
+
+	hs, err := newHLSStream(cfg, nil, SessionConfig{
+		GroupID:   "groupID",
+		CallID:    "callID",
+		SessionID: "sessionID",
+	}, false, func() {})
+	require.NoError(t, err)
+	require.Equal(t, []string{"voice"}, hs.info.Tracks)
+
+	master, err := hs.getFile(context.Background(), "index.m3u8", -1, -1)
+	require.NoError(t, err)
+	require.Equal(t, "#EXTM3U\n#EXT-X-VERSION:6\n#EXT-X-INDEPENDENT-SEGMENTS\n#EXT-X-STREAM-INF:BANDWIDTH=64000,CODECS=\"opus\"\naudio.m3u8\n", string(master))
+
+	init, err := hs.getFile(context.Background(), "audio_init.mp4", -1, -1)
+	require.NoError(t, err)
+	require.Equal(t, hs.audio.init, init)
+
+	t.Run("blocking playlist reload", func(t *testing.T) {
+		resultCh := make(chan []byte, 1)
+		go func() {
+			data, err := hs.getFile(context.Background(), "audio.m3u8", 1, 0)
+			require.NoError(t, err)
+			resultCh <- data
+		}()
+
+		for i := 0; i < 20; i++ {
+			hs.writeVoice(&rtp.Packet{Payload: []byte{0xfc, 0xff, 0xfe}})
+		}
+
+		select {
+		case data := <-resultCh:
+			require.Contains(t, string(data), "audio_1.0.m4s")
+			require.Contains(t, string(data), "#EXTINF:0.200,\naudio_0.m4s\n")
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for playlist")
+		}
+
+		_, err := hs.getFile(context.Background(), "audio.m3u8", 10, -1)
+		require.ErrorIs(t, err, ErrHLSBadRequest)
+	})
+
+	t.Run("media files", func(t *testing.T) {
+		part, err := hs.getFile(context.Background(), "audio_0.1.m4s", -1, -1)
+		require.NoError(t, err)
+		segment, err := hs.getFile(context.Background(), "audio_0.m4s", -1, -1)
+		require.NoError(t, err)
+		require.Equal(t, segment[len(segment)-len(part):], part)
+
+		_, err = hs.getFile(context.Background(), "audio_1.m4s", -1, -1)
+		require.ErrorIs(t, err, ErrHLSNotFound)
+
+		_, err = hs.getFile(context.Background(), "video_init.mp4", -1, -1)
+		require.ErrorIs(t, err, ErrHLSNotFound)
+	})
+
+	t.Run("silence", func(t *testing.T) {
+		// Muted sources are filled with silence, in real time.
+		hs.mut.Lock()
+		end := hs.audio.end()
+		hs.mut.Unlock()
+		require.Eventually(t, func() bool {
+			hs.mut.Lock()
+			defer hs.mut.Unlock()
+			wallTime := toTimescale(time.Since(hs.startedAt), hs.audio.track.timescale)
+			return hs.audio.end() > end && hs.audio.end()+uint64(2*hs.audio.partTarget) >= wallTime
+		}, 2*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		hs.mut.Lock()
+		msn := hs.audio.current().msn + 2
+		hs.mut.Unlock()
+		_, err := hs.getFile(ctx, "audio.m3u8", msn, -1)
+		require.ErrorIs(t, err, ErrHLSUnavailable)
+	})
+
+	info := hs.close("stopped")
+	require.Equal(t, "stopped", info.Reason)
+	require.NotZero(t, info.StoppedAt)
+
+	playlist, err := hs.getFile(context.Background(), "audio.m3u8", -1, -1)
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(string(playlist), "#EXT-X-ENDLIST\n"))
+
+	// The stream directory holds the same content.
+	data, err := os.ReadFile(filepath.Join(cfg.Dir, info.ID, "audio.m3u8"))
+	require.NoError(t, err)
+	require.Equal(t, playlist, data)
+	data, err = os.ReadFile(filepath.Join(cfg.Dir, info.ID, "index.m3u8"))
+	require.NoError(t, err)
+	require.Equal(t, master, data)
+	require.FileExists(t, filepath.Join(cfg.Dir, info.ID, "audio_init.mp4"))
+	lines := strings.Split(strings.TrimSpace(string(playlist)), "\n")
+	require.FileExists(t, filepath.Join(cfg.Dir, info.ID, lines[len(lines)-2]))
+}
+
+func TestHLSStreamScreen(t *testing.T) {
+	hs, err := newHLSStream(testHLSConfig(), nil, SessionConfig{SessionID: "sessionID"}, true, func() {})
+	require.NoError(t, err)
+	defer hs.close("")
+	require.Equal(t, []string{"voice", "screen"}, hs.info.Tracks)
+
+	keyFrame := []byte{0x50, 0x42, 0x00, 0x9d, 0x01, 0x2a, 0x00, 0x05, 0xd0, 0x02}
+	interFrame := []byte{0x51, 0x00, 0x00, 0x00, 0x00, 0x00}
+	writeFrame := func(seq uint16, ts uint32, frame []byte) {
+		// Split in two packets, the first one having the S bit set in its
+		// payload descriptor.
+		half := len(frame) / 2
+		hs.writeScreen(&rtp.Packet{Header: rtp.Header{SequenceNumber: seq, Timestamp: ts}, Payload: append([]byte{0x10}, frame[:half]...)})
+		hs.writeScreen(&rtp.Packet{Header: rtp.Header{SequenceNumber: seq + 1, Timestamp: ts, Marker: true}, Payload: append([]byte{0x00}, frame[half:]...)})
+	}
+
+	// Frames are dropped until a key frame comes in.
+	writeFrame(0, 0, interFrame)
+	func() {
+		hs.mut.Lock()
+		defer hs.mut.Unlock()
+		require.Nil(t, hs.held)
+		require.Nil(t, hs.video.init)
+	}()
+
+	writeFrame(2, 3000, keyFrame)
+	writeFrame(4, 6000, interFrame)
+	func() {
+		hs.mut.Lock()
+		defer hs.mut.Unlock()
+		require.Equal(t, uint16(1280), hs.video.track.width)
+		require.NotNil(t, hs.video.init)
+		require.Len(t, hs.video.samples, 1)
+		require.Equal(t, keyFrame, hs.video.samples[0].data)
+		require.Equal(t, uint32(3000), hs.video.samples[0].duration)
+		require.Equal(t, interFrame, hs.held.data)
+	}()
+
+	// Packet loss.
+	writeFrame(7, 9000, interFrame)
+	func() {
+		hs.mut.Lock()
+		defer hs.mut.Unlock()
+		require.True(t, hs.waitKeyFrame)
+		require.Equal(t, interFrame, hs.held.data)
+		require.Len(t, hs.video.samples, 1)
+	}()
+
+	master, err := hs.getFile(context.Background(), "index.m3u8", -1, -1)
+	require.NoError(t, err)
+	require.Contains(t, string(master), "CODECS=\"vp08.00.10.08,opus\",AUDIO=\"audio\"\nvideo.m3u8\n")
+}
+
+func TestStartHLS(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	t.Run("not enabled", func(t *testing.T) {
+		_, err := server.StartHLS("groupID", "sessionID")
+		require.EqualError(t, err, "hls is not enabled")
+	})
+
+	server.cfg.HLS = testHLSConfig()
+
+	t.Run("session not found", func(t *testing.T) {
+		_, err := server.StartHLS("groupID", "sessionID")
+		require.EqualError(t, err, "session not found: sessionID")
+
+		_, err = server.StopHLS("groupID", "callID")
+		require.EqualError(t, err, "group not found: groupID")
+	})
+
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	_, err = server.addSession(SessionConfig{
+		GroupID:   "groupID",
+		CallID:    "callID",
+		UserID:    "userID",
+		SessionID: "sessionID",
+	}, peerConn, nil)
+	require.NoError(t, err)
+
+	t.Run("start and stop", func(t *testing.T) {
+		info, err := server.StartHLS("groupID", "sessionID")
+		require.NoError(t, err)
+		require.Equal(t, "callID", info.CallID)
+		require.Equal(t, []string{"voice"}, info.Tracks)
+
+		_, err = server.StartHLS("groupID", "sessionID")
+		require.EqualError(t, err, "stream already started")
+
+		state, err := server.GetCallState("groupID", "callID")
+		require.NoError(t, err)
+		require.Equal(t, info.ID, state.HLSStreamID)
+
+		master, err := server.GetHLSFile(context.Background(), info.ID, "index.m3u8", -1, -1)
+		require.NoError(t, err)
+		require.NotEmpty(t, master)
+
+		info, err = server.StopHLS("groupID", "callID")
+		require.NoError(t, err)
+		require.Equal(t, "stopped", info.Reason)
+
+		_, err = server.StopHLS("groupID", "callID")
+		require.EqualError(t, err, "stream not started")
+
+		_, err = server.GetHLSFile(context.Background(), info.ID, "index.m3u8", -1, -1)
+		require.ErrorIs(t, err, ErrHLSNotFound)
+	})
+
+	t.Run("session ended", func(t *testing.T) {
+		info, err := server.StartHLS("groupID", "sessionID")
+		require.NoError(t, err)
+
+		err = server.CloseSession("sessionID")
+		require.NoError(t, err)
+
+		_, err = server.GetHLSFile(context.Background(), info.ID, "index.m3u8", -1, -1)
+		require.ErrorIs(t, err, ErrHLSNotFound)
+	})
+}
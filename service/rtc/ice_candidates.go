@@ -0,0 +1,213 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// candidateFilter drops the local candidates that aren't worth advertising
+// to clients, as configured.
+type candidateFilter struct {
+	cfg ICECandidatesConfig
+	// networks holds the parsed AllowedNetworks.
+	networks []*net.IPNet
+}
+
+func newCandidateFilter(cfg ICECandidatesConfig) *candidateFilter {
+	f := &candidateFilter{cfg: cfg}
+	for _, cidr := range cfg.AllowedNetworks {
+		// Validated along with the config.
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			f.networks = append(f.networks, network)
+		}
+	}
+	return f
+}
+
+// isPublicIP returns whether ip is a globally routable unicast address.
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// allowed returns whether a candidate with the given address passes the
+// link-local and network checks.
+func (f *candidateFilter) allowed(ip net.IP) bool {
+	if f.cfg.DropLinkLocal && (ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()) {
+		return false
+	}
+
+	if len(f.networks) == 0 {
+		return true
+	}
+	for _, network := range f.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns the candidates to send out of the given batch, along with
+// whether any of them has a public address. publicSent tells whether a
+// candidate with a public address was already sent in a previous batch.
+func (f *candidateFilter) filter(candidates []*webrtc.ICECandidate, publicSent bool) ([]*webrtc.ICECandidate, bool) {
+	var allowed []*webrtc.ICECandidate
+	hasPublic := publicSent
+	for _, c := range candidates {
+		ip := net.ParseIP(c.Address)
+		if ip == nil {
+			// Host names are left to the client.
+			allowed = append(allowed, c)
+			continue
+		}
+		if !f.allowed(ip) {
+			continue
+		}
+		if isPublicIP(ip) {
+			hasPublic = true
+		}
+		allowed = append(allowed, c)
+	}
+
+	if !f.cfg.DropPrivateWhenPublic || !hasPublic {
+		return allowed, hasPublic
+	}
+
+	kept := allowed[:0]
+	for _, c := range allowed {
+		if ip := net.ParseIP(c.Address); ip != nil && ip.IsPrivate() {
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept, hasPublic
+}
+
+// candidateBatcher filters the local candidates of a session and groups
+// those gathered within the batch interval into a single signaling message.
+type candidateBatcher struct {
+	filter   *candidateFilter
+	interval time.Duration
+	// send delivers the candidates kept out of a batch. It's never called
+	// with an empty slice.
+	send func(candidates []*webrtc.ICECandidate)
+	// onDrop is called for every filtered out candidate.
+	onDrop func(c *webrtc.ICECandidate)
+
+	pending    []*webrtc.ICECandidate
+	timer      *time.Timer
+	publicSent bool
+	closed     bool
+	mut        sync.Mutex
+}
+
+// add queues a candidate gathered by the ICE agent. A nil candidate
+// signals the end of gathering and flushes the pending batch right away.
+func (b *candidateBatcher) add(c *webrtc.ICECandidate) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	if c == nil {
+		b.flush()
+		return
+	}
+
+	b.pending = append(b.pending, c)
+	if b.interval <= 0 {
+		b.flush()
+		return
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, func() {
+			b.mut.Lock()
+			defer b.mut.Unlock()
+			if !b.closed {
+				b.flush()
+			}
+		})
+	}
+}
+
+// flush sends out the pending batch. It must be called with the lock held.
+func (b *candidateBatcher) flush() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return
+	}
+
+	candidates := b.pending
+	b.pending = nil
+
+	var kept []*webrtc.ICECandidate
+	kept, b.publicSent = b.filter.filter(candidates, b.publicSent)
+	if b.onDrop != nil && len(kept) < len(candidates) {
+		for _, c := range candidates {
+			if !containsCandidate(kept, c) {
+				b.onDrop(c)
+			}
+		}
+	}
+
+	if len(kept) > 0 {
+		b.send(kept)
+	}
+}
+
+// close discards the pending batch and stops the batcher.
+func (b *candidateBatcher) close() {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	b.closed = true
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+}
+
+func containsCandidate(candidates []*webrtc.ICECandidate, c *webrtc.ICECandidate) bool {
+	for _, candidate := range candidates {
+		if candidate == c {
+			return true
+		}
+	}
+	return false
+}
+
+// newICECandidatesMessage returns the message carrying the given local
+// candidates. A single candidate is sent as a regular ICE message while
+// several are grouped as
+// {"type": "candidates", "candidates": [<RTCIceCandidateInit>, ...]}.
+func newICECandidatesMessage(s *session, candidates []*webrtc.ICECandidate) (Message, error) {
+	if len(candidates) == 1 {
+		return newICEMessage(s, candidates[0])
+	}
+
+	inits := make([]webrtc.ICECandidateInit, 0, len(candidates))
+	for _, c := range candidates {
+		inits = append(inits, c.ToJSON())
+	}
+	data := make(map[string]interface{})
+	data["type"] = "candidates"
+	data["candidates"] = inits
+	js, err := json.Marshal(data)
+	if err != nil {
+		return Message{}, err
+	}
+	return newMessage(s, ICEMessage, js), nil
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCandidate(addr string, typ webrtc.ICECandidateType) *webrtc.ICECandidate {
+	return &webrtc.ICECandidate{
+		Foundation: "1",
+		Priority:   1,
+		Address:    addr,
+		Protocol:   webrtc.ICEProtocolUDP,
+		Port:       8443,
+		Typ:        typ,
+		Component:  1,
+	}
+}
+
+func candidateAddresses(candidates []*webrtc.ICECandidate) []string {
+	var addrs []string
+	for _, c := range candidates {
+		addrs = append(addrs, c.Address)
+	}
+	return addrs
+}
+
+func TestCandidateFilter(t *testing.T) {
+	linkLocal := newTestCandidate("169.254.10.1", webrtc.ICECandidateTypeHost)
+	linkLocalV6 := newTestCandidate("fe80::1", webrtc.ICECandidateTypeHost)
+	private := newTestCandidate("10.0.0.5", webrtc.ICECandidateTypeHost)
+	public := newTestCandidate("203.0.113.10", webrtc.ICECandidateTypeSrflx)
+
+	t.Run("default", func(t *testing.T) {
+		f := newCandidateFilter(ICECandidatesConfig{})
+		kept, hasPublic := f.filter([]*webrtc.ICECandidate{linkLocal, private, public}, false)
+		require.Equal(t, []string{"169.254.10.1", "10.0.0.5", "203.0.113.10"}, candidateAddresses(kept))
+		require.True(t, hasPublic)
+	})
+
+	t.Run("link-local", func(t *testing.T) {
+		f := newCandidateFilter(ICECandidatesConfig{DropLinkLocal: true})
+		kept, hasPublic := f.filter([]*webrtc.ICECandidate{linkLocal, linkLocalV6, private}, false)
+		require.Equal(t, []string{"10.0.0.5"}, candidateAddresses(kept))
+		require.False(t, hasPublic)
+	})
+
+	t.Run("private when public", func(t *testing.T) {
+		f := newCandidateFilter(ICECandidatesConfig{DropPrivateWhenPublic: true})
+
+		kept, hasPublic := f.filter([]*webrtc.ICECandidate{private}, false)
+		require.Equal(t, []string{"10.0.0.5"}, candidateAddresses(kept))
+		require.False(t, hasPublic)
+
+		kept, hasPublic = f.filter([]*webrtc.ICECandidate{private, public}, false)
+		require.Equal(t, []string{"203.0.113.10"}, candidateAddresses(kept))
+		require.True(t, hasPublic)
+
+		kept, hasPublic = f.filter([]*webrtc.ICECandidate{private}, true)
+		require.Empty(t, kept)
+		require.True(t, hasPublic)
+	})
+
+	t.Run("allowed networks", func(t *testing.T) {
+		f := newCandidateFilter(ICECandidatesConfig{AllowedNetworks: []string{"10.0.0.0/8", "2001:db8::/32"}})
+		v6 := newTestCandidate("2001:db8::1", webrtc.ICECandidateTypeHost)
+		kept, hasPublic := f.filter([]*webrtc.ICECandidate{private, public, v6}, false)
+		require.Equal(t, []string{"10.0.0.5", "2001:db8::1"}, candidateAddresses(kept))
+		require.True(t, hasPublic)
+	})
+}
+
+func TestCandidateBatcher(t *testing.T) {
+	private := newTestCandidate("10.0.0.5", webrtc.ICECandidateTypeHost)
+	public := newTestCandidate("203.0.113.10", webrtc.ICECandidateTypeSrflx)
+
+	newBatcher := func(cfg ICECandidatesConfig) (*candidateBatcher, chan []*webrtc.ICECandidate, *[]string) {
+		sentCh := make(chan []*webrtc.ICECandidate, 10)
+		var dropped []string
+		var mut sync.Mutex
+		b := &candidateBatcher{
+			filter:   newCandidateFilter(cfg),
+			interval: time.Duration(cfg.BatchIntervalMs) * time.Millisecond,
+			send: func(candidates []*webrtc.ICECandidate) {
+				sentCh <- candidates
+			},
+			onDrop: func(c *webrtc.ICECandidate) {
+				mut.Lock()
+				defer mut.Unlock()
+				dropped = append(dropped, c.Address)
+			},
+		}
+		return b, sentCh, &dropped
+	}
+
+	t.Run("no batching", func(t *testing.T) {
+		b, sentCh, _ := newBatcher(ICECandidatesConfig{})
+		b.add(private)
+		b.add(public)
+		require.Equal(t, []string{"10.0.0.5"}, candidateAddresses(<-sentCh))
+		require.Equal(t, []string{"203.0.113.10"}, candidateAddresses(<-sentCh))
+		b.add(nil)
+		require.Empty(t, sentCh)
+	})
+
+	t.Run("batching", func(t *testing.T) {
+		b, sentCh, dropped := newBatcher(ICECandidatesConfig{BatchIntervalMs: 50, DropPrivateWhenPublic: true})
+		b.add(private)
+		b.add(public)
+		require.Empty(t, sentCh)
+
+		select {
+		case sent := <-sentCh:
+			require.Equal(t, []string{"203.0.113.10"}, candidateAddresses(sent))
+		case <-time.After(time.Second):
+			require.Fail(t, "timed out waiting for batch")
+		}
+		require.Equal(t, []string{"10.0.0.5"}, *dropped)
+	})
+
+	t.Run("gathering complete", func(t *testing.T) {
+		b, sentCh, _ := newBatcher(ICECandidatesConfig{BatchIntervalMs: 10000})
+		b.add(private)
+		b.add(public)
+		b.add(nil)
+		require.Equal(t, []string{"10.0.0.5", "203.0.113.10"}, candidateAddresses(<-sentCh))
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		b, sentCh, _ := newBatcher(ICECandidatesConfig{BatchIntervalMs: 10})
+		b.add(private)
+		b.close()
+		b.add(public)
+		time.Sleep(50 * time.Millisecond)
+		require.Empty(t, sentCh)
+	})
+}
+
+func TestNewICECandidatesMessage(t *testing.T) {
+	s := &session{cfg: SessionConfig{GroupID: "groupID", UserID: "userID", SessionID: "sessionID"}}
+	private := newTestCandidate("10.0.0.5", webrtc.ICECandidateTypeHost)
+	public := newTestCandidate("203.0.113.10", webrtc.ICECandidateTypeSrflx)
+
+	t.Run("single", func(t *testing.T) {
+		msg, err := newICECandidatesMessage(s, []*webrtc.ICECandidate{private})
+		require.NoError(t, err)
+		require.Equal(t, ICEMessage, msg.Type)
+
+		var data struct {
+			Type      string                  `json:"type"`
+			Candidate webrtc.ICECandidateInit `json:"candidate"`
+		}
+		require.NoError(t, json.Unmarshal(msg.Data, &data))
+		require.Equal(t, "candidate", data.Type)
+		require.Equal(t, private.ToJSON(), data.Candidate)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		msg, err := newICECandidatesMessage(s, []*webrtc.ICECandidate{private, public})
+		require.NoError(t, err)
+		require.Equal(t, ICEMessage, msg.Type)
+
+		var data struct {
+			Type       string                    `json:"type"`
+			Candidates []webrtc.ICECandidateInit `json:"candidates"`
+		}
+		require.NoError(t, json.Unmarshal(msg.Data, &data))
+		require.Equal(t, "candidates", data.Type)
+		require.Equal(t, []webrtc.ICECandidateInit{private.ToJSON(), public.ToJSON()}, data.Candidates)
+	})
+}
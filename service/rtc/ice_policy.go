@@ -0,0 +1,107 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrICEPolicyUnsatisfiable is returned when a session joins a call whose ICE
+// policy can't be honored with the configured ICE servers.
+var ErrICEPolicyUnsatisfiable = errors.New("ICE policy can't be satisfied")
+
+var regionRE = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ICEPolicy restricts the data path of the sessions of a call, for tenants
+// with strict requirements on where media can flow through.
+type ICEPolicy struct {
+	// ForceRelay makes the sessions connect through the TURN servers only.
+	ForceRelay bool
+	// RelayOnly goes further than ForceRelay: the candidates sent by the
+	// clients are dropped as well unless they're relayed, so that no host or
+	// server reflexive candidate is ever exchanged.
+	RelayOnly bool
+	// DisableHost drops the host candidates sent by the clients, so that
+	// media never flows to their local addresses.
+	DisableHost bool
+	// TURNRegion restricts the TURN servers used by the sessions to the
+	// ones of the given region.
+	TURNRegion string
+}
+
+func (p ICEPolicy) IsValid() error {
+	if p.TURNRegion != "" && !regionRE.MatchString(p.TURNRegion) {
+		return fmt.Errorf("invalid TURNRegion value: should be at most 64 alphanumeric, '-' or '_' characters")
+	}
+	return nil
+}
+
+// usesRelay returns whether the sessions connect through the TURN servers
+// only.
+func (p ICEPolicy) usesRelay() bool {
+	return p.ForceRelay || p.RelayOnly
+}
+
+// dropsCandidate returns whether the candidates of the given type (e.g.
+// "host") sent by the clients are dropped.
+func (p ICEPolicy) dropsCandidate(typ string) bool {
+	if p.RelayOnly && typ != "relay" {
+		return true
+	}
+	return p.DisableHost && typ == "host"
+}
+
+// allowsServer returns whether the ICE server can be used by the sessions
+// under the policy.
+func (p ICEPolicy) allowsServer(cfg ICEServerConfig) bool {
+	return p.TURNRegion == "" || !cfg.IsTURN() || cfg.Region == p.TURNRegion
+}
+
+// getCallICEPolicy returns the ICE policy of the call the session is joining,
+// either existing or started by the session itself, along with the relay only
+// mode if enabled for all the calls.
+func (s *Server) getCallICEPolicy(cfg SessionConfig) ICEPolicy {
+	policy := cfg.ICEPolicy
+	if g := s.getGroup(cfg.GroupID); g != nil {
+		if c := g.getCall(cfg.CallID); c != nil {
+			policy = c.icePolicy
+		}
+	}
+	policy.RelayOnly = policy.RelayOnly || s.cfg.RelayOnly
+	return policy
+}
+
+// candidateType returns the type of candidate (an attribute value, e.g.
+// "candidate:1 1 udp ..."), or an empty string if it's malformed.
+func candidateType(candidate string) string {
+	// <foundation> <component> <transport> <priority> <address> <port> typ <type> ...
+	fields := strings.Fields(candidate)
+	if len(fields) < 8 || fields[6] != "typ" {
+		return ""
+	}
+	return fields[7]
+}
+
+// dropsCandidateAttr returns whether the candidate sent by a client gets
+// dropped under the policy.
+func (p ICEPolicy) dropsCandidateAttr(candidate string) bool {
+	return p.dropsCandidate(candidateType(candidate))
+}
+
+// filterCandidates removes the candidates dropped under the policy from sdp.
+func (p ICEPolicy) filterCandidates(sdp string) string {
+	lines := strings.SplitAfter(sdp, "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		value := strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(value, "a=candidate:") && p.dropsCandidateAttr(strings.TrimPrefix(value, "a=")) {
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "")
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestICEPolicyIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var p ICEPolicy
+		require.NoError(t, p.IsValid())
+	})
+
+	t.Run("invalid TURNRegion", func(t *testing.T) {
+		p := ICEPolicy{TURNRegion: "eu west"}
+		require.EqualError(t, p.IsValid(), "invalid TURNRegion value: should be at most 64 alphanumeric, '-' or '_' characters")
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		p := ICEPolicy{ForceRelay: true, DisableHost: true, TURNRegion: "eu-west"}
+		require.NoError(t, p.IsValid())
+	})
+}
+
+func TestICEPolicyAllowsServer(t *testing.T) {
+	stunServer := ICEServerConfig{URLs: []string{"stun:localhost:3478"}}
+	turnEU := ICEServerConfig{URLs: []string{"turn:eu.localhost:3478"}, Region: "eu"}
+	turnUS := ICEServerConfig{URLs: []string{"turn:us.localhost:3478"}, Region: "us"}
+
+	var p ICEPolicy
+	require.True(t, p.allowsServer(stunServer))
+	require.True(t, p.allowsServer(turnEU))
+	require.True(t, p.allowsServer(turnUS))
+
+	p.TURNRegion = "eu"
+	require.True(t, p.allowsServer(stunServer))
+	require.True(t, p.allowsServer(turnEU))
+	require.False(t, p.allowsServer(turnUS))
+}
+
+func TestICEPolicyFilterCandidates(t *testing.T) {
+	host := "candidate:1 1 udp 2130706431 192.168.1.10 50000 typ host"
+	srflx := "candidate:2 1 udp 1694498815 203.0.113.5 50000 typ srflx raddr 192.168.1.10 rport 50000"
+	relay := "candidate:3 1 udp 16777215 198.51.100.7 60000 typ relay raddr 203.0.113.5 rport 50000"
+
+	require.Equal(t, "host", candidateType(host))
+	require.Equal(t, "relay", candidateType(relay))
+	require.Empty(t, candidateType("candidate:1 1 udp"))
+
+	sdp := "v=0\r\n" +
+		"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+		"a=" + host + "\r\n" +
+		"a=" + srflx + "\r\n" +
+		"a=" + relay + "\r\n" +
+		"a=end-of-candidates\r\n"
+
+	t.Run("disable host", func(t *testing.T) {
+		p := ICEPolicy{DisableHost: true}
+		require.True(t, p.dropsCandidateAttr(host))
+		require.False(t, p.dropsCandidateAttr(srflx))
+		require.False(t, p.dropsCandidateAttr(relay))
+		require.Equal(t, "v=0\r\n"+
+			"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n"+
+			"a="+srflx+"\r\n"+
+			"a="+relay+"\r\n"+
+			"a=end-of-candidates\r\n", p.filterCandidates(sdp))
+	})
+
+	t.Run("relay only", func(t *testing.T) {
+		p := ICEPolicy{RelayOnly: true}
+		require.True(t, p.dropsCandidateAttr(host))
+		require.True(t, p.dropsCandidateAttr(srflx))
+		require.False(t, p.dropsCandidateAttr(relay))
+		require.Equal(t, "v=0\r\n"+
+			"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n"+
+			"a="+relay+"\r\n"+
+			"a=end-of-candidates\r\n", p.filterCandidates(sdp))
+	})
+
+	t.Run("none", func(t *testing.T) {
+		var p ICEPolicy
+		require.Equal(t, sdp, p.filterCandidates(sdp))
+	})
+}
+
+func TestCallICEPolicy(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	cfg := SessionConfig{
+		GroupID:   "test",
+		CallID:    "test",
+		UserID:    "userA",
+		SessionID: "sessionA",
+		ICEPolicy: ICEPolicy{ForceRelay: true, TURNRegion: "eu"},
+	}
+
+	t.Run("unsatisfiable", func(t *testing.T) {
+		err := server.InitSession(cfg, nil)
+		require.ErrorIs(t, err, ErrICEPolicyUnsatisfiable)
+	})
+
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	_, err = server.addSession(cfg, peerConn, nil)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, server.CloseSession("sessionA"))
+	}()
+
+	// Following sessions inherit the policy of the call.
+	cfgB := cfg
+	cfgB.UserID = "userB"
+	cfgB.SessionID = "sessionB"
+	cfgB.ICEPolicy = ICEPolicy{}
+	require.Equal(t, cfg.ICEPolicy, server.getCallICEPolicy(cfgB))
+
+	// Sessions of other calls don't.
+	cfgB.CallID = "other"
+	require.Equal(t, ICEPolicy{}, server.getCallICEPolicy(cfgB))
+
+	t.Run("relay only for all calls", func(t *testing.T) {
+		server.cfg.RelayOnly = true
+		defer func() {
+			server.cfg.RelayOnly = false
+		}()
+		require.Equal(t, ICEPolicy{RelayOnly: true}, server.getCallICEPolicy(cfgB))
+	})
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// trackActivity tracks whether a published track is still receiving packets.
+// Publishers can stop sending without the track being removed (e.g. crashed
+// camera, sleeping OS), leaving subscribers with a frozen frame.
+type trackActivity struct {
+	lastPacketAt time.Time
+	stalled      bool
+	mut          sync.Mutex
+}
+
+func newTrackActivity(now time.Time) *trackActivity {
+	return &trackActivity{
+		lastPacketAt: now,
+	}
+}
+
+// onPacket accounts for a packet received on the track. It returns whether
+// the track was stalled.
+func (a *trackActivity) onPacket(now time.Time) bool {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.lastPacketAt = now
+	resumed := a.stalled
+	a.stalled = false
+	return resumed
+}
+
+// reset restarts the timeout without changing the stalled state, for tracks
+// expected to go quiet.
+func (a *trackActivity) reset(now time.Time) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.lastPacketAt = now
+}
+
+// check returns whether the track just stalled, i.e. no packet was received
+// for longer than timeout.
+func (a *trackActivity) check(now time.Time, timeout time.Duration) bool {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	if a.stalled || now.Sub(a.lastPacketAt) <= timeout {
+		return false
+	}
+	a.stalled = true
+	return true
+}
+
+func (a *trackActivity) isStalled() bool {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	return a.stalled
+}
+
+func (s *session) addTrackActivity(trackID string, a *trackActivity) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.trackActivity == nil {
+		s.trackActivity = map[string]*trackActivity{}
+	}
+	s.trackActivity[trackID] = a
+}
+
+func (s *session) removeTrackActivity(trackID string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	delete(s.trackActivity, trackID)
+}
+
+// getStalledTracks returns the IDs of the stalled tracks of the session,
+// sorted. Must be called with s.mut held.
+func (s *session) getStalledTracks() []string {
+	var stalled []string
+	for trackID, a := range s.trackActivity {
+		if a.isStalled() {
+			stalled = append(stalled, trackID)
+		}
+	}
+	sort.Strings(stalled)
+	return stalled
+}
+
+// isVoiceMuted returns whether trackID is the voice track of the session and
+// it's currently not being forwarded.
+func (s *session) isVoiceMuted(trackID string) bool {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.outVoiceTrack != nil && s.outVoiceTrack.ID() == trackID && !s.outVoiceTrackEnabled
+}
+
+// monitorTrackActivity starts watching the packets received on the given
+// published track, if enabled, sending a TrackStalledEvent when they stop
+// and a TrackResumedEvent when they flow again. Packets should be reported
+// through the returned function, nil if disabled, while the second one
+// should be called once the track is gone.
+func (s *Server) monitorTrackActivity(us *session, trackID string) (func(now time.Time), func()) {
+	if s.cfg.TrackInactivityTimeoutMs <= 0 {
+		return nil, func() {}
+	}
+
+	timeout := time.Duration(s.cfg.TrackInactivityTimeoutMs) * time.Millisecond
+	a := newTrackActivity(time.Now())
+	us.addTrackActivity(trackID, a)
+	stopCh := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				// Muted clients can stop sending altogether.
+				if us.isVoiceMuted(trackID) {
+					a.reset(now)
+					continue
+				}
+				if a.check(now, timeout) {
+					s.log.Debug("track stalled", mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID), mlog.String("trackID", trackID))
+					s.sendTrackEvent(TrackStalledEvent, us, trackID)
+				}
+			case <-stopCh:
+				return
+			case <-us.closeCh:
+				return
+			}
+		}
+	}()
+
+	onPacket := func(now time.Time) {
+		if a.onPacket(now) {
+			s.log.Debug("track resumed", mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID), mlog.String("trackID", trackID))
+			s.sendTrackEvent(TrackResumedEvent, us, trackID)
+		}
+	}
+
+	return onPacket, func() {
+		us.removeTrackActivity(trackID)
+		close(stopCh)
+	}
+}
+
+func (s *Server) sendTrackEvent(evType EventType, us *session, trackID string) {
+	ev := newEvent(evType, us.cfg)
+	ev.TrackID = trackID
+	s.sendEvent(ev)
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackActivity(t *testing.T) {
+	now := time.Now()
+	timeout := time.Second
+	a := newTrackActivity(now)
+
+	t.Run("active", func(t *testing.T) {
+		require.False(t, a.check(now.Add(timeout), timeout))
+		require.False(t, a.onPacket(now.Add(timeout)))
+		require.False(t, a.check(now.Add(2*timeout), timeout))
+		require.False(t, a.isStalled())
+	})
+
+	t.Run("stalled", func(t *testing.T) {
+		require.True(t, a.check(now.Add(3*timeout), timeout))
+		require.True(t, a.isStalled())
+		// Only reported once.
+		require.False(t, a.check(now.Add(4*timeout), timeout))
+		require.True(t, a.isStalled())
+	})
+
+	t.Run("resumed", func(t *testing.T) {
+		require.True(t, a.onPacket(now.Add(5*timeout)))
+		require.False(t, a.isStalled())
+		require.False(t, a.onPacket(now.Add(5*timeout)))
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		a.reset(now.Add(10 * timeout))
+		require.False(t, a.check(now.Add(10*timeout+timeout/2), timeout))
+		require.True(t, a.check(now.Add(12*timeout), timeout))
+		// Resetting keeps the track stalled until a packet is received.
+		a.reset(now.Add(13 * timeout))
+		require.True(t, a.isStalled())
+	})
+}
+
+func TestMonitorTrackActivity(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	cfg := SessionConfig{
+		GroupID:   "groupID",
+		CallID:    "callID",
+		UserID:    "userID",
+		SessionID: "sessionID",
+	}
+
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	us, err := server.addSession(cfg, peerConn, nil)
+	require.NoError(t, err)
+	defer func() {
+		err := server.CloseSession(cfg.SessionID)
+		require.NoError(t, err)
+	}()
+
+	// Draining the session events.
+	for i := 0; i < 2; i++ {
+		<-server.EventsCh()
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		onPacket, stop := server.monitorTrackActivity(us, "trackID")
+		defer stop()
+		require.Nil(t, onPacket)
+		require.Empty(t, us.trackActivity)
+	})
+
+	t.Run("stalled and resumed", func(t *testing.T) {
+		server.cfg.TrackInactivityTimeoutMs = 100
+		defer func() { server.cfg.TrackInactivityTimeoutMs = 0 }()
+
+		onPacket, stop := server.monitorTrackActivity(us, "trackID")
+		require.NotNil(t, onPacket)
+
+		ev := <-server.EventsCh()
+		require.Equal(t, TrackStalledEvent, ev.Type)
+		require.Equal(t, "sessionID", ev.SessionID)
+		require.Equal(t, "trackID", ev.TrackID)
+
+		state, err := server.GetCallState("groupID", "callID")
+		require.NoError(t, err)
+		require.Equal(t, []string{"trackID"}, state.Sessions[0].StalledTracks)
+		stats := server.GetCallsStats()
+		require.Len(t, stats, 1)
+		require.Equal(t, 1, stats[0].StalledTracks)
+
+		onPacket(time.Now())
+		ev = <-server.EventsCh()
+		require.Equal(t, TrackResumedEvent, ev.Type)
+		require.Equal(t, "trackID", ev.TrackID)
+
+		state, err = server.GetCallState("groupID", "callID")
+		require.NoError(t, err)
+		require.Empty(t, state.Sessions[0].StalledTracks)
+
+		stop()
+		require.Empty(t, us.trackActivity)
+	})
+
+	t.Run("muted voice", func(t *testing.T) {
+		server.cfg.TrackInactivityTimeoutMs = 100
+		defer func() { server.cfg.TrackInactivityTimeoutMs = 0 }()
+
+		voiceTrack, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: "audio/opus"}, "voiceTrackID", "streamID")
+		require.NoError(t, err)
+		us.mut.Lock()
+		us.outVoiceTrack = voiceTrack
+		us.mut.Unlock()
+
+		_, stop := server.monitorTrackActivity(us, "voiceTrackID")
+		defer stop()
+
+		select {
+		case ev := <-server.EventsCh():
+			require.Fail(t, "unexpected event", ev.Type)
+		case <-time.After(300 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// interfaceIPs returns the IPv4 addresses of the named network interface.
+func interfaceIPs(name string) ([]net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interface: %w", err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interface addresses: %w", err)
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			ips = append(ips, ipNet.IP.To4())
+		}
+	}
+	return ips, nil
+}
+
+// getInterfaceBytes sums up the bytes counted per local IP, as returned by
+// sourceIPCache.getBytes, into the interfaces with a budget, keyed by name.
+// Addresses are looked up on each call so that changes are picked up.
+func (s *Server) getInterfaceBytes(ipBytes map[string]uint64) map[string]uint64 {
+	res := make(map[string]uint64, len(s.cfg.Capacity.InterfaceBudgets))
+	for _, b := range s.cfg.Capacity.InterfaceBudgets {
+		ips, err := interfaceIPs(b.Interface)
+		if err != nil {
+			s.log.Debug("rtc: failed to get interface addresses", mlog.String("interface", b.Interface), mlog.Err(err))
+			continue
+		}
+		for _, ip := range ips {
+			res[b.Interface] += ipBytes[ip.String()]
+		}
+	}
+	return res
+}
+
+// placeCall returns the interface new calls should use, i.e. the one with
+// the most headroom, ties going to the one with fewer calls. It returns an
+// empty string if no budget is configured, allowing all interfaces.
+// It must not be called while holding a group lock.
+func (s *Server) placeCall() string {
+	ifaces := s.GetCapacity().Interfaces
+	if len(ifaces) == 0 {
+		return ""
+	}
+
+	calls := make(map[string]int, len(ifaces))
+	s.iterCalls(func(_ *group, c *call) {
+		calls[c.iface]++
+	})
+
+	best := ifaces[0]
+	for _, iface := range ifaces[1:] {
+		if iface.Headroom > best.Headroom ||
+			(iface.Headroom == best.Headroom && calls[iface.Interface] < calls[best.Interface]) {
+			best = iface
+		}
+	}
+	return best.Interface
+}
+
+// callAllowsInterface returns whether ICE candidates can be gathered on the
+// named interface for the session's call.
+func (s *Server) callAllowsInterface(cfg SessionConfig, name string) bool {
+	g := s.getGroup(cfg.GroupID)
+	if g == nil {
+		return true
+	}
+	c := g.getCall(cfg.CallID)
+	if c == nil || c.iface == "" {
+		return true
+	}
+	return c.iface == name
+}
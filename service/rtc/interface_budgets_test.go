@@ -0,0 +1,104 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterfaceIPs(t *testing.T) {
+	ips, err := interfaceIPs("lo")
+	if err != nil {
+		t.Skip("no loopback interface named lo")
+	}
+	require.Contains(t, ips, net.IPv4(127, 0, 0, 1).To4())
+
+	_, err = interfaceIPs("missing0")
+	require.Error(t, err)
+}
+
+func TestGetInterfaceBytes(t *testing.T) {
+	if _, err := interfaceIPs("lo"); err != nil {
+		t.Skip("no loopback interface named lo")
+	}
+
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	server.cfg.Capacity.InterfaceBudgets = InterfaceBudgets{
+		{Interface: "lo", MaxBandwidthMbps: 100},
+		{Interface: "missing0", MaxBandwidthMbps: 100},
+	}
+	res := server.getInterfaceBytes(map[string]uint64{"127.0.0.1": 100, "192.0.2.1": 50})
+	require.Equal(t, map[string]uint64{"lo": 100}, res)
+}
+
+func TestPlaceCall(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	t.Run("no budgets", func(t *testing.T) {
+		require.Empty(t, server.placeCall())
+		require.True(t, server.callAllowsInterface(SessionConfig{GroupID: "groupID", CallID: "callID"}, "eth0"))
+	})
+
+	server.cfg.Capacity.InterfaceBudgets = InterfaceBudgets{
+		{Interface: "eth0", MaxBandwidthMbps: 1000},
+		{Interface: "eth1", MaxBandwidthMbps: 100},
+	}
+	server.mut.Lock()
+	server.capacity = newCapacity(server.cfg.Capacity)
+	server.mut.Unlock()
+
+	newSession := func(callID, sessionID string) SessionConfig {
+		cfg := SessionConfig{
+			GroupID:   "groupID",
+			CallID:    callID,
+			UserID:    "userID",
+			SessionID: sessionID,
+		}
+		peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		_, err = server.addSession(cfg, peerConn, nil)
+		require.NoError(t, err)
+		return cfg
+	}
+	// Sessions must be closed for the server to stop.
+	defer func() {
+		for _, sessionID := range []string{"sessionA", "sessionB", "sessionC"} {
+			err := server.CloseSession(sessionID)
+			require.NoError(t, err)
+		}
+	}()
+
+	t.Run("ties go to fewer calls", func(t *testing.T) {
+		cfgA := newSession("callA", "sessionA")
+		require.Equal(t, "eth0", server.getGroup("groupID").getCall("callA").iface)
+		cfgB := newSession("callB", "sessionB")
+		require.Equal(t, "eth1", server.getGroup("groupID").getCall("callB").iface)
+
+		require.True(t, server.callAllowsInterface(cfgA, "eth0"))
+		require.False(t, server.callAllowsInterface(cfgA, "eth1"))
+		require.True(t, server.callAllowsInterface(cfgB, "eth1"))
+		require.False(t, server.callAllowsInterface(cfgB, "lo"))
+
+		// Joining an ongoing call keeps its interface.
+		newSession("callB", "sessionC")
+		require.Equal(t, "eth1", server.getGroup("groupID").getCall("callB").iface)
+	})
+
+	t.Run("most headroom", func(t *testing.T) {
+		server.mut.Lock()
+		server.capacity.Interfaces = []InterfaceCapacity{
+			{Interface: "eth0", MaxBandwidthMbps: 1000, Headroom: 0.1},
+			{Interface: "eth1", MaxBandwidthMbps: 100, Headroom: 0.5},
+		}
+		server.mut.Unlock()
+		require.Equal(t, "eth1", server.placeCall())
+	})
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+const (
+	// jitterBufferMaxPackets is the number of packets a jitter buffer can
+	// hold. The oldest packets get released early past it.
+	jitterBufferMaxPackets = 256
+	// jitterBufferMaxDelay is the maximum value of the configurable delays.
+	jitterBufferMaxDelay = 200
+)
+
+type jitterPacket struct {
+	pkt *rtp.Packet
+	// buf is the buffer pkt was unmarshaled from, if any, handed back along
+	// with it once released.
+	buf []byte
+	// releaseAt is the time the packet should be released at, at the latest.
+	releaseAt time.Time
+}
+
+// jitterBuffer delays the packets received from a publisher before they get
+// forwarded. Packets are released in sequence order. If clockRate is set,
+// packets are paced according to their timestamps, delayed by delay, so that
+// subscribers see smooth inter-arrival times. Otherwise packets are only
+// held for up to delay when some are missing, so that those received out of
+// order get released in order.
+type jitterBuffer struct {
+	delay     time.Duration
+	clockRate uint32
+	// out is called, from a dedicated goroutine, with the packets as they
+	// get released.
+	out func(pkt *rtp.Packet, buf []byte)
+	// now returns the current time. It's overridden in tests.
+	now func() time.Time
+
+	queue   []jitterPacket
+	started bool
+	// lastSeq is the sequence number of the last released packet.
+	lastSeq uint16
+	// baseTime and baseTS anchor the pacing of packets: a packet with
+	// timestamp baseTS is due at baseTime.
+	baseTime time.Time
+	baseTS   uint32
+
+	wakeCh  chan struct{}
+	closeCh chan struct{}
+	doneCh  chan struct{}
+	mut     sync.Mutex
+}
+
+func newJitterBuffer(delay time.Duration, clockRate uint32, out func(pkt *rtp.Packet, buf []byte)) *jitterBuffer {
+	jb := &jitterBuffer{
+		delay:     delay,
+		clockRate: clockRate,
+		out:       out,
+		now:       time.Now,
+		wakeCh:    make(chan struct{}, 1),
+		closeCh:   make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}
+	go jb.run()
+	return jb
+}
+
+// seqBefore returns whether sequence number a comes before b, accounting
+// for wrap-around.
+func seqBefore(a, b uint16) bool {
+	return int16(a-b) < 0
+}
+
+// push queues a packet received from the publisher.
+func (jb *jitterBuffer) push(pkt *rtp.Packet, buf []byte) {
+	jb.mut.Lock()
+	now := jb.now()
+	p := jitterPacket{pkt: pkt, buf: buf, releaseAt: now}
+	// Packets too late to be reordered (e.g. retransmissions) are released
+	// right away.
+	if !jb.started || seqBefore(jb.lastSeq, pkt.SequenceNumber) {
+		p.releaseAt = jb.releaseTime(pkt, now)
+	}
+
+	// Packets mostly arrive in order, so the queue is searched backwards.
+	i := len(jb.queue)
+	for i > 0 && seqBefore(pkt.SequenceNumber, jb.queue[i-1].pkt.SequenceNumber) {
+		i--
+	}
+	if i > 0 && jb.queue[i-1].pkt.SequenceNumber == pkt.SequenceNumber {
+		// Duplicate.
+		jb.mut.Unlock()
+		return
+	}
+	jb.queue = append(jb.queue, jitterPacket{})
+	copy(jb.queue[i+1:], jb.queue[i:])
+	jb.queue[i] = p
+	jb.mut.Unlock()
+
+	select {
+	case jb.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// releaseTime returns the time a packet received at now should be released
+// at. It must be called with the lock held.
+func (jb *jitterBuffer) releaseTime(pkt *rtp.Packet, now time.Time) time.Time {
+	if jb.clockRate == 0 {
+		return now.Add(jb.delay)
+	}
+
+	if jb.baseTime.IsZero() {
+		jb.baseTime = now.Add(jb.delay)
+		jb.baseTS = pkt.Timestamp
+	}
+
+	elapsed := time.Duration(int64(int32(pkt.Timestamp-jb.baseTS))) * time.Second / time.Duration(jb.clockRate)
+	releaseAt := jb.baseTime.Add(elapsed)
+
+	// The pacing gets anchored again if the packet arrived after it was
+	// due, or too early (e.g. after a timestamp jump), so that packets are
+	// never held for much longer than the delay.
+	if releaseAt.Before(now) || releaseAt.After(now.Add(2*jb.delay)) {
+		jb.baseTime = now.Add(jb.delay)
+		jb.baseTS = pkt.Timestamp
+		releaseAt = jb.baseTime
+	}
+
+	return releaseAt
+}
+
+// pop returns the packets due for release, along with the time the next one
+// is due at, if any. It must be called with the lock held.
+func (jb *jitterBuffer) pop(now time.Time) ([]jitterPacket, time.Time) {
+	var released []jitterPacket
+	for len(jb.queue) > 0 {
+		p := jb.queue[0]
+		// In reordering mode the next packet in sequence doesn't need to
+		// wait.
+		inSequence := jb.clockRate == 0 && (!jb.started || p.pkt.SequenceNumber == jb.lastSeq+1)
+		if !inSequence && p.releaseAt.After(now) && len(jb.queue) <= jitterBufferMaxPackets {
+			return released, p.releaseAt
+		}
+
+		jb.queue = jb.queue[1:]
+		released = append(released, p)
+		if !jb.started || seqBefore(jb.lastSeq, p.pkt.SequenceNumber) {
+			jb.lastSeq = p.pkt.SequenceNumber
+		}
+		jb.started = true
+	}
+	return released, time.Time{}
+}
+
+func (jb *jitterBuffer) run() {
+	defer close(jb.doneCh)
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		jb.mut.Lock()
+		released, next := jb.pop(jb.now())
+		jb.mut.Unlock()
+
+		for _, p := range released {
+			jb.out(p.pkt, p.buf)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+
+		select {
+		case <-jb.wakeCh:
+		case <-timer.C:
+		case <-jb.closeCh:
+			return
+		}
+	}
+}
+
+// close stops the jitter buffer, dropping the packets still queued.
+func (jb *jitterBuffer) close() {
+	close(jb.closeCh)
+	<-jb.doneCh
+}
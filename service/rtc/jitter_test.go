@@ -0,0 +1,170 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestJitterBuffer(delay time.Duration, clockRate uint32, now *time.Time) *jitterBuffer {
+	return &jitterBuffer{
+		delay:     delay,
+		clockRate: clockRate,
+		now:       func() time.Time { return *now },
+	}
+}
+
+func newJitterTestPacket(seq uint16, ts uint32) *rtp.Packet {
+	return &rtp.Packet{Header: rtp.Header{SequenceNumber: seq, Timestamp: ts}}
+}
+
+func jitterPacketSeqs(packets []jitterPacket) []uint16 {
+	var seqs []uint16
+	for _, p := range packets {
+		seqs = append(seqs, p.pkt.SequenceNumber)
+	}
+	return seqs
+}
+
+func TestSeqBefore(t *testing.T) {
+	require.True(t, seqBefore(1, 2))
+	require.False(t, seqBefore(2, 1))
+	require.False(t, seqBefore(1, 1))
+	require.True(t, seqBefore(65535, 0))
+	require.False(t, seqBefore(0, 65535))
+}
+
+func TestJitterBufferPacing(t *testing.T) {
+	now := time.Now()
+	jb := newTestJitterBuffer(40*time.Millisecond, 48000, &now)
+
+	// 20ms packets, the second and third ones arriving in a burst.
+	jb.push(newJitterTestPacket(1, 0), nil)
+	released, next := jb.pop(now)
+	require.Empty(t, released)
+	require.Equal(t, now.Add(40*time.Millisecond), next)
+
+	now = now.Add(40 * time.Millisecond)
+	jb.push(newJitterTestPacket(2, 960), nil)
+	jb.push(newJitterTestPacket(3, 1920), nil)
+	released, next = jb.pop(now)
+	require.Equal(t, []uint16{1}, jitterPacketSeqs(released))
+	require.Equal(t, now.Add(20*time.Millisecond), next)
+
+	now = now.Add(20 * time.Millisecond)
+	released, next = jb.pop(now)
+	require.Equal(t, []uint16{2}, jitterPacketSeqs(released))
+	require.Equal(t, now.Add(20*time.Millisecond), next)
+
+	now = now.Add(20 * time.Millisecond)
+	released, next = jb.pop(now)
+	require.Equal(t, []uint16{3}, jitterPacketSeqs(released))
+	require.True(t, next.IsZero())
+
+	t.Run("late packet", func(t *testing.T) {
+		jb.push(newJitterTestPacket(2, 960), nil)
+		released, _ := jb.pop(now)
+		require.Equal(t, []uint16{2}, jitterPacketSeqs(released))
+	})
+
+	t.Run("anchored again", func(t *testing.T) {
+		// Packet 4 was due 20ms ago.
+		now = now.Add(40 * time.Millisecond)
+		jb.push(newJitterTestPacket(4, 2880), nil)
+		released, next := jb.pop(now)
+		require.Empty(t, released)
+		require.Equal(t, now.Add(40*time.Millisecond), next)
+
+		// A timestamp jump.
+		jb.push(newJitterTestPacket(5, 2880+48000), nil)
+		now = now.Add(40 * time.Millisecond)
+		released, next = jb.pop(now)
+		require.Equal(t, []uint16{4, 5}, jitterPacketSeqs(released))
+		require.True(t, next.IsZero())
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		jb.push(newJitterTestPacket(6, 2880+48960), nil)
+		jb.push(newJitterTestPacket(6, 2880+48960), nil)
+		require.Len(t, jb.queue, 1)
+	})
+}
+
+func TestJitterBufferReordering(t *testing.T) {
+	now := time.Now()
+	jb := newTestJitterBuffer(30*time.Millisecond, 0, &now)
+
+	jb.push(newJitterTestPacket(65534, 0), nil)
+	jb.push(newJitterTestPacket(65535, 0), nil)
+	released, next := jb.pop(now)
+	require.Equal(t, []uint16{65534, 65535}, jitterPacketSeqs(released))
+	require.True(t, next.IsZero())
+
+	// Packet 0 is missing.
+	jb.push(newJitterTestPacket(1, 3000), nil)
+	released, next = jb.pop(now)
+	require.Empty(t, released)
+	require.Equal(t, now.Add(30*time.Millisecond), next)
+
+	now = now.Add(10 * time.Millisecond)
+	jb.push(newJitterTestPacket(0, 3000), nil)
+	released, next = jb.pop(now)
+	require.Equal(t, []uint16{0, 1}, jitterPacketSeqs(released))
+	require.True(t, next.IsZero())
+
+	t.Run("window elapsed", func(t *testing.T) {
+		jb.push(newJitterTestPacket(3, 6000), nil)
+		jb.push(newJitterTestPacket(4, 6000), nil)
+		released, _ := jb.pop(now)
+		require.Empty(t, released)
+
+		now = now.Add(30 * time.Millisecond)
+		released, next := jb.pop(now)
+		require.Equal(t, []uint16{3, 4}, jitterPacketSeqs(released))
+		require.True(t, next.IsZero())
+
+		// Packet 2 shows up too late.
+		jb.push(newJitterTestPacket(2, 3000), nil)
+		released, _ = jb.pop(now)
+		require.Equal(t, []uint16{2}, jitterPacketSeqs(released))
+
+		jb.push(newJitterTestPacket(5, 9000), nil)
+		released, _ = jb.pop(now)
+		require.Equal(t, []uint16{5}, jitterPacketSeqs(released))
+	})
+
+	t.Run("full", func(t *testing.T) {
+		for i := 0; i <= jitterBufferMaxPackets; i++ {
+			jb.push(newJitterTestPacket(uint16(7+i), 12000), nil)
+		}
+		// Packet 6 is missing but the oldest packet is released early, the
+		// following ones being in sequence.
+		released, _ := jb.pop(now)
+		require.Len(t, released, jitterBufferMaxPackets+1)
+		require.Equal(t, uint16(7), released[0].pkt.SequenceNumber)
+	})
+}
+
+func TestJitterBufferRun(t *testing.T) {
+	releasedCh := make(chan uint16, 10)
+	jb := newJitterBuffer(20*time.Millisecond, 48000, func(pkt *rtp.Packet, buf []byte) {
+		require.Equal(t, []byte{byte(pkt.SequenceNumber)}, buf)
+		releasedCh <- pkt.SequenceNumber
+	})
+
+	start := time.Now()
+	jb.push(newJitterTestPacket(1, 0), []byte{1})
+	jb.push(newJitterTestPacket(2, 960), []byte{2})
+	require.Equal(t, uint16(1), <-releasedCh)
+	require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	require.Equal(t, uint16(2), <-releasedCh)
+	require.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	jb.push(newJitterTestPacket(3, 1920), []byte{3})
+	jb.close()
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"time"
+)
+
+// Phases of the setup of a session, in the order they are expected to
+// happen.
+const (
+	// JoinPhaseWSAuth is when the join request got authenticated on the
+	// signaling connection and the session was initialized.
+	JoinPhaseWSAuth        = "ws_auth"
+	JoinPhaseOfferReceived = "offer_received"
+	JoinPhaseAnswerSent    = "answer_sent"
+	JoinPhaseICEConnected  = "ice_connected"
+	// JoinPhaseDTLSConnected is when the peer connection got established,
+	// meaning the DTLS handshake completed.
+	JoinPhaseDTLSConnected = "dtls_connected"
+	// JoinPhaseFirstRTP is when the first media packet was received from the
+	// session. It's never reached by sessions not sending any media.
+	JoinPhaseFirstRTP = "first_rtp"
+)
+
+// JoinTimings holds the times, in unix milliseconds, at which a session went
+// through each setup phase. Phases not reached yet are zero.
+type JoinTimings struct {
+	WSAuthAt        int64 `json:"ws_auth_at"`
+	OfferReceivedAt int64 `json:"offer_received_at"`
+	AnswerSentAt    int64 `json:"answer_sent_at"`
+	ICEConnectedAt  int64 `json:"ice_connected_at"`
+	DTLSConnectedAt int64 `json:"dtls_connected_at"`
+	FirstRTPAt      int64 `json:"first_rtp_at"`
+}
+
+func (t *JoinTimings) getPhase(phase string) *int64 {
+	switch phase {
+	case JoinPhaseWSAuth:
+		return &t.WSAuthAt
+	case JoinPhaseOfferReceived:
+		return &t.OfferReceivedAt
+	case JoinPhaseAnswerSent:
+		return &t.AnswerSentAt
+	case JoinPhaseICEConnected:
+		return &t.ICEConnectedAt
+	case JoinPhaseDTLSConnected:
+		return &t.DTLSConnectedAt
+	case JoinPhaseFirstRTP:
+		return &t.FirstRTPAt
+	default:
+		return nil
+	}
+}
+
+// setJoinPhase records the time the session reached the given phase. Only
+// the first occurrence is recorded. It returns the time elapsed since the
+// session was initialized and whether the phase was recorded.
+func (s *session) setJoinPhase(phase string, now time.Time) (time.Duration, bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	ts := s.joinTimings.getPhase(phase)
+	if ts == nil || *ts != 0 {
+		return 0, false
+	}
+	*ts = now.UnixMilli()
+
+	if phase == JoinPhaseWSAuth {
+		s.joinStartedAt = now
+		return 0, true
+	}
+	if s.joinStartedAt.IsZero() {
+		return 0, false
+	}
+
+	return now.Sub(s.joinStartedAt), true
+}
+
+// recordJoinPhase records the given setup phase of the session, observing
+// the time it took to reach it.
+func (s *Server) recordJoinPhase(us *session, phase string) {
+	now := time.Now()
+	if elapsed, ok := us.setJoinPhase(phase, now); ok && phase != JoinPhaseWSAuth {
+		s.metrics.ObserveJoinPhase(phase, elapsed.Seconds(), us.cfg.TraceID)
+		if phase == JoinPhaseDTLSConnected && !us.cfg.Hidden {
+			s.slo.recordJoin(now, elapsed)
+		}
+	}
+}
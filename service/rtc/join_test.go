@@ -0,0 +1,65 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetJoinPhase(t *testing.T) {
+	t.Run("not started", func(t *testing.T) {
+		us := &session{}
+		now := time.Now()
+		elapsed, ok := us.setJoinPhase(JoinPhaseOfferReceived, now)
+		require.False(t, ok)
+		require.Zero(t, elapsed)
+		require.Equal(t, now.UnixMilli(), us.joinTimings.OfferReceivedAt)
+	})
+
+	t.Run("unknown phase", func(t *testing.T) {
+		us := &session{}
+		_, ok := us.setJoinPhase("unknown", time.Now())
+		require.False(t, ok)
+		require.Equal(t, JoinTimings{}, us.joinTimings)
+	})
+
+	t.Run("phases", func(t *testing.T) {
+		us := &session{}
+		start := time.Now()
+
+		elapsed, ok := us.setJoinPhase(JoinPhaseWSAuth, start)
+		require.True(t, ok)
+		require.Zero(t, elapsed)
+
+		phases := []string{
+			JoinPhaseOfferReceived,
+			JoinPhaseAnswerSent,
+			JoinPhaseICEConnected,
+			JoinPhaseDTLSConnected,
+			JoinPhaseFirstRTP,
+		}
+		for i, phase := range phases {
+			elapsed, ok := us.setJoinPhase(phase, start.Add(time.Duration(i+1)*time.Second))
+			require.True(t, ok)
+			require.Equal(t, time.Duration(i+1)*time.Second, elapsed)
+		}
+
+		// Only the first occurrence is recorded.
+		_, ok = us.setJoinPhase(JoinPhaseFirstRTP, start.Add(time.Minute))
+		require.False(t, ok)
+
+		ms := start.UnixMilli()
+		require.Equal(t, JoinTimings{
+			WSAuthAt:        ms,
+			OfferReceivedAt: ms + 1000,
+			AnswerSentAt:    ms + 2000,
+			ICEConnectedAt:  ms + 3000,
+			DTLSConnectedAt: ms + 4000,
+			FirstRTPAt:      ms + 5000,
+		}, us.joinTimings)
+	})
+}
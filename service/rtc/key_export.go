@@ -0,0 +1,278 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/dtls/v2"
+	"github.com/pion/webrtc/v3"
+)
+
+// srtpExporterLabel is the label the SRTP keying material is exported from
+// the DTLS association with (RFC 5764 4.2).
+const srtpExporterLabel = "EXTRACTOR-dtls_srtp"
+
+// Roles of the server in the DTLS association of a session.
+const (
+	DTLSRoleClient = "client"
+	DTLSRoleServer = "server"
+)
+
+// srtpKeyLengths maps the supported protection profiles to the length of
+// their master key and master salt.
+var srtpKeyLengths = map[dtls.SRTPProtectionProfile][2]int{
+	dtls.SRTP_AES128_CM_HMAC_SHA1_80: {16, 14},
+	dtls.SRTP_AEAD_AES_128_GCM:       {16, 12},
+}
+
+// keyExportClient posts the exported keys. Redirects aren't followed so
+// that keys only ever reach the configured recorder.
+var keyExportClient = &http.Client{
+	CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// SRTPKeys holds the SRTP master keys and salts of a session, as derived
+// from its DTLS association. Byte slices are base64 encoded in JSON.
+type SRTPKeys struct {
+	SessionID string `json:"session_id"`
+	UserID    string `json:"user_id"`
+	// Profile is the negotiated SRTP protection profile (e.g.
+	// "AEAD_AES_128_GCM").
+	Profile string `json:"profile"`
+	// ServerRole is the DTLS role of rtcd in the association, telling which
+	// of the keys protect the packets it sends.
+	ServerRole string `json:"server_role"`
+	// The client keys protect the packets sent by the DTLS client, the
+	// server keys the ones sent by the DTLS server.
+	ClientMasterKey  []byte `json:"client_master_key"`
+	ClientMasterSalt []byte `json:"client_master_salt"`
+	ServerMasterKey  []byte `json:"server_master_key"`
+	ServerMasterSalt []byte `json:"server_master_salt"`
+	// LocalAddr and RemoteAddr are the addresses of the selected ICE
+	// candidate pair, so that captured packets can be matched to the keys.
+	LocalAddr  string `json:"local_addr"`
+	RemoteAddr string `json:"remote_addr"`
+}
+
+// KeyExport is the document posted to the recorder.
+type KeyExport struct {
+	ID      string `json:"id"`
+	GroupID string `json:"group_id"`
+	CallID  string `json:"call_id"`
+	// Reference is the caller provided reference the export is made under,
+	// e.g. a case number.
+	Reference  string     `json:"reference"`
+	ExportedAt int64      `json:"exported_at"`
+	Sessions   []SRTPKeys `json:"sessions"`
+}
+
+// KeyExportInfo describes an export, without the keys.
+type KeyExportInfo struct {
+	ID         string   `json:"id"`
+	GroupID    string   `json:"group_id"`
+	CallID     string   `json:"call_id"`
+	Reference  string   `json:"reference"`
+	ExportedAt int64    `json:"exported_at"`
+	SessionIDs []string `json:"session_ids"`
+	// SkippedSessionIDs lists the sessions whose keys couldn't be exported,
+	// usually because their DTLS association isn't established yet.
+	SkippedSessionIDs []string `json:"skipped_session_ids,omitempty"`
+}
+
+// setupRole returns the DTLS role the setup attribute of the given session
+// description settles on (RFC 5763 5), if any.
+func setupRole(desc *webrtc.SessionDescription) string {
+	if desc == nil {
+		return ""
+	}
+	for _, line := range strings.Split(desc.SDP, "\n") {
+		switch strings.TrimSpace(line) {
+		case "a=setup:active":
+			return DTLSRoleClient
+		case "a=setup:passive":
+			return DTLSRoleServer
+		}
+	}
+	return ""
+}
+
+// dtlsRole returns the local DTLS role negotiated by the given descriptions.
+// The offerer leaves the choice to the answerer so its role is the opposite
+// of the one in the answer.
+func dtlsRole(local, remote *webrtc.SessionDescription) (string, error) {
+	if local == nil || remote == nil {
+		return "", fmt.Errorf("missing session description")
+	}
+	if role := setupRole(local); role != "" {
+		return role, nil
+	}
+	switch setupRole(remote) {
+	case DTLSRoleClient:
+		return DTLSRoleServer, nil
+	case DTLSRoleServer:
+		return DTLSRoleClient, nil
+	}
+	return "", fmt.Errorf("DTLS role not negotiated")
+}
+
+// srtpKeysOf returns the SRTP keys of the given connected peer connection.
+func srtpKeysOf(pc *webrtc.PeerConnection) (SRTPKeys, error) {
+	transport := pc.SCTP().Transport()
+	if transport.State() != webrtc.DTLSTransportStateConnected {
+		return SRTPKeys{}, fmt.Errorf("DTLS transport is not connected")
+	}
+
+	profile, ok := transport.SelectedSRTPProtectionProfile()
+	if !ok {
+		return SRTPKeys{}, fmt.Errorf("no SRTP protection profile negotiated")
+	}
+	lengths, ok := srtpKeyLengths[profile]
+	if !ok {
+		return SRTPKeys{}, fmt.Errorf("unsupported SRTP protection profile %d", profile)
+	}
+	var keys SRTPKeys
+	for name, p := range srtpProtectionProfiles {
+		if p == profile {
+			keys.Profile = name
+		}
+	}
+
+	var err error
+	keys.ServerRole, err = dtlsRole(pc.CurrentLocalDescription(), pc.CurrentRemoteDescription())
+	if err != nil {
+		return SRTPKeys{}, err
+	}
+
+	// The keying material is laid out as client key, server key, client
+	// salt and server salt.
+	keyLen, saltLen := lengths[0], lengths[1]
+	material, err := transport.ExportKeyingMaterial(srtpExporterLabel, nil, 2*(keyLen+saltLen))
+	if err != nil {
+		return SRTPKeys{}, fmt.Errorf("failed to export keying material: %w", err)
+	}
+	keys.ClientMasterKey = material[:keyLen]
+	keys.ServerMasterKey = material[keyLen : 2*keyLen]
+	keys.ClientMasterSalt = material[2*keyLen : 2*keyLen+saltLen]
+	keys.ServerMasterSalt = material[2*keyLen+saltLen:]
+
+	if pair, err := transport.ICETransport().GetSelectedCandidatePair(); err == nil && pair != nil {
+		keys.LocalAddr = net.JoinHostPort(pair.Local.Address, strconv.Itoa(int(pair.Local.Port)))
+		keys.RemoteAddr = net.JoinHostPort(pair.Remote.Address, strconv.Itoa(int(pair.Remote.Port)))
+	}
+
+	return keys, nil
+}
+
+func postKeyExport(ctx context.Context, cfg KeyExportConfig, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.RecorderURL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.RecorderAuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.RecorderAuthToken)
+	}
+
+	resp, err := keyExportClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// ExportSRTPKeys posts the SRTP keys of the sessions of the given call to
+// the configured recorder, for lawful recording. The keys are never returned
+// to the caller. As the SFU terminates SRTP, every session has its own keys,
+// sessions joining afterwards require a new export.
+func (s *Server) ExportSRTPKeys(groupID, callID, reference string) (KeyExportInfo, error) {
+	cfg := s.cfg.KeyExport
+	if !cfg.Enable {
+		return KeyExportInfo{}, fmt.Errorf("key export is not enabled")
+	}
+
+	if reference == "" {
+		return KeyExportInfo{}, fmt.Errorf("invalid reference: should not be empty")
+	}
+
+	group := s.getGroup(groupID)
+	if group == nil {
+		return KeyExportInfo{}, fmt.Errorf("group not found: %s", groupID)
+	}
+	call := group.getCall(callID)
+	if call == nil {
+		return KeyExportInfo{}, fmt.Errorf("call not found: %s", callID)
+	}
+
+	export := KeyExport{
+		ID:         random.NewID(),
+		GroupID:    groupID,
+		CallID:     callID,
+		Reference:  reference,
+		ExportedAt: time.Now().UnixMilli(),
+	}
+	info := KeyExportInfo{
+		ID:         export.ID,
+		GroupID:    groupID,
+		CallID:     callID,
+		Reference:  reference,
+		ExportedAt: export.ExportedAt,
+	}
+
+	call.iterSessions(func(us *session) {
+		keys, err := srtpKeysOf(us.rtcConn)
+		if err != nil {
+			s.log.Debug("failed to get SRTP keys", mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+			info.SkippedSessionIDs = append(info.SkippedSessionIDs, us.cfg.SessionID)
+			return
+		}
+		keys.SessionID = us.cfg.SessionID
+		keys.UserID = us.cfg.UserID
+		export.Sessions = append(export.Sessions, keys)
+		info.SessionIDs = append(info.SessionIDs, us.cfg.SessionID)
+	})
+	if len(export.Sessions) == 0 {
+		return KeyExportInfo{}, fmt.Errorf("no connected session found")
+	}
+
+	data, err := json.Marshal(export)
+	if err != nil {
+		return KeyExportInfo{}, fmt.Errorf("failed to marshal keys: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.TimeoutSeconds)*time.Second)
+	defer cancel()
+	if err := postKeyExport(ctx, cfg, data); err != nil {
+		return KeyExportInfo{}, fmt.Errorf("failed to post keys: %w", err)
+	}
+
+	s.log.Warn("SRTP keys exported",
+		mlog.String("exportID", info.ID),
+		mlog.String("groupID", groupID),
+		mlog.String("callID", callID),
+		mlog.String("reference", reference),
+		mlog.String("recorderURL", cfg.RecorderURL),
+		mlog.Int("sessions", len(info.SessionIDs)))
+
+	return info, nil
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// connectTestPeers returns two peer connections connected to each other.
+func connectTestPeers(t *testing.T) (*webrtc.PeerConnection, *webrtc.PeerConnection) {
+	t.Helper()
+
+	offerer, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	answerer, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		offerer.Close()
+		answerer.Close()
+	})
+
+	connectedCh := make(chan struct{}, 2)
+	for _, pc := range []*webrtc.PeerConnection{offerer, answerer} {
+		pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+			if state == webrtc.PeerConnectionStateConnected {
+				connectedCh <- struct{}{}
+			}
+		})
+	}
+
+	_, err = offerer.CreateDataChannel("data", nil)
+	require.NoError(t, err)
+
+	// Candidates are exchanged as part of the descriptions.
+	offer, err := offerer.CreateOffer(nil)
+	require.NoError(t, err)
+	gatheringDone := webrtc.GatheringCompletePromise(offerer)
+	require.NoError(t, offerer.SetLocalDescription(offer))
+	<-gatheringDone
+	require.NoError(t, answerer.SetRemoteDescription(*offerer.LocalDescription()))
+
+	answer, err := answerer.CreateAnswer(nil)
+	require.NoError(t, err)
+	gatheringDone = webrtc.GatheringCompletePromise(answerer)
+	require.NoError(t, answerer.SetLocalDescription(answer))
+	<-gatheringDone
+	require.NoError(t, offerer.SetRemoteDescription(*answerer.LocalDescription()))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-connectedCh:
+		case <-time.After(10 * time.Second):
+			require.Fail(t, "timed out waiting for peers to connect")
+		}
+	}
+
+	return offerer, answerer
+}
+
+func TestDTLSRole(t *testing.T) {
+	desc := func(setup string) *webrtc.SessionDescription {
+		return &webrtc.SessionDescription{SDP: "v=0\r\na=setup:" + setup + "\r\n"}
+	}
+
+	_, err := dtlsRole(nil, desc("active"))
+	require.EqualError(t, err, "missing session description")
+
+	for _, tc := range []struct {
+		local    string
+		remote   string
+		expected string
+	}{
+		{"active", "actpass", DTLSRoleClient},
+		{"passive", "actpass", DTLSRoleServer},
+		{"actpass", "active", DTLSRoleServer},
+		{"actpass", "passive", DTLSRoleClient},
+	} {
+		role, err := dtlsRole(desc(tc.local), desc(tc.remote))
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, role)
+	}
+
+	_, err = dtlsRole(desc("actpass"), desc("actpass"))
+	require.EqualError(t, err, "DTLS role not negotiated")
+}
+
+func TestSRTPKeysOf(t *testing.T) {
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer pc.Close()
+	_, err = srtpKeysOf(pc)
+	require.EqualError(t, err, "DTLS transport is not connected")
+
+	offerer, answerer := connectTestPeers(t)
+
+	offererKeys, err := srtpKeysOf(offerer)
+	require.NoError(t, err)
+	answererKeys, err := srtpKeysOf(answerer)
+	require.NoError(t, err)
+
+	// Both ends derive the same keys, from opposite roles.
+	require.Equal(t, DTLSRoleServer, offererKeys.ServerRole)
+	require.Equal(t, DTLSRoleClient, answererKeys.ServerRole)
+	require.Equal(t, SRTPProfileAEADAES128GCM, offererKeys.Profile)
+	require.Equal(t, offererKeys.Profile, answererKeys.Profile)
+	require.Len(t, offererKeys.ClientMasterKey, 16)
+	require.Len(t, offererKeys.ClientMasterSalt, 12)
+	require.Equal(t, offererKeys.ClientMasterKey, answererKeys.ClientMasterKey)
+	require.Equal(t, offererKeys.ClientMasterSalt, answererKeys.ClientMasterSalt)
+	require.Equal(t, offererKeys.ServerMasterKey, answererKeys.ServerMasterKey)
+	require.Equal(t, offererKeys.ServerMasterSalt, answererKeys.ServerMasterSalt)
+	require.NotEqual(t, offererKeys.ClientMasterKey, offererKeys.ServerMasterKey)
+	require.Equal(t, offererKeys.LocalAddr, answererKeys.RemoteAddr)
+	require.Equal(t, offererKeys.RemoteAddr, answererKeys.LocalAddr)
+}
+
+func TestExportSRTPKeys(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	var exports []KeyExport
+	recorder := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer recorderToken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/keys", http.StatusTemporaryRedirect)
+			return
+		}
+		var export KeyExport
+		if err := json.NewDecoder(r.Body).Decode(&export); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		exports = append(exports, export)
+	}))
+	defer recorder.Close()
+	transport := keyExportClient.Transport
+	keyExportClient.Transport = recorder.Client().Transport
+	defer func() {
+		keyExportClient.Transport = transport
+	}()
+
+	_, err := server.ExportSRTPKeys("groupID", "callID", "case-42")
+	require.EqualError(t, err, "key export is not enabled")
+
+	server.cfg.KeyExport = KeyExportConfig{
+		Enable:            true,
+		RecorderURL:       recorder.URL + "/keys",
+		RecorderAuthToken: "recorderToken",
+		TimeoutSeconds:    5,
+	}
+
+	_, err = server.ExportSRTPKeys("groupID", "callID", "")
+	require.EqualError(t, err, "invalid reference: should not be empty")
+
+	_, err = server.ExportSRTPKeys("groupID", "callID", "case-42")
+	require.EqualError(t, err, "group not found: groupID")
+
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	_, err = server.addSession(SessionConfig{
+		GroupID:   "groupID",
+		CallID:    "callID",
+		UserID:    "userA",
+		SessionID: "sessionA",
+	}, pc, nil)
+	require.NoError(t, err)
+	defer server.CloseSession("sessionA")
+
+	_, err = server.ExportSRTPKeys("groupID", "callID", "case-42")
+	require.EqualError(t, err, "no connected session found")
+
+	serverPC, clientPC := connectTestPeers(t)
+	_, err = server.addSession(SessionConfig{
+		GroupID:   "groupID",
+		CallID:    "callID",
+		UserID:    "userB",
+		SessionID: "sessionB",
+	}, serverPC, nil)
+	require.NoError(t, err)
+	defer server.CloseSession("sessionB")
+
+	t.Run("exported", func(t *testing.T) {
+		info, err := server.ExportSRTPKeys("groupID", "callID", "case-42")
+		require.NoError(t, err)
+		require.NotEmpty(t, info.ID)
+		require.Equal(t, "case-42", info.Reference)
+		require.Equal(t, []string{"sessionB"}, info.SessionIDs)
+		require.Equal(t, []string{"sessionA"}, info.SkippedSessionIDs)
+
+		require.Len(t, exports, 1)
+		require.Equal(t, info.ID, exports[0].ID)
+		require.Equal(t, "callID", exports[0].CallID)
+		require.Equal(t, "case-42", exports[0].Reference)
+		require.Len(t, exports[0].Sessions, 1)
+		keys := exports[0].Sessions[0]
+		require.Equal(t, "sessionB", keys.SessionID)
+		require.Equal(t, "userB", keys.UserID)
+
+		// The recorder gets the keys the client uses.
+		clientKeys, err := srtpKeysOf(clientPC)
+		require.NoError(t, err)
+		require.Equal(t, clientKeys.ClientMasterKey, keys.ClientMasterKey)
+		require.Equal(t, clientKeys.ServerMasterKey, keys.ServerMasterKey)
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		server.cfg.KeyExport.RecorderURL = recorder.URL + "/redirect"
+		_, err := server.ExportSRTPKeys("groupID", "callID", "case-42")
+		require.EqualError(t, err, "failed to post keys: unexpected status code 307")
+		require.Len(t, exports, 1)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		server.cfg.KeyExport.RecorderURL = recorder.URL + "/keys"
+		server.cfg.KeyExport.RecorderAuthToken = "invalid"
+		_, err := server.ExportSRTPKeys("groupID", "callID", "case-42")
+		require.EqualError(t, err, "failed to post keys: unexpected status code 401")
+	})
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+)
+
+// keyFrameCache holds the packets of the last complete key frame forwarded
+// on a VP8 track, so that new subscribers can be sent one right away instead
+// of waiting for the publisher to send the next one.
+type keyFrameCache struct {
+	// maxSize is the payload size above which key frames aren't cached.
+	maxSize int
+
+	// pending holds the packets of the key frame being forwarded.
+	pending     []*rtp.Packet
+	pendingSize int
+	// packets holds the packets of the last complete key frame.
+	packets []*rtp.Packet
+
+	mut sync.Mutex
+}
+
+func newKeyFrameCache(maxSize int) *keyFrameCache {
+	return &keyFrameCache{
+		maxSize: maxSize,
+	}
+}
+
+// push adds a packet forwarded on the track. It expects packets in the order
+// they are forwarded, a key frame being only cached if none of its packets
+// is missing.
+func (c *keyFrameCache) push(pkt *rtp.Packet) {
+	var vp8 codecs.VP8Packet
+	payload, err := vp8.Unmarshal(pkt.Payload)
+	if err != nil {
+		return
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if vp8.S == 1 && vp8.PID == 0 {
+		c.pending = nil
+		c.pendingSize = 0
+		if _, _, key := vp8KeyFrameSize(payload); !key {
+			return
+		}
+	} else if len(c.pending) == 0 {
+		return
+	} else if last := c.pending[len(c.pending)-1]; pkt.Timestamp != last.Timestamp || pkt.SequenceNumber != last.SequenceNumber+1 {
+		c.pending = nil
+		return
+	}
+
+	if c.pendingSize += len(pkt.Payload); c.pendingSize > c.maxSize {
+		c.pending = nil
+		return
+	}
+	c.pending = append(c.pending, pkt.Clone())
+
+	if pkt.Marker {
+		c.packets = c.pending
+		c.pending = nil
+	}
+}
+
+// get returns copies of the packets of the last cached key frame, with
+// sequence numbers leading up to nextSeq, or nil if there is none.
+func (c *keyFrameCache) get(nextSeq uint16) []*rtp.Packet {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if len(c.packets) == 0 {
+		return nil
+	}
+
+	pkts := make([]*rtp.Packet, len(c.packets))
+	seq := nextSeq - uint16(len(c.packets))
+	for i, pkt := range c.packets {
+		pkts[i] = pkt.Clone()
+		pkts[i].SequenceNumber = seq + uint16(i)
+	}
+	return pkts
+}
+
+// keyFrameInterceptor starts the VP8 streams sent to a session with the
+// cached key frame of their track. Packets are held back until the first
+// frame starts, since the frames before can't be decoded anyway, and the
+// key frame is sent right before it, numbered so that the subscriber sees no
+// gap. The frames that follow may reference ones the subscriber never got,
+// so a fresh key frame is requested as well.
+type keyFrameInterceptor struct {
+	interceptor.NoOp
+
+	// getKeyFrame returns the cached key frame of the track sent with the
+	// given SSRC, as returned by keyFrameCache.get, or nil if there is none.
+	getKeyFrame func(ssrc uint32, nextSeq uint16) []*rtp.Packet
+	// onSent is called once a cached key frame got sent on the stream with
+	// the given SSRC.
+	onSent func(ssrc uint32)
+}
+
+// NewInterceptor implements interceptor.Factory. The interceptor only keeps
+// state per stream so the same instance is shared.
+func (i *keyFrameInterceptor) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return i, nil
+}
+
+func (i *keyFrameInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	if !strings.EqualFold(info.MimeType, webrtc.MimeTypeVP8) {
+		return writer
+	}
+
+	var started bool
+	var mut sync.Mutex
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		mut.Lock()
+		defer mut.Unlock()
+
+		if started {
+			return writer.Write(header, payload, attributes)
+		}
+
+		var vp8 codecs.VP8Packet
+		frame, err := vp8.Unmarshal(payload)
+		if err != nil || vp8.S != 1 || vp8.PID != 0 {
+			return 0, nil
+		}
+
+		var pkts []*rtp.Packet
+		if _, _, key := vp8KeyFrameSize(frame); !key {
+			pkts = i.getKeyFrame(info.SSRC, header.SequenceNumber)
+		}
+		for _, pkt := range pkts {
+			pkt.SSRC = info.SSRC
+			pkt.PayloadType = info.PayloadType
+			// Nothing gets written until the connection is secured, the
+			// key frame is sent before the next frame then.
+			if n, err := writer.Write(&pkt.Header, pkt.Payload, interceptor.Attributes{}); err != nil || n == 0 {
+				return n, err
+			}
+		}
+
+		n, err := writer.Write(header, payload, attributes)
+		if n > 0 {
+			started = true
+			if len(pkts) > 0 && i.onSent != nil {
+				i.onSent(info.SSRC)
+			}
+		}
+		return n, err
+	})
+}
+
+// getSessionCall returns the call of the session with the given config, nil
+// if it ended.
+func (s *Server) getSessionCall(cfg SessionConfig) *call {
+	if g := s.getGroup(cfg.GroupID); g != nil {
+		return g.getCall(cfg.CallID)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	// vp8KeyFrameStart is the payload of the first packet of a 640x480 key
+	// frame.
+	vp8KeyFrameStart = []byte{0x10, 0x50, 0x01, 0x00, 0x9d, 0x01, 0x2a, 0x80, 0x02, 0xe0, 0x01}
+	// vp8DeltaFrameStart is the payload of the first packet of a delta
+	// frame.
+	vp8DeltaFrameStart = []byte{0x10, 0x01, 0x00, 0x00}
+	// vp8FrameContinuation is the payload of a packet following the first
+	// one of a frame.
+	vp8FrameContinuation = []byte{0x00, 0xaa, 0xbb, 0xcc}
+)
+
+func newVP8FramePacket(seq uint16, ts uint32, marker bool, payload []byte) *rtp.Packet {
+	return &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    96,
+			SequenceNumber: seq,
+			Timestamp:      ts,
+			SSRC:           1000,
+			Marker:         marker,
+		},
+		Payload: payload,
+	}
+}
+
+func TestKeyFrameCache(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		c := newKeyFrameCache(1024)
+		require.Nil(t, c.get(10))
+		c.push(newVP8FramePacket(1, 100, true, vp8DeltaFrameStart))
+		require.Nil(t, c.get(10))
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		c := newKeyFrameCache(1024)
+		c.push(newVP8FramePacket(1, 100, false, vp8KeyFrameStart))
+		c.push(newVP8FramePacket(2, 100, false, vp8FrameContinuation))
+		// Not complete yet.
+		require.Nil(t, c.get(10))
+		c.push(newVP8FramePacket(3, 100, true, vp8FrameContinuation))
+		// Following frames don't replace it.
+		c.push(newVP8FramePacket(4, 200, true, vp8DeltaFrameStart))
+
+		pkts := c.get(10)
+		require.Len(t, pkts, 3)
+		for i, pkt := range pkts {
+			require.Equal(t, uint16(7+i), pkt.SequenceNumber)
+			require.Equal(t, uint32(100), pkt.Timestamp)
+		}
+		require.Equal(t, vp8KeyFrameStart, pkts[0].Payload)
+		require.True(t, pkts[2].Marker)
+
+		// Copies are returned.
+		pkts[0].Payload[1] = 0xff
+		require.Equal(t, byte(0x50), c.get(10)[0].Payload[1])
+		require.Equal(t, byte(0x50), vp8KeyFrameStart[1])
+
+		// Sequence numbers wrap around.
+		pkts = c.get(1)
+		require.Equal(t, []uint16{65534, 65535, 0}, []uint16{pkts[0].SequenceNumber, pkts[1].SequenceNumber, pkts[2].SequenceNumber})
+	})
+
+	t.Run("replaced", func(t *testing.T) {
+		c := newKeyFrameCache(1024)
+		c.push(newVP8FramePacket(1, 100, true, vp8KeyFrameStart))
+		c.push(newVP8FramePacket(2, 200, false, vp8KeyFrameStart))
+		c.push(newVP8FramePacket(3, 200, true, vp8FrameContinuation))
+		pkts := c.get(10)
+		require.Len(t, pkts, 2)
+		require.Equal(t, uint32(200), pkts[0].Timestamp)
+	})
+
+	t.Run("missing packet", func(t *testing.T) {
+		c := newKeyFrameCache(1024)
+		c.push(newVP8FramePacket(1, 100, true, vp8KeyFrameStart))
+		c.push(newVP8FramePacket(2, 200, false, vp8KeyFrameStart))
+		c.push(newVP8FramePacket(4, 200, true, vp8FrameContinuation))
+		// The previous key frame is kept.
+		pkts := c.get(10)
+		require.Len(t, pkts, 1)
+		require.Equal(t, uint32(100), pkts[0].Timestamp)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		c := newKeyFrameCache(len(vp8KeyFrameStart) + 1)
+		c.push(newVP8FramePacket(1, 100, false, vp8KeyFrameStart))
+		c.push(newVP8FramePacket(2, 100, true, vp8FrameContinuation))
+		require.Nil(t, c.get(10))
+	})
+}
+
+func TestKeyFrameInterceptor(t *testing.T) {
+	cache := newKeyFrameCache(1024)
+	cache.push(newVP8FramePacket(1, 100, false, vp8KeyFrameStart))
+	cache.push(newVP8FramePacket(2, 100, true, vp8FrameContinuation))
+
+	var sent []uint32
+	i := &keyFrameInterceptor{
+		getKeyFrame: func(ssrc uint32, nextSeq uint16) []*rtp.Packet {
+			require.Equal(t, uint32(2000), ssrc)
+			return cache.get(nextSeq)
+		},
+		onSent: func(ssrc uint32) {
+			sent = append(sent, ssrc)
+		},
+	}
+
+	type written struct {
+		seq     uint16
+		ts      uint32
+		ssrc    uint32
+		payload []byte
+	}
+	newWriter := func(ready *bool) (interceptor.RTPWriter, *[]written) {
+		var out []written
+		return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, _ interceptor.Attributes) (int, error) {
+			if !*ready {
+				return 0, nil
+			}
+			out = append(out, written{header.SequenceNumber, header.Timestamp, header.SSRC, payload})
+			return header.MarshalSize() + len(payload), nil
+		}), &out
+	}
+	write := func(w interceptor.RTPWriter, pkt *rtp.Packet) {
+		pkt.SSRC = 2000
+		_, err := w.Write(&pkt.Header, pkt.Payload, interceptor.Attributes{})
+		require.NoError(t, err)
+	}
+
+	t.Run("audio", func(t *testing.T) {
+		ready := true
+		inner, out := newWriter(&ready)
+		w := i.BindLocalStream(&interceptor.StreamInfo{SSRC: 2000, MimeType: webrtc.MimeTypeOpus}, inner)
+		write(w, newVP8FramePacket(10, 300, true, []byte{0x01}))
+		require.Len(t, *out, 1)
+	})
+
+	t.Run("video", func(t *testing.T) {
+		ready := false
+		inner, out := newWriter(&ready)
+		w := i.BindLocalStream(&interceptor.StreamInfo{SSRC: 2000, PayloadType: 100, MimeType: webrtc.MimeTypeVP8}, inner)
+
+		// The connection isn't secured yet.
+		write(w, newVP8FramePacket(10, 300, false, vp8DeltaFrameStart))
+		require.Empty(t, *out)
+		ready = true
+
+		// Held back until a frame starts.
+		write(w, newVP8FramePacket(11, 300, true, vp8FrameContinuation))
+		require.Empty(t, *out)
+		require.Empty(t, sent)
+
+		write(w, newVP8FramePacket(12, 400, false, vp8DeltaFrameStart))
+		write(w, newVP8FramePacket(13, 400, true, vp8FrameContinuation))
+		require.Equal(t, []written{
+			{10, 100, 2000, vp8KeyFrameStart},
+			{11, 100, 2000, vp8FrameContinuation},
+			{12, 400, 2000, vp8DeltaFrameStart},
+			{13, 400, 2000, vp8FrameContinuation},
+		}, *out)
+		require.Equal(t, []uint32{2000}, sent)
+	})
+
+	t.Run("key frame", func(t *testing.T) {
+		sent = nil
+		ready := true
+		inner, out := newWriter(&ready)
+		w := i.BindLocalStream(&interceptor.StreamInfo{SSRC: 2000, MimeType: webrtc.MimeTypeVP8}, inner)
+
+		// No need for the cached one.
+		write(w, newVP8FramePacket(20, 500, true, vp8KeyFrameStart))
+		require.Equal(t, []written{{20, 500, 2000, vp8KeyFrameStart}}, *out)
+		require.Empty(t, sent)
+	})
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+)
+
+// listenUDP binds an IPv4 UDP socket to the given local port or, if zero, to
+// a free port of the range, starting from a random one so that concurrent
+// callers don't all contend for the lowest ports.
+func (c LocalUDPPortsConfig) listenUDP(port int) (*net.UDPConn, error) {
+	if port != 0 || !c.isEnabled() {
+		return net.ListenUDP("udp4", &net.UDPAddr{Port: port})
+	}
+
+	size := c.Max - c.Min + 1
+	start := rand.Intn(size)
+	for i := 0; i < size; i++ {
+		port := c.Min + (start+i)%size
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: port})
+		if err == nil {
+			return conn, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no free port in range [%d, %d]", c.Min, c.Max)
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalUDPPortsListenUDP(t *testing.T) {
+	// A port known to be free once released.
+	conn, err := net.ListenUDP("udp4", nil)
+	require.NoError(t, err)
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	t.Run("disabled", func(t *testing.T) {
+		var cfg LocalUDPPortsConfig
+		c, err := cfg.listenUDP(0)
+		require.NoError(t, err)
+		defer c.Close()
+		require.NotZero(t, c.LocalAddr().(*net.UDPAddr).Port)
+	})
+
+	t.Run("no free port", func(t *testing.T) {
+		cfg := LocalUDPPortsConfig{Min: port, Max: port}
+		c, err := cfg.listenUDP(0)
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("in range", func(t *testing.T) {
+		require.NoError(t, conn.Close())
+		cfg := LocalUDPPortsConfig{Min: port, Max: port}
+		c, err := cfg.listenUDP(0)
+		require.NoError(t, err)
+		defer c.Close()
+		require.Equal(t, port, c.LocalAddr().(*net.UDPAddr).Port)
+	})
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"encoding/json"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// Actions taken once a maintenance window starts.
+const (
+	// MaintenanceActionDrain stops the node from accepting new sessions and
+	// closes the remaining ones after the shutdown timeout, leaving the
+	// process running.
+	MaintenanceActionDrain = "drain"
+	// MaintenanceActionShutdown drains the node and stops the process.
+	MaintenanceActionShutdown = "shutdown"
+)
+
+// MaintenanceNotice tells the participants of the calls hosted on the node
+// about an upcoming maintenance window, so that meetings can wrap up before
+// the node goes away.
+type MaintenanceNotice struct {
+	ID     string `json:"id"`
+	Action string `json:"action"`
+	// StartAt is the time, in unix milliseconds, the window starts at.
+	StartAt int64 `json:"start_at"`
+	// Cancelled is set when a previously notified window got cancelled.
+	Cancelled bool `json:"cancelled,omitempty"`
+}
+
+// maintenanceMsg is the payload of MaintenanceMessage messages. As with
+// signaling messages, the kind is given by the type field.
+type maintenanceMsg struct {
+	Type string `json:"type"`
+	MaintenanceNotice
+}
+
+func (s *Server) getMaintenanceNotice() *MaintenanceNotice {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.maintenanceNotice
+}
+
+func (s *Server) sendMaintenanceMsg(us *session, notice MaintenanceNotice) {
+	data, err := json.Marshal(maintenanceMsg{Type: "maintenance", MaintenanceNotice: notice})
+	if err != nil {
+		s.log.Error("failed to marshal maintenance notice", mlog.Err(err))
+		return
+	}
+
+	msg := Message{
+		GroupID:   us.cfg.GroupID,
+		UserID:    us.cfg.UserID,
+		SessionID: us.cfg.SessionID,
+		Type:      MaintenanceMessage,
+		Data:      data,
+	}
+	select {
+	case s.receiveCh <- msg:
+	default:
+		s.log.Error("failed to send maintenance message: channel is full", mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+	}
+}
+
+// SetMaintenanceNotice notifies the participants of all the calls of the
+// given maintenance window, or of its cancellation if Cancelled is set.
+// Sessions joining afterwards are notified through SendMaintenanceNotice until
+// the notice is cancelled.
+func (s *Server) SetMaintenanceNotice(notice MaintenanceNotice) {
+	s.mut.Lock()
+	if notice.Cancelled {
+		s.maintenanceNotice = nil
+	} else {
+		s.maintenanceNotice = &notice
+	}
+	s.mut.Unlock()
+
+	s.iterCalls(func(_ *group, c *call) {
+		c.iterSessions(func(us *session) {
+			if !us.cfg.Hidden {
+				s.sendMaintenanceMsg(us, notice)
+			}
+		})
+	})
+}
+
+// SendMaintenanceNotice notifies the given session of the upcoming
+// maintenance window, if any. It's meant to be called once the session is
+// initialized.
+func (s *Server) SendMaintenanceNotice(sessionID string) {
+	notice := s.getMaintenanceNotice()
+	if notice == nil {
+		return
+	}
+
+	s.mut.RLock()
+	cfg, ok := s.sessions[sessionID]
+	s.mut.RUnlock()
+	if !ok || cfg.Hidden {
+		return
+	}
+
+	group := s.getGroup(cfg.GroupID)
+	if group == nil {
+		return
+	}
+	call := group.getCall(cfg.CallID)
+	if call == nil {
+		return
+	}
+	if us := call.getSession(sessionID); us != nil {
+		s.sendMaintenanceMsg(us, *notice)
+	}
+}
+
+// IsDraining returns whether the server stopped accepting new sessions.
+func (s *Server) IsDraining() bool {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.draining
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceNotice(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	addSession := func(sessionID string, hidden bool) {
+		t.Helper()
+		peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		_, err = server.addSession(SessionConfig{
+			GroupID:   "groupID",
+			CallID:    "callID",
+			UserID:    sessionID,
+			SessionID: sessionID,
+			Hidden:    hidden,
+		}, peerConn, nil)
+		require.NoError(t, err)
+	}
+
+	receiveNotice := func(t *testing.T) (string, MaintenanceNotice) {
+		t.Helper()
+		for {
+			select {
+			case msg := <-server.ReceiveCh():
+				if msg.Type != MaintenanceMessage {
+					continue
+				}
+				var received maintenanceMsg
+				require.NoError(t, json.Unmarshal(msg.Data, &received))
+				require.Equal(t, "maintenance", received.Type)
+				return msg.SessionID, received.MaintenanceNotice
+			case <-time.After(time.Second):
+				require.Fail(t, "timed out waiting for maintenance message")
+				return "", MaintenanceNotice{}
+			}
+		}
+	}
+
+	addSession("sessionA", false)
+	defer server.CloseSession("sessionA")
+	addSession("sessionB", true)
+	defer server.CloseSession("sessionB")
+
+	// Nothing to send yet.
+	server.SendMaintenanceNotice("sessionA")
+	require.Nil(t, server.getMaintenanceNotice())
+
+	notice := MaintenanceNotice{
+		ID:      "maintenanceID",
+		Action:  MaintenanceActionShutdown,
+		StartAt: time.Now().Add(time.Hour).UnixMilli(),
+	}
+	server.SetMaintenanceNotice(notice)
+	sessionID, received := receiveNotice(t)
+	require.Equal(t, "sessionA", sessionID)
+	require.Equal(t, notice, received)
+
+	t.Run("new session", func(t *testing.T) {
+		addSession("sessionC", false)
+		defer server.CloseSession("sessionC")
+		server.SendMaintenanceNotice("sessionC")
+		sessionID, received := receiveNotice(t)
+		require.Equal(t, "sessionC", sessionID)
+		require.Equal(t, notice, received)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		cancelled := notice
+		cancelled.Cancelled = true
+		server.SetMaintenanceNotice(cancelled)
+		sessionID, received := receiveNotice(t)
+		require.Equal(t, "sessionA", sessionID)
+		require.Equal(t, cancelled, received)
+		require.Nil(t, server.getMaintenanceNotice())
+	})
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pion/mdns"
+	"golang.org/x/net/ipv4"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const (
+	MDNSCandidatesModeIgnore  = "ignore"
+	MDNSCandidatesModeResolve = "resolve"
+)
+
+// mdnsCandidates handles the ICE candidates of clients whose address is an
+// mDNS (.local) host name, as gathered by browsers to avoid exposing local
+// IP addresses.
+type mdnsCandidates struct {
+	log mlog.LoggerIFace
+	// query resolves a host name. It's nil if the candidates are ignored.
+	query   func(ctx context.Context, name string) (net.IP, error)
+	timeout time.Duration
+	close   func() error
+}
+
+func newMDNSCandidates(cfg MDNSCandidatesConfig, log mlog.LoggerIFace) *mdnsCandidates {
+	c := &mdnsCandidates{
+		log:     log,
+		timeout: time.Duration(cfg.ResolveTimeoutMs) * time.Millisecond,
+	}
+	if cfg.Mode != MDNSCandidatesModeResolve {
+		return c
+	}
+
+	conn, err := newMDNSConn()
+	if err != nil {
+		// Servers commonly can't join multicast groups.
+		log.Error("failed to set up mDNS, ignoring mDNS candidates", mlog.Err(err))
+		return c
+	}
+	c.query = func(ctx context.Context, name string) (net.IP, error) {
+		_, src, err := conn.Query(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		// The address of the host answering the query.
+		switch addr := src.(type) {
+		case *net.UDPAddr:
+			return addr.IP, nil
+		case *net.IPAddr:
+			return addr.IP, nil
+		default:
+			return nil, fmt.Errorf("unexpected address type %T", src)
+		}
+	}
+	c.close = conn.Close
+
+	return c
+}
+
+func newMDNSConn() (*mdns.Conn, error) {
+	addr, err := net.ResolveUDPAddr("udp4", mdns.DefaultAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve address: %w", err)
+	}
+	l, err := net.ListenUDP("udp4", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	conn, err := mdns.Server(ipv4.NewPacketConn(l), &mdns.Config{})
+	if err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to create mDNS server: %w", err)
+	}
+	return conn, nil
+}
+
+// Close releases the mDNS connection, if any.
+func (c *mdnsCandidates) Close() error {
+	if c.close == nil {
+		return nil
+	}
+	return c.close()
+}
+
+// newContext returns a context bounding the resolution of the candidates of
+// a single message.
+func (c *mdnsCandidates) newContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.timeout)
+}
+
+// mdnsCandidateFields returns the fields of candidate if its address is an
+// mDNS host name.
+func mdnsCandidateFields(candidate string) ([]string, bool) {
+	// <foundation> <component> <transport> <priority> <address> <port> typ <type> ...
+	fields := strings.Fields(candidate)
+	if len(fields) < 8 || fields[7] != "host" || !strings.HasSuffix(fields[4], ".local") {
+		return nil, false
+	}
+	return fields, true
+}
+
+// process returns the candidate to add in place of the given one (an
+// attribute value, e.g. "candidate:1 1 udp ..."), with its mDNS host name
+// resolved. It returns false if the candidate should be dropped.
+func (c *mdnsCandidates) process(ctx context.Context, sessionID, candidate string) (string, bool) {
+	fields, ok := mdnsCandidateFields(candidate)
+	if !ok {
+		return candidate, true
+	}
+
+	if c.query == nil {
+		c.log.Debug("ignoring mDNS candidate", mlog.String("sessionID", sessionID), mlog.String("address", fields[4]))
+		return "", false
+	}
+
+	ip, err := c.query(ctx, fields[4])
+	if err != nil {
+		c.log.Warn("failed to resolve mDNS candidate", mlog.Err(err),
+			mlog.String("sessionID", sessionID), mlog.String("address", fields[4]))
+		return "", false
+	}
+
+	fields[4] = ip.String()
+	return strings.Join(fields, " "), true
+}
+
+// processSDP applies process to the candidates of sdp, within a single
+// resolve timeout.
+func (c *mdnsCandidates) processSDP(sessionID, sdp string) string {
+	if !strings.Contains(sdp, ".local") {
+		return sdp
+	}
+
+	ctx, cancel := c.newContext()
+	defer cancel()
+
+	lines := strings.SplitAfter(sdp, "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		value := strings.TrimRight(line, "\r\n")
+		if !strings.HasPrefix(value, "a=candidate:") {
+			out = append(out, line)
+			continue
+		}
+		candidate, ok := c.process(ctx, sessionID, strings.TrimPrefix(value, "a="))
+		if !ok {
+			continue
+		}
+		out = append(out, "a="+candidate+line[len(value):])
+	}
+
+	return strings.Join(out, "")
+}
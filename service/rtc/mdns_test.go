@@ -0,0 +1,95 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMDNSCandidates(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, log.Shutdown())
+	}()
+
+	hostCandidate := "candidate:1 1 udp 2122260223 8b4f6a2e-6fa3-4b83-9b41-1b5e3e6e0c1a.local 54321 typ host generation 0"
+	srflxCandidate := "candidate:2 1 udp 1686052607 203.0.113.10 54321 typ srflx raddr 0.0.0.0 rport 0 generation 0"
+
+	t.Run("ignore", func(t *testing.T) {
+		c := newMDNSCandidates(MDNSCandidatesConfig{Mode: MDNSCandidatesModeIgnore}, log)
+		defer c.Close()
+
+		_, ok := c.process(context.Background(), "sessionID", hostCandidate)
+		require.False(t, ok)
+
+		candidate, ok := c.process(context.Background(), "sessionID", srflxCandidate)
+		require.True(t, ok)
+		require.Equal(t, srflxCandidate, candidate)
+	})
+
+	t.Run("resolve", func(t *testing.T) {
+		c := &mdnsCandidates{
+			log:     log,
+			timeout: time.Second,
+			query: func(ctx context.Context, name string) (net.IP, error) {
+				require.Equal(t, "8b4f6a2e-6fa3-4b83-9b41-1b5e3e6e0c1a.local", name)
+				return net.ParseIP("192.168.1.10"), nil
+			},
+		}
+
+		candidate, ok := c.process(context.Background(), "sessionID", hostCandidate)
+		require.True(t, ok)
+		require.Equal(t, "candidate:1 1 udp 2122260223 192.168.1.10 54321 typ host generation 0", candidate)
+	})
+
+	t.Run("resolve failure", func(t *testing.T) {
+		c := &mdnsCandidates{
+			log:     log,
+			timeout: time.Second,
+			query: func(ctx context.Context, name string) (net.IP, error) {
+				return nil, errors.New("timed out")
+			},
+		}
+
+		_, ok := c.process(context.Background(), "sessionID", hostCandidate)
+		require.False(t, ok)
+	})
+
+	t.Run("sdp", func(t *testing.T) {
+		c := &mdnsCandidates{
+			log:     log,
+			timeout: time.Second,
+			query: func(ctx context.Context, name string) (net.IP, error) {
+				if name == "unknown.local" {
+					return nil, errors.New("timed out")
+				}
+				return net.ParseIP("192.168.1.10"), nil
+			},
+		}
+
+		sdp := "v=0\r\n" +
+			"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+			"a=" + hostCandidate + "\r\n" +
+			"a=candidate:3 1 udp 2122260223 unknown.local 54322 typ host\r\n" +
+			"a=" + srflxCandidate + "\r\n" +
+			"a=end-of-candidates\r\n"
+
+		require.Equal(t, "v=0\r\n"+
+			"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n"+
+			"a=candidate:1 1 udp 2122260223 192.168.1.10 54321 typ host generation 0\r\n"+
+			"a="+srflxCandidate+"\r\n"+
+			"a=end-of-candidates\r\n", c.processSDP("sessionID", sdp))
+
+		noMDNS := "v=0\r\na=" + srflxCandidate + "\r\n"
+		require.Equal(t, noMDNS, c.processSDP("sessionID", noMDNS))
+	})
+}
@@ -7,7 +7,26 @@ type Metrics interface {
 	IncRTCSessions(groupID string, callID string)
 	DecRTCSessions(groupID string, callID string)
 	IncRTCConnState(state string)
-	IncRTPPackets(direction, trackType string)
+	IncRTCSessionCloses(reason string)
+	// AddRTPPackets and AddRTPPacketBytes are called periodically with the
+	// RTP traffic since the previous call, rather than for each packet.
+	AddRTPPackets(direction, trackType string, value int)
 	AddRTPPacketBytes(direction, trackType string, value int)
 	IncRTCErrors(groupID string, errType string)
+	IncRTXPackets(trackType, result string)
+	IncSSRCCollisions(direction, result string)
+	IncEgressShapedPackets(trackType string)
+	IncSendQueueDrops(lane string)
+	SetConnectivityCheck(checkType, url string, ok bool)
+	ObserveJoinPhase(phase string, seconds float64, traceID string)
+	ObserveOneWayDelay(leg string, seconds float64)
+	SetUDPSocketBufferSize(direction string, size int)
+	IncPublicIPChanges()
+	AddUDPConnWrites(conn string, writes, errors uint64)
+	SetUDPConnWriteLatency(conn string, seconds float64)
+	IncUDPConnRebinds(reason string)
+	SetUDPSourceIPCacheSize(size int)
+	AddUDPTruncatedPackets(count uint64)
+	SetCapacityHeadroom(headroom float64)
+	SetSRTPBenchmarkPacketTime(profile string, seconds float64)
 }
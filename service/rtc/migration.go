@@ -0,0 +1,136 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pion/ice/v2"
+	"github.com/pion/webrtc/v3"
+)
+
+// SessionMigration describes a change of the network address of a client
+// the session was re-anchored to.
+type SessionMigration struct {
+	// PreviousAddress is the address the session used to be connected to.
+	PreviousAddress string `json:"previous_address"`
+	// Address is the address the session is now connected to.
+	Address string `json:"address"`
+}
+
+// migrationDetector detects clients whose network address changed (e.g. a
+// mobile device switching networks): once consent freshness is lost, any
+// packet received from an address other than the one of the selected
+// candidate pair triggers a migration. The ICE agent won't switch to the new
+// address on its own as its candidate pair usually has a lower priority.
+type migrationDetector struct {
+	// consentLost is set (1) while the ICE connection is disconnected. It's
+	// accessed atomically as it's checked for every received packet.
+	consentLost int32
+	// onMigrate is called, in its own goroutine, when the client is detected
+	// sending from a new address. The migration is pending until a candidate
+	// pair with a new remote address gets selected or reset is called.
+	onMigrate func(prevAddr, addr string)
+
+	remoteAddr string
+	migrating  bool
+	// migratingFrom is the remote address when the pending migration was
+	// detected.
+	migratingFrom string
+	mut           sync.Mutex
+}
+
+func newMigrationDetector(onMigrate func(prevAddr, addr string)) *migrationDetector {
+	return &migrationDetector{onMigrate: onMigrate}
+}
+
+// setICEState tracks the ICE connection state.
+func (d *migrationDetector) setICEState(state webrtc.ICEConnectionState) {
+	switch state {
+	case webrtc.ICEConnectionStateDisconnected:
+		atomic.StoreInt32(&d.consentLost, 1)
+	case webrtc.ICEConnectionStateConnected, webrtc.ICEConnectionStateCompleted:
+		atomic.StoreInt32(&d.consentLost, 0)
+	}
+}
+
+// setSelectedPair tracks the remote address of the selected candidate pair.
+// It returns the pending migration if the pair completes it.
+func (d *migrationDetector) setSelectedPair(pair *webrtc.ICECandidatePair) *SessionMigration {
+	if pair == nil || pair.Remote == nil {
+		return nil
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	d.remoteAddr = net.JoinHostPort(pair.Remote.Address, strconv.Itoa(int(pair.Remote.Port)))
+	if !d.migrating || d.remoteAddr == d.migratingFrom {
+		return nil
+	}
+	d.migrating = false
+
+	return &SessionMigration{
+		PreviousAddress: d.migratingFrom,
+		Address:         d.remoteAddr,
+	}
+}
+
+// onPacket is called with the source address of every packet received by
+// the ICE agent.
+func (d *migrationDetector) onPacket(addr net.Addr) {
+	if atomic.LoadInt32(&d.consentLost) == 0 {
+		return
+	}
+
+	d.mut.Lock()
+	if d.migrating || d.remoteAddr == "" || addr.String() == d.remoteAddr {
+		d.mut.Unlock()
+		return
+	}
+	d.migrating = true
+	d.migratingFrom = d.remoteAddr
+	prevAddr := d.remoteAddr
+	d.mut.Unlock()
+
+	go d.onMigrate(prevAddr, addr.String())
+}
+
+// reset aborts the pending migration so that a new one can be detected.
+func (d *migrationDetector) reset() {
+	d.mut.Lock()
+	d.migrating = false
+	d.mut.Unlock()
+}
+
+// migrationUDPMux reports the source address of the packets read through
+// the connections it hands out.
+type migrationUDPMux struct {
+	ice.UDPMux
+	onPacket func(addr net.Addr)
+}
+
+func (m *migrationUDPMux) GetConn(ufrag string, isIPv6 bool) (net.PacketConn, error) {
+	conn, err := m.UDPMux.GetConn(ufrag, isIPv6)
+	if err != nil {
+		return nil, err
+	}
+	return &migrationConn{PacketConn: conn, onPacket: m.onPacket}, nil
+}
+
+type migrationConn struct {
+	net.PacketConn
+	onPacket func(addr net.Addr)
+}
+
+func (c *migrationConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if err == nil {
+		c.onPacket(addr)
+	}
+	return n, addr, err
+}
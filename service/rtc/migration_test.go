@@ -0,0 +1,125 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationDetector(t *testing.T) {
+	type migration struct {
+		prevAddr string
+		addr     string
+	}
+
+	newDetector := func() (*migrationDetector, chan migration) {
+		migrationCh := make(chan migration, 10)
+		d := newMigrationDetector(func(prevAddr, addr string) {
+			migrationCh <- migration{prevAddr, addr}
+		})
+		d.setSelectedPair(&webrtc.ICECandidatePair{
+			Remote: &webrtc.ICECandidate{Address: "192.168.1.10", Port: 50000},
+		})
+		d.setICEState(webrtc.ICEConnectionStateConnected)
+		return d, migrationCh
+	}
+
+	oldAddr := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 50000}
+	newAddr := &net.UDPAddr{IP: net.ParseIP("203.0.113.10"), Port: 40000}
+	newPair := &webrtc.ICECandidatePair{
+		Remote: &webrtc.ICECandidate{Address: "203.0.113.10", Port: 40000},
+	}
+
+	t.Run("connected", func(t *testing.T) {
+		d, migrationCh := newDetector()
+		d.onPacket(newAddr)
+		time.Sleep(10 * time.Millisecond)
+		require.Empty(t, migrationCh)
+	})
+
+	t.Run("same address", func(t *testing.T) {
+		d, migrationCh := newDetector()
+		d.setICEState(webrtc.ICEConnectionStateDisconnected)
+		d.onPacket(oldAddr)
+		time.Sleep(10 * time.Millisecond)
+		require.Empty(t, migrationCh)
+	})
+
+	t.Run("migration", func(t *testing.T) {
+		d, migrationCh := newDetector()
+		d.setICEState(webrtc.ICEConnectionStateDisconnected)
+		d.onPacket(newAddr)
+		require.Equal(t, migration{"192.168.1.10:50000", "203.0.113.10:40000"}, <-migrationCh)
+
+		// Only a single migration is pending at once.
+		d.onPacket(&net.UDPAddr{IP: net.ParseIP("203.0.113.11"), Port: 40000})
+		time.Sleep(10 * time.Millisecond)
+		require.Empty(t, migrationCh)
+
+		d.setICEState(webrtc.ICEConnectionStateChecking)
+		require.Equal(t, &SessionMigration{
+			PreviousAddress: "192.168.1.10:50000",
+			Address:         "203.0.113.10:40000",
+		}, d.setSelectedPair(newPair))
+		d.setICEState(webrtc.ICEConnectionStateConnected)
+
+		d.onPacket(oldAddr)
+		time.Sleep(10 * time.Millisecond)
+		require.Empty(t, migrationCh)
+	})
+
+	t.Run("same pair selected again", func(t *testing.T) {
+		d, migrationCh := newDetector()
+		d.setICEState(webrtc.ICEConnectionStateDisconnected)
+		d.onPacket(newAddr)
+		<-migrationCh
+
+		require.Nil(t, d.setSelectedPair(&webrtc.ICECandidatePair{
+			Remote: &webrtc.ICECandidate{Address: "192.168.1.10", Port: 50000},
+		}))
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		d, migrationCh := newDetector()
+		d.setICEState(webrtc.ICEConnectionStateDisconnected)
+		d.onPacket(newAddr)
+		<-migrationCh
+
+		d.reset()
+		require.Nil(t, d.setSelectedPair(newPair))
+
+		d.setICEState(webrtc.ICEConnectionStateDisconnected)
+		d.onPacket(oldAddr)
+		require.Equal(t, migration{"203.0.113.10:40000", "192.168.1.10:50000"}, <-migrationCh)
+	})
+}
+
+func TestMigrationConn(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	addrCh := make(chan net.Addr, 1)
+	mc := &migrationConn{PacketConn: conn, onPacket: func(addr net.Addr) {
+		addrCh <- addr
+	}}
+
+	sender, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer sender.Close()
+	_, err = sender.Write([]byte("packet"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 16)
+	n, addr, err := mc.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "packet", string(buf[:n]))
+	require.Equal(t, sender.LocalAddr().String(), addr.String())
+	require.Equal(t, addr, <-addrCh)
+}
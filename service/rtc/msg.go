@@ -19,6 +19,11 @@ const (
 	UnmuteMessage
 	ScreenOnMessage
 	ScreenOffMessage
+	CaptionMessage
+	TrackPauseMessage
+	TrackResumeMessage
+	TrackFramerateMessage
+	MaintenanceMessage
 )
 
 type Message struct {
@@ -27,6 +32,9 @@ type Message struct {
 	SessionID string      `msgpack:"session_id"`
 	Type      MessageType `msgpack:"type"`
 	Data      []byte      `msgpack:"data,omitempty"`
+	// Seq is the signaling sequence number assigned by the service when
+	// delivering the message. It's zero if not set.
+	Seq uint64 `msgpack:"seq,omitempty"`
 }
 
 func (m *Message) IsValid() error {
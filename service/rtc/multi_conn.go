@@ -5,25 +5,273 @@ package rtc
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"math/rand"
 	"net"
 	"os"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
+
+	"github.com/mattermost/rtcd/service/crash"
+
+	"golang.org/x/net/ipv4"
 )
 
 const (
+	// receiveMTU is the default, and maximum, size of the read buffers as the
+	// ICE stack reads packets into buffers of this size.
 	receiveMTU = 8192
+	// minReceiveMTU is the minimum size of the read buffers, that of the
+	// smallest MTU allowed by IPv6, which WebRTC packets are sized for.
+	minReceiveMTU = 1280
+
+	// Bounds of the delay before a reader retries after a temporary error.
+	readRetryMinDelay = 10 * time.Millisecond
+	readRetryMaxDelay = time.Second
+)
+
+// newBufPool returns a pool of read buffers of the given size, or of
+// receiveMTU if zero.
+func newBufPool(size int) *sync.Pool {
+	if size <= 0 {
+		size = receiveMTU
+	}
+	return &sync.Pool{
+		New: func() interface{} {
+			return make([]byte, size)
+		},
+	}
+}
+
+// Kinds of errors returned by multiConn.ReadFrom.
+type readErrorKind int
+
+const (
+	// readErrorTimeout means the read deadline was exceeded. Reading can
+	// continue after extending it.
+	readErrorTimeout readErrorKind = iota
+	// readErrorTemporary means the read failed for a transient reason
+	// (e.g. an ICMP error or memory pressure). Readers retry on their own.
+	readErrorTemporary
+	// readErrorFatal means the conn is no longer usable.
+	readErrorFatal
+)
+
+// temporaryReadErrnos lists the errors after which reading from a socket can
+// be retried.
+var temporaryReadErrnos = []error{
+	syscall.EAGAIN,
+	syscall.EINTR,
+	syscall.ENOBUFS,
+	syscall.ENOMEM,
+	syscall.ECONNREFUSED,
+	syscall.ECONNRESET,
+	syscall.EHOSTUNREACH,
+	syscall.ENETUNREACH,
+}
+
+// readError is the error returned by multiConn.ReadFrom. It implements
+// net.Error so that callers can tell timeouts and temporary errors apart
+// from fatal ones.
+type readError struct {
+	kind readErrorKind
+	err  error
+}
+
+func (e *readError) Error() string {
+	return e.err.Error()
+}
+
+func (e *readError) Unwrap() error {
+	return e.err
+}
+
+func (e *readError) Timeout() bool {
+	return e.kind == readErrorTimeout
+}
+
+func (e *readError) Temporary() bool {
+	return e.kind == readErrorTimeout || e.kind == readErrorTemporary
+}
+
+func newReadError(err error) *readError {
+	return &readError{kind: classifyReadError(err), err: err}
+}
+
+func classifyReadError(err error) readErrorKind {
+	if os.IsTimeout(err) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return readErrorTimeout
+	}
+	if errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) {
+		return readErrorFatal
+	}
+	for _, errnos := range [][]error{temporaryReadErrnos, platformTemporaryReadErrnos} {
+		for _, errno := range errnos {
+			if errors.Is(err, errno) {
+				return readErrorTemporary
+			}
+		}
+	}
+	return readErrorFatal
+}
+
+// readRetryDelay returns the delay before the given retry attempt, doubling
+// at each attempt with a random jitter so that readers hitting the same
+// error don't retry in lockstep.
+func readRetryDelay(attempt int) time.Duration {
+	delay := readRetryMaxDelay
+	if attempt < 7 {
+		delay = readRetryMinDelay << attempt
+		if delay > readRetryMaxDelay {
+			delay = readRetryMaxDelay
+		}
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// Modes of distributing writes among the connections of a multiConn.
+const (
+	// UDPWriteModeRoundRobin spreads writes equally among the connections.
+	UDPWriteModeRoundRobin = "round_robin"
+	// UDPWriteModePinned sends all the writes to a given destination through
+	// the same connection, so that each flow stays on a single socket and
+	// kernel queue.
+	UDPWriteModePinned = "pinned"
+	// UDPWriteModeAdaptive spreads writes among the connections like
+	// round-robin but steers them away from the connections whose writes are
+	// notably slower or recently failed, so that a saturated socket doesn't
+	// degrade all the flows.
+	UDPWriteModeAdaptive = "adaptive"
+)
+
+const (
+	// writeLatencyWeight is the inverse of the weight given to a new sample
+	// in the moving average of the write latency of a conn.
+	writeLatencyWeight = 16
+	// writeLatencySlack is the latency difference below which conns are
+	// considered equally healthy, so that noise doesn't skew the writes.
+	writeLatencySlack = 50 * time.Microsecond
+	// writeErrorBackoff is how long a conn is avoided after a failed write.
+	writeErrorBackoff = time.Second
+)
+
+// connWriteStats tracks the health of the writes to a conn. Fields are
+// accessed atomically.
+type connWriteStats struct {
+	writes uint64
+	errors uint64
+	// latency is the moving average of the duration of the writes, in
+	// nanoseconds.
+	latency int64
+	// failedAt is the time of the last failed write, in Unix nanoseconds.
+	failedAt int64
+}
+
+func (s *connWriteStats) record(d time.Duration, err error, now time.Time) {
+	atomic.AddUint64(&s.writes, 1)
+	if err != nil {
+		atomic.AddUint64(&s.errors, 1)
+		atomic.StoreInt64(&s.failedAt, now.UnixNano())
+	}
+	// Concurrent writes may lose samples, which is fine for an estimate.
+	avg := atomic.LoadInt64(&s.latency)
+	atomic.StoreInt64(&s.latency, avg+(int64(d)-avg)/writeLatencyWeight)
+}
+
+func (s *connWriteStats) failedRecently(now time.Time) bool {
+	failedAt := atomic.LoadInt64(&s.failedAt)
+	return failedAt != 0 && now.UnixNano()-failedAt < int64(writeErrorBackoff)
+}
+
+// healthier returns whether writes to the conn tracked by s are expected to
+// go notably better than to the one tracked by other.
+func (s *connWriteStats) healthier(other *connWriteStats, now time.Time) bool {
+	failed, otherFailed := s.failedRecently(now), other.failedRecently(now)
+	if failed || otherFailed {
+		return !failed && otherFailed
+	}
+	return atomic.LoadInt64(&other.latency) > 2*atomic.LoadInt64(&s.latency)+int64(writeLatencySlack)
+}
+
+// connReadStats tracks the health of the reads from a conn. Fields are
+// accessed atomically.
+type connReadStats struct {
+	reads uint64
+	// lastReadAt is the time of the last successful read, or of the start
+	// of the reader if none, in Unix nanoseconds.
+	lastReadAt int64
+	// stopped is set to one once the reader stopped because of a fatal
+	// error.
+	stopped int32
+}
+
+func newConnReadStats(now time.Time) *connReadStats {
+	return &connReadStats{lastReadAt: now.UnixNano()}
+}
+
+func (s *connReadStats) record(now time.Time) {
+	atomic.AddUint64(&s.reads, 1)
+	atomic.StoreInt64(&s.lastReadAt, now.UnixNano())
+}
+
+// Reasons for rebinding a conn.
+const (
+	// connRebindStalled means the conn stopped receiving packets while the
+	// others kept receiving, as happens when the socket got detached from
+	// its interface (e.g. after a flap).
+	connRebindStalled = "stalled"
+	// connRebindStopped means the reader of the conn stopped because of a
+	// fatal error.
+	connRebindStopped = "stopped"
 )
 
 type multiConn struct {
-	conns        []net.PacketConn
+	conns []net.PacketConn
+	// pconns holds, for each conn, the wrapper used to read the destination
+	// IP of received packets and to set the source IP of sent ones. Entries
+	// are nil for conns bound to a specific address.
+	pconns []*ipv4.PacketConn
+	// writeStats holds, for each conn, the stats of the writes to it.
+	writeStats []*connWriteStats
+	// readStats holds, for each conn, the stats of the reads from it.
+	readStats    []*connReadStats
+	srcIPs       *sourceIPCache
+	stopChs      []chan struct{}
 	addr         net.Addr
 	readResultCh chan readResult
-	closeCh      chan struct{}
-	bufPool      *sync.Pool
-	counter      uint64
-	wg           sync.WaitGroup
+	// shardChs, if set, replace readResultCh: received packets are
+	// dispatched among them by shardRouter, per session.
+	shardChs    []chan readResult
+	shardRouter *shardRouter
+	closeCh     chan struct{}
+	bufPool     *sync.Pool
+	writeMode   string
+	counter     uint64
+	readCounter uint64
+	// rebindCounter is the number of conns replaced because unhealthy.
+	rebindCounter uint64
+	// truncCounter is the number of packets which filled a read buffer,
+	// and so were likely truncated.
+	truncCounter uint64
+	// tempErrCounter is the number of temporary read errors readers
+	// recovered from.
+	tempErrCounter uint64
+	wg             sync.WaitGroup
+	mut            sync.RWMutex
+	// crash reports the panics of the readers, which are then restarted.
+	crash *crash.Reporter
+	// readerCPUs, if set, are the CPUs the readers get pinned to.
+	readerCPUs []int
+
+	// The read deadline is handled here rather than on the conns so that
+	// readers never stop because of it. readDeadlineCh gets closed whenever
+	// the deadline changes, waking up pending reads.
+	readDeadline   time.Time
+	readDeadlineCh chan struct{}
+	deadlineMut    sync.Mutex
 }
 
 type readResult struct {
@@ -33,7 +281,23 @@ type readResult struct {
 	buf  []byte
 }
 
-func newMultiConn(conns []net.PacketConn) (*multiConn, error) {
+// multiConnConfig holds the settings of a multiConn.
+type multiConnConfig struct {
+	// writeMode is one of the UDPWriteMode* values.
+	writeMode string
+	// readShards, if greater than one, is the number of shards received
+	// packets are dispatched among (see shards) rather than returned by
+	// ReadFrom.
+	readShards int
+	// readerCPUs, if not empty, are the CPUs the readers get pinned to.
+	readerCPUs []int
+	// receiveMTU is the size of the read buffers. Defaults to receiveMTU if
+	// zero.
+	receiveMTU int
+}
+
+// newMultiConn returns a multiConn serving the given conns.
+func newMultiConn(conns []net.PacketConn, cfg multiConnConfig, reporter *crash.Reporter) (*multiConn, error) {
 	if len(conns) == 0 {
 		return nil, errors.New("conns should not be empty")
 	}
@@ -43,89 +307,558 @@ func newMultiConn(conns []net.PacketConn) (*multiConn, error) {
 		}
 	}
 	var mc multiConn
-	mc.conns = conns
 	mc.addr = conns[0].LocalAddr()
+	mc.writeMode = cfg.writeMode
+	mc.crash = reporter
+	mc.readerCPUs = cfg.readerCPUs
+	mc.srcIPs = newSourceIPCache()
 	mc.readResultCh = make(chan readResult)
-	mc.closeCh = make(chan struct{})
-	mc.bufPool = &sync.Pool{
-		New: func() interface{} {
-			return make([]byte, receiveMTU)
-		},
+	for i := 0; cfg.readShards > 1 && i < cfg.readShards; i++ {
+		mc.shardChs = append(mc.shardChs, make(chan readResult))
+	}
+	if len(mc.shardChs) > 0 {
+		mc.shardRouter = newShardRouter(len(mc.shardChs))
 	}
-	mc.wg.Add(len(conns))
+	mc.closeCh = make(chan struct{})
+	mc.readDeadlineCh = make(chan struct{})
+	mc.bufPool = newBufPool(cfg.receiveMTU)
 	for _, conn := range conns {
-		go mc.reader(conn)
+		mc.startReader(conn)
 	}
 	return &mc, nil
 }
 
-func (mc *multiConn) reader(conn net.PacketConn) {
+// NewMultiConn returns a connection reading from, and spreading the writes
+// among, the given conns, according to writeMode (one of the UDPWriteMode*
+// values), as the server does with its UDP sockets. It's exported for
+// benchmarking purposes.
+func NewMultiConn(conns []net.PacketConn, writeMode string) (net.PacketConn, error) {
+	mc, err := newMultiConn(conns, multiConnConfig{writeMode: writeMode}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
+// startReader adds the given conn to the set and spawns its reader. Must be
+// called with mc.mut held for writing (or before mc is shared).
+func (mc *multiConn) startReader(conn net.PacketConn) {
+	mc.conns = append(mc.conns, nil)
+	mc.pconns = append(mc.pconns, nil)
+	mc.writeStats = append(mc.writeStats, nil)
+	mc.readStats = append(mc.readStats, nil)
+	mc.stopChs = append(mc.stopChs, nil)
+	mc.runReader(len(mc.conns)-1, conn)
+}
+
+// runReader serves the given conn at the given index of the set, spawning its
+// reader. Must be called with mc.mut held for writing (or before mc is
+// shared).
+func (mc *multiConn) runReader(idx int, conn net.PacketConn) {
+	stopCh := make(chan struct{})
+	pconn := newSourceIPConn(conn)
+	readStats := newConnReadStats(time.Now())
+	mc.conns[idx] = conn
+	mc.pconns[idx] = pconn
+	mc.writeStats[idx] = &connWriteStats{}
+	mc.readStats[idx] = readStats
+	mc.stopChs[idx] = stopCh
+	mc.wg.Add(1)
+	go mc.reader(conn, pconn, readStats, stopCh)
+}
+
+func (mc *multiConn) reader(conn net.PacketConn, pconn *ipv4.PacketConn, readStats *connReadStats, stopCh chan struct{}) {
 	defer mc.wg.Done()
+	if len(mc.readerCPUs) > 0 {
+		// The buffers the pool allocates for this reader are first touched,
+		// and so placed in memory, on the node of its CPUs. Failing to pin
+		// isn't fatal, the reader runs wherever it's scheduled.
+		_ = pinThread(mc.readerCPUs)
+	}
+	// A panicking reader is restarted as the conn would otherwise stop being
+	// read, affecting all the sessions using it.
+	for mc.readLoop(conn, pconn, readStats, stopCh) {
+		select {
+		case <-time.After(readRetryMaxDelay):
+		case <-mc.closeCh:
+			return
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// readLoop reads from conn until it's stopped, returning whether it
+// panicked.
+func (mc *multiConn) readLoop(conn net.PacketConn, pconn *ipv4.PacketConn, readStats *connReadStats, stopCh chan struct{}) (panicked bool) {
+	defer mc.crash.Recover("rtc.multiConn.reader", func() { panicked = true })
+
 	var res readResult
+	var attempt int
 	for {
 		res.buf = mc.bufPool.Get().([]byte)
-		res.n, res.addr, res.err = conn.ReadFrom(res.buf)
+		if pconn != nil {
+			var cm *ipv4.ControlMessage
+			res.n, cm, res.addr, res.err = pconn.ReadFrom(res.buf)
+			if res.err == nil && cm != nil {
+				if src := mc.srcIPs.learn(res.addr, cm.Dst); src != nil {
+					src.count(res.n)
+				}
+			}
+		} else {
+			res.n, res.addr, res.err = conn.ReadFrom(res.buf)
+		}
+
+		// The conn was removed from the set, errors caused by closing it
+		// should not be surfaced to the reader side.
 		select {
-		case mc.readResultCh <- res:
-		case <-mc.closeCh:
+		case <-stopCh:
+			mc.bufPool.Put(res.buf)
+			return
+		default:
+		}
+
+		var kind readErrorKind
+		if res.err == nil {
+			atomic.AddUint64(&mc.readCounter, 1)
+			readStats.record(time.Now())
+			attempt = 0
+			// The actual size of truncated packets isn't reported.
+			if res.n == len(res.buf) {
+				atomic.AddUint64(&mc.truncCounter, 1)
+			}
+		} else {
+			rerr := newReadError(res.err)
+			kind = rerr.kind
+			res.err = rerr
+		}
+
+		// Temporary errors are not surfaced since callers (i.e. the ICE UDP
+		// mux) would stop reading altogether.
+		if res.err != nil && kind == readErrorTemporary {
+			mc.bufPool.Put(res.buf)
+			atomic.AddUint64(&mc.tempErrCounter, 1)
+			timer := time.NewTimer(readRetryDelay(attempt))
+			attempt++
+			select {
+			case <-timer.C:
+				continue
+			case <-mc.closeCh:
+				timer.Stop()
+				return
+			case <-stopCh:
+				timer.Stop()
+				return
+			}
+		}
+
+		// Fatal errors aren't surfaced either as they only concern this conn
+		// while callers would stop reading from all of them. The reader
+		// stops, leaving the conn to be rebound. ReadFrom only fails once the
+		// multiConn is closed.
+		if res.err != nil && kind == readErrorFatal {
+			mc.bufPool.Put(res.buf)
+			atomic.StoreInt32(&readStats.stopped, 1)
+			return
+		}
+
+		if !mc.deliver(res, stopCh) {
 			return
 		}
-		if os.IsTimeout(res.err) {
+	}
+}
+
+// deliver hands the given read result over to the reading side, returning
+// false if the reader got stopped in the meantime.
+func (mc *multiConn) deliver(res readResult, stopCh chan struct{}) bool {
+	if len(mc.shardChs) == 0 {
+		return mc.sendResult(mc.readResultCh, res, stopCh)
+	}
+
+	return mc.sendResult(mc.shardChs[mc.shardRouter.shard(res.buf[:res.n], res.addr)], res, stopCh)
+}
+
+func (mc *multiConn) sendResult(ch chan readResult, res readResult, stopCh chan struct{}) bool {
+	select {
+	case ch <- res:
+		return true
+	case <-mc.closeCh:
+		return false
+	case <-stopCh:
+		if res.buf != nil {
+			mc.bufPool.Put(res.buf)
+		}
+		return false
+	}
+}
+
+// addConn adds a new connection to the set, spawning a dedicated reader
+// for it. The connection is expected to be bound to the same local address.
+func (mc *multiConn) addConn(conn net.PacketConn) error {
+	if conn == nil {
+		return errors.New("invalid nil conn")
+	}
+
+	mc.mut.Lock()
+	defer mc.mut.Unlock()
+
+	select {
+	case <-mc.closeCh:
+		return errors.New("multiconn is closed")
+	default:
+	}
+
+	mc.startReader(conn)
+
+	return nil
+}
+
+// removeConn closes and removes the most recently added connection from the
+// set. At least one connection is always kept.
+func (mc *multiConn) removeConn() error {
+	mc.mut.Lock()
+	defer mc.mut.Unlock()
+
+	if len(mc.conns) <= 1 {
+		return errors.New("cannot remove last conn")
+	}
+
+	idx := len(mc.conns) - 1
+	conn := mc.conns[idx]
+	close(mc.stopChs[idx])
+	mc.conns = mc.conns[:idx]
+	mc.pconns = mc.pconns[:idx]
+	mc.writeStats = mc.writeStats[:idx]
+	mc.readStats = mc.readStats[:idx]
+	mc.stopChs = mc.stopChs[:idx]
+
+	return conn.Close()
+}
+
+// unhealthyConns returns, keyed by index, the conns to rebind along with the
+// reason: those whose reader stopped, and those which received packets at
+// some point but not for the given timeout while others did.
+func (mc *multiConn) unhealthyConns(now time.Time, timeout time.Duration) map[int]string {
+	mc.mut.RLock()
+	defer mc.mut.RUnlock()
+
+	var lastReadAt int64
+	for _, st := range mc.readStats {
+		if t := atomic.LoadInt64(&st.lastReadAt); t > lastReadAt {
+			lastReadAt = t
+		}
+	}
+
+	unhealthy := map[int]string{}
+	for i, st := range mc.readStats {
+		if atomic.LoadInt32(&st.stopped) == 1 {
+			unhealthy[i] = connRebindStopped
 			continue
-		} else if res.err != nil {
-			break
 		}
+		idleSince := atomic.LoadInt64(&st.lastReadAt)
+		if atomic.LoadUint64(&st.reads) > 0 && now.UnixNano()-idleSince > int64(timeout) &&
+			lastReadAt-idleSince > int64(timeout) {
+			unhealthy[i] = connRebindStalled
+		}
+	}
+	return unhealthy
+}
+
+// rebindConn replaces the conn at the given index with the given one, which
+// is expected to be bound to the same local address, and closes the former.
+// As both are bound at the same time, it requires SO_REUSEPORT.
+func (mc *multiConn) rebindConn(idx int, conn net.PacketConn) error {
+	if conn == nil {
+		return errors.New("invalid nil conn")
+	}
+
+	mc.mut.Lock()
+	defer mc.mut.Unlock()
+
+	select {
+	case <-mc.closeCh:
+		return errors.New("multiconn is closed")
+	default:
+	}
+
+	if idx < 0 || idx >= len(mc.conns) {
+		return fmt.Errorf("invalid conn index %d", idx)
+	}
+
+	oldConn := mc.conns[idx]
+	close(mc.stopChs[idx])
+	mc.runReader(idx, conn)
+	atomic.AddUint64(&mc.rebindCounter, 1)
+
+	// Closing fails if the former conn is already broken, which is fine.
+	_ = oldConn.Close()
+
+	return nil
+}
+
+// numConns returns the number of connections currently in the set.
+func (mc *multiConn) numConns() int {
+	mc.mut.RLock()
+	defer mc.mut.RUnlock()
+	return len(mc.conns)
+}
+
+// readCount returns the total number of packets successfully read so far.
+func (mc *multiConn) readCount() uint64 {
+	return atomic.LoadUint64(&mc.readCounter)
+}
+
+// tempErrorCount returns the total number of temporary read errors readers
+// recovered from so far.
+func (mc *multiConn) tempErrorCount() uint64 {
+	return atomic.LoadUint64(&mc.tempErrCounter)
+}
+
+// truncatedCount returns the total number of packets likely truncated so
+// far because larger than the read buffers.
+func (mc *multiConn) truncatedCount() uint64 {
+	return atomic.LoadUint64(&mc.truncCounter)
+}
+
+// rebindCount returns the total number of connections rebound so far.
+func (mc *multiConn) rebindCount() uint64 {
+	return atomic.LoadUint64(&mc.rebindCounter)
+}
+
+// connWriteStats returns the write stats of each connection.
+func (mc *multiConn) connWriteStats() []UDPConnWriteStats {
+	mc.mut.RLock()
+	defer mc.mut.RUnlock()
+	stats := make([]UDPConnWriteStats, 0, len(mc.writeStats))
+	for _, s := range mc.writeStats {
+		stats = append(stats, UDPConnWriteStats{
+			Writes:  atomic.LoadUint64(&s.writes),
+			Errors:  atomic.LoadUint64(&s.errors),
+			Latency: time.Duration(atomic.LoadInt64(&s.latency)),
+		})
 	}
+	return stats
 }
 
+// ReadFrom returns the next packet read from any of the connections. Errors
+// are of type *readError: timeouts, or net.ErrClosed once the multiConn is
+// closed. It blocks until the deadline if reads are sharded.
 func (mc *multiConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
-	res := <-mc.readResultCh
-	copy(p, res.buf[:res.n])
-	mc.bufPool.Put(res.buf)
-	return res.n, res.addr, res.err
+	return mc.readFrom(mc.readResultCh, p)
+}
+
+func (mc *multiConn) readFrom(ch chan readResult, p []byte) (n int, addr net.Addr, err error) {
+	for {
+		select {
+		case <-mc.closeCh:
+			return 0, nil, &readError{kind: readErrorFatal, err: net.ErrClosed}
+		default:
+		}
+
+		mc.deadlineMut.Lock()
+		deadline := mc.readDeadline
+		deadlineCh := mc.readDeadlineCh
+		mc.deadlineMut.Unlock()
+
+		var timer *time.Timer
+		var timeoutCh <-chan time.Time
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, nil, &readError{kind: readErrorTimeout, err: os.ErrDeadlineExceeded}
+			}
+			timer = time.NewTimer(d)
+			timeoutCh = timer.C
+		}
+
+		select {
+		case res, ok := <-ch:
+			if timer != nil {
+				timer.Stop()
+			}
+			if !ok {
+				return 0, nil, &readError{kind: readErrorFatal, err: net.ErrClosed}
+			}
+			if res.buf != nil {
+				copy(p, res.buf[:res.n])
+				mc.bufPool.Put(res.buf)
+			}
+			return res.n, res.addr, res.err
+		case <-timeoutCh:
+			return 0, nil, &readError{kind: readErrorTimeout, err: os.ErrDeadlineExceeded}
+		case <-deadlineCh:
+			// The deadline changed, wait again with the new one.
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+	}
 }
 
 func (mc *multiConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
-	// Simple round-robin to equally distribute the writes among the connections.
-	idx := (atomic.AddUint64(&mc.counter, 1) - 1) % uint64(len(mc.conns))
+	mc.mut.RLock()
+	defer mc.mut.RUnlock()
+	start := time.Now()
+	idx := mc.pickConn(addr, start)
+	n, err = mc.writeToConn(idx, p, addr)
+	now := time.Now()
+	mc.writeStats[idx].record(now.Sub(start), err, now)
+	return n, err
+}
+
+// pickConn returns the index of the connection to write to addr through,
+// according to the write mode. Must be called with mc.mut held.
+func (mc *multiConn) pickConn(addr net.Addr, now time.Time) int {
+	numConns := uint64(len(mc.conns))
+	switch mc.writeMode {
+	case UDPWriteModePinned:
+		// Scaling the number of connections remaps the destinations, which
+		// is fine as long as it happens rarely.
+		return int(uint64(hashAddr(addr)) % numConns)
+	case UDPWriteModeAdaptive:
+		// The round-robin pick is compared to the next conn, which takes
+		// over if notably healthier. Writes to a degraded conn shift to
+		// its neighbour, at most doubling its share.
+		idx := (atomic.AddUint64(&mc.counter, 1) - 1) % numConns
+		if next := (idx + 1) % numConns; mc.writeStats[next].healthier(mc.writeStats[idx], now) {
+			return int(next)
+		}
+		return int(idx)
+	default:
+		// Simple round-robin to equally distribute the writes among the connections.
+		return int((atomic.AddUint64(&mc.counter, 1) - 1) % numConns)
+	}
+}
+
+// writeToConn writes p to addr through the connection at the given index.
+// Must be called with mc.mut held.
+func (mc *multiConn) writeToConn(idx int, p []byte, addr net.Addr) (int, error) {
+	if pconn := mc.pconns[idx]; pconn != nil {
+		src := mc.srcIPs.lookup(addr)
+		if src == nil {
+			return pconn.WriteTo(p, nil, addr)
+		}
+		n, err := writeFromSourceIP(mc.conns[idx], pconn, p, src.ip, addr)
+		if err != nil && isSourceIPError(err) {
+			// The source IP was rejected (e.g. the local address went away),
+			// leave its selection to the kernel.
+			mc.srcIPs.forget(addr)
+			return pconn.WriteTo(p, nil, addr)
+		}
+		src.count(n)
+		return n, err
+	}
 	return mc.conns[idx].WriteTo(p, addr)
 }
 
+// isSourceIPError returns whether a write may have failed because of the
+// source IP set through the control message.
+func isSourceIPError(err error) bool {
+	return errors.Is(err, syscall.EINVAL) ||
+		errors.Is(err, syscall.EADDRNOTAVAIL) ||
+		errors.Is(err, syscall.ENETUNREACH)
+}
+
 func (mc *multiConn) Close() error {
 	var err error
+	mc.mut.Lock()
 	close(mc.closeCh)
 	for _, conn := range mc.conns {
 		err = conn.Close()
 	}
+	mc.mut.Unlock()
 	mc.wg.Wait()
 	close(mc.readResultCh)
+	for _, ch := range mc.shardChs {
+		close(ch)
+	}
 	return err
 }
 
+// shards returns the views reading each shard of the received packets, as
+// routed by shardRouter. The packets of a given session, or else of a given
+// remote address, are always read from the same shard. It returns nil if
+// reads are not sharded.
+func (mc *multiConn) shards() []net.PacketConn {
+	var shards []net.PacketConn
+	for _, ch := range mc.shardChs {
+		shards = append(shards, &multiConnShard{multiConn: mc, ch: ch})
+	}
+	return shards
+}
+
+// multiConnShard is the view of a multiConn reading a single shard of the
+// received packets. Writes go through the multiConn.
+type multiConnShard struct {
+	*multiConn
+	ch chan readResult
+}
+
+func (s *multiConnShard) ReadFrom(p []byte) (int, net.Addr, error) {
+	return s.readFrom(s.ch, p)
+}
+
+// Close is a no-op, the multiConn being closed by its owner.
+func (s *multiConnShard) Close() error {
+	return nil
+}
+
 func (mc *multiConn) LocalAddr() net.Addr {
 	return mc.addr
 }
 
 func (mc *multiConn) SetDeadline(t time.Time) error {
-	var err error
-	for _, conn := range mc.conns {
-		err = conn.SetDeadline(t)
+	if err := mc.SetReadDeadline(t); err != nil {
+		return err
 	}
-	return err
+	return mc.SetWriteDeadline(t)
 }
 
 func (mc *multiConn) SetReadDeadline(t time.Time) error {
-	var err error
-	for _, conn := range mc.conns {
-		err = conn.SetReadDeadline(t)
-	}
-	return err
+	mc.deadlineMut.Lock()
+	defer mc.deadlineMut.Unlock()
+	mc.readDeadline = t
+	close(mc.readDeadlineCh)
+	mc.readDeadlineCh = make(chan struct{})
+	return nil
 }
 
 func (mc *multiConn) SetWriteDeadline(t time.Time) error {
+	mc.mut.RLock()
+	defer mc.mut.RUnlock()
 	var err error
 	for _, conn := range mc.conns {
 		err = conn.SetWriteDeadline(t)
 	}
 	return err
 }
+
+// hashAddr returns the FNV-1a hash of the given address.
+func hashAddr(addr net.Addr) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	h := uint32(offset32)
+	if udpAddr, ok := addr.(*net.UDPAddr); ok {
+		// Avoids the allocations of formatting the address.
+		for _, b := range udpAddr.IP.To16() {
+			h ^= uint32(b)
+			h *= prime32
+		}
+		h ^= uint32(udpAddr.Port & 0xff)
+		h *= prime32
+		h ^= uint32(udpAddr.Port >> 8)
+		h *= prime32
+		return h
+	}
+
+	if addr != nil {
+		for _, b := range []byte(addr.String()) {
+			h ^= uint32(b)
+			h *= prime32
+		}
+	}
+	return h
+}
@@ -5,18 +5,24 @@ package rtc
 
 import (
 	"context"
+	"errors"
 	"net"
+	"os"
+	"sync/atomic"
 	"syscall"
 	"testing"
+	"time"
 
-	"golang.org/x/sys/unix"
+	"github.com/mattermost/rtcd/service/crash"
 
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/ice/v2"
 	"github.com/stretchr/testify/require"
 )
 
 func TestNewMultiConn(t *testing.T) {
 	t.Run("error - nil conns", func(t *testing.T) {
-		mc, err := newMultiConn(nil)
+		mc, err := newMultiConn(nil, multiConnConfig{writeMode: UDPWriteModeRoundRobin}, nil)
 		require.Error(t, err)
 		require.Equal(t, "conns should not be empty", err.Error())
 		require.Nil(t, mc)
@@ -24,14 +30,14 @@ func TestNewMultiConn(t *testing.T) {
 	})
 
 	t.Run("error - empty conns", func(t *testing.T) {
-		mc, err := newMultiConn([]net.PacketConn{})
+		mc, err := newMultiConn([]net.PacketConn{}, multiConnConfig{writeMode: UDPWriteModeRoundRobin}, nil)
 		require.Error(t, err)
 		require.Equal(t, "conns should not be empty", err.Error())
 		require.Nil(t, mc)
 	})
 
 	t.Run("error - nil conn", func(t *testing.T) {
-		mc, err := newMultiConn([]net.PacketConn{nil})
+		mc, err := newMultiConn([]net.PacketConn{nil}, multiConnConfig{writeMode: UDPWriteModeRoundRobin}, nil)
 		require.Error(t, err)
 		require.Equal(t, "invalid nil conn", err.Error())
 		require.Nil(t, mc)
@@ -42,7 +48,7 @@ func TestNewMultiConn(t *testing.T) {
 		conn1, err := listenConfig.ListenPacket(context.Background(), "udp4", ":0")
 		require.NoError(t, err)
 		require.NotNil(t, conn1)
-		mc, err := newMultiConn([]net.PacketConn{conn1})
+		mc, err := newMultiConn([]net.PacketConn{conn1}, multiConnConfig{writeMode: UDPWriteModeRoundRobin}, nil)
 		require.NoError(t, err)
 		require.NotNil(t, mc)
 		err = mc.Close()
@@ -54,12 +60,14 @@ func TestNewMultiConn(t *testing.T) {
 }
 
 func TestMultiConnReadWrite(t *testing.T) {
+	if !reusePortSupported {
+		t.Skip("sockets can't share an address on this platform")
+	}
+
 	listenConfig := net.ListenConfig{
 		Control: func(network, address string, c syscall.RawConn) error {
 			return c.Control(func(fd uintptr) {
-				err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1)
-				require.NoError(t, err)
-				err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+				err := setReusePort(fd)
 				require.NoError(t, err)
 			})
 		},
@@ -73,7 +81,7 @@ func TestMultiConnReadWrite(t *testing.T) {
 	require.NotNil(t, conn2)
 	require.Equal(t, conn1.LocalAddr(), conn2.LocalAddr())
 
-	mc, err := newMultiConn([]net.PacketConn{conn1, conn2})
+	mc, err := newMultiConn([]net.PacketConn{conn1, conn2}, multiConnConfig{writeMode: UDPWriteModeRoundRobin}, nil)
 	require.NoError(t, err)
 	require.NotNil(t, mc)
 	defer mc.Close()
@@ -101,3 +109,661 @@ func TestMultiConnReadWrite(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, uint64(2), mc.counter)
 }
+
+func TestMultiConnSourceIP(t *testing.T) {
+	if !sourceIPSupported {
+		t.Skip("source IP can't be set on this platform")
+	}
+
+	conn, err := net.ListenPacket("udp4", ":0")
+	require.NoError(t, err)
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	mc, err := newMultiConn([]net.PacketConn{conn}, multiConnConfig{writeMode: UDPWriteModeRoundRobin}, nil)
+	require.NoError(t, err)
+	defer mc.Close()
+	require.NotNil(t, mc.pconns[0])
+
+	client, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer client.Close()
+
+	readFrom := func() net.Addr {
+		t.Helper()
+		err := client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		require.NoError(t, err)
+		buf := make([]byte, receiveMTU)
+		_, addr, err := client.ReadFrom(buf)
+		require.NoError(t, err)
+		return addr
+	}
+
+	t.Run("route", func(t *testing.T) {
+		// No packet was received from the client yet.
+		_, err := mc.WriteTo([]byte("data"), client.LocalAddr())
+		require.NoError(t, err)
+		require.Equal(t, "127.0.0.1", readFrom().(*net.UDPAddr).IP.String())
+	})
+
+	t.Run("learned", func(t *testing.T) {
+		// Any 127.0.0.0/8 address is local, the reply should come from the
+		// one the client sent to rather than the one picked by routing.
+		_, err := client.WriteTo([]byte("data"), &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: port})
+		require.NoError(t, err)
+		buf := make([]byte, receiveMTU)
+		_, addr, err := mc.ReadFrom(buf)
+		require.NoError(t, err)
+
+		_, err = mc.WriteTo([]byte("data"), addr)
+		require.NoError(t, err)
+		require.Equal(t, "127.0.0.2", readFrom().(*net.UDPAddr).IP.String())
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		// The address is not local, the write should fall back to the
+		// kernel selection.
+		mc.srcIPs.set(client.LocalAddr(), sourceIP{ip: net.ParseIP("192.0.2.1"), learned: true})
+		_, err := mc.WriteTo([]byte("data"), client.LocalAddr())
+		require.NoError(t, err)
+		require.Equal(t, "127.0.0.1", readFrom().(*net.UDPAddr).IP.String())
+		require.Equal(t, "127.0.0.1", mc.srcIPs.get(client.LocalAddr()).String())
+	})
+
+	t.Run("bound address", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Nil(t, newSourceIPConn(conn))
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		sourceIPSupported = false
+		defer func() {
+			sourceIPSupported = true
+		}()
+		conn, err := net.ListenPacket("udp4", ":0")
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Nil(t, newSourceIPConn(conn))
+	})
+}
+
+func TestMultiConnAddRemove(t *testing.T) {
+	if !reusePortSupported {
+		t.Skip("sockets can't share an address on this platform")
+	}
+
+	listenConfig := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			return c.Control(func(fd uintptr) {
+				err := setReusePort(fd)
+				require.NoError(t, err)
+			})
+		},
+	}
+
+	conn1, err := listenConfig.ListenPacket(context.Background(), "udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.NotNil(t, conn1)
+
+	mc, err := newMultiConn([]net.PacketConn{conn1}, multiConnConfig{writeMode: UDPWriteModeRoundRobin}, nil)
+	require.NoError(t, err)
+	require.NotNil(t, mc)
+	defer mc.Close()
+
+	t.Run("cannot remove last conn", func(t *testing.T) {
+		err := mc.removeConn()
+		require.Error(t, err)
+		require.Equal(t, "cannot remove last conn", err.Error())
+		require.Equal(t, 1, mc.numConns())
+	})
+
+	t.Run("nil conn", func(t *testing.T) {
+		err := mc.addConn(nil)
+		require.Error(t, err)
+		require.Equal(t, "invalid nil conn", err.Error())
+	})
+
+	t.Run("add and remove", func(t *testing.T) {
+		conn2, err := listenConfig.ListenPacket(context.Background(), "udp4", conn1.LocalAddr().String())
+		require.NoError(t, err)
+		require.NotNil(t, conn2)
+
+		err = mc.addConn(conn2)
+		require.NoError(t, err)
+		require.Equal(t, 2, mc.numConns())
+
+		err = mc.removeConn()
+		require.NoError(t, err)
+		require.Equal(t, 1, mc.numConns())
+
+		// removed conn should be closed.
+		err = conn2.Close()
+		require.Error(t, err)
+
+		// reading should still work and not surface the close error.
+		data := []byte("data")
+		_, err = conn1.WriteTo(data, mc.LocalAddr())
+		require.NoError(t, err)
+		receivedData := make([]byte, receiveMTU)
+		read, _, err := mc.ReadFrom(receivedData)
+		require.NoError(t, err)
+		require.Equal(t, data, receivedData[:read])
+		require.Equal(t, uint64(1), mc.readCount())
+	})
+}
+
+type countingConn struct {
+	net.PacketConn
+	writes int
+	err    error
+}
+
+func (c *countingConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.writes++
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+func TestMultiConnPinnedWrites(t *testing.T) {
+	var conns []net.PacketConn
+	var counters []*countingConn
+	for i := 0; i < 4; i++ {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		cc := &countingConn{PacketConn: conn}
+		conns = append(conns, cc)
+		counters = append(counters, cc)
+	}
+
+	mc, err := newMultiConn(conns, multiConnConfig{writeMode: UDPWriteModePinned}, nil)
+	require.NoError(t, err)
+	defer mc.Close()
+
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 40000}
+	for i := 0; i < 10; i++ {
+		_, err := mc.WriteTo([]byte("data"), addr)
+		require.NoError(t, err)
+	}
+
+	var used int
+	for _, cc := range counters {
+		if cc.writes > 0 {
+			require.Equal(t, 10, cc.writes)
+			used++
+		}
+	}
+	require.Equal(t, 1, used)
+	require.Zero(t, mc.counter)
+
+	t.Run("hash", func(t *testing.T) {
+		require.Equal(t, hashAddr(addr), hashAddr(&net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 40000}))
+		require.NotEqual(t, hashAddr(addr), hashAddr(&net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 40001}))
+		require.NotEqual(t, hashAddr(addr), hashAddr(&net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: 40000}))
+	})
+}
+
+type fakeReadResult struct {
+	data  []byte
+	err   error
+	panic bool
+}
+
+type fakeReadConn struct {
+	net.PacketConn
+	readCh chan fakeReadResult
+}
+
+func (c *fakeReadConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	res, ok := <-c.readCh
+	if !ok {
+		return 0, nil, net.ErrClosed
+	}
+	if res.panic {
+		panic("read panic")
+	}
+	return copy(p, res.data), c.LocalAddr(), res.err
+}
+
+func (c *fakeReadConn) Close() error {
+	return nil
+}
+
+func TestMultiConnReadErrors(t *testing.T) {
+	t.Run("classify", func(t *testing.T) {
+		require.Equal(t, readErrorTimeout, classifyReadError(os.ErrDeadlineExceeded))
+		require.Equal(t, readErrorFatal, classifyReadError(net.ErrClosed))
+		require.Equal(t, readErrorFatal, classifyReadError(errors.New("unknown")))
+		require.Equal(t, readErrorTemporary, classifyReadError(&net.OpError{
+			Op:  "read",
+			Err: os.NewSyscallError("recvfrom", syscall.ECONNREFUSED),
+		}))
+
+		var netErr net.Error
+		err := error(newReadError(os.ErrDeadlineExceeded))
+		require.True(t, os.IsTimeout(err))
+		require.True(t, errors.As(err, &netErr))
+		require.True(t, netErr.Timeout())
+
+		err = newReadError(syscall.ENOBUFS)
+		require.False(t, os.IsTimeout(err))
+		require.True(t, errors.Is(err, syscall.ENOBUFS))
+	})
+
+	t.Run("retry delay", func(t *testing.T) {
+		for attempt := 0; attempt < 20; attempt++ {
+			delay := readRetryDelay(attempt)
+			require.GreaterOrEqual(t, delay, readRetryMinDelay/2)
+			require.LessOrEqual(t, delay, readRetryMaxDelay)
+		}
+	})
+
+	t.Run("temporary", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer conn.Close()
+		fc := &fakeReadConn{PacketConn: conn, readCh: make(chan fakeReadResult, 2)}
+
+		mc, err := newMultiConn([]net.PacketConn{fc}, multiConnConfig{writeMode: UDPWriteModeRoundRobin}, nil)
+		require.NoError(t, err)
+
+		fc.readCh <- fakeReadResult{err: syscall.ECONNREFUSED}
+		fc.readCh <- fakeReadResult{data: []byte("data")}
+
+		buf := make([]byte, receiveMTU)
+		n, _, err := mc.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, "data", string(buf[:n]))
+		require.Equal(t, uint64(1), mc.tempErrorCount())
+
+		// Fatal errors of a conn are not surfaced, only the closing of the
+		// multiConn is.
+		close(fc.readCh)
+		require.Eventually(t, func() bool {
+			return atomic.LoadInt32(&mc.readStats[0].stopped) == 1
+		}, time.Second, 10*time.Millisecond)
+		require.NoError(t, mc.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+		_, _, err = mc.ReadFrom(buf)
+		require.True(t, os.IsTimeout(err))
+
+		err = mc.Close()
+		require.NoError(t, err)
+		_, _, err = mc.ReadFrom(buf)
+		require.ErrorIs(t, err, net.ErrClosed)
+		var rerr *readError
+		require.True(t, errors.As(err, &rerr))
+		require.Equal(t, readErrorFatal, rerr.kind)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer conn.Close()
+		fc := &fakeReadConn{PacketConn: conn, readCh: make(chan fakeReadResult, 2)}
+
+		log, err := mlog.NewLogger()
+		require.NoError(t, err)
+		defer func() {
+			err := log.Shutdown()
+			require.NoError(t, err)
+		}()
+		reporter, err := crash.NewReporter(crash.Config{}, log, nil, nil)
+		require.NoError(t, err)
+		mc, err := newMultiConn([]net.PacketConn{fc}, multiConnConfig{writeMode: UDPWriteModeRoundRobin}, reporter)
+		require.NoError(t, err)
+
+		// The reader is restarted.
+		fc.readCh <- fakeReadResult{panic: true}
+		fc.readCh <- fakeReadResult{data: []byte("data")}
+
+		buf := make([]byte, receiveMTU)
+		n, _, err := mc.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, "data", string(buf[:n]))
+
+		close(fc.readCh)
+		err = mc.Close()
+		require.NoError(t, err)
+	})
+}
+
+func TestMultiConnReceiveMTU(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	fc := &fakeReadConn{PacketConn: conn, readCh: make(chan fakeReadResult, 1)}
+
+	mc, err := newMultiConn([]net.PacketConn{fc}, multiConnConfig{receiveMTU: minReceiveMTU}, nil)
+	require.NoError(t, err)
+
+	fc.readCh <- fakeReadResult{data: make([]byte, minReceiveMTU-1)}
+	buf := make([]byte, receiveMTU)
+	n, _, err := mc.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, minReceiveMTU-1, n)
+	require.Zero(t, mc.truncatedCount())
+
+	fc.readCh <- fakeReadResult{data: make([]byte, minReceiveMTU+1)}
+	n, _, err = mc.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, minReceiveMTU, n)
+	require.Equal(t, uint64(1), mc.truncatedCount())
+
+	close(fc.readCh)
+	err = mc.Close()
+	require.NoError(t, err)
+}
+
+func TestMultiConnRebind(t *testing.T) {
+	newFakeConn := func() *fakeReadConn {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return &fakeReadConn{PacketConn: conn, readCh: make(chan fakeReadResult, 1)}
+	}
+	fc1, fc2 := newFakeConn(), newFakeConn()
+
+	mc, err := newMultiConn([]net.PacketConn{fc1, fc2}, multiConnConfig{writeMode: UDPWriteModePinned}, nil)
+	require.NoError(t, err)
+
+	buf := make([]byte, receiveMTU)
+	timeout := time.Minute
+	now := time.Now()
+
+	t.Run("healthy", func(t *testing.T) {
+		// Conns which never received packets are left alone.
+		require.Empty(t, mc.unhealthyConns(now.Add(2*timeout), timeout))
+
+		fc1.readCh <- fakeReadResult{data: []byte("data")}
+		_, _, err := mc.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Empty(t, mc.unhealthyConns(now, timeout))
+		// Nor is a conn idle along with all the others.
+		require.Empty(t, mc.unhealthyConns(now.Add(2*timeout), timeout))
+	})
+
+	t.Run("stalled", func(t *testing.T) {
+		atomic.StoreInt64(&mc.readStats[0].lastReadAt, now.Add(-2*timeout).UnixNano())
+		fc2.readCh <- fakeReadResult{data: []byte("data")}
+		_, _, err := mc.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, map[int]string{0: connRebindStalled}, mc.unhealthyConns(time.Now(), timeout))
+
+		fc3 := newFakeConn()
+		err = mc.rebindConn(0, fc3)
+		require.NoError(t, err)
+		close(fc1.readCh)
+		require.Equal(t, uint64(1), mc.rebindCount())
+		require.Equal(t, []net.PacketConn{fc3, fc2}, mc.conns)
+		require.Empty(t, mc.unhealthyConns(time.Now(), timeout))
+
+		fc3.readCh <- fakeReadResult{data: []byte("rebound")}
+		n, _, err := mc.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, "rebound", string(buf[:n]))
+
+		err = mc.rebindConn(2, newFakeConn())
+		require.Error(t, err)
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		close(mc.conns[1].(*fakeReadConn).readCh)
+		require.Eventually(t, func() bool {
+			return mc.unhealthyConns(time.Now(), timeout)[1] == connRebindStopped
+		}, time.Second, 10*time.Millisecond)
+
+		fc4 := newFakeConn()
+		err = mc.rebindConn(1, fc4)
+		require.NoError(t, err)
+		require.Empty(t, mc.unhealthyConns(time.Now(), timeout))
+
+		fc4.readCh <- fakeReadResult{data: []byte("rebound")}
+		n, _, err := mc.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, "rebound", string(buf[:n]))
+	})
+
+	close(mc.conns[0].(*fakeReadConn).readCh)
+	close(mc.conns[1].(*fakeReadConn).readCh)
+	err = mc.Close()
+	require.NoError(t, err)
+}
+
+func TestMultiConnFatalErrorUDPMux(t *testing.T) {
+	newFakeConn := func() *fakeReadConn {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return &fakeReadConn{PacketConn: conn, readCh: make(chan fakeReadResult)}
+	}
+	fc1, fc2 := newFakeConn(), newFakeConn()
+
+	mc, err := newMultiConn([]net.PacketConn{fc1, fc2}, multiConnConfig{writeMode: UDPWriteModeRoundRobin}, nil)
+	require.NoError(t, err)
+	mux := ice.NewUDPMuxDefault(ice.UDPMuxParams{UDPConn: mc})
+
+	// One conn failing leaves the mux reading from the others.
+	close(fc1.readCh)
+	require.Eventually(t, func() bool {
+		return mc.unhealthyConns(time.Now(), time.Minute)[0] == connRebindStopped
+	}, time.Second, 10*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		select {
+		case fc2.readCh <- fakeReadResult{data: []byte("data")}:
+		case <-time.After(time.Second):
+			require.FailNow(t, "timed out sending packet")
+		}
+	}
+	require.Eventually(t, func() bool {
+		return mc.readCount() == 3
+	}, time.Second, 10*time.Millisecond)
+	require.False(t, mux.IsClosed())
+
+	// The failed conn can be replaced.
+	fc3 := newFakeConn()
+	require.NoError(t, mc.rebindConn(0, fc3))
+	select {
+	case fc3.readCh <- fakeReadResult{data: []byte("data")}:
+	case <-time.After(time.Second):
+		require.FailNow(t, "timed out sending packet")
+	}
+	require.False(t, mux.IsClosed())
+
+	require.NoError(t, mux.Close())
+	close(fc2.readCh)
+	close(fc3.readCh)
+	require.NoError(t, mc.Close())
+}
+
+func TestMultiConnReadDeadline(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	mc, err := newMultiConn([]net.PacketConn{conn}, multiConnConfig{writeMode: UDPWriteModeRoundRobin}, nil)
+	require.NoError(t, err)
+	defer mc.Close()
+
+	buf := make([]byte, receiveMTU)
+
+	t.Run("expired", func(t *testing.T) {
+		err := mc.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+		require.NoError(t, err)
+		_, _, err = mc.ReadFrom(buf)
+		require.True(t, os.IsTimeout(err))
+
+		// Still expired.
+		_, _, err = mc.ReadFrom(buf)
+		require.True(t, os.IsTimeout(err))
+	})
+
+	t.Run("changed while reading", func(t *testing.T) {
+		err := mc.SetReadDeadline(time.Now().Add(time.Hour))
+		require.NoError(t, err)
+
+		errCh := make(chan error, 1)
+		go func() {
+			_, _, err := mc.ReadFrom(buf)
+			errCh <- err
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		err = mc.SetReadDeadline(time.Now())
+		require.NoError(t, err)
+
+		select {
+		case err := <-errCh:
+			require.True(t, os.IsTimeout(err))
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for read")
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		err := mc.SetReadDeadline(time.Time{})
+		require.NoError(t, err)
+
+		// The readers survived the timeouts.
+		_, err = conn.WriteTo([]byte("data"), conn.LocalAddr())
+		require.NoError(t, err)
+		n, _, err := mc.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, "data", string(buf[:n]))
+	})
+}
+
+func TestMultiConnAdaptiveWrites(t *testing.T) {
+	newConns := func(t *testing.T) ([]net.PacketConn, []*countingConn) {
+		var conns []net.PacketConn
+		var counters []*countingConn
+		for i := 0; i < 2; i++ {
+			conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+			require.NoError(t, err)
+			cc := &countingConn{PacketConn: conn}
+			conns = append(conns, cc)
+			counters = append(counters, cc)
+		}
+		return conns, counters
+	}
+
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 40000}
+
+	t.Run("failing conn", func(t *testing.T) {
+		conns, counters := newConns(t)
+		counters[1].err = syscall.ENOBUFS
+
+		mc, err := newMultiConn(conns, multiConnConfig{writeMode: UDPWriteModeAdaptive}, nil)
+		require.NoError(t, err)
+		defer mc.Close()
+
+		var failed int
+		for i := 0; i < 10; i++ {
+			if _, err := mc.WriteTo([]byte("data"), addr); err != nil {
+				failed++
+			}
+		}
+
+		// Only the first write to the failing conn went through it.
+		require.Equal(t, 1, failed)
+		require.Equal(t, 9, counters[0].writes)
+		require.Equal(t, 1, counters[1].writes)
+
+		stats := mc.connWriteStats()
+		require.Len(t, stats, 2)
+		require.Equal(t, uint64(9), stats[0].Writes)
+		require.Zero(t, stats[0].Errors)
+		require.Equal(t, uint64(1), stats[1].Writes)
+		require.Equal(t, uint64(1), stats[1].Errors)
+	})
+
+	t.Run("slow conn", func(t *testing.T) {
+		conns, counters := newConns(t)
+
+		mc, err := newMultiConn(conns, multiConnConfig{writeMode: UDPWriteModeAdaptive}, nil)
+		require.NoError(t, err)
+		defer mc.Close()
+
+		mc.writeStats[1].latency = int64(10 * time.Millisecond)
+		for i := 0; i < 10; i++ {
+			_, err := mc.WriteTo([]byte("data"), addr)
+			require.NoError(t, err)
+		}
+		require.Equal(t, 10, counters[0].writes)
+		require.Zero(t, counters[1].writes)
+	})
+
+	t.Run("round robin", func(t *testing.T) {
+		conns, counters := newConns(t)
+		counters[1].err = syscall.ENOBUFS
+
+		mc, err := newMultiConn(conns, multiConnConfig{writeMode: UDPWriteModeRoundRobin}, nil)
+		require.NoError(t, err)
+		defer mc.Close()
+
+		for i := 0; i < 10; i++ {
+			_, _ = mc.WriteTo([]byte("data"), addr)
+		}
+		require.Equal(t, 5, counters[0].writes)
+		require.Equal(t, 5, counters[1].writes)
+		require.Equal(t, uint64(5), mc.connWriteStats()[1].Errors)
+	})
+}
+
+func TestConnWriteStatsHealthier(t *testing.T) {
+	now := time.Now()
+	var a, b connWriteStats
+	require.False(t, a.healthier(&b, now))
+	require.False(t, b.healthier(&a, now))
+
+	b.record(time.Millisecond, nil, now)
+	require.True(t, a.healthier(&b, now))
+	require.False(t, b.healthier(&a, now))
+
+	a.record(time.Microsecond, errors.New("failed"), now)
+	require.True(t, b.healthier(&a, now))
+	require.False(t, a.healthier(&b, now))
+
+	// Failures are forgotten after a while.
+	require.True(t, a.healthier(&b, now.Add(writeErrorBackoff)))
+}
+
+func TestMultiConnShardedReads(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mc, err := newMultiConn([]net.PacketConn{conn}, multiConnConfig{writeMode: UDPWriteModeRoundRobin, readShards: 4}, nil)
+	require.NoError(t, err)
+	shards := mc.shards()
+	require.Len(t, shards, 4)
+
+	for i := 0; i < 8; i++ {
+		client, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer client.Close()
+
+		// The packets of a given address always land on the same shard.
+		shard := shards[hashAddr(client.LocalAddr())%4]
+		for j := 0; j < 2; j++ {
+			_, err = client.WriteTo([]byte("data"), mc.LocalAddr())
+			require.NoError(t, err)
+
+			err = shard.SetReadDeadline(time.Now().Add(5 * time.Second))
+			require.NoError(t, err)
+			buf := make([]byte, receiveMTU)
+			n, addr, err := shard.ReadFrom(buf)
+			require.NoError(t, err)
+			require.Equal(t, "data", string(buf[:n]))
+			require.Equal(t, client.LocalAddr().String(), addr.String())
+		}
+	}
+
+	// Closing a shard leaves the others untouched.
+	require.NoError(t, shards[0].Close())
+	require.Equal(t, uint64(16), mc.readCount())
+
+	require.NoError(t, mc.Close())
+	for _, shard := range shards {
+		_, _, err := shard.ReadFrom(make([]byte, receiveMTU))
+		require.ErrorIs(t, err, net.ErrClosed)
+	}
+}
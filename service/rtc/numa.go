@@ -0,0 +1,76 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// NUMANodeAuto makes the socket readers get pinned to the NUMA node owning
+// the NIC the UDP address is bound to.
+const NUMANodeAuto = "auto"
+
+// parseCPUList parses a list of CPUs in the format used by the kernel (e.g.
+// "0-3,8,10-11").
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+	list = strings.TrimSpace(list)
+	if list == "" {
+		return nil, nil
+	}
+	for _, part := range strings.Split(list, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid CPU list %q", list)
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil || last < first {
+				return nil, fmt.Errorf("invalid CPU list %q", list)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// getReaderCPUs returns the CPUs the socket readers should be pinned to, as
+// configured through UDPSockets.NUMANode. It returns nil if pinning is
+// disabled.
+func (s *Server) getReaderCPUs() ([]int, error) {
+	if s.cfg.UDPSockets.NUMANode == "" || s.vnet != nil {
+		return nil, nil
+	}
+
+	var node int
+	var err error
+	if s.cfg.UDPSockets.NUMANode == NUMANodeAuto {
+		node, err = getAddrNUMANode(s.cfg.ICEAddressUDP)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get NUMA node of %s: %w", s.cfg.ICEAddressUDP, err)
+		}
+	} else if node, err = strconv.Atoi(s.cfg.UDPSockets.NUMANode); err != nil {
+		return nil, fmt.Errorf("invalid NUMA node: %w", err)
+	}
+
+	cpus, err := getNUMANodeCPUs(node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CPUs of NUMA node %d: %w", node, err)
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("no usable CPU on NUMA node %d", node)
+	}
+
+	s.log.Info("rtc: pinning socket readers to NUMA node", mlog.Int("node", node), mlog.Any("cpus", cpus))
+
+	return cpus, nil
+}
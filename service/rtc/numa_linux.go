@@ -0,0 +1,99 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// sysfsPath is where sysfs is mounted.
+const sysfsPath = "/sys"
+
+// getAddrNUMANode returns the NUMA node owning the NIC the given IP address
+// is assigned to.
+func getAddrNUMANode(addr string) (int, error) {
+	if net.ParseIP(addr) == nil {
+		return 0, fmt.Errorf("invalid address %q", addr)
+	}
+
+	ifaces, err := getMediaInterfaces(addr)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get interfaces: %w", err)
+	}
+	if len(ifaces) == 0 {
+		return 0, errors.New("no interface has this address")
+	}
+
+	return getIfaceNUMANode(ifaces[0].Name)
+}
+
+// getIfaceNUMANode returns the NUMA node owning the device of the given
+// network interface.
+func getIfaceNUMANode(name string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(sysfsPath, "class/net", name, "device/numa_node"))
+	if err != nil {
+		// Virtual interfaces have no device.
+		return 0, fmt.Errorf("failed to read NUMA node of %s: %w", name, err)
+	}
+	node, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse NUMA node of %s: %w", name, err)
+	}
+	if node < 0 {
+		return 0, fmt.Errorf("NUMA node of %s is unknown", name)
+	}
+	return node, nil
+}
+
+// getNUMANodeCPUs returns the CPUs of the given NUMA node the process is
+// allowed to run on.
+func getNUMANodeCPUs(node int) ([]int, error) {
+	data, err := os.ReadFile(filepath.Join(sysfsPath, fmt.Sprintf("devices/system/node/node%d/cpulist", node)))
+	if err != nil {
+		return nil, err
+	}
+	cpus, err := parseCPUList(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// The process may be restricted to a subset of the CPUs (e.g. through
+	// cgroups).
+	var allowed unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &allowed); err != nil {
+		return nil, fmt.Errorf("failed to get CPU affinity: %w", err)
+	}
+	var usable []int
+	for _, cpu := range cpus {
+		if allowed.IsSet(cpu) {
+			usable = append(usable, cpu)
+		}
+	}
+	return usable, nil
+}
+
+// pinThread locks the calling goroutine to its OS thread and restricts the
+// thread to the given CPUs. The goroutine should exit without unlocking so
+// that the thread gets terminated rather than reused with the affinity set.
+func pinThread(cpus []int) error {
+	var set unix.CPUSet
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+	runtime.LockOSThread()
+	if err := unix.SchedSetaffinity(0, &set); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("failed to set CPU affinity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build !linux
+
+package rtc
+
+import (
+	"errors"
+)
+
+var errNUMAUnsupported = errors.New("NUMA pinning is only supported on Linux")
+
+func getAddrNUMANode(_ string) (int, error) {
+	return 0, errNUMAUnsupported
+}
+
+func getNUMANodeCPUs(_ int) ([]int, error) {
+	return nil, errNUMAUnsupported
+}
+
+func pinThread(_ []int) error {
+	return errNUMAUnsupported
+}
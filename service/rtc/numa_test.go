@@ -0,0 +1,49 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCPUList(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		cpus, err := parseCPUList("\n")
+		require.NoError(t, err)
+		require.Empty(t, cpus)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cpus, err := parseCPUList("0-3,8,10-11\n")
+		require.NoError(t, err)
+		require.Equal(t, []int{0, 1, 2, 3, 8, 10, 11}, cpus)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, list := range []string{"a", "0-", "3-1", "-1", "0,,1"} {
+			_, err := parseCPUList(list)
+			require.Error(t, err, list)
+		}
+	})
+}
+
+func TestGetReaderCPUs(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	t.Run("disabled", func(t *testing.T) {
+		cpus, err := server.getReaderCPUs()
+		require.NoError(t, err)
+		require.Nil(t, cpus)
+	})
+
+	t.Run("missing node", func(t *testing.T) {
+		server.cfg.UDPSockets.NUMANode = "4096"
+		defer func() { server.cfg.UDPSockets.NUMANode = "" }()
+		_, err := server.getReaderCPUs()
+		require.Error(t, err)
+	})
+}
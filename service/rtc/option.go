@@ -0,0 +1,27 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+
+	"github.com/pion/transport/vnet"
+)
+
+type ServerOption func(s *Server) error
+
+// WithVNet makes the server serve media over the given virtual network
+// rather than the UDP sockets of the host, so that calls can be tested in
+// process without binding ports. Media is served on the IPv4 address of the
+// network, which should be attached to a started router, on ICEPortUDP.
+// Public IP discovery and UDP sockets scaling don't apply.
+func WithVNet(n *vnet.Net) ServerOption {
+	return func(s *Server) error {
+		if n == nil || !n.IsVirtual() {
+			return fmt.Errorf("invalid vnet: should be virtual")
+		}
+		s.vnet = n
+		return nil
+	}
+}
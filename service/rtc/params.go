@@ -0,0 +1,94 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	maxNACKBufferSize = 32768
+	rembInterval      = time.Second
+)
+
+// RuntimeParams holds the tuning parameters that can be changed while the
+// server is running. Zero values mean defaults (or no limit).
+type RuntimeParams struct {
+	// MaxScreenBitrateKbps caps the bitrate of screen sharing tracks.
+	MaxScreenBitrateKbps int `json:"max_screen_bitrate_kbps"`
+	// NACKBufferSize is the number of packets kept to respond to NACKs. It
+	// must be a power of two and only applies to new sessions.
+	NACKBufferSize int `json:"nack_buffer_size"`
+	// PLIThrottleMs is the minimum interval between PLI requests forwarded
+	// to the presenter.
+	PLIThrottleMs int `json:"pli_throttle_ms"`
+}
+
+func (p RuntimeParams) IsValid() error {
+	if p.MaxScreenBitrateKbps < 0 {
+		return fmt.Errorf("invalid MaxScreenBitrateKbps value: should not be negative")
+	}
+	if p.NACKBufferSize < 0 || p.NACKBufferSize > maxNACKBufferSize || p.NACKBufferSize&(p.NACKBufferSize-1) != 0 {
+		return fmt.Errorf("invalid NACKBufferSize value: should be a power of two not greater than %d", maxNACKBufferSize)
+	}
+	if p.PLIThrottleMs < 0 {
+		return fmt.Errorf("invalid PLIThrottleMs value: should not be negative")
+	}
+	return nil
+}
+
+func (p RuntimeParams) getNACKBufferSize() uint16 {
+	if p.NACKBufferSize == 0 {
+		return nackResponderBufferSize
+	}
+	return uint16(p.NACKBufferSize)
+}
+
+// GetRuntimeParams returns the current runtime parameters.
+func (s *Server) GetRuntimeParams() RuntimeParams {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.params
+}
+
+// SetRuntimeParams validates and applies the given runtime parameters.
+func (s *Server) SetRuntimeParams(params RuntimeParams) error {
+	if err := params.IsValid(); err != nil {
+		return err
+	}
+	s.mut.Lock()
+	s.params = params
+	s.mut.Unlock()
+	return nil
+}
+
+// capScreenBitrate periodically sends REMB packets to the presenter to keep
+// the bitrate of the screen track under the configured limit.
+func (s *Server) capScreenBitrate(us *session, remoteTrack *webrtc.TrackRemote) {
+	ticker := time.NewTicker(rembInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			maxBitrate := s.GetRuntimeParams().MaxScreenBitrateKbps
+			if maxBitrate == 0 {
+				continue
+			}
+			if err := us.rtcConn.WriteRTCP([]rtcp.Packet{&rtcp.ReceiverEstimatedMaximumBitrate{
+				Bitrate: float32(maxBitrate * 1000),
+				SSRCs:   []uint32{uint32(remoteTrack.SSRC())},
+			}}); err != nil {
+				s.log.Debug("failed to write REMB packet", mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+			}
+		case <-us.closeCh:
+			return
+		}
+	}
+}
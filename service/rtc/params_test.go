@@ -0,0 +1,91 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeParamsIsValid(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		var params RuntimeParams
+		require.NoError(t, params.IsValid())
+		require.Equal(t, uint16(256), params.getNACKBufferSize())
+	})
+
+	t.Run("negative bitrate", func(t *testing.T) {
+		params := RuntimeParams{MaxScreenBitrateKbps: -1}
+		err := params.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxScreenBitrateKbps value: should not be negative", err.Error())
+	})
+
+	t.Run("invalid nack buffer size", func(t *testing.T) {
+		params := RuntimeParams{NACKBufferSize: 100}
+		err := params.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid NACKBufferSize value: should be a power of two not greater than 32768", err.Error())
+
+		params.NACKBufferSize = 65536
+		require.Error(t, params.IsValid())
+	})
+
+	t.Run("negative pli throttle", func(t *testing.T) {
+		params := RuntimeParams{PLIThrottleMs: -1}
+		err := params.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid PLIThrottleMs value: should not be negative", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		params := RuntimeParams{
+			MaxScreenBitrateKbps: 2500,
+			NACKBufferSize:       1024,
+			PLIThrottleMs:        500,
+		}
+		require.NoError(t, params.IsValid())
+		require.Equal(t, uint16(1024), params.getNACKBufferSize())
+	})
+}
+
+func TestSetRuntimeParams(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	require.Empty(t, server.GetRuntimeParams())
+
+	err := server.SetRuntimeParams(RuntimeParams{NACKBufferSize: 3})
+	require.Error(t, err)
+	require.Empty(t, server.GetRuntimeParams())
+
+	params := RuntimeParams{
+		MaxScreenBitrateKbps: 2500,
+		NACKBufferSize:       512,
+		PLIThrottleMs:        1000,
+	}
+	err = server.SetRuntimeParams(params)
+	require.NoError(t, err)
+	require.Equal(t, params, server.GetRuntimeParams())
+}
+
+func TestShouldForwardPLI(t *testing.T) {
+	now := time.Now()
+
+	t.Run("no throttle", func(t *testing.T) {
+		us := &session{}
+		require.True(t, us.shouldForwardPLI(now, 0))
+		require.True(t, us.shouldForwardPLI(now, 0))
+	})
+
+	t.Run("throttled", func(t *testing.T) {
+		us := &session{}
+		throttle := 500 * time.Millisecond
+		require.True(t, us.shouldForwardPLI(now, throttle))
+		require.False(t, us.shouldForwardPLI(now.Add(100*time.Millisecond), throttle))
+		require.True(t, us.shouldForwardPLI(now.Add(throttle), throttle))
+	})
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+// Quality levels of a stream, as derived from its MOS.
+const (
+	QualityLevelGood = "good"
+	QualityLevelFair = "fair"
+	QualityLevelPoor = "poor"
+)
+
+const (
+	qualityMOSGood = 4.0
+	qualityMOSFair = 3.1
+	// xrMOSUnavailable is the value of the MOS fields of a VoIP metrics
+	// report block when the metric is not available.
+	xrMOSUnavailable = 127
+	// ntpEpochOffset is the number of seconds between the NTP (1900) and
+	// Unix (1970) epochs.
+	ntpEpochOffset = 2208988800
+)
+
+// StreamQuality describes the quality of a stream forwarded to a session, as
+// reported by the receiving peer through RTCP.
+type StreamQuality struct {
+	// TrackID is the ID of the forwarded track.
+	TrackID string `json:"track_id"`
+	// FractionLost is the fraction of packets lost since the previous
+	// report, between 0 and 1.
+	FractionLost float64 `json:"fraction_lost"`
+	JitterMs     float64 `json:"jitter_ms"`
+	// RTTMs is the round trip time. It's zero if unknown.
+	RTTMs float64 `json:"rtt_ms"`
+	// MOS is the estimated mean opinion score, between 1 and 4.5.
+	MOS       float64 `json:"mos"`
+	Level     string  `json:"level"`
+	UpdatedAt int64   `json:"updated_at"`
+	// ConcealmentRate is the fraction of the packets of an audio stream the
+	// receiving peer had to conceal, between 0 and 1.
+	ConcealmentRate float64 `json:"concealment_rate"`
+	// DiscardRate is the fraction of the packets of an audio stream
+	// discarded by the receiving peer for arriving too late. It's only
+	// known from XR VoIP metrics.
+	DiscardRate float64 `json:"discard_rate"`
+	// ConcealmentSource tells what the concealment stats are derived from
+	// (ConcealmentSourceXR or ConcealmentSourceRR). It's empty for video
+	// streams.
+	ConcealmentSource string `json:"concealment_source,omitempty"`
+	// UplinkFractionLost is the fraction of the packets of an audio stream
+	// lost between its publisher and the server, between 0 and 1. Lost
+	// packets are forwarded as gaps so they're part of the concealment of
+	// every subscriber: concealment well above it comes from the downlink of
+	// the subscriber.
+	UplinkFractionLost float64 `json:"uplink_fraction_lost"`
+	// UplinkDelayMs is the estimated one-way delay of the packets of the
+	// stream between its publisher and the server. It's zero if the
+	// publisher leg negotiated neither the abs-send-time nor the
+	// abs-capture-time extension.
+	UplinkDelayMs float64 `json:"uplink_delay_ms"`
+	// DownlinkDelayMs is the estimated one-way delay of the packets of the
+	// stream between the server and the receiving peer. It's zero if the
+	// round trip time is unknown.
+	DownlinkDelayMs float64 `json:"downlink_delay_ms"`
+
+	// xrReceivedAt is the time the last XR VoIP metrics block was received
+	// at.
+	xrReceivedAt time.Time
+}
+
+// computeMOS estimates the mean opinion score of a stream out of its loss,
+// jitter and round trip time, using a simplified version of the ITU-T G.107
+// E-model.
+func computeMOS(fractionLost, jitterMs, rttMs float64) float64 {
+	effectiveLatency := rttMs/2 + jitterMs*2 + 10
+
+	r := 93.2
+	if effectiveLatency < 160 {
+		r -= effectiveLatency / 40
+	} else {
+		r -= (effectiveLatency - 120) / 10
+	}
+	r -= fractionLost * 100 * 2.5
+	r = math.Max(0, math.Min(100, r))
+
+	mos := 1 + 0.035*r + 0.000007*r*(r-60)*(100-r)
+	return math.Max(1, math.Min(4.5, mos))
+}
+
+func getQualityLevel(mos float64) string {
+	switch {
+	case mos >= qualityMOSGood:
+		return QualityLevelGood
+	case mos >= qualityMOSFair:
+		return QualityLevelFair
+	default:
+		return QualityLevelPoor
+	}
+}
+
+func newStreamQuality(trackID string, fractionLost, jitterMs, rttMs float64, now time.Time) StreamQuality {
+	mos := computeMOS(fractionLost, jitterMs, rttMs)
+	return StreamQuality{
+		TrackID:      trackID,
+		FractionLost: fractionLost,
+		JitterMs:     jitterMs,
+		RTTMs:        rttMs,
+		MOS:          math.Round(mos*100) / 100,
+		Level:        getQualityLevel(mos),
+		UpdatedAt:    now.UnixMilli(),
+	}
+}
+
+// getReportRTT returns the round trip time, in milliseconds, derived from the
+// LSR and DLSR fields of a reception report received at the given time. It
+// returns zero if no sender report was received by the peer yet.
+func getReportRTT(lastSenderReport, delay uint32, now time.Time) float64 {
+	if lastSenderReport == 0 {
+		return 0
+	}
+
+	// Middle 32 bits of the NTP timestamp, in units of 1/65536 seconds.
+	secs := uint64(now.Unix()+ntpEpochOffset) << 16
+	frac := uint64(now.Nanosecond()) << 16 / uint64(time.Second)
+	ntp := uint32(secs | frac)
+
+	rtt := ntp - lastSenderReport - delay
+	// Clock skew or a bogus report.
+	if rtt > math.MaxInt32 {
+		return 0
+	}
+
+	return float64(rtt) * 1000 / 65536
+}
+
+// qualityFromReceptionReport computes the quality of a stream out of a
+// reception report (RR or SR) block sent by the receiving peer.
+func qualityFromReceptionReport(trackID string, report rtcp.ReceptionReport, clockRate uint32, now time.Time) StreamQuality {
+	var jitterMs float64
+	if clockRate > 0 {
+		jitterMs = float64(report.Jitter) * 1000 / float64(clockRate)
+	}
+	return newStreamQuality(trackID, float64(report.FractionLost)/256,
+		jitterMs, getReportRTT(report.LastSenderReport, report.Delay, now), now)
+}
+
+// qualityFromVoIPMetrics computes the quality of a stream out of an XR VoIP
+// metrics block sent by the receiving peer. The MOS it carries is used if
+// available.
+func qualityFromVoIPMetrics(trackID string, block *rtcp.VoIPMetricsReportBlock, prev StreamQuality, now time.Time) StreamQuality {
+	q := newStreamQuality(trackID, float64(block.LossRate)/256, prev.JitterMs, float64(block.RoundTripDelay), now)
+	if block.MOSLQ != xrMOSUnavailable && block.MOSLQ >= 10 && block.MOSLQ <= 50 {
+		q.MOS = float64(block.MOSLQ) / 10
+		q.Level = getQualityLevel(q.MOS)
+	}
+	return q
+}
+
+// updateQuality stores the given stream quality, returning whether its level
+// changed. Streams are assumed to be of good quality until reported
+// otherwise.
+func (s *session) updateQuality(q StreamQuality) bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.quality == nil {
+		s.quality = map[string]StreamQuality{}
+	}
+
+	prevLevel := QualityLevelGood
+	if prev, ok := s.quality[q.TrackID]; ok {
+		prevLevel = prev.Level
+	}
+	s.quality[q.TrackID] = q
+	s.addLoss(q.FractionLost)
+	if q.RTTMs > 0 && (s.minRTTMs == 0 || q.RTTMs < s.minRTTMs) {
+		s.minRTTMs = q.RTTMs
+	}
+
+	return q.Level != prevLevel
+}
+
+func (s *session) getStreamQuality(trackID string) StreamQuality {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.quality[trackID]
+}
+
+// getQuality returns the quality of the streams forwarded to the session,
+// sorted by track ID. Must be called with s.mut held.
+func (s *session) getQuality() []StreamQuality {
+	quality := make([]StreamQuality, 0, len(s.quality))
+	for _, q := range s.quality {
+		quality = append(quality, q)
+	}
+	sort.Slice(quality, func(i, j int) bool {
+		return quality[i].TrackID < quality[j].TrackID
+	})
+	return quality
+}
+
+// onStreamQualityUpdate accounts for the quality of a stream forwarded to
+// the given session being updated, notifying if its level changed.
+func (s *Server) onStreamQualityUpdate(us *session, q StreamQuality, levelChanged bool) {
+	if q.DownlinkDelayMs > 0 {
+		s.metrics.ObserveOneWayDelay(DelayLegDownlink, q.DownlinkDelayMs/1000)
+	}
+	if !levelChanged {
+		return
+	}
+	ev := newEvent(StreamQualityChangedEvent, us.cfg)
+	ev.Quality = &q
+	s.sendEvent(ev)
+}
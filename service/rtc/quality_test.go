@@ -0,0 +1,109 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeMOS(t *testing.T) {
+	t.Run("perfect", func(t *testing.T) {
+		mos := computeMOS(0, 0, 0)
+		require.InDelta(t, 4.4, mos, 0.05)
+		require.Equal(t, QualityLevelGood, getQualityLevel(mos))
+	})
+
+	t.Run("degrading", func(t *testing.T) {
+		prev := computeMOS(0, 0, 0)
+		for _, loss := range []float64{0.01, 0.05, 0.1, 0.2} {
+			mos := computeMOS(loss, 10, 50)
+			require.Less(t, mos, prev)
+			prev = mos
+		}
+		require.Equal(t, QualityLevelFair, getQualityLevel(computeMOS(0.05, 10, 50)))
+		require.Equal(t, QualityLevelPoor, getQualityLevel(computeMOS(0.2, 10, 50)))
+	})
+
+	t.Run("bounds", func(t *testing.T) {
+		require.Equal(t, 1.0, computeMOS(1, 1000, 5000))
+		require.LessOrEqual(t, computeMOS(0, 0, 0), 4.5)
+	})
+}
+
+func TestGetReportRTT(t *testing.T) {
+	now := time.Now()
+	require.Zero(t, getReportRTT(0, 0, now))
+
+	// A sender report sent 200ms ago, held for 50ms by the peer.
+	sentAt := now.Add(-200 * time.Millisecond)
+	secs := uint64(sentAt.Unix()+ntpEpochOffset) << 16
+	frac := uint64(sentAt.Nanosecond()) << 16 / uint64(time.Second)
+	lsr := uint32(secs | frac)
+	dlsr := uint32(65536 * 50 / 1000)
+	require.InDelta(t, 150, getReportRTT(lsr, dlsr, now), 1)
+
+	// Reports from the future are ignored.
+	require.Zero(t, getReportRTT(lsr, dlsr, sentAt.Add(-time.Second)))
+}
+
+func TestStreamQuality(t *testing.T) {
+	now := time.Now()
+
+	t.Run("reception report", func(t *testing.T) {
+		q := qualityFromReceptionReport("trackID", rtcp.ReceptionReport{
+			FractionLost: 64,
+			Jitter:       960,
+		}, 48000, now)
+		require.Equal(t, "trackID", q.TrackID)
+		require.Equal(t, 0.25, q.FractionLost)
+		require.Equal(t, 20.0, q.JitterMs)
+		require.Zero(t, q.RTTMs)
+		require.Equal(t, QualityLevelPoor, q.Level)
+		require.Equal(t, now.UnixMilli(), q.UpdatedAt)
+	})
+
+	t.Run("voip metrics", func(t *testing.T) {
+		prev := StreamQuality{JitterMs: 5}
+		q := qualityFromVoIPMetrics("trackID", &rtcp.VoIPMetricsReportBlock{
+			RoundTripDelay: 40,
+			MOSLQ:          xrMOSUnavailable,
+		}, prev, now)
+		require.Equal(t, 5.0, q.JitterMs)
+		require.Equal(t, 40.0, q.RTTMs)
+		require.Equal(t, QualityLevelGood, q.Level)
+
+		q = qualityFromVoIPMetrics("trackID", &rtcp.VoIPMetricsReportBlock{
+			MOSLQ: 30,
+		}, prev, now)
+		require.Equal(t, 3.0, q.MOS)
+		require.Equal(t, QualityLevelPoor, q.Level)
+	})
+
+	t.Run("level changes", func(t *testing.T) {
+		us := &session{}
+		require.False(t, us.updateQuality(newStreamQuality("trackID", 0, 0, 0, now)))
+		require.True(t, us.updateQuality(newStreamQuality("trackID", 0.2, 0, 0, now)))
+		require.False(t, us.updateQuality(newStreamQuality("trackID", 0.3, 0, 0, now)))
+		require.True(t, us.updateQuality(newStreamQuality("otherTrackID", 0.2, 0, 0, now)))
+
+		quality := us.getQuality()
+		require.Len(t, quality, 2)
+		require.Equal(t, "otherTrackID", quality[0].TrackID)
+		require.Equal(t, 0.3, quality[1].FractionLost)
+	})
+
+	t.Run("lowest rtt", func(t *testing.T) {
+		us := &session{}
+		us.updateQuality(newStreamQuality("trackID", 0, 0, 0, now))
+		require.Zero(t, us.getMinRTT())
+		us.updateQuality(newStreamQuality("trackID", 0, 0, 80, now))
+		us.updateQuality(newStreamQuality("otherTrackID", 0, 0, 50, now))
+		us.updateQuality(newStreamQuality("trackID", 0, 0, 120, now))
+		require.Equal(t, 50.0, us.getMinRTT())
+	})
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const reaperInterval = 30 * time.Second
+
+// Reasons passed to the session close callback, and reported in the
+// SessionLeftEvent, when a session is closed.
+const (
+	// CloseReasonLeft is used when a session is closed on request, usually
+	// because its client left the call.
+	CloseReasonLeft = "left"
+	// CloseReasonKicked is used when a session is closed on request because
+	// its user got removed from the call (e.g. by a moderator).
+	CloseReasonKicked = "kicked"
+	// CloseReasonNetworkTimeout is used when a session is closed because
+	// its media connection failed, i.e. no packet was received from the
+	// client for longer than the ICE failed timeout.
+	CloseReasonNetworkTimeout = "network_timeout"
+	// CloseReasonSignalingTimeout is used when a session is closed because
+	// its client didn't send an offer in time.
+	CloseReasonSignalingTimeout = "signaling_timeout"
+	// CloseReasonConnectionClosed is used when a session is closed because
+	// its client closed the media connection.
+	CloseReasonConnectionClosed = "connection_closed"
+	CloseReasonIdle             = "idle"
+	CloseReasonMaxDuration      = "max_duration"
+	// CloseReasonMaxParticipants is only used when rejecting a session that
+	// would exceed the configured participants limit.
+	CloseReasonMaxParticipants = "max_participants"
+	// CloseReasonBusy is only used when rejecting a session that would start
+	// a new call while the node is over capacity.
+	CloseReasonBusy = "busy"
+	// CloseReasonInternalError is used when a session is closed after one of
+	// its goroutines panicked.
+	CloseReasonInternalError = "internal_error"
+	// CloseReasonShutdown is used when a session is closed, or rejected,
+	// because the server is shutting down.
+	CloseReasonShutdown = "shutdown"
+)
+
+var (
+	ErrMaxParticipantsReached = errors.New("max participants reached")
+	ErrServerDraining         = errors.New("server is shutting down")
+	ErrServerBusy             = errors.New("server is over capacity")
+)
+
+// isIdle returns whether the session has not had a connected peer for longer
+// than timeout.
+func (s *session) isIdle(now time.Time, timeout time.Duration) bool {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return !s.connected && now.Sub(s.connStateChangedAt) > timeout
+}
+
+func (s *session) setConnected(connected bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.connected != connected {
+		s.connected = connected
+		s.connStateChangedAt = time.Now()
+	}
+}
+
+func (s *Server) callReaper(stopCh <-chan struct{}, doneCh chan<- struct{}) {
+	defer close(doneCh)
+
+	ticker := time.NewTicker(reaperInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if s.cfg.IdleCallTimeoutMinutes > 0 {
+				s.reapIdleCalls(now)
+			}
+			if s.cfg.MaxCallDurationMinutes > 0 {
+				s.endExpiredCalls(now)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// reapIdleCalls ends the calls that have no sessions or in which all the
+// sessions have been idle for longer than the configured timeout.
+func (s *Server) reapIdleCalls(now time.Time) {
+	timeout := time.Duration(s.cfg.IdleCallTimeoutMinutes) * time.Minute
+
+	s.iterCalls(func(g *group, c *call) {
+		sessions := c.getSessions()
+		if len(sessions) == 0 {
+			s.removeEmptyCall(g, c)
+			return
+		}
+
+		for _, ss := range sessions {
+			if !ss.isIdle(now, timeout) {
+				return
+			}
+		}
+
+		s.log.Info("rtc: ending idle call", mlog.String("groupID", g.id), mlog.String("callID", c.id),
+			mlog.Int("numSessions", len(sessions)))
+		s.endCall(sessions, CloseReasonIdle)
+	})
+}
+
+// endExpiredCalls ends the calls that have been going on for longer than the
+// configured maximum duration.
+func (s *Server) endExpiredCalls(now time.Time) {
+	maxDuration := time.Duration(s.cfg.MaxCallDurationMinutes) * time.Minute
+
+	s.iterCalls(func(g *group, c *call) {
+		if now.Sub(c.createdAt) <= maxDuration {
+			return
+		}
+
+		sessions := c.getSessions()
+		s.log.Info("rtc: ending call exceeding max duration", mlog.String("groupID", g.id), mlog.String("callID", c.id),
+			mlog.Int("numSessions", len(sessions)))
+		s.endCall(sessions, CloseReasonMaxDuration)
+	})
+}
+
+func (s *Server) endCall(sessions []*session, reason string) {
+	for _, ss := range sessions {
+		if err := s.closeSession(ss.cfg.SessionID, reason); err != nil {
+			s.log.Error("failed to close session", mlog.Err(err), mlog.String("groupID", ss.cfg.GroupID), mlog.String("sessionID", ss.cfg.SessionID), mlog.String("traceID", ss.cfg.TraceID),
+				mlog.String("reason", reason))
+		}
+	}
+}
+
+// iterCalls calls cb for every call in every group. No lock is held while cb
+// is running.
+func (s *Server) iterCalls(cb func(g *group, c *call)) {
+	s.mut.RLock()
+	groups := make([]*group, 0, len(s.groups))
+	for _, g := range s.groups {
+		groups = append(groups, g)
+	}
+	s.mut.RUnlock()
+
+	for _, g := range groups {
+		g.mut.RLock()
+		calls := make([]*call, 0, len(g.calls))
+		for _, c := range g.calls {
+			calls = append(calls, c)
+		}
+		g.mut.RUnlock()
+
+		for _, c := range calls {
+			cb(g, c)
+		}
+	}
+}
+
+func (s *Server) removeEmptyCall(g *group, c *call) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if len(c.sessions) > 0 {
+		return
+	}
+
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	if g.calls[c.id] != c {
+		return
+	}
+	s.log.Debug("rtc: removing empty call", mlog.String("groupID", g.id), mlog.String("callID", c.id))
+	delete(g.calls, c.id)
+	if len(g.calls) == 0 {
+		s.mut.Lock()
+		delete(s.groups, g.id)
+		s.mut.Unlock()
+	}
+}
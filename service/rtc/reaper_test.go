@@ -0,0 +1,123 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReapIdleCalls(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	server.cfg.IdleCallTimeoutMinutes = 10
+	timeout := 10 * time.Minute
+
+	reasonCh := make(chan string, 10)
+	addSession := func(t *testing.T, sessionID string) *session {
+		t.Helper()
+		cfg := SessionConfig{
+			GroupID:   "groupID",
+			CallID:    "callID",
+			UserID:    "userID",
+			SessionID: sessionID,
+		}
+		peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		us, err := server.addSession(cfg, peerConn, func(reason string) error {
+			reasonCh <- reason
+			return nil
+		})
+		require.NoError(t, err)
+		return us
+	}
+
+	t.Run("empty call", func(t *testing.T) {
+		server.mut.Lock()
+		server.groups["groupID"] = &group{
+			id: "groupID",
+			calls: map[string]*call{
+				"callID": {id: "callID", sessions: map[string]*session{}},
+			},
+		}
+		server.mut.Unlock()
+
+		server.reapIdleCalls(time.Now())
+		require.Nil(t, server.getGroup("groupID"))
+	})
+
+	t.Run("connected session", func(t *testing.T) {
+		usA := addSession(t, "sessionA")
+		usB := addSession(t, "sessionB")
+		usA.setConnected(true)
+
+		server.reapIdleCalls(time.Now().Add(2 * timeout))
+		group := server.getGroup("groupID")
+		require.NotNil(t, group)
+		require.NotNil(t, group.getCall("callID"))
+
+		require.NoError(t, server.CloseSession(usA.cfg.SessionID))
+		require.EqualError(t, server.CloseSessionWithReason(usB.cfg.SessionID, ""), "invalid reason: should not be empty")
+		require.NoError(t, server.CloseSessionWithReason(usB.cfg.SessionID, CloseReasonKicked))
+		require.Nil(t, server.getGroup("groupID"))
+		require.Equal(t, CloseReasonLeft, <-reasonCh)
+		require.Equal(t, CloseReasonKicked, <-reasonCh)
+	})
+
+	t.Run("idle sessions", func(t *testing.T) {
+		usA := addSession(t, "sessionA")
+		addSession(t, "sessionB")
+
+		server.reapIdleCalls(time.Now())
+		require.NotNil(t, server.getGroup("groupID"))
+
+		usA.setConnected(true)
+		usA.setConnected(false)
+		server.reapIdleCalls(time.Now().Add(timeout / 2))
+		require.NotNil(t, server.getGroup("groupID"))
+
+		server.reapIdleCalls(time.Now().Add(2 * timeout))
+		require.Nil(t, server.getGroup("groupID"))
+		require.Equal(t, CloseReasonIdle, <-reasonCh)
+		require.Equal(t, CloseReasonIdle, <-reasonCh)
+	})
+}
+
+func TestEndExpiredCalls(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	server.cfg.MaxCallDurationMinutes = 60
+	maxDuration := 60 * time.Minute
+
+	reasonCh := make(chan string, 10)
+	for _, sessionID := range []string{"sessionA", "sessionB"} {
+		cfg := SessionConfig{
+			GroupID:   "groupID",
+			CallID:    "callID",
+			UserID:    "userID",
+			SessionID: sessionID,
+		}
+		peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		us, err := server.addSession(cfg, peerConn, func(reason string) error {
+			reasonCh <- reason
+			return nil
+		})
+		require.NoError(t, err)
+		us.setConnected(true)
+	}
+
+	server.endExpiredCalls(time.Now().Add(maxDuration / 2))
+	require.NotNil(t, server.getGroup("groupID"))
+
+	server.endExpiredCalls(time.Now().Add(2 * maxDuration))
+	require.Nil(t, server.getGroup("groupID"))
+	require.Equal(t, CloseReasonMaxDuration, <-reasonCh)
+	require.Equal(t, CloseReasonMaxDuration, <-reasonCh)
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const (
+	ReceiverReportAggregationNone   = "none"
+	ReceiverReportAggregationWorst  = "worst"
+	ReceiverReportAggregationMedian = "median"
+)
+
+const (
+	receiverReportInterval = time.Second
+	// receiverReportTimeout is how long a subscriber report is considered
+	// when aggregating.
+	receiverReportTimeout = 5 * time.Second
+)
+
+type receiverReport struct {
+	report     rtcp.ReceptionReport
+	receivedAt time.Time
+}
+
+// trackReports collects the latest reception reports sent by subscribers of
+// a forwarded track.
+type trackReports struct {
+	reports map[string]receiverReport
+	mut     sync.Mutex
+}
+
+func newTrackReports() *trackReports {
+	return &trackReports{
+		reports: map[string]receiverReport{},
+	}
+}
+
+func (t *trackReports) update(sessionID string, report rtcp.ReceptionReport, now time.Time) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.reports[sessionID] = receiverReport{
+		report:     report,
+		receivedAt: now,
+	}
+}
+
+// getRecent returns the reports received after the given time, dropping the
+// older ones.
+func (t *trackReports) getRecent(since time.Time) []rtcp.ReceptionReport {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	reports := make([]rtcp.ReceptionReport, 0, len(t.reports))
+	for sessionID, r := range t.reports {
+		if r.receivedAt.Before(since) {
+			delete(t.reports, sessionID)
+			continue
+		}
+		reports = append(reports, r.report)
+	}
+	return reports
+}
+
+// aggregateReceptionReports synthesizes a single report out of the given
+// subscriber reports according to mode. Loss and jitter are aggregated
+// independently of each other.
+func aggregateReceptionReports(reports []rtcp.ReceptionReport, mode string) rtcp.ReceptionReport {
+	var aggr rtcp.ReceptionReport
+	if len(reports) == 0 {
+		return aggr
+	}
+
+	pick := func(values []uint32) uint32 {
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		if mode == ReceiverReportAggregationMedian {
+			return values[len(values)/2]
+		}
+		return values[len(values)-1]
+	}
+
+	fractionLost := make([]uint32, len(reports))
+	totalLost := make([]uint32, len(reports))
+	jitter := make([]uint32, len(reports))
+	for i, r := range reports {
+		fractionLost[i] = uint32(r.FractionLost)
+		totalLost[i] = r.TotalLost
+		jitter[i] = r.Jitter
+		if r.LastSequenceNumber > aggr.LastSequenceNumber {
+			aggr.LastSequenceNumber = r.LastSequenceNumber
+		}
+	}
+
+	aggr.FractionLost = uint8(pick(fractionLost))
+	aggr.TotalLost = pick(totalLost)
+	aggr.Jitter = pick(jitter)
+
+	return aggr
+}
+
+// trackReceiverReports starts collecting the subscriber reports for the given
+// forwarded track, if aggregation is enabled. The returned function should be
+// called once forwarding stops.
+func (s *Server) trackReceiverReports(us *session, call *call, remoteTrack *webrtc.TrackRemote, outTrack *webrtc.TrackLocalStaticRTP) func() {
+	if !s.cfg.isReceiverReportAggregationEnabled() {
+		return func() {}
+	}
+
+	tr := newTrackReports()
+	stopCh := make(chan struct{})
+	call.addTrackReports(outTrack.ID(), tr)
+	go s.feedReceiverReports(us, remoteTrack, tr, stopCh)
+
+	return func() {
+		call.removeTrackReports(outTrack.ID())
+		close(stopCh)
+	}
+}
+
+// feedReceiverReports periodically sends the publisher a receiver report
+// aggregating those sent by the subscribers of its track so that its encoder
+// can adapt to their conditions.
+func (s *Server) feedReceiverReports(us *session, remoteTrack *webrtc.TrackRemote, tr *trackReports, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(receiverReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			reports := tr.getRecent(now.Add(-receiverReportTimeout))
+			if len(reports) == 0 {
+				continue
+			}
+			report := aggregateReceptionReports(reports, s.cfg.ReceiverReportAggregation)
+			report.SSRC = uint32(remoteTrack.SSRC())
+			if err := us.rtcConn.WriteRTCP([]rtcp.Packet{&rtcp.ReceiverReport{
+				Reports: []rtcp.ReceptionReport{report},
+			}}); err != nil {
+				s.log.Debug("failed to write receiver report", mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+			}
+		case <-stopCh:
+			return
+		case <-us.closeCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregateReceptionReports(t *testing.T) {
+	reports := []rtcp.ReceptionReport{
+		{FractionLost: 10, TotalLost: 100, Jitter: 30, LastSequenceNumber: 1000},
+		{FractionLost: 50, TotalLost: 20, Jitter: 10, LastSequenceNumber: 1010},
+		{FractionLost: 0, TotalLost: 0, Jitter: 20, LastSequenceNumber: 1005},
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		require.Empty(t, aggregateReceptionReports(nil, ReceiverReportAggregationWorst))
+	})
+
+	t.Run("worst", func(t *testing.T) {
+		aggr := aggregateReceptionReports(reports, ReceiverReportAggregationWorst)
+		require.Equal(t, rtcp.ReceptionReport{
+			FractionLost:       50,
+			TotalLost:          100,
+			Jitter:             30,
+			LastSequenceNumber: 1010,
+		}, aggr)
+	})
+
+	t.Run("median", func(t *testing.T) {
+		aggr := aggregateReceptionReports(reports, ReceiverReportAggregationMedian)
+		require.Equal(t, rtcp.ReceptionReport{
+			FractionLost:       10,
+			TotalLost:          20,
+			Jitter:             20,
+			LastSequenceNumber: 1010,
+		}, aggr)
+	})
+}
+
+func TestTrackReports(t *testing.T) {
+	tr := newTrackReports()
+	now := time.Now()
+
+	tr.update("sessionA", rtcp.ReceptionReport{FractionLost: 10}, now.Add(-10*time.Second))
+	tr.update("sessionB", rtcp.ReceptionReport{FractionLost: 20}, now)
+	tr.update("sessionB", rtcp.ReceptionReport{FractionLost: 30}, now)
+
+	reports := tr.getRecent(now.Add(-receiverReportTimeout))
+	require.Equal(t, []rtcp.ReceptionReport{{FractionLost: 30}}, reports)
+
+	// Stale reports should have been dropped.
+	require.Len(t, tr.reports, 1)
+}
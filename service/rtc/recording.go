@@ -0,0 +1,320 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/pion/webrtc/v3/pkg/media/ivfwriter"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+)
+
+var recordingTargetRE = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// RecordingInfo describes the recording of a single participant.
+type RecordingInfo struct {
+	ID        string `json:"id"`
+	GroupID   string `json:"group_id"`
+	CallID    string `json:"call_id"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+	// Dir is the directory the track files are written to.
+	Dir string `json:"dir"`
+	// Files lists the names of the track files written so far, relative to
+	// Dir. Voice and screen sharing audio tracks are written as Ogg/Opus,
+	// the screen sharing video track as IVF.
+	Files     []string `json:"files"`
+	StartedAt int64    `json:"started_at"`
+	StoppedAt int64    `json:"stopped_at,omitempty"`
+	// Reason is why the recording was stopped.
+	Reason string `json:"reason,omitempty"`
+}
+
+// recording writes the tracks received from a session to files, one per
+// track type. Files are created as the tracks get received.
+type recording struct {
+	info    RecordingInfo
+	writers map[string]media.Writer
+	timer   *time.Timer
+	closed  bool
+	// onDone is called when the recording reaches its time bound or fails.
+	onDone func(r *recording, reason string)
+	mut    sync.Mutex
+}
+
+func newRecording(cfg SessionConfig, dir string, duration time.Duration, onDone func(r *recording, reason string)) (*recording, error) {
+	if err := os.MkdirAll(filepath.Dir(dir), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create recording target: %w", err)
+	}
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create recording dir: %w", err)
+	}
+
+	r := &recording{
+		info: RecordingInfo{
+			ID:        filepath.Base(dir),
+			GroupID:   cfg.GroupID,
+			CallID:    cfg.CallID,
+			UserID:    cfg.UserID,
+			SessionID: cfg.SessionID,
+			Dir:       dir,
+			Files:     []string{},
+			StartedAt: time.Now().UnixMilli(),
+		},
+		writers: map[string]media.Writer{},
+		onDone:  onDone,
+	}
+
+	r.mut.Lock()
+	r.timer = time.AfterFunc(duration, func() {
+		r.onDone(r, "duration elapsed")
+	})
+	r.mut.Unlock()
+
+	return r, nil
+}
+
+func newTrackWriter(path, trackType string) (media.Writer, error) {
+	if trackType == "screen" {
+		return ivfwriter.New(path)
+	}
+	return oggwriter.New(path, rtpAudioCodec.ClockRate, rtpAudioCodec.Channels)
+}
+
+// writeRTP writes a packet of the given track type ("voice", "screen" or
+// "screen-audio").
+func (r *recording) writeRTP(trackType string, pkt *rtp.Packet) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if r.closed {
+		return
+	}
+
+	w := r.writers[trackType]
+	if w == nil {
+		ext := ".ogg"
+		if trackType == "screen" {
+			ext = ".ivf"
+		}
+		name := trackType + ext
+		var err error
+		w, err = newTrackWriter(filepath.Join(r.info.Dir, name), trackType)
+		if err != nil {
+			r.closeLocked()
+			go r.onDone(r, "failed to create track file: "+err.Error())
+			return
+		}
+		r.writers[trackType] = w
+		r.info.Files = append(r.info.Files, name)
+		sort.Strings(r.info.Files)
+	}
+
+	if err := w.WriteRTP(pkt); err != nil {
+		r.closeLocked()
+		go r.onDone(r, "write failed: "+err.Error())
+	}
+}
+
+func (r *recording) closeLocked() {
+	if r.closed {
+		return
+	}
+	r.closed = true
+	r.timer.Stop()
+	for _, w := range r.writers {
+		_ = w.Close()
+	}
+	r.info.StoppedAt = time.Now().UnixMilli()
+}
+
+// close stops the recording and returns its info. It's safe to call it
+// multiple times, only the first reason is kept.
+func (r *recording) close(reason string) RecordingInfo {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if !r.closed {
+		r.info.Reason = reason
+	}
+	r.closeLocked()
+	return r.getInfoLocked()
+}
+
+func (r *recording) getInfo() RecordingInfo {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.getInfoLocked()
+}
+
+func (r *recording) getInfoLocked() RecordingInfo {
+	info := r.info
+	info.Files = make([]string, len(r.info.Files))
+	copy(info.Files, r.info.Files)
+	return info
+}
+
+func (s *session) getRecording() *recording {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.recording
+}
+
+func (s *session) setRecording(r *recording) bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.recording == nil {
+		s.recording = r
+		return true
+	}
+	return false
+}
+
+// clearRecording removes the given recording from the session. It returns
+// false if it was already removed.
+func (s *session) clearRecording(r *recording) bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.recording != r {
+		return false
+	}
+	s.recording = nil
+	return true
+}
+
+func (s *Server) getCallSession(groupID, sessionID string) (*call, *session, error) {
+	s.mut.RLock()
+	cfg, ok := s.sessions[sessionID]
+	s.mut.RUnlock()
+	if !ok || cfg.GroupID != groupID {
+		return nil, nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	group := s.getGroup(cfg.GroupID)
+	if group == nil {
+		return nil, nil, fmt.Errorf("group not found: %s", cfg.GroupID)
+	}
+	call := group.getCall(cfg.CallID)
+	if call == nil {
+		return nil, nil, fmt.Errorf("call not found: %s", cfg.CallID)
+	}
+	us := call.getSession(sessionID)
+	if us == nil {
+		return nil, nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	return call, us, nil
+}
+
+// StartSessionRecording starts recording the tracks of the given session to
+// the target subdirectory of the configured recordings directory. An empty
+// target means the directory itself. Recordings are independent from one
+// another and get stopped once duration (capped to the configured maximum)
+// elapses or the session ends.
+func (s *Server) StartSessionRecording(groupID, sessionID, target string, duration time.Duration) (RecordingInfo, error) {
+	if s.cfg.Recording.Dir == "" {
+		return RecordingInfo{}, fmt.Errorf("recording is not enabled")
+	}
+
+	if target != "" && !recordingTargetRE.MatchString(target) {
+		return RecordingInfo{}, fmt.Errorf("invalid recording target: %q", target)
+	}
+
+	call, us, err := s.getCallSession(groupID, sessionID)
+	if err != nil {
+		return RecordingInfo{}, err
+	}
+
+	if us.getRecording() != nil {
+		return RecordingInfo{}, fmt.Errorf("recording already started")
+	}
+
+	maxDuration := time.Duration(s.cfg.Recording.MaxDurationSeconds) * time.Second
+	if duration <= 0 || duration > maxDuration {
+		duration = maxDuration
+	}
+
+	// IDs are client provided, a random name keeps them out of the path.
+	dir := filepath.Join(s.cfg.Recording.Dir, target,
+		fmt.Sprintf("rtcd_recording_%s_%s", time.Now().UTC().Format("20060102T150405Z"), random.NewID()))
+	rec, err := newRecording(us.cfg, dir, duration, func(rec *recording, reason string) {
+		s.stopSessionRecording(us, rec, reason)
+	})
+	if err != nil {
+		return RecordingInfo{}, err
+	}
+
+	if !us.setRecording(rec) {
+		rec.close("")
+		os.RemoveAll(dir)
+		return RecordingInfo{}, fmt.Errorf("recording already started")
+	}
+
+	// Video can only be decoded starting from a key frame.
+	if call.getScreenSession() == us {
+		if err := call.requestKeyFrame(s.GetRuntimeParams()); err != nil {
+			s.log.Debug("failed to request key frame", mlog.Err(err), mlog.String("sessionID", sessionID))
+		}
+	}
+
+	info := rec.getInfo()
+
+	s.log.Info("recording started",
+		mlog.String("groupID", groupID),
+		mlog.String("sessionID", sessionID),
+		mlog.String("dir", dir),
+		mlog.Int("durationSeconds", int(duration.Seconds())))
+
+	ev := newEvent(RecordingStartedEvent, us.cfg)
+	ev.Recording = &info
+	s.sendEvent(ev)
+
+	return info, nil
+}
+
+// StopSessionRecording stops the recording running on the given session.
+func (s *Server) StopSessionRecording(groupID, sessionID string) (RecordingInfo, error) {
+	_, us, err := s.getCallSession(groupID, sessionID)
+	if err != nil {
+		return RecordingInfo{}, err
+	}
+
+	rec := us.getRecording()
+	if rec == nil {
+		return RecordingInfo{}, fmt.Errorf("recording not started")
+	}
+
+	return s.stopSessionRecording(us, rec, "stopped"), nil
+}
+
+func (s *Server) stopSessionRecording(us *session, rec *recording, reason string) RecordingInfo {
+	if !us.clearRecording(rec) {
+		// Already stopped.
+		return rec.close(reason)
+	}
+	info := rec.close(reason)
+	s.log.Info("recording stopped",
+		mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID),
+		mlog.String("traceID", us.cfg.TraceID),
+		mlog.String("dir", info.Dir),
+		mlog.String("reason", info.Reason))
+
+	ev := newEvent(RecordingStoppedEvent, us.cfg)
+	ev.Recording = &info
+	s.sendEvent(ev)
+
+	s.postProcessRecording(info)
+
+	return info
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const (
+	recordingManifestName = "manifest.json"
+	// postProcessOutputLimit caps the command output included in errors.
+	postProcessOutputLimit = 1024
+)
+
+// writeRecordingManifest writes the info of a completed recording next to
+// its track files. It returns the path of the manifest and its content.
+func writeRecordingManifest(info RecordingInfo) (string, []byte, error) {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	path := filepath.Join(info.Dir, recordingManifestName)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	return path, data, nil
+}
+
+func runPostProcessCommand(ctx context.Context, command, manifestPath string) error {
+	out, err := exec.CommandContext(ctx, command, manifestPath).CombinedOutput()
+	if err != nil {
+		if len(out) > postProcessOutputLimit {
+			out = out[:postProcessOutputLimit]
+		}
+		return fmt.Errorf("command failed: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func postRecordingManifest(ctx context.Context, u string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// postProcessRecording writes the manifest of a completed recording and
+// runs the configured post-processing hooks in the background.
+func (s *Server) postProcessRecording(info RecordingInfo) {
+	manifestPath, data, err := writeRecordingManifest(info)
+	if err != nil {
+		s.log.Error("failed to write recording manifest", mlog.Err(err), mlog.String("recordingID", info.ID))
+		return
+	}
+
+	cfg := s.cfg.Recording
+	if cfg.PostProcessCommand == "" && cfg.PostProcessURL == "" {
+		return
+	}
+
+	s.recordingHooksWg.Add(1)
+	go func() {
+		defer s.recordingHooksWg.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.PostProcessTimeoutSeconds)*time.Second)
+		defer cancel()
+
+		if cfg.PostProcessCommand != "" {
+			if err := runPostProcessCommand(ctx, cfg.PostProcessCommand, manifestPath); err != nil {
+				s.log.Error("recording post-processing command failed", mlog.Err(err), mlog.String("recordingID", info.ID))
+			} else {
+				s.log.Debug("recording post-processing command succeeded", mlog.String("recordingID", info.ID))
+			}
+		}
+
+		if cfg.PostProcessURL != "" {
+			if err := postRecordingManifest(ctx, cfg.PostProcessURL, data); err != nil {
+				s.log.Error("failed to post recording manifest", mlog.Err(err), mlog.String("recordingID", info.ID))
+			} else {
+				s.log.Debug("recording manifest posted", mlog.String("recordingID", info.ID))
+			}
+		}
+	}()
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostProcessRecording(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	dir := t.TempDir()
+	info := RecordingInfo{
+		ID:        "recordingID",
+		SessionID: "sessionID",
+		Dir:       dir,
+		Files:     []string{"voice.ogg"},
+		Reason:    "stopped",
+	}
+
+	t.Run("manifest only", func(t *testing.T) {
+		server.postProcessRecording(info)
+
+		data, err := os.ReadFile(filepath.Join(dir, recordingManifestName))
+		require.NoError(t, err)
+		var manifest RecordingInfo
+		err = json.Unmarshal(data, &manifest)
+		require.NoError(t, err)
+		require.Equal(t, info, manifest)
+	})
+
+	t.Run("hooks", func(t *testing.T) {
+		outPath := filepath.Join(t.TempDir(), "out")
+		script := filepath.Join(t.TempDir(), "hook.sh")
+		err := os.WriteFile(script, []byte("#!/bin/sh\ncp \"$1\" "+outPath+"\n"), 0700)
+		require.NoError(t, err)
+
+		bodyCh := make(chan []byte, 1)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			bodyCh <- body
+		}))
+		defer ts.Close()
+
+		server.cfg.Recording.PostProcessCommand = script
+		server.cfg.Recording.PostProcessURL = ts.URL
+		server.cfg.Recording.PostProcessTimeoutSeconds = 10
+		server.postProcessRecording(info)
+
+		manifest, err := os.ReadFile(filepath.Join(dir, recordingManifestName))
+		require.NoError(t, err)
+
+		select {
+		case body := <-bodyCh:
+			require.Equal(t, manifest, body)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for manifest")
+		}
+
+		server.recordingHooksWg.Wait()
+		out, err := os.ReadFile(outPath)
+		require.NoError(t, err)
+		require.Equal(t, manifest, out)
+	})
+}
+
+func TestRunPostProcessCommand(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "hook.sh")
+	err := os.WriteFile(script, []byte("#!/bin/sh\necho \"failed on $1\"\nexit 1\n"), 0700)
+	require.NoError(t, err)
+
+	err = runPostProcessCommand(context.Background(), script, "manifest.json")
+	require.EqualError(t, err, "command failed: exit status 1: failed on manifest.json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = runPostProcessCommand(ctx, "sleep", "10")
+	require.Error(t, err)
+}
+
+func TestPostRecordingManifest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	err := postRecordingManifest(context.Background(), ts.URL, []byte("{}"))
+	require.EqualError(t, err, "unexpected status code 500")
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecording(t *testing.T) {
+	dir := t.TempDir()
+	cfg := SessionConfig{
+		GroupID:   "groupID",
+		CallID:    "callID",
+		UserID:    "userID",
+		SessionID: "sessionID",
+	}
+
+	voicePkt := &rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: 111, SequenceNumber: 1, Timestamp: 960, SSRC: 1000},
+		Payload: []byte{0x01, 0x02, 0x03},
+	}
+	// VP8 key frame, starting and ending a frame.
+	screenPkt := &rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 1, SSRC: 2000, Marker: true},
+		Payload: []byte{0x10, 0x00, 0x01, 0x02, 0x03},
+	}
+
+	t.Run("tracks", func(t *testing.T) {
+		recDir := filepath.Join(dir, "target", "tracks")
+		r, err := newRecording(cfg, recDir, time.Minute, func(_ *recording, _ string) {})
+		require.NoError(t, err)
+
+		info := r.getInfo()
+		require.Equal(t, "tracks", info.ID)
+		require.Equal(t, "sessionID", info.SessionID)
+		require.Empty(t, info.Files)
+		require.NotZero(t, info.StartedAt)
+
+		r.writeRTP("voice", voicePkt)
+		r.writeRTP("screen", screenPkt)
+		r.writeRTP("voice", voicePkt)
+
+		info = r.close("stopped")
+		require.Equal(t, []string{"screen.ivf", "voice.ogg"}, info.Files)
+		require.Equal(t, "stopped", info.Reason)
+		require.NotZero(t, info.StoppedAt)
+		// Closing again is a no-op.
+		require.Equal(t, info, r.close("other"))
+		r.writeRTP("screen-audio", voicePkt)
+		require.Equal(t, info, r.getInfo())
+
+		data, err := os.ReadFile(filepath.Join(recDir, "voice.ogg"))
+		require.NoError(t, err)
+		require.Equal(t, "OggS", string(data[:4]))
+
+		data, err = os.ReadFile(filepath.Join(recDir, "screen.ivf"))
+		require.NoError(t, err)
+		require.Equal(t, "DKIF", string(data[:4]))
+	})
+
+	t.Run("existing dir", func(t *testing.T) {
+		_, err := newRecording(cfg, filepath.Join(dir, "target", "tracks"), time.Minute, func(_ *recording, _ string) {})
+		require.Error(t, err)
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		doneCh := make(chan string, 1)
+		_, err := newRecording(cfg, filepath.Join(dir, "duration"), 10*time.Millisecond, func(r *recording, reason string) {
+			r.close(reason)
+			doneCh <- reason
+		})
+		require.NoError(t, err)
+
+		select {
+		case reason := <-doneCh:
+			require.Equal(t, "duration elapsed", reason)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for recording to stop")
+		}
+	})
+}
+
+func TestStartSessionRecording(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	t.Run("not enabled", func(t *testing.T) {
+		_, err := server.StartSessionRecording("groupID", "sessionID", "", 0)
+		require.EqualError(t, err, "recording is not enabled")
+	})
+
+	dir := t.TempDir()
+	server.cfg.Recording = RecordingConfig{
+		Dir:                dir,
+		MaxDurationSeconds: 60,
+	}
+
+	t.Run("invalid target", func(t *testing.T) {
+		for _, target := range []string{"..", "a/b", "/tmp"} {
+			_, err := server.StartSessionRecording("groupID", "sessionID", target, 0)
+			require.EqualError(t, err, "invalid recording target: \""+target+"\"")
+		}
+	})
+
+	t.Run("session not found", func(t *testing.T) {
+		_, err := server.StartSessionRecording("groupID", "sessionID", "", 0)
+		require.EqualError(t, err, "session not found: sessionID")
+
+		_, err = server.StopSessionRecording("groupID", "sessionID")
+		require.EqualError(t, err, "session not found: sessionID")
+	})
+
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	us, err := server.addSession(SessionConfig{
+		GroupID:   "groupID",
+		CallID:    "callID",
+		UserID:    "userID",
+		SessionID: "sessionID",
+	}, peerConn, nil)
+	require.NoError(t, err)
+
+	t.Run("wrong group", func(t *testing.T) {
+		_, err := server.StartSessionRecording("otherGroupID", "sessionID", "", 0)
+		require.EqualError(t, err, "session not found: sessionID")
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		info, err := server.StartSessionRecording("groupID", "sessionID", "presenter", time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(dir, "presenter"), filepath.Dir(info.Dir))
+		require.Equal(t, "userID", info.UserID)
+		require.DirExists(t, info.Dir)
+
+		_, err = server.StartSessionRecording("groupID", "sessionID", "", 0)
+		require.EqualError(t, err, "recording already started")
+
+		info, err = server.StopSessionRecording("groupID", "sessionID")
+		require.NoError(t, err)
+		require.Equal(t, "stopped", info.Reason)
+
+		_, err = server.StopSessionRecording("groupID", "sessionID")
+		require.EqualError(t, err, "recording not started")
+	})
+
+	t.Run("session end", func(t *testing.T) {
+		_, err := server.StartSessionRecording("groupID", "sessionID", "", 0)
+		require.NoError(t, err)
+		rec := us.getRecording()
+		require.NotNil(t, rec)
+
+		err = server.CloseSession(us.cfg.SessionID)
+		require.NoError(t, err)
+		require.Nil(t, us.getRecording())
+		require.Equal(t, "session ended", rec.getInfo().Reason)
+	})
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"github.com/mattermost/rtcd/service/crash"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// SetCrashReporter sets the reporter of the panics raised by the UDP readers
+// and the sessions' goroutines. Recovering from them keeps the other calls
+// running: readers are restarted and the affected sessions are closed.
+// Without a reporter, panics crash the process. Must be called before Start.
+func (s *Server) SetCrashReporter(reporter *crash.Reporter) {
+	s.crash = reporter
+}
+
+// closePanickedSession closes a session after one of its goroutines
+// panicked. It's done asynchronously as the panicking goroutine may have left
+// locks of the session held.
+func (s *Server) closePanickedSession(sessionID string) {
+	go func() {
+		if err := s.closeSession(sessionID, CloseReasonInternalError); err != nil {
+			s.log.Error("failed to close session", mlog.Err(err), mlog.String("sessionID", sessionID),
+				mlog.String("reason", CloseReasonInternalError))
+		}
+	}()
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// reusePortSupported is true if multiple UDP sockets can be bound to the
+// same address, with the kernel balancing the packets between them. On
+// FreeBSD (12.0 and later) this requires SO_REUSEPORT_LB, SO_REUSEPORT
+// alone delivers all unicast packets to a single socket.
+const reusePortSupported = true
+
+func setReusePort(fd uintptr) error {
+	if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+		return fmt.Errorf("failed to set reuseaddr option: %w", err)
+	}
+	if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT_LB, 1); err != nil {
+		return fmt.Errorf("failed to set reuseport_lb option: %w", err)
+	}
+	return nil
+}
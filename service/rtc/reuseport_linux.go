@@ -0,0 +1,24 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// reusePortSupported is true if multiple UDP sockets can be bound to the
+// same address, with the kernel balancing the packets between them.
+const reusePortSupported = true
+
+func setReusePort(fd uintptr) error {
+	if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+		return fmt.Errorf("failed to set reuseaddr option: %w", err)
+	}
+	if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1); err != nil {
+		return fmt.Errorf("failed to set reuseport option: %w", err)
+	}
+	return nil
+}
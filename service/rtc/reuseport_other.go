@@ -0,0 +1,17 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build !linux && !freebsd
+
+package rtc
+
+// reusePortSupported is false as sockets can't share an address with the
+// kernel balancing the packets between them: SO_REUSEPORT delivers unicast
+// packets to a single socket on the other BSDs and macOS, and doesn't exist
+// on Windows (where SO_REUSEADDR lets other processes steal the port). A
+// single socket is used instead.
+const reusePortSupported = false
+
+func setReusePort(_ uintptr) error {
+	return nil
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rtpStatsPushInterval is how often the RTP counters are pushed to the
+// metrics.
+const rtpStatsPushInterval = 10 * time.Second
+
+// Indexes of the directions and track types RTP packets are counted by.
+const (
+	rtpDirectionIn = iota
+	rtpDirectionOut
+	rtpDirectionsCount
+)
+
+const (
+	rtpTrackTypeVoice = iota
+	rtpTrackTypeScreenAudio
+	rtpTrackTypeScreen
+	rtpTrackTypesCount
+)
+
+var (
+	rtpDirections = [rtpDirectionsCount]string{"in", "out"}
+	rtpTrackTypes = [rtpTrackTypesCount]string{"voice", "screen-audio", "screen"}
+)
+
+// rtpTrackTypeIndex returns the index of the given track type (e.g. "voice")
+// in the RTP counters.
+func rtpTrackTypeIndex(trackType string) int {
+	for i, typ := range rtpTrackTypes {
+		if typ == trackType {
+			return i
+		}
+	}
+	return rtpTrackTypeScreen
+}
+
+// rtpCounters holds the number of RTP packets, and payload bytes, received
+// and sent by a session. They're updated atomically from the media
+// goroutines and only read when the metrics are scraped, so that no lock is
+// ever taken for each packet.
+type rtpCounters struct {
+	packets [rtpDirectionsCount][rtpTrackTypesCount]uint64
+	bytes   [rtpDirectionsCount][rtpTrackTypesCount]uint64
+}
+
+func (c *rtpCounters) add(direction, trackType, n int) {
+	atomic.AddUint64(&c.packets[direction][trackType], 1)
+	atomic.AddUint64(&c.bytes[direction][trackType], uint64(n))
+}
+
+// addTo adds the counters to the given ones.
+func (c *rtpCounters) addTo(dst *rtpCounters) {
+	for d := 0; d < rtpDirectionsCount; d++ {
+		for t := 0; t < rtpTrackTypesCount; t++ {
+			atomic.AddUint64(&dst.packets[d][t], atomic.LoadUint64(&c.packets[d][t]))
+			atomic.AddUint64(&dst.bytes[d][t], atomic.LoadUint64(&c.bytes[d][t]))
+		}
+	}
+}
+
+// rtpStats aggregates the RTP counters of the sessions of the server.
+type rtpStats struct {
+	// closed holds the counters of the sessions that were closed.
+	closed rtpCounters
+	// last holds the values returned by the previous snapshot. A session
+	// being closed while taking a snapshot could otherwise be missed, making
+	// the counters decrease.
+	last rtpCounters
+	mut  sync.Mutex
+}
+
+// RTPStats holds the number of RTP packets, and payload bytes, received (in)
+// or sent (out) by the server for a type of track.
+type RTPStats struct {
+	Direction string
+	TrackType string
+	Packets   uint64
+	Bytes     uint64
+}
+
+// GetRTPStats returns the number of RTP packets received and sent since the
+// server started. The counters of the ongoing sessions are summed up on each
+// call, which is meant to happen on metrics scrapes only.
+func (s *Server) GetRTPStats() []RTPStats {
+	total := s.snapshotRTPStats()
+	stats := make([]RTPStats, 0, rtpDirectionsCount*rtpTrackTypesCount)
+	for d := 0; d < rtpDirectionsCount; d++ {
+		for t := 0; t < rtpTrackTypesCount; t++ {
+			stats = append(stats, RTPStats{
+				Direction: rtpDirections[d],
+				TrackType: rtpTrackTypes[t],
+				Packets:   total.packets[d][t],
+				Bytes:     total.bytes[d][t],
+			})
+		}
+	}
+	return stats
+}
+
+// snapshotRTPStats sums up the RTP counters of the server, never returning
+// lower values than the previous call.
+func (s *Server) snapshotRTPStats() rtpCounters {
+	var total rtpCounters
+	s.rtpStats.closed.addTo(&total)
+	s.iterCalls(func(_ *group, c *call) {
+		for _, ss := range c.getSessions() {
+			ss.rtpCounters.addTo(&total)
+		}
+	})
+
+	s.rtpStats.mut.Lock()
+	defer s.rtpStats.mut.Unlock()
+	last := &s.rtpStats.last
+	for d := 0; d < rtpDirectionsCount; d++ {
+		for t := 0; t < rtpTrackTypesCount; t++ {
+			if total.packets[d][t] > last.packets[d][t] {
+				last.packets[d][t] = total.packets[d][t]
+			}
+			if total.bytes[d][t] > last.bytes[d][t] {
+				last.bytes[d][t] = total.bytes[d][t]
+			}
+		}
+	}
+	return *last
+}
+
+// pushRTPStats adds the RTP traffic since the given counters, last pushed,
+// to the metrics and updates them.
+func (s *Server) pushRTPStats(pushed *rtpCounters) {
+	total := s.snapshotRTPStats()
+	for d := 0; d < rtpDirectionsCount; d++ {
+		for t := 0; t < rtpTrackTypesCount; t++ {
+			if n := total.packets[d][t] - pushed.packets[d][t]; n > 0 {
+				s.metrics.AddRTPPackets(rtpDirections[d], rtpTrackTypes[t], int(n))
+			}
+			if n := total.bytes[d][t] - pushed.bytes[d][t]; n > 0 {
+				s.metrics.AddRTPPacketBytes(rtpDirections[d], rtpTrackTypes[t], int(n))
+			}
+		}
+	}
+	*pushed = total
+}
+
+// rtpStatsPusher periodically pushes the RTP counters to the metrics, for
+// the backends which can't compute them on scrape, and a last time on stop.
+func (s *Server) rtpStatsPusher(stopCh <-chan struct{}, doneCh chan<- struct{}) {
+	defer close(doneCh)
+
+	var pushed rtpCounters
+	ticker := time.NewTicker(rtpStatsPushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.pushRTPStats(&pushed)
+		case <-stopCh:
+			s.pushRTPStats(&pushed)
+			return
+		}
+	}
+}
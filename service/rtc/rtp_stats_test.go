@@ -0,0 +1,109 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRTPStats(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	getStats := func(direction, trackType string) RTPStats {
+		t.Helper()
+		for _, s := range server.GetRTPStats() {
+			if s.Direction == direction && s.TrackType == trackType {
+				return s
+			}
+		}
+		require.Fail(t, "stats not found", direction, trackType)
+		return RTPStats{}
+	}
+
+	require.Len(t, server.GetRTPStats(), rtpDirectionsCount*rtpTrackTypesCount)
+	require.Equal(t, RTPStats{Direction: "in", TrackType: "voice"}, getStats("in", "voice"))
+
+	cfg := SessionConfig{
+		GroupID:   "groupA",
+		CallID:    "callA",
+		UserID:    "userA",
+		SessionID: "sessionA",
+	}
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	usA, err := server.addSession(cfg, peerConn, nil)
+	require.NoError(t, err)
+
+	cfg.UserID = "userB"
+	cfg.SessionID = "sessionB"
+	usB, err := server.addSession(cfg, peerConn, nil)
+	require.NoError(t, err)
+
+	usA.rtpCounters.add(rtpDirectionIn, rtpTrackTypeIndex("voice"), 100)
+	usA.rtpCounters.add(rtpDirectionOut, rtpTrackTypeIndex("voice"), 100)
+	usB.rtpCounters.add(rtpDirectionIn, rtpTrackTypeIndex("screen"), 1000)
+	usB.rtpCounters.add(rtpDirectionIn, rtpTrackTypeIndex("screen"), 500)
+
+	require.Equal(t, RTPStats{Direction: "in", TrackType: "voice", Packets: 1, Bytes: 100}, getStats("in", "voice"))
+	require.Equal(t, RTPStats{Direction: "out", TrackType: "voice", Packets: 1, Bytes: 100}, getStats("out", "voice"))
+	require.Equal(t, RTPStats{Direction: "in", TrackType: "screen", Packets: 2, Bytes: 1500}, getStats("in", "screen"))
+
+	t.Run("closed sessions", func(t *testing.T) {
+		require.NoError(t, server.CloseSession("sessionA"))
+		require.Equal(t, RTPStats{Direction: "in", TrackType: "voice", Packets: 1, Bytes: 100}, getStats("in", "voice"))
+
+		require.NoError(t, server.CloseSession("sessionB"))
+		require.Equal(t, RTPStats{Direction: "in", TrackType: "screen", Packets: 2, Bytes: 1500}, getStats("in", "screen"))
+	})
+
+	t.Run("never decreasing", func(t *testing.T) {
+		// A session closed while taking a snapshot could be missed.
+		server.rtpStats.closed = rtpCounters{}
+		require.Equal(t, RTPStats{Direction: "in", TrackType: "screen", Packets: 2, Bytes: 1500}, getStats("in", "screen"))
+	})
+}
+
+// rtpMetrics records the RTP counters pushed to the metrics.
+type rtpMetrics struct {
+	Metrics
+	packets map[string]int
+	bytes   map[string]int
+}
+
+func (m *rtpMetrics) AddRTPPackets(direction, trackType string, value int) {
+	m.packets[direction+"/"+trackType] += value
+}
+
+func (m *rtpMetrics) AddRTPPacketBytes(direction, trackType string, value int) {
+	m.bytes[direction+"/"+trackType] += value
+}
+
+func TestPushRTPStats(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	metrics := &rtpMetrics{Metrics: server.metrics, packets: map[string]int{}, bytes: map[string]int{}}
+	server.metrics = metrics
+
+	var pushed rtpCounters
+	server.pushRTPStats(&pushed)
+	require.Empty(t, metrics.packets)
+	require.Empty(t, metrics.bytes)
+
+	server.rtpStats.closed.add(rtpDirectionIn, rtpTrackTypeIndex("voice"), 100)
+	server.rtpStats.closed.add(rtpDirectionOut, rtpTrackTypeIndex("screen"), 1000)
+	server.pushRTPStats(&pushed)
+	require.Equal(t, map[string]int{"in/voice": 1, "out/screen": 1}, metrics.packets)
+	require.Equal(t, map[string]int{"in/voice": 100, "out/screen": 1000}, metrics.bytes)
+
+	// Only the traffic since the previous push is added.
+	server.rtpStats.closed.add(rtpDirectionIn, rtpTrackTypeIndex("voice"), 50)
+	server.pushRTPStats(&pushed)
+	require.Equal(t, map[string]int{"in/voice": 2, "out/screen": 1}, metrics.packets)
+	require.Equal(t, map[string]int{"in/voice": 150, "out/screen": 1000}, metrics.bytes)
+}
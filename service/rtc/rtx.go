@@ -0,0 +1,175 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+)
+
+const (
+	rtxMimeType = "video/rtx"
+	// rtxQueueSize is the number of repaired packets that can be waiting to
+	// be read on a stream.
+	rtxQueueSize = 128
+
+	rtxResultRepaired = "repaired"
+	rtxResultDropped  = "dropped"
+)
+
+// rtxInterceptor turns the packets received on RTX streams (RFC 4588) back
+// into the packets they retransmit and hands them to the stream they
+// repair, so that they get forwarded as any other packet.
+type rtxInterceptor struct {
+	interceptor.NoOp
+
+	payloadType uint8
+	apt         uint8
+	onPacket    func(ssrc uint32, result string)
+
+	// ssrcs maps RTX SSRCs to the SSRCs of the streams they repair.
+	ssrcs map[uint32]uint32
+	// queues holds the repaired packets waiting to be read, keyed by SSRC.
+	queues map[uint32]chan *rtp.Packet
+	mut    sync.RWMutex
+}
+
+func newRTXInterceptor(payloadType, apt uint8, onPacket func(ssrc uint32, result string)) *rtxInterceptor {
+	return &rtxInterceptor{
+		payloadType: payloadType,
+		apt:         apt,
+		onPacket:    onPacket,
+		ssrcs:       map[uint32]uint32{},
+		queues:      map[uint32]chan *rtp.Packet{},
+	}
+}
+
+// NewInterceptor implements interceptor.Factory. The same instance is
+// returned since a new one is created for each peer connection.
+func (i *rtxInterceptor) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return i, nil
+}
+
+func (i *rtxInterceptor) setSSRCs(ssrcs map[uint32]uint32) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	for rtxSSRC, ssrc := range ssrcs {
+		i.ssrcs[rtxSSRC] = ssrc
+	}
+}
+
+// BindRemoteStream consumes the RTX packets read from the stream and returns
+// any repaired packet pending for it before reading new ones.
+func (i *rtxInterceptor) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	queue := make(chan *rtp.Packet, rtxQueueSize)
+	i.mut.Lock()
+	i.queues[info.SSRC] = queue
+	i.mut.Unlock()
+
+	return interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		for {
+			select {
+			case pkt := <-queue:
+				n, err := pkt.MarshalTo(b)
+				return n, a, err
+			default:
+			}
+
+			n, attr, err := reader.Read(b, a)
+			if err != nil {
+				return n, attr, err
+			}
+
+			var header rtp.Header
+			if _, err := header.Unmarshal(b[:n]); err != nil || header.PayloadType != i.payloadType {
+				return n, attr, nil
+			}
+
+			i.repair(b[:n])
+		}
+	})
+}
+
+func (i *rtxInterceptor) UnbindRemoteStream(info *interceptor.StreamInfo) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	delete(i.queues, info.SSRC)
+	delete(i.ssrcs, info.SSRC)
+}
+
+// repair restores the original packet carried by the given RTX packet and
+// queues it on the stream it belongs to.
+func (i *rtxInterceptor) repair(buf []byte) {
+	var pkt rtp.Packet
+	// The packet may outlive the buffer it was read into.
+	if err := pkt.Unmarshal(append([]byte(nil), buf...)); err != nil {
+		return
+	}
+
+	// Payloads shorter than the original sequence number are padding only,
+	// usually sent for bandwidth probing.
+	if len(pkt.Payload) < 2 {
+		return
+	}
+
+	i.mut.RLock()
+	ssrc, ok := i.ssrcs[pkt.SSRC]
+	queue := i.queues[ssrc]
+	i.mut.RUnlock()
+	if !ok || queue == nil {
+		i.notify(pkt.SSRC, rtxResultDropped)
+		return
+	}
+
+	pkt.SequenceNumber = binary.BigEndian.Uint16(pkt.Payload)
+	pkt.Payload = pkt.Payload[2:]
+	pkt.SSRC = ssrc
+	pkt.PayloadType = i.apt
+	pkt.Padding = false
+	pkt.PaddingSize = 0
+
+	select {
+	case queue <- &pkt:
+		i.notify(ssrc, rtxResultRepaired)
+	default:
+		i.notify(ssrc, rtxResultDropped)
+	}
+}
+
+func (i *rtxInterceptor) notify(ssrc uint32, result string) {
+	if i.onPacket != nil {
+		i.onPacket(ssrc, result)
+	}
+}
+
+// parseRTXSSRCs returns the RTX SSRCs declared through FID groups in the
+// given SDP, mapped to the SSRCs of the streams they repair.
+func parseRTXSSRCs(sdp string) map[uint32]uint32 {
+	ssrcs := map[uint32]uint32{}
+	for _, line := range strings.Split(sdp, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "a=ssrc-group:FID ") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "a=ssrc-group:FID "))
+		if len(fields) != 2 {
+			continue
+		}
+		ssrc, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil {
+			continue
+		}
+		rtxSSRC, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			continue
+		}
+		ssrcs[uint32(rtxSSRC)] = uint32(ssrc)
+	}
+	return ssrcs
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"io"
+	"testing"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRTXSSRCs(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		require.Empty(t, parseRTXSSRCs(""))
+	})
+
+	t.Run("FID groups", func(t *testing.T) {
+		sdp := "v=0\r\n" +
+			"m=video 9 UDP/TLS/RTP/SAVPF 96 97\r\n" +
+			"a=ssrc-group:FID 1000 2000\r\n" +
+			"a=ssrc-group:SIM 1000 1001\r\n" +
+			"a=ssrc-group:FID 3000\r\n" +
+			"a=ssrc-group:FID 4000 invalid\r\n" +
+			"a=ssrc:1000 cname:test\r\n"
+		require.Equal(t, map[uint32]uint32{2000: 1000}, parseRTXSSRCs(sdp))
+	})
+}
+
+func TestRTXInterceptor(t *testing.T) {
+	marshal := func(t *testing.T, pkt rtp.Packet) []byte {
+		t.Helper()
+		data, err := pkt.Marshal()
+		require.NoError(t, err)
+		return data
+	}
+
+	newReader := func(pkts ...[]byte) interceptor.RTPReader {
+		return interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+			if len(pkts) == 0 {
+				return 0, a, io.EOF
+			}
+			n := copy(b, pkts[0])
+			pkts = pkts[1:]
+			return n, a, nil
+		})
+	}
+
+	results := map[string]int{}
+	rtx := newRTXInterceptor(97, 96, func(ssrc uint32, result string) {
+		require.Equal(t, uint32(1000), ssrc)
+		results[result]++
+	})
+	rtx.setSSRCs(map[uint32]uint32{2000: 1000})
+
+	primary := rtx.BindRemoteStream(&interceptor.StreamInfo{SSRC: 1000}, newReader(
+		marshal(t, rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 10, SSRC: 1000, Timestamp: 45000},
+			Payload: []byte{0x01, 0x02},
+		}),
+	))
+
+	repair := rtx.BindRemoteStream(&interceptor.StreamInfo{SSRC: 2000}, newReader(
+		// Padding only.
+		marshal(t, rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: 97, SequenceNumber: 1, SSRC: 2000, Timestamp: 45000},
+			Payload: []byte{0x00},
+		}),
+		marshal(t, rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: 97, SequenceNumber: 2, SSRC: 2000, Timestamp: 42000, Marker: true},
+			Payload: []byte{0x00, 0x09, 0x03, 0x04},
+		}),
+	))
+
+	// RTX packets are consumed by the repair stream.
+	buf := make([]byte, 1500)
+	_, _, err := repair.Read(buf, nil)
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, map[string]int{rtxResultRepaired: 1}, results)
+
+	// The repaired packet is read first on the primary stream.
+	var pkt rtp.Packet
+	n, _, err := primary.Read(buf, nil)
+	require.NoError(t, err)
+	require.NoError(t, pkt.Unmarshal(buf[:n]))
+	require.Equal(t, uint32(1000), pkt.SSRC)
+	require.Equal(t, uint8(96), pkt.PayloadType)
+	require.Equal(t, uint16(9), pkt.SequenceNumber)
+	require.Equal(t, uint32(42000), pkt.Timestamp)
+	require.True(t, pkt.Marker)
+	require.Equal(t, []byte{0x03, 0x04}, pkt.Payload)
+
+	n, _, err = primary.Read(buf, nil)
+	require.NoError(t, err)
+	require.NoError(t, pkt.Unmarshal(buf[:n]))
+	require.Equal(t, uint16(10), pkt.SequenceNumber)
+	require.Equal(t, []byte{0x01, 0x02}, pkt.Payload)
+
+	t.Run("unknown stream", func(t *testing.T) {
+		unknown := rtx.BindRemoteStream(&interceptor.StreamInfo{SSRC: 3000}, newReader(
+			marshal(t, rtp.Packet{
+				Header:  rtp.Header{Version: 2, PayloadType: 97, SequenceNumber: 1, SSRC: 3000},
+				Payload: []byte{0x00, 0x01, 0x02},
+			}),
+		))
+		results = map[string]int{}
+		rtx.onPacket = func(ssrc uint32, result string) {
+			require.Equal(t, uint32(3000), ssrc)
+			results[result]++
+		}
+		_, _, err := unknown.Read(buf, nil)
+		require.Equal(t, io.EOF, err)
+		require.Equal(t, map[string]int{rtxResultDropped: 1}, results)
+	})
+}
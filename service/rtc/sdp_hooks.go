@@ -0,0 +1,45 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// SDPHook lets embedders inspect and modify the session descriptions
+// exchanged with a session. Local is true for the descriptions generated by
+// the server, which are modified before being sent to the client (the
+// peer connection doesn't allow changing them once created), and false for
+// the ones received from the client, which are modified before being
+// applied. The returned description replaces the given one, an error aborts
+// the negotiation.
+type SDPHook func(cfg SessionConfig, local bool, desc webrtc.SessionDescription) (webrtc.SessionDescription, error)
+
+// AddSDPHook registers a hook called for every session description of the
+// sessions initialized afterwards. Hooks are chained in registration order.
+func (s *Server) AddSDPHook(hook SDPHook) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.sdpHooks = append(s.sdpHooks, hook)
+}
+
+func (s *Server) getSDPHooks() []SDPHook {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.sdpHooks
+}
+
+// applySDPHooks runs the hooks registered when the session was initialized.
+func (s *session) applySDPHooks(local bool, desc webrtc.SessionDescription) (webrtc.SessionDescription, error) {
+	for i, hook := range s.sdpHooks {
+		var err error
+		desc, err = hook(s.cfg, local, desc)
+		if err != nil {
+			return desc, fmt.Errorf("sdp hook %d failed: %w", i, err)
+		}
+	}
+	return desc, nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSDPHooks(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	cfg := SessionConfig{
+		GroupID:   "test",
+		CallID:    "test",
+		UserID:    "test",
+		SessionID: "sessionA",
+	}
+
+	var calls []string
+	server.AddSDPHook(func(cfg SessionConfig, local bool, desc webrtc.SessionDescription) (webrtc.SessionDescription, error) {
+		calls = append(calls, fmt.Sprintf("first %s %t %s", cfg.SessionID, local, desc.Type))
+		if local {
+			desc.SDP = strings.Replace(desc.SDP, "a=group:BUNDLE", "a=x-custom:1\r\na=group:BUNDLE", 1)
+		}
+		return desc, nil
+	})
+	server.AddSDPHook(func(_ SessionConfig, local bool, desc webrtc.SessionDescription) (webrtc.SessionDescription, error) {
+		calls = append(calls, fmt.Sprintf("second %t %s", local, desc.Type))
+		if local {
+			require.Contains(t, desc.SDP, "a=x-custom:1")
+		}
+		return desc, nil
+	})
+
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer peerConn.Close()
+	us, err := server.addSession(cfg, peerConn, nil)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, server.CloseSession("sessionA"))
+	}()
+	us.sdpHooks = server.getSDPHooks()
+
+	offerer, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer offerer.Close()
+	track, err := webrtc.NewTrackLocalStaticRTP(rtpAudioCodec, "voice", "stream")
+	require.NoError(t, err)
+	_, err = offerer.AddTrack(track)
+	require.NoError(t, err)
+	offer, err := offerer.CreateOffer(nil)
+	require.NoError(t, err)
+
+	t.Run("applied in order", func(t *testing.T) {
+		ch := make(chan Message, 1)
+		require.NoError(t, us.signaling(offer, ch))
+		require.Equal(t, []string{
+			"first sessionA false offer",
+			"second false offer",
+			"first sessionA true answer",
+			"second true answer",
+		}, calls)
+
+		msg := <-ch
+		var answer webrtc.SessionDescription
+		require.NoError(t, json.Unmarshal(msg.Data, &answer))
+		require.Contains(t, answer.SDP, "a=x-custom:1")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		us.sdpHooks = append(us.sdpHooks, func(_ SessionConfig, _ bool, desc webrtc.SessionDescription) (webrtc.SessionDescription, error) {
+			return desc, fmt.Errorf("rejected")
+		})
+		_, err := us.applySDPHooks(false, offer)
+		require.EqualError(t, err, "sdp hook 2 failed: rejected")
+	})
+}
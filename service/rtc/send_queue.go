@@ -0,0 +1,411 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/pion/interceptor"
+)
+
+const (
+	// sendQuantum is the number of bytes a queue is allowed to send at each
+	// round of the scheduler, that of a full sized packet.
+	sendQuantum = 1500
+	// sendBufSize is the size of the pooled buffers queued packets are
+	// copied to. Larger packets get a buffer of their own.
+	sendBufSize = 1500
+	// defaultSendFlow is the key of the queue of the packets sent to
+	// addresses no call is known for (e.g. ICE checks before a candidate
+	// pair is selected).
+	defaultSendFlow = ""
+)
+
+// Lanes of the send queues. The priority lane, holding audio and control
+// packets (RTCP, ICE, DTLS), is always served ahead of the video one since
+// audio continuity matters far more than video during congestion.
+const (
+	sendLanePriority = iota
+	sendLaneVideo
+	numSendLanes
+)
+
+// sendLaneNames are the names of the lanes, as reported in metrics.
+var sendLaneNames = [numSendLanes]string{"priority", "video"}
+
+// sendAddrKey identifies a remote UDP address without allocating.
+type sendAddrKey struct {
+	ip   [16]byte
+	port int
+}
+
+func newSendAddrKey(ip net.IP, port int) (sendAddrKey, bool) {
+	var key sendAddrKey
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return key, false
+	}
+	copy(key.ip[:], ip16)
+	key.port = port
+	return key, true
+}
+
+func sendAddrKeyOf(addr net.Addr) (sendAddrKey, bool) {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return sendAddrKey{}, false
+	}
+	return newSendAddrKey(udpAddr.IP, udpAddr.Port)
+}
+
+type queuedPacket struct {
+	data []byte
+	addr net.Addr
+}
+
+// sendQueue holds the packets waiting to be sent for a flow (a call).
+type sendQueue struct {
+	key     string
+	packets []queuedPacket
+	// deficit is the number of bytes the queue can still send in the
+	// current round.
+	deficit int
+	active  bool
+	// busy is set while a writer sends a packet of the queue, the others
+	// skipping it so that its packets are sent in order.
+	busy bool
+}
+
+// sendLane holds the queues of a lane, one per call.
+type sendLane struct {
+	queues map[string]*sendQueue
+	// active lists the queues having packets to send, in round order.
+	active []*sendQueue
+}
+
+// sendScheduler queues the packets sent on the media conn per call and
+// writes them in deficit round robin order, so that a call sending bursts
+// doesn't delay the packets of the other calls when the sockets can't keep
+// up. Each call has a queue in each lane, the priority lane being drained
+// first. Packets sent while their queue is full are dropped.
+type sendScheduler struct {
+	conn      net.PacketConn
+	queueSize int
+	onDrop    func(lane string)
+
+	lanes [numSendLanes]sendLane
+	// flows maps the remote address of each session to the key of its
+	// call, and sessions to their address.
+	flows        map[sendAddrKey]string
+	sessionAddrs map[string]sendAddrKey
+	// videoSSRCs counts the video streams sent with each SSRC, the packets
+	// of which go to the video lane.
+	videoSSRCs map[uint32]int
+	bufPool    sync.Pool
+	closed     bool
+
+	// readyCh is signaled whenever packets get queued.
+	readyCh chan struct{}
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+	mut     sync.Mutex
+}
+
+// newSendScheduler returns a scheduler writing to conn, once started.
+func newSendScheduler(conn net.PacketConn, queueSize int, onDrop func(lane string)) *sendScheduler {
+	s := &sendScheduler{
+		conn:         conn,
+		queueSize:    queueSize,
+		onDrop:       onDrop,
+		flows:        map[sendAddrKey]string{},
+		sessionAddrs: map[string]sendAddrKey{},
+		videoSSRCs:   map[uint32]int{},
+		readyCh:      make(chan struct{}, 1),
+		closeCh:      make(chan struct{}),
+	}
+	for i := range s.lanes {
+		s.lanes[i].queues = map[string]*sendQueue{}
+	}
+	s.bufPool.New = func() interface{} {
+		return make([]byte, sendBufSize)
+	}
+	return s
+}
+
+// start spawns the given number of writers.
+func (s *sendScheduler) start(writers int) {
+	if writers < 1 {
+		writers = 1
+	}
+	s.wg.Add(writers)
+	for i := 0; i < writers; i++ {
+		go s.writer()
+	}
+}
+
+// setFlow assigns the packets sent to the given address to the queue of the
+// call of the given session.
+func (s *sendScheduler) setFlow(sessionID string, ip net.IP, port int, callKey string) {
+	key, ok := newSendAddrKey(ip, port)
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.removeFlowLocked(sessionID)
+	if !ok {
+		return
+	}
+	s.flows[key] = callKey
+	s.sessionAddrs[sessionID] = key
+}
+
+// removeFlow forgets the address of the given session.
+func (s *sendScheduler) removeFlow(sessionID string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.removeFlowLocked(sessionID)
+}
+
+func (s *sendScheduler) removeFlowLocked(sessionID string) {
+	if key, ok := s.sessionAddrs[sessionID]; ok {
+		delete(s.flows, key)
+		delete(s.sessionAddrs, sessionID)
+	}
+}
+
+func (s *sendScheduler) addVideoSSRC(ssrc uint32) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.videoSSRCs[ssrc]++
+}
+
+func (s *sendScheduler) removeVideoSSRC(ssrc uint32) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.videoSSRCs[ssrc]--; s.videoSSRCs[ssrc] <= 0 {
+		delete(s.videoSSRCs, ssrc)
+	}
+}
+
+// laneLocked returns the lane the given packet should be queued to: RTP
+// packets of video streams go to the video lane, all the others (audio, RTCP,
+// STUN, DTLS) to the priority one. SRTP leaves the RTP header in the clear.
+func (s *sendScheduler) laneLocked(p []byte) int {
+	if len(p) < 12 || p[0]>>6 != 2 {
+		return sendLanePriority
+	}
+	// RTCP packet types, as multiplexed with RTP (RFC 5761).
+	if pt := p[1] & 0x7f; pt >= 64 && pt <= 95 {
+		return sendLanePriority
+	}
+	if s.videoSSRCs[binary.BigEndian.Uint32(p[8:12])] > 0 {
+		return sendLaneVideo
+	}
+	return sendLanePriority
+}
+
+// enqueue queues a copy of p to be sent to addr, returning false if it got
+// dropped.
+func (s *sendScheduler) enqueue(p []byte, addr net.Addr) bool {
+	var data []byte
+	if len(p) <= sendBufSize {
+		data = s.bufPool.Get().([]byte)[:len(p)]
+	} else {
+		data = make([]byte, len(p))
+	}
+	copy(data, p)
+
+	s.mut.Lock()
+	if s.closed {
+		s.mut.Unlock()
+		return false
+	}
+	flow := defaultSendFlow
+	if key, ok := sendAddrKeyOf(addr); ok {
+		if callKey, ok := s.flows[key]; ok {
+			flow = callKey
+		}
+	}
+	laneIdx := s.laneLocked(data)
+	lane := &s.lanes[laneIdx]
+	q := lane.queues[flow]
+	if q == nil {
+		q = &sendQueue{key: flow}
+		lane.queues[flow] = q
+	}
+	if len(q.packets) >= s.queueSize {
+		s.mut.Unlock()
+		s.putBuf(data)
+		if s.onDrop != nil {
+			s.onDrop(sendLaneNames[laneIdx])
+		}
+		return false
+	}
+	q.packets = append(q.packets, queuedPacket{data: data, addr: addr})
+	if !q.active {
+		q.active = true
+		lane.active = append(lane.active, q)
+	}
+	s.mut.Unlock()
+
+	s.signal()
+	return true
+}
+
+// signal wakes up a writer, unless one is already about to.
+func (s *sendScheduler) signal() {
+	select {
+	case s.readyCh <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the next packet to send, if any, the priority lane being
+// served first, along with its queue, to be released once the packet is
+// sent. more is set if packets are left to send.
+func (s *sendScheduler) next() (pkt queuedPacket, q *sendQueue, more bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	for i := range s.lanes {
+		if pkt, q = s.lanes[i].next(); q != nil {
+			q.busy = true
+			break
+		}
+	}
+	for i := range s.lanes {
+		if len(s.lanes[i].active) > 0 {
+			more = true
+		}
+	}
+	return pkt, q, more
+}
+
+// release marks the queue of a sent packet as no longer busy.
+func (s *sendScheduler) release(q *sendQueue) {
+	s.mut.Lock()
+	q.busy = false
+	more := len(q.packets) > 0
+	s.mut.Unlock()
+	if more {
+		s.signal()
+	}
+}
+
+// next returns the next packet to send from the lane, if any, along with
+// its queue, in deficit round robin order: each queue sends up to
+// sendQuantum bytes per round, the unused part carrying over to the next
+// round while it has packets. Busy queues are skipped.
+func (l *sendLane) next() (queuedPacket, *sendQueue) {
+	for i := 0; i < len(l.active); {
+		q := l.active[i]
+		if q.busy {
+			i++
+			continue
+		}
+		if len(q.packets) == 0 {
+			q.active = false
+			q.deficit = 0
+			l.active = append(l.active[:i], l.active[i+1:]...)
+			// Queues of ended calls aren't kept around.
+			delete(l.queues, q.key)
+			continue
+		}
+		pkt := q.packets[0]
+		if q.deficit < len(pkt.data) {
+			q.deficit += sendQuantum
+			l.active = append(append(l.active[:i], l.active[i+1:]...), q)
+			continue
+		}
+		q.deficit -= len(pkt.data)
+		q.packets[0] = queuedPacket{}
+		q.packets = q.packets[1:]
+		return pkt, q
+	}
+	return queuedPacket{}, nil
+}
+
+func (s *sendScheduler) writer() {
+	defer s.wg.Done()
+	for {
+		for {
+			pkt, q, more := s.next()
+			if q == nil {
+				break
+			}
+			if more {
+				// Waking up another writer to share the load.
+				s.signal()
+			}
+			// Errors are accounted for by the conn, UDP being lossy anyway.
+			_, _ = s.conn.WriteTo(pkt.data, pkt.addr)
+			s.putBuf(pkt.data)
+			s.release(q)
+		}
+		select {
+		case <-s.readyCh:
+		case <-s.closeCh:
+			return
+		}
+	}
+}
+
+func (s *sendScheduler) putBuf(data []byte) {
+	if cap(data) == sendBufSize {
+		s.bufPool.Put(data[:sendBufSize])
+	}
+}
+
+// close stops the writers, dropping the packets left in the queues.
+func (s *sendScheduler) close() {
+	s.mut.Lock()
+	if s.closed {
+		s.mut.Unlock()
+		return
+	}
+	s.closed = true
+	s.mut.Unlock()
+	close(s.closeCh)
+	s.wg.Wait()
+}
+
+// fairConn is the view of a media conn whose writes go through a
+// sendScheduler.
+type fairConn struct {
+	net.PacketConn
+	sched *sendScheduler
+}
+
+func (c *fairConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	// Dropped packets are reported as sent, as a congested network would.
+	c.sched.enqueue(p, addr)
+	return len(p), nil
+}
+
+// sendLaneInterceptor registers the SSRCs of the video streams sent by a
+// session with the sendScheduler, so that their packets go to the video lane.
+type sendLaneInterceptor struct {
+	interceptor.NoOp
+
+	sched *sendScheduler
+}
+
+// NewInterceptor implements interceptor.Factory. The same instance is
+// returned since a new one is created for each peer connection.
+func (i *sendLaneInterceptor) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return i, nil
+}
+
+func (i *sendLaneInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	if strings.HasPrefix(info.MimeType, "video/") {
+		i.sched.addVideoSSRC(info.SSRC)
+	}
+	return writer
+}
+
+func (i *sendLaneInterceptor) UnbindLocalStream(info *interceptor.StreamInfo) {
+	if strings.HasPrefix(info.MimeType, "video/") {
+		i.sched.removeVideoSSRC(info.SSRC)
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingConn records the packets written to it.
+type recordingConn struct {
+	net.PacketConn
+	written [][]byte
+	mut     sync.Mutex
+}
+
+func (c *recordingConn) WriteTo(p []byte, _ net.Addr) (int, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.written = append(c.written, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func (c *recordingConn) count() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return len(c.written)
+}
+
+func TestSendScheduler(t *testing.T) {
+	addrA := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1000}
+	addrB := &net.UDPAddr{IP: net.ParseIP("10.0.0.2"), Port: 1000}
+
+	// sendNext returns the next packet as a writer would send it.
+	sendNext := func(s *sendScheduler) (queuedPacket, bool, bool) {
+		pkt, q, more := s.next()
+		if q == nil {
+			return pkt, false, more
+		}
+		s.release(q)
+		return pkt, true, more
+	}
+
+	t.Run("fairness", func(t *testing.T) {
+		s := newSendScheduler(nil, 100, nil)
+		s.setFlow("sessionA", addrA.IP, addrA.Port, "groupID/callA")
+		s.setFlow("sessionB", addrB.IP, addrB.Port, "groupID/callB")
+
+		// A burst on call A doesn't delay call B by more than a round.
+		for i := 0; i < 10; i++ {
+			require.True(t, s.enqueue(make([]byte, 1000), addrA))
+		}
+		for i := 0; i < 2; i++ {
+			require.True(t, s.enqueue(make([]byte, 1000), addrB))
+		}
+
+		var order []net.Addr
+		for {
+			pkt, ok, _ := sendNext(s)
+			if !ok {
+				break
+			}
+			order = append(order, pkt.addr)
+		}
+		require.Len(t, order, 12)
+		require.Equal(t, []net.Addr{addrA, addrB, addrA, addrA, addrB}, order[:5])
+		require.Empty(t, s.lanes[sendLanePriority].queues)
+	})
+
+	t.Run("flows", func(t *testing.T) {
+		s := newSendScheduler(nil, 100, nil)
+		s.setFlow("sessionA", addrA.IP, addrA.Port, "groupID/callA")
+		require.True(t, s.enqueue([]byte("a"), addrA))
+		require.True(t, s.enqueue([]byte("b"), addrB))
+		require.Len(t, s.lanes[sendLanePriority].queues["groupID/callA"].packets, 1)
+		require.Len(t, s.lanes[sendLanePriority].queues[defaultSendFlow].packets, 1)
+
+		s.removeFlow("sessionA")
+		require.Empty(t, s.flows)
+		require.True(t, s.enqueue([]byte("c"), addrA))
+		require.Len(t, s.lanes[sendLanePriority].queues[defaultSendFlow].packets, 2)
+
+		// Unparsable addresses (e.g. mDNS hostnames) aren't mapped.
+		s.setFlow("sessionA", net.ParseIP("host.local"), 1000, "groupID/callA")
+		require.Empty(t, s.flows)
+	})
+
+	t.Run("full queue", func(t *testing.T) {
+		var drops []string
+		s := newSendScheduler(nil, 2, func(lane string) { drops = append(drops, lane) })
+		require.True(t, s.enqueue([]byte("a"), addrA))
+		require.True(t, s.enqueue([]byte("b"), addrA))
+		require.False(t, s.enqueue([]byte("c"), addrA))
+		require.Equal(t, []string{"priority"}, drops)
+	})
+
+	t.Run("lanes", func(t *testing.T) {
+		s := newSendScheduler(nil, 100, nil)
+		lanes := &sendLaneInterceptor{sched: s}
+		lanes.BindLocalStream(&interceptor.StreamInfo{SSRC: 1, MimeType: "video/VP8"}, nil)
+		lanes.BindLocalStream(&interceptor.StreamInfo{SSRC: 2, MimeType: "audio/opus"}, nil)
+
+		newPacket := func(ssrc uint32, pt uint8) []byte {
+			data, err := (&rtp.Packet{Header: rtp.Header{Version: 2, PayloadType: pt, SSRC: ssrc}, Payload: make([]byte, 100)}).Marshal()
+			require.NoError(t, err)
+			return data
+		}
+		video := newPacket(1, 96)
+		audio := newPacket(2, 111)
+		rtcpData, err := rtcp.Marshal([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: 1}})
+		require.NoError(t, err)
+
+		// Video queued first still gets sent after audio and RTCP.
+		for i := 0; i < 5; i++ {
+			require.True(t, s.enqueue(video, addrA))
+		}
+		require.True(t, s.enqueue(audio, addrB))
+		require.True(t, s.enqueue(rtcpData, addrA))
+
+		pkt, ok, more := sendNext(s)
+		require.True(t, ok)
+		require.True(t, more)
+		require.Equal(t, audio, pkt.data)
+		pkt, _, _ = sendNext(s)
+		require.Equal(t, rtcpData, pkt.data)
+		for i := 0; i < 5; i++ {
+			pkt, ok, _ = sendNext(s)
+			require.True(t, ok)
+			require.Equal(t, video, pkt.data)
+		}
+		_, ok, more = sendNext(s)
+		require.False(t, ok)
+		require.False(t, more)
+
+		// Once the stream is gone, its packets aren't told apart anymore.
+		lanes.UnbindLocalStream(&interceptor.StreamInfo{SSRC: 1, MimeType: "video/VP8"})
+		require.Empty(t, s.videoSSRCs)
+		require.True(t, s.enqueue(video, addrA))
+		require.Len(t, s.lanes[sendLanePriority].queues[defaultSendFlow].packets, 1)
+	})
+
+	t.Run("busy queue", func(t *testing.T) {
+		s := newSendScheduler(nil, 100, nil)
+		require.True(t, s.enqueue([]byte("a1"), addrA))
+		require.True(t, s.enqueue([]byte("a2"), addrA))
+		s.setFlow("sessionB", addrB.IP, addrB.Port, "groupID/callB")
+		require.True(t, s.enqueue([]byte("b1"), addrB))
+
+		pkt, qA, _ := s.next()
+		require.Equal(t, []byte("a1"), pkt.data)
+
+		// The queue of call A is skipped while its first packet is sent.
+		pkt, qB, more := s.next()
+		require.Equal(t, []byte("b1"), pkt.data)
+		require.True(t, more)
+		s.release(qB)
+		_, q, _ := s.next()
+		require.Nil(t, q)
+
+		s.release(qA)
+		pkt, q, _ = s.next()
+		require.Equal(t, []byte("a2"), pkt.data)
+		require.Equal(t, qA, q)
+	})
+
+	t.Run("ordering", func(t *testing.T) {
+		conn := &recordingConn{}
+		s := newSendScheduler(conn, 1000, nil)
+		s.start(4)
+		defer s.close()
+
+		for i := 0; i < 1000; i++ {
+			require.True(t, s.enqueue([]byte{byte(i >> 8), byte(i)}, addrA))
+		}
+		require.Eventually(t, func() bool {
+			return conn.count() == 1000
+		}, 5*time.Second, 10*time.Millisecond)
+
+		conn.mut.Lock()
+		defer conn.mut.Unlock()
+		for i, p := range conn.written {
+			require.Equal(t, []byte{byte(i >> 8), byte(i)}, p)
+		}
+	})
+
+	t.Run("writers", func(t *testing.T) {
+		conn := &recordingConn{}
+		s := newSendScheduler(conn, 100, nil)
+		s.start(1)
+
+		fc := &fairConn{sched: s}
+		buf := make([]byte, 2000)
+		for i := 0; i < 10; i++ {
+			buf[0] = byte(i)
+			n, err := fc.WriteTo(buf[:100*(i+1)], addrA)
+			require.NoError(t, err)
+			require.Equal(t, 100*(i+1), n)
+		}
+		require.Eventually(t, func() bool {
+			return conn.count() == 10
+		}, time.Second, 10*time.Millisecond)
+
+		// Packets were copied, the caller reusing its buffer.
+		conn.mut.Lock()
+		for i, p := range conn.written {
+			require.Len(t, p, 100*(i+1))
+			require.Equal(t, byte(i), p[0])
+		}
+		conn.mut.Unlock()
+
+		s.close()
+		require.False(t, s.enqueue([]byte("a"), addrA))
+	})
+}
@@ -5,29 +5,35 @@ package rtc
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"net"
 	"runtime"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
-	"golang.org/x/sys/unix"
+	"github.com/mattermost/rtcd/service/crash"
 
 	"github.com/pion/ice/v2"
+	"github.com/pion/transport/vnet"
 	"github.com/pion/webrtc/v3"
 
 	"github.com/mattermost/mattermost-server/v6/shared/mlog"
 )
 
 const (
-	udpSocketBufferSize = 1024 * 1024 * 16 // 16MB
-	msgChSize           = 256
-	signalingTimeout    = 10 * time.Second
+	msgChSize        = 256
+	signalingTimeout = 10 * time.Second
 )
 
 type Server struct {
+	// rtpStats is accessed atomically, keeping it first guarantees 64-bit
+	// alignment.
+	rtpStats rtpStats
+
 	cfg     ServerConfig
 	log     mlog.LoggerIFace
 	metrics Metrics
@@ -35,18 +41,112 @@ type Server struct {
 	groups   map[string]*group
 	sessions map[string]SessionConfig
 
-	udpConn net.PacketConn
+	udpConn *multiConn
 	udpMux  ice.UDPMux
+	// sendSched schedules the writes of the media packets between calls.
+	// It's nil if packets are written right away.
+	sendSched *sendScheduler
+	// probeConn answers the connectivity probes. It's nil if connectivity
+	// checks are disabled.
+	probeConn *probeConn
+	// turnRootCAs verifies the certificates of the TURN servers the
+	// connectivity checks reach over TLS. The host's root CAs are used if
+	// nil.
+	turnRootCAs *x509.CertPool
+	// featureFlags overrides the config for the features gated behind
+	// flags. It's nil if the config applies.
+	featureFlags FeatureFlags
+
+	udpPacketRate float64
+	// udpReadBufSize and udpWriteBufSize are the effective sizes of the
+	// socket buffers, as reported by the kernel.
+	udpReadBufSize  int
+	udpWriteBufSize int
+	stopCh          chan struct{}
+	stopOnce        sync.Once
+	monitorDoneCh   chan struct{}
+	capacityDoneCh  chan struct{}
+	rtpStatsDoneCh  chan struct{}
+	reaperDoneCh    chan struct{}
+	scaleMut        sync.Mutex
+
+	// capacity is the last estimate of the capacity left on the node.
+	capacity Capacity
+
+	// usage holds the traffic counters of each group, keyed by group ID.
+	usage    map[string]*groupUsage
+	usageMut sync.RWMutex
+
+	// slo records the outcome of sessions for the SLO reports.
+	slo *sloTracker
+
+	// recordingHooksWg tracks the running recording post-processing hooks.
+	recordingHooksWg sync.WaitGroup
+	// transcribersWg tracks the transcribers connecting to the
+	// transcription service.
+	transcribersWg sync.WaitGroup
+	// transcriptionDialer connects to the transcription service, if set.
+	transcriptionDialer TranscriptionDialer
+
+	// hlsStreams holds the running LL-HLS streams, keyed by stream ID.
+	hlsStreams map[string]*hlsStream
+	hlsMut     sync.RWMutex
+
+	connectivityDoneCh chan struct{}
+	connectivityChecks []ConnectivityCheck
+	connectivityMut    sync.RWMutex
+
+	// publicIP is the discovered public IP address advertised to clients if
+	// no ICEHostOverride is configured.
+	publicIP       string
+	publicIPMut    sync.RWMutex
+	publicIPDoneCh chan struct{}
 
 	sendCh    chan Message
 	receiveCh chan Message
 	drainCh   chan struct{}
 	bufPool   *sync.Pool
+	// draining is set once the server stopped accepting new sessions.
+	draining bool
+	// stopped is set, under mut, once the server is about to close sendCh.
+	stopped bool
+	// maintenanceNotice is the upcoming maintenance window the participants
+	// got notified of, if any.
+	maintenanceNotice *MaintenanceNotice
+
+	eventsCh     chan Event
+	eventsMut    sync.RWMutex
+	eventsClosed bool
+
+	// params holds the tuning parameters that can be changed at runtime.
+	params RuntimeParams
+
+	// sdpHooks are passed to the sessions when initialized.
+	sdpHooks []SDPHook
+
+	// crash reports the panics recovered from, if set.
+	crash *crash.Reporter
+
+	// dtlsCert is the certificate shared by the DTLS transports, if set.
+	dtlsCert *webrtc.Certificate
+
+	// srtpProfiles are the SRTP protection profiles offered to clients, in
+	// order of preference. The WebRTC stack defaults are used if empty.
+	srtpProfiles []string
+
+	// mdns handles the mDNS candidates of the clients. It's set on Start.
+	mdns *mdnsCandidates
+
+	// candidateFilter drops the local candidates not to be sent to clients.
+	candidateFilter *candidateFilter
+
+	// vnet is the virtual network media is served on, if set.
+	vnet *vnet.Net
 
 	mut sync.RWMutex
 }
 
-func NewServer(cfg ServerConfig, log mlog.LoggerIFace, metrics Metrics) (*Server, error) {
+func NewServer(cfg ServerConfig, log mlog.LoggerIFace, metrics Metrics, opts ...ServerOption) (*Server, error) {
 	if err := cfg.IsValid(); err != nil {
 		return nil, err
 	}
@@ -58,20 +158,53 @@ func NewServer(cfg ServerConfig, log mlog.LoggerIFace, metrics Metrics) (*Server
 	}
 
 	s := &Server{
-		cfg:       cfg,
-		log:       log,
-		metrics:   metrics,
-		groups:    map[string]*group{},
-		sessions:  map[string]SessionConfig{},
-		sendCh:    make(chan Message, msgChSize),
-		receiveCh: make(chan Message, msgChSize),
-		bufPool:   &sync.Pool{New: func() interface{} { return make([]byte, receiveMTU) }},
+		cfg:        cfg,
+		log:        log,
+		metrics:    metrics,
+		groups:     map[string]*group{},
+		sessions:   map[string]SessionConfig{},
+		usage:      map[string]*groupUsage{},
+		slo:        &sloTracker{},
+		capacity:   newCapacity(cfg.Capacity),
+		hlsStreams: map[string]*hlsStream{},
+		sendCh:     make(chan Message, msgChSize),
+		receiveCh:  make(chan Message, msgChSize),
+		eventsCh:   make(chan Event, msgChSize),
+		stopCh:     make(chan struct{}),
+		bufPool:    newBufPool(cfg.UDPSockets.ReceiveMTU),
+
+		candidateFilter: newCandidateFilter(cfg.ICECandidates),
+		srtpProfiles:    cfg.SRTPProtectionProfiles,
+	}
+
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, fmt.Errorf("failed to apply option: %w", err)
+		}
 	}
 
 	return s, nil
 }
 
+// GetChannelsDepth returns the number of messages queued in the server's
+// internal channels, keyed by name.
+func (s *Server) GetChannelsDepth() map[string]int {
+	return map[string]int{
+		"send":    len(s.sendCh),
+		"receive": len(s.receiveCh),
+		"events":  len(s.eventsCh),
+	}
+}
+
+// Send queues a signaling message received from a client.
 func (s *Server) Send(msg Message) error {
+	// Holding the lock keeps stop from closing the channel in between.
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	if s.stopped {
+		return fmt.Errorf("failed to send rtc message, server is stopped")
+	}
+
 	select {
 	case s.sendCh <- msg:
 	default:
@@ -80,98 +213,173 @@ func (s *Server) Send(msg Message) error {
 	return nil
 }
 
+// ReceiveCh returns the channel of the signaling messages to deliver to the
+// clients. It's closed when the server stops.
 func (s *Server) ReceiveCh() <-chan Message {
 	return s.receiveCh
 }
 
+// Start binds the UDP sockets and starts processing messages.
 func (s *Server) Start() error {
-	if s.cfg.ICEHostOverride == "" && len(s.cfg.ICEServers) > 0 {
-		addr, err := getPublicIP(s.cfg.ICEPortUDP, s.cfg.ICEServers.getSTUN())
+	// With interface budgets, the host candidates of each interface are
+	// advertised as they are, a single public IP would defeat placement.
+	discoverPublicIP := s.cfg.ICEHostOverride == "" && len(s.cfg.getDiscoverySTUNServers()) > 0 && s.vnet == nil &&
+		len(s.cfg.Capacity.InterfaceBudgets) == 0
+	if discoverPublicIP {
+		addr, err := s.discoverPublicIP(s.cfg.ICEPortUDP)
 		if err != nil {
 			return fmt.Errorf("failed to get public IP address: %w", err)
 		}
-		s.cfg.ICEHostOverride = addr
-		s.log.Info("got public IP address", mlog.String("addr", addr))
+		s.setPublicIP(addr)
 	}
 
-	var conns []net.PacketConn
-	for i := 0; i < runtime.NumCPU(); i++ {
-		listenConfig := net.ListenConfig{
-			Control: func(network, address string, c syscall.RawConn) error {
-				return c.Control(func(fd uintptr) {
-					err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1)
-					if err != nil {
-						s.log.Error("failed to set reuseaddr option", mlog.Err(err))
-						return
-					}
-					err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
-					if err != nil {
-						s.log.Error("failed to set reuseport option", mlog.Err(err))
-						return
-					}
-				})
-			},
-		}
+	s.initSRTPProfiles()
 
-		listenAddress := fmt.Sprintf("%s:%d", s.cfg.ICEAddressUDP, s.cfg.ICEPortUDP)
-		udpConn, err := listenConfig.ListenPacket(context.Background(), "udp4", listenAddress)
+	numConns := runtime.NumCPU()
+	if s.cfg.UDPSockets.EnableScaling {
+		numConns = s.cfg.UDPSockets.getMinCount()
+	}
+	if !reusePortSupported || s.vnet != nil {
+		numConns = 1
+	}
+
+	var conns []net.PacketConn
+	for i := 0; i < numConns; i++ {
+		udpConn, err := s.newUDPConn()
 		if err != nil {
-			return fmt.Errorf("failed to listen on udp: %w", err)
+			return err
 		}
+		conns = append(conns, udpConn)
+	}
+	s.checkInterfaceMTU()
 
-		s.log.Info(fmt.Sprintf("rtc: server is listening on udp %s", listenAddress))
+	readerCPUs, err := s.getReaderCPUs()
+	if err != nil {
+		s.log.Warn("rtc: socket readers won't be pinned to a NUMA node", mlog.Err(err))
+	}
+	udpConn, err := newMultiConn(conns, multiConnConfig{
+		writeMode:  s.cfg.UDPSockets.WriteMode,
+		readShards: s.cfg.UDPSockets.ReadShards,
+		readerCPUs: readerCPUs,
+		receiveMTU: s.cfg.UDPSockets.ReceiveMTU,
+	}, s.crash)
+	if err != nil {
+		return fmt.Errorf("failed to create multiconn: %w", err)
+	}
+	s.mut.Lock()
+	s.udpConn = udpConn
+	s.mut.Unlock()
 
-		if err := udpConn.(*net.UDPConn).SetWriteBuffer(udpSocketBufferSize); err != nil {
-			s.log.Warn("rtc: failed to set udp send buffer", mlog.Err(err))
+	muxConns := s.udpConn.shards()
+	if len(muxConns) == 0 {
+		muxConns = []net.PacketConn{s.udpConn}
+	}
+	if size := s.cfg.UDPSockets.SendQueueSize; size > 0 {
+		s.sendSched = newSendScheduler(s.udpConn, size, s.metrics.IncSendQueueDrops)
+		// As many writers as sockets so that writes aren't serialized.
+		s.sendSched.start(numConns)
+		for i, conn := range muxConns {
+			muxConns[i] = &fairConn{PacketConn: conn, sched: s.sendSched}
 		}
-
-		if err := udpConn.(*net.UDPConn).SetReadBuffer(udpSocketBufferSize); err != nil {
-			s.log.Warn("rtc: failed to set udp receive buffer", mlog.Err(err))
+	}
+	if s.cfg.Chaos.Enable {
+		s.log.Warn("chaos mode is enabled: media packets get dropped, delayed and reordered on purpose",
+			mlog.Int("packetLossPercent", s.cfg.Chaos.PacketLossPercent),
+			mlog.Int("latencyMs", s.cfg.Chaos.LatencyMs),
+			mlog.Int("jitterMs", s.cfg.Chaos.JitterMs),
+			mlog.Int("reorderPercent", s.cfg.Chaos.ReorderPercent))
+		for i, conn := range muxConns {
+			muxConns[i] = newChaosConn(conn, s.cfg.Chaos)
 		}
-
-		connFile, err := udpConn.(*net.UDPConn).File()
-		if err != nil {
-			return fmt.Errorf("failed to get udp conn file: %w", err)
+	}
+	if s.cfg.ConnectivityCheck.Enable {
+		s.probeConn = newProbeConn(s.udpConn)
+		// Probes can land on any shard.
+		for i, conn := range muxConns {
+			muxConns[i] = &probeConn{PacketConn: conn, username: s.probeConn.username}
 		}
-		defer connFile.Close()
+	}
+	if len(muxConns) > 1 {
+		s.udpMux = newShardedUDPMux(muxConns, s.udpConn.shardRouter)
+	} else {
+		s.udpMux = webrtc.NewICEUDPMux(nil, muxConns[0])
+	}
+	s.mdns = newMDNSCandidates(s.cfg.MDNSCandidates, s.log)
 
-		sysConn, err := connFile.SyscallConn()
-		if err != nil {
-			return fmt.Errorf("failed to get syscall conn: %w", err)
-		}
-		err = sysConn.Control(func(fd uintptr) {
-			writeBufSize, err := syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF)
-			if err != nil {
-				s.log.Error("failed to get buffer size", mlog.Err(err))
-				return
-			}
-			readBufSize, err := syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF)
-			if err != nil {
-				s.log.Error("failed to get buffer size", mlog.Err(err))
-				return
-			}
-			s.log.Debug("rtc: udp buffers", mlog.Int("writeBufSize", writeBufSize), mlog.Int("readBufSize", readBufSize))
-		})
-		if err != nil {
-			return fmt.Errorf("Control call failed: %w", err)
-		}
+	go s.msgReader()
 
-		conns = append(conns, udpConn)
+	s.monitorDoneCh = make(chan struct{})
+	go s.udpSocketsMonitor(s.stopCh, s.monitorDoneCh)
+
+	s.capacityDoneCh = make(chan struct{})
+	go s.capacityMonitor(s.stopCh, s.capacityDoneCh)
+
+	s.rtpStatsDoneCh = make(chan struct{})
+	go s.rtpStatsPusher(s.stopCh, s.rtpStatsDoneCh)
+
+	if s.cfg.IdleCallTimeoutMinutes > 0 || s.cfg.MaxCallDurationMinutes > 0 {
+		s.reaperDoneCh = make(chan struct{})
+		go s.callReaper(s.stopCh, s.reaperDoneCh)
 	}
-	var err error
-	s.udpConn, err = newMultiConn(conns)
+
+	if s.cfg.ConnectivityCheck.Enable {
+		s.connectivityDoneCh = make(chan struct{})
+		go s.connectivityChecker(s.stopCh, s.connectivityDoneCh)
+	}
+
+	if discoverPublicIP && s.cfg.PublicIPDiscovery.RecheckIntervalSeconds > 0 {
+		s.publicIPDoneCh = make(chan struct{})
+		go s.publicIPChecker(s.stopCh, s.publicIPDoneCh)
+	}
+
+	return nil
+}
+
+// newUDPConn creates a new UDP socket bound to the configured ICE address
+// and port. SO_REUSEPORT is set, where supported, so that multiple sockets
+// can share the same address.
+func (s *Server) newUDPConn() (net.PacketConn, error) {
+	if s.vnet != nil {
+		return s.newVNetConn()
+	}
+
+	listenConfig := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			return c.Control(func(fd uintptr) {
+				if err := setReusePort(fd); err != nil {
+					s.log.Error("failed to set socket options", mlog.Err(err))
+				}
+			})
+		},
+	}
+
+	listenAddress := fmt.Sprintf("%s:%d", s.cfg.ICEAddressUDP, s.cfg.ICEPortUDP)
+	udpConn, err := listenConfig.ListenPacket(context.Background(), "udp4", listenAddress)
 	if err != nil {
-		return fmt.Errorf("failed to create multiconn: %w", err)
+		return nil, fmt.Errorf("failed to listen on udp: %w", err)
 	}
 
-	s.udpMux = webrtc.NewICEUDPMux(nil, s.udpConn)
+	s.log.Info(fmt.Sprintf("rtc: server is listening on udp %s", listenAddress))
 
-	go s.msgReader()
+	if err := s.setUDPConnBuffers(udpConn.(*net.UDPConn)); err != nil {
+		udpConn.Close()
+		return nil, err
+	}
 
-	return nil
+	return udpConn, nil
 }
 
+// Stop waits for the ongoing sessions to end and releases all resources.
+// Only the first call has any effect.
 func (s *Server) Stop() error {
+	var err error
+	s.stopOnce.Do(func() {
+		err = s.stop()
+	})
+	return err
+}
+
+func (s *Server) stop() error {
 	var drainCh chan struct{}
 	s.mut.Lock()
 	if len(s.sessions) > 0 {
@@ -187,20 +395,57 @@ func (s *Server) Stop() error {
 		<-drainCh
 	}
 
+	close(s.stopCh)
+	if s.monitorDoneCh != nil {
+		<-s.monitorDoneCh
+	}
+	if s.capacityDoneCh != nil {
+		<-s.capacityDoneCh
+	}
+	if s.rtpStatsDoneCh != nil {
+		<-s.rtpStatsDoneCh
+	}
+	if s.reaperDoneCh != nil {
+		<-s.reaperDoneCh
+	}
+	if s.connectivityDoneCh != nil {
+		<-s.connectivityDoneCh
+	}
+	if s.publicIPDoneCh != nil {
+		<-s.publicIPDoneCh
+	}
+	s.recordingHooksWg.Wait()
+	s.transcribersWg.Wait()
+
 	if s.udpMux != nil {
 		if err := s.udpMux.Close(); err != nil {
 			return fmt.Errorf("failed to close udp mux: %w", err)
 		}
 	}
 
+	if s.sendSched != nil {
+		s.sendSched.close()
+	}
+
 	if s.udpConn != nil {
 		if err := s.udpConn.Close(); err != nil {
 			return fmt.Errorf("failed to close udp conn: %w", err)
 		}
 	}
 
+	if s.mdns != nil {
+		if err := s.mdns.Close(); err != nil {
+			return fmt.Errorf("failed to close mdns conn: %w", err)
+		}
+	}
+
+	s.mut.Lock()
+	s.stopped = true
+	s.mut.Unlock()
+
 	close(s.receiveCh)
 	close(s.sendCh)
+	s.closeEvents()
 
 	s.log.Info("rtc: server was shutdown")
 
@@ -240,7 +485,7 @@ func (s *Server) msgReader() {
 
 		session := call.getSession(cfg.SessionID)
 		if session == nil {
-			s.log.Error("session not found", mlog.String("sessionID", cfg.SessionID))
+			s.log.Error("session not found", mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
 			continue
 		}
 
@@ -286,6 +531,11 @@ func (s *Server) msgReader() {
 				continue
 			}
 
+			if call.audioOnly {
+				s.log.Debug("ignoring screen sharing in audio-only call", mlog.String("groupID", session.cfg.GroupID), mlog.String("sessionID", session.cfg.SessionID), mlog.String("traceID", session.cfg.TraceID))
+				continue
+			}
+
 			s.log.Debug("received screen sharing stream ID", mlog.String("screenStreamID", data["screenStreamID"]))
 
 			session.mut.Lock()
@@ -301,6 +551,10 @@ func (s *Server) msgReader() {
 				call.screenSession = nil
 			}
 			call.mut.Unlock()
+
+			if hs := call.getHLSStream(); hs != nil && hs.sessionID == session.cfg.SessionID && hs.video != nil {
+				s.stopHLS(call, hs, "screen sharing ended")
+			}
 		case MuteMessage, UnmuteMessage:
 			session.mut.RLock()
 			track := session.outVoiceTrack
@@ -316,11 +570,70 @@ func (s *Server) msgReader() {
 
 			s.log.Debug("setting voice track state",
 				mlog.Bool("enabled", enabled),
-				mlog.String("sessionID", session.cfg.SessionID))
+				mlog.String("groupID", session.cfg.GroupID), mlog.String("sessionID", session.cfg.SessionID),
+				mlog.String("traceID", session.cfg.TraceID))
 
 			session.mut.Lock()
+			changed := session.outVoiceTrackEnabled != enabled
 			session.outVoiceTrackEnabled = enabled
 			session.mut.Unlock()
+
+			if changed {
+				evType := SessionMutedEvent
+				if enabled {
+					evType = SessionUnmutedEvent
+				}
+				s.sendEvent(newEvent(evType, session.cfg))
+			}
+		case TrackPauseMessage, TrackResumeMessage:
+			data := map[string]string{}
+			if err := json.Unmarshal(msg.Data, &data); err != nil {
+				s.log.Error("failed to unmarshal track msg data", mlog.Err(err))
+				continue
+			}
+
+			paused := msg.Type == TrackPauseMessage
+
+			s.log.Debug("setting track forwarding state",
+				mlog.Bool("paused", paused),
+				mlog.String("trackID", data["trackID"]),
+				mlog.String("groupID", session.cfg.GroupID), mlog.String("sessionID", session.cfg.SessionID),
+				mlog.String("traceID", session.cfg.TraceID))
+
+			track, err := session.setTrackPaused(data["trackID"], paused)
+			if err != nil {
+				s.log.Error("failed to set track state", mlog.Err(err), mlog.String("groupID", session.cfg.GroupID), mlog.String("sessionID", session.cfg.SessionID), mlog.String("traceID", session.cfg.TraceID))
+				continue
+			}
+
+			// Requesting a keyframe so that video can be rendered right away.
+			if !paused && track.Kind() == webrtc.RTPCodecTypeVideo {
+				if err := call.requestKeyFrame(s.GetRuntimeParams()); err != nil {
+					s.log.Error("failed to request key frame", mlog.Err(err), mlog.String("groupID", session.cfg.GroupID), mlog.String("sessionID", session.cfg.SessionID), mlog.String("traceID", session.cfg.TraceID))
+				}
+			}
+		case TrackFramerateMessage:
+			data := map[string]string{}
+			if err := json.Unmarshal(msg.Data, &data); err != nil {
+				s.log.Error("failed to unmarshal track msg data", mlog.Err(err))
+				continue
+			}
+
+			maxFramerate, err := strconv.Atoi(data["maxFramerate"])
+			if err != nil {
+				s.log.Error("failed to parse maxFramerate", mlog.Err(err), mlog.String("groupID", session.cfg.GroupID), mlog.String("sessionID", session.cfg.SessionID), mlog.String("traceID", session.cfg.TraceID))
+				continue
+			}
+
+			s.log.Debug("setting track max framerate",
+				mlog.Int("maxFramerate", maxFramerate),
+				mlog.String("trackID", data["trackID"]),
+				mlog.String("groupID", session.cfg.GroupID), mlog.String("sessionID", session.cfg.SessionID),
+				mlog.String("traceID", session.cfg.TraceID))
+
+			if err := session.setTrackMaxFramerate(call, data["trackID"], maxFramerate); err != nil {
+				s.log.Error("failed to set track max framerate", mlog.Err(err), mlog.String("groupID", session.cfg.GroupID), mlog.String("sessionID", session.cfg.SessionID), mlog.String("traceID", session.cfg.TraceID))
+			}
 		default:
 			s.log.Error("received unexpected message type")
 		}
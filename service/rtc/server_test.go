@@ -4,6 +4,7 @@
 package rtc
 
 import (
+	"encoding/json"
 	"net"
 	"testing"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"github.com/mattermost/rtcd/service/perf"
 
 	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/webrtc/v3"
 	"github.com/stretchr/testify/require"
 )
 
@@ -130,6 +132,39 @@ func TestStartServer(t *testing.T) {
 		}()
 		require.NoError(t, err)
 	})
+
+	t.Run("read shards", func(t *testing.T) {
+		cfg := cfg
+		cfg.UDPSockets.ReadShards = 4
+		s, err := NewServer(cfg, log, metrics)
+		require.NoError(t, err)
+		require.NotNil(t, s)
+
+		err = s.Start()
+		defer func() {
+			err := s.Stop()
+			require.NoError(t, err)
+		}()
+		require.NoError(t, err)
+		require.IsType(t, &shardedUDPMux{}, s.udpMux)
+		require.Len(t, s.udpMux.(*shardedUDPMux).muxes, 4)
+	})
+
+	t.Run("stopped twice", func(t *testing.T) {
+		s, err := NewServer(cfg, log, metrics)
+		require.NoError(t, err)
+		require.NotNil(t, s)
+
+		err = s.Start()
+		require.NoError(t, err)
+
+		err = s.Stop()
+		require.NoError(t, err)
+		require.NotPanics(t, func() {
+			err = s.Stop()
+		})
+		require.NoError(t, err)
+	})
 }
 
 func TestDraining(t *testing.T) {
@@ -186,3 +221,202 @@ func TestDraining(t *testing.T) {
 		require.True(t, time.Since(beforeStop) > time.Second)
 	})
 }
+
+func TestScaleUDPSockets(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		err := log.Shutdown()
+		require.NoError(t, err)
+	}()
+
+	metrics := perf.NewMetrics("rtcd", nil)
+	require.NotNil(t, metrics)
+
+	cfg := ServerConfig{
+		ICEPortUDP: 30433,
+		UDPSockets: UDPSocketsConfig{
+			EnableScaling:       true,
+			MinCount:            1,
+			MaxCount:            4,
+			PacketRatePerSocket: 1000,
+		},
+	}
+
+	t.Run("not started", func(t *testing.T) {
+		s, err := NewServer(cfg, log, metrics)
+		require.NoError(t, err)
+		require.NotNil(t, s)
+
+		err = s.ScaleUDPSockets(2)
+		require.Error(t, err)
+		require.Equal(t, "server is not started", err.Error())
+		require.Equal(t, UDPSocketsStats{}, s.UDPSocketsStats())
+	})
+
+	t.Run("out of range", func(t *testing.T) {
+		s, err := NewServer(cfg, log, metrics)
+		require.NoError(t, err)
+		require.NotNil(t, s)
+
+		err = s.ScaleUDPSockets(0)
+		require.Error(t, err)
+		require.Equal(t, "invalid count value: 0 is not in allowed range [1, 4]", err.Error())
+
+		err = s.ScaleUDPSockets(5)
+		require.Error(t, err)
+		require.Equal(t, "invalid count value: 5 is not in allowed range [1, 4]", err.Error())
+	})
+
+	t.Run("scale up and down", func(t *testing.T) {
+		s, err := NewServer(cfg, log, metrics)
+		require.NoError(t, err)
+		require.NotNil(t, s)
+
+		err = s.Start()
+		require.NoError(t, err)
+		defer func() {
+			err := s.Stop()
+			require.NoError(t, err)
+		}()
+		require.Equal(t, 1, s.UDPSocketsStats().Count)
+
+		err = s.ScaleUDPSockets(4)
+		require.NoError(t, err)
+		require.Equal(t, 4, s.UDPSocketsStats().Count)
+
+		err = s.ScaleUDPSockets(2)
+		require.NoError(t, err)
+		require.Equal(t, 2, s.UDPSocketsStats().Count)
+	})
+
+	t.Run("target", func(t *testing.T) {
+		s, err := NewServer(cfg, log, metrics)
+		require.NoError(t, err)
+		require.NotNil(t, s)
+
+		require.Equal(t, 1, s.udpSocketsTarget(0))
+		require.Equal(t, 1, s.udpSocketsTarget(1000))
+		require.Equal(t, 2, s.udpSocketsTarget(1001))
+		require.Equal(t, 4, s.udpSocketsTarget(100000))
+	})
+}
+
+func TestUDPSocketBuffers(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	// Small enough not to be capped by the kernel limits.
+	server.cfg.UDPSockets.ReadBufferSize = 64 * 1024
+	server.cfg.UDPSockets.WriteBufferSize = 32 * 1024
+
+	conn, err := server.newUDPConn()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	readSize, writeSize, err := getUDPConnBuffers(conn.(*net.UDPConn))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, readSize, 64*1024)
+	require.GreaterOrEqual(t, writeSize, 32*1024)
+
+	stats := server.UDPSocketsStats()
+	require.Equal(t, readSize, stats.ReadBufferSize)
+	require.Equal(t, writeSize, stats.WriteBufferSize)
+}
+
+func TestGetMediaInterfaces(t *testing.T) {
+	t.Run("by address", func(t *testing.T) {
+		ifaces, err := getMediaInterfaces("127.0.0.1")
+		require.NoError(t, err)
+		require.Len(t, ifaces, 1)
+		require.NotZero(t, ifaces[0].Flags&net.FlagLoopback)
+	})
+
+	t.Run("unknown address", func(t *testing.T) {
+		ifaces, err := getMediaInterfaces("192.0.2.1")
+		require.NoError(t, err)
+		require.Empty(t, ifaces)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		ifaces, err := getMediaInterfaces("")
+		require.NoError(t, err)
+		for _, iface := range ifaces {
+			require.Zero(t, iface.Flags&net.FlagLoopback)
+		}
+	})
+}
+
+func TestEmbeddedSFU(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, log.Shutdown())
+	}()
+
+	s, err := NewServer(ServerConfig{ICEPortUDP: 30433}, log, perf.NewMetrics("rtcd", nil))
+	require.NoError(t, err)
+
+	// Only going through the public interfaces.
+	var sfu SFU = s
+	require.NoError(t, sfu.Start())
+
+	cfg := SessionConfig{
+		GroupID:   "groupID",
+		CallID:    "callID",
+		UserID:    "userID",
+		SessionID: "sessionID",
+	}
+	require.NoError(t, sfu.InitSession(cfg, nil))
+
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer pc.Close()
+	track, err := webrtc.NewTrackLocalStaticRTP(rtpAudioCodec, "voice", "stream")
+	require.NoError(t, err)
+	_, err = pc.AddTrack(track)
+	require.NoError(t, err)
+	offer, err := pc.CreateOffer(nil)
+	require.NoError(t, err)
+	require.NoError(t, pc.SetLocalDescription(offer))
+
+	data, err := json.Marshal(offer)
+	require.NoError(t, err)
+	require.NoError(t, sfu.Send(Message{
+		GroupID:   cfg.GroupID,
+		UserID:    cfg.UserID,
+		SessionID: cfg.SessionID,
+		Type:      SDPMessage,
+		Data:      data,
+	}))
+
+	timeoutCh := time.After(5 * time.Second)
+	for answered := false; !answered; {
+		select {
+		case msg := <-sfu.ReceiveCh():
+			require.Equal(t, cfg.SessionID, msg.SessionID)
+			var desc webrtc.SessionDescription
+			if err := json.Unmarshal(msg.Data, &desc); err == nil && desc.Type == webrtc.SDPTypeAnswer {
+				require.NoError(t, pc.SetRemoteDescription(desc))
+				answered = true
+			}
+		case <-timeoutCh:
+			require.Fail(t, "timed out waiting for answer")
+		}
+	}
+
+	state, err := sfu.GetCallState(cfg.GroupID, cfg.CallID)
+	require.NoError(t, err)
+	require.Len(t, state.Sessions, 1)
+	// A trace ID is generated as the config doesn't set one.
+	require.NotEmpty(t, state.Sessions[0].TraceID)
+
+	require.NoError(t, sfu.CloseSession(cfg.SessionID))
+	require.NoError(t, sfu.Stop())
+
+	var events []EventType
+	for ev := range sfu.EventsCh() {
+		events = append(events, ev.Type)
+	}
+	require.Equal(t, []EventType{CallStartedEvent, SessionJoinedEvent, SessionLeftEvent, CallEndedEvent}, events)
+}
@@ -22,6 +22,10 @@ const (
 
 // session contains all the state necessary to connect a user to a call.
 type session struct {
+	// rtpCounters is accessed atomically, keeping it first guarantees 64-bit
+	// alignment.
+	rtpCounters rtpCounters
+
 	cfg SessionConfig
 
 	// WebRTC
@@ -36,16 +40,90 @@ type session struct {
 	iceInCh              chan []byte
 	sdpOfferInCh         chan webrtc.SessionDescription
 	sdpAnswerInCh        chan webrtc.SessionDescription
+	// rtx repairs the packets received on the RTX streams of the session.
+	// It's nil if RTX is disabled.
+	rtx *rtxInterceptor
+	// ssrcs detects the SSRC collisions between the streams of the session.
+	ssrcs *ssrcInterceptor
+	// sdpHooks are applied to the session descriptions, before they're set.
+	sdpHooks []SDPHook
+	// mdns handles the mDNS candidates sent by the client, if set.
+	mdns *mdnsCandidates
+	// icePolicy is the ICE policy of the call, applied to the candidates
+	// sent by the client.
+	icePolicy ICEPolicy
+	// candidates batches and filters the local candidates sent to the
+	// client.
+	candidates *candidateBatcher
+	// migration detects the client changing network address. It's nil if
+	// session migration is disabled.
+	migration *migrationDetector
+	// iceRestartCh receives the requests to restart ICE.
+	iceRestartCh chan struct{}
+	// senders holds the senders of the tracks forwarded to this session,
+	// keyed by track ID.
+	senders map[string]*trackSender
 
 	closeCh chan struct{}
-	closeCb func() error
+	closeCb func(reason string) error
 
 	makingOffer bool
 
+	// lastPLIForwardAt is the last time a PLI request was forwarded to this
+	// session (as presenter).
+	lastPLIForwardAt time.Time
+
+	// quality holds the quality of the streams forwarded to this session,
+	// keyed by track ID.
+	quality map[string]StreamQuality
+	// lossSum and lossReports accumulate the fractions of lost packets
+	// reported for the streams forwarded to this session.
+	lossSum     float64
+	lossReports int
+	// minRTTMs is the lowest round trip time reported on the connection of
+	// the session, taken as free of queuing delay.
+	minRTTMs float64
+	// uplinkQueuingMs is the last queuing delay measured on the packets
+	// published by the session.
+	uplinkQueuingMs float64
+
+	// trackActivity holds the activity of the tracks published by this
+	// session, keyed by outgoing track ID, if inactivity detection is
+	// enabled.
+	trackActivity map[string]*trackActivity
+
+	// joinTimings holds the times the session went through each setup
+	// phase, measured from joinStartedAt.
+	joinTimings   JoinTimings
+	joinStartedAt time.Time
+
+	// recording is the recording of the tracks received from this session,
+	// if any.
+	recording *recording
+
+	// announcer plays the announcements queued to the session. It's created
+	// on first use.
+	announcer *announcer
+
+	// connected tracks whether the peer connection is currently established.
+	connected          bool
+	connStateChangedAt time.Time
+
 	mut sync.RWMutex
 }
 
-func (s *Server) addSession(cfg SessionConfig, peerConn *webrtc.PeerConnection, closeCb func() error) (*session, error) {
+// isAudioOnlyCall returns whether the session is joining an audio-only call,
+// either existing or started by the session itself.
+func (s *Server) isAudioOnlyCall(cfg SessionConfig) bool {
+	if g := s.getGroup(cfg.GroupID); g != nil {
+		if c := g.getCall(cfg.CallID); c != nil {
+			return c.audioOnly
+		}
+	}
+	return cfg.AudioOnly
+}
+
+func (s *Server) addSession(cfg SessionConfig, peerConn *webrtc.PeerConnection, closeCb func(reason string) error) (*session, error) {
 	if err := cfg.IsValid(); err != nil {
 		return nil, err
 	}
@@ -66,25 +144,54 @@ func (s *Server) addSession(cfg SessionConfig, peerConn *webrtc.PeerConnection,
 	}
 	s.mut.Unlock()
 
+	// Placement iterates the calls, so it's done before taking the group
+	// lock. It's only used if the call is still missing once locked.
+	var iface string
+	if g.getCall(cfg.CallID) == nil {
+		iface = s.placeCall()
+	}
+
+	var callCreated bool
 	g.mut.Lock()
 	c := g.calls[cfg.CallID]
 	if c == nil {
 		// call is missing, creating one
 		c = &call{
-			id:       cfg.CallID,
-			sessions: map[string]*session{},
+			id:        cfg.CallID,
+			sessions:  map[string]*session{},
+			createdAt: time.Now(),
+			audioOnly: cfg.AudioOnly,
+			icePolicy: cfg.ICEPolicy,
+			iface:     iface,
+		}
+		if s.isFeatureEnabled(FeatureEgressShaping, cfg.GroupID, s.cfg.EgressShaping.Enable) {
+			c.egress = newTokenBucket(s.cfg.EgressShaping, c.createdAt)
 		}
 		g.calls[c.id] = c
+		callCreated = true
 	}
 	g.mut.Unlock()
 
-	us, ok := c.addSession(cfg, peerConn, closeCb)
-	if !ok {
-		return nil, fmt.Errorf("user session already exists")
+	us, callStarted, err := c.addSession(cfg, peerConn, closeCb, s.cfg.MaxCallParticipants)
+	if err != nil {
+		if callCreated {
+			s.removeEmptyCall(g, c)
+		}
+		return nil, err
 	}
 	s.mut.Lock()
 	s.sessions[cfg.SessionID] = cfg
 	s.mut.Unlock()
+	// Counted once admitted, as closing the session is what decrements it.
+	s.metrics.IncRTCSessions(cfg.GroupID, cfg.CallID)
+
+	if callStarted {
+		s.sendEvent(newCallEvent(CallStartedEvent, cfg.GroupID, cfg.CallID))
+	}
+
+	if !cfg.Hidden {
+		s.sendEvent(newEvent(SessionJoinedEvent, cfg))
+	}
 
 	return us, nil
 }
@@ -112,7 +219,7 @@ func (s *session) handleICE(log mlog.LoggerIFace, m Metrics) {
 
 			var candidate webrtc.ICECandidateInit
 			if err := json.Unmarshal(data, &candidate); err != nil {
-				log.Error("failed to encode ice candidate", mlog.Err(err), mlog.String("sessionID", s.cfg.SessionID))
+				log.Error("failed to encode ice candidate", mlog.Err(err), mlog.String("groupID", s.cfg.GroupID), mlog.String("sessionID", s.cfg.SessionID), mlog.String("traceID", s.cfg.TraceID))
 				continue
 			}
 
@@ -120,10 +227,24 @@ func (s *session) handleICE(log mlog.LoggerIFace, m Metrics) {
 				continue
 			}
 
-			log.Debug("setting ICE candidate for remote", mlog.String("sessionID", s.cfg.SessionID))
+			if s.icePolicy.dropsCandidateAttr(candidate.Candidate) {
+				continue
+			}
+
+			if s.mdns != nil {
+				ctx, cancel := s.mdns.newContext()
+				c, ok := s.mdns.process(ctx, s.cfg.SessionID, candidate.Candidate)
+				cancel()
+				if !ok {
+					continue
+				}
+				candidate.Candidate = c
+			}
+
+			log.Debug("setting ICE candidate for remote", mlog.String("groupID", s.cfg.GroupID), mlog.String("sessionID", s.cfg.SessionID), mlog.String("traceID", s.cfg.TraceID))
 
 			if err := s.rtcConn.AddICECandidate(candidate); err != nil {
-				log.Error("failed to add ice candidate", mlog.Err(err), mlog.String("sessionID", s.cfg.SessionID))
+				log.Error("failed to add ice candidate", mlog.Err(err), mlog.String("groupID", s.cfg.GroupID), mlog.String("sessionID", s.cfg.SessionID), mlog.String("traceID", s.cfg.TraceID))
 				m.IncRTCErrors(s.cfg.GroupID, "ice")
 				continue
 			}
@@ -133,42 +254,204 @@ func (s *session) handleICE(log mlog.LoggerIFace, m Metrics) {
 	}
 }
 
-// handlePLI is used to listen for for PLI (Picture Loss Indication) packet requests
-// from a peer receiving a video track (e.g. screen). When one is received
-// the request is forwarded to the peer generating the track (e.g. presenter).
-func (s *session) handlePLI(log mlog.LoggerIFace, call *call, sender *webrtc.RTPSender) {
+// trackSender holds a track forwarded to a session along with the sender
+// used to do so.
+type trackSender struct {
+	sender *webrtc.RTPSender
+	track  *webrtc.TrackLocalStaticRTP
+	// ssrc is the SSRC of the stream sent by sender.
+	ssrc   uint32
+	paused bool
+	// throttler is set if the subscriber requested a maximum framerate.
+	throttler *frameThrottler
+}
+
+// getSendingTrack returns the track that should be bound to the sender
+// when not paused.
+func (ts *trackSender) getSendingTrack() *webrtc.TrackLocalStaticRTP {
+	if ts.throttler != nil {
+		return ts.throttler.track
+	}
+	return ts.track
+}
+
+// setTrackPaused pauses or resumes forwarding of the given track to the
+// session. This doesn't require renegotiation as the sender is kept.
+func (s *session) setTrackPaused(trackID string, paused bool) (*webrtc.TrackLocalStaticRTP, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	ts := s.senders[trackID]
+	if ts == nil {
+		return nil, fmt.Errorf("track not found: %s", trackID)
+	}
+
+	if ts.paused == paused {
+		return ts.track, nil
+	}
+
+	var track webrtc.TrackLocal
+	if !paused {
+		track = ts.getSendingTrack()
+	}
+	if err := ts.sender.ReplaceTrack(track); err != nil {
+		return nil, fmt.Errorf("failed to replace track: %w", err)
+	}
+	ts.paused = paused
+
+	return ts.track, nil
+}
+
+// setTrackMaxFramerate limits the framerate at which the given video track is
+// forwarded to the session. Zero removes the limit, the track still being
+// forwarded through the throttler so that its sequence numbers stay
+// continuous.
+func (s *session) setTrackMaxFramerate(c *call, trackID string, maxFramerate int) error {
+	if maxFramerate < 0 {
+		return fmt.Errorf("invalid maxFramerate value: should not be negative")
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	ts := s.senders[trackID]
+	if ts == nil {
+		return fmt.Errorf("track not found: %s", trackID)
+	}
+	if ts.track.Kind() != webrtc.RTPCodecTypeVideo {
+		return fmt.Errorf("invalid track kind: should be video")
+	}
+
+	if ts.throttler != nil {
+		ts.throttler.setMaxFramerate(maxFramerate)
+		return nil
+	}
+	if maxFramerate == 0 {
+		return nil
+	}
+
+	out, err := webrtc.NewTrackLocalStaticRTP(ts.track.Codec(), ts.track.ID(), ts.track.StreamID())
+	if err != nil {
+		return fmt.Errorf("failed to create local track: %w", err)
+	}
+	throttler := newFrameThrottler(out, maxFramerate)
+
+	ts.throttler = throttler
+	if !ts.paused {
+		if err := ts.sender.ReplaceTrack(ts.getSendingTrack()); err != nil {
+			ts.throttler = nil
+			return fmt.Errorf("failed to replace track: %w", err)
+		}
+	}
+	c.setFrameThrottler(trackID, s.cfg.SessionID, throttler)
+
+	return nil
+}
+
+// handleRTCP is used to listen for RTCP packets sent by a peer receiving a
+// track. PLI (Picture Loss Indication) requests are forwarded to the peer
+// generating the track (e.g. presenter) while receiver reports are collected
+// for aggregation and used to score the quality of the stream.
+func (s *session) handleRTCP(log mlog.LoggerIFace, call *call, sender *webrtc.RTPSender, getParams func() RuntimeParams, onQualityUpdate func(*session, StreamQuality, bool)) {
+	trackID := sender.Track().ID()
+	var ssrc uint32
+	if encodings := sender.GetParameters().Encodings; len(encodings) > 0 {
+		ssrc = uint32(encodings[0].SSRC)
+	}
+	var clockRate uint32
+	if track, ok := sender.Track().(*webrtc.TrackLocalStaticRTP); ok {
+		clockRate = track.Codec().ClockRate
+	}
+	isAudio := sender.Track().Kind() == webrtc.RTPCodecTypeAudio
+
+	// withLegStats completes the quality of the stream with the delay on
+	// either leg and, for audio streams, the loss on the publisher leg, so
+	// that they can be told apart from the ones on the subscriber leg.
+	withLegStats := func(q StreamQuality) StreamQuality {
+		if d := call.getUplinkDelay(trackID); d != nil {
+			q.UplinkDelayMs = d.getDelay()
+		}
+		q.DownlinkDelayMs = estimateDownlinkDelay(q.RTTMs, s.getMinRTT(), s.getUplinkQueuing())
+		if !isAudio {
+			return q
+		}
+		if l := call.getUplinkLoss(trackID); l != nil {
+			q.UplinkFractionLost = l.get()
+		}
+		return q
+	}
+
+	handleReports := func(reports []rtcp.ReceptionReport) {
+		for _, report := range reports {
+			if report.SSRC != ssrc {
+				continue
+			}
+			now := time.Now()
+			if tr := call.getTrackReports(trackID); tr != nil {
+				tr.update(s.cfg.SessionID, report, now)
+			}
+			q := qualityFromReceptionReport(trackID, report, clockRate, now)
+			if isAudio {
+				q = concealmentFromReceptionReport(q, s.getStreamQuality(trackID), now)
+			}
+			q = withLegStats(q)
+			onQualityUpdate(s, q, s.updateQuality(q))
+		}
+	}
+
 	for {
 		pkts, _, err := sender.ReadRTCP()
 		if err != nil {
 			log.Error("failed to read RTCP packet",
-				mlog.Err(err), mlog.String("sessionID", s.cfg.SessionID))
+				mlog.Err(err), mlog.String("groupID", s.cfg.GroupID), mlog.String("sessionID", s.cfg.SessionID), mlog.String("traceID", s.cfg.TraceID))
 			return
 		}
 		for _, pkt := range pkts {
-			if _, ok := pkt.(*rtcp.PictureLossIndication); ok {
-				screenSession := call.getScreenSession()
-				if screenSession == nil {
-					log.Error("screenSession should not be nil", mlog.String("sessionID", s.cfg.SessionID))
+			switch p := pkt.(type) {
+			case *rtcp.PictureLossIndication:
+				call.stats.incPLIs()
+				if err := call.requestKeyFrame(getParams()); err != nil {
+					log.Error("failed to forward PLI", mlog.Err(err), mlog.String("groupID", s.cfg.GroupID), mlog.String("sessionID", s.cfg.SessionID), mlog.String("traceID", s.cfg.TraceID))
 					return
 				}
-
-				screenTrack := screenSession.getRemoteScreenTrack()
-				if screenTrack == nil {
-					log.Error("screenTrack should not be nil", mlog.String("sessionID", s.cfg.SessionID))
-					return
-				}
-
-				if err := screenSession.rtcConn.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: uint32(screenTrack.SSRC())}}); err != nil {
-					log.Error("failed to write RTCP packet", mlog.Err(err), mlog.String("sessionID", s.cfg.SessionID))
-					return
+			case *rtcp.TransportLayerNack:
+				call.stats.incNACKs()
+			case *rtcp.ReceiverReport:
+				handleReports(p.Reports)
+			case *rtcp.SenderReport:
+				// Peers also sending media report on the streams they receive
+				// through their sender reports.
+				handleReports(p.Reports)
+			case *rtcp.ExtendedReport:
+				for _, block := range p.Reports {
+					metrics, ok := block.(*rtcp.VoIPMetricsReportBlock)
+					if !ok || metrics.SSRC != ssrc {
+						continue
+					}
+					now := time.Now()
+					q := qualityFromVoIPMetrics(trackID, metrics, s.getStreamQuality(trackID), now)
+					q = withLegStats(concealmentFromVoIPMetrics(q, metrics, now))
+					onQualityUpdate(s, q, s.updateQuality(q))
 				}
 			}
 		}
 	}
 }
 
+// shouldForwardPLI returns whether a PLI request received at the given time
+// should be forwarded to the session, given the throttle interval.
+func (s *session) shouldForwardPLI(now time.Time, throttle time.Duration) bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if throttle > 0 && now.Sub(s.lastPLIForwardAt) < throttle {
+		return false
+	}
+	s.lastPLIForwardAt = now
+	return true
+}
+
 // addTrack adds the given track to the peer and starts negotiation.
-func (s *session) addTrack(log mlog.LoggerIFace, c *call, sdpOutCh chan<- Message, track *webrtc.TrackLocalStaticRTP) error {
+func (s *session) addTrack(log mlog.LoggerIFace, c *call, sdpOutCh chan<- Message, track *webrtc.TrackLocalStaticRTP, getParams func() RuntimeParams, onQualityUpdate func(*session, StreamQuality, bool)) error {
 	s.mut.Lock()
 	s.makingOffer = true
 	s.mut.Unlock()
@@ -178,14 +461,83 @@ func (s *session) addTrack(log mlog.LoggerIFace, c *call, sdpOutCh chan<- Messag
 		s.mut.Unlock()
 	}()
 
+	sender, err := s.addSender(log, track)
+	if err != nil {
+		return err
+	}
+	s.mut.Lock()
+	if s.senders == nil {
+		s.senders = map[string]*trackSender{}
+	}
+	s.senders[track.ID()] = &trackSender{
+		sender: sender,
+		track:  track,
+		ssrc:   getSenderSSRC(sender),
+	}
+	s.mut.Unlock()
+	go s.handleRTCP(log, c, sender, getParams, onQualityUpdate)
+
+	return s.sendOffer(sdpOutCh, nil)
+}
+
+// addSender adds a sender for the given track to the peer. A sender drawing
+// the SSRC of another stream of the session is replaced, up to
+// ssrcMaxReassignments times, before the track gets negotiated.
+func (s *session) addSender(log mlog.LoggerIFace, track *webrtc.TrackLocalStaticRTP) (*webrtc.RTPSender, error) {
 	sender, err := s.rtcConn.AddTrack(track)
 	if err != nil {
-		return fmt.Errorf("failed to add track: %w", err)
-	} else if track.Kind() == webrtc.RTPCodecTypeVideo {
-		go s.handlePLI(log, c, sender)
+		return nil, fmt.Errorf("failed to add track: %w", err)
 	}
 
-	offer, err := s.rtcConn.CreateOffer(nil)
+	for i := 0; ; i++ {
+		ssrc := getSenderSSRC(sender)
+		if !s.isSSRCInUse(sender, ssrc) {
+			return sender, nil
+		}
+
+		if i == ssrcMaxReassignments {
+			log.Warn("SSRC collision: failed to reassign SSRC", mlog.String("groupID", s.cfg.GroupID), mlog.String("sessionID", s.cfg.SessionID), mlog.String("traceID", s.cfg.TraceID),
+				mlog.String("trackID", track.ID()), mlog.Uint32("ssrc", ssrc))
+			if s.ssrcs != nil {
+				s.ssrcs.notify(ssrc, ssrcCollisionSent, ssrcCollisionUnresolved)
+			}
+			return sender, nil
+		}
+
+		if err := s.rtcConn.RemoveTrack(sender); err != nil {
+			return nil, fmt.Errorf("failed to remove colliding track: %w", err)
+		}
+		sender, err = s.rtcConn.AddTrack(track)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add track: %w", err)
+		}
+		log.Debug("SSRC collision: reassigned SSRC", mlog.String("groupID", s.cfg.GroupID), mlog.String("sessionID", s.cfg.SessionID), mlog.String("traceID", s.cfg.TraceID),
+			mlog.String("trackID", track.ID()), mlog.Uint32("ssrc", ssrc), mlog.Uint32("newSSRC", getSenderSSRC(sender)))
+		if s.ssrcs != nil {
+			s.ssrcs.notify(ssrc, ssrcCollisionSent, ssrcCollisionReassigned)
+		}
+	}
+}
+
+// restartICE renegotiates the session restarting ICE, so that the client
+// connects again from its current network address. The DTLS association
+// is kept.
+func (s *session) restartICE(sdpOutCh chan<- Message) error {
+	s.mut.Lock()
+	s.makingOffer = true
+	s.mut.Unlock()
+	defer func() {
+		s.mut.Lock()
+		s.makingOffer = false
+		s.mut.Unlock()
+	}()
+
+	return s.sendOffer(sdpOutCh, &webrtc.OfferOptions{ICERestart: true})
+}
+
+// sendOffer sends an offer to the client and sets its answer.
+func (s *session) sendOffer(sdpOutCh chan<- Message, options *webrtc.OfferOptions) error {
+	offer, err := s.rtcConn.CreateOffer(options)
 	if err != nil {
 		return fmt.Errorf("failed to create offer: %w", err)
 	}
@@ -195,7 +547,12 @@ func (s *session) addTrack(log mlog.LoggerIFace, c *call, sdpOutCh chan<- Messag
 		return fmt.Errorf("failed to set local description: %w", err)
 	}
 
-	sdp, err := json.Marshal(s.rtcConn.LocalDescription())
+	localDesc, err := s.applySDPHooks(true, *s.rtcConn.LocalDescription())
+	if err != nil {
+		return err
+	}
+
+	sdp, err := json.Marshal(localDesc)
 	if err != nil {
 		return fmt.Errorf("failed to marshal sdp: %w", err)
 	}
@@ -211,6 +568,16 @@ func (s *session) addTrack(log mlog.LoggerIFace, c *call, sdpOutCh chan<- Messag
 		if !ok {
 			return nil
 		}
+		answer, err := s.applySDPHooks(false, answer)
+		if err != nil {
+			return err
+		}
+		if s.icePolicy.DisableHost || s.icePolicy.RelayOnly {
+			answer.SDP = s.icePolicy.filterCandidates(answer.SDP)
+		}
+		if s.mdns != nil {
+			answer.SDP = s.mdns.processSDP(s.cfg.SessionID, answer.SDP)
+		}
 		if err := s.rtcConn.SetRemoteDescription(answer); err != nil {
 			return fmt.Errorf("failed to set remote description: %w", err)
 		}
@@ -223,6 +590,22 @@ func (s *session) addTrack(log mlog.LoggerIFace, c *call, sdpOutCh chan<- Messag
 
 // signaling handles incoming SDP offers.
 func (s *session) signaling(offer webrtc.SessionDescription, sdpOutCh chan<- Message) error {
+	offer, err := s.applySDPHooks(false, offer)
+	if err != nil {
+		return err
+	}
+
+	if s.icePolicy.DisableHost || s.icePolicy.RelayOnly {
+		offer.SDP = s.icePolicy.filterCandidates(offer.SDP)
+	}
+	if s.mdns != nil {
+		offer.SDP = s.mdns.processSDP(s.cfg.SessionID, offer.SDP)
+	}
+
+	if s.rtx != nil {
+		s.rtx.setSSRCs(parseRTXSSRCs(offer.SDP))
+	}
+
 	if err := s.rtcConn.SetRemoteDescription(offer); err != nil {
 		return err
 	}
@@ -236,7 +619,12 @@ func (s *session) signaling(offer webrtc.SessionDescription, sdpOutCh chan<- Mes
 		return err
 	}
 
-	sdp, err := json.Marshal(s.rtcConn.LocalDescription())
+	localDesc, err := s.applySDPHooks(true, *s.rtcConn.LocalDescription())
+	if err != nil {
+		return err
+	}
+
+	sdp, err := json.Marshal(localDesc)
 	if err != nil {
 		return err
 	}
@@ -258,3 +646,16 @@ func (s *session) HasSignalingConflict() bool {
 	}
 	return s.makingOffer || s.rtcConn.SignalingState() != webrtc.SignalingStateStable
 }
+
+// getTrackIDBySSRC returns the ID of the track forwarded to the session on
+// the stream with the given SSRC, empty if none.
+func (s *session) getTrackIDBySSRC(ssrc uint32) string {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	for trackID, ts := range s.senders {
+		if ts.ssrc == ssrc {
+			return trackID
+		}
+	}
+	return ""
+}
@@ -5,10 +5,15 @@ package rtc
 
 import (
 	"errors"
+	"strings"
 	"sync"
 	"testing"
 
+	"github.com/mattermost/rtcd/service/perf"
+
 	"github.com/pion/webrtc/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -66,12 +71,12 @@ func TestAddSession(t *testing.T) {
 		require.NoError(t, err)
 
 		cbError := errors.New("closeCb failed")
-		closeCbError := func() error {
+		closeCbError := func(_ string) error {
 			return cbError
 		}
 
 		var cbCalled bool
-		closeCbSuccess := func() error {
+		closeCbSuccess := func(_ string) error {
 			cbCalled = true
 			return nil
 		}
@@ -94,6 +99,107 @@ func TestAddSession(t *testing.T) {
 	})
 }
 
+func TestAddSessionMaxParticipants(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	server.cfg.MaxCallParticipants = 1
+	registry := prometheus.NewRegistry()
+	server.metrics = perf.NewMetrics("rtcd", registry)
+
+	cfg := SessionConfig{
+		GroupID:   "test",
+		CallID:    "test",
+		UserID:    "test",
+		SessionID: "sessionA",
+	}
+
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+
+	us, err := server.addSession(cfg, peerConn, nil)
+	require.NoError(t, err)
+	require.NotNil(t, us)
+
+	cfg.SessionID = "sessionB"
+	us, err = server.addSession(cfg, peerConn, nil)
+	require.ErrorIs(t, err, ErrMaxParticipantsReached)
+	require.Nil(t, us)
+
+	// The rejected session isn't counted.
+	expected := `
+# HELP rtcd_rtc_sessions_total Total number of active RTC sessions
+# TYPE rtcd_rtc_sessions_total gauge
+rtcd_rtc_sessions_total{callID="test",groupID="test"} 1
+`
+	err = testutil.GatherAndCompare(registry, strings.NewReader(expected), "rtcd_rtc_sessions_total")
+	require.NoError(t, err)
+
+	require.NoError(t, server.CloseSession("sessionA"))
+	require.Nil(t, server.getGroup("test"))
+
+	expected = `
+# HELP rtcd_rtc_sessions_total Total number of active RTC sessions
+# TYPE rtcd_rtc_sessions_total gauge
+rtcd_rtc_sessions_total{callID="test",groupID="test"} 0
+`
+	err = testutil.GatherAndCompare(registry, strings.NewReader(expected), "rtcd_rtc_sessions_total")
+	require.NoError(t, err)
+}
+
+func TestAddHiddenSession(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	server.cfg.MaxCallParticipants = 1
+
+	cfg := SessionConfig{
+		GroupID:   "test",
+		CallID:    "test",
+		UserID:    "test",
+		SessionID: "sessionA",
+	}
+	hiddenCfg := cfg
+	hiddenCfg.UserID = "recorder"
+	hiddenCfg.SessionID = "sessionB"
+	hiddenCfg.Hidden = true
+
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+
+	_, err = server.addSession(cfg, peerConn, nil)
+	require.NoError(t, err)
+
+	// Hidden sessions don't count towards the participant limit.
+	us, err := server.addSession(hiddenCfg, peerConn, nil)
+	require.NoError(t, err)
+	require.NotNil(t, us)
+
+	state, err := server.GetCallState("test", "test")
+	require.NoError(t, err)
+	require.Len(t, state.Sessions, 1)
+	require.Equal(t, "sessionA", state.Sessions[0].SessionID)
+
+	require.NoError(t, server.CloseSession("sessionB"))
+	require.NoError(t, server.CloseSession("sessionA"))
+
+	// No events are sent for hidden sessions.
+	leftEv := newEvent(SessionLeftEvent, cfg)
+	leftEv.Reason = CloseReasonLeft
+	expected := []Event{
+		newCallEvent(CallStartedEvent, cfg.GroupID, cfg.CallID),
+		newEvent(SessionJoinedEvent, cfg),
+		leftEv,
+		newCallEvent(CallEndedEvent, cfg.GroupID, cfg.CallID),
+	}
+	for _, exp := range expected {
+		ev := <-server.EventsCh()
+		ev.Timestamp = 0
+		exp.Timestamp = 0
+		require.Equal(t, exp, ev)
+	}
+}
+
 func TestCloseSessionConcurrent(t *testing.T) {
 	server, shutdown := setupServer(t)
 	defer shutdown()
@@ -124,3 +230,188 @@ func TestCloseSessionConcurrent(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestSetTrackPaused(t *testing.T) {
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer peerConn.Close()
+
+	track, err := webrtc.NewTrackLocalStaticRTP(rtpVideoCodecVP8, "screen_test", "streamID")
+	require.NoError(t, err)
+
+	sender, err := peerConn.AddTrack(track)
+	require.NoError(t, err)
+
+	us := &session{
+		rtcConn: peerConn,
+		senders: map[string]*trackSender{
+			track.ID(): {sender: sender, track: track},
+		},
+	}
+
+	t.Run("track not found", func(t *testing.T) {
+		_, err := us.setTrackPaused("unknown", true)
+		require.Error(t, err)
+		require.Equal(t, "track not found: unknown", err.Error())
+	})
+
+	t.Run("pause", func(t *testing.T) {
+		out, err := us.setTrackPaused(track.ID(), true)
+		require.NoError(t, err)
+		require.Equal(t, track, out)
+		require.Nil(t, sender.Track())
+
+		// Pausing twice should be a no-op.
+		_, err = us.setTrackPaused(track.ID(), true)
+		require.NoError(t, err)
+		require.Nil(t, sender.Track())
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		out, err := us.setTrackPaused(track.ID(), false)
+		require.NoError(t, err)
+		require.Equal(t, track, out)
+		require.Equal(t, track, sender.Track())
+	})
+}
+
+func TestSetTrackMaxFramerate(t *testing.T) {
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer peerConn.Close()
+
+	videoTrack, err := webrtc.NewTrackLocalStaticRTP(rtpVideoCodecVP8, "screen_test", "streamID")
+	require.NoError(t, err)
+	videoSender, err := peerConn.AddTrack(videoTrack)
+	require.NoError(t, err)
+
+	audioTrack, err := webrtc.NewTrackLocalStaticRTP(rtpAudioCodec, "voice_test", "streamID")
+	require.NoError(t, err)
+	audioSender, err := peerConn.AddTrack(audioTrack)
+	require.NoError(t, err)
+
+	c := &call{}
+	us := &session{
+		cfg:     SessionConfig{SessionID: "sessionA"},
+		rtcConn: peerConn,
+		senders: map[string]*trackSender{
+			videoTrack.ID(): {sender: videoSender, track: videoTrack},
+			audioTrack.ID(): {sender: audioSender, track: audioTrack},
+		},
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		err := us.setTrackMaxFramerate(c, videoTrack.ID(), -1)
+		require.Error(t, err)
+		require.Equal(t, "invalid maxFramerate value: should not be negative", err.Error())
+
+		err = us.setTrackMaxFramerate(c, "unknown", 10)
+		require.Error(t, err)
+		require.Equal(t, "track not found: unknown", err.Error())
+
+		err = us.setTrackMaxFramerate(c, audioTrack.ID(), 10)
+		require.Error(t, err)
+		require.Equal(t, "invalid track kind: should be video", err.Error())
+	})
+
+	t.Run("set", func(t *testing.T) {
+		err := us.setTrackMaxFramerate(c, videoTrack.ID(), 10)
+		require.NoError(t, err)
+		throttlers := c.getFrameThrottlers(videoTrack.ID())
+		require.Len(t, throttlers, 1)
+		require.Equal(t, throttlers[0].track, videoSender.Track())
+		require.Equal(t, uint32(9000), throttlers[0].minInterval)
+
+		err = us.setTrackMaxFramerate(c, videoTrack.ID(), 15)
+		require.NoError(t, err)
+		require.Equal(t, throttlers, c.getFrameThrottlers(videoTrack.ID()))
+		require.Equal(t, uint32(6000), throttlers[0].minInterval)
+	})
+
+	t.Run("pause and resume", func(t *testing.T) {
+		_, err := us.setTrackPaused(videoTrack.ID(), true)
+		require.NoError(t, err)
+		require.Nil(t, videoSender.Track())
+
+		_, err = us.setTrackPaused(videoTrack.ID(), false)
+		require.NoError(t, err)
+		require.Equal(t, c.getFrameThrottlers(videoTrack.ID())[0].track, videoSender.Track())
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		// The track keeps being forwarded through the throttler, for its
+		// sequence numbers to stay continuous.
+		throttlers := c.getFrameThrottlers(videoTrack.ID())
+		err := us.setTrackMaxFramerate(c, videoTrack.ID(), 0)
+		require.NoError(t, err)
+		require.Equal(t, throttlers, c.getFrameThrottlers(videoTrack.ID()))
+		require.Equal(t, throttlers[0].track, videoSender.Track())
+		require.Zero(t, throttlers[0].getMaxFramerate())
+		require.Zero(t, throttlers[0].minInterval)
+
+		err = us.setTrackMaxFramerate(c, videoTrack.ID(), 10)
+		require.NoError(t, err)
+		require.Equal(t, throttlers, c.getFrameThrottlers(videoTrack.ID()))
+		require.Equal(t, uint32(9000), throttlers[0].minInterval)
+	})
+}
+
+func TestAudioOnlyCall(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	cfg := SessionConfig{
+		GroupID:   "test",
+		CallID:    "test",
+		UserID:    "userA",
+		SessionID: "sessionA",
+		AudioOnly: true,
+	}
+	require.True(t, server.isAudioOnlyCall(cfg))
+
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	_, err = server.addSession(cfg, peerConn, nil)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, server.CloseSession("sessionA"))
+	}()
+
+	// Following sessions inherit the setting of the call.
+	cfgB := cfg
+	cfgB.UserID = "userB"
+	cfgB.SessionID = "sessionB"
+	cfgB.AudioOnly = false
+	require.True(t, server.isAudioOnlyCall(cfgB))
+
+	state, err := server.GetCallState("test", "test")
+	require.NoError(t, err)
+	require.True(t, state.AudioOnly)
+
+	t.Run("video rejected", func(t *testing.T) {
+		m, err := initMediaEngine(server.cfg.RTX, true, nil)
+		require.NoError(t, err)
+		pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(m)).NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		defer pc.Close()
+
+		offerer, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		defer offerer.Close()
+		for _, codec := range []webrtc.RTPCodecCapability{rtpAudioCodec, rtpVideoCodecVP8} {
+			track, err := webrtc.NewTrackLocalStaticRTP(codec, "track", "stream")
+			require.NoError(t, err)
+			_, err = offerer.AddTrack(track)
+			require.NoError(t, err)
+		}
+		offer, err := offerer.CreateOffer(nil)
+		require.NoError(t, err)
+
+		require.NoError(t, pc.SetRemoteDescription(offer))
+		answer, err := pc.CreateAnswer(nil)
+		require.NoError(t, err)
+		require.Contains(t, answer.SDP, "m=video 0 ")
+		require.NotContains(t, answer.SDP, "VP8")
+		require.Contains(t, answer.SDP, "opus")
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"time"
 
 	"github.com/mattermost/rtcd/service/random"
@@ -16,6 +17,7 @@ import (
 	"github.com/pion/ice/v2"
 	"github.com/pion/interceptor"
 	"github.com/pion/interceptor/pkg/nack"
+	"github.com/pion/interceptor/pkg/report"
 	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v3"
 )
@@ -44,34 +46,64 @@ var (
 
 const (
 	nackResponderBufferSize = 256
+
+	rtpAudioCodecPayloadType    = 111
+	rtpVideoCodecVP8PayloadType = 96
 )
 
-func initMediaEngine() (*webrtc.MediaEngine, error) {
+// initMediaEngine registers the supported codecs. Video codecs are left out
+// for audio-only calls so that any video section of the offer gets rejected.
+func initMediaEngine(rtxCfg RTXConfig, audioOnly bool, exts []rtpHeaderExtension) (*webrtc.MediaEngine, error) {
 	var m webrtc.MediaEngine
+	if err := registerRTPHeaderExtensions(&m, exts); err != nil {
+		return nil, err
+	}
 	if err := m.RegisterCodec(webrtc.RTPCodecParameters{
 		RTPCodecCapability: rtpAudioCodec,
-		PayloadType:        111,
+		PayloadType:        rtpAudioCodecPayloadType,
 	}, webrtc.RTPCodecTypeAudio); err != nil {
 		return nil, err
 	}
+	if audioOnly {
+		return &m, nil
+	}
 	if err := m.RegisterCodec(webrtc.RTPCodecParameters{
 		RTPCodecCapability: rtpVideoCodecVP8,
-		PayloadType:        96,
+		PayloadType:        rtpVideoCodecVP8PayloadType,
 	}, webrtc.RTPCodecTypeVideo); err != nil {
 		return nil, err
 	}
+	if rtxCfg.Enable {
+		if err := m.RegisterCodec(webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:    rtxMimeType,
+				ClockRate:   rtpVideoCodecVP8.ClockRate,
+				SDPFmtpLine: fmt.Sprintf("apt=%d", rtpVideoCodecVP8PayloadType),
+			},
+			PayloadType: webrtc.PayloadType(rtxCfg.PayloadType),
+		}, webrtc.RTPCodecTypeVideo); err != nil {
+			return nil, err
+		}
+	}
 	return &m, nil
 }
 
-func initInterceptors(m *webrtc.MediaEngine) (*interceptor.Registry, error) {
+func initInterceptors(m *webrtc.MediaEngine, nackBufferSize uint16, rtx *rtxInterceptor, ssrcs *ssrcInterceptor, keyFrames *keyFrameInterceptor, capture *captureInterceptor, lanes *sendLaneInterceptor, exts []rtpHeaderExtension, aggregateReports bool) (*interceptor.Registry, error) {
 	var i interceptor.Registry
+
+	// RTX needs to come first so that repaired packets are seen by the NACK
+	// generator as well.
+	if rtx != nil {
+		i.Add(rtx)
+	}
+
 	generator, err := nack.NewGeneratorInterceptor()
 	if err != nil {
 		return nil, err
 	}
 
 	// NACK
-	responder, err := nack.NewResponderInterceptor(nack.ResponderSize(nackResponderBufferSize))
+	responder, err := nack.NewResponderInterceptor(nack.ResponderSize(nackBufferSize))
 	if err != nil {
 		return nil, err
 	}
@@ -80,26 +112,102 @@ func initInterceptors(m *webrtc.MediaEngine) (*interceptor.Registry, error) {
 	i.Add(responder)
 	i.Add(generator)
 
-	// RTCP Reports
-	if err := webrtc.ConfigureRTCPReports(&i); err != nil {
+	// RTCP Reports. When aggregated, the receiver reports are synthesized out
+	// of those sent by the subscribers of the forwarded tracks in place of
+	// the ones generated for the received streams.
+	if !aggregateReports {
+		receiver, err := report.NewReceiverInterceptor()
+		if err != nil {
+			return nil, err
+		}
+		i.Add(receiver)
+	}
+	sender, err := report.NewSenderInterceptor()
+	if err != nil {
 		return nil, err
 	}
+	i.Add(sender)
+
+	// Transport-wide congestion control is hop-by-hop: feedback is generated
+	// for the received packets and the sent ones get numbered again.
+	if hasRTPHeaderExtension(exts, RTPHeaderExtensionTransportCC) {
+		if err := webrtc.ConfigureTWCCSender(m, &i); err != nil {
+			return nil, err
+		}
+		if err := webrtc.ConfigureTWCCHeaderExtensionSender(m, &i); err != nil {
+			return nil, err
+		}
+	}
+
+	// Header extensions get rewritten before any other interceptor adds its
+	// own.
+	i.Add(&headerExtensionsInterceptor{})
+
+	// Cached key frames go through the header extensions rewriting and the
+	// NACK responder like forwarded packets.
+	if keyFrames != nil {
+		i.Add(keyFrames)
+	}
+
+	if ssrcs != nil {
+		i.Add(ssrcs)
+	}
+
+	if lanes != nil {
+		i.Add(lanes)
+	}
+
+	// Capture comes last so that it sees packets as they are read and
+	// written by the session.
+	if capture != nil {
+		i.Add(capture)
+	}
 
 	return &i, nil
 }
 
-func (s *Server) InitSession(cfg SessionConfig, closeCb func() error) error {
-	s.metrics.IncRTCSessions(cfg.GroupID, cfg.CallID)
+// InitSession creates a new session for the given config. The optional closeCb
+// is called once the session gets closed, along with the reason for it (empty
+// if closed normally). A trace ID is generated if the config doesn't set one.
+func (s *Server) InitSession(cfg SessionConfig, closeCb func(reason string) error) error {
+	s.mut.RLock()
+	draining := s.draining
+	s.mut.RUnlock()
+	if draining {
+		return ErrServerDraining
+	}
 
+	if s.isOverCapacity(cfg) {
+		return ErrServerBusy
+	}
+
+	if cfg.TraceID == "" {
+		cfg.TraceID = NewTraceID()
+	}
+
+	startedAt := time.Now()
+
+	s.mut.RLock()
+	turnSecret := s.cfg.TURNConfig.StaticAuthSecret
+	params := s.params
+	dtlsCert := s.dtlsCert
+	srtpProfiles := s.srtpProfiles
+	s.mut.RUnlock()
+
+	policy := s.getCallICEPolicy(cfg)
+	var hasTURN bool
 	iceServers := make([]webrtc.ICEServer, 0, len(s.cfg.ICEServers))
 	for _, iceCfg := range s.cfg.ICEServers {
+		if !policy.allowsServer(iceCfg) {
+			continue
+		}
 		// generating short-lived TURN credentials if needed.
-		if iceCfg.IsTURN() && s.cfg.TURNConfig.StaticAuthSecret == "" {
+		if iceCfg.IsTURN() && turnSecret == "" {
 			continue
 		}
 		if iceCfg.IsTURN() && iceCfg.Username == "" && iceCfg.Credential == "" {
 			ts := time.Now().Add(time.Duration(s.cfg.TURNConfig.CredentialsExpirationMinutes) * time.Minute).Unix()
-			username, password, err := genTURNCredentials(cfg.SessionID, s.cfg.TURNConfig.StaticAuthSecret, ts)
+			username, password, err := genTURNCredentials(cfg.SessionID, turnSecret, ts)
 			if err != nil {
 				s.log.Error("failed to generate TURN credentials", mlog.Err(err))
 				continue
@@ -112,28 +220,130 @@ func (s *Server) InitSession(cfg SessionConfig, closeCb func() error) error {
 			Username:   iceCfg.Username,
 			Credential: iceCfg.Credential,
 		})
+		hasTURN = hasTURN || iceCfg.IsTURN()
+	}
+	if (policy.usesRelay() || policy.TURNRegion != "") && !hasTURN {
+		return fmt.Errorf("no TURN server available: %w", ErrICEPolicyUnsatisfiable)
 	}
 
 	peerConnConfig := webrtc.Configuration{
 		ICEServers:   iceServers,
 		SDPSemantics: webrtc.SDPSemanticsUnifiedPlanWithFallback,
 	}
+	if policy.usesRelay() {
+		peerConnConfig.ICETransportPolicy = webrtc.ICETransportPolicyRelay
+	}
+	if dtlsCert != nil {
+		peerConnConfig.Certificates = []webrtc.Certificate{*dtlsCert}
+	}
 
-	m, err := initMediaEngine()
+	// Validated along with the config.
+	exts, _ := parseRTPHeaderExtensions(s.cfg.RTPHeaderExtensions)
+
+	m, err := initMediaEngine(s.cfg.RTX, s.isAudioOnlyCall(cfg), exts)
 	if err != nil {
 		return fmt.Errorf("failed to init media engine: %w", err)
 	}
 
-	i, err := initInterceptors(m)
+	var us *session
+	var rtx *rtxInterceptor
+	if s.cfg.RTX.Enable {
+		rtx = newRTXInterceptor(uint8(s.cfg.RTX.PayloadType), rtpVideoCodecVP8PayloadType, func(ssrc uint32, result string) {
+			trackType := "video"
+			if track := us.getRemoteScreenTrack(); track != nil && uint32(track.SSRC()) == ssrc {
+				trackType = "screen"
+			}
+			s.metrics.IncRTXPackets(trackType, result)
+		})
+	}
+
+	ssrcs := newSSRCInterceptor(func(ssrc uint32, direction, result string) {
+		if result == ssrcCollisionUnresolved {
+			s.log.Warn("SSRC collision", mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID),
+				mlog.Uint32("ssrc", ssrc), mlog.String("direction", direction))
+		}
+		s.metrics.IncSSRCCollisions(direction, result)
+	})
+
+	var keyFrames *keyFrameInterceptor
+	if s.cfg.KeyFrameCache.Enable {
+		keyFrames = &keyFrameInterceptor{
+			getKeyFrame: func(ssrc uint32, nextSeq uint16) []*rtp.Packet {
+				call := s.getSessionCall(cfg)
+				if call == nil {
+					return nil
+				}
+				kf := call.getKeyFrameCache(us.getTrackIDBySSRC(ssrc))
+				if kf == nil {
+					return nil
+				}
+				return kf.get(nextSeq)
+			},
+			onSent: func(_ uint32) {
+				go func() {
+					call := s.getSessionCall(cfg)
+					if call == nil {
+						return
+					}
+					if err := call.requestKeyFrame(s.GetRuntimeParams()); err != nil {
+						s.log.Debug("failed to request key frame", mlog.Err(err), mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
+					}
+				}()
+			},
+		}
+	}
+
+	var capture *captureInterceptor
+	if s.cfg.Capture.Dir != "" {
+		capture = &captureInterceptor{sessionID: cfg.SessionID}
+	}
+
+	var lanes *sendLaneInterceptor
+	if s.sendSched != nil {
+		lanes = &sendLaneInterceptor{sched: s.sendSched}
+	}
+
+	i, err := initInterceptors(m, params.getNACKBufferSize(), rtx, ssrcs, keyFrames, capture, lanes, exts, s.cfg.isReceiverReportAggregationEnabled())
 	if err != nil {
 		return fmt.Errorf("failed to init interceptors: %w", err)
 	}
 
 	sEngine := webrtc.SettingEngine{}
 	sEngine.SetICEMulticastDNSMode(ice.MulticastDNSModeDisabled)
-	sEngine.SetICEUDPMux(s.udpMux)
-	if s.cfg.ICEHostOverride != "" {
-		hostIP, err := resolveHost(s.cfg.ICEHostOverride, time.Second)
+	var migration *migrationDetector
+	if s.isFeatureEnabled(FeatureSessionMigration, cfg.GroupID, s.cfg.EnableSessionMigration) {
+		migration = newMigrationDetector(func(prevAddr, addr string) {
+			s.migrateSession(us, prevAddr, addr)
+		})
+		sEngine.SetICEUDPMux(&migrationUDPMux{UDPMux: s.udpMux, onPacket: migration.onPacket})
+	} else {
+		sEngine.SetICEUDPMux(s.udpMux)
+	}
+	sEngine.SetICETimeouts(s.cfg.ICETimeouts.getTimeouts())
+	if s.cfg.UDPSockets.ReceiveMTU > 0 {
+		sEngine.SetReceiveMTU(uint(s.cfg.UDPSockets.ReceiveMTU))
+	}
+	if s.vnet != nil {
+		sEngine.SetVNet(s.vnet)
+	}
+	if ports := s.cfg.LocalUDPPorts; ports.isEnabled() {
+		// Validated along with the config.
+		_ = sEngine.SetEphemeralUDPPortRange(uint16(ports.Min), uint16(ports.Max))
+	}
+	if len(s.cfg.Capacity.InterfaceBudgets) > 0 {
+		// Host candidates are only gathered on the interface the call was
+		// placed on.
+		sEngine.SetInterfaceFilter(func(name string) bool {
+			return s.callAllowsInterface(cfg, name)
+		})
+	}
+	if len(srtpProfiles) > 0 {
+		// Validated along with the config.
+		profiles, _ := parseSRTPProtectionProfiles(srtpProfiles)
+		sEngine.SetSRTPProtectionProfiles(profiles...)
+	}
+	if iceHost := s.getICEHost(); iceHost != "" {
+		hostIP, err := resolveHost(iceHost, time.Second)
 		if err != nil {
 			return fmt.Errorf("failed to resolve host: %w", err)
 		}
@@ -150,71 +360,136 @@ func (s *Server) InitSession(cfg SessionConfig, closeCb func() error) error {
 		return fmt.Errorf("failed to create peer connection: %w", err)
 	}
 
-	us, err := s.addSession(cfg, peerConn, closeCb)
+	us, err = s.addSession(cfg, peerConn, closeCb)
 	if err != nil {
 		// TODO: handle case session exists
+		peerConn.Close()
 		return fmt.Errorf("failed to add session: %w", err)
 	}
+	us.rtx = rtx
+	us.ssrcs = ssrcs
+	us.sdpHooks = s.getSDPHooks()
+	us.mdns = s.mdns
+	us.icePolicy = policy
+	us.migration = migration
+	us.iceRestartCh = make(chan struct{}, 1)
+	us.setJoinPhase(JoinPhaseWSAuth, startedAt)
 	group := s.getGroup(cfg.GroupID)
 	call := group.getCall(cfg.CallID)
+	if capture != nil {
+		capture.setCall(call)
+	}
 
-	peerConn.OnICECandidate(func(candidate *webrtc.ICECandidate) {
-		if candidate == nil {
-			return
-		}
-		msg, err := newICEMessage(us, candidate)
-		if err != nil {
-			s.log.Error("failed to create ICE message", mlog.Err(err), mlog.String("sessionID", cfg.SessionID))
-			return
-		}
-		select {
-		case s.receiveCh <- msg:
-		default:
-			s.log.Error("failed to send ICE message: channel is full", mlog.String("sessionID", cfg.SessionID))
-		}
-	})
+	us.candidates = &candidateBatcher{
+		filter:   s.candidateFilter,
+		interval: time.Duration(s.cfg.ICECandidates.BatchIntervalMs) * time.Millisecond,
+		send: func(candidates []*webrtc.ICECandidate) {
+			msg, err := newICECandidatesMessage(us, candidates)
+			if err != nil {
+				s.log.Error("failed to create ICE message", mlog.Err(err), mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
+				return
+			}
+			select {
+			case s.receiveCh <- msg:
+			default:
+				s.log.Error("failed to send ICE message: channel is full", mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
+			}
+		},
+		onDrop: func(candidate *webrtc.ICECandidate) {
+			s.log.Debug("dropping local ICE candidate", mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID),
+				mlog.String("address", candidate.Address), mlog.String("type", candidate.Typ.String()))
+		},
+	}
+
+	peerConn.OnICECandidate(us.candidates.add)
 
 	peerConn.OnICEGatheringStateChange(func(state webrtc.ICEGathererState) {
 		if state == webrtc.ICEGathererStateComplete {
-			s.log.Debug("ice gathering complete", mlog.String("sessionID", cfg.SessionID))
+			s.log.Debug("ice gathering complete", mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
 		}
 	})
 
 	peerConn.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		us.setConnected(state == webrtc.PeerConnectionStateConnected)
 		if state == webrtc.PeerConnectionStateConnected {
-			s.log.Debug("rtc connected!", mlog.String("sessionID", cfg.SessionID))
+			s.recordJoinPhase(us, JoinPhaseDTLSConnected)
+			s.log.Debug("rtc connected!", mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
 			s.metrics.IncRTCConnState("connected")
 		} else if state == webrtc.PeerConnectionStateDisconnected {
-			s.log.Debug("peer connection disconnected", mlog.String("sessionID", cfg.SessionID))
+			s.log.Debug("peer connection disconnected", mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
 			s.metrics.IncRTCConnState("disconnected")
 		} else if state == webrtc.PeerConnectionStateFailed {
-			s.log.Debug("peer connection failed", mlog.String("sessionID", cfg.SessionID))
+			s.log.Debug("peer connection failed", mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
 			s.metrics.IncRTCConnState("failed")
 		} else if state == webrtc.PeerConnectionStateClosed {
-			s.log.Debug("peer connection closed", mlog.String("sessionID", cfg.SessionID))
+			s.log.Debug("peer connection closed", mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
 			s.metrics.IncRTCConnState("closed")
 		}
+		var reason string
 		switch state {
-		case webrtc.PeerConnectionStateClosed, webrtc.PeerConnectionStateFailed:
-			if err := s.CloseSession(cfg.SessionID); err != nil {
+		case webrtc.PeerConnectionStateClosed:
+			reason = CloseReasonConnectionClosed
+		case webrtc.PeerConnectionStateFailed:
+			reason = CloseReasonNetworkTimeout
+		}
+		if reason != "" {
+			if err := s.CloseSessionWithReason(cfg.SessionID, reason); err != nil {
 				s.log.Error("failed to close RTC session", mlog.Err(err), mlog.Any("sessionCfg", cfg))
 			}
 		}
 	})
 
+	if migration != nil || s.sendSched != nil {
+		peerConn.SCTP().Transport().ICETransport().OnSelectedCandidatePairChange(func(pair *webrtc.ICECandidatePair) {
+			if s.sendSched != nil {
+				s.sendSched.setFlow(cfg.SessionID, net.ParseIP(pair.Remote.Address), int(pair.Remote.Port), cfg.GroupID+"/"+cfg.CallID)
+			}
+			if migration == nil {
+				return
+			}
+			m := migration.setSelectedPair(pair)
+			if m == nil {
+				return
+			}
+			s.log.Info("session migrated", mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID),
+				mlog.String("previousAddress", m.PreviousAddress), mlog.String("address", m.Address))
+			ev := newEvent(SessionMigratedEvent, cfg)
+			ev.Migration = m
+			s.sendEvent(ev)
+		})
+	}
+
 	peerConn.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
-		if state == webrtc.ICEConnectionStateDisconnected {
-			s.log.Debug("ice disconnected", mlog.String("sessionID", cfg.SessionID))
+		if migration != nil {
+			migration.setICEState(state)
+		}
+		if state == webrtc.ICEConnectionStateConnected {
+			s.recordJoinPhase(us, JoinPhaseICEConnected)
+		} else if state == webrtc.ICEConnectionStateDisconnected {
+			s.log.Debug("ice disconnected", mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
 		} else if state == webrtc.ICEConnectionStateFailed {
-			s.log.Debug("ice failed", mlog.String("sessionID", cfg.SessionID))
+			s.log.Debug("ice failed", mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
 		} else if state == webrtc.ICEConnectionStateClosed {
-			s.log.Debug("ice closed", mlog.String("sessionID", cfg.SessionID))
+			s.log.Debug("ice closed", mlog.String("groupID", cfg.GroupID), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
 		}
 	})
 
 	peerConn.OnTrack(func(remoteTrack *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		defer s.crash.Recover("rtc.session.track", func() { s.closePanickedSession(cfg.SessionID) })
+
+		if us.cfg.Hidden {
+			s.log.Debug("ignoring track sent by hidden session", mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+			return
+		}
+
+		if call.audioOnly && remoteTrack.Kind() == webrtc.RTPCodecTypeVideo {
+			s.log.Debug("ignoring video track in audio-only call", mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+			return
+		}
+
 		streamID := remoteTrack.StreamID()
 		trackType := remoteTrack.Codec().MimeType
+		usage := s.getGroupUsage(us.cfg.GroupID)
 
 		s.log.Debug("new track received",
 			mlog.Any("codec", remoteTrack.Codec().RTPCodecCapability),
@@ -223,9 +498,14 @@ func (s *Server) InitSession(cfg SessionConfig, closeCb func() error) error {
 			mlog.String("streamID", streamID),
 			mlog.String("remoteTrackID", remoteTrack.ID()),
 			mlog.Int("SSRC", int(remoteTrack.SSRC())),
-			mlog.String("sessionID", us.cfg.SessionID),
+			mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID),
+			mlog.String("traceID", us.cfg.TraceID),
 		)
 
+		// Header extensions are forwarded with the registry IDs, rewritten
+		// for each subscriber when sent.
+		extsMap := newInboundRTPHeaderExtensionsMap(receiver.GetParameters().HeaderExtensions)
+
 		var screenStreamID string
 		if screenSession := call.getScreenSession(); screenSession != nil {
 			screenStreamID = screenSession.getScreenStreamID()
@@ -234,13 +514,14 @@ func (s *Server) InitSession(cfg SessionConfig, closeCb func() error) error {
 		if trackType == rtpAudioCodec.MimeType {
 			trackType := "voice"
 			if streamID == screenStreamID {
-				s.log.Debug("received screen sharing audio track", mlog.String("sessionID", us.cfg.SessionID))
+				s.log.Debug("received screen sharing audio track", mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 				trackType = "screen-audio"
 			}
+			trackTypeIdx := rtpTrackTypeIndex(trackType)
 
 			outAudioTrack, err := webrtc.NewTrackLocalStaticRTP(rtpAudioCodec, genTrackID(trackType, us.cfg.SessionID), random.NewID())
 			if err != nil {
-				s.log.Error("failed to create local track", mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID))
+				s.log.Error("failed to create local track", mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 				return
 			}
 
@@ -253,6 +534,21 @@ func (s *Server) InitSession(cfg SessionConfig, closeCb func() error) error {
 			}
 			us.mut.Unlock()
 
+			defer s.trackReceiverReports(us, call, remoteTrack, outAudioTrack)()
+
+			uplink := &uplinkLoss{}
+			call.addUplinkLoss(outAudioTrack.ID(), uplink)
+			defer call.removeUplinkLoss(outAudioTrack.ID())
+
+			uplinkDelay := newUplinkDelay(receiver.GetParameters().HeaderExtensions)
+			if uplinkDelay != nil {
+				call.addUplinkDelay(outAudioTrack.ID(), uplinkDelay)
+				defer call.removeUplinkDelay(outAudioTrack.ID())
+			}
+
+			onPacket, stopActivity := s.monitorTrackActivity(us, outAudioTrack.ID())
+			defer stopActivity()
+
 			call.iterSessions(func(ss *session) {
 				if ss.cfg.UserID == us.cfg.UserID {
 					return
@@ -265,67 +561,127 @@ func (s *Server) InitSession(cfg SessionConfig, closeCb func() error) error {
 				}
 			})
 
+			// forward hands a packet to the subscribers. buf is returned to
+			// the pool once done.
+			forward := func(pkt *rtp.Packet, buf []byte) error {
+				defer s.bufPool.Put(buf)
+
+				if trackType == "voice" {
+					us.mut.RLock()
+					isEnabled := us.outVoiceTrackEnabled
+					us.mut.RUnlock()
+					if !isEnabled {
+						return nil
+					}
+
+					if t := call.getTranscriber(); t != nil {
+						t.push(us.cfg, pkt)
+					}
+
+					if hs := call.getHLSStream(); hs != nil && hs.sessionID == us.cfg.SessionID {
+						hs.writeVoice(pkt)
+					}
+				}
+
+				if rec := us.getRecording(); rec != nil {
+					rec.writeRTP(trackType, pkt)
+				}
+
+				if call.egress != nil {
+					// Audio is always forwarded, taking from the bandwidth left
+					// to video.
+					call.egress.force(len(pkt.Payload)*call.countSubscribers(us.cfg.UserID), time.Now())
+				}
+
+				pkt.Header = extsMap.rewrite(pkt.Header)
+				if err := outAudioTrack.WriteRTP(pkt); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+					return err
+				}
+				pLen := len(pkt.Payload)
+
+				call.iterSessions(func(ss *session) {
+					if ss.cfg.UserID == us.cfg.UserID {
+						return
+					}
+					us.rtpCounters.add(rtpDirectionOut, trackTypeIdx, pLen)
+					call.stats.addForwardedBytes(pLen)
+					usage.addEgress(pLen)
+				})
+
+				return nil
+			}
+
+			var jb *jitterBuffer
+			if delay := s.cfg.JitterBuffer.AudioDelayMs; delay > 0 {
+				jb = newJitterBuffer(time.Duration(delay)*time.Millisecond, rtpAudioCodec.ClockRate, func(pkt *rtp.Packet, buf []byte) {
+					if err := forward(pkt, buf); err != nil {
+						s.log.Error("failed to write RTP packet",
+							mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+						s.metrics.IncRTCErrors(us.cfg.GroupID, "rtp")
+					}
+				})
+				defer jb.close()
+			}
+
+			var gotRTP bool
 			for {
 				buf := s.bufPool.Get().([]byte)
 				i, _, err := remoteTrack.Read(buf)
 				if err != nil {
 					s.log.Error("failed to read RTP packet",
-						mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID))
+						mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 					s.metrics.IncRTCErrors(us.cfg.GroupID, "rtp")
 					return
 				}
+				if !gotRTP {
+					gotRTP = true
+					s.recordJoinPhase(us, JoinPhaseFirstRTP)
+				}
 
 				rtp := &rtp.Packet{}
 				if err := rtp.Unmarshal(buf[:i]); err != nil {
 					s.log.Error("failed to unmarshal RTP packet",
-						mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID))
+						mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 					s.metrics.IncRTCErrors(us.cfg.GroupID, "rtp")
 					return
 				}
 
-				s.metrics.IncRTPPackets("in", trackType)
-				s.metrics.AddRTPPacketBytes("in", trackType, len(rtp.Payload))
+				us.rtpCounters.add(rtpDirectionIn, trackTypeIdx, len(rtp.Payload))
+				usage.addIngress(len(rtp.Payload))
+				now := time.Now()
+				uplink.onPacket(rtp.SequenceNumber, now)
+				if uplinkDelay != nil && uplinkDelay.onPacket(&rtp.Header, now) {
+					s.onUplinkDelay(us, uplinkDelay)
+				}
+				if onPacket != nil {
+					onPacket(now)
+				}
 
-				if trackType == "voice" {
-					us.mut.RLock()
-					isEnabled := us.outVoiceTrackEnabled
-					us.mut.RUnlock()
-					if !isEnabled {
-						s.bufPool.Put(buf)
-						continue
-					}
+				if jb != nil {
+					jb.push(rtp, buf)
+					continue
 				}
 
-				if err := outAudioTrack.WriteRTP(rtp); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+				if err := forward(rtp, buf); err != nil {
 					s.log.Error("failed to write RTP packet",
-						mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID))
+						mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 					s.metrics.IncRTCErrors(us.cfg.GroupID, "rtp")
 					return
 				}
-				pLen := len(rtp.Payload)
-				s.bufPool.Put(buf)
-
-				call.iterSessions(func(ss *session) {
-					if ss.cfg.UserID == us.cfg.UserID {
-						return
-					}
-					s.metrics.IncRTPPackets("out", trackType)
-					s.metrics.AddRTPPacketBytes("out", trackType, pLen)
-				})
 			}
 		} else if trackType == rtpVideoCodecVP8.MimeType {
 			if screenStreamID != "" && screenStreamID != streamID {
 				s.log.Error("received unexpected video track",
-					mlog.String("streamID", streamID), mlog.String("sessionID", us.cfg.SessionID))
+					mlog.String("streamID", streamID), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 				return
 			}
 
-			s.log.Debug("received screen sharing stream", mlog.String("streamID", streamID), mlog.String("sessionID", us.cfg.SessionID))
+			s.log.Debug("received screen sharing stream", mlog.String("streamID", streamID), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 
 			outScreenTrack, err := webrtc.NewTrackLocalStaticRTP(rtpVideoCodecVP8, genTrackID("screen", us.cfg.SessionID), random.NewID())
 			if err != nil {
 				s.log.Error("failed to create local track",
-					mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID))
+					mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 				return
 			}
 			us.mut.Lock()
@@ -333,6 +689,30 @@ func (s *Server) InitSession(cfg SessionConfig, closeCb func() error) error {
 			us.remoteScreenTrack = remoteTrack
 			us.mut.Unlock()
 
+			go s.capScreenBitrate(us, remoteTrack)
+			defer s.trackReceiverReports(us, call, remoteTrack, outScreenTrack)()
+
+			var keyFrames *keyFrameCache
+			if s.cfg.KeyFrameCache.Enable {
+				keyFrames = newKeyFrameCache(s.cfg.KeyFrameCache.MaxSizeKB * 1024)
+				call.addKeyFrameCache(outScreenTrack.ID(), keyFrames)
+				defer call.removeKeyFrameCache(outScreenTrack.ID())
+			}
+
+			var shaper *videoShaper
+			if call.egress != nil {
+				shaper = newVideoShaper(call.egress)
+			}
+
+			uplinkDelay := newUplinkDelay(receiver.GetParameters().HeaderExtensions)
+			if uplinkDelay != nil {
+				call.addUplinkDelay(outScreenTrack.ID(), uplinkDelay)
+				defer call.removeUplinkDelay(outScreenTrack.ID())
+			}
+
+			onPacket, stopActivity := s.monitorTrackActivity(us, outScreenTrack.ID())
+			defer stopActivity()
+
 			call.iterSessions(func(ss *session) {
 				if ss.cfg.UserID == us.cfg.UserID {
 					return
@@ -342,68 +722,152 @@ func (s *Server) InitSession(cfg SessionConfig, closeCb func() error) error {
 				default:
 					s.log.Error("failed to send screen track: channel is full",
 						mlog.String("UserID", us.cfg.UserID),
-						mlog.String("sessionID", us.cfg.SessionID),
+						mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID),
+						mlog.String("traceID", us.cfg.TraceID),
 						mlog.String("trackUserID", ss.cfg.UserID),
 						mlog.String("trackSessionID", ss.cfg.SessionID),
 					)
 				}
 			})
 
+			forward := func(pkt *rtp.Packet) error {
+				if rec := us.getRecording(); rec != nil {
+					rec.writeRTP("screen", pkt)
+				}
+
+				if hs := call.getHLSStream(); hs != nil && hs.sessionID == us.cfg.SessionID {
+					hs.writeScreen(pkt)
+				}
+
+				if shaper != nil {
+					ok, requestKeyFrame := shaper.shape(pkt, call.countSubscribers(us.cfg.UserID), time.Now())
+					if requestKeyFrame {
+						go func() {
+							if err := call.requestKeyFrame(s.GetRuntimeParams()); err != nil {
+								s.log.Debug("failed to request key frame", mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+							}
+						}()
+					}
+					if !ok {
+						s.metrics.IncEgressShapedPackets("screen")
+						return nil
+					}
+				}
+
+				pkt.Header = extsMap.rewrite(pkt.Header)
+				if err := outScreenTrack.WriteRTP(pkt); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+					return err
+				}
+				// Cached after being written so that new subscribers get the
+				// packets following the key frame.
+				if keyFrames != nil {
+					keyFrames.push(pkt)
+				}
+
+				for _, t := range call.getFrameThrottlers(outScreenTrack.ID()) {
+					if err := t.writeRTP(pkt); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+						s.log.Error("failed to write RTP packet",
+							mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+						s.metrics.IncRTCErrors(us.cfg.GroupID, "rtp")
+					}
+				}
+
+				call.iterSessions(func(ss *session) {
+					if ss.cfg.UserID == us.cfg.UserID {
+						return
+					}
+					us.rtpCounters.add(rtpDirectionOut, rtpTrackTypeScreen, len(pkt.Payload))
+					call.stats.addForwardedBytes(len(pkt.Payload))
+					usage.addEgress(len(pkt.Payload))
+				})
+
+				return nil
+			}
+
+			var jb *jitterBuffer
+			if window := s.cfg.JitterBuffer.VideoReorderWindowMs; window > 0 {
+				jb = newJitterBuffer(time.Duration(window)*time.Millisecond, 0, func(pkt *rtp.Packet, _ []byte) {
+					if err := forward(pkt); err != nil {
+						s.log.Error("failed to write RTP packet",
+							mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+						s.metrics.IncRTCErrors(us.cfg.GroupID, "rtp")
+					}
+				})
+				defer jb.close()
+			}
+
+			var gotRTP bool
 			for {
 				rtp, _, readErr := remoteTrack.ReadRTP()
 				if readErr != nil {
 					s.log.Error("failed to read RTP packet",
-						mlog.Err(readErr), mlog.String("sessionID", us.cfg.SessionID))
+						mlog.Err(readErr), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 					s.metrics.IncRTCErrors(us.cfg.GroupID, "rtp")
 					return
 				}
+				if !gotRTP {
+					gotRTP = true
+					s.recordJoinPhase(us, JoinPhaseFirstRTP)
+				}
+
+				us.rtpCounters.add(rtpDirectionIn, rtpTrackTypeScreen, len(rtp.Payload))
+				usage.addIngress(len(rtp.Payload))
+				now := time.Now()
+				if uplinkDelay != nil && uplinkDelay.onPacket(&rtp.Header, now) {
+					s.onUplinkDelay(us, uplinkDelay)
+				}
+				if onPacket != nil {
+					onPacket(now)
+				}
 
-				s.metrics.IncRTPPackets("in", "screen")
-				s.metrics.AddRTPPacketBytes("in", "screen", len(rtp.Payload))
+				if jb != nil {
+					jb.push(rtp, nil)
+					continue
+				}
 
-				if err := outScreenTrack.WriteRTP(rtp); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+				if err := forward(rtp); err != nil {
 					s.log.Error("failed to write RTP packet",
-						mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID))
+						mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 					s.metrics.IncRTCErrors(us.cfg.GroupID, "rtp")
 					return
 				}
-
-				call.iterSessions(func(ss *session) {
-					if ss.cfg.UserID == us.cfg.UserID {
-						return
-					}
-					s.metrics.IncRTPPackets("out", "screen")
-					s.metrics.AddRTPPacketBytes("out", "screen", len(rtp.Payload))
-				})
 			}
 		}
 	})
 
 	go func() {
+		defer s.crash.Recover("rtc.session.signaling", func() { s.closePanickedSession(cfg.SessionID) })
+
 		select {
 		case offer, ok := <-us.sdpOfferInCh:
 			if !ok {
 				return
 			}
+			s.recordJoinPhase(us, JoinPhaseOfferReceived)
 			if err := us.signaling(offer, s.receiveCh); err != nil {
 				s.metrics.IncRTCErrors(cfg.GroupID, "signaling")
 				s.log.Error("failed to signal", mlog.Err(err), mlog.Any("sessionCfg", us.cfg))
 				return
 			}
+			s.recordJoinPhase(us, JoinPhaseAnswerSent)
 		case <-time.After(signalingTimeout):
 			s.log.Error("timed out signaling", mlog.Any("sessionCfg", us.cfg))
 			s.metrics.IncRTCErrors(cfg.GroupID, "signaling")
-			if err := s.CloseSession(cfg.SessionID); err != nil {
+			if err := s.CloseSessionWithReason(cfg.SessionID, CloseReasonSignalingTimeout); err != nil {
 				s.log.Error("failed to close session", mlog.Any("sessionCfg", us.cfg))
 			}
 			return
 		}
 
-		go us.handleICE(s.log, s.metrics)
+		go func() {
+			defer s.crash.Recover("rtc.session.ice", func() { s.closePanickedSession(cfg.SessionID) })
+			us.handleICE(s.log, s.metrics)
+		}()
 
 		go func() {
+			defer s.crash.Recover("rtc.session.tracks", func() { s.closePanickedSession(cfg.SessionID) })
 			if err := s.handleTracks(call, us); err != nil {
-				s.log.Error("handleTracks failed", mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID))
+				s.log.Error("handleTracks failed", mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 			}
 		}()
 	}()
@@ -411,7 +875,21 @@ func (s *Server) InitSession(cfg SessionConfig, closeCb func() error) error {
 	return nil
 }
 
+// CloseSession closes the given session as if its client left the call.
 func (s *Server) CloseSession(sessionID string) error {
+	return s.closeSession(sessionID, CloseReasonLeft)
+}
+
+// CloseSessionWithReason closes the given session, reporting the given
+// reason (e.g. CloseReasonKicked) to its client and in the SessionLeftEvent.
+func (s *Server) CloseSessionWithReason(sessionID, reason string) error {
+	if reason == "" {
+		return fmt.Errorf("invalid reason: should not be empty")
+	}
+	return s.closeSession(sessionID, reason)
+}
+
+func (s *Server) closeSession(sessionID, reason string) error {
 	s.mut.Lock()
 	cfg, ok := s.sessions[sessionID]
 	delete(s.sessions, sessionID)
@@ -428,6 +906,11 @@ func (s *Server) CloseSession(sessionID string) error {
 	}
 
 	s.metrics.DecRTCSessions(cfg.GroupID, cfg.CallID)
+	s.metrics.IncRTCSessionCloses(reason)
+
+	if s.sendSched != nil {
+		s.sendSched.removeFlow(cfg.SessionID)
+	}
 
 	group := s.getGroup(cfg.GroupID)
 	if group == nil {
@@ -442,12 +925,24 @@ func (s *Server) CloseSession(sessionID string) error {
 		return fmt.Errorf("session not found: %s", cfg.SessionID)
 	}
 
+	s.recordSessionSLO(session, reason, time.Now())
+
 	call.mut.Lock()
 	if session == call.screenSession {
 		call.screenSession = nil
 	}
 	delete(call.sessions, cfg.SessionID)
-	if len(call.sessions) == 0 {
+	for _, throttlers := range call.frameThrottlers {
+		delete(throttlers, cfg.SessionID)
+	}
+	var t *transcriber
+	var cpt *capture
+	callEnded := len(call.sessions) == 0
+	if callEnded {
+		t = call.transcriber
+		call.transcriber = nil
+		call.transcriberStarting = false
+		cpt = call.capture
 		group.mut.Lock()
 		delete(group.calls, cfg.CallID)
 		if len(group.calls) == 0 {
@@ -459,11 +954,50 @@ func (s *Server) CloseSession(sessionID string) error {
 	}
 	call.mut.Unlock()
 
+	// Only added once the session can no longer be found through the call,
+	// so that it never gets counted twice.
+	session.rtpCounters.addTo(&s.rtpStats.closed)
+
+	if cpt != nil {
+		s.stopCapture(call, cpt, "call ended")
+	}
+
+	if rec := session.getRecording(); rec != nil {
+		s.stopSessionRecording(session, rec, "session ended")
+	}
+
+	if hs := call.getHLSStream(); hs != nil && hs.sessionID == cfg.SessionID {
+		s.stopHLS(call, hs, "session ended")
+	}
+
+	if a := session.getAnnouncer(); a != nil {
+		a.close()
+	}
+
+	if t != nil {
+		if err := t.close(); err != nil {
+			s.log.Error("failed to close transcriber", mlog.Err(err), mlog.String("callID", cfg.CallID))
+		}
+		s.sendEvent(newCallEvent(TranscriptionStoppedEvent, cfg.GroupID, cfg.CallID))
+	}
+
+	if !cfg.Hidden {
+		ev := newEvent(SessionLeftEvent, cfg)
+		ev.Reason = reason
+		s.sendEvent(ev)
+	}
+	if callEnded {
+		s.sendEvent(newCallEvent(CallEndedEvent, cfg.GroupID, cfg.CallID))
+	}
+
 	session.rtcConn.Close()
+	if session.candidates != nil {
+		session.candidates.close()
+	}
 	close(session.closeCh)
 
 	if session.closeCb != nil {
-		return session.closeCb()
+		return session.closeCb(reason)
 	}
 
 	return nil
@@ -485,21 +1019,21 @@ func (s *Server) handleTracks(call *call, us *session) error {
 		ss.mut.RUnlock()
 
 		if outVoiceTrack != nil {
-			if err := us.addTrack(s.log, call, s.receiveCh, outVoiceTrack); err != nil {
+			if err := us.addTrack(s.log, call, s.receiveCh, outVoiceTrack, s.GetRuntimeParams, s.onStreamQualityUpdate); err != nil {
 				s.metrics.IncRTCErrors(us.cfg.GroupID, "track")
-				s.log.Error("failed to add voice track", mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID))
+				s.log.Error("failed to add voice track", mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 			}
 		}
 		if outScreenTrack != nil {
-			if err := us.addTrack(s.log, call, s.receiveCh, outScreenTrack); err != nil {
+			if err := us.addTrack(s.log, call, s.receiveCh, outScreenTrack, s.GetRuntimeParams, s.onStreamQualityUpdate); err != nil {
 				s.metrics.IncRTCErrors(us.cfg.GroupID, "track")
-				s.log.Error("failed to add screen track", mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID))
+				s.log.Error("failed to add screen track", mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 			}
 		}
 		if outScreenAudioTrack != nil {
-			if err := us.addTrack(s.log, call, s.receiveCh, outScreenAudioTrack); err != nil {
+			if err := us.addTrack(s.log, call, s.receiveCh, outScreenAudioTrack, s.GetRuntimeParams, s.onStreamQualityUpdate); err != nil {
 				s.metrics.IncRTCErrors(us.cfg.GroupID, "track")
-				s.log.Error("failed to add screen audio track", mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID))
+				s.log.Error("failed to add screen audio track", mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 			}
 		}
 	})
@@ -510,9 +1044,9 @@ func (s *Server) handleTracks(call *call, us *session) error {
 			if !ok {
 				return nil
 			}
-			if err := us.addTrack(s.log, call, s.receiveCh, track); err != nil {
+			if err := us.addTrack(s.log, call, s.receiveCh, track, s.GetRuntimeParams, s.onStreamQualityUpdate); err != nil {
 				s.metrics.IncRTCErrors(us.cfg.GroupID, "track")
-				s.log.Error("failed to add track", mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID))
+				s.log.Error("failed to add track", mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 				continue
 			}
 		case offer, ok := <-us.sdpOfferInCh:
@@ -522,11 +1056,40 @@ func (s *Server) handleTracks(call *call, us *session) error {
 
 			if err := us.signaling(offer, s.receiveCh); err != nil {
 				s.metrics.IncRTCErrors(us.cfg.GroupID, "signaling")
-				s.log.Error("failed to signal", mlog.Err(err), mlog.String("sessionID", us.cfg.SessionID))
+				s.log.Error("failed to signal", mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
+				continue
+			}
+		case <-us.iceRestartCh:
+			if err := us.restartICE(s.receiveCh); err != nil {
+				us.migration.reset()
+				s.metrics.IncRTCErrors(us.cfg.GroupID, "signaling")
+				s.log.Error("failed to restart ICE", mlog.Err(err), mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID))
 				continue
 			}
+			s.sendEvent(newEvent(ICERestartedEvent, us.cfg))
 		case <-us.closeCh:
 			return nil
 		}
 	}
 }
+
+// migrateSession re-anchors a session whose client started sending from a
+// new network address by restarting ICE, provided the DTLS association
+// survived.
+func (s *Server) migrateSession(us *session, prevAddr, addr string) {
+	if state := us.rtcConn.SCTP().Transport().State(); state != webrtc.DTLSTransportStateConnected {
+		s.log.Debug("not migrating session: DTLS is not connected", mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID),
+			mlog.String("dtlsState", state.String()))
+		us.migration.reset()
+		return
+	}
+
+	s.log.Debug("client address changed, restarting ICE", mlog.String("groupID", us.cfg.GroupID), mlog.String("sessionID", us.cfg.SessionID), mlog.String("traceID", us.cfg.TraceID),
+		mlog.String("previousAddress", prevAddr), mlog.String("address", addr))
+
+	select {
+	case us.iceRestartCh <- struct{}{}:
+	default:
+		us.migration.reset()
+	}
+}
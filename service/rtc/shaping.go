@@ -0,0 +1,152 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+)
+
+// tokenBucket limits the bytes sent over time: tokens accrue at a given rate
+// up to the burst size, each byte sent taking one.
+type tokenBucket struct {
+	// rate is the number of tokens accrued per second.
+	rate  float64
+	burst float64
+
+	tokens float64
+	last   time.Time
+
+	mut sync.Mutex
+}
+
+func newTokenBucket(cfg EgressShapingConfig, now time.Time) *tokenBucket {
+	burst := float64(cfg.BurstKB * 1024)
+	return &tokenBucket{
+		rate:   float64(cfg.RateKbps) * 1000 / 8,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+func (b *tokenBucket) refillLocked(now time.Time) {
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// take takes n tokens if as many are available, returning whether it did.
+func (b *tokenBucket) take(n int, now time.Time) bool {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.refillLocked(now)
+	if b.tokens < float64(n) {
+		return false
+	}
+	b.tokens -= float64(n)
+	return true
+}
+
+// force takes n tokens whether or not they are available, the bucket going
+// into debt, bounded by the burst size, for the following packets.
+func (b *tokenBucket) force(n int, now time.Time) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.refillLocked(now)
+	b.tokens -= float64(n)
+	if b.tokens < -b.burst {
+		b.tokens = -b.burst
+	}
+}
+
+// available returns whether any token is available.
+func (b *tokenBucket) available(now time.Time) bool {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.refillLocked(now)
+	return b.tokens > 0
+}
+
+// videoShaper drops the packets of a video track forwarded on a call whose
+// egress bucket is empty. Frames following a dropped one can't be decoded,
+// so whole frames are dropped until a key frame fits in the bucket again.
+type videoShaper struct {
+	bucket *tokenBucket
+
+	started bool
+	// frameTS is the timestamp of the frame currently being processed.
+	frameTS   uint32
+	dropFrame bool
+	// dropping is set once a frame got dropped, until the next key frame.
+	dropping bool
+	// seqOffset is the number of dropped packets, used to rewrite sequence
+	// numbers so that subscribers don't ask for the dropped ones.
+	seqOffset uint16
+
+	mut sync.Mutex
+}
+
+func newVideoShaper(bucket *tokenBucket) *videoShaper {
+	return &videoShaper{
+		bucket: bucket,
+	}
+}
+
+// shape returns whether the given packet, sent to as many subscribers, should
+// be forwarded, rewriting its sequence number if so, and whether a key frame
+// should be requested from the publisher. It expects packets in the order
+// they are received from the publisher.
+func (s *videoShaper) shape(pkt *rtp.Packet, subscribers int, now time.Time) (bool, bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var requestKeyFrame bool
+	if !s.started || pkt.Timestamp != s.frameTS {
+		s.started = true
+		s.frameTS = pkt.Timestamp
+		if s.dropping && isVP8KeyFrameStart(pkt) {
+			if s.bucket.available(now) {
+				s.dropping = false
+			} else {
+				// The key frame is wasted, the next one is needed.
+				requestKeyFrame = true
+			}
+		}
+		s.dropFrame = s.dropping
+	}
+
+	if !s.dropFrame && !s.bucket.take(len(pkt.Payload)*subscribers, now) {
+		s.dropFrame = true
+		s.dropping = true
+		requestKeyFrame = true
+	}
+
+	if s.dropFrame {
+		s.seqOffset++
+		return false, requestKeyFrame
+	}
+
+	pkt.SequenceNumber -= s.seqOffset
+	return true, requestKeyFrame
+}
+
+// isVP8KeyFrameStart returns whether the packet holds the start of a VP8 key
+// frame.
+func isVP8KeyFrameStart(pkt *rtp.Packet) bool {
+	var vp8 codecs.VP8Packet
+	payload, err := vp8.Unmarshal(pkt.Payload)
+	if err != nil || vp8.S != 1 || vp8.PID != 0 {
+		return false
+	}
+	_, _, key := vp8KeyFrameSize(payload)
+	return key
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	// 1000 bytes per second, up to 1024.
+	b := newTokenBucket(EgressShapingConfig{Enable: true, RateKbps: 8, BurstKB: 1}, now)
+
+	t.Run("burst", func(t *testing.T) {
+		require.True(t, b.take(1024, now))
+		require.False(t, b.take(1, now))
+		require.False(t, b.available(now))
+	})
+
+	t.Run("refill", func(t *testing.T) {
+		now = now.Add(100 * time.Millisecond)
+		require.False(t, b.take(101, now))
+		require.True(t, b.take(100, now))
+
+		// Tokens are capped to the burst size.
+		now = now.Add(time.Hour)
+		require.True(t, b.take(1024, now))
+		require.False(t, b.take(1, now))
+	})
+
+	t.Run("force", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		b.force(5000, now)
+		// The debt is capped to the burst size.
+		now = now.Add(time.Second)
+		require.False(t, b.available(now))
+		now = now.Add(time.Second)
+		require.True(t, b.available(now))
+	})
+}
+
+func TestVideoShaper(t *testing.T) {
+	now := time.Now()
+	// 1000 bytes per second, up to 1024, each packet being sent to 50
+	// subscribers.
+	const subscribers = 50
+	s := newVideoShaper(newTokenBucket(EgressShapingConfig{Enable: true, RateKbps: 8, BurstKB: 1}, now))
+
+	shape := func(pkt *rtp.Packet) (bool, bool) {
+		return s.shape(pkt, subscribers, now)
+	}
+
+	// 200 bytes per packet, the sixth one doesn't fit.
+	pkt := newVP8FramePacket(1, 100, false, vp8DeltaFrameStart)
+	ok, requestKeyFrame := shape(pkt)
+	require.True(t, ok)
+	require.False(t, requestKeyFrame)
+	require.Equal(t, uint16(1), pkt.SequenceNumber)
+	for seq := uint16(2); seq <= 5; seq++ {
+		ok, requestKeyFrame = shape(newVP8FramePacket(seq, 100, false, vp8FrameContinuation))
+		require.True(t, ok)
+		require.False(t, requestKeyFrame)
+	}
+	ok, requestKeyFrame = shape(newVP8FramePacket(6, 100, true, vp8FrameContinuation))
+	require.False(t, ok)
+	require.True(t, requestKeyFrame)
+
+	// Frames keep being dropped until the next key frame, even once the
+	// bucket refilled.
+	now = now.Add(time.Second)
+	ok, requestKeyFrame = shape(newVP8FramePacket(7, 200, true, vp8DeltaFrameStart))
+	require.False(t, ok)
+	require.False(t, requestKeyFrame)
+
+	pkt = newVP8FramePacket(8, 300, false, vp8KeyFrameStart)
+	ok, requestKeyFrame = shape(pkt)
+	require.True(t, ok)
+	require.False(t, requestKeyFrame)
+	require.Equal(t, uint16(6), pkt.SequenceNumber)
+	pkt = newVP8FramePacket(9, 300, true, vp8FrameContinuation)
+	ok, _ = shape(pkt)
+	require.True(t, ok)
+	require.Equal(t, uint16(7), pkt.SequenceNumber)
+
+	// A key frame arriving while the bucket is empty gets dropped and a new
+	// one requested.
+	s.bucket.force(2048, now)
+	ok, requestKeyFrame = shape(newVP8FramePacket(10, 400, true, vp8DeltaFrameStart))
+	require.False(t, ok)
+	require.True(t, requestKeyFrame)
+	ok, requestKeyFrame = shape(newVP8FramePacket(11, 500, true, vp8KeyFrameStart))
+	require.False(t, ok)
+	require.True(t, requestKeyFrame)
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/pion/ice/v2"
+	"github.com/pion/stun"
+	"github.com/pion/webrtc/v3"
+)
+
+// shardedUDPMux demultiplexes the ICE connections through one UDP mux per
+// shard of the received packets. Each session is served by the mux of a
+// single shard, picked from its ufrag, the router sending it all of the
+// session's packets.
+type shardedUDPMux struct {
+	muxes  []ice.UDPMux
+	router *shardRouter
+}
+
+func newShardedUDPMux(conns []net.PacketConn, router *shardRouter) *shardedUDPMux {
+	m := &shardedUDPMux{
+		router: router,
+	}
+	for _, conn := range conns {
+		m.muxes = append(m.muxes, webrtc.NewICEUDPMux(nil, conn))
+	}
+	return m
+}
+
+func (m *shardedUDPMux) GetConn(ufrag string, isIPv6 bool) (net.PacketConn, error) {
+	return m.muxes[m.router.addUfrag(ufrag)].GetConn(ufrag, isIPv6)
+}
+
+func (m *shardedUDPMux) RemoveConnByUfrag(ufrag string) {
+	m.muxes[m.router.removeUfrag(ufrag)].RemoveConnByUfrag(ufrag)
+}
+
+func (m *shardedUDPMux) Close() error {
+	var err error
+	for _, mux := range m.muxes {
+		if closeErr := mux.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// shardRouter picks the shards of the received packets so that all those of
+// a session land on the shard of its ufrag. As the UDP mux does, the remote
+// addresses of the sessions are learned from the STUN messages they send,
+// whose username starts with the local ufrag. The packets of the other
+// addresses are dispatched by hash of the address.
+type shardRouter struct {
+	numShards uint32
+	mut       sync.RWMutex
+	// ufrags holds the ufrags of the sessions, mapped to the remote
+	// addresses learned for them.
+	ufrags map[string][]udpAddrKey
+	// addrs maps the learned remote addresses to their session.
+	addrs map[udpAddrKey]routedAddr
+}
+
+// udpAddrKey identifies a UDP address without allocating.
+type udpAddrKey struct {
+	ip   [16]byte
+	port int
+}
+
+type routedAddr struct {
+	ufrag string
+	shard uint32
+}
+
+func newShardRouter(numShards int) *shardRouter {
+	return &shardRouter{
+		numShards: uint32(numShards),
+		ufrags:    make(map[string][]udpAddrKey),
+		addrs:     make(map[udpAddrKey]routedAddr),
+	}
+}
+
+// addUfrag registers the ufrag of a session, returning its shard.
+func (r *shardRouter) addUfrag(ufrag string) uint32 {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if _, ok := r.ufrags[ufrag]; !ok {
+		r.ufrags[ufrag] = nil
+	}
+	return r.ufragShard(ufrag)
+}
+
+// removeUfrag forgets the ufrag of a session along with its addresses,
+// returning its shard.
+func (r *shardRouter) removeUfrag(ufrag string) uint32 {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	for _, key := range r.ufrags[ufrag] {
+		// The address may have been learned by another session since.
+		if r.addrs[key].ufrag == ufrag {
+			delete(r.addrs, key)
+		}
+	}
+	delete(r.ufrags, ufrag)
+	return r.ufragShard(ufrag)
+}
+
+// shard returns the shard of the given packet.
+func (r *shardRouter) shard(data []byte, addr net.Addr) uint32 {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return hashAddr(addr) % r.numShards
+	}
+	var key udpAddrKey
+	copy(key.ip[:], udpAddr.IP.To16())
+	key.port = udpAddr.Port
+
+	if stun.IsMessage(data) {
+		if ufrag := stunLocalUfrag(data); ufrag != "" {
+			if shard, ok := r.learnAddr(key, ufrag); ok {
+				return shard
+			}
+		}
+	}
+
+	r.mut.RLock()
+	routed, ok := r.addrs[key]
+	r.mut.RUnlock()
+	if ok {
+		return routed.shard
+	}
+	return hashAddr(addr) % r.numShards
+}
+
+// learnAddr maps the given address to the session of ufrag, if registered.
+func (r *shardRouter) learnAddr(key udpAddrKey, ufrag string) (uint32, bool) {
+	r.mut.RLock()
+	routed, ok := r.addrs[key]
+	r.mut.RUnlock()
+	if ok && routed.ufrag == ufrag {
+		return routed.shard, true
+	}
+
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	keys, ok := r.ufrags[ufrag]
+	if !ok {
+		return 0, false
+	}
+	shard := r.ufragShard(ufrag)
+	r.addrs[key] = routedAddr{ufrag: ufrag, shard: shard}
+	r.ufrags[ufrag] = append(keys, key)
+	return shard, true
+}
+
+// ufragShard returns the FNV-1a hash of ufrag modulo the number of shards.
+func (r *shardRouter) ufragShard(ufrag string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	h := uint32(offset32)
+	for i := 0; i < len(ufrag); i++ {
+		h ^= uint32(ufrag[i])
+		h *= prime32
+	}
+	return h % r.numShards
+}
+
+// stunLocalUfrag returns the local ufrag in the username of the given STUN
+// message, empty if it has none.
+func stunLocalUfrag(data []byte) string {
+	msg := &stun.Message{Raw: data}
+	if err := msg.Decode(); err != nil {
+		return ""
+	}
+	username, err := msg.Get(stun.AttrUsername)
+	if err != nil {
+		return ""
+	}
+	ufrag := string(username)
+	if i := strings.IndexByte(ufrag, ':'); i >= 0 {
+		ufrag = ufrag[:i]
+	}
+	return ufrag
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedUDPMux(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mc, err := newMultiConn([]net.PacketConn{conn}, multiConnConfig{writeMode: UDPWriteModeRoundRobin, readShards: 4}, nil)
+	require.NoError(t, err)
+	defer mc.Close()
+
+	mux := newShardedUDPMux(mc.shards(), mc.shardRouter)
+	defer mux.Close()
+
+	muxConn, err := mux.GetConn("ufragA", false)
+	require.NoError(t, err)
+	require.Equal(t, mc.LocalAddr(), muxConn.LocalAddr())
+
+	// Clients hash to different shards but, once they sent the STUN
+	// messages of the session, all their packets land on its shard.
+	for i := 0; i < 8; i++ {
+		client, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer client.Close()
+
+		req, err := stun.Build(stun.TransactionID, stun.BindingRequest, stun.NewUsername("ufragA:ufragB"))
+		require.NoError(t, err)
+		_, err = client.WriteTo(req.Raw, mc.LocalAddr())
+		require.NoError(t, err)
+
+		buf := make([]byte, receiveMTU)
+		n, addr, err := muxConn.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, req.Raw, buf[:n])
+		require.Equal(t, client.LocalAddr().String(), addr.String())
+
+		// Replies go through the multiConn.
+		_, err = muxConn.WriteTo([]byte("reply"), addr)
+		require.NoError(t, err)
+		err = client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		require.NoError(t, err)
+		n, _, err = client.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, "reply", string(buf[:n]))
+
+		// Once replied to, the address is known to the mux, which then
+		// also gets its non-STUN packets.
+		_, err = client.WriteTo([]byte("data"), mc.LocalAddr())
+		require.NoError(t, err)
+		n, _, err = muxConn.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, "data", string(buf[:n]))
+	}
+
+	mux.RemoveConnByUfrag("ufragA")
+	mc.shardRouter.mut.RLock()
+	require.Empty(t, mc.shardRouter.ufrags)
+	require.Empty(t, mc.shardRouter.addrs)
+	mc.shardRouter.mut.RUnlock()
+	require.NoError(t, muxConn.Close())
+	_, _, err = muxConn.ReadFrom(make([]byte, receiveMTU))
+	require.Error(t, err)
+}
+
+func TestShardRouter(t *testing.T) {
+	r := newShardRouter(4)
+	addrA := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}
+	addrB := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 5000}
+	stunMsg := func(username string) []byte {
+		t.Helper()
+		msg, err := stun.Build(stun.TransactionID, stun.BindingRequest, stun.NewUsername(username))
+		require.NoError(t, err)
+		return msg.Raw
+	}
+
+	t.Run("unknown addresses", func(t *testing.T) {
+		require.Equal(t, hashAddr(addrA)%4, r.shard([]byte("data"), addrA))
+		// The ufrag is only routed once registered.
+		require.Equal(t, hashAddr(addrA)%4, r.shard(stunMsg("ufragA:ufragB"), addrA))
+		require.Equal(t, hashAddr(addrA)%4, r.shard([]byte("data"), addrA))
+	})
+
+	t.Run("learned addresses", func(t *testing.T) {
+		shard := r.addUfrag("ufragA")
+		require.Equal(t, r.ufragShard("ufragA"), shard)
+		require.Equal(t, shard, r.shard(stunMsg("ufragA:ufragB"), addrA))
+		require.Equal(t, shard, r.shard([]byte("data"), addrA))
+		require.Equal(t, shard, r.shard(stunMsg("ufragA:ufragC"), addrB))
+		require.Equal(t, shard, r.shard([]byte("data"), addrB))
+
+		require.Equal(t, shard, r.removeUfrag("ufragA"))
+		require.Equal(t, hashAddr(addrA)%4, r.shard([]byte("data"), addrA))
+		require.Equal(t, hashAddr(addrB)%4, r.shard([]byte("data"), addrB))
+	})
+
+	t.Run("address taken over", func(t *testing.T) {
+		r.addUfrag("ufragA")
+		r.addUfrag("ufragD")
+		require.Equal(t, r.ufragShard("ufragA"), r.shard(stunMsg("ufragA:ufragB"), addrA))
+		require.Equal(t, r.ufragShard("ufragD"), r.shard(stunMsg("ufragD:ufragB"), addrA))
+
+		// Removing the previous session keeps the address of the new one.
+		r.removeUfrag("ufragA")
+		require.Equal(t, r.ufragShard("ufragD"), r.shard([]byte("data"), addrA))
+	})
+}
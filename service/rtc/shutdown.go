@@ -0,0 +1,66 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// Drain stops accepting new sessions and waits up to timeout for the ongoing
+// ones to end, giving clients a chance to leave cleanly. The sessions still
+// ongoing past it get closed with CloseReasonShutdown. It returns the number
+// of sessions that had to be force-closed.
+func (s *Server) Drain(timeout time.Duration) int {
+	var drainCh chan struct{}
+	s.mut.Lock()
+	s.draining = true
+	numSessions := len(s.sessions)
+	if numSessions > 0 {
+		drainCh = make(chan struct{})
+		s.drainCh = drainCh
+	}
+	s.mut.Unlock()
+
+	if drainCh == nil {
+		return 0
+	}
+
+	s.log.Info("rtc: waiting for sessions to end", mlog.Int("numSessions", numSessions),
+		mlog.Float64("timeoutSeconds", timeout.Seconds()))
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-drainCh:
+		return 0
+	case <-timer.C:
+	}
+
+	s.mut.RLock()
+	sessionIDs := make([]string, 0, len(s.sessions))
+	for sessionID := range s.sessions {
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	s.mut.RUnlock()
+
+	var forced int
+	for _, sessionID := range sessionIDs {
+		s.mut.RLock()
+		_, ok := s.sessions[sessionID]
+		s.mut.RUnlock()
+		if !ok {
+			// Ended on its own in the meantime.
+			continue
+		}
+		forced++
+		if err := s.closeSession(sessionID, CloseReasonShutdown); err != nil {
+			s.log.Error("failed to close session", mlog.Err(err), mlog.String("sessionID", sessionID),
+				mlog.String("reason", CloseReasonShutdown))
+		}
+	}
+
+	return forced
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrain(t *testing.T) {
+	reasonCh := make(chan string, 10)
+	addSession := func(t *testing.T, server *Server, sessionID string) {
+		t.Helper()
+		cfg := SessionConfig{
+			GroupID:   "groupID",
+			CallID:    "callID",
+			UserID:    "userID",
+			SessionID: sessionID,
+		}
+		peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		_, err = server.addSession(cfg, peerConn, func(reason string) error {
+			reasonCh <- reason
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	t.Run("no sessions", func(t *testing.T) {
+		server, shutdown := setupServer(t)
+		defer shutdown()
+
+		require.Zero(t, server.Drain(time.Second))
+
+		err := server.InitSession(SessionConfig{
+			GroupID:   "groupID",
+			CallID:    "callID",
+			UserID:    "userID",
+			SessionID: "sessionID",
+		}, nil)
+		require.ErrorIs(t, err, ErrServerDraining)
+	})
+
+	t.Run("sessions ending", func(t *testing.T) {
+		server, shutdown := setupServer(t)
+		defer shutdown()
+
+		addSession(t, server, "sessionA")
+		addSession(t, server, "sessionB")
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			_ = server.CloseSession("sessionA")
+			_ = server.CloseSession("sessionB")
+		}()
+
+		require.Zero(t, server.Drain(10*time.Second))
+		require.Equal(t, CloseReasonLeft, <-reasonCh)
+		require.Equal(t, CloseReasonLeft, <-reasonCh)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		server, shutdown := setupServer(t)
+		defer shutdown()
+
+		addSession(t, server, "sessionA")
+		addSession(t, server, "sessionB")
+		require.NoError(t, server.CloseSession("sessionB"))
+		require.Equal(t, CloseReasonLeft, <-reasonCh)
+
+		require.Equal(t, 1, server.Drain(100*time.Millisecond))
+		require.Equal(t, CloseReasonShutdown, <-reasonCh)
+		require.Nil(t, server.getGroup("groupID"))
+	})
+}
@@ -0,0 +1,245 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// SLOMaxWindowMinutes is the longest window SLO indicators can be
+	// computed over.
+	SLOMaxWindowMinutes = 24 * 60
+	// SLOLossThreshold is the mean fraction of lost packets above which a
+	// session counts as lossy.
+	SLOLossThreshold = 0.05
+)
+
+// sloLatencyBoundsMs are the upper bounds of the buckets join latencies are
+// counted in. Latencies above the last one fall in an extra bucket.
+var sloLatencyBoundsMs = [...]float64{
+	50, 100, 150, 200, 300, 400, 500, 750,
+	1000, 1500, 2000, 3000, 5000, 10000, 30000,
+}
+
+// sloBucket holds the outcomes recorded during a given minute.
+type sloBucket struct {
+	minute        int64
+	joins         uint64
+	failedJoins   uint64
+	latencies     [len(sloLatencyBoundsMs) + 1]uint64
+	sessions      uint64
+	lossySessions uint64
+}
+
+// sloTracker records the outcome of sessions in per-minute buckets, for the
+// last SLOMaxWindowMinutes.
+type sloTracker struct {
+	buckets [SLOMaxWindowMinutes]sloBucket
+	mut     sync.Mutex
+}
+
+// getBucket returns the bucket of the given time, resetting it if it was
+// last used for an older minute. Must be called with t.mut held.
+func (t *sloTracker) getBucket(now time.Time) *sloBucket {
+	minute := now.Unix() / 60
+	b := &t.buckets[minute%SLOMaxWindowMinutes]
+	if b.minute != minute {
+		*b = sloBucket{minute: minute}
+	}
+	return b
+}
+
+func (t *sloTracker) recordJoin(now time.Time, latency time.Duration) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	b := t.getBucket(now)
+	b.joins++
+	ms := float64(latency) / float64(time.Millisecond)
+	i := 0
+	for i < len(sloLatencyBoundsMs) && ms > sloLatencyBoundsMs[i] {
+		i++
+	}
+	b.latencies[i]++
+}
+
+func (t *sloTracker) recordFailedJoin(now time.Time) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	b := t.getBucket(now)
+	b.joins++
+	b.failedJoins++
+}
+
+func (t *sloTracker) recordSessionLoss(now time.Time, lossy bool) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	b := t.getBucket(now)
+	b.sessions++
+	if lossy {
+		b.lossySessions++
+	}
+}
+
+// SLOReport summarizes the user experience indicators of the sessions
+// handled by the server over a rolling window.
+type SLOReport struct {
+	WindowMinutes int   `json:"window_minutes"`
+	GeneratedAt   int64 `json:"generated_at"`
+	// Joins is the number of sessions which either connected or were closed
+	// before connecting during the window.
+	Joins       uint64 `json:"joins"`
+	FailedJoins uint64 `json:"failed_joins"`
+	// JoinSuccessRate is the fraction of joins which connected, between 0
+	// and 1. It's 1 if there were no joins.
+	JoinSuccessRate float64 `json:"join_success_rate"`
+	// MedianJoinLatencyMs is the median time sessions took to connect,
+	// estimated from a histogram. It's zero if no session connected.
+	MedianJoinLatencyMs float64 `json:"median_join_latency_ms"`
+	// Sessions is the number of sessions with loss reported, closed during
+	// the window or still ongoing.
+	Sessions uint64 `json:"sessions"`
+	// LossySessions is the number of those whose mean fraction of lost
+	// packets is above SLOLossThreshold.
+	LossySessions uint64 `json:"lossy_sessions"`
+	// LossySessionsRate is the fraction of lossy sessions, between 0 and 1.
+	LossySessionsRate float64 `json:"lossy_sessions_rate"`
+}
+
+// medianLatency estimates the median of the latencies counted in the given
+// histogram, interpolating within the bucket it falls in.
+func medianLatency(latencies []uint64) float64 {
+	var total uint64
+	for _, n := range latencies {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+
+	target := float64(total) / 2
+	var count float64
+	for i, n := range latencies {
+		if n == 0 || count+float64(n) < target {
+			count += float64(n)
+			continue
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = sloLatencyBoundsMs[i-1]
+		}
+		// The last bucket is open ended.
+		if i == len(sloLatencyBoundsMs) {
+			return lower
+		}
+		return lower + (sloLatencyBoundsMs[i]-lower)*(target-count)/float64(n)
+	}
+
+	return 0
+}
+
+func (t *sloTracker) getReport(now time.Time, windowMinutes int) SLOReport {
+	report := SLOReport{
+		WindowMinutes: windowMinutes,
+		GeneratedAt:   now.UnixMilli(),
+	}
+	latencies := make([]uint64, len(sloLatencyBoundsMs)+1)
+
+	t.mut.Lock()
+	minute := now.Unix() / 60
+	for i := range t.buckets {
+		b := &t.buckets[i]
+		if b.minute <= minute-int64(windowMinutes) || b.minute > minute {
+			continue
+		}
+		report.Joins += b.joins
+		report.FailedJoins += b.failedJoins
+		report.Sessions += b.sessions
+		report.LossySessions += b.lossySessions
+		for j, n := range b.latencies {
+			latencies[j] += n
+		}
+	}
+	t.mut.Unlock()
+
+	report.JoinSuccessRate = 1
+	if report.Joins > 0 {
+		report.JoinSuccessRate = float64(report.Joins-report.FailedJoins) / float64(report.Joins)
+	}
+	report.MedianJoinLatencyMs = medianLatency(latencies)
+
+	return report
+}
+
+// addLoss records a fraction of lost packets reported for one of the
+// streams forwarded to the session. Must be called with s.mut held.
+func (s *session) addLoss(fractionLost float64) {
+	s.lossSum += fractionLost
+	s.lossReports++
+}
+
+// getLoss returns the mean fraction of lost packets reported for the
+// streams forwarded to the session, and whether any was reported.
+func (s *session) getLoss() (float64, bool) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	if s.lossReports == 0 {
+		return 0, false
+	}
+	return s.lossSum / float64(s.lossReports), true
+}
+
+// recordSessionSLO records the outcome of the given session as it's closed.
+// Hidden sessions aren't accounted for as they don't serve users, nor are the
+// sessions closed on shutdown before connecting.
+func (s *Server) recordSessionSLO(us *session, reason string, now time.Time) {
+	if us.cfg.Hidden {
+		return
+	}
+
+	us.mut.RLock()
+	joinStarted := !us.joinStartedAt.IsZero()
+	connected := us.joinTimings.DTLSConnectedAt != 0
+	us.mut.RUnlock()
+
+	if joinStarted && !connected && reason != CloseReasonShutdown {
+		s.slo.recordFailedJoin(now)
+	}
+
+	if loss, ok := us.getLoss(); ok {
+		s.slo.recordSessionLoss(now, loss > SLOLossThreshold)
+	}
+}
+
+// GetSLOReport returns the SLO indicators of the last windowMinutes minutes.
+// Ongoing sessions are accounted for along with the ones closed during the
+// window.
+func (s *Server) GetSLOReport(windowMinutes int) (SLOReport, error) {
+	if windowMinutes <= 0 || windowMinutes > SLOMaxWindowMinutes {
+		return SLOReport{}, fmt.Errorf("invalid window: should be between 1 and %d minutes", SLOMaxWindowMinutes)
+	}
+
+	report := s.slo.getReport(time.Now(), windowMinutes)
+
+	s.iterCalls(func(_ *group, c *call) {
+		c.iterSessions(func(us *session) {
+			if us.cfg.Hidden {
+				return
+			}
+			if loss, ok := us.getLoss(); ok {
+				report.Sessions++
+				if loss > SLOLossThreshold {
+					report.LossySessions++
+				}
+			}
+		})
+	})
+	if report.Sessions > 0 {
+		report.LossySessionsRate = float64(report.LossySessions) / float64(report.Sessions)
+	}
+
+	return report, nil
+}
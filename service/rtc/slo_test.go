@@ -0,0 +1,139 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMedianLatency(t *testing.T) {
+	latencies := make([]uint64, len(sloLatencyBoundsMs)+1)
+	require.Zero(t, medianLatency(latencies))
+
+	// All in the (100, 150] bucket.
+	latencies[2] = 4
+	require.Equal(t, 125.0, medianLatency(latencies))
+
+	// Half of them in the first bucket.
+	latencies[0] = 4
+	require.Equal(t, 50.0, medianLatency(latencies))
+
+	// Open ended bucket.
+	latencies = make([]uint64, len(sloLatencyBoundsMs)+1)
+	latencies[len(sloLatencyBoundsMs)] = 1
+	require.Equal(t, 30000.0, medianLatency(latencies))
+}
+
+func TestSLOTracker(t *testing.T) {
+	tracker := &sloTracker{}
+	now := time.Unix(1700000000, 0)
+
+	report := tracker.getReport(now, 60)
+	require.Equal(t, SLOReport{
+		WindowMinutes:   60,
+		GeneratedAt:     now.UnixMilli(),
+		JoinSuccessRate: 1,
+	}, report)
+
+	// Two hours ago, out of the window.
+	tracker.recordFailedJoin(now.Add(-2 * time.Hour))
+	tracker.recordSessionLoss(now.Add(-2*time.Hour), true)
+	// Half an hour ago.
+	tracker.recordJoin(now.Add(-30*time.Minute), 120*time.Millisecond)
+	tracker.recordJoin(now.Add(-30*time.Minute), 130*time.Millisecond)
+	tracker.recordFailedJoin(now.Add(-30 * time.Minute))
+	tracker.recordSessionLoss(now.Add(-30*time.Minute), false)
+	// Now.
+	tracker.recordJoin(now, 140*time.Millisecond)
+	tracker.recordSessionLoss(now, true)
+
+	report = tracker.getReport(now, 60)
+	require.Equal(t, uint64(4), report.Joins)
+	require.Equal(t, uint64(1), report.FailedJoins)
+	require.Equal(t, 0.75, report.JoinSuccessRate)
+	require.InDelta(t, 125, report.MedianJoinLatencyMs, 0.01)
+	require.Equal(t, uint64(2), report.Sessions)
+	require.Equal(t, uint64(1), report.LossySessions)
+
+	report = tracker.getReport(now, 3*60)
+	require.Equal(t, uint64(5), report.Joins)
+	require.Equal(t, uint64(2), report.FailedJoins)
+	require.Equal(t, uint64(3), report.Sessions)
+
+	report = tracker.getReport(now, 1)
+	require.Equal(t, uint64(1), report.Joins)
+	require.Equal(t, uint64(1), report.Sessions)
+
+	// A day later the buckets are reused.
+	later := now.Add(24 * time.Hour)
+	tracker.recordFailedJoin(later)
+	report = tracker.getReport(later, SLOMaxWindowMinutes)
+	require.Equal(t, uint64(1), report.Joins)
+	require.Equal(t, uint64(1), report.FailedJoins)
+	require.Zero(t, report.JoinSuccessRate)
+	require.Zero(t, report.Sessions)
+}
+
+func TestGetSLOReport(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	_, err := server.GetSLOReport(0)
+	require.EqualError(t, err, "invalid window: should be between 1 and 1440 minutes")
+	_, err = server.GetSLOReport(SLOMaxWindowMinutes + 1)
+	require.EqualError(t, err, "invalid window: should be between 1 and 1440 minutes")
+
+	addSession := func(sessionID string, hidden bool) *session {
+		t.Helper()
+		peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		us, err := server.addSession(SessionConfig{
+			GroupID:   "groupID",
+			CallID:    "callID",
+			UserID:    sessionID,
+			SessionID: sessionID,
+			Hidden:    hidden,
+		}, peerConn, nil)
+		require.NoError(t, err)
+		us.setJoinPhase(JoinPhaseWSAuth, time.Now())
+		return us
+	}
+
+	// Connected, lossy and ongoing.
+	sessionA := addSession("sessionA", false)
+	server.recordJoinPhase(sessionA, JoinPhaseDTLSConnected)
+	sessionA.updateQuality(StreamQuality{TrackID: "trackA", FractionLost: 0.1})
+	sessionA.updateQuality(StreamQuality{TrackID: "trackA", FractionLost: 0.02})
+	defer server.CloseSession("sessionA")
+
+	// Connected and closed.
+	sessionB := addSession("sessionB", false)
+	server.recordJoinPhase(sessionB, JoinPhaseDTLSConnected)
+	sessionB.updateQuality(StreamQuality{TrackID: "trackA", FractionLost: 0.01})
+	require.NoError(t, server.CloseSession("sessionB"))
+
+	// Closed before connecting.
+	addSession("sessionC", false)
+	require.NoError(t, server.CloseSession("sessionC"))
+
+	// Hidden sessions are left out.
+	sessionD := addSession("sessionD", true)
+	sessionD.updateQuality(StreamQuality{TrackID: "trackA", FractionLost: 0.5})
+	require.NoError(t, server.CloseSession("sessionD"))
+
+	report, err := server.GetSLOReport(60)
+	require.NoError(t, err)
+	require.Equal(t, 60, report.WindowMinutes)
+	require.Equal(t, uint64(3), report.Joins)
+	require.Equal(t, uint64(1), report.FailedJoins)
+	require.InDelta(t, 2.0/3, report.JoinSuccessRate, 0.001)
+	require.Greater(t, report.MedianJoinLatencyMs, 0.0)
+	require.Equal(t, uint64(2), report.Sessions)
+	require.Equal(t, uint64(1), report.LossySessions)
+	require.Equal(t, 0.5, report.LossySessionsRate)
+}
@@ -0,0 +1,375 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const (
+	udpSocketsSampleInterval = 10 * time.Second
+	ipv4HeaderSize           = 20
+	udpHeaderSize            = 8
+	// maxUDPReadShards bounds the number of read shards, each of which adds
+	// a goroutine per ICE connection.
+	maxUDPReadShards = 64
+)
+
+type UDPSocketsStats struct {
+	// Count is the number of UDP sockets currently open.
+	Count int `json:"count"`
+	// PacketRate is the number of received packets per second, as measured
+	// during the last sampling interval.
+	PacketRate float64 `json:"packetRate"`
+	// ReadBufferSize and WriteBufferSize are the effective sizes, in bytes,
+	// of the socket buffers as reported by the kernel.
+	ReadBufferSize  int `json:"readBufferSize"`
+	WriteBufferSize int `json:"writeBufferSize"`
+	// TemporaryReadErrors is the number of transient read errors the
+	// sockets recovered from.
+	TemporaryReadErrors uint64 `json:"temporaryReadErrors"`
+	// Rebinds is the number of sockets replaced because they stopped
+	// receiving packets.
+	Rebinds uint64 `json:"rebinds"`
+	// TruncatedPackets is the number of received packets which were likely
+	// truncated because larger than the receive MTU.
+	TruncatedPackets uint64 `json:"truncatedPackets"`
+	// Conns holds the write stats of each socket.
+	Conns []UDPConnWriteStats `json:"conns"`
+}
+
+// UDPConnWriteStats holds the stats of the writes to a UDP socket.
+type UDPConnWriteStats struct {
+	// Writes is the number of write attempts, including the failed ones.
+	Writes uint64 `json:"writes"`
+	// Errors is the number of failed writes.
+	Errors uint64 `json:"errors"`
+	// Latency is the moving average of the duration of the writes, which
+	// grows as the send queue of the socket fills up.
+	Latency time.Duration `json:"latency"`
+}
+
+// UDPSocketsStats returns the current UDP sockets usage.
+func (s *Server) UDPSocketsStats() UDPSocketsStats {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	var stats UDPSocketsStats
+	if s.udpConn != nil {
+		stats.Count = s.udpConn.numConns()
+		stats.TemporaryReadErrors = s.udpConn.tempErrorCount()
+		stats.Rebinds = s.udpConn.rebindCount()
+		stats.TruncatedPackets = s.udpConn.truncatedCount()
+		stats.Conns = s.udpConn.connWriteStats()
+	}
+	stats.PacketRate = s.udpPacketRate
+	stats.ReadBufferSize = s.udpReadBufSize
+	stats.WriteBufferSize = s.udpWriteBufSize
+
+	return stats
+}
+
+// setUDPConnBuffers applies the configured buffer sizes to the given conn and
+// verifies the ones actually in effect, since the kernel silently caps them
+// (e.g. to net.core.rmem_max and net.core.wmem_max on Linux).
+func (s *Server) setUDPConnBuffers(conn *net.UDPConn) error {
+	readSize := s.cfg.UDPSockets.ReadBufferSize
+	writeSize := s.cfg.UDPSockets.WriteBufferSize
+
+	if readSize > 0 {
+		if err := conn.SetReadBuffer(readSize); err != nil {
+			s.log.Warn("rtc: failed to set udp receive buffer", mlog.Err(err))
+		}
+	}
+
+	if writeSize > 0 {
+		if err := conn.SetWriteBuffer(writeSize); err != nil {
+			s.log.Warn("rtc: failed to set udp send buffer", mlog.Err(err))
+		}
+	}
+
+	effReadSize, effWriteSize, err := getUDPConnBuffers(conn)
+	if err != nil {
+		return fmt.Errorf("failed to get udp buffer sizes: %w", err)
+	}
+
+	s.log.Debug("rtc: udp buffers", mlog.Int("readBufSize", effReadSize), mlog.Int("writeBufSize", effWriteSize))
+
+	if effReadSize < readSize {
+		s.log.Warn("rtc: udp receive buffer is smaller than configured, packets may be dropped",
+			mlog.Int("configured", readSize), mlog.Int("effective", effReadSize))
+	}
+	if effWriteSize < writeSize {
+		s.log.Warn("rtc: udp send buffer is smaller than configured, packets may be dropped",
+			mlog.Int("configured", writeSize), mlog.Int("effective", effWriteSize))
+	}
+
+	s.mut.Lock()
+	s.udpReadBufSize = effReadSize
+	s.udpWriteBufSize = effWriteSize
+	s.mut.Unlock()
+
+	s.metrics.SetUDPSocketBufferSize("read", effReadSize)
+	s.metrics.SetUDPSocketBufferSize("write", effWriteSize)
+
+	return nil
+}
+
+// getUDPConnBuffers returns the sizes of the receive and send buffers of the
+// given conn.
+func getUDPConnBuffers(conn *net.UDPConn) (int, int, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get syscall conn: %w", err)
+	}
+
+	var readSize, writeSize int
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		readSize, sockErr = getSocketOption(fd, syscall.SO_RCVBUF)
+		if sockErr != nil {
+			return
+		}
+		writeSize, sockErr = getSocketOption(fd, syscall.SO_SNDBUF)
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("control call failed: %w", err)
+	}
+	if sockErr != nil {
+		return 0, 0, fmt.Errorf("failed to get socket option: %w", sockErr)
+	}
+
+	return readSize, writeSize, nil
+}
+
+// ScaleUDPSockets opens or closes UDP sockets (and their respective readers)
+// until count sockets are serving media.
+func (s *Server) ScaleUDPSockets(count int) error {
+	if s.vnet != nil {
+		return fmt.Errorf("udp sockets can't be scaled on a vnet")
+	}
+
+	minCount := s.cfg.UDPSockets.getMinCount()
+	maxCount := s.cfg.UDPSockets.getMaxCount()
+	if count < minCount || count > maxCount {
+		return fmt.Errorf("invalid count value: %d is not in allowed range [%d, %d]", count, minCount, maxCount)
+	}
+
+	s.scaleMut.Lock()
+	defer s.scaleMut.Unlock()
+
+	s.mut.RLock()
+	mc := s.udpConn
+	s.mut.RUnlock()
+	if mc == nil {
+		return fmt.Errorf("server is not started")
+	}
+
+	current := mc.numConns()
+	if current == count {
+		return nil
+	}
+
+	s.log.Info("rtc: scaling udp sockets", mlog.Int("from", current), mlog.Int("to", count))
+
+	for ; current < count; current++ {
+		conn, err := s.newUDPConn()
+		if err != nil {
+			return fmt.Errorf("failed to create udp conn: %w", err)
+		}
+		if err := mc.addConn(conn); err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to add udp conn: %w", err)
+		}
+	}
+
+	for ; current > count; current-- {
+		if err := mc.removeConn(); err != nil {
+			return fmt.Errorf("failed to remove udp conn: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// udpSocketsMonitor periodically samples the received packet rate and, if
+// enabled, scales the number of UDP sockets accordingly.
+func (s *Server) udpSocketsMonitor(stopCh <-chan struct{}, doneCh chan<- struct{}) {
+	defer close(doneCh)
+
+	ticker := time.NewTicker(udpSocketsSampleInterval)
+	defer ticker.Stop()
+
+	lastCount := s.udpConn.readCount()
+	lastTime := time.Now()
+	var lastWriteStats []UDPConnWriteStats
+	var lastTruncated uint64
+
+	for {
+		select {
+		case now := <-ticker.C:
+			count := s.udpConn.readCount()
+			rate := float64(count-lastCount) / now.Sub(lastTime).Seconds()
+			lastCount, lastTime = count, now
+
+			s.mut.Lock()
+			s.udpPacketRate = rate
+			s.mut.Unlock()
+
+			writeStats := s.udpConn.connWriteStats()
+			s.updateUDPConnWriteMetrics(lastWriteStats, writeStats)
+			lastWriteStats = writeStats
+
+			truncated := s.udpConn.truncatedCount()
+			if truncated > lastTruncated {
+				s.log.Warn("rtc: received packets larger than the receive MTU, they got truncated",
+					mlog.Uint64("count", truncated-lastTruncated), mlog.Int("receiveMTU", s.cfg.UDPSockets.getReceiveMTU()))
+				s.metrics.AddUDPTruncatedPackets(truncated - lastTruncated)
+			}
+			lastTruncated = truncated
+
+			s.rebindUnhealthyUDPConns(now)
+
+			if n := s.udpConn.srcIPs.expire(now); n > 0 {
+				s.log.Debug("rtc: expired source IPs", mlog.Int("count", n))
+			}
+			s.metrics.SetUDPSourceIPCacheSize(s.udpConn.srcIPs.size())
+
+			if !s.cfg.UDPSockets.EnableScaling {
+				continue
+			}
+
+			if err := s.ScaleUDPSockets(s.udpSocketsTarget(rate)); err != nil {
+				s.log.Error("rtc: failed to scale udp sockets", mlog.Err(err))
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// rebindUnhealthyUDPConns replaces the UDP sockets which stopped receiving
+// packets with new ones bound to the same address, so that the flows the
+// kernel steers to them aren't silently lost.
+func (s *Server) rebindUnhealthyUDPConns(now time.Time) {
+	if s.cfg.UDPSockets.StallTimeoutSeconds <= 0 || !reusePortSupported || s.vnet != nil {
+		return
+	}
+
+	s.scaleMut.Lock()
+	defer s.scaleMut.Unlock()
+
+	timeout := time.Duration(s.cfg.UDPSockets.StallTimeoutSeconds) * time.Second
+	for idx, reason := range s.udpConn.unhealthyConns(now, timeout) {
+		s.log.Warn("rtc: rebinding unhealthy udp socket", mlog.Int("conn", idx), mlog.String("reason", reason))
+		conn, err := s.newUDPConn()
+		if err != nil {
+			s.log.Error("rtc: failed to create udp conn", mlog.Err(err))
+			continue
+		}
+		if err := s.udpConn.rebindConn(idx, conn); err != nil {
+			conn.Close()
+			s.log.Error("rtc: failed to rebind udp conn", mlog.Err(err))
+			continue
+		}
+		s.metrics.IncUDPConnRebinds(reason)
+	}
+}
+
+// updateUDPConnWriteMetrics exports the per socket write stats sampled since
+// the previous ones.
+func (s *Server) updateUDPConnWriteMetrics(prev, stats []UDPConnWriteStats) {
+	for i, st := range stats {
+		var last UDPConnWriteStats
+		// Sockets removed and added back start over.
+		if i < len(prev) && st.Writes >= prev[i].Writes {
+			last = prev[i]
+		}
+		conn := strconv.Itoa(i)
+		s.metrics.AddUDPConnWrites(conn, st.Writes-last.Writes, st.Errors-last.Errors)
+		s.metrics.SetUDPConnWriteLatency(conn, st.Latency.Seconds())
+	}
+	// Removed sockets.
+	for i := len(stats); i < len(prev); i++ {
+		s.metrics.SetUDPConnWriteLatency(strconv.Itoa(i), 0)
+	}
+}
+
+// udpSocketsTarget returns the number of sockets needed to handle the given
+// packet rate, bounded by the configured limits.
+func (s *Server) udpSocketsTarget(rate float64) int {
+	target := int(math.Ceil(rate / float64(s.cfg.UDPSockets.PacketRatePerSocket)))
+	if minCount := s.cfg.UDPSockets.getMinCount(); target < minCount {
+		return minCount
+	}
+	if maxCount := s.cfg.UDPSockets.getMaxCount(); target > maxCount {
+		return maxCount
+	}
+	return target
+}
+
+// checkInterfaceMTU warns about the interfaces media can be received on whose
+// MTU allows for packets larger than the read buffers, which would get
+// truncated.
+func (s *Server) checkInterfaceMTU() {
+	if s.vnet != nil {
+		return
+	}
+
+	ifaces, err := getMediaInterfaces(s.cfg.ICEAddressUDP)
+	if err != nil {
+		s.log.Warn("rtc: failed to get network interfaces", mlog.Err(err))
+		return
+	}
+
+	mtu := s.cfg.UDPSockets.getReceiveMTU()
+	for _, iface := range ifaces {
+		// The largest UDP payload an IPv4 packet can carry.
+		if maxPayload := iface.MTU - ipv4HeaderSize - udpHeaderSize; maxPayload > mtu {
+			s.log.Warn("rtc: interface MTU allows for packets larger than the receive MTU, they would get truncated",
+				mlog.String("interface", iface.Name), mlog.Int("interfaceMTU", iface.MTU), mlog.Int("receiveMTU", mtu))
+		}
+	}
+}
+
+// getMediaInterfaces returns the interfaces which are up and have the given
+// IP address or, if empty, all those which are up but the loopback ones.
+func getMediaInterfaces(addr string) ([]net.Interface, error) {
+	ip := net.ParseIP(addr)
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []net.Interface
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		if ip == nil {
+			if iface.Flags&net.FlagLoopback == 0 {
+				res = append(res, iface)
+			}
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				res = append(res, iface)
+				break
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,18 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build !windows
+
+package rtc
+
+import (
+	"syscall"
+)
+
+// platformTemporaryReadErrnos lists the platform specific errors after which
+// reading from a socket can be retried.
+var platformTemporaryReadErrnos []error
+
+func getSocketOption(fd uintptr, opt int) (int, error) {
+	return syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, opt)
+}
@@ -0,0 +1,22 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// platformTemporaryReadErrnos lists the platform specific errors after which
+// reading from a socket can be retried. Windows reports ICMP port unreachable
+// and TTL expired messages as errors on the next read.
+var platformTemporaryReadErrnos = []error{
+	windows.WSAECONNRESET,
+	windows.WSAENETRESET,
+}
+
+func getSocketOption(fd uintptr, opt int) (int, error) {
+	return syscall.GetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, opt)
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// sourceIPCacheMaxSize is the number of remote addresses after which the
+	// least recently used ones get evicted, bounding the memory usage.
+	sourceIPCacheMaxSize = 16384
+	// sourceIPCacheEvictRatio is the fraction of the entries evicted at once
+	// when the cache is full, so that the cost of finding the least recently
+	// used ones is amortized.
+	sourceIPCacheEvictRatio = 0.1
+	// sourceIPCacheTTL is how long an entry is kept without being used. It's
+	// well above the ICE timeouts so that the addresses of live sessions
+	// never expire.
+	sourceIPCacheTTL = 5 * time.Minute
+	// sourceIPTouchInterval is the precision of the last use time of the
+	// entries, saving writes on the hot path.
+	sourceIPTouchInterval = time.Second
+)
+
+// sourceIPCache tracks which local IP should be used as source when writing
+// to a remote address. This matters on multi-homed hosts when sockets are
+// bound to the unspecified address: replies must leave from the IP the
+// remote peer sent its packets to, otherwise they get dropped by stateful
+// firewalls and NATs along the way.
+type sourceIPCache struct {
+	ips map[string]*sourceIP
+	// bytes holds the number of bytes received and sent through each local
+	// IP, keyed by IP. Entries are never removed, a host only has a handful
+	// of IPs.
+	bytes map[string]*uint64
+	mut   sync.RWMutex
+}
+
+type sourceIP struct {
+	ip net.IP
+	// learned is true if the IP was taken from a packet received from the
+	// remote address, as opposed to being looked up from the routing table.
+	learned bool
+	// usedAt is the time the entry was last used, in Unix nanoseconds. It's
+	// accessed atomically.
+	usedAt int64
+	// bytes is the counter of the local IP, shared by all the remote
+	// addresses using it.
+	bytes *uint64
+}
+
+// count records n bytes received or sent through the entry.
+func (ip *sourceIP) count(n int) {
+	if n > 0 {
+		atomic.AddUint64(ip.bytes, uint64(n))
+	}
+}
+
+// touch records the use of the entry at the given time.
+func (ip *sourceIP) touch(now time.Time) {
+	if now.UnixNano()-atomic.LoadInt64(&ip.usedAt) >= int64(sourceIPTouchInterval) {
+		atomic.StoreInt64(&ip.usedAt, now.UnixNano())
+	}
+}
+
+func newSourceIPCache() *sourceIPCache {
+	return &sourceIPCache{
+		ips:   make(map[string]*sourceIP),
+		bytes: make(map[string]*uint64),
+	}
+}
+
+func (c *sourceIPCache) set(addr net.Addr, ip sourceIP) *sourceIP {
+	now := time.Now()
+	ip.usedAt = now.UnixNano()
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	key := addr.String()
+	if _, ok := c.ips[key]; !ok && len(c.ips) >= sourceIPCacheMaxSize {
+		c.evictLocked(now)
+	}
+	ipKey := ip.ip.String()
+	if c.bytes[ipKey] == nil {
+		c.bytes[ipKey] = new(uint64)
+	}
+	ip.bytes = c.bytes[ipKey]
+	c.ips[key] = &ip
+	return &ip
+}
+
+// getBytes returns the number of bytes received and sent through each local
+// IP, keyed by IP. Packets written without a source IP, left to the kernel,
+// aren't accounted.
+func (c *sourceIPCache) getBytes() map[string]uint64 {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	res := make(map[string]uint64, len(c.bytes))
+	for ip, n := range c.bytes {
+		res[ip] = atomic.LoadUint64(n)
+	}
+	return res
+}
+
+// evictLocked removes the expired entries and, if the cache is still full,
+// the least recently used ones. Must be called with c.mut held.
+func (c *sourceIPCache) evictLocked(now time.Time) {
+	if c.expireLocked(now) > 0 && len(c.ips) < sourceIPCacheMaxSize {
+		return
+	}
+
+	usedAts := make([]int64, 0, len(c.ips))
+	for _, ip := range c.ips {
+		usedAts = append(usedAts, atomic.LoadInt64(&ip.usedAt))
+	}
+	sort.Slice(usedAts, func(i, j int) bool { return usedAts[i] < usedAts[j] })
+	threshold := usedAts[int(float64(len(usedAts))*sourceIPCacheEvictRatio)]
+	for key, ip := range c.ips {
+		if atomic.LoadInt64(&ip.usedAt) <= threshold {
+			delete(c.ips, key)
+		}
+	}
+}
+
+// expireLocked removes the entries unused for sourceIPCacheTTL, returning how
+// many. Must be called with c.mut held.
+func (c *sourceIPCache) expireLocked(now time.Time) int {
+	var n int
+	for key, ip := range c.ips {
+		if now.UnixNano()-atomic.LoadInt64(&ip.usedAt) > int64(sourceIPCacheTTL) {
+			delete(c.ips, key)
+			n++
+		}
+	}
+	return n
+}
+
+// expire removes the entries unused for sourceIPCacheTTL, e.g. the addresses
+// of the sessions which ended, returning how many.
+func (c *sourceIPCache) expire(now time.Time) int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.expireLocked(now)
+}
+
+// size returns the number of remote addresses in the cache.
+func (c *sourceIPCache) size() int {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return len(c.ips)
+}
+
+// forget removes the source IP recorded for addr, e.g. after it was
+// rejected because the local address went away.
+func (c *sourceIPCache) forget(addr net.Addr) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	delete(c.ips, addr.String())
+}
+
+// learn records the local IP a packet from addr was received on, returning
+// the entry, or nil if the IP isn't usable.
+func (c *sourceIPCache) learn(addr net.Addr, dst net.IP) *sourceIP {
+	if addr == nil || dst == nil || dst.IsUnspecified() {
+		return nil
+	}
+
+	c.mut.RLock()
+	cur, ok := c.ips[addr.String()]
+	c.mut.RUnlock()
+	if ok && cur.learned && cur.ip.Equal(dst) {
+		cur.touch(time.Now())
+		return cur
+	}
+
+	return c.set(addr, sourceIP{ip: dst, learned: true})
+}
+
+// get returns the source IP to use when writing to addr. If no packet was
+// received from addr yet (e.g. for connectivity checks we initiate), the IP
+// is selected from the routing table. It returns nil if no IP could be
+// found, leaving the selection to the kernel.
+func (c *sourceIPCache) get(addr net.Addr) net.IP {
+	if cur := c.lookup(addr); cur != nil {
+		return cur.ip
+	}
+	return nil
+}
+
+// lookup is like get but returns the entry, so that the bytes written can be
+// accounted.
+func (c *sourceIPCache) lookup(addr net.Addr) *sourceIP {
+	c.mut.RLock()
+	cur, ok := c.ips[addr.String()]
+	c.mut.RUnlock()
+	if ok {
+		cur.touch(time.Now())
+		return cur
+	}
+
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return nil
+	}
+	ip := routeSourceIP(udpAddr)
+	if ip == nil {
+		return nil
+	}
+	return c.set(addr, sourceIP{ip: ip})
+}
+
+// routeSourceIP returns the local IP the kernel would route packets to addr
+// from. Connecting a UDP socket only performs the route lookup, no packet
+// is sent.
+func routeSourceIP(addr *net.UDPAddr) net.IP {
+	conn, err := net.DialUDP("udp4", nil, addr)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	localAddr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok || localAddr.IP.IsUnspecified() {
+		return nil
+	}
+	return localAddr.IP
+}
+
+// newSourceIPConn returns an ipv4.PacketConn reporting the destination IP of
+// received packets if conn is bound to the unspecified address. It returns
+// nil if the source IP is already fixed by the bound address or the option
+// is not supported.
+func newSourceIPConn(conn net.PacketConn) *ipv4.PacketConn {
+	if !sourceIPSupported {
+		return nil
+	}
+	localAddr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok || !localAddr.IP.IsUnspecified() {
+		return nil
+	}
+	pconn := ipv4.NewPacketConn(conn)
+	if err := pconn.SetControlMessage(ipv4.FlagDst, true); err != nil {
+		return nil
+	}
+	return pconn
+}
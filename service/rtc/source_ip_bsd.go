@@ -0,0 +1,43 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build freebsd || openbsd
+
+package rtc
+
+import (
+	"net"
+	"unsafe"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/sys/unix"
+)
+
+// ipSendSrcAddr is IP_SENDSRCADDR, which shares its value with
+// IP_RECVDSTADDR but is missing from some of the unix packages.
+const ipSendSrcAddr = unix.IP_RECVDSTADDR
+
+// sourceIPSupported is true on the platforms where the source IP of the
+// sent packets can be set. The ipv4 package only supports reading the
+// destination IP on BSDs, so IP_SENDSRCADDR control messages are built here.
+var sourceIPSupported = true
+
+// writeFromSourceIP writes p to addr, sending it from the src local IP.
+func writeFromSourceIP(conn net.PacketConn, pconn *ipv4.PacketConn, p []byte, src net.IP, addr net.Addr) (int, error) {
+	udpConn, ok := conn.(*net.UDPConn)
+	udpAddr, isUDPAddr := addr.(*net.UDPAddr)
+	src4 := src.To4()
+	if !ok || !isUDPAddr || src4 == nil {
+		return pconn.WriteTo(p, nil, addr)
+	}
+
+	oob := make([]byte, unix.CmsgSpace(net.IPv4len))
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level = unix.IPPROTO_IP
+	h.Type = ipSendSrcAddr
+	h.SetLen(unix.CmsgLen(net.IPv4len))
+	copy(oob[unix.CmsgLen(0):], src4)
+
+	n, _, err := udpConn.WriteMsgUDP(p, oob, udpAddr)
+	return n, err
+}
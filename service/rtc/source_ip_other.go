@@ -0,0 +1,20 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build !linux && !darwin && !freebsd && !openbsd
+
+package rtc
+
+import (
+	"net"
+
+	"golang.org/x/net/ipv4"
+)
+
+// sourceIPSupported is false as the source IP of the sent packets can't be
+// set on this platform, its selection is left to the kernel.
+var sourceIPSupported = false
+
+func writeFromSourceIP(_ net.PacketConn, pconn *ipv4.PacketConn, p []byte, _ net.IP, addr net.Addr) (int, error) {
+	return pconn.WriteTo(p, nil, addr)
+}
@@ -0,0 +1,21 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build linux || darwin
+
+package rtc
+
+import (
+	"net"
+
+	"golang.org/x/net/ipv4"
+)
+
+// sourceIPSupported is true on the platforms where the source IP of the
+// sent packets can be set, here through IP_PKTINFO control messages.
+var sourceIPSupported = true
+
+// writeFromSourceIP writes p to addr, sending it from the src local IP.
+func writeFromSourceIP(_ net.PacketConn, pconn *ipv4.PacketConn, p []byte, src net.IP, addr net.Addr) (int, error) {
+	return pconn.WriteTo(p, &ipv4.ControlMessage{Src: src}, addr)
+}
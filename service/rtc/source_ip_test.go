@@ -0,0 +1,76 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceIPCache(t *testing.T) {
+	ip := net.ParseIP("192.0.2.1")
+	newAddr := func(port int) net.Addr {
+		return &net.UDPAddr{IP: net.ParseIP("198.51.100.1"), Port: port}
+	}
+
+	t.Run("learn", func(t *testing.T) {
+		c := newSourceIPCache()
+		c.learn(newAddr(1), ip)
+		c.learn(newAddr(2), net.IPv4zero)
+		require.Equal(t, 1, c.size())
+		require.Equal(t, ip, c.get(newAddr(1)))
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		c := newSourceIPCache()
+		c.learn(newAddr(1), ip)
+		c.learn(newAddr(2), ip)
+		now := time.Now()
+		atomic.StoreInt64(&c.ips[newAddr(1).String()].usedAt, now.Add(-2*sourceIPCacheTTL).UnixNano())
+
+		require.Equal(t, 1, c.expire(now))
+		require.Equal(t, 1, c.size())
+		require.Contains(t, c.ips, newAddr(2).String())
+
+		// Using an entry keeps it.
+		require.Equal(t, ip, c.get(newAddr(2)))
+		require.Zero(t, c.expire(now.Add(sourceIPCacheTTL/2)))
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		c := newSourceIPCache()
+		now := time.Now()
+		for i := 0; i < sourceIPCacheMaxSize; i++ {
+			c.learn(newAddr(i), ip)
+			// The most recently added entries were used last.
+			atomic.StoreInt64(&c.ips[newAddr(i).String()].usedAt, now.Add(time.Duration(i)).UnixNano())
+		}
+		require.Equal(t, sourceIPCacheMaxSize, c.size())
+
+		c.learn(newAddr(sourceIPCacheMaxSize), ip)
+		require.Less(t, c.size(), sourceIPCacheMaxSize)
+		require.Greater(t, c.size(), sourceIPCacheMaxSize*8/10)
+		require.NotContains(t, c.ips, newAddr(0).String())
+		require.Contains(t, c.ips, newAddr(sourceIPCacheMaxSize-1).String())
+		require.Contains(t, c.ips, newAddr(sourceIPCacheMaxSize).String())
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		c := newSourceIPCache()
+		otherIP := net.ParseIP("192.0.2.2")
+		c.learn(newAddr(1), ip).count(100)
+		c.learn(newAddr(2), ip).count(50)
+		c.learn(newAddr(3), otherIP).count(10)
+		c.lookup(newAddr(1)).count(200)
+		require.Equal(t, map[string]uint64{ip.String(): 350, otherIP.String(): 10}, c.getBytes())
+
+		// Counters outlive the entries.
+		require.Equal(t, 3, c.expire(time.Now().Add(2*sourceIPCacheTTL)))
+		require.Equal(t, map[string]uint64{ip.String(): 350, otherIP.String(): 10}, c.getBytes())
+	})
+}
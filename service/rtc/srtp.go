@@ -0,0 +1,49 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+
+	"github.com/pion/dtls/v2"
+)
+
+// Names of the SRTP protection profiles (RFC 5764, RFC 7714).
+const (
+	SRTPProfileAES128CMSHA180 = "AES_128_CM_SHA1_80"
+	SRTPProfileAEADAES128GCM  = "AEAD_AES_128_GCM"
+	SRTPProfileAEADAES256GCM  = "AEAD_AES_256_GCM"
+)
+
+var srtpProtectionProfiles = map[string]dtls.SRTPProtectionProfile{
+	SRTPProfileAES128CMSHA180: dtls.SRTP_AES128_CM_HMAC_SHA1_80,
+	SRTPProfileAEADAES128GCM:  dtls.SRTP_AEAD_AES_128_GCM,
+}
+
+// parseSRTPProtectionProfiles returns the DTLS protection profiles matching
+// the given names, keeping their order.
+func parseSRTPProtectionProfiles(names []string) ([]dtls.SRTPProtectionProfile, error) {
+	profiles := make([]dtls.SRTPProtectionProfile, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate profile %q", name)
+		}
+		seen[name] = true
+
+		// The DTLS handshake can negotiate it but the SRTP session can't be
+		// set up with it yet.
+		if name == SRTPProfileAEADAES256GCM {
+			return nil, fmt.Errorf("profile %q is not supported by the WebRTC stack", name)
+		}
+
+		profile, ok := srtpProtectionProfiles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q: should be one of %q or %q",
+				name, SRTPProfileAEADAES128GCM, SRTPProfileAES128CMSHA180)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+
+	"github.com/pion/rtp"
+	"github.com/pion/srtp/v2"
+	"golang.org/x/sys/cpu"
+)
+
+const (
+	// srtpBenchmarkPackets is the number of packets encrypted to measure the
+	// crypto time of a protection profile.
+	srtpBenchmarkPackets = 1000
+	// srtpBenchmarkPayloadSize is the size of the packets encrypted, typical
+	// of video.
+	srtpBenchmarkPayloadSize = 1200
+)
+
+// Implementations of AES used by the Go crypto library.
+const (
+	AESImplementationAESNI    = "aes-ni"
+	AESImplementationARMv8    = "armv8-ce"
+	AESImplementationCPACF    = "cpacf"
+	AESImplementationPOWER8   = "power8"
+	AESImplementationSoftware = "software"
+)
+
+// AESImplementation returns the implementation of AES the Go crypto library
+// uses on this CPU, either an hardware accelerated one or a software
+// fallback which is an order of magnitude slower. AES-GCM also requires
+// carry-less multiplication to be accelerated, which all the CPUs supporting
+// AES instructions have in practice.
+func AESImplementation() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		if cpu.X86.HasAES && cpu.X86.HasPCLMULQDQ {
+			return AESImplementationAESNI
+		}
+	case "arm64":
+		if cpu.ARM64.HasAES && cpu.ARM64.HasPMULL {
+			return AESImplementationARMv8
+		}
+	case "s390x":
+		if cpu.S390X.HasAES && cpu.S390X.HasAESGCM {
+			return AESImplementationCPACF
+		}
+	case "ppc64le":
+		return AESImplementationPOWER8
+	}
+	return AESImplementationSoftware
+}
+
+// defaultSRTPProfiles are the protection profiles offered if none are
+// configured, in order of preference.
+var defaultSRTPProfiles = []string{SRTPProfileAEADAES128GCM, SRTPProfileAES128CMSHA180}
+
+// SRTPCryptoResult is the outcome of the benchmark of an SRTP protection
+// profile.
+type SRTPCryptoResult struct {
+	Profile string `json:"profile"`
+	// PacketTime is the time taken to encrypt a video packet in the
+	// benchmark.
+	PacketTime time.Duration `json:"packet_time"`
+}
+
+// benchmarkSRTPProfile returns the average time taken to encrypt a packet
+// with the given protection profile.
+func benchmarkSRTPProfile(name string, packets int) (time.Duration, error) {
+	profile, ok := srtpProtectionProfiles[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown profile %q", name)
+	}
+	lengths := srtpKeyLengths[profile]
+	// The key doesn't matter, only the time taken.
+	key := make([]byte, lengths[0]+lengths[1])
+	ctx, err := srtp.CreateContext(key[:lengths[0]], key[lengths[0]:], srtp.ProtectionProfile(profile))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create context: %w", err)
+	}
+
+	header := rtp.Header{Version: 2, PayloadType: 96, SSRC: 1}
+	pkt := make([]byte, header.MarshalSize()+srtpBenchmarkPayloadSize)
+	dst := make([]byte, 0, receiveMTU)
+	var elapsed time.Duration
+	// The first packets warm up the context.
+	for i := -packets / 10; i < packets; i++ {
+		header.SequenceNumber = uint16(i)
+		if _, err := header.MarshalTo(pkt); err != nil {
+			return 0, fmt.Errorf("failed to marshal header: %w", err)
+		}
+		start := time.Now()
+		dst, err = ctx.EncryptRTP(dst[:0], pkt, &header)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encrypt packet: %w", err)
+		}
+		if i >= 0 {
+			elapsed += time.Since(start)
+		}
+	}
+
+	return elapsed / time.Duration(packets), nil
+}
+
+// benchmarkSRTPCrypto measures the crypto time of the supported protection
+// profiles on synthetic packets, returning the results from the fastest
+// profile to the slowest.
+func (s *Server) benchmarkSRTPCrypto() []SRTPCryptoResult {
+	var results []SRTPCryptoResult
+	for _, name := range defaultSRTPProfiles {
+		packetTime, err := benchmarkSRTPProfile(name, srtpBenchmarkPackets)
+		if err != nil {
+			s.log.Warn("rtc: failed to benchmark SRTP profile", mlog.String("profile", name), mlog.Err(err))
+			continue
+		}
+		s.metrics.SetSRTPBenchmarkPacketTime(name, packetTime.Seconds())
+		results = append(results, SRTPCryptoResult{Profile: name, PacketTime: packetTime})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].PacketTime < results[j].PacketTime
+	})
+	return results
+}
+
+// initSRTPProfiles sets the SRTP protection profiles offered to clients: the
+// configured ones if any, else the supported ones in the default order or,
+// if opted in, from the fastest on this node to the slowest. The benchmark
+// being noisy, the latter could differ between nodes and restarts.
+func (s *Server) initSRTPProfiles() {
+	results := s.benchmarkSRTPCrypto()
+
+	profiles := s.cfg.SRTPProtectionProfiles
+	if len(profiles) == 0 && s.cfg.SRTPProfilesByBenchmark {
+		for _, res := range results {
+			profiles = append(profiles, res.Profile)
+		}
+	} else if len(profiles) == 0 {
+		profiles = defaultSRTPProfiles
+	}
+
+	fields := []mlog.Field{
+		mlog.String("aesImplementation", AESImplementation()),
+		mlog.Any("profiles", profiles),
+	}
+	for _, res := range results {
+		fields = append(fields, mlog.Duration(res.Profile, res.PacketTime))
+	}
+	s.log.Info("rtc: SRTP crypto benchmarked", fields...)
+	if AESImplementation() == AESImplementationSoftware {
+		s.log.Warn("rtc: AES is not hardware accelerated, SRTP crypto will be slow")
+	}
+
+	s.mut.Lock()
+	s.srtpProfiles = profiles
+	s.mut.Unlock()
+}
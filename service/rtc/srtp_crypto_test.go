@@ -0,0 +1,65 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAESImplementation(t *testing.T) {
+	require.Contains(t, []string{
+		AESImplementationAESNI,
+		AESImplementationARMv8,
+		AESImplementationCPACF,
+		AESImplementationPOWER8,
+		AESImplementationSoftware,
+	}, AESImplementation())
+}
+
+func TestBenchmarkSRTPProfile(t *testing.T) {
+	t.Run("unknown profile", func(t *testing.T) {
+		_, err := benchmarkSRTPProfile(SRTPProfileAEADAES256GCM, 10)
+		require.Error(t, err)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		for _, name := range []string{SRTPProfileAEADAES128GCM, SRTPProfileAES128CMSHA180} {
+			packetTime, err := benchmarkSRTPProfile(name, 10)
+			require.NoError(t, err)
+			require.Positive(t, packetTime)
+		}
+	})
+}
+
+func TestInitSRTPProfiles(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	t.Run("default", func(t *testing.T) {
+		// The order doesn't depend on the benchmark unless opted in.
+		server.initSRTPProfiles()
+		require.Equal(t, []string{SRTPProfileAEADAES128GCM, SRTPProfileAES128CMSHA180}, server.srtpProfiles)
+	})
+
+	t.Run("fastest first", func(t *testing.T) {
+		results := server.benchmarkSRTPCrypto()
+		require.Len(t, results, 2)
+		require.LessOrEqual(t, results[0].PacketTime, results[1].PacketTime)
+
+		server.cfg.SRTPProfilesByBenchmark = true
+		defer func() {
+			server.cfg.SRTPProfilesByBenchmark = false
+		}()
+		server.initSRTPProfiles()
+		require.ElementsMatch(t, []string{SRTPProfileAEADAES128GCM, SRTPProfileAES128CMSHA180}, server.srtpProfiles)
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		server.cfg.SRTPProtectionProfiles = []string{SRTPProfileAES128CMSHA180}
+		server.initSRTPProfiles()
+		require.Equal(t, []string{SRTPProfileAES128CMSHA180}, server.srtpProfiles)
+	})
+}
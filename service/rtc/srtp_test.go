@@ -0,0 +1,62 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/pion/dtls/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSRTPProtectionProfiles(t *testing.T) {
+	tcs := []struct {
+		name     string
+		names    []string
+		profiles []dtls.SRTPProtectionProfile
+		err      string
+	}{
+		{
+			name:     "empty",
+			profiles: []dtls.SRTPProtectionProfile{},
+		},
+		{
+			name:     "gcm only",
+			names:    []string{SRTPProfileAEADAES128GCM},
+			profiles: []dtls.SRTPProtectionProfile{dtls.SRTP_AEAD_AES_128_GCM},
+		},
+		{
+			name:     "order is kept",
+			names:    []string{SRTPProfileAES128CMSHA180, SRTPProfileAEADAES128GCM},
+			profiles: []dtls.SRTPProtectionProfile{dtls.SRTP_AES128_CM_HMAC_SHA1_80, dtls.SRTP_AEAD_AES_128_GCM},
+		},
+		{
+			name:  "duplicate",
+			names: []string{SRTPProfileAEADAES128GCM, SRTPProfileAEADAES128GCM},
+			err:   `duplicate profile "AEAD_AES_128_GCM"`,
+		},
+		{
+			name:  "unsupported",
+			names: []string{SRTPProfileAEADAES256GCM},
+			err:   `profile "AEAD_AES_256_GCM" is not supported by the WebRTC stack`,
+		},
+		{
+			name:  "unknown",
+			names: []string{"NULL_HMAC_SHA1_80"},
+			err:   `unknown profile "NULL_HMAC_SHA1_80": should be one of "AEAD_AES_128_GCM" or "AES_128_CM_SHA1_80"`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			profiles, err := parseSRTPProtectionProfiles(tc.names)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.profiles, profiles)
+		})
+	}
+}
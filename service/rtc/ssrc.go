@@ -0,0 +1,132 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"sync"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	// ssrcMaxReassignments is the number of times a new SSRC is drawn for
+	// a track whose sender collides with a stream of the session.
+	ssrcMaxReassignments = 3
+
+	ssrcCollisionSent     = "sent"
+	ssrcCollisionReceived = "received"
+
+	ssrcCollisionReassigned = "reassigned"
+	ssrcCollisionUnresolved = "unresolved"
+)
+
+// ssrcInterceptor keeps track of the SSRCs of the streams sent and received
+// on a session to detect collisions. Forwarded packets get the SSRC of the
+// sender they're written to, drawn at random for each subscriber, so streams
+// of different legs never collide, but a sender can draw the SSRC of another
+// stream of the same session. Browsers then mix up the streams, which
+// usually breaks decoding.
+type ssrcInterceptor struct {
+	interceptor.NoOp
+
+	// onCollision is called when a stream collides with another one of the
+	// session, along with whether the collision got resolved.
+	onCollision func(ssrc uint32, direction, result string)
+
+	// local and remote hold the number of streams bound per SSRC, for the
+	// sent and received streams respectively.
+	local  map[uint32]int
+	remote map[uint32]int
+	mut    sync.Mutex
+}
+
+func newSSRCInterceptor(onCollision func(ssrc uint32, direction, result string)) *ssrcInterceptor {
+	return &ssrcInterceptor{
+		onCollision: onCollision,
+		local:       map[uint32]int{},
+		remote:      map[uint32]int{},
+	}
+}
+
+// NewInterceptor implements interceptor.Factory. The same instance is
+// returned since a new one is created for each peer connection.
+func (i *ssrcInterceptor) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return i, nil
+}
+
+// inUse returns whether a stream with the given SSRC is bound, in either
+// direction.
+func (i *ssrcInterceptor) inUse(ssrc uint32) bool {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	return i.local[ssrc] > 0 || i.remote[ssrc] > 0
+}
+
+func (i *ssrcInterceptor) bind(ssrcs map[uint32]int, ssrc uint32, direction string) {
+	i.mut.Lock()
+	collision := i.local[ssrc] > 0 || i.remote[ssrc] > 0
+	ssrcs[ssrc]++
+	i.mut.Unlock()
+
+	// Streams can't be reassigned once negotiated.
+	if collision {
+		i.notify(ssrc, direction, ssrcCollisionUnresolved)
+	}
+}
+
+func (i *ssrcInterceptor) notify(ssrc uint32, direction, result string) {
+	if i.onCollision != nil {
+		i.onCollision(ssrc, direction, result)
+	}
+}
+
+func (i *ssrcInterceptor) unbind(ssrcs map[uint32]int, ssrc uint32) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	if ssrcs[ssrc]--; ssrcs[ssrc] <= 0 {
+		delete(ssrcs, ssrc)
+	}
+}
+
+func (i *ssrcInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	i.bind(i.local, info.SSRC, ssrcCollisionSent)
+	return writer
+}
+
+func (i *ssrcInterceptor) UnbindLocalStream(info *interceptor.StreamInfo) {
+	i.unbind(i.local, info.SSRC)
+}
+
+func (i *ssrcInterceptor) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	i.bind(i.remote, info.SSRC, ssrcCollisionReceived)
+	return reader
+}
+
+func (i *ssrcInterceptor) UnbindRemoteStream(info *interceptor.StreamInfo) {
+	i.unbind(i.remote, info.SSRC)
+}
+
+// getSenderSSRC returns the SSRC of the stream sent by sender.
+func getSenderSSRC(sender *webrtc.RTPSender) uint32 {
+	if encodings := sender.GetParameters().Encodings; len(encodings) > 0 {
+		return uint32(encodings[0].SSRC)
+	}
+	return 0
+}
+
+// isSSRCInUse returns whether the given SSRC, drawn for sender, is already
+// used by another stream of the session. Senders are checked as well since
+// their streams only get bound once negotiated.
+func (s *session) isSSRCInUse(sender *webrtc.RTPSender, ssrc uint32) bool {
+	if s.ssrcs != nil && s.ssrcs.inUse(ssrc) {
+		return true
+	}
+	for _, other := range s.rtcConn.GetSenders() {
+		if other != sender && other.Track() != nil && getSenderSSRC(other) == ssrc {
+			return true
+		}
+	}
+	return false
+}
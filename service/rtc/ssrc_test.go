@@ -0,0 +1,96 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/interceptor"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSRCInterceptor(t *testing.T) {
+	type collision struct {
+		ssrc      uint32
+		direction string
+		result    string
+	}
+	var collisions []collision
+	i := newSSRCInterceptor(func(ssrc uint32, direction, result string) {
+		collisions = append(collisions, collision{ssrc, direction, result})
+	})
+
+	i.BindRemoteStream(&interceptor.StreamInfo{SSRC: 1000}, nil)
+	i.BindLocalStream(&interceptor.StreamInfo{SSRC: 2000}, nil)
+	require.True(t, i.inUse(1000))
+	require.True(t, i.inUse(2000))
+	require.False(t, i.inUse(3000))
+	require.Empty(t, collisions)
+
+	i.BindLocalStream(&interceptor.StreamInfo{SSRC: 1000}, nil)
+	i.BindRemoteStream(&interceptor.StreamInfo{SSRC: 2000}, nil)
+	require.Equal(t, []collision{
+		{1000, ssrcCollisionSent, ssrcCollisionUnresolved},
+		{2000, ssrcCollisionReceived, ssrcCollisionUnresolved},
+	}, collisions)
+
+	// The SSRCs stay in use until all the streams are unbound.
+	i.UnbindRemoteStream(&interceptor.StreamInfo{SSRC: 1000})
+	require.True(t, i.inUse(1000))
+	i.UnbindLocalStream(&interceptor.StreamInfo{SSRC: 1000})
+	require.False(t, i.inUse(1000))
+	i.UnbindLocalStream(&interceptor.StreamInfo{SSRC: 2000})
+	i.UnbindRemoteStream(&interceptor.StreamInfo{SSRC: 2000})
+	require.False(t, i.inUse(2000))
+}
+
+func TestSessionAddSender(t *testing.T) {
+	peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer peerConn.Close()
+
+	var collisions int
+	s := &session{
+		cfg:     SessionConfig{SessionID: "sessionA"},
+		rtcConn: peerConn,
+		ssrcs: newSSRCInterceptor(func(_ uint32, _, _ string) {
+			collisions++
+		}),
+	}
+
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer log.Shutdown()
+
+	newTrack := func(t *testing.T, id string) *webrtc.TrackLocalStaticRTP {
+		t.Helper()
+		track, err := webrtc.NewTrackLocalStaticRTP(rtpAudioCodec, id, "streamA")
+		require.NoError(t, err)
+		return track
+	}
+
+	senderA, err := s.addSender(log, newTrack(t, "trackA"))
+	require.NoError(t, err)
+	ssrcA := getSenderSSRC(senderA)
+	require.NotZero(t, ssrcA)
+	require.False(t, s.isSSRCInUse(senderA, ssrcA))
+
+	senderB, err := s.addSender(log, newTrack(t, "trackB"))
+	require.NoError(t, err)
+	require.NotEqual(t, ssrcA, getSenderSSRC(senderB))
+	require.Zero(t, collisions)
+
+	// Another sender already uses the SSRC.
+	require.True(t, s.isSSRCInUse(senderB, ssrcA))
+
+	// A received stream uses the SSRC.
+	s.ssrcs.BindRemoteStream(&interceptor.StreamInfo{SSRC: getSenderSSRC(senderB)}, nil)
+	require.True(t, s.isSSRCInUse(senderB, getSenderSSRC(senderB)))
+
+	// Removed tracks don't count.
+	require.NoError(t, peerConn.RemoveTrack(senderA))
+	require.False(t, s.isSSRCInUse(senderB, ssrcA))
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// SessionState is a snapshot of the state of a session in a call.
+type SessionState struct {
+	SessionID string `json:"session_id"`
+	UserID    string `json:"user_id"`
+	// TraceID identifies the session across services.
+	TraceID string `json:"trace_id"`
+	// HasVoice is true if the session is sending a voice track.
+	HasVoice bool `json:"has_voice"`
+	// Unmuted is true if the voice track is currently being forwarded.
+	Unmuted bool `json:"unmuted"`
+	// ScreenSharing is true if the session is sharing its screen.
+	ScreenSharing bool `json:"screen_sharing"`
+	// Tracks lists the IDs of the outgoing tracks of the session.
+	Tracks []string `json:"tracks"`
+	// StalledTracks lists the IDs of the outgoing tracks of the session no
+	// packet was received on for longer than the inactivity timeout.
+	StalledTracks []string `json:"stalled_tracks,omitempty"`
+	// JoinTimings holds the times the session went through each setup
+	// phase.
+	JoinTimings JoinTimings `json:"join_timings"`
+	// Quality holds the quality of the streams forwarded to the session.
+	Quality []StreamQuality `json:"quality"`
+}
+
+// CallState is a snapshot of the state of a call, meant to let clients
+// resync after a reconnect.
+type CallState struct {
+	GroupID         string         `json:"group_id"`
+	CallID          string         `json:"call_id"`
+	Sessions        []SessionState `json:"sessions"`
+	ScreenSessionID string         `json:"screen_session_id,omitempty"`
+	Transcribing    bool           `json:"transcribing"`
+	// HLSStreamID is the ID of the LL-HLS stream broadcasting the call, if
+	// any.
+	HLSStreamID string `json:"hls_stream_id,omitempty"`
+	// AudioOnly is set for calls rejecting video tracks.
+	AudioOnly bool `json:"audio_only,omitempty"`
+}
+
+func (s *session) getState(isScreenSession bool) SessionState {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	state := SessionState{
+		SessionID:     s.cfg.SessionID,
+		UserID:        s.cfg.UserID,
+		TraceID:       s.cfg.TraceID,
+		HasVoice:      s.outVoiceTrack != nil,
+		Unmuted:       s.outVoiceTrackEnabled,
+		ScreenSharing: isScreenSession,
+		Tracks:        []string{},
+		JoinTimings:   s.joinTimings,
+		Quality:       s.getQuality(),
+		StalledTracks: s.getStalledTracks(),
+	}
+
+	for _, track := range []*webrtc.TrackLocalStaticRTP{s.outVoiceTrack, s.outScreenTrack, s.outScreenAudioTrack} {
+		if track != nil {
+			state.Tracks = append(state.Tracks, track.ID())
+		}
+	}
+
+	return state
+}
+
+// GetCallState returns a snapshot of the state of the given call.
+func (s *Server) GetCallState(groupID, callID string) (CallState, error) {
+	group := s.getGroup(groupID)
+	if group == nil {
+		return CallState{}, fmt.Errorf("group not found: %s", groupID)
+	}
+	call := group.getCall(callID)
+	if call == nil {
+		return CallState{}, fmt.Errorf("call not found: %s", callID)
+	}
+
+	call.mut.RLock()
+	sessions := make([]*session, 0, len(call.sessions))
+	for _, ss := range call.sessions {
+		if !ss.cfg.Hidden {
+			sessions = append(sessions, ss)
+		}
+	}
+	screenSession := call.screenSession
+	transcribing := call.transcriber != nil
+	var hlsStreamID string
+	if call.hlsStream != nil {
+		hlsStreamID = call.hlsStream.info.ID
+	}
+	call.mut.RUnlock()
+
+	state := CallState{
+		GroupID:      groupID,
+		CallID:       callID,
+		Sessions:     make([]SessionState, 0, len(sessions)),
+		Transcribing: transcribing,
+		HLSStreamID:  hlsStreamID,
+		AudioOnly:    call.audioOnly,
+	}
+	if screenSession != nil {
+		state.ScreenSessionID = screenSession.cfg.SessionID
+	}
+
+	for _, ss := range sessions {
+		state.Sessions = append(state.Sessions, ss.getState(ss == screenSession))
+	}
+	sort.Slice(state.Sessions, func(i, j int) bool {
+		return state.Sessions[i].SessionID < state.Sessions[j].SessionID
+	})
+
+	return state, nil
+}
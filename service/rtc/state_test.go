@@ -0,0 +1,90 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCallState(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := server.GetCallState("groupID", "callID")
+		require.Error(t, err)
+		require.Equal(t, "group not found: groupID", err.Error())
+	})
+
+	t.Run("success", func(t *testing.T) {
+		var sessions []*session
+		for _, id := range []string{"sessionB", "sessionA"} {
+			cfg := SessionConfig{
+				GroupID:   "groupID",
+				CallID:    "callID",
+				UserID:    "user" + id,
+				SessionID: id,
+				TraceID:   "trace" + id,
+			}
+			peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+			require.NoError(t, err)
+			us, err := server.addSession(cfg, peerConn, nil)
+			require.NoError(t, err)
+			sessions = append(sessions, us)
+			defer func() {
+				err := server.CloseSession(cfg.SessionID)
+				require.NoError(t, err)
+			}()
+		}
+
+		_, err := server.GetCallState("groupID", "unknown")
+		require.Error(t, err)
+		require.Equal(t, "call not found: unknown", err.Error())
+
+		voiceTrack, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: "audio/opus"}, "voiceTrackID", "streamID")
+		require.NoError(t, err)
+		sessions[0].mut.Lock()
+		sessions[0].outVoiceTrack = voiceTrack
+		sessions[0].outVoiceTrackEnabled = true
+		sessions[0].mut.Unlock()
+
+		group := server.getGroup("groupID")
+		require.NotNil(t, group)
+		require.True(t, group.getCall("callID").setScreenSession(sessions[1]))
+
+		quality := newStreamQuality("voiceTrackID", 0.1, 20, 100, time.Now())
+		sessions[1].updateQuality(quality)
+
+		state, err := server.GetCallState("groupID", "callID")
+		require.NoError(t, err)
+		require.Equal(t, CallState{
+			GroupID:         "groupID",
+			CallID:          "callID",
+			ScreenSessionID: "sessionA",
+			Sessions: []SessionState{
+				{
+					SessionID:     "sessionA",
+					UserID:        "usersessionA",
+					TraceID:       "tracesessionA",
+					ScreenSharing: true,
+					Tracks:        []string{},
+					Quality:       []StreamQuality{quality},
+				},
+				{
+					SessionID: "sessionB",
+					UserID:    "usersessionB",
+					TraceID:   "tracesessionB",
+					HasVoice:  true,
+					Unmuted:   true,
+					Tracks:    []string{"voiceTrackID"},
+					Quality:   []StreamQuality{},
+				},
+			},
+		}, state)
+	})
+}
@@ -9,17 +9,92 @@ import (
 	"strings"
 	"time"
 
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
 	"github.com/pion/stun"
 )
 
-func getPublicIP(port int, stunURL string) (string, error) {
+// discoverPublicIP queries the STUN servers, in order, for the public IP
+// address of the host as seen from the given local port (zero meaning any).
+func (s *Server) discoverPublicIP(port int) (string, error) {
+	var errs []string
+	for _, u := range s.cfg.getDiscoverySTUNServers() {
+		addr, err := getPublicIP(s.cfg.LocalUDPPorts, port, u, s.cfg.PublicIPDiscovery.getTimeout())
+		if err == nil {
+			return addr, nil
+		}
+		s.log.Warn("failed to get public IP address", mlog.String("url", u), mlog.Err(err))
+		errs = append(errs, err.Error())
+	}
+	return "", fmt.Errorf("no STUN server answered: %s", strings.Join(errs, "; "))
+}
+
+// publicIPChecker periodically discovers the public IP address again so that
+// changes (e.g. a cloud VM getting a new address) are picked up.
+func (s *Server) publicIPChecker(stopCh <-chan struct{}, doneCh chan<- struct{}) {
+	defer close(doneCh)
+
+	ticker := time.NewTicker(time.Duration(s.cfg.PublicIPDiscovery.RecheckIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// The ICE port is taken by the media sockets. Any other port is
+			// mapped to the same address by the 1:1 NATs of cloud providers.
+			addr, err := s.discoverPublicIP(0)
+			if err != nil {
+				s.log.Error("failed to re-check public IP address", mlog.Err(err))
+				continue
+			}
+			s.setPublicIP(addr)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// setPublicIP updates the discovered public IP address. Only sessions
+// initialized afterwards advertise the new address.
+func (s *Server) setPublicIP(addr string) {
+	s.publicIPMut.Lock()
+	prevAddr := s.publicIP
+	s.publicIP = addr
+	s.publicIPMut.Unlock()
+
+	if prevAddr == "" {
+		s.log.Info("got public IP address", mlog.String("addr", addr))
+	} else if prevAddr != addr {
+		s.log.Warn("public IP address changed, new sessions will advertise the new one",
+			mlog.String("prevAddr", prevAddr), mlog.String("addr", addr))
+		s.metrics.IncPublicIPChanges()
+	}
+}
+
+// getICEHost returns the host advertised in the host candidates: either the
+// configured override or the discovered public IP address, if any.
+func (s *Server) getICEHost() string {
+	if s.cfg.ICEHostOverride != "" {
+		return s.cfg.ICEHostOverride
+	}
+	s.publicIPMut.RLock()
+	defer s.publicIPMut.RUnlock()
+	return s.publicIP
+}
+
+// GetPublicIP returns the discovered public IP address. It's empty if
+// discovery is disabled or ICEHostOverride is set.
+func (s *Server) GetPublicIP() string {
+	s.publicIPMut.RLock()
+	defer s.publicIPMut.RUnlock()
+	return s.publicIP
+}
+
+func getPublicIP(localPorts LocalUDPPortsConfig, port int, stunURL string, timeout time.Duration) (string, error) {
 	if stunURL == "" {
 		return "", fmt.Errorf("no STUN server URL was provided")
 	}
 
-	conn, err := net.ListenUDP("udp4", &net.UDPAddr{
-		Port: port,
-	})
+	conn, err := localPorts.listenUDP(port)
 	if err != nil {
 		return "", err
 	}
@@ -31,7 +106,7 @@ func getPublicIP(port int, stunURL string) (string, error) {
 		return "", fmt.Errorf("failed to resolve stun host: %w", err)
 	}
 
-	xoraddr, err := getXORMappedAddr(conn, serverAddr, 5*time.Second)
+	xoraddr, err := getXORMappedAddr(conn, serverAddr, timeout)
 	if err != nil {
 		return "", fmt.Errorf("failed to get public address: %w", err)
 	}
@@ -0,0 +1,87 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverPublicIP(t *testing.T) {
+	stunAddr, closeSTUN := setupTURNServer(t, "username", "password")
+	defer closeSTUN()
+
+	// A server that never answers.
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	t.Run("fallback", func(t *testing.T) {
+		server, shutdown := setupServer(t)
+		defer shutdown()
+
+		server.cfg.PublicIPDiscovery = PublicIPDiscoveryConfig{
+			STUNServers:    []string{"stun:" + conn.LocalAddr().String(), "stun:" + stunAddr},
+			TimeoutSeconds: 1,
+		}
+
+		addr, err := server.discoverPublicIP(0)
+		require.NoError(t, err)
+		require.Equal(t, "127.0.0.1", addr)
+	})
+
+	t.Run("no answer", func(t *testing.T) {
+		server, shutdown := setupServer(t)
+		defer shutdown()
+
+		server.cfg.PublicIPDiscovery = PublicIPDiscoveryConfig{
+			STUNServers:    []string{"stun:" + conn.LocalAddr().String()},
+			TimeoutSeconds: 1,
+		}
+
+		addr, err := server.discoverPublicIP(0)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "no STUN server answered")
+		require.Empty(t, addr)
+	})
+
+	t.Run("start", func(t *testing.T) {
+		server, shutdown := setupServer(t)
+		defer shutdown()
+
+		server.cfg.ICEServers = ICEServers{{URLs: []string{"stun:" + stunAddr}}}
+		server.cfg.PublicIPDiscovery.RecheckIntervalSeconds = 60
+
+		err := server.Start()
+		require.NoError(t, err)
+		require.Equal(t, "127.0.0.1", server.GetPublicIP())
+		require.Equal(t, "127.0.0.1", server.getICEHost())
+	})
+
+	t.Run("override", func(t *testing.T) {
+		server, shutdown := setupServer(t)
+		defer shutdown()
+
+		server.cfg.ICEHostOverride = "10.0.0.1"
+		server.cfg.ICEServers = ICEServers{{URLs: []string{"stun:" + stunAddr}}}
+
+		err := server.Start()
+		require.NoError(t, err)
+		require.Empty(t, server.GetPublicIP())
+		require.Equal(t, "10.0.0.1", server.getICEHost())
+	})
+}
+
+func TestSetPublicIP(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	server.setPublicIP("10.0.0.1")
+	require.Equal(t, "10.0.0.1", server.getICEHost())
+
+	server.setPublicIP("10.0.0.2")
+	require.Equal(t, "10.0.0.2", server.getICEHost())
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	TopologyTrackKindVoice       = "voice"
+	TopologyTrackKindScreen      = "screen"
+	TopologyTrackKindScreenAudio = "screen_audio"
+)
+
+// TopologyTrack is a track published by a session of a call.
+type TopologyTrack struct {
+	TrackID string `json:"track_id"`
+	// Kind is one of "voice", "screen" or "screen_audio".
+	Kind     string `json:"kind"`
+	MimeType string `json:"mime_type"`
+	// Muted is set for voice tracks not currently being forwarded.
+	Muted bool `json:"muted,omitempty"`
+	// Stalled is set if no packet was received on the track for longer than
+	// the inactivity timeout.
+	Stalled bool `json:"stalled,omitempty"`
+}
+
+// TopologyPublisher is a session of a call publishing tracks.
+type TopologyPublisher struct {
+	SessionID string          `json:"session_id"`
+	UserID    string          `json:"user_id"`
+	Tracks    []TopologyTrack `json:"tracks"`
+}
+
+// TopologySubscriber is a session of a call, along with the tracks it
+// receives.
+type TopologySubscriber struct {
+	SessionID string `json:"session_id"`
+	UserID    string `json:"user_id"`
+	Hidden    bool   `json:"hidden,omitempty"`
+	// Observer is set for the sessions joined by admins to watch the call.
+	Observer bool `json:"observer,omitempty"`
+	// Connected is set while the peer connection of the session is
+	// established.
+	Connected bool `json:"connected"`
+	// Tracks lists the IDs of the tracks forwarded to the session.
+	Tracks []string `json:"tracks"`
+	// MissingTracks lists the IDs of the tracks published by the other
+	// sessions of the call that aren't forwarded to the session.
+	MissingTracks []string `json:"missing_tracks,omitempty"`
+}
+
+// TopologyEdge is a track forwarded to a subscriber, an edge of the
+// forwarding graph of the call.
+type TopologyEdge struct {
+	TrackID string `json:"track_id"`
+	// PublisherSessionID is empty for tracks not published by a session of
+	// the call (e.g. announcements).
+	PublisherSessionID  string `json:"publisher_session_id,omitempty"`
+	SubscriberSessionID string `json:"subscriber_session_id"`
+	// SSRC is the SSRC of the stream the track is sent on to the subscriber.
+	SSRC   uint32 `json:"ssrc"`
+	Paused bool   `json:"paused"`
+	// MaxFramerate is the framerate video tracks are limited to for the
+	// subscriber, by dropping frames of the upper temporal layers first. It's
+	// zero if the full framerate is forwarded. Simulcast isn't supported, so
+	// this is the only layer selection made.
+	MaxFramerate int `json:"max_framerate,omitempty"`
+}
+
+// CallTopology describes how the tracks of a call are routed between its
+// sessions.
+type CallTopology struct {
+	GroupID     string               `json:"group_id"`
+	CallID      string               `json:"call_id"`
+	Publishers  []TopologyPublisher  `json:"publishers"`
+	Subscribers []TopologySubscriber `json:"subscribers"`
+	Edges       []TopologyEdge       `json:"edges"`
+}
+
+// getPublishedTracks returns the tracks published by the session. Must be
+// called with s.mut held.
+func (s *session) getPublishedTracks() []TopologyTrack {
+	stalled := map[string]bool{}
+	for _, trackID := range s.getStalledTracks() {
+		stalled[trackID] = true
+	}
+
+	var tracks []TopologyTrack
+	for _, t := range []struct {
+		track *webrtc.TrackLocalStaticRTP
+		kind  string
+	}{
+		{s.outVoiceTrack, TopologyTrackKindVoice},
+		{s.outScreenTrack, TopologyTrackKindScreen},
+		{s.outScreenAudioTrack, TopologyTrackKindScreenAudio},
+	} {
+		if t.track == nil {
+			continue
+		}
+		tracks = append(tracks, TopologyTrack{
+			TrackID:  t.track.ID(),
+			Kind:     t.kind,
+			MimeType: t.track.Codec().MimeType,
+			Muted:    t.kind == TopologyTrackKindVoice && !s.outVoiceTrackEnabled,
+			Stalled:  stalled[t.track.ID()],
+		})
+	}
+	return tracks
+}
+
+// GetCallTopology returns a snapshot of the routing of the tracks of the
+// given call, hidden sessions included.
+func (s *Server) GetCallTopology(groupID, callID string) (CallTopology, error) {
+	group := s.getGroup(groupID)
+	if group == nil {
+		return CallTopology{}, fmt.Errorf("group not found: %s", groupID)
+	}
+	call := group.getCall(callID)
+	if call == nil {
+		return CallTopology{}, fmt.Errorf("call not found: %s", callID)
+	}
+
+	call.mut.RLock()
+	sessions := make([]*session, 0, len(call.sessions))
+	for _, ss := range call.sessions {
+		sessions = append(sessions, ss)
+	}
+	call.mut.RUnlock()
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].cfg.SessionID < sessions[j].cfg.SessionID
+	})
+
+	topology := CallTopology{
+		GroupID:     groupID,
+		CallID:      callID,
+		Publishers:  []TopologyPublisher{},
+		Subscribers: []TopologySubscriber{},
+		Edges:       []TopologyEdge{},
+	}
+
+	// Tracks of hidden sessions are ignored, so they're left out.
+	publishers := map[string]string{}
+	for _, ss := range sessions {
+		if ss.cfg.Hidden {
+			continue
+		}
+		ss.mut.RLock()
+		tracks := ss.getPublishedTracks()
+		ss.mut.RUnlock()
+		if len(tracks) == 0 {
+			continue
+		}
+		for _, track := range tracks {
+			publishers[track.TrackID] = ss.cfg.SessionID
+		}
+		topology.Publishers = append(topology.Publishers, TopologyPublisher{
+			SessionID: ss.cfg.SessionID,
+			UserID:    ss.cfg.UserID,
+			Tracks:    tracks,
+		})
+	}
+
+	for _, ss := range sessions {
+		sub := TopologySubscriber{
+			SessionID: ss.cfg.SessionID,
+			UserID:    ss.cfg.UserID,
+			Hidden:    ss.cfg.Hidden,
+			Observer:  ss.cfg.Observer,
+			Tracks:    []string{},
+		}
+
+		ss.mut.RLock()
+		sub.Connected = ss.connected
+		for trackID, ts := range ss.senders {
+			edge := TopologyEdge{
+				TrackID:             trackID,
+				PublisherSessionID:  publishers[trackID],
+				SubscriberSessionID: ss.cfg.SessionID,
+				SSRC:                ts.ssrc,
+				Paused:              ts.paused,
+			}
+			if ts.throttler != nil {
+				edge.MaxFramerate = ts.throttler.getMaxFramerate()
+			}
+			sub.Tracks = append(sub.Tracks, trackID)
+			topology.Edges = append(topology.Edges, edge)
+		}
+		for trackID, publisherID := range publishers {
+			if _, ok := ss.senders[trackID]; !ok && publisherID != ss.cfg.SessionID {
+				sub.MissingTracks = append(sub.MissingTracks, trackID)
+			}
+		}
+		ss.mut.RUnlock()
+
+		sort.Strings(sub.Tracks)
+		sort.Strings(sub.MissingTracks)
+		topology.Subscribers = append(topology.Subscribers, sub)
+	}
+
+	sort.Slice(topology.Edges, func(i, j int) bool {
+		if topology.Edges[i].SubscriberSessionID != topology.Edges[j].SubscriberSessionID {
+			return topology.Edges[i].SubscriberSessionID < topology.Edges[j].SubscriberSessionID
+		}
+		return topology.Edges[i].TrackID < topology.Edges[j].TrackID
+	})
+
+	return topology, nil
+}
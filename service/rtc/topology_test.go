@@ -0,0 +1,98 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCallTopology(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := server.GetCallTopology("groupID", "callID")
+		require.EqualError(t, err, "group not found: groupID")
+	})
+
+	sessions := map[string]*session{}
+	for _, id := range []string{"sessionA", "sessionB", "sessionC"} {
+		cfg := SessionConfig{
+			GroupID:   "groupID",
+			CallID:    "callID",
+			UserID:    "user" + id,
+			SessionID: id,
+			Hidden:    id == "sessionC",
+			Observer:  id == "sessionC",
+		}
+		peerConn, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		us, err := server.addSession(cfg, peerConn, nil)
+		require.NoError(t, err)
+		sessions[id] = us
+		defer func() {
+			require.NoError(t, server.CloseSession(cfg.SessionID))
+		}()
+	}
+
+	_, err := server.GetCallTopology("groupID", "unknown")
+	require.EqualError(t, err, "call not found: unknown")
+
+	voiceTrack, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: "audio/opus"}, "voiceA", "streamA")
+	require.NoError(t, err)
+	screenTrack, err := webrtc.NewTrackLocalStaticRTP(rtpVideoCodecVP8, "screenA", "streamA")
+	require.NoError(t, err)
+	throttledTrack, err := webrtc.NewTrackLocalStaticRTP(rtpVideoCodecVP8, "screenA", "streamA")
+	require.NoError(t, err)
+
+	sessions["sessionA"].mut.Lock()
+	sessions["sessionA"].outVoiceTrack = voiceTrack
+	sessions["sessionA"].outScreenTrack = screenTrack
+	sessions["sessionA"].connected = true
+	sessions["sessionA"].mut.Unlock()
+
+	// sessionB is missing the screen track.
+	sessions["sessionB"].mut.Lock()
+	sessions["sessionB"].senders = map[string]*trackSender{
+		"voiceA": {track: voiceTrack, ssrc: 1000},
+	}
+	sessions["sessionB"].mut.Unlock()
+
+	sessions["sessionC"].mut.Lock()
+	sessions["sessionC"].senders = map[string]*trackSender{
+		"voiceA":  {track: voiceTrack, ssrc: 2000, paused: true},
+		"screenA": {track: screenTrack, ssrc: 2001, throttler: newFrameThrottler(throttledTrack, 5)},
+	}
+	sessions["sessionC"].mut.Unlock()
+
+	topology, err := server.GetCallTopology("groupID", "callID")
+	require.NoError(t, err)
+	require.Equal(t, CallTopology{
+		GroupID: "groupID",
+		CallID:  "callID",
+		Publishers: []TopologyPublisher{
+			{
+				SessionID: "sessionA",
+				UserID:    "usersessionA",
+				Tracks: []TopologyTrack{
+					{TrackID: "voiceA", Kind: TopologyTrackKindVoice, MimeType: "audio/opus", Muted: true},
+					{TrackID: "screenA", Kind: TopologyTrackKindScreen, MimeType: rtpVideoCodecVP8.MimeType},
+				},
+			},
+		},
+		Subscribers: []TopologySubscriber{
+			{SessionID: "sessionA", UserID: "usersessionA", Connected: true, Tracks: []string{}},
+			{SessionID: "sessionB", UserID: "usersessionB", Tracks: []string{"voiceA"}, MissingTracks: []string{"screenA"}},
+			{SessionID: "sessionC", UserID: "usersessionC", Hidden: true, Observer: true, Tracks: []string{"screenA", "voiceA"}},
+		},
+		Edges: []TopologyEdge{
+			{TrackID: "voiceA", PublisherSessionID: "sessionA", SubscriberSessionID: "sessionB", SSRC: 1000},
+			{TrackID: "screenA", PublisherSessionID: "sessionA", SubscriberSessionID: "sessionC", SSRC: 2001, MaxFramerate: 5},
+			{TrackID: "voiceA", PublisherSessionID: "sessionA", SubscriberSessionID: "sessionC", SSRC: 2000, Paused: true},
+		},
+	}, topology)
+}
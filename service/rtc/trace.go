@@ -0,0 +1,22 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"regexp"
+
+	"github.com/mattermost/rtcd/service/random"
+)
+
+// maxTraceIDLength is the maximum length of the trace ID of a session.
+const maxTraceIDLength = 64
+
+// traceIDRE matches the valid trace IDs, leaving room for the common formats
+// (e.g. UUIDs or W3C trace IDs) to be passed by the plugin.
+var traceIDRE = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// NewTraceID returns a new identifier to trace a session with.
+func NewTraceID() string {
+	return random.NewID()
+}
@@ -0,0 +1,283 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/rtp"
+)
+
+const transcriberChSize = 512
+
+// TranscriptionPacket is the unit of audio forwarded to the external
+// transcription service. The payload is a raw Opus frame as received from the
+// participant.
+type TranscriptionPacket struct {
+	SessionID      string `msgpack:"session_id"`
+	UserID         string `msgpack:"user_id"`
+	SequenceNumber uint16 `msgpack:"seq"`
+	Timestamp      uint32 `msgpack:"ts"`
+	Payload        []byte `msgpack:"payload"`
+}
+
+// Caption is the text returned by the external transcription service for a
+// given session.
+type Caption struct {
+	SessionID string `json:"session_id"`
+	UserID    string `json:"user_id"`
+	Text      string `json:"text"`
+	Final     bool   `json:"final"`
+}
+
+// TranscriptionSink is a connection to the external transcription service,
+// opened for each transcribed call.
+type TranscriptionSink interface {
+	// Send forwards a packet of audio to the service.
+	Send(pkt TranscriptionPacket) error
+	// CaptionsCh returns the channel of the captions received from the
+	// service. It's closed once the connection is lost or the sink closed.
+	CaptionsCh() <-chan Caption
+	Close() error
+}
+
+// TranscriptionDialer connects to the transcription service configured by
+// cfg.
+type TranscriptionDialer func(cfg TranscriptionConfig) (TranscriptionSink, error)
+
+// WithTranscriptionDialer sets how the server connects to the transcription
+// service. Transcription is disabled without one.
+func WithTranscriptionDialer(dial TranscriptionDialer) ServerOption {
+	return func(s *Server) error {
+		s.transcriptionDialer = dial
+		return nil
+	}
+}
+
+// transcriber forks the voice tracks of a call to an external transcription
+// service.
+type transcriber struct {
+	log     mlog.LoggerIFace
+	sink    TranscriptionSink
+	pktCh   chan TranscriptionPacket
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+}
+
+// newTranscriber connects to the transcription service. closedCb is called,
+// from its own goroutine, if the service closes the connection.
+func newTranscriber(dial TranscriptionDialer, cfg TranscriptionConfig, log mlog.LoggerIFace, captionCb func(c Caption), closedCb func(t *transcriber)) (*transcriber, error) {
+	sink, err := dial(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to transcription service: %w", err)
+	}
+
+	t := &transcriber{
+		log:     log,
+		sink:    sink,
+		pktCh:   make(chan TranscriptionPacket, transcriberChSize),
+		closeCh: make(chan struct{}),
+	}
+
+	t.wg.Add(2)
+	go t.writer()
+	go t.reader(captionCb, closedCb)
+
+	return t, nil
+}
+
+// push queues an RTP packet to be forwarded. Packets are dropped if the
+// transcription service cannot keep up.
+func (t *transcriber) push(cfg SessionConfig, pkt *rtp.Packet) {
+	payload := make([]byte, len(pkt.Payload))
+	copy(payload, pkt.Payload)
+
+	select {
+	case t.pktCh <- TranscriptionPacket{
+		SessionID:      cfg.SessionID,
+		UserID:         cfg.UserID,
+		SequenceNumber: pkt.SequenceNumber,
+		Timestamp:      pkt.Timestamp,
+		Payload:        payload,
+	}:
+	default:
+	}
+}
+
+func (t *transcriber) writer() {
+	defer t.wg.Done()
+	for {
+		select {
+		case pkt := <-t.pktCh:
+			if err := t.sink.Send(pkt); err != nil {
+				t.log.Error("failed to send transcription packet", mlog.Err(err))
+			}
+		case <-t.closeCh:
+			return
+		}
+	}
+}
+
+func (t *transcriber) reader(captionCb func(c Caption), closedCb func(t *transcriber)) {
+	defer t.wg.Done()
+	defer func() {
+		select {
+		case <-t.closeCh:
+		default:
+			// Closing waits for the reader, hence the goroutine.
+			go closedCb(t)
+		}
+	}()
+
+	for caption := range t.sink.CaptionsCh() {
+		if caption.SessionID == "" || caption.Text == "" {
+			continue
+		}
+		captionCb(caption)
+	}
+}
+
+func (t *transcriber) close() error {
+	close(t.closeCh)
+	err := t.sink.Close()
+	t.wg.Wait()
+	return err
+}
+
+// StartTranscription starts forking the voice tracks of the given call to the
+// configured transcription service. Captions received back are relayed to all
+// the sessions in the call as CaptionMessage messages.
+//
+// The transcription service is connected to in the background, a
+// TranscriptionStartedEvent being sent once done. errCb, if set, is called
+// with the error if it fails.
+func (s *Server) StartTranscription(groupID, callID string, errCb func(err error)) error {
+	if s.cfg.Transcription.URL == "" || s.transcriptionDialer == nil {
+		return fmt.Errorf("transcription is not enabled")
+	}
+
+	group := s.getGroup(groupID)
+	if group == nil {
+		return fmt.Errorf("group not found: %s", groupID)
+	}
+	call := group.getCall(callID)
+	if call == nil {
+		return fmt.Errorf("call not found: %s", callID)
+	}
+
+	if !call.reserveTranscriber() {
+		return fmt.Errorf("transcription already started")
+	}
+
+	s.transcribersWg.Add(1)
+	go func() {
+		defer s.transcribersWg.Done()
+		if err := s.startTranscriber(groupID, callID, call); err != nil {
+			s.log.Error("failed to start transcription", mlog.Err(err), mlog.String("groupID", groupID), mlog.String("callID", callID))
+			if errCb != nil {
+				errCb(err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// startTranscriber connects the transcriber reserved for the given call.
+func (s *Server) startTranscriber(groupID, callID string, call *call) error {
+	t, err := newTranscriber(s.transcriptionDialer, s.cfg.Transcription, s.log, func(c Caption) {
+		s.sendCaption(call, c)
+	}, func(t *transcriber) {
+		s.onTranscriberClosed(groupID, callID, call, t)
+	})
+	if err != nil {
+		call.setTranscriber(nil)
+		return err
+	}
+
+	if !call.setTranscriber(t) {
+		// Stopped, or the call ended, while connecting.
+		s.log.Debug("transcription canceled", mlog.String("groupID", groupID), mlog.String("callID", callID))
+		if err := t.close(); err != nil {
+			s.log.Error("failed to close transcriber", mlog.Err(err), mlog.String("callID", callID))
+		}
+		return nil
+	}
+
+	s.log.Debug("transcription started", mlog.String("groupID", groupID), mlog.String("callID", callID))
+	s.sendEvent(newCallEvent(TranscriptionStartedEvent, groupID, callID))
+
+	return nil
+}
+
+// StopTranscription stops the transcription for the given call.
+func (s *Server) StopTranscription(groupID, callID string) error {
+	group := s.getGroup(groupID)
+	if group == nil {
+		return fmt.Errorf("group not found: %s", groupID)
+	}
+	call := group.getCall(callID)
+	if call == nil {
+		return fmt.Errorf("call not found: %s", callID)
+	}
+
+	t, starting := call.clearTranscriber()
+	if t == nil {
+		if starting {
+			s.log.Debug("transcription start canceled", mlog.String("groupID", groupID), mlog.String("callID", callID))
+			return nil
+		}
+		return fmt.Errorf("transcription not started")
+	}
+
+	s.log.Debug("transcription stopped", mlog.String("groupID", groupID), mlog.String("callID", callID))
+
+	err := t.close()
+	s.sendEvent(newCallEvent(TranscriptionStoppedEvent, groupID, callID))
+
+	return err
+}
+
+// onTranscriberClosed stops the transcription of the given call once the
+// transcription service closed its connection, so that it can be started
+// again.
+func (s *Server) onTranscriberClosed(groupID, callID string, call *call, t *transcriber) {
+	if !call.removeTranscriber(t) {
+		// Already stopped.
+		return
+	}
+
+	s.log.Warn("transcription service closed the connection", mlog.String("groupID", groupID), mlog.String("callID", callID))
+
+	if err := t.close(); err != nil {
+		s.log.Error("failed to close transcriber", mlog.Err(err), mlog.String("callID", callID))
+	}
+	s.sendEvent(newCallEvent(TranscriptionStoppedEvent, groupID, callID))
+}
+
+func (s *Server) sendCaption(call *call, c Caption) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		s.log.Error("failed to marshal caption", mlog.Err(err))
+		return
+	}
+
+	call.iterSessions(func(ss *session) {
+		msg := Message{
+			GroupID:   ss.cfg.GroupID,
+			UserID:    ss.cfg.UserID,
+			SessionID: ss.cfg.SessionID,
+			Type:      CaptionMessage,
+			Data:      data,
+		}
+		select {
+		case s.receiveCh <- msg:
+		default:
+			s.log.Error("failed to send caption message: channel is full", mlog.String("groupID", ss.cfg.GroupID), mlog.String("sessionID", ss.cfg.SessionID), mlog.String("traceID", ss.cfg.TraceID))
+		}
+	})
+}
@@ -0,0 +1,296 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTranscriptionService returns the dialer of an in-memory transcription
+// service captioning every packet, the channel the packets are received on
+// and a function closing the open connections.
+func setupTranscriptionService(t *testing.T) (TranscriptionDialer, chan TranscriptionPacket, func()) {
+	t.Helper()
+
+	pktCh := make(chan TranscriptionPacket, 8)
+	var sinks []*testTranscriptionSink
+	var mut sync.Mutex
+	dial := func(cfg TranscriptionConfig) (TranscriptionSink, error) {
+		if cfg.AuthToken != "authToken" {
+			return nil, fmt.Errorf("unauthorized")
+		}
+		sink := &testTranscriptionSink{pktCh: pktCh, captionsCh: make(chan Caption, 8)}
+		mut.Lock()
+		sinks = append(sinks, sink)
+		mut.Unlock()
+		return sink, nil
+	}
+
+	closeConns := func() {
+		mut.Lock()
+		defer mut.Unlock()
+		for _, sink := range sinks {
+			sink.close()
+		}
+		sinks = nil
+	}
+
+	return dial, pktCh, closeConns
+}
+
+type testTranscriptionSink struct {
+	pktCh      chan TranscriptionPacket
+	captionsCh chan Caption
+	closed     bool
+	mut        sync.Mutex
+}
+
+func (s *testTranscriptionSink) Send(pkt TranscriptionPacket) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.closed {
+		return fmt.Errorf("sink is closed")
+	}
+
+	s.pktCh <- pkt
+	s.captionsCh <- Caption{
+		SessionID: pkt.SessionID,
+		UserID:    pkt.UserID,
+		Text:      "hello",
+		Final:     true,
+	}
+	return nil
+}
+
+func (s *testTranscriptionSink) CaptionsCh() <-chan Caption {
+	return s.captionsCh
+}
+
+func (s *testTranscriptionSink) close() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.captionsCh)
+	}
+}
+
+func (s *testTranscriptionSink) Close() error {
+	s.close()
+	return nil
+}
+
+func TestTranscriber(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		err := log.Shutdown()
+		require.NoError(t, err)
+	}()
+
+	dial, pktCh, _ := setupTranscriptionService(t)
+	u := "ws://transcription.example.com"
+
+	t.Run("unauthorized", func(t *testing.T) {
+		tr, err := newTranscriber(dial, TranscriptionConfig{URL: u}, log, func(_ Caption) {}, func(_ *transcriber) {})
+		require.Error(t, err)
+		require.Nil(t, tr)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		captionCh := make(chan Caption, 1)
+		tr, err := newTranscriber(dial, TranscriptionConfig{URL: u, AuthToken: "authToken"}, log, func(c Caption) {
+			captionCh <- c
+		}, func(_ *transcriber) {
+			require.Fail(t, "unexpected close")
+		})
+		require.NoError(t, err)
+		require.NotNil(t, tr)
+		defer func() {
+			err := tr.close()
+			require.NoError(t, err)
+		}()
+
+		cfg := SessionConfig{
+			GroupID:   "groupID",
+			CallID:    "callID",
+			UserID:    "userID",
+			SessionID: "sessionID",
+		}
+		tr.push(cfg, &rtp.Packet{
+			Header: rtp.Header{
+				SequenceNumber: 45,
+				Timestamp:      1000,
+			},
+			Payload: []byte{0x01, 0x02, 0x03},
+		})
+
+		select {
+		case pkt := <-pktCh:
+			require.Equal(t, TranscriptionPacket{
+				SessionID:      "sessionID",
+				UserID:         "userID",
+				SequenceNumber: 45,
+				Timestamp:      1000,
+				Payload:        []byte{0x01, 0x02, 0x03},
+			}, pkt)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for packet")
+		}
+
+		select {
+		case c := <-captionCh:
+			require.Equal(t, Caption{
+				SessionID: "sessionID",
+				UserID:    "userID",
+				Text:      "hello",
+				Final:     true,
+			}, c)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for caption")
+		}
+	})
+}
+
+func TestTranscriberRemoteClose(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	dial, _, closeConns := setupTranscriptionService(t)
+
+	server.transcriptionDialer = dial
+	server.cfg.Transcription = TranscriptionConfig{URL: "ws://transcription.example.com", AuthToken: "authToken"}
+	c := &call{id: "callID", sessions: map[string]*session{}}
+	server.mut.Lock()
+	server.groups["groupID"] = &group{
+		id:    "groupID",
+		calls: map[string]*call{"callID": c},
+	}
+	server.mut.Unlock()
+
+	waitEvent := func(evType EventType) {
+		t.Helper()
+		select {
+		case ev := <-server.EventsCh():
+			require.Equal(t, evType, ev.Type)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for event", evType)
+		}
+	}
+
+	require.NoError(t, server.StartTranscription("groupID", "callID", nil))
+	waitEvent(TranscriptionStartedEvent)
+
+	closeConns()
+	waitEvent(TranscriptionStoppedEvent)
+	require.Nil(t, c.getTranscriber())
+
+	// The transcription can be started again.
+	require.NoError(t, server.StartTranscription("groupID", "callID", nil))
+	waitEvent(TranscriptionStartedEvent)
+	require.NoError(t, server.StopTranscription("groupID", "callID"))
+	waitEvent(TranscriptionStoppedEvent)
+}
+
+func TestStartTranscription(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	dial, _, _ := setupTranscriptionService(t)
+	u := "ws://transcription.example.com"
+
+	t.Run("not enabled", func(t *testing.T) {
+		err := server.StartTranscription("groupID", "callID", nil)
+		require.Error(t, err)
+		require.Equal(t, "transcription is not enabled", err.Error())
+	})
+
+	t.Run("no dialer", func(t *testing.T) {
+		server.cfg.Transcription.URL = u
+		defer func() { server.cfg.Transcription.URL = "" }()
+		err := server.StartTranscription("groupID", "callID", nil)
+		require.Error(t, err)
+		require.Equal(t, "transcription is not enabled", err.Error())
+	})
+
+	server.transcriptionDialer = dial
+
+	t.Run("group not found", func(t *testing.T) {
+		server.cfg.Transcription.URL = "ws://localhost"
+		defer func() { server.cfg.Transcription.URL = "" }()
+		err := server.StartTranscription("groupID", "callID", nil)
+		require.Error(t, err)
+		require.Equal(t, "group not found: groupID", err.Error())
+	})
+
+	t.Run("stop not started", func(t *testing.T) {
+		err := server.StopTranscription("groupID", "callID")
+		require.Error(t, err)
+		require.Equal(t, "group not found: groupID", err.Error())
+	})
+
+	c := &call{id: "callID", sessions: map[string]*session{}}
+	server.mut.Lock()
+	server.groups["groupID"] = &group{
+		id:    "groupID",
+		calls: map[string]*call{"callID": c},
+	}
+	server.mut.Unlock()
+	defer func() {
+		server.mut.Lock()
+		delete(server.groups, "groupID")
+		server.mut.Unlock()
+	}()
+
+	t.Run("connection failure", func(t *testing.T) {
+		// Missing the auth token, the service rejects the connection.
+		server.cfg.Transcription = TranscriptionConfig{URL: u}
+		defer func() { server.cfg.Transcription = TranscriptionConfig{} }()
+
+		errCh := make(chan error, 1)
+		err := server.StartTranscription("groupID", "callID", func(err error) {
+			errCh <- err
+		})
+		require.NoError(t, err)
+
+		select {
+		case err := <-errCh:
+			require.Contains(t, err.Error(), "failed to connect to transcription service")
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for error")
+		}
+		server.transcribersWg.Wait()
+		require.Nil(t, c.getTranscriber())
+
+		// The failed start is cleared.
+		server.cfg.Transcription.AuthToken = "authToken"
+		require.NoError(t, server.StartTranscription("groupID", "callID", func(err error) {
+			require.Fail(t, "unexpected error", err)
+		}))
+		err = server.StartTranscription("groupID", "callID", nil)
+		require.EqualError(t, err, "transcription already started")
+		server.transcribersWg.Wait()
+		require.NotNil(t, c.getTranscriber())
+		require.NoError(t, server.StopTranscription("groupID", "callID"))
+	})
+
+	t.Run("stop while starting", func(t *testing.T) {
+		server.cfg.Transcription = TranscriptionConfig{URL: u, AuthToken: "authToken"}
+		defer func() { server.cfg.Transcription = TranscriptionConfig{} }()
+
+		require.True(t, c.reserveTranscriber())
+		require.NoError(t, server.StopTranscription("groupID", "callID"))
+
+		// The transcriber connected once stopped is discarded.
+		require.NoError(t, server.startTranscriber("groupID", "callID", c))
+		require.Nil(t, c.getTranscriber())
+	})
+}
@@ -7,12 +7,17 @@ import (
 	"crypto/hmac"
 	"crypto/sha1"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"time"
 )
 
 const MaxTURNCredentialsExpiration = 7 * 24 * 60 // 1 week in minutes
 
+// ErrTURNCredentialsDisabled is returned when TURN credentials are requested
+// while no secret to generate them is configured.
+var ErrTURNCredentialsDisabled = errors.New("TURN credentials are not enabled")
+
 type TURNConfig struct {
 	// The secret key used to generate TURN short-lived authentication
 	// credentials.
@@ -82,3 +87,46 @@ func GenTURNConfigs(turnServers ICEServers, username, secret string, expiryMinut
 
 	return configs, nil
 }
+
+// SetTURNStaticAuthSecret updates the secret used to generate TURN
+// credentials for new sessions.
+func (s *Server) SetTURNStaticAuthSecret(secret string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.cfg.TURNConfig.StaticAuthSecret = secret
+}
+
+// GenICEServers returns the configured STUN servers, along with the TURN
+// servers without static credentials with short-lived ones generated for the
+// given username, and the time the credentials expire at.
+func (s *Server) GenICEServers(username string) (ICEServers, time.Time, error) {
+	s.mut.RLock()
+	secret := s.cfg.TURNConfig.StaticAuthSecret
+	s.mut.RUnlock()
+	if secret == "" {
+		return nil, time.Time{}, ErrTURNCredentialsDisabled
+	}
+
+	expiresAt := time.Now().Add(time.Duration(s.cfg.TURNConfig.CredentialsExpirationMinutes) * time.Minute)
+	servers := ICEServers{}
+	for _, cfg := range s.cfg.ICEServers {
+		if cfg.IsSTUN() {
+			servers = append(servers, ICEServerConfig{URLs: cfg.URLs})
+			continue
+		}
+		if !cfg.IsTURN() || cfg.Username != "" || cfg.Credential != "" {
+			continue
+		}
+		turnUsername, password, err := genTURNCredentials(username, secret, expiresAt.Unix())
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to generate credentials: %w", err)
+		}
+		servers = append(servers, ICEServerConfig{
+			URLs:       cfg.URLs,
+			Username:   turnUsername,
+			Credential: password,
+		})
+	}
+
+	return servers, expiresAt, nil
+}
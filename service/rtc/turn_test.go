@@ -98,3 +98,38 @@ func TestGenTURNConfigs(t *testing.T) {
 		require.NotEmpty(t, configs[1].Credential)
 	})
 }
+
+func TestGenICEServers(t *testing.T) {
+	s, shutdown := setupServer(t)
+	defer shutdown()
+
+	s.cfg.ICEServers = ICEServers{
+		{URLs: []string{"stun:stun.example.com:3478"}},
+		{URLs: []string{"turn:turn1.example.com:3478"}},
+		{URLs: []string{"turn:turn2.example.com:3478"}, Username: "username", Credential: "password"},
+	}
+	s.cfg.TURNConfig.CredentialsExpirationMinutes = 60
+
+	t.Run("disabled", func(t *testing.T) {
+		servers, _, err := s.GenICEServers("clientA")
+		require.ErrorIs(t, err, ErrTURNCredentialsDisabled)
+		require.Empty(t, servers)
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		s.SetTURNStaticAuthSecret("secret")
+		servers, expiresAt, err := s.GenICEServers("clientA")
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+
+		// Servers with static credentials aren't shared.
+		require.Len(t, servers, 2)
+		require.Equal(t, ICEServerConfig{URLs: []string{"stun:stun.example.com:3478"}}, servers[0])
+		require.Equal(t, []string{"turn:turn1.example.com:3478"}, servers[1].URLs)
+		username, password, err := genTURNCredentials("clientA", "secret", expiresAt.Unix())
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("%d:clientA", expiresAt.Unix()), username)
+		require.Equal(t, username, servers[1].Username)
+		require.Equal(t, password, servers[1].Credential)
+	})
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"sync/atomic"
+)
+
+// BandwidthUsage holds the cumulative media traffic of a group.
+type BandwidthUsage struct {
+	// IngressBytes is the total number of RTP payload bytes received from
+	// the group's sessions.
+	IngressBytes uint64 `json:"ingress_bytes"`
+	// EgressBytes is the total number of RTP payload bytes forwarded to the
+	// group's sessions.
+	EgressBytes uint64 `json:"egress_bytes"`
+}
+
+// groupUsage holds the traffic counters of a group. Unlike groups, it's kept
+// for the lifetime of the server so that totals survive calls ending.
+type groupUsage struct {
+	ingressBytes uint64
+	egressBytes  uint64
+}
+
+func (u *groupUsage) addIngress(n int) {
+	atomic.AddUint64(&u.ingressBytes, uint64(n))
+}
+
+func (u *groupUsage) addEgress(n int) {
+	atomic.AddUint64(&u.egressBytes, uint64(n))
+}
+
+// getGroupUsage returns the traffic counters of the given group, creating
+// them if needed.
+func (s *Server) getGroupUsage(groupID string) *groupUsage {
+	s.usageMut.RLock()
+	u := s.usage[groupID]
+	s.usageMut.RUnlock()
+	if u != nil {
+		return u
+	}
+
+	s.usageMut.Lock()
+	defer s.usageMut.Unlock()
+	if u = s.usage[groupID]; u == nil {
+		u = &groupUsage{}
+		s.usage[groupID] = u
+	}
+	return u
+}
+
+// GetBandwidthUsage returns the cumulative media traffic of every group,
+// keyed by group ID.
+func (s *Server) GetBandwidthUsage() map[string]BandwidthUsage {
+	s.usageMut.RLock()
+	defer s.usageMut.RUnlock()
+
+	usage := make(map[string]BandwidthUsage, len(s.usage))
+	for groupID, u := range s.usage {
+		usage[groupID] = BandwidthUsage{
+			IngressBytes: atomic.LoadUint64(&u.ingressBytes),
+			EgressBytes:  atomic.LoadUint64(&u.egressBytes),
+		}
+	}
+	return usage
+}
+
+// AddBandwidthUsage adds the given traffic to the totals of a group. It's
+// meant to restore previously persisted totals.
+func (s *Server) AddBandwidthUsage(groupID string, usage BandwidthUsage) {
+	u := s.getGroupUsage(groupID)
+	atomic.AddUint64(&u.ingressBytes, usage.IngressBytes)
+	atomic.AddUint64(&u.egressBytes, usage.EgressBytes)
+}
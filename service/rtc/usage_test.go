@@ -0,0 +1,39 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandwidthUsage(t *testing.T) {
+	server, shutdown := setupServer(t)
+	defer shutdown()
+
+	require.Empty(t, server.GetBandwidthUsage())
+
+	u := server.getGroupUsage("groupA")
+	require.Same(t, u, server.getGroupUsage("groupA"))
+
+	u.addIngress(100)
+	u.addIngress(50)
+	u.addEgress(300)
+	server.getGroupUsage("groupB").addEgress(10)
+
+	require.Equal(t, map[string]BandwidthUsage{
+		"groupA": {IngressBytes: 150, EgressBytes: 300},
+		"groupB": {EgressBytes: 10},
+	}, server.GetBandwidthUsage())
+
+	server.AddBandwidthUsage("groupA", BandwidthUsage{IngressBytes: 1, EgressBytes: 2})
+	server.AddBandwidthUsage("groupC", BandwidthUsage{IngressBytes: 5})
+
+	require.Equal(t, map[string]BandwidthUsage{
+		"groupA": {IngressBytes: 151, EgressBytes: 302},
+		"groupB": {EgressBytes: 10},
+		"groupC": {IngressBytes: 5},
+	}, server.GetBandwidthUsage())
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/pion/transport/vnet"
+)
+
+// VNet returns the virtual network media is served on, or nil if the UDP
+// sockets of the host are used. In-process peers need to join calls over the
+// same network.
+func (s *Server) VNet() *vnet.Net {
+	return s.vnet
+}
+
+// newVNetConn creates a conn bound to the IPv4 address of the virtual
+// network and the configured ICE port.
+func (s *Server) newVNetConn() (net.PacketConn, error) {
+	ip, err := getVNetIP(s.vnet)
+	if err != nil {
+		return nil, err
+	}
+
+	listenAddress := net.JoinHostPort(ip.String(), strconv.Itoa(s.cfg.ICEPortUDP))
+	conn, err := s.vnet.ListenPacket("udp4", listenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on vnet: %w", err)
+	}
+
+	s.log.Info(fmt.Sprintf("rtc: server is listening on vnet udp %s", listenAddress))
+
+	return conn, nil
+}
+
+// getVNetIP returns the IPv4 address assigned to the virtual network. It's
+// only set once the network is attached to a router.
+func getVNetIP(n *vnet.Net) (net.IP, error) {
+	ifaces, err := n.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vnet interfaces: %w", err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		// Fails if no address is assigned.
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				return ipNet.IP, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no IPv4 address found on vnet")
+}
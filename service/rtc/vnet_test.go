@@ -0,0 +1,48 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/pion/logging"
+	"github.com/pion/transport/vnet"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithVNet(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		var s Server
+		require.EqualError(t, WithVNet(nil)(&s), "invalid vnet: should be virtual")
+	})
+
+	t.Run("not virtual", func(t *testing.T) {
+		var s Server
+		require.EqualError(t, WithVNet(vnet.NewNet(nil))(&s), "invalid vnet: should be virtual")
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var s Server
+		n := vnet.NewNet(&vnet.NetConfig{})
+		require.NoError(t, WithVNet(n)(&s))
+		require.Equal(t, n, s.VNet())
+	})
+}
+
+func TestGetVNetIP(t *testing.T) {
+	n := vnet.NewNet(&vnet.NetConfig{StaticIPs: []string{"10.0.0.5"}})
+	_, err := getVNetIP(n)
+	require.EqualError(t, err, "no IPv4 address found on vnet")
+
+	router, err := vnet.NewRouter(&vnet.RouterConfig{
+		CIDR:          "10.0.0.0/24",
+		LoggerFactory: logging.NewDefaultLoggerFactory(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, router.AddNet(n))
+
+	ip, err := getVNetIP(n)
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.5", ip.String())
+}
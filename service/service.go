@@ -4,7 +4,10 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http/pprof"
 	"sync"
 	"time"
@@ -12,198 +15,662 @@ import (
 	"github.com/mattermost/rtcd/logger"
 	"github.com/mattermost/rtcd/service/api"
 	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/autoscaling"
+	"github.com/mattermost/rtcd/service/crash"
+	"github.com/mattermost/rtcd/service/eventbus"
 	"github.com/mattermost/rtcd/service/perf"
+	"github.com/mattermost/rtcd/service/rpc"
 	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/signaling"
 	"github.com/mattermost/rtcd/service/store"
+	"github.com/mattermost/rtcd/service/vault"
+	"github.com/mattermost/rtcd/service/webhook"
 	"github.com/mattermost/rtcd/service/ws"
 
 	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/transport/vnet"
 )
 
 type Service struct {
 	cfg          Config
 	apiServer    *api.Server
+	adminServer  *api.Server
+	spec         *api.Spec
+	rpcServer    *rpc.Server
 	wsServer     *ws.Server
 	rtcServer    *rtc.Server
 	store        store.Store
 	auth         *auth.Service
+	authProvider auth.Provider
 	metrics      *perf.Metrics
+	watchdog     *perf.Watchdog
+	statsd       *perf.StatsDBackend
 	log          *mlog.Logger
+	recentLogs   *logger.RecentLogs
+	crash        *crash.Reporter
 	sessionCache *auth.SessionCache
+	webhooks     *webhook.Dispatcher
+	autoscaling  *autoscaling.Publisher
+	eventBus     *eventbus.Publisher
+	vault        *vault.Client
+	vaultStopCh  chan struct{}
+	vaultDoneCh  chan struct{}
+	// metricsServer serves the metrics endpoint, if on a separate listener.
+	metricsServer *api.Server
+	// features resolves the state of the features gated behind flags.
+	features *featureFlags
+	// secretsMut guards the secrets in cfg that can be refreshed at runtime.
+	secretsMut sync.RWMutex
+	params     runtimeParams
+	paramsMut  sync.RWMutex
 	// connMap maps user sessions to the websocket connection they originated
 	// from. This is needed to keep track of the MM instance end users are
 	// connected to in order to route any message to it and avoid the additional
 	// intra-cluster messaging layer that can introduce race conditions.
 	connMap map[string]string
+	// sessionScopes maps user sessions to the group and call they joined,
+	// so that only the connections acting on behalf of the group can take
+	// them over.
+	sessionScopes map[string]sessionScope
+	// connProtocols maps connection IDs to the protocol version and
+	// capabilities negotiated with the client.
+	connProtocols map[string]protocolInfo
+	// connGroups maps connection IDs to the additional groups authenticated
+	// on them.
+	connGroups map[string]map[string]bool
+	// replayBuffers holds the signaling messages sent to each session that
+	// haven't been acknowledged yet, for the sessions bound to a connection
+	// supporting replay.
+	replayBuffers map[string]*replayBuffer
+	// localPeers maps the sessions of the in-process peers (test calls and
+	// bots) to the peers.
+	localPeers map[string]*localPeer
+	// bots maps the IDs of the running bots to the bots.
+	bots map[string]*bot
+	// mirrors maps the IDs of the running call mirrors to the mirrors.
+	mirrors map[string]*mirror
 	mut     sync.RWMutex
+	// rpcConns maps the IDs of the active gRPC signaling streams to their
+	// send channels.
+	rpcConns map[string]chan *rpc.ClientMessage
+	rpcMut   sync.RWMutex
+	// outboundConn is the signaling connection dialed by the service when
+	// running in outbound-only mode.
+	outboundConn   *ws.Client
+	outboundConnID string
+	outboundMut    sync.RWMutex
+	outboundStopCh chan struct{}
+	outboundDoneCh chan struct{}
+	usageStopCh    chan struct{}
+	usageDoneCh    chan struct{}
+	// idempotencyStopCh and idempotencyDoneCh control the periodic removal
+	// of the expired idempotency keys.
+	idempotencyStopCh chan struct{}
+	idempotencyDoneCh chan struct{}
+	// callEvents indexes the persisted call events by the key prefix of
+	// their call.
+	callEvents    map[string]*callEventsRange
+	callEventsMut sync.Mutex
+	// callEventsStopCh and callEventsDoneCh control the periodic removal of
+	// the expired call events.
+	callEventsStopCh chan struct{}
+	callEventsDoneCh chan struct{}
+	// storeStopCh and storeDoneCh control the periodic compaction and size
+	// check of the store.
+	storeStopCh chan struct{}
+	storeDoneCh chan struct{}
+	// openFilesStopCh and openFilesDoneCh control the periodic check of the
+	// number of open file descriptors.
+	openFilesStopCh chan struct{}
+	openFilesDoneCh chan struct{}
+	// authLockoutStopCh and authLockoutDoneCh control the periodic removal
+	// of the expired lockout entries.
+	authLockoutStopCh chan struct{}
+	authLockoutDoneCh chan struct{}
+	// dtlsCertStopCh and dtlsCertDoneCh control the periodic rotation of
+	// the persisted DTLS certificate.
+	dtlsCertStopCh chan struct{}
+	dtlsCertDoneCh chan struct{}
+	// dtlsCertPEM is the DTLS certificate currently used by the rtc server.
+	dtlsCertPEM string
+	// authLockoutMut serializes the updates of the lockout entries.
+	authLockoutMut sync.Mutex
+	// maintenance is the scheduled maintenance window, if any.
+	maintenance    *maintenanceWindow
+	maintenanceMut sync.Mutex
+	// maintenanceStopCh is closed once a maintenance window requires the
+	// service to be stopped.
+	maintenanceStopCh   chan struct{}
+	maintenanceStopOnce sync.Once
+	// signalingTraces holds the running signaling traces, keyed by group
+	// and call ID.
+	signalingTraces    map[string]*signalingTrace
+	signalingTracesMut sync.RWMutex
+	// adminEvents dispatches the live server events to the admin
+	// consumers.
+	adminEvents *adminEventsHub
+	// listener, if set, is the listener the HTTP API is served on.
+	listener net.Listener
+	// vnet, if set, is the virtual network media is served on.
+	vnet *vnet.Net
+	// lifecycle starts and stops the components of the service in the
+	// order of their dependencies.
+	lifecycle lifecycle
+	// wsHandlersWg tracks the handler of the ws messages and rtcHandlersWg
+	// the handlers of the rtc messages and events.
+	wsHandlersWg  sync.WaitGroup
+	rtcHandlersWg sync.WaitGroup
+	// p2pCalls maps the group and call IDs of the calls negotiated
+	// peer-to-peer to their state, and p2pSessions the IDs of their sessions
+	// to the calls.
+	p2pCalls    map[string]*p2pCall
+	p2pSessions map[string]*p2pCall
+	p2pMut      sync.Mutex
+	// lostConns maps the IDs of the signaling connections that went away
+	// while still having sessions to the timers closing the sessions not
+	// resumed in time. Guarded by mut.
+	lostConns map[string]*time.Timer
 }
 
-func New(cfg Config) (*Service, error) {
+func New(cfg Config, opts ...ServiceOption) (*Service, error) {
+	var vaultClient *vault.Client
+	if cfg.Vault.Enable {
+		var err error
+		vaultClient, err = vault.NewClient(cfg.Vault)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create vault client: %w", err)
+		}
+		secrets, err := vaultClient.FetchSecrets()
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch secrets from vault: %w", err)
+		}
+		if err := applySecrets(&cfg, secrets); err != nil {
+			return nil, fmt.Errorf("failed to apply secrets from vault: %w", err)
+		}
+	}
+
 	if err := cfg.IsValid(); err != nil {
 		return nil, err
 	}
 
+	if cfg.FIPS.Enable {
+		if err := cfg.FIPS.CheckModule(); err != nil {
+			return nil, err
+		}
+		if len(cfg.RTC.SRTPProtectionProfiles) == 0 {
+			cfg.RTC.SRTPProtectionProfiles = []string{rtc.SRTPProfileAEADAES128GCM}
+		}
+	}
+
 	s := &Service{
-		cfg:     cfg,
-		metrics: perf.NewMetrics("rtcd", nil),
-		connMap: map[string]string{},
+		cfg:               cfg,
+		connMap:           map[string]string{},
+		sessionScopes:     map[string]sessionScope{},
+		connProtocols:     map[string]protocolInfo{},
+		connGroups:        map[string]map[string]bool{},
+		replayBuffers:     map[string]*replayBuffer{},
+		p2pCalls:          map[string]*p2pCall{},
+		p2pSessions:       map[string]*p2pCall{},
+		lostConns:         map[string]*time.Timer{},
+		localPeers:        map[string]*localPeer{},
+		bots:              map[string]*bot{},
+		mirrors:           map[string]*mirror{},
+		rpcConns:          map[string]chan *rpc.ClientMessage{},
+		vault:             vaultClient,
+		vaultStopCh:       make(chan struct{}),
+		vaultDoneCh:       make(chan struct{}),
+		outboundStopCh:    make(chan struct{}),
+		outboundDoneCh:    make(chan struct{}),
+		usageStopCh:       make(chan struct{}),
+		usageDoneCh:       make(chan struct{}),
+		idempotencyStopCh: make(chan struct{}),
+		idempotencyDoneCh: make(chan struct{}),
+		callEvents:        map[string]*callEventsRange{},
+		callEventsStopCh:  make(chan struct{}),
+		callEventsDoneCh:  make(chan struct{}),
+		storeStopCh:       make(chan struct{}),
+		storeDoneCh:       make(chan struct{}),
+		openFilesStopCh:   make(chan struct{}),
+		openFilesDoneCh:   make(chan struct{}),
+		authLockoutStopCh: make(chan struct{}),
+		authLockoutDoneCh: make(chan struct{}),
+		dtlsCertStopCh:    make(chan struct{}),
+		dtlsCertDoneCh:    make(chan struct{}),
+		maintenanceStopCh: make(chan struct{}),
+		signalingTraces:   map[string]*signalingTrace{},
+		adminEvents:       newAdminEventsHub(),
 	}
 
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	if s.metrics == nil && cfg.Metrics.StatsD.Enable {
+		var err error
+		s.statsd, err = perf.NewStatsDBackend(cfg.Metrics.StatsD)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create statsd backend: %w", err)
+		}
+		backend := perf.NewMultiBackend(perf.NewPrometheusBackend(perf.NewRegistry("rtcd")), s.statsd)
+		s.metrics, err = perf.NewMetricsWithBackend("rtcd", backend)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create metrics: %w", err)
+		}
+	} else if s.metrics == nil {
+		s.metrics = perf.NewMetrics("rtcd", nil)
+	}
+	s.metrics.SetCallsAggregationThreshold(cfg.Metrics.AggregateCallsThreshold)
+
 	var err error
-	s.log, err = logger.New(cfg.Logger)
+	if cfg.Process.Crash.LogLines > 0 {
+		s.recentLogs = logger.NewRecentLogs(cfg.Process.Crash.LogLines)
+	}
+	s.log, err = logger.New(cfg.Logger, s.recentLogs)
 	if err != nil {
 		return nil, fmt.Errorf("rtcd: failed to init logger: %w", err)
 	}
 
-	s.log.Info("rtcd: starting up", getVersionInfo().logFields()...)
+	s.crash, err = crash.NewReporter(cfg.Process.Crash, s.log, s.recentLogs, RedactedConfig(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create crash reporter: %w", err)
+	}
+
+	s.log.Info("rtcd: starting up", getVersionInfo(cfg.FIPS.Enable).logFields()...)
+
+	openFilesLimit := s.setupOpenFilesLimit()
+
+	if s.vault != nil {
+		s.log.Info("loaded secrets from vault", mlog.String("address", cfg.Vault.Address), mlog.String("secretPath", cfg.Vault.SecretPath))
+	}
 
-	s.store, err = store.New(cfg.Store.DataSource)
+	s.store, err = OpenStore(cfg.Store)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create store: %w", err)
 	}
-	s.log.Info("initiated data store", mlog.String("DataSource", cfg.Store.DataSource))
+	s.log.Info("initiated data store", mlog.String("DataSource", cfg.Store.DataSource),
+		mlog.Bool("encryption", cfg.Store.EncryptionKey != ""))
+
+	steps, err := MigrateStore(s.store, -1, false)
+	for _, step := range steps {
+		s.log.Info("applied store migration", mlog.Int("version", step.Version), mlog.String("description", step.Description))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate store: %w", err)
+	}
 
 	s.sessionCache, err = auth.NewSessionCache(cfg.API.Security.SessionCache)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session cache: %w", err)
 	}
 
-	s.auth, err = auth.NewService(s.store, s.sessionCache)
+	var authOpts []auth.ServiceOption
+	if cfg.API.Security.SignedAuth.Enable {
+		authOpts = append(authOpts, auth.WithSignedAuth())
+	}
+	s.auth, err = auth.NewService(s.store, s.sessionCache, authOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create auth service: %w", err)
 	}
 	s.log.Info("initiated auth service")
 
-	s.apiServer, err = api.NewServer(cfg.API.HTTP, s.log)
+	s.authProvider, err = auth.NewProvider(cfg.API.Security.RegistrationAuth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registration auth provider: %w", err)
+	}
+
+	var apiOpts []api.ServerOption
+	var rpcOpts []rpc.ServerOption
+	if cfg.FIPS.Enable {
+		apiOpts = append(apiOpts, api.WithFIPSMode())
+		rpcOpts = append(rpcOpts, rpc.WithFIPSMode())
+	}
+	httpOpts := append([]api.ServerOption{}, apiOpts...)
+	if s.listener != nil {
+		httpOpts = append(httpOpts, api.WithListener(s.listener))
+	}
+
+	s.spec, err = api.ParseSpec(openAPISpec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse api spec: %w", err)
+	}
+
+	s.apiServer, err = api.NewServer(cfg.API.HTTP, s.log, httpOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create api server: %w", err)
 	}
+	s.apiServer.SetCrashReporter(s.crash)
+	s.apiServer.SetSpec(s.spec)
+
+	adminServer := s.apiServer
+	if cfg.API.Admin.ListenAddress != "" {
+		s.adminServer, err = api.NewServer(cfg.API.Admin, s.log, apiOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create admin api server: %w", err)
+		}
+		s.adminServer.SetCrashReporter(s.crash)
+		s.adminServer.SetSpec(s.spec)
+		adminServer = s.adminServer
+	}
+
+	metricsServer := s.apiServer
+	if cfg.API.Metrics.ListenAddress != "" {
+		s.metricsServer, err = api.NewServer(cfg.API.Metrics, s.log, apiOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create metrics api server: %w", err)
+		}
+		s.metricsServer.SetCrashReporter(s.crash)
+		metricsServer = s.metricsServer
+	}
 
 	wsConfig := ws.ServerConfig{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
 		PingInterval:    10 * time.Second,
 	}
-	s.wsServer, err = ws.NewServer(wsConfig, s.log, ws.WithAuthCb(s.authHandler))
+	wsOpts := []ws.ServerOption{ws.WithAuthCb(s.wsAuthHandler)}
+	if cfg.API.Chaos.Enable {
+		s.log.Warn("chaos mode is enabled: signaling connections get dropped on purpose",
+			mlog.Int("wsDisconnectPercent", cfg.API.Chaos.WSDisconnectPercent))
+		wsOpts = append(wsOpts, ws.WithDisconnectPercent(cfg.API.Chaos.WSDisconnectPercent))
+	}
+	s.wsServer, err = ws.NewServer(wsConfig, s.log, wsOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ws server: %w", err)
 	}
 
-	s.rtcServer, err = rtc.NewServer(cfg.RTC, s.log, s.metrics)
+	s.features = newFeatureFlags(cfg.Features)
+	rtcOpts := []rtc.ServerOption{
+		rtc.WithFeatureFlags(s.features),
+		rtc.WithTranscriptionDialer(newTranscriptionDialer(s.log)),
+	}
+	if s.vnet != nil {
+		rtcOpts = append(rtcOpts, rtc.WithVNet(s.vnet))
+	}
+	s.rtcServer, err = rtc.NewServer(cfg.RTC, s.log, s.metrics, rtcOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create rtc server: %w", err)
 	}
+	s.rtcServer.SetCrashReporter(s.crash)
+
+	if cfg.RTC.DTLSCertificate.Persist {
+		if err := s.updateDTLSCertificate(time.Now()); err != nil {
+			return nil, fmt.Errorf("failed to load DTLS certificate: %w", err)
+		}
+	}
+
+	if cfg.Metrics.EnableCallMetrics {
+		if err := s.metrics.RegisterCallsCollector("rtcd", cfg.Metrics, s.getCallsStats); err != nil {
+			return nil, fmt.Errorf("failed to register calls collector: %w", err)
+		}
+	}
+
+	if err := s.metrics.RegisterUsageCollector("rtcd", s.getClientsUsage); err != nil {
+		return nil, fmt.Errorf("failed to register usage collector: %w", err)
+	}
+
+	if err := s.metrics.RegisterRTPCollector("rtcd", s.getRTPStats); err != nil {
+		return nil, fmt.Errorf("failed to register rtp collector: %w", err)
+	}
+
+	if cfg.Metrics.Watchdog.Enable {
+		s.watchdog, err = s.metrics.NewWatchdog("rtcd", cfg.Metrics.Watchdog, s.log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create watchdog: %w", err)
+		}
+		s.watchdog.AddChannels("rtc", s.rtcServer.GetChannelsDepth)
+		s.watchdog.AddChannels("ws", s.wsServer.GetChannelsDepth)
+	}
+
+	if err := s.loadRuntimeParams(); err != nil {
+		return nil, fmt.Errorf("failed to load runtime params: %w", err)
+	}
+
+	if err := s.loadFeatureFlags(); err != nil {
+		return nil, fmt.Errorf("failed to load feature flags: %w", err)
+	}
+
+	if err := s.loadBandwidthUsage(); err != nil {
+		return nil, fmt.Errorf("failed to load bandwidth usage: %w", err)
+	}
+
+	if err := s.removeExpiredIdempotencyKeys(true); err != nil {
+		return nil, fmt.Errorf("failed to remove expired idempotency keys: %w", err)
+	}
+
+	if cfg.Store.CallEventsMax > 0 {
+		if err := s.loadCallEvents(); err != nil {
+			return nil, fmt.Errorf("failed to load call events: %w", err)
+		}
+		if err := s.removeExpiredCallEvents(time.Now()); err != nil {
+			return nil, fmt.Errorf("failed to remove expired call events: %w", err)
+		}
+	}
+
+	if cfg.API.GRPC.Enable {
+		s.rpcServer, err = rpc.NewServer(cfg.API.GRPC, s.log, &grpcServer{s: s}, rpcOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create rpc server: %w", err)
+		}
+	}
+
+	if cfg.Webhooks.IsEnabled() {
+		s.webhooks, err = webhook.NewDispatcher(cfg.Webhooks, s.log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create webhook dispatcher: %w", err)
+		}
+		s.log.Info("initiated webhook dispatcher", mlog.Int("numURLs", len(cfg.Webhooks.URLs)))
+	}
+
+	if cfg.Autoscaling.IsEnabled() {
+		s.autoscaling, err = autoscaling.NewPublisher(cfg.Autoscaling, s.log, s.getAutoscalingSignals)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create autoscaling publisher: %w", err)
+		}
+		s.log.Info("initiated autoscaling publisher", mlog.String("backend", cfg.Autoscaling.Backend))
+	}
+
+	if cfg.EventBus.IsEnabled() {
+		s.eventBus, err = eventbus.NewPublisher(cfg.EventBus, s.log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create event bus publisher: %w", err)
+		}
+		s.log.Info("initiated event bus publisher", mlog.String("backend", cfg.EventBus.Backend))
+	}
 
 	s.apiServer.RegisterHandleFunc("/version", s.getVersion)
+	s.apiServer.RegisterHandleFunc("/readyz", s.handleReadyz)
+	s.apiServer.RegisterHandleFunc(specPath, s.getSpec)
+	s.apiServer.RegisterHandleFunc(signalingSchemaPath, s.getSignalingSchema)
 	s.apiServer.RegisterHandleFunc("/login", s.loginClient)
 	s.apiServer.RegisterHandleFunc("/register", s.registerClient)
+	s.apiServer.RegisterHandleFunc("/bootstrap", s.bootstrapClient)
 	s.apiServer.RegisterHandleFunc("/unregister", s.unregisterClient)
-	s.apiServer.RegisterHandler("/ws", s.wsServer)
+	s.apiServer.RegisterHandleFunc("/join_token", s.getJoinToken)
+	s.apiServer.RegisterHandleFunc("/turn_credentials", s.getTURNCredentials)
+	s.apiServer.RegisterHandler("/ws", s.wsServer, api.WithUpgrade())
+	adminServer.RegisterHandleFunc("/admin/rtc/sockets", s.handleUDPSockets)
+	adminServer.RegisterHandleFunc("/admin/rtc/capacity", s.handleCapacity)
+	adminServer.RegisterHandleFunc("/admin/store/export", s.handleStoreExport, api.WithLongRequests())
+	adminServer.RegisterHandleFunc("/admin/store/import", s.handleStoreImport, api.WithLongRequests(), api.WithMaxBodySize(0))
+	adminServer.RegisterHandleFunc("/admin/rtc/params", s.handleRuntimeParams)
+	adminServer.RegisterHandleFunc("/admin/features", s.handleFeatures)
+	adminServer.RegisterHandleFunc("/admin/rtc/capture", s.handleCapture)
+	adminServer.RegisterHandleFunc("/admin/signaling_trace", s.handleSignalingTrace)
+	adminServer.RegisterHandleFunc("/admin/rtc/recording", s.handleRecording)
+	adminServer.RegisterHandleFunc("/admin/rtc/hls", s.handleHLSStream)
+	adminServer.RegisterHandleFunc("/admin/rtc/announcements", s.handleAnnouncement)
+	adminServer.RegisterHandleFunc("/admin/rtc/key_export", s.handleKeyExport)
+	adminServer.RegisterHandleFunc("/admin/rtc/test_call", s.handleTestCall)
+	adminServer.RegisterHandleFunc("/admin/rtc/dtls_certificate", s.handleDTLSCertificate)
+	adminServer.RegisterHandleFunc("/admin/bots", s.handleBots)
+	adminServer.RegisterHandleFunc("/admin/mirrors", s.handleMirrors)
+	adminServer.RegisterHandleFunc("/admin/usage", s.handleUsage)
+	adminServer.RegisterHandleFunc("/admin/slo", s.handleSLO)
+	adminServer.RegisterHandleFunc("/admin/maintenance", s.handleMaintenance)
+	adminServer.RegisterHandleFunc("/admin/diagnostics", s.handleDiagnostics, api.WithLongRequests())
+	adminServer.RegisterHandleFunc("/admin/events", s.handleAdminEvents, api.WithUpgrade())
+	adminServer.RegisterHandleFunc(callEventsPathPrefix, s.handleCalls)
+	if cfg.RTC.HLS.Enable {
+		s.apiServer.RegisterHandleFunc(hlsPathPrefix, s.handleHLS, api.WithLongRequests())
+	}
 
-	s.apiServer.RegisterHandler("/metrics", s.metrics.Handler())
-	s.apiServer.RegisterHandler("/debug/pprof/heap", pprof.Handler("heap"))
-	s.apiServer.RegisterHandler("/debug/pprof/goroutine", pprof.Handler("goroutine"))
-	s.apiServer.RegisterHandler("/debug/pprof/mutex", pprof.Handler("mutex"))
-	s.apiServer.RegisterHandleFunc("/debug/pprof/profile", pprof.Profile)
-	s.apiServer.RegisterHandleFunc("/debug/pprof/trace", pprof.Trace)
+	if h := s.metrics.Handler(); h != nil {
+		metricsServer.RegisterHandler("/metrics", h)
+	}
+	adminServer.RegisterHandler("/debug/pprof/heap", pprof.Handler("heap"))
+	adminServer.RegisterHandler("/debug/pprof/goroutine", pprof.Handler("goroutine"))
+	adminServer.RegisterHandler("/debug/pprof/mutex", pprof.Handler("mutex"))
+	adminServer.RegisterHandleFunc("/debug/pprof/profile", pprof.Profile, api.WithLongRequests())
+	adminServer.RegisterHandleFunc("/debug/pprof/trace", pprof.Trace, api.WithLongRequests())
+
+	s.addComponents(openFilesLimit)
 
 	return s, nil
 }
 
+// Start starts the components of the service in the order of their
+// dependencies.
 func (s *Service) Start() error {
-	if err := s.apiServer.Start(); err != nil {
-		return fmt.Errorf("failed to start api server: %w", err)
-	}
-
-	if err := s.rtcServer.Start(); err != nil {
-		return fmt.Errorf("failed to start rtc server: %w", err)
-	}
-
-	go func() {
-		for msg := range s.wsServer.ReceiveCh() {
-			switch msg.Type {
-			case ws.OpenMessage:
-				s.log.Debug("connect", mlog.String("connID", msg.ConnID), mlog.String("clientID", msg.ClientID))
-				s.metrics.IncWSConnections(msg.ClientID)
-
-				data, err := NewPackedClientMessage(ClientMessageHello, map[string]string{
-					"clientID": msg.ClientID,
-					"connID":   msg.ConnID,
-				})
-				if err != nil {
-					s.log.Error("failed to pack hello message", mlog.Err(err))
-					continue
-				}
-
-				if err := s.sendClientMessage(msg.ConnID, msg.ClientID, data); err != nil {
-					s.log.Error("failed to send hello message", mlog.Err(err))
-					continue
-				}
-			case ws.CloseMessage:
-				s.log.Debug("disconnect", mlog.String("connID", msg.ConnID), mlog.String("clientID", msg.ClientID))
-				s.metrics.DecWSConnections(msg.ClientID)
-			case ws.TextMessage:
-				s.log.Warn("unexpected text message", mlog.String("connID", msg.ConnID), mlog.String("clientID", msg.ClientID))
-			case ws.BinaryMessage:
-				if err := s.handleClientMsg(msg); err != nil {
-					s.log.Error("failed to handle message",
-						mlog.Err(err),
-						mlog.String("connID", msg.ConnID),
-						mlog.String("clientID", msg.ClientID))
-					continue
-				}
-			default:
-				s.log.Warn("unexpected ws message", mlog.String("connID", msg.ConnID), mlog.String("clientID", msg.ClientID))
-			}
-		}
-	}()
+	return s.lifecycle.start()
+}
 
-	go func() {
-		for msg := range s.rtcServer.ReceiveCh() {
-			if err := s.handleRTCMsg(msg); err != nil {
+// handleWSMessages handles the messages received on the signaling
+// connections until the ws server is closed.
+func (s *Service) handleWSMessages() {
+	for msg := range s.wsServer.ReceiveCh() {
+		switch msg.Type {
+		case ws.OpenMessage:
+			if err := s.handleConnOpen(msg.ConnID, msg.ClientID); err != nil {
+				s.log.Error("failed to handle connection", mlog.Err(err), mlog.String("connID", msg.ConnID))
+				continue
+			}
+		case ws.CloseMessage:
+			s.handleConnClose(msg.ConnID, msg.ClientID)
+		case ws.TextMessage:
+			s.log.Warn("unexpected text message", mlog.String("connID", msg.ConnID), mlog.String("clientID", msg.ClientID))
+		case ws.BinaryMessage:
+			if err := s.handleClientMsg(msg); err != nil {
 				s.log.Error("failed to handle message",
 					mlog.Err(err),
-					mlog.String("groupID", msg.GroupID),
-					mlog.String("sessionID", msg.SessionID))
+					mlog.String("connID", msg.ConnID),
+					mlog.String("clientID", msg.ClientID))
+				ev := newAdminEvent(ErrorEvent)
+				ev.ClientID = msg.ClientID
+				ev.Error = err.Error()
+				s.publishAdminEvent(ev)
 				continue
 			}
+		default:
+			s.log.Warn("unexpected ws message", mlog.String("connID", msg.ConnID), mlog.String("clientID", msg.ClientID))
 		}
-	}()
-
-	return nil
+	}
 }
 
-func (s *Service) Stop() error {
-	s.log.Info("rtcd: shutting down")
-
-	if err := s.rtcServer.Stop(); err != nil {
-		return fmt.Errorf("failed to stop rtc server: %w", err)
+// handleRTCMessages relays the messages of the rtc sessions until the rtc
+// server is stopped.
+func (s *Service) handleRTCMessages() {
+	for msg := range s.rtcServer.ReceiveCh() {
+		if err := s.handleRTCMsg(msg); err != nil {
+			s.log.Error("failed to handle message",
+				mlog.Err(err),
+				mlog.String("groupID", msg.GroupID),
+				mlog.String("sessionID", msg.SessionID))
+			ev := newAdminEvent(ErrorEvent)
+			ev.GroupID = msg.GroupID
+			ev.UserID = msg.UserID
+			ev.SessionID = msg.SessionID
+			ev.Error = err.Error()
+			s.publishAdminEvent(ev)
+			continue
+		}
 	}
+}
 
-	if err := s.apiServer.Stop(); err != nil {
-		return fmt.Errorf("failed to stop api server: %w", err)
+// handleRTCEvents dispatches the events of the rtc server to the clients,
+// the admin consumers, the event bus and the webhooks until it is stopped.
+func (s *Service) handleRTCEvents() {
+	for ev := range s.rtcServer.EventsCh() {
+		if err := s.recordCallEvent(ev); err != nil {
+			s.log.Error("failed to record call event", mlog.Err(err), mlog.String("type", string(ev.Type)))
+		}
+		s.sendEventToClients(ev)
+		s.publishAdminEvent(AdminEvent{Event: ev})
+		if s.autoscaling != nil && isAutoscalingEvent(ev) {
+			s.autoscaling.Notify()
+		}
+		if s.eventBus != nil {
+			if err := s.eventBus.Send(ev); err != nil {
+				s.log.Error("failed to publish event", mlog.Err(err), mlog.String("type", string(ev.Type)))
+			}
+		}
+		if s.webhooks == nil {
+			continue
+		}
+		if err := s.webhooks.Send(ev); err != nil {
+			s.log.Error("failed to send webhook event", mlog.Err(err), mlog.String("type", string(ev.Type)))
+		}
 	}
+}
 
-	s.wsServer.Close()
+// AddSDPHook registers a hook to inspect or modify the session descriptions
+// of the RTC sessions. It should be called before Start.
+func (s *Service) AddSDPHook(hook rtc.SDPHook) {
+	s.rtcServer.AddSDPHook(hook)
+}
 
-	if err := s.store.Close(); err != nil {
-		return fmt.Errorf("failed to close store: %w", err)
+// Stop stops the components of the service in the reverse order of their
+// dependencies, going on past the ones failing or timing out and returning
+// their errors aggregated.
+func (s *Service) Stop() error {
+	s.log.Info("rtcd: shutting down")
+	return s.lifecycle.stop()
+}
+
+// handleConnOpen greets a newly established signaling connection.
+func (s *Service) handleConnOpen(connID, clientID string) error {
+	s.log.Debug("connect", mlog.String("connID", connID), mlog.String("clientID", clientID))
+	s.metrics.IncWSConnections(clientID)
+
+	helloData := newHelloData(ProtocolVersion, serverCapabilities)
+	helloData.ClientID = clientID
+	helloData.ConnID = connID
+	data, err := NewPackedClientMessage(ClientMessageHello, helloData.Map())
+	if err != nil {
+		return fmt.Errorf("failed to pack hello message: %w", err)
 	}
 
-	if err := s.log.Shutdown(); err != nil {
-		return fmt.Errorf("failed to shutdown logger: %w", err)
+	if err := s.sendClientMessage(connID, clientID, data); err != nil {
+		return fmt.Errorf("failed to send hello message: %w", err)
 	}
 
 	return nil
 }
 
+// handleConnClose clears the state of a signaling connection that went away.
+func (s *Service) handleConnClose(connID, clientID string) {
+	s.log.Debug("disconnect", mlog.String("connID", connID), mlog.String("clientID", clientID))
+	s.metrics.DecWSConnections(clientID)
+	s.mut.Lock()
+	delete(s.connProtocols, connID)
+	delete(s.connGroups, connID)
+	s.mut.Unlock()
+
+	s.scheduleStaleSessionsCleanup(connID, clientID)
+}
+
 func (s *Service) handleRTCMsg(msg rtc.Message) error {
-	var cm ClientMessage
 	switch msg.Type {
-	case rtc.SDPMessage, rtc.ICEMessage:
-		cm.Type = ClientMessageRTC
+	case rtc.SDPMessage, rtc.ICEMessage, rtc.CaptionMessage, rtc.MaintenanceMessage:
 	default:
-		return fmt.Errorf("unexpected rtc message type: %s", cm.Type)
+		return fmt.Errorf("unexpected rtc message type: %d", msg.Type)
+	}
+
+	if p := s.getLocalPeer(msg.SessionID); p != nil {
+		if msg.Type == rtc.CaptionMessage || msg.Type == rtc.MaintenanceMessage {
+			return nil
+		}
+		return p.push(msg)
 	}
 
 	s.mut.RLock()
@@ -213,7 +680,30 @@ func (s *Service) handleRTCMsg(msg rtc.Message) error {
 		return fmt.Errorf("unexpected empty connID")
 	}
 
-	cm.Data = msg
+	if msg.Type == rtc.CaptionMessage && !s.getConnProtocol(connID).hasCapability(CapabilityCaptions) {
+		return nil
+	}
+	if msg.Type == rtc.MaintenanceMessage && !s.getConnProtocol(connID).hasCapability(CapabilityMaintenance) {
+		return nil
+	}
+
+	s.mut.RLock()
+	buf := s.replayBuffers[msg.SessionID]
+	s.mut.RUnlock()
+	if buf != nil {
+		msg = buf.push(msg)
+	}
+
+	s.traceClientMsg(SignalingTraceDirectionOut, connID, msg.GroupID, ClientMessage{Type: ClientMessageRTC, Data: msg})
+
+	return s.sendRTCMsg(connID, msg)
+}
+
+func (s *Service) sendRTCMsg(connID string, msg rtc.Message) error {
+	cm := ClientMessage{
+		Type: ClientMessageRTC,
+		Data: msg,
+	}
 
 	data, err := cm.Pack()
 	if err != nil {
@@ -227,7 +717,7 @@ func (s *Service) handleRTCMsg(msg rtc.Message) error {
 		Data:     data,
 	}
 
-	if err := s.wsServer.Send(wsMsg); err != nil {
+	if err := s.sendMessage(wsMsg); err != nil {
 		return err
 	}
 
@@ -236,45 +726,94 @@ func (s *Service) handleRTCMsg(msg rtc.Message) error {
 	return nil
 }
 
-func (s *Service) handleClientMsg(msg ws.Message) error {
+func (s *Service) handleClientMsg(msg ws.Message) (err error) {
 	var cm ClientMessage
+	defer func() {
+		// The group_auth reply already carries the outcome.
+		if err != nil && cm.Type != ClientMessageGroupAuth {
+			s.sendClientError(msg.ConnID, msg.ClientID, cm, err)
+		}
+	}()
 	if err := cm.Unpack(msg.Data); err != nil {
-		return fmt.Errorf("failed to unpack data: %w", err)
+		return withErrorCode(ErrorCodeBadRequest, fmt.Errorf("failed to unpack data: %w", err))
 	}
 
 	s.metrics.IncWSMessages(msg.ClientID, cm.Type, "in")
+	if cm.Type != ClientMessageJoin {
+		s.traceClientMsg(SignalingTraceDirectionIn, msg.ConnID, msg.ClientID, cm)
+	}
 
 	var rtcMsg rtc.Message
 	switch cm.Type {
 	case ClientMessageJoin:
 		data, ok := cm.Data.(map[string]string)
 		if !ok {
-			return fmt.Errorf("unexpected data type: %T", cm.Data)
+			return newBadMessageError("unexpected data type: %T", cm.Data)
+		}
+		join, err := signaling.ParseJoinData(data)
+		if err != nil {
+			return newBadMessageError("%s in client message", err)
 		}
-		callID := data["callID"]
-		if callID == "" {
-			return fmt.Errorf("missing callID in client message")
+		callID, userID, sessionID := join.CallID, join.UserID, join.SessionID
+		groupID, err := s.resolveGroupID(msg.ConnID, msg.ClientID, join.GroupID)
+		if err != nil {
+			return err
 		}
-		userID := data["userID"]
-		if userID == "" {
-			return fmt.Errorf("missing userID in client message")
+		s.traceSessionJoin(msg.ConnID, groupID, callID, sessionID, cm)
+
+		// Observers are authorized by the token issued through the admin
+		// API, whether join tokens are required or not.
+		observer := join.Observer
+		if observer {
+			if err := s.auth.ValidateObserverToken(join.Token, groupID, callID, sessionID); err != nil {
+				s.auditObserver("observerJoin", "fail", rtc.SessionConfig{GroupID: groupID, CallID: callID, UserID: userID, SessionID: sessionID},
+					mlog.String("error", err.Error()))
+				return withErrorCode(ErrorCodeAuthFailed, fmt.Errorf("failed to authorize observer: %w", err))
+			}
+		} else if s.cfg.API.Security.JoinTokens.Enable {
+			if err := s.auth.ValidateJoinToken(join.Token, groupID, callID, sessionID); err != nil {
+				return withErrorCode(ErrorCodeAuthFailed, fmt.Errorf("failed to authorize session: %w", err))
+			}
 		}
-		sessionID := data["sessionID"]
-		if sessionID == "" {
-			return fmt.Errorf("missing sessionID in client message")
+
+		// The plugin can pass its own trace ID so that the session can be
+		// looked up with the same identifier on both sides.
+		traceID := join.TraceID
+		if traceID == "" {
+			traceID = rtc.NewTraceID()
 		}
 
-		closeCb := func() error {
+		closeCb := func(reason string) error {
+			if observer {
+				s.auditObserver("observerLeave", "success", rtc.SessionConfig{GroupID: groupID, CallID: callID, UserID: userID, SessionID: sessionID, TraceID: traceID},
+					mlog.String("reason", reason))
+			}
+
 			s.mut.Lock()
 			defer s.mut.Unlock()
 			delete(s.connMap, sessionID)
+			delete(s.sessionScopes, sessionID)
+			delete(s.replayBuffers, sessionID)
 
-			data, err := NewPackedClientMessage(ClientMessageClose, map[string]string{
-				"sessionID": sessionID,
-			})
+			// The connection the session originated from is gone, there's
+			// no one left to notify.
+			if reason == closeReasonSignalingLost {
+				return nil
+			}
+
+			closeData := signaling.CloseData{
+				SessionID: sessionID,
+				TraceID:   traceID,
+				Reason:    reason,
+				ErrorCode: string(errorCodeForCloseReason(reason)),
+			}.Map()
+			data, err := NewPackedClientMessage(ClientMessageClose, closeData)
 			if err != nil {
 				return fmt.Errorf("failed to pack close message: %w", err)
 			}
+			// The session ID is set, sparing the group resolution which
+			// would need s.mut.
+			s.traceClientMsg(SignalingTraceDirectionOut, msg.ConnID, msg.ClientID, ClientMessage{Type: ClientMessageClose, Data: closeData})
 
 			if err := s.sendClientMessage(msg.ConnID, msg.ClientID, data); err != nil {
 				return fmt.Errorf("failed to send close message: %w", err)
@@ -283,62 +822,356 @@ func (s *Service) handleClientMsg(msg ws.Message) error {
 			return nil
 		}
 
+		// Opus is the only audio codec the SFU accepts.
+		if !s.getConnProtocol(msg.ConnID).hasCapability(CapabilityCodecOpus) {
+			if cbErr := closeCb(closeReasonCodecUnsupported); cbErr != nil {
+				s.log.Error("failed to reject session", mlog.Err(cbErr), mlog.String("sessionID", sessionID), mlog.String("traceID", traceID))
+			}
+			return withErrorCode(ErrorCodeCodecUnsupported, errors.New("client doesn't support the opus codec"))
+		}
+
 		cfg := rtc.SessionConfig{
-			GroupID:   msg.ClientID,
+			GroupID:   groupID,
 			CallID:    callID,
 			UserID:    userID,
 			SessionID: sessionID,
+			Hidden:    join.Hidden || observer,
+			Observer:  observer,
+			AudioOnly: join.AudioOnly,
+			Locale:    join.Locale,
+			TraceID:   traceID,
+			ICEPolicy: rtc.ICEPolicy{
+				ForceRelay:  join.ICEForceRelay,
+				RelayOnly:   join.ICERelayOnly,
+				DisableHost: join.ICEDisableHost,
+				TURNRegion:  join.ICETURNRegion,
+			},
 		}
 		s.log.Debug("join message", mlog.Any("sessionCfg", cfg))
-		if err := s.rtcServer.InitSession(cfg, closeCb); err != nil {
-			return fmt.Errorf("failed to initialize rtc session: %w", err)
+		if joined, err := s.joinP2PCall(msg.ConnID, msg.ClientID, cfg, join.P2P, closeCb); err != nil || joined {
+			return err
 		}
 
-		s.mut.Lock()
-		s.connMap[sessionID] = msg.ConnID
-		s.mut.Unlock()
+		if err := s.initRTCSession(msg.ConnID, cfg, closeCb); err != nil {
+			if observer {
+				s.auditObserver("observerJoin", "fail", cfg, mlog.String("error", err.Error()))
+			}
+			return err
+		}
+		if observer {
+			s.auditObserver("observerJoin", "success", cfg)
+		}
 
 		return nil
 	case ClientMessageReconnect:
 		data, ok := cm.Data.(map[string]string)
 		if !ok {
-			return fmt.Errorf("unexpected data type: %T", cm.Data)
+			return newBadMessageError("unexpected data type: %T", cm.Data)
+		}
+		reconnect, err := signaling.ParseReconnectData(data)
+		if err != nil {
+			return newBadMessageError("%s in client message", err)
 		}
-		sessionID := data["sessionID"]
-		if sessionID == "" {
-			return fmt.Errorf("missing sessionID in client message")
+		sessionID := reconnect.SessionID
+
+		// The session is taken over along with its buffered messages, which
+		// only the connections acting on behalf of its group can do.
+		scope, err := s.checkSessionGroup(msg.ConnID, msg.ClientID, sessionID)
+		if err != nil {
+			return err
+		}
+		// As when joining, the session needs to be authorized by a join
+		// token. Observers were authorized by the admin API already.
+		if s.cfg.API.Security.JoinTokens.Enable && !scope.observer {
+			if err := s.auth.ValidateJoinToken(reconnect.Token, scope.groupID, scope.callID, sessionID); err != nil {
+				return withErrorCode(ErrorCodeAuthFailed, fmt.Errorf("failed to authorize session: %w", err))
+			}
 		}
 
 		s.log.Debug("reconnect message, updating connMap", mlog.String("sessionID", sessionID))
+		replay := s.getConnProtocol(msg.ConnID).hasCapability(CapabilityReplay)
 		s.mut.Lock()
+		_, known := s.connMap[sessionID]
 		s.connMap[sessionID] = msg.ConnID
+		// Messages are only sequenced and buffered for the sessions bound to
+		// a connection supporting replay.
+		buf := s.replayBuffers[sessionID]
+		if !replay {
+			delete(s.replayBuffers, sessionID)
+			buf = nil
+		} else if buf == nil && known {
+			s.replayBuffers[sessionID] = newReplayBuffer()
+		}
 		s.mut.Unlock()
 
+		// Clients supporting replay send the sequence number of the last
+		// message they received so that anything lost in between can be
+		// retransmitted.
+		if reconnect.LastSeq == nil || buf == nil {
+			return nil
+		}
+		for _, rtcMsg := range buf.since(*reconnect.LastSeq) {
+			if err := s.sendRTCMsg(msg.ConnID, rtcMsg); err != nil {
+				return fmt.Errorf("failed to replay message: %w", err)
+			}
+		}
+
+		return nil
+	case ClientMessageAck:
+		data, ok := cm.Data.(map[string]string)
+		if !ok {
+			return newBadMessageError("unexpected data type: %T", cm.Data)
+		}
+		ack, err := signaling.ParseAckData(data)
+		if err != nil {
+			return newBadMessageError("%s in client message", err)
+		}
+
+		s.mut.RLock()
+		ownerConnID := s.connMap[ack.SessionID]
+		buf := s.replayBuffers[ack.SessionID]
+		s.mut.RUnlock()
+		if ownerConnID != msg.ConnID {
+			return withErrorCode(ErrorCodeForbidden, fmt.Errorf("session %q doesn't belong to the connection", ack.SessionID))
+		}
+		if buf != nil {
+			buf.ack(ack.Seq)
+		}
+
 		return nil
 	case ClientMessageLeave:
 		data, ok := cm.Data.(map[string]string)
 		if !ok {
-			return fmt.Errorf("unexpected data type: %T", cm.Data)
+			return newBadMessageError("unexpected data type: %T", cm.Data)
 		}
-		sessionID := data["sessionID"]
-		if sessionID == "" {
-			return fmt.Errorf("missing sessionID in client message")
+		leave, err := signaling.ParseLeaveData(data)
+		if err != nil {
+			return newBadMessageError("%s in client message", err)
 		}
+		sessionID := leave.SessionID
 
-		s.log.Debug("leave message", mlog.String("sessionID", sessionID))
-		if err := s.rtcServer.CloseSession(sessionID); err != nil {
+		// The reason lets clients closing sessions on behalf of others (e.g.
+		// a moderator removing a participant) tell it apart from leaving.
+		reason := leave.Reason
+		switch reason {
+		case "":
+			reason = rtc.CloseReasonLeft
+		case rtc.CloseReasonLeft, rtc.CloseReasonKicked:
+		default:
+			return newBadMessageError("invalid reason %q in client message", reason)
+		}
+
+		s.log.Debug("leave message", mlog.String("sessionID", sessionID), mlog.String("reason", reason))
+		if s.leaveP2PCall(sessionID, reason) {
+			return nil
+		}
+		if err := s.rtcServer.CloseSessionWithReason(sessionID, reason); err != nil {
 			return fmt.Errorf("failed to close session: %w", err)
 		}
 		return nil
+	case ClientMessageHello:
+		data, ok := cm.Data.(map[string]string)
+		if !ok {
+			return newBadMessageError("unexpected data type: %T", cm.Data)
+		}
+		hello, err := signaling.ParseHelloData(data)
+		if err != nil {
+			return newBadMessageError("%s in client message", err)
+		}
+		clientVersion, err := parseProtocolVersion(hello.ProtocolVersion)
+		if err != nil {
+			return err
+		}
+
+		version, caps := negotiateProtocol(ProtocolVersion, serverCapabilities, clientVersion, parseCapabilities(hello.Capabilities))
+		s.log.Debug("protocol negotiated",
+			mlog.String("connID", msg.ConnID),
+			mlog.Int("version", version),
+			mlog.String("capabilities", formatCapabilities(caps)))
+
+		info := newProtocolInfo(version, caps)
+		info.clientID = msg.ClientID
+		s.mut.Lock()
+		s.connProtocols[msg.ConnID] = info
+		s.mut.Unlock()
+
+		s.sendMaintenanceNoticeToConn(msg.ConnID, msg.ClientID)
+
+		return nil
+	case ClientMessageGroupAuth:
+		data, ok := cm.Data.(map[string]string)
+		if !ok {
+			return newBadMessageError("unexpected data type: %T", cm.Data)
+		}
+		return s.handleGroupAuth(msg.ConnID, msg.ClientID, data)
+	case ClientMessageP2PSignal:
+		data, ok := cm.Data.(map[string]string)
+		if !ok {
+			return newBadMessageError("unexpected data type: %T", cm.Data)
+		}
+		return s.handleP2PSignal(msg.ConnID, data)
+	case ClientMessageResync:
+		data, ok := cm.Data.(map[string]string)
+		if !ok {
+			return newBadMessageError("unexpected data type: %T", cm.Data)
+		}
+		resync, err := signaling.ParseResyncData(data)
+		if err != nil {
+			return newBadMessageError("%s in client message", err)
+		}
+		callID := resync.CallID
+
+		groupID, err := s.resolveGroupID(msg.ConnID, msg.ClientID, resync.GroupID)
+		if err != nil {
+			return err
+		}
+
+		s.log.Debug("resync message", mlog.String("callID", callID))
+		state, err := s.rtcServer.GetCallState(groupID, callID)
+		if err != nil {
+			return fmt.Errorf("failed to get call state: %w", err)
+		}
+		js, err := json.Marshal(state)
+		if err != nil {
+			return fmt.Errorf("failed to marshal call state: %w", err)
+		}
+
+		replyData := signaling.CallStateData{
+			CallID: callID,
+			State:  string(js),
+		}
+		if groupID != msg.ClientID {
+			replyData.GroupID = groupID
+		}
+		reply, err := NewPackedClientMessage(ClientMessageCallState, replyData.Map())
+		if err != nil {
+			return fmt.Errorf("failed to pack call state message: %w", err)
+		}
+
+		return s.sendClientMessage(msg.ConnID, msg.ClientID, reply)
+	case ClientMessageTranscriptionStart, ClientMessageTranscriptionStop:
+		data, ok := cm.Data.(map[string]string)
+		if !ok {
+			return newBadMessageError("unexpected data type: %T", cm.Data)
+		}
+		transcription, err := signaling.ParseTranscriptionData(data)
+		if err != nil {
+			return newBadMessageError("%s in client message", err)
+		}
+		callID := transcription.CallID
+
+		groupID, err := s.resolveGroupID(msg.ConnID, msg.ClientID, transcription.GroupID)
+		if err != nil {
+			return err
+		}
+
+		s.log.Debug("transcription message", mlog.String("type", cm.Type), mlog.String("callID", callID))
+		if cm.Type == ClientMessageTranscriptionStart {
+			// Connecting to the transcription service happens in the
+			// background, not to hold the handling of the other messages.
+			err := s.rtcServer.StartTranscription(groupID, callID, func(err error) {
+				s.sendClientError(msg.ConnID, msg.ClientID, cm, fmt.Errorf("failed to start transcription: %w", err))
+			})
+			if err != nil {
+				return fmt.Errorf("failed to start transcription: %w", err)
+			}
+		} else if err := s.rtcServer.StopTranscription(groupID, callID); err != nil {
+			return fmt.Errorf("failed to stop transcription: %w", err)
+		}
+		return nil
+	case ClientMessageRecordingStart:
+		data, ok := cm.Data.(map[string]string)
+		if !ok {
+			return newBadMessageError("unexpected data type: %T", cm.Data)
+		}
+		recording, err := signaling.ParseRecordingStartData(data)
+		if err != nil {
+			return newBadMessageError("%s in client message", err)
+		}
+
+		groupID, err := s.resolveGroupID(msg.ConnID, msg.ClientID, recording.GroupID)
+		if err != nil {
+			return err
+		}
+
+		s.log.Debug("recording message", mlog.String("type", cm.Type), mlog.String("sessionID", recording.SessionID))
+		var duration time.Duration
+		if recording.DurationSeconds != nil {
+			duration = time.Duration(*recording.DurationSeconds) * time.Second
+		}
+		if _, err := s.rtcServer.StartSessionRecording(groupID, recording.SessionID, recording.Target, duration); err != nil {
+			return fmt.Errorf("failed to start recording: %w", err)
+		}
+		return nil
+	case ClientMessageRecordingStop:
+		data, ok := cm.Data.(map[string]string)
+		if !ok {
+			return newBadMessageError("unexpected data type: %T", cm.Data)
+		}
+		recording, err := signaling.ParseRecordingStopData(data)
+		if err != nil {
+			return newBadMessageError("%s in client message", err)
+		}
+
+		groupID, err := s.resolveGroupID(msg.ConnID, msg.ClientID, recording.GroupID)
+		if err != nil {
+			return err
+		}
+
+		s.log.Debug("recording message", mlog.String("type", cm.Type), mlog.String("sessionID", recording.SessionID))
+		if _, err := s.rtcServer.StopSessionRecording(groupID, recording.SessionID); err != nil {
+			return fmt.Errorf("failed to stop recording: %w", err)
+		}
+		return nil
+	case ClientMessageHLSStart:
+		data, ok := cm.Data.(map[string]string)
+		if !ok {
+			return newBadMessageError("unexpected data type: %T", cm.Data)
+		}
+		hls, err := signaling.ParseHLSStartData(data)
+		if err != nil {
+			return newBadMessageError("%s in client message", err)
+		}
+
+		groupID, err := s.resolveGroupID(msg.ConnID, msg.ClientID, hls.GroupID)
+		if err != nil {
+			return err
+		}
+
+		s.log.Debug("hls message", mlog.String("type", cm.Type))
+		if _, err := s.rtcServer.StartHLS(groupID, hls.SessionID); err != nil {
+			return fmt.Errorf("failed to start hls stream: %w", err)
+		}
+		return nil
+	case ClientMessageHLSStop:
+		data, ok := cm.Data.(map[string]string)
+		if !ok {
+			return newBadMessageError("unexpected data type: %T", cm.Data)
+		}
+		hls, err := signaling.ParseHLSStopData(data)
+		if err != nil {
+			return newBadMessageError("%s in client message", err)
+		}
+
+		groupID, err := s.resolveGroupID(msg.ConnID, msg.ClientID, hls.GroupID)
+		if err != nil {
+			return err
+		}
+
+		s.log.Debug("hls message", mlog.String("type", cm.Type))
+		if _, err := s.rtcServer.StopHLS(groupID, hls.CallID); err != nil {
+			return fmt.Errorf("failed to stop hls stream: %w", err)
+		}
+		return nil
 	case ClientMessageRTC:
 		var ok bool
 		rtcMsg, ok = cm.Data.(rtc.Message)
 		if !ok {
-			return fmt.Errorf("unexpected data type: %T", cm.Data)
+			return newBadMessageError("unexpected data type: %T", cm.Data)
 		}
 		s.log.Debug("rtc message", mlog.String("sessionID", rtcMsg.SessionID), mlog.Int("type", int(rtcMsg.Type)))
 	default:
-		return fmt.Errorf("unexpected client message type: %s", cm.Type)
+		return newBadMessageError("unexpected client message type: %s", cm.Type)
 	}
 
 	if err := s.rtcServer.Send(rtcMsg); err != nil {
@@ -348,6 +1181,157 @@ func (s *Service) handleClientMsg(msg ws.Message) error {
 	return nil
 }
 
+// initRTCSession initializes the rtc session of a user joining a call from
+// the given connection.
+func (s *Service) initRTCSession(connID string, cfg rtc.SessionConfig, closeCb func(reason string) error) error {
+	if err := s.rtcServer.InitSession(cfg, closeCb); err != nil {
+		if errors.Is(err, rtc.ErrMaxParticipantsReached) {
+			if cbErr := closeCb(rtc.CloseReasonMaxParticipants); cbErr != nil {
+				s.log.Error("failed to reject session", mlog.Err(cbErr), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
+			}
+		} else if errors.Is(err, rtc.ErrServerDraining) {
+			if cbErr := closeCb(rtc.CloseReasonShutdown); cbErr != nil {
+				s.log.Error("failed to reject session", mlog.Err(cbErr), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
+			}
+		} else if errors.Is(err, rtc.ErrServerBusy) {
+			if cbErr := closeCb(rtc.CloseReasonBusy); cbErr != nil {
+				s.log.Error("failed to reject session", mlog.Err(cbErr), mlog.String("sessionID", cfg.SessionID), mlog.String("traceID", cfg.TraceID))
+			}
+		}
+		return fmt.Errorf("failed to initialize rtc session: %w", err)
+	}
+
+	replay := s.getConnProtocol(connID).hasCapability(CapabilityReplay)
+	s.mut.Lock()
+	s.connMap[cfg.SessionID] = connID
+	s.sessionScopes[cfg.SessionID] = newSessionScope(cfg)
+	if replay {
+		s.replayBuffers[cfg.SessionID] = newReplayBuffer()
+	}
+	s.mut.Unlock()
+
+	s.rtcServer.SendMaintenanceNotice(cfg.SessionID)
+
+	return nil
+}
+
+// sessionScope is what a session joined: the group and call it belongs to,
+// and whether it joined as an observer.
+type sessionScope struct {
+	groupID  string
+	callID   string
+	observer bool
+}
+
+func newSessionScope(cfg rtc.SessionConfig) sessionScope {
+	return sessionScope{
+		groupID:  cfg.GroupID,
+		callID:   cfg.CallID,
+		observer: cfg.Observer,
+	}
+}
+
+// checkSessionGroup returns the scope of the given session, or an error if
+// it doesn't belong to any of the groups the connection acts on behalf of.
+func (s *Service) checkSessionGroup(connID, clientID, sessionID string) (sessionScope, error) {
+	s.mut.RLock()
+	scope, ok := s.sessionScopes[sessionID]
+	s.mut.RUnlock()
+	if !ok {
+		return sessionScope{}, withErrorCode(ErrorCodeNotFound, fmt.Errorf("session %q not found", sessionID))
+	}
+
+	if _, err := s.resolveGroupID(connID, clientID, scope.groupID); err != nil {
+		return sessionScope{}, withErrorCode(ErrorCodeForbidden, fmt.Errorf("session %q doesn't belong to the connection", sessionID))
+	}
+
+	return scope, nil
+}
+
+// getConnProtocol returns the protocol negotiated on the given connection.
+// Connections that didn't take part in the negotiation are assumed to speak
+// the legacy protocol.
+func (s *Service) getConnProtocol(connID string) protocolInfo {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	if info, ok := s.connProtocols[connID]; ok {
+		return info
+	}
+	return legacyProtocolInfo()
+}
+
+// sendEventToClients delivers the given event to the connections acting on
+// behalf of the group owning the call that negotiated the events capability.
+func (s *Service) sendEventToClients(ev rtc.Event) {
+	var connIDs []string
+	s.mut.RLock()
+	for connID, info := range s.connProtocols {
+		if s.hasConnGroup(connID, ev.GroupID, info) && info.hasCapability(CapabilityEvents) {
+			connIDs = append(connIDs, connID)
+		}
+	}
+	s.mut.RUnlock()
+
+	if len(connIDs) == 0 {
+		return
+	}
+
+	evData := signaling.EventData{
+		Type:      string(ev.Type),
+		Timestamp: ev.Timestamp,
+		GroupID:   ev.GroupID,
+		CallID:    ev.CallID,
+		UserID:    ev.UserID,
+		SessionID: ev.SessionID,
+		TrackID:   ev.TrackID,
+		TraceID:   ev.TraceID,
+	}
+	if ev.Quality != nil {
+		js, err := json.Marshal(ev.Quality)
+		if err != nil {
+			s.log.Error("failed to marshal stream quality", mlog.Err(err))
+			return
+		}
+		evData.Quality = string(js)
+	}
+	if ev.Recording != nil {
+		js, err := json.Marshal(ev.Recording)
+		if err != nil {
+			s.log.Error("failed to marshal recording info", mlog.Err(err))
+			return
+		}
+		evData.Recording = string(js)
+	}
+	if ev.HLS != nil {
+		js, err := json.Marshal(ev.HLS)
+		if err != nil {
+			s.log.Error("failed to marshal hls stream info", mlog.Err(err))
+			return
+		}
+		evData.HLS = string(js)
+	}
+	if ev.Migration != nil {
+		js, err := json.Marshal(ev.Migration)
+		if err != nil {
+			s.log.Error("failed to marshal session migration", mlog.Err(err))
+			return
+		}
+		evData.Migration = string(js)
+	}
+
+	data, err := NewPackedClientMessage(ClientMessageEvent, evData.Map())
+	if err != nil {
+		s.log.Error("failed to pack event message", mlog.Err(err))
+		return
+	}
+
+	for _, connID := range connIDs {
+		if err := s.sendClientMessage(connID, ev.GroupID, data); err != nil {
+			s.log.Error("failed to send event message", mlog.Err(err), mlog.String("connID", connID))
+		}
+	}
+}
+
 func (s *Service) sendClientMessage(connID, clientID string, data []byte) error {
 	wsMsg := ws.Message{
 		ConnID:   connID,
@@ -356,9 +1340,64 @@ func (s *Service) sendClientMessage(connID, clientID string, data []byte) error
 		Data:     data,
 	}
 
-	if err := s.wsServer.Send(wsMsg); err != nil {
+	if err := s.sendMessage(wsMsg); err != nil {
 		return fmt.Errorf("failed to send client message: %w", err)
 	}
 
 	return nil
 }
+
+// sendClientError reports the failure to handle a client message back to
+// the connection it came from, if it supports the errors capability.
+func (s *Service) sendClientError(connID, clientID string, cm ClientMessage, msgErr error) {
+	if !s.getConnProtocol(connID).hasCapability(CapabilityErrors) {
+		return
+	}
+
+	code := errorCode(msgErr)
+	if code == "" {
+		code = ErrorCodeInternal
+	}
+	errData := signaling.ErrorData{
+		MsgType:   cm.Type,
+		ErrorCode: string(code),
+		Error:     msgErr.Error(),
+	}
+	switch data := cm.Data.(type) {
+	case map[string]string:
+		errData.GroupID = data["groupID"]
+		errData.CallID = data["callID"]
+		errData.SessionID = data["sessionID"]
+	case rtc.Message:
+		errData.SessionID = data.SessionID
+	}
+
+	data, err := NewPackedClientMessage(ClientMessageError, errData.Map())
+	if err != nil {
+		s.log.Error("failed to pack error message", mlog.Err(err))
+		return
+	}
+	s.traceClientMsg(SignalingTraceDirectionOut, connID, clientID, ClientMessage{Type: ClientMessageError, Data: errData.Map()})
+	if err := s.sendClientMessage(connID, clientID, data); err != nil {
+		s.log.Error("failed to send error message", mlog.Err(err), mlog.String("connID", connID))
+	}
+}
+
+// getCallsStats converts the rtc calls stats for the metrics collector.
+func (s *Service) getCallsStats() []perf.CallStats {
+	rtcStats := s.rtcServer.GetCallsStats()
+	stats := make([]perf.CallStats, len(rtcStats))
+	for i, cs := range rtcStats {
+		stats[i] = perf.CallStats(cs)
+	}
+	return stats
+}
+
+func (s *Service) getRTPStats() []perf.RTPStats {
+	rtcStats := s.rtcServer.GetRTPStats()
+	stats := make([]perf.RTPStats, len(rtcStats))
+	for i, rs := range rtcStats {
+		stats[i] = perf.RTPStats(rs)
+	}
+	return stats
+}
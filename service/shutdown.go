@@ -0,0 +1,78 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"time"
+
+	"github.com/mattermost/rtcd/service/signaling"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// notifyShutdown tells the connected clients supporting the shutdown
+// capability that the server is shutting down, and how long their sessions
+// have left. It returns the number of connections notified.
+func (s *Service) notifyShutdown(timeout time.Duration) int {
+	type conn struct {
+		connID   string
+		clientID string
+	}
+	var conns []conn
+	s.mut.RLock()
+	for connID, info := range s.connProtocols {
+		if info.hasCapability(CapabilityShutdown) {
+			conns = append(conns, conn{connID: connID, clientID: info.clientID})
+		}
+	}
+	s.mut.RUnlock()
+
+	if len(conns) == 0 {
+		return 0
+	}
+
+	data, err := NewPackedClientMessage(ClientMessageShutdown, signaling.ShutdownData{
+		TimeoutSeconds: int(timeout.Seconds()),
+	}.Map())
+	if err != nil {
+		s.log.Error("failed to pack shutdown message", mlog.Err(err))
+		return 0
+	}
+
+	var notified int
+	for _, c := range conns {
+		if err := s.sendClientMessage(c.connID, c.clientID, data); err != nil {
+			s.log.Error("failed to send shutdown message", mlog.Err(err), mlog.String("connID", c.connID))
+			continue
+		}
+		notified++
+	}
+
+	return notified
+}
+
+// drain notifies the clients of the shutdown and gives their sessions the
+// configured grace period to end before they get force-closed.
+func (s *Service) drain() {
+	s.publishAdminEvent(newAdminEvent(DrainStartedEvent))
+
+	timeout := time.Duration(s.cfg.Process.ShutdownTimeoutSeconds) * time.Second
+	notified := s.notifyShutdown(timeout)
+	// Bots and mirrors are local sessions that would otherwise wait for the
+	// whole timeout.
+	s.stopBots(botStopReasonShutdown)
+	s.stopMirrors(mirrorStopReasonShutdown)
+	forced := s.rtcServer.Drain(timeout)
+
+	ev := newAdminEvent(DrainFinishedEvent)
+	ev.ForcedSessions = forced
+	s.publishAdminEvent(ev)
+
+	if forced > 0 {
+		s.log.Warn("rtcd: sessions had to be force-closed", mlog.Int("notifiedConns", notified),
+			mlog.Int("forceClosedSessions", forced))
+		return
+	}
+	s.log.Info("rtcd: sessions drained", mlog.Int("notifiedConns", notified))
+}
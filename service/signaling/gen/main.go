@@ -0,0 +1,34 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Command gen generates the Go types of the signaling messages from
+// schema.json. It's run through go generate from the signaling package.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/mattermost/rtcd/service/signaling/internal/codegen"
+)
+
+const (
+	schemaFile = "schema.json"
+	outFile    = "messages_gen.go"
+)
+
+func main() {
+	schema, err := os.ReadFile(schemaFile)
+	if err != nil {
+		log.Fatalf("failed to read schema: %s", err.Error())
+	}
+
+	src, err := codegen.Generate(schema, "signaling")
+	if err != nil {
+		log.Fatalf("failed to generate types: %s", err.Error())
+	}
+
+	if err := os.WriteFile(outFile, src, 0644); err != nil {
+		log.Fatalf("failed to write file: %s", err.Error())
+	}
+}
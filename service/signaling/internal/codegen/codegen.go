@@ -0,0 +1,517 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package codegen generates the Go types of the signaling messages from
+// their JSON Schema.
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+)
+
+const defRefPrefix = "#/$defs/"
+
+// Formats of the string fields mapped to Go types other than string.
+const (
+	formatBoolean = "boolean"
+	formatInteger = "integer"
+	formatInt64   = "int64"
+	formatUint64  = "uint64"
+	// formatJSON only documents the field, which is kept as a string.
+	formatJSON = "json"
+)
+
+// initialisms are the words kept upper case in Go names.
+var initialisms = map[string]bool{
+	"hls":  true,
+	"ice":  true,
+	"id":   true,
+	"p2p":  true,
+	"rtc":  true,
+	"turn": true,
+	"url":  true,
+}
+
+type document struct {
+	OneOf []struct {
+		Description string `json:"description"`
+		Properties  struct {
+			Type struct {
+				Const string `json:"const"`
+			} `json:"type"`
+			Data struct {
+				Ref string `json:"$ref"`
+			} `json:"data"`
+		} `json:"properties"`
+	} `json:"oneOf"`
+	Defs json.RawMessage `json:"$defs"`
+}
+
+type definition struct {
+	Type        string          `json:"type"`
+	Description string          `json:"description"`
+	GoType      string          `json:"x-go-type"`
+	Properties  json.RawMessage `json:"properties"`
+	Required    []string        `json:"required"`
+}
+
+type property struct {
+	Type        string   `json:"type"`
+	Format      string   `json:"format"`
+	Minimum     *float64 `json:"minimum"`
+	Description string   `json:"description"`
+}
+
+type message struct {
+	name        string
+	value       string
+	description string
+	data        *dataType
+}
+
+type dataType struct {
+	name        string
+	description string
+	// external is the Go type of the data if it isn't a map of strings, in
+	// which case no type is generated.
+	external string
+	fields   []field
+	required []string
+}
+
+type field struct {
+	key         string
+	name        string
+	format      string
+	minimum     *int64
+	required    bool
+	description string
+}
+
+// Generate returns the formatted Go source of the message types described
+// by the given schema, for the given package.
+func Generate(schema []byte, pkg string) ([]byte, error) {
+	msgs, types, err := parse(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeFile(&buf, pkg, msgs, types)
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format source: %w", err)
+	}
+	return src, nil
+}
+
+func parse(schema []byte) ([]message, []*dataType, error) {
+	var doc document
+	if err := json.Unmarshal(schema, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode schema: %w", err)
+	}
+
+	var types []*dataType
+	typesByKey := map[string]*dataType{}
+	err := decodeOrdered(doc.Defs, func(key string, raw json.RawMessage) error {
+		dt, err := parseDefinition(key, raw)
+		if err != nil {
+			return fmt.Errorf("invalid definition %q: %w", key, err)
+		}
+		types = append(types, dt)
+		typesByKey[key] = dt
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msgs := make([]message, 0, len(doc.OneOf))
+	values := map[string]bool{}
+	for _, m := range doc.OneOf {
+		value := m.Properties.Type.Const
+		if value == "" {
+			return nil, nil, fmt.Errorf("invalid message: missing type const")
+		}
+		if values[value] {
+			return nil, nil, fmt.Errorf("invalid message %q: duplicate type", value)
+		}
+		values[value] = true
+
+		ref := m.Properties.Data.Ref
+		dt := typesByKey[strings.TrimPrefix(ref, defRefPrefix)]
+		if !strings.HasPrefix(ref, defRefPrefix) || dt == nil {
+			return nil, nil, fmt.Errorf("invalid message %q: unknown data reference %q", value, ref)
+		}
+
+		msgs = append(msgs, message{
+			name:        "Type" + goName(value),
+			value:       value,
+			description: m.Description,
+			data:        dt,
+		})
+	}
+
+	return msgs, types, nil
+}
+
+func parseDefinition(key string, raw json.RawMessage) (*dataType, error) {
+	var def definition
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return nil, err
+	}
+	if def.Type != "object" {
+		return nil, fmt.Errorf("type should be object")
+	}
+
+	dt := &dataType{
+		name:        goName(key) + "Data",
+		description: def.Description,
+		external:    def.GoType,
+		required:    def.Required,
+	}
+	if dt.external != "" {
+		return dt, nil
+	}
+
+	required := map[string]bool{}
+	for _, name := range def.Required {
+		required[name] = true
+	}
+
+	err := decodeOrdered(def.Properties, func(key string, raw json.RawMessage) error {
+		var prop property
+		if err := json.Unmarshal(raw, &prop); err != nil {
+			return fmt.Errorf("invalid property %q: %w", key, err)
+		}
+		if prop.Type != "string" {
+			return fmt.Errorf("invalid property %q: type should be string", key)
+		}
+
+		f := field{
+			key:         key,
+			name:        goName(key),
+			format:      prop.Format,
+			required:    required[key],
+			description: prop.Description,
+		}
+		switch f.format {
+		case "", formatJSON:
+		case formatBoolean:
+			if f.required {
+				return fmt.Errorf("invalid property %q: boolean properties can't be required", key)
+			}
+		case formatInteger, formatInt64, formatUint64:
+		default:
+			return fmt.Errorf("invalid property %q: unsupported format %q", key, f.format)
+		}
+		if prop.Minimum != nil {
+			if f.format != formatInteger && f.format != formatInt64 {
+				return fmt.Errorf("invalid property %q: minimum is only supported by signed integers", key)
+			}
+			minimum := int64(*prop.Minimum)
+			f.minimum = &minimum
+		}
+		delete(required, key)
+		dt.fields = append(dt.fields, f)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(required) > 0 {
+		var missing []string
+		for name := range required {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return nil, fmt.Errorf("unknown required properties %s", strings.Join(missing, ", "))
+	}
+
+	return dt, nil
+}
+
+// decodeOrdered calls fn with the members of the given JSON object, in the
+// order they're defined in.
+func decodeOrdered(data json.RawMessage, fn func(key string, raw json.RawMessage) error) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return fmt.Errorf("failed to decode object: expected an object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("failed to decode object: %w", err)
+		}
+		key, _ := tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return fmt.Errorf("failed to decode object: %w", err)
+		}
+		if err := fn(key, raw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// goName converts a camelCase or snake_case name to an exported Go name,
+// keeping initialisms upper case (e.g. "iceTURNRegion" gives ICETURNRegion,
+// "p2p_peer" gives P2PPeer).
+func goName(s string) string {
+	var b strings.Builder
+	for _, word := range splitWords(s) {
+		if initialisms[strings.ToLower(word)] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]))
+		b.WriteString(word[1:])
+	}
+	return b.String()
+}
+
+func splitWords(s string) []string {
+	isUpper := func(c byte) bool { return c >= 'A' && c <= 'Z' }
+	isLower := func(c byte) bool { return c >= 'a' && c <= 'z' }
+
+	var words []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '_' {
+			if i > start {
+				words = append(words, s[start:i])
+			}
+			start = i + 1
+			continue
+		}
+		// A word starts at an upper case letter following a lower case
+		// one, or preceding one (e.g. the R of TURNRegion).
+		if i > start && isUpper(s[i]) && (isLower(s[i-1]) || (i+1 < len(s) && isLower(s[i+1]))) {
+			words = append(words, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+// goType returns the Go type of the field, optional numbers being pointers
+// so that their absence can be told apart from zero.
+func (f field) goType() string {
+	var typ string
+	switch f.format {
+	case formatBoolean:
+		return "bool"
+	case formatInteger:
+		typ = "int"
+	case formatInt64:
+		typ = "int64"
+	case formatUint64:
+		typ = "uint64"
+	default:
+		return "string"
+	}
+	if !f.required {
+		typ = "*" + typ
+	}
+	return typ
+}
+
+func (f field) isNumber() bool {
+	return f.format == formatInteger || f.format == formatInt64 || f.format == formatUint64
+}
+
+// formatExpr returns the expression formatting v, a number field, as a
+// string.
+func (f field) formatExpr(v string) string {
+	switch f.format {
+	case formatInteger:
+		return fmt.Sprintf("strconv.Itoa(%s)", v)
+	case formatInt64:
+		return fmt.Sprintf("strconv.FormatInt(%s, 10)", v)
+	default:
+		return fmt.Sprintf("strconv.FormatUint(%s, 10)", v)
+	}
+}
+
+// parseStmt returns the statement parsing v into n, a number field.
+func (f field) parseStmt(v string) string {
+	switch f.format {
+	case formatInteger:
+		return fmt.Sprintf("n, err := strconv.Atoi(%s)", v)
+	case formatInt64:
+		return fmt.Sprintf("n, err := strconv.ParseInt(%s, 10, 64)", v)
+	default:
+		return fmt.Sprintf("n, err := strconv.ParseUint(%s, 10, 64)", v)
+	}
+}
+
+func writeFile(w *bytes.Buffer, pkg string, msgs []message, types []*dataType) {
+	var hasRequired, hasNumbers bool
+	for _, dt := range types {
+		if dt.external != "" {
+			continue
+		}
+		hasRequired = hasRequired || len(dt.required) > 0
+		for _, f := range dt.fields {
+			hasNumbers = hasNumbers || f.isNumber()
+		}
+	}
+
+	fmt.Fprintf(w, "// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.\n")
+	fmt.Fprintf(w, "// See LICENSE.txt for license information.\n\n")
+	fmt.Fprintf(w, "// Code generated from schema.json by go generate. DO NOT EDIT.\n\n")
+	fmt.Fprintf(w, "package %s\n\n", pkg)
+	fmt.Fprintf(w, "import (\n")
+	if hasRequired {
+		fmt.Fprintf(w, "\"errors\"\n")
+	}
+	if hasNumbers {
+		fmt.Fprintf(w, "\"fmt\"\n\"strconv\"\n")
+	}
+	fmt.Fprintf(w, ")\n\n")
+
+	fmt.Fprintf(w, "// The types of the signaling messages.\nconst (\n")
+	for _, m := range msgs {
+		writeComment(w, fmt.Sprintf("%s is the type of the %s message. %s", m.name, m.value, m.description))
+		fmt.Fprintf(w, "%s = %q\n", m.name, m.value)
+	}
+	fmt.Fprintf(w, ")\n\n")
+
+	fmt.Fprintf(w, "// mapDataTypes are the types of the messages whose data is a map of\n// strings.\n")
+	fmt.Fprintf(w, "var mapDataTypes = map[string]bool{\n")
+	for _, m := range msgs {
+		if m.data.external == "" {
+			fmt.Fprintf(w, "%s: true,\n", m.name)
+		}
+	}
+	fmt.Fprintf(w, "}\n\n")
+
+	for _, dt := range types {
+		if dt.external != "" {
+			continue
+		}
+		writeType(w, dt, msgs)
+	}
+}
+
+func writeType(w *bytes.Buffer, dt *dataType, msgs []message) {
+	var used []string
+	for _, m := range msgs {
+		if m.data == dt {
+			used = append(used, m.value)
+		}
+	}
+	doc := fmt.Sprintf("%s is the data of the %s message.", dt.name, used[0])
+	if len(used) > 1 {
+		doc = fmt.Sprintf("%s is the data of the %s messages.", dt.name, strings.Join(used, " and "))
+	}
+	writeComment(w, strings.TrimSpace(doc+" "+dt.description))
+
+	fmt.Fprintf(w, "type %s struct {\n", dt.name)
+	for _, f := range dt.fields {
+		desc := f.description
+		if f.required {
+			desc += " Required."
+		}
+		writeComment(w, strings.TrimSpace(desc))
+		fmt.Fprintf(w, "%s %s\n", f.name, f.goType())
+	}
+	fmt.Fprintf(w, "}\n\n")
+
+	fmt.Fprintf(w, "// Map returns the data as sent on the wire.\n")
+	fmt.Fprintf(w, "func (d %s) Map() map[string]string {\n", dt.name)
+	fmt.Fprintf(w, "m := map[string]string{\n")
+	for _, f := range dt.fields {
+		if !f.required {
+			continue
+		}
+		if f.isNumber() {
+			fmt.Fprintf(w, "%q: %s,\n", f.key, f.formatExpr("d."+f.name))
+		} else {
+			fmt.Fprintf(w, "%q: d.%s,\n", f.key, f.name)
+		}
+	}
+	fmt.Fprintf(w, "}\n")
+	for _, f := range dt.fields {
+		if f.required {
+			continue
+		}
+		switch {
+		case f.format == formatBoolean:
+			fmt.Fprintf(w, "if d.%s {\nm[%q] = \"true\"\n}\n", f.name, f.key)
+		case f.isNumber():
+			fmt.Fprintf(w, "if d.%s != nil {\nm[%q] = %s\n}\n", f.name, f.key, f.formatExpr("*d."+f.name))
+		default:
+			fmt.Fprintf(w, "if d.%s != \"\" {\nm[%q] = d.%s\n}\n", f.name, f.key, f.name)
+		}
+	}
+	fmt.Fprintf(w, "return m\n}\n\n")
+
+	writeComment(w, fmt.Sprintf("Parse%s returns the data held by m, as received on the wire. "+
+		"It returns an error if a required field is missing or if a field is invalid.", dt.name))
+	fmt.Fprintf(w, "func Parse%s(m map[string]string) (%s, error) {\n", dt.name, dt.name)
+	fmt.Fprintf(w, "var d %s\n", dt.name)
+	for _, name := range dt.required {
+		fmt.Fprintf(w, "if m[%q] == \"\" {\nreturn d, errors.New(%q)\n}\n", name, "missing "+name)
+	}
+	for _, f := range dt.fields {
+		switch {
+		case f.format == formatBoolean:
+			fmt.Fprintf(w, "d.%s = m[%q] == \"true\"\n", f.name, f.key)
+		case f.isNumber():
+			if f.required {
+				fmt.Fprintf(w, "{\n")
+			} else {
+				fmt.Fprintf(w, "if m[%q] != \"\" {\n", f.key)
+			}
+			fmt.Fprintf(w, "%s\n", f.parseStmt(fmt.Sprintf("m[%q]", f.key)))
+			cond := "err != nil"
+			if f.minimum != nil {
+				cond += fmt.Sprintf(" || n < %d", *f.minimum)
+			}
+			fmt.Fprintf(w, "if %s {\nreturn d, fmt.Errorf(\"invalid %s value: %%q\", m[%q])\n}\n", cond, f.key, f.key)
+			if f.required {
+				fmt.Fprintf(w, "d.%s = n\n", f.name)
+			} else {
+				fmt.Fprintf(w, "d.%s = &n\n", f.name)
+			}
+			fmt.Fprintf(w, "}\n")
+		default:
+			fmt.Fprintf(w, "d.%s = m[%q]\n", f.name, f.key)
+		}
+	}
+	fmt.Fprintf(w, "return d, nil\n}\n\n")
+}
+
+// writeComment writes text as a comment, wrapped at 80 columns once
+// indented.
+func writeComment(w *bytes.Buffer, text string) {
+	if text == "" {
+		return
+	}
+	line := "//"
+	for _, word := range strings.Fields(text) {
+		if len(line)+1+len(word) > 76 && line != "//" {
+			fmt.Fprintf(w, "%s\n", line)
+			line = "//"
+		}
+		line += " " + word
+	}
+	fmt.Fprintf(w, "%s\n", line)
+}
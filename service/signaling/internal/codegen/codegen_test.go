@@ -0,0 +1,75 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package codegen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"callID":        "CallID",
+		"iceTURNRegion": "ICETURNRegion",
+		"audioOnly":     "AudioOnly",
+		"p2p":           "P2P",
+		"p2p_peer":      "P2PPeer",
+		"hls_start":     "HLSStart",
+		"call_state":    "CallState",
+		"id":            "ID",
+	} {
+		require.Equal(t, expected, goName(name), name)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	schema := func(defs string) []byte {
+		return []byte(`{
+  "oneOf": [{"description": "A message.", "properties": {"type": {"const": "some_msg"}, "data": {"$ref": "#/$defs/some_msg"}}}],
+  "$defs": {"some_msg": ` + defs + `}
+}`)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		src, err := Generate(schema(`{"type": "object", "properties": {
+  "sessionID": {"type": "string", "description": "The session."},
+  "count": {"type": "string", "format": "integer", "minimum": 1},
+  "enabled": {"type": "string", "format": "boolean"}
+}, "required": ["sessionID"]}`), "pkg")
+		require.NoError(t, err)
+		require.Contains(t, string(src), "TypeSomeMsg = \"some_msg\"")
+		require.Contains(t, string(src), "type SomeMsgData struct {\n\t// The session. Required.\n\tSessionID string\n\tCount     *int\n\tEnabled   bool\n}")
+		require.Contains(t, string(src), "if err != nil || n < 1 {")
+		require.True(t, strings.HasPrefix(string(src), "// Copyright"))
+	})
+
+	t.Run("external type", func(t *testing.T) {
+		src, err := Generate(schema(`{"type": "object", "x-go-type": "rtc.Message"}`), "pkg")
+		require.NoError(t, err)
+		require.NotContains(t, string(src), "SomeMsgData")
+		require.NotContains(t, string(src), "TypeSomeMsg: true")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for defs, expected := range map[string]string{
+			`{"type": "array"}`: `invalid definition "some_msg": type should be object`,
+			`{"type": "object", "properties": {"count": {"type": "integer"}}}`:                                      `invalid definition "some_msg": invalid property "count": type should be string`,
+			`{"type": "object", "properties": {"count": {"type": "string", "format": "float"}}}`:                    `invalid definition "some_msg": invalid property "count": unsupported format "float"`,
+			`{"type": "object", "properties": {"ok": {"type": "string", "format": "boolean"}}, "required": ["ok"]}`: `invalid definition "some_msg": invalid property "ok": boolean properties can't be required`,
+			`{"type": "object", "properties": {"seq": {"type": "string", "format": "uint64", "minimum": 1}}}`:       `invalid definition "some_msg": invalid property "seq": minimum is only supported by signed integers`,
+			`{"type": "object", "properties": {}, "required": ["sessionID"]}`:                                       `invalid definition "some_msg": unknown required properties sessionID`,
+		} {
+			_, err := Generate(schema(defs), "pkg")
+			require.EqualError(t, err, expected)
+		}
+
+		_, err := Generate([]byte(`{"oneOf": [{"properties": {"type": {"const": "msg"}, "data": {"$ref": "#/$defs/other"}}}], "$defs": {}}`), "pkg")
+		require.EqualError(t, err, `invalid message "msg": unknown data reference "#/$defs/other"`)
+
+		_, err = Generate([]byte(`{"oneOf": [{"properties": {"type": {}}}]}`), "pkg")
+		require.EqualError(t, err, "invalid message: missing type const")
+	})
+}
@@ -0,0 +1,1088 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Code generated from schema.json by go generate. DO NOT EDIT.
+
+package signaling
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// The types of the signaling messages.
+const (
+	// TypeJoin is the type of the join message. Sent by clients to join a call.
+	TypeJoin = "join"
+	// TypeLeave is the type of the leave message. Sent by clients to close one
+	// of their sessions.
+	TypeLeave = "leave"
+	// TypeReconnect is the type of the reconnect message. Sent by clients to
+	// attach a session to a new connection.
+	TypeReconnect = "reconnect"
+	// TypeAck is the type of the ack message. Sent by clients supporting the
+	// replay capability to acknowledge the rtc messages received.
+	TypeAck = "ack"
+	// TypeHello is the type of the hello message. Sent by the server as the
+	// connection is established, and by clients in reply, to negotiate the
+	// protocol version and capabilities.
+	TypeHello = "hello"
+	// TypeGroupAuth is the type of the group_auth message. Sent by clients to
+	// multiplex an additional group over their connection, and by the server in
+	// reply.
+	TypeGroupAuth = "group_auth"
+	// TypeRTC is the type of the rtc message. Carries the WebRTC signaling of a
+	// session.
+	TypeRTC = "rtc"
+	// TypeResync is the type of the resync message. Sent by clients to get the
+	// state of a call, replied to with a call_state message.
+	TypeResync = "resync"
+	// TypeCallState is the type of the call_state message. Sent by the server
+	// in reply to a resync message.
+	TypeCallState = "call_state"
+	// TypeClose is the type of the close message. Sent by the server as a
+	// session is closed.
+	TypeClose = "close"
+	// TypeEvent is the type of the event message. Sent by the server to the
+	// clients supporting the events capability as call and session events
+	// happen.
+	TypeEvent = "event"
+	// TypeTranscriptionStart is the type of the transcription_start message.
+	// Sent by clients to start transcribing a call.
+	TypeTranscriptionStart = "transcription_start"
+	// TypeTranscriptionStop is the type of the transcription_stop message. Sent
+	// by clients to stop transcribing a call.
+	TypeTranscriptionStop = "transcription_stop"
+	// TypeRecordingStart is the type of the recording_start message. Sent by
+	// clients to start recording a session.
+	TypeRecordingStart = "recording_start"
+	// TypeRecordingStop is the type of the recording_stop message. Sent by
+	// clients to stop recording a session.
+	TypeRecordingStop = "recording_stop"
+	// TypeHLSStart is the type of the hls_start message. Sent by clients to
+	// start broadcasting a session over LL-HLS.
+	TypeHLSStart = "hls_start"
+	// TypeHLSStop is the type of the hls_stop message. Sent by clients to stop
+	// the LL-HLS broadcast of a call.
+	TypeHLSStop = "hls_stop"
+	// TypeShutdown is the type of the shutdown message. Sent by the server to
+	// the clients supporting the shutdown capability as it's shutting down.
+	TypeShutdown = "shutdown"
+	// TypeError is the type of the error message. Sent by the server to the
+	// clients supporting the errors capability as it fails to handle one of
+	// their messages.
+	TypeError = "error"
+	// TypeMaintenance is the type of the maintenance message. Sent by the
+	// server to the clients supporting the maintenance capability to notify
+	// them of an upcoming maintenance window, or of its cancellation.
+	TypeMaintenance = "maintenance"
+	// TypeP2PPeer is the type of the p2p_peer message. Sent by the server to
+	// the sessions of a call negotiated peer-to-peer as their peer joins or
+	// leaves.
+	TypeP2PPeer = "p2p_peer"
+	// TypeP2PSignal is the type of the p2p_signal message. Carries the
+	// signaling exchanged between the sessions of a call negotiated
+	// peer-to-peer, relayed by the server.
+	TypeP2PSignal = "p2p_signal"
+	// TypeP2PUpgrade is the type of the p2p_upgrade message. Sent by the server
+	// to the sessions of a call negotiated peer-to-peer as it gets routed
+	// through the server, for them to negotiate with it as if they had just
+	// joined.
+	TypeP2PUpgrade = "p2p_upgrade"
+)
+
+// mapDataTypes are the types of the messages whose data is a map of
+// strings.
+var mapDataTypes = map[string]bool{
+	TypeJoin:               true,
+	TypeLeave:              true,
+	TypeReconnect:          true,
+	TypeAck:                true,
+	TypeHello:              true,
+	TypeGroupAuth:          true,
+	TypeResync:             true,
+	TypeCallState:          true,
+	TypeClose:              true,
+	TypeEvent:              true,
+	TypeTranscriptionStart: true,
+	TypeTranscriptionStop:  true,
+	TypeRecordingStart:     true,
+	TypeRecordingStop:      true,
+	TypeHLSStart:           true,
+	TypeHLSStop:            true,
+	TypeShutdown:           true,
+	TypeError:              true,
+	TypeMaintenance:        true,
+	TypeP2PPeer:            true,
+	TypeP2PSignal:          true,
+	TypeP2PUpgrade:         true,
+}
+
+// JoinData is the data of the join message.
+type JoinData struct {
+	// The call to join. Required.
+	CallID string
+	// The user the session belongs to. Required.
+	UserID string
+	// The ID of the session, unique across calls. Required.
+	SessionID string
+	// The group the call belongs to, if not the client owning the connection.
+	GroupID string
+	// The join token, required if join tokens are enabled, or the observer
+	// token.
+	Token string
+	// The trace ID of the session, generated by the server if empty.
+	TraceID string
+	// Whether the session is left out of the call state and events.
+	Hidden bool
+	// Whether the session joins as a read-only observer, authorized by the
+	// token.
+	Observer bool
+	// Whether the session only sends and receives audio.
+	AudioOnly bool
+	// The locale of the session, used for the captions.
+	Locale string
+	// Whether the server only offers relayed candidates.
+	ICEForceRelay bool
+	// Whether the session only uses relayed candidates.
+	ICERelayOnly bool
+	// Whether host candidates are left out.
+	ICEDisableHost bool
+	// The region of the TURN servers to relay through.
+	ICETURNRegion string
+	// Whether the session can be connected peer-to-peer to the other session of
+	// a two-party call.
+	P2P bool
+}
+
+// Map returns the data as sent on the wire.
+func (d JoinData) Map() map[string]string {
+	m := map[string]string{
+		"callID":    d.CallID,
+		"userID":    d.UserID,
+		"sessionID": d.SessionID,
+	}
+	if d.GroupID != "" {
+		m["groupID"] = d.GroupID
+	}
+	if d.Token != "" {
+		m["token"] = d.Token
+	}
+	if d.TraceID != "" {
+		m["traceID"] = d.TraceID
+	}
+	if d.Hidden {
+		m["hidden"] = "true"
+	}
+	if d.Observer {
+		m["observer"] = "true"
+	}
+	if d.AudioOnly {
+		m["audioOnly"] = "true"
+	}
+	if d.Locale != "" {
+		m["locale"] = d.Locale
+	}
+	if d.ICEForceRelay {
+		m["iceForceRelay"] = "true"
+	}
+	if d.ICERelayOnly {
+		m["iceRelayOnly"] = "true"
+	}
+	if d.ICEDisableHost {
+		m["iceDisableHost"] = "true"
+	}
+	if d.ICETURNRegion != "" {
+		m["iceTURNRegion"] = d.ICETURNRegion
+	}
+	if d.P2P {
+		m["p2p"] = "true"
+	}
+	return m
+}
+
+// ParseJoinData returns the data held by m, as received on the wire. It
+// returns an error if a required field is missing or if a field is invalid.
+func ParseJoinData(m map[string]string) (JoinData, error) {
+	var d JoinData
+	if m["callID"] == "" {
+		return d, errors.New("missing callID")
+	}
+	if m["userID"] == "" {
+		return d, errors.New("missing userID")
+	}
+	if m["sessionID"] == "" {
+		return d, errors.New("missing sessionID")
+	}
+	d.CallID = m["callID"]
+	d.UserID = m["userID"]
+	d.SessionID = m["sessionID"]
+	d.GroupID = m["groupID"]
+	d.Token = m["token"]
+	d.TraceID = m["traceID"]
+	d.Hidden = m["hidden"] == "true"
+	d.Observer = m["observer"] == "true"
+	d.AudioOnly = m["audioOnly"] == "true"
+	d.Locale = m["locale"]
+	d.ICEForceRelay = m["iceForceRelay"] == "true"
+	d.ICERelayOnly = m["iceRelayOnly"] == "true"
+	d.ICEDisableHost = m["iceDisableHost"] == "true"
+	d.ICETURNRegion = m["iceTURNRegion"]
+	d.P2P = m["p2p"] == "true"
+	return d, nil
+}
+
+// LeaveData is the data of the leave message.
+type LeaveData struct {
+	// The session to close. Required.
+	SessionID string
+	// The reason the session is closed with, either "left" (default) or
+	// "kicked".
+	Reason string
+}
+
+// Map returns the data as sent on the wire.
+func (d LeaveData) Map() map[string]string {
+	m := map[string]string{
+		"sessionID": d.SessionID,
+	}
+	if d.Reason != "" {
+		m["reason"] = d.Reason
+	}
+	return m
+}
+
+// ParseLeaveData returns the data held by m, as received on the wire. It
+// returns an error if a required field is missing or if a field is invalid.
+func ParseLeaveData(m map[string]string) (LeaveData, error) {
+	var d LeaveData
+	if m["sessionID"] == "" {
+		return d, errors.New("missing sessionID")
+	}
+	d.SessionID = m["sessionID"]
+	d.Reason = m["reason"]
+	return d, nil
+}
+
+// ReconnectData is the data of the reconnect message.
+type ReconnectData struct {
+	// The session to attach to the connection. Required.
+	SessionID string
+	// The sequence number of the last rtc message received, for the following
+	// ones to be replayed.
+	LastSeq *uint64
+	// The join token of the session, required if join tokens are enabled, or
+	// its observer token.
+	Token string
+}
+
+// Map returns the data as sent on the wire.
+func (d ReconnectData) Map() map[string]string {
+	m := map[string]string{
+		"sessionID": d.SessionID,
+	}
+	if d.LastSeq != nil {
+		m["lastSeq"] = strconv.FormatUint(*d.LastSeq, 10)
+	}
+	if d.Token != "" {
+		m["token"] = d.Token
+	}
+	return m
+}
+
+// ParseReconnectData returns the data held by m, as received on the wire.
+// It returns an error if a required field is missing or if a field is
+// invalid.
+func ParseReconnectData(m map[string]string) (ReconnectData, error) {
+	var d ReconnectData
+	if m["sessionID"] == "" {
+		return d, errors.New("missing sessionID")
+	}
+	d.SessionID = m["sessionID"]
+	if m["lastSeq"] != "" {
+		n, err := strconv.ParseUint(m["lastSeq"], 10, 64)
+		if err != nil {
+			return d, fmt.Errorf("invalid lastSeq value: %q", m["lastSeq"])
+		}
+		d.LastSeq = &n
+	}
+	d.Token = m["token"]
+	return d, nil
+}
+
+// AckData is the data of the ack message.
+type AckData struct {
+	// The session the messages were received for. Required.
+	SessionID string
+	// The sequence number of the last rtc message received. Required.
+	Seq uint64
+}
+
+// Map returns the data as sent on the wire.
+func (d AckData) Map() map[string]string {
+	m := map[string]string{
+		"sessionID": d.SessionID,
+		"seq":       strconv.FormatUint(d.Seq, 10),
+	}
+	return m
+}
+
+// ParseAckData returns the data held by m, as received on the wire. It
+// returns an error if a required field is missing or if a field is invalid.
+func ParseAckData(m map[string]string) (AckData, error) {
+	var d AckData
+	if m["sessionID"] == "" {
+		return d, errors.New("missing sessionID")
+	}
+	if m["seq"] == "" {
+		return d, errors.New("missing seq")
+	}
+	d.SessionID = m["sessionID"]
+	{
+		n, err := strconv.ParseUint(m["seq"], 10, 64)
+		if err != nil {
+			return d, fmt.Errorf("invalid seq value: %q", m["seq"])
+		}
+		d.Seq = n
+	}
+	return d, nil
+}
+
+// HelloData is the data of the hello message.
+type HelloData struct {
+	// The client the connection authenticated as. Set by the server.
+	ClientID string
+	// The ID of the connection. Set by the server.
+	ConnID string
+	// The highest protocol version supported by the sender. Legacy senders
+	// leave it empty.
+	ProtocolVersion string
+	// The comma separated capabilities supported by the sender.
+	Capabilities string
+}
+
+// Map returns the data as sent on the wire.
+func (d HelloData) Map() map[string]string {
+	m := map[string]string{}
+	if d.ClientID != "" {
+		m["clientID"] = d.ClientID
+	}
+	if d.ConnID != "" {
+		m["connID"] = d.ConnID
+	}
+	if d.ProtocolVersion != "" {
+		m["protocolVersion"] = d.ProtocolVersion
+	}
+	if d.Capabilities != "" {
+		m["capabilities"] = d.Capabilities
+	}
+	return m
+}
+
+// ParseHelloData returns the data held by m, as received on the wire. It
+// returns an error if a required field is missing or if a field is invalid.
+func ParseHelloData(m map[string]string) (HelloData, error) {
+	var d HelloData
+	d.ClientID = m["clientID"]
+	d.ConnID = m["connID"]
+	d.ProtocolVersion = m["protocolVersion"]
+	d.Capabilities = m["capabilities"]
+	return d, nil
+}
+
+// GroupAuthData is the data of the group_auth message.
+type GroupAuthData struct {
+	// The group to authenticate. Required.
+	GroupID string
+	// The auth key of the group, unless signed credentials are sent.
+	AuthKey string
+	// The signed credentials of the group.
+	Credentials string
+	// The reason the authentication failed, if it did. Set by the server.
+	Error string
+	// The code of the error. Set by the server.
+	ErrorCode string
+}
+
+// Map returns the data as sent on the wire.
+func (d GroupAuthData) Map() map[string]string {
+	m := map[string]string{
+		"groupID": d.GroupID,
+	}
+	if d.AuthKey != "" {
+		m["authKey"] = d.AuthKey
+	}
+	if d.Credentials != "" {
+		m["credentials"] = d.Credentials
+	}
+	if d.Error != "" {
+		m["error"] = d.Error
+	}
+	if d.ErrorCode != "" {
+		m["errorCode"] = d.ErrorCode
+	}
+	return m
+}
+
+// ParseGroupAuthData returns the data held by m, as received on the wire.
+// It returns an error if a required field is missing or if a field is
+// invalid.
+func ParseGroupAuthData(m map[string]string) (GroupAuthData, error) {
+	var d GroupAuthData
+	if m["groupID"] == "" {
+		return d, errors.New("missing groupID")
+	}
+	d.GroupID = m["groupID"]
+	d.AuthKey = m["authKey"]
+	d.Credentials = m["credentials"]
+	d.Error = m["error"]
+	d.ErrorCode = m["errorCode"]
+	return d, nil
+}
+
+// ResyncData is the data of the resync message.
+type ResyncData struct {
+	// The call to get the state of. Required.
+	CallID string
+	// The group the call belongs to, if not the client owning the connection.
+	GroupID string
+}
+
+// Map returns the data as sent on the wire.
+func (d ResyncData) Map() map[string]string {
+	m := map[string]string{
+		"callID": d.CallID,
+	}
+	if d.GroupID != "" {
+		m["groupID"] = d.GroupID
+	}
+	return m
+}
+
+// ParseResyncData returns the data held by m, as received on the wire. It
+// returns an error if a required field is missing or if a field is invalid.
+func ParseResyncData(m map[string]string) (ResyncData, error) {
+	var d ResyncData
+	if m["callID"] == "" {
+		return d, errors.New("missing callID")
+	}
+	d.CallID = m["callID"]
+	d.GroupID = m["groupID"]
+	return d, nil
+}
+
+// CallStateData is the data of the call_state message.
+type CallStateData struct {
+	// The call the state is of. Required.
+	CallID string
+	// The JSON encoded state of the call. Required.
+	State string
+	// The group the call belongs to, if not the client owning the connection.
+	GroupID string
+}
+
+// Map returns the data as sent on the wire.
+func (d CallStateData) Map() map[string]string {
+	m := map[string]string{
+		"callID": d.CallID,
+		"state":  d.State,
+	}
+	if d.GroupID != "" {
+		m["groupID"] = d.GroupID
+	}
+	return m
+}
+
+// ParseCallStateData returns the data held by m, as received on the wire.
+// It returns an error if a required field is missing or if a field is
+// invalid.
+func ParseCallStateData(m map[string]string) (CallStateData, error) {
+	var d CallStateData
+	if m["callID"] == "" {
+		return d, errors.New("missing callID")
+	}
+	if m["state"] == "" {
+		return d, errors.New("missing state")
+	}
+	d.CallID = m["callID"]
+	d.State = m["state"]
+	d.GroupID = m["groupID"]
+	return d, nil
+}
+
+// CloseData is the data of the close message.
+type CloseData struct {
+	// The session that got closed. Required.
+	SessionID string
+	// The trace ID of the session.
+	TraceID string
+	// The reason the session was closed with.
+	Reason string
+	// The error code matching the reason, if it's an error.
+	ErrorCode string
+}
+
+// Map returns the data as sent on the wire.
+func (d CloseData) Map() map[string]string {
+	m := map[string]string{
+		"sessionID": d.SessionID,
+	}
+	if d.TraceID != "" {
+		m["traceID"] = d.TraceID
+	}
+	if d.Reason != "" {
+		m["reason"] = d.Reason
+	}
+	if d.ErrorCode != "" {
+		m["errorCode"] = d.ErrorCode
+	}
+	return m
+}
+
+// ParseCloseData returns the data held by m, as received on the wire. It
+// returns an error if a required field is missing or if a field is invalid.
+func ParseCloseData(m map[string]string) (CloseData, error) {
+	var d CloseData
+	if m["sessionID"] == "" {
+		return d, errors.New("missing sessionID")
+	}
+	d.SessionID = m["sessionID"]
+	d.TraceID = m["traceID"]
+	d.Reason = m["reason"]
+	d.ErrorCode = m["errorCode"]
+	return d, nil
+}
+
+// EventData is the data of the event message.
+type EventData struct {
+	// The type of the event. Required.
+	Type string
+	// The time the event happened at, in milliseconds since the epoch.
+	// Required.
+	Timestamp int64
+	// The group the call belongs to.
+	GroupID string
+	// The call the event is about.
+	CallID string
+	// The user owning the session, for session events.
+	UserID string
+	// The session the event is about, for session events.
+	SessionID string
+	// The JSON encoded stream quality, for stream quality events.
+	Quality string
+	// The JSON encoded recording info, for recording events.
+	Recording string
+	// The JSON encoded LL-HLS stream info, for LL-HLS events.
+	HLS string
+	// The JSON encoded migration, for session migration events.
+	Migration string
+	// The track the event is about, for track events.
+	TrackID string
+	// The trace ID of the session.
+	TraceID string
+}
+
+// Map returns the data as sent on the wire.
+func (d EventData) Map() map[string]string {
+	m := map[string]string{
+		"type":      d.Type,
+		"timestamp": strconv.FormatInt(d.Timestamp, 10),
+	}
+	if d.GroupID != "" {
+		m["groupID"] = d.GroupID
+	}
+	if d.CallID != "" {
+		m["callID"] = d.CallID
+	}
+	if d.UserID != "" {
+		m["userID"] = d.UserID
+	}
+	if d.SessionID != "" {
+		m["sessionID"] = d.SessionID
+	}
+	if d.Quality != "" {
+		m["quality"] = d.Quality
+	}
+	if d.Recording != "" {
+		m["recording"] = d.Recording
+	}
+	if d.HLS != "" {
+		m["hls"] = d.HLS
+	}
+	if d.Migration != "" {
+		m["migration"] = d.Migration
+	}
+	if d.TrackID != "" {
+		m["trackID"] = d.TrackID
+	}
+	if d.TraceID != "" {
+		m["traceID"] = d.TraceID
+	}
+	return m
+}
+
+// ParseEventData returns the data held by m, as received on the wire. It
+// returns an error if a required field is missing or if a field is invalid.
+func ParseEventData(m map[string]string) (EventData, error) {
+	var d EventData
+	if m["type"] == "" {
+		return d, errors.New("missing type")
+	}
+	if m["timestamp"] == "" {
+		return d, errors.New("missing timestamp")
+	}
+	d.Type = m["type"]
+	{
+		n, err := strconv.ParseInt(m["timestamp"], 10, 64)
+		if err != nil {
+			return d, fmt.Errorf("invalid timestamp value: %q", m["timestamp"])
+		}
+		d.Timestamp = n
+	}
+	d.GroupID = m["groupID"]
+	d.CallID = m["callID"]
+	d.UserID = m["userID"]
+	d.SessionID = m["sessionID"]
+	d.Quality = m["quality"]
+	d.Recording = m["recording"]
+	d.HLS = m["hls"]
+	d.Migration = m["migration"]
+	d.TrackID = m["trackID"]
+	d.TraceID = m["traceID"]
+	return d, nil
+}
+
+// TranscriptionData is the data of the transcription_start and
+// transcription_stop messages.
+type TranscriptionData struct {
+	// The call to transcribe. Required.
+	CallID string
+	// The group the call belongs to, if not the client owning the connection.
+	GroupID string
+}
+
+// Map returns the data as sent on the wire.
+func (d TranscriptionData) Map() map[string]string {
+	m := map[string]string{
+		"callID": d.CallID,
+	}
+	if d.GroupID != "" {
+		m["groupID"] = d.GroupID
+	}
+	return m
+}
+
+// ParseTranscriptionData returns the data held by m, as received on the
+// wire. It returns an error if a required field is missing or if a field is
+// invalid.
+func ParseTranscriptionData(m map[string]string) (TranscriptionData, error) {
+	var d TranscriptionData
+	if m["callID"] == "" {
+		return d, errors.New("missing callID")
+	}
+	d.CallID = m["callID"]
+	d.GroupID = m["groupID"]
+	return d, nil
+}
+
+// RecordingStartData is the data of the recording_start message.
+type RecordingStartData struct {
+	// The session to record. Required.
+	SessionID string
+	// The group the call belongs to, if not the client owning the connection.
+	GroupID string
+	// The recording target to upload to, if not the default one.
+	Target string
+	// The duration after which the recording stops, unlimited if zero.
+	DurationSeconds *int
+}
+
+// Map returns the data as sent on the wire.
+func (d RecordingStartData) Map() map[string]string {
+	m := map[string]string{
+		"sessionID": d.SessionID,
+	}
+	if d.GroupID != "" {
+		m["groupID"] = d.GroupID
+	}
+	if d.Target != "" {
+		m["target"] = d.Target
+	}
+	if d.DurationSeconds != nil {
+		m["durationSeconds"] = strconv.Itoa(*d.DurationSeconds)
+	}
+	return m
+}
+
+// ParseRecordingStartData returns the data held by m, as received on the
+// wire. It returns an error if a required field is missing or if a field is
+// invalid.
+func ParseRecordingStartData(m map[string]string) (RecordingStartData, error) {
+	var d RecordingStartData
+	if m["sessionID"] == "" {
+		return d, errors.New("missing sessionID")
+	}
+	d.SessionID = m["sessionID"]
+	d.GroupID = m["groupID"]
+	d.Target = m["target"]
+	if m["durationSeconds"] != "" {
+		n, err := strconv.Atoi(m["durationSeconds"])
+		if err != nil || n < 0 {
+			return d, fmt.Errorf("invalid durationSeconds value: %q", m["durationSeconds"])
+		}
+		d.DurationSeconds = &n
+	}
+	return d, nil
+}
+
+// RecordingStopData is the data of the recording_stop message.
+type RecordingStopData struct {
+	// The session to stop recording. Required.
+	SessionID string
+	// The group the call belongs to, if not the client owning the connection.
+	GroupID string
+}
+
+// Map returns the data as sent on the wire.
+func (d RecordingStopData) Map() map[string]string {
+	m := map[string]string{
+		"sessionID": d.SessionID,
+	}
+	if d.GroupID != "" {
+		m["groupID"] = d.GroupID
+	}
+	return m
+}
+
+// ParseRecordingStopData returns the data held by m, as received on the
+// wire. It returns an error if a required field is missing or if a field is
+// invalid.
+func ParseRecordingStopData(m map[string]string) (RecordingStopData, error) {
+	var d RecordingStopData
+	if m["sessionID"] == "" {
+		return d, errors.New("missing sessionID")
+	}
+	d.SessionID = m["sessionID"]
+	d.GroupID = m["groupID"]
+	return d, nil
+}
+
+// HLSStartData is the data of the hls_start message.
+type HLSStartData struct {
+	// The session to broadcast. Required.
+	SessionID string
+	// The group the call belongs to, if not the client owning the connection.
+	GroupID string
+}
+
+// Map returns the data as sent on the wire.
+func (d HLSStartData) Map() map[string]string {
+	m := map[string]string{
+		"sessionID": d.SessionID,
+	}
+	if d.GroupID != "" {
+		m["groupID"] = d.GroupID
+	}
+	return m
+}
+
+// ParseHLSStartData returns the data held by m, as received on the wire. It
+// returns an error if a required field is missing or if a field is invalid.
+func ParseHLSStartData(m map[string]string) (HLSStartData, error) {
+	var d HLSStartData
+	if m["sessionID"] == "" {
+		return d, errors.New("missing sessionID")
+	}
+	d.SessionID = m["sessionID"]
+	d.GroupID = m["groupID"]
+	return d, nil
+}
+
+// HLSStopData is the data of the hls_stop message.
+type HLSStopData struct {
+	// The call to stop broadcasting. Required.
+	CallID string
+	// The group the call belongs to, if not the client owning the connection.
+	GroupID string
+}
+
+// Map returns the data as sent on the wire.
+func (d HLSStopData) Map() map[string]string {
+	m := map[string]string{
+		"callID": d.CallID,
+	}
+	if d.GroupID != "" {
+		m["groupID"] = d.GroupID
+	}
+	return m
+}
+
+// ParseHLSStopData returns the data held by m, as received on the wire. It
+// returns an error if a required field is missing or if a field is invalid.
+func ParseHLSStopData(m map[string]string) (HLSStopData, error) {
+	var d HLSStopData
+	if m["callID"] == "" {
+		return d, errors.New("missing callID")
+	}
+	d.CallID = m["callID"]
+	d.GroupID = m["groupID"]
+	return d, nil
+}
+
+// ShutdownData is the data of the shutdown message.
+type ShutdownData struct {
+	// The time left before the sessions get force-closed. Required.
+	TimeoutSeconds int
+}
+
+// Map returns the data as sent on the wire.
+func (d ShutdownData) Map() map[string]string {
+	m := map[string]string{
+		"timeoutSeconds": strconv.Itoa(d.TimeoutSeconds),
+	}
+	return m
+}
+
+// ParseShutdownData returns the data held by m, as received on the wire. It
+// returns an error if a required field is missing or if a field is invalid.
+func ParseShutdownData(m map[string]string) (ShutdownData, error) {
+	var d ShutdownData
+	if m["timeoutSeconds"] == "" {
+		return d, errors.New("missing timeoutSeconds")
+	}
+	{
+		n, err := strconv.Atoi(m["timeoutSeconds"])
+		if err != nil {
+			return d, fmt.Errorf("invalid timeoutSeconds value: %q", m["timeoutSeconds"])
+		}
+		d.TimeoutSeconds = n
+	}
+	return d, nil
+}
+
+// ErrorData is the data of the error message.
+type ErrorData struct {
+	// The type of the message that failed to be handled. Required.
+	MsgType string
+	// The machine readable code of the error. Required.
+	ErrorCode string
+	// The error message. Required.
+	Error string
+	// The group of the message, if set.
+	GroupID string
+	// The call of the message, if set.
+	CallID string
+	// The session of the message, if set.
+	SessionID string
+}
+
+// Map returns the data as sent on the wire.
+func (d ErrorData) Map() map[string]string {
+	m := map[string]string{
+		"msgType":   d.MsgType,
+		"errorCode": d.ErrorCode,
+		"error":     d.Error,
+	}
+	if d.GroupID != "" {
+		m["groupID"] = d.GroupID
+	}
+	if d.CallID != "" {
+		m["callID"] = d.CallID
+	}
+	if d.SessionID != "" {
+		m["sessionID"] = d.SessionID
+	}
+	return m
+}
+
+// ParseErrorData returns the data held by m, as received on the wire. It
+// returns an error if a required field is missing or if a field is invalid.
+func ParseErrorData(m map[string]string) (ErrorData, error) {
+	var d ErrorData
+	if m["msgType"] == "" {
+		return d, errors.New("missing msgType")
+	}
+	if m["errorCode"] == "" {
+		return d, errors.New("missing errorCode")
+	}
+	if m["error"] == "" {
+		return d, errors.New("missing error")
+	}
+	d.MsgType = m["msgType"]
+	d.ErrorCode = m["errorCode"]
+	d.Error = m["error"]
+	d.GroupID = m["groupID"]
+	d.CallID = m["callID"]
+	d.SessionID = m["sessionID"]
+	return d, nil
+}
+
+// MaintenanceData is the data of the maintenance message.
+type MaintenanceData struct {
+	// The ID of the maintenance window. Required.
+	ID string
+	// What happens as the window starts, either "drain" or "shutdown".
+	// Required.
+	Action string
+	// The time the window starts at, in milliseconds since the epoch. Required.
+	StartAt int64
+	// Whether the window got cancelled.
+	Cancelled bool
+}
+
+// Map returns the data as sent on the wire.
+func (d MaintenanceData) Map() map[string]string {
+	m := map[string]string{
+		"id":      d.ID,
+		"action":  d.Action,
+		"startAt": strconv.FormatInt(d.StartAt, 10),
+	}
+	if d.Cancelled {
+		m["cancelled"] = "true"
+	}
+	return m
+}
+
+// ParseMaintenanceData returns the data held by m, as received on the wire.
+// It returns an error if a required field is missing or if a field is
+// invalid.
+func ParseMaintenanceData(m map[string]string) (MaintenanceData, error) {
+	var d MaintenanceData
+	if m["id"] == "" {
+		return d, errors.New("missing id")
+	}
+	if m["action"] == "" {
+		return d, errors.New("missing action")
+	}
+	if m["startAt"] == "" {
+		return d, errors.New("missing startAt")
+	}
+	d.ID = m["id"]
+	d.Action = m["action"]
+	{
+		n, err := strconv.ParseInt(m["startAt"], 10, 64)
+		if err != nil {
+			return d, fmt.Errorf("invalid startAt value: %q", m["startAt"])
+		}
+		d.StartAt = n
+	}
+	d.Cancelled = m["cancelled"] == "true"
+	return d, nil
+}
+
+// P2PPeerData is the data of the p2p_peer message.
+type P2PPeerData struct {
+	// The session notified. Required.
+	SessionID string
+	// The session of the peer. Required.
+	PeerSessionID string
+	// The user owning the session of the peer.
+	PeerUserID string
+	// Either "joined" or "left". Required.
+	State string
+	// Either "offerer" or "answerer", as the peer joins.
+	Role string
+}
+
+// Map returns the data as sent on the wire.
+func (d P2PPeerData) Map() map[string]string {
+	m := map[string]string{
+		"sessionID":     d.SessionID,
+		"peerSessionID": d.PeerSessionID,
+		"state":         d.State,
+	}
+	if d.PeerUserID != "" {
+		m["peerUserID"] = d.PeerUserID
+	}
+	if d.Role != "" {
+		m["role"] = d.Role
+	}
+	return m
+}
+
+// ParseP2PPeerData returns the data held by m, as received on the wire. It
+// returns an error if a required field is missing or if a field is invalid.
+func ParseP2PPeerData(m map[string]string) (P2PPeerData, error) {
+	var d P2PPeerData
+	if m["sessionID"] == "" {
+		return d, errors.New("missing sessionID")
+	}
+	if m["peerSessionID"] == "" {
+		return d, errors.New("missing peerSessionID")
+	}
+	if m["state"] == "" {
+		return d, errors.New("missing state")
+	}
+	d.SessionID = m["sessionID"]
+	d.PeerSessionID = m["peerSessionID"]
+	d.PeerUserID = m["peerUserID"]
+	d.State = m["state"]
+	d.Role = m["role"]
+	return d, nil
+}
+
+// P2PSignalData is the data of the p2p_signal message.
+type P2PSignalData struct {
+	// The session sending the signaling, or the session it's relayed to.
+	// Required.
+	SessionID string
+	// The session the signaling comes from. Set by the server.
+	PeerSessionID string
+	// The opaque signaling data.
+	Data string
+}
+
+// Map returns the data as sent on the wire.
+func (d P2PSignalData) Map() map[string]string {
+	m := map[string]string{
+		"sessionID": d.SessionID,
+	}
+	if d.PeerSessionID != "" {
+		m["peerSessionID"] = d.PeerSessionID
+	}
+	if d.Data != "" {
+		m["data"] = d.Data
+	}
+	return m
+}
+
+// ParseP2PSignalData returns the data held by m, as received on the wire.
+// It returns an error if a required field is missing or if a field is
+// invalid.
+func ParseP2PSignalData(m map[string]string) (P2PSignalData, error) {
+	var d P2PSignalData
+	if m["sessionID"] == "" {
+		return d, errors.New("missing sessionID")
+	}
+	d.SessionID = m["sessionID"]
+	d.PeerSessionID = m["peerSessionID"]
+	d.Data = m["data"]
+	return d, nil
+}
+
+// P2PUpgradeData is the data of the p2p_upgrade message.
+type P2PUpgradeData struct {
+	// The session to negotiate with the server. Required.
+	SessionID string
+}
+
+// Map returns the data as sent on the wire.
+func (d P2PUpgradeData) Map() map[string]string {
+	m := map[string]string{
+		"sessionID": d.SessionID,
+	}
+	return m
+}
+
+// ParseP2PUpgradeData returns the data held by m, as received on the wire.
+// It returns an error if a required field is missing or if a field is
+// invalid.
+func ParseP2PUpgradeData(m map[string]string) (P2PUpgradeData, error) {
+	var d P2PUpgradeData
+	if m["sessionID"] == "" {
+		return d, errors.New("missing sessionID")
+	}
+	d.SessionID = m["sessionID"]
+	return d, nil
+}
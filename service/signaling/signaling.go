@@ -0,0 +1,25 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package signaling defines the messages exchanged over the signaling
+// connection. The message types and the Go types of their data are
+// generated from schema.json, which is the reference for clients
+// implemented in other languages.
+package signaling
+
+import (
+	_ "embed"
+)
+
+//go:generate go run ./gen
+
+// Schema is the JSON Schema of the signaling messages.
+//
+//go:embed schema.json
+var Schema []byte
+
+// HasMapData returns whether the data of the given message type is a map of
+// strings, as opposed to a struct (rtc messages) or an unknown type.
+func HasMapData(msgType string) bool {
+	return mapDataTypes[msgType]
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package signaling
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/mattermost/rtcd/service/signaling/internal/codegen"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerated(t *testing.T) {
+	require.True(t, json.Valid(Schema))
+
+	src, err := codegen.Generate(Schema, "signaling")
+	require.NoError(t, err)
+	generated, err := os.ReadFile("messages_gen.go")
+	require.NoError(t, err)
+	require.Equal(t, string(src), string(generated), "messages_gen.go is out of date, run go generate")
+}
+
+func TestHasMapData(t *testing.T) {
+	require.True(t, HasMapData(TypeJoin))
+	require.True(t, HasMapData(TypeTranscriptionStop))
+	require.False(t, HasMapData(TypeRTC))
+	require.False(t, HasMapData("unknown"))
+}
+
+func TestMapData(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		lastSeq := uint64(0)
+		data := ReconnectData{
+			SessionID: "sessionID",
+			LastSeq:   &lastSeq,
+		}
+		m := data.Map()
+		require.Equal(t, map[string]string{"sessionID": "sessionID", "lastSeq": "0"}, m)
+		parsed, err := ParseReconnectData(m)
+		require.NoError(t, err)
+		require.Equal(t, data, parsed)
+
+		join := JoinData{
+			CallID:        "callID",
+			UserID:        "userID",
+			SessionID:     "sessionID",
+			Hidden:        true,
+			ICETURNRegion: "eu",
+		}
+		require.Equal(t, map[string]string{
+			"callID":        "callID",
+			"userID":        "userID",
+			"sessionID":     "sessionID",
+			"hidden":        "true",
+			"iceTURNRegion": "eu",
+		}, join.Map())
+		parsedJoin, err := ParseJoinData(join.Map())
+		require.NoError(t, err)
+		require.Equal(t, join, parsedJoin)
+	})
+
+	t.Run("zero values omitted", func(t *testing.T) {
+		require.Equal(t, map[string]string{"sessionID": "sessionID"}, ReconnectData{SessionID: "sessionID"}.Map())
+
+		data, err := ParseReconnectData(map[string]string{"sessionID": "sessionID"})
+		require.NoError(t, err)
+		require.Nil(t, data.LastSeq)
+	})
+
+	t.Run("missing field", func(t *testing.T) {
+		_, err := ParseJoinData(map[string]string{"userID": "userID", "sessionID": "sessionID"})
+		require.EqualError(t, err, "missing callID")
+
+		_, err = ParseAckData(map[string]string{"sessionID": "sessionID"})
+		require.EqualError(t, err, "missing seq")
+	})
+
+	t.Run("invalid field", func(t *testing.T) {
+		_, err := ParseAckData(map[string]string{"sessionID": "sessionID", "seq": "-1"})
+		require.EqualError(t, err, `invalid seq value: "-1"`)
+
+		_, err = ParseRecordingStartData(map[string]string{"sessionID": "sessionID", "durationSeconds": "-1"})
+		require.EqualError(t, err, `invalid durationSeconds value: "-1"`)
+
+		_, err = ParseMaintenanceData(map[string]string{"id": "id", "action": "drain", "startAt": "soon"})
+		require.EqualError(t, err, `invalid startAt value: "soon"`)
+	})
+
+	t.Run("unknown fields ignored", func(t *testing.T) {
+		data, err := ParseLeaveData(map[string]string{"sessionID": "sessionID", "unknown": "value"})
+		require.NoError(t, err)
+		require.Equal(t, LeaveData{SessionID: "sessionID"}, data)
+	})
+}
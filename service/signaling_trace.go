@@ -0,0 +1,568 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const (
+	SignalingTraceDirectionIn  = "in"
+	SignalingTraceDirectionOut = "out"
+
+	// signalingTraceRedacted replaces the values of sensitive fields. ICE
+	// passwords are replaced with it as well, it's long enough to remain a
+	// valid one.
+	signalingTraceRedacted = "redacted-redacted-redacted"
+	// Documentation addresses (RFC 5737, RFC 3849) replacing the network
+	// addresses of participants.
+	signalingTraceRedactedIPv4 = "192.0.2.1"
+	signalingTraceRedactedIPv6 = "2001:db8::1"
+)
+
+// sensitiveSignalingFields lists the (partial, lowercase) names of the
+// client message fields whose values are redacted from signaling traces.
+var sensitiveSignalingFields = []string{"token", "secret", "key", "password", "credential"}
+
+// SignalingTraceEntry is a signaling message recorded in a signaling trace,
+// which is made of one JSON encoded entry per line.
+type SignalingTraceEntry struct {
+	// Timestamp is the time, in unix milliseconds, the message was received
+	// or sent at.
+	Timestamp int64 `json:"timestamp"`
+	// Direction is either "in", for the messages received from clients, or
+	// "out".
+	Direction string `json:"direction"`
+	ConnID    string `json:"conn_id"`
+	// Type is the type of the client message.
+	Type string            `json:"type"`
+	Data map[string]string `json:"data,omitempty"`
+	// RTC is set for the messages of the rtc type.
+	RTC *SignalingTraceRTCMessage `json:"rtc,omitempty"`
+}
+
+// SignalingTraceRTCMessage is an rtc message recorded in a signaling trace.
+type SignalingTraceRTCMessage struct {
+	GroupID   string          `json:"group_id"`
+	UserID    string          `json:"user_id"`
+	SessionID string          `json:"session_id"`
+	Type      rtc.MessageType `json:"type"`
+	Data      string          `json:"data,omitempty"`
+	Seq       uint64          `json:"seq,omitempty"`
+}
+
+// ClientMessage returns the client message recorded in the entry.
+func (e SignalingTraceEntry) ClientMessage() ClientMessage {
+	if e.RTC != nil {
+		return ClientMessage{
+			Type: e.Type,
+			Data: rtc.Message{
+				GroupID:   e.RTC.GroupID,
+				UserID:    e.RTC.UserID,
+				SessionID: e.RTC.SessionID,
+				Type:      e.RTC.Type,
+				Data:      []byte(e.RTC.Data),
+				Seq:       e.RTC.Seq,
+			},
+		}
+	}
+	return ClientMessage{
+		Type: e.Type,
+		Data: e.Data,
+	}
+}
+
+// ReadSignalingTrace reads the entries of the signaling trace read from r.
+func ReadSignalingTrace(r io.Reader) ([]SignalingTraceEntry, error) {
+	var entries []SignalingTraceEntry
+	dec := json.NewDecoder(r)
+	for {
+		var entry SignalingTraceEntry
+		if err := dec.Decode(&entry); errors.Is(err, io.EOF) {
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode entry %d: %w", len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// SignalingTraceInfo describes a signaling trace.
+type SignalingTraceInfo struct {
+	// Path is the path of the trace file.
+	Path string
+	// Size is the number of bytes written to the file.
+	Size int64
+	// Messages is the number of messages recorded.
+	Messages int
+}
+
+// signalingTrace records the signaling messages exchanged with the sessions
+// of a call to a file.
+type signalingTrace struct {
+	groupID  string
+	callID   string
+	file     *os.File
+	w        *bufio.Writer
+	path     string
+	size     int64
+	maxSize  int64
+	messages int
+	// sessions holds the IDs of the sessions of the call.
+	sessions map[string]bool
+	timer    *time.Timer
+	closed   bool
+	// onDone is called when the trace reaches its size or time bound.
+	onDone func(t *signalingTrace, reason string)
+	mut    sync.Mutex
+}
+
+func newSignalingTrace(path, groupID, callID string, sessionIDs []string, maxSize int64, duration time.Duration,
+	onDone func(t *signalingTrace, reason string)) (*signalingTrace, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signaling trace file: %w", err)
+	}
+
+	t := &signalingTrace{
+		groupID:  groupID,
+		callID:   callID,
+		file:     file,
+		w:        bufio.NewWriter(file),
+		path:     path,
+		maxSize:  maxSize,
+		sessions: map[string]bool{},
+		onDone:   onDone,
+	}
+	for _, sessionID := range sessionIDs {
+		t.sessions[sessionID] = true
+	}
+
+	t.mut.Lock()
+	t.timer = time.AfterFunc(duration, func() {
+		t.onDone(t, "duration elapsed")
+	})
+	t.mut.Unlock()
+
+	return t, nil
+}
+
+func (t *signalingTrace) hasSession(sessionID string) bool {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.sessions[sessionID]
+}
+
+func (t *signalingTrace) addSession(sessionID string) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.sessions[sessionID] = true
+}
+
+// record writes the given entry to the trace file.
+func (t *signalingTrace) record(entry SignalingTraceEntry) {
+	js, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	js = append(js, '\n')
+
+	t.mut.Lock()
+	if t.closed {
+		t.mut.Unlock()
+		return
+	}
+	if t.size+int64(len(js)) > t.maxSize {
+		t.mut.Unlock()
+		t.onDone(t, "max size reached")
+		return
+	}
+	n, err := t.w.Write(js)
+	t.size += int64(n)
+	if err == nil {
+		t.messages++
+	}
+	t.mut.Unlock()
+
+	if err != nil {
+		t.onDone(t, "write failed")
+	}
+}
+
+// close flushes and closes the trace file. It's safe to call multiple
+// times.
+func (t *signalingTrace) close() SignalingTraceInfo {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	info := SignalingTraceInfo{
+		Path:     t.path,
+		Size:     t.size,
+		Messages: t.messages,
+	}
+
+	if t.closed {
+		return info
+	}
+	t.closed = true
+
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	_ = t.w.Flush()
+	_ = t.file.Close()
+
+	return info
+}
+
+func signalingTraceKey(groupID, callID string) string {
+	return groupID + "/" + callID
+}
+
+// startSignalingTrace starts recording the signaling messages of the given
+// call. It returns the path of the trace file.
+func (s *Service) startSignalingTrace(groupID, callID string, duration time.Duration) (string, error) {
+	cfg := s.cfg.API.SignalingTrace
+	if cfg.Dir == "" {
+		return "", errors.New("signaling trace is not enabled")
+	}
+
+	state, err := s.rtcServer.GetCallState(groupID, callID)
+	if err != nil {
+		return "", err
+	}
+	sessionIDs := make([]string, 0, len(state.Sessions))
+	for _, ss := range state.Sessions {
+		sessionIDs = append(sessionIDs, ss.SessionID)
+	}
+
+	maxDuration := time.Duration(cfg.MaxDurationSeconds) * time.Second
+	if duration <= 0 || duration > maxDuration {
+		duration = maxDuration
+	}
+
+	key := signalingTraceKey(groupID, callID)
+	s.signalingTracesMut.Lock()
+	defer s.signalingTracesMut.Unlock()
+	if s.signalingTraces[key] != nil {
+		return "", errors.New("signaling trace already started")
+	}
+
+	// IDs are client provided, a random name keeps them out of the path.
+	path := filepath.Join(cfg.Dir,
+		fmt.Sprintf("rtcd_signaling_%s_%s.jsonl", time.Now().UTC().Format("20060102T150405Z"), random.NewID()))
+	t, err := newSignalingTrace(path, groupID, callID, sessionIDs, int64(cfg.MaxSizeMB)*1024*1024, duration,
+		func(t *signalingTrace, reason string) {
+			s.stopSignalingTrace(t, reason)
+		})
+	if err != nil {
+		return "", err
+	}
+	s.signalingTraces[key] = t
+
+	s.log.Info("signaling trace started",
+		mlog.String("groupID", groupID),
+		mlog.String("callID", callID),
+		mlog.String("path", path),
+		mlog.Int("durationSeconds", int(duration.Seconds())))
+
+	return path, nil
+}
+
+// stopSignalingTrace removes the given trace and closes it.
+func (s *Service) stopSignalingTrace(t *signalingTrace, reason string) SignalingTraceInfo {
+	key := signalingTraceKey(t.groupID, t.callID)
+	s.signalingTracesMut.Lock()
+	removed := s.signalingTraces[key] == t
+	if removed {
+		delete(s.signalingTraces, key)
+	}
+	s.signalingTracesMut.Unlock()
+
+	info := t.close()
+	if removed {
+		s.log.Info("signaling trace stopped",
+			mlog.String("callID", t.callID),
+			mlog.String("path", info.Path),
+			mlog.Int64("size", info.Size),
+			mlog.Int("messages", info.Messages),
+			mlog.String("reason", reason))
+	}
+	return info
+}
+
+// stopSignalingTraces stops all the running traces.
+func (s *Service) stopSignalingTraces() {
+	s.signalingTracesMut.RLock()
+	traces := make([]*signalingTrace, 0, len(s.signalingTraces))
+	for _, t := range s.signalingTraces {
+		traces = append(traces, t)
+	}
+	s.signalingTracesMut.RUnlock()
+
+	for _, t := range traces {
+		s.stopSignalingTrace(t, "shutdown")
+	}
+}
+
+func (s *Service) getSignalingTrace(groupID, callID string) *signalingTrace {
+	s.signalingTracesMut.RLock()
+	defer s.signalingTracesMut.RUnlock()
+	return s.signalingTraces[signalingTraceKey(groupID, callID)]
+}
+
+// getSessionSignalingTrace returns the trace recording the call the given
+// session belongs to, if any.
+func (s *Service) getSessionSignalingTrace(sessionID string) *signalingTrace {
+	s.signalingTracesMut.RLock()
+	defer s.signalingTracesMut.RUnlock()
+	for _, t := range s.signalingTraces {
+		if t.hasSession(sessionID) {
+			return t
+		}
+	}
+	return nil
+}
+
+func (s *Service) hasSignalingTraces() bool {
+	s.signalingTracesMut.RLock()
+	defer s.signalingTracesMut.RUnlock()
+	return len(s.signalingTraces) > 0
+}
+
+// traceSessionJoin records the join message of the given session, if its
+// call is being traced.
+func (s *Service) traceSessionJoin(connID, groupID, callID, sessionID string, cm ClientMessage) {
+	if !s.hasSignalingTraces() {
+		return
+	}
+	if t := s.getSignalingTrace(groupID, callID); t != nil {
+		t.addSession(sessionID)
+		t.record(newSignalingTraceEntry(SignalingTraceDirectionIn, connID, cm))
+	}
+}
+
+// traceClientMsg records the given message if it concerns a session, or a
+// call, being traced. Join messages are recorded through traceSessionJoin.
+func (s *Service) traceClientMsg(direction, connID, clientID string, cm ClientMessage) {
+	if !s.hasSignalingTraces() {
+		return
+	}
+
+	var t *signalingTrace
+	switch data := cm.Data.(type) {
+	case rtc.Message:
+		t = s.getSessionSignalingTrace(data.SessionID)
+	case map[string]string:
+		if sessionID := data["sessionID"]; sessionID != "" {
+			t = s.getSessionSignalingTrace(sessionID)
+		} else if callID := data["callID"]; callID != "" {
+			if groupID, err := s.resolveGroupID(connID, clientID, data["groupID"]); err == nil {
+				t = s.getSignalingTrace(groupID, callID)
+			}
+		}
+	}
+
+	if t != nil {
+		t.record(newSignalingTraceEntry(direction, connID, cm))
+	}
+}
+
+func newSignalingTraceEntry(direction, connID string, cm ClientMessage) SignalingTraceEntry {
+	entry := SignalingTraceEntry{
+		Timestamp: time.Now().UnixMilli(),
+		Direction: direction,
+		ConnID:    connID,
+		Type:      cm.Type,
+	}
+
+	switch data := cm.Data.(type) {
+	case rtc.Message:
+		entry.RTC = &SignalingTraceRTCMessage{
+			GroupID:   data.GroupID,
+			UserID:    data.UserID,
+			SessionID: data.SessionID,
+			Type:      data.Type,
+			Data:      redactRTCData(data.Type, data.Data),
+			Seq:       data.Seq,
+		}
+	case map[string]string:
+		entry.Data = make(map[string]string, len(data))
+		for k, v := range data {
+			entry.Data[k] = v
+			for _, field := range sensitiveSignalingFields {
+				if strings.Contains(strings.ToLower(k), field) {
+					entry.Data[k] = signalingTraceRedacted
+					break
+				}
+			}
+		}
+	}
+
+	return entry
+}
+
+// redactRTCData strips the ICE passwords and network addresses from the
+// payload of SDP and ICE messages.
+func redactRTCData(msgType rtc.MessageType, data []byte) string {
+	if msgType != rtc.SDPMessage && msgType != rtc.ICEMessage {
+		return string(data)
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		// Better not recording something than leaking it.
+		return signalingTraceRedacted
+	}
+
+	if sdp, ok := payload["sdp"].(string); ok {
+		payload["sdp"] = redactSDP(sdp)
+	}
+	if candidate, ok := payload["candidate"].(map[string]interface{}); ok {
+		if c, ok := candidate["candidate"].(string); ok {
+			candidate["candidate"] = redactCandidate(c)
+		}
+	}
+
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return signalingTraceRedacted
+	}
+	return string(js)
+}
+
+func redactSDP(sdp string) string {
+	lines := strings.Split(sdp, "\n")
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case strings.HasPrefix(line, "a=candidate:"):
+			line = "a=" + redactCandidate(strings.TrimPrefix(line, "a="))
+		case strings.HasPrefix(line, "a=ice-pwd:"):
+			line = "a=ice-pwd:" + signalingTraceRedacted
+		case strings.HasPrefix(line, "c="), strings.HasPrefix(line, "o="), strings.HasPrefix(line, "a=rtcp:"):
+			// The address is the last field.
+			fields := strings.Fields(line)
+			if len(fields) > 1 {
+				fields[len(fields)-1] = redactAddress(fields[len(fields)-1])
+				line = strings.Join(fields, " ")
+			}
+		}
+		if strings.HasSuffix(lines[i], "\r") {
+			line += "\r"
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// redactCandidate replaces the connection and related addresses of the
+// given candidate attribute.
+func redactCandidate(candidate string) string {
+	fields := strings.Fields(candidate)
+	if len(fields) > 4 {
+		fields[4] = redactAddress(fields[4])
+	}
+	for i := 5; i < len(fields)-1; i++ {
+		if fields[i] == "raddr" {
+			fields[i+1] = redactAddress(fields[i+1])
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+func redactAddress(addr string) string {
+	if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+		return signalingTraceRedactedIPv6
+	}
+	return signalingTraceRedactedIPv4
+}
+
+// handleSignalingTrace starts (POST) or stops (DELETE) the recording of the
+// signaling messages of the requested call.
+func (s *Service) handleSignalingTrace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleSignalingTrace", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	if s.checkIdempotencyKey("handleSignalingTrace", data, w, r) {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data.reqData); err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	groupID := data.reqData["groupID"]
+	callID := data.reqData["callID"]
+
+	if r.Method == http.MethodDelete {
+		t := s.getSignalingTrace(groupID, callID)
+		if t == nil {
+			data.err = "signaling trace not found"
+			data.code = http.StatusBadRequest
+			return
+		}
+		info := s.stopSignalingTrace(t, "stopped")
+		data.code = http.StatusOK
+		data.resData["path"] = info.Path
+		data.resData["size"] = strconv.FormatInt(info.Size, 10)
+		data.resData["messages"] = strconv.Itoa(info.Messages)
+		return
+	}
+
+	var duration time.Duration
+	if val := data.reqData["durationSeconds"]; val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil || seconds < 0 {
+			data.err = "invalid durationSeconds value"
+			data.code = http.StatusBadRequest
+			return
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+
+	path, err := s.startSignalingTrace(groupID, callID, duration)
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	data.code = http.StatusOK
+	data.resData["path"] = path
+}
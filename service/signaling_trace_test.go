@@ -0,0 +1,259 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactRTCData(t *testing.T) {
+	t.Run("sdp", func(t *testing.T) {
+		sdp := "v=0\r\n" +
+			"o=- 4215 2 IN IP4 10.0.0.5\r\n" +
+			"c=IN IP6 fd00::5\r\n" +
+			"a=ice-ufrag:ufrag\r\n" +
+			"a=ice-pwd:secretpasswordsecretpassword\r\n" +
+			"a=rtcp:9 IN IP4 10.0.0.5\r\n" +
+			"a=candidate:1 1 udp 2130706431 10.0.0.5 50000 typ host\r\n" +
+			"a=candidate:2 1 udp 1694498815 203.0.113.10 50001 typ srflx raddr 10.0.0.5 rport 50000\r\n" +
+			"a=candidate:3 1 udp 2130706431 8f3c0a3e-cf6d-4c3b-a3f3-fd2e5a0b2a1c.local 50002 typ host\r\n"
+		data, err := json.Marshal(map[string]string{"type": "offer", "sdp": sdp})
+		require.NoError(t, err)
+
+		var payload map[string]string
+		require.NoError(t, json.Unmarshal([]byte(redactRTCData(rtc.SDPMessage, data)), &payload))
+		require.Equal(t, "offer", payload["type"])
+		require.Equal(t, "v=0\r\n"+
+			"o=- 4215 2 IN IP4 192.0.2.1\r\n"+
+			"c=IN IP6 2001:db8::1\r\n"+
+			"a=ice-ufrag:ufrag\r\n"+
+			"a=ice-pwd:redacted-redacted-redacted\r\n"+
+			"a=rtcp:9 IN IP4 192.0.2.1\r\n"+
+			"a=candidate:1 1 udp 2130706431 192.0.2.1 50000 typ host\r\n"+
+			"a=candidate:2 1 udp 1694498815 192.0.2.1 50001 typ srflx raddr 192.0.2.1 rport 50000\r\n"+
+			"a=candidate:3 1 udp 2130706431 192.0.2.1 50002 typ host\r\n", payload["sdp"])
+	})
+
+	t.Run("ice", func(t *testing.T) {
+		data := []byte(`{"type":"candidate","candidate":{"candidate":"candidate:1 1 udp 2130706431 fe80::1 50000 typ host","sdpMid":"0"}}`)
+		require.JSONEq(t,
+			`{"type":"candidate","candidate":{"candidate":"candidate:1 1 udp 2130706431 2001:db8::1 50000 typ host","sdpMid":"0"}}`,
+			redactRTCData(rtc.ICEMessage, data))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		require.Equal(t, signalingTraceRedacted, redactRTCData(rtc.SDPMessage, []byte("v=0")))
+	})
+
+	t.Run("other", func(t *testing.T) {
+		require.Equal(t, `{"trackID":"trackA"}`, redactRTCData(rtc.TrackPauseMessage, []byte(`{"trackID":"trackA"}`)))
+	})
+}
+
+func TestSignalingTraceEntry(t *testing.T) {
+	cm := *NewClientMessage(ClientMessageJoin, map[string]string{
+		"callID":    "callID",
+		"sessionID": "sessionID",
+		"token":     "joinToken",
+		"authKey":   "authKey",
+	})
+	entry := newSignalingTraceEntry(SignalingTraceDirectionIn, "connID", cm)
+	require.Equal(t, SignalingTraceDirectionIn, entry.Direction)
+	require.Equal(t, "connID", entry.ConnID)
+	require.Equal(t, ClientMessageJoin, entry.Type)
+	require.Equal(t, map[string]string{
+		"callID":    "callID",
+		"sessionID": "sessionID",
+		"token":     signalingTraceRedacted,
+		"authKey":   signalingTraceRedacted,
+	}, entry.Data)
+	require.Nil(t, entry.RTC)
+	// The message itself is left untouched.
+	require.Equal(t, "joinToken", cm.Data.(map[string]string)["token"])
+
+	rtcMsg := rtc.Message{
+		GroupID:   "groupID",
+		UserID:    "userID",
+		SessionID: "sessionID",
+		Type:      rtc.MuteMessage,
+		Data:      []byte(`{}`),
+		Seq:       4,
+	}
+	rtcEntry := newSignalingTraceEntry(SignalingTraceDirectionOut, "connID", ClientMessage{Type: ClientMessageRTC, Data: rtcMsg})
+	require.NotNil(t, rtcEntry.RTC)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	require.NoError(t, enc.Encode(entry))
+	require.NoError(t, enc.Encode(rtcEntry))
+
+	entries, err := ReadSignalingTrace(&buf)
+	require.NoError(t, err)
+	require.Equal(t, []SignalingTraceEntry{entry, rtcEntry}, entries)
+	require.Equal(t, ClientMessage{Type: ClientMessageJoin, Data: entry.Data}, entries[0].ClientMessage())
+	require.Equal(t, ClientMessage{Type: ClientMessageRTC, Data: rtcMsg}, entries[1].ClientMessage())
+
+	_, err = ReadSignalingTrace(strings.NewReader("{}\n{"))
+	require.EqualError(t, err, "failed to decode entry 2: unexpected EOF")
+}
+
+func TestSignalingTraceHandler(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.API.SignalingTrace.Dir = t.TempDir()
+	cfg.API.SignalingTrace.MaxSizeMB = 1
+	cfg.API.SignalingTrace.MaxDurationSeconds = 60
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	doRequest := func(t *testing.T, method string, reqData map[string]string) (int, map[string]string) {
+		t.Helper()
+		body, err := json.Marshal(reqData)
+		require.NoError(t, err)
+		req, err := http.NewRequest(method, th.apiURL+"/admin/signaling_trace", bytes.NewReader(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		return resp.StatusCode, response
+	}
+
+	clientID := "clientA"
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	require.NoError(t, th.adminClient.Register(clientID, authKey))
+	c, err := NewClient(ClientConfig{
+		URL:      th.apiURL,
+		ClientID: clientID,
+		AuthKey:  authKey,
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.Connect())
+	defer c.Close()
+	msg := <-c.ReceiveCh()
+	require.Equal(t, ClientMessageHello, msg.Type)
+	require.Eventually(t, func() bool {
+		th.srvc.mut.RLock()
+		defer th.srvc.mut.RUnlock()
+		return len(th.srvc.connProtocols) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	join := func(t *testing.T, sessionID string) {
+		t.Helper()
+		err := c.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+			"callID":    "callID",
+			"userID":    sessionID,
+			"sessionID": sessionID,
+			"token":     "joinToken",
+		}))
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			th.srvc.mut.RLock()
+			defer th.srvc.mut.RUnlock()
+			return th.srvc.connMap[sessionID] != ""
+		}, time.Second, 10*time.Millisecond)
+	}
+
+	t.Run("not enabled", func(t *testing.T) {
+		th.srvc.cfg.API.SignalingTrace.Dir = ""
+		defer func() {
+			th.srvc.cfg.API.SignalingTrace.Dir = cfg.API.SignalingTrace.Dir
+		}()
+		code, response := doRequest(t, http.MethodPost, map[string]string{"groupID": clientID, "callID": "callID"})
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "signaling trace is not enabled", response["error"])
+	})
+
+	t.Run("call not found", func(t *testing.T) {
+		code, response := doRequest(t, http.MethodPost, map[string]string{"groupID": clientID, "callID": "callID"})
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "group not found: clientA", response["error"])
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		code, response := doRequest(t, http.MethodDelete, map[string]string{"groupID": clientID, "callID": "callID"})
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "signaling trace not found", response["error"])
+	})
+
+	t.Run("invalid durationSeconds", func(t *testing.T) {
+		code, response := doRequest(t, http.MethodPost, map[string]string{"groupID": clientID, "callID": "callID", "durationSeconds": "-1"})
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "invalid durationSeconds value", response["error"])
+	})
+
+	t.Run("recorded", func(t *testing.T) {
+		join(t, "sessionA")
+
+		code, response := doRequest(t, http.MethodPost, map[string]string{"groupID": clientID, "callID": "callID"})
+		require.Equal(t, http.StatusOK, code)
+		path := response["path"]
+		require.NotEmpty(t, path)
+
+		code, response = doRequest(t, http.MethodPost, map[string]string{"groupID": clientID, "callID": "callID"})
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "signaling trace already started", response["error"])
+
+		// Session of another call.
+		err := c.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+			"callID":    "callB",
+			"userID":    "userB",
+			"sessionID": "sessionOther",
+		}))
+		require.NoError(t, err)
+
+		join(t, "sessionB")
+		err = c.Send(*NewClientMessage(ClientMessageLeave, map[string]string{"sessionID": "sessionA"}))
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			th.srvc.mut.RLock()
+			defer th.srvc.mut.RUnlock()
+			return th.srvc.connMap["sessionA"] == ""
+		}, time.Second, 10*time.Millisecond)
+
+		code, response = doRequest(t, http.MethodDelete, map[string]string{"groupID": clientID, "callID": "callID"})
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, path, response["path"])
+		require.Equal(t, "3", response["messages"])
+
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		info, err := f.Stat()
+		require.NoError(t, err)
+		require.Equal(t, strconv.FormatInt(info.Size(), 10), response["size"])
+		entries, err := ReadSignalingTrace(f)
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+
+		require.Equal(t, SignalingTraceDirectionIn, entries[0].Direction)
+		require.Equal(t, ClientMessageJoin, entries[0].Type)
+		require.Equal(t, "sessionB", entries[0].Data["sessionID"])
+		require.Equal(t, signalingTraceRedacted, entries[0].Data["token"])
+
+		require.Equal(t, SignalingTraceDirectionIn, entries[1].Direction)
+		require.Equal(t, ClientMessageLeave, entries[1].Type)
+		require.Equal(t, "sessionA", entries[1].Data["sessionID"])
+
+		require.Equal(t, SignalingTraceDirectionOut, entries[2].Direction)
+		require.Equal(t, ClientMessageClose, entries[2].Type)
+		require.Equal(t, "sessionA", entries[2].Data["sessionID"])
+		require.Equal(t, rtc.CloseReasonLeft, entries[2].Data["reason"])
+	})
+}
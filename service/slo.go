@@ -0,0 +1,63 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// sloDefaultWindowMinutes is the window SLO indicators are computed over
+// when none is requested.
+const sloDefaultWindowMinutes = 60
+
+// handleSLO reports the SLO indicators of the node over a rolling window.
+func (s *Service) handleSLO(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleSLO", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	windowMinutes := sloDefaultWindowMinutes
+	if val := r.URL.Query().Get("windowMinutes"); val != "" {
+		data.reqData["windowMinutes"] = val
+		var err error
+		if windowMinutes, err = strconv.Atoi(val); err != nil {
+			data.err = "invalid windowMinutes value"
+			data.code = http.StatusBadRequest
+			return
+		}
+	}
+
+	report, err := s.rtcServer.GetSLOReport(windowMinutes)
+	if err != nil {
+		data.err = err.Error()
+		data.code = http.StatusBadRequest
+		return
+	}
+
+	js, err := json.Marshal(report)
+	if err != nil {
+		data.err = "failed to marshal SLO report: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	data.code = http.StatusOK
+	data.resData["slo"] = string(js)
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSLOHandler(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	getSLO := func(t *testing.T, query string) (int, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/slo"+query, nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp.StatusCode, response
+	}
+
+	t.Run("invalid method", func(t *testing.T) {
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/slo", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/slo", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("invalid window", func(t *testing.T) {
+		code, response := getSLO(t, "?windowMinutes=abc")
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "invalid windowMinutes value", response["error"])
+
+		code, response = getSLO(t, "?windowMinutes=1441")
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "invalid window: should be between 1 and 1440 minutes", response["error"])
+	})
+
+	t.Run("report", func(t *testing.T) {
+		code, response := getSLO(t, "")
+		require.Equal(t, http.StatusOK, code)
+		var report rtc.SLOReport
+		require.NoError(t, json.Unmarshal([]byte(response["slo"]), &report))
+		require.Equal(t, sloDefaultWindowMinutes, report.WindowMinutes)
+		require.Zero(t, report.Joins)
+		require.Equal(t, 1.0, report.JoinSuccessRate)
+
+		code, response = getSLO(t, "?windowMinutes=5")
+		require.Equal(t, http.StatusOK, code)
+		require.NoError(t, json.Unmarshal([]byte(response["slo"]), &report))
+		require.Equal(t, 5, report.WindowMinutes)
+	})
+}
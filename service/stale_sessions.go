@@ -0,0 +1,91 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// closeReasonSignalingLost is used when closing a session because the
+// signaling connection it originated from went away and wasn't resumed in
+// time.
+const closeReasonSignalingLost = "signaling_lost"
+
+// scheduleStaleSessionsCleanup schedules the closing of the sessions that
+// originated from the given connection, unless their client resumes them by
+// reconnecting within the configured timeout.
+func (s *Service) scheduleStaleSessionsCleanup(connID, clientID string) {
+	timeout := time.Duration(s.cfg.API.StaleSessionTimeoutSeconds) * time.Second
+	if timeout == 0 {
+		return
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if len(s.getConnSessions(connID)) == 0 {
+		return
+	}
+	s.lostConns[connID] = time.AfterFunc(timeout, func() {
+		s.closeStaleSessions(connID, clientID)
+	})
+}
+
+// getConnSessions returns the IDs of the sessions currently bound to the
+// given connection. Must be called with s.mut held.
+func (s *Service) getConnSessions(connID string) []string {
+	var sessionIDs []string
+	for sessionID, id := range s.connMap {
+		if id == connID {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+	}
+	return sessionIDs
+}
+
+// closeStaleSessions closes the sessions still bound to the lost connection,
+// ending the calls left without participants along with them. Sessions
+// resumed on another connection are bound to it and thus left untouched.
+func (s *Service) closeStaleSessions(connID, clientID string) {
+	s.mut.Lock()
+	if _, ok := s.lostConns[connID]; !ok {
+		// Cancelled on shutdown.
+		s.mut.Unlock()
+		return
+	}
+	delete(s.lostConns, connID)
+	sessionIDs := s.getConnSessions(connID)
+	s.mut.Unlock()
+
+	if len(sessionIDs) == 0 {
+		return
+	}
+
+	s.log.Warn("closing sessions of lost signaling connection",
+		mlog.String("connID", connID),
+		mlog.String("clientID", clientID),
+		mlog.Int("sessions", len(sessionIDs)))
+
+	for _, sessionID := range sessionIDs {
+		s.metrics.IncWSStaleSessionCloses(clientID)
+		if s.leaveP2PCall(sessionID, closeReasonSignalingLost) {
+			continue
+		}
+		if err := s.rtcServer.CloseSessionWithReason(sessionID, closeReasonSignalingLost); err != nil {
+			s.log.Error("failed to close stale session", mlog.Err(err), mlog.String("sessionID", sessionID))
+		}
+	}
+}
+
+// stopStaleSessionsCleanups cancels the pending cleanups, the sessions being
+// closed on shutdown anyway.
+func (s *Service) stopStaleSessionsCleanups() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	for connID, timer := range s.lostConns {
+		timer.Stop()
+		delete(s.lostConns, connID)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/random"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaleSessionsCleanup(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	// The timeout never expires during the test, which runs the cleanup
+	// itself once the sessions are in the expected state.
+	cfg.API.StaleSessionTimeoutSeconds = 3600
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	clientID := "clientA"
+	authKey, err := random.NewSecureString(auth.MinKeyLen)
+	require.NoError(t, err)
+	err = th.adminClient.Register(clientID, authKey)
+	require.NoError(t, err)
+
+	connect := func(t *testing.T) *Client {
+		t.Helper()
+		c, err := NewClient(ClientConfig{
+			URL:          th.apiURL,
+			ClientID:     clientID,
+			AuthKey:      authKey,
+			Capabilities: []string{CapabilityCodecOpus},
+		})
+		require.NoError(t, err)
+		require.NoError(t, c.Connect())
+		msg, ok := <-c.ReceiveCh()
+		require.True(t, ok)
+		require.Equal(t, ClientMessageHello, msg.Type)
+		return c
+	}
+
+	getSessions := func(callID string) []string {
+		state, err := th.srvc.rtcServer.GetCallState(clientID, callID)
+		if err != nil {
+			return nil
+		}
+		var sessionIDs []string
+		for _, ss := range state.Sessions {
+			sessionIDs = append(sessionIDs, ss.SessionID)
+		}
+		return sessionIDs
+	}
+
+	lostClient := connect(t)
+	for _, sessionID := range []string{"sessionA", "sessionB"} {
+		err := lostClient.Send(*NewClientMessage(ClientMessageJoin, map[string]string{
+			"callID":    "callA",
+			"userID":    "user" + sessionID,
+			"sessionID": sessionID,
+		}))
+		require.NoError(t, err)
+	}
+	require.Eventually(t, func() bool {
+		return len(getSessions("callA")) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, lostClient.Close())
+	var lostConnID string
+	require.Eventually(t, func() bool {
+		th.srvc.mut.RLock()
+		defer th.srvc.mut.RUnlock()
+		for connID, timer := range th.srvc.lostConns {
+			timer.Stop()
+			lostConnID = connID
+		}
+		return len(th.srvc.lostConns) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// sessionB gets resumed on a new connection within the timeout.
+	c := connect(t)
+	defer c.Close()
+	err = c.Send(*NewClientMessage(ClientMessageReconnect, map[string]string{
+		"sessionID": "sessionB",
+	}))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		th.srvc.mut.RLock()
+		defer th.srvc.mut.RUnlock()
+		connID, ok := th.srvc.connMap["sessionB"]
+		return ok && connID != lostConnID
+	}, 4*time.Second, 10*time.Millisecond)
+
+	th.srvc.closeStaleSessions(lostConnID, clientID)
+
+	require.Eventually(t, func() bool {
+		sessionIDs := getSessions("callA")
+		return len(sessionIDs) == 1 && sessionIDs[0] == "sessionB"
+	}, 4*time.Second, 50*time.Millisecond)
+
+	th.srvc.mut.RLock()
+	require.Empty(t, th.srvc.lostConns)
+	require.NotContains(t, th.srvc.connMap, "sessionA")
+	th.srvc.mut.RUnlock()
+
+	t.Run("no sessions", func(t *testing.T) {
+		idleClient := connect(t)
+		require.NoError(t, idleClient.Close())
+		time.Sleep(100 * time.Millisecond)
+		th.srvc.mut.RLock()
+		defer th.srvc.mut.RUnlock()
+		require.Empty(t, th.srvc.lostConns)
+	})
+}
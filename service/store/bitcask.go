@@ -12,21 +12,61 @@ import (
 )
 
 type bitcaskStore struct {
-	db  *bitcask.Bitcask
-	mut sync.RWMutex
+	db *bitcask.Bitcask
+	// maxSize is the size, in bytes, past which writes are rejected. Zero
+	// means unlimited.
+	maxSize int64
+	mut     sync.RWMutex
 }
 
-func newBitcaskStore(path string) (*bitcaskStore, error) {
+func newBitcaskStore(path string, opts ...Option) (*bitcaskStore, error) {
+	s := &bitcaskStore{}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
 	db, err := bitcask.Open(path,
 		bitcask.WithDirFileModeBeforeUmask(0700),
 		bitcask.WithFileFileModeBeforeUmask(0600))
 	if err != nil {
 		return nil, err
 	}
+	s.db = db
 
-	return &bitcaskStore{
-		db: db,
-	}, nil
+	return s, nil
+}
+
+// checkSize returns ErrFull if writing an entry of the given size would take
+// the store past its max size, compacting it first if that can make room.
+// Must be called with s.mut held.
+func (s *bitcaskStore) checkSize(entrySize int) error {
+	if s.maxSize == 0 {
+		return nil
+	}
+
+	stats, err := s.db.Stats()
+	if err != nil {
+		return fmt.Errorf("failed to get stats: %w", err)
+	}
+	if stats.Size+int64(entrySize) <= s.maxSize {
+		return nil
+	}
+
+	if s.db.Reclaimable() > 0 {
+		if err := s.db.Merge(); err != nil {
+			return fmt.Errorf("failed to compact store: %w", err)
+		}
+		if stats, err = s.db.Stats(); err != nil {
+			return fmt.Errorf("failed to get stats: %w", err)
+		}
+		if stats.Size+int64(entrySize) <= s.maxSize {
+			return nil
+		}
+	}
+
+	return ErrFull
 }
 
 func (s *bitcaskStore) Set(key, value string) error {
@@ -37,6 +77,10 @@ func (s *bitcaskStore) Set(key, value string) error {
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
+	if err := s.checkSize(len(key) + len(value)); err != nil {
+		return err
+	}
+
 	err := s.db.Put([]byte(key), []byte(value))
 	if err != nil {
 		return fmt.Errorf("failed to set key: %w", err)
@@ -61,6 +105,10 @@ func (s *bitcaskStore) Put(key, value string) error {
 		return ErrConflict
 	}
 
+	if err := s.checkSize(len(key) + len(value)); err != nil {
+		return err
+	}
+
 	err := s.db.Put([]byte(key), []byte(value))
 	if err != nil {
 		return fmt.Errorf("failed to set key: %w", err)
@@ -110,6 +158,45 @@ func (s *bitcaskStore) Delete(key string) error {
 	return nil
 }
 
+func (s *bitcaskStore) Keys() ([]string, error) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	keys := make([]string, 0, s.db.Len())
+	for key := range s.db.Keys() {
+		keys = append(keys, string(key))
+	}
+
+	return keys, nil
+}
+
+func (s *bitcaskStore) Stats() (Stats, error) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	stats, err := s.db.Stats()
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to get stats: %w", err)
+	}
+
+	return Stats{
+		SizeBytes:        stats.Size,
+		ReclaimableBytes: s.db.Reclaimable(),
+		Keys:             stats.Keys,
+	}, nil
+}
+
+func (s *bitcaskStore) Compact() error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if err := s.db.Merge(); err != nil {
+		return fmt.Errorf("failed to merge datafiles: %w", err)
+	}
+
+	return nil
+}
+
 func (s *bitcaskStore) Close() error {
 	s.mut.Lock()
 	defer s.mut.Unlock()
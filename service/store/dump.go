@@ -0,0 +1,103 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DumpVersion is the version of the export format.
+const DumpVersion = 1
+
+// Dump is the portable representation of the store content.
+type Dump struct {
+	Version int         `json:"version"`
+	Entries []DumpEntry `json:"entries"`
+}
+
+type DumpEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// IsRegistrationKey returns whether key holds a client registration, the
+// only entries exported and imported: the internal state of the service,
+// secrets included, never leaves the store.
+func IsRegistrationKey(key string) bool {
+	return !strings.HasPrefix(key, InternalKeyPrefix)
+}
+
+// Export returns a dump of the client registrations in the store.
+func Export(s Store) (Dump, error) {
+	keys, err := s.Keys()
+	if err != nil {
+		return Dump{}, fmt.Errorf("failed to get keys: %w", err)
+	}
+	sort.Strings(keys)
+
+	dump := Dump{
+		Version: DumpVersion,
+		Entries: make([]DumpEntry, 0, len(keys)),
+	}
+	for _, key := range keys {
+		if !IsRegistrationKey(key) {
+			continue
+		}
+		value, err := s.Get(key)
+		if err != nil {
+			return Dump{}, fmt.Errorf("failed to get value for key %q: %w", key, err)
+		}
+		dump.Entries = append(dump.Entries, DumpEntry{Key: key, Value: value})
+	}
+
+	return dump, nil
+}
+
+// Import writes the client registrations in dump to the store, overwriting
+// any existing value for the same key. Dumps holding other entries are
+// rejected as a whole. It returns the number of imported entries.
+func Import(s Store, dump Dump) (int, error) {
+	if dump.Version != DumpVersion {
+		return 0, fmt.Errorf("unsupported dump version %d", dump.Version)
+	}
+
+	for _, entry := range dump.Entries {
+		if !IsRegistrationKey(entry.Key) {
+			return 0, fmt.Errorf("invalid key %q: not a client registration", entry.Key)
+		}
+	}
+
+	var n int
+	for _, entry := range dump.Entries {
+		if err := s.Set(entry.Key, entry.Value); err != nil {
+			return n, fmt.Errorf("failed to import key %q: %w", entry.Key, err)
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// WriteDump encodes dump as JSON to w.
+func WriteDump(w io.Writer, dump Dump) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(dump); err != nil {
+		return fmt.Errorf("failed to encode dump: %w", err)
+	}
+	return nil
+}
+
+// ReadDump decodes a JSON encoded dump from r.
+func ReadDump(r io.Reader) (Dump, error) {
+	var dump Dump
+	if err := json.NewDecoder(r).Decode(&dump); err != nil {
+		return Dump{}, fmt.Errorf("failed to decode dump: %w", err)
+	}
+	return dump, nil
+}
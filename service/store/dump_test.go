@@ -0,0 +1,92 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package store
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T) Store {
+	t.Helper()
+	dbDir, err := os.MkdirTemp("", "db")
+	require.NoError(t, err)
+	store, err := New(dbDir)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, store.Close())
+		require.NoError(t, os.RemoveAll(dbDir))
+	})
+	return store
+}
+
+func TestExportImport(t *testing.T) {
+	src := newTestStore(t)
+	dst := newTestStore(t)
+
+	t.Run("empty", func(t *testing.T) {
+		dump, err := Export(src)
+		require.NoError(t, err)
+		require.Equal(t, DumpVersion, dump.Version)
+		require.Empty(t, dump.Entries)
+	})
+
+	require.NoError(t, src.Set("keyB", "valueB"))
+	require.NoError(t, src.Set("keyA", "valueA"))
+	require.NoError(t, src.Set(InternalKeyPrefix+"internal", "value"))
+	require.NoError(t, src.Set(SchemaVersionKey, "1"))
+	require.NoError(t, dst.Set("keyA", "oldValue"))
+
+	t.Run("roundtrip", func(t *testing.T) {
+		dump, err := Export(src)
+		require.NoError(t, err)
+		require.Equal(t, []DumpEntry{
+			{Key: "keyA", Value: "valueA"},
+			{Key: "keyB", Value: "valueB"},
+		}, dump.Entries)
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteDump(&buf, dump))
+		decoded, err := ReadDump(&buf)
+		require.NoError(t, err)
+		require.Equal(t, dump, decoded)
+
+		n, err := Import(dst, decoded)
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+
+		val, err := dst.Get("keyA")
+		require.NoError(t, err)
+		require.Equal(t, "valueA", val)
+		val, err = dst.Get("keyB")
+		require.NoError(t, err)
+		require.Equal(t, "valueB", val)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		n, err := Import(dst, Dump{Version: 0})
+		require.EqualError(t, err, "unsupported dump version 0")
+		require.Zero(t, n)
+	})
+
+	t.Run("internal key", func(t *testing.T) {
+		n, err := Import(dst, Dump{Version: DumpVersion, Entries: []DumpEntry{
+			{Key: "keyC", Value: "valueC"},
+			{Key: InternalKeyPrefix + "internal", Value: "value"},
+		}})
+		require.EqualError(t, err, `invalid key "rtcd:internal": not a client registration`)
+		require.Zero(t, n)
+		_, err = dst.Get("keyC")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("empty key", func(t *testing.T) {
+		n, err := Import(dst, Dump{Version: DumpVersion, Entries: []DumpEntry{{Key: "keyC", Value: "valueC"}, {}}})
+		require.ErrorIs(t, err, ErrEmptyKey)
+		require.Equal(t, 1, n)
+	})
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MasterKeyLen is the required length in bytes of the master key.
+const MasterKeyLen = 32
+
+// encryptedPrefix marks values encrypted by encryptedStore.
+const encryptedPrefix = "enc:v1:"
+
+// encryptionMigratedKey is set once the values written before encryption was
+// enabled have been encrypted.
+const encryptionMigratedKey = InternalKeyPrefix + "encryption_migrated"
+
+// encryptedStore wraps a Store encrypting all the values at rest. Every value
+// is encrypted with its own random data key which in turn is encrypted
+// (wrapped) with the master key. Both are bound to the key of the value so
+// that they can't be moved to another one.
+type encryptedStore struct {
+	Store
+	master cipher.AEAD
+}
+
+// NewEncrypted returns a Store that encrypts values written to (and decrypts
+// values read from) the given store using masterKey. The first time
+// encryption is enabled, the values already in the store get encrypted.
+// Plaintext values are rejected afterwards.
+func NewEncrypted(s Store, masterKey []byte) (Store, error) {
+	if s == nil {
+		return nil, errors.New("invalid store")
+	}
+	master, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	encSt := &encryptedStore{
+		Store:  s,
+		master: master,
+	}
+	if err := encSt.migrate(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt existing values: %w", err)
+	}
+	return encSt, nil
+}
+
+// migrate encrypts the plaintext values, unless done already.
+func (s *encryptedStore) migrate() error {
+	if _, err := s.Store.Get(encryptionMigratedKey); err == nil {
+		return nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	keys, err := s.Store.Keys()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		value, err := s.Store.Get(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if strings.HasPrefix(value, encryptedPrefix) {
+			continue
+		}
+		if err := s.Set(key, value); err != nil {
+			return fmt.Errorf("failed to encrypt %q: %w", key, err)
+		}
+	}
+
+	return s.Set(encryptionMigratedKey, "true")
+}
+
+// ParseMasterKey decodes a base64 encoded master key.
+func ParseMasterKey(key string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key: %w", err)
+	}
+	if len(data) != MasterKeyLen {
+		return nil, fmt.Errorf("key should be %d bytes long", MasterKeyLen)
+	}
+	return data, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != MasterKeyLen {
+		return nil, fmt.Errorf("key should be %d bytes long", MasterKeyLen)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts and authenticates data, along with the additional data ad,
+// using aead, prepending the random nonce.
+func seal(aead cipher.AEAD, data, ad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, data, ad), nil
+}
+
+// open decrypts data previously encrypted with seal, with the same
+// additional data.
+func open(aead cipher.AEAD, data, ad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], ad)
+}
+
+func (s *encryptedStore) encrypt(key, value string) (string, error) {
+	dataKey := make([]byte, MasterKeyLen)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrappedKey, err := seal(s.master, dataKey, []byte(key))
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(aead, []byte(value), []byte(key))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt value: %w", err)
+	}
+
+	return encryptedPrefix + base64.RawStdEncoding.EncodeToString(wrappedKey) + "." +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+func (s *encryptedStore) decrypt(key, value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return "", errors.New("value is not encrypted")
+	}
+
+	encWrappedKey, encCiphertext, ok := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ".")
+	if !ok {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(encWrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode data key: %w", err)
+	}
+	dataKey, err := open(s.master, wrappedKey, []byte(key))
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	ciphertext, err := base64.RawStdEncoding.DecodeString(encCiphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode value: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := open(aead, ciphertext, []byte(key))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+
+	return string(data), nil
+}
+
+func (s *encryptedStore) Set(key, value string) error {
+	encValue, err := s.encrypt(key, value)
+	if err != nil {
+		return err
+	}
+	return s.Store.Set(key, encValue)
+}
+
+func (s *encryptedStore) Put(key, value string) error {
+	encValue, err := s.encrypt(key, value)
+	if err != nil {
+		return err
+	}
+	return s.Store.Put(key, encValue)
+}
+
+func (s *encryptedStore) Get(key string) (string, error) {
+	value, err := s.Store.Get(key)
+	if err != nil {
+		return "", err
+	}
+	return s.decrypt(key, value)
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMasterKey(t *testing.T) {
+	key, err := ParseMasterKey("invalid")
+	require.Error(t, err)
+	require.Nil(t, key)
+
+	key, err = ParseMasterKey(base64.StdEncoding.EncodeToString([]byte("short")))
+	require.EqualError(t, err, "key should be 32 bytes long")
+	require.Nil(t, key)
+
+	masterKey := bytes.Repeat([]byte{1}, MasterKeyLen)
+	key, err = ParseMasterKey(base64.StdEncoding.EncodeToString(masterKey))
+	require.NoError(t, err)
+	require.Equal(t, masterKey, key)
+}
+
+func TestEncryptedStore(t *testing.T) {
+	plainStore := newTestStore(t)
+	masterKey := bytes.Repeat([]byte{1}, MasterKeyLen)
+
+	t.Run("invalid args", func(t *testing.T) {
+		st, err := NewEncrypted(nil, masterKey)
+		require.EqualError(t, err, "invalid store")
+		require.Nil(t, st)
+
+		st, err = NewEncrypted(plainStore, []byte("short"))
+		require.EqualError(t, err, "invalid master key: key should be 32 bytes long")
+		require.Nil(t, st)
+	})
+
+	// Written before enabling encryption.
+	require.NoError(t, plainStore.Set("legacy", "plainValue"))
+
+	st, err := NewEncrypted(plainStore, masterKey)
+	require.NoError(t, err)
+
+	t.Run("roundtrip", func(t *testing.T) {
+		require.NoError(t, st.Set("keyA", "valueA"))
+		require.NoError(t, st.Put("keyB", "valueB"))
+		require.ErrorIs(t, st.Put("keyB", "valueB"), ErrConflict)
+
+		val, err := st.Get("keyA")
+		require.NoError(t, err)
+		require.Equal(t, "valueA", val)
+		val, err = st.Get("keyB")
+		require.NoError(t, err)
+		require.Equal(t, "valueB", val)
+
+		// Values should not be readable from the underlying store.
+		rawVal, err := plainStore.Get("keyA")
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(rawVal, encryptedPrefix))
+		require.NotContains(t, rawVal, "valueA")
+
+		// Encrypting the same value twice should give different results.
+		require.NoError(t, st.Set("keyC", "valueA"))
+		rawValC, err := plainStore.Get("keyC")
+		require.NoError(t, err)
+		require.NotEqual(t, rawVal, rawValC)
+	})
+
+	t.Run("plaintext value", func(t *testing.T) {
+		// Existing values get encrypted when enabling encryption.
+		rawVal, err := plainStore.Get("legacy")
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(rawVal, encryptedPrefix))
+		val, err := st.Get("legacy")
+		require.NoError(t, err)
+		require.Equal(t, "plainValue", val)
+
+		// Only once: plaintext values written afterwards are rejected.
+		require.NoError(t, plainStore.Set("injected", "plainValue"))
+		reopenedSt, err := NewEncrypted(plainStore, masterKey)
+		require.NoError(t, err)
+		val, err = reopenedSt.Get("injected")
+		require.EqualError(t, err, "value is not encrypted")
+		require.Empty(t, val)
+	})
+
+	t.Run("moved value", func(t *testing.T) {
+		// Values are bound to their key.
+		rawVal, err := plainStore.Get("keyA")
+		require.NoError(t, err)
+		require.NoError(t, plainStore.Set("keyD", rawVal))
+		val, err := st.Get("keyD")
+		require.EqualError(t, err, "failed to unwrap data key: cipher: message authentication failed")
+		require.Empty(t, val)
+	})
+
+	t.Run("wrong master key", func(t *testing.T) {
+		otherSt, err := NewEncrypted(plainStore, bytes.Repeat([]byte{2}, MasterKeyLen))
+		require.NoError(t, err)
+		val, err := otherSt.Get("keyA")
+		require.Error(t, err)
+		require.Empty(t, val)
+	})
+
+	t.Run("malformed value", func(t *testing.T) {
+		require.NoError(t, plainStore.Set("malformed", encryptedPrefix+"invalid"))
+		val, err := st.Get("malformed")
+		require.EqualError(t, err, "malformed encrypted value")
+		require.Empty(t, val)
+	})
+}
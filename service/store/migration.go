@@ -0,0 +1,170 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// SchemaVersionKey is the key under which the version of the schema of the
+// store content is kept. It's left out of dumps as it describes the store
+// rather than its content.
+const SchemaVersionKey = "rtcd:schema_version"
+
+// Migration changes the format of the store content from the previous
+// schema version to Version.
+type Migration struct {
+	// Version is the schema version the migration brings the store to.
+	// Versions start at 1 and follow each other.
+	Version int
+	// Description summarizes the change.
+	Description string
+	// Up migrates the content from the previous version.
+	Up func(s Store) error
+	// Down reverts Up. Migrations without Down can't be rolled back.
+	Down func(s Store) error
+}
+
+// MigrationStep is a migration applied, or to be applied, to a store.
+type MigrationStep struct {
+	Version     int
+	Description string
+	// Down is set if the migration is rolled back.
+	Down bool
+}
+
+// SchemaVersion returns the schema version of the store content. Stores
+// predating migrations are at version 0.
+func SchemaVersion(s Store) (int, error) {
+	val, err := s.Get(SchemaVersionKey)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	version, err := strconv.Atoi(val)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid schema version %q", val)
+	}
+	return version, nil
+}
+
+// Migrate brings the store schema to the target version, applying the Up
+// migrations from the current version or, if target is lower, the Down ones
+// in reverse order. With dryRun set, nothing is changed and the steps that
+// would be applied are returned. A failing migration leaves the store as it
+// was before that migration, the previous ones staying applied.
+func Migrate(s Store, migrations []Migration, target int, dryRun bool) ([]MigrationStep, error) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("invalid migration %d: version should be %d", m.Version, i+1)
+		}
+		if m.Up == nil {
+			return nil, fmt.Errorf("invalid migration %d: Up should not be nil", m.Version)
+		}
+	}
+	if target < 0 || target > len(migrations) {
+		return nil, fmt.Errorf("invalid target version %d: should be in the range [0, %d]", target, len(migrations))
+	}
+
+	current, err := SchemaVersion(s)
+	if err != nil {
+		return nil, err
+	}
+	// Content written by a newer rtcd version could get corrupted.
+	if current > len(migrations) {
+		return nil, fmt.Errorf("schema version %d is newer than the latest supported one (%d)", current, len(migrations))
+	}
+
+	var steps []MigrationStep
+	for v := current + 1; v <= target; v++ {
+		m := migrations[v-1]
+		steps = append(steps, MigrationStep{Version: m.Version, Description: m.Description})
+	}
+	for v := current; v > target; v-- {
+		m := migrations[v-1]
+		if m.Down == nil {
+			return nil, fmt.Errorf("migration %d can't be rolled back", m.Version)
+		}
+		steps = append(steps, MigrationStep{Version: m.Version, Description: m.Description, Down: true})
+	}
+
+	if dryRun {
+		return steps, nil
+	}
+
+	for i, step := range steps {
+		m := migrations[step.Version-1]
+		fn, version := m.Up, m.Version
+		if step.Down {
+			fn, version = m.Down, m.Version-1
+		}
+		if err := applyMigration(s, fn, version); err != nil {
+			return steps[:i], fmt.Errorf("failed to apply migration %d: %w", m.Version, err)
+		}
+	}
+
+	return steps, nil
+}
+
+// applyMigration runs fn and sets the schema version to version. The store
+// content is restored if it fails.
+func applyMigration(s Store, fn func(s Store) error, version int) error {
+	snapshot, err := takeSnapshot(s)
+	if err != nil {
+		return err
+	}
+
+	err = fn(s)
+	if err == nil {
+		err = s.Set(SchemaVersionKey, strconv.Itoa(version))
+	}
+	if err != nil {
+		if restoreErr := restoreSnapshot(s, snapshot); restoreErr != nil {
+			return fmt.Errorf("%w (failed to restore store: %s)", err, restoreErr.Error())
+		}
+		return err
+	}
+
+	return nil
+}
+
+func takeSnapshot(s Store) (map[string]string, error) {
+	keys, err := s.Keys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get keys: %w", err)
+	}
+	snapshot := make(map[string]string, len(keys))
+	for _, key := range keys {
+		val, err := s.Get(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get value for key %q: %w", key, err)
+		}
+		snapshot[key] = val
+	}
+	return snapshot, nil
+}
+
+func restoreSnapshot(s Store, snapshot map[string]string) error {
+	keys, err := s.Keys()
+	if err != nil {
+		return fmt.Errorf("failed to get keys: %w", err)
+	}
+	for _, key := range keys {
+		if _, ok := snapshot[key]; ok {
+			continue
+		}
+		if err := s.Delete(key); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to delete key %q: %w", key, err)
+		}
+	}
+	for key, val := range snapshot {
+		if err := s.Set(key, val); err != nil {
+			return fmt.Errorf("failed to set key %q: %w", key, err)
+		}
+	}
+	return nil
+}
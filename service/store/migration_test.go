@@ -0,0 +1,190 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package store
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	// v1 renames keyA to keyB, v2 doubles its value.
+	migrations := []Migration{
+		{
+			Version:     1,
+			Description: "rename keyA",
+			Up: func(s Store) error {
+				val, err := s.Get("keyA")
+				if err != nil {
+					return err
+				}
+				if err := s.Set("keyB", val); err != nil {
+					return err
+				}
+				return s.Delete("keyA")
+			},
+			Down: func(s Store) error {
+				val, err := s.Get("keyB")
+				if err != nil {
+					return err
+				}
+				if err := s.Set("keyA", val); err != nil {
+					return err
+				}
+				return s.Delete("keyB")
+			},
+		},
+		{
+			Version:     2,
+			Description: "double keyB",
+			Up: func(s Store) error {
+				val, err := s.Get("keyB")
+				if err != nil {
+					return err
+				}
+				return s.Set("keyB", val+val)
+			},
+			Down: func(s Store) error {
+				val, err := s.Get("keyB")
+				if err != nil {
+					return err
+				}
+				return s.Set("keyB", val[:len(val)/2])
+			},
+		},
+	}
+
+	checkVersion := func(t *testing.T, s Store, expected int) {
+		t.Helper()
+		version, err := SchemaVersion(s)
+		require.NoError(t, err)
+		require.Equal(t, expected, version)
+	}
+
+	t.Run("invalid migrations", func(t *testing.T) {
+		s := newTestStore(t)
+		_, err := Migrate(s, []Migration{{Version: 2, Up: migrations[0].Up}}, 1, false)
+		require.EqualError(t, err, "invalid migration 2: version should be 1")
+		_, err = Migrate(s, []Migration{{Version: 1}}, 1, false)
+		require.EqualError(t, err, "invalid migration 1: Up should not be nil")
+		_, err = Migrate(s, migrations, 3, false)
+		require.EqualError(t, err, "invalid target version 3: should be in the range [0, 2]")
+	})
+
+	t.Run("invalid version", func(t *testing.T) {
+		s := newTestStore(t)
+		require.NoError(t, s.Set(SchemaVersionKey, "invalid"))
+		_, err := Migrate(s, migrations, 2, false)
+		require.EqualError(t, err, `invalid schema version "invalid"`)
+	})
+
+	t.Run("newer version", func(t *testing.T) {
+		s := newTestStore(t)
+		require.NoError(t, s.Set(SchemaVersionKey, "3"))
+		_, err := Migrate(s, migrations, 2, false)
+		require.EqualError(t, err, "schema version 3 is newer than the latest supported one (2)")
+	})
+
+	t.Run("up and down", func(t *testing.T) {
+		s := newTestStore(t)
+		require.NoError(t, s.Set("keyA", "value"))
+		checkVersion(t, s, 0)
+
+		steps, err := Migrate(s, migrations, 2, true)
+		require.NoError(t, err)
+		require.Equal(t, []MigrationStep{
+			{Version: 1, Description: "rename keyA"},
+			{Version: 2, Description: "double keyB"},
+		}, steps)
+		checkVersion(t, s, 0)
+		val, err := s.Get("keyA")
+		require.NoError(t, err)
+		require.Equal(t, "value", val)
+
+		steps, err = Migrate(s, migrations, 2, false)
+		require.NoError(t, err)
+		require.Len(t, steps, 2)
+		checkVersion(t, s, 2)
+		val, err = s.Get("keyB")
+		require.NoError(t, err)
+		require.Equal(t, "valuevalue", val)
+		_, err = s.Get("keyA")
+		require.ErrorIs(t, err, ErrNotFound)
+
+		steps, err = Migrate(s, migrations, 2, false)
+		require.NoError(t, err)
+		require.Empty(t, steps)
+
+		steps, err = Migrate(s, migrations, 0, false)
+		require.NoError(t, err)
+		require.Equal(t, []MigrationStep{
+			{Version: 2, Description: "double keyB", Down: true},
+			{Version: 1, Description: "rename keyA", Down: true},
+		}, steps)
+		checkVersion(t, s, 0)
+		val, err = s.Get("keyA")
+		require.NoError(t, err)
+		require.Equal(t, "value", val)
+		_, err = s.Get("keyB")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("irreversible", func(t *testing.T) {
+		s := newTestStore(t)
+		irreversible := []Migration{{Version: 1, Up: func(_ Store) error { return nil }}}
+		_, err := Migrate(s, irreversible, 1, false)
+		require.NoError(t, err)
+		_, err = Migrate(s, irreversible, 0, true)
+		require.EqualError(t, err, "migration 1 can't be rolled back")
+		checkVersion(t, s, 1)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		s := newTestStore(t)
+		require.NoError(t, s.Set("keyA", "value"))
+
+		failing := append([]Migration{}, migrations...)
+		failing[1].Up = func(s Store) error {
+			if err := s.Set("keyB", "partial"); err != nil {
+				return err
+			}
+			if err := s.Set("keyC", "partial"); err != nil {
+				return err
+			}
+			return fmt.Errorf("failed")
+		}
+
+		steps, err := Migrate(s, failing, 2, false)
+		require.EqualError(t, err, "failed to apply migration 2: failed")
+		require.Equal(t, []MigrationStep{{Version: 1, Description: "rename keyA"}}, steps)
+		checkVersion(t, s, 1)
+		val, err := s.Get("keyB")
+		require.NoError(t, err)
+		require.Equal(t, "value", val)
+		_, err = s.Get("keyC")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestExportSchemaVersion(t *testing.T) {
+	s := newTestStore(t)
+	require.NoError(t, s.Set("keyA", "valueA"))
+	require.NoError(t, s.Set(SchemaVersionKey, "1"))
+
+	dump, err := Export(s)
+	require.NoError(t, err)
+	require.Equal(t, []DumpEntry{{Key: "keyA", Value: "valueA"}}, dump.Entries)
+
+	dst := newTestStore(t)
+	n, err := Import(dst, Dump{Version: DumpVersion, Entries: []DumpEntry{
+		{Key: "keyA", Value: "valueA"},
+		{Key: SchemaVersionKey, Value: "2"},
+	}})
+	require.Error(t, err)
+	require.Zero(t, n)
+	_, err = dst.Get(SchemaVersionKey)
+	require.ErrorIs(t, err, ErrNotFound)
+}
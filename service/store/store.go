@@ -5,22 +5,62 @@ package store
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
 	ErrNotFound = errors.New("error: not found")
 	ErrEmptyKey = errors.New("error: empty key")
 	ErrConflict = errors.New("error: conflict")
+	// ErrFull is returned by writes that would take the store past its max
+	// size.
+	ErrFull = errors.New("error: max size reached")
 )
 
+// InternalKeyPrefix is the prefix of the keys the service keeps its own
+// state under. The keys outside it are the client registrations.
+const InternalKeyPrefix = "rtcd:"
+
+// Stats describes the on-disk footprint of a store.
+type Stats struct {
+	// SizeBytes is the size of the files of the store.
+	SizeBytes int64
+	// ReclaimableBytes is the size of the overwritten and deleted entries
+	// compaction would reclaim.
+	ReclaimableBytes int64
+	Keys             int
+}
+
 type Store interface {
 	Put(key, value string) error
 	Set(key, value string) error
 	Get(key string) (string, error)
 	Delete(key string) error
+	Keys() ([]string, error)
+	// Stats returns the on-disk footprint of the store.
+	Stats() (Stats, error)
+	// Compact rewrites the store, reclaiming the space taken by overwritten
+	// and deleted entries.
+	Compact() error
 	Close() error
 }
 
-func New(dataSource string) (Store, error) {
-	return newBitcaskStore(dataSource)
+// Option configures a store.
+type Option func(s *bitcaskStore) error
+
+// WithMaxSize makes the store reject the writes that would take it past
+// maxSize bytes with ErrFull, after compacting it if that can make room.
+// Zero means unlimited.
+func WithMaxSize(maxSize int64) Option {
+	return func(s *bitcaskStore) error {
+		if maxSize < 0 {
+			return fmt.Errorf("invalid max size: should not be negative")
+		}
+		s.maxSize = maxSize
+		return nil
+	}
+}
+
+func New(dataSource string, opts ...Option) (Store, error) {
+	return newBitcaskStore(dataSource, opts...)
 }
@@ -4,7 +4,9 @@
 package store
 
 import (
+	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -30,6 +32,12 @@ func TestNew(t *testing.T) {
 		err = store.Close()
 		require.NoError(t, err)
 	})
+
+	t.Run("invalid max size", func(t *testing.T) {
+		store, err := New(dbDir, WithMaxSize(-1))
+		require.EqualError(t, err, "invalid max size: should not be negative")
+		require.Nil(t, store)
+	})
 }
 
 func TestPut(t *testing.T) {
@@ -170,3 +178,75 @@ func TestDelete(t *testing.T) {
 		require.Empty(t, val)
 	})
 }
+
+func TestCompact(t *testing.T) {
+	dbDir, err := os.MkdirTemp("", "db")
+	require.NoError(t, err)
+	defer os.RemoveAll(dbDir)
+
+	store, err := New(dbDir)
+	require.NoError(t, err)
+	defer store.Close()
+
+	value := strings.Repeat("a", 1024)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, store.Set("key", value))
+	}
+	require.NoError(t, store.Set("deleted", value))
+	require.NoError(t, store.Delete("deleted"))
+
+	stats, err := store.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.Keys)
+	require.Greater(t, stats.ReclaimableBytes, int64(100*1024))
+	require.Greater(t, stats.SizeBytes, int64(100*1024))
+
+	require.NoError(t, store.Compact())
+
+	compacted, err := store.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 1, compacted.Keys)
+	require.Zero(t, compacted.ReclaimableBytes)
+	require.Less(t, compacted.SizeBytes, stats.SizeBytes-100*1024)
+
+	val, err := store.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, value, val)
+	_, err = store.Get("deleted")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMaxSize(t *testing.T) {
+	dbDir, err := os.MkdirTemp("", "db")
+	require.NoError(t, err)
+	defer os.RemoveAll(dbDir)
+
+	store, err := New(dbDir, WithMaxSize(64*1024))
+	require.NoError(t, err)
+	defer store.Close()
+
+	value := strings.Repeat("a", 1024)
+
+	t.Run("compacting to make room", func(t *testing.T) {
+		// Overwriting the same key only takes room until compacted.
+		for i := 0; i < 200; i++ {
+			require.NoError(t, store.Set("key", value))
+		}
+		stats, err := store.Stats()
+		require.NoError(t, err)
+		require.LessOrEqual(t, stats.SizeBytes, int64(64*1024))
+	})
+
+	t.Run("full", func(t *testing.T) {
+		var err error
+		for i := 0; i < 200 && err == nil; i++ {
+			err = store.Put(fmt.Sprintf("key%d", i), value)
+		}
+		require.ErrorIs(t, err, ErrFull)
+		require.ErrorIs(t, store.Set("key", value), ErrFull)
+
+		// Deleting is still allowed, so that room can be made.
+		require.NoError(t, store.Delete("key"))
+		require.NoError(t, store.Set("key", "value"))
+	})
+}
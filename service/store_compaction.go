@@ -0,0 +1,82 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// storeStatsInterval is the interval at which the size of the store is
+// checked.
+const storeStatsInterval = time.Minute
+
+// storeSizeWarnPercent is the percentage of the max size of the store past
+// which a warning is logged.
+const storeSizeWarnPercent = 80
+
+// runStoreCompaction periodically reports the size of the store, compacting
+// it every compactionInterval. Zero disables compaction.
+func (s *Service) runStoreCompaction(compactionInterval time.Duration) {
+	defer close(s.storeDoneCh)
+
+	ticker := time.NewTicker(storeStatsInterval)
+	defer ticker.Stop()
+
+	if err := s.checkStore(false); err != nil {
+		s.log.Error("failed to check store", mlog.Err(err))
+	}
+
+	lastCompaction := time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			compact := compactionInterval > 0 && now.Sub(lastCompaction) >= compactionInterval
+			if compact {
+				lastCompaction = now
+			}
+			if err := s.checkStore(compact); err != nil {
+				s.log.Error("failed to check store", mlog.Err(err))
+			}
+		case <-s.storeStopCh:
+			return
+		}
+	}
+}
+
+// checkStore updates the size metrics of the store, compacting it first if
+// requested and there is space to reclaim.
+func (s *Service) checkStore(compact bool) error {
+	stats, err := s.store.Stats()
+	if err != nil {
+		return fmt.Errorf("failed to get store stats: %w", err)
+	}
+
+	if compact && stats.ReclaimableBytes > 0 {
+		start := time.Now()
+		if err := s.store.Compact(); err != nil {
+			return fmt.Errorf("failed to compact store: %w", err)
+		}
+		elapsed := time.Since(start)
+		s.metrics.ObserveStoreCompaction(elapsed.Seconds())
+
+		prevSize := stats.SizeBytes
+		if stats, err = s.store.Stats(); err != nil {
+			return fmt.Errorf("failed to get store stats: %w", err)
+		}
+		s.log.Info("compacted store", mlog.Int64("sizeBytes", stats.SizeBytes),
+			mlog.Int64("reclaimedBytes", prevSize-stats.SizeBytes), mlog.Duration("duration", elapsed))
+	}
+
+	s.metrics.SetStoreSize(stats.SizeBytes, stats.ReclaimableBytes)
+
+	if maxSize := int64(s.cfg.Store.MaxSizeMB) * 1024 * 1024; maxSize > 0 && stats.SizeBytes*100 >= maxSize*storeSizeWarnPercent {
+		s.log.Warn("store is close to its max size", mlog.Int64("sizeBytes", stats.SizeBytes),
+			mlog.Int64("maxSizeBytes", maxSize), mlog.Int64("reclaimableBytes", stats.ReclaimableBytes))
+	}
+
+	return nil
+}
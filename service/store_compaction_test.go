@@ -0,0 +1,53 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckStore(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	snapshot := func() string {
+		var sb strings.Builder
+		require.NoError(t, th.srvc.metrics.WriteSnapshot(&sb))
+		return sb.String()
+	}
+
+	value := strings.Repeat("a", 1024)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, th.srvc.store.Set("compaction_test", value))
+	}
+
+	t.Run("size", func(t *testing.T) {
+		require.NoError(t, th.srvc.checkStore(false))
+		stats, err := th.srvc.store.Stats()
+		require.NoError(t, err)
+		require.Greater(t, stats.ReclaimableBytes, int64(99*1024))
+		metrics := snapshot()
+		require.Contains(t, metrics, fmt.Sprintf("rtcd_store_size_bytes %d\n", stats.SizeBytes))
+		require.Contains(t, metrics, fmt.Sprintf("rtcd_store_reclaimable_bytes %d\n", stats.ReclaimableBytes))
+	})
+
+	t.Run("compaction", func(t *testing.T) {
+		require.NoError(t, th.srvc.checkStore(true))
+		stats, err := th.srvc.store.Stats()
+		require.NoError(t, err)
+		require.Zero(t, stats.ReclaimableBytes)
+		metrics := snapshot()
+		require.Contains(t, metrics, fmt.Sprintf("rtcd_store_size_bytes %d\n", stats.SizeBytes))
+		require.Contains(t, metrics, "rtcd_store_reclaimable_bytes 0\n")
+		require.Contains(t, metrics, "rtcd_store_compaction_duration_seconds_count 1\n")
+
+		val, err := th.srvc.store.Get("compaction_test")
+		require.NoError(t, err)
+		require.Equal(t, value, val)
+	})
+}
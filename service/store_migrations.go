@@ -0,0 +1,43 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"github.com/mattermost/rtcd/service/auth"
+	"github.com/mattermost/rtcd/service/store"
+)
+
+// storeMigrations lists the changes to the format of the store content, in
+// order. Any change to how registrations, certificates, keys or runtime
+// parameters are persisted needs a migration appended here, along with its
+// rollback whenever possible.
+var storeMigrations = []store.Migration{
+	{
+		Version:     1,
+		Description: "baseline: client registrations keyed by client ID, service data under the rtcd: prefix",
+		Up:          func(_ store.Store) error { return nil },
+		Down:        func(_ store.Store) error { return nil },
+	},
+	{
+		// The signing keys used to be derived for every client, whether or
+		// not signed credentials were enabled and the store encrypted.
+		Version:     2,
+		Description: "remove the signing keys of the clients, derived again on authentication once signed auth is enabled",
+		Up:          auth.DeleteSigningKeys,
+		Down:        func(_ store.Store) error { return nil },
+	},
+}
+
+// LatestStoreSchemaVersion is the store schema version this rtcd version
+// runs with.
+var LatestStoreSchemaVersion = len(storeMigrations)
+
+// MigrateStore brings the schema of st to the given version, the latest one
+// if negative. With dryRun set, the steps are returned without being applied.
+func MigrateStore(st store.Store, version int, dryRun bool) ([]store.MigrationStep, error) {
+	if version < 0 {
+		version = LatestStoreSchemaVersion
+	}
+	return store.Migrate(st, storeMigrations, version, dryRun)
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/mattermost/rtcd/service/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateStore(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	// The service migrates the store at startup.
+	version, err := store.SchemaVersion(th.srvc.store)
+	require.NoError(t, err)
+	require.Equal(t, LatestStoreSchemaVersion, version)
+
+	steps, err := MigrateStore(th.srvc.store, 0, true)
+	require.NoError(t, err)
+	require.Len(t, steps, LatestStoreSchemaVersion)
+	for _, step := range steps {
+		require.True(t, step.Down)
+	}
+
+	steps, err = MigrateStore(th.srvc.store, -1, false)
+	require.NoError(t, err)
+	require.Empty(t, steps)
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/service/random"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+)
+
+const (
+	// testCallGroupID is the group the synthetic test calls are run in.
+	testCallGroupID        = "rtcd_test_call"
+	testCallMinPackets     = 10
+	testCallDefaultTimeout = 10 * time.Second
+	testCallMaxTimeout     = time.Minute
+	testCallAudioFrameMs   = 20
+	testCallVideoFrameMs   = 100
+)
+
+var (
+	// opusSilenceFrame is a 20ms Opus frame encoding silence.
+	opusSilenceFrame = []byte{0xf8, 0xff, 0xfe}
+	// vp8TestFrame is a minimal VP8 key frame header followed by padding.
+	// The content is never decoded, only forwarded.
+	vp8TestFrame = append([]byte{0x10, 0x02, 0x00, 0x9d, 0x01, 0x2a, 0x10, 0x00, 0x10, 0x00}, make([]byte, 256)...)
+)
+
+// testCallResult holds the outcome of a synthetic test call.
+type testCallResult struct {
+	// Connect is the time it took for both peers to connect.
+	Connect time.Duration
+	// FirstAudio and FirstVideo are the times it took for the subscriber
+	// to receive the first forwarded packet of each kind.
+	FirstAudio time.Duration
+	FirstVideo time.Duration
+	// AudioPackets and VideoPackets are the number of packets received by
+	// the subscriber.
+	AudioPackets int
+	VideoPackets int
+}
+
+func (r testCallResult) toMap() map[string]string {
+	return map[string]string{
+		"connectMs":    fmt.Sprintf("%d", r.Connect.Milliseconds()),
+		"firstAudioMs": fmt.Sprintf("%d", r.FirstAudio.Milliseconds()),
+		"firstVideoMs": fmt.Sprintf("%d", r.FirstVideo.Milliseconds()),
+		"audioPackets": fmt.Sprintf("%d", r.AudioPackets),
+		"videoPackets": fmt.Sprintf("%d", r.VideoPackets),
+	}
+}
+
+// runTestCall runs a call between two in-process peers on this node: a
+// publisher sending generated audio and video and a subscriber receiving them
+// through the SFU. It verifies the whole media path (ICE, DTLS, SRTP and
+// forwarding) without the need of a real client.
+func (s *Service) runTestCall(timeout time.Duration) (testCallResult, error) {
+	var res testCallResult
+	start := time.Now()
+	deadline := time.After(timeout)
+
+	callID := random.NewID()
+	pubCfg := rtc.SessionConfig{
+		GroupID:   testCallGroupID,
+		CallID:    callID,
+		UserID:    "publisher",
+		SessionID: random.NewID(),
+	}
+	subCfg := rtc.SessionConfig{
+		GroupID:   testCallGroupID,
+		CallID:    callID,
+		UserID:    "subscriber",
+		SessionID: random.NewID(),
+	}
+
+	pub, err := newLocalPeer(pubCfg, s.rtcServer, s.log)
+	if err != nil {
+		return res, fmt.Errorf("failed to create publisher: %w", err)
+	}
+	sub, err := newLocalPeer(subCfg, s.rtcServer, s.log)
+	if err != nil {
+		_ = pub.close()
+		return res, fmt.Errorf("failed to create subscriber: %w", err)
+	}
+
+	s.mut.Lock()
+	s.localPeers[pubCfg.SessionID] = pub
+	s.localPeers[subCfg.SessionID] = sub
+	s.mut.Unlock()
+
+	defer func() {
+		for _, p := range []*localPeer{pub, sub} {
+			if err := s.rtcServer.CloseSession(p.cfg.SessionID); err != nil {
+				s.log.Error("failed to close test session", mlog.Err(err), mlog.String("sessionID", p.cfg.SessionID))
+			}
+			if err := p.close(); err != nil {
+				s.log.Error("failed to close test peer", mlog.Err(err), mlog.String("sessionID", p.cfg.SessionID))
+			}
+			s.mut.Lock()
+			delete(s.localPeers, p.cfg.SessionID)
+			s.mut.Unlock()
+		}
+	}()
+
+	var mut sync.Mutex
+	audioCh := make(chan struct{})
+	videoCh := make(chan struct{})
+	sub.pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		isAudio := track.Kind() == webrtc.RTPCodecTypeAudio
+		doneCh := videoCh
+		if isAudio {
+			doneCh = audioCh
+		}
+		for {
+			if _, _, err := track.ReadRTP(); err != nil {
+				return
+			}
+			mut.Lock()
+			count := &res.VideoPackets
+			first := &res.FirstVideo
+			if isAudio {
+				count = &res.AudioPackets
+				first = &res.FirstAudio
+			}
+			if *count == 0 {
+				*first = time.Since(start)
+			}
+			*count++
+			if *count == testCallMinPackets {
+				close(doneCh)
+			}
+			mut.Unlock()
+		}
+	})
+
+	audioTrack, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", random.NewID())
+	if err != nil {
+		return res, fmt.Errorf("failed to create audio track: %w", err)
+	}
+	videoTrack, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}, "video", random.NewID())
+	if err != nil {
+		return res, fmt.Errorf("failed to create video track: %w", err)
+	}
+	for _, track := range []*webrtc.TrackLocalStaticSample{audioTrack, videoTrack} {
+		if _, err := pub.pc.AddTrack(track); err != nil {
+			return res, fmt.Errorf("failed to add track: %w", err)
+		}
+	}
+	// The subscriber only receives but still needs a media section to
+	// negotiate the initial connection.
+	if _, err := sub.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{
+		Direction: webrtc.RTPTransceiverDirectionRecvonly,
+	}); err != nil {
+		return res, fmt.Errorf("failed to add transceiver: %w", err)
+	}
+
+	for _, p := range []*localPeer{pub, sub} {
+		if err := s.rtcServer.InitSession(p.cfg, nil); err != nil {
+			return res, fmt.Errorf("failed to initialize rtc session: %w", err)
+		}
+	}
+
+	screenData, err := json.Marshal(map[string]string{"screenStreamID": videoTrack.StreamID()})
+	if err != nil {
+		return res, fmt.Errorf("failed to marshal screen data: %w", err)
+	}
+	if err := pub.send(rtc.ScreenOnMessage, screenData); err != nil {
+		return res, fmt.Errorf("failed to send screen message: %w", err)
+	}
+
+	for _, p := range []*localPeer{pub, sub} {
+		if err := p.offer(); err != nil {
+			return res, err
+		}
+	}
+
+	for _, p := range []*localPeer{pub, sub} {
+		select {
+		case <-p.connCh:
+		case <-deadline:
+			return res, fmt.Errorf("timed out waiting for %s to connect", p.cfg.UserID)
+		}
+	}
+	res.Connect = time.Since(start)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go writeTestMedia(audioTrack, opusSilenceFrame, testCallAudioFrameMs, stopCh)
+	go writeTestMedia(videoTrack, vp8TestFrame, testCallVideoFrameMs, stopCh)
+
+	for _, ch := range []struct {
+		kind   string
+		doneCh chan struct{}
+	}{{"audio", audioCh}, {"video", videoCh}} {
+		select {
+		case <-ch.doneCh:
+		case <-deadline:
+			mut.Lock()
+			defer mut.Unlock()
+			return res, fmt.Errorf("timed out waiting for %s packets", ch.kind)
+		}
+	}
+
+	mut.Lock()
+	defer mut.Unlock()
+	return res, nil
+}
+
+// writeTestMedia writes the given frame to the track at a fixed interval
+// until stopCh is closed.
+func writeTestMedia(track *webrtc.TrackLocalStaticSample, frame []byte, intervalMs int, stopCh <-chan struct{}) {
+	interval := time.Duration(intervalMs) * time.Millisecond
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := track.WriteSample(media.Sample{Data: frame, Duration: interval}); err != nil {
+				return
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
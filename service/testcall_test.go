@@ -0,0 +1,67 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestCallHandler(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	doRequest := func(t *testing.T, body string) (int, map[string]string) {
+		t.Helper()
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/rtc/test_call", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		return resp.StatusCode, response
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/rtc/test_call", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		code, response := doRequest(t, `{"timeoutSeconds": "3600"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "invalid timeoutSeconds value", response["error"])
+	})
+
+	t.Run("success", func(t *testing.T) {
+		code, response := doRequest(t, "")
+		require.Equal(t, http.StatusOK, code, response["error"])
+
+		for _, key := range []string{"audioPackets", "videoPackets"} {
+			n, err := strconv.Atoi(response[key])
+			require.NoError(t, err)
+			require.GreaterOrEqual(t, n, testCallMinPackets)
+		}
+		require.NotEmpty(t, response["connectMs"])
+
+		// Test sessions are cleaned up once done.
+		th.srvc.mut.RLock()
+		require.Empty(t, th.srvc.localPeers)
+		th.srvc.mut.RUnlock()
+		_, err := th.srvc.rtcServer.GetCallState(testCallGroupID, "")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mattermost/rtcd/service/rtc"
+	"github.com/mattermost/rtcd/service/ws"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// transcriptionDialTimeout bounds the time connecting to the
+	// transcription service can take.
+	transcriptionDialTimeout  = 10 * time.Second
+	transcriptionCaptionsSize = 64
+)
+
+// wsTranscriptionSink is the WebSocket connection to the transcription
+// service of a call. Audio packets are sent msgpack encoded and captions
+// received JSON encoded.
+type wsTranscriptionSink struct {
+	log        mlog.LoggerIFace
+	client     *ws.Client
+	captionsCh chan rtc.Caption
+	closeCh    chan struct{}
+	wg         sync.WaitGroup
+}
+
+// newTranscriptionDialer returns the dialer connecting the rtc server to the
+// transcription service over WebSocket.
+func newTranscriptionDialer(log mlog.LoggerIFace) rtc.TranscriptionDialer {
+	return func(cfg rtc.TranscriptionConfig) (rtc.TranscriptionSink, error) {
+		return dialTranscription(cfg, log)
+	}
+}
+
+func dialTranscription(cfg rtc.TranscriptionConfig, log mlog.LoggerIFace) (*wsTranscriptionSink, error) {
+	client, err := ws.NewClient(ws.ClientConfig{
+		URL:       cfg.URL,
+		AuthToken: cfg.AuthToken,
+	}, ws.WithHandshakeTimeout(transcriptionDialTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &wsTranscriptionSink{
+		log:        log,
+		client:     client,
+		captionsCh: make(chan rtc.Caption, transcriptionCaptionsSize),
+		closeCh:    make(chan struct{}),
+	}
+
+	s.wg.Add(2)
+	go s.reader()
+	go s.errorHandler()
+
+	return s, nil
+}
+
+func (s *wsTranscriptionSink) Send(pkt rtc.TranscriptionPacket) error {
+	data, err := msgpack.Marshal(&pkt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcription packet: %w", err)
+	}
+	return s.client.Send(ws.BinaryMessage, data)
+}
+
+func (s *wsTranscriptionSink) CaptionsCh() <-chan rtc.Caption {
+	return s.captionsCh
+}
+
+func (s *wsTranscriptionSink) reader() {
+	defer s.wg.Done()
+	defer close(s.captionsCh)
+
+	for msg := range s.client.ReceiveCh() {
+		var caption rtc.Caption
+		if err := json.Unmarshal(msg.Data, &caption); err != nil {
+			s.log.Error("failed to unmarshal caption", mlog.Err(err))
+			continue
+		}
+		select {
+		case s.captionsCh <- caption:
+		case <-s.closeCh:
+		}
+	}
+}
+
+func (s *wsTranscriptionSink) errorHandler() {
+	defer s.wg.Done()
+	for err := range s.client.ErrorCh() {
+		s.log.Error("transcription client error", mlog.Err(err))
+	}
+}
+
+// Close closes the connection, dropping the captions not read yet.
+func (s *wsTranscriptionSink) Close() error {
+	close(s.closeCh)
+	err := s.client.Close()
+	s.wg.Wait()
+	return err
+}
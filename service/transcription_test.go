@@ -0,0 +1,155 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/gorilla/websocket"
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// setupTranscriptionService returns the URL of a transcription service
+// captioning every packet, the channel the packets are received on and a
+// function closing the open connections.
+func setupTranscriptionService(t *testing.T) (string, chan rtc.TranscriptionPacket, func()) {
+	t.Helper()
+
+	pktCh := make(chan rtc.TranscriptionPacket, 8)
+	upgrader := websocket.Upgrader{}
+	var conns []*websocket.Conn
+	var mut sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Basic authToken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		mut.Lock()
+		conns = append(conns, conn)
+		mut.Unlock()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var pkt rtc.TranscriptionPacket
+			if err := msgpack.Unmarshal(data, &pkt); err != nil {
+				return
+			}
+			pktCh <- pkt
+
+			caption, _ := json.Marshal(rtc.Caption{
+				SessionID: pkt.SessionID,
+				UserID:    pkt.UserID,
+				Text:      "hello",
+				Final:     true,
+			})
+			if err := conn.WriteMessage(websocket.TextMessage, caption); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	closeConns := func() {
+		mut.Lock()
+		defer mut.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		conns = nil
+	}
+
+	return "ws" + strings.TrimPrefix(srv.URL, "http"), pktCh, closeConns
+}
+
+func TestWSTranscriptionSink(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		err := log.Shutdown()
+		require.NoError(t, err)
+	}()
+
+	u, pktCh, closeConns := setupTranscriptionService(t)
+	dial := newTranscriptionDialer(log)
+
+	t.Run("unauthorized", func(t *testing.T) {
+		sink, err := dial(rtc.TranscriptionConfig{URL: u})
+		require.Error(t, err)
+		require.Nil(t, sink)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		sink, err := dial(rtc.TranscriptionConfig{URL: u, AuthToken: "authToken"})
+		require.NoError(t, err)
+		require.NotNil(t, sink)
+		defer func() {
+			err := sink.Close()
+			require.NoError(t, err)
+		}()
+
+		pkt := rtc.TranscriptionPacket{
+			SessionID:      "sessionID",
+			UserID:         "userID",
+			SequenceNumber: 45,
+			Timestamp:      1000,
+			Payload:        []byte{0x01, 0x02, 0x03},
+		}
+		require.NoError(t, sink.Send(pkt))
+
+		select {
+		case received := <-pktCh:
+			require.Equal(t, pkt, received)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for packet")
+		}
+
+		select {
+		case c := <-sink.CaptionsCh():
+			require.Equal(t, rtc.Caption{
+				SessionID: "sessionID",
+				UserID:    "userID",
+				Text:      "hello",
+				Final:     true,
+			}, c)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for caption")
+		}
+	})
+
+	t.Run("remote close", func(t *testing.T) {
+		sink, err := dial(rtc.TranscriptionConfig{URL: u, AuthToken: "authToken"})
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, sink.Close())
+		}()
+
+		closeConns()
+
+		select {
+		case _, ok := <-sink.CaptionsCh():
+			require.False(t, ok)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for the captions channel to close")
+		}
+	})
+}
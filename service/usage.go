@@ -0,0 +1,136 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/rtcd/service/perf"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// bandwidthUsageStoreKeyPrefix is the prefix of the store keys under which
+// the bandwidth usage of each client is persisted.
+const bandwidthUsageStoreKeyPrefix = "rtcd:bandwidth_usage:"
+
+func (s *Service) getClientsUsage() []perf.ClientUsage {
+	usage := s.rtcServer.GetBandwidthUsage()
+	clientsUsage := make([]perf.ClientUsage, 0, len(usage))
+	for clientID, u := range usage {
+		clientsUsage = append(clientsUsage, perf.ClientUsage{
+			ClientID:     clientID,
+			IngressBytes: u.IngressBytes,
+			EgressBytes:  u.EgressBytes,
+		})
+	}
+	sort.Slice(clientsUsage, func(i, j int) bool {
+		return clientsUsage[i].ClientID < clientsUsage[j].ClientID
+	})
+	return clientsUsage
+}
+
+// loadBandwidthUsage restores the bandwidth usage totals persisted in the
+// store.
+func (s *Service) loadBandwidthUsage() error {
+	keys, err := s.store.Keys()
+	if err != nil {
+		return fmt.Errorf("failed to get keys: %w", err)
+	}
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, bandwidthUsageStoreKeyPrefix) {
+			continue
+		}
+		data, err := s.store.Get(key)
+		if err != nil {
+			return fmt.Errorf("failed to get usage: %w", err)
+		}
+		var usage rtc.BandwidthUsage
+		if err := json.Unmarshal([]byte(data), &usage); err != nil {
+			return fmt.Errorf("failed to unmarshal usage: %w", err)
+		}
+		s.rtcServer.AddBandwidthUsage(strings.TrimPrefix(key, bandwidthUsageStoreKeyPrefix), usage)
+	}
+
+	return nil
+}
+
+// persistBandwidthUsage writes the current bandwidth usage totals to the
+// store.
+func (s *Service) persistBandwidthUsage() error {
+	for clientID, usage := range s.rtcServer.GetBandwidthUsage() {
+		data, err := json.Marshal(usage)
+		if err != nil {
+			return fmt.Errorf("failed to marshal usage: %w", err)
+		}
+		if err := s.store.Set(bandwidthUsageStoreKeyPrefix+clientID, string(data)); err != nil {
+			return fmt.Errorf("failed to store usage: %w", err)
+		}
+	}
+	return nil
+}
+
+// runUsagePersistence periodically persists the bandwidth usage totals.
+func (s *Service) runUsagePersistence(interval time.Duration) {
+	defer close(s.usageDoneCh)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.persistBandwidthUsage(); err != nil {
+				s.log.Error("failed to persist bandwidth usage", mlog.Err(err))
+			}
+		case <-s.usageStopCh:
+			return
+		}
+	}
+}
+
+func (s *Service) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &httpData{
+		reqData: map[string]string{},
+		resData: map[string]string{},
+	}
+	defer s.httpAudit("handleUsage", data, w, r)
+
+	if code, err := s.adminAuthHandler(w, r); err != nil {
+		data.err = err.Error()
+		data.code = code
+		return
+	}
+	data.actor = actorID("")
+
+	usage := s.rtcServer.GetBandwidthUsage()
+	if clientID := r.URL.Query().Get("clientID"); clientID != "" {
+		data.reqData["clientID"] = clientID
+		usage = map[string]rtc.BandwidthUsage{
+			clientID: usage[clientID],
+		}
+	}
+
+	js, err := json.Marshal(usage)
+	if err != nil {
+		data.err = "failed to marshal usage: " + err.Error()
+		data.code = http.StatusInternalServerError
+		return
+	}
+
+	data.code = http.StatusOK
+	data.resData["usage"] = string(js)
+}
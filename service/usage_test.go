@@ -0,0 +1,114 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mattermost/rtcd/service/perf"
+	"github.com/mattermost/rtcd/service/rtc"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageHandler(t *testing.T) {
+	th := SetupTestHelper(t, nil)
+	defer th.Teardown()
+
+	th.srvc.rtcServer.AddBandwidthUsage("clientA", rtc.BandwidthUsage{IngressBytes: 100, EgressBytes: 200})
+	th.srvc.rtcServer.AddBandwidthUsage("clientB", rtc.BandwidthUsage{IngressBytes: 10})
+
+	getUsage := func(t *testing.T, query string) (int, map[string]rtc.BandwidthUsage) {
+		t.Helper()
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/usage"+query, nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", th.srvc.cfg.API.Security.AdminSecretKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return resp.StatusCode, nil
+		}
+		var response map[string]string
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+		var usage map[string]rtc.BandwidthUsage
+		err = json.Unmarshal([]byte(response["usage"]), &usage)
+		require.NoError(t, err)
+		return resp.StatusCode, usage
+	}
+
+	t.Run("invalid method", func(t *testing.T) {
+		req, err := http.NewRequest("POST", th.apiURL+"/admin/usage", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/usage", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("all clients", func(t *testing.T) {
+		code, usage := getUsage(t, "")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, map[string]rtc.BandwidthUsage{
+			"clientA": {IngressBytes: 100, EgressBytes: 200},
+			"clientB": {IngressBytes: 10},
+		}, usage)
+	})
+
+	t.Run("single client", func(t *testing.T) {
+		code, usage := getUsage(t, "?clientID=clientA")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, map[string]rtc.BandwidthUsage{
+			"clientA": {IngressBytes: 100, EgressBytes: 200},
+		}, usage)
+
+		code, usage = getUsage(t, "?clientID=unknown")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, map[string]rtc.BandwidthUsage{
+			"unknown": {},
+		}, usage)
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		require.Equal(t, []perf.ClientUsage{
+			{ClientID: "clientA", IngressBytes: 100, EgressBytes: 200},
+			{ClientID: "clientB", IngressBytes: 10},
+		}, th.srvc.getClientsUsage())
+	})
+}
+
+func TestUsagePersistence(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.Store.UsagePersistIntervalSeconds = 60
+
+	th := SetupTestHelper(t, cfg)
+	th.srvc.rtcServer.AddBandwidthUsage("clientA", rtc.BandwidthUsage{IngressBytes: 100, EgressBytes: 200})
+
+	// Stopping persists the totals.
+	err := th.srvc.Stop()
+	require.NoError(t, err)
+
+	srvc, err := New(*cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]rtc.BandwidthUsage{
+		"clientA": {IngressBytes: 100, EgressBytes: 200},
+	}, srvc.rtcServer.GetBandwidthUsage())
+
+	th.srvc = srvc
+	err = srvc.Start()
+	require.NoError(t, err)
+	th.Teardown()
+}
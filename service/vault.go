@@ -0,0 +1,102 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mattermost/rtcd/service/vault"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+// applySecrets overrides the values in cfg with the ones fetched from Vault.
+// TLS key pairs are written to the files configured for the HTTP API.
+func applySecrets(cfg *Config, secrets vault.Secrets) error {
+	if secrets.AdminSecretKey != "" {
+		cfg.API.Security.AdminSecretKey = secrets.AdminSecretKey
+	}
+
+	if secrets.TURNStaticAuthSecret != "" {
+		cfg.RTC.TURNConfig.StaticAuthSecret = secrets.TURNStaticAuthSecret
+	}
+
+	if secrets.TLSCert != "" || secrets.TLSKey != "" {
+		tlsCfg := cfg.API.HTTP.TLS
+		if secrets.TLSCert == "" || secrets.TLSKey == "" {
+			return fmt.Errorf("both %s and %s should be set", vault.KeyTLSCert, vault.KeyTLSKey)
+		}
+		if tlsCfg.CertFile == "" || tlsCfg.CertKey == "" {
+			return fmt.Errorf("TLS CertFile and CertKey should be set to store the fetched key pair")
+		}
+		if err := writeSecretFile(tlsCfg.CertFile, secrets.TLSCert); err != nil {
+			return fmt.Errorf("failed to write TLS cert: %w", err)
+		}
+		if err := writeSecretFile(tlsCfg.CertKey, secrets.TLSKey); err != nil {
+			return fmt.Errorf("failed to write TLS key: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeSecretFile writes data to the named file, only if its content
+// changed, so that file watchers aren't needlessly triggered.
+func writeSecretFile(name, data string) error {
+	if current, err := os.ReadFile(name); err == nil && string(current) == data {
+		return nil
+	}
+
+	tmpName := name + ".tmp"
+	if err := os.WriteFile(tmpName, []byte(data), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, name)
+}
+
+func (s *Service) getAdminSecretKey() string {
+	s.secretsMut.RLock()
+	defer s.secretsMut.RUnlock()
+	return s.cfg.API.Security.AdminSecretKey
+}
+
+// refreshSecrets periodically fetches the secrets from Vault and applies
+// any change to the running service.
+func (s *Service) refreshSecrets(interval time.Duration) {
+	defer close(s.vaultDoneCh)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			secrets, err := s.vault.FetchSecrets()
+			if err != nil {
+				s.log.Error("failed to fetch secrets from vault", mlog.Err(err))
+				continue
+			}
+
+			s.secretsMut.Lock()
+			cfg := s.cfg
+			err = applySecrets(&cfg, secrets)
+			if err == nil {
+				s.cfg.API.Security.AdminSecretKey = cfg.API.Security.AdminSecretKey
+			}
+			s.secretsMut.Unlock()
+			if err != nil {
+				s.log.Error("failed to apply secrets from vault", mlog.Err(err))
+				continue
+			}
+
+			s.rtcServer.SetTURNStaticAuthSecret(cfg.RTC.TURNConfig.StaticAuthSecret)
+
+			s.log.Debug("refreshed secrets from vault")
+		case <-s.vaultStopCh:
+			return
+		}
+	}
+}
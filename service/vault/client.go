@@ -0,0 +1,188 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Secret keys looked up in the configured Vault secret.
+const (
+	KeyAdminSecretKey       = "admin_secret_key"
+	KeyTURNStaticAuthSecret = "turn_static_auth_secret"
+	KeyTLSCert              = "tls_cert"
+	KeyTLSKey               = "tls_key"
+)
+
+var errPermissionDenied = errors.New("permission denied")
+
+// Secrets holds the values fetched from Vault. Empty fields were not set in
+// the secret.
+type Secrets struct {
+	AdminSecretKey       string
+	TURNStaticAuthSecret string
+	// TLSCert and TLSKey are PEM encoded.
+	TLSCert string
+	TLSKey  string
+}
+
+// Client fetches secrets from a Vault server through its HTTP API.
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+
+	token string
+	mut   sync.Mutex
+}
+
+func NewClient(cfg Config) (*Client, error) {
+	if err := cfg.IsValid(); err != nil {
+		return nil, err
+	}
+	if !cfg.Enable {
+		return nil, errors.New("vault is not enabled")
+	}
+	return &Client{
+		cfg: cfg,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+// FetchSecrets reads the configured secret, logging in if needed.
+func (c *Client) FetchSecrets() (Secrets, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.token == "" {
+		if err := c.login(); err != nil {
+			return Secrets{}, fmt.Errorf("failed to login: %w", err)
+		}
+	}
+
+	data, err := c.readSecret()
+	if errors.Is(err, errPermissionDenied) {
+		// The token may have expired, trying again with a fresh one.
+		if err := c.login(); err != nil {
+			return Secrets{}, fmt.Errorf("failed to login: %w", err)
+		}
+		data, err = c.readSecret()
+	}
+	if err != nil {
+		return Secrets{}, fmt.Errorf("failed to read secret: %w", err)
+	}
+
+	return Secrets{
+		AdminSecretKey:       data[KeyAdminSecretKey],
+		TURNStaticAuthSecret: data[KeyTURNStaticAuthSecret],
+		TLSCert:              data[KeyTLSCert],
+		TLSKey:               data[KeyTLSKey],
+	}, nil
+}
+
+func (c *Client) login() error {
+	if c.cfg.AuthMethod == AuthMethodToken {
+		c.token = c.cfg.Token
+		return nil
+	}
+
+	jwt, err := os.ReadFile(c.cfg.KubernetesTokenPath)
+	if err != nil {
+		return fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"role": c.cfg.KubernetesRole,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal login request: %w", err)
+	}
+
+	var resp struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	path := "auth/" + strings.Trim(c.cfg.KubernetesMountPath, "/") + "/login"
+	if err := c.do(http.MethodPost, path, body, &resp); err != nil {
+		return err
+	}
+	if resp.Auth.ClientToken == "" {
+		return errors.New("empty client token")
+	}
+	c.token = resp.Auth.ClientToken
+
+	return nil
+}
+
+func (c *Client) readSecret() (map[string]string, error) {
+	var resp struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := c.do(http.MethodGet, strings.Trim(c.cfg.SecretPath, "/"), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	data := resp.Data
+	// KV version 2 engines nest the secret data along with its metadata.
+	if nested, ok := data["data"]; ok && data["metadata"] != nil {
+		data = nil
+		if err := json.Unmarshal(nested, &data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal secret data: %w", err)
+		}
+	}
+
+	secrets := make(map[string]string, len(data))
+	for k, v := range data {
+		var str string
+		if err := json.Unmarshal(v, &str); err != nil {
+			return nil, fmt.Errorf("invalid value for key %q: should be a string", k)
+		}
+		secrets[k] = str
+	}
+
+	return secrets, nil
+}
+
+func (c *Client) do(method, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, strings.TrimRight(c.cfg.Address, "/")+"/v1/"+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden {
+		return errPermissionDenied
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request failed with status %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientFetchSecrets(t *testing.T) {
+	var logins int32
+	var validToken atomic.Value
+	validToken.Store("token")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			var req map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			if req["role"] != "rtcd" || req["jwt"] != "jwt" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			atomic.AddInt32(&logins, 1)
+			_, _ = w.Write([]byte(`{"auth": {"client_token": "token"}}`))
+		case "/v1/secret/data/rtcd":
+			if r.Header.Get("X-Vault-Token") != validToken.Load().(string) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"data": {"data": {"admin_secret_key": "adminKey", "turn_static_auth_secret": "turnSecret"}, "metadata": {"version": 1}}}`))
+		case "/v1/kv/rtcd":
+			if r.Header.Get("X-Vault-Token") != validToken.Load().(string) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"data": {"tls_cert": "cert", "tls_key": "key"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := Config{
+		Enable:              true,
+		Address:             srv.URL,
+		AuthMethod:          AuthMethodToken,
+		Token:               "token",
+		KubernetesMountPath: "kubernetes",
+		SecretPath:          "secret/data/rtcd",
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		c, err := NewClient(Config{})
+		require.EqualError(t, err, "vault is not enabled")
+		require.Nil(t, c)
+	})
+
+	t.Run("token auth, kv v2", func(t *testing.T) {
+		c, err := NewClient(cfg)
+		require.NoError(t, err)
+
+		secrets, err := c.FetchSecrets()
+		require.NoError(t, err)
+		require.Equal(t, Secrets{
+			AdminSecretKey:       "adminKey",
+			TURNStaticAuthSecret: "turnSecret",
+		}, secrets)
+	})
+
+	t.Run("kv v1", func(t *testing.T) {
+		cfg := cfg
+		cfg.SecretPath = "/kv/rtcd"
+		c, err := NewClient(cfg)
+		require.NoError(t, err)
+
+		secrets, err := c.FetchSecrets()
+		require.NoError(t, err)
+		require.Equal(t, Secrets{
+			TLSCert: "cert",
+			TLSKey:  "key",
+		}, secrets)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		cfg := cfg
+		cfg.Token = "invalid"
+		c, err := NewClient(cfg)
+		require.NoError(t, err)
+
+		_, err = c.FetchSecrets()
+		require.EqualError(t, err, "failed to read secret: permission denied")
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		cfg := cfg
+		cfg.SecretPath = "secret/data/missing"
+		c, err := NewClient(cfg)
+		require.NoError(t, err)
+
+		_, err = c.FetchSecrets()
+		require.EqualError(t, err, "failed to read secret: request failed with status 404 Not Found")
+	})
+
+	t.Run("kubernetes auth", func(t *testing.T) {
+		tokenPath := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenPath, []byte("jwt\n"), 0600))
+
+		cfg := cfg
+		cfg.AuthMethod = AuthMethodKubernetes
+		cfg.KubernetesRole = "rtcd"
+		cfg.KubernetesTokenPath = tokenPath
+		c, err := NewClient(cfg)
+		require.NoError(t, err)
+
+		secrets, err := c.FetchSecrets()
+		require.NoError(t, err)
+		require.Equal(t, "adminKey", secrets.AdminSecretKey)
+		require.Equal(t, int32(1), atomic.LoadInt32(&logins))
+
+		// The cached token is reused.
+		_, err = c.FetchSecrets()
+		require.NoError(t, err)
+		require.Equal(t, int32(1), atomic.LoadInt32(&logins))
+
+		// An expired token triggers a new login.
+		validToken.Store("newToken")
+		_, err = c.FetchSecrets()
+		require.EqualError(t, err, "failed to read secret: permission denied")
+		require.Equal(t, int32(2), atomic.LoadInt32(&logins))
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package vault
+
+import (
+	"fmt"
+	"net/url"
+)
+
+const (
+	AuthMethodToken      = "token"
+	AuthMethodKubernetes = "kubernetes"
+)
+
+type Config struct {
+	// Enable controls whether secrets should be fetched from Vault.
+	Enable bool `toml:"enable"`
+	// Address is the URL of the Vault server.
+	Address string `toml:"address"`
+	// AuthMethod is the method used to authenticate against Vault, either
+	// "token" or "kubernetes".
+	AuthMethod string `toml:"auth_method"`
+	// Token is the Vault token used with the token auth method.
+	Token string `toml:"token"`
+	// KubernetesRole is the Vault role to login as with the kubernetes auth method.
+	KubernetesRole string `toml:"kubernetes_role"`
+	// KubernetesMountPath is the path the kubernetes auth method is mounted at.
+	KubernetesMountPath string `toml:"kubernetes_mount_path"`
+	// KubernetesTokenPath is the path to the service account token.
+	KubernetesTokenPath string `toml:"kubernetes_token_path"`
+	// SecretPath is the API path of the secret holding the rtcd secrets
+	// (e.g. "secret/data/rtcd" for a KV version 2 engine).
+	SecretPath string `toml:"secret_path"`
+	// RefreshIntervalMinutes is the interval at which secrets are re-fetched.
+	// Set to 0 to only fetch them on startup.
+	RefreshIntervalMinutes int `toml:"refresh_interval_minutes"`
+}
+
+func (c Config) IsValid() error {
+	if !c.Enable {
+		return nil
+	}
+
+	parsed, err := url.Parse(c.Address)
+	if err != nil {
+		return fmt.Errorf("invalid Address value: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("invalid Address value: should use the http or https scheme")
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("invalid Address value: should contain a host")
+	}
+
+	switch c.AuthMethod {
+	case AuthMethodToken:
+		if c.Token == "" {
+			return fmt.Errorf("invalid Token value: should not be empty")
+		}
+	case AuthMethodKubernetes:
+		if c.KubernetesRole == "" {
+			return fmt.Errorf("invalid KubernetesRole value: should not be empty")
+		}
+		if c.KubernetesMountPath == "" {
+			return fmt.Errorf("invalid KubernetesMountPath value: should not be empty")
+		}
+		if c.KubernetesTokenPath == "" {
+			return fmt.Errorf("invalid KubernetesTokenPath value: should not be empty")
+		}
+	default:
+		return fmt.Errorf("invalid AuthMethod value: should be either %q or %q", AuthMethodToken, AuthMethodKubernetes)
+	}
+
+	if c.SecretPath == "" {
+		return fmt.Errorf("invalid SecretPath value: should not be empty")
+	}
+
+	if c.RefreshIntervalMinutes < 0 {
+		return fmt.Errorf("invalid RefreshIntervalMinutes value: should not be negative")
+	}
+
+	return nil
+}
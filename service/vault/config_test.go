@@ -0,0 +1,74 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package vault
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigIsValid(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		var cfg Config
+		require.NoError(t, cfg.IsValid())
+	})
+
+	makeCfg := func() Config {
+		return Config{
+			Enable:              true,
+			Address:             "http://localhost:8200",
+			AuthMethod:          AuthMethodToken,
+			Token:               "token",
+			KubernetesMountPath: "kubernetes",
+			KubernetesTokenPath: "/var/run/secrets/kubernetes.io/serviceaccount/token",
+			SecretPath:          "secret/data/rtcd",
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := makeCfg()
+		require.NoError(t, cfg.IsValid())
+
+		cfg.AuthMethod = AuthMethodKubernetes
+		cfg.KubernetesRole = "rtcd"
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		cfg := makeCfg()
+		cfg.Address = "localhost:8200"
+		require.EqualError(t, cfg.IsValid(), "invalid Address value: should use the http or https scheme")
+	})
+
+	t.Run("invalid auth method", func(t *testing.T) {
+		cfg := makeCfg()
+		cfg.AuthMethod = "invalid"
+		require.EqualError(t, cfg.IsValid(), `invalid AuthMethod value: should be either "token" or "kubernetes"`)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		cfg := makeCfg()
+		cfg.Token = ""
+		require.EqualError(t, cfg.IsValid(), "invalid Token value: should not be empty")
+	})
+
+	t.Run("missing kubernetes role", func(t *testing.T) {
+		cfg := makeCfg()
+		cfg.AuthMethod = AuthMethodKubernetes
+		require.EqualError(t, cfg.IsValid(), "invalid KubernetesRole value: should not be empty")
+	})
+
+	t.Run("missing secret path", func(t *testing.T) {
+		cfg := makeCfg()
+		cfg.SecretPath = ""
+		require.EqualError(t, cfg.IsValid(), "invalid SecretPath value: should not be empty")
+	})
+
+	t.Run("negative refresh interval", func(t *testing.T) {
+		cfg := makeCfg()
+		cfg.RefreshIntervalMinutes = -1
+		require.EqualError(t, cfg.IsValid(), "invalid RefreshIntervalMinutes value: should not be negative")
+	})
+}
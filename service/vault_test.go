@@ -0,0 +1,141 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattermost/rtcd/service/vault"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplySecrets(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		cfg := MakeDefaultCfg(t)
+		expected := *cfg
+		err := applySecrets(cfg, vault.Secrets{})
+		require.NoError(t, err)
+		require.Equal(t, expected, *cfg)
+	})
+
+	t.Run("secrets", func(t *testing.T) {
+		cfg := MakeDefaultCfg(t)
+		err := applySecrets(cfg, vault.Secrets{
+			AdminSecretKey:       "adminKey",
+			TURNStaticAuthSecret: "turnSecret",
+		})
+		require.NoError(t, err)
+		require.Equal(t, "adminKey", cfg.API.Security.AdminSecretKey)
+		require.Equal(t, "turnSecret", cfg.RTC.TURNConfig.StaticAuthSecret)
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		cfg := MakeDefaultCfg(t)
+		err := applySecrets(cfg, vault.Secrets{TLSCert: "cert"})
+		require.EqualError(t, err, "both tls_cert and tls_key should be set")
+
+		err = applySecrets(cfg, vault.Secrets{TLSCert: "cert", TLSKey: "key"})
+		require.EqualError(t, err, "TLS CertFile and CertKey should be set to store the fetched key pair")
+
+		dir := t.TempDir()
+		cfg.API.HTTP.TLS.CertFile = filepath.Join(dir, "cert.pem")
+		cfg.API.HTTP.TLS.CertKey = filepath.Join(dir, "key.pem")
+		err = applySecrets(cfg, vault.Secrets{TLSCert: "cert", TLSKey: "key"})
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(cfg.API.HTTP.TLS.CertFile)
+		require.NoError(t, err)
+		require.Equal(t, "cert", string(data))
+		data, err = os.ReadFile(cfg.API.HTTP.TLS.CertKey)
+		require.NoError(t, err)
+		require.Equal(t, "key", string(data))
+	})
+}
+
+func TestVaultSecrets(t *testing.T) {
+	var adminKey atomic.Value
+	adminKey.Store("vault_admin_key")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/rtcd" || r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"data": {"admin_secret_key": "` + adminKey.Load().(string) + `"}, "metadata": {}}}`))
+	}))
+	defer srv.Close()
+
+	cfg := MakeDefaultCfg(t)
+	cfg.API.Security.AdminSecretKey = ""
+	cfg.Vault = vault.Config{
+		Enable:     true,
+		Address:    srv.URL,
+		AuthMethod: vault.AuthMethodToken,
+		Token:      "token",
+		SecretPath: "secret/data/rtcd",
+	}
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	checkAdminKey := func(key string) int {
+		req, err := http.NewRequest("GET", th.apiURL+"/admin/rtc/sockets", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", key)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, checkAdminKey("vault_admin_key"))
+
+	// Running the refresh loop directly to avoid waiting for minutes.
+	go th.srvc.refreshSecrets(10 * time.Millisecond)
+	defer func() {
+		close(th.srvc.vaultStopCh)
+		<-th.srvc.vaultDoneCh
+	}()
+
+	adminKey.Store("new_admin_key")
+	require.Eventually(t, func() bool {
+		return checkAdminKey("new_admin_key") == http.StatusOK
+	}, 2*time.Second, 20*time.Millisecond)
+	require.Equal(t, http.StatusUnauthorized, checkAdminKey("vault_admin_key"))
+}
+
+func TestVaultRefreshNotStarted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data": {"data": {"admin_secret_key": "vault_admin_key"}, "metadata": {}}}`))
+	}))
+	defer srv.Close()
+
+	cfg := MakeDefaultCfg(t)
+	defer os.RemoveAll(cfg.Store.DataSource)
+	cfg.API.Security.AdminSecretKey = ""
+	cfg.Vault = vault.Config{
+		Enable:                 true,
+		Address:                srv.URL,
+		AuthMethod:             vault.AuthMethodToken,
+		Token:                  "token",
+		SecretPath:             "secret/data/rtcd",
+		RefreshIntervalMinutes: 1,
+	}
+
+	srvc, err := New(*cfg)
+	require.NoError(t, err)
+	require.NoError(t, srvc.Stop())
+
+	// The refresh loop is only run once started.
+	select {
+	case <-srvc.vaultDoneCh:
+		require.Fail(t, "refresh loop should not have run")
+	default:
+	}
+}
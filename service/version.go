@@ -8,6 +8,10 @@ import (
 	"net/http"
 	"runtime"
 
+	"github.com/mattermost/rtcd/service/api"
+	"github.com/mattermost/rtcd/service/fips"
+	"github.com/mattermost/rtcd/service/rtc"
+
 	"github.com/mattermost/mattermost-server/v6/shared/mlog"
 )
 
@@ -22,14 +26,32 @@ type VersionInfo struct {
 	BuildVersion string `json:"buildVersion"`
 	BuildHash    string `json:"buildHash"`
 	GoVersion    string `json:"goVersion"`
+	// CryptoModule is the cryptographic module the process is backed by:
+	// "go", "boringcrypto" or "fips140".
+	CryptoModule string `json:"cryptoModule"`
+	// AESImplementation is the implementation of AES used on this CPU:
+	// "aes-ni", "armv8-ce", "cpacf", "power8" or "software".
+	AESImplementation string `json:"aesImplementation"`
+	// FIPSMode is whether TLS, DTLS and SRTP are restricted to
+	// FIPS-approved algorithms.
+	FIPSMode bool `json:"fipsMode"`
+	// MinAPIVersion and APIVersion are the range of the versions of the
+	// HTTP API served.
+	MinAPIVersion int `json:"minAPIVersion"`
+	APIVersion    int `json:"apiVersion"`
 }
 
-func getVersionInfo() VersionInfo {
+func getVersionInfo(fipsMode bool) VersionInfo {
 	return VersionInfo{
-		BuildDate:    buildDate,
-		BuildVersion: buildVersion,
-		BuildHash:    buildHash,
-		GoVersion:    runtime.Version(),
+		BuildDate:         buildDate,
+		BuildVersion:      buildVersion,
+		BuildHash:         buildHash,
+		GoVersion:         runtime.Version(),
+		CryptoModule:      fips.Module(),
+		AESImplementation: rtc.AESImplementation(),
+		FIPSMode:          fipsMode,
+		MinAPIVersion:     api.MinVersion,
+		APIVersion:        api.CurrentVersion,
 	}
 }
 
@@ -39,6 +61,10 @@ func (v VersionInfo) logFields() []mlog.Field {
 		mlog.String("buildVersion", v.BuildVersion),
 		mlog.String("buildHash", v.BuildHash),
 		mlog.String("goVersion", v.GoVersion),
+		mlog.String("cryptoModule", v.CryptoModule),
+		mlog.String("aesImplementation", v.AESImplementation),
+		mlog.Bool("fipsMode", v.FIPSMode),
+		mlog.Int("apiVersion", v.APIVersion),
 	}
 }
 
@@ -49,7 +75,7 @@ func (s *Service) getVersion(w http.ResponseWriter, req *http.Request) {
 	}
 
 	w.Header().Add("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(getVersionInfo()); err != nil {
+	if err := json.NewEncoder(w).Encode(getVersionInfo(s.cfg.FIPS.Enable)); err != nil {
 		s.log.Error("failed to encode data", mlog.Err(err))
 	}
 }
@@ -9,6 +9,10 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/mattermost/rtcd/service/api"
+	"github.com/mattermost/rtcd/service/fips"
+	"github.com/mattermost/rtcd/service/rtc"
+
 	"github.com/stretchr/testify/require"
 )
 
@@ -40,10 +44,14 @@ func TestGetVersion(t *testing.T) {
 		err = json.NewDecoder(resp.Body).Decode(&info)
 		require.NoError(t, err)
 		require.Equal(t, VersionInfo{
-			BuildHash:    buildHash,
-			BuildDate:    buildDate,
-			BuildVersion: buildVersion,
-			GoVersion:    goVersion,
+			BuildHash:         buildHash,
+			BuildDate:         buildDate,
+			BuildVersion:      buildVersion,
+			GoVersion:         goVersion,
+			CryptoModule:      fips.Module(),
+			AESImplementation: rtc.AESImplementation(),
+			MinAPIVersion:     api.MinVersion,
+			APIVersion:        api.CurrentVersion,
 		}, info)
 	})
 
@@ -57,7 +65,30 @@ func TestGetVersion(t *testing.T) {
 		err = json.NewDecoder(resp.Body).Decode(&info)
 		require.NoError(t, err)
 		require.Equal(t, VersionInfo{
-			GoVersion: goVersion,
+			GoVersion:         goVersion,
+			CryptoModule:      fips.Module(),
+			AESImplementation: rtc.AESImplementation(),
+			MinAPIVersion:     api.MinVersion,
+			APIVersion:        api.CurrentVersion,
 		}, info)
 	})
 }
+
+func TestGetVersionFIPSMode(t *testing.T) {
+	cfg := MakeDefaultCfg(t)
+	cfg.FIPS.Enable = true
+	th := SetupTestHelper(t, cfg)
+	defer th.Teardown()
+
+	require.Equal(t, []string{"AEAD_AES_128_GCM"}, th.srvc.cfg.RTC.SRTPProtectionProfiles)
+
+	resp, err := http.Get(th.apiURL + "/version")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+	var info VersionInfo
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	require.NoError(t, err)
+	require.True(t, info.FIPSMode)
+	require.Equal(t, fips.Module(), info.CryptoModule)
+}
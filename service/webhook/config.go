@@ -0,0 +1,59 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package webhook
+
+import (
+	"fmt"
+	"net/url"
+)
+
+type Config struct {
+	// URLs is the list of endpoints events are posted to. Webhooks are
+	// disabled if empty.
+	URLs []string `toml:"urls"`
+	// SigningKey is the secret used to compute the HMAC-SHA256 signature
+	// sent along with each request.
+	SigningKey string `toml:"signing_key"`
+	// MaxRetries is the maximum number of times a failed delivery is retried.
+	MaxRetries int `toml:"max_retries"`
+	// TimeoutSeconds is the timeout applied to each delivery attempt.
+	TimeoutSeconds int `toml:"timeout_seconds"`
+}
+
+func (c Config) IsEnabled() bool {
+	return len(c.URLs) > 0
+}
+
+func (c Config) IsValid() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+
+	for _, u := range c.URLs {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return fmt.Errorf("invalid URLs value: failed to parse %q: %w", u, err)
+		}
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			return fmt.Errorf("invalid URLs value: %q should use the http or https scheme", u)
+		}
+		if parsed.Host == "" {
+			return fmt.Errorf("invalid URLs value: %q should contain a host", u)
+		}
+	}
+
+	if c.SigningKey == "" {
+		return fmt.Errorf("invalid SigningKey value: should not be empty")
+	}
+
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("invalid MaxRetries value: should not be negative")
+	}
+
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("invalid TimeoutSeconds value: should be a positive number")
+	}
+
+	return nil
+}
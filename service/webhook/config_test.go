@@ -0,0 +1,68 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigIsValid(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var cfg Config
+		require.False(t, cfg.IsEnabled())
+		require.NoError(t, cfg.IsValid())
+	})
+
+	t.Run("invalid URLs", func(t *testing.T) {
+		var cfg Config
+		cfg.URLs = []string{"ftp://localhost"}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid URLs value: "ftp://localhost" should use the http or https scheme`, err.Error())
+
+		cfg.URLs = []string{"http://"}
+		err = cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, `invalid URLs value: "http://" should contain a host`, err.Error())
+	})
+
+	t.Run("invalid SigningKey", func(t *testing.T) {
+		var cfg Config
+		cfg.URLs = []string{"http://localhost"}
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid SigningKey value: should not be empty", err.Error())
+	})
+
+	t.Run("invalid MaxRetries", func(t *testing.T) {
+		var cfg Config
+		cfg.URLs = []string{"http://localhost"}
+		cfg.SigningKey = "key"
+		cfg.MaxRetries = -1
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid MaxRetries value: should not be negative", err.Error())
+	})
+
+	t.Run("invalid TimeoutSeconds", func(t *testing.T) {
+		var cfg Config
+		cfg.URLs = []string{"http://localhost"}
+		cfg.SigningKey = "key"
+		err := cfg.IsValid()
+		require.Error(t, err)
+		require.Equal(t, "invalid TimeoutSeconds value: should be a positive number", err.Error())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg Config
+		cfg.URLs = []string{"http://localhost", "https://example.com/hooks"}
+		cfg.SigningKey = "key"
+		cfg.MaxRetries = 3
+		cfg.TimeoutSeconds = 10
+		require.True(t, cfg.IsEnabled())
+		require.NoError(t, cfg.IsValid())
+	})
+}
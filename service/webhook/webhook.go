@@ -0,0 +1,180 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+)
+
+const (
+	queueSize       = 1024
+	retryBaseDelay  = time.Second
+	retryMaxDelay   = 30 * time.Second
+	SignatureHeader = "X-Rtcd-Signature"
+	TimestampHeader = "X-Rtcd-Timestamp"
+)
+
+// Dispatcher delivers events to the configured webhook endpoints. Deliveries
+// happen asynchronously and are retried with exponential backoff. Each
+// endpoint has its own queue, so that a slow or failing one doesn't delay
+// the deliveries to the others.
+type Dispatcher struct {
+	cfg    Config
+	log    mlog.LoggerIFace
+	client *http.Client
+	// queues holds the queue of each endpoint, in the order of the
+	// configured URLs.
+	queues  []chan []byte
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewDispatcher(cfg Config, log mlog.LoggerIFace) (*Dispatcher, error) {
+	if err := cfg.IsValid(); err != nil {
+		return nil, fmt.Errorf("failed to validate config: %w", err)
+	}
+	if log == nil {
+		return nil, fmt.Errorf("log should not be nil")
+	}
+
+	d := &Dispatcher{
+		cfg: cfg,
+		log: log,
+		client: &http.Client{
+			Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+		},
+		queues:  make([]chan []byte, len(cfg.URLs)),
+		closeCh: make(chan struct{}),
+	}
+
+	for i, u := range cfg.URLs {
+		d.queues[i] = make(chan []byte, queueSize)
+		d.wg.Add(1)
+		go d.worker(u, d.queues[i])
+	}
+
+	return d, nil
+}
+
+// Send queues an event for delivery to every endpoint. It does not block
+// and returns an error listing the endpoints whose queue is full, the event
+// being dropped for those only.
+func (d *Dispatcher) Send(ev interface{}) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	var full []string
+	for i, queueCh := range d.queues {
+		select {
+		case queueCh <- data:
+		default:
+			full = append(full, d.cfg.URLs[i])
+		}
+	}
+
+	if len(full) > 0 {
+		return fmt.Errorf("queue is full for %s", strings.Join(full, ", "))
+	}
+
+	return nil
+}
+
+// Close stops the dispatcher. Pending deliveries are dropped.
+func (d *Dispatcher) Close() {
+	close(d.closeCh)
+	d.wg.Wait()
+}
+
+// worker delivers the events queued for the given endpoint, in order.
+func (d *Dispatcher) worker(u string, queueCh chan []byte) {
+	defer d.wg.Done()
+	for {
+		select {
+		case data := <-queueCh:
+			if err := d.deliver(u, data); err != nil {
+				d.log.Error("failed to deliver webhook", mlog.Err(err), mlog.String("url", u))
+			}
+		case <-d.closeCh:
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(u string, data []byte) error {
+	var err error
+	delay := retryBaseDelay
+	for i := 0; i <= d.cfg.MaxRetries; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(delay):
+			case <-d.closeCh:
+				return fmt.Errorf("dispatcher closed: %w", err)
+			}
+			delay *= 2
+			if delay > retryMaxDelay {
+				delay = retryMaxDelay
+			}
+		}
+
+		if err = d.post(u, data); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("giving up after %d attempts: %w", d.cfg.MaxRetries+1, err)
+}
+
+func (d *Dispatcher) post(u string, data []byte) error {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, ts)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(d.cfg.SigningKey, ts, data))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	// Reading the body to the end lets the connection be reused.
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the timestamp and payload
+// joined by a dot. Receivers should compute the same value and compare it
+// against the signature header.
+func Sign(key, ts string, data []byte) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
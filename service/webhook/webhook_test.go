@@ -0,0 +1,143 @@
+// Copyright (c) 2022-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v6/shared/mlog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatcher(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		err := log.Shutdown()
+		require.NoError(t, err)
+	}()
+
+	t.Run("invalid config", func(t *testing.T) {
+		d, err := NewDispatcher(Config{URLs: []string{"http://localhost"}}, log)
+		require.Error(t, err)
+		require.Nil(t, d)
+	})
+
+	t.Run("signed delivery with retries", func(t *testing.T) {
+		var attempts int32
+		bodyCh := make(chan []byte, 1)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			require.Equal(t, "sha256="+Sign("key", r.Header.Get(TimestampHeader), body), r.Header.Get(SignatureHeader))
+			bodyCh <- body
+		}))
+		defer ts.Close()
+
+		d, err := NewDispatcher(Config{
+			URLs:           []string{ts.URL},
+			SigningKey:     "key",
+			MaxRetries:     1,
+			TimeoutSeconds: 5,
+		}, log)
+		require.NoError(t, err)
+		require.NotNil(t, d)
+		defer d.Close()
+
+		err = d.Send(map[string]string{"type": "call_started"})
+		require.NoError(t, err)
+
+		select {
+		case body := <-bodyCh:
+			require.JSONEq(t, `{"type": "call_started"}`, string(body))
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for delivery")
+		}
+		require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	})
+
+	t.Run("slow endpoint", func(t *testing.T) {
+		unblockCh := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-unblockCh
+		}))
+		defer slow.Close()
+
+		bodyCh := make(chan []byte, 2)
+		fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			bodyCh <- body
+		}))
+		defer fast.Close()
+
+		d, err := NewDispatcher(Config{
+			URLs:           []string{slow.URL, fast.URL},
+			SigningKey:     "key",
+			TimeoutSeconds: 30,
+		}, log)
+		require.NoError(t, err)
+		defer d.Close()
+		defer close(unblockCh)
+
+		require.NoError(t, d.Send(map[string]string{"type": "call_started"}))
+		require.NoError(t, d.Send(map[string]string{"type": "call_ended"}))
+
+		for _, expected := range []string{`{"type": "call_started"}`, `{"type": "call_ended"}`} {
+			select {
+			case body := <-bodyCh:
+				require.JSONEq(t, expected, string(body))
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "timed out waiting for delivery")
+			}
+		}
+	})
+
+	t.Run("full queue", func(t *testing.T) {
+		unblockCh := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-unblockCh
+		}))
+		defer slow.Close()
+
+		fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer fast.Close()
+
+		d, err := NewDispatcher(Config{
+			URLs:           []string{slow.URL, fast.URL},
+			SigningKey:     "key",
+			TimeoutSeconds: 30,
+		}, log)
+		require.NoError(t, err)
+		defer d.Close()
+		defer close(unblockCh)
+
+		// The slow endpoint holds one event while its queue fills up.
+		var sendErr error
+		for i := 0; i < queueSize+2 && sendErr == nil; i++ {
+			sendErr = d.Send(map[string]string{"type": "call_started"})
+		}
+		require.Error(t, sendErr)
+		require.Contains(t, sendErr.Error(), "queue is full for "+slow.URL)
+	})
+}
+
+func TestSign(t *testing.T) {
+	sig := Sign("key", "1672531200", []byte(`{}`))
+	require.Len(t, sig, 64)
+	require.Equal(t, sig, Sign("key", "1672531200", []byte(`{}`)))
+	require.NotEqual(t, sig, Sign("otherKey", "1672531200", []byte(`{}`)))
+	require.NotEqual(t, sig, Sign("key", "1672531201", []byte(`{}`)))
+}
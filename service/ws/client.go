@@ -32,6 +32,8 @@ type Client struct {
 	connState     int32
 	dialFn        DialContextFn
 	pingHandlerFn func(msg string) error
+	// handshakeTimeout, if set, overrides the one of the default dialer.
+	handshakeTimeout time.Duration
 }
 
 // NewClient initializes and returns a new WebSocket client.
@@ -53,14 +55,21 @@ func NewClient(cfg ClientConfig, opts ...ClientOption) (*Client, error) {
 		}
 	}
 
+	authScheme := cfg.AuthScheme
+	if authScheme == "" {
+		authScheme = "Basic"
+	}
 	header := http.Header{
-		"Authorization": []string{"Basic " + cfg.AuthToken},
+		"Authorization": []string{authScheme + " " + cfg.AuthToken},
 	}
 
 	dialer := *websocket.DefaultDialer
 	if c.dialFn != nil {
 		dialer.NetDialContext = c.dialFn
 	}
+	if c.handshakeTimeout > 0 {
+		dialer.HandshakeTimeout = c.handshakeTimeout
+	}
 	ws, _, err := dialer.Dial(cfg.URL, header)
 	if err != nil {
 		return nil, fmt.Errorf("failed to dial: %w", err)
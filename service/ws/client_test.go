@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mattermost/rtcd/service/random"
 
@@ -86,6 +87,35 @@ func TestNewClient(t *testing.T) {
 		require.Nil(t, c)
 		require.Equal(t, "failed to dial: dial error test", err.Error())
 	})
+
+	t.Run("handshake timeout", func(t *testing.T) {
+		// The listener accepts connections but never answers the handshake.
+		ln, err := net.Listen("tcp", "localhost:0")
+		require.NoError(t, err)
+		defer ln.Close()
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+			}
+		}()
+
+		cfg := ClientConfig{
+			URL: "ws://" + ln.Addr().String() + "/ws",
+		}
+		c, err := NewClient(cfg, WithHandshakeTimeout(0))
+		require.EqualError(t, err, "failed to apply option: invalid handshake timeout: should be positive")
+		require.Nil(t, c)
+
+		start := time.Now()
+		c, err = NewClient(cfg, WithHandshakeTimeout(100*time.Millisecond))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
 }
 
 func TestNewClientWithAuth(t *testing.T) {
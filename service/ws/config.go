@@ -46,6 +46,9 @@ type ClientConfig struct {
 	// AuthToken specifies the token to be used to authenticate
 	// the connection.
 	AuthToken string
+	// AuthScheme specifies the scheme of the Authorization header carrying
+	// AuthToken. Defaults to Basic if empty.
+	AuthScheme string
 }
 
 func (c ClientConfig) IsValid() error {
@@ -5,7 +5,9 @@ package ws
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"time"
 )
 
 type ServerOption func(s *Server) error
@@ -21,6 +23,19 @@ func WithAuthCb(cb AuthCb) ServerOption {
 	}
 }
 
+// WithDisconnectPercent makes the server drop, as a network failure would,
+// the connections the given percentage of the messages are sent to or
+// received from. Meant for testing the reconnection logic of clients.
+func WithDisconnectPercent(percent int) ServerOption {
+	return func(s *Server) error {
+		if percent < 0 || percent > 100 {
+			return fmt.Errorf("invalid disconnect percentage: should be in the range [0, 100]")
+		}
+		s.disconnectPercent = percent
+		return nil
+	}
+}
+
 // WithDialFunc lets the caller set an optional dialing function to setup the
 // TCP connection needed by the client.
 func WithDialFunc(dialFn DialContextFn) ClientOption {
@@ -29,3 +44,15 @@ func WithDialFunc(dialFn DialContextFn) ClientOption {
 		return nil
 	}
 }
+
+// WithHandshakeTimeout bounds the time the client is given to connect,
+// including the WebSocket handshake. Defaults to 45 seconds.
+func WithHandshakeTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) error {
+		if timeout <= 0 {
+			return fmt.Errorf("invalid handshake timeout: should be positive")
+		}
+		c.handshakeTimeout = timeout
+		return nil
+	}
+}
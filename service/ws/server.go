@@ -5,6 +5,7 @@ package ws
 
 import (
 	"fmt"
+	"math/rand"
 	"net/http"
 	"sync"
 	"time"
@@ -32,6 +33,9 @@ type Server struct {
 	sendCh    chan Message
 	receiveCh chan Message
 	closed    bool
+	// disconnectPercent is the percentage of the messages sent or received
+	// after which the connection gets dropped on purpose.
+	disconnectPercent int
 }
 
 // NewServer initializes and returns a new WebSocket server.
@@ -59,8 +63,16 @@ func NewServer(cfg ServerConfig, log mlog.LoggerIFace, opts ...ServerOption) (*S
 	return s, nil
 }
 
-// SendCh queues a message to be sent through a ws connection.
+// SendCh queues a message to be sent through a ws connection. It fails
+// once the server is closed.
 func (s *Server) Send(msg Message) error {
+	// Holding the lock keeps Close from closing the channel in between.
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	if s.closed {
+		return fmt.Errorf("failed to send ws message, server is closed")
+	}
+
 	select {
 	case s.sendCh <- msg:
 	default:
@@ -74,8 +86,16 @@ func (s *Server) ReceiveCh() <-chan Message {
 	return s.receiveCh
 }
 
+// GetChannelsDepth returns the number of messages queued in the server's
+// internal channels, keyed by name.
+func (s *Server) GetChannelsDepth() map[string]int {
+	return map[string]int{
+		"send":    len(s.sendCh),
+		"receive": len(s.receiveCh),
+	}
+}
+
 // Close stops the websocket server and closes all the ws connections.
-// Must be called once all senders are done.
 func (s *Server) Close() {
 	s.mut.Lock()
 	if s.closed {
@@ -170,6 +190,12 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
+		if s.shouldDisconnect() {
+			s.log.Debug("dropping ws conn on purpose", mlog.String("connID", connID))
+			_ = ws.UnderlyingConn().Close()
+			break
+		}
+
 		s.receiveCh <- Message{
 			ConnID:   connID,
 			ClientID: conn.clientID,
@@ -196,6 +222,13 @@ func (s *Server) connWriter() {
 				continue
 			}
 
+			if s.shouldDisconnect() {
+				// The reader fails and cleans up after the conn.
+				s.log.Debug("dropping ws conn on purpose", mlog.String("connID", msg.ConnID))
+				_ = conn.ws.UnderlyingConn().Close()
+				continue
+			}
+
 			var msgType int
 			switch msg.Type {
 			case TextMessage:
@@ -229,6 +262,12 @@ func (s *Server) connWriter() {
 	}
 }
 
+// shouldDisconnect returns whether a connection should be dropped, as set
+// through WithDisconnectPercent.
+func (s *Server) shouldDisconnect() bool {
+	return s.disconnectPercent > 0 && rand.Intn(100) < s.disconnectPercent
+}
+
 func (s *Server) isClosed() bool {
 	s.mut.RLock()
 	defer s.mut.RUnlock()
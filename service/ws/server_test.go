@@ -399,6 +399,31 @@ func TestRaceReceiveClose(t *testing.T) {
 	wg.Wait()
 }
 
+func TestRaceSendClose(t *testing.T) {
+	server, _, shutdown := setupServer(t)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_ = server.Send(Message{ConnID: "connID", Type: TextMessage, Data: []byte("data")})
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		server.Close()
+		shutdown()
+	}()
+
+	wg.Wait()
+
+	err := server.Send(Message{ConnID: "connID", Type: TextMessage, Data: []byte("data")})
+	require.EqualError(t, err, "failed to send ws message, server is closed")
+}
+
 func TestRaceConnectClose(t *testing.T) {
 	_, addr, shutdown := setupServer(t)
 	setupClient := func(t *testing.T, addr string) *Client {
@@ -477,3 +502,40 @@ func TestSendMessages(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestWithDisconnectPercent(t *testing.T) {
+	log, err := mlog.NewLogger()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, log.Shutdown())
+	}()
+	cfg := ServerConfig{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		PingInterval:    time.Second,
+	}
+	_, err = NewServer(cfg, log, WithDisconnectPercent(101))
+	require.EqualError(t, err, "failed to apply option: invalid disconnect percentage: should be in the range [0, 100]")
+
+	s, addr, shutdown := setupServer(t, WithDisconnectPercent(100))
+	defer shutdown()
+
+	_, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	u := url.URL{Scheme: "ws", Host: "localhost:" + port, Path: "/ws"}
+	ws, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	msg := <-s.ReceiveCh()
+	require.Equal(t, OpenMessage, msg.Type)
+
+	err = ws.WriteMessage(websocket.TextMessage, []byte("data"))
+	require.NoError(t, err)
+
+	// The message is dropped along with the conn.
+	msg = <-s.ReceiveCh()
+	require.Equal(t, CloseMessage, msg.Type)
+	_, _, err = ws.ReadMessage()
+	require.Error(t, err)
+}
@@ -0,0 +1,38 @@
+//go:build !js
+// +build !js
+
+package webrtc
+
+import (
+	"errors"
+
+	"github.com/pion/dtls/v2"
+)
+
+// errDTLSTransportNotConnected is returned when the keying material of a
+// transport is requested before its DTLS association is established.
+var errDTLSTransportNotConnected = errors.New("DTLS transport is not connected")
+
+// SelectedSRTPProtectionProfile returns the SRTP protection profile
+// negotiated by the DTLS association, if any.
+func (t *DTLSTransport) SelectedSRTPProtectionProfile() (dtls.SRTPProtectionProfile, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	if t.conn == nil {
+		return 0, false
+	}
+	return t.conn.SelectedSRTPProtectionProfile()
+}
+
+// ExportKeyingMaterial exports keying material from the DTLS association
+// (RFC 5705), e.g. the SRTP master keys and salts with the
+// "EXTRACTOR-dtls_srtp" label (RFC 5764 4.2).
+func (t *DTLSTransport) ExportKeyingMaterial(label string, context []byte, length int) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	if t.conn == nil {
+		return nil, errDTLSTransportNotConnected
+	}
+	state := t.conn.ConnectionState()
+	return state.ExportKeyingMaterial(label, context, length)
+}
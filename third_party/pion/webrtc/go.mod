@@ -0,0 +1,24 @@
+module github.com/pion/webrtc/v3
+
+go 1.13
+
+require (
+	github.com/onsi/ginkgo v1.16.5 // indirect
+	github.com/onsi/gomega v1.17.0 // indirect
+	github.com/pion/datachannel v1.5.2
+	github.com/pion/dtls/v2 v2.1.5
+	github.com/pion/ice/v2 v2.2.6
+	github.com/pion/interceptor v0.1.11
+	github.com/pion/logging v0.2.2
+	github.com/pion/randutil v0.1.0
+	github.com/pion/rtcp v1.2.9
+	github.com/pion/rtp v1.7.13
+	github.com/pion/sctp v1.8.2
+	github.com/pion/sdp/v3 v3.0.5
+	github.com/pion/srtp/v2 v2.0.7
+	github.com/pion/transport v0.13.0
+	github.com/sclevine/agouti v3.0.0+incompatible
+	github.com/stretchr/testify v1.7.1
+	golang.org/x/crypto v0.0.0-20220516162934-403b01795ae8 // indirect
+	golang.org/x/net v0.0.0-20220425223048-2871e0cb64e4
+)